@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -17,6 +23,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/ppiankov/logtap/internal/forward"
+	"github.com/ppiankov/logtap/internal/logtypes"
 )
 
 const (
@@ -27,14 +34,59 @@ const (
 	envBufferSize    = "LOGTAP_BUFFER_SIZE"
 	envRetryMax      = "LOGTAP_RETRY_MAX"
 	envTLSSkipVerify = "LOGTAP_TLS_SKIP_VERIFY"
+	envTLSPin        = "LOGTAP_TLS_PIN"
+	envSink          = "LOGTAP_SINK"
+	envReadyWindow   = "LOGTAP_READY_WINDOW"
+	envSanitize      = "LOGTAP_SANITIZE"
+	envPushEncoding  = "LOGTAP_PUSH_ENCODING"
+	envGRPCTarget    = "LOGTAP_GRPC_TARGET"
+	envSpillDir      = "LOGTAP_SPILL_DIR"
+	envSpillSize     = "LOGTAP_SPILL_SIZE"
+	envPushRate      = "LOGTAP_PUSH_RATE"
+	envPushJitter    = "LOGTAP_PUSH_JITTER"
+	envRetryBase     = "LOGTAP_RETRY_BASE"
+	envRetryMaxWait  = "LOGTAP_RETRY_MAX_BACKOFF"
+	envRetryJitter   = "LOGTAP_RETRY_JITTER"
+	envBreakerAfter  = "LOGTAP_BREAKER_THRESHOLD"
+	envBreakerPause  = "LOGTAP_BREAKER_COOLDOWN"
+	envMultiline     = "LOGTAP_MULTILINE_PATTERN"
+	envContainers    = "LOGTAP_CONTAINERS"
+	envExcludeConts  = "LOGTAP_EXCLUDE_CONTAINERS"
+	envSampleRate    = "LOGTAP_SAMPLE_RATE"
+	envMaxLineRate   = "LOGTAP_MAX_LINES_PER_SEC"
+	envGrep          = "LOGTAP_GREP"
+	envGrepExclude   = "LOGTAP_GREP_EXCLUDE"
+	envJSONLabels    = "LOGTAP_JSON_LABELS"
+	envAuthToken     = "LOGTAP_AUTH_TOKEN"
 
 	defaultHealthAddr    = ":9091"
 	defaultBatchSize     = 100
 	defaultFlushInterval = 500 * time.Millisecond
 	defaultBufferSize    = 1 << 20 // 1MB
 	defaultRetryMax      = 10
+	defaultSpillSize     = 256 << 20 // 256MB
+	defaultRetryJitter   = 0.2
+	defaultBreakerAfter  = 5
+	defaultBreakerPause  = 30 * time.Second
+	closeTimeout         = 5 * time.Second
 )
 
+// set by -ldflags at build time
+var (
+	version = "dev"
+	commit  = "none"
+)
+
+// clientInfo identifies this forwarder build to the receiver.
+func clientInfo() logtypes.ClientInfo {
+	return logtypes.ClientInfo{
+		Name:     "logtap-forwarder",
+		Version:  version,
+		Commit:   commit,
+		Protocol: logtypes.ProtocolVersion,
+	}
+}
+
 type Config struct {
 	Target        string
 	Session       string
@@ -44,20 +96,51 @@ type Config struct {
 	BufferSize    int
 	MaxRetries    int
 	TLSSkipVerify bool
+	TLSPin        string // SHA-256 fingerprint the receiver's certificate must match
+	ReadyWindow   time.Duration
+	Sanitize      forward.Sanitizer
+	PushEncoding  string
+	GRPCTarget    string // receiver push stream address; replaces HTTP pushes when set
+	Sink          string // URL of an alternate sink (kafka://, nats://, file://) used instead of a receiver
+	SpillDir      string // buffer overflow goes to disk here when set
+	SpillSize     int64
+	PushRate      float64 // max pushes per second; 0 disables pacing
+	PushJitter    float64 // fraction of the pacing interval randomized
+	RetryBackoff  forward.Backoff
+	// BreakerThreshold consecutive failed pushes open the circuit breaker
+	// for BreakerCooldown; 0 disables it.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// MultilinePattern matches the first line of a record; following lines
+	// that do not match are stitched onto it. Empty disables stitching.
+	MultilinePattern string
+	// Containers limits the followed containers; ExcludeContainers drops
+	// some, e.g. istio-proxy. Both are comma lists in the env.
+	Containers forward.ContainerFilter
+	// SampleRate is the fraction of lines forwarded (1 keeps all);
+	// MaxLinesPerSec caps the forwarded line rate (0 disables it).
+	SampleRate     float64
+	MaxLinesPerSec float64
+	// Grep keeps only lines matching it; GrepExclude drops lines matching
+	// it. Both are regular expressions; empty disables them.
+	Grep        string
+	GrepExclude string
+	// JSONLabels promotes fields of JSON log lines to push labels; nil
+	// disables it.
+	JSONLabels *forward.JSONLabels
+	// AuthToken is sent as a bearer token with every push.
+	AuthToken string
 }
 
 type logReader interface {
 	FollowAll(ctx context.Context, out chan<- forward.LogLine) error
 }
 
-type logPusher interface {
-	Push(ctx context.Context, labels map[string]string, lines []forward.TimestampedLine) error
-}
-
 type Dependencies struct {
 	NewReader func(podName, namespace string) (logReader, error)
-	NewPusher func(target string) logPusher
+	NewPusher func(target string) forward.Sink
 	LogWriter io.Writer
+	Readiness *forward.Readiness
 }
 
 func main() {
@@ -67,8 +150,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	fmt.Fprintf(os.Stderr, "logtap-forwarder starting: session=%s target=%s pod=%s/%s\n",
-		cfg.Session, cfg.Target, cfg.Namespace, cfg.PodName)
+	encoding := cfg.PushEncoding
+	if cfg.GRPCTarget != "" {
+		encoding = "stream:" + cfg.GRPCTarget
+	}
+	target := cfg.Target
+	if u, err := url.Parse(cfg.Sink); err == nil && cfg.Sink != "" {
+		target, encoding = u.Redacted(), "jsonl" // the URL may carry credentials
+	}
+	fmt.Fprintf(os.Stderr, "logtap-forwarder starting: session=%s target=%s pod=%s/%s sanitize=%s encoding=%s\n",
+		cfg.Session, target, cfg.Namespace, cfg.PodName, cfg.Sanitize, encoding)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -81,11 +172,12 @@ func main() {
 		cancel()
 	}()
 
-	if _, err := startHealthServer(ctx, cfg.HealthAddr, os.Stderr); err != nil {
+	ready := forward.NewReadiness(cfg.ReadyWindow)
+	if _, err := startHealthServer(ctx, cfg.HealthAddr, ready, os.Stderr); err != nil {
 		fmt.Fprintf(os.Stderr, "health server: %v\n", err)
 	}
 
-	if err := run(ctx, cfg, Dependencies{}); err != nil {
+	if err := run(ctx, cfg, Dependencies{Readiness: ready}); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -93,13 +185,35 @@ func main() {
 
 func loadConfigFromEnv(getenv func(string) string) (Config, error) {
 	cfg := Config{
-		Target:     getenv(envTarget),
-		Session:    getenv(envSession),
-		PodName:    getenv(envPodName),
-		Namespace:  getenv(envNamespace),
-		HealthAddr: defaultHealthAddr,
-		BufferSize: defaultBufferSize,
-		MaxRetries: defaultRetryMax,
+		Target:       getenv(envTarget),
+		Session:      getenv(envSession),
+		PodName:      getenv(envPodName),
+		Namespace:    getenv(envNamespace),
+		HealthAddr:   defaultHealthAddr,
+		BufferSize:   defaultBufferSize,
+		MaxRetries:   defaultRetryMax,
+		PushEncoding: forward.EncodingProtobuf,
+		GRPCTarget:   getenv(envGRPCTarget),
+		Sink:         getenv(envSink),
+		SpillDir:     getenv(envSpillDir),
+		SpillSize:    defaultSpillSize,
+		PushJitter:   forward.DefaultPacingJitter,
+		RetryBackoff: forward.Backoff{
+			Base:   forward.DefaultBackoff.Base,
+			Max:    forward.DefaultBackoff.Max,
+			Jitter: defaultRetryJitter,
+		},
+		BreakerThreshold: defaultBreakerAfter,
+		BreakerCooldown:  defaultBreakerPause,
+		MultilinePattern: getenv(envMultiline),
+		Grep:             getenv(envGrep),
+		GrepExclude:      getenv(envGrepExclude),
+		AuthToken:        getenv(envAuthToken),
+		Containers: forward.ContainerFilter{
+			Include: splitList(getenv(envContainers)),
+			Exclude: splitList(getenv(envExcludeConts)),
+		},
+		SampleRate: 1,
 	}
 	if v := getenv(envBufferSize); v != "" {
 		n, err := strconv.Atoi(v)
@@ -115,17 +229,148 @@ func loadConfigFromEnv(getenv func(string) string) (Config, error) {
 		}
 		cfg.MaxRetries = n
 	}
+	if v := getenv(envSpillSize); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid %s: %q", envSpillSize, v)
+		}
+		cfg.SpillSize = n
+	}
+	if v := getenv(envPushRate); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r <= 0 {
+			return Config{}, fmt.Errorf("invalid %s: %q", envPushRate, v)
+		}
+		cfg.PushRate = r
+	}
+	if v := getenv(envPushJitter); v != "" {
+		j, err := strconv.ParseFloat(v, 64)
+		if err != nil || j < 0 || j > 1 {
+			return Config{}, fmt.Errorf("invalid %s: %q (want 0 to 1)", envPushJitter, v)
+		}
+		cfg.PushJitter = j
+	}
+	for _, d := range []struct {
+		env string
+		dst *time.Duration
+	}{
+		{envRetryBase, &cfg.RetryBackoff.Base},
+		{envRetryMaxWait, &cfg.RetryBackoff.Max},
+		{envBreakerPause, &cfg.BreakerCooldown},
+	} {
+		if v := getenv(d.env); v != "" {
+			dur, err := time.ParseDuration(v)
+			if err != nil || dur <= 0 {
+				return Config{}, fmt.Errorf("invalid %s: %q", d.env, v)
+			}
+			*d.dst = dur
+		}
+	}
+	if v := getenv(envRetryJitter); v != "" {
+		j, err := strconv.ParseFloat(v, 64)
+		if err != nil || j < 0 || j > 1 {
+			return Config{}, fmt.Errorf("invalid %s: %q (want 0 to 1)", envRetryJitter, v)
+		}
+		cfg.RetryBackoff.Jitter = j
+	}
+	if v := getenv(envBreakerAfter); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("invalid %s: %q", envBreakerAfter, v)
+		}
+		cfg.BreakerThreshold = n
+	}
+	if v := getenv(envSampleRate); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r <= 0 || r > 1 {
+			return Config{}, fmt.Errorf("invalid %s: %q (want a fraction in (0, 1])", envSampleRate, v)
+		}
+		cfg.SampleRate = r
+	}
+	if v := getenv(envMaxLineRate); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 {
+			return Config{}, fmt.Errorf("invalid %s: %q", envMaxLineRate, v)
+		}
+		cfg.MaxLinesPerSec = r
+	}
 	if v := getenv(envTLSSkipVerify); v == "1" || v == "true" {
 		cfg.TLSSkipVerify = true
 	}
+	if v := getenv(envTLSPin); v != "" {
+		if _, err := forward.ParsePin(v); err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", envTLSPin, err)
+		}
+		cfg.TLSPin = v
+	}
+	if v := getenv(envReadyWindow); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", envReadyWindow, err)
+		}
+		cfg.ReadyWindow = d
+	}
+	if v := getenv(envSanitize); v != "" {
+		s, err := forward.ParseSanitize(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", envSanitize, err)
+		}
+		cfg.Sanitize = s
+	}
+	if v := getenv(envJSONLabels); v != "" {
+		j, err := forward.ParseJSONLabels(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", envJSONLabels, err)
+		}
+		cfg.JSONLabels = j
+	}
+	if v := getenv(envPushEncoding); v != "" {
+		enc, err := forward.ParseEncoding(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", envPushEncoding, err)
+		}
+		cfg.PushEncoding = enc
+	}
+	if cfg.MultilinePattern != "" {
+		if _, err := regexp.Compile(cfg.MultilinePattern); err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", envMultiline, err)
+		}
+	}
+	for _, p := range []struct{ env, pattern string }{{envGrep, cfg.Grep}, {envGrepExclude, cfg.GrepExclude}} {
+		if _, err := regexp.Compile(p.pattern); err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", p.env, err)
+		}
+	}
 	if err := validateConfig(cfg); err != nil {
 		return Config{}, err
 	}
 	return cfg, nil
 }
 
+// splitList splits a comma-separated env value, dropping empty items.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func validateConfig(cfg Config) error {
-	if cfg.Target == "" {
+	if cfg.Sink != "" {
+		u, err := url.Parse(cfg.Sink)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", envSink, err)
+		}
+		if !slices.Contains(forward.SinkSchemes(), u.Scheme) {
+			return fmt.Errorf("invalid %s: unknown sink %q (available: %s)", envSink, u.Scheme, strings.Join(forward.SinkSchemes(), ", "))
+		}
+		if cfg.GRPCTarget != "" {
+			return fmt.Errorf("%s and %s are mutually exclusive", envSink, envGRPCTarget)
+		}
+	} else if cfg.Target == "" {
 		return fmt.Errorf("required env var %s not set", envTarget)
 	}
 	if cfg.Session == "" {
@@ -153,33 +398,76 @@ var (
 		Name: "logtap_forwarder_drops_total",
 		Help: "Total number of batches dropped due to buffer overflow.",
 	})
+	backpressureTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "logtap_forwarder_backpressure_total",
+		Help: "Total number of backpressure signals from the receiver push stream.",
+	})
+	spillUsage = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "logtap_forwarder_spill_usage_bytes",
+		Help: "Current disk spill usage in bytes.",
+	})
+	pacingDelay = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "logtap_forwarder_pacing_delay_seconds",
+		Help:    "Time pushes waited for LOGTAP_PUSH_RATE pacing.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	})
+	circuitState = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "logtap_forwarder_circuit_state",
+		Help: "Push circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	})
+	circuitOpensTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "logtap_forwarder_circuit_opens_total",
+		Help: "Total number of times the push circuit breaker opened.",
+	})
+	linesDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logtap_forwarder_lines_dropped_total",
+		Help: "Total number of lines not forwarded, by reason: filtered (LOGTAP_GREP, LOGTAP_GREP_EXCLUDE), sampled (LOGTAP_SAMPLE_RATE) or rate_limited (LOGTAP_MAX_LINES_PER_SEC).",
+	}, []string{"reason"})
 )
 
 func init() {
-	prometheus.MustRegister(retriesTotal, bufferUsage, dropsTotal)
+	prometheus.MustRegister(retriesTotal, bufferUsage, dropsTotal, backpressureTotal, spillUsage, pacingDelay,
+		circuitState, circuitOpensTotal, linesDroppedTotal)
 }
 
-func healthHandler() http.Handler {
+// healthHandler serves /healthz (process up) and /readyz (push pipeline
+// delivering). A nil ready always reports ready.
+func healthHandler(ready *forward.Readiness) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		_, _ = w.Write([]byte(`{"status":"ok"}`))
 	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		resp := struct {
+			Status string `json:"status"`
+			forward.ReadinessStatus
+		}{Status: "ok", ReadinessStatus: forward.ReadinessStatus{Ready: true}}
+		if ready != nil {
+			resp.ReadinessStatus = ready.Status()
+		}
+		if !resp.Ready {
+			resp.Status = "not_ready"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
 	mux.Handle("GET /metrics", promhttp.Handler())
 	return mux
 }
 
-func startHealthServer(ctx context.Context, addr string, log io.Writer) (string, error) {
+func startHealthServer(ctx context.Context, addr string, ready *forward.Readiness, log io.Writer) (string, error) {
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return "", err
 	}
-	return startHealthServerWithListener(ctx, ln, log)
+	return startHealthServerWithListener(ctx, ln, ready, log)
 }
 
-func startHealthServerWithListener(ctx context.Context, ln net.Listener, log io.Writer) (string, error) {
+func startHealthServerWithListener(ctx context.Context, ln net.Listener, ready *forward.Readiness, log io.Writer) (string, error) {
 	srv := &http.Server{
-		Handler:      healthHandler(),
+		Handler:      healthHandler(ready),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  120 * time.Second,
@@ -205,13 +493,20 @@ func run(ctx context.Context, cfg Config, deps Dependencies) error {
 	}
 	if deps.NewReader == nil {
 		deps.NewReader = func(podName, namespace string) (logReader, error) {
-			return forward.NewReader(podName, namespace)
+			r, err := forward.NewReader(podName, namespace)
+			if err != nil {
+				return nil, err
+			}
+			r.SetContainerFilter(cfg.Containers)
+			return r, nil
 		}
 	}
 	if deps.NewPusher == nil {
-		deps.NewPusher = func(target string) logPusher {
-			if cfg.TLSSkipVerify || strings.HasPrefix(target, "https://") {
-				return forward.NewTLSPusher(target, cfg.TLSSkipVerify)
+		deps.NewPusher = func(target string) forward.Sink {
+			if cfg.TLSSkipVerify || cfg.TLSPin != "" || strings.HasPrefix(target, "https://") {
+				// the pin was validated when the config was read
+				tlsCfg, _ := forward.ClientTLSConfig(cfg.TLSSkipVerify, cfg.TLSPin)
+				return forward.NewTLSConfigPusher(target, tlsCfg)
 			}
 			return forward.NewPusher(target)
 		}
@@ -219,13 +514,35 @@ func run(ctx context.Context, cfg Config, deps Dependencies) error {
 	if deps.LogWriter == nil {
 		deps.LogWriter = os.Stderr
 	}
+	if deps.Readiness == nil {
+		deps.Readiness = forward.NewReadiness(cfg.ReadyWindow)
+	}
+	ready := deps.Readiness
 
 	reader, err := deps.NewReader(cfg.PodName, cfg.Namespace)
 	if err != nil {
 		return fmt.Errorf("init reader: %w", err)
 	}
 
-	pusher := deps.NewPusher(cfg.Target)
+	var pusher forward.Sink
+	switch {
+	case cfg.Sink != "":
+		if pusher, err = forward.OpenSink(cfg.Sink); err != nil {
+			return err
+		}
+	case cfg.GRPCTarget != "":
+		tlsCfg, err := forward.ClientTLSConfig(cfg.TLSSkipVerify, cfg.TLSPin)
+		if err != nil {
+			return err
+		}
+		sp, err := forward.NewTLSConfigStreamPusher(cfg.GRPCTarget, tlsCfg)
+		if err != nil {
+			return err
+		}
+		pusher = sp
+	default:
+		pusher = deps.NewPusher(cfg.Target)
+	}
 
 	// apply defaults for zero-valued config
 	bufSize := cfg.BufferSize
@@ -237,13 +554,128 @@ func run(ctx context.Context, cfg Config, deps Dependencies) error {
 		maxRetries = defaultRetryMax
 	}
 
+	retry := cfg.RetryBackoff
+	if retry.Base <= 0 || retry.Max <= 0 {
+		retry = forward.DefaultBackoff
+	}
+	var breaker *forward.Breaker
+	if cfg.BreakerThreshold > 0 {
+		cooldown := cfg.BreakerCooldown
+		if cooldown <= 0 {
+			cooldown = defaultBreakerPause
+		}
+		if breaker, err = forward.NewBreaker(cfg.BreakerThreshold, cooldown); err != nil {
+			return err
+		}
+		breaker.SetOnChange(func(s forward.BreakerState) {
+			circuitState.Set(float64(s))
+			if s == forward.BreakerOpen {
+				circuitOpensTotal.Inc()
+			}
+			_, _ = fmt.Fprintf(deps.LogWriter, "push circuit breaker %s\n", s)
+		})
+	}
+
+	incompatible := func(protocol int) {
+		_, _ = fmt.Fprintf(deps.LogWriter, "WARNING: receiver speaks push protocol %d, this forwarder needs %d or later; upgrade the receiver\n",
+			protocol, logtypes.MinProtocolVersion)
+	}
+
 	// configure retry and buffer
 	if p, ok := pusher.(*forward.Pusher); ok {
 		p.SetMaxRetries(maxRetries)
+		p.SetBackoff(retry)
+		p.SetBreaker(breaker)
+		p.SetOnRetry(func() { retriesTotal.Inc() })
+		p.SetAuthToken(cfg.AuthToken)
+		p.SetClientInfo(clientInfo())
+		p.SetOnIncompatible(incompatible)
+		if cfg.PushEncoding != "" {
+			if err := p.SetEncoding(cfg.PushEncoding); err != nil {
+				return err
+			}
+		}
+	}
+	if p, ok := pusher.(*forward.StreamPusher); ok {
+		p.SetMaxRetries(maxRetries)
+		p.SetBackoff(retry)
+		p.SetBreaker(breaker)
 		p.SetOnRetry(func() { retriesTotal.Inc() })
+		p.SetOnBackpressure(func() { backpressureTotal.Inc() })
+		p.SetAuthToken(cfg.AuthToken)
+		p.SetClientInfo(clientInfo())
+		p.SetOnIncompatible(incompatible)
+	}
+	// closePusher waits for in-flight batches of a streaming pusher and
+	// releases the connections or files a sink holds.
+	base := pusher
+	closePusher := func() {
+		c, ok := base.(interface{ Close(context.Context) error })
+		if !ok {
+			return
+		}
+		closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if err := c.Close(closeCtx); err != nil {
+			_, _ = fmt.Fprintf(deps.LogWriter, "close pusher: %v\n", err)
+		}
+	}
+	if cfg.PushRate > 0 {
+		pacer, err := forward.NewPacer(cfg.PushRate, cfg.PushJitter)
+		if err != nil {
+			return err
+		}
+		pusher = pacedPusher{pusher: pusher, pacer: pacer}
 	}
 
 	buf := forward.NewBuffer(bufSize)
+	var spill *forward.Spill
+	if cfg.SpillDir != "" {
+		spillSize := cfg.SpillSize
+		if spillSize <= 0 {
+			spillSize = defaultSpillSize
+		}
+		if spill, err = forward.OpenSpill(cfg.SpillDir, spillSize); err != nil {
+			return err
+		}
+		buf.SetSpill(spill)
+		if n := spill.Len(); n > 0 {
+			_, _ = fmt.Fprintf(deps.LogWriter, "replaying %d spilled batches from %s\n", n, cfg.SpillDir)
+		}
+	}
+	// persistBuffer keeps undelivered batches on disk across a restart.
+	persistBuffer := func() {
+		if spill == nil {
+			return
+		}
+		if err := buf.Persist(); err != nil {
+			_, _ = fmt.Fprintf(deps.LogWriter, "persist buffer: %v\n", err)
+		}
+		_ = spill.Close()
+	}
+
+	var multiline *forward.Multiline
+	if cfg.MultilinePattern != "" {
+		if multiline, err = forward.NewMultiline(cfg.MultilinePattern, 0); err != nil {
+			return err
+		}
+	}
+
+	lineFilter, err := forward.NewLineFilter(cfg.Grep, cfg.GrepExclude)
+	if err != nil {
+		return err
+	}
+
+	var limiter *forward.LineLimiter
+	sampleRate := cfg.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 1 // unset in a Config built without loadConfigFromEnv
+	}
+	if sampleRate < 1 || cfg.MaxLinesPerSec > 0 {
+		if limiter, err = forward.NewLineLimiter(sampleRate, cfg.MaxLinesPerSec); err != nil {
+			return err
+		}
+	}
 
 	logCh := make(chan forward.LogLine, 1024)
 
@@ -261,6 +693,7 @@ func run(ctx context.Context, cfg Config, deps Dependencies) error {
 
 	batch := make([]forward.TimestampedLine, 0, defaultBatchSize)
 	currentContainer := ""
+	var currentPromoted map[string]string // JSON fields promoted for the batch
 	ticker := time.NewTicker(defaultFlushInterval)
 	defer ticker.Stop()
 
@@ -268,17 +701,25 @@ func run(ctx context.Context, cfg Config, deps Dependencies) error {
 		if len(batch) == 0 {
 			return
 		}
-		labels := make(map[string]string, len(baseLabels)+1)
+		labels := make(map[string]string, len(baseLabels)+1+len(currentPromoted))
+		for k, v := range currentPromoted {
+			labels[k] = v
+		}
 		for k, v := range baseLabels {
 			labels[k] = v
 		}
 		labels["container"] = currentContainer
 
 		if err := pusher.Push(ctx, labels, batch); err != nil {
+			if ctx.Err() == nil {
+				ready.RecordFailure(err)
+			}
 			if err == forward.ErrBufferExceeded {
 				_, _ = fmt.Fprintf(deps.LogWriter, "batch too large, dropping %d lines\n", len(batch))
 			} else if ctx.Err() == nil {
-				_, _ = fmt.Fprintf(deps.LogWriter, "push error, buffering %d lines: %v\n", len(batch), err)
+				if !errors.Is(err, forward.ErrCircuitOpen) {
+					_, _ = fmt.Fprintf(deps.LogWriter, "push error, buffering %d lines: %v\n", len(batch), err)
+				}
 				saved := make([]forward.TimestampedLine, len(batch))
 				copy(saved, batch)
 				dropsBefore := buf.Drops()
@@ -292,61 +733,152 @@ func run(ctx context.Context, cfg Config, deps Dependencies) error {
 				}
 				bufferUsage.Set(float64(buf.Size()))
 			}
+		} else {
+			ready.RecordSuccess()
 		}
 		batch = batch[:0]
 
 		// drain buffered batches
-		drainBuffer(ctx, buf, pusher, deps.LogWriter)
+		if pushed, err := drainBuffer(ctx, buf, pusher, deps.LogWriter); pushed > 0 && err == nil {
+			ready.RecordSuccess()
+		} else if err != nil && ctx.Err() == nil {
+			ready.RecordFailure(err)
+		}
 		bufferUsage.Set(float64(buf.Size()))
+		if spill != nil {
+			spillUsage.Set(float64(spill.Size()))
+		}
+		ready.SetPending(buf.Len())
+	}
+
+	add := func(line forward.LogLine) {
+		// restart markers always go through; filtered lines do not use up
+		// the rate budget
+		marker := logtypes.IsRestartMarker(line.Line)
+		if lineFilter != nil && !marker && !lineFilter.Match(line.Line) {
+			linesDroppedTotal.WithLabelValues(forward.DropFiltered).Inc()
+			return
+		}
+		if limiter != nil && !marker {
+			if ok, reason := limiter.Allow(); !ok {
+				linesDroppedTotal.WithLabelValues(reason).Inc()
+				return
+			}
+		}
+		var promoted map[string]string
+		if cfg.JSONLabels != nil {
+			promoted = cfg.JSONLabels.Extract(line.Line)
+		}
+		// a batch shares one label set, so a change of container or of
+		// promoted fields starts a new one
+		if currentContainer != "" && (line.Container != currentContainer || !maps.Equal(promoted, currentPromoted)) {
+			flush()
+		}
+		currentContainer = line.Container
+		currentPromoted = promoted
+		batch = append(batch, forward.TimestampedLine{
+			Timestamp: line.Timestamp,
+			Line:      line.Line,
+		})
+		if len(batch) >= defaultBatchSize {
+			flush()
+		}
+	}
+	// drainMultiline adds the records still being stitched before exit.
+	drainMultiline := func() {
+		if multiline == nil {
+			return
+		}
+		for _, rec := range multiline.Drain() {
+			add(rec)
+		}
 	}
 
 	for {
 		select {
 		case line, ok := <-logCh:
 			if !ok {
+				drainMultiline()
 				flush()
+				closePusher()
+				persistBuffer()
 				return nil
 			}
-			if currentContainer != "" && line.Container != currentContainer {
-				flush()
+			// sanitize before stitching, which joins lines with newlines
+			line.Line = cfg.Sanitize.Clean(line.Line)
+			if multiline == nil {
+				add(line)
+				continue
 			}
-			currentContainer = line.Container
-			batch = append(batch, forward.TimestampedLine{
-				Timestamp: line.Timestamp,
-				Line:      line.Line,
-			})
-			if len(batch) >= defaultBatchSize {
-				flush()
+			for _, rec := range multiline.Add(line) {
+				add(rec)
 			}
 		case <-ticker.C:
+			if multiline != nil {
+				for _, rec := range multiline.Expire() {
+					add(rec)
+				}
+			}
 			flush()
 		case <-ctx.Done():
+			drainMultiline()
 			flush()
+			closePusher()
+			persistBuffer()
 			_, _ = fmt.Fprintln(deps.LogWriter, "logtap-forwarder stopped")
 			return nil
 		}
 	}
 }
 
-// drainBuffer attempts to re-push all buffered batches. On first failure,
-// remaining batches are re-added to the buffer for the next drain cycle.
-func drainBuffer(ctx context.Context, buf *forward.Buffer, pusher logPusher, log io.Writer) {
-	batches := buf.Drain()
-	for i, b := range batches {
-		if ctx.Err() != nil {
-			// context cancelled — re-buffer remaining
-			for _, remaining := range batches[i:] {
-				buf.Add(remaining)
-			}
-			return
+// pacedPusher waits for its pacer before each push. The final flush after
+// cancellation is not paced.
+type pacedPusher struct {
+	pusher forward.Sink
+	pacer  *forward.Pacer
+}
+
+func (p pacedPusher) Push(ctx context.Context, labels map[string]string, lines []forward.TimestampedLine) error {
+	if ctx.Err() == nil {
+		wait, err := p.pacer.Wait(ctx)
+		if err != nil {
+			return err
+		}
+		pacingDelay.Observe(wait.Seconds())
+	}
+	return p.pusher.Push(ctx, labels, lines)
+}
+
+// drainBuffer attempts to re-push all buffered batches, spilled segments
+// first. On first failure, remaining batches are re-added to the buffer for
+// the next drain cycle. Returns the number of batches pushed and the push
+// error, if any.
+func drainBuffer(ctx context.Context, buf *forward.Buffer, pusher forward.Sink, log io.Writer) (int, error) {
+	pushed := 0
+	for {
+		batches := buf.Drain()
+		if len(batches) == 0 {
+			return pushed, nil
 		}
-		if err := pusher.Push(ctx, b.Labels, b.Lines); err != nil {
-			// re-buffer this and all remaining batches
-			for _, remaining := range batches[i:] {
-				buf.Add(remaining)
+		for i, b := range batches {
+			if ctx.Err() != nil {
+				// context cancelled — re-buffer remaining
+				for _, remaining := range batches[i:] {
+					buf.Add(remaining)
+				}
+				return pushed + i, nil
+			}
+			if err := pusher.Push(ctx, b.Labels, b.Lines); err != nil {
+				// re-buffer this and all remaining batches
+				for _, remaining := range batches[i:] {
+					buf.Add(remaining)
+				}
+				if !errors.Is(err, forward.ErrCircuitOpen) {
+					_, _ = fmt.Fprintf(log, "drain retry failed, %d batches re-buffered: %v\n", len(batches)-i, err)
+				}
+				return pushed + i, err
 			}
-			_, _ = fmt.Fprintf(log, "drain retry failed, %d batches re-buffered: %v\n", len(batches)-i, err)
-			return
 		}
+		pushed += len(batches)
 	}
 }
@@ -5,15 +5,23 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	dto "github.com/prometheus/client_model/go"
+
 	"github.com/ppiankov/logtap/internal/forward"
+	"github.com/ppiankov/logtap/internal/logtypes"
+	"github.com/ppiankov/logtap/internal/recv"
 )
 
 type fakeReader struct {
@@ -154,7 +162,7 @@ func TestStartHealthServerOK(t *testing.T) {
 	t.Cleanup(cancel)
 
 	ln := newInMemoryListener()
-	addr, err := startHealthServerWithListener(ctx, ln, io.Discard)
+	addr, err := startHealthServerWithListener(ctx, ln, nil, io.Discard)
 	if err != nil {
 		t.Fatalf("startHealthServerWithListener: %v", err)
 	}
@@ -186,6 +194,800 @@ func TestStartHealthServerOK(t *testing.T) {
 	}
 }
 
+func TestHealthReadyz(t *testing.T) {
+	ready := forward.NewReadiness(time.Minute)
+	h := healthHandler(ready)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/readyz")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"ok"`) {
+		t.Fatalf("readyz = %d %s, want 200 ok", rec.Code, rec.Body.String())
+	}
+
+	// buffered batches without a successful push: not ready, but still alive
+	ready.RecordFailure(errors.New("connection refused"))
+	ready.SetPending(3)
+	rec = get("/readyz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz code = %d, want 503", rec.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body["status"] != "not_ready" || body["last_error"] != "connection refused" || body["pending_batches"] != float64(3) {
+		t.Errorf("unexpected body: %v", body)
+	}
+	if rec := get("/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("healthz = %d, want 200", rec.Code)
+	}
+
+	// nil readiness always reports ready
+	rec = httptest.NewRecorder()
+	healthHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("nil readiness readyz = %d, want 200", rec.Code)
+	}
+}
+
+func TestRunTracksReadiness(t *testing.T) {
+	cfg := Config{
+		Target:    "receiver",
+		Session:   "session",
+		PodName:   "pod",
+		Namespace: "namespace",
+	}
+	reader := fakeReader{lines: []forward.LogLine{
+		{Timestamp: time.Unix(1700000000, 0), Container: "app", Line: "hello"},
+	}}
+	pusher := &simplePusher{err: errors.New("connection refused")}
+	ready := forward.NewReadiness(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, cfg, Dependencies{
+			NewReader: func(string, string) (logReader, error) { return reader, nil },
+			NewPusher: func(string) forward.Sink { return pusher },
+			LogWriter: io.Discard,
+			Readiness: ready,
+		})
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for ready.Status().Pending == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	st := ready.Status()
+	if st.Ready || st.Pending != 1 || st.LastError != "connection refused" {
+		t.Errorf("status = %+v, want not ready with 1 pending batch", st)
+	}
+}
+
+func TestLoadConfigReadyWindow(t *testing.T) {
+	env := map[string]string{
+		envTarget:      "receiver",
+		envSession:     "session",
+		envPodName:     "pod",
+		envNamespace:   "namespace",
+		envReadyWindow: "2m",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ReadyWindow != 2*time.Minute {
+		t.Errorf("ReadyWindow = %v, want 2m", cfg.ReadyWindow)
+	}
+
+	env[envReadyWindow] = "soon"
+	if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("expected error for invalid ready window")
+	}
+}
+
+func TestLoadConfigSanitize(t *testing.T) {
+	env := map[string]string{
+		envTarget:    "receiver",
+		envSession:   "session",
+		envPodName:   "pod",
+		envNamespace: "namespace",
+		envSanitize:  "ansi",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Sanitize.ANSI || cfg.Sanitize.Control {
+		t.Errorf("Sanitize = %+v, want ansi only", cfg.Sanitize)
+	}
+
+	env[envSanitize] = "rainbow"
+	if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("expected error for invalid sanitize mode")
+	}
+}
+
+func TestLoadConfigPushEncoding(t *testing.T) {
+	env := map[string]string{
+		envTarget:    "receiver",
+		envSession:   "session",
+		envPodName:   "pod",
+		envNamespace: "namespace",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PushEncoding != forward.EncodingProtobuf {
+		t.Errorf("default PushEncoding = %q, want %q", cfg.PushEncoding, forward.EncodingProtobuf)
+	}
+
+	env[envPushEncoding] = "json"
+	if cfg, err = loadConfigFromEnv(func(k string) string { return env[k] }); err != nil || cfg.PushEncoding != forward.EncodingJSON {
+		t.Errorf("PushEncoding = %q, %v; want json", cfg.PushEncoding, err)
+	}
+
+	env[envPushEncoding] = "xml"
+	if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("expected error for invalid push encoding")
+	}
+}
+
+func TestLoadConfigGRPCTarget(t *testing.T) {
+	env := map[string]string{
+		envTarget:     "receiver",
+		envSession:    "session",
+		envPodName:    "pod",
+		envNamespace:  "namespace",
+		envGRPCTarget: "receiver:4317",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GRPCTarget != "receiver:4317" {
+		t.Errorf("GRPCTarget = %q, want receiver:4317", cfg.GRPCTarget)
+	}
+}
+
+func TestLoadConfigTLSPin(t *testing.T) {
+	pin := strings.Repeat("ab", 32)
+	env := map[string]string{
+		envTarget:    "https://receiver:3100",
+		envSession:   "session",
+		envPodName:   "pod",
+		envNamespace: "namespace",
+		envTLSPin:    pin,
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TLSPin != pin {
+		t.Errorf("TLSPin = %q, want %q", cfg.TLSPin, pin)
+	}
+
+	env[envTLSPin] = "not-a-fingerprint"
+	if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("expected error for an invalid pin")
+	}
+}
+
+func TestLoadConfigSink(t *testing.T) {
+	env := map[string]string{
+		envSession:   "session",
+		envPodName:   "pod",
+		envNamespace: "namespace",
+		envSink:      "kafka://broker:9092/logs",
+	}
+	getenv := func(k string) string { return env[k] }
+	cfg, err := loadConfigFromEnv(getenv)
+	if err != nil {
+		t.Fatalf("a sink needs no %s: %v", envTarget, err)
+	}
+	if cfg.Sink != "kafka://broker:9092/logs" {
+		t.Errorf("Sink = %q", cfg.Sink)
+	}
+
+	env[envSink] = "redis://cache:6379/logs"
+	if _, err := loadConfigFromEnv(getenv); err == nil || !strings.Contains(err.Error(), "unknown sink") {
+		t.Errorf("unknown scheme: err = %v", err)
+	}
+	env[envSink] = "nats://nats:4222/logs"
+	env[envGRPCTarget] = "receiver:4317"
+	if _, err := loadConfigFromEnv(getenv); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("sink with grpc target: err = %v", err)
+	}
+}
+
+func TestRunFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.jsonl")
+	lines := []forward.LogLine{{Timestamp: time.Now(), Container: "app", Line: "to a file"}}
+	cfg := Config{Session: "session", PodName: "pod", Namespace: "namespace", Sink: "file://" + path}
+	deps := Dependencies{
+		NewReader: func(string, string) (logReader, error) { return fakeReader{lines: lines}, nil },
+		LogWriter: io.Discard,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, deps) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, _ := os.ReadFile(path); strings.Contains(string(data), "to a file") {
+			cancel()
+			<-done
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	t.Fatalf("line not written to the file sink: %v", <-done)
+}
+
+func TestLoadConfigSpill(t *testing.T) {
+	env := map[string]string{
+		envTarget:    "receiver",
+		envSession:   "session",
+		envPodName:   "pod",
+		envNamespace: "namespace",
+		envSpillDir:  "/var/spool/logtap",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SpillDir != "/var/spool/logtap" || cfg.SpillSize != defaultSpillSize {
+		t.Errorf("spill = %q/%d, want dir with default size", cfg.SpillDir, cfg.SpillSize)
+	}
+
+	env[envSpillSize] = "1048576"
+	if cfg, err = loadConfigFromEnv(func(k string) string { return env[k] }); err != nil || cfg.SpillSize != 1<<20 {
+		t.Errorf("SpillSize = %d, %v; want 1048576", cfg.SpillSize, err)
+	}
+	env[envSpillSize] = "lots"
+	if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("expected error for invalid spill size")
+	}
+}
+
+func TestLoadConfigPushRate(t *testing.T) {
+	env := map[string]string{
+		envTarget:    "receiver",
+		envSession:   "session",
+		envPodName:   "pod",
+		envNamespace: "namespace",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PushRate != 0 || cfg.PushJitter != forward.DefaultPacingJitter {
+		t.Errorf("pacing = %v/%v, want off with default jitter", cfg.PushRate, cfg.PushJitter)
+	}
+
+	env[envPushRate] = "20"
+	env[envPushJitter] = "0.5"
+	if cfg, err = loadConfigFromEnv(func(k string) string { return env[k] }); err != nil || cfg.PushRate != 20 || cfg.PushJitter != 0.5 {
+		t.Errorf("pacing = %v/%v, %v; want 20/0.5", cfg.PushRate, cfg.PushJitter, err)
+	}
+	env[envPushJitter] = "2"
+	if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("expected error for jitter above 1")
+	}
+	env[envPushJitter] = ""
+	env[envPushRate] = "-1"
+	if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("expected error for negative rate")
+	}
+}
+
+func TestLoadConfigRetryAndBreaker(t *testing.T) {
+	env := map[string]string{
+		envTarget:    "receiver",
+		envSession:   "session",
+		envPodName:   "pod",
+		envNamespace: "namespace",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RetryBackoff.Base != time.Second || cfg.RetryBackoff.Jitter != defaultRetryJitter {
+		t.Errorf("RetryBackoff = %+v, want 1s base with default jitter", cfg.RetryBackoff)
+	}
+	if cfg.BreakerThreshold != defaultBreakerAfter || cfg.BreakerCooldown != defaultBreakerPause {
+		t.Errorf("breaker = %d/%s, want defaults", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	}
+
+	env[envRetryBase] = "250ms"
+	env[envRetryMaxWait] = "5s"
+	env[envRetryJitter] = "0"
+	env[envBreakerAfter] = "0"
+	env[envBreakerPause] = "1m"
+	cfg, err = loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := forward.Backoff{Base: 250 * time.Millisecond, Max: 5 * time.Second}
+	if cfg.RetryBackoff != want || cfg.BreakerThreshold != 0 || cfg.BreakerCooldown != time.Minute {
+		t.Errorf("cfg = %+v / %d / %s", cfg.RetryBackoff, cfg.BreakerThreshold, cfg.BreakerCooldown)
+	}
+
+	for k, v := range map[string]string{envRetryBase: "soon", envRetryJitter: "1.5", envBreakerAfter: "-1", envBreakerPause: "0s"} {
+		bad := map[string]string{envTarget: "r", envSession: "s", envPodName: "p", envNamespace: "n", k: v}
+		if _, err := loadConfigFromEnv(func(key string) string { return bad[key] }); err == nil {
+			t.Errorf("%s=%s: expected error", k, v)
+		}
+	}
+}
+
+func TestPacedPusher(t *testing.T) {
+	pacer, err := forward.NewPacer(50, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &simplePusher{}
+	p := pacedPusher{pusher: inner, pacer: pacer}
+	line := []forward.TimestampedLine{{Line: "x"}}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := p.Push(context.Background(), nil, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("3 paced pushes took %v, want >= 40ms", elapsed)
+	}
+
+	// the final flush after cancellation goes out immediately
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Push(ctx, nil, line); err != nil {
+		t.Errorf("push after cancel: %v", err)
+	}
+	if n := len(inner.getCalls()); n != 4 {
+		t.Errorf("inner pushes = %d, want 4", n)
+	}
+}
+
+func TestDrainBuffer_Spill(t *testing.T) {
+	spill, err := forward.OpenSpill(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []forward.TimestampedLine{{Timestamp: time.Now(), Line: "x"}}
+	buf := forward.NewBuffer(forward.EstimateBatchSize(nil, line))
+	buf.SetSpill(spill)
+	for i := 0; i < 5; i++ {
+		buf.Add(forward.Batch{Lines: line, Size: forward.EstimateBatchSize(nil, line)})
+	}
+
+	p := &simplePusher{}
+	pushed, err := drainBuffer(context.Background(), buf, p, io.Discard)
+	if err != nil || pushed != 5 {
+		t.Errorf("drainBuffer = %d, %v; want all 5 batches", pushed, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Len after drain = %d, want 0", buf.Len())
+	}
+}
+
+func TestRunStreamPush(t *testing.T) {
+	w := recv.NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	ring := recv.NewLogRing(0)
+	srv := recv.NewServer(":0", w, nil, nil, nil, ring)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.ServeOTLPGRPC(ln) }()
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+
+	cfg := Config{
+		Target:     "receiver",
+		Session:    "session",
+		PodName:    "pod",
+		Namespace:  "namespace",
+		GRPCTarget: ln.Addr().String(),
+	}
+	now := time.Unix(1700000000, 0).UTC()
+	reader := fakeReader{lines: []forward.LogLine{
+		{Timestamp: now, Container: "app", Line: "one"},
+		{Timestamp: now, Container: "app", Line: "two"},
+	}}
+	deps := Dependencies{
+		NewReader: func(string, string) (logReader, error) { return reader, nil },
+		LogWriter: io.Discard,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, deps) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ring.Snapshot()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	entries := ring.Snapshot()
+	if len(entries) != 2 || entries[0].Labels["container"] != "app" || entries[1].Message != "two" {
+		t.Errorf("entries = %+v, want both lines streamed", entries)
+	}
+}
+
+func TestRunSanitizesLines(t *testing.T) {
+	cfg := Config{
+		Target:    "receiver",
+		Session:   "session",
+		PodName:   "pod",
+		Namespace: "namespace",
+		Sanitize:  forward.Sanitizer{ANSI: true, Control: true},
+	}
+
+	now := time.Unix(1700000000, 0).UTC()
+	reader := fakeReader{
+		lines: []forward.LogLine{
+			{Timestamp: now, Container: "app", Line: "\x1b[31mERROR\x1b[0m disk full\r"},
+		},
+	}
+	pushCh := make(chan pushCall, 4)
+	deps := Dependencies{
+		NewReader: func(string, string) (logReader, error) { return reader, nil },
+		NewPusher: func(string) forward.Sink { return &scriptedPusher{calls: pushCh} },
+		LogWriter: io.Discard,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, deps) }()
+
+	call := waitForPush(t, pushCh)
+	if len(call.lines) != 1 || call.lines[0].Line != "ERROR disk full" {
+		t.Fatalf("lines = %#v, want sanitized line", call.lines)
+	}
+	cancel()
+	<-done
+}
+
+func TestRunStitchesMultiline(t *testing.T) {
+	cfg := Config{
+		Target:           "receiver",
+		Session:          "session",
+		PodName:          "pod",
+		Namespace:        "namespace",
+		Sanitize:         forward.Sanitizer{Control: true},
+		MultilinePattern: `^\d{4}-`,
+	}
+
+	now := time.Unix(1700000000, 0).UTC()
+	reader := fakeReader{
+		lines: []forward.LogLine{
+			{Timestamp: now, Container: "app", Line: "2024-01-15 ERROR request failed"},
+			{Timestamp: now, Container: "app", Line: "java.lang.IllegalStateException: boom\r"},
+			{Timestamp: now, Container: "app", Line: "\tat com.example.Handler.run(Handler.java:42)"},
+			{Timestamp: now.Add(time.Second), Container: "app", Line: "2024-01-15 INFO recovered"},
+		},
+	}
+	pushCh := make(chan pushCall, 4)
+	deps := Dependencies{
+		NewReader: func(string, string) (logReader, error) { return reader, nil },
+		NewPusher: func(string) forward.Sink { return &scriptedPusher{calls: pushCh} },
+		LogWriter: io.Discard,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, deps) }()
+
+	// the trace is pushed on the ticker; the last record once it times out
+	var lines []forward.TimestampedLine
+	for len(lines) < 2 {
+		lines = append(lines, waitForPush(t, pushCh).lines...)
+	}
+	want := "2024-01-15 ERROR request failed\njava.lang.IllegalStateException: boom\n\tat com.example.Handler.run(Handler.java:42)"
+	if lines[0].Line != want || !lines[0].Timestamp.Equal(now) {
+		t.Errorf("first record = %q at %v, want the stitched trace at %v", lines[0].Line, lines[0].Timestamp, now)
+	}
+	if lines[1].Line != "2024-01-15 INFO recovered" {
+		t.Errorf("second record = %q", lines[1].Line)
+	}
+	cancel()
+	<-done
+}
+
+func TestRunLimitsLines(t *testing.T) {
+	cfg := Config{
+		Target:         "receiver",
+		Session:        "session",
+		PodName:        "pod",
+		Namespace:      "namespace",
+		MaxLinesPerSec: 5,
+	}
+
+	now := time.Unix(1700000000, 0).UTC()
+	var lines []forward.LogLine
+	for i := range 20 {
+		lines = append(lines, forward.LogLine{Timestamp: now, Container: "app", Line: fmt.Sprintf("line %d", i)})
+	}
+	lines = append(lines, forward.LogLine{Timestamp: now, Container: "app", Line: logtypes.RestartMarker("app", 1)})
+	pushCh := make(chan pushCall, 4)
+	deps := Dependencies{
+		NewReader: func(string, string) (logReader, error) { return fakeReader{lines: lines}, nil },
+		NewPusher: func(string) forward.Sink { return &scriptedPusher{calls: pushCh} },
+		LogWriter: io.Discard,
+	}
+
+	var before dto.Metric
+	_ = linesDroppedTotal.WithLabelValues(forward.DropRateLimited).Write(&before)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, deps) }()
+
+	got := waitForPush(t, pushCh).lines
+	cancel()
+	<-done
+	if len(got) != 6 {
+		t.Fatalf("pushed %d lines, want the 5/s burst plus the restart marker", len(got))
+	}
+	if !logtypes.IsRestartMarker(got[5].Line) {
+		t.Errorf("last line = %q, want the restart marker", got[5].Line)
+	}
+	var after dto.Metric
+	_ = linesDroppedTotal.WithLabelValues(forward.DropRateLimited).Write(&after)
+	if d := after.GetCounter().GetValue() - before.GetCounter().GetValue(); d != 15 {
+		t.Errorf("rate_limited drops = %v, want 15", d)
+	}
+}
+
+func TestLoadConfigLimits(t *testing.T) {
+	env := map[string]string{
+		envTarget:      "receiver",
+		envSession:     "session",
+		envPodName:     "pod",
+		envNamespace:   "namespace",
+		envSampleRate:  "0.1",
+		envMaxLineRate: "500",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SampleRate != 0.1 || cfg.MaxLinesPerSec != 500 {
+		t.Errorf("SampleRate = %v, MaxLinesPerSec = %v", cfg.SampleRate, cfg.MaxLinesPerSec)
+	}
+
+	delete(env, envSampleRate)
+	if cfg, _ := loadConfigFromEnv(func(k string) string { return env[k] }); cfg.SampleRate != 1 {
+		t.Errorf("default SampleRate = %v, want 1", cfg.SampleRate)
+	}
+	for k, v := range map[string]string{envSampleRate: "0", envMaxLineRate: "-1"} {
+		env[k] = v
+		if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+			t.Errorf("expected error for %s=%s", k, v)
+		}
+		delete(env, k)
+	}
+}
+
+func TestRunGrepFilter(t *testing.T) {
+	cfg := Config{
+		Target:      "receiver",
+		Session:     "session",
+		PodName:     "pod",
+		Namespace:   "namespace",
+		Grep:        `ERROR|WARN`,
+		GrepExclude: `healthz`,
+	}
+
+	now := time.Unix(1700000000, 0).UTC()
+	lines := []forward.LogLine{
+		{Timestamp: now, Container: "app", Line: "INFO started"},
+		{Timestamp: now, Container: "app", Line: "ERROR db timeout"},
+		{Timestamp: now, Container: "app", Line: "WARN /healthz slow"},
+		{Timestamp: now, Container: "app", Line: logtypes.RestartMarker("app", 1)},
+		{Timestamp: now, Container: "app", Line: "WARN retrying"},
+	}
+	pushCh := make(chan pushCall, 4)
+	deps := Dependencies{
+		NewReader: func(string, string) (logReader, error) { return fakeReader{lines: lines}, nil },
+		NewPusher: func(string) forward.Sink { return &scriptedPusher{calls: pushCh} },
+		LogWriter: io.Discard,
+	}
+
+	var before dto.Metric
+	_ = linesDroppedTotal.WithLabelValues(forward.DropFiltered).Write(&before)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, deps) }()
+
+	got := waitForPush(t, pushCh).lines
+	cancel()
+	<-done
+	var pushed []string
+	for _, l := range got {
+		pushed = append(pushed, l.Line)
+	}
+	want := []string{"ERROR db timeout", logtypes.RestartMarker("app", 1), "WARN retrying"}
+	if strings.Join(pushed, "|") != strings.Join(want, "|") {
+		t.Errorf("pushed %q, want %q", pushed, want)
+	}
+	var after dto.Metric
+	_ = linesDroppedTotal.WithLabelValues(forward.DropFiltered).Write(&after)
+	if d := after.GetCounter().GetValue() - before.GetCounter().GetValue(); d != 2 {
+		t.Errorf("filtered drops = %v, want 2", d)
+	}
+}
+
+func TestLoadConfigGrep(t *testing.T) {
+	env := map[string]string{
+		envTarget:      "receiver",
+		envSession:     "session",
+		envPodName:     "pod",
+		envNamespace:   "namespace",
+		envGrep:        `(?i)error`,
+		envGrepExclude: `healthz`,
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Grep != `(?i)error` || cfg.GrepExclude != "healthz" {
+		t.Errorf("Grep = %q, GrepExclude = %q", cfg.Grep, cfg.GrepExclude)
+	}
+	for _, k := range []string{envGrep, envGrepExclude} {
+		env[k] = "("
+		if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+			t.Errorf("expected error for invalid %s", k)
+		}
+		delete(env, k)
+	}
+}
+
+func TestRunJSONLabels(t *testing.T) {
+	jl, err := forward.ParseJSONLabels("level,tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{
+		Target:     "receiver",
+		Session:    "session",
+		PodName:    "pod",
+		Namespace:  "namespace",
+		JSONLabels: jl,
+	}
+
+	now := time.Unix(1700000000, 0).UTC()
+	lines := []forward.LogLine{
+		{Timestamp: now, Container: "app", Line: `{"level":"info","tenant":"acme","msg":"a"}`},
+		{Timestamp: now, Container: "app", Line: `{"level":"info","tenant":"acme","msg":"b"}`},
+		{Timestamp: now, Container: "app", Line: `{"level":"error","tenant":"acme","msg":"c"}`},
+		{Timestamp: now, Container: "app", Line: "plain text"},
+	}
+	pushCh := make(chan pushCall, 4)
+	deps := Dependencies{
+		NewReader: func(string, string) (logReader, error) { return fakeReader{lines: lines}, nil },
+		NewPusher: func(string) forward.Sink { return &scriptedPusher{calls: pushCh} },
+		LogWriter: io.Discard,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, deps) }()
+
+	var calls []pushCall
+	for range 3 {
+		calls = append(calls, waitForPush(t, pushCh))
+	}
+	cancel()
+	<-done
+
+	want := []struct {
+		level, tenant string
+		lines         int
+	}{{"info", "acme", 2}, {"error", "acme", 1}, {"", "", 1}}
+	for i, w := range want {
+		c := calls[i]
+		if c.labels["level"] != w.level || c.labels["tenant"] != w.tenant || len(c.lines) != w.lines {
+			t.Errorf("push %d: labels %v with %d lines, want level=%q tenant=%q with %d",
+				i, c.labels, len(c.lines), w.level, w.tenant, w.lines)
+		}
+		if c.labels["pod"] != "pod" || c.labels["container"] != "app" {
+			t.Errorf("push %d: base labels missing: %v", i, c.labels)
+		}
+	}
+}
+
+func TestLoadConfigJSONLabels(t *testing.T) {
+	env := map[string]string{
+		envTarget:     "receiver",
+		envSession:    "session",
+		envPodName:    "pod",
+		envNamespace:  "namespace",
+		envJSONLabels: "level,trace_id",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.JSONLabels.Extract(`{"level":"warn"}`); got["level"] != "warn" {
+		t.Errorf("Extract = %v", got)
+	}
+	env[envJSONLabels] = "pod"
+	if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("expected error promoting a reserved label")
+	}
+}
+
+func TestLoadConfigMultiline(t *testing.T) {
+	env := map[string]string{
+		envTarget:    "receiver",
+		envSession:   "session",
+		envPodName:   "pod",
+		envNamespace: "namespace",
+		envMultiline: `^\S`,
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MultilinePattern != `^\S` {
+		t.Errorf("MultilinePattern = %q", cfg.MultilinePattern)
+	}
+
+	env[envMultiline] = "(unclosed"
+	if _, err := loadConfigFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("expected error for invalid multiline pattern")
+	}
+}
+
+func TestLoadConfigContainers(t *testing.T) {
+	env := map[string]string{
+		envTarget:       "receiver",
+		envSession:      "session",
+		envPodName:      "pod",
+		envNamespace:    "namespace",
+		envContainers:   "app, worker,",
+		envExcludeConts: "istio-proxy",
+	}
+	cfg, err := loadConfigFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cfg.Containers.Include, ","); got != "app,worker" {
+		t.Errorf("Include = %q, want app,worker", got)
+	}
+	if got := strings.Join(cfg.Containers.Exclude, ","); got != "istio-proxy" {
+		t.Errorf("Exclude = %q, want istio-proxy", got)
+	}
+}
+
 func TestValidateConfigMissing(t *testing.T) {
 	base := Config{
 		Target:    "target",
@@ -362,7 +1164,7 @@ func TestRunFlushesAndLogsBufferExceeded(t *testing.T) {
 		NewReader: func(string, string) (logReader, error) {
 			return reader, nil
 		},
-		NewPusher: func(target string) logPusher {
+		NewPusher: func(target string) forward.Sink {
 			if target != cfg.Target {
 				t.Fatalf("target = %q, want %q", target, cfg.Target)
 			}
@@ -466,7 +1268,7 @@ func TestRunSuccessfulPush(t *testing.T) {
 		NewReader: func(string, string) (logReader, error) {
 			return reader, nil
 		},
-		NewPusher: func(target string) logPusher {
+		NewPusher: func(target string) forward.Sink {
 			return pusher
 		},
 		LogWriter: io.Discard,
@@ -527,7 +1329,7 @@ func TestRunMultipleContainers(t *testing.T) {
 		NewReader: func(string, string) (logReader, error) {
 			return reader, nil
 		},
-		NewPusher: func(target string) logPusher {
+		NewPusher: func(target string) forward.Sink {
 			return pusher
 		},
 		LogWriter: io.Discard,
@@ -583,7 +1385,7 @@ func TestRunContextCancel(t *testing.T) {
 		NewReader: func(string, string) (logReader, error) {
 			return blockingReader, nil
 		},
-		NewPusher: func(target string) logPusher {
+		NewPusher: func(target string) forward.Sink {
 			return pusher
 		},
 		LogWriter: &logs,
@@ -639,7 +1441,7 @@ func TestRunPushError_Buffered(t *testing.T) {
 		NewReader: func(string, string) (logReader, error) {
 			return reader, nil
 		},
-		NewPusher: func(target string) logPusher {
+		NewPusher: func(target string) forward.Sink {
 			return pusher
 		},
 		LogWriter: &logs,
@@ -748,7 +1550,7 @@ func TestHealthMetricsEndpoint(t *testing.T) {
 	t.Cleanup(cancel)
 
 	ln := newInMemoryListener()
-	_, err := startHealthServerWithListener(ctx, ln, io.Discard)
+	_, err := startHealthServerWithListener(ctx, ln, nil, io.Discard)
 	if err != nil {
 		t.Fatalf("startHealthServerWithListener: %v", err)
 	}
@@ -804,7 +1606,7 @@ func TestRunBatchSizeFlush(t *testing.T) {
 		NewReader: func(string, string) (logReader, error) {
 			return reader, nil
 		},
-		NewPusher: func(target string) logPusher {
+		NewPusher: func(target string) forward.Sink {
 			return pusher
 		},
 		LogWriter: io.Discard,
@@ -885,7 +1687,7 @@ func TestStartHealthServer_BadAddress(t *testing.T) {
 	defer cancel()
 
 	// Invalid address should fail
-	_, err := startHealthServer(ctx, "invalid-not-an-address::::::", io.Discard)
+	_, err := startHealthServer(ctx, "invalid-not-an-address::::::", nil, io.Discard)
 	if err == nil {
 		t.Fatal("expected error for bad address")
 	}
@@ -895,7 +1697,7 @@ func TestStartHealthServer_RealListener(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	t.Cleanup(cancel)
 
-	addr, err := startHealthServer(ctx, ":0", io.Discard)
+	addr, err := startHealthServer(ctx, ":0", nil, io.Discard)
 	if err != nil {
 		t.Fatalf("startHealthServer: %v", err)
 	}
@@ -951,7 +1753,7 @@ func TestRunFollowError(t *testing.T) {
 		NewReader: func(string, string) (logReader, error) {
 			return reader, nil
 		},
-		NewPusher: func(target string) logPusher {
+		NewPusher: func(target string) forward.Sink {
 			return pusher
 		},
 		LogWriter: logs,
@@ -2,11 +2,16 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
@@ -14,6 +19,8 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
 	"github.com/ppiankov/logtap/internal/recv"
 	"github.com/ppiankov/logtap/internal/rotate"
 )
@@ -182,6 +189,36 @@ func TestRunInspect_Success(t *testing.T) {
 	})
 }
 
+func TestRunStats_Success(t *testing.T) {
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+
+	out := captureStdout(t, func() {
+		if err := runStats(dir, 5, false); err != nil {
+			t.Fatalf("runStats text: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Lines per minute:") || !strings.Contains(out, "Message length") {
+		t.Errorf("unexpected text output:\n%s", out)
+	}
+
+	out = captureStdout(t, func() {
+		if err := runStats(dir, 5, true); err != nil {
+			t.Fatalf("runStats json: %v", err)
+		}
+	})
+	var stats archive.Stats
+	if err := json.Unmarshal([]byte(out), &stats); err != nil {
+		t.Fatalf("json output: %v\n%s", err, out)
+	}
+	if stats.Lines == 0 {
+		t.Errorf("Lines = 0, want entries from the capture")
+	}
+
+	if err := runStats("/nonexistent/dir", 5, false); err == nil {
+		t.Error("expected error for nonexistent dir")
+	}
+}
+
 func TestRunDiff_Success(t *testing.T) {
 	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
 	dirA := makeCaptureDir(t, sampleEntries(base))
@@ -209,28 +246,192 @@ func TestRunGrep_Success(t *testing.T) {
 	defer restore()
 
 	t.Run("matches", func(t *testing.T) {
-		if err := runGrep("error", dir, "", "", nil, false, false, "json", 0); err != nil {
+		if err := runGrep("error", dir, "", "", nil, false, false, "json", 0, false, false, lifecycleFilter{}); err != nil {
 			t.Fatalf("runGrep: %v", err)
 		}
 	})
 
 	t.Run("count", func(t *testing.T) {
-		if err := runGrep("error", dir, "", "", nil, true, false, "json", 0); err != nil {
+		if err := runGrep("error", dir, "", "", nil, true, false, "json", 0, false, false, lifecycleFilter{}); err != nil {
 			t.Fatalf("runGrep count: %v", err)
 		}
 	})
 
 	t.Run("sort", func(t *testing.T) {
-		if err := runGrep("error", dir, "", "", nil, false, true, "json", 0); err != nil {
+		if err := runGrep("error", dir, "", "", nil, false, true, "json", 0, false, false, lifecycleFilter{}); err != nil {
 			t.Fatalf("runGrep sort: %v", err)
 		}
 	})
 
 	t.Run("text", func(t *testing.T) {
-		if err := runGrep("error", dir, "", "", nil, false, false, "text", 0); err != nil {
+		if err := runGrep("error", dir, "", "", nil, false, false, "text", 0, false, false, lifecycleFilter{}); err != nil {
 			t.Fatalf("runGrep text: %v", err)
 		}
 	})
+
+	t.Run("field", func(t *testing.T) {
+		if err := runGrep("error", dir, "", "", nil, false, false, "json", 0, false, false, lifecycleFilter{fields: []string{"trace_id=abc"}}); err != nil {
+			t.Fatalf("runGrep field: %v", err)
+		}
+		if err := runGrep("error", dir, "", "", nil, false, false, "json", 0, false, false, lifecycleFilter{fields: []string{"trace_id"}}); err == nil {
+			t.Fatal("expected error for --field without a value")
+		}
+	})
+
+	t.Run("out-capture", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "errors")
+		if err := runGrepCapture("error", dir, out, "", "", nil, 1, false, lifecycleFilter{}); err != nil {
+			t.Fatalf("runGrepCapture: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(out, "metadata.json")); err != nil {
+			t.Fatalf("no capture written: %v", err)
+		}
+	})
+}
+
+func TestRunGrep_SortSameTimestamp(t *testing.T) {
+	base := time.Date(2025, 1, 15, 10, 0, 0, 123456789, time.UTC)
+	// lines a nanosecond apart, interleaved out of order in the data file
+	var entries []recv.LogEntry
+	for i := range 25 {
+		entries = append(entries,
+			recv.LogEntry{Timestamp: base.Add(1), Labels: map[string]string{"app": "web"}, Message: fmt.Sprintf("error b%02d", i)},
+			recv.LogEntry{Timestamp: base, Labels: map[string]string{"app": "web"}, Message: fmt.Sprintf("error a%02d", i)})
+	}
+	unsorted := slices.Clone(entries)
+	dir := makeCaptureDir(t, entries)
+	writeDataFile(t, dir, base.Format("2006-01-02T150405")+"-000.jsonl", unsorted)
+
+	out := captureStdout(t, func() {
+		if err := runGrep("error", dir, "", "", nil, false, true, "json", 0, false, false, lifecycleFilter{}); err != nil {
+			t.Errorf("runGrep: %v", err)
+		}
+	})
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var e recv.LogEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		got = append(got, e.Message)
+	}
+	if len(got) != 50 || !sort.StringsAreSorted(got) {
+		t.Errorf("--sort reordered lines sharing a timestamp: %v", got)
+	}
+
+	for precision, want := range map[string]string{"": "10:00:00.123", "s": "10:00:00", "us": "10:00:00.123456", "ns": "10:00:00.123456789"} {
+		if got := base.Format(textTimeLayout(precision)); got != want {
+			t.Errorf("precision %q: %s, want %s", precision, got, want)
+		}
+	}
+}
+
+func TestRunGrep_Summary(t *testing.T) {
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+
+	t.Run("json", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			if err := runGrep("error", dir, "", "", nil, false, false, "json", 0, true, false, lifecycleFilter{}); err != nil {
+				t.Fatalf("runGrep: %v", err)
+			}
+		})
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		var last struct {
+			Summary archive.GrepSummary `json:"summary"`
+		}
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+			t.Fatalf("decode summary: %v", err)
+		}
+		if last.Summary.Matches != 1 || last.Summary.Labels["app"]["web"] != 1 {
+			t.Errorf("summary = %+v", last.Summary)
+		}
+		if len(last.Summary.Hours) != 1 {
+			t.Errorf("hours = %+v", last.Summary.Hours)
+		}
+	})
+
+	t.Run("count", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			if err := runGrep("error", dir, "", "", nil, true, false, "json", 0, true, false, lifecycleFilter{}); err != nil {
+				t.Fatalf("runGrep: %v", err)
+			}
+		})
+		if !strings.Contains(out, "Summary: 1 matches") || !strings.Contains(out, "By app:") {
+			t.Errorf("expected text summary after counts, got:\n%s", out)
+		}
+	})
+}
+
+func TestRunGrepNewSince(t *testing.T) {
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	entry := func(min int, msg string) recv.LogEntry {
+		return recv.LogEntry{
+			Timestamp: base.Add(time.Duration(min) * time.Minute),
+			Labels:    map[string]string{"app": "api"},
+			Message:   msg,
+		}
+	}
+	dir := makeCaptureDir(t, []recv.LogEntry{
+		entry(0, "GET /health 200"),
+		entry(5, "upstream timeout after 1500ms"),
+		entry(31, "GET /health 200"),
+		entry(32, "pool exhausted: 64 of 64 in use"),
+		entry(33, "upstream timeout after 3000ms"),
+		entry(34, "pool exhausted: 64 of 64 in use"),
+		entry(35, "redis failover to 10.0.0.9"),
+	})
+
+	out := captureStdout(t, func() {
+		if err := runGrepNewSince("", dir, "", "", nil, "10:30", "json", lifecycleFilter{}); err != nil {
+			t.Fatalf("runGrepNewSince: %v", err)
+		}
+	})
+	var got []archive.NewPattern
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var p archive.NewPattern
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		got = append(got, p)
+	}
+	if len(got) != 2 || got[0].Pattern != "pool exhausted: 64 of 64 in use" || got[0].Count != 2 ||
+		got[1].Pattern != "redis failover to <IP>" {
+		t.Errorf("new patterns = %+v", got)
+	}
+
+	// the pattern narrows the lines considered
+	out = captureStdout(t, func() {
+		if err := runGrepNewSince("redis", dir, "", "", nil, "10:30", "text", lifecycleFilter{}); err != nil {
+			t.Fatalf("runGrepNewSince: %v", err)
+		}
+	})
+	if strings.Count(strings.TrimSpace(out), "\n") != 0 || !strings.Contains(out, "redis failover to <IP>") {
+		t.Errorf("text output = %q", out)
+	}
+
+	if err := runGrepNewSince("", dir, "", "", nil, "noon", "json", lifecycleFilter{}); err == nil {
+		t.Error("expected error for invalid --new-since")
+	}
+}
+
+func TestNewSinceArgs(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		args          []string
+		pattern, want string
+	}{
+		{[]string{dir}, "", dir},
+		{[]string{"timeout", dir}, "timeout", dir},
+	}
+	for _, tt := range tests {
+		pattern, got, err := newSinceArgs(tt.args)
+		if err != nil {
+			t.Fatalf("newSinceArgs(%q): %v", tt.args, err)
+		}
+		if pattern != tt.pattern || got != tt.want {
+			t.Errorf("newSinceArgs(%q) = %q, %q; want %q, %q", tt.args, pattern, got, tt.pattern, tt.want)
+		}
+	}
 }
 
 func TestRunSlice_Success(t *testing.T) {
@@ -240,7 +441,7 @@ func TestRunSlice_Success(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runSlice(dir, "", "", nil, "", outDir); err != nil {
+	if err := runSlice(dir, "", "", nil, "", outDir, lifecycleFilter{}); err != nil {
 		t.Fatalf("runSlice: %v", err)
 	}
 }
@@ -252,7 +453,7 @@ func TestRunExport_Success(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runExport(dir, "jsonl", "", "", nil, "", outPath, false); err != nil {
+	if err := runExport(dir, "jsonl", "", "", nil, "", outPath, false, false, false, false, nil, false, lifecycleFilter{}); err != nil {
 		t.Fatalf("runExport: %v", err)
 	}
 	if _, err := os.Stat(outPath); err != nil {
@@ -260,6 +461,42 @@ func TestRunExport_Success(t *testing.T) {
 	}
 }
 
+func TestRunGrep_Profile(t *testing.T) {
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+
+	errFile, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	origStdout, origStderr := os.Stdout, os.Stderr
+	os.Stdout, _ = os.Open(os.DevNull)
+	os.Stderr = errFile
+	err = runGrep("error", dir, "", "", nil, false, false, "json", 0, false, true, lifecycleFilter{})
+	os.Stdout, os.Stderr = origStdout, origStderr
+	if err != nil {
+		t.Fatalf("runGrep: %v", err)
+	}
+
+	data, err := os.ReadFile(errFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{"Read profile:", "BYTES READ", "DECOMPRESS", "total"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stderr missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintProfile_Nil(t *testing.T) {
+	var buf bytes.Buffer
+	printProfile(&buf, newProfile(false))
+	if buf.Len() != 0 {
+		t.Errorf("expected no output without --profile, got %q", buf.String())
+	}
+}
+
 func TestRunMerge_Success(t *testing.T) {
 	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
 	dirA := makeCaptureDir(t, sampleEntries(base))
@@ -269,7 +506,7 @@ func TestRunMerge_Success(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runMerge([]string{dirA, dirB}, outDir, false, false); err != nil {
+	if err := runMerge([]string{dirA, dirB}, outDir, false, false, archive.ConflictMix); err != nil {
 		t.Fatalf("runMerge: %v", err)
 	}
 	if _, err := os.Stat(filepath.Join(outDir, "metadata.json")); err != nil {
@@ -277,6 +514,16 @@ func TestRunMerge_Success(t *testing.T) {
 	}
 }
 
+func TestMergeCmd_InvalidOnConflict(t *testing.T) {
+	cmd := newMergeCmd()
+	cmd.SetArgs([]string{"a", "b", "-o", t.TempDir(), "--on-conflict", "keep-c"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--on-conflict") {
+		t.Fatalf("err = %v, want --on-conflict error", err)
+	}
+}
+
 func TestRunSnapshot_Success(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	archivePath := filepath.Join(t.TempDir(), "capture.tar.zst")
@@ -296,6 +543,61 @@ func TestRunSnapshot_Success(t *testing.T) {
 	}
 }
 
+func TestPackToBackend(t *testing.T) {
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+	backend := &mockBackend{}
+
+	size, err := packToBackend(context.Background(), dir, backend, "runs/run1.tar.zst")
+	if err != nil {
+		t.Fatalf("packToBackend: %v", err)
+	}
+	if len(backend.uploads) != 1 {
+		t.Fatalf("uploads = %d, want 1", len(backend.uploads))
+	}
+	up := backend.uploads[0]
+	if up.Key != "runs/run1.tar.zst" || up.Size != -1 {
+		t.Errorf("upload key/size = %q/%d, want streamed runs/run1.tar.zst", up.Key, up.Size)
+	}
+	if size != int64(len(up.Data)) {
+		t.Errorf("reported size %d, uploaded %d bytes", size, len(up.Data))
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "run1.tar.zst")
+	if err := os.WriteFile(archivePath, up.Data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	extractDir := filepath.Join(t.TempDir(), "extract")
+	if err := archive.Unpack(archivePath, extractDir); err != nil {
+		t.Fatalf("uploaded archive does not unpack: %v", err)
+	}
+}
+
+func TestPackToBackend_Errors(t *testing.T) {
+	t.Run("not a capture", func(t *testing.T) {
+		backend := &mockBackend{}
+		if _, err := packToBackend(context.Background(), t.TempDir(), backend, "k"); err == nil {
+			t.Fatal("expected error for missing metadata.json")
+		}
+	})
+	t.Run("upload fails", func(t *testing.T) {
+		dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+		backend := &mockBackend{uploadErr: fmt.Errorf("access denied")}
+		if _, err := packToBackend(context.Background(), dir, backend, "k"); err == nil {
+			t.Fatal("expected upload error")
+		}
+	})
+}
+
+func TestRunSnapshot_RemoteErrors(t *testing.T) {
+	if err := runSnapshot("archive.tar.zst", "s3://bucket/out", true, false); err == nil {
+		t.Error("expected error for --extract to a cloud URL")
+	}
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+	if err := runSnapshot(dir, "s3://bucket", false, false); err == nil || !strings.Contains(err.Error(), "no object key") {
+		t.Errorf("expected missing key error, got %v", err)
+	}
+}
+
 func TestRunTriage_Success(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 
@@ -303,7 +605,7 @@ func TestRunTriage_Success(t *testing.T) {
 		restore := redirectOutput(t)
 		defer restore()
 
-		if err := runTriage(dir, "", 1, time.Minute, 5, 10000, true, false); err != nil {
+		if err := runTriage(dir, "", 1, time.Minute, 5, 10000, nil, archive.CorrelateConfig{}, nil, nil, nil, true, false, false, ""); err != nil {
 			t.Fatalf("runTriage json: %v", err)
 		}
 	})
@@ -313,7 +615,7 @@ func TestRunTriage_Success(t *testing.T) {
 		defer restore()
 
 		outDir := filepath.Join(t.TempDir(), "triage")
-		if err := runTriage(dir, outDir, 1, time.Minute, 5, 10000, false, false); err != nil {
+		if err := runTriage(dir, outDir, 1, time.Minute, 5, 10000, nil, archive.CorrelateConfig{}, nil, nil, nil, false, false, false, ""); err != nil {
 			t.Fatalf("runTriage files: %v", err)
 		}
 		if _, err := os.Stat(filepath.Join(outDir, "summary.md")); err != nil {
@@ -322,6 +624,93 @@ func TestRunTriage_Success(t *testing.T) {
 	})
 }
 
+func TestRunTriage_DetectAnomalies(t *testing.T) {
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+	outDir := filepath.Join(t.TempDir(), "triage")
+
+	restore := redirectOutput(t)
+	defer restore()
+
+	if err := runTriage(dir, outDir, 1, time.Minute, 5, 10000, nil, archive.CorrelateConfig{}, &archive.AnomalyConfig{}, nil, nil, false, false, false, ""); err != nil {
+		t.Fatalf("runTriage: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "anomalies.json"))
+	if err != nil {
+		t.Fatalf("anomalies.json missing: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "[]" {
+		t.Errorf("anomalies.json = %s, want [] for a capture shorter than the warmup", data)
+	}
+}
+
+func TestRunTriage_Latency(t *testing.T) {
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "web"}, Message: `{"msg":"GET /","duration_ms":120}`},
+		{Timestamp: base.Add(time.Second), Labels: map[string]string{"app": "web"}, Message: `{"msg":"GET /","duration_ms":80}`},
+	})
+	outDir := filepath.Join(t.TempDir(), "triage")
+
+	restore := redirectOutput(t)
+	defer restore()
+
+	latency := &archive.LatencyConfig{Field: "duration_ms", Unit: time.Millisecond, GroupBy: "app"}
+	if err := runTriage(dir, outDir, 1, time.Minute, 5, 10000, nil, archive.CorrelateConfig{}, nil, nil, latency, false, false, false, ""); err != nil {
+		t.Fatalf("runTriage: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "latency.csv"))
+	if err != nil {
+		t.Fatalf("latency.csv missing: %v", err)
+	}
+	if !strings.Contains(string(data), "2025-01-15T10:00:00Z,web,2,") {
+		t.Errorf("latency.csv = %s", data)
+	}
+}
+
+func TestLatencyConfig(t *testing.T) {
+	cases := []struct {
+		args    []string
+		wantNil bool
+		wantErr string
+	}{
+		{args: nil, wantNil: true},
+		{args: []string{"--latency-field", "duration_ms"}},
+		{args: []string{"--latency-regex", `took (\d+)ms`, "--latency-unit", "s", "--latency-by", "service"}},
+		{args: []string{"--latency-field", "d", "--latency-regex", "x"}, wantErr: "mutually exclusive"},
+		{args: []string{"--latency-field", "d", "--latency-unit", "minutes"}, wantErr: "invalid --latency-unit"},
+		{args: []string{"--latency-regex", "("}, wantErr: "invalid --latency-regex"},
+	}
+	for _, tc := range cases {
+		cmd := newTriageCmd()
+		if err := cmd.ParseFlags(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := latencyConfig(cmd)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%v: err = %v, want %q", tc.args, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tc.args, err)
+			continue
+		}
+		if (cfg == nil) != tc.wantNil {
+			t.Errorf("%v: cfg = %+v", tc.args, cfg)
+		}
+	}
+
+	cmd := newReportCmd()
+	if err := cmd.ParseFlags([]string{"--latency-regex", `took (\d+)`, "--latency-unit", "s", "--latency-by", "service"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := latencyConfig(cmd)
+	if err != nil || cfg.Unit != time.Second || cfg.GroupBy != "service" || cfg.Pattern == nil {
+		t.Errorf("report latency config = %+v, %v", cfg, err)
+	}
+}
+
 func TestRunTriage_HTML(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	outDir := filepath.Join(t.TempDir(), "triage-html")
@@ -329,7 +718,7 @@ func TestRunTriage_HTML(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runTriage(dir, outDir, 1, time.Minute, 5, 10000, false, true); err != nil {
+	if err := runTriage(dir, outDir, 1, time.Minute, 5, 10000, nil, archive.CorrelateConfig{}, nil, nil, nil, false, true, false, ""); err != nil {
 		t.Fatalf("runTriage html: %v", err)
 	}
 	if _, err := os.Stat(filepath.Join(outDir, "report.html")); err != nil {
@@ -342,7 +731,7 @@ func TestRunGrep_NoMatches(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runGrep("zzz_no_match_zzz", dir, "", "", nil, false, false, "json", 0); err != nil {
+	if err := runGrep("zzz_no_match_zzz", dir, "", "", nil, false, false, "json", 0, false, false, lifecycleFilter{}); err != nil {
 		t.Fatalf("runGrep no match: %v", err)
 	}
 }
@@ -352,7 +741,7 @@ func TestRunGrep_WithLabelFilter(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runGrep("hello", dir, "", "", []string{"app=web"}, false, false, "json", 0); err != nil {
+	if err := runGrep("hello", dir, "", "", []string{"app=web"}, false, false, "json", 0, false, false, lifecycleFilter{}); err != nil {
 		t.Fatalf("runGrep label: %v", err)
 	}
 }
@@ -364,7 +753,7 @@ func TestRunExport_CSV(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runExport(dir, "csv", "", "", nil, "", outPath, false); err != nil {
+	if err := runExport(dir, "csv", "", "", nil, "", outPath, false, false, false, false, nil, false, lifecycleFilter{}); err != nil {
 		t.Fatalf("runExport csv: %v", err)
 	}
 	if _, err := os.Stat(outPath); err != nil {
@@ -379,7 +768,7 @@ func TestRunExport_Parquet(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runExport(dir, "parquet", "", "", nil, "", outPath, false); err != nil {
+	if err := runExport(dir, "parquet", "", "", nil, "", outPath, false, false, false, false, nil, false, lifecycleFilter{}); err != nil {
 		t.Fatalf("runExport parquet: %v", err)
 	}
 	if _, err := os.Stat(outPath); err != nil {
@@ -387,6 +776,169 @@ func TestRunExport_Parquet(t *testing.T) {
 	}
 }
 
+func TestRunExport_ToLoki(t *testing.T) {
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, sampleEntries(base))
+
+	var tenant string
+	var values [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant = r.Header.Get("X-Scope-OrgID")
+		var req struct {
+			Streams []struct {
+				Stream map[string]string `json:"stream"`
+				Values [][]string        `json:"values"`
+			} `json:"streams"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode push: %v", err)
+		}
+		for _, s := range req.Streams {
+			if s.Stream["app"] != "web" {
+				t.Errorf("stream = %v, want app=web", s.Stream)
+			}
+			values = append(values, s.Values...)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	restore := redirectOutput(t)
+	defer restore()
+
+	loki := lokiExport{url: srv.URL, tenant: "team-a", batch: 1}
+	if err := runExportLoki(dir, loki, "", "", nil, "", false, false, false, lifecycleFilter{}); err != nil {
+		t.Fatalf("runExportLoki: %v", err)
+	}
+	if tenant != "team-a" {
+		t.Errorf("tenant = %q, want team-a", tenant)
+	}
+	want := [][]string{
+		{fmt.Sprint(base.UnixNano()), "hello world"},
+		{fmt.Sprint(base.Add(2 * time.Second).UnixNano()), "error: boom"},
+	}
+	if fmt.Sprint(values) != fmt.Sprint(want) {
+		t.Errorf("pushed %v, want %v", values, want)
+	}
+	if _, err := os.Stat(lokiCheckpointPath(dir)); !os.IsNotExist(err) {
+		t.Errorf("checkpoint left behind: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{dir, "--to-loki", srv.URL, "--format", "csv"},
+		{dir, "--tenant", "team-a", "--format", "csv", "--out", "x.csv"},
+		{dir, "--format", "csv"},
+	} {
+		cmd := newExportCmd()
+		cmd.SetArgs(args)
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("export %v: expected usage error", args[1:])
+		}
+	}
+}
+
+func TestRunExport_ToElastic(t *testing.T) {
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, sampleEntries(base))
+
+	var paths, lines []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.URL.Path != "/_bulk" {
+			_, _ = w.Write([]byte(`{"acknowledged":true}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+		_, _ = w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`))
+	}))
+	defer srv.Close()
+
+	restore := redirectOutput(t)
+	defer restore()
+
+	elastic := elasticExport{url: srv.URL, index: defaultElasticIndex, template: "logtap"}
+	if err := runExportElastic(dir, elastic, "", "", nil, "", false, false, false, lifecycleFilter{}); err != nil {
+		t.Fatalf("runExportElastic: %v", err)
+	}
+	if want := "[PUT /_index_template/logtap POST /_bulk]"; fmt.Sprint(paths) != want {
+		t.Errorf("requests = %v, want %s", paths, want)
+	}
+	if len(lines) != 4 || !strings.Contains(lines[0], `"_index":"logtap-2025.01.15"`) ||
+		lines[1] != `{"@timestamp":"2025-01-15T10:00:00Z","message":"hello world","labels":{"app":"web"}}` {
+		t.Errorf("bulk body = %q", lines)
+	}
+
+	elastic.index = "Logtap"
+	if err := runExportElastic(dir, elastic, "", "", nil, "", false, false, false, lifecycleFilter{}); err == nil {
+		t.Error("uppercase index should be rejected")
+	}
+	for _, args := range [][]string{
+		{dir, "--to-elastic", srv.URL, "--to-loki", srv.URL},
+		{dir, "--to-elastic", srv.URL, "--tenant", "a"},
+		{dir, "--index", "x", "--format", "csv", "--out", "x.csv"},
+	} {
+		cmd := newExportCmd()
+		cmd.SetArgs(args)
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("export %v: expected usage error", args[1:])
+		}
+	}
+}
+
+func TestRunExport_ToSplunk(t *testing.T) {
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, sampleEntries(base))
+
+	var auth string
+	var lines []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+		_, _ = w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer srv.Close()
+
+	restore := redirectOutput(t)
+	defer restore()
+
+	splunk := splunkExport{url: srv.URL, token: "tok", index: "load", sourcetype: "logtap", mapping: []string{"source=app"}}
+	if err := runExportSplunk(dir, splunk, "", "", nil, "", false, false, false, lifecycleFilter{}); err != nil {
+		t.Fatalf("runExportSplunk: %v", err)
+	}
+	if auth != "Splunk tok" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if len(lines) != 2 || lines[0] != `{"time":1736935200.000000,"source":"web","sourcetype":"logtap","index":"load","event":"hello world","fields":{"app":"web"}}` {
+		t.Errorf("HEC body = %q", lines)
+	}
+
+	splunk.token = ""
+	if err := runExportSplunk(dir, splunk, "", "", nil, "", false, false, false, lifecycleFilter{}); err == nil {
+		t.Error("missing --hec-token should be rejected")
+	}
+	for _, args := range [][]string{
+		{dir, "--to-splunk", srv.URL, "--to-elastic", srv.URL},
+		{dir, "--to-splunk", srv.URL, "--hec-token", "t", "--template", "x"},
+		{dir, "--to-loki", srv.URL, "--ack"},
+		{dir, "--sourcetype", "x", "--format", "csv", "--out", "x.csv"},
+		{dir, "--hec-token", "t", "--format", "csv", "--out", "x.csv"},
+	} {
+		cmd := newExportCmd()
+		cmd.SetArgs(args)
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("export %v: expected usage error", args[1:])
+		}
+	}
+}
+
 func TestRunSlice_WithFilter(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	outDir := filepath.Join(t.TempDir(), "slice-filtered")
@@ -394,7 +946,7 @@ func TestRunSlice_WithFilter(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runSlice(dir, "", "", []string{"app=web"}, "", outDir); err != nil {
+	if err := runSlice(dir, "", "", []string{"app=web"}, "", outDir, lifecycleFilter{}); err != nil {
 		t.Fatalf("runSlice with filter: %v", err)
 	}
 }
@@ -403,7 +955,7 @@ func TestRunMerge_SingleDir(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	outDir := filepath.Join(t.TempDir(), "merged")
 
-	err := runMerge([]string{dir}, outDir, false, false)
+	err := runMerge([]string{dir}, outDir, false, false, archive.ConflictMix)
 	if err == nil {
 		t.Fatal("expected error for single capture merge")
 	}
@@ -515,7 +1067,7 @@ func TestDiffCIJSON_Contract(t *testing.T) {
 	dirB := makeCaptureDir(t, sampleEntries(base))
 
 	out := captureStdout(t, func() {
-		if err := runBaselineDiff(dirA, dirB, true, true, []string{"regression"}); err != nil {
+		if err := runBaselineDiff(dirA, dirB, archive.BaselineDiffConfig{}, true, true, []string{"regression"}); err != nil {
 			t.Fatalf("runBaselineDiff CI: %v", err)
 		}
 	})
@@ -535,7 +1087,7 @@ func TestGrepJSON_Contract(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 
 	out := captureStdout(t, func() {
-		if err := runGrep("error", dir, "", "", nil, false, false, "json", 0); err != nil {
+		if err := runGrep("error", dir, "", "", nil, false, false, "json", 0, false, false, lifecycleFilter{}); err != nil {
 			t.Fatalf("runGrep: %v", err)
 		}
 	})
@@ -562,7 +1114,7 @@ func TestTriageJSON_Contract(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 
 	out := captureStdout(t, func() {
-		if err := runTriage(dir, "", 1, time.Minute, 5, 10000, true, false); err != nil {
+		if err := runTriage(dir, "", 1, time.Minute, 5, 10000, nil, archive.CorrelateConfig{}, nil, nil, nil, true, false, false, ""); err != nil {
 			t.Fatalf("runTriage: %v", err)
 		}
 	})
@@ -695,7 +1247,7 @@ func TestTriageSequence_Contract(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runTriage(dir, outDir, 1, time.Minute, 5, 10000, false, false); err != nil {
+	if err := runTriage(dir, outDir, 1, time.Minute, 5, 10000, nil, archive.CorrelateConfig{}, nil, nil, nil, false, false, false, ""); err != nil {
 		t.Fatalf("runTriage: %v", err)
 	}
 
@@ -720,7 +1272,7 @@ func TestMergeJSON_Contract(t *testing.T) {
 	outDir := filepath.Join(t.TempDir(), "merged")
 
 	out := captureStdout(t, func() {
-		if err := runMerge([]string{dirA, dirB}, outDir, true, false); err != nil {
+		if err := runMerge([]string{dirA, dirB}, outDir, true, false, archive.ConflictMix); err != nil {
 			t.Fatalf("runMerge: %v", err)
 		}
 	})
@@ -757,7 +1309,7 @@ func TestMergeClockCorrect_Contract(t *testing.T) {
 	outDir := filepath.Join(t.TempDir(), "merged-corrected")
 
 	out := captureStdout(t, func() {
-		if err := runMerge([]string{dirA, dirB}, outDir, true, true); err != nil {
+		if err := runMerge([]string{dirA, dirB}, outDir, true, true, archive.ConflictMix); err != nil {
 			t.Fatalf("runMerge clock-correct: %v", err)
 		}
 	})
@@ -772,3 +1324,246 @@ func TestMergeClockCorrect_Contract(t *testing.T) {
 		}
 	}
 }
+
+func TestRunAssert(t *testing.T) {
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+
+	out := captureStdout(t, func() {
+		if err := runAssert(dir, []string{`count({app="web"}) == 2`, `absent(|= "panic")`}, "", "", false); err != nil {
+			t.Errorf("runAssert: %v", err)
+		}
+	})
+	if !strings.Contains(out, "2/2 assertions passed") {
+		t.Errorf("output = %q", out)
+	}
+
+	var err error
+	out = captureStdout(t, func() {
+		err = runAssert(dir, []string{`absent(|= "boom")`}, "", "", true)
+	})
+	if cli.ExitCode(err) != cli.ExitFindings {
+		t.Errorf("exit code = %d, want %d (err %v)", cli.ExitCode(err), cli.ExitFindings, err)
+	}
+	if !strings.Contains(out, `"failed": 1`) {
+		t.Errorf("json output = %q", out)
+	}
+
+	// --to excludes the second entry.
+	captureStdout(t, func() {
+		err = runAssert(dir, []string{`absent(|= "boom")`}, "", "10:00", false)
+	})
+	if err != nil {
+		t.Errorf("windowed assert: %v", err)
+	}
+
+	err = runAssert(dir, []string{`count(|= "x")`}, "", "", false)
+	if cli.ExitCode(err) != cli.ExitUsage {
+		t.Errorf("invalid expression exit code = %d, want %d", cli.ExitCode(err), cli.ExitUsage)
+	}
+	if err := runAssert(dir, nil, "", "", false); cli.ExitCode(err) != cli.ExitUsage {
+		t.Errorf("missing --expect exit code = %d", cli.ExitCode(err))
+	}
+	if err := runAssert(t.TempDir(), []string{`present(|= "x")`}, "", "", false); err == nil {
+		t.Error("expected error for missing capture")
+	}
+}
+
+func TestLoadOwners(t *testing.T) {
+	dir := t.TempDir()
+	if o, err := loadOwners("", dir); o != nil || err != nil {
+		t.Fatalf("no owners file: %v, %v; want nil, nil", o, err)
+	}
+
+	rules := "owners:\n  - team: api\n    labels:\n      app: [api]\n"
+	if err := os.WriteFile(filepath.Join(dir, ownersFile), []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if o, err := loadOwners("", dir); o == nil || err != nil {
+		t.Fatalf("capture owners.yaml: %v, %v", o, err)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(bad, []byte("owners:\n  - labels: {app: [x]}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadOwners(bad, dir); err == nil {
+		t.Error("expected error for rule without team")
+	}
+}
+
+func TestRunCompact(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, []recv.LogEntry{{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "first"}})
+	second := []recv.LogEntry{{Timestamp: base.Add(time.Second), Labels: map[string]string{"app": "api"}, Message: "second"}}
+	size := writeDataFile(t, dir, "2024-01-15T100001-000.jsonl", second)
+	index, err := os.ReadFile(filepath.Join(dir, "index.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra, _ := json.Marshal(rotate.IndexEntry{File: "2024-01-15T100001-000.jsonl", From: base.Add(time.Second), To: base.Add(time.Second), Lines: 1, Bytes: size})
+	if err := os.WriteFile(filepath.Join(dir, "index.jsonl"), append(append(index, extra...), '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runCompact(dir, "0", false, false); err == nil || !strings.Contains(err.Error(), "--target-size") {
+		t.Errorf("err = %v, want --target-size error", err)
+	}
+	restore := redirectOutput(t)
+	err = runCompact(dir, "1MB", false, true)
+	restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := archive.NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.Files()) != 1 || reader.TotalLines() != 2 {
+		t.Errorf("files = %d, lines = %d; want 1 file with 2 lines", len(reader.Files()), reader.TotalLines())
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "first"},
+		{Timestamp: base.Add(time.Second), Labels: map[string]string{"app": "api"}, Message: "second"},
+	})
+
+	restore := redirectOutput(t)
+	err := runVerify(dir, false, true)
+	restore()
+	if err != nil {
+		t.Fatalf("intact capture: %v", err)
+	}
+
+	name := base.Format("2006-01-02T150405") + "-000.jsonl"
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data[:len(data)-4], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	restore = redirectOutput(t)
+	err = runVerify(dir, false, false)
+	restore()
+	if cli.ExitCode(err) != cli.ExitFindings {
+		t.Errorf("exit code = %d, want %d (err %v)", cli.ExitCode(err), cli.ExitFindings, err)
+	}
+}
+
+func TestRunMigrate(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "first"},
+	})
+
+	restore := redirectOutput(t)
+	err := runMigrate(dir, false, true)
+	restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Version != recv.MetadataVersion {
+		t.Errorf("version = %d, want %d", meta.Version, recv.MetadataVersion)
+	}
+
+	restore = redirectOutput(t)
+	err = runMigrate(t.TempDir(), false, false)
+	restore()
+	if err == nil {
+		t.Error("expected error for a directory without metadata")
+	}
+}
+
+func TestRunSQL(t *testing.T) {
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+	query := "SELECT label('app') AS app, count(*) AS n, max(ts) FROM logs GROUP BY 1"
+
+	out := captureStdout(t, func() {
+		if err := runSQL(dir, query, "table"); err != nil {
+			t.Errorf("runSQL table: %v", err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "app") || !strings.Contains(lines[1], "web  2") {
+		t.Errorf("table output = %q", out)
+	}
+
+	out = captureStdout(t, func() {
+		if err := runSQL(dir, query, "json"); err != nil {
+			t.Errorf("runSQL json: %v", err)
+		}
+	})
+	if want := `{"app":"web","n":2,"max(ts)":"2025-01-15T10:00:02Z"}`; strings.TrimSpace(out) != want {
+		t.Errorf("json output = %q, want %q", out, want)
+	}
+
+	out = captureStdout(t, func() {
+		if err := runSQL(dir, "SELECT msg, label('pod') FROM logs WHERE msg LIKE 'error%'", "csv"); err != nil {
+			t.Errorf("runSQL csv: %v", err)
+		}
+	})
+	if want := "msg,label('pod')\nerror: boom,\n"; out != want {
+		t.Errorf("csv output = %q, want %q", out, want)
+	}
+
+	var cliErr *cli.CLIError
+	if err := runSQL(dir, "SELECT app FROM logs", "table"); !errors.As(err, &cliErr) {
+		t.Errorf("unknown column: err = %v, want a usage error", err)
+	}
+	if err := runSQL(dir, query, "yaml"); !errors.As(err, &cliErr) {
+		t.Errorf("--format yaml: err = %v, want a usage error", err)
+	}
+}
+
+func TestRunCatalog_ToElastic(t *testing.T) {
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, sampleEntries(base))
+	root := filepath.Dir(dir)
+	if err := os.Mkdir(filepath.Join(root, "active"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := recv.WriteMetadata(filepath.Join(root, "active"), &recv.Metadata{Version: 1, Format: "jsonl", Started: base}); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+		_, _ = w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer srv.Close()
+
+	restore := redirectOutput(t)
+	defer restore()
+
+	elastic := catalogElastic{url: srv.URL, index: "logtap-runs-%{+yyyy.MM}", jobs: 1}
+	if err := runCatalogElastic(root, false, elastic, false); err != nil {
+		t.Fatalf("runCatalogElastic: %v", err)
+	}
+	if len(lines) != 2 || !strings.Contains(lines[0], `"_index":"logtap-runs-2025.01"`) ||
+		!strings.Contains(lines[1], `"@timestamp":"2025-01-15T10:00:00Z"`) || !strings.Contains(lines[1], `"severity":`) {
+		t.Errorf("bulk body = %q", lines)
+	}
+
+	for _, args := range [][]string{
+		{root, "--baseline", dir},
+		{root, "--to-elastic", srv.URL, "--index", "Runs"},
+	} {
+		cmd := newCatalogCmd()
+		cmd.SetArgs(args)
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("catalog %v: expected error", args[1:])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
+)
+
+func newAssertCmd() *cobra.Command {
+	var (
+		expects    []string
+		fromStr    string
+		toStr      string
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "assert <capture-dir>",
+		Short: "Check a capture against log-based expectations",
+		Long: `Evaluate assertions over a capture and exit non-zero when any fails, so
+log-based acceptance checks can be scripted after a load test.
+
+Each --expect is one of:
+  count(<selector>) <op> <number>   op: > >= < <= == !=
+  absent(<selector>)                no matching lines
+  present(<selector>)               at least one matching line
+
+A selector is a LogQL-style stream selector and/or line filters:
+  {app="web", pod=~"api-.*"} |= "order completed" != "retry"
+
+All assertions are evaluated in a single pass. Exits with code 6 when any
+assertion fails.`,
+		Example: `  logtap assert ./capture \
+    --expect 'count({app="web"} |= "order completed") > 1000' \
+    --expect 'absent(|= "deadlock")'`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAssert(args[0], expects, fromStr, toStr, jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&expects, "expect", nil, "assertion expression (repeatable)")
+	cmd.Flags().StringVar(&fromStr, "from", "", "start time filter (RFC3339, HH:MM, or -30m)")
+	cmd.Flags().StringVar(&toStr, "to", "", "end time filter (RFC3339, HH:MM, or -30m)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output as JSON")
+	addFormatAlias(cmd, &jsonOutput)
+	_ = cmd.MarkFlagRequired("expect")
+
+	return cmd
+}
+
+func runAssert(src string, expects []string, fromStr, toStr string, jsonOutput bool) error {
+	if len(expects) == 0 {
+		return cli.NewUsageError("assert: at least one --expect is required")
+	}
+	assertions := make([]*archive.Assertion, 0, len(expects))
+	for _, e := range expects {
+		a, err := archive.ParseAssertion(e)
+		if err != nil {
+			return cli.NewUsageError(fmt.Sprintf("invalid --expect: %v", err))
+		}
+		assertions = append(assertions, a)
+	}
+
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("open capture: %w", err)
+	}
+	filter, err := buildFilter(fromStr, toStr, nil, "", reader.Metadata())
+	if err != nil {
+		return err
+	}
+
+	result, err := archive.Assert(src, assertions, filter)
+	if err != nil {
+		return fmt.Errorf("assert: %w", err)
+	}
+	if jsonOutput {
+		if err := result.WriteJSON(os.Stdout); err != nil {
+			return err
+		}
+	} else {
+		result.WriteText(os.Stdout)
+	}
+
+	if !result.Passed {
+		return cli.NewFindingsError(fmt.Sprintf("assert: %d of %d assertions failed", result.Failed, len(result.Assertions)))
+	}
+	return nil
+}
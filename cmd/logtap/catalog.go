@@ -1,29 +1,74 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
+	"github.com/ppiankov/logtap/internal/forward"
 )
 
+// defaultRunIndex is the index catalog --to-elastic writes run summaries to.
+const defaultRunIndex = "logtap-runs"
+
+// catalogElastic holds the --to-elastic flags of catalog.
+type catalogElastic struct {
+	url        string
+	index      string
+	apiKey     string
+	baseline   string
+	ownersPath string
+	jobs       int
+	top        int
+}
+
 func newCatalogCmd() *cobra.Command {
 	var (
 		jsonOutput bool
 		recursive  bool
+		elastic    catalogElastic
 	)
 
 	cmd := &cobra.Command{
 		Use:   "catalog [dir]",
 		Short: "Discover and list capture directories",
-		Long:  "Scan a directory for logtap captures (directories containing metadata.json) and list them with summary information.",
-		Args:  cobra.MaximumNArgs(1),
+		Long: `Scan a directory for logtap captures (directories containing metadata.json) and list them with summary information.
+
+With --to-elastic each finished capture is triaged and one summary document
+per run (metadata, totals, severity, top error signatures and, with
+--baseline, the baseline verdict) is indexed into Elasticsearch or
+OpenSearch, so load test history can be dashboarded outside logtap.
+Documents are keyed by capture path and start time: exporting again updates
+them.`,
+		Args: cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if elastic.url != "" {
+				return nil
+			}
+			for _, name := range []string{"index", "api-key", "baseline", "owners", "jobs", "top"} {
+				if cmd.Flags().Changed(name) {
+					return cli.NewUsageError(fmt.Sprintf("--%s requires --to-elastic", name))
+				}
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			root := "."
 			if len(args) > 0 {
 				root = args[0]
 			}
+			if elastic.url != "" {
+				return runCatalogElastic(root, recursive, elastic, jsonOutput)
+			}
 			return runCatalog(root, recursive, jsonOutput)
 		},
 	}
@@ -31,6 +76,13 @@ func newCatalogCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output as JSON")
 	addFormatAlias(cmd, &jsonOutput)
 	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "scan subdirectories recursively")
+	cmd.Flags().StringVar(&elastic.url, "to-elastic", "", "index a summary document per capture into Elasticsearch or OpenSearch at this URL (e.g. https://es:9200)")
+	cmd.Flags().StringVar(&elastic.index, "index", defaultRunIndex, "index name with --to-elastic, where %{+yyyy.MM.dd} is replaced by each capture's UTC start date")
+	cmd.Flags().StringVar(&elastic.apiKey, "api-key", "", "Elasticsearch API key (base64 id:key); basic auth goes in the URL")
+	cmd.Flags().StringVar(&elastic.baseline, "baseline", "", "capture each run is diffed against for a verdict with --to-elastic")
+	cmd.Flags().StringVar(&elastic.ownersPath, "owners", "", ownersFlagUsage)
+	cmd.Flags().IntVar(&elastic.jobs, "jobs", runtime.NumCPU(), "parallel scan workers per capture")
+	cmd.Flags().IntVar(&elastic.top, "top", archive.DefaultRunTopErrors, "number of top error signatures per run")
 
 	return cmd
 }
@@ -48,3 +100,74 @@ func runCatalog(root string, recursive, jsonOutput bool) error {
 	archive.WriteCatalogText(os.Stdout, entries)
 	return nil
 }
+
+func runCatalogElastic(root string, recursive bool, elastic catalogElastic, jsonOutput bool) error {
+	pattern, err := forward.ParseIndexPattern(elastic.index)
+	if err != nil {
+		return cli.NewUsageError(fmt.Sprintf("invalid --index: %v", err))
+	}
+	entries, err := archive.Catalog(root, recursive)
+	if err != nil {
+		return err
+	}
+
+	var (
+		docs    []forward.ElasticDoc
+		skipped int
+	)
+	for _, entry := range entries {
+		// a capture still being written has no final totals yet
+		if entry.Active {
+			skipped++
+			continue
+		}
+		owners, err := loadOwners(elastic.ownersPath, entry.Dir)
+		if err != nil {
+			return err
+		}
+		doc, err := archive.SummarizeRun(entry, archive.RunSummaryConfig{
+			Jobs:     elastic.jobs,
+			Top:      elastic.top,
+			Owners:   owners,
+			Baseline: elastic.baseline,
+		})
+		if err != nil {
+			return err
+		}
+		source, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, forward.ElasticDoc{Index: pattern.Name(doc.Timestamp), ID: doc.ID, Source: source})
+	}
+
+	var result forward.BulkResult
+	if len(docs) > 0 {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		client := forward.NewElasticClient(elastic.url)
+		client.SetAPIKey(elastic.apiKey)
+		if result, err = client.Bulk(ctx, docs); err != nil {
+			return err
+		}
+	}
+
+	if jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"target":   redactedURL(elastic.url),
+			"index":    elastic.index,
+			"runs":     result.Indexed,
+			"skipped":  skipped,
+			"rejected": result.Rejected,
+		}); err != nil {
+			return err
+		}
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "Indexed: %d runs -> %s/%s (%d active skipped)\n",
+			result.Indexed, strings.TrimRight(redactedURL(elastic.url), "/"), pattern.Wildcard(), skipped)
+	}
+	if result.Rejected > 0 {
+		return fmt.Errorf("%d documents rejected by the cluster, first: %s", result.Rejected, result.FirstError)
+	}
+	return nil
+}
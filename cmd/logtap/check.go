@@ -46,11 +46,50 @@ func newCheckCmd() *cobra.Command {
 	return cmd
 }
 
+// printClusterInfo writes the cluster section of the text report.
+func printClusterInfo(info *k8s.ClusterInfo) {
+	fmt.Fprintf(os.Stderr, "Cluster:       %s (API %.0fms)\n", info.Version, info.APILatencyMs)
+	ns := info.Namespace
+	if level := info.PodSecurity[info.Namespace]; level != "" {
+		ns += " (pod security: " + level + ")"
+	}
+	fmt.Fprintf(os.Stderr, "Namespace:     %s\n", ns)
+	if n := info.Nodes; n != nil {
+		line := fmt.Sprintf("%d (%d ready", n.Total, n.Ready)
+		for _, c := range []struct {
+			count int
+			what  string
+		}{
+			{n.Unschedulable, "unschedulable"},
+			{n.MemoryPressure, "memory pressure"},
+			{n.DiskPressure, "disk pressure"},
+			{n.PIDPressure, "PID pressure"},
+		} {
+			if c.count > 0 {
+				line += fmt.Sprintf(", %d %s", c.count, c.what)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Nodes:         %s)\n", line)
+	}
+	if info.DefaultStorageClass != "" {
+		fmt.Fprintf(os.Stderr, "Storage class: %s (default)\n", info.DefaultStorageClass)
+	}
+	for _, sec := range []struct{ key, name string }{
+		{"nodes", "Nodes:        "},
+		{"storage_class", "Storage class:"},
+		{"pod_security", "Pod security: "},
+	} {
+		if msg, ok := info.Errors[sec.key]; ok {
+			fmt.Fprintf(os.Stderr, "%s error: %s\n", sec.name, msg)
+		}
+	}
+}
+
 func runCheck(namespace string, jsonOutput bool) error {
 	ctx, cancel := clusterContext()
 	defer cancel()
 
-	c, err := k8s.NewClient(namespace)
+	c, err := newK8sClient(namespace)
 	if err != nil {
 		return fmt.Errorf("connect to cluster: %w", err)
 	}
@@ -64,8 +103,7 @@ func runCheck(namespace string, jsonOutput bool) error {
 	} else if err == nil {
 		result.Cluster = info
 		if !jsonOutput {
-			fmt.Fprintf(os.Stderr, "Cluster:       %s\n", info.Version)
-			fmt.Fprintf(os.Stderr, "Namespace:     %s\n", info.Namespace)
+			printClusterInfo(info)
 		}
 	}
 
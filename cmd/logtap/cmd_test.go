@@ -8,7 +8,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
 	"github.com/ppiankov/logtap/internal/config"
+	"github.com/ppiankov/logtap/internal/k8s"
 	"github.com/spf13/cobra"
 )
 
@@ -42,12 +45,14 @@ func TestExecute_SubcommandRegistration(t *testing.T) {
 	root.AddCommand(newDownloadCmd())
 	root.AddCommand(newGCCmd())
 	root.AddCommand(newWatchCmd())
+	root.AddCommand(newQueryCmd())
+	root.AddCommand(newTailCmd())
 
 	expected := []string{
 		"version", "recv", "open", "inspect", "slice", "export", "triage",
 		"grep", "merge", "snapshot", "diff", "completion",
 		"tap", "untap", "check", "status", "deploy", "upload", "download", "gc",
-		"watch",
+		"watch", "query", "tail",
 	}
 
 	commands := make(map[string]bool)
@@ -87,6 +92,8 @@ func TestSubcommandHelp(t *testing.T) {
 		newDownloadCmd,
 		newGCCmd,
 		newWatchCmd,
+		newQueryCmd,
+		newTailCmd,
 	}
 
 	for _, newCmd := range cmds {
@@ -176,6 +183,16 @@ func TestClusterContext_ConfigOverride(t *testing.T) {
 	}
 }
 
+func TestNewK8sClient_InvalidAuth(t *testing.T) {
+	oldAuth := k8sAuth
+	defer func() { k8sAuth = oldAuth }()
+
+	k8sAuth = k8s.AuthOptions{AsGroups: []string{"ci"}}
+	if _, err := newK8sClient("default"); err == nil {
+		t.Fatal("expected error for --as-group without --as")
+	}
+}
+
 func TestApplyConfigDefaults_NilConfig(t *testing.T) {
 	oldCfg := cfg
 	defer func() { cfg = oldCfg }()
@@ -211,6 +228,37 @@ func TestApplyConfigDefaults_SetsValues(t *testing.T) {
 	}
 }
 
+func TestApplyLanguagePacks(t *testing.T) {
+	oldCfg, oldPacks := cfg, langPacks
+	defer func() {
+		cfg, langPacks = oldCfg, oldPacks
+		_ = archive.SetLanguagePacks(nil)
+	}()
+	cfg = &config.Config{Defaults: config.DefaultsConfig{LanguagePacks: []string{"java", "cobol"}}}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().StringSliceVar(&langPacks, "language-pack", nil, "")
+	if err := applyLanguagePacks(cmd); err != nil {
+		t.Fatalf("config packs: %v", err)
+	}
+	if got := archive.ActiveLanguagePacks(); len(got) != 1 || got[0] != "java" {
+		t.Errorf("active from config = %v", got)
+	}
+
+	_ = cmd.Flags().Set("language-pack", "nginx")
+	if err := applyLanguagePacks(cmd); err != nil {
+		t.Fatalf("flag packs: %v", err)
+	}
+	if got := archive.ActiveLanguagePacks(); len(got) != 1 || got[0] != "nginx" {
+		t.Errorf("active from flag = %v", got)
+	}
+
+	_ = cmd.Flags().Set("language-pack", "cobol")
+	if err := applyLanguagePacks(cmd); err == nil {
+		t.Error("expected error for unknown pack on the command line")
+	}
+}
+
 func TestApplyConfigDefaults_FlagPrecedence(t *testing.T) {
 	oldCfg := cfg
 	defer func() { cfg = oldCfg }()
@@ -318,35 +366,35 @@ func TestRunDiff_InvalidDirs(t *testing.T) {
 }
 
 func TestRunSlice_InvalidDir(t *testing.T) {
-	err := runSlice("/nonexistent/dir", "", "", nil, "", "/tmp/out")
+	err := runSlice("/nonexistent/dir", "", "", nil, "", "/tmp/out", lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for nonexistent source dir")
 	}
 }
 
 func TestRunExport_InvalidFormat(t *testing.T) {
-	err := runExport("/nonexistent/dir", "xml", "", "", nil, "", "/tmp/out", false)
+	err := runExport("/nonexistent/dir", "xml", "", "", nil, "", "/tmp/out", false, false, false, false, nil, false, lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for invalid format")
 	}
 }
 
 func TestRunExport_InvalidDir(t *testing.T) {
-	err := runExport("/nonexistent/dir", "csv", "", "", nil, "", "/tmp/out", false)
+	err := runExport("/nonexistent/dir", "csv", "", "", nil, "", "/tmp/out", false, false, false, false, nil, false, lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for nonexistent dir")
 	}
 }
 
 func TestRunGrep_InvalidDir(t *testing.T) {
-	err := runGrep("pattern", "/nonexistent/dir", "", "", nil, false, false, "json", 0)
+	err := runGrep("pattern", "/nonexistent/dir", "", "", nil, false, false, "json", 0, false, false, lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for nonexistent dir")
 	}
 }
 
 func TestRunMerge_InvalidDirs(t *testing.T) {
-	err := runMerge([]string{"/nonexistent/a", "/nonexistent/b"}, "/tmp/out", false, false)
+	err := runMerge([]string{"/nonexistent/a", "/nonexistent/b"}, "/tmp/out", false, false, archive.ConflictMix)
 	if err == nil {
 		t.Error("expected error for nonexistent source dirs")
 	}
@@ -374,21 +422,21 @@ func TestRunOpen_InvalidDir(t *testing.T) {
 }
 
 func TestRunTriage_InvalidDir(t *testing.T) {
-	err := runTriage("/nonexistent/dir", "/tmp/out", 1, 60000000000, 50, 10000, false, false)
+	err := runTriage("/nonexistent/dir", "/tmp/out", 1, 60000000000, 50, 10000, nil, archive.CorrelateConfig{}, nil, nil, nil, false, false, false, "")
 	if err == nil {
 		t.Error("expected error for nonexistent dir")
 	}
 }
 
 func TestRunRecv_InvalidByteSize(t *testing.T) {
-	err := runRecv(":3100", "/tmp", "invalid", "50GB", true, "", "", 100, true, "", "", nil, "", "", "")
+	err := runRecv(recvOpts{listen: ":3100", dir: "/tmp", maxFile: "invalid", maxDisk: "50GB", compress: true, bufSize: 100, headless: true})
 	if err == nil {
 		t.Error("expected error for invalid max-file size")
 	}
 }
 
 func TestRunRecv_InvalidDiskSize(t *testing.T) {
-	err := runRecv(":3100", "/tmp", "256MB", "invalid", true, "", "", 100, true, "", "", nil, "", "", "")
+	err := runRecv(recvOpts{listen: ":3100", dir: "/tmp", maxFile: "256MB", maxDisk: "invalid", compress: true, bufSize: 100, headless: true})
 	if err == nil {
 		t.Error("expected error for invalid max-disk size")
 	}
@@ -396,7 +444,7 @@ func TestRunRecv_InvalidDiskSize(t *testing.T) {
 
 func TestRunRecv_InvalidRedactPatterns(t *testing.T) {
 	dir := t.TempDir()
-	err := runRecv(":0", dir, "256MB", "50GB", true, "true", "/nonexistent/patterns.yaml", 100, true, "", "", nil, "", "", "")
+	err := runRecv(recvOpts{listen: ":0", dir: dir, maxFile: "256MB", maxDisk: "50GB", compress: true, redact: "true", redactPatterns: "/nonexistent/patterns.yaml", bufSize: 100, headless: true})
 	if err == nil {
 		t.Error("expected error for nonexistent redact patterns file")
 	}
@@ -404,16 +452,32 @@ func TestRunRecv_InvalidRedactPatterns(t *testing.T) {
 
 func TestRunRecv_MissingDir(t *testing.T) {
 	// --dir is required
-	err := runRecv(":0", "", "256MB", "50GB", true, "", "", 100, true, "", "", nil, "", "", "")
+	err := runRecv(recvOpts{listen: ":0", maxFile: "256MB", maxDisk: "50GB", compress: true, bufSize: 100, headless: true})
 	// We check this in the command RunE, but runRecv itself creates the dir.
 	// Pass an empty dir — os.MkdirAll("") may fail on some systems.
 	// Just verify it doesn't panic.
 	_ = err
 }
 
+func TestRunRecv_AuthTokenUnauthenticatedListeners(t *testing.T) {
+	dir := t.TempDir()
+	base := recvOpts{listen: ":0", dir: dir, maxFile: "256MB", maxDisk: "50GB", bufSize: 100, headless: true, authToken: "s3cret"}
+
+	opts := base
+	opts.syslogListen = ":0"
+	if err := runRecv(opts); err == nil || !strings.Contains(err.Error(), "--syslog") {
+		t.Errorf("--syslog with --auth-token: err = %v", err)
+	}
+	opts = base
+	opts.forwardListen = ":0"
+	if err := runRecv(opts); err == nil || !strings.Contains(err.Error(), "--forward-shared-key") {
+		t.Errorf("--forward without --forward-shared-key: err = %v", err)
+	}
+}
+
 func TestRunRecv_InvalidRedactName(t *testing.T) {
 	dir := t.TempDir()
-	err := runRecv(":0", dir, "256MB", "50GB", true, "nonexistent_pattern_name", "", 100, true, "", "", nil, "", "", "")
+	err := runRecv(recvOpts{listen: ":0", dir: dir, maxFile: "256MB", maxDisk: "50GB", compress: true, redact: "nonexistent_pattern_name", bufSize: 100, headless: true})
 	if err == nil {
 		t.Error("expected error for invalid redact pattern name")
 	}
@@ -421,7 +485,7 @@ func TestRunRecv_InvalidRedactName(t *testing.T) {
 
 func TestRunRecv_InvalidBufferSize(t *testing.T) {
 	dir := t.TempDir()
-	err := runRecv(":0", dir, "256MB", "50GB", true, "", "", maxBufSize+1, true, "", "", nil, "", "", "")
+	err := runRecv(recvOpts{listen: ":0", dir: dir, maxFile: "256MB", maxDisk: "50GB", compress: true, bufSize: maxBufSize + 1, headless: true})
 	if err == nil {
 		t.Fatal("expected error for buffer size exceeding maximum")
 	}
@@ -433,7 +497,7 @@ func TestRunRecv_InvalidBufferSize(t *testing.T) {
 func TestRunRecv_BufferSizeBoundary(t *testing.T) {
 	// Exactly at maxBufSize should NOT trigger the validation error
 	dir := t.TempDir()
-	err := runRecv(":0", dir, "invalid-size", "50GB", true, "", "", maxBufSize, true, "", "", nil, "", "", "")
+	err := runRecv(recvOpts{listen: ":0", dir: dir, maxFile: "invalid-size", maxDisk: "50GB", compress: true, bufSize: maxBufSize, headless: true})
 	// Should fail on parseByteSize("invalid-size"), not on buffer validation
 	if err == nil {
 		t.Fatal("expected error")
@@ -759,6 +823,43 @@ func TestCobraSlice_Success(t *testing.T) {
 	}
 }
 
+func TestCobraSlice_Interactive(t *testing.T) {
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+	outDir := filepath.Join(t.TempDir(), "sliced")
+
+	cfg = config.Load()
+	restore := redirectOutput(t)
+	defer restore()
+	origTerminal := stdinIsTerminal
+	stdinIsTerminal = func() bool { return false }
+	defer func() { stdinIsTerminal = origTerminal }()
+
+	for _, args := range [][]string{
+		{"slice", dir, "--out", outDir, "-i", "--from", "10:00"},
+		{"slice", dir, "--out", outDir, "--interactive"},
+	} {
+		root := &cobra.Command{Use: "logtap"}
+		root.AddCommand(newSliceCmd())
+		root.SetArgs(args)
+		if err := root.Execute(); cli.ExitCode(err) != cli.ExitUsage {
+			t.Errorf("%v: err = %v, want usage error", args, err)
+		}
+	}
+
+	pick := archive.SlicePick{
+		From:   time.Date(2025, 1, 15, 10, 40, 0, 0, time.UTC),
+		To:     time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC),
+		Labels: []archive.LabelFilter{{Key: "app", Value: "api"}},
+	}
+	want := "logtap slice ./cap --from 2025-01-15T10:40:00Z --to 2025-01-15T10:45:00Z --label app=api --out ./out"
+	if got := pickCommand("./cap", "./out", pick); got != want {
+		t.Errorf("pickCommand = %q, want %q", got, want)
+	}
+	if got := pickCommand("./cap", "./out", archive.SlicePick{}); got != "logtap slice ./cap --out ./out" {
+		t.Errorf("pickCommand for the whole capture = %q", got)
+	}
+}
+
 func TestCobraExport_Success(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	outPath := filepath.Join(t.TempDir(), "export.jsonl")
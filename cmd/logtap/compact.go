@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+)
+
+func newCompactCmd() *cobra.Command {
+	var targetSizeStr string
+	var dryRun bool
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "compact <capture-dir>",
+		Short: "Merge small data files of a capture into larger ones",
+		Long: `Merge runs of adjacent small data files into files of up to --target-size
+and rebuild the index, so long sessions with frequent rotation scan faster.
+Line order is preserved. Run it on finished captures only, not while a
+receiver writes to the directory.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCompact(args[0], targetSizeStr, dryRun, jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringVar(&targetSizeStr, "target-size", "256MB", "grow merged files up to this size on disk")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show which files would be merged without changing the capture")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output the result as JSON")
+	addFormatAlias(cmd, &jsonOutput)
+
+	return cmd
+}
+
+func runCompact(dir, targetSizeStr string, dryRun, jsonOutput bool) error {
+	targetSize, err := parseByteSize(targetSizeStr)
+	if err != nil {
+		return fmt.Errorf("invalid --target-size: %w", err)
+	}
+	if targetSize <= 0 {
+		return fmt.Errorf("invalid --target-size: must be positive")
+	}
+
+	result, err := archive.Compact(dir, archive.CompactOptions{TargetSize: targetSize, DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return result.WriteJSON(os.Stdout)
+	}
+	result.WriteText(os.Stdout)
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/cli"
+	"github.com/ppiankov/logtap/internal/config"
+)
+
+type configLintResult struct {
+	Files    []string       `json:"files"`
+	Issues   []config.Issue `json:"issues"`
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect logtap configuration files",
+	}
+	cmd.AddCommand(newConfigLintCmd())
+	return cmd
+}
+
+func newConfigLintCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "lint [file...]",
+		Short: "Check config files for unknown keys, invalid values, and conflicts",
+		Long: `Check config files against the schema and report unknown keys (with
+suggestions for likely typos), invalid values, deprecated usage, and settings
+that have no effect. Without arguments, checks ~/.logtap/config.yaml and
+.logtap.yaml when present. Exits with code 6 when errors are found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigLint(args, jsonOutput)
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output as JSON")
+	addFormatAlias(cmd, &jsonOutput)
+	return cmd
+}
+
+func runConfigLint(files []string, jsonOutput bool) error {
+	if len(files) == 0 {
+		files = defaultConfigFiles()
+	}
+
+	result := configLintResult{Files: files, Issues: []config.Issue{}}
+	for _, f := range files {
+		issues, err := config.LintFile(f)
+		if err != nil {
+			return err
+		}
+		result.Issues = append(result.Issues, issues...)
+	}
+	for _, is := range result.Issues {
+		if is.Severity == config.SeverityError {
+			result.Errors++
+		} else {
+			result.Warnings++
+		}
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		switch {
+		case len(files) == 0:
+			fmt.Println("No config files found.")
+		case len(result.Issues) == 0:
+			for _, f := range files {
+				fmt.Printf("%s: ok\n", f)
+			}
+		default:
+			for _, is := range result.Issues {
+				fmt.Println(is)
+			}
+			fmt.Printf("\n%d error(s), %d warning(s)\n", result.Errors, result.Warnings)
+		}
+	}
+
+	if result.Errors > 0 {
+		return cli.NewFindingsError(fmt.Sprintf("config lint: %d error(s)", result.Errors))
+	}
+	return nil
+}
+
+// defaultConfigFiles returns the config files Load reads that exist.
+func defaultConfigFiles() []string {
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".logtap", "config.yaml"))
+	}
+	files = append(files, ".logtap.yaml")
+
+	existing := files[:0]
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			existing = append(existing, f)
+		}
+	}
+	return existing
+}
+
+// reportConfigIssues prints problems found while loading config to w, so a
+// typo does not silently fall back to defaults. config lint reports them
+// itself.
+func reportConfigIssues(w io.Writer, cmd *cobra.Command) {
+	if cfg == nil || (cmd.Name() == "lint" && cmd.Parent() != nil && cmd.Parent().Name() == "config") {
+		return
+	}
+	for _, is := range cfg.Issues {
+		_, _ = fmt.Fprintf(w, "logtap: config: %s\n", is)
+	}
+}
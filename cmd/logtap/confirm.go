@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// confirmInput is read for answers to confirmation prompts.
+var confirmInput io.Reader = os.Stdin
+
+// stdinIsTerminal reports whether confirmation prompts can be answered;
+// commands run from scripts are never prompted.
+var stdinIsTerminal = func() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmPrompt asks question on w and reports whether it was answered yes.
+func confirmPrompt(w io.Writer, question string) (bool, error) {
+	_, _ = fmt.Fprintf(w, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(confirmInput).ReadString('\n')
+	if err != nil && answer == "" {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, fmt.Errorf("read answer: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
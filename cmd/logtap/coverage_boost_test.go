@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ppiankov/logtap/internal/archive"
 	"github.com/ppiankov/logtap/internal/cli"
 	"github.com/ppiankov/logtap/internal/config"
 	"github.com/ppiankov/logtap/internal/recv"
@@ -244,7 +245,7 @@ func TestCobraReport_FlagDefaults(t *testing.T) {
 }
 
 func TestRunReport_InvalidDir(t *testing.T) {
-	err := runReport("/nonexistent/dir", "", false, false, 1, 5)
+	err := runReport("/nonexistent/dir", "", "", false, 1, 5, nil, nil, nil, "")
 	if err == nil {
 		t.Error("expected error for nonexistent dir")
 	}
@@ -256,7 +257,7 @@ func TestRunReport_Success_JSON(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runReport(dir, "", true, false, 1, 5); err != nil {
+	if err := runReport(dir, "", "json", false, 1, 5, nil, nil, nil, ""); err != nil {
 		t.Fatalf("runReport json: %v", err)
 	}
 }
@@ -267,7 +268,7 @@ func TestRunReport_MissingOut(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	err := runReport(dir, "", false, false, 1, 5)
+	err := runReport(dir, "", "", false, 1, 5, nil, nil, nil, "")
 	if err == nil {
 		t.Fatal("expected error when --out not set and --json not used")
 	}
@@ -283,7 +284,7 @@ func TestRunReport_WithOut(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runReport(dir, outDir, false, true, 1, 5); err != nil {
+	if err := runReport(dir, outDir, "", true, 1, 5, nil, nil, nil, ""); err != nil {
 		t.Fatalf("runReport with out: %v", err)
 	}
 	if _, err := os.Stat(filepath.Join(outDir, "report.json")); err != nil {
@@ -294,8 +295,54 @@ func TestRunReport_WithOut(t *testing.T) {
 	}
 }
 
+func TestRunReport_MarkdownSummary(t *testing.T) {
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+
+	restore := redirectOutput(t)
+	defer restore()
+
+	out := captureStdout(t, func() {
+		if err := runReport(dir, "", "markdown-summary", false, 1, 5, nil, nil, []string{"Dashboard=https://grafana/d/1"}, ""); err != nil {
+			t.Fatalf("runReport markdown-summary: %v", err)
+		}
+	})
+	for _, want := range []string{"**logtap report**", "**Lines:** 2", "**Errors:** 1", "[Dashboard](https://grafana/d/1)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	outDir := filepath.Join(t.TempDir(), "report-out")
+	out = captureStdout(t, func() {
+		if err := runReport(dir, outDir, "markdown-summary", true, 1, 5, nil, nil, nil, ""); err != nil {
+			t.Fatalf("runReport markdown-summary with out: %v", err)
+		}
+	})
+	if !strings.Contains(out, "[HTML report]("+filepath.Join(outDir, "report.html")+")") {
+		t.Errorf("summary should link the HTML report:\n%s", out)
+	}
+}
+
+func TestRunReport_InvalidLink(t *testing.T) {
+	err := runReport("/nonexistent/dir", "", "markdown-summary", false, 1, 5, nil, nil, []string{"no-url"}, "")
+	if cli.ExitCode(err) != cli.ExitUsage {
+		t.Errorf("err = %v, want usage error", err)
+	}
+}
+
+func TestCobraReport_InvalidFormat(t *testing.T) {
+	root := &cobra.Command{Use: "logtap"}
+	root.AddCommand(newReportCmd())
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	root.SetArgs([]string{"report", "--format", "slack", t.TempDir()})
+	if err := root.Execute(); cli.ExitCode(err) != cli.ExitUsage {
+		t.Errorf("err = %v, want usage error", err)
+	}
+}
+
 func TestRunBaselineDiff_InvalidDirs(t *testing.T) {
-	err := runBaselineDiff("/nonexistent/a", "/nonexistent/b", false, false, nil)
+	err := runBaselineDiff("/nonexistent/a", "/nonexistent/b", archive.BaselineDiffConfig{}, false, false, nil)
 	if err == nil {
 		t.Error("expected error for nonexistent dirs")
 	}
@@ -309,7 +356,7 @@ func TestRunBaselineDiff_Success(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runBaselineDiff(dirA, dirB, false, false, nil); err != nil {
+	if err := runBaselineDiff(dirA, dirB, archive.BaselineDiffConfig{}, false, false, nil); err != nil {
 		t.Fatalf("runBaselineDiff text: %v", err)
 	}
 }
@@ -322,7 +369,7 @@ func TestRunBaselineDiff_JSON(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runBaselineDiff(dirA, dirB, true, false, nil); err != nil {
+	if err := runBaselineDiff(dirA, dirB, archive.BaselineDiffConfig{}, true, false, nil); err != nil {
 		t.Fatalf("runBaselineDiff json: %v", err)
 	}
 }
@@ -443,7 +490,7 @@ func TestRunExport_JSONOutput(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runExport(dir, "jsonl", "", "", nil, "", outPath, true); err != nil {
+	if err := runExport(dir, "jsonl", "", "", nil, "", outPath, true, false, false, false, nil, false, lifecycleFilter{}); err != nil {
 		t.Fatalf("runExport json output: %v", err)
 	}
 }
@@ -457,7 +504,7 @@ func TestRunMerge_JSONOutput(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runMerge([]string{dirA, dirB}, outDir, true, false); err != nil {
+	if err := runMerge([]string{dirA, dirB}, outDir, true, false, archive.ConflictMix); err != nil {
 		t.Fatalf("runMerge json: %v", err)
 	}
 }
@@ -468,7 +515,7 @@ func TestRunTriage_MissingOut(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	err := runTriage(dir, "", 1, time.Minute, 5, 10000, false, false)
+	err := runTriage(dir, "", 1, time.Minute, 5, 10000, nil, archive.CorrelateConfig{}, nil, nil, nil, false, false, false, "")
 	if err == nil {
 		t.Fatal("expected error when --out not set and --json not used")
 	}
@@ -481,7 +528,7 @@ func TestCobraSlice_InvalidLabel(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	outDir := filepath.Join(t.TempDir(), "sliced")
 
-	err := runSlice(dir, "", "", []string{"badlabel"}, "", outDir)
+	err := runSlice(dir, "", "", []string{"badlabel"}, "", outDir, lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for invalid label")
 	}
@@ -491,7 +538,7 @@ func TestCobraSlice_InvalidGrep(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	outDir := filepath.Join(t.TempDir(), "sliced")
 
-	err := runSlice(dir, "", "", nil, "[invalid(", outDir)
+	err := runSlice(dir, "", "", nil, "[invalid(", outDir, lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for invalid grep regex")
 	}
@@ -554,7 +601,7 @@ func TestRunGrep_Context(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runGrep("error", dir, "", "", nil, false, false, "json", 1); err != nil {
+	if err := runGrep("error", dir, "", "", nil, false, false, "json", 1, false, false, lifecycleFilter{}); err != nil {
 		t.Fatalf("runGrep context: %v", err)
 	}
 }
@@ -564,7 +611,7 @@ func TestRunGrep_TextWithContext(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runGrep("error", dir, "", "", nil, false, false, "text", 1); err != nil {
+	if err := runGrep("error", dir, "", "", nil, false, false, "text", 1, false, false, lifecycleFilter{}); err != nil {
 		t.Fatalf("runGrep text with context: %v", err)
 	}
 }
@@ -786,7 +833,7 @@ func TestRunExport_WithFilters(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runExport(dir, "jsonl", "", "", []string{"app=web"}, "hello", outPath, false); err != nil {
+	if err := runExport(dir, "jsonl", "", "", []string{"app=web"}, "hello", outPath, false, false, false, false, nil, false, lifecycleFilter{}); err != nil {
 		t.Fatalf("runExport with filters: %v", err)
 	}
 }
@@ -798,7 +845,7 @@ func TestRunSlice_WithTimeFilter(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runSlice(dir, "2025-01-15T10:00:00Z", "2025-01-15T10:00:03Z", nil, "", outDir); err != nil {
+	if err := runSlice(dir, "2025-01-15T10:00:00Z", "2025-01-15T10:00:03Z", nil, "", outDir, lifecycleFilter{}); err != nil {
 		t.Fatalf("runSlice with time: %v", err)
 	}
 }
@@ -807,7 +854,7 @@ func TestRunSlice_InvalidFrom(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	outDir := filepath.Join(t.TempDir(), "slice-bad")
 
-	err := runSlice(dir, "not-a-time", "", nil, "", outDir)
+	err := runSlice(dir, "not-a-time", "", nil, "", outDir, lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for invalid --from")
 	}
@@ -817,7 +864,7 @@ func TestRunSlice_InvalidTo(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	outDir := filepath.Join(t.TempDir(), "slice-bad")
 
-	err := runSlice(dir, "", "not-a-time", nil, "", outDir)
+	err := runSlice(dir, "", "not-a-time", nil, "", outDir, lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for invalid --to")
 	}
@@ -832,7 +879,7 @@ func TestPrintTextLine_NoLabels(t *testing.T) {
 		Labels:    map[string]string{},
 		Message:   "test message",
 	}
-	printTextLine(e, 10)
+	printTextLine(e, 10, textTimeLayout(""))
 }
 
 func TestCobraCompletion_InvalidShell(t *testing.T) {
@@ -897,14 +944,14 @@ func TestBuildTestRoot_CatalogReport(t *testing.T) {
 }
 
 func TestRunGC_NegativeAge(t *testing.T) {
-	err := runGC("/tmp", "-1h", "", false, false)
+	err := runGC("/tmp", "-1h", "", false, false, gcSafety{})
 	if err == nil {
 		t.Error("expected error for negative --max-age")
 	}
 }
 
 func TestRunGC_NegativeTotal(t *testing.T) {
-	err := runGC("/tmp", "", "-100", false, false)
+	err := runGC("/tmp", "", "-100", false, false, gcSafety{})
 	if err == nil {
 		t.Error("expected error for negative --max-total")
 	}
@@ -931,7 +978,7 @@ func TestRunExport_InvalidGrep(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 	outPath := filepath.Join(t.TempDir(), "export.jsonl")
 
-	err := runExport(dir, "jsonl", "", "", nil, "[invalid(", outPath, false)
+	err := runExport(dir, "jsonl", "", "", nil, "[invalid(", outPath, false, false, false, false, nil, false, lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for invalid grep")
 	}
@@ -1041,7 +1088,7 @@ func TestRunSlice_WithGrep(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	if err := runSlice(dir, "", "", nil, "error", outDir); err != nil {
+	if err := runSlice(dir, "", "", nil, "error", outDir, lifecycleFilter{}); err != nil {
 		t.Fatalf("runSlice with grep: %v", err)
 	}
 }
@@ -1049,7 +1096,7 @@ func TestRunSlice_WithGrep(t *testing.T) {
 func TestRunGrep_InvalidPattern(t *testing.T) {
 	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
 
-	err := runGrep("[invalid(", dir, "", "", nil, false, false, "json", 0)
+	err := runGrep("[invalid(", dir, "", "", nil, false, false, "json", 0, false, false, lifecycleFilter{})
 	if err == nil {
 		t.Error("expected error for invalid regex pattern")
 	}
@@ -1148,6 +1195,88 @@ func TestInit(t *testing.T) {
 	}
 }
 
+func TestConfigLint(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(t.TempDir())
+
+	// no config files
+	out := captureStdout(t, func() {
+		if err := runConfigLint(nil, false); err != nil {
+			t.Fatalf("lint without files: %v", err)
+		}
+	})
+	if !strings.Contains(out, "No config files") {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	// the file written by init is clean
+	if err := runInit(false); err != nil {
+		t.Fatal(err)
+	}
+	out = captureStdout(t, func() {
+		if err := runConfigLint(nil, false); err != nil {
+			t.Fatalf("lint init config: %v", err)
+		}
+	})
+	if !strings.Contains(out, ": ok") {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	// typo is an error with location
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(bad, []byte("recv:\n  dsk_cap: 10GB\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var lintErr error
+	out = captureStdout(t, func() {
+		lintErr = runConfigLint([]string{bad}, false)
+	})
+	if cli.ExitCode(lintErr) != cli.ExitFindings {
+		t.Errorf("exit code = %d, want %d", cli.ExitCode(lintErr), cli.ExitFindings)
+	}
+	if !strings.Contains(out, bad+":2:3: error: recv.dsk_cap") {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	// JSON
+	out = captureStdout(t, func() {
+		_ = runConfigLint([]string{bad}, true)
+	})
+	var result configLintResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if result.Errors != 1 || len(result.Issues) != 1 || result.Issues[0].Line != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// unreadable file
+	if err := runConfigLint([]string{filepath.Join(home, "missing.yaml")}, false); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestReportConfigIssues(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &config.Config{Issues: []config.Issue{{File: "c.yaml", Line: 2, Severity: config.SeverityError, Message: "unknown key"}}}
+
+	var buf bytes.Buffer
+	reportConfigIssues(&buf, newVersionCmd())
+	if !strings.Contains(buf.String(), "logtap: config: c.yaml:2: error: unknown key") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+
+	// config lint reports issues itself
+	buf.Reset()
+	lint := newConfigCmd().Commands()[0]
+	reportConfigIssues(&buf, lint)
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for config lint, got %q", buf.String())
+	}
+}
+
 func TestFormatAlias(t *testing.T) {
 	root := execute
 	_ = root
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// debugSignals are the signals that make recv write a diagnostics dump.
+var debugSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,7 @@
+package main
+
+import "os"
+
+// debugSignals is empty on Windows, which has no SIGUSR1; use POST
+// /admin/debug instead.
+var debugSignals []os.Signal
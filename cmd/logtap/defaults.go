@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
+	"github.com/ppiankov/logtap/internal/k8s"
 )
 
 // clusterContext returns a context with the configured timeout for cluster operations.
@@ -26,6 +31,31 @@ func clusterContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), timeout)
 }
 
+// newK8sClient connects to the cluster using the identity overrides from the
+// global --as, --as-group, --token, and --sa-token-path flags.
+func newK8sClient(namespace string) (*k8s.Client, error) {
+	return k8s.NewClientWithAuth(namespace, k8sAuth)
+}
+
+// applyLanguagePacks selects the archive language packs from --language-pack,
+// or from config when the flag is not set. Unknown names in config are
+// skipped, as config lint already reports them.
+func applyLanguagePacks(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("language-pack") || cfg == nil {
+		if err := archive.SetLanguagePacks(langPacks); err != nil {
+			return cli.NewUsageError(err.Error())
+		}
+		return nil
+	}
+	var names []string
+	for _, name := range cfg.Defaults.LanguagePacks {
+		if archive.LanguagePackDescription(strings.ToLower(strings.TrimSpace(name))) != "" {
+			names = append(names, name)
+		}
+	}
+	return archive.SetLanguagePacks(names)
+}
+
 // applyConfigDefaults sets flag values from config when the flag
 // was not explicitly set on the command line. Flags > env > config > defaults.
 // The config package already handles env > config, so we just need to
@@ -45,14 +75,23 @@ func applyConfigDefaults(cmd *cobra.Command) {
 
 	// recv defaults
 	setDefault("listen", cfg.Recv.Addr)
+	setDefault("otlp-grpc-listen", cfg.Recv.OTLPGRPCAddr)
+	setDefault("syslog", cfg.Recv.SyslogAddr)
+	setDefault("forward", cfg.Recv.ForwardAddr)
 	setDefault("dir", cfg.Recv.Dir)
 	setDefault("max-disk", cfg.Recv.DiskCap)
 	setDefault("redact", cfg.Recv.Redact)
 	setDefault("redact-patterns", cfg.Recv.RedactPatterns)
 	setDefault("webhook-events", cfg.Recv.WebhookEvents)
+	setDefault("kafka-group", cfg.Recv.KafkaGroup)
+	setDefault("kafka-start", cfg.Recv.KafkaStart)
+	if cfg.Recv.TimestampFallback {
+		setDefault("timestamp-fallback", "true")
+	}
 
 	// tap defaults
 	setDefault("namespace", cfg.Tap.Namespace)
 	setDefault("cpu", cfg.Tap.CPU)
 	setDefault("memory", cfg.Tap.Memory)
+	setDefault("sanitize", cfg.Tap.Sanitize)
 }
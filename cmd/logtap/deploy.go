@@ -69,7 +69,7 @@ func runDeploy(opts deployOpts) error {
 	ctx, cancel := clusterContext()
 	defer cancel()
 
-	c, err := k8s.NewClient(opts.namespace)
+	c, err := newK8sClient(opts.namespace)
 	if err != nil {
 		return fmt.Errorf("connect to cluster: %w", err)
 	}
@@ -142,7 +142,7 @@ func runDeployCleanup(namespace string, dryRun bool) error {
 	ctx, cancel := clusterContext()
 	defer cancel()
 
-	c, err := k8s.NewClient(namespace)
+	c, err := newK8sClient(namespace)
 	if err != nil {
 		return fmt.Errorf("connect to cluster: %w", err)
 	}
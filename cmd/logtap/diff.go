@@ -16,6 +16,7 @@ func newDiffCmd() *cobra.Command {
 		baseline   bool
 		ci         bool
 		failOn     []string
+		requests   string
 	)
 
 	cmd := &cobra.Command{
@@ -26,11 +27,16 @@ func newDiffCmd() *cobra.Command {
 			"With --ci, exit code encodes the verdict: 0=pass, 6=fail. Use --fail-on to control which verdicts fail.",
 		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			requestPattern, err := compileRequestPattern(requests)
+			if err != nil {
+				return err
+			}
+			cfg := archive.BaselineDiffConfig{RequestPattern: requestPattern}
 			if ci {
-				return runBaselineDiff(args[0], args[1], jsonOutput, true, failOn)
+				return runBaselineDiff(args[0], args[1], cfg, jsonOutput, true, failOn)
 			}
 			if baseline {
-				return runBaselineDiff(args[0], args[1], jsonOutput, false, nil)
+				return runBaselineDiff(args[0], args[1], cfg, jsonOutput, false, nil)
 			}
 			return runDiff(args[0], args[1], jsonOutput)
 		},
@@ -41,6 +47,7 @@ func newDiffCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&baseline, "baseline", false, "treat first capture as baseline and produce a verdict")
 	cmd.Flags().BoolVar(&ci, "ci", false, "CI mode: exit code encodes verdict (0=pass, 6=fail)")
 	cmd.Flags().StringSliceVar(&failOn, "fail-on", []string{"regression"}, "verdicts that cause exit 6 in --ci mode")
+	cmd.Flags().StringVar(&requests, "request-pattern", "", requestPatternFlagUsage+" (with --baseline or --ci)")
 
 	return cmd
 }
@@ -59,8 +66,8 @@ func runDiff(dirA, dirB string, jsonOutput bool) error {
 	return nil
 }
 
-func runBaselineDiff(baselineDir, currentDir string, jsonOutput, ci bool, failOn []string) error {
-	result, err := archive.BaselineDiff(baselineDir, currentDir)
+func runBaselineDiff(baselineDir, currentDir string, cfg archive.BaselineDiffConfig, jsonOutput, ci bool, failOn []string) error {
+	result, err := archive.BaselineDiffWithConfig(baselineDir, currentDir, cfg)
 	if err != nil {
 		return err
 	}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ppiankov/logtap/internal/archive"
 	"github.com/ppiankov/logtap/internal/cli"
 	"github.com/ppiankov/logtap/internal/recv"
 )
@@ -62,7 +63,7 @@ func TestRunBaselineDiff_CI_Regression(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	err := runBaselineDiff(baselineDir, currentDir, false, true, []string{"regression"})
+	err := runBaselineDiff(baselineDir, currentDir, archive.BaselineDiffConfig{}, false, true, []string{"regression"})
 	if err == nil {
 		t.Fatal("expected FindingsError for regression verdict")
 	}
@@ -81,7 +82,7 @@ func TestRunBaselineDiff_CI_Stable(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	err := runBaselineDiff(baselineDir, currentDir, false, true, []string{"regression"})
+	err := runBaselineDiff(baselineDir, currentDir, archive.BaselineDiffConfig{}, false, true, []string{"regression"})
 	if err != nil {
 		t.Fatalf("expected nil for stable verdict, got: %v", err)
 	}
@@ -93,7 +94,7 @@ func TestRunBaselineDiff_CI_FailOnDifferent(t *testing.T) {
 	defer restore()
 
 	// fail-on includes "regression" — should still fail
-	err := runBaselineDiff(baselineDir, currentDir, false, true, []string{"regression", "different"})
+	err := runBaselineDiff(baselineDir, currentDir, archive.BaselineDiffConfig{}, false, true, []string{"regression", "different"})
 	if err == nil {
 		t.Fatal("expected FindingsError")
 	}
@@ -111,7 +112,7 @@ func TestRunBaselineDiff_CI_JSON(t *testing.T) {
 	baselineDir, currentDir := makeRegressionCaptures(t)
 
 	out := captureStdout(t, func() {
-		_ = runBaselineDiff(baselineDir, currentDir, true, true, []string{"regression"})
+		_ = runBaselineDiff(baselineDir, currentDir, archive.BaselineDiffConfig{}, true, true, []string{"regression"})
 	})
 
 	// JSON should still be written even when CI fails
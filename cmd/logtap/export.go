@@ -1,50 +1,161 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
+	"github.com/ppiankov/logtap/internal/forward"
+	"github.com/ppiankov/logtap/internal/recv"
 )
 
 func newExportCmd() *cobra.Command {
 	var (
 		formatStr  string
+		batch      int
+		index      string
 		fromStr    string
 		toStr      string
 		labels     []string
 		grepStr    string
 		outPath    string
 		jsonOutput bool
+		resume     bool
+		expand     bool
+		profile    bool
+		columns    []string
+		excelSafe  bool
+		loki       lokiExport
+		elastic    elasticExport
+		splunk     splunkExport
+		lifecycle  lifecycleFilter
 	)
 
 	cmd := &cobra.Command{
 		Use:   "export <capture-dir>",
-		Short: "Export capture data to parquet, CSV, or JSONL",
-		Long:  "Convert capture data to external formats for ingestion into analytics systems (DuckDB, pandas, BigQuery, etc.).",
-		Args:  cobra.ExactArgs(1),
+		Short: "Export capture data to parquet, CSV, JSONL, Loki, Elasticsearch, or Splunk",
+		Long: `Convert capture data to external formats for ingestion into analytics systems (DuckDB, pandas, BigQuery, etc.).
+
+With --to-loki, --to-elastic or --to-splunk the capture is replayed into a Loki
+instance, an Elasticsearch/OpenSearch cluster or a Splunk HTTP Event Collector with
+its original timestamps instead, so it can be explored in existing Grafana, Kibana
+or Splunk dashboards.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runExport(args[0], formatStr, fromStr, toStr, labels, grepStr, outPath, jsonOutput)
+			flags := cmd.Flags()
+			targets := 0
+			for _, u := range []string{loki.url, elastic.url, splunk.url} {
+				if u != "" {
+					targets++
+				}
+			}
+			if targets > 0 {
+				if targets > 1 {
+					return cli.NewUsageError("--to-loki, --to-elastic and --to-splunk are mutually exclusive")
+				}
+				if formatStr != "" || outPath != "" || len(columns) > 0 || excelSafe || expand {
+					return cli.NewUsageError("--to-loki, --to-elastic and --to-splunk cannot be combined with --format, --out, --columns, --excel-safe or --expand-repeats")
+				}
+				if batch < 0 {
+					return cli.NewUsageError("--batch-size must be >= 0")
+				}
+			}
+			changed := func(names ...string) bool {
+				for _, name := range names {
+					if flags.Changed(name) {
+						return true
+					}
+				}
+				return false
+			}
+			switch {
+			case loki.url != "":
+				if changed("index", "template", "api-key") {
+					return cli.NewUsageError("--index, --template and --api-key require --to-elastic")
+				}
+				if changed("hec-token", "sourcetype", "hec-map", "ack") {
+					return cli.NewUsageError("--hec-token, --sourcetype, --hec-map and --ack require --to-splunk")
+				}
+				loki.batch = batch
+				return runExportLoki(args[0], loki, fromStr, toStr, labels, grepStr, jsonOutput, resume, profile, lifecycle)
+			case elastic.url != "":
+				if changed("tenant", "rate") {
+					return cli.NewUsageError("--tenant and --rate require --to-loki")
+				}
+				if changed("hec-token", "sourcetype", "hec-map", "ack") {
+					return cli.NewUsageError("--hec-token, --sourcetype, --hec-map and --ack require --to-splunk")
+				}
+				elastic.index = index
+				if elastic.index == "" {
+					elastic.index = defaultElasticIndex
+				}
+				elastic.batch = batch
+				return runExportElastic(args[0], elastic, fromStr, toStr, labels, grepStr, jsonOutput, resume, profile, lifecycle)
+			case splunk.url != "":
+				if changed("tenant", "rate") {
+					return cli.NewUsageError("--tenant and --rate require --to-loki")
+				}
+				if changed("template", "api-key") {
+					return cli.NewUsageError("--template and --api-key require --to-elastic")
+				}
+				splunk.index = index
+				splunk.batch = batch
+				return runExportSplunk(args[0], splunk, fromStr, toStr, labels, grepStr, jsonOutput, resume, profile, lifecycle)
+			}
+			for _, name := range []string{"tenant", "rate", "index", "template", "api-key", "hec-token", "sourcetype", "hec-map", "ack", "batch-size"} {
+				if flags.Changed(name) {
+					return cli.NewUsageError(fmt.Sprintf("--%s requires --to-loki, --to-elastic or --to-splunk", name))
+				}
+			}
+			if formatStr == "" || outPath == "" {
+				return cli.NewUsageError("--format and --out are required (or --to-loki, --to-elastic, --to-splunk)")
+			}
+			return runExport(args[0], formatStr, fromStr, toStr, labels, grepStr, outPath, jsonOutput, resume, expand, profile, columns, excelSafe, lifecycle)
 		},
 	}
 
-	cmd.Flags().StringVar(&formatStr, "format", "", "output format: parquet, csv, jsonl (required)")
+	cmd.Flags().StringVar(&formatStr, "format", "", "output format: parquet, csv, jsonl (required unless --to-loki, --to-elastic or --to-splunk)")
 	cmd.Flags().StringVar(&fromStr, "from", "", "start time filter (RFC3339, HH:MM, or -30m)")
 	cmd.Flags().StringVar(&toStr, "to", "", "end time filter (RFC3339, HH:MM, or -30m)")
 	cmd.Flags().StringSliceVar(&labels, "label", nil, "label filter (key=value, repeatable)")
 	cmd.Flags().StringVar(&grepStr, "grep", "", "regex filter on log message")
-	cmd.Flags().StringVar(&outPath, "out", "", "output file path (required)")
+	cmd.Flags().StringVar(&outPath, "out", "", "output file path (required unless --to-loki, --to-elastic or --to-splunk)")
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output summary as JSON")
-	_ = cmd.MarkFlagRequired("format")
-	_ = cmd.MarkFlagRequired("out")
+	cmd.Flags().BoolVar(&resume, "resume", false, "continue an interrupted export from its checkpoint (csv, jsonl, --to-loki, --to-elastic and --to-splunk)")
+	cmd.Flags().BoolVar(&expand, "expand-repeats", false, "write lines collapsed by recv --dedup-window once per repeat instead of with a repeat_count (always on for csv and parquet)")
+	cmd.Flags().BoolVar(&profile, "profile", false, profileFlagUsage)
+	cmd.Flags().StringSliceVar(&columns, "columns", nil, "csv columns in order: ts, msg, labels (all as key=val;key=val) and label keys (label:KEY for a key named like a column); default ts, one column per label key, msg")
+	cmd.Flags().BoolVar(&excelSafe, "excel-safe", false, "csv for spreadsheets: quote cells starting with = + - @ so they are not run as formulas, and write a UTF-8 byte order mark")
+	cmd.Flags().StringVar(&loki.url, "to-loki", "", "replay into Loki at this URL (e.g. http://loki:3100) with original timestamps instead of writing a file")
+	cmd.Flags().StringVar(&loki.tenant, "tenant", "", "Loki tenant sent as X-Scope-OrgID (with --to-loki)")
+	cmd.Flags().Float64Var(&loki.rate, "rate", 0, "max lines per second pushed to Loki (0 = unlimited)")
+	cmd.Flags().StringVar(&elastic.url, "to-elastic", "", "index into Elasticsearch or OpenSearch at this URL (e.g. https://es:9200) instead of writing a file")
+	cmd.Flags().StringVar(&index, "index", "", "index name: with --to-elastic, where %{+yyyy.MM.dd} is replaced by each line's UTC date (default \""+defaultElasticIndex+"\"); with --to-splunk, the event index (default: the token's)")
+	cmd.Flags().StringVar(&elastic.template, "template", "logtap", "index template installed before indexing with --to-elastic (empty to skip)")
+	cmd.Flags().StringVar(&elastic.apiKey, "api-key", "", "Elasticsearch API key (base64 id:key); basic auth goes in the URL")
+	cmd.Flags().StringVar(&splunk.url, "to-splunk", "", "send to a Splunk HTTP Event Collector at this URL (e.g. https://hec:8088) instead of writing a file")
+	cmd.Flags().StringVar(&splunk.token, "hec-token", "", "HEC token (required with --to-splunk)")
+	cmd.Flags().StringVar(&splunk.sourcetype, "sourcetype", "logtap", "Splunk sourcetype of the events, unless --hec-map maps it to a label")
+	cmd.Flags().StringSliceVar(&splunk.mapping, "hec-map", nil, "set HEC event metadata from labels: host, source, sourcetype or index = label key (e.g. host=pod,source=container)")
+	cmd.Flags().BoolVar(&splunk.ack, "ack", false, "wait for Splunk indexer acknowledgement of each batch before counting it as exported (must be enabled for the token)")
+	cmd.Flags().IntVar(&batch, "batch-size", 0, "max lines per push, bulk or HEC request (default 1000)")
+	lifecycle.addFlags(cmd)
+	addPresetFlag(cmd)
 
 	return cmd
 }
 
-func runExport(src, formatStr, fromStr, toStr string, labels []string, grepStr, outPath string, jsonOutput bool) error {
+func runExport(src, formatStr, fromStr, toStr string, labels []string, grepStr, outPath string, jsonOutput, resume, expand, profileMode bool, columns []string, excelSafe bool, lifecycle lifecycleFilter) error {
 	format, err := parseExportFormat(formatStr)
 	if err != nil {
 		return err
@@ -54,27 +165,18 @@ func runExport(src, formatStr, fromStr, toStr string, labels []string, grepStr,
 	if err != nil {
 		return fmt.Errorf("open capture: %w", err)
 	}
-	meta := reader.Metadata()
-
-	filter, err := buildFilter(fromStr, toStr, labels, grepStr, meta)
+	filter, err := exportFilter(reader, fromStr, toStr, labels, grepStr, lifecycle)
 	if err != nil {
 		return err
 	}
 
-	progress := func(p archive.ExportProgress) {
-		if p.Total > 0 {
-			pct := float64(p.Written) / float64(p.Total) * 100
-			fmt.Fprintf(os.Stderr, "\rExporting: %s / %s lines (%.1f%%)",
-				archive.FormatCount(p.Written), archive.FormatCount(p.Total), pct)
-		} else {
-			fmt.Fprintf(os.Stderr, "\rExporting: %s lines", archive.FormatCount(p.Written))
-		}
-	}
-
-	if err := archive.Export(src, outPath, format, filter, progress); err != nil {
+	profile := newProfile(profileMode)
+	opts := archive.ExportOptions{Resume: resume, ExpandRepeats: expand, Profile: profile, Columns: columns, ExcelSafe: excelSafe}
+	if err := archive.ExportWithOptions(src, outPath, format, filter, exportProgress, opts); err != nil {
 		fmt.Fprintln(os.Stderr)
 		return err
 	}
+	defer printProfile(os.Stderr, profile)
 
 	info, err := os.Stat(outPath)
 	if err != nil {
@@ -97,6 +199,308 @@ func runExport(src, formatStr, fromStr, toStr string, labels []string, grepStr,
 	return nil
 }
 
+// lokiExport holds the --to-loki flags of export.
+type lokiExport struct {
+	url    string
+	tenant string
+	rate   float64
+	batch  int
+}
+
+// lokiCheckpointPath is where a Loki replay of src records its progress:
+// next to the capture, as file exports keep theirs next to the output.
+func lokiCheckpointPath(src string) string {
+	return filepath.Clean(src) + ".loki.checkpoint"
+}
+
+func runExportLoki(src string, loki lokiExport, fromStr, toStr string, labels []string, grepStr string, jsonOutput, resume, profileMode bool, lifecycle lifecycleFilter) error {
+	if loki.rate < 0 {
+		return cli.NewUsageError("--rate must be >= 0")
+	}
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("open capture: %w", err)
+	}
+	filter, err := exportFilter(reader, fromStr, toStr, labels, grepStr, lifecycle)
+	if err != nil {
+		return err
+	}
+
+	pusher := forward.NewPusher(loki.url)
+	pusher.SetTenant(loki.tenant)
+	push := func(ctx context.Context, labels map[string]string, entries []recv.LogEntry) error {
+		lines := make([]forward.TimestampedLine, len(entries))
+		for i, e := range entries {
+			lines[i] = forward.TimestampedLine{Timestamp: e.Timestamp, Line: e.Message}
+		}
+		return pusher.Push(ctx, labels, lines)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	profile := newProfile(profileMode)
+	opts := archive.LokiExportOptions{
+		Target:     redactedURL(loki.url),
+		Tenant:     loki.tenant,
+		Checkpoint: lokiCheckpointPath(src),
+		Resume:     resume,
+		BatchLines: loki.batch,
+		Rate:       loki.rate,
+		Profile:    profile,
+	}
+	pushed, err := archive.ExportLoki(ctx, src, filter, push, exportProgress, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		if _, serr := os.Stat(opts.Checkpoint); serr == nil && !resume {
+			return fmt.Errorf("%w (rerun with --resume to continue)", err)
+		}
+		return err
+	}
+	defer printProfile(os.Stderr, profile)
+
+	if jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"source": src,
+			"target": redactedURL(loki.url),
+			"tenant": loki.tenant,
+			"lines":  pushed,
+		})
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "\rExported: %s lines -> %s\n", archive.FormatCount(pushed), redactedURL(loki.url))
+	return nil
+}
+
+// redactedURL hides the password of a target URL for output and
+// checkpoints.
+func redactedURL(s string) string {
+	if u, err := url.Parse(s); err == nil {
+		return u.Redacted()
+	}
+	return s
+}
+
+// defaultElasticIndex is a daily index, the Logstash convention.
+const defaultElasticIndex = "logtap-%{+yyyy.MM.dd}"
+
+// elasticExport holds the --to-elastic flags of export.
+type elasticExport struct {
+	url      string
+	index    string
+	template string
+	apiKey   string
+	batch    int
+}
+
+// elasticCheckpointPath is where an Elasticsearch export of src records
+// its progress.
+func elasticCheckpointPath(src string) string {
+	return filepath.Clean(src) + ".elastic.checkpoint"
+}
+
+func runExportElastic(src string, elastic elasticExport, fromStr, toStr string, labels []string, grepStr string, jsonOutput, resume, profileMode bool, lifecycle lifecycleFilter) error {
+	pattern, err := forward.ParseIndexPattern(elastic.index)
+	if err != nil {
+		return cli.NewUsageError(fmt.Sprintf("invalid --index: %v", err))
+	}
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("open capture: %w", err)
+	}
+	filter, err := exportFilter(reader, fromStr, toStr, labels, grepStr, lifecycle)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	client := forward.NewElasticClient(elastic.url)
+	client.SetAPIKey(elastic.apiKey)
+	if elastic.template != "" {
+		if err := client.PutIndexTemplate(ctx, elastic.template, pattern); err != nil {
+			return fmt.Errorf("%w (use --template \"\" to keep the cluster's mappings)", err)
+		}
+	}
+
+	var result forward.BulkResult
+	bulk := func(ctx context.Context, entries []archive.ElasticEntry) error {
+		docs := make([]forward.ElasticDoc, len(entries))
+		for i, e := range entries {
+			source, err := forward.ElasticSource(e.Timestamp, e.Labels, e.Message)
+			if err != nil {
+				return err
+			}
+			docs[i] = forward.ElasticDoc{Index: pattern.Name(e.Timestamp), ID: e.ID, Source: source}
+		}
+		res, err := client.Bulk(ctx, docs)
+		result.Indexed += res.Indexed
+		result.Rejected += res.Rejected
+		if result.FirstError == "" {
+			result.FirstError = res.FirstError
+		}
+		return err
+	}
+
+	profile := newProfile(profileMode)
+	opts := archive.ElasticExportOptions{
+		Target:     redactedURL(elastic.url),
+		Index:      elastic.index,
+		Checkpoint: elasticCheckpointPath(src),
+		Resume:     resume,
+		BatchLines: elastic.batch,
+		Profile:    profile,
+	}
+	if _, err := archive.ExportElastic(ctx, src, filter, bulk, exportProgress, opts); err != nil {
+		fmt.Fprintln(os.Stderr)
+		if _, serr := os.Stat(opts.Checkpoint); serr == nil && !resume {
+			return fmt.Errorf("%w (rerun with --resume to continue)", err)
+		}
+		return err
+	}
+	defer printProfile(os.Stderr, profile)
+
+	if jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"source":   src,
+			"target":   redactedURL(elastic.url),
+			"index":    elastic.index,
+			"lines":    result.Indexed,
+			"rejected": result.Rejected,
+		}); err != nil {
+			return err
+		}
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "\rExported: %s lines -> %s/%s\n",
+			archive.FormatCount(int64(result.Indexed)), strings.TrimRight(redactedURL(elastic.url), "/"), pattern.Wildcard())
+	}
+	if result.Rejected > 0 {
+		return fmt.Errorf("%d documents rejected by the cluster, first: %s", result.Rejected, result.FirstError)
+	}
+	return nil
+}
+
+// splunkExport holds the --to-splunk flags of export.
+type splunkExport struct {
+	url        string
+	token      string
+	index      string
+	sourcetype string
+	mapping    []string
+	ack        bool
+	batch      int
+}
+
+// splunkCheckpointPath is where a Splunk export of src records its
+// progress.
+func splunkCheckpointPath(src string) string {
+	return filepath.Clean(src) + ".splunk.checkpoint"
+}
+
+func runExportSplunk(src string, splunk splunkExport, fromStr, toStr string, labels []string, grepStr string, jsonOutput, resume, profileMode bool, lifecycle lifecycleFilter) error {
+	if splunk.token == "" {
+		return cli.NewUsageError("--to-splunk requires --hec-token")
+	}
+	mapped, err := forward.ParseSplunkMapping(splunk.mapping)
+	if err != nil {
+		return cli.NewUsageError(fmt.Sprintf("invalid --hec-map: %v", err))
+	}
+	mapping := forward.SplunkMapping{Sourcetype: splunk.sourcetype, Index: splunk.index, Labels: mapped}
+
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("open capture: %w", err)
+	}
+	filter, err := exportFilter(reader, fromStr, toStr, labels, grepStr, lifecycle)
+	if err != nil {
+		return err
+	}
+
+	client := forward.NewSplunkClient(splunk.url, splunk.token)
+	if splunk.ack {
+		if err := client.EnableAck(0); err != nil {
+			return err
+		}
+	}
+	send := func(ctx context.Context, entries []recv.LogEntry) error {
+		events := make([]forward.SplunkEvent, len(entries))
+		for i, e := range entries {
+			events[i] = mapping.Event(e.Timestamp, e.Labels, e.Message)
+		}
+		return client.Send(ctx, events)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	profile := newProfile(profileMode)
+	opts := archive.SplunkExportOptions{
+		Target:     redactedURL(splunk.url),
+		Index:      splunk.index,
+		Checkpoint: splunkCheckpointPath(src),
+		Resume:     resume,
+		BatchLines: splunk.batch,
+		Profile:    profile,
+	}
+	sent, err := archive.ExportSplunk(ctx, src, filter, send, exportProgress, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		if _, serr := os.Stat(opts.Checkpoint); serr == nil && !resume {
+			return fmt.Errorf("%w (rerun with --resume to continue)", err)
+		}
+		return err
+	}
+	defer printProfile(os.Stderr, profile)
+
+	if jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"source":       src,
+			"target":       redactedURL(splunk.url),
+			"index":        splunk.index,
+			"acknowledged": splunk.ack,
+			"lines":        sent,
+		})
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "\rExported: %s lines -> %s\n", archive.FormatCount(sent), redactedURL(splunk.url))
+	return nil
+}
+
+// exportFilter builds the entry filter of export from its flags.
+func exportFilter(reader *archive.Reader, fromStr, toStr string, labels []string, grepStr string, lifecycle lifecycleFilter) (*archive.Filter, error) {
+	filter, err := buildFilter(fromStr, toStr, lifecycle.labels(labels), grepStr, reader.Metadata())
+	if err != nil {
+		return nil, err
+	}
+	restarts, err := lifecycle.restarts(reader)
+	if err != nil {
+		return nil, err
+	}
+	phases, err := lifecycle.phases(reader)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := lifecycle.fieldMatchers()
+	if err != nil {
+		return nil, err
+	}
+	if restarts != nil || phases != nil || fields != nil {
+		if filter == nil {
+			filter = &archive.Filter{}
+		}
+		filter.Restarts = restarts
+		filter.Phases = phases
+		filter.Fields = fields
+	}
+	return filter, nil
+}
+
+func exportProgress(p archive.ExportProgress) {
+	if p.Total > 0 {
+		pct := float64(p.Written) / float64(p.Total) * 100
+		fmt.Fprintf(os.Stderr, "\rExporting: %s / %s lines (%.1f%%)",
+			archive.FormatCount(p.Written), archive.FormatCount(p.Total), pct)
+	} else {
+		fmt.Fprintf(os.Stderr, "\rExporting: %s lines", archive.FormatCount(p.Written))
+	}
+}
+
 func parseExportFormat(s string) (archive.ExportFormat, error) {
 	switch s {
 	case "parquet":
@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"regexp"
+	"time"
+
+	"github.com/spf13/cobra"
 
 	"github.com/ppiankov/logtap/internal/archive"
 	"github.com/ppiankov/logtap/internal/recv"
@@ -57,3 +60,84 @@ func buildFilter(fromStr, toStr string, labels []string, grepStr string, meta *r
 
 	return f, nil
 }
+
+// lifecycleFilter holds the --session, --pod, --restarts-only, --phase and
+// --field flags shared by grep, slice and export.
+type lifecycleFilter struct {
+	session       string
+	pod           string
+	restartsOnly  bool
+	restartWindow time.Duration
+	phase         string   // test phase started by an annotation
+	fields        []string // key=value matchers on JSON message fields
+}
+
+func (lf *lifecycleFilter) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&lf.session, "session", "", "only entries of this tap session (shorthand for --label session=ID)")
+	cmd.Flags().StringVar(&lf.pod, "pod", "", "only entries of this pod (shorthand for --label pod=NAME)")
+	cmd.Flags().BoolVar(&lf.restartsOnly, "restarts-only", false, "only entries around container restarts recorded by the forwarder")
+	cmd.Flags().DurationVar(&lf.restartWindow, "restart-window", archive.DefaultRestartWindow, "how far either side of a restart --restarts-only reaches")
+	cmd.Flags().StringVar(&lf.phase, "phase", "", "only entries of this test phase, from its phase=NAME annotation to the next phase annotation")
+	cmd.Flags().StringArrayVar(&lf.fields, "field", nil, "only JSON messages with this field value, e.g. trace_id=abc (repeatable, AND); files recorded with recv --index-fields are skipped by their field index")
+}
+
+// fieldMatchers parses the --field flags.
+func (lf lifecycleFilter) fieldMatchers() ([]archive.FieldMatcher, error) {
+	var matchers []archive.FieldMatcher
+	for _, s := range lf.fields {
+		fm, err := archive.ParseFieldFlag(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --field: %w", err)
+		}
+		matchers = append(matchers, fm)
+	}
+	return matchers, nil
+}
+
+// labels returns labels with the --session and --pod filters appended.
+func (lf lifecycleFilter) labels(labels []string) []string {
+	if lf.session != "" {
+		labels = append(labels, "session="+lf.session)
+	}
+	if lf.pod != "" {
+		labels = append(labels, "pod="+lf.pod)
+	}
+	return labels
+}
+
+// restarts returns the restart filter for --restarts-only, or nil when the
+// flag is unset. It fails if the capture holds no restart markers.
+func (lf lifecycleFilter) restarts(r *archive.Reader) (*archive.RestartFilter, error) {
+	if !lf.restartsOnly {
+		return nil, nil
+	}
+	restarts, err := archive.FindRestarts(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(restarts) == 0 {
+		return nil, fmt.Errorf("--restarts-only: no container restarts recorded in capture")
+	}
+	window := lf.restartWindow
+	if window <= 0 {
+		window = archive.DefaultRestartWindow
+	}
+	return &archive.RestartFilter{Restarts: restarts, Window: window}, nil
+}
+
+// phases returns the phase filter for --phase, or nil when the flag is
+// unset. It fails if the capture holds no annotation for the phase.
+func (lf lifecycleFilter) phases(r *archive.Reader) (*archive.PhaseFilter, error) {
+	if lf.phase == "" {
+		return nil, nil
+	}
+	phases, err := archive.FindPhases(r)
+	if err != nil {
+		return nil, err
+	}
+	pf, err := archive.NewPhaseFilter(phases, lf.phase)
+	if err != nil {
+		return nil, fmt.Errorf("--phase: %w", err)
+	}
+	return pf, nil
+}
@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/logtypes"
 	"github.com/ppiankov/logtap/internal/recv"
 )
 
@@ -274,3 +276,51 @@ func TestBuildFilter_ZeroStopped(t *testing.T) {
 		t.Errorf("expected from %v, got %v", expected, f.From)
 	}
 }
+
+func TestLifecycleFilter_Labels(t *testing.T) {
+	lf := lifecycleFilter{session: "lt-a3f9", pod: "api-0"}
+	got := lf.labels([]string{"app=web"})
+	want := []string{"app=web", "session=lt-a3f9", "pod=api-0"}
+	if len(got) != len(want) {
+		t.Fatalf("labels = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("labels[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLifecycleFilter_Restarts(t *testing.T) {
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	entries := sampleEntries(base)
+	noRestarts := makeCaptureDir(t, entries)
+
+	r, err := archive.NewReader(noRestarts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rf, err := (lifecycleFilter{}).restarts(r); rf != nil || err != nil {
+		t.Errorf("restarts without the flag = %v, %v", rf, err)
+	}
+	if _, err := (lifecycleFilter{restartsOnly: true}).restarts(r); err == nil {
+		t.Error("expected an error for a capture without restarts")
+	}
+
+	entries = append(entries, recv.LogEntry{
+		Timestamp: base.Add(time.Second),
+		Labels:    map[string]string{"app": "web", "pod": "web-0"},
+		Message:   logtypes.RestartMarker("web", 1),
+	})
+	r, err = archive.NewReader(makeCaptureDir(t, entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf, err := (lifecycleFilter{restartsOnly: true}).restarts(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rf.Restarts) != 1 || rf.Window != archive.DefaultRestartWindow {
+		t.Errorf("restart filter = %+v", rf)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
@@ -17,27 +18,51 @@ func newGCCmd() *cobra.Command {
 	var maxTotalStr string
 	var dryRun bool
 	var jsonOutput bool
+	var safety gcSafety
+	var restore string
 
 	cmd := &cobra.Command{
 		Use:   "gc <captures-dir>",
 		Short: "Delete old capture directories",
-		Long:  "Delete capture subdirectories based on age or total disk usage.",
-		Args:  cobra.ExactArgs(1),
+		Long: `Delete capture subdirectories based on age or total disk usage.
+
+Captures that look live are skipped unless --force is given: a running
+receiver's lock file, or files modified within --active-window. With
+--trash-dir, captures are moved there instead of deleted and removed for
+good by a later gc once --trash-ttl has passed; --restore moves one back.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runGC(args[0], maxAgeStr, maxTotalStr, dryRun, jsonOutput)
+			if restore != "" {
+				return runGCRestore(args[0], safety.trashDir, restore, jsonOutput)
+			}
+			return runGC(args[0], maxAgeStr, maxTotalStr, dryRun, jsonOutput, safety)
 		},
 	}
 
 	cmd.Flags().StringVar(&maxAgeStr, "max-age", "", "delete captures older than this (e.g. 7d, 24h)")
 	cmd.Flags().StringVar(&maxTotalStr, "max-total", "", "delete oldest captures until total size under limit (e.g. 100GB)")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would be deleted without removing")
+	cmd.Flags().BoolVar(&safety.force, "force", false, "also delete captures that look live (lock file or recent writes)")
+	cmd.Flags().StringVar(&safety.activeWindow, "active-window", "", "treat captures modified within this as live (default 15m)")
+	cmd.Flags().StringVar(&safety.trashDir, "trash-dir", "", "move captures here instead of deleting them (same filesystem)")
+	cmd.Flags().StringVar(&safety.trashTTL, "trash-ttl", "", "remove trashed captures older than this for good (default 24h)")
+	cmd.Flags().StringVar(&restore, "restore", "", "move the named capture back from --trash-dir")
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output deletion list as JSON")
 	addFormatAlias(cmd, &jsonOutput)
 
 	return cmd
 }
 
-func runGC(dir, maxAgeStr, maxTotalStr string, dryRun, jsonOutput bool) error {
+// gcSafety holds the gc flags guarding against deleting live captures or
+// deleting irreversibly.
+type gcSafety struct {
+	force        bool
+	activeWindow string
+	trashDir     string
+	trashTTL     string
+}
+
+func runGC(dir, maxAgeStr, maxTotalStr string, dryRun, jsonOutput bool, safety gcSafety) error {
 	if maxAgeStr == "" && maxTotalStr == "" {
 		return fmt.Errorf("--max-age or --max-total is required")
 	}
@@ -66,11 +91,42 @@ func runGC(dir, maxAgeStr, maxTotalStr string, dryRun, jsonOutput bool) error {
 		}
 	}
 
+	var window time.Duration
+	if safety.activeWindow != "" {
+		var err error
+		window, err = parseGCAge(safety.activeWindow)
+		if err != nil {
+			return fmt.Errorf("invalid --active-window: %w", err)
+		}
+		if window <= 0 {
+			return fmt.Errorf("invalid --active-window: must be positive")
+		}
+	}
+
+	var trashTTL time.Duration
+	if safety.trashTTL != "" {
+		if safety.trashDir == "" {
+			return fmt.Errorf("--trash-ttl requires --trash-dir")
+		}
+		var err error
+		trashTTL, err = parseGCAge(safety.trashTTL)
+		if err != nil {
+			return fmt.Errorf("invalid --trash-ttl: %w", err)
+		}
+		if trashTTL <= 0 {
+			return fmt.Errorf("invalid --trash-ttl: must be positive")
+		}
+	}
+
 	result, err := archive.GC(dir, archive.GCOptions{
 		MaxAge:        maxAge,
 		MaxTotalBytes: maxTotal,
 		DryRun:        dryRun,
 		Now:           time.Now(),
+		Force:         safety.force,
+		ActiveWindow:  window,
+		TrashDir:      safety.trashDir,
+		TrashTTL:      trashTTL,
 	})
 	if err != nil {
 		return err
@@ -84,6 +140,23 @@ func runGC(dir, maxAgeStr, maxTotalStr string, dryRun, jsonOutput bool) error {
 	return nil
 }
 
+func runGCRestore(dir, trashDir, name string, jsonOutput bool) error {
+	if trashDir == "" {
+		return fmt.Errorf("--restore requires --trash-dir")
+	}
+	restored, err := archive.RestoreTrash(dir, trashDir, name)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]string{"restored": restored})
+	}
+	fmt.Printf("Restored %s\n", restored)
+	return nil
+}
+
 func parseGCAge(input string) (time.Duration, error) {
 	s := strings.TrimSpace(input)
 	if s == "" {
@@ -29,6 +29,12 @@ func makeCaptureSub(t *testing.T, root, name string, started time.Time) string {
 	if err := os.WriteFile(filepath.Join(dir, "data.jsonl"), []byte(`{"msg":"test"}`+"\n"), 0o644); err != nil {
 		t.Fatalf("write data: %v", err)
 	}
+	// finished captures are not written to any more
+	for _, name := range []string{"metadata.json", "data.jsonl"} {
+		if err := os.Chtimes(filepath.Join(dir, name), started, started); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
 	return dir
 }
 
@@ -44,7 +50,7 @@ func TestRunGC_MaxAge(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	err := runGC(root, "24h", "", false, false)
+	err := runGC(root, "24h", "", false, false, gcSafety{})
 	if err != nil {
 		t.Fatalf("runGC: %v", err)
 	}
@@ -71,7 +77,7 @@ func TestRunGC_MaxTotal(t *testing.T) {
 	defer restore()
 
 	// set max total very small so oldest is deleted
-	err := runGC(root, "", "1", false, false)
+	err := runGC(root, "", "1", false, false, gcSafety{})
 	if err != nil {
 		t.Fatalf("runGC: %v", err)
 	}
@@ -95,7 +101,7 @@ func TestRunGC_DryRun(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	err := runGC(root, "24h", "", true, false)
+	err := runGC(root, "24h", "", true, false, gcSafety{})
 	if err != nil {
 		t.Fatalf("runGC dry-run: %v", err)
 	}
@@ -115,35 +121,35 @@ func TestRunGC_JSON(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	err := runGC(root, "24h", "", true, true)
+	err := runGC(root, "24h", "", true, true, gcSafety{})
 	if err != nil {
 		t.Fatalf("runGC json: %v", err)
 	}
 }
 
 func TestRunGC_MissingFlags(t *testing.T) {
-	err := runGC("/tmp", "", "", false, false)
+	err := runGC("/tmp", "", "", false, false, gcSafety{})
 	if err == nil {
 		t.Error("expected error when neither --max-age nor --max-total provided")
 	}
 }
 
 func TestRunGC_InvalidAge(t *testing.T) {
-	err := runGC("/tmp", "notaduration", "", false, false)
+	err := runGC("/tmp", "notaduration", "", false, false, gcSafety{})
 	if err == nil || !strings.Contains(err.Error(), "max-age") {
 		t.Errorf("expected --max-age error, got: %v", err)
 	}
 }
 
 func TestRunGC_InvalidTotal(t *testing.T) {
-	err := runGC("/tmp", "", "notasize", false, false)
+	err := runGC("/tmp", "", "notasize", false, false, gcSafety{})
 	if err == nil || !strings.Contains(err.Error(), "max-total") {
 		t.Errorf("expected --max-total error, got: %v", err)
 	}
 }
 
 func TestRunGC_InvalidDir(t *testing.T) {
-	err := runGC("/nonexistent/dir", "24h", "", false, false)
+	err := runGC("/nonexistent/dir", "24h", "", false, false, gcSafety{})
 	if err == nil {
 		t.Error("expected error for nonexistent dir")
 	}
@@ -158,7 +164,7 @@ func TestRunGC_NoDeletions(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
-	err := runGC(root, "48h", "", false, false)
+	err := runGC(root, "48h", "", false, false, gcSafety{})
 	if err != nil {
 		t.Fatalf("runGC: %v", err)
 	}
@@ -169,13 +175,47 @@ func TestRunGC_NoDeletions(t *testing.T) {
 	}
 }
 
+func TestRunGC_TrashAndRestore(t *testing.T) {
+	root := t.TempDir()
+	trash := filepath.Join(root, ".trash")
+	old := makeCaptureSub(t, root, "old", time.Now().Add(-48*time.Hour))
+
+	restore := redirectOutput(t)
+	defer restore()
+
+	if err := runGC(root, "24h", "", false, false, gcSafety{trashDir: trash, trashTTL: "7d"}); err != nil {
+		t.Fatalf("runGC: %v", err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal("expected old to be moved to trash")
+	}
+	if err := runGCRestore(root, trash, "old", false); err != nil {
+		t.Fatalf("runGCRestore: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(old, "metadata.json")); err != nil {
+		t.Errorf("expected old to be restored: %v", err)
+	}
+}
+
+func TestRunGC_SafetyFlagErrors(t *testing.T) {
+	if err := runGC("/tmp", "24h", "", false, false, gcSafety{trashTTL: "1d"}); err == nil || !strings.Contains(err.Error(), "--trash-dir") {
+		t.Errorf("--trash-ttl without --trash-dir: %v", err)
+	}
+	if err := runGC("/tmp", "24h", "", false, false, gcSafety{activeWindow: "soon"}); err == nil {
+		t.Error("expected error for invalid --active-window")
+	}
+	if err := runGCRestore("/tmp", "", "old", false); err == nil {
+		t.Error("expected error for --restore without --trash-dir")
+	}
+}
+
 func TestRunGC_EmptyDir(t *testing.T) {
 	root := t.TempDir()
 
 	restore := redirectOutput(t)
 	defer restore()
 
-	err := runGC(root, "24h", "", false, false)
+	err := runGC(root, "24h", "", false, false, gcSafety{})
 	if err != nil {
 		t.Fatalf("runGC empty: %v", err)
 	}
@@ -199,7 +239,7 @@ func TestRunGC_JSONOutput(t *testing.T) {
 		os.Stdout = oldStdout
 	}
 
-	gcErr := runGC(root, "24h", "", true, true)
+	gcErr := runGC(root, "24h", "", true, true, gcSafety{})
 	_ = w.Close()
 	restore()
 
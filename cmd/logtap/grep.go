@@ -6,6 +6,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -22,25 +23,64 @@ func newGrepCmd() *cobra.Command {
 		sortFlag   bool
 		formatFlag string
 		ctxLines   int
+		summary    bool
+		profile    bool
+		newSince   string
+		outCapture string
+		lifecycle  lifecycleFilter
 	)
 
 	cmd := &cobra.Command{
-		Use:   "grep <pattern> <capture-dir>",
+		Use:   "grep <pattern> [capture-dir]",
 		Short: "Search capture for matching log entries",
 		Long:  "Cross-file regex search across all compressed JSONL files in a capture directory.",
-		Args:  cobra.ExactArgs(2),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if newSince != "" || presetPattern(cmd) != "" {
+				return cobra.MaximumNArgs(2)(cmd, args) // the pattern is optional
+			}
+			return cobra.RangeArgs(1, 2)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			pattern, captureDir := args[0], args[1]
+			if outCapture != "" && (newSince != "" || count || summary || sortFlag || cmd.Flags().Changed("format")) {
+				return fmt.Errorf("--out-capture cannot be combined with --new-since, --count, --summary, --sort or --format")
+			}
+			if newSince != "" {
+				if count || ctxLines > 0 || summary {
+					return fmt.Errorf("--new-since cannot be combined with --count, --context or --summary")
+				}
+				pattern, captureDir, err := newSinceArgs(args)
+				if err != nil {
+					return err
+				}
+				if pattern == "" {
+					pattern = presetPattern(cmd)
+				}
+				return runGrepNewSince(pattern, captureDir, fromStr, toStr, labels, newSince, formatFlag, lifecycle)
+			}
+
+			// a preset's pattern stands in for the first argument unless
+			// both the pattern and the directory are given
+			pattern, dirArgs := presetPattern(cmd), args
+			if pattern == "" || len(args) == 2 {
+				pattern, dirArgs = args[0], args[1:]
+			}
+			captureDir, err := captureDirArg(dirArgs, 0)
+			if err != nil {
+				return err
+			}
 
 			// Detect reversed arguments: if the first arg looks like a directory
 			// and the second doesn't exist as a directory, suggest swapping.
-			if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			if info, err := os.Stat(pattern); err == nil && info.IsDir() && len(args) == 2 {
 				if _, err2 := os.Stat(captureDir); err2 != nil {
 					return fmt.Errorf("'%s' is a directory — did you mean: logtap grep %q %s", pattern, captureDir, pattern)
 				}
 			}
 
-			return runGrep(pattern, captureDir, fromStr, toStr, labels, count, sortFlag, formatFlag, ctxLines)
+			if outCapture != "" {
+				return runGrepCapture(pattern, captureDir, outCapture, fromStr, toStr, labels, ctxLines, profile, lifecycle)
+			}
+			return runGrep(pattern, captureDir, fromStr, toStr, labels, count, sortFlag, formatFlag, ctxLines, summary, profile, lifecycle)
 		},
 	}
 
@@ -51,11 +91,17 @@ func newGrepCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&sortFlag, "sort", false, "sort results by timestamp (chronological order)")
 	cmd.Flags().StringVar(&formatFlag, "format", "json", "output format: json or text (text implies --sort)")
 	cmd.Flags().IntVarP(&ctxLines, "context", "C", 0, "number of surrounding lines to include")
+	cmd.Flags().BoolVar(&summary, "summary", false, "print match breakdown per label value and hour after results")
+	cmd.Flags().BoolVar(&profile, "profile", false, profileFlagUsage)
+	cmd.Flags().StringVar(&newSince, "new-since", "", "list message signatures first seen at or after this time and never before it (RFC3339, HH:MM, or -30m); the pattern is optional")
+	cmd.Flags().StringVar(&outCapture, "out-capture", "", "write matches and their context lines as a new capture in this directory, for triage, diff or open")
+	lifecycle.addFlags(cmd)
+	addPresetFlag(cmd)
 
 	return cmd
 }
 
-func runGrep(pattern, src, fromStr, toStr string, labels []string, countMode, sortByTime bool, format string, ctxLines int) error {
+func runGrep(pattern, src, fromStr, toStr string, labels []string, countMode, sortByTime bool, format string, ctxLines int, summaryMode, profileMode bool, lifecycle lifecycleFilter) error {
 	textMode := format == "text"
 	if textMode {
 		sortByTime = true // text timeline requires chronological order
@@ -70,17 +116,32 @@ func runGrep(pattern, src, fromStr, toStr string, labels []string, countMode, so
 	}
 	meta := reader.Metadata()
 
-	filter, err := buildFilter(fromStr, toStr, labels, pattern, meta)
+	filter, err := buildFilter(fromStr, toStr, lifecycle.labels(labels), pattern, meta)
 	if err != nil {
 		return err
 	}
+	if filter.Restarts, err = lifecycle.restarts(reader); err != nil {
+		return err
+	}
+	if filter.Phases, err = lifecycle.phases(reader); err != nil {
+		return err
+	}
+	if filter.Fields, err = lifecycle.fieldMatchers(); err != nil {
+		return err
+	}
 
 	// pattern is required — buildFilter returns nil when no flags set,
 	// but we always have a pattern, so filter is never nil here.
 
 	cfg := archive.GrepConfig{
-		CountOnly: countMode,
+		CountOnly: countMode && !summaryMode, // summary needs the matching entries
 		Context:   ctxLines,
+		Profile:   newProfile(profileMode),
+	}
+
+	var summary *archive.GrepSummary
+	if summaryMode {
+		summary = archive.NewGrepSummary()
 	}
 
 	enc := json.NewEncoder(os.Stdout)
@@ -94,6 +155,12 @@ func runGrep(pattern, src, fromStr, toStr string, labels []string, countMode, so
 	var collected []collectedEntry
 	var totalMatches int64
 	onMatch := func(m archive.GrepMatch) {
+		if summary != nil && m.Context == "" {
+			summary.Add(m.Entry)
+		}
+		if countMode {
+			return
+		}
 		if sortByTime {
 			collected = append(collected, collectedEntry{entry: m.Entry, context: m.Context, group: m.Group})
 		} else {
@@ -124,9 +191,11 @@ func runGrep(pattern, src, fromStr, toStr string, labels []string, countMode, so
 		_, _ = fmt.Fprintln(os.Stderr)
 		return err
 	}
+	defer printProfile(os.Stderr, cfg.Profile)
 
 	if sortByTime && len(collected) > 0 {
-		sort.Slice(collected, func(i, j int) bool {
+		// stable, so lines sharing a timestamp keep their capture order
+		sort.SliceStable(collected, func(i, j int) bool {
 			return collected[i].entry.Timestamp.Before(collected[j].entry.Timestamp)
 		})
 		if textMode {
@@ -144,7 +213,7 @@ func runGrep(pattern, src, fromStr, toStr string, labels []string, countMode, so
 					_, _ = fmt.Fprintln(os.Stdout, "--")
 				}
 				lastGroup = c.group
-				printTextLine(c.entry, maxLabel)
+				printTextLine(c.entry, maxLabel, textTimeLayout(meta.Precision))
 			}
 		} else {
 			for _, c := range collected {
@@ -173,9 +242,182 @@ func runGrep(pattern, src, fromStr, toStr string, labels []string, countMode, so
 	_, _ = fmt.Fprintf(os.Stderr, "\r%s matches across %d files\n",
 		archive.FormatCount(totalMatches), len(counts))
 
+	if summary != nil {
+		summary.Finish()
+		if textMode || countMode {
+			printGrepSummary(summary)
+		} else {
+			_ = enc.Encode(struct {
+				Summary *archive.GrepSummary `json:"summary"`
+			}{summary})
+		}
+	}
+
 	return nil
 }
 
+// runGrepCapture writes the entries matching pattern, with ctxLines of
+// context, to a new capture in out.
+func runGrepCapture(pattern, src, out, fromStr, toStr string, labels []string, ctxLines int, profileMode bool, lifecycle lifecycleFilter) error {
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("open capture: %w", err)
+	}
+	meta := reader.Metadata()
+
+	filter, err := buildFilter(fromStr, toStr, lifecycle.labels(labels), pattern, meta)
+	if err != nil {
+		return err
+	}
+	if filter.Restarts, err = lifecycle.restarts(reader); err != nil {
+		return err
+	}
+	if filter.Phases, err = lifecycle.phases(reader); err != nil {
+		return err
+	}
+	if filter.Fields, err = lifecycle.fieldMatchers(); err != nil {
+		return err
+	}
+
+	cfg := archive.GrepConfig{Context: ctxLines, Profile: newProfile(profileMode)}
+	progress := func(p archive.GrepProgress) {
+		_, _ = fmt.Fprintf(os.Stderr, "\rSearching: %s lines", archive.FormatCount(p.Scanned))
+	}
+	result, err := archive.GrepToCapture(src, out, filter, cfg, progress)
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer printProfile(os.Stderr, cfg.Profile)
+
+	_, _ = fmt.Fprintf(os.Stderr, "Wrote %s lines (%s matches, %s context) in %d files to %s\n",
+		archive.FormatCount(result.Lines), archive.FormatCount(result.Matches),
+		archive.FormatCount(result.Context), result.Files, result.Output)
+	return nil
+}
+
+// newSinceArgs splits the optional pattern and capture directory of
+// grep --new-since. A lone argument naming a directory is the capture.
+func newSinceArgs(args []string) (pattern, captureDir string, err error) {
+	if len(args) == 1 {
+		if info, statErr := os.Stat(args[0]); statErr == nil && info.IsDir() {
+			captureDir, err = captureDirArg(args, 0)
+			return "", captureDir, err
+		}
+	}
+	if len(args) > 0 {
+		pattern = args[0]
+	}
+	captureDir, err = captureDirArg(args, 1)
+	return pattern, captureDir, err
+}
+
+// runGrepNewSince reports the message signatures among matching entries
+// that appear at or after newSince but never before it.
+func runGrepNewSince(pattern, src, fromStr, toStr string, labels []string, newSince, format string, lifecycle lifecycleFilter) error {
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("open capture: %w", err)
+	}
+	meta := reader.Metadata()
+
+	refTime := meta.Stopped
+	if refTime.IsZero() {
+		refTime = meta.Started
+	}
+	since, err := archive.ParseTimeFlag(newSince, meta.Started, refTime)
+	if err != nil {
+		return fmt.Errorf("invalid --new-since: %w", err)
+	}
+
+	filter, err := buildFilter(fromStr, toStr, lifecycle.labels(labels), pattern, meta)
+	if err != nil {
+		return err
+	}
+	if filter == nil {
+		filter = &archive.Filter{}
+	}
+	if filter.Restarts, err = lifecycle.restarts(reader); err != nil {
+		return err
+	}
+	if filter.Phases, err = lifecycle.phases(reader); err != nil {
+		return err
+	}
+	if filter.Fields, err = lifecycle.fieldMatchers(); err != nil {
+		return err
+	}
+
+	tracker := archive.NewNewPatterns(since)
+	var scanned int64
+	progress := func(p archive.GrepProgress) {
+		scanned = p.Scanned
+		_, _ = fmt.Fprintf(os.Stderr, "\rScanning: %s lines", archive.FormatCount(p.Scanned))
+	}
+	if _, err := archive.Grep(src, filter, archive.GrepConfig{}, func(m archive.GrepMatch) {
+		tracker.Add(m.Entry)
+	}, progress); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr)
+		return err
+	}
+
+	results := tracker.Results()
+	_, _ = fmt.Fprintf(os.Stderr, "\r%d new patterns since %s (%s lines scanned)\n",
+		len(results), since.UTC().Format(time.RFC3339), archive.FormatCount(scanned))
+	if tracker.Truncated() {
+		_, _ = fmt.Fprintln(os.Stderr, "warning: too many distinct messages to track; results may be incomplete")
+	}
+
+	if format == "text" {
+		for _, p := range results {
+			_, _ = fmt.Fprintf(os.Stdout, "%s  %s  %8s  %s\n",
+				p.FirstSeen.UTC().Format("15:04:05"), p.LastSeen.UTC().Format("15:04:05"),
+				archive.FormatCount(p.Count), p.Pattern)
+		}
+		return nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, p := range results {
+		_ = enc.Encode(p)
+	}
+	return nil
+}
+
+// maxSummaryValues caps the label values listed per key in the text summary.
+const maxSummaryValues = 10
+
+func printGrepSummary(s *archive.GrepSummary) {
+	w := os.Stdout
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintf(w, "Summary: %s matches\n", archive.FormatCount(s.Matches))
+	if s.Matches == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "  First: %s\n", s.First.UTC().Format(time.RFC3339))
+	_, _ = fmt.Fprintf(w, "  Last:  %s\n", s.Last.UTC().Format(time.RFC3339))
+
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(w, "\nBy %s:\n", k)
+		vals := s.TopValues(k)
+		for i, v := range vals {
+			if i == maxSummaryValues {
+				_, _ = fmt.Fprintf(w, "  ... %d more\n", len(vals)-maxSummaryValues)
+				break
+			}
+			_, _ = fmt.Fprintf(w, "  %-30s %s\n", v.Value, archive.FormatCount(v.Count))
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "\nBy hour (UTC):")
+	for _, h := range s.Hours {
+		_, _ = fmt.Fprintf(w, "  %s  %s\n", h.Hour.Format("2006-01-02 15:00"), archive.FormatCount(h.Count))
+	}
+}
+
 func entryLabel(e recv.LogEntry) string {
 	if app := e.Labels["app"]; app != "" {
 		return app
@@ -187,8 +429,17 @@ func entryLabel(e recv.LogEntry) string {
 	return "-"
 }
 
-func printTextLine(e recv.LogEntry, maxLabel int) {
-	ts := e.Timestamp.Format("15:04:05.000")
+// textTimeLayout returns the time layout of text output for a capture's
+// timestamp precision, so lines within the same millisecond stay apart.
+func textTimeLayout(precision string) string {
+	if n := recv.PrecisionDigits(precision); n > 0 {
+		return "15:04:05." + strings.Repeat("0", n)
+	}
+	return "15:04:05"
+}
+
+func printTextLine(e recv.LogEntry, maxLabel int, layout string) {
+	ts := e.Timestamp.Format(layout)
 	label := entryLabel(e)
 	pad := ""
 	if len(label) < maxLabel {
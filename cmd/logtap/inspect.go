@@ -13,12 +13,16 @@ func newInspectCmd() *cobra.Command {
 	var jsonOutput bool
 
 	cmd := &cobra.Command{
-		Use:   "inspect <capture-dir>",
+		Use:   "inspect [capture-dir]",
 		Short: "Show capture directory summary",
 		Long:  "Read metadata.json and index.jsonl from a capture directory and display label breakdown, timeline, and size stats. No decompression — instant even for large captures.",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runInspect(args[0], jsonOutput)
+			captureDir, err := captureDirArg(args, 0)
+			if err != nil {
+				return err
+			}
+			return runInspect(captureDir, jsonOutput)
 		},
 	}
 
@@ -5,7 +5,7 @@ import "testing"
 func TestRunStatus_NoKubeconfig(t *testing.T) {
 	t.Setenv("KUBECONFIG", "/nonexistent")
 
-	err := runStatus("default", false)
+	err := runStatus("default", "", false)
 	if err == nil {
 		t.Fatal("expected error without kubeconfig")
 	}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+)
+
+// addLatencyFlags registers --latency-field, --latency-regex, --latency-unit
+// and --latency-by; latencyConfig reads them back.
+func addLatencyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("latency-field", "", "JSON field holding a request duration (e.g. duration_ms or http.latency), to report p50/p95/p99 latency")
+	cmd.Flags().String("latency-regex", "", "regex whose first capture group holds a request duration (e.g. 'took (\\d+)ms'), instead of --latency-field")
+	cmd.Flags().String("latency-unit", "ms", "unit of durations without one: ns, us, ms or s")
+	cmd.Flags().String("latency-by", "app", "label key latency percentiles are split by")
+}
+
+// latencyConfig returns the duration extraction set by cmd's latency flags,
+// or nil when neither --latency-field nor --latency-regex is set.
+func latencyConfig(cmd *cobra.Command) (*archive.LatencyConfig, error) {
+	field, _ := cmd.Flags().GetString("latency-field")
+	expr, _ := cmd.Flags().GetString("latency-regex")
+	unitStr, _ := cmd.Flags().GetString("latency-unit")
+	by, _ := cmd.Flags().GetString("latency-by")
+
+	if field == "" && expr == "" {
+		return nil, nil
+	}
+	if field != "" && expr != "" {
+		return nil, fmt.Errorf("--latency-field and --latency-regex are mutually exclusive")
+	}
+	unit, err := time.ParseDuration("1" + unitStr)
+	if err != nil || unit <= 0 {
+		return nil, fmt.Errorf("invalid --latency-unit %q (expected ns, us, ms or s)", unitStr)
+	}
+	if by == "" {
+		return nil, fmt.Errorf("--latency-by must not be empty")
+	}
+	cfg := &archive.LatencyConfig{Field: field, Unit: unit, GroupBy: by}
+	if expr != "" {
+		if cfg.Pattern, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid --latency-regex: %w", err)
+		}
+	}
+	return cfg, nil
+}
@@ -11,8 +11,10 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/ppiankov/logtap/internal/archive"
 	"github.com/ppiankov/logtap/internal/cli"
 	"github.com/ppiankov/logtap/internal/config"
+	"github.com/ppiankov/logtap/internal/k8s"
 )
 
 const defaultTimeout = 30 * time.Second
@@ -23,6 +25,9 @@ var (
 	date       = "unknown"
 	cfg        *config.Config
 	timeoutStr string
+	k8sAuth    k8s.AuthOptions
+	keyFile    string
+	langPacks  []string
 )
 
 type buildInfo struct {
@@ -96,17 +101,39 @@ func execute() error {
 	root := &cobra.Command{
 		Use:   "logtap",
 		Short: "Ephemeral log mirror for load testing",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			reportConfigIssues(os.Stderr, cmd)
+			if keyFile != "" {
+				archive.SetKeyFile(keyFile)
+			}
+			if err := applyLanguagePacks(cmd); err != nil {
+				return err
+			}
+			return applyPreset(cmd)
+		},
 	}
 	root.PersistentFlags().StringVar(&timeoutStr, "timeout", "", "timeout for cluster operations (e.g. 30s, 1m)")
+	root.PersistentFlags().StringVar(&k8sAuth.As, "as", "", "username to impersonate for cluster operations")
+	root.PersistentFlags().StringSliceVar(&k8sAuth.AsGroups, "as-group", nil, "group to impersonate for cluster operations (repeatable, requires --as)")
+	root.PersistentFlags().StringVar(&k8sAuth.Token, "token", "", "bearer token for cluster operations (replaces kubeconfig credentials)")
+	root.PersistentFlags().StringVar(&k8sAuth.TokenPath, "sa-token-path", "", "path to a service-account token file for cluster operations")
+	root.PersistentFlags().StringVar(&contextDir, "context-dir", "", "capture directory for commands that omit it (overrides logtap use)")
+	root.PersistentFlags().StringVar(&keyFile, "key-file", "", "key for reading encrypted capture files (32 bytes raw, hex, or base64; env "+archive.KeyFileEnv+")")
+	root.PersistentFlags().StringSliceVar(&langPacks, "language-pack", nil, "error classification packs for analysis commands: "+strings.Join(archive.LanguagePackNames(), ", ")+" (repeatable)")
 	root.AddCommand(newVersionCmd())
 	root.AddCommand(newRecvCmd())
 	root.AddCommand(newOpenCmd())
 	root.AddCommand(newInspectCmd())
+	root.AddCommand(newStatsCmd())
 	root.AddCommand(newGCCmd())
+	root.AddCommand(newCompactCmd())
 	root.AddCommand(newSliceCmd())
 	root.AddCommand(newExportCmd())
 	root.AddCommand(newTriageCmd())
 	root.AddCommand(newGrepCmd())
+	root.AddCommand(newQueryCmd())
+	root.AddCommand(newSQLCmd())
+	root.AddCommand(newTailCmd())
 	root.AddCommand(newMergeCmd())
 	root.AddCommand(newSnapshotCmd())
 	root.AddCommand(newDiffCmd())
@@ -122,7 +149,17 @@ func execute() error {
 	root.AddCommand(newCatalogCmd())
 	root.AddCommand(newReportCmd())
 	root.AddCommand(newSignCmd())
+	root.AddCommand(newVerifyCmd())
+	root.AddCommand(newMigrateCmd())
 	root.AddCommand(newInitCmd())
+	root.AddCommand(newConfigCmd())
+	root.AddCommand(newPresetsCmd())
+	root.AddCommand(newAssertCmd())
+	root.AddCommand(newUseCmd())
+	groupCommands(root)
+	if err := unknownCommandError(root, os.Args[1:]); err != nil {
+		return err
+	}
 	return root.Execute()
 }
 
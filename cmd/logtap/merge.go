@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -15,16 +16,36 @@ func newMergeCmd() *cobra.Command {
 		outDir       string
 		jsonOutput   bool
 		clockCorrect bool
+		fromShards   bool
+		onConflict   string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "merge <capture-dir> <capture-dir> [<capture-dir>...] -o <output-dir>",
 		Short: "Combine multiple captures into one",
 		Long: "Merge multiple capture directories by timestamp. Copies compressed files without decompressing.\n" +
-			"With --clock-correct, detects and corrects clock skew between sources.",
-		Args: cobra.MinimumNArgs(2),
+			"With --clock-correct, detects and corrects clock skew between sources.\n" +
+			"With --from-shards, the sources (or the subdirectories of a single source) must be every shard of a recv --shard set.\n" +
+			"Label keys used by several sources with no value in common are reported as conflicts; --on-conflict resolves them.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if fromShards {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return cobra.MinimumNArgs(2)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runMerge(args, outDir, jsonOutput, clockCorrect)
+			strategy, err := archive.ParseConflictStrategy(onConflict)
+			if err != nil {
+				return fmt.Errorf("--on-conflict: %w", err)
+			}
+			if fromShards {
+				sources, err := archive.ShardSources(args)
+				if err != nil {
+					return err
+				}
+				args = sources
+			}
+			return runMerge(args, outDir, jsonOutput, clockCorrect, strategy)
 		},
 	}
 
@@ -32,27 +53,46 @@ func newMergeCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output summary as JSON")
 	addFormatAlias(cmd, &jsonOutput)
 	cmd.Flags().BoolVar(&clockCorrect, "clock-correct", false, "detect and correct clock skew between sources")
+	cmd.Flags().BoolVar(&fromShards, "from-shards", false, "reassemble the captures of a recv --shard set, checking that every shard is present")
+	cmd.Flags().StringVar(&onConflict, "on-conflict", "", "resolve conflicting label keys: keep-a (first source), keep-b (last source) or suffix (rename to <key>_<n>); default reports them only")
 	_ = cmd.MarkFlagRequired("out")
 
 	return cmd
 }
 
-func runMerge(sources []string, outDir string, jsonOutput, clockCorrect bool) error {
+func runMerge(sources []string, outDir string, jsonOutput, clockCorrect bool, strategy archive.ConflictStrategy) error {
 	progress := func(p archive.MergeProgress) {
 		_, _ = fmt.Fprintf(os.Stderr, "\rMerging: %d / %d files", p.FilesCopied, p.TotalFiles)
 	}
 
+	conflicts, err := archive.DetectLabelConflicts(sources)
+	if err != nil {
+		return err
+	}
+	inputs, cleanup, err := archive.ResolveLabelConflicts(sources, conflicts, strategy)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
 	var corrections []archive.ClockCorrection
 
 	if clockCorrect {
-		var err error
-		corrections, err = archive.MergeWithCorrection(sources, outDir, progress)
+		corrections, err = archive.MergeWithCorrection(inputs, outDir, progress)
 		if err != nil {
 			_, _ = fmt.Fprintln(os.Stderr)
 			return err
 		}
+		// report corrections against the sources given, not rewritten copies
+		for i := range corrections {
+			for j, in := range inputs {
+				if corrections[i].Source == in {
+					corrections[i].Source = sources[j]
+				}
+			}
+		}
 	} else {
-		if err := archive.Merge(sources, outDir, progress); err != nil {
+		if err := archive.Merge(inputs, outDir, progress); err != nil {
 			_, _ = fmt.Fprintln(os.Stderr)
 			return err
 		}
@@ -75,6 +115,9 @@ func runMerge(sources []string, outDir string, jsonOutput, clockCorrect bool) er
 		if len(corrections) > 0 {
 			result["clock_corrections"] = corrections
 		}
+		if len(conflicts) > 0 {
+			result["label_conflicts"] = conflicts
+		}
 		return json.NewEncoder(os.Stdout).Encode(result)
 	}
 
@@ -89,6 +132,13 @@ func runMerge(sources []string, outDir string, jsonOutput, clockCorrect bool) er
 				cc.Source, cc.OffsetMs, cc.Confidence, cc.Method)
 		}
 	}
+	for _, c := range conflicts {
+		_, _ = fmt.Fprintf(os.Stderr, "  Label conflict: %s in %s — %s\n",
+			c.Key, strings.Join(c.Sources, ", "), c.Resolution)
+	}
+	if len(conflicts) > 0 && strategy == archive.ConflictMix {
+		_, _ = fmt.Fprintln(os.Stderr, "  Use --on-conflict keep-a|keep-b|suffix to resolve label conflicts")
+	}
 
 	return nil
 }
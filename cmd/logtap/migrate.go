@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func newMigrateCmd() *cobra.Command {
+	var dryRun bool
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate <capture-dir>",
+		Short: "Upgrade a capture's metadata to the current schema version",
+		Long: fmt.Sprintf(`Upgrade metadata.json of a capture, and of each of its sessions, to schema
+version %d in place. The features the capture uses (shards, sessions,
+partitions, dedup, offload, encryption, checksums) are detected and recorded,
+so tools can tell what they need to support before reading it. Data files
+and indexes are not changed. Run it on finished captures only, not while a
+receiver writes to the directory.`, recv.MetadataVersion),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrate(args[0], dryRun, jsonOutput)
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would change without writing")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output the result as JSON")
+	addFormatAlias(cmd, &jsonOutput)
+
+	return cmd
+}
+
+func runMigrate(dir string, dryRun, jsonOutput bool) error {
+	result, err := archive.Migrate(dir, archive.MigrateOptions{DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return result.WriteJSON(os.Stdout)
+	}
+	result.WriteText(os.Stdout)
+	return nil
+}
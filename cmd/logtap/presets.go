@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/cli"
+	"github.com/ppiankov/logtap/internal/config"
+)
+
+const presetFlagUsage = "apply a named filter from the presets section of the config (see logtap presets); explicit flags win"
+
+// addPresetFlag registers --preset; applyPreset fills the command's filter
+// flags from it before the command runs.
+func addPresetFlag(cmd *cobra.Command) {
+	cmd.Flags().String("preset", "", presetFlagUsage)
+}
+
+// lookupPreset returns the preset named by cmd's --preset flag, or nil when
+// the command has no such flag or it is unset.
+func lookupPreset(cmd *cobra.Command) (*config.Preset, error) {
+	f := cmd.Flags().Lookup("preset")
+	if f == nil || f.Value.String() == "" {
+		return nil, nil
+	}
+	name := f.Value.String()
+	if cfg != nil {
+		if p, ok := cfg.Presets[name]; ok {
+			return &p, nil
+		}
+	}
+	msg := fmt.Sprintf("unknown preset %q", name)
+	if names := presetNames(); len(names) > 0 {
+		msg += " (defined: " + strings.Join(names, ", ") + ")"
+	} else {
+		msg += " (no presets in ~/.logtap/config.yaml or .logtap.yaml)"
+	}
+	return nil, cli.NewUsageError(msg)
+}
+
+// applyPreset sets --from, --to, --grep and --label from the command's
+// preset, for the flags the command has and the user did not set. grep
+// takes the preset's pattern through presetPattern instead.
+func applyPreset(cmd *cobra.Command) error {
+	p, err := lookupPreset(cmd)
+	if p == nil {
+		return err
+	}
+	set := func(name string, values ...string) error {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			return nil
+		}
+		for _, v := range values {
+			if v == "" {
+				continue
+			}
+			if err := f.Value.Set(v); err != nil {
+				return cli.NewUsageError(fmt.Sprintf("preset %s: invalid %s %q: %v", cmd.Flags().Lookup("preset").Value, name, v, err))
+			}
+		}
+		return nil
+	}
+	for _, fv := range []struct {
+		name   string
+		values []string
+	}{
+		{"from", []string{p.From}},
+		{"to", []string{p.To}},
+		{"grep", []string{p.Grep}},
+		{"label", p.Labels},
+	} {
+		if err := set(fv.name, fv.values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// presetPattern returns the grep pattern of cmd's preset, or "".
+func presetPattern(cmd *cobra.Command) string {
+	if p, _ := lookupPreset(cmd); p != nil {
+		return p.Grep
+	}
+	return ""
+}
+
+func presetNames() []string {
+	if cfg == nil {
+		return nil
+	}
+	names := make([]string, 0, len(cfg.Presets))
+	for name := range cfg.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type presetEntry struct {
+	Name string `json:"name"`
+	config.Preset
+}
+
+func newPresetsCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "presets",
+		Short: "List the named filters defined in config",
+		Long: `List the presets defined under presets: in ~/.logtap/config.yaml and
+.logtap.yaml. grep, slice, export and open apply one with --preset NAME;
+flags given on the command line override the preset's values.
+
+  presets:
+    payment-errors:
+      description: Payment failures in the last half hour of a capture
+      labels: [app=payments]
+      grep: "error|declined|timeout"
+      from: -30m`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPresets(jsonOutput)
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output as JSON")
+	addFormatAlias(cmd, &jsonOutput)
+	return cmd
+}
+
+func runPresets(jsonOutput bool) error {
+	entries := []presetEntry{}
+	for _, name := range presetNames() {
+		entries = append(entries, presetEntry{Name: name, Preset: cfg.Presets[name]})
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No presets defined. Add them under presets: in ~/.logtap/config.yaml or .logtap.yaml.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tFILTER\tDESCRIPTION")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Name, presetFlags(e.Preset), e.Description)
+	}
+	return tw.Flush()
+}
+
+// presetFlags renders a preset as the flags it stands for.
+func presetFlags(p config.Preset) string {
+	var parts []string
+	for _, l := range p.Labels {
+		parts = append(parts, "--label "+l)
+	}
+	if p.Grep != "" {
+		parts = append(parts, fmt.Sprintf("--grep %q", p.Grep))
+	}
+	if p.From != "" {
+		parts = append(parts, "--from "+p.From)
+	}
+	if p.To != "" {
+		parts = append(parts, "--to "+p.To)
+	}
+	return strings.Join(parts, " ")
+}
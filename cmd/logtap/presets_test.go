@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/cli"
+	"github.com/ppiankov/logtap/internal/config"
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func withPresets(t *testing.T, presets map[string]config.Preset) {
+	t.Helper()
+	orig := cfg
+	cfg = &config.Config{Presets: presets}
+	t.Cleanup(func() { cfg = orig })
+}
+
+// presetRoot runs sub under a root that applies presets like main's does.
+func presetRoot(sub *cobra.Command) *cobra.Command {
+	root := &cobra.Command{
+		Use:               "logtap",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return applyPreset(cmd) },
+		SilenceUsage:      true,
+		SilenceErrors:     true,
+	}
+	root.AddCommand(sub)
+	return root
+}
+
+func TestGrepPreset(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "payments"}, Message: "card declined"},
+		{Timestamp: base.Add(time.Minute), Labels: map[string]string{"app": "payments"}, Message: "charge ok"},
+		{Timestamp: base.Add(2 * time.Minute), Labels: map[string]string{"app": "api"}, Message: "upstream declined"},
+		{Timestamp: base.Add(50 * time.Minute), Labels: map[string]string{"app": "payments"}, Message: "refund declined"},
+	})
+	withPresets(t, map[string]config.Preset{
+		"payment-errors": {Labels: []string{"app=payments"}, Grep: "declined", From: "-30m"},
+	})
+
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		// pattern, label and relative --from all come from the preset
+		{[]string{"grep", "--preset", "payment-errors", dir}, []string{"refund declined"}},
+		// an explicit pattern and --from override it; the label still applies
+		{[]string{"grep", "--preset", "payment-errors", "--from", "09:00", "charge|declined", dir}, []string{"card declined", "charge ok", "refund declined"}},
+	} {
+		out := captureStdout(t, func() {
+			root := presetRoot(newGrepCmd())
+			root.SetArgs(tt.args)
+			if err := root.Execute(); err != nil {
+				t.Fatalf("%v: %v", tt.args, err)
+			}
+		})
+		for _, msg := range tt.want {
+			if !strings.Contains(out, msg) {
+				t.Errorf("%v: output lacks %q:\n%s", tt.args, msg, out)
+			}
+		}
+		if got := strings.Count(strings.TrimSpace(out), "\n") + 1; got != len(tt.want) {
+			t.Errorf("%v: %d lines, want %d:\n%s", tt.args, got, len(tt.want), out)
+		}
+	}
+
+	root := presetRoot(newGrepCmd())
+	root.SetArgs([]string{"grep", "--preset", "payment-erors", dir})
+	err := root.Execute()
+	if cli.ExitCode(err) != cli.ExitUsage || !strings.Contains(err.Error(), "defined: payment-errors") {
+		t.Errorf("unknown preset: err = %v", err)
+	}
+}
+
+func TestRunPresets(t *testing.T) {
+	withPresets(t, nil)
+	out := captureStdout(t, func() {
+		if err := runPresets(false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "No presets defined") {
+		t.Errorf("empty output = %q", out)
+	}
+
+	withPresets(t, map[string]config.Preset{
+		"slow":           {Description: "Slow requests", Grep: "took [0-9]+s"},
+		"payment-errors": {Labels: []string{"app=payments"}, From: "-30m"},
+	})
+	out = captureStdout(t, func() {
+		if err := runPresets(false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "payment-errors  --label app=payments --from -30m") ||
+		!strings.HasSuffix(lines[2], "Slow requests") || !strings.Contains(lines[2], `--grep "took [0-9]+s"`) {
+		t.Errorf("output =\n%s", out)
+	}
+
+	out = captureStdout(t, func() {
+		if err := runPresets(true); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, `"name": "payment-errors"`) || !strings.Contains(out, `"labels": [`) {
+		t.Errorf("json output =\n%s", out)
+	}
+}
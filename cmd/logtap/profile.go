@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ppiankov/logtap/internal/archive"
+)
+
+const profileFlagUsage = "report bytes read and read/decompress/decode/filter time per file on stderr when done"
+
+// newProfile returns a profile when --profile is set, nil otherwise.
+func newProfile(enabled bool) *archive.Profile {
+	if !enabled {
+		return nil
+	}
+	return archive.NewProfile()
+}
+
+// printProfile writes the per-file read profile, if any.
+func printProfile(w io.Writer, p *archive.Profile) {
+	if p == nil {
+		return
+	}
+	_, _ = fmt.Fprintln(w, "\nRead profile:")
+	p.WriteText(w)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// queryOpts holds the flags of query.
+type queryOpts struct {
+	live      bool
+	receiver  string
+	labels    []string
+	from      string
+	to        string
+	limit     int
+	files     bool
+	format    string
+	authToken string
+	step      string
+}
+
+func newQueryCmd() *cobra.Command {
+	var opts queryOpts
+
+	cmd := &cobra.Command{
+		Use:   "query [capture-dir] <logql> | query --live [pattern]",
+		Short: "Run a LogQL query over a capture, or search a running receiver",
+		Long: `Query evaluates a LogQL query over a capture. Log queries print the
+matching entries, with the labels json and logfmt stages extract; metric
+queries print one series per label set.
+
+Supported: stream selectors, line filters (|= != |~ !~), the json and
+logfmt parsers, label filters on strings, numbers and durations (joined by
+"and" or ","), the range aggregations rate, count_over_time, bytes_rate and
+bytes_over_time, and sum, avg, min, max and count with by or without.
+Metric queries are evaluated every --step (default the range) from the
+first to the last matching entry.
+
+With --live, query instead asks a running receiver for its most recent
+entries matching label filters and an optional regex, without waiting for
+the capture to finish. The receiver searches the entries it holds in
+memory; with --files it also searches the capture files written before
+them.`,
+		Example: `  logtap query ./capture '{app="web"} |= "error" | json | status >= 500'
+  logtap query ./capture 'sum by (app) (rate({namespace="prod"} |= "timeout" [1m]))'
+  logtap query ./capture 'count_over_time({app="api"} | logfmt | took > 1s [5m])' --step 1m --format text
+  logtap query --live error
+  logtap query --live --label app=api --from -10m --files 'timeout|refused'
+  logtap query --live --receiver 10.0.0.5:3100 --auth-token $TOKEN --format text panic`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !opts.live {
+				for _, name := range []string{"receiver", "label", "files", "auth-token"} {
+					if cmd.Flags().Changed(name) {
+						return cli.NewUsageError(fmt.Sprintf("--%s needs --live", name))
+					}
+				}
+				if len(args) == 0 {
+					return cli.NewUsageError("query needs a LogQL expression, or --live for a running receiver")
+				}
+				captureDir, err := captureDirArg(args[:len(args)-1], 0)
+				if err != nil {
+					return err
+				}
+				limit := opts.limit
+				if !cmd.Flags().Changed("limit") {
+					limit = 0
+				}
+				return runCaptureQuery(captureDir, args[len(args)-1], opts.from, opts.to, opts.step, limit, opts.format)
+			}
+			if len(args) > 1 {
+				return cli.NewUsageError("query --live takes at most one pattern")
+			}
+			if cmd.Flags().Changed("step") {
+				return cli.NewUsageError("--step applies to capture queries, not --live")
+			}
+			var pattern string
+			if len(args) == 1 {
+				pattern = args[0]
+			}
+			return runQuery(pattern, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.live, "live", false, "query the receiver at --receiver")
+	cmd.Flags().StringVar(&opts.receiver, "receiver", "127.0.0.1:3100", "receiver address")
+	cmd.Flags().StringSliceVar(&opts.labels, "label", nil, "label filter (key=value, repeatable)")
+	cmd.Flags().StringVar(&opts.from, "from", "", "start time filter (RFC3339 or -30m)")
+	cmd.Flags().StringVar(&opts.to, "to", "", "end time filter (RFC3339 or -30m)")
+	cmd.Flags().IntVar(&opts.limit, "limit", 100, "newest matching entries to show with --live (max 10000); first matching entries of a capture log query (default all)")
+	cmd.Flags().BoolVar(&opts.files, "files", false, "also search capture files written before the entries held in memory (the last 15m without --from)")
+	cmd.Flags().StringVar(&opts.format, "format", "json", "output format: json or text")
+	cmd.Flags().StringVar(&opts.authToken, "auth-token", "", "bearer token for receivers started with --auth-token")
+	cmd.Flags().StringVar(&opts.step, "step", "", "metric query resolution (default the range of the range aggregation, which must be a multiple)")
+
+	return cmd
+}
+
+func runQuery(pattern string, opts queryOpts) error {
+	if opts.format != "json" && opts.format != "text" {
+		return fmt.Errorf("invalid --format %q: use json or text", opts.format)
+	}
+	res, err := fetchLiveQuery(pattern, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.format == "text" {
+		maxLabel := 0
+		for _, e := range res.Entries {
+			maxLabel = max(maxLabel, len(entryLabel(e)))
+		}
+		for _, e := range res.Entries {
+			printTextLine(e, maxLabel, textTimeLayout(""))
+		}
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range res.Entries {
+			_ = enc.Encode(e)
+		}
+	}
+
+	note := ""
+	if res.Truncated {
+		note = fmt.Sprintf(", newest %d shown", len(res.Entries))
+	}
+	if !res.RingFrom.IsZero() && !res.Files {
+		note += fmt.Sprintf(", in memory since %s", res.RingFrom.Local().Format("15:04:05"))
+	}
+	fmt.Fprintf(os.Stderr, "%d matches%s\n", res.Matched, note)
+	return nil
+}
+
+// runCaptureQuery evaluates a LogQL query over a capture. Log queries
+// print entries like grep; metric queries print Loki's matrix format in
+// JSON, or a block of samples per series in text.
+func runCaptureQuery(src, expr, fromStr, toStr, stepStr string, limit int, format string) error {
+	if format != "json" && format != "text" {
+		return cli.NewUsageError(fmt.Sprintf("invalid --format %q: use json or text", format))
+	}
+	q, err := archive.ParseLogQL(expr)
+	if err != nil {
+		return cli.NewUsageError(err.Error())
+	}
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("open source: %w", err)
+	}
+	meta := reader.Metadata()
+	var opts archive.LogQLOptions
+	if f, err := buildFilter(fromStr, toStr, nil, "", meta); err != nil {
+		return err
+	} else if f != nil {
+		opts.From, opts.To = f.From, f.To
+	}
+	if stepStr != "" {
+		if !q.IsMetric() {
+			return cli.NewUsageError("--step applies to metric queries")
+		}
+		if opts.Step, err = time.ParseDuration(stepStr); err != nil || opts.Step <= 0 {
+			return cli.NewUsageError(fmt.Sprintf("invalid --step %q", stepStr))
+		}
+	}
+
+	if q.IsMetric() {
+		series, scanned, err := q.RunMetric(src, opts)
+		if err != nil {
+			return err
+		}
+		if format == "text" {
+			for _, s := range series {
+				fmt.Println(archive.FormatLabels(s.Labels))
+				for _, p := range s.Points {
+					fmt.Printf("  %s  %s\n", p.Time.Format(time.RFC3339), strconv.FormatFloat(p.Value, 'f', -1, 64))
+				}
+			}
+		} else {
+			enc := json.NewEncoder(os.Stdout)
+			for _, s := range series {
+				_ = enc.Encode(s)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%d series, %s lines scanned\n", len(series), archive.FormatCount(scanned))
+		return nil
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	layout := textTimeLayout(meta.Precision)
+	matched := 0
+	scanned, err := q.RunLog(src, opts, func(e recv.LogEntry) bool {
+		matched++
+		if format == "text" {
+			printTextLine(e, 0, layout)
+		} else {
+			_ = enc.Encode(e)
+		}
+		return limit <= 0 || matched < limit
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d matches, %s lines scanned\n", matched, archive.FormatCount(scanned))
+	return nil
+}
+
+// fetchLiveQuery calls the receiver's live query API.
+func fetchLiveQuery(pattern string, opts queryOpts) (*recv.QueryResult, error) {
+	base := opts.receiver
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "http://" + base
+	}
+	params := url.Values{}
+	for _, l := range opts.labels {
+		params.Add("label", l)
+	}
+	if pattern != "" {
+		params.Set("grep", pattern)
+	}
+	if opts.from != "" {
+		params.Set("from", opts.from)
+	}
+	if opts.to != "" {
+		params.Set("to", opts.to)
+	}
+	params.Set("limit", strconv.Itoa(opts.limit))
+	if opts.files {
+		params.Set("files", "true")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(base, "/")+"/logtap/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.authToken)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("contact receiver: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("receiver returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var res recv.QueryResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("parse query result: %w", err)
+	}
+	return &res, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/cli"
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestRunQuery(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		_ = json.NewEncoder(w).Encode(recv.QueryResult{
+			Entries: []recv.LogEntry{{
+				Timestamp: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+				Labels:    map[string]string{"app": "api"},
+				Message:   "upstream timeout",
+			}},
+			Matched: 1,
+		})
+	}))
+	defer srv.Close()
+
+	out := captureStdout(t, func() {
+		err := runQuery("timeout", queryOpts{
+			receiver:  srv.URL,
+			labels:    []string{"app=api"},
+			from:      "-10m",
+			limit:     5,
+			files:     true,
+			format:    "text",
+			authToken: "s3cret",
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	if got.URL.Path != "/logtap/api/v1/query" {
+		t.Fatalf("path = %s", got.URL.Path)
+	}
+	q := got.URL.Query()
+	if q.Get("grep") != "timeout" || q.Get("label") != "app=api" || q.Get("from") != "-10m" || q.Get("limit") != "5" || q.Get("files") != "true" {
+		t.Errorf("query = %s", got.URL.RawQuery)
+	}
+	if got.Header.Get("Authorization") != "Bearer s3cret" {
+		t.Errorf("authorization = %q", got.Header.Get("Authorization"))
+	}
+	if !strings.Contains(out, "[api] upstream timeout") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestRunQuery_ReceiverError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	err := runQuery("", queryOpts{receiver: srv.URL, limit: 100, format: "json"})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want the receiver's 401", err)
+	}
+}
+
+func TestQueryCmd_LiveFlagsNeedLive(t *testing.T) {
+	cmd := newQueryCmd()
+	cmd.SetArgs([]string{"--label", "app=api", "error"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--live") {
+		t.Errorf("err = %v, want --label to need --live", err)
+	}
+}
+
+func TestRunCaptureQuery(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "web"}, Message: `{"status":200}`},
+		{Timestamp: base.Add(10 * time.Second), Labels: map[string]string{"app": "web"}, Message: `{"status":503}`},
+		{Timestamp: base.Add(70 * time.Second), Labels: map[string]string{"app": "web"}, Message: `{"status":500}`},
+		{Timestamp: base.Add(80 * time.Second), Labels: map[string]string{"app": "api"}, Message: "error: boom"},
+	})
+
+	out := captureStdout(t, func() {
+		if err := runCaptureQuery(dir, `{app="web"} | json | status >= 500`, "", "", "", 0, "json"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"status":"503"`) {
+		t.Errorf("log query output = %q, want two entries with extracted status", out)
+	}
+
+	out = captureStdout(t, func() {
+		if err := runCaptureQuery(dir, `sum by (app) (count_over_time({app="web"}[1m]))`, "", "", "", 0, "json"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	want := `{"metric":{"app":"web"},"values":[[1705312800,"1"],[1705312860,"1"],[1705312920,"1"]]}`
+	if strings.TrimSpace(out) != want {
+		t.Errorf("metric query output = %s, want %s", out, want)
+	}
+
+	out = captureStdout(t, func() {
+		if err := runCaptureQuery(dir, `count_over_time({app="api"}[1m])`, "", "", "", 0, "text"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.HasPrefix(out, `{app="api"}`) || !strings.Contains(out, "2024-01-15T10:02:00Z  1") {
+		t.Errorf("metric text output = %q", out)
+	}
+
+	for _, tt := range []struct{ query, step, want string }{
+		{`{app="web"} | line_format "{{.status}}"`, "", "not supported"},
+		{`{app="web"}`, "1m", "metric queries"},
+		{`rate({}[1m])`, "soon", "invalid --step"},
+	} {
+		err := runCaptureQuery(dir, tt.query, "", "", tt.step, 0, "json")
+		if cli.ExitCode(err) != cli.ExitUsage || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want usage error containing %q", tt.query, err, tt.want)
+		}
+	}
+}
+
+func TestCaptureFileSearch(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeCaptureDir(t, []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "upstream timeout"},
+		{Timestamp: base.Add(time.Second), Labels: map[string]string{"app": "web"}, Message: "upstream timeout"},
+		{Timestamp: base.Add(2 * time.Second), Labels: map[string]string{"app": "api"}, Message: "ok"},
+	})
+
+	q := &recv.LiveQuery{Labels: map[string]string{"app": "api"}}
+	var got []string
+	if err := captureFileSearch(dir, nil)(q, func(e recv.LogEntry) { got = append(got, e.Message) }); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "upstream timeout,ok" {
+		t.Errorf("matches = %v", got)
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -15,39 +18,31 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cloud"
 	"github.com/ppiankov/logtap/internal/k8s"
+	"github.com/ppiankov/logtap/internal/logtypes"
 	"github.com/ppiankov/logtap/internal/recv"
 	"github.com/ppiankov/logtap/internal/rotate"
 )
 
 func newRecvCmd() *cobra.Command {
 	var (
-		listen         string
-		dir            string
-		maxFileStr     string
-		maxDiskStr     string
-		compress       bool
-		redactFlag     string
-		redactPatterns string
-		bufSize        int
-		headless       bool
-		tlsCert        string
-		tlsKey         string
-		inCluster      bool
-		image          string
-		namespace      string
-		ttlStr         string
-		webhookURLs    []string
-		webhookEvents  string
-		webhookAuth    string
-		alertRulesPath string
+		opts      recvOpts
+		inCluster bool
+		image     string
+		namespace string
+		ttlStr    string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "recv",
 		Short: "Start the log receiver",
-		Long:  "Accept Loki push API payloads, optionally redact PII, write compressed JSONL to disk.",
+		Long:  "Accept Loki push API, OTLP logs, Elasticsearch _bulk, syslog, and the Fluentd forward protocol, consume Kafka topics, optionally redact PII, write compressed JSONL to disk.",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			applyConfigDefaults(cmd)
 			return nil
@@ -68,49 +63,299 @@ func newRecvCmd() *cobra.Command {
 				return runRecvInCluster(inClusterOpts{
 					image:      image,
 					namespace:  namespace,
-					maxFile:    maxFileStr,
-					maxDisk:    maxDiskStr,
-					compress:   compress,
-					redact:     redactFlag,
+					maxFile:    opts.maxFile,
+					maxDisk:    opts.maxDisk,
+					compress:   opts.compress,
+					redact:     opts.redact,
+					sessions:   opts.sessions,
 					listenPort: 9000,
 					ttl:        ttl,
 				})
 			}
-			if dir == "" {
-				return fmt.Errorf("--dir is required (or use --in-cluster)")
+			if opts.memory {
+				return runRecvMemory(opts)
 			}
-			return runRecv(listen, dir, maxFileStr, maxDiskStr, compress, redactFlag, redactPatterns, bufSize, headless, tlsCert, tlsKey, webhookURLs, webhookEvents, webhookAuth, alertRulesPath)
+			if opts.dir == "" {
+				return fmt.Errorf("--dir is required (or use --in-cluster or --memory)")
+			}
+			return runRecv(opts)
 		},
 	}
 
-	cmd.Flags().StringVar(&listen, "listen", "127.0.0.1:3100", "address to listen on")
-	cmd.Flags().StringVar(&dir, "dir", "", "output directory (required)")
-	cmd.Flags().StringVar(&maxFileStr, "max-file", "256MB", "max file size before rotation")
-	cmd.Flags().StringVar(&maxDiskStr, "max-disk", "50GB", "max total disk usage")
-	cmd.Flags().BoolVar(&compress, "compress", true, "zstd compress rotated files")
-	cmd.Flags().StringVar(&redactFlag, "redact", "", "enable PII redaction (true or comma-separated pattern names)")
-	cmd.Flags().StringVar(&redactPatterns, "redact-patterns", "", "path to custom redaction patterns YAML file")
-	cmd.Flags().IntVar(&bufSize, "buffer", 65536, "internal channel buffer size")
-	cmd.Flags().BoolVar(&headless, "headless", false, "disable TUI, log to stderr")
-	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file")
-	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "TLS key file")
+	cmd.Flags().StringVar(&opts.listen, "listen", "127.0.0.1:3100", "address to listen on")
+	cmd.Flags().StringVar(&opts.syslogListen, "syslog", "", "also accept syslog (RFC 5424/3164) over TCP and UDP on this address (e.g. :5514)")
+	cmd.Flags().StringVar(&opts.forwardListen, "forward", "", "also accept the Fluentd forward protocol over TCP on this address (e.g. :24224)")
+	cmd.Flags().StringVar(&opts.forwardSharedKey, "forward-shared-key", "", "require forward clients to authenticate with this shared key")
+	cmd.Flags().StringSliceVar(&opts.kafkaBrokers, "kafka-brokers", nil, "also consume log records from Kafka via these bootstrap brokers (host:port, comma-separated)")
+	cmd.Flags().StringSliceVar(&opts.kafkaTopics, "kafka-topics", nil, "Kafka topics to consume (comma-separated; requires --kafka-brokers)")
+	cmd.Flags().StringVar(&opts.kafkaGroup, "kafka-group", "logtap", "consumer group Kafka offsets are committed to (empty disables commits)")
+	cmd.Flags().StringVar(&opts.kafkaStart, "kafka-start", recv.KafkaStartLatest, "where to start partitions without a committed offset: latest or earliest")
+	cmd.Flags().StringVar(&opts.dir, "dir", "", "output directory (required); a comma-separated list shards streams across the directories by label hash")
+	cmd.Flags().StringVar(&opts.otlpGRPCListen, "otlp-grpc-listen", "", "also accept OTLP/gRPC logs and the forwarder push stream on this address (e.g. 127.0.0.1:4317); OTLP/HTTP is always served on /v1/logs")
+	cmd.Flags().StringVar(&opts.maxFile, "max-file", "256MB", "max file size before rotation")
+	cmd.Flags().StringVar(&opts.maxDisk, "max-disk", "50GB", "max total disk usage")
+	cmd.Flags().StringVar(&opts.partitionBy, "partition-by", "", "write a separate file series per value of this label (e.g. app), so label filters skip other values' files")
+	cmd.Flags().StringSliceVar(&opts.indexFields, "index-fields", nil, "index these fields of JSON messages (e.g. trace_id,request_id,status) per data file, so grep, slice and export --field skip files without the value")
+	cmd.Flags().BoolVar(&opts.grepBloom, "grep-bloom", false, "write a bloom filter of message tokens per data file, so grep skips files that cannot contain a literal the pattern requires")
+	cmd.Flags().DurationVar(&opts.dedupWindow, "dedup-window", 0, "collapse identical lines (same labels and message) arriving within this window into one entry with a repeat_count (0 = off)")
+	cmd.Flags().DurationVar(&opts.fsyncInterval, "fsync-interval", 0, "sync written lines to disk at this interval, so a host crash loses at most that much (0 = leave it to the OS)")
+	cmd.Flags().StringVar(&opts.traceEndpoint, "trace-endpoint", "", "export OpenTelemetry spans of push requests (decode, redact, enqueue, write) to this OTLP/HTTP endpoint, e.g. http://otel-collector:4318")
+	cmd.Flags().Float64Var(&opts.traceSampleRatio, "trace-sample-ratio", recv.DefaultTraceSampleRatio, "share of pushes traced when the client sent no sampled traceparent (0-1)")
+	cmd.Flags().BoolVar(&opts.durable, "durable", false, "sync written lines to disk after each batch, before more are taken from the queue, and sync rotated files and the index (slower)")
+	cmd.Flags().DurationVar(&opts.rotateEvery, "rotate-every", 0, "also rotate files at each multiple of this interval (e.g. 5m), so index entries have predictable time boundaries (0 = by size only)")
+	cmd.Flags().StringVar(&opts.description, "description", "", "what the capture is recorded for, stored in metadata.json and shown by inspect and catalog")
+	cmd.Flags().StringVar(&opts.owner, "owner", "", "who to ask about the capture, e.g. a team or email")
+	cmd.Flags().StringArrayVar(&opts.meta, "meta", nil, "extra context stored in metadata.json (key=value, repeatable), e.g. test-run=1234")
+	cmd.Flags().BoolVar(&opts.sessions, "sessions", false, "host one capture per session under --dir, keyed by the session label or X-Logtap-Session header; --max-disk applies to each")
+	cmd.Flags().StringVar(&opts.shard, "shard", "", "run as receiver N of M (e.g. 2/3), storing the streams whose label hash maps to it")
+	cmd.Flags().StringSliceVar(&opts.shardPeers, "shard-peers", nil, "with --shard, the URLs of all M receivers in shard order; pushes for streams owned by another shard are passed on to it")
+	cmd.Flags().BoolVar(&opts.memory, "memory", false, "keep received entries in memory instead of writing a capture, for integration tests; read them with logtap query --live")
+	cmd.Flags().IntVar(&opts.memoryEntries, "memory-entries", recv.DefaultMemoryEntries, "with --memory, the newest entries kept")
+	cmd.Flags().IntVar(&opts.maxSessions, "max-sessions", rotate.DefaultMaxSessions, "with --sessions, the most sessions hosted at once; further sessions go to the default capture")
+	cmd.Flags().BoolVar(&opts.compress, "compress", true, "zstd compress rotated files")
+	cmd.Flags().StringVar(&opts.redact, "redact", "", "enable PII redaction (true or comma-separated pattern names)")
+	cmd.Flags().StringVar(&opts.redactPatterns, "redact-patterns", "", "path to custom redaction patterns YAML file")
+	cmd.Flags().IntVar(&opts.bufSize, "buffer", 65536, "internal channel buffer size")
+	cmd.Flags().BoolVar(&opts.headless, "headless", false, "disable TUI, log to stderr")
+	cmd.Flags().StringVar(&opts.tlsCert, "tls-cert", "", "TLS certificate file")
+	cmd.Flags().StringVar(&opts.tlsKey, "tls-key", "", "TLS key file")
+	cmd.Flags().BoolVar(&opts.tlsSelfSigned, "tls-self-signed", false, "serve TLS with a generated self-signed certificate, kept in --tls-dir and reused across restarts; its SHA-256 fingerprint is printed for tap --tls-pin")
+	cmd.Flags().StringSliceVar(&opts.tlsACMEDomains, "tls-acme-domain", nil, "obtain and renew a Let's Encrypt certificate for this domain (repeatable); the receiver must be reachable on port 443 under it")
+	cmd.Flags().StringVar(&opts.tlsACMEEmail, "tls-acme-email", "", "contact email for the Let's Encrypt account, used for expiry notices")
+	cmd.Flags().StringVar(&opts.tlsDir, "tls-dir", "", "where --tls-self-signed and --tls-acme-domain keep certificates and keys (default ~/.logtap/tls)")
 	cmd.Flags().BoolVar(&inCluster, "in-cluster", false, "deploy receiver as in-cluster pod")
 	cmd.Flags().StringVar(&image, "image", "", "container image for in-cluster receiver (required with --in-cluster)")
 	cmd.Flags().StringVarP(&namespace, "namespace", "n", "logtap", "namespace for in-cluster resources")
 	cmd.Flags().StringVar(&ttlStr, "ttl", "4h", "receiver pod TTL for in-cluster mode (e.g. 4h, 30m)")
-	cmd.Flags().StringSliceVar(&webhookURLs, "webhook", nil, "webhook URLs to notify on lifecycle events (repeatable)")
-	cmd.Flags().StringVar(&webhookEvents, "webhook-events", "", "comma-separated event filter (start,stop,rotation,error,disk-warning)")
-	cmd.Flags().StringVar(&webhookAuth, "webhook-auth", "", "webhook auth (bearer:<token> or hmac-sha256:<secret>)")
-	cmd.Flags().StringVar(&alertRulesPath, "alert-rules", "", "path to alert rules YAML file")
+	cmd.Flags().StringSliceVar(&opts.webhookURLs, "webhook", nil, "webhook URLs to notify on lifecycle events (repeatable)")
+	cmd.Flags().StringVar(&opts.webhookEvents, "webhook-events", "", "comma-separated event filter ("+strings.Join(recv.WebhookEvents, ",")+")")
+	cmd.Flags().StringVar(&opts.webhookAuth, "webhook-auth", "", "webhook auth (bearer:<token> or hmac-sha256:<secret>)")
+	cmd.Flags().StringVar(&opts.alertRules, "alert-rules", "", "path to alert rules YAML file")
+	cmd.Flags().StringVar(&opts.replay, "replay", "", "feed an existing capture through the ingest pipeline instead of listening")
+	cmd.Flags().StringVar(&opts.replaySpeed, "replay-speed", "0", "replay speed: 0=instant, 1=realtime, 10=fast-forward (or 10x)")
+	cmd.Flags().BoolVar(&opts.detectDups, "detect-duplicates", false, "warn when two tap sessions push identical streams (same pod tapped twice)")
+	cmd.Flags().StringArrayVar(&opts.auditSinks, "audit-sink", nil, "also ship audit records to a remote sink as they are written: https://..., syslog://host:514, syslog+tcp://host:601 (repeatable)")
+	cmd.Flags().StringVar(&opts.auditSinkAuth, "audit-sink-auth", "", "auth for https audit sinks (bearer:<token> or hmac-sha256:<secret>)")
+	cmd.Flags().BoolVar(&opts.tsFallback, "timestamp-fallback", false, "replace zero or implausible entry timestamps with one parsed from the message, else arrival time (recorded in the ts_source label)")
+	cmd.Flags().StringArrayVar(&opts.tsLayouts, "timestamp-layout", nil, "extra Go time layout to look for in messages, tried before the built-in ones; implies --timestamp-fallback (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.processors, "processor", nil, "write path processor name[:arg], applied in order after redaction (repeatable; e.g. exec:/usr/local/bin/scrub, label:env=load)")
+	cmd.Flags().StringVar(&opts.authToken, "auth-token", "", "require this bearer token (or a session token derived from it by logtap tap --auth-token) on push endpoints; cannot be combined with --syslog, needs --forward-shared-key with --forward, and does not cover --kafka-brokers, which is secured by the brokers")
+	cmd.Flags().StringSliceVar(&opts.trustedProxies, "trusted-proxy", nil, "CIDR or IP of an Ingress/load balancer whose X-Forwarded-For/Proto headers identify the client in audit records (repeatable)")
+	cmd.Flags().StringVar(&opts.sink, "sink", "", "copy each rotated segment to object storage (s3://bucket/prefix or gs://bucket/prefix); --max-disk then removes uploaded segments first and keeps them in the index")
+	cmd.Flags().DurationVar(&opts.shutdownTimeout, "shutdown-timeout", 0, "on stop, time allowed to finish requests and then again to flush queued lines; lines still queued are dropped, recorded in metadata, and recv exits non-zero (0 = flush every queued line)")
+	cmd.Flags().DurationVar(&opts.streamIdleTTL, "stream-idle-ttl", time.Hour, "forget streams without entries for this long, keeping their last-seen watermark in metadata (0 keeps every stream)")
+	cmd.Flags().IntVar(&opts.maxLabels, "max-labels", 0, "refuse pushes with a stream of more labels than this with 400 (0 = unlimited)")
+	cmd.Flags().IntVar(&opts.maxLabelValue, "max-label-value-bytes", 0, "refuse pushes with a label value longer than this with 400 (0 = unlimited)")
+	cmd.Flags().StringVar(&opts.sample, "sample", "", "store only a percentage of entries: default=<pct> and per-label key=value=<pct> overrides (e.g. default=100%,app=ingress-nginx=10%)")
 
 	return cmd
 }
 
 const maxBufSize = 1 << 20 // 1,048,576
 
-func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFlag, redactPatterns string, bufSize int, headless bool, tlsCert, tlsKey string, webhookURLs []string, webhookEvents, webhookAuth string, alertRulesPath string) error {
+// defaultShutdownTimeout bounds finishing in-flight requests on stop when
+// --shutdown-timeout is unset.
+const defaultShutdownTimeout = 5 * time.Second
+
+// captureStore is where the writer's lines land: a capture, possibly
+// sharded across disks, or one capture per session.
+type captureStore interface {
+	recv.LabeledWriter
+	SetOnRotate(fn func(reason string))
+	SetOnError(fn func())
+	SetOnDiskWarning(fn func(usage, cap int64))
+	SetOnUpload(fn func(name string, size int64, err error))
+	DiskUsage() int64
+	Stats() []rotate.Stats
+	Sync() error
+	Close() error
+}
+
+// sessionMetadata derives the metadata of one hosted session from the
+// receiver's.
+func sessionMetadata(meta *recv.Metadata, name string, started time.Time) *recv.Metadata {
+	return &recv.Metadata{
+		Version:     meta.Version,
+		Features:    meta.Features,
+		Format:      meta.Format,
+		Started:     started,
+		Redaction:   meta.Redaction,
+		ReplayOf:    meta.ReplayOf,
+		Processors:  meta.Processors,
+		Sampling:    meta.Sampling,
+		Shard:       meta.Shard,
+		PartitionBy: meta.PartitionBy,
+		IndexFields: meta.IndexFields,
+		GrepBloom:   meta.GrepBloom,
+		Session:     name,
+		Description: meta.Description,
+		Owner:       meta.Owner,
+		Meta:        meta.Meta,
+	}
+}
+
+// captureInfo returns the capture info given by --description, --owner and
+// --meta.
+func (o recvOpts) captureInfo() (recv.CaptureInfo, error) {
+	pairs, err := recv.ParseCaptureMeta(o.meta)
+	if err != nil {
+		return recv.CaptureInfo{}, fmt.Errorf("invalid --meta: %w", err)
+	}
+	var info recv.CaptureInfo
+	if err := info.Merge(recv.CaptureInfo{Description: o.description, Owner: o.owner, Meta: pairs}); err != nil {
+		return recv.CaptureInfo{}, err
+	}
+	return info, nil
+}
+
+// captureFileSearch searches the files of the capture being written for
+// live queries; with sessions, those of the queried session or of all.
+func captureFileSearch(dir string, sessions *rotate.Sessions) recv.FileSearch {
+	return func(q *recv.LiveQuery, fn func(recv.LogEntry)) error {
+		dirs := []string{dir}
+		if sessions != nil {
+			dirs = dirs[:0]
+			want, byName := q.Labels["session"]
+			for _, st := range sessions.Sessions() {
+				if !byName || st.Name == rotate.SessionName(want) {
+					dirs = append(dirs, st.Dir)
+				}
+			}
+		}
+		filter := &archive.Filter{From: q.From, To: q.To, Grep: q.Grep}
+		for k, v := range q.Labels {
+			filter.Labels = append(filter.Labels, archive.LabelMatcher{Key: k, Value: v})
+		}
+		for _, d := range dirs {
+			reader, err := archive.NewReader(d)
+			if err != nil {
+				return fmt.Errorf("open capture: %w", err)
+			}
+			if _, err := reader.Scan(filter, func(e recv.LogEntry) bool {
+				fn(e)
+				return true
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// recvOpts holds the parsed flags for a local receiver.
+type recvOpts struct {
+	listen           string
+	otlpGRPCListen   string
+	syslogListen     string
+	forwardListen    string
+	forwardSharedKey string
+	kafkaBrokers     []string
+	kafkaTopics      []string
+	kafkaGroup       string
+	kafkaStart       string
+	dir              string
+	maxFile          string
+	rotateEvery      time.Duration
+	partitionBy      string // label key with one file series per value
+	indexFields      []string
+	grepBloom        bool
+	dedupWindow      time.Duration
+	fsyncInterval    time.Duration
+	durable          bool
+	traceEndpoint    string
+	traceSampleRatio float64
+	description      string
+	owner            string
+	meta             []string // key=value capture context
+	maxDisk          string
+	sessions         bool // one capture per session under dir
+	maxSessions      int
+	shard            string   // N/M of a horizontally scaled receiver set
+	shardPeers       []string // receiver URLs in shard order
+	compress         bool
+	redact           string
+	redactPatterns   string
+	bufSize          int
+	headless         bool
+	tlsCert          string
+	tlsKey           string
+	tlsSelfSigned    bool     // generate a certificate instead of --tls-cert/--tls-key
+	tlsACMEDomains   []string // obtain certificates from Let's Encrypt
+	tlsACMEEmail     string
+	tlsDir           string // self-signed pair and ACME cache
+	webhookURLs      []string
+	webhookEvents    string
+	webhookAuth      string
+	alertRules       string
+	replay           string // capture directory to replay instead of listening
+	replaySpeed      string
+	detectDups       bool
+	processors       []string // processor specs, name[:arg]
+	sample           string   // ingest sampling rules
+	trustedProxies   []string // CIDRs whose X-Forwarded-* headers are honored
+	authToken        string   // required on push endpoints when set
+	auditSinks       []string // remote audit sink URLs
+	auditSinkAuth    string
+	tsFallback       bool     // repair timestamps from message bodies
+	tsLayouts        []string // custom message timestamp layouts
+	streamIdleTTL    time.Duration
+	shutdownTimeout  time.Duration
+	maxLabels        int    // labels per pushed stream
+	maxLabelValue    int    // bytes per pushed label value
+	sink             string // object storage URL for rotated segments
+	memory           bool   // keep entries in a bounded ring instead of writing a capture
+	memoryEntries    int
+}
+
+func runRecv(opts recvOpts) error {
+	listen := opts.listen
+	dirs, err := parseShardDirs(opts.dir)
+	if err != nil {
+		return fmt.Errorf("invalid --dir: %w", err)
+	}
+	dir := dirs[0]
+	if opts.sessions && len(dirs) > 1 {
+		return fmt.Errorf("--sessions cannot be combined with a sharded --dir")
+	}
+	if opts.sink != "" && len(dirs) > 1 {
+		return fmt.Errorf("--sink cannot be combined with a sharded --dir")
+	}
+	var shardRouter *recv.ShardRouter
+	var shard recv.Shard
+	if opts.shard != "" {
+		if shard, err = recv.ParseShard(opts.shard); err != nil {
+			return fmt.Errorf("invalid --shard: %w", err)
+		}
+		if len(opts.shardPeers) > 0 {
+			if shardRouter, err = recv.NewShardRouter(shard, opts.shardPeers); err != nil {
+				return fmt.Errorf("invalid --shard-peers: %w", err)
+			}
+		}
+	} else if len(opts.shardPeers) > 0 {
+		return fmt.Errorf("--shard-peers requires --shard")
+	}
+	// --auth-token guards the HTTP and gRPC endpoints; refuse listeners
+	// that would take pushes around it
+	if opts.authToken != "" {
+		if opts.syslogListen != "" {
+			return fmt.Errorf("--syslog cannot be combined with --auth-token (syslog has no authentication)")
+		}
+		if opts.forwardListen != "" && opts.forwardSharedKey == "" {
+			return fmt.Errorf("--forward requires --forward-shared-key when --auth-token is set")
+		}
+	}
+	bufSize, headless := opts.bufSize, opts.headless
+	webhookURLs := opts.webhookURLs
+	var tlsCert, tlsKey string
+	var tlsConfig *tls.Config
+	if opts.replay == "" {
+		if tlsCert, tlsKey, tlsConfig, err = recvTLS(opts); err != nil {
+			return err
+		}
+	}
+
 	// Check for insecure direct IP mode without TLS
-	if tlsCert == "" && tlsKey == "" {
+	if opts.replay == "" && tlsCert == "" && tlsKey == "" && tlsConfig == nil {
 		host, _, err := net.SplitHostPort(listen)
 		if err != nil {
 			host = listen // Assume listen is just a host if split fails
@@ -124,14 +369,97 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 		return fmt.Errorf("--buffer %d exceeds maximum of %d", bufSize, maxBufSize)
 	}
 
-	maxFile, err := parseByteSize(maxFileStr)
+	maxFile, err := parseByteSize(opts.maxFile)
 	if err != nil {
 		return fmt.Errorf("invalid --max-file: %w", err)
 	}
-	maxDisk, err := parseByteSize(maxDiskStr)
+	maxDisk, err := parseByteSize(opts.maxDisk)
 	if err != nil {
 		return fmt.Errorf("invalid --max-disk: %w", err)
 	}
+	if opts.rotateEvery < 0 || (opts.rotateEvery > 0 && opts.rotateEvery < time.Second) {
+		return fmt.Errorf("--rotate-every must be at least 1s")
+	}
+	if opts.dedupWindow < 0 {
+		return fmt.Errorf("--dedup-window must not be negative")
+	}
+	if opts.shutdownTimeout < 0 {
+		return fmt.Errorf("--shutdown-timeout must not be negative")
+	}
+	if opts.maxLabels < 0 {
+		return fmt.Errorf("--max-labels must not be negative")
+	}
+	if opts.maxLabelValue < 0 {
+		return fmt.Errorf("--max-label-value-bytes must not be negative")
+	}
+	if opts.fsyncInterval < 0 {
+		return fmt.Errorf("--fsync-interval must not be negative")
+	}
+	if opts.traceSampleRatio < 0 || opts.traceSampleRatio > 1 {
+		return fmt.Errorf("--trace-sample-ratio must be between 0 and 1")
+	}
+	for _, f := range opts.indexFields {
+		if strings.TrimSpace(f) == "" || strings.Trim(f, ".") != f || strings.Contains(f, "..") {
+			return fmt.Errorf("invalid --index-fields entry %q", f)
+		}
+	}
+	if opts.traceEndpoint != "" {
+		if _, err := recv.NewOTLPTraceExporter(opts.traceEndpoint); err != nil {
+			return fmt.Errorf("invalid --trace-endpoint: %w", err)
+		}
+	}
+	captureInfo, err := opts.captureInfo()
+	if err != nil {
+		return err
+	}
+
+	// timestamp fallback — merge config layouts if CLI provided none
+	tsLayouts := opts.tsLayouts
+	if len(tsLayouts) == 0 && cfg != nil && len(cfg.Recv.TimestampLayouts) > 0 {
+		tsLayouts = cfg.Recv.TimestampLayouts
+	}
+	// Kafka source — config lists apply when the flags are not given
+	kafkaCfg := recv.KafkaConfig{
+		Brokers: opts.kafkaBrokers,
+		Topics:  opts.kafkaTopics,
+		Group:   opts.kafkaGroup,
+		Start:   opts.kafkaStart,
+	}
+	if len(kafkaCfg.Brokers) == 0 && cfg != nil {
+		kafkaCfg.Brokers = cfg.Recv.KafkaBrokers
+	}
+	if len(kafkaCfg.Topics) == 0 && cfg != nil {
+		kafkaCfg.Topics = cfg.Recv.KafkaTopics
+	}
+	if len(kafkaCfg.Brokers) > 0 != (len(kafkaCfg.Topics) > 0) {
+		return fmt.Errorf("--kafka-brokers and --kafka-topics must be used together")
+	}
+	if kafkaCfg.Start != recv.KafkaStartLatest && kafkaCfg.Start != recv.KafkaStartEarliest {
+		return fmt.Errorf("invalid --kafka-start %q (expected latest or earliest)", kafkaCfg.Start)
+	}
+
+	var tsResolver *recv.TimestampResolver
+	if opts.tsFallback || len(tsLayouts) > 0 {
+		tsResolver, err = recv.NewTimestampResolver(tsLayouts)
+		if err != nil {
+			return fmt.Errorf("invalid --timestamp-layout: %w", err)
+		}
+	}
+
+	// replay source is opened before anything is written so a bad path
+	// does not leave an empty capture behind
+	var replayReader *archive.Reader
+	var replaySpeed archive.Speed
+	if opts.replay != "" {
+		replaySpeed, err = parseSpeed(opts.replaySpeed)
+		if err != nil {
+			return fmt.Errorf("invalid --replay-speed: %w", err)
+		}
+		replayReader, err = archive.NewReader(opts.replay)
+		if err != nil {
+			return fmt.Errorf("open replay capture: %w", err)
+		}
+	}
 
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("create output dir: %w", err)
@@ -139,22 +467,32 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 
 	// metadata
 	meta := &recv.Metadata{
-		Version: 1,
-		Format:  "jsonl",
-		Started: time.Now(),
+		Version:     recv.MetadataVersion,
+		Format:      "jsonl",
+		Started:     time.Now(),
+		ReplayOf:    opts.replay,
+		Shards:      dirs[1:],
+		PartitionBy: opts.partitionBy,
+		IndexFields: opts.indexFields,
+		GrepBloom:   opts.grepBloom,
+	}
+	meta.AddFeature(recv.FeatureChecksums)
+	meta.SetCaptureInfo(captureInfo)
+	if opts.shard != "" {
+		meta.Shard = shard.String()
 	}
 
 	// redactor
 	var redactor *recv.Redactor
 	var redactInfo string
-	redactEnabled, redactNames := recv.ParseRedactFlag(redactFlag)
+	redactEnabled, redactNames := recv.ParseRedactFlag(opts.redact)
 	if redactEnabled {
 		redactor, err = recv.NewRedactor(redactNames)
 		if err != nil {
 			return fmt.Errorf("init redactor: %w", err)
 		}
-		if redactPatterns != "" {
-			if err := redactor.LoadCustomPatterns(redactPatterns); err != nil {
+		if opts.redactPatterns != "" {
+			if err := redactor.LoadCustomPatterns(opts.redactPatterns); err != nil {
 				return fmt.Errorf("load custom patterns: %w", err)
 			}
 		}
@@ -165,15 +503,39 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 		redactInfo = fmt.Sprintf("on (%d patterns)", len(redactor.PatternNames()))
 	}
 
-	// rotator
-	rot, err := rotate.New(rotate.Config{
-		Dir:      dir,
-		MaxFile:  maxFile,
-		MaxDisk:  maxDisk,
-		Compress: compress,
-	})
-	if err != nil {
-		return fmt.Errorf("init rotator: %w", err)
+	// rotator — one per shard, or one per session with --sessions
+	rotCfg := rotate.Config{
+		MaxFile:     maxFile,
+		MaxDisk:     maxDisk,
+		Compress:    opts.compress,
+		RotateEvery: opts.rotateEvery,
+		PartitionBy: opts.partitionBy,
+		IndexFields: opts.indexFields,
+		GrepBloom:   opts.grepBloom,
+		Durable:     opts.durable || opts.fsyncInterval > 0,
+	}
+	if opts.sink != "" {
+		if rotCfg.Sink, err = newSegmentSink(opts.sink, dir); err != nil {
+			return fmt.Errorf("invalid --sink: %w", err)
+		}
+		meta.Sink = opts.sink
+	}
+	var rot captureStore
+	var sessions *rotate.Sessions
+	if opts.sessions {
+		sessions = rotate.NewSessions(rotCfg, dir)
+		sessions.SetMaxSessions(opts.maxSessions)
+		rot = sessions
+	} else {
+		sharded, err := rotate.NewSharded(rotCfg, dirs)
+		if err != nil {
+			return fmt.Errorf("init rotator: %w", err)
+		}
+		if recovered := sharded.Recovered(); len(recovered) > 0 {
+			fmt.Fprintf(os.Stderr, "recovered %d file(s) left by an unclean shutdown: %s\n", len(recovered), strings.Join(recovered, ", "))
+			meta.Recovered = recovered
+		}
+		rot = sharded
 	}
 
 	// webhook dispatcher — merge config URLs if CLI provided none
@@ -181,14 +543,28 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 		webhookURLs = cfg.Recv.Webhooks
 	}
 	var eventFilter []string
-	if webhookEvents != "" {
-		eventFilter = strings.Split(webhookEvents, ",")
+	if opts.webhookEvents != "" {
+		eventFilter = strings.Split(opts.webhookEvents, ",")
 	}
-	dispatcher, err := recv.NewWebhookDispatcher(webhookURLs, eventFilter, webhookAuth)
+	dispatcher, err := recv.NewWebhookDispatcher(webhookURLs, eventFilter, opts.webhookAuth)
 	if err != nil {
 		return fmt.Errorf("invalid --webhook-auth: %w", err)
 	}
 
+	// remote audit sinks — merge config sinks if CLI provided none
+	auditSinkURLs := opts.auditSinks
+	if len(auditSinkURLs) == 0 && cfg != nil && len(cfg.Recv.AuditSinks) > 0 {
+		auditSinkURLs = cfg.Recv.AuditSinks
+	}
+	auditSinks := make([]recv.AuditSink, 0, len(auditSinkURLs))
+	for _, spec := range auditSinkURLs {
+		sink, err := recv.NewAuditSink(spec, opts.auditSinkAuth)
+		if err != nil {
+			return fmt.Errorf("invalid --audit-sink: %w", err)
+		}
+		auditSinks = append(auditSinks, sink)
+	}
+
 	// metrics
 	reg := prometheus.DefaultRegisterer
 	metrics := recv.NewMetrics(reg)
@@ -201,8 +577,20 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 	}
 
 	// writer
-	writer := recv.NewWriter(bufSize, rot, rot.TrackLine)
+	writer := recv.NewLabeledWriter(bufSize, rot)
 	writer.SetQueueGauge(func(v float64) { metrics.WriterQueueLength.Set(v) })
+	writer.SetLatencyObserver(metrics.ObserveIngestLatency)
+	writer.SetDedup(opts.dedupWindow, func(n int64) { metrics.LogsDeduplicated.Add(float64(n)) })
+	if err := writer.SetSync(opts.fsyncInterval, opts.durable, func(took time.Duration, err error) {
+		metrics.FsyncDuration.Observe(took.Seconds())
+		if err != nil {
+			metrics.FsyncErrors.Inc()
+		}
+	}); err != nil {
+		writer.Close()
+		_ = rot.Close()
+		return err
+	}
 
 	// rotation metrics + webhook notifications
 	rot.SetOnRotate(func(reason string) {
@@ -213,6 +601,15 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 		metrics.RotationErrors.Inc()
 		dispatcher.Fire(recv.WebhookEvent{Event: "error"})
 	})
+	rot.SetOnUpload(func(name string, size int64, err error) {
+		if err != nil {
+			metrics.SinkUploads.WithLabelValues("error").Inc()
+			dispatcher.Fire(recv.WebhookEvent{Event: "error", Detail: "sink: " + err.Error()})
+			return
+		}
+		metrics.SinkUploads.WithLabelValues("ok").Inc()
+		metrics.SinkUploadBytes.Add(float64(size))
+	})
 	rot.SetOnDiskWarning(func(usage, cap int64) {
 		dispatcher.Fire(recv.WebhookEvent{
 			Event: "disk-warning",
@@ -224,56 +621,301 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 	// stats and ring (needed by both TUI and server hooks)
 	stats := recv.NewStats()
 	ring := recv.NewLogRing(0)
+	dispatcher.SetOnDeliver(func(d recv.WebhookDelivery) {
+		stats.RecordActivity(d.Activity())
+	})
 
 	// alert engine
 	var alertEngine *recv.AlertEngine
-	if alertRulesPath != "" {
-		alertRules, err := recv.LoadAlertRules(alertRulesPath)
+	if opts.alertRules != "" {
+		alertRules, err := recv.LoadAlertRules(opts.alertRules)
 		if err != nil {
 			return fmt.Errorf("load alert rules: %w", err)
 		}
 		alertEngine = recv.NewAlertEngine(alertRules, dispatcher)
+		alertEngine.SetOnChange(func(rule recv.AlertRule, value float64, firing bool) {
+			stats.RecordActivity(recv.AlertActivity(rule, value, firing))
+		})
+		if err := alertEngine.SetSilenceFile(filepath.Join(dir, recv.AlertSilencesFile)); err != nil {
+			return err
+		}
+	}
+
+	trusted, err := recv.ParseTrustedProxies(opts.trustedProxies)
+	if err != nil {
+		return fmt.Errorf("invalid --trusted-proxy: %w", err)
+	}
+
+	// ingest sampling
+	var sampler *recv.Sampler
+	if opts.sample != "" {
+		sampler, err = recv.ParseSampler(opts.sample)
+		if err != nil {
+			return fmt.Errorf("invalid --sample: %w", err)
+		}
+		sampler.SetOnSample(func(rule string) {
+			metrics.LogsSampled.WithLabelValues(rule).Inc()
+			stats.RecordSampled()
+		})
+		meta.Sampling = sampler.Info()
+	}
+
+	// write path processors
+	var processors *recv.ProcessorChain
+	if len(opts.processors) > 0 {
+		processors, err = recv.NewProcessorChain(opts.processors)
+		if err != nil {
+			return fmt.Errorf("init processors: %w", err)
+		}
+		processors.SetOnDrop(func(name string) {
+			metrics.ProcessorDropped.WithLabelValues(name).Inc()
+		})
+		processors.SetOnError(func(name string, err error) {
+			metrics.ProcessorErrors.WithLabelValues(name).Inc()
+			detail := fmt.Sprintf("processor %s failed, passing entries through: %v", name, err)
+			if headless {
+				fmt.Fprintf(os.Stderr, "WARNING: %s\n", detail)
+			}
+			dispatcher.Fire(recv.WebhookEvent{Event: "error", Dir: dir, Detail: detail})
+		})
+		meta.Processors = processors.Names()
 	}
 
 	// write initial metadata
 	if err := recv.WriteMetadata(dir, meta); err != nil {
+		if processors != nil {
+			_ = processors.Close()
+		}
 		return fmt.Errorf("write metadata: %w", err)
 	}
 
+	// mark the capture live so gc leaves it alone
+	lock, err := recv.AcquireLock(dir)
+	if err != nil {
+		if processors != nil {
+			_ = processors.Close()
+		}
+		return err
+	}
+	defer func() { _ = lock.Release() }()
+
 	// audit logger
 	audit, err := recv.NewAuditLogger(dir)
 	if err != nil {
+		if processors != nil {
+			_ = processors.Close()
+		}
 		return fmt.Errorf("init audit logger: %w", err)
 	}
+	for _, sink := range auditSinks {
+		audit.AddSink(sink, func(name string, err error) {
+			detail := fmt.Sprintf("audit sink %s: %v", name, err)
+			if headless {
+				fmt.Fprintf(os.Stderr, "WARNING: %s\n", detail)
+			}
+			dispatcher.Fire(recv.WebhookEvent{Event: "error", Dir: dir, Detail: detail})
+		})
+	}
 
 	// server
 	srv := recv.NewServer(listen, writer, redactor, metrics, stats, ring)
 	srv.SetVersion(version)
 	srv.SetAuditLogger(audit)
+	var tracer *sdktrace.TracerProvider
+	if opts.traceEndpoint != "" {
+		tracer, err = recv.NewTracerProvider(opts.traceEndpoint, opts.traceSampleRatio, version)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: tracing disabled: %v\n", err)
+		} else {
+			srv.SetTracerProvider(tracer)
+		}
+	}
+	srv.SetTrustedProxies(trusted)
+	srv.SetPushAuth(recv.NewPushAuth(opts.authToken))
+	if shardRouter != nil {
+		srv.SetShardRouter(shardRouter)
+	}
+	srv.SetFileSearch(captureFileSearch(dir, sessions))
+	if sessions != nil {
+		sessions.SetOnOpen(func(name, sdir string) {
+			if err := recv.WriteMetadata(sdir, sessionMetadata(meta, name, time.Now())); err != nil {
+				fmt.Fprintf(os.Stderr, "write session metadata: %v\n", err)
+			}
+			audit.Log(recv.AuditEntry{Event: "session_opened", Detail: name})
+		})
+	}
+	audit.SetOnLog(func(e recv.AuditEntry) {
+		if a, ok := recv.AuditActivity(e); ok {
+			stats.RecordActivity(a)
+		}
+	})
+	if processors != nil {
+		srv.SetProcessors(processors)
+	}
+	if sampler != nil {
+		srv.SetSampler(sampler)
+	}
+	srv.SetLabelLimits(recv.LabelLimits{MaxLabels: opts.maxLabels, MaxValueBytes: opts.maxLabelValue})
+	if tsResolver != nil {
+		tsResolver.SetOnResolve(func(source string) {
+			metrics.TimestampFallback.WithLabelValues(source).Inc()
+		})
+		srv.SetTimestampResolver(tsResolver)
+	}
+	if opts.detectDups {
+		srv.SetDupDetector(recv.NewDupDetector(0, 0, func(d recv.DuplicateStream) {
+			stats.RecordDuplicate(d)
+			detail := fmt.Sprintf("sessions %s and %s push the same stream (%s)", d.Sessions[0], d.Sessions[1], d.Stream)
+			if headless {
+				fmt.Fprintf(os.Stderr, "WARNING: %s\n", detail)
+			}
+			dispatcher.Fire(recv.WebhookEvent{Event: "duplicate-stream", Dir: dir, Detail: detail})
+		}))
+	}
+	// capture info updated through the API is stored right away, so the
+	// capture keeps it if the receiver does not shut down cleanly
+	srv.SetCaptureInfo(captureInfo, func(info recv.CaptureInfo) {
+		updated := *meta
+		updated.SetCaptureInfo(info)
+		if err := recv.WriteMetadata(dir, &updated); err != nil {
+			fmt.Fprintf(os.Stderr, "update metadata: %v\n", err)
+		}
+	})
+	srv.SetOnIncompatibleClient(func(c recv.ClientBuild) {
+		detail := fmt.Sprintf("%s (commit %s) pushes with protocol %d; this receiver supports %d to %d",
+			c.Header(), c.Commit, c.Protocol, logtypes.MinProtocolVersion, logtypes.ProtocolVersion)
+		if headless {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", detail)
+		}
+		dispatcher.Fire(recv.WebhookEvent{Event: "client-version", Dir: dir, Detail: detail})
+	})
+
+	// runtime diagnostics: SIGUSR1 or POST /admin/debug
+	debugger := recv.NewDebugger(dir, version, writer, ring, stats)
+	debugger.AddSection("rotator", func() any {
+		stats := rot.Stats()
+		if len(stats) == 1 {
+			return stats[0]
+		}
+		return stats
+	})
+	debugger.AddSection("config", func() any { return opts.debugConfig() })
+	srv.SetDebugger(debugger)
+	if alertEngine != nil {
+		srv.SetAlertEngine(alertEngine)
+	}
+	stopDebug := watchDebugSignal(debugger, audit, headless)
+
+	// idle stream expiry
+	var expiry *recv.StreamExpiry
+	stopExpiry := make(chan struct{})
+	if opts.streamIdleTTL > 0 {
+		expiry = recv.NewStreamExpiry(opts.streamIdleTTL, writer.Watermarks(), stats)
+		expiry.SetOnExpire(func(expired []recv.StreamWatermark, active int) {
+			metrics.StreamsExpired.Add(float64(len(expired)))
+			metrics.StreamsActive.Set(float64(active))
+		})
+		go expiry.Run(max(min(opts.streamIdleTTL/4, time.Minute), time.Second), stopExpiry)
+	}
 
 	audit.Log(recv.AuditEntry{Event: "server_started"})
 	dispatcher.Fire(recv.WebhookEvent{Event: "start", Dir: dir})
 
-	// shutdown performs graceful teardown of all components
-	shutdown := func() {
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// shutdown performs graceful teardown of all components. With
+	// --shutdown-timeout, in-flight requests and then the write queue each
+	// get that long; it fails when queued lines had to be dropped
+	var syslogLn *recv.SyslogListener
+	var forwardLn *recv.ForwardListener
+	var kafkaConsumer *recv.KafkaConsumer
+	shutdown := func() error {
+		requestTimeout := defaultShutdownTimeout
+		if opts.shutdownTimeout > 0 {
+			requestTimeout = opts.shutdownTimeout
+		}
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), requestTimeout)
 		defer shutdownCancel()
+		stopDebug()
+		close(stopExpiry)
+		if syslogLn != nil {
+			_ = syslogLn.Close()
+		}
+		if forwardLn != nil {
+			_ = forwardLn.Close()
+		}
+		if kafkaConsumer != nil {
+			_ = kafkaConsumer.Close()
+		}
 		_ = srv.Shutdown(shutdownCtx)
+		if processors != nil {
+			_ = processors.Close()
+		}
 
-		writer.Close()
+		// the queue gets its own budget, whatever the requests took
+		var drainDeadline time.Time
+		if opts.shutdownTimeout > 0 {
+			drainDeadline = time.Now().Add(opts.shutdownTimeout)
+		}
+		drain := writer.CloseBy(drainDeadline)
 		if err := rot.Close(); err != nil {
 			fmt.Fprintf(os.Stderr, "rotator close: %v\n", err)
 		}
+		if tracer != nil {
+			if err := tracer.Shutdown(shutdownCtx); err != nil {
+				fmt.Fprintf(os.Stderr, "tracing: %v\n", err)
+			}
+		}
 
 		meta.Stopped = time.Now()
 		meta.TotalLines = writer.LinesWritten()
 		meta.TotalBytes = writer.BytesWritten()
+		meta.Precision = writer.TimestampPrecision()
+		if sampler != nil {
+			meta.Sampling = sampler.Info()
+		}
+		if expiry != nil {
+			meta.Expired = expiry.Info()
+		}
+		meta.Clients = srv.Clients()
+		meta.Shutdown = &recv.ShutdownInfo{
+			Flushed: drain.Flushed,
+			Dropped: drain.Dropped,
+			Clean:   drain.Dropped == 0,
+		}
+		if opts.shutdownTimeout > 0 {
+			meta.Shutdown.Timeout = opts.shutdownTimeout.String()
+		}
+		meta.SetCaptureInfo(srv.CaptureInfo())
+		if opts.dedupWindow > 0 {
+			meta.Dedup = &recv.DedupInfo{Window: opts.dedupWindow.String(), Collapsed: writer.Deduplicated()}
+		}
+		if sessions != nil {
+			for _, st := range sessions.Sessions() {
+				smeta := sessionMetadata(meta, st.Name, st.Started)
+				smeta.Stopped = meta.Stopped
+				smeta.TotalLines = st.Lines
+				smeta.TotalBytes = st.Bytes
+				smeta.Precision = meta.Precision
+				if err := recv.WriteMetadata(st.Dir, smeta); err != nil {
+					fmt.Fprintf(os.Stderr, "update session metadata: %v\n", err)
+				}
+				meta.Sessions = append(meta.Sessions, st.Name)
+			}
+		}
 		if err := recv.WriteMetadata(dir, meta); err != nil {
 			fmt.Fprintf(os.Stderr, "update metadata: %v\n", err)
 		}
+		if err := lock.Release(); err != nil {
+			fmt.Fprintf(os.Stderr, "remove lock file: %v\n", err)
+		}
+		if rotCfg.Sink != nil {
+			storeMetadata(rotCfg.Sink, dir, meta.Sessions)
+		}
 
 		audit.Log(recv.AuditEntry{Event: "server_stopped"})
 		_ = audit.Close()
+		if n := audit.SinkDropped(); n > 0 {
+			fmt.Fprintf(os.Stderr, "WARNING: %d audit record(s) not delivered to remote sinks\n", n)
+		}
 
 		dispatcher.Fire(recv.WebhookEvent{
 			Event: "stop",
@@ -283,10 +925,15 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 				BytesWritten: writer.BytesWritten(),
 				DiskUsage:    rot.DiskUsage(),
 				DiskCap:      maxDisk,
+				Shutdown:     meta.Shutdown,
 			},
 		})
 
 		metrics.DiskUsage.Set(float64(rot.DiskUsage()))
+		if drain.Dropped > 0 {
+			return fmt.Errorf("shutdown: %d queued line(s) dropped after --shutdown-timeout %s (%d flushed)", drain.Dropped, opts.shutdownTimeout, drain.Flushed)
+		}
+		return nil
 	}
 
 	// alert evaluation loop
@@ -305,13 +952,63 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 		}()
 	}
 
+	if replayReader != nil {
+		feeder := startReplay(replayReader, replaySpeed, srv, writer, audit)
+		replayShutdown := func() error {
+			feeder.Stop()
+			audit.Log(recv.AuditEntry{Event: "replay_finished", Lines: int(feeder.LinesEmitted())})
+			return shutdown()
+		}
+		if headless {
+			return runReplayHeadless(opts.replay, dir, feeder, writer, replayShutdown)
+		}
+		return runTUI(stats, ring, rot, maxDisk, writer, alertEngine, "replay:"+opts.replay, dir, redactInfo, make(chan error), replayShutdown)
+	}
+
 	// start HTTP server in background
-	errCh := make(chan error, 1)
+	errCh := make(chan error, 2)
+	if opts.otlpGRPCListen != "" {
+		if err := startOTLPGRPC(srv, opts.otlpGRPCListen, tlsCert, tlsKey, tlsConfig, errCh); err != nil {
+			_ = shutdown()
+			return err
+		}
+	}
+	if opts.syslogListen != "" {
+		syslogLn, err = recv.ListenSyslog(opts.syslogListen, srv)
+		if err != nil {
+			_ = shutdown()
+			return fmt.Errorf("listen --syslog: %w", err)
+		}
+	}
+	if opts.forwardListen != "" {
+		forwardLn, err = recv.ListenForward(opts.forwardListen, opts.forwardSharedKey, srv)
+		if err != nil {
+			_ = shutdown()
+			return fmt.Errorf("listen --forward: %w", err)
+		}
+	}
+	if len(kafkaCfg.Brokers) > 0 {
+		kafkaCfg.OnError = func(err error) {
+			if headless {
+				fmt.Fprintf(os.Stderr, "WARNING: %v (retrying)\n", err)
+			}
+			dispatcher.Fire(recv.WebhookEvent{Event: "error", Dir: dir, Detail: err.Error()})
+		}
+		kafkaConsumer, err = recv.StartKafka(kafkaCfg, srv)
+		if err != nil {
+			_ = shutdown()
+			return err
+		}
+	}
 	go func() {
 		var srvErr error
-		if tlsCert != "" && tlsKey != "" {
+		switch {
+		case tlsCert != "" && tlsKey != "":
 			srvErr = srv.ListenAndServeTLS(tlsCert, tlsKey)
-		} else {
+		case tlsConfig != nil:
+			srv.SetTLSConfig(tlsConfig)
+			srvErr = srv.ListenAndServeTLS("", "")
+		default:
 			srvErr = srv.ListenAndServe()
 		}
 		if srvErr != nil {
@@ -322,10 +1019,268 @@ func runRecv(listen, dir, maxFileStr, maxDiskStr string, compress bool, redactFl
 	if headless {
 		return runHeadless(listen, dir, writer, errCh, shutdown)
 	}
-	return runTUI(stats, ring, rot, maxDisk, writer, listen, dir, redactInfo, errCh, shutdown)
+	return runTUI(stats, ring, rot, maxDisk, writer, alertEngine, listen, dir, redactInfo, errCh, shutdown)
+}
+
+// watchDebugSignal writes a diagnostics dump to the capture directory on
+// each debug signal until the returned stop function is called.
+func watchDebugSignal(d *recv.Debugger, audit *recv.AuditLogger, headless bool) func() {
+	if len(debugSignals) == 0 {
+		return func() {}
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, debugSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				path, _, err := d.Dump()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "WARNING: debug dump: %v\n", err)
+					continue
+				}
+				audit.Log(recv.AuditEntry{Event: "debug_dump"})
+				if headless {
+					fmt.Fprintf(os.Stderr, "debug dump written to %s\n", path)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
+
+// debugConfig returns the effective receiver settings for diagnostics
+// dumps, with secrets reduced to whether they are set.
+func (o recvOpts) debugConfig() map[string]any {
+	secret := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "<set>"
+	}
+	return map[string]any{
+		"listen":             o.listen,
+		"otlp_grpc_listen":   o.otlpGRPCListen,
+		"syslog":             o.syslogListen,
+		"forward":            o.forwardListen,
+		"forward_shared_key": secret(o.forwardSharedKey),
+		"kafka_brokers":      o.kafkaBrokers,
+		"kafka_topics":       o.kafkaTopics,
+		"kafka_group":        o.kafkaGroup,
+		"kafka_start":        o.kafkaStart,
+		"dir":                o.dir,
+		"max_file":           o.maxFile,
+		"max_disk":           o.maxDisk,
+		"rotate_every":       o.rotateEvery.String(),
+		"partition_by":       o.partitionBy,
+		"index_fields":       o.indexFields,
+		"grep_bloom":         o.grepBloom,
+		"dedup_window":       o.dedupWindow.String(),
+		"fsync_interval":     o.fsyncInterval.String(),
+		"durable":            o.durable,
+		"trace_endpoint":     o.traceEndpoint,
+		"trace_sample_ratio": o.traceSampleRatio,
+		"sessions":           o.sessions,
+		"max_sessions":       o.maxSessions,
+		"shard":              o.shard,
+		"shard_peers":        o.shardPeers,
+		"compress":           o.compress,
+		"redact":             o.redact,
+		"redact_patterns":    o.redactPatterns,
+		"buffer":             o.bufSize,
+		"headless":           o.headless,
+		"tls":                (o.tlsCert != "" && o.tlsKey != "") || o.tlsSelfSigned || len(o.tlsACMEDomains) > 0,
+		"webhooks":           len(o.webhookURLs),
+		"webhook_events":     o.webhookEvents,
+		"webhook_auth":       secret(o.webhookAuth),
+		"alert_rules":        o.alertRules,
+		"replay":             o.replay,
+		"replay_speed":       o.replaySpeed,
+		"detect_duplicates":  o.detectDups,
+		"processors":         o.processors,
+		"sample":             o.sample,
+		"trusted_proxies":    o.trustedProxies,
+		"auth_token":         secret(o.authToken),
+		"audit_sinks":        len(o.auditSinks),
+		"audit_sink_auth":    secret(o.auditSinkAuth),
+		"timestamp_fallback": o.tsFallback,
+		"timestamp_layouts":  o.tsLayouts,
+		"stream_idle_ttl":    o.streamIdleTTL.String(),
+		"shutdown_timeout":   o.shutdownTimeout.String(),
+		"max_labels":         o.maxLabels,
+		"max_label_value":    o.maxLabelValue,
+		"sink":               o.sink,
+		"memory":             o.memory,
+	}
+}
+
+// newSegmentSink connects to the object storage at url for the capture in
+// dir.
+func newSegmentSink(url, dir string) (rotate.Sink, error) {
+	scheme, bucket, _, err := cloud.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := cloud.NewBackend(context.Background(), scheme, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", scheme, err)
+	}
+	return rotate.NewCloudSink(backend, url, dir)
+}
+
+// storeMetadata copies the final metadata.json of the capture and of each
+// session to sink, completing the remote copy of the capture.
+func storeMetadata(sink rotate.Sink, dir string, sessions []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	dirs := []string{dir}
+	for _, name := range sessions {
+		dirs = append(dirs, filepath.Join(dir, name))
+	}
+	for _, d := range dirs {
+		if _, err := rotate.StoreFile(ctx, sink, d, "metadata.json"); err != nil {
+			fmt.Fprintf(os.Stderr, "sink: store metadata: %v\n", err)
+		}
+	}
+}
+
+// runRecvMemory runs an in-memory receiver until interrupted. Entries stay
+// in a bounded ring, readable over the live query API; nothing is written
+// to disk.
+func runRecvMemory(opts recvOpts) error {
+	if opts.dir != "" || opts.sessions || opts.shard != "" || opts.replay != "" {
+		return fmt.Errorf("--memory cannot be combined with --dir, --sessions, --shard or --replay")
+	}
+	captureInfo, err := opts.captureInfo()
+	if err != nil {
+		return err
+	}
+	srv := recv.NewInMemoryServer(opts.listen, opts.memoryEntries)
+	srv.SetVersion(version)
+	srv.SetCaptureInfo(captureInfo, nil)
+	srv.SetPushAuth(recv.NewPushAuth(opts.authToken))
+	if err := srv.Start(); err != nil {
+		return err
+	}
+	errCh := make(chan error, 1)
+	if opts.otlpGRPCListen != "" {
+		if err := startOTLPGRPC(srv.Server, opts.otlpGRPCListen, "", "", nil, errCh); err != nil {
+			_ = srv.Close()
+			return err
+		}
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	fmt.Fprintf(os.Stderr, "logtap recv listening on %s, in memory (newest %d entries)\n", srv.Addr(), max(opts.memoryEntries, 1))
+	select {
+	case <-sigCh:
+	case err = <-srv.Err():
+	case err = <-errCh:
+	}
+	fmt.Fprintln(os.Stderr, "shutting down...")
+	if cerr := srv.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
-func runHeadless(listen, dir string, writer *recv.Writer, errCh <-chan error, shutdown func()) error {
+// startOTLPGRPC serves OTLP/gRPC logs and the push stream on addr in the
+// background, with TLS when a certificate or TLS config is given. Serve
+// errors are sent to errCh.
+func startOTLPGRPC(srv *recv.Server, addr, tlsCert, tlsKey string, tlsConfig *tls.Config, errCh chan<- error) error {
+	var grpcOpts []grpc.ServerOption
+	switch {
+	case tlsCert != "" && tlsKey != "":
+		creds, err := credentials.NewServerTLSFromFile(tlsCert, tlsKey)
+		if err != nil {
+			return fmt.Errorf("load TLS for --otlp-grpc-listen: %w", err)
+		}
+		grpcOpts = append(grpcOpts, grpc.Creds(creds))
+	case tlsConfig != nil:
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen --otlp-grpc-listen: %w", err)
+	}
+	go func() {
+		if err := srv.ServeOTLPGRPC(ln, grpcOpts...); err != nil {
+			errCh <- fmt.Errorf("otlp grpc: %w", err)
+		}
+	}()
+	return nil
+}
+
+// recvTLS resolves the receiver's TLS setup: --tls-cert/--tls-key files,
+// a generated self-signed pair (also returned as files), or an ACME config.
+// All results are empty when TLS is off.
+func recvTLS(opts recvOpts) (certFile, keyFile string, cfg *tls.Config, err error) {
+	manual := opts.tlsCert != "" || opts.tlsKey != ""
+	acme := len(opts.tlsACMEDomains) > 0
+	switch {
+	case manual && (opts.tlsSelfSigned || acme):
+		return "", "", nil, fmt.Errorf("--tls-cert and --tls-key cannot be combined with --tls-self-signed or --tls-acme-domain")
+	case opts.tlsSelfSigned && acme:
+		return "", "", nil, fmt.Errorf("--tls-self-signed and --tls-acme-domain are mutually exclusive")
+	case opts.tlsACMEEmail != "" && !acme:
+		return "", "", nil, fmt.Errorf("--tls-acme-email requires --tls-acme-domain")
+	case manual:
+		return opts.tlsCert, opts.tlsKey, nil, nil
+	case !opts.tlsSelfSigned && !acme:
+		return "", "", nil, nil
+	}
+
+	dir := opts.tlsDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", nil, fmt.Errorf("resolve --tls-dir: %w", err)
+		}
+		dir = filepath.Join(home, ".logtap", "tls")
+	}
+	if acme {
+		return "", "", recv.ACMETLSConfig(opts.tlsACMEDomains, opts.tlsACMEEmail, filepath.Join(dir, "acme")), nil
+	}
+
+	certFile, keyFile, err = recv.SelfSignedCert(dir, selfSignedHosts(opts.listen))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("--tls-self-signed: %w", err)
+	}
+	fp, err := recv.CertFingerprint(certFile)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("--tls-self-signed: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "TLS: self-signed certificate %s\n", certFile)
+	fmt.Fprintf(os.Stderr, "TLS: SHA-256 fingerprint %s\n", fp)
+	fmt.Fprintf(os.Stderr, "TLS: pin it with logtap tap --tls-pin %s (or LOGTAP_TLS_PIN on the forwarder)\n", fp)
+	return certFile, keyFile, nil, nil
+}
+
+// selfSignedHosts lists the names a self-signed certificate is issued for:
+// loopback, this host's name and the address recv listens on.
+func selfSignedHosts(listen string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	if host, _, err := net.SplitHostPort(listen); err == nil {
+		if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+func runHeadless(listen, dir string, writer *recv.Writer, errCh <-chan error, shutdown func() error) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -351,13 +1306,57 @@ func runHeadless(listen, dir string, writer *recv.Writer, errCh <-chan error, sh
 	}
 
 	fmt.Fprintln(os.Stderr, "shutting down...")
-	shutdown()
+	err := shutdown()
 	fmt.Fprintf(os.Stderr, "done: %d lines, %d bytes written\n", writer.LinesWritten(), writer.BytesWritten())
-	return nil
+	return err
+}
+
+// startReplay feeds a capture through the server's ingest pipeline. Entries
+// wait for writer capacity instead of being dropped, so an instant replay
+// reproduces the source capture line for line.
+func startReplay(reader *archive.Reader, speed archive.Speed, srv *recv.Server, writer *recv.Writer, audit *recv.AuditLogger) *archive.Feeder {
+	feeder := archive.NewFeeder(reader, nil, nil, speed)
+	feeder.SetSink(func(e recv.LogEntry) {
+		for !writer.Healthy() {
+			time.Sleep(time.Millisecond)
+		}
+		srv.Ingest(&e)
+	})
+	audit.Log(recv.AuditEntry{Event: "replay_started"})
+	feeder.Start()
+	return feeder
+}
+
+func runReplayHeadless(src, dir string, feeder *archive.Feeder, writer *recv.Writer, shutdown func() error) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	fmt.Fprintf(os.Stderr, "logtap recv replaying %s, writing to %s\n", src, dir)
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	interrupted := false
+	for !feeder.Done() && !interrupted {
+		select {
+		case <-sigCh:
+			interrupted = true
+		case <-ticker.C:
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "shutting down...")
+	shutdownErr := shutdown()
+	if err := feeder.Err(); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "done: %d lines replayed, %d lines, %d bytes written\n", feeder.LinesEmitted(), writer.LinesWritten(), writer.BytesWritten())
+	return shutdownErr
 }
 
-func runTUI(stats *recv.Stats, ring *recv.LogRing, disk recv.DiskReporter, diskCap int64, writer *recv.Writer, listen, dir, redactInfo string, errCh <-chan error, shutdown func()) error {
+func runTUI(stats *recv.Stats, ring *recv.LogRing, disk recv.DiskReporter, diskCap int64, writer *recv.Writer, alerts *recv.AlertEngine, listen, dir, redactInfo string, errCh <-chan error, shutdown func() error) error {
 	model := recv.NewTUIModel(stats, ring, disk, diskCap, writer, listen, dir, redactInfo)
+	model.SetAlertEngine(alerts)
 	p := tea.NewProgram(model, tea.WithAltScreen())
 
 	// forward server errors to TUI quit
@@ -373,8 +1372,7 @@ func runTUI(stats *recv.Stats, ring *recv.LogRing, disk recv.DiskReporter, diskC
 		return fmt.Errorf("TUI: %w", err)
 	}
 
-	shutdown()
-	return nil
+	return shutdown()
 }
 
 type inClusterOpts struct {
@@ -384,6 +1382,7 @@ type inClusterOpts struct {
 	maxDisk    string
 	compress   bool
 	redact     string
+	sessions   bool
 	listenPort int
 	ttl        time.Duration
 }
@@ -399,7 +1398,7 @@ func runRecvInCluster(opts inClusterOpts) error {
 		cancel()
 	}()
 
-	c, err := k8s.NewClient(opts.namespace)
+	c, err := newK8sClient(opts.namespace)
 	if err != nil {
 		return fmt.Errorf("connect to cluster: %w", err)
 	}
@@ -422,6 +1421,9 @@ func runRecvInCluster(opts inClusterOpts) error {
 	if opts.redact != "" {
 		podArgs = append(podArgs, "--redact", opts.redact)
 	}
+	if opts.sessions {
+		podArgs = append(podArgs, "--sessions")
+	}
 
 	spec := k8s.ReceiverSpec{
 		Image:     opts.image,
@@ -529,3 +1531,33 @@ func parseByteSize(s string) (int64, error) {
 	}
 	return int64(val), nil
 }
+
+// parseShardDirs splits a comma-separated --dir value. The first directory
+// is the primary; shard directories are made absolute so the capture's
+// metadata still locates them when read from elsewhere.
+func parseShardDirs(s string) ([]string, error) {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return nil, err
+		}
+		if seen[abs] {
+			return nil, fmt.Errorf("directory %s listed twice", d)
+		}
+		seen[abs] = true
+		if len(dirs) > 0 {
+			d = abs
+		}
+		dirs = append(dirs, d)
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no directory given")
+	}
+	return dirs, nil
+}
@@ -3,8 +3,14 @@ package main
 import (
 	"bytes"
 	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ppiankov/logtap/internal/archive"
 	"github.com/ppiankov/logtap/internal/recv"
 )
 
@@ -18,9 +24,10 @@ func TestRunHeadless_Shutdown(t *testing.T) {
 	errCh <- errors.New("http: Server closed")
 
 	called := false
-	shutdown := func() {
+	shutdown := func() error {
 		called = true
 		writer.Close()
+		return nil
 	}
 
 	if err := runHeadless(":0", t.TempDir(), writer, errCh, shutdown); err != nil {
@@ -31,13 +38,326 @@ func TestRunHeadless_Shutdown(t *testing.T) {
 	}
 }
 
+func TestRunHeadless_ShutdownLoss(t *testing.T) {
+	restore := redirectOutput(t)
+	defer restore()
+
+	var buf bytes.Buffer
+	writer := recv.NewWriter(1, &buf, nil)
+	errCh := make(chan error, 1)
+	errCh <- errors.New("http: Server closed")
+
+	shutdown := func() error {
+		writer.Close()
+		return errors.New("shutdown: 3 queued line(s) dropped")
+	}
+	if err := runHeadless(":0", t.TempDir(), writer, errCh, shutdown); err == nil || !strings.Contains(err.Error(), "dropped") {
+		t.Fatalf("runHeadless = %v, want the shutdown error", err)
+	}
+}
+
+func TestRunRecv_NegativeShutdownTimeout(t *testing.T) {
+	err := runRecv(recvOpts{listen: ":0", dir: t.TempDir(), maxFile: "1KB", maxDisk: "1MB", bufSize: 8, headless: true, shutdownTimeout: -time.Second})
+	if err == nil || !strings.Contains(err.Error(), "--shutdown-timeout") {
+		t.Fatalf("expected --shutdown-timeout error, got %v", err)
+	}
+}
+
 func TestRunRecv_InvalidListen(t *testing.T) {
 	restore := redirectOutput(t)
 	defer restore()
 
 	dir := t.TempDir()
-	err := runRecv("invalid", dir, "1KB", "1MB", false, "true", "", 8, true, "", "", nil, "", "", "")
+	err := runRecv(recvOpts{listen: "invalid", dir: dir, maxFile: "1KB", maxDisk: "1MB", redact: "true", bufSize: 8, headless: true})
 	if err == nil {
 		t.Fatal("expected error for invalid listen address")
 	}
 }
+
+func TestRunRecv_ReplayMissingCapture(t *testing.T) {
+	restore := redirectOutput(t)
+	defer restore()
+
+	dir := t.TempDir()
+	err := runRecv(recvOpts{listen: ":0", dir: dir, maxFile: "1KB", maxDisk: "1MB", bufSize: 8, headless: true, replay: "/nonexistent/capture", replaySpeed: "0"})
+	if err == nil {
+		t.Fatal("expected error for missing replay capture")
+	}
+}
+
+func TestRunRecv_ReplayInvalidSpeed(t *testing.T) {
+	restore := redirectOutput(t)
+	defer restore()
+
+	src := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+	err := runRecv(recvOpts{listen: ":0", dir: t.TempDir(), maxFile: "1KB", maxDisk: "1MB", bufSize: 8, headless: true, replay: src, replaySpeed: "fast"})
+	if err == nil {
+		t.Fatal("expected error for invalid --replay-speed")
+	}
+}
+
+func TestRunRecv_SessionsShardedDir(t *testing.T) {
+	dirs := t.TempDir() + "," + t.TempDir()
+	err := runRecv(recvOpts{listen: ":0", dir: dirs, maxFile: "1KB", maxDisk: "1MB", bufSize: 8, headless: true, sessions: true})
+	if err == nil || !strings.Contains(err.Error(), "--sessions") {
+		t.Fatalf("err = %v, want --sessions with sharded --dir rejected", err)
+	}
+}
+
+func TestRunRecv_ShardFlags(t *testing.T) {
+	tests := []struct {
+		shard string
+		peers []string
+		want  string
+	}{
+		{"", []string{"a:3100"}, "--shard-peers requires --shard"},
+		{"4/3", nil, "invalid --shard"},
+		{"1/3", []string{"a:3100", "b:3100"}, "invalid --shard-peers"},
+	}
+	for _, tt := range tests {
+		err := runRecv(recvOpts{listen: ":0", dir: t.TempDir(), maxFile: "1KB", maxDisk: "1MB", bufSize: 8, headless: true, shard: tt.shard, shardPeers: tt.peers})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("shard %q peers %v: err = %v, want %q", tt.shard, tt.peers, err, tt.want)
+		}
+	}
+}
+
+func TestRunRecv_SinkFlags(t *testing.T) {
+	err := runRecv(recvOpts{listen: ":0", dir: t.TempDir() + "," + t.TempDir(), maxFile: "1KB", maxDisk: "1MB", bufSize: 8, headless: true, sink: "s3://bucket/run"})
+	if err == nil || !strings.Contains(err.Error(), "--sink") {
+		t.Errorf("sharded --dir: err = %v", err)
+	}
+	err = runRecv(recvOpts{listen: ":0", dir: t.TempDir(), maxFile: "1KB", maxDisk: "1MB", bufSize: 8, headless: true, kafkaStart: recv.KafkaStartLatest, sink: "ftp://bucket/run"})
+	if err == nil || !strings.Contains(err.Error(), "invalid --sink") {
+		t.Errorf("bad URL: err = %v", err)
+	}
+}
+
+func TestRunRecv_RotateEveryTooShort(t *testing.T) {
+	err := runRecv(recvOpts{listen: ":0", dir: t.TempDir(), maxFile: "1KB", maxDisk: "1MB", bufSize: 8, headless: true, kafkaStart: recv.KafkaStartLatest, rotateEvery: time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "--rotate-every") {
+		t.Errorf("err = %v, want --rotate-every error", err)
+	}
+}
+
+func TestRunRecv_InvalidMeta(t *testing.T) {
+	err := runRecv(recvOpts{listen: ":0", dir: t.TempDir(), maxFile: "1KB", maxDisk: "1MB", bufSize: 8, headless: true, kafkaStart: recv.KafkaStartLatest, meta: []string{"test-run"}})
+	if err == nil || !strings.Contains(err.Error(), "--meta") {
+		t.Errorf("err = %v, want --meta error", err)
+	}
+}
+
+func TestRecvTLS(t *testing.T) {
+	for _, tt := range []struct {
+		opts recvOpts
+		want string
+	}{
+		{recvOpts{tlsCert: "c.pem", tlsKey: "k.pem", tlsSelfSigned: true}, "cannot be combined"},
+		{recvOpts{tlsCert: "c.pem", tlsACMEDomains: []string{"logs.example.com"}}, "cannot be combined"},
+		{recvOpts{tlsSelfSigned: true, tlsACMEDomains: []string{"logs.example.com"}}, "mutually exclusive"},
+		{recvOpts{tlsACMEEmail: "ops@example.com"}, "requires --tls-acme-domain"},
+	} {
+		if _, _, _, err := recvTLS(tt.opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: err = %v, want %q", tt.opts, err, tt.want)
+		}
+	}
+
+	cert, key, cfg, err := recvTLS(recvOpts{tlsCert: "c.pem", tlsKey: "k.pem"})
+	if err != nil || cert != "c.pem" || key != "k.pem" || cfg != nil {
+		t.Errorf("manual: %q %q %v %v", cert, key, cfg, err)
+	}
+
+	dir := t.TempDir()
+	stderr := os.Stderr
+	devnull, _ := os.Open(os.DevNull)
+	os.Stderr = devnull
+	cert, key, cfg, err = recvTLS(recvOpts{listen: "10.0.0.5:3100", tlsSelfSigned: true, tlsDir: dir})
+	os.Stderr = stderr
+	_ = devnull.Close()
+	if err != nil || filepath.Dir(cert) != dir || filepath.Dir(key) != dir || cfg != nil {
+		t.Fatalf("self-signed: %q %q %v %v", cert, key, cfg, err)
+	}
+	if _, err := recv.CertFingerprint(cert); err != nil {
+		t.Errorf("generated certificate: %v", err)
+	}
+
+	_, _, cfg, err = recvTLS(recvOpts{tlsACMEDomains: []string{"logs.example.com"}, tlsDir: dir})
+	if err != nil || cfg == nil || cfg.GetCertificate == nil {
+		t.Errorf("acme: cfg = %v, err = %v", cfg, err)
+	}
+}
+
+func TestSelfSignedHosts(t *testing.T) {
+	hosts := selfSignedHosts("10.0.0.5:3100")
+	if !slices.Contains(hosts, "10.0.0.5") || !slices.Contains(hosts, "localhost") {
+		t.Errorf("hosts = %v", hosts)
+	}
+	if hosts := selfSignedHosts(":3100"); slices.Contains(hosts, "") {
+		t.Errorf("hosts for a wildcard listen = %v", hosts)
+	}
+	if hosts := selfSignedHosts("0.0.0.0:3100"); slices.Contains(hosts, "0.0.0.0") {
+		t.Errorf("hosts for an unspecified listen = %v", hosts)
+	}
+}
+
+func TestRunRecvMemory_Conflicts(t *testing.T) {
+	for _, opts := range []recvOpts{
+		{listen: ":0", memory: true, dir: t.TempDir()},
+		{listen: ":0", memory: true, sessions: true},
+		{listen: ":0", memory: true, shard: "1/2"},
+	} {
+		err := runRecvMemory(opts)
+		if err == nil || !strings.Contains(err.Error(), "--memory cannot be combined") {
+			t.Errorf("%+v: err = %v", opts, err)
+		}
+	}
+}
+
+func TestRunRecv_SessionsReplay(t *testing.T) {
+	restore := redirectOutput(t)
+	defer restore()
+
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	var entries []recv.LogEntry
+	for i := 0; i < 6; i++ {
+		session := "load-a"
+		if i%3 == 0 {
+			session = "load-b"
+		}
+		entries = append(entries, recv.LogEntry{
+			Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Labels:    map[string]string{"app": "web", "session": session},
+			Message:   "request served",
+		})
+	}
+	src := makeCaptureDir(t, entries)
+
+	dir := t.TempDir()
+	err := runRecv(recvOpts{listen: ":0", dir: dir, maxFile: "1MB", maxDisk: "10MB", bufSize: 64, headless: true,
+		replay: src, replaySpeed: "0", kafkaStart: recv.KafkaStartLatest, sessions: true, maxSessions: 8})
+	if err != nil {
+		t.Fatalf("runRecv: %v", err)
+	}
+
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(meta.Sessions, ",") != "load-a,load-b" {
+		t.Errorf("sessions = %v, want load-a,load-b", meta.Sessions)
+	}
+	if sd := meta.Shutdown; sd == nil || !sd.Clean || sd.Dropped != 0 || sd.Timeout != "" {
+		t.Errorf("shutdown = %+v, want a clean drain without a deadline", sd)
+	}
+	for name, want := range map[string]int64{"load-a": 4, "load-b": 2} {
+		smeta, err := recv.ReadMetadata(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if smeta.Session != name || smeta.TotalLines != want {
+			t.Errorf("%s metadata: session %q, %d lines; want %d", name, smeta.Session, smeta.TotalLines, want)
+		}
+	}
+}
+
+func TestReplayHeadless_WritesAllEntries(t *testing.T) {
+	restore := redirectOutput(t)
+	defer restore()
+
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	var entries []recv.LogEntry
+	for i := 0; i < 50; i++ {
+		entries = append(entries, recv.LogEntry{
+			Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Labels:    map[string]string{"app": "web"},
+			Message:   "user alice@example.com logged in",
+		})
+	}
+	src := makeCaptureDir(t, entries)
+
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	redactor, err := recv.NewRedactor([]string{"email"})
+	if err != nil {
+		t.Fatalf("NewRedactor: %v", err)
+	}
+
+	outDir := t.TempDir()
+	audit, err := recv.NewAuditLogger(outDir)
+	if err != nil {
+		t.Fatalf("NewAuditLogger: %v", err)
+	}
+
+	// a tiny buffer forces the sink to wait for capacity rather than drop
+	var buf bytes.Buffer
+	writer := recv.NewWriter(1, &buf, nil)
+	stats := recv.NewStats()
+	srv := recv.NewServer(":0", writer, redactor, nil, stats, nil)
+
+	feeder := startReplay(reader, archive.SpeedInstant, srv, writer, audit)
+	shutdown := func() error {
+		feeder.Stop()
+		writer.Close()
+		return audit.Close()
+	}
+	if err := runReplayHeadless(src, outDir, feeder, writer, shutdown); err != nil {
+		t.Fatalf("runReplayHeadless: %v", err)
+	}
+
+	if got := writer.LinesWritten(); got != 50 {
+		t.Errorf("lines written = %d, want 50", got)
+	}
+	if snap := stats.Snapshot(0, 0, 0); snap.LogsDropped != 0 {
+		t.Errorf("dropped = %d, want 0", snap.LogsDropped)
+	}
+	if strings.Contains(buf.String(), "alice@example.com") {
+		t.Error("expected replayed entries to be redacted")
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("read audit: %v", err)
+	}
+	if !strings.Contains(string(data), `"replay_started"`) {
+		t.Errorf("audit log missing replay_started: %s", data)
+	}
+}
+
+func TestRecvOptsDebugConfig(t *testing.T) {
+	cfg := recvOpts{listen: ":3100", forwardSharedKey: "s3cret", webhookAuth: "bearer:tok", webhookURLs: []string{"https://hook?token=x"}}.debugConfig()
+	if cfg["listen"] != ":3100" {
+		t.Errorf("listen = %v", cfg["listen"])
+	}
+	if cfg["forward_shared_key"] != "<set>" || cfg["webhook_auth"] != "<set>" || cfg["audit_sink_auth"] != "" {
+		t.Errorf("secrets not masked: %v", cfg)
+	}
+	if cfg["webhooks"] != 1 {
+		t.Errorf("webhooks = %v, want count 1", cfg["webhooks"])
+	}
+}
+
+func TestParseShardDirs(t *testing.T) {
+	dirs, err := parseShardDirs("capture, /mnt/b ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 || dirs[0] != "capture" || dirs[1] != "/mnt/b" {
+		t.Errorf("dirs = %q, want primary kept as given and one shard", dirs)
+	}
+
+	dirs, err = parseShardDirs("/a,rel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filepath.IsAbs(dirs[1]) {
+		t.Errorf("shard %q not made absolute", dirs[1])
+	}
+
+	for _, bad := range []string{"", " , ", "/a,/a/"} {
+		if _, err := parseShardDirs(bad); err == nil {
+			t.Errorf("parseShardDirs(%q): expected error", bad)
+		}
+	}
+}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
 	"github.com/ppiankov/logtap/internal/recv"
 )
 
@@ -16,35 +19,85 @@ func newReportCmd() *cobra.Command {
 	var (
 		outDir     string
 		jsonOutput bool
+		format     string
 		htmlOutput bool
 		jobs       int
 		top        int
+		links      []string
+		ownersPath string
+		queryURL   string
 	)
 
 	cmd := &cobra.Command{
-		Use:   "report <capture-dir>",
+		Use:   "report [capture-dir]",
 		Short: "Generate a self-contained incident report",
 		Long:  "Combines inspect and triage into a single deliverable: report.json for agents, report.html for operators.",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			switch format {
+			case "", reportFormatJSON, reportFormatMarkdownSummary:
+			default:
+				return cli.NewUsageError(fmt.Sprintf("invalid --format %q (expected json or markdown-summary)", format))
+			}
+			if queryURL != "" {
+				if u, err := url.Parse(queryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return cli.NewUsageError(fmt.Sprintf("invalid --query-url %q (expected http(s)://host:port)", queryURL))
+				}
+			}
+			if jsonOutput && format == "" {
+				format = reportFormatJSON
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runReport(args[0], outDir, jsonOutput, htmlOutput, jobs, top)
+			captureDir, err := captureDirArg(args, 0)
+			if err != nil {
+				return err
+			}
+			owners, err := loadOwners(ownersPath, captureDir)
+			if err != nil {
+				return err
+			}
+			latency, err := latencyConfig(cmd)
+			if err != nil {
+				return err
+			}
+			return runReport(captureDir, outDir, format, htmlOutput, jobs, top, owners, latency, links, queryURL)
 		},
 	}
 
 	cmd.Flags().StringVar(&outDir, "out", "", "output directory for report artifacts")
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output report.json to stdout")
-	addFormatAlias(cmd, &jsonOutput)
+	cmd.Flags().StringVar(&format, "format", "", "stdout format: json or markdown-summary (short block for Slack or PRs)")
 	cmd.Flags().BoolVar(&htmlOutput, "html", true, "include HTML report")
 	cmd.Flags().IntVar(&jobs, "jobs", runtime.NumCPU(), "parallel scan workers")
 	cmd.Flags().IntVar(&top, "top", 20, "number of top error signatures")
+	cmd.Flags().StringArrayVar(&links, "link", nil, "link for the markdown summary as label=url (repeatable)")
+	cmd.Flags().StringVar(&ownersPath, "owners", "", ownersFlagUsage)
+	cmd.Flags().StringVar(&queryURL, "query-url", "", "base URL of a logtap recv serving the capture (e.g. http://localhost:3100); report.html links top errors to its live query API")
+	addLatencyFlags(cmd)
 
 	return cmd
 }
 
-func runReport(src, outDir string, jsonOutput, htmlOutput bool, jobs, top int) error {
+// Report stdout formats.
+const (
+	reportFormatJSON            = "json"
+	reportFormatMarkdownSummary = "markdown-summary"
+)
+
+func runReport(src, outDir, format string, htmlOutput bool, jobs, top int, owners *archive.Owners, latency *archive.LatencyConfig, links []string, queryURL string) error {
+	summaryLinks, err := parseReportLinks(links)
+	if err != nil {
+		return err
+	}
+
 	cfg := archive.ReportConfig{
-		Jobs: jobs,
-		Top:  top,
+		Jobs:     jobs,
+		Top:      top,
+		Owners:   owners,
+		Latency:  latency,
+		QueryURL: queryURL,
 	}
 
 	progress := func(p archive.TriageProgress) {
@@ -63,10 +116,14 @@ func runReport(src, outDir string, jsonOutput, htmlOutput bool, jobs, top int) e
 	fmt.Fprintf(os.Stderr, "\rReport: severity=%s, error_rate=%.1f%%, entries=%s\n",
 		result.Severity, result.Triage.ErrorRatePct, archive.FormatCount(result.Capture.Entries))
 
-	if jsonOutput {
+	if format == reportFormatJSON {
 		return result.WriteJSON(os.Stdout)
 	}
 
+	if format == reportFormatMarkdownSummary && outDir == "" {
+		return result.WriteMarkdownSummary(os.Stdout, summaryLinks)
+	}
+
 	if outDir == "" {
 		return fmt.Errorf("--out is required (or use --json for stdout)")
 	}
@@ -97,7 +154,7 @@ func runReport(src, outDir string, jsonOutput, htmlOutput bool, jobs, top int) e
 			return fmt.Errorf("create report.html: %w", err)
 		}
 		// Re-run triage for HTML (uses its own SVG renderer)
-		triageCfg := archive.TriageConfig{Jobs: jobs, Top: top}
+		triageCfg := archive.TriageConfig{Jobs: jobs, Top: top, Owners: owners, Latency: latency}
 		triageResult, _ := archive.Triage(src, triageCfg, nil)
 		meta, _ := recv.ReadMetadata(src)
 		if err := result.WriteHTML(hf, triageResult, meta); err != nil {
@@ -110,5 +167,26 @@ func runReport(src, outDir string, jsonOutput, htmlOutput bool, jobs, top int) e
 	}
 
 	fmt.Fprintf(os.Stderr, "Report: %s\n", filepath.Join(outDir, "report.json"))
+
+	if format == reportFormatMarkdownSummary {
+		if htmlOutput {
+			link := archive.ReportLink{Label: "HTML report", URL: filepath.Join(outDir, "report.html")}
+			summaryLinks = append([]archive.ReportLink{link}, summaryLinks...)
+		}
+		return result.WriteMarkdownSummary(os.Stdout, summaryLinks)
+	}
 	return nil
 }
+
+// parseReportLinks parses --link label=url values.
+func parseReportLinks(values []string) ([]archive.ReportLink, error) {
+	var links []archive.ReportLink
+	for _, v := range values {
+		label, url, ok := strings.Cut(v, "=")
+		if !ok || strings.TrimSpace(label) == "" || strings.TrimSpace(url) == "" {
+			return nil, cli.NewUsageError(fmt.Sprintf("invalid --link %q (expected label=url)", v))
+		}
+		links = append(links, archive.ReportLink{Label: strings.TrimSpace(label), URL: strings.TrimSpace(url)})
+	}
+	return links, nil
+}
@@ -8,36 +8,66 @@ import (
 	"strings"
 	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 
 	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
 )
 
 var (
-	sliceFrom  string
-	sliceTo    string
-	sliceLabel []string
-	sliceGrep  string
-	sliceOut   string
-	sliceJSON  bool
+	sliceFrom    string
+	sliceTo      string
+	sliceLabel   []string
+	sliceGrep    string
+	sliceOut     string
+	sliceJSON    bool
+	sliceResume  bool
+	sliceProfile bool
+	sliceLife    lifecycleFilter
+	sliceInter   bool
 )
 
 func newSliceCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "slice <capture-directory>",
+		Use:   "slice [capture-directory]",
 		Short: "Extract a time range and/or label filter into a new smaller capture directory",
-		Long:  "Slice reads a capture directory, applies time range and/or label filters, and writes matching entries to a new capture directory with its own metadata and index.",
-		Args:  cobra.ExactArgs(1),
+		Long: `Slice reads a capture directory, applies time range and/or label filters, and writes matching entries to a new capture directory with its own metadata and index.
+
+With --interactive, slice first shows a histogram of the capture from its index: move the cursor with h/l, press space at the start and end of the window, tab to pick label values, and enter to slice. The equivalent flags are printed so the slice can be repeated.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			captureDir := args[0]
+			captureDir, err := captureDirArg(args, 0)
+			if err != nil {
+				return err
+			}
 
 			if sliceOut == "" {
 				return fmt.Errorf("--out flag is required")
 			}
 
-			var fromTime, toTime time.Time
-			var err error
+			if sliceInter {
+				for _, name := range []string{"from", "to", "label"} {
+					if cmd.Flags().Changed(name) {
+						return cli.NewUsageError(fmt.Sprintf("--%s cannot be combined with --interactive, which picks it", name))
+					}
+				}
+				if !stdinIsTerminal() {
+					return cli.NewUsageError("--interactive needs a terminal")
+				}
+				pick, ok, err := pickSlice(captureDir)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					_, _ = fmt.Fprintln(os.Stderr, "Slice cancelled.")
+					return nil
+				}
+				sliceFrom, sliceTo, sliceLabel = pickFlags(pick)
+				_, _ = fmt.Fprintln(os.Stderr, pickCommand(captureDir, sliceOut, pick))
+			}
 
+			var fromTime, toTime time.Time
 			if sliceFrom != "" {
 				fromTime, err = parseTime(sliceFrom)
 				if err != nil {
@@ -52,7 +82,7 @@ func newSliceCmd() *cobra.Command {
 			}
 
 			var labelFilters []archive.LabelFilter
-			for _, l := range sliceLabel {
+			for _, l := range sliceLife.labels(sliceLabel) {
 				parts := strings.SplitN(l, "=", 2)
 				if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
 					return fmt.Errorf("invalid --label format '%s': expected key=value", l)
@@ -68,18 +98,37 @@ func newSliceCmd() *cobra.Command {
 				}
 			}
 
+			restarts, err := sliceRestarts(captureDir, sliceLife)
+			if err != nil {
+				return err
+			}
+			phases, err := slicePhases(captureDir, sliceLife)
+			if err != nil {
+				return err
+			}
+			fields, err := sliceLife.fieldMatchers()
+			if err != nil {
+				return err
+			}
+
 			opts := archive.SliceOptions{
 				CaptureDir: captureDir,
 				OutputDir:  sliceOut,
 				From:       fromTime,
 				To:         toTime,
 				Labels:     labelFilters,
+				Fields:     fields,
 				Grep:       grepRegex,
+				Restarts:   restarts,
+				Phases:     phases,
+				Resume:     sliceResume,
+				Profile:    newProfile(sliceProfile),
 			}
 
 			if err := archive.Slice(opts); err != nil {
 				return err
 			}
+			printProfile(os.Stderr, opts.Profile)
 
 			if sliceJSON {
 				summary, err := archive.Inspect(sliceOut)
@@ -106,14 +155,63 @@ func newSliceCmd() *cobra.Command {
 	cmd.Flags().StringVar(&sliceGrep, "grep", "", "regex filter on message content")
 	cmd.Flags().StringVarP(&sliceOut, "out", "o", "", "output directory for the new capture (required)")
 	cmd.Flags().BoolVar(&sliceJSON, "json", false, "output summary as JSON")
+	cmd.Flags().BoolVar(&sliceResume, "resume", false, "continue an interrupted slice from its checkpoint in --out")
+	cmd.Flags().BoolVar(&sliceProfile, "profile", false, profileFlagUsage)
+	cmd.Flags().BoolVarP(&sliceInter, "interactive", "i", false, "pick the time window and labels from a histogram of the capture")
+	sliceLife.addFlags(cmd)
+	addPresetFlag(cmd)
 	addFormatAlias(cmd, &sliceJSON)
 	_ = cmd.MarkFlagRequired("out")
 
 	return cmd
 }
 
+// pickSlice runs the slice picker over the capture. ok is false when the
+// user quit without choosing.
+func pickSlice(dir string) (archive.SlicePick, bool, error) {
+	reader, err := archive.NewReader(dir)
+	if err != nil {
+		return archive.SlicePick{}, false, fmt.Errorf("open capture: %w", err)
+	}
+	model, err := archive.NewSlicePickerModel(reader)
+	if err != nil {
+		return archive.SlicePick{}, false, err
+	}
+	final, err := tea.NewProgram(model, tea.WithAltScreen()).Run()
+	if err != nil {
+		return archive.SlicePick{}, false, fmt.Errorf("TUI: %w", err)
+	}
+	pick, ok := final.(archive.SlicePickerModel).Result()
+	return pick, ok, nil
+}
+
+// pickFlags turns a pick into --from, --to and --label values.
+func pickFlags(pick archive.SlicePick) (from, to string, labels []string) {
+	if !pick.From.IsZero() {
+		from = pick.From.UTC().Format(time.RFC3339)
+		to = pick.To.UTC().Format(time.RFC3339)
+	}
+	for _, l := range pick.Labels {
+		labels = append(labels, l.Key+"="+l.Value)
+	}
+	return from, to, labels
+}
+
+// pickCommand returns the slice command line equivalent to a pick.
+func pickCommand(dir, out string, pick archive.SlicePick) string {
+	from, to, labels := pickFlags(pick)
+	cmd := "logtap slice " + dir
+	if from != "" {
+		cmd += " --from " + from + " --to " + to
+	}
+	for _, l := range labels {
+		cmd += " --label " + l
+	}
+	return cmd + " --out " + out
+}
+
 // runSlice is the testable entry point for the slice command.
-func runSlice(src, fromStr, toStr string, labels []string, grepStr, outDir string) error {
+func runSlice(src, fromStr, toStr string, labels []string, grepStr, outDir string, lifecycle lifecycleFilter) error {
 	now := time.Now()
 	var fromTime, toTime time.Time
 	var err error
@@ -132,7 +230,7 @@ func runSlice(src, fromStr, toStr string, labels []string, grepStr, outDir strin
 	}
 
 	var labelFilters []archive.LabelFilter
-	for _, l := range labels {
+	for _, l := range lifecycle.labels(labels) {
 		parts := strings.SplitN(l, "=", 2)
 		if len(parts) != 2 || parts[0] == "" {
 			return fmt.Errorf("invalid label %q: expected key=value", l)
@@ -148,16 +246,56 @@ func runSlice(src, fromStr, toStr string, labels []string, grepStr, outDir strin
 		}
 	}
 
+	restarts, err := sliceRestarts(src, lifecycle)
+	if err != nil {
+		return err
+	}
+	phases, err := slicePhases(src, lifecycle)
+	if err != nil {
+		return err
+	}
+	fields, err := lifecycle.fieldMatchers()
+	if err != nil {
+		return err
+	}
+
 	return archive.Slice(archive.SliceOptions{
 		CaptureDir: src,
 		OutputDir:  outDir,
 		From:       fromTime,
 		To:         toTime,
 		Labels:     labelFilters,
+		Fields:     fields,
 		Grep:       grepRegex,
+		Restarts:   restarts,
+		Phases:     phases,
 	})
 }
 
+// sliceRestarts resolves --restarts-only against the source capture.
+func sliceRestarts(src string, lifecycle lifecycleFilter) (*archive.RestartFilter, error) {
+	if !lifecycle.restartsOnly {
+		return nil, nil
+	}
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("open capture: %w", err)
+	}
+	return lifecycle.restarts(reader)
+}
+
+// slicePhases resolves --phase against the source capture.
+func slicePhases(src string, lifecycle lifecycleFilter) (*archive.PhaseFilter, error) {
+	if lifecycle.phase == "" {
+		return nil, nil
+	}
+	reader, err := archive.NewReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("open capture: %w", err)
+	}
+	return lifecycle.phases(reader)
+}
+
 // parseTime attempts to parse a string into a time.Time, supporting RFC3339, 15:04 (HH:MM), or duration relative to now.
 func parseTime(s string) (time.Time, error) {
 	// Try RFC3339
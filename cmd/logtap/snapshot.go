@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cloud"
 )
 
 func newSnapshotCmd() *cobra.Command {
@@ -20,8 +23,11 @@ func newSnapshotCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "snapshot <capture-dir|archive>",
 		Short: "Package or extract a capture archive",
-		Long:  "Snapshot creates a single .tar.zst file from a capture directory, or extracts one back to a directory.",
-		Args:  cobra.ExactArgs(1),
+		Long: `Snapshot creates a single .tar.zst file from a capture directory, or extracts one back to a directory.
+
+With an s3:// or gs:// --output, the archive is streamed to object storage while
+it is packed, without a local temporary file.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if output == "" {
 				return fmt.Errorf("--output is required")
@@ -30,7 +36,7 @@ func newSnapshotCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&output, "output", "o", "", "output path (required)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output path or s3://bucket/key, gs://bucket/key (required)")
 	cmd.Flags().BoolVar(&extract, "extract", false, "extract archive to directory")
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output summary as JSON")
 	addFormatAlias(cmd, &jsonOutput)
@@ -39,6 +45,13 @@ func newSnapshotCmd() *cobra.Command {
 }
 
 func runSnapshot(src, output string, extract, jsonOutput bool) error {
+	if cloud.IsURL(output) {
+		if extract {
+			return fmt.Errorf("--extract needs a local --output directory")
+		}
+		return runSnapshotRemote(context.Background(), src, output, jsonOutput)
+	}
+
 	if extract {
 		if err := archive.Unpack(src, output); err != nil {
 			return err
@@ -76,6 +89,67 @@ func runSnapshot(src, output string, extract, jsonOutput bool) error {
 	return nil
 }
 
+func runSnapshotRemote(ctx context.Context, src, output string, jsonOutput bool) error {
+	scheme, bucket, key, err := cloud.ParseURL(output)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("--output %s has no object key (e.g. %s://%s/run1.tar.zst)", output, scheme, bucket)
+	}
+	backend, err := cloud.NewBackend(ctx, scheme, bucket)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "Packing %s to %s...\n", src, output)
+	size, err := packToBackend(ctx, src, backend, key)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"operation": "pack",
+			"source":    src,
+			"output":    output,
+			"bytes":     size,
+		})
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "Snapshot uploaded to %s (%s)\n", output, formatBytes(size))
+	return nil
+}
+
+// packToBackend packs src into a pipe that feeds the upload, so the archive
+// never needs local disk space. Returns the archive size.
+func packToBackend(ctx context.Context, src string, backend cloud.Backend, key string) (int64, error) {
+	pr, pw := io.Pipe()
+	counter := &countingWriter{w: pw}
+	go func() {
+		_ = pw.CloseWithError(archive.PackTo(src, counter))
+	}()
+
+	err := backend.Upload(ctx, key, pr, -1)
+	// unblock the packer if the upload stopped reading early
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
 func formatBytes(b int64) string {
 	switch {
 	case b >= 1<<30:
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
+)
+
+func newSQLCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "sql [capture-dir] <query>",
+		Short: "Run a SQL query over a capture",
+		Long: `SQL runs an aggregation or selection over a capture without exporting it
+first. The capture is the table logs with the columns ts, msg and labels;
+label('key') reads a label (NULL when absent) and field('key') a field of
+JSON messages.
+
+Files are streamed, not loaded: time ranges and label equalities ANDed into
+WHERE skip files through the index, and LIKE or ~ on msg through the bloom
+filters. Queries without GROUP BY, aggregates or ORDER BY print rows as they
+are read and stop at LIMIT.
+
+Aggregates: count(*), count([DISTINCT] x), sum, avg, min, max.
+Functions: label, field, lower, upper, length, signature (the normalized
+error pattern of triage), is_error, bucket(ts, '5m'), coalesce.`,
+		Example: `  logtap sql ./capture "SELECT label('app'), count(*) FROM logs WHERE msg LIKE '%timeout%' GROUP BY 1 ORDER BY 2 DESC"
+  logtap sql ./capture "SELECT bucket(ts, '1m') AS minute, count(*) FROM logs WHERE is_error(msg) GROUP BY minute"
+  logtap sql ./capture "SELECT ts, msg FROM logs WHERE label('pod') = 'api-0' AND ts >= '2024-01-15T10:30:00Z' LIMIT 20"
+  logtap sql "SELECT field('status'), count(*) FROM logs GROUP BY 1" --format csv`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			captureDir, err := captureDirArg(args[:len(args)-1], 0)
+			if err != nil {
+				return err
+			}
+			return runSQL(captureDir, args[len(args)-1], format)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table, csv or json (one object per row)")
+
+	return cmd
+}
+
+func runSQL(src, query, format string) error {
+	if format != "table" && format != "csv" && format != "json" {
+		return cli.NewUsageError(fmt.Sprintf("invalid --format %q: use table, csv or json", format))
+	}
+	q, err := archive.ParseSQL(query)
+	if err != nil {
+		return cli.NewUsageError(err.Error())
+	}
+	out := newSQLWriter(os.Stdout, format, q.Columns())
+
+	rows := 0
+	var werr error
+	scanned, err := q.Run(src, func(row []any) bool {
+		rows++
+		werr = out.row(row)
+		return werr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if werr != nil {
+		return werr
+	}
+	if err := out.flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d rows, %s lines scanned\n", rows, archive.FormatCount(scanned))
+	return nil
+}
+
+// sqlWriter prints result rows. Tables are aligned once every row is in;
+// csv and json rows are written as they come.
+type sqlWriter struct {
+	format  string
+	columns []string
+	tw      *tabwriter.Writer
+	csv     *csv.Writer
+	w       io.Writer
+}
+
+func newSQLWriter(w io.Writer, format string, columns []string) *sqlWriter {
+	sw := &sqlWriter{format: format, columns: columns, w: w}
+	switch format {
+	case "table":
+		sw.tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(sw.tw, strings.Join(columns, "\t"))
+	case "csv":
+		sw.csv = csv.NewWriter(w)
+		_ = sw.csv.Write(columns)
+	}
+	return sw
+}
+
+func (sw *sqlWriter) row(row []any) error {
+	switch sw.format {
+	case "table":
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(archive.FormatSQLValue(v))
+		}
+		_, err := fmt.Fprintln(sw.tw, strings.Join(cells, "\t"))
+		return err
+	case "csv":
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = archive.FormatSQLValue(v)
+		}
+		return sw.csv.Write(cells)
+	}
+
+	// json: an object with the columns in query order
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(sw.columns[i])
+		val, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteString("}\n")
+	_, err := sw.w.Write(buf.Bytes())
+	return err
+}
+
+func (sw *sqlWriter) flush() error {
+	switch sw.format {
+	case "table":
+		return sw.tw.Flush()
+	case "csv":
+		sw.csv.Flush()
+		return sw.csv.Error()
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
+)
+
+func newStatsCmd() *cobra.Command {
+	var (
+		jsonOutput bool
+		top        int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stats [capture-dir]",
+		Short: "Show distribution statistics for a capture",
+		Long: `Scan a capture once and report per-label cardinality, the message length
+distribution, lines and bytes per minute percentiles, the most repeated
+messages and the compression ratio. A lighter companion to triage: no
+error classification, just the shape of the data.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			captureDir, err := captureDirArg(args, 0)
+			if err != nil {
+				return err
+			}
+			if top < 1 {
+				return cli.NewUsageError("--top must be at least 1")
+			}
+			return runStats(captureDir, top, jsonOutput)
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output as JSON")
+	addFormatAlias(cmd, &jsonOutput)
+	cmd.Flags().IntVar(&top, "top", 10, "number of repeated messages to show")
+
+	return cmd
+}
+
+func runStats(dir string, top int, jsonOutput bool) error {
+	stats, err := archive.ComputeStats(dir, top)
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+
+	if jsonOutput {
+		return stats.WriteJSON(os.Stdout)
+	}
+
+	stats.WriteText(os.Stdout)
+	return nil
+}
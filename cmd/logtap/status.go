@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,29 +20,31 @@ import (
 func newStatusCmd() *cobra.Command {
 	var (
 		namespace  string
+		session    string
 		jsonOutput bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show tapped workloads and receiver stats",
-		Long:  "Status lists all workloads with active logtap sidecars, pod health, and receiver throughput if reachable.",
+		Long:  "Status lists all workloads with active logtap sidecars, pod health, and receiver throughput if reachable, followed by each tap session summed over its workloads.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStatus(namespace, jsonOutput)
+			return runStatus(namespace, session, jsonOutput)
 		},
 	}
 
 	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace (defaults to current context)")
+	cmd.Flags().StringVar(&session, "session", "", "only the workloads of this tap session, with the session's totals")
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output as JSON")
 	addFormatAlias(cmd, &jsonOutput)
 	return cmd
 }
 
-func runStatus(namespace string, jsonOutput bool) error {
+func runStatus(namespace, session string, jsonOutput bool) error {
 	ctx, cancel := clusterContext()
 	defer cancel()
 
-	c, err := k8s.NewClient(namespace)
+	c, err := newK8sClient(namespace)
 	if err != nil {
 		return fmt.Errorf("connect to cluster: %w", err)
 	}
@@ -49,10 +53,35 @@ func runStatus(namespace string, jsonOutput bool) error {
 	if err != nil {
 		return err
 	}
+	records, err := sidecar.ListSessions(ctx, c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	sessions := sessionStatuses(records, statuses)
+
+	var only *sessionStatus
+	if session != "" {
+		for i := range sessions {
+			if sessions[i].ID == session {
+				only = &sessions[i]
+			}
+		}
+		if only == nil {
+			return fmt.Errorf("session %s not found", session)
+		}
+		statuses = only.Workloads
+	}
+	k8s.ProbeForwarders(ctx, c, statuses, sidecar.HealthPort)
+	for i := range sessions {
+		sessions[i].total()
+	}
 
 	if jsonOutput {
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
+		if only != nil {
+			return enc.Encode(only)
+		}
 		return enc.Encode(statuses)
 	}
 
@@ -97,13 +126,105 @@ func runStatus(namespace string, jsonOutput bool) error {
 	fmt.Fprintln(os.Stderr, "Tapped workloads:")
 	for _, s := range statuses {
 		sessions := strings.Join(s.Sessions, ",")
-		fmt.Fprintf(os.Stderr, "  %s/%-24s (%s)     %d/%d pods forwarding   sessions: %s\n",
-			s.Workload.Kind, s.Workload.Name, s.Workload.Namespace, s.Ready, s.Total, sessions)
+		delivering := ""
+		if s.NotDelivering > 0 {
+			delivering = fmt.Sprintf(" (%d not delivering)", s.NotDelivering)
+		}
+		fmt.Fprintf(os.Stderr, "  %s/%-24s (%s)     %d/%d pods forwarding%s   sessions: %s\n",
+			s.Workload.Kind, s.Workload.Name, s.Workload.Namespace, s.Ready, s.Total, delivering, sessions)
+		for _, p := range s.Pods {
+			if p.Forwarder == k8s.ForwarderNotReady {
+				fmt.Fprintf(os.Stderr, "    %s: not delivering: %s\n", p.Name, p.Reason)
+			}
+		}
+	}
+
+	if only != nil {
+		sessions = []sessionStatus{*only}
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Sessions:")
+	for _, s := range sessions {
+		since := ""
+		if !s.Created.IsZero() {
+			since = fmt.Sprintf("   up %s", time.Since(s.Created).Round(time.Minute))
+		}
+		fmt.Fprintf(os.Stderr, "  %-20s %d workload(s)   %d/%d pods forwarding%s   targets: %s\n",
+			s.ID, len(s.Workloads), s.Ready, s.Total, since, strings.Join(s.Targets, ","))
+		for _, m := range s.Missing {
+			fmt.Fprintf(os.Stderr, "    %s: recorded but no longer tapped\n", m)
+		}
 	}
 
 	return nil
 }
 
+// sessionStatus is the status of a tap session summed over its workloads.
+type sessionStatus struct {
+	ID            string             `json:"id"`
+	Created       time.Time          `json:"created,omitzero"` // unset for sessions tapped by earlier releases
+	Targets       []string           `json:"targets"`
+	Workloads     []k8s.TappedStatus `json:"workloads"`
+	Missing       []string           `json:"missing,omitempty"` // recorded Kind/Name no longer carrying the session
+	Ready         int                `json:"ready"`
+	Total         int                `json:"total"`
+	NotDelivering int                `json:"not_delivering,omitempty"`
+}
+
+// total sums the pod counts of the session's workloads.
+func (s *sessionStatus) total() {
+	s.Ready, s.Total, s.NotDelivering = 0, 0, 0
+	for _, w := range s.Workloads {
+		s.Ready += w.Ready
+		s.Total += w.Total
+		s.NotDelivering += w.NotDelivering
+	}
+}
+
+// sessionStatuses groups the tapped workloads by session: the recorded
+// sessions first, oldest first, then sessions found only in workload
+// annotations.
+func sessionStatuses(records []*sidecar.Session, statuses []k8s.TappedStatus) []sessionStatus {
+	var out []sessionStatus
+	index := make(map[string]int)
+	for _, rec := range records {
+		index[rec.ID] = len(out)
+		out = append(out, sessionStatus{ID: rec.ID, Created: rec.Created, Targets: rec.Targets()})
+	}
+	for _, st := range statuses {
+		for _, id := range st.Sessions {
+			i, ok := index[id]
+			if !ok {
+				i = len(out)
+				index[id] = i
+				out = append(out, sessionStatus{ID: id})
+			}
+			out[i].Workloads = append(out[i].Workloads, st)
+			// recorded targets are authoritative; others come from the workloads
+			if i >= len(records) && st.Target != "" && !slices.Contains(out[i].Targets, st.Target) {
+				out[i].Targets = append(out[i].Targets, st.Target)
+			}
+		}
+	}
+	for _, rec := range records {
+		s := &out[index[rec.ID]]
+		for _, sw := range rec.Workloads {
+			if !slices.ContainsFunc(s.Workloads, func(st k8s.TappedStatus) bool {
+				return st.Workload.Kind == sw.Kind && st.Workload.Name == sw.Name
+			}) {
+				s.Missing = append(s.Missing, string(sw.Kind)+"/"+sw.Name)
+			}
+		}
+	}
+	recorded := out[:len(records)]
+	rest := out[len(records):]
+	sort.Slice(rest, func(i, j int) bool { return rest[i].ID < rest[j].ID })
+	for i := range out {
+		out[i].total()
+	}
+	return append(recorded, rest...)
+}
+
 type receiverMetrics struct {
 	logsReceived string
 	diskUsage    string
@@ -6,6 +6,10 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/k8s"
+	"github.com/ppiankov/logtap/internal/sidecar"
 )
 
 type roundTripFunc func(*http.Request) (*http.Response, error)
@@ -57,3 +61,32 @@ func TestFetchReceiverMetrics(t *testing.T) {
 		}
 	})
 }
+
+func TestSessionStatuses(t *testing.T) {
+	created := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	records := []*sidecar.Session{{ID: "lt-b", Created: created, Workloads: []sidecar.SessionWorkload{
+		{Kind: k8s.KindDeployment, Name: "api", Target: "recv:3100"},
+		{Kind: k8s.KindDeployment, Name: "web", Target: "recv:3100"},
+		{Kind: k8s.KindDaemonSet, Name: "gone", Target: "recv:3100"},
+	}}}
+	statuses := []k8s.TappedStatus{
+		{Workload: &k8s.Workload{Kind: k8s.KindDeployment, Name: "api"}, Sessions: []string{"lt-b", "lt-z"}, Target: "recv:3100", Ready: 2, Total: 3, NotDelivering: 1},
+		{Workload: &k8s.Workload{Kind: k8s.KindDeployment, Name: "web"}, Sessions: []string{"lt-b"}, Target: "recv:3100", Ready: 1, Total: 1},
+		{Workload: &k8s.Workload{Kind: k8s.KindStatefulSet, Name: "db"}, Sessions: []string{"lt-a"}, Target: "other:3100", Ready: 1, Total: 1},
+	}
+
+	got := sessionStatuses(records, statuses)
+	if len(got) != 3 || got[0].ID != "lt-b" || got[1].ID != "lt-a" || got[2].ID != "lt-z" {
+		t.Fatalf("sessions = %+v", got)
+	}
+	b := got[0]
+	if len(b.Workloads) != 2 || b.Ready != 3 || b.Total != 4 || b.NotDelivering != 1 || !b.Created.Equal(created) {
+		t.Errorf("lt-b = %+v", b)
+	}
+	if len(b.Missing) != 1 || b.Missing[0] != "DaemonSet/gone" {
+		t.Errorf("lt-b missing = %v", b.Missing)
+	}
+	if a := got[1]; len(a.Targets) != 1 || a.Targets[0] != "other:3100" || !a.Created.IsZero() {
+		t.Errorf("unrecorded lt-a = %+v", a)
+	}
+}
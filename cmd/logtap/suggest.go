@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/cli"
+)
+
+// commandGroups sorts the subcommands into sections of the root help;
+// commands not listed appear under "Additional Commands".
+var commandGroups = []struct {
+	id, title string
+	commands  []string
+}{
+	{"capture", "Capture:", []string{"recv", "watch", "tail", "query"}},
+	{"analyze", "Analyze:", []string{"open", "inspect", "stats", "grep", "sql", "slice", "export", "triage", "report", "assert", "diff", "merge"}},
+	{"cluster", "Cluster:", []string{"tap", "untap", "check", "status", "deploy"}},
+	{"storage", "Storage:", []string{"catalog", "use", "snapshot", "upload", "download", "sign", "verify", "compact", "migrate", "gc"}},
+}
+
+// groupCommands assigns root's subcommands to commandGroups.
+func groupCommands(root *cobra.Command) {
+	groupOf := make(map[string]string)
+	for _, g := range commandGroups {
+		root.AddGroup(&cobra.Group{ID: g.id, Title: g.title})
+		for _, name := range g.commands {
+			groupOf[name] = g.id
+		}
+	}
+	for _, c := range root.Commands() {
+		if id, ok := groupOf[c.Name()]; ok {
+			c.GroupID = id
+		}
+	}
+}
+
+// unknownCommandError returns a usage error naming the closest commands
+// when args start with a subcommand of root, or of one of its command
+// groups such as config, that does not exist. It returns nil otherwise and
+// leaves the rest to cobra.
+func unknownCommandError(root *cobra.Command, args []string) error {
+	root.InitDefaultHelpCmd()
+	cmd, rest, _ := root.Find(args) // cobra's own unknown command error is replaced below
+	if cmd == nil || cmd.Runnable() || !cmd.HasAvailableSubCommands() {
+		return nil
+	}
+	if len(rest) == 0 || strings.HasPrefix(rest[0], "-") {
+		return nil
+	}
+	typed := rest[0]
+	if typed == cobra.ShellCompRequestCmd || typed == cobra.ShellCompNoDescRequestCmd {
+		return nil // added by cobra on execution
+	}
+	msg := fmt.Sprintf("unknown command '%s'", typed)
+	if cmd != root {
+		msg = fmt.Sprintf("unknown command '%s' for '%s'", typed, cmd.CommandPath())
+	}
+	suggestions := suggestCommands(cmd, typed)
+	if len(suggestions) == 0 {
+		return cli.NewUsageError(fmt.Sprintf("%s; run '%s --help' for a list of commands", msg, cmd.CommandPath()))
+	}
+	quoted := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		quoted[i] = "'" + s + "'"
+	}
+	return cli.NewUsageError(fmt.Sprintf("%s, did you mean %s?", msg, strings.Join(quoted, " or ")))
+}
+
+// suggestCommands returns up to three subcommands of cmd close to typed,
+// closest first: names it abbreviates, and names within one edit (two for
+// longer words), counting a swap of adjacent letters as one edit.
+func suggestCommands(cmd *cobra.Command, typed string) []string {
+	typed = strings.ToLower(typed)
+	maxDist := 1
+	if len(typed) > 4 {
+		maxDist = 2
+	}
+
+	type candidate struct {
+		name string
+		dist int
+	}
+	var found []candidate
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() {
+			continue
+		}
+		best := -1
+		for _, name := range append([]string{c.Name()}, c.Aliases...) {
+			d := editDistance(typed, name)
+			if len(typed) >= 2 && strings.HasPrefix(name, typed) {
+				d = 0
+			}
+			if best < 0 || d < best {
+				best = d
+			}
+		}
+		for _, s := range c.SuggestFor {
+			if strings.EqualFold(s, typed) {
+				best = 0
+			}
+		}
+		if best <= maxDist {
+			found = append(found, candidate{c.Name(), best})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].dist < found[j].dist })
+
+	var out []string
+	for _, c := range found {
+		if len(out) == 3 {
+			break
+		}
+		out = append(out, c.name)
+	}
+	return out
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions and swaps of adjacent characters.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
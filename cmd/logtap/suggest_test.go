@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/cli"
+)
+
+func suggestTestRoot() *cobra.Command {
+	root := &cobra.Command{Use: "logtap"}
+	run := func(*cobra.Command, []string) {}
+	for _, name := range []string{"grep", "slice", "gc", "use", "tail", "triage", "inspect"} {
+		root.AddCommand(&cobra.Command{Use: name, Run: run})
+	}
+	config := &cobra.Command{Use: "config"}
+	config.AddCommand(&cobra.Command{Use: "lint", Run: run})
+	root.AddCommand(config)
+	return root
+}
+
+func TestUnknownCommandError(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string // "" for no error
+	}{
+		{[]string{"gerp"}, "unknown command 'gerp', did you mean 'grep'?"},
+		{[]string{"sl"}, "did you mean 'slice'?"},
+		{[]string{"tial", "-f"}, "did you mean 'tail'?"},
+		{[]string{"insepct"}, "did you mean 'inspect'?"},
+		{[]string{"config", "lnt"}, "unknown command 'lnt' for 'logtap config', did you mean 'lint'?"},
+		{[]string{"frobnicate"}, "run 'logtap --help' for a list of commands"},
+		{[]string{"grep", "gerp"}, ""},
+		{[]string{"help"}, ""},
+		{[]string{"config"}, ""},
+		{[]string{"--timeout", "1s"}, ""},
+		{nil, ""},
+		{[]string{"__complete", "gr"}, ""},
+	}
+	for _, tt := range tests {
+		err := unknownCommandError(suggestTestRoot(), tt.args)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", tt.args, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: err = %v, want %q", tt.args, err, tt.want)
+			continue
+		}
+		if cli.ExitCode(err) != cli.ExitUsage {
+			t.Errorf("%v: exit code %d, want %d", tt.args, cli.ExitCode(err), cli.ExitUsage)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"grep", "grep", 0},
+		{"gerp", "grep", 1},
+		{"slcie", "slice", 1},
+		{"trige", "triage", 1},
+		{"xyz", "grep", 4},
+		{"", "gc", 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGroupCommands(t *testing.T) {
+	root := suggestTestRoot()
+	groupCommands(root)
+	for _, c := range root.Commands() {
+		want := map[string]string{"grep": "analyze", "slice": "analyze", "triage": "analyze", "inspect": "analyze",
+			"gc": "storage", "use": "storage", "tail": "capture"}[c.Name()]
+		if c.GroupID != want {
+			t.Errorf("%s: group %q, want %q", c.Name(), c.GroupID, want)
+		}
+	}
+
+	// no command is in two groups; housekeeping commands stay ungrouped
+	ungrouped := map[string]bool{"version": true, "completion": true, "config": true, "init": true}
+	grouped := make(map[string]bool)
+	for _, g := range commandGroups {
+		for _, name := range g.commands {
+			if grouped[name] {
+				t.Errorf("%s is in two groups", name)
+			}
+			grouped[name] = true
+		}
+	}
+	for name := range ungrouped {
+		if grouped[name] {
+			t.Errorf("%s should be under Additional Commands", name)
+		}
+	}
+}
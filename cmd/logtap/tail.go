@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/k8s"
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// tailOpts holds the flags of tail.
+type tailOpts struct {
+	receiver    string
+	labels      []string
+	grep        string
+	lines       int
+	format      string
+	authToken   string
+	portForward bool
+	namespace   string
+	pod         string
+	remotePort  int
+}
+
+func newTailCmd() *cobra.Command {
+	var opts tailOpts
+
+	cmd := &cobra.Command{
+		Use:   "tail [session]",
+		Short: "Stream a running receiver's entries as they arrive",
+		Long: `Tail connects to a running receiver's live tail WebSocket and prints
+entries matching the label and regex filters as they arrive, until
+interrupted. A session argument (e.g. lt-a3f9 from 'logtap tap') shows only
+that session's entries.
+
+With --port-forward it reaches an in-cluster receiver pod through a
+Kubernetes port-forward instead of --receiver.`,
+		Example: `  logtap tail
+  logtap tail lt-a3f9 --grep 'timeout|refused'
+  logtap tail --label app=api --format json
+  logtap tail --port-forward --namespace logtap --auth-token $TOKEN`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var session string
+			if len(args) == 1 {
+				session = args[0]
+			}
+			ctx, cancel := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer cancel()
+			return runTail(ctx, session, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.receiver, "receiver", "127.0.0.1:3100", "receiver address")
+	cmd.Flags().StringSliceVar(&opts.labels, "label", nil, "label filter (key=value, repeatable)")
+	cmd.Flags().StringVar(&opts.grep, "grep", "", "regex filter on the message or any label value")
+	cmd.Flags().IntVarP(&opts.lines, "lines", "n", 10, "recent matching entries to show first (max 10000)")
+	cmd.Flags().StringVar(&opts.format, "format", "text", "output format: text or json")
+	cmd.Flags().StringVar(&opts.authToken, "auth-token", "", "bearer token for receivers started with --auth-token")
+	cmd.Flags().BoolVar(&opts.portForward, "port-forward", false, "reach the receiver pod through a Kubernetes port-forward")
+	cmd.Flags().StringVar(&opts.namespace, "namespace", "logtap", "with --port-forward, the receiver pod's namespace")
+	cmd.Flags().StringVar(&opts.pod, "pod", k8s.ReceiverName, "with --port-forward, the receiver pod")
+	cmd.Flags().IntVar(&opts.remotePort, "remote-port", 9000, "with --port-forward, the port the receiver pod listens on")
+
+	return cmd
+}
+
+func runTail(ctx context.Context, session string, opts tailOpts) error {
+	if opts.format != "json" && opts.format != "text" {
+		return fmt.Errorf("invalid --format %q: use json or text", opts.format)
+	}
+	if opts.portForward {
+		port, stop, err := portForwardPod(ctx, opts.namespace, opts.pod, opts.remotePort)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		opts.receiver = fmt.Sprintf("127.0.0.1:%d", port)
+	}
+
+	conn, err := dialTail(ctx, session, opts)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		var e recv.LogEntry
+		if err := conn.ReadJSON(&e); err != nil {
+			if ctx.Err() != nil || websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return fmt.Errorf("tail: %w", err)
+		}
+		if opts.format == "json" {
+			_ = enc.Encode(e)
+			continue
+		}
+		printTextLine(e, 0, textTimeLayout(""))
+	}
+}
+
+// dialTail opens the receiver's live tail WebSocket.
+func dialTail(ctx context.Context, session string, opts tailOpts) (*websocket.Conn, error) {
+	base := opts.receiver
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	u, err := url.Parse(strings.TrimRight(base, "/") + "/logtap/api/v1/tail")
+	if err != nil {
+		return nil, fmt.Errorf("invalid --receiver: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	params := url.Values{}
+	for _, l := range opts.labels {
+		params.Add("label", l)
+	}
+	if session != "" {
+		params.Add("label", "session="+session)
+	}
+	if opts.grep != "" {
+		params.Set("grep", opts.grep)
+	}
+	params.Set("backlog", strconv.Itoa(opts.lines))
+	u.RawQuery = params.Encode()
+
+	header := http.Header{}
+	if opts.authToken != "" {
+		header.Set("Authorization", "Bearer "+opts.authToken)
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second, Proxy: http.ProxyFromEnvironment}
+	conn, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			return nil, fmt.Errorf("receiver returned %s", resp.Status)
+		}
+		return nil, fmt.Errorf("contact receiver: %w", err)
+	}
+	return conn, nil
+}
+
+// portForwardPod forwards a local port to port of pod and returns it with a
+// function that stops the forward.
+func portForwardPod(ctx context.Context, namespace, pod string, port int) (int, func(), error) {
+	c, err := newK8sClient(namespace)
+	if err != nil {
+		return 0, nil, fmt.Errorf("connect to cluster: %w", err)
+	}
+	spec := k8s.PortForwardSpec{Namespace: c.NS, PodName: pod, RemotePort: port}
+	tunnel, err := k8s.NewPortForwardTunnel(c.RestConfig, c.CS, spec, os.Stderr, os.Stderr)
+	if err != nil {
+		return 0, nil, fmt.Errorf("create port-forward: %w", err)
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- tunnel.Run() }()
+
+	select {
+	case <-tunnel.ReadyCh():
+	case err := <-errCh:
+		return 0, nil, fmt.Errorf("port-forward failed: %w", err)
+	case <-ctx.Done():
+		tunnel.Stop()
+		return 0, nil, ctx.Err()
+	}
+	local, err := tunnel.GetLocalPort()
+	if err != nil {
+		tunnel.Stop()
+		return 0, nil, fmt.Errorf("get local port: %w", err)
+	}
+	return local, tunnel.Stop, nil
+}
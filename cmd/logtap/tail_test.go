@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestRunTail(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_ = conn.WriteJSON(recv.LogEntry{
+			Timestamp: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+			Labels:    map[string]string{"app": "api", "session": "lt-a3f9"},
+			Message:   "upstream timeout",
+		})
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+	}))
+	defer srv.Close()
+
+	out := captureStdout(t, func() {
+		err := runTail(context.Background(), "lt-a3f9", tailOpts{
+			receiver:  srv.URL,
+			labels:    []string{"app=api"},
+			grep:      "timeout",
+			lines:     5,
+			format:    "text",
+			authToken: "s3cret",
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	if got.URL.Path != "/logtap/api/v1/tail" {
+		t.Fatalf("path = %s", got.URL.Path)
+	}
+	q := got.URL.Query()
+	if strings.Join(q["label"], ",") != "app=api,session=lt-a3f9" || q.Get("grep") != "timeout" || q.Get("backlog") != "5" {
+		t.Errorf("query = %s", got.URL.RawQuery)
+	}
+	if got.Header.Get("Authorization") != "Bearer s3cret" {
+		t.Errorf("authorization = %q", got.Header.Get("Authorization"))
+	}
+	if !strings.Contains(out, "[api] upstream timeout") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestRunTail_ReceiverError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	err := runTail(context.Background(), "", tailOpts{receiver: srv.URL, format: "json"})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("err = %v, want the receiver's status", err)
+	}
+	if err := runTail(context.Background(), "", tailOpts{receiver: srv.URL, format: "yaml"}); err == nil {
+		t.Error("expected error for invalid --format")
+	}
+}
@@ -3,35 +3,45 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ppiankov/logtap/internal/forward"
 	"github.com/ppiankov/logtap/internal/k8s"
+	"github.com/ppiankov/logtap/internal/recv"
 	"github.com/ppiankov/logtap/internal/sidecar"
 )
 
 func newTapCmd() *cobra.Command {
 	var (
-		deployment    string
-		statefulset   string
-		daemonset     string
+		deployments   []string
+		statefulsets  []string
+		daemonsets    []string
 		namespace     string
 		selector      string
 		all           bool
+		targets       []string
 		target        string
+		routes        []sidecar.TargetRoute
 		forwarder     string
 		dryRun        bool
 		force         bool
+		yes           bool
 		allowProd     bool
 		image         string
 		sidecarMemory string
 		sidecarCPU    string
 		noRollback    bool
 		pinImages     bool
+		sanitize      string
+		authToken     string
+		spoolSize     string
+		tlsPin        string
 	)
 
 	cmd := &cobra.Command{
@@ -40,7 +50,8 @@ func newTapCmd() *cobra.Command {
 		Long:  "Tap patches Kubernetes workloads to add a logtap log-forwarding sidecar container. The sidecar sends logs to the logtap receiver via the Loki push API.",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			applyConfigDefaults(cmd)
-			if err := sidecar.ValidateTarget(target); err != nil {
+			var err error
+			if target, routes, err = sidecar.ParseTargets(targets); err != nil {
 				return err
 			}
 			if err := validateQuantity("--sidecar-memory", sidecarMemory); err != nil {
@@ -49,80 +60,117 @@ func newTapCmd() *cobra.Command {
 			if err := validateQuantity("--sidecar-cpu", sidecarCPU); err != nil {
 				return err
 			}
+			if _, err := forward.ParseSanitize(sanitize); err != nil {
+				return fmt.Errorf("--sanitize: %w", err)
+			}
+			if authToken != "" && forwarder == sidecar.ForwarderFluentBit {
+				return fmt.Errorf("--auth-token is not supported with --forwarder %s", sidecar.ForwarderFluentBit)
+			}
+			if err := validateQuantity("--spool-size", spoolSize); err != nil {
+				return err
+			}
+			if spoolSize != "" && forwarder == sidecar.ForwarderFluentBit {
+				return fmt.Errorf("--spool-size is not supported with --forwarder %s", sidecar.ForwarderFluentBit)
+			}
+			if tlsPin != "" {
+				if forwarder == sidecar.ForwarderFluentBit {
+					return fmt.Errorf("--tls-pin is not supported with --forwarder %s", sidecar.ForwarderFluentBit)
+				}
+				if _, err := forward.ParsePin(tlsPin); err != nil {
+					return fmt.Errorf("--tls-pin: %w", err)
+				}
+				// a pin only means something on a TLS connection
+				for _, t := range append([]string{target}, routeTargets(routes)...) {
+					if t != "" && !strings.HasPrefix(t, "https://") {
+						return fmt.Errorf("--tls-pin requires https:// targets, got %q", t)
+					}
+				}
+			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runTap(tapOpts{
-				deployment:    deployment,
-				statefulset:   statefulset,
-				daemonset:     daemonset,
+				deployments:   deployments,
+				statefulsets:  statefulsets,
+				daemonsets:    daemonsets,
 				namespace:     namespace,
 				selector:      selector,
 				all:           all,
 				target:        target,
+				routes:        routes,
 				forwarder:     forwarder,
 				dryRun:        dryRun,
 				force:         force,
+				yes:           yes,
 				allowProd:     allowProd,
 				image:         image,
 				sidecarMemory: sidecarMemory,
 				sidecarCPU:    sidecarCPU,
 				noRollback:    noRollback,
 				pinImages:     pinImages,
+				sanitize:      sanitize,
+				authToken:     authToken,
+				spoolSize:     spoolSize,
+				tlsPin:        tlsPin,
 			})
 		},
 	}
 
-	cmd.Flags().StringVar(&deployment, "deployment", "", "deployment name")
-	cmd.Flags().StringVar(&statefulset, "statefulset", "", "statefulset name")
-	cmd.Flags().StringVar(&daemonset, "daemonset", "", "daemonset name")
+	cmd.Flags().StringSliceVar(&deployments, "deployment", nil, "deployment name (repeatable or comma-separated; combines with --statefulset and --daemonset into one session)")
+	cmd.Flags().StringSliceVar(&statefulsets, "statefulset", nil, "statefulset name (repeatable or comma-separated)")
+	cmd.Flags().StringSliceVar(&daemonsets, "daemonset", nil, "daemonset name (repeatable or comma-separated)")
 	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace (defaults to current context)")
 	cmd.Flags().StringVarP(&selector, "selector", "l", "", "label selector")
 	cmd.Flags().BoolVar(&all, "all", false, "tap all workloads in namespace (requires --force)")
-	cmd.Flags().StringVar(&target, "target", "", "receiver address (required)")
+	cmd.Flags().StringArrayVar(&targets, "target", nil, "receiver address (required); pattern=address routes workloads whose name matches the glob to their own receiver (repeatable)")
 	cmd.Flags().StringVar(&forwarder, "forwarder", sidecar.ForwarderLogtap, "forwarder type (logtap or fluent-bit)")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show diff without applying")
 	cmd.Flags().BoolVar(&force, "force", false, "proceed despite warnings")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "tap several workloads without showing the combined diff and asking to confirm")
 	cmd.Flags().BoolVar(&allowProd, "allow-prod", false, "allow tapping production namespaces")
 	cmd.Flags().StringVar(&image, "image", sidecar.DefaultImage, "forwarder sidecar image")
 	cmd.Flags().StringVar(&sidecarMemory, "sidecar-memory", sidecar.DefaultMemReq, "sidecar memory request (limit = 2x)")
 	cmd.Flags().StringVar(&sidecarCPU, "sidecar-cpu", sidecar.DefaultCPUReq, "sidecar CPU request (limit = 2x)")
 	cmd.Flags().BoolVar(&noRollback, "no-rollback", false, "disable auto-rollback on partial failure")
 	cmd.Flags().BoolVar(&pinImages, "pin-images", false, "change imagePullPolicy from Always to IfNotPresent on existing containers")
+	cmd.Flags().StringVar(&sanitize, "sanitize", "", "strip ANSI escapes and/or control characters in the forwarder before push (ansi, control, all)")
+	cmd.Flags().StringVar(&authToken, "auth-token", "", "receiver --auth-token; sidecars get a token derived for this session instead of the token itself")
+	cmd.Flags().StringVar(&spoolSize, "spool-size", "", "give each forwarder an emptyDir of this size (e.g. 256Mi) to spool batches to while the receiver is unreachable")
+	cmd.Flags().StringVar(&tlsPin, "tls-pin", "", "SHA-256 fingerprint of the receiver's certificate, as printed by recv --tls-self-signed; forwarders trust only that certificate")
 	_ = cmd.MarkFlagRequired("target")
 
 	return cmd
 }
 
 type tapOpts struct {
-	deployment    string
-	statefulset   string
-	daemonset     string
+	deployments   []string
+	statefulsets  []string
+	daemonsets    []string
 	namespace     string
 	selector      string
 	all           bool
-	target        string
+	target        string                // default receiver; empty when every workload is routed
+	routes        []sidecar.TargetRoute // per workload group receivers, first match wins
 	forwarder     string
 	dryRun        bool
 	force         bool
+	yes           bool // skip the confirmation of a multi-workload tap
 	allowProd     bool
 	image         string
 	sidecarMemory string
 	sidecarCPU    string
 	noRollback    bool
 	pinImages     bool
+	sanitize      string
+	authToken     string // receiver token; sidecars get the session token
+	spoolSize     string // emptyDir size limit for the forwarder spool
+	tlsPin        string // receiver certificate fingerprint for the forwarders
 }
 
 func runTap(opts tapOpts) error {
-	// Validate targeting mode: exactly one of deployment/statefulset/daemonset/selector/all
+	// Validate targeting mode: named workloads, a selector, or all
 	modes := 0
-	if opts.deployment != "" {
-		modes++
-	}
-	if opts.statefulset != "" {
-		modes++
-	}
-	if opts.daemonset != "" {
+	if len(opts.deployments)+len(opts.statefulsets)+len(opts.daemonsets) > 0 {
 		modes++
 	}
 	if opts.selector != "" {
@@ -135,7 +183,7 @@ func runTap(opts tapOpts) error {
 		return fmt.Errorf("specify one of --deployment, --statefulset, --daemonset, --selector, or --all")
 	}
 	if modes > 1 {
-		return fmt.Errorf("specify only one of --deployment, --statefulset, --daemonset, --selector, or --all")
+		return fmt.Errorf("specify only one of named workloads (--deployment, --statefulset, --daemonset), --selector, or --all")
 	}
 	if opts.all && !opts.dryRun && !opts.force {
 		return fmt.Errorf("--all requires --force to confirm bulk tapping (or use --dry-run)")
@@ -146,12 +194,15 @@ func runTap(opts tapOpts) error {
 	if opts.forwarder == sidecar.ForwarderFluentBit && opts.image == sidecar.DefaultImage {
 		return fmt.Errorf("--image is required when using --forwarder fluent-bit (no default Fluent Bit image)")
 	}
+	if opts.forwarder == sidecar.ForwarderFluentBit && opts.sanitize != "" {
+		return fmt.Errorf("--sanitize is only supported with --forwarder logtap")
+	}
 
 	ctx, cancel := clusterContext()
 	defer cancel()
 
 	// Build k8s client
-	c, err := k8s.NewClient(opts.namespace)
+	c, err := newK8sClient(opts.namespace)
 	if err != nil {
 		return fmt.Errorf("connect to cluster: %w", err)
 	}
@@ -168,35 +219,15 @@ func runTap(opts tapOpts) error {
 		fmt.Fprintf(os.Stderr, "WARNING: tapping production namespace %q\n", c.NS)
 	}
 
-	// Pre-check receiver reachability
-	if !opts.force {
-		if err := checkReceiver(opts.target); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: receiver not reachable: %v (use --force to proceed)\n", err)
-			return fmt.Errorf("receiver pre-check failed (use --force to proceed): %w", err)
-		}
-	}
-
 	// Discover workloads
 	var workloads []*k8s.Workload
 	switch {
-	case opts.deployment != "":
-		w, err := k8s.DiscoverByName(ctx, c, k8s.KindDeployment, opts.deployment)
-		if err != nil {
-			return err
-		}
-		workloads = []*k8s.Workload{w}
-	case opts.statefulset != "":
-		w, err := k8s.DiscoverByName(ctx, c, k8s.KindStatefulSet, opts.statefulset)
-		if err != nil {
-			return err
-		}
-		workloads = []*k8s.Workload{w}
-	case opts.daemonset != "":
-		w, err := k8s.DiscoverByName(ctx, c, k8s.KindDaemonSet, opts.daemonset)
+	case opts.selector == "" && !opts.all:
+		wl, err := discoverNamed(ctx, c, opts.deployments, opts.statefulsets, opts.daemonsets)
 		if err != nil {
 			return err
 		}
-		workloads = []*k8s.Workload{w}
+		workloads = wl
 	case opts.selector != "":
 		wl, err := k8s.DiscoverBySelector(ctx, c, opts.selector)
 		if err != nil {
@@ -222,6 +253,22 @@ func runTap(opts tapOpts) error {
 		}
 	}
 
+	// Route each workload to its receiver
+	targets, err := routeWorkloads(workloads, opts.target, opts.routes)
+	if err != nil {
+		return err
+	}
+
+	// Pre-check receiver reachability
+	if !opts.force {
+		for _, target := range distinctTargets(workloads, targets) {
+			if err := checkReceiver(target); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: receiver %s not reachable: %v (use --force to proceed)\n", target, err)
+				return fmt.Errorf("receiver pre-check failed (use --force to proceed): %w", err)
+			}
+		}
+	}
+
 	// Ensure RBAC for forwarder sidecar
 	saSet := make(map[string]bool)
 	for _, w := range workloads {
@@ -248,7 +295,7 @@ func runTap(opts tapOpts) error {
 	// Resource pre-checks
 	if !opts.force {
 		for _, w := range workloads {
-			warnings, err := k8s.CheckResources(ctx, c, w.Replicas, opts.sidecarMemory, opts.sidecarCPU)
+			warnings, err := k8s.CheckResources(ctx, c, w, opts.sidecarMemory, opts.sidecarCPU, memLimit, cpuLimit)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: resource check failed: %v\n", err)
 			}
@@ -261,7 +308,6 @@ func runTap(opts tapOpts) error {
 	// Build sidecar config
 	scfg := sidecar.SidecarConfig{
 		SessionID:  sessionID,
-		Target:     opts.target,
 		Image:      opts.image,
 		Forwarder:  opts.forwarder,
 		MemRequest: opts.sidecarMemory,
@@ -269,6 +315,12 @@ func runTap(opts tapOpts) error {
 		CPURequest: opts.sidecarCPU,
 		CPULimit:   cpuLimit,
 		PinImages:  opts.pinImages,
+		Sanitize:   opts.sanitize,
+		SpoolSize:  opts.spoolSize,
+		TLSPin:     opts.tlsPin,
+	}
+	if opts.authToken != "" {
+		scfg.AuthToken = recv.SessionToken(opts.authToken, sessionID)
 	}
 
 	// Warn about imagePullPolicy: Always
@@ -285,42 +337,173 @@ func runTap(opts tapOpts) error {
 		}
 	}
 
+	// Plan every workload before changing any, so the tap is shown and
+	// confirmed as one session
+	session := &sidecar.Session{ID: sessionID, Created: time.Now().UTC(), Forwarder: opts.forwarder}
+	var plans []*sidecar.InjectResult
+	for _, w := range workloads {
+		wcfg := scfg
+		wcfg.Target = targets[w]
+		result, err := sidecar.Inject(ctx, c, w, wcfg, true)
+		if err != nil {
+			return fmt.Errorf("inject %s/%s: %w", w.Kind, w.Name, err)
+		}
+		plans = append(plans, result)
+		session.Workloads = append(session.Workloads, sidecar.SessionWorkload{Kind: w.Kind, Name: w.Name, Target: wcfg.Target})
+	}
+
+	confirm := len(workloads) > 1 && !opts.force && !opts.yes && stdinIsTerminal()
+	if opts.dryRun || confirm {
+		prefix := "[dry-run] "
+		if !opts.dryRun {
+			prefix = ""
+		}
+		for _, p := range plans {
+			fmt.Fprintf(os.Stderr, "%s%s/%s:\n", prefix, p.Workload.Kind, p.Workload.Name)
+			_, _ = fmt.Fprintln(os.Stdout, p.Diff)
+		}
+		fmt.Fprintf(os.Stderr, "  Note: ensure terminationGracePeriodSeconds >= 10 for graceful sidecar drain\n")
+		impact, err := k8s.EstimateImpact(ctx, c, workloads, opts.sidecarMemory, opts.sidecarCPU, memLimit, cpuLimit, k8s.DefaultImpactSampleWindow)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: impact estimate failed: %v\n", err)
+		} else {
+			printImpact(os.Stderr, impact, prefix)
+		}
+	}
+	if opts.dryRun {
+		return nil
+	}
+	if confirm {
+		ok, err := confirmPrompt(os.Stderr, fmt.Sprintf("\nTap %d workloads as session %s?", len(workloads), sessionID))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("tap cancelled")
+		}
+	}
+
+	// The session is recorded first, so untap finds every workload even
+	// when the tap is interrupted
+	if err := sidecar.SaveSession(ctx, c, session, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; untap will find the workloads by annotation\n", err)
+	}
+
 	// Inject into each workload with progress and rollback
 	var tapped []*k8s.Workload
-	total := len(workloads)
-
 	for i, w := range workloads {
-		if !opts.dryRun && total > 1 {
-			fmt.Fprintf(os.Stderr, "Tapping %s/%s [%d/%d]...\n", w.Kind, w.Name, i+1, total)
+		if len(workloads) > 1 {
+			fmt.Fprintf(os.Stderr, "Tapping %s/%s [%d/%d]...\n", w.Kind, w.Name, i+1, len(workloads))
 		}
 
-		result, err := sidecar.Inject(ctx, c, w, scfg, opts.dryRun)
-		if err != nil {
-			if !opts.dryRun && !opts.noRollback && len(tapped) > 0 {
+		wcfg := scfg
+		wcfg.Target = targets[w]
+		if _, err := sidecar.Inject(ctx, c, w, wcfg, false); err != nil {
+			switch {
+			case len(tapped) == 0:
+				_ = sidecar.DeleteSession(ctx, c, sessionID, false)
+			case opts.noRollback:
+				// keep the session to the workloads actually tapped
+				for _, rest := range workloads[i:] {
+					session = session.Without(rest)
+				}
+				_ = sidecar.SaveSession(ctx, c, session, false)
+			default:
 				rollbackTap(ctx, c, tapped, sessionID)
 			}
 			return fmt.Errorf("inject %s/%s: %w", w.Kind, w.Name, err)
 		}
-
-		if opts.dryRun {
-			fmt.Fprintf(os.Stderr, "[dry-run] %s/%s:\n", w.Kind, w.Name)
-			_, _ = fmt.Fprintln(os.Stdout, result.Diff)
-			fmt.Fprintf(os.Stderr, "  Note: ensure terminationGracePeriodSeconds >= 10 for graceful sidecar drain\n")
+		tapped = append(tapped, w)
+		if len(opts.routes) > 0 {
+			fmt.Fprintf(os.Stderr, "Tapped %s/%s → %s (session %s)\n", w.Kind, w.Name, wcfg.Target, sessionID)
 		} else {
-			tapped = append(tapped, w)
 			fmt.Fprintf(os.Stderr, "Tapped %s/%s (session %s)\n", w.Kind, w.Name, sessionID)
 		}
 	}
 
-	if !opts.dryRun {
-		fmt.Fprintf(os.Stderr, "\nSession: %s\n", sessionID)
-		fmt.Fprintf(os.Stderr, "Target:  %s\n", opts.target)
-		fmt.Fprintf(os.Stderr, "Use 'logtap untap --session %s' to remove\n", sessionID)
+	fmt.Fprintf(os.Stderr, "\nSession: %s (%d workload(s))\n", sessionID, len(workloads))
+	for _, target := range session.Targets() {
+		fmt.Fprintf(os.Stderr, "Target:  %s\n", target)
 	}
+	fmt.Fprintf(os.Stderr, "Use 'logtap status --session %s' to follow it and 'logtap untap --session %s' to remove it\n", sessionID, sessionID)
 
 	return nil
 }
 
+// discoverNamed looks up workloads given by name, each once.
+func discoverNamed(ctx context.Context, c *k8s.Client, deployments, statefulsets, daemonsets []string) ([]*k8s.Workload, error) {
+	var workloads []*k8s.Workload
+	seen := make(map[string]bool)
+	for _, group := range []struct {
+		kind  k8s.WorkloadKind
+		names []string
+	}{
+		{k8s.KindDeployment, deployments},
+		{k8s.KindStatefulSet, statefulsets},
+		{k8s.KindDaemonSet, daemonsets},
+	} {
+		for _, name := range group.names {
+			key := string(group.kind) + "/" + name
+			if name == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			w, err := k8s.DiscoverByName(ctx, c, group.kind, name)
+			if err != nil {
+				return nil, err
+			}
+			workloads = append(workloads, w)
+		}
+	}
+	if len(workloads) == 0 {
+		return nil, fmt.Errorf("no workload names given")
+	}
+	return workloads, nil
+}
+
+// printImpact summarizes what confirming the tap would cost. prefix marks
+// the header, e.g. "[dry-run] ".
+func printImpact(w io.Writer, impact *k8s.Impact, prefix string) {
+	_, _ = fmt.Fprintf(w, "\n%sImpact: %d workload(s), %d replica(s)\n", prefix, len(impact.Workloads), impact.Replicas)
+	_, _ = fmt.Fprintf(w, "  Extra requests:  memory %s, cpu %s (limits %s, %s)\n",
+		impact.MemRequest, impact.CPURequest, impact.MemLimit, impact.CPULimit)
+	_, _ = fmt.Fprintf(w, "  Pod restarts:    %d (rolling update of every replica)\n", impact.Restarts)
+
+	bandwidth := "unknown (no running pods to sample)"
+	if sampled := len(impact.Workloads) - impact.Unsampled; sampled > 0 {
+		bandwidth = fmt.Sprintf("~%s/s (log volume over the last %s, %d of %d workload(s) sampled)",
+			formatBytes(int64(impact.BytesPerSec)), k8s.DefaultImpactSampleWindow, sampled, len(impact.Workloads))
+	}
+	_, _ = fmt.Fprintf(w, "  Receiver load:   %s\n", bandwidth)
+}
+
+// routeWorkloads resolves the receiver of each workload. Every workload
+// must match a route or fall back to the default target.
+func routeWorkloads(workloads []*k8s.Workload, def string, routes []sidecar.TargetRoute) (map[*k8s.Workload]string, error) {
+	targets := make(map[*k8s.Workload]string, len(workloads))
+	for _, w := range workloads {
+		target := sidecar.RouteTarget(w.Name, def, routes)
+		if target == "" {
+			return nil, fmt.Errorf("no --target matches %s/%s (add a default --target host:port)", w.Kind, w.Name)
+		}
+		targets[w] = target
+	}
+	return targets, nil
+}
+
+// distinctTargets lists the receivers in use, in workload order.
+func distinctTargets(workloads []*k8s.Workload, targets map[*k8s.Workload]string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, w := range workloads {
+		if t := targets[w]; !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
 func rollbackTap(ctx context.Context, c *k8s.Client, tapped []*k8s.Workload, sessionID string) {
 	fmt.Fprintf(os.Stderr, "\nRolling back %d tapped workload(s)...\n", len(tapped))
 	for _, w := range tapped {
@@ -329,6 +512,9 @@ func rollbackTap(ctx context.Context, c *k8s.Client, tapped []*k8s.Workload, ses
 			fmt.Fprintf(os.Stderr, "  rollback failed for %s/%s: %v\n", w.Kind, w.Name, err)
 		}
 	}
+	if err := sidecar.DeleteSession(ctx, c, sessionID, false); err != nil {
+		fmt.Fprintf(os.Stderr, "  %v\n", err)
+	}
 	fmt.Fprintln(os.Stderr, "Rollback complete")
 }
 
@@ -353,3 +539,11 @@ func doubleResource(req string) string {
 	}
 	return req
 }
+
+func routeTargets(routes []sidecar.TargetRoute) []string {
+	targets := make([]string, len(routes))
+	for i, r := range routes {
+		targets[i] = r.Target
+	}
+	return targets
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/ppiankov/logtap/internal/k8s"
 	"github.com/ppiankov/logtap/internal/sidecar"
 )
 
@@ -91,7 +92,7 @@ func TestRunTap_Validation(t *testing.T) {
 		},
 		{
 			name:    "multiple modes",
-			opts:    tapOpts{deployment: "foo", statefulset: "bar", target: "localhost:9000", forwarder: sidecar.ForwarderLogtap},
+			opts:    tapOpts{deployments: []string{"foo"}, selector: "app=bar", target: "localhost:9000", forwarder: sidecar.ForwarderLogtap},
 			wantErr: "specify only one of",
 		},
 		{
@@ -101,14 +102,19 @@ func TestRunTap_Validation(t *testing.T) {
 		},
 		{
 			name:    "invalid forwarder",
-			opts:    tapOpts{deployment: "foo", target: "localhost:9000", forwarder: "invalid"},
+			opts:    tapOpts{deployments: []string{"foo"}, target: "localhost:9000", forwarder: "invalid"},
 			wantErr: "must be",
 		},
 		{
 			name:    "fluent-bit without image",
-			opts:    tapOpts{deployment: "foo", target: "localhost:9000", forwarder: sidecar.ForwarderFluentBit, image: sidecar.DefaultImage},
+			opts:    tapOpts{deployments: []string{"foo"}, target: "localhost:9000", forwarder: sidecar.ForwarderFluentBit, image: sidecar.DefaultImage},
 			wantErr: "required when using",
 		},
+		{
+			name:    "fluent-bit with sanitize",
+			opts:    tapOpts{deployments: []string{"foo"}, target: "localhost:9000", forwarder: sidecar.ForwarderFluentBit, image: "fluent/fluent-bit:3", sanitize: "ansi"},
+			wantErr: "only supported with --forwarder logtap",
+		},
 	}
 
 	for _, tt := range tests {
@@ -180,3 +186,64 @@ func TestCheckReceiver_MockTransport(t *testing.T) {
 		}
 	})
 }
+
+func TestPrintImpact(t *testing.T) {
+	impact := &k8s.Impact{
+		Workloads:   []k8s.WorkloadImpact{{Name: "api", Sampled: true}, {Name: "worker"}},
+		Replicas:    5,
+		Restarts:    5,
+		MemRequest:  "80Mi",
+		CPURequest:  "125m",
+		MemLimit:    "160Mi",
+		CPULimit:    "250m",
+		BytesPerSec: 3 << 20,
+		Unsampled:   1,
+	}
+
+	var buf strings.Builder
+	printImpact(&buf, impact, "[dry-run] ")
+	out := buf.String()
+	for _, want := range []string{"2 workload(s), 5 replica(s)", "memory 80Mi, cpu 125m", "limits 160Mi, 250m", "Pod restarts:    5", "~3.0 MB/s", "1 of 2 workload(s) sampled"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	impact.Unsampled = 2
+	buf.Reset()
+	printImpact(&buf, impact, "[dry-run] ")
+	if !strings.Contains(buf.String(), "unknown") {
+		t.Errorf("expected unknown bandwidth when nothing sampled:\n%s", buf.String())
+	}
+}
+
+func TestRouteWorkloads(t *testing.T) {
+	pay := &k8s.Workload{Kind: k8s.KindDeployment, Name: "payments-api"}
+	web := &k8s.Workload{Kind: k8s.KindDeployment, Name: "web"}
+	routes := []sidecar.TargetRoute{{Pattern: "payments-*", Target: "receiver-a:3100"}}
+
+	targets, err := routeWorkloads([]*k8s.Workload{pay, web}, "receiver-b:3100", routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if targets[pay] != "receiver-a:3100" || targets[web] != "receiver-b:3100" {
+		t.Errorf("targets = %v", targets)
+	}
+	if got := distinctTargets([]*k8s.Workload{pay, web}, targets); len(got) != 2 || got[0] != "receiver-a:3100" {
+		t.Errorf("distinctTargets = %v", got)
+	}
+
+	_, err = routeWorkloads([]*k8s.Workload{pay, web}, "", routes)
+	if err == nil || !containsString(err.Error(), "no --target matches Deployment/web") {
+		t.Errorf("err = %v, want unrouted workload error", err)
+	}
+}
+
+func TestTapCmd_MultipleDefaultTargets(t *testing.T) {
+	cmd := newTapCmd()
+	cmd.SetArgs([]string{"--target", "a:3100", "--target", "b:3100", "--deployment", "foo"})
+	err := cmd.Execute()
+	if err == nil || !containsString(err.Error(), "default receiver already set") {
+		t.Errorf("err = %v, want duplicate default error", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"time"
 
@@ -21,19 +22,69 @@ func newTriageCmd() *cobra.Command {
 		maxSignatures int
 		jsonOutput    bool
 		htmlOutput    bool
+		corrWindowStr string
+		corrMaxLagStr string
+		corrMinConf   float64
+		corrLabel     string
+		ownersPath    string
+		profile       bool
+		noCache       bool
+		detect        bool
+		anomalyThresh float64
+		requestExpr   string
 	)
 
 	cmd := &cobra.Command{
-		Use:   "triage <capture-dir>",
+		Use:   "triage [capture-dir]",
 		Short: "Scan capture for anomalies and produce a summary report",
 		Long:  "Triage scans a capture directory for error patterns, volume spikes, and anomalies, producing a summary report with recommended slices.",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			captureDir, err := captureDirArg(args, 0)
+			if err != nil {
+				return err
+			}
 			window, err := time.ParseDuration(windowStr)
 			if err != nil {
 				return fmt.Errorf("invalid --window: %w", err)
 			}
-			return runTriage(args[0], outDir, jobs, window, top, maxSignatures, jsonOutput, htmlOutput)
+			corr := archive.CorrelateConfig{MinConfidence: &corrMinConf, ServiceLabel: corrLabel}
+			if corr.Window, err = time.ParseDuration(corrWindowStr); err != nil {
+				return fmt.Errorf("invalid --correlation-window: %w", err)
+			}
+			if corrMaxLagStr != "" {
+				if corr.MaxLag, err = time.ParseDuration(corrMaxLagStr); err != nil {
+					return fmt.Errorf("invalid --correlation-max-lag: %w", err)
+				}
+			}
+			if corrMinConf < 0 || corrMinConf >= 1 {
+				return fmt.Errorf("--correlation-min-confidence must be in [0, 1)")
+			}
+			var anomalies *archive.AnomalyConfig
+			if detect {
+				if anomalyThresh <= 0 {
+					return fmt.Errorf("--anomaly-threshold must be positive")
+				}
+				anomalies = &archive.AnomalyConfig{Threshold: anomalyThresh}
+			}
+			requestPattern, err := compileRequestPattern(requestExpr)
+			if err != nil {
+				return err
+			}
+			latency, err := latencyConfig(cmd)
+			if err != nil {
+				return err
+			}
+			owners, err := loadOwners(ownersPath, captureDir)
+			if err != nil {
+				return err
+			}
+			var cacheDir string
+			if !noCache {
+				// no usable cache dir only means every file is scanned
+				cacheDir, _ = archive.DefaultTriageCacheDir()
+			}
+			return runTriage(captureDir, outDir, jobs, window, top, maxSignatures, owners, corr, anomalies, requestPattern, latency, jsonOutput, htmlOutput, profile, cacheDir)
 		},
 	}
 
@@ -45,16 +96,37 @@ func newTriageCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output as JSON to stdout")
 	addFormatAlias(cmd, &jsonOutput)
 	cmd.Flags().BoolVar(&htmlOutput, "html", false, "generate self-contained HTML report")
+	cmd.Flags().StringVar(&corrWindowStr, "correlation-window", "10s", "error bucket width for cross-service correlation")
+	cmd.Flags().StringVar(&corrMaxLagStr, "correlation-max-lag", "", "longest cascade lag to consider (default 5 correlation windows)")
+	cmd.Flags().Float64Var(&corrMinConf, "correlation-min-confidence", 0.5, "discard correlations at or below this confidence (0-1)")
+	cmd.Flags().StringVar(&corrLabel, "correlation-label", "app", "label key that identifies a service for correlation")
+	cmd.Flags().StringVar(&ownersPath, "owners", "", ownersFlagUsage)
+	cmd.Flags().BoolVar(&profile, "profile", false, profileFlagUsage)
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "scan every file instead of reusing per-file results from earlier runs")
+	cmd.Flags().BoolVar(&detect, "detect-anomalies", false, "rank spike, drop and silence windows in line and error rates with a seasonal EWMA detector")
+	cmd.Flags().StringVar(&requestExpr, "request-pattern", "", requestPatternFlagUsage)
+	cmd.Flags().Float64Var(&anomalyThresh, "anomaly-threshold", archive.DefaultAnomalyThreshold, "anomaly score (standard deviations from expected) to flag a minute")
+	addLatencyFlags(cmd)
 
 	return cmd
 }
 
-func runTriage(src, outDir string, jobs int, window time.Duration, top, maxSignatures int, jsonOutput, htmlOutput bool) error {
+func runTriage(src, outDir string, jobs int, window time.Duration, top, maxSignatures int, owners *archive.Owners, corr archive.CorrelateConfig, anomalies *archive.AnomalyConfig, requestPattern *regexp.Regexp, latency *archive.LatencyConfig, jsonOutput, htmlOutput, profileMode bool, cacheDir string) error {
 	triageCfg := archive.TriageConfig{
-		Jobs:          jobs,
-		Window:        window,
-		Top:           top,
-		MaxSignatures: maxSignatures,
+		Jobs:                     jobs,
+		Window:                   window,
+		Top:                      top,
+		MaxSignatures:            maxSignatures,
+		CorrelationWindow:        corr.Window,
+		CorrelationMaxLag:        corr.MaxLag,
+		CorrelationMinConfidence: corr.MinConfidence,
+		CorrelationLabel:         corr.ServiceLabel,
+		Owners:                   owners,
+		Profile:                  newProfile(profileMode),
+		CacheDir:                 cacheDir,
+		Anomalies:                anomalies,
+		RequestPattern:           requestPattern,
+		Latency:                  latency,
 	}
 
 	progress := func(p archive.TriageProgress) {
@@ -75,6 +147,10 @@ func runTriage(src, outDir string, jobs int, window time.Duration, top, maxSigna
 
 	fmt.Fprintf(os.Stderr, "\rTriage: %s lines scanned, %s errors found\n",
 		archive.FormatCount(result.TotalLines), archive.FormatCount(result.ErrorLines))
+	if result.CachedFiles > 0 {
+		fmt.Fprintf(os.Stderr, "Triage: %d files reused from cache (--no-cache to rescan)\n", result.CachedFiles)
+	}
+	printProfile(os.Stderr, triageCfg.Profile)
 
 	if jsonOutput {
 		return result.WriteJSON(os.Stdout)
@@ -100,6 +176,18 @@ func runTriage(src, outDir string, jobs int, window time.Duration, top, maxSigna
 		{"sequence.txt", func(f *os.File) { result.WriteSequence(f) }},
 	}
 
+	if anomalies != nil {
+		outputs = append(outputs, struct {
+			name string
+			fn   func(*os.File)
+		}{"anomalies.json", func(f *os.File) { _ = result.WriteAnomalies(f) }})
+	}
+	if result.Latency != nil {
+		outputs = append(outputs, struct {
+			name string
+			fn   func(*os.File)
+		}{"latency.csv", func(f *os.File) { _ = result.Latency.WriteCSV(f) }})
+	}
 	if htmlOutput {
 		outputs = append(outputs, struct {
 			name string
@@ -122,3 +210,39 @@ func runTriage(src, outDir string, jobs int, window time.Duration, top, maxSigna
 	fmt.Fprintf(os.Stderr, "Results: %s\n", filepath.Join(outDir, "summary.md"))
 	return nil
 }
+
+const requestPatternFlagUsage = "regex matching one log line per request (e.g. access log lines), to report errors per 1k requests when the capture has no harness request metrics"
+
+// compileRequestPattern compiles --request-pattern; "" means none.
+func compileRequestPattern(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --request-pattern: %w", err)
+	}
+	return re, nil
+}
+
+// ownersFile is the ownership mapping triage and report pick up from the
+// capture directory when --owners is not given.
+const ownersFile = "owners.yaml"
+
+const ownersFlagUsage = "owners.yaml mapping label values or signature regexes to teams (default: owners.yaml in the capture dir, if present)"
+
+// loadOwners loads the --owners file, else the capture's owners.yaml if it
+// exists. It returns nil when neither is set.
+func loadOwners(path, captureDir string) (*archive.Owners, error) {
+	if path == "" {
+		path = filepath.Join(captureDir, ownersFile)
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+	}
+	owners, err := archive.LoadOwners(path)
+	if err != nil {
+		return nil, fmt.Errorf("--owners: %w", err)
+	}
+	return owners, nil
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/spf13/cobra"
 
@@ -21,12 +23,13 @@ func newUntapCmd() *cobra.Command {
 		all         bool
 		dryRun      bool
 		force       bool
+		yes         bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   "untap",
 		Short: "Remove logtap forwarder sidecar from workloads",
-		Long:  "Untap removes logtap log-forwarding sidecar containers from Kubernetes workloads. Use --session to remove a specific session or --all to remove all sessions.",
+		Long:  "Untap removes logtap log-forwarding sidecar containers from Kubernetes workloads. Use --session to remove a specific session from every workload it tapped, or --all to remove all sessions.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runUntap(untapOpts{
 				deployment:  deployment,
@@ -38,6 +41,7 @@ func newUntapCmd() *cobra.Command {
 				all:         all,
 				dryRun:      dryRun,
 				force:       force,
+				yes:         yes,
 			})
 		},
 	}
@@ -51,6 +55,7 @@ func newUntapCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&all, "all", false, "remove all sessions")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show diff without applying")
 	cmd.Flags().BoolVar(&force, "force", false, "required with --all to confirm bulk removal")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "untap several workloads without showing the combined diff and asking to confirm")
 
 	return cmd
 }
@@ -65,6 +70,7 @@ type untapOpts struct {
 	all         bool
 	dryRun      bool
 	force       bool
+	yes         bool // skip the confirmation of a multi-workload untap
 }
 
 func runUntap(opts untapOpts) error {
@@ -82,7 +88,7 @@ func runUntap(opts untapOpts) error {
 	ctx, cancel := clusterContext()
 	defer cancel()
 
-	c, err := k8s.NewClient(opts.namespace)
+	c, err := newK8sClient(opts.namespace)
 	if err != nil {
 		return fmt.Errorf("connect to cluster: %w", err)
 	}
@@ -110,23 +116,10 @@ func runUntap(opts untapOpts) error {
 
 	if modes == 0 {
 		// Auto-discover tapped workloads
-		all, err := k8s.DiscoverTapped(ctx, c, sidecar.AnnotationTapped)
+		workloads, err = untapTargets(ctx, c, opts.session)
 		if err != nil {
 			return err
 		}
-		if opts.session != "" {
-			// Filter to workloads containing this session
-			for _, w := range all {
-				for _, s := range sidecar.ParseSessions(w.Annotations[sidecar.AnnotationTapped]) {
-					if s == opts.session {
-						workloads = append(workloads, w)
-						break
-					}
-				}
-			}
-		} else {
-			workloads = all
-		}
 	} else {
 		switch {
 		case opts.deployment != "":
@@ -160,51 +153,164 @@ func runUntap(opts untapOpts) error {
 		return fmt.Errorf("no tapped workloads found")
 	}
 
+	// Plan the removal from every workload before changing any, so it is
+	// shown and confirmed as one
+	var plans []*sidecar.RemoveResult
+	for _, w := range workloads {
+		var results []*sidecar.RemoveResult
+		if opts.all {
+			results, err = sidecar.RemoveAll(ctx, c, w, true)
+		} else {
+			var r *sidecar.RemoveResult
+			r, err = sidecar.Remove(ctx, c, w, opts.session, true)
+			results = []*sidecar.RemoveResult{r}
+		}
+		if err != nil {
+			return fmt.Errorf("untap %s/%s: %w", w.Kind, w.Name, err)
+		}
+		plans = append(plans, results...)
+	}
+
+	confirm := len(workloads) > 1 && !opts.force && !opts.yes && stdinIsTerminal()
+	if opts.dryRun || confirm {
+		prefix := "[dry-run] "
+		if !opts.dryRun {
+			prefix = ""
+		}
+		var last *k8s.Workload
+		for _, p := range plans {
+			if p.Workload == last {
+				continue // RemoveAll shares one diff between sessions
+			}
+			last = p.Workload
+			fmt.Fprintf(os.Stderr, "%s%s/%s:\n", prefix, p.Workload.Kind, p.Workload.Name)
+			_, _ = fmt.Fprintln(os.Stdout, p.Diff)
+		}
+	}
+	if opts.dryRun {
+		return nil
+	}
+	if confirm {
+		what := "all sessions"
+		if opts.session != "" {
+			what = "session " + opts.session
+		}
+		ok, err := confirmPrompt(os.Stderr, fmt.Sprintf("\nRemove %s from %d workloads?", what, len(workloads)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("untap cancelled")
+		}
+	}
+
 	// Execute removal
-	var totalRemoved int
+	removed := make(map[string][]*k8s.Workload) // session → workloads it was removed from
 	for _, w := range workloads {
 		if opts.all {
-			results, err := sidecar.RemoveAll(ctx, c, w, opts.dryRun)
+			results, err := sidecar.RemoveAll(ctx, c, w, false)
 			if err != nil {
+				forgetUntapped(ctx, c, removed)
 				return fmt.Errorf("untap %s/%s: %w", w.Kind, w.Name, err)
 			}
-			if opts.dryRun {
-				fmt.Fprintf(os.Stderr, "[dry-run] %s/%s:\n", w.Kind, w.Name)
-				if len(results) > 0 {
-					_, _ = fmt.Fprintln(os.Stdout, results[0].Diff)
-				}
-			} else {
-				for _, r := range results {
-					fmt.Fprintf(os.Stderr, "Untapped %s/%s (session %s)\n", w.Kind, w.Name, r.SessionID)
-				}
+			for _, r := range results {
+				fmt.Fprintf(os.Stderr, "Untapped %s/%s (session %s)\n", w.Kind, w.Name, r.SessionID)
+				removed[r.SessionID] = append(removed[r.SessionID], w)
 			}
-			totalRemoved += len(results)
 		} else {
-			result, err := sidecar.Remove(ctx, c, w, opts.session, opts.dryRun)
+			result, err := sidecar.Remove(ctx, c, w, opts.session, false)
 			if err != nil {
+				forgetUntapped(ctx, c, removed)
 				return fmt.Errorf("untap %s/%s: %w", w.Kind, w.Name, err)
 			}
-			if opts.dryRun {
-				fmt.Fprintf(os.Stderr, "[dry-run] %s/%s:\n", w.Kind, w.Name)
-				_, _ = fmt.Fprintln(os.Stdout, result.Diff)
-			} else {
-				fmt.Fprintf(os.Stderr, "Untapped %s/%s (session %s)\n", w.Kind, w.Name, result.SessionID)
-			}
-			totalRemoved++
+			fmt.Fprintf(os.Stderr, "Untapped %s/%s (session %s)\n", w.Kind, w.Name, result.SessionID)
+			removed[result.SessionID] = append(removed[result.SessionID], w)
 		}
 	}
+	forgetUntapped(ctx, c, removed)
 
-	if !opts.dryRun {
-		fmt.Fprintf(os.Stderr, "\nRemoved %d session(s) from %d workload(s)\n", totalRemoved, len(workloads))
+	var totalRemoved int
+	for _, ws := range removed {
+		totalRemoved += len(ws)
+	}
+	fmt.Fprintf(os.Stderr, "\nRemoved %d session(s) from %d workload(s)\n", totalRemoved, len(workloads))
 
-		// Clean up RBAC if no tapped workloads remain
-		remaining, err := k8s.DiscoverTapped(ctx, c, sidecar.AnnotationTapped)
-		if err == nil && len(remaining) == 0 {
-			if err := k8s.DeleteForwarderRBAC(ctx, c, false); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: could not clean up forwarder RBAC: %v\n", err)
-			}
+	// Clean up RBAC if no tapped workloads remain
+	remaining, err := k8s.DiscoverTapped(ctx, c, sidecar.AnnotationTapped)
+	if err == nil && len(remaining) == 0 {
+		if err := k8s.DeleteForwarderRBAC(ctx, c, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not clean up forwarder RBAC: %v\n", err)
 		}
 	}
 
 	return nil
 }
+
+// untapTargets finds the tapped workloads to untap when none are named:
+// those recorded for session, or carrying it in their annotation when the
+// session has no record; every tapped workload without a session.
+func untapTargets(ctx context.Context, c *k8s.Client, session string) ([]*k8s.Workload, error) {
+	if session != "" {
+		rec, err := sidecar.LoadSession(ctx, c, session)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; finding its workloads by annotation\n", err)
+		}
+		if rec != nil {
+			var workloads []*k8s.Workload
+			for _, sw := range rec.Workloads {
+				w, err := k8s.DiscoverByName(ctx, c, sw.Kind, sw.Name)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: skipping %s/%s of session %s: %v\n", sw.Kind, sw.Name, session, err)
+					continue
+				}
+				if !slices.Contains(sidecar.ParseSessions(w.Annotations[sidecar.AnnotationTapped]), session) {
+					fmt.Fprintf(os.Stderr, "Warning: %s/%s no longer carries session %s\n", sw.Kind, sw.Name, session)
+					continue
+				}
+				workloads = append(workloads, w)
+			}
+			if len(workloads) == 0 {
+				// nothing left to untap; drop the stale record
+				_ = sidecar.DeleteSession(ctx, c, session, false)
+			}
+			return workloads, nil
+		}
+	}
+
+	all, err := k8s.DiscoverTapped(ctx, c, sidecar.AnnotationTapped)
+	if err != nil {
+		return nil, err
+	}
+	if session == "" {
+		return all, nil
+	}
+	var workloads []*k8s.Workload
+	for _, w := range all {
+		if slices.Contains(sidecar.ParseSessions(w.Annotations[sidecar.AnnotationTapped]), session) {
+			workloads = append(workloads, w)
+		}
+	}
+	return workloads, nil
+}
+
+// forgetUntapped updates the session records after sessions were removed
+// from workloads, deleting a record once none of its workloads is left.
+func forgetUntapped(ctx context.Context, c *k8s.Client, removed map[string][]*k8s.Workload) {
+	for id, ws := range removed {
+		rec, err := sidecar.LoadSession(ctx, c, id)
+		if err != nil || rec == nil {
+			continue
+		}
+		for _, w := range ws {
+			rec = rec.Without(w)
+		}
+		if len(rec.Workloads) == 0 {
+			err = sidecar.DeleteSession(ctx, c, id, false)
+		} else {
+			err = sidecar.SaveSession(ctx, c, rec, false)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
+)
+
+// currentCaptureFile holds the capture set with `logtap use`, relative to
+// ~/.logtap.
+const currentCaptureFile = "current"
+
+// contextDir is the global --context-dir flag: the capture to use when a
+// command's capture directory argument is omitted, overriding `logtap use`.
+var contextDir string
+
+func newUseCmd() *cobra.Command {
+	var clear bool
+
+	cmd := &cobra.Command{
+		Use:   "use [capture-dir]",
+		Short: "Set the current capture for commands that omit the directory",
+		Long: `Set the current capture, stored in ~/.logtap/current. grep, triage,
+slice, report, and inspect use it when their capture directory argument is
+omitted; --context-dir overrides it for one invocation. Without arguments,
+prints the current capture.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			switch {
+			case clear:
+				if len(args) > 0 {
+					return cli.NewUsageError("--clear takes no capture directory")
+				}
+				if err := clearCurrentCapture(); err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(out, "Current capture cleared")
+				return nil
+			case len(args) == 0:
+				dir, err := currentCapture()
+				if err != nil {
+					return err
+				}
+				if dir == "" {
+					_, _ = fmt.Fprintln(out, "No current capture (set one with: logtap use <capture-dir>)")
+					return nil
+				}
+				_, _ = fmt.Fprintln(out, dir)
+				return nil
+			}
+
+			dir, err := setCurrentCapture(args[0])
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(out, "Using capture %s\n", dir)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&clear, "clear", false, "forget the current capture")
+	return cmd
+}
+
+// currentCapturePath returns the path of the state file.
+func currentCapturePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".logtap", currentCaptureFile), nil
+}
+
+// currentCapture returns the capture set with `logtap use`, or "" if none.
+func currentCapture() (string, error) {
+	path, err := currentCapturePath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read current capture: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// setCurrentCapture validates dir as a capture and records its absolute path.
+func setCurrentCapture(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if _, err := archive.NewReader(abs); err != nil {
+		return "", err
+	}
+	path, err := currentCapturePath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("create state directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(abs+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("write current capture: %w", err)
+	}
+	return abs, nil
+}
+
+func clearCurrentCapture() error {
+	path, err := currentCapturePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("clear current capture: %w", err)
+	}
+	return nil
+}
+
+// captureDirArg returns args[i] when given, else --context-dir, else the
+// current capture.
+func captureDirArg(args []string, i int) (string, error) {
+	if len(args) > i {
+		return args[i], nil
+	}
+	if contextDir != "" {
+		return contextDir, nil
+	}
+	dir, err := currentCapture()
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		return "", cli.NewUsageError("no capture directory given (pass one, use --context-dir, or set one with: logtap use <capture-dir>)")
+	}
+	return dir, nil
+}
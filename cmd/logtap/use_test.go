@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/cli"
+)
+
+func TestCaptureDirArg(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	contextDir = ""
+	t.Cleanup(func() { contextDir = "" })
+
+	if _, err := captureDirArg(nil, 0); err == nil || cli.ExitCode(err) != cli.ExitUsage {
+		t.Fatalf("no capture: err = %v, want usage error", err)
+	}
+
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+	if _, err := setCurrentCapture(dir); err != nil {
+		t.Fatalf("setCurrentCapture: %v", err)
+	}
+	if got, _ := captureDirArg(nil, 0); got != dir {
+		t.Errorf("current capture = %q, want %q", got, dir)
+	}
+
+	contextDir = "/other"
+	if got, _ := captureDirArg(nil, 0); got != "/other" {
+		t.Errorf("--context-dir = %q, want /other", got)
+	}
+	if got, _ := captureDirArg([]string{"pattern", "/explicit"}, 1); got != "/explicit" {
+		t.Errorf("explicit arg = %q, want /explicit", got)
+	}
+}
+
+func TestUseCmd(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := makeCaptureDir(t, sampleEntries(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)))
+
+	run := func(args ...string) (string, error) {
+		cmd := newUseCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	if out, err := run(); err != nil || !strings.Contains(out, "No current capture") {
+		t.Fatalf("use (unset) = %q, %v", out, err)
+	}
+	if _, err := run(t.TempDir()); err == nil {
+		t.Error("expected error for a directory that is not a capture")
+	}
+	if out, err := run(dir); err != nil || !strings.Contains(out, dir) {
+		t.Fatalf("use <dir> = %q, %v", out, err)
+	}
+	if out, err := run(); err != nil || strings.TrimSpace(out) != dir {
+		t.Fatalf("use = %q, %v; want %q", out, err, dir)
+	}
+	if _, err := run("--clear"); err != nil {
+		t.Fatalf("use --clear: %v", err)
+	}
+	if got, _ := currentCapture(); got != "" {
+		t.Errorf("after --clear current capture = %q", got)
+	}
+}
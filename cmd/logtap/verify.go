@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ppiankov/logtap/internal/archive"
+	"github.com/ppiankov/logtap/internal/cli"
+)
+
+func newVerifyCmd() *cobra.Command {
+	var quarantine bool
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "verify <capture-dir>",
+		Short: "Check capture data files against their recorded checksums",
+		Long: `Recompute the SHA-256 and line count of every indexed data file and compare
+them with what the receiver recorded when the file was finished. Truncated,
+corrupted and missing files are reported; --quarantine moves damaged files
+to the capture's quarantine/ directory so later commands skip them.
+
+Captures recorded before checksums were added are checked by line count.
+Offloaded files are not fetched. Unlike sign --verify, this needs no prior
+manifest and also covers files added after signing.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerify(args[0], quarantine, jsonOutput)
+		},
+	}
+
+	cmd.Flags().BoolVar(&quarantine, "quarantine", false, "move truncated and corrupted files to quarantine/ and drop them from the index")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output the result as JSON")
+	addFormatAlias(cmd, &jsonOutput)
+
+	return cmd
+}
+
+func runVerify(dir string, quarantine, jsonOutput bool) error {
+	result, err := archive.CheckIntegrity(dir, archive.IntegrityOptions{Quarantine: quarantine})
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if jsonOutput {
+		if err := result.WriteJSON(os.Stdout); err != nil {
+			return err
+		}
+	} else {
+		result.WriteText(os.Stdout)
+	}
+	if !result.Valid {
+		return cli.NewFindingsError("capture integrity check failed")
+	}
+	return nil
+}
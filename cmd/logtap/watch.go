@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"regexp"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -22,6 +26,7 @@ func newWatchCmd() *cobra.Command {
 		grepStr    string
 		labelStr   string
 		jsonOutput bool
+		alerts     watchAlertOpts
 	)
 
 	cmd := &cobra.Command{
@@ -29,9 +34,23 @@ func newWatchCmd() *cobra.Command {
 		Short: "Tail a live or completed capture directory",
 		Long: `Watch streams new log entries from a capture directory to stdout.
 For live captures (receiver still running), it follows new entries like 'tail -f'.
-For completed captures, it shows the last N lines and exits.`,
-		Args: cobra.ExactArgs(1),
+For completed captures, it shows the last N lines and exits.
+
+With --alerts, --ack, --silence, or --unsilence it manages the alert rules of
+the running receiver at --receiver instead, through its admin API. Acknowledged
+and silenced rules send no webhook events until the silence expires.`,
+		Example: `  logtap watch ./capture
+  logtap watch --alerts
+  logtap watch --ack high_drops --for 2h
+  logtap watch --receiver 10.0.0.5:3100 --auth-token $TOKEN --silence disk_full --for 30m`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if alerts.active() {
+				return runWatchAlerts(alerts, jsonOutput)
+			}
+			if len(args) != 1 {
+				return fmt.Errorf("requires a capture directory")
+			}
 			return runWatch(args[0], lines, grepStr, labelStr, jsonOutput)
 		},
 	}
@@ -40,11 +59,132 @@ For completed captures, it shows the last N lines and exits.`,
 	cmd.Flags().StringVar(&grepStr, "grep", "", "regex filter on message content")
 	cmd.Flags().StringVar(&labelStr, "label", "", "label filter (key=value)")
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "output as JSON")
+	cmd.Flags().StringVar(&alerts.receiver, "receiver", "127.0.0.1:3100", "receiver address for alert management")
+	cmd.Flags().BoolVar(&alerts.list, "alerts", false, "list the receiver's alert rules and their silences")
+	cmd.Flags().StringSliceVar(&alerts.ack, "ack", nil, "acknowledge firing alert rules (comma-separated)")
+	cmd.Flags().StringSliceVar(&alerts.silence, "silence", nil, "silence alert rules (comma-separated)")
+	cmd.Flags().StringSliceVar(&alerts.unsilence, "unsilence", nil, "remove acknowledgements and silences (comma-separated)")
+	cmd.Flags().DurationVar(&alerts.duration, "for", recv.DefaultSilence, "how long --ack and --silence last")
+	cmd.Flags().StringVar(&alerts.authToken, "auth-token", "", "bearer token for receivers started with --auth-token")
 	addFormatAlias(cmd, &jsonOutput)
 
 	return cmd
 }
 
+// watchAlertOpts holds the alert management flags of watch.
+type watchAlertOpts struct {
+	receiver  string
+	list      bool
+	ack       []string
+	silence   []string
+	unsilence []string
+	duration  time.Duration
+	authToken string
+}
+
+func (o watchAlertOpts) active() bool {
+	return o.list || len(o.ack) > 0 || len(o.silence) > 0 || len(o.unsilence) > 0
+}
+
+// runWatchAlerts applies the alert changes through the receiver admin API,
+// then lists the rules.
+func runWatchAlerts(o watchAlertOpts, jsonOutput bool) error {
+	base := o.receiver
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "http://" + base
+	}
+	base = strings.TrimRight(base, "/") + "/admin/alerts/"
+	client := &http.Client{Timeout: 5 * time.Second}
+	forQuery := "?for=" + url.QueryEscape(o.duration.String())
+
+	for _, c := range []struct {
+		rules  []string
+		method string
+		suffix string
+		done   string
+	}{
+		{o.ack, http.MethodPost, "/ack" + forQuery, "acknowledged"},
+		{o.silence, http.MethodPost, "/silence" + forQuery, "silenced"},
+		{o.unsilence, http.MethodDelete, "/silence", "unsilenced"},
+	} {
+		for _, rule := range c.rules {
+			if _, err := alertAdminRequest(client, c.method, base+url.PathEscape(rule)+c.suffix, o.authToken); err != nil {
+				return fmt.Errorf("%s: %w", rule, err)
+			}
+			if c.method == http.MethodDelete {
+				fmt.Fprintf(os.Stderr, "%s %s\n", c.done, rule)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s %s for %s\n", c.done, rule, o.duration)
+			}
+		}
+	}
+
+	body, err := alertAdminRequest(client, http.MethodGet, strings.TrimSuffix(base, "/"), o.authToken)
+	if err != nil {
+		return err
+	}
+	var status []recv.AlertStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("parse alert status: %w", err)
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+	printAlertStatus(os.Stdout, status)
+	return nil
+}
+
+func alertAdminRequest(client *http.Client, method, target, authToken string) ([]byte, error) {
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+authToken)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("contact receiver: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("receiver requires a token (set --auth-token)")
+	}
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return nil, fmt.Errorf("receiver has no alert rules (start it with --alert-rules)")
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("receiver returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func printAlertStatus(w io.Writer, status []recv.AlertStatus) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "RULE\tSTATE\tCONDITION\tSILENCED UNTIL")
+	for _, st := range status {
+		state := "ok"
+		if st.Firing {
+			state = "FIRING"
+		}
+		until := "-"
+		if st.Silence != nil {
+			until = st.Silence.Until.Local().Format("2006-01-02 15:04:05")
+			if st.Silence.Ack {
+				state += " (acked)"
+			}
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s %s %g\t%s\n", st.Name, state, st.Metric, st.Op, st.Threshold, until)
+	}
+	_ = tw.Flush()
+}
+
 func runWatch(dir string, n int, grepStr, labelStr string, jsonOutput bool) error {
 	// Validate dir
 	info, err := os.Stat(dir)
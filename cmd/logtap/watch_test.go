@@ -2,9 +2,16 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ppiankov/logtap/internal/config"
+	"github.com/ppiankov/logtap/internal/recv"
 	"github.com/spf13/cobra"
 )
 
@@ -83,3 +90,74 @@ func TestWatchCmd_InvalidLabel(t *testing.T) {
 		t.Error("expected error for invalid label filter")
 	}
 }
+
+func TestWatchCmd_RequiresDir(t *testing.T) {
+	root := &cobra.Command{Use: "logtap"}
+	root.AddCommand(newWatchCmd())
+	var buf bytes.Buffer
+	root.SetOut(&buf)
+	root.SetErr(&buf)
+	root.SetArgs([]string{"watch"})
+	if err := root.Execute(); err == nil {
+		t.Error("expected error without a capture dir")
+	}
+}
+
+func TestRunWatchAlerts(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode([]recv.AlertStatus{{Name: "high_drops", Metric: "logs_dropped", Op: "gt", Threshold: 100, Firing: true}})
+		case strings.Contains(r.URL.Path, "/nope/"):
+			http.Error(w, "unknown alert rule: nope", http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	opts := watchAlertOpts{receiver: srv.URL, ack: []string{"high_drops"}, unsilence: []string{"disk_full"}, duration: 2 * time.Hour, authToken: "s3cret"}
+	if err := runWatchAlerts(opts, true); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"POST /admin/alerts/high_drops/ack?for=2h0m0s",
+		"DELETE /admin/alerts/disk_full/silence",
+		"GET /admin/alerts",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+
+	opts = watchAlertOpts{receiver: srv.URL, silence: []string{"nope"}, duration: time.Hour, authToken: "s3cret"}
+	if err := runWatchAlerts(opts, false); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("err = %v, want the receiver's 404", err)
+	}
+
+	opts.authToken = ""
+	if err := runWatchAlerts(opts, false); err == nil || !strings.Contains(err.Error(), "--auth-token") {
+		t.Errorf("err = %v, want a token hint", err)
+	}
+}
+
+func TestPrintAlertStatus(t *testing.T) {
+	var buf bytes.Buffer
+	printAlertStatus(&buf, []recv.AlertStatus{
+		{Name: "high_drops", Metric: "logs_dropped", Op: "gt", Threshold: 100, Firing: true,
+			Silence: &recv.AlertSilence{Rule: "high_drops", Ack: true, Until: time.Now().Add(time.Hour)}},
+		{Name: "disk_full", Metric: "disk_pct", Op: "gt", Threshold: 90},
+	})
+	out := buf.String()
+	if !strings.Contains(out, "FIRING (acked)") || !strings.Contains(out, "disk_pct gt 90") {
+		t.Errorf("output:\n%s", out)
+	}
+}
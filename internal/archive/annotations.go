@@ -0,0 +1,82 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// AnnotationsFile holds the bookmarks reviewers add to a capture in the
+// open TUI. It is not part of the signed capture, so a signed capture can
+// still be annotated.
+const AnnotationsFile = "annotations.json"
+
+// Annotation is a bookmarked entry with a reviewer's note.
+type Annotation struct {
+	Timestamp time.Time         `json:"ts"`               // of the bookmarked entry
+	Labels    map[string]string `json:"labels,omitempty"` // of the bookmarked entry
+	Message   string            `json:"msg"`
+	Note      string            `json:"note"`
+	Created   time.Time         `json:"created"`
+}
+
+// NewAnnotation bookmarks e with note.
+func NewAnnotation(e recv.LogEntry, note string) Annotation {
+	return Annotation{
+		Timestamp: e.Timestamp,
+		Labels:    e.Labels,
+		Message:   e.Message,
+		Note:      note,
+		Created:   time.Now().UTC(),
+	}
+}
+
+// Matches reports whether a is the bookmark of e.
+func (a Annotation) Matches(e recv.LogEntry) bool {
+	return a.Timestamp.Equal(e.Timestamp) && a.Message == e.Message
+}
+
+// ReadAnnotations returns the annotations of the capture in dir, ordered by
+// entry timestamp. A capture without annotations returns none.
+func ReadAnnotations(dir string) ([]Annotation, error) {
+	data, err := os.ReadFile(filepath.Join(dir, AnnotationsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read annotations: %w", err)
+	}
+	var anns []Annotation
+	if err := json.Unmarshal(data, &anns); err != nil {
+		return nil, fmt.Errorf("parse annotations: %w", err)
+	}
+	return anns, nil
+}
+
+// AddAnnotation appends a to the annotations of the capture in dir.
+func AddAnnotation(dir string, a Annotation) error {
+	anns, err := ReadAnnotations(dir)
+	if err != nil {
+		return err
+	}
+	anns = append(anns, a)
+	sort.SliceStable(anns, func(i, j int) bool { return anns[i].Timestamp.Before(anns[j].Timestamp) })
+	data, err := json.MarshalIndent(anns, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal annotations: %w", err)
+	}
+	tmp := filepath.Join(dir, AnnotationsFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write annotations: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, AnnotationsFile)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("write annotations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestAnnotations_AddRead(t *testing.T) {
+	dir := t.TempDir()
+	if anns, err := ReadAnnotations(dir); err != nil || anns != nil {
+		t.Fatalf("empty capture: %v, %v", anns, err)
+	}
+
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	later := recv.LogEntry{Timestamp: base.Add(time.Minute), Labels: map[string]string{"app": "api"}, Message: "pool exhausted"}
+	earlier := recv.LogEntry{Timestamp: base, Labels: map[string]string{"app": "web"}, Message: "first 502"}
+	if err := AddAnnotation(dir, NewAnnotation(later, "root cause")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddAnnotation(dir, NewAnnotation(earlier, "symptom")); err != nil {
+		t.Fatal(err)
+	}
+
+	anns, err := ReadAnnotations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anns) != 2 || anns[0].Note != "symptom" || anns[1].Note != "root cause" {
+		t.Fatalf("annotations = %+v, want ordered by entry time", anns)
+	}
+	if !anns[1].Matches(later) || anns[1].Matches(earlier) || anns[1].Labels["app"] != "api" {
+		t.Errorf("annotation = %+v", anns[1])
+	}
+	if _, err := os.Stat(filepath.Join(dir, AnnotationsFile+".tmp")); !os.IsNotExist(err) {
+		t.Error("temporary file left behind")
+	}
+}
+
+func TestAnnotations_NotSigned(t *testing.T) {
+	dir := createTestCapture(t)
+	if _, err := Sign(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddAnnotation(dir, NewAnnotation(recv.LogEntry{Timestamp: time.Now(), Message: "x"}, "later note")); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid {
+		t.Errorf("annotating a signed capture broke verification: %+v", res)
+	}
+}
@@ -0,0 +1,210 @@
+package archive
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Anomaly kinds.
+const (
+	AnomalySpike   = "spike"   // far more lines than expected
+	AnomalyDrop    = "drop"    // far fewer lines than expected
+	AnomalySilence = "silence" // no lines where many were expected
+)
+
+// Anomaly metrics.
+const (
+	MetricLines  = "lines"
+	MetricErrors = "errors"
+)
+
+// DefaultAnomalyThreshold is the score at or above which a minute is
+// anomalous, in standard deviations from the expected count.
+const DefaultAnomalyThreshold = 3.5
+
+const (
+	anomalyLevelAlpha    = 0.3 // EWMA weight of a new minute in the level
+	anomalySeasonGamma   = 0.1 // EWMA weight of a new minute in its seasonal offset
+	anomalyVarianceBeta  = 0.1 // EWMA weight of a new residual in the variance
+	anomalyWarmupMinutes = 15  // minutes used to seed the baseline, never flagged
+	defaultAnomalyTop    = 10
+)
+
+// AnomalyConfig controls anomaly detection over the triage timeline.
+type AnomalyConfig struct {
+	Threshold float64 // minimum score (default DefaultAnomalyThreshold)
+	Top       int     // windows kept, highest score first (default 10)
+}
+
+// Anomaly is a run of consecutive minutes whose line or error count
+// deviated from the detector's expectation in the same direction.
+type Anomaly struct {
+	Kind     string    `json:"kind"`
+	Metric   string    `json:"metric"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Score    float64   `json:"score"`
+	Observed int64     `json:"observed"`
+	Expected int64     `json:"expected"`
+	Desc     string    `json:"description"`
+}
+
+// DetectAnomalies runs a seasonal EWMA detector over the per-minute line and
+// error counts of timeline and returns the anomalous windows ranked by score.
+//
+// Each minute's expected count is an exponentially weighted level plus the
+// offset learned for its phase in the season: a day for timelines of two days
+// or more, an hour for three hours or more, none below that. Its score is the
+// deviation divided by the residual standard deviation, floored by the
+// Poisson noise of the expected count so flat series do not flag every
+// wobble. Anomalous minutes leave the baseline untouched, so a long incident
+// stays one window instead of fading into the expectation. Lines are checked
+// for spikes, drops and silence, errors for spikes only.
+func DetectAnomalies(timeline []TriageBucket, cfg AnomalyConfig) []Anomaly {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultAnomalyThreshold
+	}
+	if cfg.Top <= 0 {
+		cfg.Top = defaultAnomalyTop
+	}
+	if len(timeline) <= anomalyWarmupMinutes {
+		return nil
+	}
+
+	lines := make([]float64, len(timeline))
+	errs := make([]float64, len(timeline))
+	for i, b := range timeline {
+		lines[i] = float64(b.TotalLines)
+		errs[i] = float64(b.ErrorLines)
+	}
+	season := anomalySeason(len(timeline))
+
+	var out []Anomaly
+	out = append(out, anomalyWindows(timeline, lines, season, cfg.Threshold, MetricLines)...)
+	out = append(out, anomalyWindows(timeline, errs, season, cfg.Threshold, MetricErrors)...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].From.Before(out[j].From)
+	})
+	if len(out) > cfg.Top {
+		out = out[:cfg.Top]
+	}
+	return out
+}
+
+// anomalySeason returns the seasonal period in minutes for a timeline of n
+// minutes, or 0 when it is too short to learn one.
+func anomalySeason(n int) int {
+	const hour, day = 60, 24 * 60
+	switch {
+	case n >= 2*day:
+		return day
+	case n >= 3*hour:
+		return hour
+	}
+	return 0
+}
+
+// anomalyWindows scores series minute by minute and merges consecutive
+// anomalous minutes of one kind into windows.
+func anomalyWindows(timeline []TriageBucket, series []float64, season int, threshold float64, metric string) []Anomaly {
+	level, variance := seedBaseline(series[:anomalyWarmupMinutes])
+	var offsets []float64
+	if season > 0 {
+		offsets = make([]float64, season)
+	}
+
+	var out []Anomaly
+	var cur *Anomaly
+	var curExpected float64
+	flush := func() {
+		if cur != nil {
+			cur.Expected = int64(math.Round(curExpected))
+			cur.Desc = anomalyDesc(cur)
+			out = append(out, *cur)
+			cur = nil
+		}
+	}
+
+	for i, x := range series {
+		var offset float64
+		if offsets != nil {
+			offset = offsets[i%season]
+		}
+		expected := math.Max(0, level+offset)
+		spread := math.Max(math.Sqrt(variance), math.Sqrt(expected)+1)
+		score := (x - expected) / spread
+
+		kind := ""
+		if i >= anomalyWarmupMinutes && math.Abs(score) >= threshold {
+			switch {
+			case score > 0:
+				kind = AnomalySpike
+			case metric == MetricErrors:
+				// fewer errors than usual is not an incident
+			case x == 0:
+				kind = AnomalySilence
+			default:
+				kind = AnomalyDrop
+			}
+		}
+		if cur != nil && kind != cur.Kind {
+			flush()
+		}
+		if kind != "" {
+			if cur == nil {
+				cur = &Anomaly{Kind: kind, Metric: metric, From: timeline[i].Time}
+				curExpected = 0
+			}
+			cur.To = timeline[i].Time.Add(time.Minute)
+			cur.Score = math.Max(cur.Score, math.Round(math.Abs(score)*100)/100)
+			cur.Observed += int64(x)
+			curExpected += expected
+		}
+
+		// an outage or flood must not become the new normal
+		if math.Abs(score) >= threshold {
+			continue
+		}
+		residual := x - expected
+		level += anomalyLevelAlpha * (x - offset - level)
+		if offsets != nil {
+			offsets[i%season] += anomalySeasonGamma * (x - level - offset)
+		}
+		variance = (1-anomalyVarianceBeta)*variance + anomalyVarianceBeta*residual*residual
+	}
+	flush()
+	return out
+}
+
+// seedBaseline returns the median and variance of the warmup minutes; the
+// median keeps a burst at the start of the capture out of the level.
+func seedBaseline(warmup []float64) (level, variance float64) {
+	sorted := append([]float64(nil), warmup...)
+	sort.Float64s(sorted)
+	level = sorted[len(sorted)/2]
+	for _, x := range warmup {
+		variance += (x - level) * (x - level)
+	}
+	return level, variance / float64(len(warmup))
+}
+
+func anomalyDesc(a *Anomaly) string {
+	minutes := int(a.To.Sub(a.From) / time.Minute)
+	span := fmt.Sprintf("%d minute", minutes)
+	if minutes != 1 {
+		span += "s"
+	}
+	unit := "lines"
+	if a.Metric == MetricErrors {
+		unit = "errors"
+	}
+	if a.Kind == AnomalySilence {
+		return fmt.Sprintf("no lines for %s, ~%s expected", span, FormatCount(a.Expected))
+	}
+	return fmt.Sprintf("%s %s in %s, ~%s expected", FormatCount(a.Observed), unit, span, FormatCount(a.Expected))
+}
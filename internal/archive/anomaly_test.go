@@ -0,0 +1,186 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// noisyTimeline returns n minutes of about 1000 lines and 10 errors each,
+// with deterministic jitter.
+func noisyTimeline(n int) []TriageBucket {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	timeline := make([]TriageBucket, n)
+	for i := range timeline {
+		timeline[i] = TriageBucket{
+			Time:       base.Add(time.Duration(i) * time.Minute),
+			TotalLines: 1000 + int64(i*37%41) - 20,
+			ErrorLines: 10 + int64(i*7%5) - 2,
+		}
+	}
+	return timeline
+}
+
+func TestDetectAnomalies_Steady(t *testing.T) {
+	if got := DetectAnomalies(noisyTimeline(240), AnomalyConfig{}); len(got) != 0 {
+		t.Errorf("steady timeline flagged: %+v", got)
+	}
+	if got := DetectAnomalies(noisyTimeline(10), AnomalyConfig{}); got != nil {
+		t.Errorf("timeline shorter than the warmup flagged: %+v", got)
+	}
+}
+
+func TestDetectAnomalies_Kinds(t *testing.T) {
+	timeline := noisyTimeline(120)
+	for i := 40; i < 43; i++ {
+		timeline[i].TotalLines = 8000
+		timeline[i].ErrorLines = 900
+	}
+	for i := 70; i < 75; i++ {
+		timeline[i].TotalLines, timeline[i].ErrorLines = 0, 0
+	}
+	timeline[100].TotalLines = 300
+
+	got := DetectAnomalies(timeline, AnomalyConfig{})
+	find := func(kind, metric string) *Anomaly {
+		for i := range got {
+			if got[i].Kind == kind && got[i].Metric == metric {
+				return &got[i]
+			}
+		}
+		t.Fatalf("no %s in %s among %+v", kind, metric, got)
+		return nil
+	}
+
+	spike := find(AnomalySpike, MetricErrors)
+	if !spike.From.Equal(timeline[40].Time) || !spike.To.Equal(timeline[43].Time) || spike.Observed != 2700 {
+		t.Errorf("error spike = %+v", spike)
+	}
+	find(AnomalySpike, MetricLines)
+	silence := find(AnomalySilence, MetricLines)
+	if !silence.From.Equal(timeline[70].Time) || !silence.To.Equal(timeline[75].Time) || silence.Expected < 4000 {
+		t.Errorf("silence = %+v", silence)
+	}
+	if !strings.Contains(silence.Desc, "no lines for 5 minutes") {
+		t.Errorf("silence desc = %q", silence.Desc)
+	}
+	if drop := find(AnomalyDrop, MetricLines); drop.Observed != 300 {
+		t.Errorf("drop = %+v", drop)
+	}
+	for _, a := range got {
+		if a.Metric == MetricErrors && a.Kind != AnomalySpike {
+			t.Errorf("error %s flagged: %+v", a.Kind, a)
+		}
+		if a.Score < DefaultAnomalyThreshold {
+			t.Errorf("score %.2f below threshold: %+v", a.Score, a)
+		}
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Score > got[i-1].Score {
+			t.Errorf("not ranked by score: %+v", got)
+		}
+	}
+
+	if top := DetectAnomalies(timeline, AnomalyConfig{Top: 1}); len(top) != 1 {
+		t.Errorf("Top 1 = %+v", top)
+	}
+}
+
+func TestDetectAnomalies_BaselineSurvivesIncident(t *testing.T) {
+	// a long outage must not become the new normal
+	timeline := noisyTimeline(120)
+	for i := 30; i < 90; i++ {
+		timeline[i].TotalLines = 0
+	}
+	var silence int
+	for _, a := range DetectAnomalies(timeline, AnomalyConfig{}) {
+		if a.Kind == AnomalySilence {
+			silence++
+			if !a.From.Equal(timeline[30].Time) || !a.To.Equal(timeline[90].Time) {
+				t.Errorf("silence = %+v, want 10:30–11:30", a)
+			}
+		}
+	}
+	if silence != 1 {
+		t.Errorf("silence windows = %d, want 1", silence)
+	}
+}
+
+func TestTriageDetectAnomalies(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	labels := map[string]string{"app": "api"}
+	var entries []recv.LogEntry
+	for m := 0; m < 40; m++ {
+		ts := base.Add(time.Duration(m) * time.Minute)
+		entries = append(entries,
+			recv.LogEntry{Timestamp: ts, Labels: labels, Message: "request ok"},
+			recv.LogEntry{Timestamp: ts.Add(time.Second), Labels: labels, Message: "request ok"})
+		if m == 30 {
+			for i := 0; i < 60; i++ {
+				entries = append(entries, recv.LogEntry{Timestamp: ts.Add(2 * time.Second), Labels: labels, Message: "error: upstream timeout"})
+			}
+		}
+	}
+	writeMetadata(t, dir, base, base.Add(40*time.Minute), int64(len(entries)))
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", entries)
+	writeIndex(t, dir, []rotate.IndexEntry{{
+		File:  "2024-01-15T100000-000.jsonl",
+		From:  base,
+		To:    base.Add(40 * time.Minute),
+		Lines: int64(len(entries)),
+	}})
+
+	result, err := Triage(dir, TriageConfig{Jobs: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Anomalies != nil {
+		t.Errorf("anomalies without detection: %+v", result.Anomalies)
+	}
+
+	result, err = Triage(dir, TriageConfig{Jobs: 1, Anomalies: &AnomalyConfig{}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Anomalies) == 0 {
+		t.Fatal("no anomalies detected")
+	}
+	top := result.Anomalies[0]
+	if top.Kind != AnomalySpike || !top.From.Equal(base.Add(30*time.Minute)) {
+		t.Errorf("top anomaly = %+v", top)
+	}
+
+	var buf bytes.Buffer
+	result.WriteSummary(&buf)
+	for _, want := range []string{"## Anomalies", "spike", "--out ./anomaly"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("summary missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := result.WriteAnomalies(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got []Anomaly
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(result.Anomalies) {
+		t.Errorf("anomalies.json = %+v", got)
+	}
+
+	buf.Reset()
+	if err := result.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<h2>Anomalies</h2>") {
+		t.Error("HTML missing anomalies section")
+	}
+}
@@ -0,0 +1,164 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// Assertion is a parsed expectation over a capture, e.g.
+// count({app="web"} |= "order completed") > 1000 or absent(|= "deadlock").
+type Assertion struct {
+	Expr      string
+	Func      string // count, absent, or present
+	Selector  *LogSelector
+	Op        string // comparison for count: >, >=, <, <=, ==, !=
+	Threshold float64
+}
+
+// assertFuncs are the supported assertion functions. count needs a
+// comparison; absent and present take none.
+var assertFuncs = map[string]bool{"count": true, "absent": false, "present": false}
+
+// ParseAssertion parses an assertion expression.
+func ParseAssertion(expr string) (*Assertion, error) {
+	p := &queryParser{s: expr}
+	fn, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	needsCmp, ok := assertFuncs[fn]
+	if !ok {
+		return nil, p.errorf("unknown function %q (expected count, absent, or present)", fn)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	sel, err := p.selector()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	a := &Assertion{Expr: expr, Func: fn, Selector: sel}
+	if needsCmp {
+		op, ok := p.oneOf(">=", "<=", "==", "!=", ">", "<")
+		if !ok {
+			return nil, p.errorf("%s() needs a comparison, e.g. %s(...) > 0", fn, fn)
+		}
+		if a.Threshold, err = p.number(); err != nil {
+			return nil, err
+		}
+		a.Op = op
+	}
+	if p.skipSpace(); !p.eof() {
+		return nil, p.errorf("unexpected %q", p.rest())
+	}
+	return a, nil
+}
+
+// Check reports whether count satisfies the assertion.
+func (a *Assertion) Check(count int64) bool {
+	switch a.Func {
+	case "absent":
+		return count == 0
+	case "present":
+		return count > 0
+	}
+	v := float64(count)
+	switch a.Op {
+	case ">":
+		return v > a.Threshold
+	case ">=":
+		return v >= a.Threshold
+	case "<":
+		return v < a.Threshold
+	case "<=":
+		return v <= a.Threshold
+	case "==":
+		return v == a.Threshold
+	case "!=":
+		return v != a.Threshold
+	}
+	return false
+}
+
+// AssertionResult is the outcome of one assertion.
+type AssertionResult struct {
+	Expr   string `json:"expr"`
+	Count  int64  `json:"count"`
+	Passed bool   `json:"passed"`
+}
+
+// AssertResult is the outcome of evaluating assertions over a capture.
+type AssertResult struct {
+	Dir          string            `json:"dir"`
+	LinesScanned int64             `json:"lines_scanned"`
+	Passed       bool              `json:"passed"`
+	Failed       int               `json:"failed"`
+	Assertions   []AssertionResult `json:"assertions"`
+}
+
+// Assert evaluates assertions over the capture in src in a single pass.
+// filter restricts the entries considered (e.g. a time window); it may be nil.
+func Assert(src string, assertions []*Assertion, filter *Filter) (*AssertResult, error) {
+	reader, err := NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, len(assertions))
+	scanned, err := reader.Scan(filter, func(e recv.LogEntry) bool {
+		for i, a := range assertions {
+			if a.Selector.Match(e) {
+				counts[i]++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AssertResult{Dir: src, LinesScanned: scanned, Passed: true, Assertions: []AssertionResult{}}
+	for i, a := range assertions {
+		ok := a.Check(counts[i])
+		if !ok {
+			result.Passed = false
+			result.Failed++
+		}
+		result.Assertions = append(result.Assertions, AssertionResult{Expr: a.Expr, Count: counts[i], Passed: ok})
+	}
+	return result, nil
+}
+
+// WriteJSON writes the assert result as indented JSON.
+func (r *AssertResult) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+// WriteText writes one PASS/FAIL line per assertion and a summary.
+func (r *AssertResult) WriteText(w io.Writer) {
+	for _, a := range r.Assertions {
+		status := "PASS"
+		if !a.Passed {
+			status = "FAIL"
+		}
+		_, _ = fmt.Fprintf(w, "%s  %s  (count %d)\n", status, a.Expr, a.Count)
+	}
+	_, _ = fmt.Fprintf(w, "\n%d/%d assertions passed (%d lines scanned)\n",
+		len(r.Assertions)-r.Failed, len(r.Assertions), r.LinesScanned)
+}
@@ -0,0 +1,138 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestParseAssertion(t *testing.T) {
+	a, err := ParseAssertion(`count({app="web"} |= "order completed") >= 1e3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Func != "count" || a.Op != ">=" || a.Threshold != 1000 || a.Selector.String() != `{app="web"} |= "order completed"` {
+		t.Errorf("assertion = %+v", a)
+	}
+
+	a, err = ParseAssertion(`absent( |= "dead)lock" )`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Func != "absent" || a.Selector.Lines[0].Value != "dead)lock" {
+		t.Errorf("assertion = %+v", a)
+	}
+
+	for _, bad := range []string{
+		`sum({app="web"}) > 1`,
+		`count({app="web"})`,
+		`count({app="web"}) > many`,
+		`absent(|= "x") > 0`,
+		`present(|= "x"`,
+		`count`,
+	} {
+		if _, err := ParseAssertion(bad); err == nil {
+			t.Errorf("ParseAssertion(%q): expected error", bad)
+		}
+	}
+}
+
+func TestAssertionCheck(t *testing.T) {
+	tests := []struct {
+		expr  string
+		count int64
+		want  bool
+	}{
+		{`count(|= "x") > 5`, 6, true},
+		{`count(|= "x") > 5`, 5, false},
+		{`count(|= "x") >= 5`, 5, true},
+		{`count(|= "x") < 5`, 4, true},
+		{`count(|= "x") <= 5`, 6, false},
+		{`count(|= "x") == 0`, 0, true},
+		{`count(|= "x") != 0`, 0, false},
+		{`absent(|= "x")`, 0, true},
+		{`absent(|= "x")`, 1, false},
+		{`present(|= "x")`, 1, true},
+		{`present(|= "x")`, 0, false},
+	}
+	for _, tt := range tests {
+		a, err := ParseAssertion(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.Check(tt.count); got != tt.want {
+			t.Errorf("%s with count %d = %v, want %v", tt.expr, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestAssert(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	entries := append(makeEntries(20, base, "web"), makeEntries(5, base, "worker")...)
+	entries = append(entries, recv.LogEntry{Timestamp: base, Labels: map[string]string{"app": "worker"}, Message: "deadlock detected"})
+	writeMetadata(t, dir, base, base.Add(time.Minute), int64(len(entries)))
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", entries)
+
+	parse := func(exprs ...string) []*Assertion {
+		var out []*Assertion
+		for _, e := range exprs {
+			a, err := ParseAssertion(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, a)
+		}
+		return out
+	}
+
+	result, err := Assert(dir, parse(
+		`count({app="web"}) == 20`,
+		`count({app=~"web|worker"} |~ "line [0-4]$") == 10`,
+		`absent(|= "deadlock")`,
+		`present({app="worker"} |= "deadlock")`,
+	), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.LinesScanned != 26 || result.Passed || result.Failed != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	wantCounts := []int64{20, 10, 1, 1}
+	for i, a := range result.Assertions {
+		if a.Count != wantCounts[i] || a.Passed != (i != 2) {
+			t.Errorf("assertion %d = %+v", i, a)
+		}
+	}
+
+	var text bytes.Buffer
+	result.WriteText(&text)
+	if !strings.Contains(text.String(), `FAIL  absent(|= "deadlock")  (count 1)`) || !strings.Contains(text.String(), "3/4 assertions passed (26 lines scanned)") {
+		t.Errorf("text = %s", text.String())
+	}
+	var js bytes.Buffer
+	if err := result.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var decoded AssertResult
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || decoded.Failed != 1 || len(decoded.Assertions) != 4 {
+		t.Errorf("json = %s (%v)", js.String(), err)
+	}
+
+	// A time window restricts the entries considered.
+	result, err = Assert(dir, parse(`count({app="web"}) == 10`), &Filter{To: base.Add(9 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Passed {
+		t.Errorf("windowed result = %+v", result)
+	}
+
+	if _, err := Assert(t.TempDir(), parse(`present(|= "x")`), nil); err == nil {
+		t.Error("expected error for missing capture")
+	}
+}
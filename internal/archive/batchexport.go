@@ -0,0 +1,230 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// maxPendingBytes flushes every batch once this many message bytes are
+// buffered across them.
+const maxPendingBytes = 8 << 20
+
+// batchExport configures exportBatches, the replay of a capture into a
+// remote store by ExportLoki, ExportElastic and ExportSplunk.
+type batchExport struct {
+	dest       string // store name for errors
+	params     string // checkpoint fingerprint
+	checkpoint string
+	resume     bool
+	batchLines int
+	batchBytes int
+	rate       float64
+	profile    *Profile
+	ids        bool // give each entry a stable ID, see entryID
+
+	// group returns the batch key of an entry and the labels of its batch.
+	group func(recv.LogEntry) (string, map[string]string)
+	push  func(context.Context, *exportBatch) error
+}
+
+// exportBatch is the pending entries of one group.
+type exportBatch struct {
+	labels  map[string]string
+	entries []recv.LogEntry
+	ids     []string // set with batchExport.ids
+	bytes   int
+}
+
+// exportBatches pushes the filtered entries of src in batches. A
+// checkpoint records each completed file, so a failed export can be
+// resumed; the file in flight is pushed again. Returns the number of
+// lines pushed.
+func exportBatches(ctx context.Context, src string, filter *Filter, progress func(ExportProgress), cfg batchExport) (int64, error) {
+	reader, err := NewReader(src)
+	if err != nil {
+		return 0, fmt.Errorf("open source: %w", err)
+	}
+	reader.SetProfile(cfg.profile)
+	reader.SetExpandRepeats(true) // remote stores have no repeat count
+	totalLines := reader.TotalLines()
+
+	var cp *Checkpoint
+	if cfg.resume && cfg.checkpoint != "" {
+		if cp, err = loadResumeCheckpoint(cfg.checkpoint, "export", cfg.params); err != nil {
+			return 0, err
+		}
+	}
+	if cp == nil {
+		cp = &Checkpoint{Op: "export", Params: cfg.params}
+	}
+
+	x := &batchExporter{
+		ctx:     ctx,
+		cfg:     cfg,
+		batches: make(map[string]*exportBatch),
+		written: cp.Written,
+		limit:   lineLimiter{rate: cfg.rate},
+		report: func(written int64) {
+			if progress != nil {
+				progress(ExportProgress{Written: written, Total: totalLines})
+			}
+		},
+	}
+
+	for _, f := range reader.Files() {
+		if filter.skip(f) || cp.Done(f.Name) {
+			continue
+		}
+		x.file, x.seq = f.Name, 0
+		if _, _, err := reader.scanFile(f, filter, x.add); err != nil {
+			return x.written, fmt.Errorf("scan source: scan %s: %w", f.Name, err)
+		}
+		if x.err == nil {
+			x.flushAll()
+		}
+		if x.err != nil {
+			return x.written, x.err
+		}
+		if cfg.checkpoint == "" {
+			continue
+		}
+		cp.Completed = append(cp.Completed, f.Name)
+		cp.Written = x.written
+		if err := WriteCheckpoint(cfg.checkpoint, cp); err != nil {
+			return x.written, err
+		}
+	}
+
+	if cfg.checkpoint != "" {
+		_ = os.Remove(cfg.checkpoint)
+	}
+	x.report(x.written)
+	return x.written, nil
+}
+
+type batchExporter struct {
+	ctx     context.Context
+	cfg     batchExport
+	batches map[string]*exportBatch
+	pending int // message bytes buffered across batches
+	written int64
+	limit   lineLimiter
+	report  func(written int64)
+	err     error
+
+	file string // data file being scanned
+	seq  int64  // entries of file seen so far
+}
+
+// add buffers e and pushes its batch once full. It stops the scan on the
+// first failed push.
+func (x *batchExporter) add(e recv.LogEntry) bool {
+	key, labels := x.cfg.group(e)
+	b := x.batches[key]
+	if b == nil {
+		b = &exportBatch{labels: labels}
+		x.batches[key] = b
+	}
+	b.entries = append(b.entries, e)
+	if x.cfg.ids {
+		b.ids = append(b.ids, entryID(x.file, x.seq, e))
+	}
+	x.seq++
+	b.bytes += len(e.Message)
+	x.pending += len(e.Message)
+
+	if len(b.entries) >= x.cfg.batchLines || b.bytes >= x.cfg.batchBytes {
+		x.flush(key)
+	} else if x.pending >= maxPendingBytes {
+		x.flushAll()
+	}
+	return x.err == nil
+}
+
+// flushAll pushes every pending batch in key order.
+func (x *batchExporter) flushAll() {
+	keys := make([]string, 0, len(x.batches))
+	for k := range x.batches {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if x.err != nil {
+			return
+		}
+		x.flush(k)
+	}
+}
+
+func (x *batchExporter) flush(key string) {
+	b := x.batches[key]
+	delete(x.batches, key)
+	x.pending -= b.bytes
+	if err := x.limit.wait(x.ctx, len(b.entries)); err != nil {
+		x.err = err
+		return
+	}
+	if err := x.cfg.push(x.ctx, b); err != nil {
+		x.err = fmt.Errorf("push to %s: %w", x.cfg.dest, err)
+		return
+	}
+	before := x.written
+	x.written += int64(len(b.entries))
+	if before/10000 != x.written/10000 {
+		x.report(x.written)
+	}
+}
+
+// entryID identifies the seq-th entry of a data file by its position and
+// content, so pushing a file again overwrites what an interrupted export
+// already stored instead of duplicating it.
+func entryID(file string, seq int64, e recv.LogEntry) string {
+	h := sha256.New()
+	h.Write([]byte(file))
+	h.Write([]byte{0})
+	h.Write(strconv.AppendInt(nil, seq, 10))
+	h.Write([]byte{0})
+	h.Write(strconv.AppendInt(nil, e.Timestamp.UnixNano(), 10))
+	h.Write([]byte{0})
+	h.Write([]byte(e.Message))
+	return hex.EncodeToString(h.Sum(nil)[:20])
+}
+
+// lineLimiter spaces pushes so that on average no more than rate lines
+// are sent per second.
+type lineLimiter struct {
+	rate float64
+	next time.Time
+}
+
+// wait blocks until n more lines may be sent.
+func (l *lineLimiter) wait(ctx context.Context, n int) error {
+	if l.rate <= 0 {
+		return ctx.Err()
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
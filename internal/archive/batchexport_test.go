@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestExportElastic(t *testing.T) {
+	dir, _ := writeLokiCapture(t)
+	cpPath := filepath.Join(t.TempDir(), "elastic.checkpoint")
+	opts := ElasticExportOptions{Target: "http://es:9200", Index: "logtap", Checkpoint: cpPath, BatchLines: 3}
+
+	var batches [][]ElasticEntry
+	bulk := func(_ context.Context, entries []ElasticEntry) error {
+		batches = append(batches, entries)
+		return nil
+	}
+	n, err := ExportElastic(context.Background(), dir, nil, bulk, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Errorf("sent %d entries, want 10", n)
+	}
+	// one group across pods: 3+2 per file
+	if len(batches) != 4 || len(batches[0]) != 3 || len(batches[1]) != 2 {
+		t.Fatalf("batch sizes = %d batches, want 3,2,3,2", len(batches))
+	}
+	ids := make(map[string]bool)
+	for _, b := range batches {
+		for _, e := range b {
+			if e.ID == "" || ids[e.ID] {
+				t.Errorf("entry %q: id %q empty or repeated", e.Message, e.ID)
+			}
+			ids[e.ID] = true
+		}
+	}
+
+	var again []ElasticEntry
+	bulk = func(_ context.Context, entries []ElasticEntry) error {
+		again = append(again, entries...)
+		return nil
+	}
+	if _, err := ExportElastic(context.Background(), dir, nil, bulk, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range again {
+		if !ids[e.ID] {
+			t.Errorf("entry %q got a new id on the second run", e.Message)
+		}
+	}
+}
+
+func TestExportSplunk(t *testing.T) {
+	dir, _ := writeLokiCapture(t)
+	cpPath := filepath.Join(t.TempDir(), "splunk.checkpoint")
+	opts := SplunkExportOptions{Target: "https://hec:8088", Index: "main", Checkpoint: cpPath, BatchLines: 4}
+
+	// the second file fails, the first is kept in the checkpoint
+	var sent []recv.LogEntry
+	send := func(_ context.Context, entries []recv.LogEntry) error {
+		if entries[0].Message == "line 5" {
+			return errors.New("HTTP 503")
+		}
+		sent = append(sent, entries...)
+		return nil
+	}
+	if _, err := ExportSplunk(context.Background(), dir, nil, send, nil, opts); err == nil || !strings.Contains(err.Error(), "push to splunk") {
+		t.Fatalf("err = %v, want a failed push", err)
+	}
+	if len(sent) != 5 {
+		t.Fatalf("sent %d entries before the failure, want the first file's 5", len(sent))
+	}
+
+	sent = nil
+	send = func(_ context.Context, entries []recv.LogEntry) error {
+		sent = append(sent, entries...)
+		return nil
+	}
+	opts.Resume = true
+	n, err := ExportSplunk(context.Background(), dir, nil, send, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 || len(sent) != 5 || sent[0].Message != "line 5" {
+		t.Errorf("resumed export: n = %d, sent %d entries starting at %q; want 10 in total, the second file's 5", n, len(sent), sent[0].Message)
+	}
+}
+
+func TestEntryID(t *testing.T) {
+	e := recv.LogEntry{Timestamp: time.Unix(1, 0), Message: "repeat"}
+	if entryID("a.jsonl", 0, e) == entryID("a.jsonl", 1, e) {
+		t.Error("identical entries at different positions should get different ids")
+	}
+	if entryID("a.jsonl", 0, e) != entryID("a.jsonl", 0, e) {
+		t.Error("entryID should be deterministic")
+	}
+}
+
+func TestLineLimiter(t *testing.T) {
+	l := lineLimiter{rate: 1000}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(context.Background(), 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 35*time.Millisecond {
+		t.Errorf("3 pushes of 20 lines at 1000/s took %v, want at least 40ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx, 1); err == nil {
+		t.Error("wait should fail on a canceled context")
+	}
+}
@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// skipGrep reports whether the bloom filter of data file name in dir rules
+// out every match of Grep: a literal the pattern requires is in no message
+// of the file, and none of its label values match. Files without a
+// readable bloom filter are kept.
+func (f *Filter) skipGrep(dir, name string, idx *rotate.IndexEntry) bool {
+	if f.Grep == nil {
+		return false
+	}
+	literals := requiredLiterals(f.Grep)
+	if len(literals) == 0 {
+		return false
+	}
+	b, err := rotate.ReadBloom(dir, name)
+	if err != nil || b == nil {
+		return false
+	}
+	for _, values := range idx.Labels {
+		for v := range values {
+			if f.Grep.MatchString(v) {
+				return false
+			}
+		}
+	}
+	for _, lit := range literals {
+		if !b.MayContain(lit) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredLiterals returns substrings every match of re contains, of at
+// least rotate.BloomTokenLen bytes. Case-insensitive literals are left out
+// unless ASCII, since the bloom filter folds ASCII case only.
+func requiredLiterals(re *regexp.Regexp) []string {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	collectLiterals(parsed.Simplify(), &out)
+	return out
+}
+
+func collectLiterals(re *syntax.Regexp, out *[]string) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		lit := string(re.Rune)
+		if re.Flags&syntax.FoldCase != 0 && !isASCII(lit) {
+			return
+		}
+		if len(lit) >= rotate.BloomTokenLen {
+			*out = append(*out, lit)
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			collectLiterals(sub, out)
+		}
+	case syntax.OpCapture, syntax.OpPlus:
+		collectLiterals(re.Sub[0], out)
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			collectLiterals(re.Sub[0], out)
+		}
+	}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package archive
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"timeout", []string{"timeout"}},
+		{"(?i)timeout", []string{"timeout"}},
+		{"connection.*refused", []string{"connection", "refused"}},
+		{"(upstream)+ failed", []string{"upstream", " failed"}},
+		{"error|warn", nil},
+		{"ab", nil},
+		{"(?i)café", nil},
+		{"caf(é)?", []string{"caf"}},
+		{"[0-9]+ms", nil},
+	}
+	for _, tt := range tests {
+		got := requiredLiterals(regexp.MustCompile(tt.pattern))
+		if !slices.EqualFunc(got, tt.want, strings.EqualFold) { // case-insensitive literals come back in either case
+			t.Errorf("requiredLiterals(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestGrepSkipsByBloom(t *testing.T) {
+	dir := writeFieldCapture(t, 30, true)
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := len(r.Files())
+	if files < 3 {
+		t.Fatalf("%d files, want several", files)
+	}
+
+	grep := func(pattern string) (matches, scanned int64) {
+		t.Helper()
+		f := &Filter{Grep: regexp.MustCompile(pattern)}
+		_, err := Grep(dir, f, GrepConfig{}, func(GrepMatch) { matches++ }, func(p GrepProgress) { scanned = p.Scanned })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return matches, scanned
+	}
+
+	matches, scanned := grep(`"t17"`)
+	if matches != 1 || scanned >= 30/int64(files)+2 {
+		t.Errorf("literal: %d matches, %d lines scanned, want 1 from one file", matches, scanned)
+	}
+	if matches, scanned := grep(`deadlock`); matches != 0 || scanned != 0 {
+		t.Errorf("absent literal: %d matches, %d lines scanned, want none", matches, scanned)
+	}
+	// label values are matched too, and are not in the bloom filter
+	if matches, _ := grep(`(?i)API`); matches != 30 {
+		t.Errorf("label match: %d matches, want 30", matches)
+	}
+	// patterns without a required literal scan every file
+	if matches, scanned := grep(`t0[0-9]|t1[0-9]`); matches != 20 || scanned != 30 {
+		t.Errorf("alternation: %d matches, %d lines scanned", matches, scanned)
+	}
+}
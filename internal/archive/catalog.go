@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -24,6 +25,10 @@ type CatalogEntry struct {
 	Bytes   int64     `json:"bytes"`
 	Active  bool      `json:"active"`
 	Labels  []string  `json:"labels,omitempty"`
+
+	Description string            `json:"description,omitempty"`
+	Owner       string            `json:"owner,omitempty"`
+	Meta        map[string]string `json:"meta,omitempty"`
 }
 
 // Catalog scans root for capture directories containing metadata.json.
@@ -92,6 +97,10 @@ func tryCapture(dir string) (CatalogEntry, bool) {
 		Bytes:   diskSize,
 		Active:  meta.Stopped.IsZero(),
 		Labels:  meta.LabelsSeen,
+
+		Description: meta.Description,
+		Owner:       meta.Owner,
+		Meta:        meta.Meta,
 	}, true
 }
 
@@ -109,16 +118,30 @@ func WriteCatalogText(w io.Writer, entries []CatalogEntry) {
 		return
 	}
 
+	// owner and description columns only when some capture has them
+	described := false
+	for _, e := range entries {
+		described = described || e.Owner != "" || e.Description != ""
+	}
+
 	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
-	_, _ = fmt.Fprintln(tw, "CAPTURE\tSTARTED\tSTOPPED\tFILES\tENTRIES\tSIZE")
+	header := "CAPTURE\tSTARTED\tSTOPPED\tFILES\tENTRIES\tSIZE"
+	if described {
+		header += "\tOWNER\tDESCRIPTION"
+	}
+	_, _ = fmt.Fprintln(tw, header)
 	for _, e := range entries {
 		started := e.Started.Format("2006-01-02 15:04")
 		stopped := "(active)"
 		if !e.Active {
 			stopped = e.Stopped.Format("2006-01-02 15:04")
 		}
-		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s",
 			e.Dir, started, stopped, e.Files, FormatCount(e.Entries), formatBytes(e.Bytes))
+		if described {
+			_, _ = fmt.Fprintf(tw, "\t%s\t%s", catalogCell(e.Owner, 24), catalogCell(e.Description, 48))
+		}
+		_, _ = fmt.Fprintln(tw)
 	}
 	_ = tw.Flush()
 }
@@ -135,3 +158,15 @@ func formatBytes(b int64) string {
 		return fmt.Sprintf("%dB", b)
 	}
 }
+
+// catalogCell fits s into one table cell of at most n runes, "-" if empty.
+func catalogCell(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return "-"
+	}
+	if r := []rune(s); len(r) > n {
+		s = string(r[:n-1]) + "…"
+	}
+	return s
+}
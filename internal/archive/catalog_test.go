@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -156,6 +157,36 @@ func TestCatalog_TextOutput(t *testing.T) {
 	}
 }
 
+func TestCatalog_CaptureInfo(t *testing.T) {
+	root := t.TempDir()
+	writeMeta(t, filepath.Join(root, "plain"), &recv.Metadata{
+		Started: time.Date(2026, 2, 20, 9, 0, 0, 0, time.UTC),
+	})
+	writeMeta(t, filepath.Join(root, "described"), &recv.Metadata{
+		Started:     time.Date(2026, 2, 20, 10, 0, 0, 0, time.UTC),
+		Description: "checkout load test run against the staging cluster after the cache rollout",
+		Owner:       "payments",
+		Meta:        map[string]string{"test-run": "1234"},
+	})
+
+	entries, err := Catalog(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Owner != "payments" || entries[0].Meta["test-run"] != "1234" {
+		t.Errorf("entry = %+v, want owner and meta", entries[0])
+	}
+
+	var buf bytes.Buffer
+	WriteCatalogText(&buf, entries)
+	out := buf.String()
+	for _, want := range []string{"OWNER", "DESCRIPTION", "payments", "checkout load test run", "…"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
 func TestCatalog_EmptyText(t *testing.T) {
 	var buf bytes.Buffer
 	WriteCatalogText(&buf, nil)
@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Checkpoint records the progress of a slice or export so an interrupted run
+// can resume from the last completed input file.
+type Checkpoint struct {
+	Op        string       `json:"op"`     // "slice" or "export"
+	Params    string       `json:"params"` // fingerprint of source and filters; resume requires a match
+	Completed []string     `json:"completed"`
+	Index     []IndexEntry `json:"index,omitempty"`   // slice: output index entries so far
+	Written   int64        `json:"written,omitempty"` // export: entries written so far
+	Offset    int64        `json:"offset,omitempty"`  // export: output size after the last completed file
+	Updated   time.Time    `json:"updated"`
+}
+
+// ReadCheckpoint loads a checkpoint. Returns nil without error if none exists.
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("parse checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// WriteCheckpoint atomically replaces the checkpoint at path.
+func WriteCheckpoint(path string, cp *Checkpoint) error {
+	cp.Updated = time.Now()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("marshal checkpoint: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Done reports whether the given input file was fully processed.
+func (c *Checkpoint) Done(file string) bool {
+	if c == nil {
+		return false
+	}
+	for _, f := range c.Completed {
+		if f == file {
+			return true
+		}
+	}
+	return false
+}
+
+// loadResumeCheckpoint returns the checkpoint at path when resuming and it
+// matches op and params. A mismatched checkpoint is an error so a resume
+// never mixes output from different filters.
+func loadResumeCheckpoint(path, op, params string) (*Checkpoint, error) {
+	cp, err := ReadCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	if cp == nil {
+		return nil, nil
+	}
+	if cp.Op != op || cp.Params != params {
+		return nil, fmt.Errorf("checkpoint %s was written by a %s with different options; rerun without --resume", path, cp.Op)
+	}
+	return cp, nil
+}
+
+// filterFingerprint renders the filter in a stable form for checkpoint matching.
+func filterFingerprint(f *Filter) string {
+	if f == nil {
+		return ""
+	}
+	grep := ""
+	if f.Grep != nil {
+		grep = f.Grep.String()
+	}
+	fp := fmt.Sprintf("from=%s to=%s labels=%v grep=%q",
+		f.From.UTC().Format(time.RFC3339Nano), f.To.UTC().Format(time.RFC3339Nano), f.Labels, grep)
+	if f.Restarts != nil {
+		fp += " " + f.Restarts.String()
+	}
+	if f.Phases != nil {
+		fp += " " + f.Phases.String()
+	}
+	return fp
+}
@@ -0,0 +1,194 @@
+package archive
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cp.json")
+
+	cp, err := ReadCheckpoint(path)
+	if err != nil || cp != nil {
+		t.Fatalf("missing checkpoint: got %v, %v; want nil, nil", cp, err)
+	}
+
+	want := &Checkpoint{Op: "export", Params: "p", Completed: []string{"a.jsonl"}, Written: 3, Offset: 42}
+	if err := WriteCheckpoint(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Done("a.jsonl") || got.Done("b.jsonl") {
+		t.Errorf("Done mismatch: %+v", got.Completed)
+	}
+	if got.Written != 3 || got.Offset != 42 || got.Updated.IsZero() {
+		t.Errorf("checkpoint = %+v", got)
+	}
+
+	if _, err := loadResumeCheckpoint(path, "export", "other"); err == nil {
+		t.Error("expected error for mismatched params")
+	}
+	if _, err := loadResumeCheckpoint(path, "slice", "p"); err == nil {
+		t.Error("expected error for mismatched op")
+	}
+}
+
+func setupTwoFileSource(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	var first, second []recv.LogEntry
+	for i := 0; i < 3; i++ {
+		first = append(first, recv.LogEntry{Timestamp: base.Add(time.Duration(i) * time.Second), Labels: map[string]string{"app": "api"}, Message: "first file"})
+		second = append(second, recv.LogEntry{Timestamp: base.Add(time.Hour + time.Duration(i)*time.Second), Labels: map[string]string{"app": "api"}, Message: "second file"})
+	}
+	writeMetadata(t, dir, base, base.Add(time.Hour+2*time.Second), 6)
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", first)
+	writeDataFile(t, dir, "2024-01-15T110000-000.jsonl", second)
+	writeIndex(t, dir, []rotate.IndexEntry{
+		{File: "2024-01-15T100000-000.jsonl", From: base, To: base.Add(2 * time.Second), Lines: 3},
+		{File: "2024-01-15T110000-000.jsonl", From: base.Add(time.Hour), To: base.Add(time.Hour + 2*time.Second), Lines: 3},
+	})
+	return dir
+}
+
+func TestExportResume(t *testing.T) {
+	for _, format := range []ExportFormat{FormatJSONL, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			src := setupTwoFileSource(t)
+			outDir := t.TempDir()
+
+			ref := filepath.Join(outDir, "ref")
+			if err := Export(src, ref, format, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(ref + ".checkpoint"); !os.IsNotExist(err) {
+				t.Error("expected checkpoint to be removed after a completed export")
+			}
+			want, err := os.ReadFile(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// simulate an export interrupted midway through the second file
+			idx := bytes.Index(want, []byte("second file"))
+			offset := int64(bytes.LastIndexByte(want[:idx], '\n') + 1)
+			out := filepath.Join(outDir, "out")
+			partial := append(append([]byte{}, want[:offset]...), []byte("partial line without newl")...)
+			if err := os.WriteFile(out, partial, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteCheckpoint(out+".checkpoint", &Checkpoint{
+				Op:        "export",
+				Params:    exportParams(src, format, nil),
+				Completed: []string{"2024-01-15T100000-000.jsonl"},
+				Written:   3,
+				Offset:    offset,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			var final ExportProgress
+			if err := ExportResume(src, out, format, nil, func(p ExportProgress) { final = p }); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("resumed output differs from a full export:\ngot:\n%s\nwant:\n%s", got, want)
+			}
+			if final.Written != 6 {
+				t.Errorf("written = %d, want 6", final.Written)
+			}
+		})
+	}
+}
+
+func TestExportResume_Errors(t *testing.T) {
+	src := setupTwoFileSource(t)
+	out := filepath.Join(t.TempDir(), "out.parquet")
+	if err := ExportResume(src, out, FormatParquet, nil, nil); err == nil {
+		t.Error("expected error for parquet resume")
+	}
+
+	out = filepath.Join(t.TempDir(), "out.jsonl")
+	if err := WriteCheckpoint(out+".checkpoint", &Checkpoint{Op: "export", Params: "stale"}); err != nil {
+		t.Fatal(err)
+	}
+	err := ExportResume(src, out, FormatJSONL, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "different options") {
+		t.Errorf("expected mismatched checkpoint error, got %v", err)
+	}
+}
+
+func TestSliceResume(t *testing.T) {
+	tempDir := t.TempDir()
+	captureDir := filepath.Join(tempDir, "capture")
+	outputDir := filepath.Join(tempDir, "output")
+
+	logFile1 := "2024-01-01T100000-000.jsonl.zst"
+	logFile2 := "2024-01-01T101000-000.jsonl.zst"
+	t1 := time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+	t2 := time.Date(2024, time.January, 1, 10, 10, 0, 0, time.UTC)
+	entries := []IndexEntry{
+		{File: logFile1, From: t1, To: t1.Add(time.Minute), Lines: 2, Bytes: 100},
+		{File: logFile2, From: t2, To: t2.Add(time.Minute), Lines: 1, Bytes: 50},
+	}
+	logs := map[string][]string{
+		logFile1: {`{"ts":"...","labels":{"app":"api"},"msg":"line 1"}`, `{"ts":"...","labels":{"app":"api"},"msg":"line 2"}`},
+		logFile2: {`{"ts":"...","labels":{"app":"worker"},"msg":"task"}`},
+	}
+	createDummyCapture(t, captureDir, entries, logs)
+
+	// first file was sliced before the interruption
+	opts := SliceOptions{CaptureDir: captureDir, OutputDir: outputDir, Resume: true}
+	createDummyCapture(t, outputDir, nil, map[string][]string{logFile1: logs[logFile1]})
+	if err := WriteCheckpoint(filepath.Join(outputDir, sliceCheckpointFile), &Checkpoint{
+		Op:        "slice",
+		Params:    opts.fingerprint(),
+		Completed: []string{logFile1},
+		Index:     []IndexEntry{{File: logFile1, From: t1, To: t1.Add(time.Minute), Lines: 2, Bytes: 100}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// removing the source proves the completed file is not re-read
+	if err := os.Remove(filepath.Join(captureDir, logFile1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Slice(opts); err != nil {
+		t.Fatalf("Slice resume: %v", err)
+	}
+
+	meta, err := ReadMetadata(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TotalLines != 3 {
+		t.Errorf("TotalLines = %d, want 3", meta.TotalLines)
+	}
+	idx, err := ReadIndex(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Entries) != 2 || idx.Entries[0].File != logFile1 || idx.Entries[1].File != logFile2 {
+		t.Errorf("index = %+v", idx.Entries)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, sliceCheckpointFile)); !os.IsNotExist(err) {
+		t.Error("expected checkpoint to be removed after a completed slice")
+	}
+}
@@ -224,7 +224,7 @@ func RewriteWithOffset(src, dst string, offset time.Duration) (int64, error) {
 
 	// write metadata
 	outMeta := &recv.Metadata{
-		Version:    1,
+		Version:    recv.MetadataVersion,
 		Format:     "jsonl",
 		Started:    minTS,
 		Stopped:    maxTS,
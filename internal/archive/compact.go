@@ -0,0 +1,503 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// DefaultCompactTargetSize is the size compact grows merged files to.
+const DefaultCompactTargetSize = 256 << 20
+
+// CompactOptions configures Compact.
+type CompactOptions struct {
+	TargetSize int64 // merged files grow up to this many bytes on disk
+	DryRun     bool
+}
+
+// CompactGroup is a run of adjacent data files merged into one.
+type CompactGroup struct {
+	Dir         string   `json:"dir"`
+	Files       []string `json:"files"`
+	Output      string   `json:"output"`
+	Lines       int64    `json:"lines"`
+	BytesBefore int64    `json:"bytes_before"`
+	BytesAfter  int64    `json:"bytes_after,omitempty"` // not known in a dry run
+}
+
+// CompactResult summarizes a compaction.
+type CompactResult struct {
+	Dir         string         `json:"dir"`
+	TargetSize  int64          `json:"target_size"`
+	DryRun      bool           `json:"dry_run"`
+	FilesBefore int            `json:"files_before"`
+	FilesAfter  int            `json:"files_after"`
+	BytesBefore int64          `json:"bytes_before"`
+	BytesAfter  int64          `json:"bytes_after"`
+	Groups      []CompactGroup `json:"groups"`
+}
+
+// Compact merges runs of adjacent small data files of a finished capture
+// into files of up to TargetSize bytes and rebuilds the index. Lines keep
+// their order, and each merged file takes the name of the first file of
+// its run, so files still sort chronologically. Files offloaded to object
+// storage or encrypted are left alone, and so are orphans. In a capture
+// recorded with recv --partition-by only files of the same partition are
+// merged. Compact must not run while a receiver writes to dir.
+func Compact(dir string, opts CompactOptions) (*CompactResult, error) {
+	if opts.TargetSize <= 0 {
+		opts.TargetSize = DefaultCompactTargetSize
+	}
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+	result := &CompactResult{Dir: dir, TargetSize: opts.TargetSize, DryRun: opts.DryRun}
+	for _, d := range captureDirs(dir, meta) {
+		if err := compactDir(d, meta.PartitionBy, opts, result); err != nil {
+			return result, fmt.Errorf("compact %s: %w", d, err)
+		}
+	}
+	return result, nil
+}
+
+// compactFile is an index entry and the size of its file on disk; size is
+// -1 for files compact leaves alone.
+type compactFile struct {
+	entry rotate.IndexEntry
+	size  int64
+}
+
+func compactDir(dir, partitionBy string, opts CompactOptions, result *CompactResult) error {
+	index, err := readIndex(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read index: %w", err)
+	}
+	offload, err := ReadOffloadManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	files := make([]compactFile, len(index))
+	for i, e := range index {
+		files[i] = compactFile{entry: e, size: -1}
+		if offload.Files[e.File] != "" || strings.HasSuffix(e.File, EncryptedSuffix) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, e.File))
+		if err != nil {
+			continue
+		}
+		files[i].size = info.Size()
+		result.BytesBefore += info.Size()
+	}
+	result.FilesBefore += len(files)
+
+	runs := compactRuns(files, partitionBy, opts.TargetSize)
+	merged := make(map[int]rotate.IndexEntry) // first file of a run → its merged entry
+	dropped := make(map[int]bool)
+	for _, run := range runs {
+		group := CompactGroup{Dir: dir}
+		for _, i := range run {
+			group.Files = append(group.Files, files[i].entry.File)
+			group.Lines += files[i].entry.Lines
+			group.BytesBefore += files[i].size
+			dropped[i] = true
+		}
+		entry := mergeIndexEntries(files, run)
+		group.Output = entry.File
+		if opts.DryRun {
+			result.BytesAfter += group.BytesBefore
+			merged[run[0]] = entry
+		} else {
+			m, err := mergeDataFiles(dir, entry.File, group.Files)
+			if err != nil {
+				return err
+			}
+			entry.SHA256 = m.sum
+			merged[run[0]] = entry
+			// the index drops the inputs before they are removed, so an
+			// interrupted run leaves their lines to readers as orphans
+			// rather than an index naming files that are gone
+			if err := replaceIndexFile(dir, compactIndex(files, merged, dropped)); err != nil {
+				m.discard()
+				return fmt.Errorf("write index: %w", err)
+			}
+			if err := m.commit(); err != nil {
+				return err
+			}
+			group.BytesAfter = m.size
+			result.BytesAfter += m.size
+		}
+		result.Groups = append(result.Groups, group)
+	}
+
+	result.FilesAfter += len(compactIndex(files, merged, dropped))
+	for i, f := range files {
+		if f.size >= 0 && !dropped[i] {
+			result.BytesAfter += f.size
+		}
+	}
+	return nil
+}
+
+// compactIndex returns the index of files with the runs merged so far:
+// merged holds the entry of each merged run's first file, and dropped every
+// file of those runs.
+func compactIndex(files []compactFile, merged map[int]rotate.IndexEntry, dropped map[int]bool) []rotate.IndexEntry {
+	var out []rotate.IndexEntry
+	for i, f := range files {
+		if e, ok := merged[i]; ok {
+			out = append(out, e)
+		} else if !dropped[i] {
+			out = append(out, f.entry)
+		}
+	}
+	return out
+}
+
+// replaceIndexFile writes entries to a temporary file and renames it over
+// index.jsonl, so readers never see a partial index.
+func replaceIndexFile(dir string, entries []rotate.IndexEntry) error {
+	tmp, err := os.MkdirTemp(dir, ".compact-index-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	if err := writeIndexFile(tmp, entries); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(tmp, "index.jsonl"), filepath.Join(dir, "index.jsonl"))
+}
+
+// compactRuns groups adjacent files of the same partition whose sizes add
+// up to at most target. Only runs of two or more files are returned.
+func compactRuns(files []compactFile, partitionBy string, target int64) [][]int {
+	var runs [][]int
+	open := make(map[string][]int) // partition → current run
+	sizes := make(map[string]int64)
+	flush := func(key string) {
+		if len(open[key]) > 1 {
+			runs = append(runs, open[key])
+		}
+		delete(open, key)
+		delete(sizes, key)
+	}
+	for i, f := range files {
+		key := partitionOf(f.entry, partitionBy)
+		if f.size < 0 {
+			flush(key)
+			continue
+		}
+		if len(open[key]) > 0 && sizes[key]+f.size > target {
+			flush(key)
+		}
+		open[key] = append(open[key], i)
+		sizes[key] += f.size
+	}
+	for key := range open {
+		flush(key)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i][0] < runs[j][0] })
+	return runs
+}
+
+// partitionOf returns the partitionBy value of a file holding lines of one
+// value only, or "" for the unpartitioned series.
+func partitionOf(e rotate.IndexEntry, partitionBy string) string {
+	if partitionBy == "" || len(e.Labels[partitionBy]) != 1 {
+		return ""
+	}
+	for v := range e.Labels[partitionBy] {
+		return v
+	}
+	return ""
+}
+
+// mergeIndexEntries builds the index entry of a run's merged file, named
+// after its first file; it is compressed if any file of the run was.
+func mergeIndexEntries(files []compactFile, run []int) rotate.IndexEntry {
+	first := files[run[0]].entry
+	out := rotate.IndexEntry{File: strings.TrimSuffix(first.File, ".zst")}
+	compressed := false
+	for _, i := range run {
+		e := files[i].entry
+		compressed = compressed || strings.HasSuffix(e.File, ".zst")
+		if !e.From.IsZero() && (out.From.IsZero() || e.From.Before(out.From)) {
+			out.From = e.From
+		}
+		if e.To.After(out.To) {
+			out.To = e.To
+		}
+		out.Lines += e.Lines
+		out.Bytes += e.Bytes
+		for k, vals := range e.Labels {
+			if out.Labels == nil {
+				out.Labels = make(map[string]map[string]int64)
+			}
+			if out.Labels[k] == nil {
+				out.Labels[k] = make(map[string]int64)
+			}
+			for v, n := range vals {
+				out.Labels[k][v] += n
+			}
+		}
+	}
+	if compressed {
+		out.File += ".zst"
+	}
+	return out
+}
+
+// mergeFieldIndexes returns the field index of a file merged from inputs,
+// or nil unless every input has one.
+func mergeFieldIndexes(dir string, inputs []string) (*rotate.FieldIndex, error) {
+	var merged *rotate.FieldIndex
+	for _, name := range inputs {
+		fi, err := rotate.ReadFieldIndex(dir, name)
+		if err != nil || fi == nil {
+			return nil, err
+		}
+		if merged == nil {
+			merged = fi
+		} else {
+			merged.Merge(fi)
+		}
+	}
+	return merged, nil
+}
+
+// hasBlooms reports whether every input has a bloom filter.
+func hasBlooms(dir string, inputs []string) bool {
+	for _, name := range inputs {
+		if _, err := os.Stat(filepath.Join(dir, name+rotate.BloomSuffix)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomWriter feeds the lines written to it to a bloom filter builder.
+type bloomWriter struct {
+	bb      *rotate.BloomBuilder
+	partial []byte
+}
+
+func (w *bloomWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.partial = append(w.partial, p...)
+			return n, nil
+		}
+		if len(w.partial) > 0 {
+			w.bb.AddLine(append(w.partial, p[:i]...))
+			w.partial = w.partial[:0]
+		} else {
+			w.bb.AddLine(p[:i])
+		}
+		p = p[i+1:]
+	}
+}
+
+// mergedFile is a data file merged from inputs, written under a temporary
+// name until commit puts it in place.
+type mergedFile struct {
+	dir, tmp, output string
+	inputs           []string
+	size             int64
+	sum              string // SHA-256
+	fields           *rotate.FieldIndex
+	tokens           *bloomWriter
+}
+
+// mergeDataFiles concatenates the lines of inputs in dir into a temporary
+// file, to become output on commit, and builds a merged field index when
+// each input had one and a bloom filter of the merged lines when each input
+// had one. Until commit every input stays in place.
+func mergeDataFiles(dir, output string, inputs []string) (*mergedFile, error) {
+	fields, err := mergeFieldIndexes(dir, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("read field index: %w", err)
+	}
+	var tokens *bloomWriter
+	if hasBlooms(dir, inputs) {
+		tokens = &bloomWriter{bb: rotate.NewBloomBuilder()}
+	}
+
+	tmp, err := os.CreateTemp(dir, ".compact-*")
+	if err != nil {
+		return nil, err
+	}
+	m := &mergedFile{dir: dir, tmp: tmp.Name(), output: output, inputs: inputs, fields: fields, tokens: tokens}
+	fail := func(err error) (*mergedFile, error) {
+		_ = tmp.Close()
+		m.discard()
+		return nil, err
+	}
+
+	sum := sha256.New()
+	bw := bufio.NewWriterSize(io.MultiWriter(tmp, sum), 256*1024)
+	var w io.Writer = bw
+	var enc *zstd.Encoder
+	if strings.HasSuffix(output, ".zst") {
+		if enc, err = zstd.NewWriter(bw); err != nil {
+			return fail(err)
+		}
+		w = enc
+	}
+	if tokens != nil {
+		w = io.MultiWriter(w, tokens)
+	}
+	for _, name := range inputs {
+		if err := copyDataFile(w, filepath.Join(dir, name)); err != nil {
+			return fail(fmt.Errorf("read %s: %w", name, err))
+		}
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return fail(err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Chmod(0o640); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		m.discard()
+		return nil, err
+	}
+	info, err := os.Stat(m.tmp)
+	if err != nil {
+		m.discard()
+		return nil, err
+	}
+	m.size = info.Size()
+	m.sum = hex.EncodeToString(sum.Sum(nil))
+	return m, nil
+}
+
+// discard removes the merged file before it is committed.
+func (m *mergedFile) discard() {
+	_ = os.Remove(m.tmp)
+}
+
+// commit puts the merged file in place of its inputs: the inputs' sidecars
+// go first, as the output may take an input's name, then the inputs, and
+// the merged file's own field index and bloom filter are written last.
+func (m *mergedFile) commit() error {
+	for _, name := range m.inputs {
+		for _, suffix := range rotate.SidecarSuffixes {
+			if err := os.Remove(filepath.Join(m.dir, name+suffix)); err != nil && !os.IsNotExist(err) {
+				m.discard()
+				return err
+			}
+		}
+	}
+	if err := os.Rename(m.tmp, filepath.Join(m.dir, m.output)); err != nil {
+		m.discard()
+		return err
+	}
+	for _, name := range m.inputs {
+		if name == m.output {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if m.fields != nil {
+		if err := rotate.WriteFieldIndex(m.dir, m.output, m.fields, false); err != nil {
+			return fmt.Errorf("write field index: %w", err)
+		}
+	}
+	if m.tokens != nil {
+		if b := m.tokens.bb.Bloom(); b != nil {
+			if err := rotate.WriteBloom(m.dir, m.output, b, false); err != nil {
+				return fmt.Errorf("write bloom filter: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyDataFile writes the lines of a plain or zstd data file to w, ending
+// with a newline.
+func copyDataFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".zst") {
+		dec, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer dec.Close()
+		r = dec
+	}
+	var last byte = '\n'
+	buf := make([]byte, 256*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if last != '\n' {
+		_, err = w.Write([]byte{'\n'})
+	}
+	return err
+}
+
+// WriteJSON writes the result as indented JSON.
+func (r *CompactResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a human-readable summary.
+func (r *CompactResult) WriteText(w io.Writer) {
+	tw := &textWriter{w: w}
+	verb := "Compacted"
+	if r.DryRun {
+		verb = "Dry run: would compact"
+	}
+	tw.printf("%s %d files into %d (%s -> %s, target %s)\n", verb,
+		r.FilesBefore, r.FilesAfter, FormatBytes(r.BytesBefore), FormatBytes(r.BytesAfter), FormatBytes(r.TargetSize))
+	for _, g := range r.Groups {
+		tw.printf("  %s  %d files, %d lines\n", g.Output, len(g.Files), g.Lines)
+	}
+}
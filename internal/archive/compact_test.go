@@ -0,0 +1,256 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// writeRotatedCapture records n lines through a rotator with tiny files.
+func writeRotatedCapture(t *testing.T, n int, cfg rotate.Config, apps ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	cfg.Dir = dir
+	cfg.MaxFile = 150
+	cfg.MaxDisk = 1 << 30
+	rot, err := rotate.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := range n {
+		e := recv.LogEntry{
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Labels:    map[string]string{"app": apps[i%len(apps)]},
+			Message:   fmt.Sprintf("line %03d", i),
+		}
+		data, _ := json.Marshal(e)
+		if _, err := rot.WriteLabeled(append(data, '\n'), e.Timestamp, e.Labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rot.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeMetadata(t, dir, base, base.Add(time.Duration(n)*time.Second), int64(n))
+	return dir
+}
+
+func scanMessages(t *testing.T, dir string) []string {
+	t.Helper()
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	if _, err := r.Scan(nil, func(e recv.LogEntry) bool {
+		msgs = append(msgs, e.Message)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return msgs
+}
+
+func TestCompact(t *testing.T) {
+	dir := writeRotatedCapture(t, 60, rotate.Config{Compress: true}, "web")
+	before := scanMessages(t, dir)
+	files, _ := readIndex(dir)
+
+	result, err := Compact(dir, CompactOptions{TargetSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilesBefore != len(files) || result.FilesAfter != 1 || len(result.Groups) != 1 {
+		t.Fatalf("result = %+v, want %d files merged into 1", result, len(files))
+	}
+
+	index, err := readIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 1 || index[0].File != files[0].File || index[0].Lines != 60 || index[0].Labels["app"]["web"] != 60 {
+		t.Errorf("index = %+v", index)
+	}
+	if got := scanMessages(t, dir); strings.Join(got, ",") != strings.Join(before, ",") {
+		t.Errorf("lines changed by compaction:\n%v\n%v", got, before)
+	}
+	entries, _ := os.ReadDir(dir)
+	var data int
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".jsonl.zst") {
+			data++
+		}
+		if strings.HasPrefix(e.Name(), ".compact") {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+	if data != 1 {
+		t.Errorf("%d data files on disk, want 1", data)
+	}
+}
+
+func TestCompact_FieldIndex(t *testing.T) {
+	dir := writeFieldCapture(t, 30, true)
+	if _, err := Compact(dir, CompactOptions{TargetSize: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	index, err := readIndex(dir)
+	if err != nil || len(index) != 1 {
+		t.Fatalf("index = %+v, %v", index, err)
+	}
+	fi, err := rotate.ReadFieldIndex(dir, index[0].File)
+	if err != nil || fi == nil {
+		t.Fatalf("merged field index = %v, %v", fi, err)
+	}
+	if len(fi.Fields["trace_id"]) != 30 || fi.MayContain("trace_id", "t99") {
+		t.Errorf("merged field index = %+v", fi)
+	}
+	entries, _ := os.ReadDir(dir)
+	var sidecars int
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), rotate.FieldIndexSuffix) {
+			sidecars++
+		}
+	}
+	if sidecars != 1 {
+		t.Errorf("%d field indexes on disk, want 1", sidecars)
+	}
+
+	b, err := rotate.ReadBloom(dir, index[0].File)
+	if err != nil || b == nil {
+		t.Fatalf("merged bloom filter = %v, %v", b, err)
+	}
+	if !b.MayContain(`"t00"`) || !b.MayContain(`"t29"`) {
+		t.Error("merged bloom filter lost tokens of an input")
+	}
+	if _, err := os.Stat(filepath.Join(dir, index[0].File+rotate.BloomSuffix)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompact_TargetSize(t *testing.T) {
+	dir := writeRotatedCapture(t, 60, rotate.Config{}, "web")
+	files, _ := readIndex(dir)
+
+	// two or three source files fit the target
+	result, err := Compact(dir, CompactOptions{TargetSize: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilesAfter >= len(files) || result.FilesAfter < len(files)/3 {
+		t.Errorf("files %d -> %d", len(files), result.FilesAfter)
+	}
+	for _, g := range result.Groups {
+		if g.BytesBefore > 400 {
+			t.Errorf("group %s holds %d bytes, over the target", g.Output, g.BytesBefore)
+		}
+	}
+	if got := scanMessages(t, dir); len(got) != 60 || got[0] != "line 000" || got[59] != "line 059" {
+		t.Errorf("scanned %d lines", len(got))
+	}
+}
+
+func TestCompact_DryRun(t *testing.T) {
+	dir := writeRotatedCapture(t, 30, rotate.Config{}, "web")
+	before, _ := os.ReadFile(filepath.Join(dir, "index.jsonl"))
+
+	result, err := Compact(dir, CompactOptions{TargetSize: 1 << 20, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilesAfter != 1 || len(result.Groups) != 1 {
+		t.Errorf("result = %+v", result)
+	}
+	after, _ := os.ReadFile(filepath.Join(dir, "index.jsonl"))
+	if string(after) != string(before) {
+		t.Error("dry run rewrote the index")
+	}
+}
+
+func TestCompact_Partitions(t *testing.T) {
+	dir := writeRotatedCapture(t, 60, rotate.Config{PartitionBy: "app"}, "web", "api")
+	meta, _ := recv.ReadMetadata(dir)
+	meta.PartitionBy = "app"
+	if err := recv.WriteMetadata(dir, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Compact(dir, CompactOptions{TargetSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilesAfter != 2 {
+		t.Fatalf("files after = %d, want one per partition", result.FilesAfter)
+	}
+	index, _ := readIndex(dir)
+	for _, e := range index {
+		if len(e.Labels["app"]) != 1 || e.Lines != 30 {
+			t.Errorf("entry %s: labels %v, %d lines", e.File, e.Labels["app"], e.Lines)
+		}
+	}
+}
+
+func TestCompact_SkipsOffloaded(t *testing.T) {
+	dir := writeRotatedCapture(t, 60, rotate.Config{}, "web")
+	files, _ := readIndex(dir)
+	middle := files[len(files)/2].File
+	manifest := fmt.Sprintf(`{"files":{%q:"s3://bucket/%s"}}`, middle, middle)
+	if err := os.WriteFile(filepath.Join(dir, OffloadManifestFile), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Compact(dir, CompactOptions{TargetSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilesAfter != 3 {
+		t.Errorf("files after = %d, want the runs either side of the offloaded file and the file itself", result.FilesAfter)
+	}
+	if _, err := os.Stat(filepath.Join(dir, middle)); err != nil {
+		t.Errorf("offloaded file touched: %v", err)
+	}
+}
+
+func TestCompact_FailedRunKeepsIndex(t *testing.T) {
+	dir := writeRotatedCapture(t, 60, rotate.Config{Compress: true}, "web")
+	files, _ := readIndex(dir)
+
+	// a corrupt last file fails the last run after the first has merged
+	last := filepath.Join(dir, files[len(files)-1].File)
+	if err := os.WriteFile(last, []byte("not zstd"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Compact(dir, CompactOptions{TargetSize: 200})
+	if err == nil {
+		t.Fatal("expected an error for the corrupt file")
+	}
+	if len(result.Groups) == 0 {
+		t.Fatal("no run merged before the corrupt one")
+	}
+
+	index, err := readIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines int64
+	for _, e := range index {
+		if _, err := os.Stat(filepath.Join(dir, e.File)); err != nil {
+			t.Errorf("index names %s: %v", e.File, err)
+		}
+		lines += e.Lines
+	}
+	if lines != 60 {
+		t.Errorf("index holds %d lines, want 60", lines)
+	}
+	if len(index) >= len(files) {
+		t.Errorf("index has %d files, want the merged runs recorded", len(index))
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math"
 	"os"
 	"regexp"
@@ -12,8 +11,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/klauspost/compress/zstd"
-
 	"github.com/ppiankov/logtap/internal/recv"
 )
 
@@ -28,8 +25,39 @@ type Correlation struct {
 	TargetError string  `json:"target_error"` // first error from target
 }
 
-// minConfidence is the threshold below which correlations are discarded.
-const minConfidence = 0.5
+// Correlation defaults, used when the matching CorrelateConfig field is unset.
+const (
+	minConfidence       = 0.5              // threshold below which correlations are discarded
+	defaultCorrWindow   = 10 * time.Second // error bucket width
+	defaultCorrMaxLag   = 5                // lag considered, in windows
+	defaultServiceLabel = "app"
+)
+
+// CorrelateConfig controls cross-service correlation.
+type CorrelateConfig struct {
+	Window        time.Duration // error bucket width (default 10s)
+	MaxLag        time.Duration // longest source-to-target lag considered (default 5 windows)
+	MinConfidence *float64      // discard correlations at or below this (nil = 0.5)
+	ServiceLabel  string        // label key that names the service (default "app")
+	Profile       *Profile      // per-file read profile, files named "<file> (correlation)" (nil = off)
+}
+
+func (c CorrelateConfig) withDefaults() CorrelateConfig {
+	if c.Window <= 0 {
+		c.Window = defaultCorrWindow
+	}
+	if c.MaxLag <= 0 {
+		c.MaxLag = defaultCorrMaxLag * c.Window
+	}
+	if c.MinConfidence == nil {
+		v := minConfidence
+		c.MinConfidence = &v
+	}
+	if c.ServiceLabel == "" {
+		c.ServiceLabel = defaultServiceLabel
+	}
+	return c
+}
 
 // serviceErrors holds error occurrences for a single service, keyed by window bucket.
 type serviceErrors struct {
@@ -39,8 +67,17 @@ type serviceErrors struct {
 
 // Correlate analyzes error entries grouped by label to detect temporal cascade patterns.
 func Correlate(dir string, windowSize time.Duration) ([]Correlation, error) {
-	if windowSize <= 0 {
-		windowSize = 10 * time.Second
+	return CorrelateWithConfig(dir, CorrelateConfig{Window: windowSize})
+}
+
+// CorrelateWithConfig is Correlate with explicit window, lag, confidence, and
+// service label settings.
+func CorrelateWithConfig(dir string, cfg CorrelateConfig) ([]Correlation, error) {
+	cfg = cfg.withDefaults()
+	windowSize := cfg.Window
+	maxLag := int(cfg.MaxLag / windowSize)
+	if maxLag < 1 {
+		maxLag = 1
 	}
 
 	reader, err := NewReader(dir)
@@ -51,7 +88,7 @@ func Correlate(dir string, windowSize time.Duration) ([]Correlation, error) {
 	// pass 1: read all entries, group errors by service
 	services := make(map[string]*serviceErrors)
 	for _, f := range reader.Files() {
-		if err := scanFileForCorrelation(f, windowSize, services); err != nil {
+		if err := scanFileForCorrelation(f, windowSize, cfg.ServiceLabel, services, cfg.Profile); err != nil {
 			return nil, fmt.Errorf("scan %s: %w", f.Name, err)
 		}
 	}
@@ -77,8 +114,8 @@ func Correlate(dir string, windowSize time.Duration) ([]Correlation, error) {
 			}
 			tgt := services[tgtName]
 
-			c := computeCorrelation(srcName, tgtName, src, tgt, windowSize)
-			if c != nil && c.Confidence > minConfidence {
+			c := computeCorrelation(srcName, tgtName, src, tgt, windowSize, maxLag, *cfg.MinConfidence)
+			if c != nil && c.Confidence > *cfg.MinConfidence {
 				correlations = append(correlations, *c)
 			}
 		}
@@ -95,8 +132,8 @@ func Correlate(dir string, windowSize time.Duration) ([]Correlation, error) {
 	return correlations, nil
 }
 
-func scanFileForCorrelation(f FileInfo, windowSize time.Duration, services map[string]*serviceErrors) error {
-	file, err := os.Open(f.Path)
+func scanFileForCorrelation(f FileInfo, windowSize time.Duration, labelKey string, services map[string]*serviceErrors, profile *Profile) error {
+	file, name, err := openDataFile(f)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // file rotated away during scan
@@ -105,13 +142,16 @@ func scanFileForCorrelation(f FileInfo, windowSize time.Duration, services map[s
 	}
 	defer func() { _ = file.Close() }()
 
-	var r io.Reader = file
-	if strings.HasSuffix(f.Name, ".zst") {
-		dec, err := zstd.NewReader(file)
+	prof := profile.profileFile(f.Name + " (correlation)")
+	defer prof.done()
+
+	r := prof.wrapFile(file)
+	if strings.HasSuffix(name, ".zst") {
+		dec, closeDec, err := prof.openZstd(r)
 		if err != nil {
-			return fmt.Errorf("zstd open: %w", err)
+			return err
 		}
-		defer dec.Close()
+		defer closeDec()
 		r = dec
 	}
 
@@ -130,15 +170,19 @@ func scanFileForCorrelation(f FileInfo, windowSize time.Duration, services map[s
 		}
 
 		var entry recv.LogEntry
-		if err := json.Unmarshal(line, &entry); err != nil {
+		t := prof.now()
+		err := json.Unmarshal(line, &entry)
+		prof.decoded(t)
+		if err != nil {
 			continue
 		}
 
-		if !IsError(entry.Message) {
-			continue
+		t = prof.now()
+		svcName := ""
+		if IsError(entry.Message) {
+			svcName = serviceLabel(entry.Labels, labelKey)
 		}
-
-		svcName := serviceLabel(entry.Labels)
+		prof.filtered(t)
 		if svcName == "" {
 			continue
 		}
@@ -159,9 +203,9 @@ func scanFileForCorrelation(f FileInfo, windowSize time.Duration, services map[s
 	return scanner.Err()
 }
 
-// serviceLabel extracts the service name from labels. Uses "app" key, falls back to first label.
-func serviceLabel(labels map[string]string) string {
-	if v, ok := labels["app"]; ok && v != "" {
+// serviceLabel extracts the service name from labels. Uses the given key, falls back to first label.
+func serviceLabel(labels map[string]string, key string) string {
+	if v, ok := labels[key]; ok && v != "" {
 		return v
 	}
 	// fall back to first label value (sorted for determinism)
@@ -176,7 +220,7 @@ func serviceLabel(labels map[string]string) string {
 	return ""
 }
 
-func computeCorrelation(srcName, tgtName string, src, tgt *serviceErrors, windowSize time.Duration) *Correlation {
+func computeCorrelation(srcName, tgtName string, src, tgt *serviceErrors, windowSize time.Duration, maxLag int, minConf float64) *Correlation {
 	// find overlapping time range
 	var minBucket, maxBucket int64
 	first := true
@@ -224,8 +268,7 @@ func computeCorrelation(srcName, tgtName string, src, tgt *serviceErrors, window
 		}
 	}
 
-	// compute cross-correlation at offsets -maxLag to +maxLag
-	maxLag := 5 // up to 5 windows of lag
+	// compute cross-correlation at offsets 0 to +maxLag
 	if maxLag > nBuckets-1 {
 		maxLag = nBuckets - 1
 	}
@@ -241,7 +284,7 @@ func computeCorrelation(srcName, tgtName string, src, tgt *serviceErrors, window
 		}
 	}
 
-	if bestCorr <= minConfidence {
+	if bestCorr <= minConf {
 		return nil
 	}
 
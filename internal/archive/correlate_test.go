@@ -313,7 +313,7 @@ func TestServiceLabel(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := serviceLabel(tt.labels)
+			got := serviceLabel(tt.labels, "app")
 			if got != tt.want {
 				t.Errorf("serviceLabel(%v) = %q, want %q", tt.labels, got, tt.want)
 			}
@@ -343,3 +343,109 @@ func TestMentionsService(t *testing.T) {
 		})
 	}
 }
+
+func TestCorrelateWithConfig_ServiceLabel(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	// every entry shares the same "app"; the real service is in "component"
+	var entries []recv.LogEntry
+	for i := 0; i < 10; i++ {
+		offset := time.Duration(i) * 30 * time.Second
+		entries = append(entries, recv.LogEntry{
+			Timestamp: base.Add(offset),
+			Labels:    map[string]string{"app": "shop", "component": "db"},
+			Message:   "error connection refused",
+		})
+		entries = append(entries, recv.LogEntry{
+			Timestamp: base.Add(offset + 10*time.Second),
+			Labels:    map[string]string{"app": "shop", "component": "api"},
+			Message:   "error upstream failed",
+		})
+	}
+	dir := setupCorrelateDir(t, entries)
+
+	byApp, err := CorrelateWithConfig(dir, CorrelateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byApp) != 0 {
+		t.Errorf("expected no correlations grouping by app, got %d", len(byApp))
+	}
+
+	byComponent, err := CorrelateWithConfig(dir, CorrelateConfig{ServiceLabel: "component"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byComponent) == 0 {
+		t.Fatal("expected correlation grouping by component")
+	}
+	c := byComponent[0]
+	hasPair := (c.Source == "db" && c.Target == "api") ||
+		(c.Source == "api" && c.Target == "db")
+	if !hasPair {
+		t.Errorf("expected correlation between db and api, got %s → %s", c.Source, c.Target)
+	}
+}
+
+func TestCorrelateWithConfig_MaxLag(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	// slow cascade: target fails 80s (8 windows) after source
+	var entries []recv.LogEntry
+	for i := 0; i < 10; i++ {
+		offset := time.Duration(i) * 200 * time.Second
+		entries = append(entries, recv.LogEntry{
+			Timestamp: base.Add(offset),
+			Labels:    map[string]string{"app": "queue"},
+			Message:   "error broker unavailable",
+		})
+		entries = append(entries, recv.LogEntry{
+			Timestamp: base.Add(offset + 80*time.Second),
+			Labels:    map[string]string{"app": "worker"},
+			Message:   "error job backlog exceeded",
+		})
+	}
+	dir := setupCorrelateDir(t, entries)
+
+	short, err := CorrelateWithConfig(dir, CorrelateConfig{Window: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range short {
+		if c.LagSeconds == 80 {
+			t.Errorf("default max lag should not reach 80s, got %+v", c)
+		}
+	}
+
+	long, err := CorrelateWithConfig(dir, CorrelateConfig{Window: 10 * time.Second, MaxLag: 2 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(long) == 0 {
+		t.Fatal("expected slow cascade to be detected with longer max lag")
+	}
+	if long[0].LagSeconds != 80 || long[0].Source != "queue" {
+		t.Errorf("got %+v, want queue → worker at 80s", long[0])
+	}
+}
+
+func TestCorrelateConfig_Defaults(t *testing.T) {
+	cfg := CorrelateConfig{}.withDefaults()
+	if cfg.Window != 10*time.Second || cfg.MaxLag != 50*time.Second {
+		t.Errorf("window/lag = %v/%v, want 10s/50s", cfg.Window, cfg.MaxLag)
+	}
+	if *cfg.MinConfidence != minConfidence || cfg.ServiceLabel != "app" {
+		t.Errorf("min confidence/label = %v/%q", *cfg.MinConfidence, cfg.ServiceLabel)
+	}
+
+	cfg = CorrelateConfig{Window: time.Minute}.withDefaults()
+	if cfg.MaxLag != 5*time.Minute {
+		t.Errorf("MaxLag = %v, want 5 windows", cfg.MaxLag)
+	}
+
+	zero := 0.0
+	cfg = CorrelateConfig{MinConfidence: &zero}.withDefaults()
+	if *cfg.MinConfidence != 0 {
+		t.Errorf("MinConfidence = %v, want an explicit 0 kept", *cfg.MinConfidence)
+	}
+}
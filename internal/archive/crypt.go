@@ -0,0 +1,269 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Encrypted data files carry an ".enc" suffix after their normal name
+// (e.g. "2024-01-15T10-32-00.jsonl.zst.enc") and use a chunked AES-256-GCM
+// format, so they can be decrypted as a stream:
+//
+//	header: "LTAPENC1" | chunk size (uint32 BE) | nonce prefix (8 bytes)
+//	chunk:  ciphertext length (uint32 BE) | ciphertext
+//
+// Chunk i uses nonce prefix || uint32 BE i and the header plus a final
+// flag byte as additional data, so reordered, truncated, or extended files
+// fail authentication.
+
+// EncryptedSuffix marks an at-rest encrypted data file.
+const EncryptedSuffix = ".enc"
+
+// KeyFileEnv names the environment variable read for the decryption key
+// file when none is set with SetKeyFile.
+const KeyFileEnv = "LOGTAP_KEY_FILE"
+
+const (
+	encMagic          = "LTAPENC1"
+	encHeaderLen      = len(encMagic) + 4 + 8
+	encChunkSize      = 64 << 10
+	encMaxChunkSize   = 16 << 20
+	encKeyLen         = 32
+	encNoncePrefixLen = 8
+)
+
+// ErrNoKey is returned when an encrypted file is read without a key.
+var ErrNoKey = errors.New("encrypted capture file: no key (use --key-file or " + KeyFileEnv + ")")
+
+var (
+	keyMu   sync.Mutex
+	keyFile string
+	keyData []byte
+)
+
+// SetKeyFile sets the file holding the key used to decrypt ".enc" data
+// files. The key is 32 bytes: raw, hex, or base64 encoded.
+func SetKeyFile(path string) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	keyFile, keyData = path, nil
+}
+
+// decryptionKey loads the key from the configured file or KeyFileEnv.
+func decryptionKey() ([]byte, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if keyData != nil {
+		return keyData, nil
+	}
+	path := keyFile
+	if path == "" {
+		path = os.Getenv(KeyFileEnv)
+	}
+	if path == "" {
+		return nil, ErrNoKey
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	key, err := ParseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	keyData = key
+	return key, nil
+}
+
+// ParseKey decodes a 32-byte key given raw, as hex, or as base64.
+func ParseKey(data []byte) ([]byte, error) {
+	if len(data) == encKeyLen {
+		return data, nil
+	}
+	s := strings.TrimSpace(string(data))
+	if k, err := hex.DecodeString(s); err == nil && len(k) == encKeyLen {
+		return k, nil
+	}
+	if k, err := base64.StdEncoding.DecodeString(s); err == nil && len(k) == encKeyLen {
+		return k, nil
+	}
+	return nil, fmt.Errorf("expected a %d-byte key (raw, hex, or base64)", encKeyLen)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encNonce(prefix []byte, i uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte(nil), prefix...), i)
+}
+
+func encAAD(header []byte, final bool) []byte {
+	aad := append([]byte(nil), header...)
+	if final {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	n      uint32
+	closed bool
+}
+
+// NewEncryptWriter returns a writer that encrypts to w with key. Close
+// writes the final chunk; it does not close w.
+func NewEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	header := make([]byte, 0, encHeaderLen)
+	header = append(header, encMagic...)
+	header = binary.BigEndian.AppendUint32(header, encChunkSize)
+	prefix := make([]byte, encNoncePrefixLen)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	header = append(header, prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, header: header}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("encrypt: write after close")
+	}
+	e.buf = append(e.buf, p...)
+	// hold back the last chunk so Close can seal it as final
+	for len(e.buf) > encChunkSize {
+		if err := e.seal(e.buf[:encChunkSize], false); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[encChunkSize:]
+	}
+	return len(p), nil
+}
+
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(e.buf, true)
+}
+
+func (e *encryptWriter) seal(plain []byte, final bool) error {
+	nonce := encNonce(e.header[len(encMagic)+4:], e.n)
+	e.n++
+	ct := e.aead.Seal(nil, nonce, plain, encAAD(e.header, final))
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(ct)))
+	if _, err := e.w.Write(append(out, ct...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+type decryptReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	max    int
+	buf    bytes.Reader
+	n      uint32
+	final  bool
+}
+
+// NewDecryptReader returns a reader that decrypts r with key.
+func NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	header := make([]byte, encHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("decrypt: read header: %w", err)
+	}
+	if string(header[:len(encMagic)]) != encMagic {
+		return nil, errors.New("decrypt: not an encrypted capture file")
+	}
+	size := int(binary.BigEndian.Uint32(header[len(encMagic):]))
+	if size <= 0 || size > encMaxChunkSize {
+		return nil, fmt.Errorf("decrypt: invalid chunk size %d", size)
+	}
+	return &decryptReader{r: r, aead: aead, header: header, max: size + aead.Overhead()}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for d.buf.Len() == 0 {
+		if d.final {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	return d.buf.Read(p)
+}
+
+func (d *decryptReader) next() error {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(d.r, lenBuf[:]); err != nil {
+		if err == io.EOF {
+			return errors.New("decrypt: file truncated (no final chunk)")
+		}
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	n := int(binary.BigEndian.Uint32(lenBuf[:]))
+	if n < d.aead.Overhead() || n > d.max {
+		return fmt.Errorf("decrypt: invalid chunk length %d", n)
+	}
+	ct := make([]byte, n)
+	if _, err := io.ReadFull(d.r, ct); err != nil {
+		return fmt.Errorf("decrypt: %w", unexpectedEOF(err))
+	}
+	nonce := encNonce(d.header[len(encMagic)+4:], d.n)
+	d.n++
+	plain, err := d.aead.Open(nil, nonce, ct, encAAD(d.header, false))
+	if err != nil {
+		plain, err = d.aead.Open(nil, nonce, ct, encAAD(d.header, true))
+		if err != nil {
+			return errors.New("decrypt: authentication failed (wrong key or corrupted file)")
+		}
+		d.final = true
+		var extra [1]byte
+		if n, _ := d.r.Read(extra[:]); n > 0 {
+			return errors.New("decrypt: data after final chunk")
+		}
+	}
+	d.buf.Reset(plain)
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func testKey() []byte {
+	return bytes.Repeat([]byte{0x42}, 32)
+}
+
+func encrypt(t *testing.T, key, plain []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, encChunkSize, encChunkSize + 1, 3*encChunkSize + 17} {
+		plain := bytes.Repeat([]byte("0123456789abcdef"), size/16+1)[:size]
+		r, err := NewDecryptReader(bytes.NewReader(encrypt(t, testKey(), plain)), testKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	plain := bytes.Repeat([]byte("x"), 2*encChunkSize+5)
+	ct := encrypt(t, testKey(), plain)
+
+	read := func(data, key []byte) error {
+		r, err := NewDecryptReader(bytes.NewReader(data), key)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadAll(r)
+		return err
+	}
+
+	wrongKey := bytes.Repeat([]byte{0x07}, 32)
+	if err := read(ct, wrongKey); err == nil {
+		t.Error("wrong key: expected error")
+	}
+
+	flipped := append([]byte(nil), ct...)
+	flipped[len(flipped)/2] ^= 1
+	if err := read(flipped, testKey()); err == nil {
+		t.Error("flipped bit: expected error")
+	}
+
+	// drop the final chunk: the file ends after a non-final chunk
+	chunk := 4 + encChunkSize + 16
+	if err := read(ct[:encHeaderLen+2*chunk], testKey()); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("truncated: err = %v", err)
+	}
+
+	if err := read(append(append([]byte(nil), ct...), 0), testKey()); err == nil {
+		t.Error("trailing data: expected error")
+	}
+
+	if err := read([]byte("not encrypted at all, just text"), testKey()); err == nil {
+		t.Error("plain file: expected error")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := testKey()
+	for _, in := range [][]byte{key, []byte(hex.EncodeToString(key) + "\n"), []byte("QkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkI=\n")} {
+		got, err := ParseKey(in)
+		if err != nil || !bytes.Equal(got, key) {
+			t.Errorf("ParseKey(%q) = %x, %v", in, got, err)
+		}
+	}
+	if _, err := ParseKey([]byte("short")); err == nil {
+		t.Error("expected error for short key")
+	}
+}
+
+func TestReaderEncryptedFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	writeMetadata(t, dir, base, base.Add(time.Minute), 3)
+
+	var plain bytes.Buffer
+	for i, msg := range []string{"one", "two"} {
+		plain.WriteString(`{"ts":"` + base.Add(time.Duration(i)*time.Second).Format(time.RFC3339) + `","labels":{"app":"api"},"msg":"` + msg + `"}` + "\n")
+	}
+	name := "2024-01-15T100000-000.jsonl" + EncryptedSuffix
+	if err := os.WriteFile(filepath.Join(dir, name), encrypt(t, testKey(), plain.Bytes()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeIndex(t, dir, []rotate.IndexEntry{{File: name, From: base, To: base.Add(time.Second), Lines: 2}})
+	// an encrypted orphan is discovered too
+	orphan := "2024-01-15T100100-000.jsonl" + EncryptedSuffix
+	line := `{"ts":"2024-01-15T10:01:00Z","labels":{"app":"api"},"msg":"three"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, orphan), encrypt(t, testKey(), []byte(line)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetKeyFile("")
+	t.Setenv(KeyFileEnv, "")
+	if _, err := reader.Scan(nil, func(recv.LogEntry) bool { return true }); err == nil || !strings.Contains(err.Error(), "no key") {
+		t.Fatalf("scan without key: err = %v", err)
+	}
+
+	keyPath := filepath.Join(t.TempDir(), "capture.key")
+	if err := os.WriteFile(keyPath, []byte(hex.EncodeToString(testKey())), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	SetKeyFile(keyPath)
+	defer SetKeyFile("")
+
+	var msgs []string
+	if _, err := reader.Scan(nil, func(e recv.LogEntry) bool {
+		msgs = append(msgs, e.Message)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(msgs, ",") != "one,two,three" {
+		t.Errorf("messages = %v", msgs)
+	}
+}
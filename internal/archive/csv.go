@@ -2,6 +2,8 @@ package archive
 
 import (
 	"encoding/csv"
+	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
@@ -10,32 +12,79 @@ import (
 	"github.com/ppiankov/logtap/internal/recv"
 )
 
+// CSV columns other than label keys.
+const (
+	CSVColumnTS     = "ts"
+	CSVColumnMsg    = "msg"
+	CSVColumnLabels = "labels" // every label as key=val;key=val
+)
+
+// CSVLabelPrefix selects a label whose key is also a column name, e.g.
+// label:ts.
+const CSVLabelPrefix = "label:"
+
+// utf8BOM lets Excel detect UTF-8 when opening the file.
+const utf8BOM = "\ufeff"
+
 type csvWriter struct {
-	file *os.File
-	w    *csv.Writer
+	file      *os.File
+	w         *csv.Writer
+	columns   []string
+	excelSafe bool
+	row       []string
 }
 
-func newCSVWriter(path string) (*csvWriter, error) {
-	f, err := os.Create(path)
+func newCSVWriter(path string, offset int64, columns []string, excelSafe bool) (*csvWriter, error) {
+	f, err := createExportFile(path, offset)
 	if err != nil {
 		return nil, err
 	}
 
 	w := csv.NewWriter(f)
-	if err := w.Write([]string{"ts", "labels", "msg"}); err != nil {
-		_ = f.Close()
-		return nil, err
+	w.UseCRLF = true // RFC 4180
+	if offset == 0 {
+		if excelSafe {
+			if _, err := f.WriteString(utf8BOM); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+		}
+		if err := w.Write(columns); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
 	}
 
-	return &csvWriter{file: f, w: w}, nil
+	return &csvWriter{file: f, w: w, columns: columns, excelSafe: excelSafe, row: make([]string, len(columns))}, nil
 }
 
 func (w *csvWriter) Write(e recv.LogEntry) error {
-	return w.w.Write([]string{
-		e.Timestamp.Format(time.RFC3339Nano),
-		flattenLabels(e.Labels),
-		e.Message,
-	})
+	for i, col := range w.columns {
+		var v string
+		switch col {
+		case CSVColumnTS:
+			v = e.Timestamp.Format(time.RFC3339Nano)
+		case CSVColumnMsg:
+			v = e.Message
+		case CSVColumnLabels:
+			v = flattenLabels(e.Labels)
+		default:
+			v = e.Labels[strings.TrimPrefix(col, CSVLabelPrefix)]
+		}
+		if w.excelSafe {
+			v = escapeFormula(v)
+		}
+		w.row[i] = v
+	}
+	return w.w.Write(w.row)
+}
+
+func (w *csvWriter) Flush() (int64, error) {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return 0, err
+	}
+	return w.file.Seek(0, io.SeekCurrent)
 }
 
 func (w *csvWriter) Close() error {
@@ -47,6 +96,64 @@ func (w *csvWriter) Close() error {
 	return w.file.Close()
 }
 
+// csvColumns returns the CSV columns for selected, or by default ts, one
+// column per label key recorded in the index of files, and msg. Files
+// whose labels the index does not record (orphan files, older indexes) add
+// the flattened labels column before msg, so no label is lost.
+func csvColumns(files []FileInfo, selected []string) ([]string, error) {
+	if len(selected) > 0 {
+		seen := make(map[string]bool, len(selected))
+		for _, col := range selected {
+			if col == "" || col == CSVLabelPrefix {
+				return nil, fmt.Errorf("empty column name")
+			}
+			if seen[col] {
+				return nil, fmt.Errorf("duplicate column %q", col)
+			}
+			seen[col] = true
+		}
+		return selected, nil
+	}
+
+	keys := make(map[string]bool)
+	unindexed := false
+	for _, f := range files {
+		if f.Index == nil || len(f.Index.Labels) == 0 {
+			unindexed = true
+			continue
+		}
+		for k := range f.Index.Labels {
+			keys[k] = true
+		}
+	}
+	columns := make([]string, 0, len(keys)+3)
+	for k := range keys {
+		if k == CSVColumnTS || k == CSVColumnMsg || k == CSVColumnLabels {
+			k = CSVLabelPrefix + k
+		}
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+	columns = append([]string{CSVColumnTS}, columns...)
+	if unindexed || len(keys) == 0 {
+		columns = append(columns, CSVColumnLabels)
+	}
+	return append(columns, CSVColumnMsg), nil
+}
+
+// escapeFormula prefixes a cell that spreadsheets would run as a formula
+// with a single quote, so it is shown as text.
+func escapeFormula(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + s
+	}
+	return s
+}
+
 // flattenLabels produces a deterministic "key=val;key=val" string sorted by key.
 func flattenLabels(labels map[string]string) string {
 	if len(labels) == 0 {
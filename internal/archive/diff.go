@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"regexp"
 	"sort"
 	"time"
 
@@ -20,6 +22,9 @@ type DiffResult struct {
 	ErrorsOnlyA []ErrorSummary `json:"errors_only_a,omitempty"`
 	ErrorsOnlyB []ErrorSummary `json:"errors_only_b,omitempty"`
 	RateCompare []RateBucket   `json:"rate_compare,omitempty"`
+	// Churn lists the signatures whose share of total volume moved the
+	// most, largest movement first.
+	Churn []SignatureChurn `json:"churn,omitempty"`
 }
 
 // DiffCapture summarizes one side of the comparison.
@@ -39,6 +44,25 @@ type ErrorSummary struct {
 	Count   int64  `json:"count"`
 }
 
+// SignatureChurn is the volume of one message signature in both captures.
+// Shares are percentages of each capture's lines; ShareDelta is B minus A in
+// percentage points.
+type SignatureChurn struct {
+	Pattern    string  `json:"pattern"`
+	CountA     int64   `json:"count_a"`
+	CountB     int64   `json:"count_b"`
+	ShareA     float64 `json:"share_a"`
+	ShareB     float64 `json:"share_b"`
+	ShareDelta float64 `json:"share_delta"`
+}
+
+const (
+	maxChurnSignatures = 10000 // distinct signatures tracked per capture
+	maxChurnResults    = 10
+	minChurnDelta      = 0.1 // percentage points
+	otherSignature     = "(other signatures)"
+)
+
 // RateBucket compares log rates at a given minute.
 type RateBucket struct {
 	Minute time.Time `json:"minute"`
@@ -48,11 +72,11 @@ type RateBucket struct {
 
 // Diff compares two capture directories.
 func Diff(srcA, srcB string) (*DiffResult, error) {
-	capA, err := summarizeCapture(srcA)
+	capA, err := summarizeCapture(srcA, nil)
 	if err != nil {
 		return nil, fmt.Errorf("capture A: %w", err)
 	}
-	capB, err := summarizeCapture(srcB)
+	capB, err := summarizeCapture(srcB, nil)
 	if err != nil {
 		return nil, fmt.Errorf("capture B: %w", err)
 	}
@@ -96,9 +120,49 @@ func Diff(srcA, srcB string) (*DiffResult, error) {
 	// Rate comparison (per-minute buckets, aligned to earlier start)
 	result.RateCompare = buildRateComparison(capA.rates, capB.rates)
 
+	result.Churn = buildChurn(capA, capB)
+
 	return result, nil
 }
 
+// buildChurn ranks signatures by how far their share of volume moved. A
+// retry storm shows up here as a known message taking over the capture,
+// even though no new error pattern appears.
+func buildChurn(capA, capB *captureData) []SignatureChurn {
+	if capA.scanned == 0 || capB.scanned == 0 {
+		return nil
+	}
+	patterns := make(map[string]bool, len(capA.signatures)+len(capB.signatures))
+	for p := range capA.signatures {
+		patterns[p] = true
+	}
+	for p := range capB.signatures {
+		patterns[p] = true
+	}
+
+	var churn []SignatureChurn
+	for p := range patterns {
+		c := SignatureChurn{Pattern: p, CountA: capA.signatures[p], CountB: capB.signatures[p]}
+		c.ShareA = float64(c.CountA) * 100 / float64(capA.scanned)
+		c.ShareB = float64(c.CountB) * 100 / float64(capB.scanned)
+		c.ShareDelta = c.ShareB - c.ShareA
+		if math.Abs(c.ShareDelta) >= minChurnDelta {
+			churn = append(churn, c)
+		}
+	}
+	sort.Slice(churn, func(i, j int) bool {
+		di, dj := math.Abs(churn[i].ShareDelta), math.Abs(churn[j].ShareDelta)
+		if di != dj {
+			return di > dj
+		}
+		return churn[i].Pattern < churn[j].Pattern
+	})
+	if len(churn) > maxChurnResults {
+		churn = churn[:maxChurnResults]
+	}
+	return churn
+}
+
 // WriteJSON writes the diff result as JSON.
 func (d *DiffResult) WriteJSON(w io.Writer) error {
 	enc := json.NewEncoder(w)
@@ -135,6 +199,14 @@ func (d *DiffResult) WriteText(w io.Writer) {
 		}
 	}
 
+	if len(d.Churn) > 0 {
+		tw.printf("\nVolume churn (share of lines, largest movers):\n")
+		for _, c := range d.Churn {
+			tw.printf("  %+6.1fpp  %5.1f%% -> %5.1f%%  [%d -> %d] %s\n",
+				c.ShareDelta, c.ShareA, c.ShareB, c.CountA, c.CountB, c.Pattern)
+		}
+	}
+
 	if len(d.RateCompare) > 0 {
 		tw.printf("\nRate comparison (lines/min):\n")
 		tw.printf("  %-20s %8s %8s\n", "Minute", "A", "B")
@@ -150,9 +222,14 @@ type captureData struct {
 	allErrors  map[string]int64    // full error counts (not truncated)
 	errorLines int64               // total lines matching IsError
 	rates      map[time.Time]int64 // per-minute counts
+	signatures map[string]int64    // lines per normalized message
+	requests   *RequestRate        // nil without harness metrics or pattern matches
+	scanned    int64
 }
 
-func summarizeCapture(dir string) (*captureData, error) {
+// summarizeCapture scans dir once; requestPattern is passed to the request
+// counter as for CountRequests.
+func summarizeCapture(dir string, requestPattern *regexp.Regexp) (*captureData, error) {
 	r, err := NewReader(dir)
 	if err != nil {
 		return nil, err
@@ -183,16 +260,27 @@ func summarizeCapture(dir string) (*captureData, error) {
 	// Scan for errors and per-minute rates
 	errorCounts := make(map[string]int64)
 	rates := make(map[time.Time]int64)
-	var errorLines int64
+	signatures := make(map[string]int64)
+	var errorLines, scanned int64
+	reqs := newRequestCounter(requestPattern)
 
 	_, err = r.Scan(nil, func(e recv.LogEntry) bool {
+		n := e.Count() // lines collapsed by recv --dedup-window count in full
+		scanned += n
+		reqs.add(e)
 		minute := e.Timestamp.Truncate(time.Minute)
-		rates[minute]++
+		rates[minute] += n
+
+		normalized := NormalizeMessage(e.Message)
+		if _, ok := signatures[normalized]; ok || len(signatures) < maxChurnSignatures {
+			signatures[normalized] += n
+		} else {
+			signatures[otherSignature] += n
+		}
 
 		if IsError(e.Message) {
-			errorLines++
-			normalized := NormalizeMessage(e.Message)
-			errorCounts[normalized]++
+			errorLines += n
+			errorCounts[normalized] += n
 		}
 		return true
 	})
@@ -224,6 +312,9 @@ func summarizeCapture(dir string) (*captureData, error) {
 		allErrors:  errorCounts,
 		errorLines: errorLines,
 		rates:      rates,
+		signatures: signatures,
+		requests:   reqs.rate(),
+		scanned:    scanned,
 	}, nil
 }
 
@@ -267,6 +358,19 @@ type BaselineDiffResult struct {
 	NewLabels        []string     `json:"new_labels,omitempty"`
 	Verdict          string       `json:"verdict"`
 	Confidence       float64      `json:"confidence"`
+
+	// set when both captures have a request rate; the verdict then judges
+	// errors per 1k requests instead of the share of error lines, and
+	// volume and pattern growth relative to the load
+	BaselineErrorsPer1k float64 `json:"baseline_errors_per_1k_requests,omitempty"`
+	CurrentErrorsPer1k  float64 `json:"current_errors_per_1k_requests,omitempty"`
+	ErrorsPer1kChange   string  `json:"errors_per_1k_requests_change,omitempty"`
+	RequestChange       string  `json:"request_change,omitempty"`
+}
+
+// BaselineDiffConfig controls BaselineDiffWithConfig.
+type BaselineDiffConfig struct {
+	RequestPattern *regexp.Regexp // lines counted as requests when no harness metrics exist (nil = harness only)
 }
 
 // ErrorDelta describes an error pattern that is new or significantly worse in the current capture.
@@ -279,11 +383,19 @@ type ErrorDelta struct {
 // BaselineDiff compares a current capture against a baseline, producing a verdict.
 // baselineDir is the known-good reference; currentDir is the capture under evaluation.
 func BaselineDiff(baselineDir, currentDir string) (*BaselineDiffResult, error) {
-	baseCap, err := summarizeCapture(baselineDir)
+	return BaselineDiffWithConfig(baselineDir, currentDir, BaselineDiffConfig{})
+}
+
+// BaselineDiffWithConfig is BaselineDiff with request rate settings. When
+// both captures have a request rate, the verdict compares errors per 1000
+// requests, lines per request and pattern growth beyond the load change, so
+// more errors under proportionally more load stay stable.
+func BaselineDiffWithConfig(baselineDir, currentDir string, cfg BaselineDiffConfig) (*BaselineDiffResult, error) {
+	baseCap, err := summarizeCapture(baselineDir, cfg.RequestPattern)
 	if err != nil {
 		return nil, fmt.Errorf("baseline: %w", err)
 	}
-	curCap, err := summarizeCapture(currentDir)
+	curCap, err := summarizeCapture(currentDir, cfg.RequestPattern)
 	if err != nil {
 		return nil, fmt.Errorf("current: %w", err)
 	}
@@ -303,6 +415,20 @@ func BaselineDiff(baselineDir, currentDir string) (*BaselineDiffResult, error) {
 	volumeChangePct := percentChange(float64(baseCap.summary.Lines), float64(curCap.summary.Lines))
 	result.VolumeChange = formatPercentChange(volumeChangePct)
 
+	// With load known on both sides, judge errors, volume and pattern
+	// growth per request
+	loadRatio := 1.0
+	if baseCap.requests != nil && curCap.requests != nil {
+		baseReqs, curReqs := float64(baseCap.requests.Total), float64(curCap.requests.Total)
+		loadRatio = curReqs / baseReqs
+		result.BaselineErrorsPer1k = ErrorsPer1k(baseCap.errorLines, baseCap.requests.Total)
+		result.CurrentErrorsPer1k = ErrorsPer1k(curCap.errorLines, curCap.requests.Total)
+		errorRateChangePct = percentChange(result.BaselineErrorsPer1k, result.CurrentErrorsPer1k)
+		result.ErrorsPer1kChange = formatPercentChange(errorRateChangePct)
+		result.RequestChange = formatPercentChange(percentChange(baseReqs, curReqs))
+		volumeChangePct = percentChange(float64(baseCap.summary.Lines)/baseReqs, float64(curCap.summary.Lines)/curReqs)
+	}
+
 	// New or significantly worse error patterns
 	for pat, count := range curCap.allErrors {
 		baseCount := baseCap.allErrors[pat]
@@ -313,7 +439,7 @@ func BaselineDiff(baselineDir, currentDir string) (*BaselineDiffResult, error) {
 				Count:         count,
 				BaselineCount: 0,
 			})
-		} else if count > baseCount*2 {
+		} else if float64(count) > float64(baseCount)*2*loadRatio {
 			// more than 2x increase
 			result.NewErrorPatterns = append(result.NewErrorPatterns, ErrorDelta{
 				Pattern:       pat,
@@ -447,6 +573,10 @@ func (b *BaselineDiffResult) WriteText(w io.Writer) {
 	tw.printf("Current:  %s\n", b.Current)
 	tw.printf("\nVerdict:    %s (confidence %.0f%%)\n", b.Verdict, b.Confidence*100)
 	tw.printf("Error rate: %s\n", b.ErrorRateChange)
+	if b.ErrorsPer1kChange != "" {
+		tw.printf("Errors/1k requests: %s (%.2f -> %.2f)\n", b.ErrorsPer1kChange, b.BaselineErrorsPer1k, b.CurrentErrorsPer1k)
+		tw.printf("Requests:   %s\n", b.RequestChange)
+	}
 	tw.printf("Volume:     %s\n", b.VolumeChange)
 
 	if len(b.NewErrorPatterns) > 0 {
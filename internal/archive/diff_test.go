@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -103,6 +104,43 @@ func TestDiffErrorPatterns(t *testing.T) {
 	}
 }
 
+func TestDiffRepeatCount(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	stop := base.Add(time.Minute)
+
+	dirA := t.TempDir()
+	dirB := t.TempDir()
+
+	// recv --dedup-window collapsed nine identical errors into one entry
+	entriesA := []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "web"}, Message: "ERROR: connection refused", RepeatCount: 9},
+		{Timestamp: base.Add(time.Second), Labels: map[string]string{"app": "web"}, Message: "ok line"},
+	}
+	entriesB := []recv.LogEntry{
+		{Timestamp: base.Add(time.Second), Labels: map[string]string{"app": "web"}, Message: "ok line"},
+	}
+
+	setupCapture(t, dirA, base, stop, entriesA, "web")
+	setupCapture(t, dirB, base, stop, entriesB, "web")
+
+	result, err := Diff(dirA, dirB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.ErrorsOnlyA) != 1 || result.ErrorsOnlyA[0].Count != 9 {
+		t.Errorf("ErrorsOnlyA = %+v, want one pattern with count 9", result.ErrorsOnlyA)
+	}
+	if len(result.RateCompare) != 1 || result.RateCompare[0].RateA != 10 || result.RateCompare[0].RateB != 1 {
+		t.Errorf("RateCompare = %+v, want 10 lines in A and 1 in B", result.RateCompare)
+	}
+	for _, c := range result.Churn {
+		if c.CountA == 9 && c.ShareA != 90 {
+			t.Errorf("error share in A = %v, want 90", c.ShareA)
+		}
+	}
+}
+
 func TestDiffRateComparison(t *testing.T) {
 	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
 	stop := base.Add(3 * time.Minute)
@@ -142,6 +180,103 @@ func TestDiffRateComparison(t *testing.T) {
 	}
 }
 
+func TestDiffChurn(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	stop := base.Add(time.Minute)
+
+	dirA := t.TempDir()
+	dirB := t.TempDir()
+
+	line := func(i int, msg string) recv.LogEntry {
+		return recv.LogEntry{
+			Timestamp: base.Add(time.Duration(i) * 100 * time.Millisecond),
+			Labels:    map[string]string{"app": "web"},
+			Message:   msg,
+		}
+	}
+	// same patterns in both, but B is dominated by retries
+	var entriesA, entriesB []recv.LogEntry
+	for i := 0; i < 90; i++ {
+		entriesA = append(entriesA, line(i, fmt.Sprintf("served request %d", 1000+i)))
+	}
+	for i := 0; i < 10; i++ {
+		entriesA = append(entriesA, line(90+i, fmt.Sprintf("retrying upstream call %d", 1000+i)))
+	}
+	for i := 0; i < 30; i++ {
+		entriesB = append(entriesB, line(i, fmt.Sprintf("served request %d", 1000+i)))
+	}
+	for i := 0; i < 70; i++ {
+		entriesB = append(entriesB, line(30+i, fmt.Sprintf("retrying upstream call %d", 1000+i)))
+	}
+
+	setupCapture(t, dirA, base, stop, entriesA, "web")
+	setupCapture(t, dirB, base, stop, entriesB, "web")
+
+	result, err := Diff(dirA, dirB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ErrorsOnlyA)+len(result.ErrorsOnlyB) != 0 {
+		t.Errorf("expected no new or missing errors, got %+v %+v", result.ErrorsOnlyA, result.ErrorsOnlyB)
+	}
+	if len(result.Churn) != 2 {
+		t.Fatalf("churn = %+v, want 2 entries", result.Churn)
+	}
+	for _, c := range result.Churn {
+		if c.ShareDelta != 60 && c.ShareDelta != -60 {
+			t.Errorf("%q delta = %v, want ±60", c.Pattern, c.ShareDelta)
+		}
+	}
+	retry := result.Churn[0]
+	if retry.ShareDelta < 0 {
+		retry = result.Churn[1]
+	}
+	if retry.CountA != 10 || retry.CountB != 70 || retry.ShareA != 10 || retry.ShareB != 70 {
+		t.Errorf("retry churn = %+v", retry)
+	}
+
+	var buf bytes.Buffer
+	result.WriteText(&buf)
+	if !strings.Contains(buf.String(), "Volume churn") || !strings.Contains(buf.String(), "+60.0pp") {
+		t.Errorf("text output missing churn:\n%s", buf.String())
+	}
+}
+
+func TestDiffChurnUnchanged(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	stop := base.Add(time.Minute)
+
+	dirA := t.TempDir()
+	dirB := t.TempDir()
+
+	// same mix of patterns at different volumes
+	mix := func(n int) []recv.LogEntry {
+		entries := make([]recv.LogEntry, n)
+		for i := range entries {
+			msg := fmt.Sprintf("served request %d", 1000+i)
+			if i%2 == 1 {
+				msg = fmt.Sprintf("cache miss key=%d", 1000+i)
+			}
+			entries[i] = recv.LogEntry{
+				Timestamp: base.Add(time.Duration(i) * time.Second),
+				Labels:    map[string]string{"app": "web"},
+				Message:   msg,
+			}
+		}
+		return entries
+	}
+	setupCapture(t, dirA, base, stop, mix(20), "web")
+	setupCapture(t, dirB, base, stop, mix(40), "web")
+
+	result, err := Diff(dirA, dirB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Churn) != 0 {
+		t.Errorf("expected no churn for same mix at different volume, got %+v", result.Churn)
+	}
+}
+
 func TestDiffWriteJSON(t *testing.T) {
 	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
 	stop := base.Add(time.Minute)
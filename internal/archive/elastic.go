@@ -0,0 +1,68 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// Elasticsearch export defaults.
+const (
+	DefaultElasticBatchLines = 1000
+	DefaultElasticBatchBytes = 5 << 20 // the bulk size Elasticsearch recommends starting from
+)
+
+// ElasticEntry is an entry with its document ID.
+type ElasticEntry struct {
+	ID string // stable across runs of the same export
+	recv.LogEntry
+}
+
+// ElasticBulkFunc indexes entries with one bulk request.
+type ElasticBulkFunc func(ctx context.Context, entries []ElasticEntry) error
+
+// ElasticExportOptions holds settings for ExportElastic.
+type ElasticExportOptions struct {
+	Target     string   // cluster address, part of the checkpoint fingerprint
+	Index      string   // index name or pattern, part of the checkpoint fingerprint
+	Checkpoint string   // checkpoint path; empty disables checkpointing
+	Resume     bool     // skip files completed by an earlier run
+	BatchLines int      // max documents per bulk request (0 = DefaultElasticBatchLines)
+	BatchBytes int      // max message bytes per bulk request (0 = DefaultElasticBatchBytes)
+	Profile    *Profile // per-file read profile (nil = off)
+}
+
+// ExportElastic indexes the filtered entries of src into Elasticsearch or
+// OpenSearch through bulk. Each entry gets an ID derived from its data file,
+// position and content, so a resumed export overwrites the documents of
+// the file that was in flight instead of duplicating them. Returns the
+// number of entries sent.
+func ExportElastic(ctx context.Context, src string, filter *Filter, bulk ElasticBulkFunc, progress func(ExportProgress), opts ElasticExportOptions) (int64, error) {
+	if opts.BatchLines <= 0 {
+		opts.BatchLines = DefaultElasticBatchLines
+	}
+	if opts.BatchBytes <= 0 {
+		opts.BatchBytes = DefaultElasticBatchBytes
+	}
+
+	params := fmt.Sprintf("src=%s elastic=%s index=%q %s", src, opts.Target, opts.Index, filterFingerprint(filter))
+	return exportBatches(ctx, src, filter, progress, batchExport{
+		dest:       "elasticsearch",
+		params:     params,
+		checkpoint: opts.Checkpoint,
+		resume:     opts.Resume,
+		batchLines: opts.BatchLines,
+		batchBytes: opts.BatchBytes,
+		profile:    opts.Profile,
+		ids:        true,
+		group:      func(recv.LogEntry) (string, map[string]string) { return "", nil },
+		push: func(ctx context.Context, b *exportBatch) error {
+			entries := make([]ElasticEntry, len(b.entries))
+			for i, e := range b.entries {
+				entries[i] = ElasticEntry{ID: b.ids[i], LogEntry: e}
+			}
+			return bulk(ctx, entries)
+		},
+	})
+}
@@ -2,6 +2,8 @@ package archive
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/ppiankov/logtap/internal/recv"
 )
@@ -28,20 +30,94 @@ type ExportWriter interface {
 }
 
 // Export reads filtered entries from src and writes to dst in the given format.
+// For csv and jsonl a checkpoint is kept at dst+".checkpoint" while running so
+// an interrupted export can be continued with ExportResume.
 func Export(src, dst string, format ExportFormat, filter *Filter, progress func(ExportProgress)) error {
+	return ExportWithOptions(src, dst, format, filter, progress, ExportOptions{})
+}
+
+// ExportResume continues an interrupted Export from its checkpoint, or starts
+// a new export if there is none. Not supported for parquet.
+func ExportResume(src, dst string, format ExportFormat, filter *Filter, progress func(ExportProgress)) error {
+	return ExportWithOptions(src, dst, format, filter, progress, ExportOptions{Resume: true})
+}
+
+// ExportOptions holds optional export settings.
+type ExportOptions struct {
+	Resume  bool     // continue from the checkpoint (csv and jsonl only)
+	Profile *Profile // per-file read profile (nil = off)
+
+	// ExpandRepeats writes entries collapsed by recv --dedup-window once per
+	// line they stand for. CSV and parquet have no repeat_count column, so
+	// they are always expanded; JSONL keeps repeat_count unless set.
+	ExpandRepeats bool
+
+	// Columns selects the CSV columns: ts, msg, labels (all labels as
+	// key=val;key=val) and label keys, label:<key> for a key named like a
+	// column. Empty means ts, one column per label key and msg.
+	Columns []string
+	// ExcelSafe prefixes CSV cells starting with =, +, -, @, tab or CR
+	// with a single quote and starts the file with a UTF-8 byte order mark.
+	ExcelSafe bool
+}
+
+// ExportWithOptions is Export with resume and profiling options.
+func ExportWithOptions(src, dst string, format ExportFormat, filter *Filter, progress func(ExportProgress), opts ExportOptions) error {
+	if opts.Resume && format == FormatParquet {
+		return fmt.Errorf("resume is not supported for %s export", format)
+	}
+	if (len(opts.Columns) > 0 || opts.ExcelSafe) && format != FormatCSV {
+		return fmt.Errorf("columns and excel-safe are only supported for csv export")
+	}
+	resume := opts.Resume
+
 	reader, err := NewReader(src)
 	if err != nil {
 		return fmt.Errorf("open source: %w", err)
 	}
+	reader.SetProfile(opts.Profile)
+	reader.SetExpandRepeats(opts.ExpandRepeats || format != FormatJSONL)
 	totalLines := reader.TotalLines()
 
-	writer, err := newExportWriter(dst, format)
+	// parquet writes its footer on close, so partial output cannot be appended to
+	checkpointing := format != FormatParquet
+	cpPath := dst + ".checkpoint"
+	params := exportParams(src, format, filter)
+	if opts.ExpandRepeats && format == FormatJSONL {
+		params += " expand-repeats"
+	}
+	if len(opts.Columns) > 0 {
+		params += fmt.Sprintf(" columns=%q", opts.Columns)
+	}
+	if opts.ExcelSafe {
+		params += " excel-safe"
+	}
+	var columns []string
+	if format == FormatCSV {
+		// the default columns depend on the source only
+		if columns, err = csvColumns(reader.Files(), opts.Columns); err != nil {
+			return err
+		}
+	}
+
+	var cp *Checkpoint
+	if resume {
+		cp, err = loadResumeCheckpoint(cpPath, "export", params)
+		if err != nil {
+			return err
+		}
+	}
+	if cp == nil {
+		cp = &Checkpoint{Op: "export", Params: params}
+	}
+
+	writer, err := newExportWriter(dst, format, cp.Offset, columns, opts.ExcelSafe)
 	if err != nil {
 		return fmt.Errorf("create writer: %w", err)
 	}
 
-	var written int64
-	_, err = reader.Scan(filter, func(e recv.LogEntry) bool {
+	written := cp.Written
+	fn := func(e recv.LogEntry) bool {
 		if werr := writer.Write(e); werr != nil {
 			return true // skip write errors, continue scanning
 		}
@@ -54,15 +130,42 @@ func Export(src, dst string, format ExportFormat, filter *Filter, progress func(
 			})
 		}
 		return true
-	})
-	if err != nil {
-		_ = writer.Close()
-		return fmt.Errorf("scan source: %w", err)
+	}
+
+	for _, f := range reader.Files() {
+		if filter.skip(f) {
+			continue
+		}
+		if cp.Done(f.Name) {
+			continue
+		}
+		if _, _, err := reader.scanFile(f, filter, fn); err != nil {
+			_ = writer.Close()
+			return fmt.Errorf("scan source: scan %s: %w", f.Name, err)
+		}
+		if !checkpointing {
+			continue
+		}
+		offset, err := writer.(flushWriter).Flush()
+		if err != nil {
+			_ = writer.Close()
+			return fmt.Errorf("flush output: %w", err)
+		}
+		cp.Completed = append(cp.Completed, f.Name)
+		cp.Written = written
+		cp.Offset = offset
+		if err := WriteCheckpoint(cpPath, cp); err != nil {
+			_ = writer.Close()
+			return err
+		}
 	}
 
 	if err := writer.Close(); err != nil {
 		return fmt.Errorf("close writer: %w", err)
 	}
+	if checkpointing {
+		_ = os.Remove(cpPath)
+	}
 
 	// final progress
 	if progress != nil {
@@ -75,14 +178,45 @@ func Export(src, dst string, format ExportFormat, filter *Filter, progress func(
 	return nil
 }
 
-func newExportWriter(path string, format ExportFormat) (ExportWriter, error) {
+func exportParams(src string, format ExportFormat, filter *Filter) string {
+	return fmt.Sprintf("src=%s format=%s %s", src, format, filterFingerprint(filter))
+}
+
+// flushWriter is implemented by export writers whose output can be
+// checkpointed. Flush returns the output size after flushing.
+type flushWriter interface {
+	Flush() (int64, error)
+}
+
+// createExportFile creates path, or reopens it truncated to offset when
+// resuming so output past the last checkpoint is discarded.
+func createExportFile(path string, offset int64) (*os.File, error) {
+	if offset == 0 {
+		return os.Create(path)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(offset); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func newExportWriter(path string, format ExportFormat, offset int64, columns []string, excelSafe bool) (ExportWriter, error) {
 	switch format {
 	case FormatParquet:
 		return newParquetWriter(path)
 	case FormatCSV:
-		return newCSVWriter(path)
+		return newCSVWriter(path, offset, columns, excelSafe)
 	case FormatJSONL:
-		return newJSONLWriter(path)
+		return newJSONLWriter(path, offset)
 	default:
 		return nil, fmt.Errorf("unsupported format: %q", format)
 	}
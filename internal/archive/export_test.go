@@ -2,8 +2,10 @@ package archive
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -12,6 +14,7 @@ import (
 	"time"
 
 	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
 
 	"github.com/ppiankov/logtap/internal/recv"
 	"github.com/ppiankov/logtap/internal/rotate"
@@ -81,6 +84,58 @@ func TestExportParquet(t *testing.T) {
 	}
 }
 
+func TestExportParquetSchema(t *testing.T) {
+	src, base := setupExportSource(t)
+	out := filepath.Join(t.TempDir(), "out.parquet")
+	if err := Export(src, out, FormatParquet, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	stat, _ := f.Stat()
+	pf, err := parquet.OpenFile(f, stat.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// microsecond timestamps load in Spark and Athena; nanosecond ones do not
+	ts, ok := pf.Schema().Lookup("ts")
+	if !ok {
+		t.Fatal("no ts column")
+	}
+	if lt := ts.Node.Type().LogicalType(); lt == nil || lt.Timestamp == nil || lt.Timestamp.Unit.Micros == nil {
+		t.Errorf("ts logical type = %v, want timestamp(microsecond)", lt)
+	}
+	for _, path := range [][]string{{"ts_ns"}, {"msg"}, {"labels", "key_value", "key"}} {
+		if _, ok := pf.Schema().Lookup(path...); !ok {
+			t.Errorf("no %v column", path)
+		}
+	}
+	for _, rg := range pf.Metadata().RowGroups {
+		for _, cc := range rg.Columns {
+			if cc.MetaData.Codec != format.Zstd {
+				t.Errorf("column %v compressed with %v, want zstd", cc.MetaData.PathInSchema, cc.MetaData.Codec)
+			}
+		}
+	}
+
+	rows, err := parquet.Read[parquetEntry](f, stat.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("rows = %d, want 5", len(rows))
+	}
+	want := base.Add(time.Minute)
+	if rows[1].Ts != want.UnixMicro() || rows[1].TsNs != want.UnixNano() || rows[1].Labels["app"] != "api" || rows[1].Msg != "timeout error" {
+		t.Errorf("row 1 = %+v", rows[1])
+	}
+}
+
 func TestExportCSV(t *testing.T) {
 	src, _ := setupExportSource(t)
 	out := filepath.Join(t.TempDir(), "out.csv")
@@ -106,8 +161,82 @@ func TestExportCSV(t *testing.T) {
 	if len(records) != 6 {
 		t.Fatalf("CSV records = %d, want 6 (1 header + 5 data)", len(records))
 	}
-	if records[0][0] != "ts" || records[0][1] != "labels" || records[0][2] != "msg" {
-		t.Errorf("CSV header = %v, want [ts labels msg]", records[0])
+	// one column per label key in the index
+	if fmt.Sprint(records[0]) != "[ts app msg]" {
+		t.Errorf("CSV header = %v, want [ts app msg]", records[0])
+	}
+	if records[2][1] != "api" || records[2][2] != "timeout error" {
+		t.Errorf("CSV row = %v", records[2])
+	}
+}
+
+func TestExportCSVColumns(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	entries := []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"pod": "api-0", "msg": "x"}, Message: "=HYPERLINK(\"http://evil\")"},
+		{Timestamp: base.Add(time.Second), Labels: map[string]string{"pod": "api-1"}, Message: "line \"two\",\nwrapped"},
+	}
+	writeMetadata(t, dir, base, base.Add(time.Second), 2)
+	writeDataFile(t, dir, "data.jsonl", entries)
+	writeIndex(t, dir, []rotate.IndexEntry{{
+		File: "data.jsonl", From: base, To: base.Add(time.Second), Lines: 2,
+		Labels: map[string]map[string]int64{"pod": {"api-0": 1, "api-1": 1}, "msg": {"x": 1}},
+	}})
+
+	read := func(path string) (string, [][]string) {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte(utf8BOM)))).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data), records
+	}
+
+	out := filepath.Join(t.TempDir(), "default.csv")
+	if err := Export(dir, out, FormatCSV, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, records := read(out)
+	if fmt.Sprint(records[0]) != "[ts label:msg pod msg]" {
+		t.Errorf("header = %v, want [ts label:msg pod msg]", records[0])
+	}
+	if !strings.HasPrefix(data, "ts,label:msg,pod,msg\r\n") {
+		t.Errorf("want CRLF line endings, got %q", data)
+	}
+	if records[2][3] != "line \"two\",\nwrapped" {
+		t.Errorf("msg = %q", records[2][3])
+	}
+
+	out = filepath.Join(t.TempDir(), "selected.csv")
+	opts := ExportOptions{Columns: []string{"pod", "msg", "labels"}, ExcelSafe: true}
+	if err := ExportWithOptions(dir, out, FormatCSV, nil, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	data, records = read(out)
+	if !strings.HasPrefix(data, utf8BOM) {
+		t.Error("want a UTF-8 byte order mark with ExcelSafe")
+	}
+	want := [][]string{
+		{"pod", "msg", "labels"},
+		{"api-0", "'=HYPERLINK(\"http://evil\")", "msg=x;pod=api-0"},
+		{"api-1", "line \"two\",\nwrapped", "pod=api-1"},
+	}
+	if fmt.Sprint(records) != fmt.Sprint(want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+
+	for _, bad := range [][]string{{"pod", "pod"}, {""}} {
+		if err := ExportWithOptions(dir, out, FormatCSV, nil, nil, ExportOptions{Columns: bad}); err == nil {
+			t.Errorf("columns %q: want error", bad)
+		}
+	}
+	if err := ExportWithOptions(dir, out, FormatJSONL, nil, nil, ExportOptions{Columns: []string{"msg"}}); err == nil {
+		t.Error("want error for columns with jsonl")
 	}
 }
 
@@ -278,6 +407,44 @@ func TestExportCSVLabelFormat(t *testing.T) {
 	}
 }
 
+func TestExportCSVOrphanKeepsLabels(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	writeMetadata(t, dir, base, base.Add(time.Minute), 2)
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "indexed"},
+	})
+	writeDataFile(t, dir, "2024-01-15T100100-000.jsonl", []recv.LogEntry{
+		{Timestamp: base.Add(time.Minute), Labels: map[string]string{"pod": "web-0"}, Message: "orphan"},
+	})
+	writeIndex(t, dir, []rotate.IndexEntry{{
+		File: "2024-01-15T100000-000.jsonl", From: base, To: base, Lines: 1,
+		Labels: map[string]map[string]int64{"app": {"api": 1}},
+	}})
+
+	out := filepath.Join(t.TempDir(), "mixed.csv")
+	if err := Export(dir, out, FormatCSV, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"ts", "app", "labels", "msg"},
+		{base.Format(time.RFC3339Nano), "api", "app=api", "indexed"},
+		{base.Add(time.Minute).Format(time.RFC3339Nano), "", "pod=web-0", "orphan"},
+	}
+	if fmt.Sprint(records) != fmt.Sprint(want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
 func TestExportParquetWithFilter(t *testing.T) {
 	src, base := setupExportSource(t)
 	out := filepath.Join(t.TempDir(), "filtered.parquet")
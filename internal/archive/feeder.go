@@ -23,6 +23,7 @@ type Feeder struct {
 	filter      *Filter
 	transform   func(recv.LogEntry) []recv.LogEntry
 	labelFilter func(recv.LogEntry) bool
+	sink        func(recv.LogEntry)
 
 	mu          sync.Mutex
 	speed       Speed
@@ -66,6 +67,12 @@ func (f *Feeder) SetLabelFilter(fn func(recv.LogEntry) bool) {
 	f.labelFilter = fn
 }
 
+// SetSink sets a function that receives emitted entries instead of the ring.
+// Must be called before Start.
+func (f *Feeder) SetSink(fn func(recv.LogEntry)) {
+	f.sink = fn
+}
+
 // Start launches the feeder goroutine.
 func (f *Feeder) Start() {
 	f.mu.Lock()
@@ -248,7 +255,11 @@ func (f *Feeder) run() {
 			entries = f.transform(e)
 		}
 		for _, out := range entries {
-			f.ring.Push(out)
+			if f.sink != nil {
+				f.sink(out)
+			} else {
+				f.ring.Push(out)
+			}
 			f.linesEmitted.Add(1)
 		}
 		return true
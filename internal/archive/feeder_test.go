@@ -337,3 +337,32 @@ func TestFeederErrorOnBadDir(t *testing.T) {
 }
 
 // writeMetadata, writeIndex, writeDataFile are defined in reader_test.go
+
+func TestFeederSink(t *testing.T) {
+	_, reader := setupFeederDir(t, 20, time.Second)
+	var got []recv.LogEntry
+	feeder := NewFeeder(reader, nil, nil, SpeedInstant)
+	feeder.SetSink(func(e recv.LogEntry) {
+		got = append(got, e)
+	})
+
+	feeder.Start()
+	deadline := time.After(5 * time.Second)
+	for !feeder.Done() {
+		select {
+		case <-deadline:
+			feeder.Stop()
+			t.Fatal("feeder did not complete in time")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	feeder.Stop()
+
+	if len(got) != 20 {
+		t.Fatalf("sink received %d entries, want 20", len(got))
+	}
+	if got[0].Message != "line 0" || got[19].Message != "line 19" {
+		t.Errorf("unexpected order: first=%q last=%q", got[0].Message, got[19].Message)
+	}
+}
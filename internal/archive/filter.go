@@ -2,6 +2,7 @@ package archive
 
 import (
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -16,12 +17,22 @@ type LabelMatcher struct {
 	Value string
 }
 
+// FieldMatcher matches a field of JSON messages, e.g. trace_id=abc.
+// Key may be a dotted path into nested objects.
+type FieldMatcher struct {
+	Key   string
+	Value string
+}
+
 // Filter provides two-tier filtering: file-level skip and entry-level match.
 type Filter struct {
-	From   time.Time
-	To     time.Time
-	Labels []LabelMatcher
-	Grep   *regexp.Regexp
+	From     time.Time
+	To       time.Time
+	Labels   []LabelMatcher
+	Fields   []FieldMatcher // AND logic, like Labels
+	Grep     *regexp.Regexp
+	Restarts *RestartFilter // keep only entries near container restarts
+	Phases   *PhaseFilter   // keep only entries within annotated test phases
 }
 
 // SkipFile returns true if the entire file can be skipped based on index metadata.
@@ -47,10 +58,71 @@ func (f *Filter) SkipFile(idx *rotate.IndexEntry) bool {
 		}
 	}
 
+	if f.Restarts != nil && !f.Restarts.overlaps(idx.From, idx.To) {
+		return true
+	}
+	if f.Phases != nil && !f.Phases.overlaps(idx.From, idx.To) {
+		return true
+	}
+
 	// grep: cannot skip at file level
 	return false
 }
 
+// skip reports whether file f can be skipped, consulting its field index
+// for Fields and its bloom filter for Grep after the index metadata.
+func (f *Filter) skip(fi FileInfo) bool {
+	if f == nil || fi.Orphan || fi.Index == nil {
+		return false
+	}
+	if f.SkipFile(fi.Index) {
+		return true
+	}
+	dir := filepath.Dir(fi.Path)
+	return f.skipFields(dir, fi.Name) || f.skipGrep(dir, fi.Name, fi.Index)
+}
+
+// skipFields reports whether the field index of data file name in dir rules
+// out every line matching Fields. Files without a readable one are kept.
+func (f *Filter) skipFields(dir, name string) bool {
+	if len(f.Fields) == 0 {
+		return false
+	}
+	fi, err := rotate.ReadFieldIndex(dir, name)
+	if err != nil || fi == nil {
+		return false
+	}
+	for _, fm := range f.Fields {
+		if !fi.MayContain(fm.Key, fm.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldKeys returns the keys of the field matchers.
+func fieldKeys(fields []FieldMatcher) []string {
+	keys := make([]string, len(fields))
+	for i, fm := range fields {
+		keys[i] = fm.Key
+	}
+	return keys
+}
+
+// matchFields reports whether msg carries every field matcher's value.
+func matchFields(fields []FieldMatcher, msg string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	values := rotate.ExtractFields(msg, fieldKeys(fields))
+	for _, fm := range fields {
+		if v, ok := values[fm.Key]; !ok || v != fm.Value {
+			return false
+		}
+	}
+	return true
+}
+
 // MatchEntry returns true if the entry passes all filter criteria.
 func (f *Filter) MatchEntry(e recv.LogEntry) bool {
 	if f == nil {
@@ -72,6 +144,17 @@ func (f *Filter) MatchEntry(e recv.LogEntry) bool {
 		}
 	}
 
+	if !matchFields(f.Fields, e.Message) {
+		return false
+	}
+
+	if f.Restarts != nil && !f.Restarts.Match(e.Timestamp, e.Labels) {
+		return false
+	}
+	if f.Phases != nil && !f.Phases.Match(e.Timestamp) {
+		return false
+	}
+
 	// grep: match message or any label value
 	if f.Grep != nil && !grepMatchEntry(f.Grep, e) {
 		return false
@@ -139,3 +222,12 @@ func ParseLabelFlag(s string) (LabelMatcher, error) {
 	}
 	return LabelMatcher{Key: parts[0], Value: parts[1]}, nil
 }
+
+// ParseFieldFlag parses a "key=value" field matcher.
+func ParseFieldFlag(s string) (FieldMatcher, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return FieldMatcher{}, fmt.Errorf("invalid field filter %q: expected key=value", s)
+	}
+	return FieldMatcher{Key: key, Value: value}, nil
+}
@@ -1,6 +1,8 @@
 package archive
 
 import (
+	"encoding/json"
+	"fmt"
 	"regexp"
 	"testing"
 	"time"
@@ -358,3 +360,98 @@ func TestMatchEntryNilFilter(t *testing.T) {
 		t.Error("nil filter should match everything")
 	}
 }
+
+func TestParseFieldFlag(t *testing.T) {
+	fm, err := ParseFieldFlag("http.status=503")
+	if err != nil || fm.Key != "http.status" || fm.Value != "503" {
+		t.Errorf("ParseFieldFlag = %+v, %v", fm, err)
+	}
+	if fm, err := ParseFieldFlag("user="); err != nil || fm.Value != "" {
+		t.Errorf("empty value: %+v, %v", fm, err)
+	}
+	for _, bad := range []string{"", "trace_id", "=abc"} {
+		if _, err := ParseFieldFlag(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestMatchEntryFields(t *testing.T) {
+	f := &Filter{Fields: []FieldMatcher{{Key: "trace_id", Value: "abc"}, {Key: "http.status", Value: "503"}}}
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{`{"trace_id":"abc","http":{"status":503}}`, true},
+		{`{"trace_id":"abc","http":{"status":200}}`, false},
+		{`{"trace_id":"abc"}`, false},
+		{`trace_id=abc status=503`, false},
+	}
+	for _, tt := range tests {
+		if got := f.MatchEntry(recv.LogEntry{Message: tt.msg}); got != tt.want {
+			t.Errorf("MatchEntry(%s) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+// writeFieldCapture records n JSON lines, each with its own trace_id,
+// through a rotator indexing trace_id into small files.
+func writeFieldCapture(t *testing.T, n int, compress bool) string {
+	t.Helper()
+	dir := t.TempDir()
+	rot, err := rotate.New(rotate.Config{Dir: dir, MaxFile: 300, MaxDisk: 1 << 30, Compress: compress, IndexFields: []string{"trace_id"}, GrepBloom: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := range n {
+		e := recv.LogEntry{
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Labels:    map[string]string{"app": "api"},
+			Message:   fmt.Sprintf(`{"trace_id":"t%02d","status":200}`, i),
+		}
+		data, _ := json.Marshal(e)
+		if _, err := rot.WriteLabeled(append(data, '\n'), e.Timestamp, e.Labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rot.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeMetadata(t, dir, base, base.Add(time.Duration(n)*time.Second), int64(n))
+	return dir
+}
+
+func TestScanSkipsByFieldIndex(t *testing.T) {
+	dir := writeFieldCapture(t, 30, true)
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Files()) < 3 {
+		t.Fatalf("%d files, want several", len(r.Files()))
+	}
+
+	f := &Filter{Fields: []FieldMatcher{{Key: "trace_id", Value: "t17"}}}
+	var got []string
+	scanned, err := r.Scan(f, func(e recv.LogEntry) bool {
+		got = append(got, e.Message)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != `{"trace_id":"t17","status":200}` {
+		t.Errorf("matches = %v", got)
+	}
+	if scanned >= 30/int64(len(r.Files()))+2 {
+		t.Errorf("scanned %d lines, want only the file holding t17", scanned)
+	}
+
+	// fields not indexed are still found by scanning every file
+	f = &Filter{Fields: []FieldMatcher{{Key: "status", Value: "200"}}}
+	scanned, _ = r.Scan(f, func(recv.LogEntry) bool { return true })
+	if scanned != 30 {
+		t.Errorf("scanned %d lines for an unindexed field, want 30", scanned)
+	}
+}
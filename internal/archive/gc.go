@@ -14,12 +14,34 @@ import (
 	"github.com/ppiankov/logtap/internal/recv"
 )
 
+// GC safety defaults.
+const (
+	DefaultGCActiveWindow = 15 * time.Minute
+	DefaultGCTrashTTL     = 24 * time.Hour
+)
+
+// trashSuffix separates a trashed capture's name from the time it was
+// trashed.
+const trashSuffix = ".trashed-"
+
+const trashTimeLayout = "20060102T150405Z"
+
 // GCOptions configures garbage collection of capture directories.
 type GCOptions struct {
 	MaxAge        time.Duration
 	MaxTotalBytes int64
 	DryRun        bool
 	Now           time.Time
+
+	// Force also deletes captures that look live: a receiver lock file
+	// with a recent heartbeat, or files modified within ActiveWindow.
+	Force        bool
+	ActiveWindow time.Duration // 0 = DefaultGCActiveWindow
+
+	// TrashDir moves deleted captures there instead of removing them. Runs
+	// with the same TrashDir remove trashed captures older than TrashTTL.
+	TrashDir string
+	TrashTTL time.Duration // 0 = DefaultGCTrashTTL
 }
 
 // GCDeletion records a capture directory selected for deletion.
@@ -28,6 +50,13 @@ type GCDeletion struct {
 	Started   time.Time `json:"started"`
 	SizeBytes int64     `json:"size_bytes"`
 	Reasons   []string  `json:"reasons,omitempty"`
+	Trash     string    `json:"trash,omitempty"` // where the capture was moved with TrashDir
+}
+
+// GCSkip records a capture that would have been deleted but looks live.
+type GCSkip struct {
+	Dir    string `json:"dir"`
+	Reason string `json:"reason"`
 }
 
 // GCResult summarizes a GC run.
@@ -39,14 +68,20 @@ type GCResult struct {
 	MaxTotalBytes int64         `json:"max_total_bytes,omitempty"`
 	DryRun        bool          `json:"dry_run"`
 	Deletions     []GCDeletion  `json:"deletions"`
+	Skipped       []GCSkip      `json:"skipped,omitempty"`
+	TrashDir      string        `json:"trash_dir,omitempty"`
+	TrashTTL      time.Duration `json:"trash_ttl,omitempty"`
+	Purged        []string      `json:"purged,omitempty"` // trashed captures removed for good
 
 	now time.Time
 }
 
 type captureInfo struct {
 	Dir       string
+	Shards    []string // shard directories outside Dir, deleted with it
 	Started   time.Time
 	SizeBytes int64
+	Modified  time.Time // newest file modification
 }
 
 // GC scans subdirectories of root and deletes old or oversized captures.
@@ -59,6 +94,14 @@ func GC(root string, opts GCOptions) (*GCResult, error) {
 	if now.IsZero() {
 		now = time.Now()
 	}
+	window := opts.ActiveWindow
+	if window <= 0 {
+		window = DefaultGCActiveWindow
+	}
+	ttl := opts.TrashTTL
+	if ttl <= 0 {
+		ttl = DefaultGCTrashTTL
+	}
 
 	captures, err := scanCaptures(root)
 	if err != nil {
@@ -73,17 +116,43 @@ func GC(root string, opts GCOptions) (*GCResult, error) {
 		DryRun:        opts.DryRun,
 		now:           now,
 	}
+	if opts.TrashDir != "" {
+		result.TrashDir = opts.TrashDir
+		result.TrashTTL = ttl
+		if result.Purged, err = purgeTrash(opts.TrashDir, now.Add(-ttl), opts.DryRun); err != nil {
+			return result, err
+		}
+	}
 
+	shardsOf := make(map[string][]string, len(captures))
 	for _, c := range captures {
 		result.TotalBytes += c.SizeBytes
+		shardsOf[c.Dir] = c.Shards
 	}
 
 	if len(captures) == 0 {
 		return result, nil
 	}
 
+	active := make(map[string]string)
+	if !opts.Force {
+		for _, c := range captures {
+			if active[c.Dir], err = captureActivity(c, now, window); err != nil {
+				return result, err
+			}
+		}
+	}
+
 	deletions := make(map[string]*GCDeletion)
+	skipped := make(map[string]bool)
 	mark := func(c captureInfo, reason string) {
+		if why := active[c.Dir]; why != "" {
+			if !skipped[c.Dir] {
+				skipped[c.Dir] = true
+				result.Skipped = append(result.Skipped, GCSkip{Dir: c.Dir, Reason: why})
+			}
+			return
+		}
 		d, ok := deletions[c.Dir]
 		if !ok {
 			d = &GCDeletion{Dir: c.Dir, Started: c.Started, SizeBytes: c.SizeBytes}
@@ -130,7 +199,9 @@ func GC(root string, opts GCOptions) (*GCResult, error) {
 					break
 				}
 				mark(c, "max-total")
-				total -= c.SizeBytes
+				if deletions[c.Dir] != nil {
+					total -= c.SizeBytes
+				}
 			}
 		}
 	}
@@ -152,13 +223,27 @@ func GC(root string, opts GCOptions) (*GCResult, error) {
 		return result, nil
 	}
 
-	for _, d := range result.Deletions {
+	for i := range result.Deletions {
+		d := &result.Deletions[i]
 		if _, err := os.Stat(filepath.Join(d.Dir, "metadata.json")); err != nil {
 			if os.IsNotExist(err) {
 				continue
 			}
 			return result, fmt.Errorf("check metadata: %w", err)
 		}
+		shards := shardsOf[d.Dir]
+		if opts.TrashDir != "" {
+			if d.Trash, err = moveToTrash(d.Dir, shards, opts.TrashDir, now); err != nil {
+				return result, err
+			}
+			continue
+		}
+		// shards first, so an interrupted run leaves the metadata to retry
+		for _, shard := range shards {
+			if err := os.RemoveAll(shard); err != nil {
+				return result, fmt.Errorf("delete shard %s: %w", shard, err)
+			}
+		}
 		if err := os.RemoveAll(d.Dir); err != nil {
 			return result, fmt.Errorf("delete %s: %w", d.Dir, err)
 		}
@@ -167,6 +252,166 @@ func GC(root string, opts GCOptions) (*GCResult, error) {
 	return result, nil
 }
 
+// captureActivity returns why a capture looks like it is still being
+// written, or "" if it does not.
+func captureActivity(c captureInfo, now time.Time, window time.Duration) (string, error) {
+	lock, err := recv.ReadLock(c.Dir)
+	if err != nil {
+		return fmt.Sprintf("unreadable %s: %v", recv.LockFile, err), nil
+	}
+	if lock != nil && lock.Live(now) {
+		return fmt.Sprintf("live receiver, pid %d on %s", lock.PID, lock.Host), nil
+	}
+	if age := now.Sub(c.Modified); age < window {
+		return fmt.Sprintf("modified %s ago", formatHumanDuration(max(age, time.Second))), nil
+	}
+	return "", nil
+}
+
+// moveToTrash moves dir into trashDir as <name>.trashed-<time>. Shards on
+// other disks cannot move there; each is renamed in place with the same
+// suffix instead, and purged and restored along with dir.
+func moveToTrash(dir string, shards []string, trashDir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(trashDir, 0o755); err != nil {
+		return "", fmt.Errorf("create trash dir: %w", err)
+	}
+	base := filepath.Join(trashDir, filepath.Base(dir)+trashSuffix+now.UTC().Format(trashTimeLayout))
+	dst := base
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			break
+		}
+		dst = fmt.Sprintf("%s-%d", base, n)
+	}
+	if err := os.Rename(dir, dst); err != nil {
+		return "", fmt.Errorf("move %s to trash (the trash dir must be on the same filesystem): %w", dir, err)
+	}
+	suffix := strings.TrimPrefix(dst, filepath.Join(trashDir, filepath.Base(dir)))
+	for _, shard := range shards {
+		if err := os.Rename(shard, shard+suffix); err != nil && !os.IsNotExist(err) {
+			return dst, fmt.Errorf("move shard %s to trash: %w", shard, err)
+		}
+	}
+	return dst, nil
+}
+
+// trashedShards returns the shards of the trashed capture at path that were
+// renamed in place by moveToTrash, under their trashed and original names.
+// Only shards recorded by absolute path, as recv records them, are found.
+func trashedShards(path string) (moved, original []string) {
+	meta, err := recv.ReadMetadata(path)
+	if err != nil {
+		return nil, nil
+	}
+	name := filepath.Base(path)
+	base, _, ok := trashed(name)
+	if !ok {
+		return nil, nil
+	}
+	suffix := name[len(base):]
+	for _, shard := range meta.Shards {
+		if filepath.IsAbs(shard) {
+			moved = append(moved, shard+suffix)
+			original = append(original, shard)
+		}
+	}
+	return moved, original
+}
+
+// trashed parses a trash entry name into the capture name and the time it
+// was trashed.
+func trashed(name string) (string, time.Time, bool) {
+	i := strings.LastIndex(name, trashSuffix)
+	if i <= 0 {
+		return "", time.Time{}, false
+	}
+	stamp := name[i+len(trashSuffix):]
+	if j := strings.IndexByte(stamp, '-'); j >= 0 {
+		stamp = stamp[:j] // collision counter
+	}
+	t, err := time.Parse(trashTimeLayout, stamp)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return name[:i], t, true
+}
+
+// purgeTrash removes trashed captures trashed before cutoff.
+func purgeTrash(trashDir string, cutoff time.Time, dryRun bool) ([]string, error) {
+	entries, err := os.ReadDir(trashDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read trash dir: %w", err)
+	}
+	var purged []string
+	for _, e := range entries {
+		_, at, ok := trashed(e.Name())
+		if !ok || !e.IsDir() || !at.Before(cutoff) {
+			continue
+		}
+		path := filepath.Join(trashDir, e.Name())
+		if !dryRun {
+			shards, _ := trashedShards(path)
+			for _, shard := range shards {
+				if err := os.RemoveAll(shard); err != nil {
+					return purged, fmt.Errorf("purge %s: %w", shard, err)
+				}
+			}
+			if err := os.RemoveAll(path); err != nil {
+				return purged, fmt.Errorf("purge %s: %w", path, err)
+			}
+		}
+		purged = append(purged, path)
+	}
+	return purged, nil
+}
+
+// RestoreTrash moves the capture name back from trashDir into root and
+// returns its restored path. name is either a trash entry or the original
+// capture name, in which case the most recently trashed copy is restored.
+func RestoreTrash(root, trashDir, name string) (string, error) {
+	entries, err := os.ReadDir(trashDir)
+	if err != nil {
+		return "", fmt.Errorf("read trash dir: %w", err)
+	}
+	var (
+		src, capture string
+		latest       time.Time
+	)
+	for _, e := range entries {
+		base, at, ok := trashed(e.Name())
+		if !ok || !e.IsDir() {
+			continue
+		}
+		if e.Name() == name {
+			src, capture = e.Name(), base
+			break
+		}
+		if base == name && (src == "" || !at.Before(latest)) {
+			src, capture, latest = e.Name(), base, at
+		}
+	}
+	if src == "" {
+		return "", fmt.Errorf("no capture %q in trash %s", name, trashDir)
+	}
+	dst := filepath.Join(root, capture)
+	if _, err := os.Lstat(dst); err == nil {
+		return "", fmt.Errorf("restore %s: %s already exists", name, dst)
+	}
+	shards, original := trashedShards(filepath.Join(trashDir, src))
+	if err := os.Rename(filepath.Join(trashDir, src), dst); err != nil {
+		return "", fmt.Errorf("restore %s: %w", name, err)
+	}
+	for i, shard := range shards {
+		if err := os.Rename(shard, original[i]); err != nil && !os.IsNotExist(err) {
+			return dst, fmt.Errorf("restore shard %s: %w", original[i], err)
+		}
+	}
+	return dst, nil
+}
+
 // WriteJSON writes the deletion list as indented JSON.
 func (r *GCResult) WriteJSON(w io.Writer) error {
 	enc := json.NewEncoder(w)
@@ -179,11 +424,29 @@ func (r *GCResult) WriteText(w io.Writer) {
 	tw := &textWriter{w: w}
 
 	tw.printf("Captures: %d   Total size: %s\n", r.CaptureCount, FormatBytes(r.TotalBytes))
-	if r.DryRun {
-		tw.printf("Dry run: would delete %d capture(s)\n", len(r.Deletions))
-	} else {
+	verb := "delete"
+	if r.TrashDir != "" {
+		verb = fmt.Sprintf("move to %s (kept %s)", r.TrashDir, formatHumanDuration(r.TrashTTL))
+	}
+	switch {
+	case r.DryRun:
+		tw.printf("Dry run: would %s %d capture(s)\n", verb, len(r.Deletions))
+	case r.TrashDir != "":
+		tw.printf("Moved %d capture(s) to %s (kept %s)\n", len(r.Deletions), r.TrashDir, formatHumanDuration(r.TrashTTL))
+	default:
 		tw.printf("Deleted %d capture(s)\n", len(r.Deletions))
 	}
+	r.writeSkipped(tw)
+	if len(r.Purged) > 0 {
+		if r.DryRun {
+			tw.printf("Dry run: would purge %d capture(s) from trash\n", len(r.Purged))
+		} else {
+			tw.printf("Purged %d capture(s) from trash\n", len(r.Purged))
+		}
+		for _, p := range r.Purged {
+			tw.printf("  %s\n", p)
+		}
+	}
 	if len(r.Deletions) == 0 {
 		return
 	}
@@ -215,6 +478,16 @@ func (r *GCResult) WriteText(w io.Writer) {
 	}
 }
 
+func (r *GCResult) writeSkipped(tw *textWriter) {
+	if len(r.Skipped) == 0 {
+		return
+	}
+	tw.printf("Skipped %d live capture(s) (--force to delete):\n", len(r.Skipped))
+	for _, s := range r.Skipped {
+		tw.printf("  %s  (%s)\n", s.Dir, s.Reason)
+	}
+}
+
 func scanCaptures(root string) ([]captureInfo, error) {
 	entries, err := os.ReadDir(root)
 	if err != nil {
@@ -238,17 +511,45 @@ func scanCaptures(root string) ([]captureInfo, error) {
 		if err != nil {
 			return nil, fmt.Errorf("read metadata %s: %w", dir, err)
 		}
-		sizeBytes, err := dirSize(dir)
+		sizeBytes, modified, err := dirStats(dir)
 		if err != nil {
 			return nil, fmt.Errorf("size %s: %w", dir, err)
 		}
-		captures = append(captures, captureInfo{Dir: dir, Started: meta.Started, SizeBytes: sizeBytes})
+		c := captureInfo{Dir: dir, Started: meta.Started, SizeBytes: sizeBytes, Modified: modified}
+		for _, shard := range captureDirs(dir, meta)[1:] {
+			if !outsideDir(dir, shard) {
+				continue
+			}
+			size, mod, err := dirStats(shard)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("size %s: %w", shard, err)
+			}
+			c.Shards = append(c.Shards, shard)
+			c.SizeBytes += size
+			if mod.After(c.Modified) {
+				c.Modified = mod
+			}
+		}
+		captures = append(captures, c)
 	}
 	return captures, nil
 }
 
 func dirSize(dir string) (int64, error) {
-	var total int64
+	total, _, err := dirStats(dir)
+	return total, err
+}
+
+// dirStats returns the total size of the files under dir and the newest
+// modification time among them.
+func dirStats(dir string) (int64, time.Time, error) {
+	var (
+		total    int64
+		modified time.Time
+	)
 	walkErr := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -261,7 +562,10 @@ func dirSize(dir string) (int64, error) {
 			return err
 		}
 		total += info.Size()
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
 		return nil
 	})
-	return total, walkErr
+	return total, modified, walkErr
 }
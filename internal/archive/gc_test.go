@@ -8,6 +8,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
 )
 
 func TestGC_MaxAge(t *testing.T) {
@@ -107,6 +109,102 @@ func TestGC_SkipNonCapture(t *testing.T) {
 	assertMissing(t, oldDir)
 }
 
+func TestGC_SkipsLiveCaptures(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	started := now.Add(-10 * 24 * time.Hour)
+
+	locked := createCapture(t, root, "locked", started, 8)
+	lock, err := recv.AcquireLock(locked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = lock.Release() }()
+	writing := createCapture(t, root, "writing", started, 8)
+	recent := now.Add(-2 * time.Minute)
+	if err := os.Chtimes(filepath.Join(writing, "data.jsonl"), recent, recent); err != nil {
+		t.Fatal(err)
+	}
+	crashed := createCapture(t, root, "crashed", started, 8)
+	if err := os.WriteFile(filepath.Join(crashed, recv.LockFile), []byte(`{"pid":1,"host":"h"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backdate(t, crashed, started)
+
+	result, err := GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now})
+	if err != nil {
+		t.Fatalf("GC error: %v", err)
+	}
+	assertExists(t, locked)
+	assertExists(t, writing)
+	assertMissing(t, crashed)
+	if len(result.Skipped) != 2 {
+		t.Fatalf("skipped = %+v, want locked and writing", result.Skipped)
+	}
+	reasons := map[string]string{}
+	for _, s := range result.Skipped {
+		reasons[filepath.Base(s.Dir)] = s.Reason
+	}
+	if !strings.HasPrefix(reasons["locked"], "live receiver") || !strings.HasPrefix(reasons["writing"], "modified 2m") {
+		t.Errorf("skip reasons = %v", reasons)
+	}
+	var buf bytes.Buffer
+	result.WriteText(&buf)
+	if !strings.Contains(buf.String(), "Skipped 2 live capture(s) (--force to delete)") {
+		t.Errorf("text output missing skipped captures:\n%s", buf.String())
+	}
+
+	if _, err := GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now, Force: true}); err != nil {
+		t.Fatalf("GC --force error: %v", err)
+	}
+	assertMissing(t, locked)
+	assertMissing(t, writing)
+}
+
+func TestGC_Trash(t *testing.T) {
+	root := t.TempDir()
+	trash := filepath.Join(root, ".trash")
+	now := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	old := createCapture(t, root, "old", now.Add(-10*24*time.Hour), 8)
+
+	result, err := GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now, TrashDir: trash})
+	if err != nil {
+		t.Fatalf("GC error: %v", err)
+	}
+	assertMissing(t, old)
+	want := filepath.Join(trash, "old.trashed-20240401T000000Z")
+	if len(result.Deletions) != 1 || result.Deletions[0].Trash != want {
+		t.Fatalf("deletions = %+v, want old moved to %s", result.Deletions, want)
+	}
+	assertExists(t, want)
+
+	// the trash dir is no capture and is not collected
+	result, err = GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now.Add(time.Hour), TrashDir: trash})
+	if err != nil || result.CaptureCount != 0 || len(result.Purged) != 0 {
+		t.Fatalf("second GC = %+v, %v; want nothing to do", result, err)
+	}
+
+	restored, err := RestoreTrash(root, trash, "old")
+	if err != nil || restored != old {
+		t.Fatalf("RestoreTrash = %q, %v; want %s", restored, err, old)
+	}
+	if _, err := RestoreTrash(root, trash, "old"); err == nil {
+		t.Error("restoring twice should fail")
+	}
+
+	if _, err := GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now, TrashDir: trash}); err != nil {
+		t.Fatal(err)
+	}
+	result, err = GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now.Add(25 * time.Hour), TrashDir: trash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Purged) != 1 {
+		t.Fatalf("purged = %v, want the trashed capture after its TTL", result.Purged)
+	}
+	assertMissing(t, want)
+}
+
 func TestGC_EmptyDir(t *testing.T) {
 	root := t.TempDir()
 	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
@@ -251,9 +349,25 @@ func createCapture(t *testing.T, root, name string, started time.Time, size int)
 			t.Fatalf("write data file: %v", err)
 		}
 	}
+	backdate(t, dir, started)
 	return dir
 }
 
+// backdate sets the modification time of the files in dir to at, so gc
+// does not take the capture for one being written.
+func backdate(t *testing.T, dir string, at time.Time) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if err := os.Chtimes(filepath.Join(dir, e.Name()), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func assertExists(t *testing.T, dir string) {
 	t.Helper()
 	if _, err := os.Stat(dir); err != nil {
@@ -269,3 +383,74 @@ func assertMissing(t *testing.T, dir string) {
 		t.Fatalf("stat %s: %v", dir, err)
 	}
 }
+
+// shardCapture records an external shard holding size bytes in the capture
+// at dir, as recv --shard-dirs does, and returns the shard directory.
+func shardCapture(t *testing.T, dir string, size int) string {
+	t.Helper()
+	shard := t.TempDir()
+	if err := os.WriteFile(filepath.Join(shard, "data.jsonl"), bytes.Repeat([]byte{'b'}, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.Shards = []string{shard}
+	if err := recv.WriteMetadata(dir, meta); err != nil {
+		t.Fatal(err)
+	}
+	backdate(t, dir, meta.Started)
+	backdate(t, shard, meta.Started)
+	return shard
+}
+
+func TestGC_Shards(t *testing.T) {
+	root := t.TempDir()
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	old := createCapture(t, root, "old", now.Add(-10*24*time.Hour), 16)
+	shard := shardCapture(t, old, 32)
+
+	result, err := GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now})
+	if err != nil {
+		t.Fatalf("GC error: %v", err)
+	}
+	if len(result.Deletions) != 1 || result.Deletions[0].SizeBytes < 48 {
+		t.Fatalf("deletions = %+v, want old with its shard's bytes", result.Deletions)
+	}
+	assertMissing(t, old)
+	assertMissing(t, shard)
+}
+
+func TestGC_TrashShards(t *testing.T) {
+	root := t.TempDir()
+	trash := filepath.Join(root, ".trash")
+	now := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	old := createCapture(t, root, "old", now.Add(-10*24*time.Hour), 8)
+	shard := shardCapture(t, old, 8)
+
+	if _, err := GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now, TrashDir: trash}); err != nil {
+		t.Fatalf("GC error: %v", err)
+	}
+	moved := shard + ".trashed-20240401T000000Z"
+	assertMissing(t, shard)
+	assertExists(t, moved)
+
+	if _, err := RestoreTrash(root, trash, "old"); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, shard)
+	assertMissing(t, moved)
+
+	if _, err := GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now, TrashDir: trash}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := GC(root, GCOptions{MaxAge: 7 * 24 * time.Hour, Now: now.Add(25 * time.Hour), TrashDir: trash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Purged) != 1 {
+		t.Fatalf("purged = %v, want the trashed capture after its TTL", result.Purged)
+	}
+	assertMissing(t, moved)
+}
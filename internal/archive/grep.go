@@ -8,15 +8,14 @@ import (
 	"os"
 	"strings"
 
-	"github.com/klauspost/compress/zstd"
-
 	"github.com/ppiankov/logtap/internal/recv"
 )
 
 // GrepConfig controls grep behavior.
 type GrepConfig struct {
-	CountOnly bool // only report per-file counts, do not call onMatch
-	Context   int  // number of surrounding lines to include (0 = matches only)
+	CountOnly bool     // only report per-file counts, do not call onMatch
+	Context   int      // number of surrounding lines to include (0 = matches only)
+	Profile   *Profile // per-file read profile (nil = off)
 }
 
 // GrepMatch represents a matching entry with file context.
@@ -61,7 +60,7 @@ func Grep(src string, filter *Filter, cfg GrepConfig,
 	)
 
 	for _, f := range files {
-		if filter != nil && !f.Orphan && f.Index != nil && filter.SkipFile(f.Index) {
+		if filter.skip(f) {
 			continue
 		}
 
@@ -86,7 +85,7 @@ func Grep(src string, filter *Filter, cfg GrepConfig,
 }
 
 func grepFile(f FileInfo, filter *Filter, cfg GrepConfig, onMatch func(GrepMatch)) (int64, int64, error) {
-	file, err := os.Open(f.Path)
+	file, name, err := openDataFile(f)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return 0, 0, nil // file rotated away during scan
@@ -95,20 +94,23 @@ func grepFile(f FileInfo, filter *Filter, cfg GrepConfig, onMatch func(GrepMatch
 	}
 	defer func() { _ = file.Close() }()
 
-	var r io.Reader = file
-	if strings.HasSuffix(f.Name, ".zst") {
-		dec, err := zstd.NewReader(file)
+	prof := cfg.Profile.profileFile(f.Name)
+	defer prof.done()
+
+	r := prof.wrapFile(file)
+	if strings.HasSuffix(name, ".zst") {
+		dec, closeDec, err := prof.openZstd(r)
 		if err != nil {
-			return 0, 0, fmt.Errorf("zstd open: %w", err)
+			return 0, 0, err
 		}
-		defer dec.Close()
+		defer closeDec()
 		r = dec
 	}
 
 	// When context is requested, collect all entries and match indices,
 	// then expand ranges and emit with context markers.
 	if cfg.Context > 0 && !cfg.CountOnly && onMatch != nil {
-		return grepFileWithContext(f.Name, r, filter, cfg.Context, onMatch)
+		return grepFileWithContext(f.Name, r, filter, cfg.Context, onMatch, prof)
 	}
 
 	var scanned, matches int64
@@ -122,12 +124,18 @@ func grepFile(f FileInfo, filter *Filter, cfg GrepConfig, onMatch func(GrepMatch
 		}
 
 		var entry recv.LogEntry
-		if err := json.Unmarshal(line, &entry); err != nil {
+		t := prof.now()
+		err := json.Unmarshal(line, &entry)
+		prof.decoded(t)
+		if err != nil {
 			continue
 		}
 		scanned++
 
-		if filter != nil && !filter.MatchEntry(entry) {
+		t = prof.now()
+		match := filter == nil || filter.MatchEntry(entry)
+		prof.filtered(t)
+		if !match {
 			continue
 		}
 
@@ -143,7 +151,7 @@ func grepFile(f FileInfo, filter *Filter, cfg GrepConfig, onMatch func(GrepMatch
 // grepFileWithContext scans a file, collecting all entries and tracking match
 // positions, then emits matches with surrounding context lines.
 func grepFileWithContext(name string, r io.Reader, filter *Filter, ctx int,
-	onMatch func(GrepMatch)) (int64, int64, error) {
+	onMatch func(GrepMatch), prof *fileProfiler) (int64, int64, error) {
 
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 256*1024), 1024*1024)
@@ -157,12 +165,18 @@ func grepFileWithContext(name string, r io.Reader, filter *Filter, ctx int,
 			continue
 		}
 		var entry recv.LogEntry
-		if err := json.Unmarshal(line, &entry); err != nil {
+		t := prof.now()
+		err := json.Unmarshal(line, &entry)
+		prof.decoded(t)
+		if err != nil {
 			continue
 		}
 		idx := len(entries)
 		entries = append(entries, entry)
-		if filter == nil || filter.MatchEntry(entry) {
+		t = prof.now()
+		match := filter == nil || filter.MatchEntry(entry)
+		prof.filtered(t)
+		if match {
 			matchIndices = append(matchIndices, idx)
 		}
 	}
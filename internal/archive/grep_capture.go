@@ -0,0 +1,158 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// grepCaptureMaxFile is the size at which a derived capture's data files
+// rotate.
+const grepCaptureMaxFile = 64 << 20
+
+// GrepCaptureResult summarizes a grep written as a capture.
+type GrepCaptureResult struct {
+	Source  string `json:"source"`
+	Output  string `json:"output"`
+	Matches int64  `json:"matches"`
+	Context int64  `json:"context_lines"`
+	Lines   int64  `json:"lines"`
+	Files   int    `json:"files"`
+}
+
+// GrepToCapture runs Grep over src and writes the matching entries, with
+// their context lines, to a new capture in dst: metadata, index and zstd
+// data files, so triage, diff and open read it like any other capture.
+// dst must not exist or be empty.
+func GrepToCapture(src, dst string, filter *Filter, cfg GrepConfig, progress func(GrepProgress)) (*GrepCaptureResult, error) {
+	if same, err := sameDir(src, dst); err != nil || same {
+		return nil, fmt.Errorf("output directory cannot be the capture directory")
+	}
+	if entries, err := os.ReadDir(dst); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("output directory %s is not empty", dst)
+	}
+
+	reader, err := NewReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("open source: %w", err)
+	}
+	meta := reader.Metadata()
+
+	rot, err := rotate.New(rotate.Config{Dir: dst, MaxFile: grepCaptureMaxFile, MaxDisk: 1 << 62, Compress: true})
+	if err != nil {
+		return nil, fmt.Errorf("create output: %w", err)
+	}
+
+	result := &GrepCaptureResult{Source: src, Output: dst}
+	var (
+		bytes        int64
+		minTS, maxTS time.Time
+		labelsSeen   = make(map[string]bool)
+		writeErr     error
+	)
+	cfg.CountOnly = false
+	_, grepErr := Grep(src, filter, cfg, func(m GrepMatch) {
+		if writeErr != nil {
+			return
+		}
+		data, err := json.Marshal(m.Entry)
+		if err != nil {
+			return
+		}
+		n, err := rot.WriteLabeled(append(data, '\n'), m.Entry.Timestamp, m.Entry.Labels)
+		if err != nil {
+			writeErr = err
+			return
+		}
+		bytes += int64(n)
+		result.Lines++
+		if m.Context == "" {
+			result.Matches++
+		} else {
+			result.Context++
+		}
+		if minTS.IsZero() || m.Entry.Timestamp.Before(minTS) {
+			minTS = m.Entry.Timestamp
+		}
+		if m.Entry.Timestamp.After(maxTS) {
+			maxTS = m.Entry.Timestamp
+		}
+		for k := range m.Entry.Labels {
+			labelsSeen[k] = true
+		}
+	}, progress)
+	if err := rot.Close(); err != nil && writeErr == nil {
+		writeErr = err
+	}
+	// dst was empty, so a failed run leaves nothing behind
+	if grepErr != nil {
+		_ = os.RemoveAll(dst)
+		return nil, grepErr
+	}
+	if writeErr != nil {
+		_ = os.RemoveAll(dst)
+		return nil, fmt.Errorf("write output: %w", writeErr)
+	}
+	if result.Lines == 0 {
+		_ = os.RemoveAll(dst)
+		return nil, fmt.Errorf("no matching log lines found")
+	}
+
+	index, err := readIndex(dst)
+	if err != nil {
+		return nil, fmt.Errorf("read output index: %w", err)
+	}
+	result.Files = len(index)
+
+	labels := make([]string, 0, len(labelsSeen))
+	for k := range labelsSeen {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+	out := &recv.Metadata{
+		Version:     recv.MetadataVersion,
+		Format:      meta.Format,
+		Started:     minTS,
+		Stopped:     maxTS,
+		TotalLines:  result.Lines,
+		TotalBytes:  bytes,
+		LabelsSeen:  labels,
+		Precision:   meta.Precision,
+		Redaction:   meta.Redaction,
+		Description: meta.Description,
+		Owner:       meta.Owner,
+		Meta:        meta.Meta,
+		Derived:     &recv.DerivedInfo{Source: src, Context: cfg.Context},
+	}
+	if filter != nil && filter.Grep != nil {
+		out.Derived.Pattern = filter.Grep.String()
+	}
+	out.AddFeature(recv.FeatureChecksums)
+	if slices.Contains(meta.Features, recv.FeatureDedup) { // entries keep their repeat counts
+		out.AddFeature(recv.FeatureDedup)
+	}
+	if err := recv.WriteMetadata(dst, out); err != nil {
+		return nil, fmt.Errorf("write metadata: %w", err)
+	}
+	return result, nil
+}
+
+// sameDir reports whether a and b name the same directory.
+func sameDir(a, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	return absA == absB, nil
+}
@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func TestGrepToCapture(t *testing.T) {
+	src := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var entries []recv.LogEntry
+	for i := range 20 {
+		msg := "line ok"
+		if i == 5 || i == 15 {
+			msg = "error: boom"
+		}
+		entries = append(entries, recv.LogEntry{Timestamp: base.Add(time.Duration(i) * time.Second), Labels: map[string]string{"app": "api"}, Message: msg})
+	}
+	writeMetadata(t, src, base, base.Add(20*time.Second), 20)
+	writeDataFile(t, src, "2024-01-15T100000-000.jsonl", entries)
+	writeIndex(t, src, []rotate.IndexEntry{{File: "2024-01-15T100000-000.jsonl", From: base, To: base.Add(19 * time.Second), Lines: 20}})
+
+	dst := filepath.Join(t.TempDir(), "errors")
+	filter := &Filter{Grep: regexp.MustCompile("error")}
+	result, err := GrepToCapture(src, dst, filter, GrepConfig{Context: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Matches != 2 || result.Context != 4 || result.Lines != 6 || result.Files != 1 {
+		t.Errorf("result = %+v", result)
+	}
+
+	// the output reads back as a capture
+	r, err := NewReader(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := r.Metadata()
+	if meta.TotalLines != 6 || !meta.Started.Equal(base.Add(4*time.Second)) || !meta.Stopped.Equal(base.Add(16*time.Second)) {
+		t.Errorf("metadata = %+v", meta)
+	}
+	if meta.Derived == nil || meta.Derived.Source != src || meta.Derived.Pattern != "error" || meta.Derived.Context != 1 {
+		t.Errorf("derived = %+v", meta.Derived)
+	}
+	if len(meta.LabelsSeen) != 1 || meta.LabelsSeen[0] != "app" {
+		t.Errorf("labels seen = %v", meta.LabelsSeen)
+	}
+	var got []string
+	if _, err := r.Scan(nil, func(e recv.LogEntry) bool {
+		got = append(got, e.Message)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 6 || got[1] != "error: boom" || got[4] != "error: boom" {
+		t.Errorf("entries = %v", got)
+	}
+	if files := r.Files(); len(files) != 1 || files[0].Index == nil || files[0].Index.SHA256 == "" {
+		t.Errorf("files = %+v", files)
+	}
+
+	// refuses a non-empty output and the source itself
+	if _, err := GrepToCapture(src, dst, filter, GrepConfig{}, nil); err == nil {
+		t.Error("wrote into a non-empty directory")
+	}
+	if _, err := GrepToCapture(src, src, filter, GrepConfig{}, nil); err == nil {
+		t.Error("wrote into the source capture")
+	}
+
+	// no matches leaves no output behind
+	empty := filepath.Join(t.TempDir(), "none")
+	if _, err := GrepToCapture(src, empty, &Filter{Grep: regexp.MustCompile("deadlock")}, GrepConfig{}, nil); err == nil {
+		t.Error("expected error for no matches")
+	}
+	if _, err := os.Stat(empty); !os.IsNotExist(err) {
+		t.Errorf("output left behind: %v", err)
+	}
+}
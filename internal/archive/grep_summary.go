@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// GrepSummary aggregates grep matches by label value and hour.
+type GrepSummary struct {
+	Matches int64                       `json:"matches"`
+	First   time.Time                   `json:"first,omitempty"`
+	Last    time.Time                   `json:"last,omitempty"`
+	Labels  map[string]map[string]int64 `json:"labels"`
+	Hours   []HourCount                 `json:"hours"`
+
+	hours map[time.Time]int64
+}
+
+// HourCount is the number of matches within one UTC hour.
+type HourCount struct {
+	Hour  time.Time `json:"hour"`
+	Count int64     `json:"count"`
+}
+
+// NewGrepSummary creates an empty summary.
+func NewGrepSummary() *GrepSummary {
+	return &GrepSummary{
+		Labels: make(map[string]map[string]int64),
+		hours:  make(map[time.Time]int64),
+	}
+}
+
+// Add records one matching entry, counting a deduplicated entry as the
+// lines it stands for.
+func (s *GrepSummary) Add(e recv.LogEntry) {
+	n := e.Count()
+	s.Matches += n
+	if s.First.IsZero() || e.Timestamp.Before(s.First) {
+		s.First = e.Timestamp
+	}
+	if e.Timestamp.After(s.Last) {
+		s.Last = e.Timestamp
+	}
+	for k, v := range e.Labels {
+		if s.Labels[k] == nil {
+			s.Labels[k] = make(map[string]int64)
+		}
+		s.Labels[k][v] += n
+	}
+	s.hours[e.Timestamp.UTC().Truncate(time.Hour)] += n
+}
+
+// Finish builds the chronological hour breakdown. Call once after the last Add.
+func (s *GrepSummary) Finish() {
+	s.Hours = make([]HourCount, 0, len(s.hours))
+	for h, n := range s.hours {
+		s.Hours = append(s.Hours, HourCount{Hour: h, Count: n})
+	}
+	sort.Slice(s.Hours, func(i, j int) bool {
+		return s.Hours[i].Hour.Before(s.Hours[j].Hour)
+	})
+}
+
+// TopValues returns the values of a label key ordered by match count, descending.
+func (s *GrepSummary) TopValues(key string) []LabelValueCount {
+	vals := s.Labels[key]
+	out := make([]LabelValueCount, 0, len(vals))
+	for v, n := range vals {
+		out = append(out, LabelValueCount{Value: v, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
+
+// LabelValueCount is a label value with its match count.
+type LabelValueCount struct {
+	Value string
+	Count int64
+}
@@ -0,0 +1,71 @@
+package archive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestGrepSummary(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	s := NewGrepSummary()
+	s.Add(recv.LogEntry{Timestamp: base.Add(time.Hour), Labels: map[string]string{"app": "api"}})
+	s.Add(recv.LogEntry{Timestamp: base, Labels: map[string]string{"app": "web"}})
+	s.Add(recv.LogEntry{Timestamp: base.Add(10 * time.Minute), Labels: map[string]string{"app": "api"}})
+	s.Finish()
+
+	if s.Matches != 3 {
+		t.Errorf("Matches = %d, want 3", s.Matches)
+	}
+	if !s.First.Equal(base) {
+		t.Errorf("First = %v, want %v", s.First, base)
+	}
+	if !s.Last.Equal(base.Add(time.Hour)) {
+		t.Errorf("Last = %v, want %v", s.Last, base.Add(time.Hour))
+	}
+	if s.Labels["app"]["api"] != 2 || s.Labels["app"]["web"] != 1 {
+		t.Errorf("Labels = %v", s.Labels)
+	}
+
+	if len(s.Hours) != 2 {
+		t.Fatalf("Hours len = %d, want 2", len(s.Hours))
+	}
+	if !s.Hours[0].Hour.Equal(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)) || s.Hours[0].Count != 2 {
+		t.Errorf("Hours[0] = %+v", s.Hours[0])
+	}
+	if s.Hours[1].Count != 1 {
+		t.Errorf("Hours[1] = %+v", s.Hours[1])
+	}
+
+	top := s.TopValues("app")
+	if len(top) != 2 || top[0].Value != "api" || top[0].Count != 2 {
+		t.Errorf("TopValues = %+v", top)
+	}
+}
+
+func TestGrepSummary_RepeatCount(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	s := NewGrepSummary()
+	s.Add(recv.LogEntry{Timestamp: base, Labels: map[string]string{"app": "api"}, RepeatCount: 5})
+	s.Add(recv.LogEntry{Timestamp: base.Add(time.Minute), Labels: map[string]string{"app": "web"}})
+	s.Finish()
+
+	if s.Matches != 6 {
+		t.Errorf("Matches = %d, want 6", s.Matches)
+	}
+	if s.Labels["app"]["api"] != 5 || s.Labels["app"]["web"] != 1 {
+		t.Errorf("Labels = %v", s.Labels)
+	}
+	if len(s.Hours) != 1 || s.Hours[0].Count != 6 {
+		t.Errorf("Hours = %+v, want one hour with 6", s.Hours)
+	}
+}
+
+func TestGrepSummary_Empty(t *testing.T) {
+	s := NewGrepSummary()
+	s.Finish()
+	if s.Matches != 0 || len(s.Hours) != 0 || !s.First.IsZero() {
+		t.Errorf("unexpected empty summary: %+v", s)
+	}
+}
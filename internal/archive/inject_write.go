@@ -107,7 +107,7 @@ func InjectWrite(src, dst string, filter *Filter, faults []FaultConfig) (*Inject
 
 	// write metadata
 	outMeta := &recv.Metadata{
-		Version:    meta.Version,
+		Version:    recv.MetadataVersion,
 		Format:     meta.Format,
 		Started:    minTS,
 		Stopped:    maxTS,
@@ -7,6 +7,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -54,13 +55,26 @@ func Inspect(dir string) (*Summary, error) {
 		return nil, fmt.Errorf("read metadata: %w", err)
 	}
 
-	index, err := readIndex(dir)
-	if err != nil && !os.IsNotExist(err) {
-		return nil, fmt.Errorf("read index: %w", err)
+	dirs := captureDirs(dir, meta)
+	var index []rotate.IndexEntry
+	var diskSize int64
+	var fileCount int
+	indexedFiles := make([]map[string]bool, len(dirs)) // per directory
+	for i, d := range dirs {
+		dirIndex, err := readIndex(d)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read index: %w", err)
+		}
+		index = append(index, dirIndex...)
+		indexedFiles[i] = make(map[string]bool, len(dirIndex))
+		for _, entry := range dirIndex {
+			indexedFiles[i][entry.File] = true
+		}
+		size, count := diskStats(d)
+		diskSize += size
+		fileCount += count
 	}
 
-	diskSize, fileCount := diskStats(dir)
-
 	s := &Summary{
 		Dir:      dir,
 		Meta:     meta,
@@ -71,10 +85,8 @@ func Inspect(dir string) (*Summary, error) {
 	// aggregate from index entries
 	labelAcc := make(map[string]map[string]*LabelVal) // key -> value -> accumulator
 	var minTime, maxTime time.Time
-	indexedFiles := make(map[string]bool, len(index))
 
 	for _, entry := range index {
-		indexedFiles[entry.File] = true
 		s.TotalLines += entry.Lines
 		s.TotalBytes += entry.Bytes
 
@@ -104,8 +116,11 @@ func Inspect(dir string) (*Summary, error) {
 	}
 
 	// count lines from orphan files not yet in index (including labels and time range)
-	orphans, oErr := discoverOrphans(dir, indexedFiles)
-	if oErr == nil {
+	for i, d := range dirs {
+		orphans, oErr := discoverOrphans(d, indexedFiles[i])
+		if oErr != nil {
+			continue
+		}
 		for _, orph := range orphans {
 			os := scanOrphanFile(orph.Path)
 			s.TotalLines += os.Lines
@@ -276,7 +291,7 @@ func diskStats(dir string) (totalSize int64, fileCount int) {
 		if name == "metadata.json" || name == "index.jsonl" || name == ".gitkeep" {
 			continue
 		}
-		if !strings.HasSuffix(name, ".jsonl") && !strings.HasSuffix(name, ".jsonl.zst") {
+		if !isDataFileName(strings.TrimSuffix(name, EncryptedSuffix)) {
 			continue
 		}
 		info, err := e.Info()
@@ -313,14 +328,14 @@ func scanFileStats(path string) (lines, bytes int64, labels map[string]map[strin
 
 // scanOrphanFile performs a full scan of an orphan data file.
 func scanOrphanFile(path string) orphanStats {
-	f, err := os.Open(path)
+	f, name, err := openDataFile(FileInfo{Path: path, Name: filepath.Base(path)})
 	if err != nil {
 		return orphanStats{}
 	}
 	defer func() { _ = f.Close() }()
 
 	var r io.Reader = f
-	if strings.HasSuffix(path, ".zst") {
+	if strings.HasSuffix(name, ".zst") {
 		dec, err := zstd.NewReader(f)
 		if err != nil {
 			return orphanStats{}
@@ -392,6 +407,33 @@ func (s *Summary) WriteText(w io.Writer) {
 	// header
 	tw.printf("Capture: %s\n", s.Dir)
 	tw.printf("Format:  %s (v%d)\n", s.Meta.Format, s.Meta.Version)
+	if len(s.Meta.Features) > 0 {
+		tw.printf("Uses:    %s\n", strings.Join(s.Meta.Features, ", "))
+	}
+	if unknown := s.Meta.UnknownFeatures(); len(unknown) > 0 {
+		tw.printf("Warning: capture uses features this release does not know (%s); results may be incomplete\n", strings.Join(unknown, ", "))
+	}
+	if s.Meta.Version > 0 && s.Meta.Version < recv.MetadataVersion {
+		tw.printf("Note:    metadata v%d; run logtap migrate to upgrade to v%d\n", s.Meta.Version, recv.MetadataVersion)
+	}
+	if s.Meta.Description != "" {
+		tw.printf("About:   %s\n", s.Meta.Description)
+	}
+	if s.Meta.Owner != "" {
+		tw.printf("Owner:   %s\n", s.Meta.Owner)
+	}
+	if len(s.Meta.Meta) > 0 {
+		keys := make([]string, 0, len(s.Meta.Meta))
+		for k := range s.Meta.Meta {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = k + "=" + s.Meta.Meta[k]
+		}
+		tw.printf("Meta:    %s\n", strings.Join(pairs, " "))
+	}
 
 	// period — prefer metadata, fall back to index data range
 	start, stop := s.effectivePeriod()
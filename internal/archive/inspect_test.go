@@ -147,6 +147,24 @@ func TestInspectSingleFile(t *testing.T) {
 	}
 }
 
+func TestInspectShards(t *testing.T) {
+	dir, _ := writeShardedCapture(t)
+
+	s, err := Inspect(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TotalLines != 9 {
+		t.Errorf("TotalLines = %d, want 9", s.TotalLines)
+	}
+	if s.Files != 3 {
+		t.Errorf("Files = %d, want 3", s.Files)
+	}
+	if len(s.Labels["app"]) != 2 || s.Labels["app"][0].Value != "web" {
+		t.Errorf("unexpected labels: %v", s.Labels)
+	}
+}
+
 func TestInspectTimelineBucketDistribution(t *testing.T) {
 	dir := t.TempDir()
 	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
@@ -357,6 +375,31 @@ func TestWriteText(t *testing.T) {
 	}
 }
 
+func TestWriteTextCaptureInfo(t *testing.T) {
+	s := &Summary{
+		Dir: "./capture",
+		Meta: &recv.Metadata{
+			Version:     1,
+			Format:      "jsonl",
+			Description: "checkout load test",
+			Owner:       "payments",
+			Meta:        map[string]string{"test-run": "1234", "commit": "abc123"},
+		},
+	}
+	var buf bytes.Buffer
+	s.WriteText(&buf)
+	out := buf.String()
+	for _, check := range []string{
+		"About:   checkout load test\n",
+		"Owner:   payments\n",
+		"Meta:    commit=abc123 test-run=1234\n",
+	} {
+		if !strings.Contains(out, check) {
+			t.Errorf("text output missing %q\noutput:\n%s", check, out)
+		}
+	}
+}
+
 func TestWriteTextPeriodFallback(t *testing.T) {
 	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
 	dataEnd := base.Add(3*time.Hour + 30*time.Minute)
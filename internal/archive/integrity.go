@@ -0,0 +1,292 @@
+package archive
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// QuarantineDir is the subdirectory of a capture that CheckIntegrity moves
+// damaged data files to.
+const QuarantineDir = "quarantine"
+
+// Segment integrity states.
+const (
+	SegmentOK         = "ok"
+	SegmentUnverified = "unverified" // no checksum recorded; the lines that could be checked are fine
+	SegmentTruncated  = "truncated"
+	SegmentCorrupted  = "corrupted"
+	SegmentMissing    = "missing"
+	SegmentOffloaded  = "offloaded" // stored in object storage only; not checked
+)
+
+// IntegrityOptions configures CheckIntegrity.
+type IntegrityOptions struct {
+	Quarantine bool // move truncated and corrupted files to QuarantineDir
+}
+
+// SegmentCheck is the result of checking one indexed data file.
+type SegmentCheck struct {
+	File           string `json:"file"` // relative to the capture directory
+	Status         string `json:"status"`
+	Detail         string `json:"detail,omitempty"`
+	ExpectedSHA256 string `json:"expected_sha256,omitempty"`
+	ActualSHA256   string `json:"actual_sha256,omitempty"`
+	ExpectedLines  int64  `json:"expected_lines"`
+	Lines          int64  `json:"lines"`
+	Quarantined    bool   `json:"quarantined,omitempty"`
+}
+
+// IntegrityResult summarizes the integrity check of a capture.
+type IntegrityResult struct {
+	Dir      string         `json:"dir"`
+	Valid    bool           `json:"valid"`
+	Checked  int            `json:"checked"`
+	Problems int            `json:"problems"`
+	Segments []SegmentCheck `json:"segments"`
+}
+
+// CheckIntegrity recomputes the SHA-256 and line count of every data file
+// listed in a capture's index and compares them with what the receiver
+// recorded. Files whose digest differs are reported as truncated when they
+// lost lines at the end and as corrupted otherwise; files indexed before
+// digests were recorded are checked by line count alone. Offloaded files
+// are not fetched. With Quarantine, damaged files are moved to
+// QuarantineDir together with their index entries, so later reads skip them.
+func CheckIntegrity(dir string, opts IntegrityOptions) (*IntegrityResult, error) {
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+	result := &IntegrityResult{Dir: dir, Valid: true}
+	for _, d := range captureDirs(dir, meta) {
+		if err := checkDirIntegrity(dir, d, opts, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func checkDirIntegrity(root, dir string, opts IntegrityOptions, result *IntegrityResult) error {
+	index, err := readIndex(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read index: %w", err)
+	}
+	offload, err := ReadOffloadManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	var keep, damaged []rotate.IndexEntry
+	for _, e := range index {
+		check := checkSegment(dir, e, offload.Files[e.File] != "")
+		if rel, err := filepath.Rel(root, filepath.Join(dir, e.File)); err == nil {
+			check.File = rel
+		}
+		if check.Status != SegmentOffloaded {
+			result.Checked++
+		}
+		switch check.Status {
+		case SegmentTruncated, SegmentCorrupted, SegmentMissing:
+			result.Problems++
+			result.Valid = false
+		}
+		if opts.Quarantine && (check.Status == SegmentTruncated || check.Status == SegmentCorrupted) {
+			damaged = append(damaged, e)
+			check.Quarantined = true
+		} else {
+			keep = append(keep, e)
+		}
+		result.Segments = append(result.Segments, check)
+	}
+	if len(damaged) == 0 {
+		return nil
+	}
+	if err := quarantine(dir, damaged); err != nil {
+		return fmt.Errorf("quarantine: %w", err)
+	}
+	if err := replaceIndexFile(dir, keep); err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	return nil
+}
+
+// checkSegment hashes a data file as stored and counts its lines.
+func checkSegment(dir string, e rotate.IndexEntry, offloaded bool) SegmentCheck {
+	check := SegmentCheck{File: e.File, ExpectedSHA256: e.SHA256, ExpectedLines: e.Lines}
+	f, err := os.Open(filepath.Join(dir, e.File))
+	if os.IsNotExist(err) {
+		if offloaded {
+			check.Status = SegmentOffloaded
+			return check
+		}
+		check.Status = SegmentMissing
+		return check
+	}
+	if err != nil {
+		check.Status = SegmentCorrupted
+		check.Detail = err.Error()
+		return check
+	}
+	defer func() { _ = f.Close() }()
+
+	sum := sha256.New()
+	stored := io.TeeReader(f, sum)
+	lines, partial, readErr := countSegmentLines(stored, e.File)
+	if _, err := io.Copy(io.Discard, stored); err != nil && readErr == nil {
+		readErr = err
+	}
+	check.ActualSHA256 = hex.EncodeToString(sum.Sum(nil))
+	check.Lines = lines
+
+	noKey := errors.Is(readErr, errNoKey)
+	lost := partial || errors.Is(readErr, io.ErrUnexpectedEOF) ||
+		(readErr == nil && e.Lines > 0 && lines < e.Lines)
+	switch {
+	case e.SHA256 != "" && e.SHA256 == check.ActualSHA256:
+		check.Status = SegmentOK
+	case noKey && e.SHA256 == "":
+		check.Status = SegmentUnverified
+		check.Detail = "no checksum recorded, " + errNoKey.Error()
+	case e.SHA256 != "" || (readErr != nil && !noKey) || lost || (e.Lines > 0 && lines > e.Lines):
+		check.Status = SegmentCorrupted
+		if lost {
+			check.Status = SegmentTruncated
+		}
+		check.Detail = segmentDetail(e, check, partial, readErr)
+	default:
+		check.Status = SegmentUnverified
+		check.Detail = "no checksum recorded"
+	}
+	return check
+}
+
+// errNoKey marks encrypted files that cannot be read without a key; their
+// digest can still be checked.
+var errNoKey = errors.New("encrypted, no key to count lines")
+
+// countSegmentLines counts the complete lines of a data file read from r,
+// reporting whether it ends in a partial line.
+func countSegmentLines(r io.Reader, name string) (lines int64, partial bool, err error) {
+	plainName, encrypted := strings.CutSuffix(name, EncryptedSuffix)
+	if encrypted {
+		key, keyErr := decryptionKey()
+		if keyErr != nil {
+			return 0, false, errNoKey
+		}
+		if r, err = NewDecryptReader(r, key); err != nil {
+			return 0, false, err
+		}
+	}
+	if strings.HasSuffix(plainName, ".zst") {
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return 0, false, err
+		}
+		defer dec.Close()
+		r = dec
+	}
+	br := bufio.NewReaderSize(r, 256*1024)
+	for {
+		line, err := br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue // the rest of a long line follows
+		}
+		if err == io.EOF {
+			return lines, len(line) > 0, nil
+		}
+		if err != nil {
+			return lines, false, err
+		}
+		lines++
+	}
+}
+
+func segmentDetail(e rotate.IndexEntry, check SegmentCheck, partial bool, readErr error) string {
+	var parts []string
+	if e.SHA256 != "" && e.SHA256 != check.ActualSHA256 {
+		parts = append(parts, "checksum mismatch")
+	}
+	if readErr != nil {
+		parts = append(parts, readErr.Error())
+	}
+	if partial {
+		parts = append(parts, "partial last line")
+	}
+	if e.Lines > 0 && check.Lines != e.Lines && !errors.Is(readErr, errNoKey) {
+		parts = append(parts, fmt.Sprintf("%d of %d lines", check.Lines, e.Lines))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// quarantine moves damaged data files into QuarantineDir and appends their
+// index entries to its own index, keeping the recorded digests as evidence.
+func quarantine(dir string, entries []rotate.IndexEntry) error {
+	qdir := filepath.Join(dir, QuarantineDir)
+	if err := os.MkdirAll(qdir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(qdir, "index.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	for _, e := range entries {
+		if err := os.Rename(filepath.Join(dir, e.File), filepath.Join(qdir, e.File)); err != nil {
+			return err
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(f, "%s\n", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the result as indented JSON.
+func (r *IntegrityResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a human-readable summary listing the files that are not ok.
+func (r *IntegrityResult) WriteText(w io.Writer) {
+	tw := &textWriter{w: w}
+	for _, s := range r.Segments {
+		if s.Status == SegmentOK {
+			continue
+		}
+		line := fmt.Sprintf("  %-10s %s", strings.ToUpper(s.Status), s.File)
+		if s.Detail != "" {
+			line += "  (" + s.Detail + ")"
+		}
+		if s.Quarantined {
+			line += "  -> " + QuarantineDir + "/"
+		}
+		tw.printf("%s\n", line)
+	}
+	if r.Valid {
+		tw.printf("OK: %d files verified in %s\n", r.Checked, r.Dir)
+		return
+	}
+	tw.printf("FAILED: %d of %d files damaged or missing in %s\n", r.Problems, r.Checked, r.Dir)
+}
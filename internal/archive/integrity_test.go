@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func TestCheckIntegrityClean(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir := writeRotatedCapture(t, 30, rotate.Config{Compress: compress}, "web")
+		result, err := CheckIntegrity(dir, IntegrityOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Valid || result.Checked == 0 || result.Checked != len(result.Segments) {
+			t.Fatalf("compress=%v: result = %+v", compress, result)
+		}
+		for _, s := range result.Segments {
+			if s.Status != SegmentOK || s.ExpectedSHA256 == "" || s.Lines != s.ExpectedLines {
+				t.Errorf("compress=%v: segment %+v", compress, s)
+			}
+		}
+	}
+}
+
+func TestCheckIntegrityDamaged(t *testing.T) {
+	dir := writeRotatedCapture(t, 30, rotate.Config{}, "web")
+	index, _ := readIndex(dir)
+	if len(index) < 4 {
+		t.Fatalf("expected at least 4 files, got %d", len(index))
+	}
+
+	// truncated: cut in the middle of the last line
+	truncated := filepath.Join(dir, index[0].File)
+	data, _ := os.ReadFile(truncated)
+	if err := os.WriteFile(truncated, data[:len(data)-5], 0o640); err != nil {
+		t.Fatal(err)
+	}
+	// corrupted: same size, one byte flipped
+	corrupted := filepath.Join(dir, index[1].File)
+	data, _ = os.ReadFile(corrupted)
+	data[10] ^= 0x20
+	if err := os.WriteFile(corrupted, data, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	// missing
+	if err := os.Remove(filepath.Join(dir, index[2].File)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := CheckIntegrity(dir, IntegrityOptions{Quarantine: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		index[0].File: SegmentTruncated,
+		index[1].File: SegmentCorrupted,
+		index[2].File: SegmentMissing,
+		index[3].File: SegmentOK,
+	}
+	for _, s := range result.Segments {
+		if w, ok := want[s.File]; ok && s.Status != w {
+			t.Errorf("%s: status %s (%s), want %s", s.File, s.Status, s.Detail, w)
+		}
+	}
+	if result.Valid || result.Problems != 3 {
+		t.Errorf("valid = %v, problems = %d", result.Valid, result.Problems)
+	}
+
+	// damaged files are moved aside with their index entries
+	for _, name := range []string{index[0].File, index[1].File} {
+		if _, err := os.Stat(filepath.Join(dir, QuarantineDir, name)); err != nil {
+			t.Errorf("%s not quarantined: %v", name, err)
+		}
+	}
+	after, _ := readIndex(dir)
+	if len(after) != len(index)-2 {
+		t.Errorf("index has %d entries, want %d", len(after), len(index)-2)
+	}
+	qindex, _ := readIndex(filepath.Join(dir, QuarantineDir))
+	if len(qindex) != 2 || qindex[0].SHA256 != index[0].SHA256 {
+		t.Errorf("quarantine index = %+v", qindex)
+	}
+
+	var out strings.Builder
+	result.WriteText(&out)
+	if !strings.Contains(out.String(), "TRUNCATED") || !strings.Contains(out.String(), "FAILED: 3 of") {
+		t.Errorf("text output:\n%s", out.String())
+	}
+}
+
+func TestCheckIntegrityWithoutChecksums(t *testing.T) {
+	dir := writeRotatedCapture(t, 20, rotate.Config{}, "web")
+	index, _ := readIndex(dir)
+	for i := range index {
+		index[i].SHA256 = "" // as indexed by older releases
+	}
+	if err := replaceIndexFile(dir, index); err != nil {
+		t.Fatal(err)
+	}
+	last := filepath.Join(dir, index[len(index)-1].File)
+	data, _ := os.ReadFile(last)
+	if err := os.WriteFile(last, data[:len(data)-3], 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := CheckIntegrity(dir, IntegrityOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range result.Segments {
+		want := SegmentUnverified
+		if i == len(result.Segments)-1 {
+			want = SegmentTruncated
+		}
+		if s.Status != want {
+			t.Errorf("%s: status %s, want %s", s.File, s.Status, want)
+		}
+	}
+}
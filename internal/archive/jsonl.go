@@ -3,6 +3,7 @@ package archive
 import (
 	"bufio"
 	"encoding/json"
+	"io"
 	"os"
 
 	"github.com/ppiankov/logtap/internal/recv"
@@ -14,8 +15,8 @@ type jsonlWriter struct {
 	enc  *json.Encoder
 }
 
-func newJSONLWriter(path string) (*jsonlWriter, error) {
-	f, err := os.Create(path)
+func newJSONLWriter(path string, offset int64) (*jsonlWriter, error) {
+	f, err := createExportFile(path, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -31,6 +32,13 @@ func (w *jsonlWriter) Write(e recv.LogEntry) error {
 	return w.enc.Encode(e)
 }
 
+func (w *jsonlWriter) Flush() (int64, error) {
+	if err := w.buf.Flush(); err != nil {
+		return 0, err
+	}
+	return w.file.Seek(0, io.SeekCurrent)
+}
+
 func (w *jsonlWriter) Close() error {
 	if err := w.buf.Flush(); err != nil {
 		_ = w.file.Close()
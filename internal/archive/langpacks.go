@@ -0,0 +1,151 @@
+package archive
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// languagePack extends IsError and NormalizeMessage for a language,
+// framework or log format whose error lines do not carry the English
+// keywords the default heuristic looks for.
+type languagePack struct {
+	name        string
+	description string
+	keywords    []string         // lower case, matched like errorKeywords
+	patterns    []*regexp.Regexp // matched against the original message
+	normalizers []normalizer     // applied before the default normalizers
+	// classify, if set, decides lines it recognizes on its own, overriding
+	// keywords, e.g. an access log line whose path contains "error".
+	classify func(msg string) (isError, decided bool)
+}
+
+// nginxAccessRe matches the request and status of an nginx (or Apache)
+// combined format access log line.
+var nginxAccessRe = regexp.MustCompile(`"(?:[A-Z]+ \S+(?: HTTP/[\d.]+)?|-)" (\d{3}) `)
+
+var languagePacks = map[string]*languagePack{
+	"java": {
+		name:        "java",
+		description: "Java and Spring: stack frames, Caused by, Throwable, SEVERE",
+		keywords:    []string{"caused by:", "throwable", "severe"},
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s+at [\w$.<>]+\(`),
+			regexp.MustCompile(`^\s*\.\.\. \d+ (?:more|common frames omitted)`),
+		},
+		normalizers: []normalizer{
+			{regexp.MustCompile(`@[0-9a-f]{6,}\b`), "@<HEX>"},
+			{regexp.MustCompile(`\.java:\d+\)`), ".java:<N>)"},
+			{regexp.MustCompile(`\[(?:nio|http-nio|exec|pool|task|scheduling)-[\w-]*?\d+(?:-\w+-\d+)?\]`), "[<THREAD>]"},
+		},
+	},
+	"go": {
+		name:        "go",
+		description: "Go: klog/glog E and F lines, goroutine dumps, runtime stack frames",
+		keywords:    []string{"goroutine ", "runtime error", "sigsegv"},
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`^[EF]\d{4} \d{2}:\d{2}:\d{2}`),
+			regexp.MustCompile(`\.go:\d+ \+0x[0-9a-f]+`),
+		},
+		normalizers: []normalizer{
+			{regexp.MustCompile(`^([EFIW])\d{4} \d{2}:\d{2}:\d{2}\.\d+\s+\d+`), "$1<TS> <PID>"},
+			{regexp.MustCompile(`goroutine \d+`), "goroutine <N>"},
+			{regexp.MustCompile(`\.go:\d+`), ".go:<N>"},
+		},
+	},
+	"python": {
+		name:        "python",
+		description: "Python: tracebacks, CRITICAL, exception class lines",
+		keywords:    []string{"traceback (most recent call last)", "critical"},
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*File "[^"]+", line \d+`),
+			regexp.MustCompile(`^(?:[\w.]+\.)?[A-Z]\w*(?:Error|Exception|Interrupt|Exit): `),
+		},
+		normalizers: []normalizer{
+			{regexp.MustCompile(`, line \d+`), ", line <N>"},
+			{regexp.MustCompile(` at 0x[0-9a-f]+>`), " at <HEX>>"},
+		},
+	},
+	"nginx": {
+		name:        "nginx",
+		description: "nginx: 5xx access log lines, [crit]/[alert]/[emerg] error log lines",
+		keywords:    []string{"[crit]", "[alert]", "[emerg]", "upstream prematurely closed", "no live upstreams"},
+		normalizers: []normalizer{
+			{regexp.MustCompile(`\[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\]`), "[<TS>]"},
+			{regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}`), "<TS>"},
+			{regexp.MustCompile(`(\S)\?[^\s"]*`), "$1?<Q>"},
+			{regexp.MustCompile(`/\d+([/?\s"]|$)`), "/<N>$1"}, // path IDs, not HTTP/1.1
+			{regexp.MustCompile(`(\d+)#\d+: \*\d+`), "<PID>#<N>: *<N>"},
+		},
+		classify: func(msg string) (bool, bool) {
+			m := nginxAccessRe.FindStringSubmatch(msg)
+			if m == nil {
+				return false, false
+			}
+			return m[1][0] == '5', true
+		},
+	},
+}
+
+// activePacks holds the packs selected with SetLanguagePacks.
+var activePacks atomic.Pointer[[]*languagePack]
+
+// LanguagePackNames returns the names of the built-in language packs.
+func LanguagePackNames() []string {
+	names := make([]string, 0, len(languagePacks))
+	for name := range languagePacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LanguagePackDescription returns a one-line description of a pack, or ""
+// for an unknown name.
+func LanguagePackDescription(name string) string {
+	if p := languagePacks[name]; p != nil {
+		return p.description
+	}
+	return ""
+}
+
+// SetLanguagePacks selects the language packs IsError and NormalizeMessage
+// use in addition to the default heuristics. Names are case-insensitive;
+// an empty list restores the defaults. The selection is unchanged when a
+// name is unknown.
+func SetLanguagePacks(names []string) error {
+	var packs []*languagePack
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		p := languagePacks[name]
+		if p == nil {
+			return fmt.Errorf("unknown language pack %q (available: %s)", name, strings.Join(LanguagePackNames(), ", "))
+		}
+		seen[name] = true
+		packs = append(packs, p)
+	}
+	activePacks.Store(&packs)
+	return nil
+}
+
+// ActiveLanguagePacks returns the names of the selected language packs.
+func ActiveLanguagePacks() []string {
+	var names []string
+	for _, p := range currentPacks() {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+func currentPacks() []*languagePack {
+	if p := activePacks.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+)
+
+func withLanguagePacks(t *testing.T, names ...string) {
+	t.Helper()
+	if err := SetLanguagePacks(names); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetLanguagePacks(nil) })
+}
+
+func TestLanguagePacksIsError(t *testing.T) {
+	tests := []struct {
+		pack string
+		msg  string
+		want bool
+	}{
+		{"java", "\tat com.example.OrderService.place(OrderService.java:42)", true},
+		{"java", "Caused by: java.net.SocketTimeoutException: Read timed out", true},
+		{"java", "\t... 23 common frames omitted", true},
+		{"java", "SEVERE: Servlet.service() for servlet [dispatcher] threw", true},
+		{"java", "Started Application in 3.2 seconds", false},
+		{"go", "E0115 10:32:01.123456       1 reflector.go:138] watch of *v1.Pod ended", true},
+		{"go", "I0115 10:32:01.123456       1 leaderelection.go:248] attempting to acquire lease", false},
+		{"go", "\t/app/server.go:88 +0x1a5", true},
+		{"go", "goroutine 17 [running]:", true},
+		{"python", "Traceback (most recent call last):", true},
+		{"python", `  File "/app/worker.py", line 12, in run`, true},
+		{"python", "KeyError: 'user_id'", true},
+		{"python", "CRITICAL:root:disk full", true},
+		{"python", "Processed 12 jobs", false},
+		{"nginx", `10.0.0.1 - - [15/Jan/2024:10:32:01 +0000] "GET /api/orders HTTP/1.1" 502 157 "-" "curl/8.0"`, true},
+		{"nginx", `10.0.0.1 - - [15/Jan/2024:10:32:01 +0000] "GET /api/errors HTTP/1.1" 200 12 "-" "curl/8.0"`, false},
+		{"nginx", "2024/01/15 10:32:01 [crit] 7#7: *1 open() failed", true},
+		{"nginx", "2024/01/15 10:32:01 [emerg] 1#1: bind() to 0.0.0.0:80", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pack+"/"+tt.msg, func(t *testing.T) {
+			withLanguagePacks(t, tt.pack)
+			if got := IsError(tt.msg); got != tt.want {
+				t.Errorf("IsError = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLanguagePacksOff(t *testing.T) {
+	withLanguagePacks(t)
+	for _, msg := range []string{
+		"\tat com.example.OrderService.place(OrderService.java:42)",
+		"E0115 10:32:01.123456       1 reflector.go:138] watch ended",
+		"Traceback (most recent call last):",
+	} {
+		if IsError(msg) {
+			t.Errorf("IsError(%q) = true without packs", msg)
+		}
+	}
+	// without the nginx pack, keyword matching applies to access lines
+	if !IsError(`"GET /api/errors HTTP/1.1" 200 12`) {
+		t.Error("expected keyword match on access line without packs")
+	}
+}
+
+func TestLanguagePacksNormalize(t *testing.T) {
+	tests := []struct {
+		pack, msg, want string
+	}{
+		{"java", "lock held by com.example.Cache@1a2b3c4d at Cache.get(Cache.java:87)", "lock held by com.example.Cache@<HEX> at Cache.get(Cache.java:<N>)"},
+		{"go", "E0115 10:32:01.123456       1 reflector.go:138] watch ended", "E<TS> <PID> reflector.go:<N>] watch ended"},
+		{"python", `  File "/app/worker.py", line 12, in run`, `  File "/app/worker.py", line <N>, in run`},
+		{"nginx", `[15/Jan/2024:10:32:01 +0000] "GET /api/orders/81?page=2 HTTP/1.1" 502`, `[<TS>] "GET /api/orders/<N>?<Q> HTTP/1.1" 502`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pack, func(t *testing.T) {
+			withLanguagePacks(t, tt.pack)
+			if got := NormalizeMessage(tt.msg); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetLanguagePacks(t *testing.T) {
+	withLanguagePacks(t, "Java", " go ", "java")
+	if got := strings.Join(ActiveLanguagePacks(), ","); got != "java,go" {
+		t.Errorf("active = %q", got)
+	}
+
+	err := SetLanguagePacks([]string{"python", "cobol"})
+	if err == nil || !strings.Contains(err.Error(), `"cobol"`) {
+		t.Fatalf("expected unknown pack error, got %v", err)
+	}
+	if got := strings.Join(ActiveLanguagePacks(), ","); got != "java,go" {
+		t.Errorf("active after error = %q", got)
+	}
+
+	for _, name := range LanguagePackNames() {
+		if LanguagePackDescription(name) == "" {
+			t.Errorf("pack %s has no description", name)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package archive
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+const (
+	// latencyGamma sets the sketch's bucket growth: values are kept to
+	// within 1% of their true size.
+	latencyGamma = 1.02
+
+	// maxLatencyGroups caps the label values tracked; lines of further
+	// values are counted under latencyOtherGroup.
+	maxLatencyGroups  = 200
+	latencyOtherGroup = "(other)"
+)
+
+// LatencyConfig selects the request duration triage and report extract from
+// log lines. Exactly one of Field and Pattern is set.
+type LatencyConfig struct {
+	Field   string         // JSON field (top-level key or dotted path) holding the duration
+	Pattern *regexp.Regexp // its first capture group, or the whole match, holds the duration
+	Unit    time.Duration  // of durations without a unit (default time.Millisecond)
+	GroupBy string         // label key percentiles are split by (default "app")
+}
+
+// LatencyReport holds duration percentiles per label value, overall and per
+// minute. Durations are in milliseconds.
+type LatencyReport struct {
+	Source    string         `json:"source"` // field or pattern the durations came from
+	GroupBy   string         `json:"group_by"`
+	Samples   int64          `json:"samples"`
+	Unparsed  int64          `json:"unparsed,omitempty"` // matched lines without a readable duration
+	Groups    []LatencyGroup `json:"groups"`             // most samples first
+	Truncated bool           `json:"truncated,omitempty"`
+}
+
+// LatencyPercentiles summarizes a set of durations in milliseconds.
+type LatencyPercentiles struct {
+	Samples int64   `json:"samples"`
+	P50     float64 `json:"p50_ms"`
+	P95     float64 `json:"p95_ms"`
+	P99     float64 `json:"p99_ms"`
+	Max     float64 `json:"max_ms"`
+}
+
+// LatencyGroup is the latency of one label value; Value is "" for lines
+// without the label.
+type LatencyGroup struct {
+	Value string `json:"value"`
+	LatencyPercentiles
+	Minutes []LatencyMinute `json:"minutes"`
+}
+
+// LatencyMinute is the latency of one group in one minute.
+type LatencyMinute struct {
+	Time time.Time `json:"time"`
+	LatencyPercentiles
+}
+
+// ParseLatency reads a duration: a Go duration such as "350ms" or "1.2s",
+// or a bare number in unit.
+func ParseLatency(s string, unit time.Duration) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(f * float64(unit)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// extract returns the duration in msg, whether msg carried one at all, and
+// whether it could be read.
+func (c *LatencyConfig) extract(msg string) (d time.Duration, found, ok bool) {
+	var raw string
+	if c.Pattern != nil {
+		m := c.Pattern.FindStringSubmatch(msg)
+		if m == nil {
+			return 0, false, false
+		}
+		raw = m[0]
+		if len(m) > 1 {
+			raw = m[1]
+		}
+	} else {
+		v, present := rotate.ExtractFields(msg, []string{c.Field})[c.Field]
+		if !present {
+			return 0, false, false
+		}
+		raw = v
+	}
+	d, err := ParseLatency(raw, c.Unit)
+	return d, true, err == nil
+}
+
+func (c *LatencyConfig) source() string {
+	if c.Pattern != nil {
+		return "pattern " + c.Pattern.String()
+	}
+	return "field " + c.Field
+}
+
+// latencySketch is a log-bucketed histogram of milliseconds whose
+// quantiles are within 1% of the exact ones, at a memory cost bounded by
+// the range of values rather than their number.
+type latencySketch struct {
+	buckets map[int]int64
+	zero    int64
+	count   int64
+	max     float64
+}
+
+func newLatencySketch() *latencySketch {
+	return &latencySketch{buckets: make(map[int]int64)}
+}
+
+var latencyLogGamma = math.Log(latencyGamma)
+
+func (s *latencySketch) add(ms float64, n int64) {
+	s.count += n
+	s.max = math.Max(s.max, ms)
+	if ms < 1e-6 {
+		s.zero += n
+		return
+	}
+	s.buckets[int(math.Ceil(math.Log(ms)/latencyLogGamma))] += n
+}
+
+// quantile returns the nearest-rank q-quantile.
+func (s *latencySketch) quantile(q float64) float64 {
+	rank := int64(math.Ceil(q * float64(s.count)))
+	if rank <= s.zero {
+		return 0
+	}
+	idx := make([]int, 0, len(s.buckets))
+	for i := range s.buckets {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	seen := s.zero
+	for _, i := range idx {
+		seen += s.buckets[i]
+		if seen >= rank {
+			// the bucket's midpoint, never above the largest value seen
+			v := 2 * math.Pow(latencyGamma, float64(i)) / (latencyGamma + 1)
+			return math.Min(v, s.max)
+		}
+	}
+	return s.max
+}
+
+func (s *latencySketch) percentiles() LatencyPercentiles {
+	round := func(v float64) float64 { return math.Round(v*1000) / 1000 }
+	return LatencyPercentiles{
+		Samples: s.count,
+		P50:     round(s.quantile(0.50)),
+		P95:     round(s.quantile(0.95)),
+		P99:     round(s.quantile(0.99)),
+		Max:     round(s.max),
+	}
+}
+
+type latencyGroupAccum struct {
+	total   *latencySketch
+	minutes map[int64]*latencySketch
+}
+
+// ExtractLatency reads the durations cfg selects from every line of the
+// capture read by r and returns their percentiles per cfg.GroupBy value,
+// overall and per minute. It returns nil when no line carried a duration.
+func ExtractLatency(r *Reader, cfg LatencyConfig) (*LatencyReport, error) {
+	if cfg.Unit <= 0 {
+		cfg.Unit = time.Millisecond
+	}
+	if cfg.GroupBy == "" {
+		cfg.GroupBy = "app"
+	}
+
+	rep := &LatencyReport{Source: cfg.source(), GroupBy: cfg.GroupBy}
+	groups := make(map[string]*latencyGroupAccum)
+	if _, err := r.Scan(nil, func(e recv.LogEntry) bool {
+		if recv.IsAnnotation(e.Labels) {
+			return true
+		}
+		d, found, ok := cfg.extract(e.Message)
+		if !found {
+			return true
+		}
+		n := e.Count()
+		if !ok {
+			rep.Unparsed += n
+			return true
+		}
+		value := e.Labels[cfg.GroupBy]
+		g := groups[value]
+		if g == nil {
+			if len(groups) >= maxLatencyGroups {
+				value = latencyOtherGroup
+				rep.Truncated = true
+				g = groups[value]
+			}
+			if g == nil {
+				g = &latencyGroupAccum{total: newLatencySketch(), minutes: make(map[int64]*latencySketch)}
+				groups[value] = g
+			}
+		}
+		ms := float64(d) / float64(time.Millisecond)
+		g.total.add(ms, n)
+		minute := e.Timestamp.Truncate(time.Minute).Unix()
+		s := g.minutes[minute]
+		if s == nil {
+			s = newLatencySketch()
+			g.minutes[minute] = s
+		}
+		s.add(ms, n)
+		rep.Samples += n
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	if rep.Samples == 0 && rep.Unparsed == 0 {
+		return nil, nil
+	}
+
+	for value, g := range groups {
+		lg := LatencyGroup{Value: value, LatencyPercentiles: g.total.percentiles()}
+		for minute, s := range g.minutes {
+			lg.Minutes = append(lg.Minutes, LatencyMinute{Time: time.Unix(minute, 0).UTC(), LatencyPercentiles: s.percentiles()})
+		}
+		sort.Slice(lg.Minutes, func(i, j int) bool { return lg.Minutes[i].Time.Before(lg.Minutes[j].Time) })
+		rep.Groups = append(rep.Groups, lg)
+	}
+	sort.Slice(rep.Groups, func(i, j int) bool {
+		if rep.Groups[i].Samples != rep.Groups[j].Samples {
+			return rep.Groups[i].Samples > rep.Groups[j].Samples
+		}
+		return rep.Groups[i].Value < rep.Groups[j].Value
+	})
+	return rep, nil
+}
+
+// groupName is the group's label value, or a placeholder for lines
+// without the label.
+func (g LatencyGroup) groupName() string {
+	if g.Value == "" {
+		return "(none)"
+	}
+	return g.Value
+}
+
+// WriteText writes a table of the overall percentiles of each group.
+func (l *LatencyReport) WriteText(w io.Writer) {
+	tw := &textWriter{w: w}
+	tw.printf("  %-24s %10s %10s %10s %10s %10s\n", l.GroupBy, "samples", "p50 ms", "p95 ms", "p99 ms", "max ms")
+	for _, g := range l.Groups {
+		tw.printf("  %-24s %10s %10.1f %10.1f %10.1f %10.1f\n",
+			g.groupName(), FormatCount(g.Samples), g.P50, g.P95, g.P99, g.Max)
+	}
+	if l.Unparsed > 0 {
+		tw.printf("  %s lines with an unreadable duration skipped\n", FormatCount(l.Unparsed))
+	}
+}
+
+// buildHTMLLatency formats the overall percentiles of each group for the
+// triage and report HTML.
+func buildHTMLLatency(l *LatencyReport) []htmlLatency {
+	rows := make([]htmlLatency, 0, len(l.Groups))
+	for _, g := range l.Groups {
+		rows = append(rows, htmlLatency{
+			Value:   g.groupName(),
+			Samples: FormatCount(g.Samples),
+			P50:     fmt.Sprintf("%.1f", g.P50),
+			P95:     fmt.Sprintf("%.1f", g.P95),
+			P99:     fmt.Sprintf("%.1f", g.P99),
+			Max:     fmt.Sprintf("%.1f", g.Max),
+		})
+	}
+	return rows
+}
+
+// WriteCSV writes the per-minute percentiles:
+// minute,<group_by>,samples,p50_ms,p95_ms,p99_ms,max_ms.
+func (l *LatencyReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"minute", l.GroupBy, "samples", "p50_ms", "p95_ms", "p99_ms", "max_ms"})
+	for _, g := range l.Groups {
+		for _, m := range g.Minutes {
+			_ = cw.Write([]string{
+				m.Time.Format(time.RFC3339),
+				g.Value,
+				strconv.FormatInt(m.Samples, 10),
+				strconv.FormatFloat(m.P50, 'f', -1, 64),
+				strconv.FormatFloat(m.P95, 'f', -1, 64),
+				strconv.FormatFloat(m.P99, 'f', -1, 64),
+				strconv.FormatFloat(m.Max, 'f', -1, 64),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,233 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// writeLatencyCapture writes two minutes of access lines: api answers in
+// 1–100ms, worker in 500ms with one 5s outlier, and a few lines without or
+// with an unreadable duration.
+func writeLatencyCapture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	api := map[string]string{"app": "api"}
+	worker := map[string]string{"app": "worker"}
+	var entries []recv.LogEntry
+	for m := 0; m < 2; m++ {
+		ts := base.Add(time.Duration(m) * time.Minute)
+		for i := 1; i <= 100; i++ {
+			entries = append(entries, recv.LogEntry{Timestamp: ts, Labels: api, Message: fmt.Sprintf(`{"msg":"GET /items","http":{"duration_ms":%d}}`, i)})
+		}
+		entries = append(entries, recv.LogEntry{Timestamp: ts, Labels: worker, Message: `{"msg":"job done","http":{"duration_ms":"500ms"}}`, RepeatCount: 9})
+	}
+	entries = append(entries,
+		recv.LogEntry{Timestamp: base.Add(time.Minute), Labels: worker, Message: `{"msg":"job done","http":{"duration_ms":"5s"}}`},
+		recv.LogEntry{Timestamp: base, Labels: api, Message: `{"msg":"cache refreshed"}`},
+		recv.LogEntry{Timestamp: base, Labels: api, Message: `{"msg":"GET /","http":{"duration_ms":"n/a"}}`},
+		recv.LogEntry{
+			Timestamp: base,
+			Labels:    map[string]string{recv.AnnotationLabel: "true"},
+			Message:   `{"msg":"annotation","http":{"duration_ms":99999}}`,
+		})
+	var lines int64
+	for _, e := range entries {
+		lines += e.Count()
+	}
+	end := base.Add(2 * time.Minute)
+	writeMetadata(t, dir, base, end, lines)
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", entries)
+	writeIndex(t, dir, []rotate.IndexEntry{{File: "2024-01-15T100000-000.jsonl", From: base, To: end, Lines: lines}})
+	return dir
+}
+
+func TestParseLatency(t *testing.T) {
+	tests := []struct {
+		in   string
+		unit time.Duration
+		want time.Duration
+	}{
+		{"120", time.Millisecond, 120 * time.Millisecond},
+		{"0.25", time.Second, 250 * time.Millisecond},
+		{" 1500us ", time.Millisecond, 1500 * time.Microsecond},
+		{"1.2s", time.Millisecond, 1200 * time.Millisecond},
+		{"0", time.Millisecond, 0},
+	}
+	for _, tt := range tests {
+		got, err := ParseLatency(tt.in, tt.unit)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLatency(%q, %v) = %v, %v, want %v", tt.in, tt.unit, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "fast", "-5", "-1s", "NaN"} {
+		if _, err := ParseLatency(in, time.Millisecond); err == nil {
+			t.Errorf("ParseLatency(%q) accepted", in)
+		}
+	}
+}
+
+func TestLatencyConfigExtract(t *testing.T) {
+	re := &LatencyConfig{Pattern: regexp.MustCompile(`took (\d+)ms`), Unit: time.Millisecond}
+	if d, found, ok := re.extract("GET / took 42ms"); !found || !ok || d != 42*time.Millisecond {
+		t.Errorf("capture group: %v %v %v", d, found, ok)
+	}
+	if _, found, _ := re.extract("GET /"); found {
+		t.Error("line without a match found")
+	}
+
+	whole := &LatencyConfig{Pattern: regexp.MustCompile(`\d+s\b`), Unit: time.Millisecond}
+	if d, _, ok := whole.extract("request finished in 3s"); !ok || d != 3*time.Second {
+		t.Errorf("whole match: %v %v", d, ok)
+	}
+
+	field := &LatencyConfig{Field: "latency", Unit: time.Second}
+	if d, _, ok := field.extract(`{"latency":0.5}`); !ok || d != 500*time.Millisecond {
+		t.Errorf("field: %v %v", d, ok)
+	}
+	if _, found, ok := field.extract(`{"latency":"slow"}`); !found || ok {
+		t.Errorf("unreadable field: found=%v ok=%v", found, ok)
+	}
+	if _, found, _ := field.extract("plain text"); found {
+		t.Error("non-JSON line found")
+	}
+}
+
+func TestLatencySketchAccuracy(t *testing.T) {
+	s := newLatencySketch()
+	for i := 1; i <= 10000; i++ {
+		s.add(float64(i)/10, 1)
+	}
+	p := s.percentiles()
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"p50", p.P50, 500},
+		{"p95", p.P95, 950},
+		{"p99", p.P99, 990},
+	} {
+		if math.Abs(c.got-c.want)/c.want > 0.01 {
+			t.Errorf("%s = %v, want %v within 1%%", c.name, c.got, c.want)
+		}
+	}
+	if p.Max != 1000 || p.Samples != 10000 {
+		t.Errorf("percentiles = %+v", p)
+	}
+
+	zero := newLatencySketch()
+	zero.add(0, 3)
+	zero.add(10, 1)
+	if got := zero.quantile(0.5); got != 0 {
+		t.Errorf("p50 of mostly zero = %v", got)
+	}
+}
+
+func TestExtractLatency(t *testing.T) {
+	r, err := NewReader(writeLatencyCapture(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, err := ExtractLatency(r, LatencyConfig{Field: "http.duration_ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Samples != 219 || rep.Unparsed != 1 || rep.GroupBy != "app" || rep.Source != "field http.duration_ms" {
+		t.Fatalf("report = %+v", rep)
+	}
+	if len(rep.Groups) != 2 || rep.Groups[0].Value != "api" || rep.Groups[1].Value != "worker" {
+		t.Fatalf("groups = %+v", rep.Groups)
+	}
+
+	api := rep.Groups[0]
+	if api.Samples != 200 || len(api.Minutes) != 2 || math.Abs(api.P95-95) > 1 || api.Max != 100 {
+		t.Errorf("api = %+v", api.LatencyPercentiles)
+	}
+	worker := rep.Groups[1]
+	if math.Abs(worker.P50-500) > 5 || worker.Max != 5000 {
+		t.Errorf("worker = %+v", worker.LatencyPercentiles)
+	}
+	if m := worker.Minutes[1]; m.Samples != 10 || m.P99 != 5000 {
+		t.Errorf("worker minute 2 = %+v", m)
+	}
+
+	var buf bytes.Buffer
+	if err := rep.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(records[0], ","); got != "minute,app,samples,p50_ms,p95_ms,p99_ms,max_ms" {
+		t.Errorf("header = %s", got)
+	}
+	if len(records) != 5 || records[1][0] != "2024-01-15T10:00:00Z" || records[1][1] != "api" || records[1][2] != "100" {
+		t.Errorf("records = %v", records)
+	}
+
+	// no line carries the field
+	if rep, err := ExtractLatency(r, LatencyConfig{Field: "elapsed"}); err != nil || rep != nil {
+		t.Errorf("missing field: %+v, %v", rep, err)
+	}
+}
+
+func TestTriageLatency(t *testing.T) {
+	dir := writeLatencyCapture(t)
+	result, err := Triage(dir, TriageConfig{Jobs: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Latency != nil {
+		t.Errorf("latency without a config: %+v", result.Latency)
+	}
+
+	cfg := &LatencyConfig{Pattern: regexp.MustCompile(`"duration_ms":"?(\d+)`), GroupBy: "app"}
+	result, err = Triage(dir, TriageConfig{Jobs: 1, Latency: cfg}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Latency == nil || result.Latency.Samples != 219 {
+		t.Fatalf("latency = %+v", result.Latency)
+	}
+
+	var buf bytes.Buffer
+	result.WriteSummary(&buf)
+	for _, want := range []string{"## Latency (pattern ", "by app)", "p95 ms", "api"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("summary missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := result.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<h2>Latency (") {
+		t.Error("HTML missing latency section")
+	}
+
+	rep, err := Report(dir, ReportConfig{Jobs: 1, Latency: cfg}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Triage.Latency == nil {
+		t.Fatal("report missing latency")
+	}
+	buf.Reset()
+	if err := rep.WriteMarkdownSummary(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "- **Latency:** api p50 ") {
+		t.Errorf("markdown summary missing latency:\n%s", buf.String())
+	}
+}
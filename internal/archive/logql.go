@@ -0,0 +1,734 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// LogQLQuery is a query in the LogQL subset logtap evaluates over captures.
+// A log query is a selector with a pipeline:
+//
+//	{app="web"} |= "error" | json | status >= 500
+//
+// A metric query applies a range aggregation (rate, count_over_time,
+// bytes_rate, bytes_over_time) to a log query, optionally wrapped in vector
+// aggregations (sum, avg, min, max, count) with by or without:
+//
+//	sum by (app) (rate({namespace="prod"} |= "timeout" [1m]))
+type LogQLQuery struct {
+	Expr   string
+	Log    *LogPipeline // the log query, or the one the metric query reads
+	metric *metricNode  // nil for log queries
+}
+
+// LogPipeline is a log selector followed by parser and label filter stages.
+type LogPipeline struct {
+	Selector *LogSelector
+	stages   []pipelineStage
+}
+
+// pipelineStage is one "| ..." stage: a parser (json, logfmt) or a label
+// filter.
+type pipelineStage struct {
+	parser string
+	filter *labelFilter
+}
+
+// labelFilter compares a label, possibly extracted by a parser. Numeric
+// and duration comparisons fail for values that do not parse.
+type labelFilter struct {
+	key   string
+	op    string // = != =~ !~ for strings, == != > >= < <= for numbers and durations
+	value string
+	num   float64
+	dur   bool // num is a duration in seconds
+	kind  byte // 's' string, 'n' number
+	re    *regexp.Regexp
+}
+
+// metricNode is a range aggregation over a log pipeline or a vector
+// aggregation over another metric node.
+type metricNode struct {
+	fn      string        // range or vector aggregation
+	rng     time.Duration // range aggregations
+	inner   *metricNode   // vector aggregations
+	by      []string
+	without bool
+}
+
+var (
+	logqlRangeFuncs  = map[string]bool{"rate": true, "count_over_time": true, "bytes_rate": true, "bytes_over_time": true}
+	logqlVectorFuncs = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+)
+
+// ParseLogQL parses a log or metric query.
+func ParseLogQL(s string) (*LogQLQuery, error) {
+	p := &queryParser{s: s}
+	q := &LogQLQuery{Expr: s}
+	p.skipSpace()
+	if !p.eof() && p.s[p.pos] != '{' && p.s[p.pos] != '|' && p.s[p.pos] != '!' {
+		m, log, err := p.metric()
+		if err != nil {
+			return nil, err
+		}
+		q.metric, q.Log = m, log
+	} else {
+		log, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		q.Log = log
+	}
+	if p.skipSpace(); !p.eof() {
+		return nil, p.errorf("unexpected %q", p.rest())
+	}
+	return q, nil
+}
+
+// IsMetric reports whether the query returns series instead of entries.
+func (q *LogQLQuery) IsMetric() bool { return q.metric != nil }
+
+func (p *queryParser) metric() (*metricNode, *LogPipeline, error) {
+	fn, err := p.ident()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case logqlRangeFuncs[fn]:
+		if err := p.expect("("); err != nil {
+			return nil, nil, err
+		}
+		log, err := p.pipeline()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := p.expect("["); err != nil {
+			return nil, nil, err
+		}
+		end := strings.IndexByte(p.rest(), ']')
+		if end < 0 {
+			return nil, nil, p.errorf("expected \"]\"")
+		}
+		rng, err := time.ParseDuration(strings.TrimSpace(p.rest()[:end]))
+		if err != nil || rng <= 0 {
+			return nil, nil, p.errorf("invalid range %q", p.rest()[:end])
+		}
+		p.pos += end + 1
+		if err := p.expect(")"); err != nil {
+			return nil, nil, err
+		}
+		return &metricNode{fn: fn, rng: rng}, log, nil
+
+	case logqlVectorFuncs[fn]:
+		m := &metricNode{fn: fn}
+		if err := p.grouping(m); err != nil {
+			return nil, nil, err
+		}
+		if err := p.expect("("); err != nil {
+			return nil, nil, err
+		}
+		inner, log, err := p.metric()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, nil, err
+		}
+		if m.by == nil && !m.without {
+			if err := p.grouping(m); err != nil {
+				return nil, nil, err
+			}
+		}
+		m.inner = inner
+		return m, log, nil
+	}
+	return nil, nil, p.errorf("unknown function %q (expected a range aggregation such as rate or count_over_time, or sum, avg, min, max, count)", fn)
+}
+
+// grouping parses an optional "by (a, b)" or "without (a, b)" clause.
+func (p *queryParser) grouping(m *metricNode) error {
+	start := p.pos
+	word, err := p.ident()
+	if err != nil || (word != "by" && word != "without") {
+		p.pos = start
+		return nil
+	}
+	m.without = word == "without"
+	m.by = []string{}
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for !p.accept(")") {
+		if len(m.by) > 0 {
+			if err := p.expect(","); err != nil {
+				return err
+			}
+		}
+		key, err := p.ident()
+		if err != nil {
+			return err
+		}
+		m.by = append(m.by, key)
+	}
+	return nil
+}
+
+// pipeline parses a selector, line filters and "| stage" stages.
+func (p *queryParser) pipeline() (*LogPipeline, error) {
+	sel, err := p.selector()
+	if err != nil {
+		return nil, err
+	}
+	lp := &LogPipeline{Selector: sel}
+	for {
+		p.skipSpace()
+		if !strings.HasPrefix(p.rest(), "|") || strings.HasPrefix(p.rest(), "|=") || strings.HasPrefix(p.rest(), "|~") {
+			// line filters may follow stages; they see the unchanged line
+			more, err := p.selector()
+			if err != nil {
+				return nil, err
+			}
+			if len(more.Labels) > 0 {
+				return nil, p.errorf("unexpected selector")
+			}
+			if len(more.Lines) == 0 {
+				return lp, nil
+			}
+			sel.Lines = append(sel.Lines, more.Lines...)
+			continue
+		}
+		p.pos++
+		stage, err := p.stage()
+		if err != nil {
+			return nil, err
+		}
+		lp.stages = append(lp.stages, stage...)
+	}
+}
+
+// stage parses what follows "|": json, logfmt, or label filters joined by
+// "and" or ",".
+func (p *queryParser) stage() ([]pipelineStage, error) {
+	word, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	switch word {
+	case "json", "logfmt":
+		return []pipelineStage{{parser: word}}, nil
+	case "line_format", "label_format", "unwrap", "pattern", "regexp", "drop", "keep", "decolorize", "unpack":
+		return nil, p.errorf("pipeline stage %q is not supported", word)
+	}
+
+	var stages []pipelineStage
+	key := word
+	for {
+		f, err := p.labelFilter(key)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, pipelineStage{filter: f})
+		p.skipSpace()
+		start := p.pos
+		if !p.accept(",") {
+			if w, err := p.ident(); err != nil || w != "and" {
+				p.pos = start
+				return stages, nil
+			}
+		}
+		if key, err = p.ident(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *queryParser) labelFilter(key string) (*labelFilter, error) {
+	op, ok := p.oneOf("==", "=~", "!~", "!=", ">=", "<=", "=", ">", "<")
+	if !ok {
+		return nil, p.errorf("expected label filter operator after %s", key)
+	}
+	f := &labelFilter{key: key, op: op}
+	var err error
+	p.skipSpace()
+	if !p.eof() && (p.s[p.pos] == '"' || p.s[p.pos] == '`') {
+		if op != "=" && op != "!=" && op != "=~" && op != "!~" {
+			return nil, p.errorf("%s compares numbers or durations, not strings", op)
+		}
+		if f.value, err = p.str(); err != nil {
+			return nil, err
+		}
+		f.kind = 's'
+		if op == "=~" || op == "!~" {
+			if f.re, err = regexp.Compile("^(?:" + f.value + ")$"); err != nil {
+				return nil, p.errorf("invalid regex for %s: %v", key, err)
+			}
+		}
+		return f, nil
+	}
+	if op == "=~" || op == "!~" {
+		return nil, p.errorf("%s needs a quoted regex", op)
+	}
+	start := p.pos
+	for !p.eof() && (unicode.IsLetter(rune(p.s[p.pos])) || unicode.IsDigit(rune(p.s[p.pos])) || strings.IndexByte(".+-", p.s[p.pos]) >= 0) {
+		p.pos++
+	}
+	lit := p.s[start:p.pos]
+	f.kind = 'n'
+	if op == "=" {
+		f.op = "=="
+	}
+	if n, err := strconv.ParseFloat(lit, 64); err == nil {
+		f.num = n
+		return f, nil
+	}
+	if d, err := time.ParseDuration(lit); err == nil {
+		f.num, f.dur = d.Seconds(), true
+		return f, nil
+	}
+	p.pos = start
+	return nil, p.errorf("expected quoted string, number or duration")
+}
+
+// Process runs e through the pipeline and returns its labels, with those
+// the parsers extracted, and whether it passes.
+func (lp *LogPipeline) Process(e recv.LogEntry) (map[string]string, bool) {
+	if !lp.Selector.Match(e) {
+		return nil, false
+	}
+	if len(lp.stages) == 0 {
+		return e.Labels, true
+	}
+	labels := make(map[string]string, len(e.Labels)+4)
+	for k, v := range e.Labels {
+		labels[k] = v
+	}
+	for _, st := range lp.stages {
+		switch st.parser {
+		case "json":
+			extractJSON(e.Message, e.Labels, labels)
+		case "logfmt":
+			extractLogfmt(e.Message, e.Labels, labels)
+		default:
+			if !st.filter.match(labels[st.filter.key]) {
+				return nil, false
+			}
+		}
+	}
+	return labels, true
+}
+
+func (f *labelFilter) match(v string) bool {
+	if f.kind == 's' {
+		switch f.op {
+		case "=":
+			return v == f.value
+		case "!=":
+			return v != f.value
+		case "=~":
+			return f.re.MatchString(v)
+		default:
+			return !f.re.MatchString(v)
+		}
+	}
+	var n float64
+	if f.dur {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return false
+		}
+		n = d.Seconds()
+	} else {
+		var err error
+		if n, err = strconv.ParseFloat(v, 64); err != nil {
+			return false
+		}
+	}
+	switch f.op {
+	case "==":
+		return n == f.num
+	case "!=":
+		return n != f.num
+	case ">":
+		return n > f.num
+	case ">=":
+		return n >= f.num
+	case "<":
+		return n < f.num
+	default:
+		return n <= f.num
+	}
+}
+
+// logqlErrorLabel marks entries a parser stage could not parse, as in Loki.
+const logqlErrorLabel = "__error__"
+
+// setExtracted adds an extracted label; names clashing with a stream label
+// get the suffix _extracted, as in Loki.
+func setExtracted(stream, labels map[string]string, key, value string) {
+	key = sanitizeLabelKey(key)
+	if key == "" {
+		return
+	}
+	if _, ok := stream[key]; ok {
+		key += "_extracted"
+	}
+	labels[key] = value
+}
+
+func sanitizeLabelKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, k)
+}
+
+// extractJSON adds the fields of a JSON object message; nested objects are
+// flattened with "_" between keys and arrays are skipped.
+func extractJSON(msg string, stream, labels map[string]string) {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(msg), &obj); err != nil {
+		labels[logqlErrorLabel] = "JSONParserErr"
+		return
+	}
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			switch x := v.(type) {
+			case map[string]any:
+				walk(prefix+k+"_", x)
+			case string:
+				setExtracted(stream, labels, prefix+k, x)
+			case float64:
+				setExtracted(stream, labels, prefix+k, strconv.FormatFloat(x, 'f', -1, 64))
+			case bool:
+				setExtracted(stream, labels, prefix+k, strconv.FormatBool(x))
+			case nil:
+				setExtracted(stream, labels, prefix+k, "")
+			}
+		}
+	}
+	walk("", obj)
+}
+
+// extractLogfmt adds the key=value pairs of a logfmt message; values may be
+// double-quoted and bare keys get an empty value.
+func extractLogfmt(msg string, stream, labels map[string]string) {
+	for i := 0; i < len(msg); {
+		for i < len(msg) && msg[i] == ' ' {
+			i++
+		}
+		start := i
+		for i < len(msg) && msg[i] != '=' && msg[i] != ' ' {
+			i++
+		}
+		key := msg[start:i]
+		if i >= len(msg) || msg[i] != '=' {
+			if key != "" {
+				setExtracted(stream, labels, key, "")
+			}
+			continue
+		}
+		i++
+		var value string
+		if i < len(msg) && msg[i] == '"' {
+			end := i + 1
+			for end < len(msg) && msg[end] != '"' {
+				if msg[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(msg) {
+				labels[logqlErrorLabel] = "LogfmtParserErr"
+				return
+			}
+			v, err := strconv.Unquote(msg[i : end+1])
+			if err != nil {
+				v = msg[i+1 : end]
+			}
+			value, i = v, end+1
+		} else {
+			vs := i
+			for i < len(msg) && msg[i] != ' ' {
+				i++
+			}
+			value = msg[vs:i]
+		}
+		if key != "" {
+			setExtracted(stream, labels, key, value)
+		}
+	}
+}
+
+// filter derives the file and entry filter the index can answer: the time
+// range, label equalities and one line filter.
+func (lp *LogPipeline) filter(from, to time.Time) *Filter {
+	f := &Filter{From: from, To: to}
+	for _, m := range lp.Selector.Labels {
+		if m.Op == LabelEqual && m.Value != "" {
+			f.Labels = append(f.Labels, LabelMatcher{Key: m.Key, Value: m.Value})
+		}
+	}
+	for _, l := range lp.Selector.Lines {
+		if l.Op == LineContains {
+			f.Grep = regexp.MustCompile(regexp.QuoteMeta(l.Value))
+			break
+		}
+		if l.Op == LineRegex {
+			f.Grep = l.re
+			break
+		}
+	}
+	return f
+}
+
+// LogQLOptions bound the evaluation of a query.
+type LogQLOptions struct {
+	From, To time.Time     // zero = the capture's first and last entry
+	Step     time.Duration // metric queries: resolution (default the range); the range must be a multiple
+}
+
+// RunLog evaluates a log query, calling fn with each passing entry, with
+// extracted labels added, until it returns false. It returns the number of
+// lines scanned.
+func (q *LogQLQuery) RunLog(src string, opts LogQLOptions, fn func(recv.LogEntry) bool) (int64, error) {
+	if q.IsMetric() {
+		return 0, fmt.Errorf("%s is a metric query", q.Expr)
+	}
+	reader, err := NewReader(src)
+	if err != nil {
+		return 0, fmt.Errorf("open source: %w", err)
+	}
+	return reader.Scan(q.Log.filter(opts.From, opts.To), func(e recv.LogEntry) bool {
+		labels, ok := q.Log.Process(e)
+		if !ok {
+			return true
+		}
+		e.Labels = labels
+		return fn(e)
+	})
+}
+
+// MetricSeries is one series of a metric query result.
+type MetricSeries struct {
+	Labels map[string]string `json:"metric"`
+	Points []MetricPoint     `json:"values"`
+}
+
+// MetricPoint is a sample of a series.
+type MetricPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// MarshalJSON encodes a point as Loki does: [unix seconds, "value"].
+func (p MetricPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{p.Time.Unix(), strconv.FormatFloat(p.Value, 'f', -1, 64)})
+}
+
+// RunMetric evaluates a metric query. Entries are counted in buckets of
+// Step aligned to the Unix epoch; each point at time t covers (t-range, t].
+// Points are produced from the first to the last bucket holding entries,
+// and series without entries in a point's range have no sample for it.
+func (q *LogQLQuery) RunMetric(src string, opts LogQLOptions) ([]MetricSeries, int64, error) {
+	if !q.IsMetric() {
+		return nil, 0, fmt.Errorf("%s is a log query; metric queries apply rate, count_over_time, bytes_rate or bytes_over_time", q.Expr)
+	}
+	leaf := q.metric
+	for leaf.inner != nil {
+		leaf = leaf.inner
+	}
+	step := opts.Step
+	if step <= 0 {
+		step = leaf.rng
+	}
+	if leaf.rng%step != 0 {
+		return nil, 0, fmt.Errorf("range %s is not a multiple of step %s", leaf.rng, step)
+	}
+	reader, err := NewReader(src)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open source: %w", err)
+	}
+	reader.SetExpandRepeats(true)
+
+	type rawSeries struct {
+		labels  map[string]string
+		buckets map[int64]float64 // bucket end (unix ns) -> lines or bytes
+	}
+	series := make(map[string]*rawSeries)
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	bytesFn := leaf.fn == "bytes_rate" || leaf.fn == "bytes_over_time"
+	scanned, err := reader.Scan(q.Log.filter(opts.From, opts.To), func(e recv.LogEntry) bool {
+		labels, ok := q.Log.Process(e)
+		if !ok {
+			return true
+		}
+		key := labelsKey(labels)
+		s := series[key]
+		if s == nil {
+			s = &rawSeries{labels: labels, buckets: make(map[int64]float64)}
+			series[key] = s
+		}
+		end := bucketEnd(e.Timestamp, step)
+		if bytesFn {
+			s.buckets[end] += float64(len(e.Message))
+		} else {
+			s.buckets[end]++
+		}
+		first, last = min(first, end), max(last, end)
+		return true
+	})
+	if err != nil {
+		return nil, scanned, err
+	}
+	if len(series) == 0 {
+		return []MetricSeries{}, scanned, nil
+	}
+
+	n := int64(leaf.rng / step)
+	var out []MetricSeries
+	for _, s := range series {
+		ms := MetricSeries{Labels: s.labels}
+		for t := first; t <= last+int64(leaf.rng)-int64(step); t += int64(step) {
+			var sum float64
+			seen := false
+			for i := int64(0); i < n; i++ {
+				if v, ok := s.buckets[t-i*int64(step)]; ok {
+					sum += v
+					seen = true
+				}
+			}
+			if !seen {
+				continue
+			}
+			if leaf.fn == "rate" || leaf.fn == "bytes_rate" {
+				sum /= leaf.rng.Seconds()
+			}
+			ms.Points = append(ms.Points, MetricPoint{Time: time.Unix(0, t).UTC(), Value: sum})
+		}
+		out = append(out, ms)
+	}
+
+	var aggs []*metricNode
+	for m := q.metric; m.inner != nil; m = m.inner {
+		aggs = append(aggs, m)
+	}
+	for i := len(aggs) - 1; i >= 0; i-- {
+		out = aggregateSeries(aggs[i], out)
+	}
+	sort.Slice(out, func(i, j int) bool { return labelsKey(out[i].Labels) < labelsKey(out[j].Labels) })
+	return out, scanned, nil
+}
+
+// bucketEnd returns the end of the step bucket holding t; buckets are
+// (end-step, end].
+func bucketEnd(t time.Time, step time.Duration) int64 {
+	ns := t.UnixNano()
+	end := ns - ns%int64(step)
+	if end < ns {
+		end += int64(step)
+	}
+	return end
+}
+
+// aggregateSeries applies a vector aggregation at every point in time.
+func aggregateSeries(m *metricNode, in []MetricSeries) []MetricSeries {
+	type group struct {
+		labels map[string]string
+		values map[int64][]float64
+	}
+	groups := make(map[string]*group)
+	for _, s := range in {
+		labels := make(map[string]string)
+		for k, v := range s.Labels {
+			keep := false
+			for _, b := range m.by {
+				if b == k {
+					keep = true
+				}
+			}
+			if keep != m.without {
+				labels[k] = v
+			}
+		}
+		key := labelsKey(labels)
+		g := groups[key]
+		if g == nil {
+			g = &group{labels: labels, values: make(map[int64][]float64)}
+			groups[key] = g
+		}
+		for _, p := range s.Points {
+			g.values[p.Time.UnixNano()] = append(g.values[p.Time.UnixNano()], p.Value)
+		}
+	}
+
+	out := make([]MetricSeries, 0, len(groups))
+	for _, g := range groups {
+		times := make([]int64, 0, len(g.values))
+		for t := range g.values {
+			times = append(times, t)
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+		ms := MetricSeries{Labels: g.labels}
+		for _, t := range times {
+			vs := g.values[t]
+			v := vs[0]
+			switch m.fn {
+			case "sum", "avg":
+				v = 0
+				for _, x := range vs {
+					v += x
+				}
+				if m.fn == "avg" {
+					v /= float64(len(vs))
+				}
+			case "min":
+				for _, x := range vs {
+					v = math.Min(v, x)
+				}
+			case "max":
+				for _, x := range vs {
+					v = math.Max(v, x)
+				}
+			case "count":
+				v = float64(len(vs))
+			}
+			ms.Points = append(ms.Points, MetricPoint{Time: time.Unix(0, t).UTC(), Value: v})
+		}
+		out = append(out, ms)
+	}
+	return out
+}
+
+// labelsKey formats labels as a selector, {a="1", b="2"}, sorted by key.
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(k + "=" + strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// FormatLabels formats labels as a LogQL selector.
+func FormatLabels(labels map[string]string) string { return labelsKey(labels) }
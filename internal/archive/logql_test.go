@@ -0,0 +1,137 @@
+package archive
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func setupLogQLSource(t *testing.T) (string, time.Time) {
+	t.Helper()
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	entries := []recv.LogEntry{
+		{Timestamp: base.Add(10 * time.Second), Labels: map[string]string{"app": "web", "pod": "web-0"}, Message: `{"status":200,"path":"/","latency":"12ms"}`},
+		{Timestamp: base.Add(20 * time.Second), Labels: map[string]string{"app": "web", "pod": "web-1"}, Message: `{"status":503,"path":"/pay","latency":"1.5s","err":{"code":"E1"}}`},
+		{Timestamp: base.Add(70 * time.Second), Labels: map[string]string{"app": "web", "pod": "web-0"}, Message: `{"status":500,"path":"/pay","latency":"900ms","app":"shadow"}`},
+		{Timestamp: base.Add(80 * time.Second), Labels: map[string]string{"app": "api", "pod": "api-0"}, Message: `level=error msg="db timeout" took=2s`},
+		{Timestamp: base.Add(130 * time.Second), Labels: map[string]string{"app": "api", "pod": "api-0"}, Message: `level=info msg="ok" took=5ms`},
+	}
+	writeMetadata(t, dir, base, base.Add(130*time.Second), int64(len(entries)))
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", entries)
+	writeIndex(t, dir, []rotate.IndexEntry{{File: "2024-01-15T100000-000.jsonl", From: base, To: base.Add(130 * time.Second), Lines: int64(len(entries))}})
+	return dir, base
+}
+
+func TestLogQLLogQuery(t *testing.T) {
+	src, _ := setupLogQLSource(t)
+	tests := []struct {
+		query string
+		want  string // messages' pods, or extracted labels, joined by ","
+		label string
+	}{
+		{`{app="web"}`, "web-0,web-1,web-0", "pod"},
+		{`{app="web"} |= "pay" | json | status >= 500`, "503,500", "status"},
+		{`{app="web"} | json | err_code="E1"`, "web-1", "pod"},
+		{`{app="web"} | json | app_extracted="shadow"`, "web-0", "pod"},
+		{`{app="web"} | json | latency > 1s`, "1.5s", "latency"},
+		{`{app="web"} | json | status == 200 or path="/pay"`, "", ""}, // or is not a stage keyword
+		{`{app="api"} | logfmt | level="error", took >= 1s`, "db timeout", "msg"},
+		{`{app="api"} | json | __error__="JSONParserErr"`, "api-0,api-0", "pod"},
+		{`{pod=~"web-.*"} != "503" | json | path = "/pay"`, "500", "status"},
+	}
+	for _, tt := range tests {
+		q, err := ParseLogQL(tt.query)
+		if tt.label == "" {
+			if err == nil {
+				t.Errorf("ParseLogQL(%q): expected error", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ParseLogQL(%q): %v", tt.query, err)
+		}
+		var got []string
+		if _, err := q.RunLog(src, LogQLOptions{}, func(e recv.LogEntry) bool {
+			got = append(got, e.Labels[tt.label])
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if g := strings.Join(got, ","); g != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.query, g, tt.want)
+		}
+	}
+}
+
+func TestLogQLMetricQuery(t *testing.T) {
+	src, _ := setupLogQLSource(t)
+	format := func(series []MetricSeries) string {
+		var parts []string
+		for _, s := range series {
+			var pts []string
+			for _, p := range s.Points {
+				pts = append(pts, fmt.Sprintf("%s=%g", p.Time.Format("15:04"), p.Value))
+			}
+			parts = append(parts, FormatLabels(s.Labels)+" "+strings.Join(pts, " "))
+		}
+		return strings.Join(parts, "; ")
+	}
+	tests := []struct {
+		query string
+		step  time.Duration
+		want  string
+	}{
+		{`sum by (app) (count_over_time({} [1m]))`, 0, `{app="api"} 10:02=1 10:03=1; {app="web"} 10:01=2 10:02=1`},
+		{`sum(count_over_time({app="web"} | json | status >= 500 [2m]))`, time.Minute, `{} 10:01=1 10:02=2 10:03=1`},
+		{`count_over_time({app="api"} [1m])`, 0, `{app="api", pod="api-0"} 10:02=1 10:03=1`},
+		{`sum without (pod) (rate({app="web"}[1m]))`, 0, `{app="web"} 10:01=0.03333333333333333 10:02=0.016666666666666666`},
+		{`count(count_over_time({app="web"}[1m])) by (app)`, 0, `{app="web"} 10:01=2 10:02=1`},
+		{`max(bytes_over_time({app="api"}[5m]))`, 0, `{} 10:05=64`},
+	}
+	for _, tt := range tests {
+		q, err := ParseLogQL(tt.query)
+		if err != nil {
+			t.Fatalf("ParseLogQL(%q): %v", tt.query, err)
+		}
+		series, _, err := q.RunMetric(src, LogQLOptions{Step: tt.step})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := format(series); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+
+	q, _ := ParseLogQL(`rate({}[1m])`)
+	if _, _, err := q.RunMetric(src, LogQLOptions{Step: 25 * time.Second}); err == nil {
+		t.Error("range not a multiple of step: expected error")
+	}
+}
+
+func TestParseLogQLErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`rate({app="web"})`, `expected "["`},
+		{`rate({app="web"}[soon])`, "invalid range"},
+		{`topk(3, rate({}[1m]))`, "unknown function"},
+		{`{app="web"} | line_format "{{.msg}}"`, "not supported"},
+		{`{app="web"} | status >= "500"`, "not strings"},
+		{`{app="web"} | status >= high`, "expected quoted string, number or duration"},
+		{`{app="web"} | status =~ 5`, "needs a quoted regex"},
+		{`sum by (app (rate({}[1m]))`, `expected ","`},
+		{`{app="web"} {pod="x"}`, "unexpected selector"},
+	}
+	for _, tt := range tests {
+		_, err := ParseLogQL(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseLogQL(%q) error = %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// Loki export defaults.
+const (
+	DefaultLokiBatchLines = 1000
+	DefaultLokiBatchBytes = 512 << 10 // well under the pusher's 1 MB body limit
+
+	lokiDefaultJob = "logtap"
+)
+
+// LokiPushFunc sends entries of one stream, all carrying labels, to Loki.
+// Entries keep their original timestamps.
+type LokiPushFunc func(ctx context.Context, labels map[string]string, entries []recv.LogEntry) error
+
+// LokiExportOptions holds settings for ExportLoki.
+type LokiExportOptions struct {
+	Target     string   // Loki address, part of the checkpoint fingerprint
+	Tenant     string   // Loki tenant, part of the checkpoint fingerprint
+	Checkpoint string   // checkpoint path; empty disables checkpointing
+	Resume     bool     // skip files completed by an earlier run
+	BatchLines int      // max lines per push (0 = DefaultLokiBatchLines)
+	BatchBytes int      // max message bytes per push (0 = DefaultLokiBatchBytes)
+	Rate       float64  // max lines pushed per second (0 = unlimited)
+	Profile    *Profile // per-file read profile (nil = off)
+}
+
+// ExportLoki replays the filtered entries of src into Loki through push,
+// batched per label set. Label names are rewritten to Loki's
+// [a-zA-Z_][a-zA-Z0-9_]* form, and entries without labels get
+// job="logtap". A checkpoint records each completed file, so a failed
+// replay can be resumed; the file in flight is pushed again, which Loki
+// drops as duplicates. Returns the number of lines pushed.
+func ExportLoki(ctx context.Context, src string, filter *Filter, push LokiPushFunc, progress func(ExportProgress), opts LokiExportOptions) (int64, error) {
+	if opts.BatchLines <= 0 {
+		opts.BatchLines = DefaultLokiBatchLines
+	}
+	if opts.BatchBytes <= 0 {
+		opts.BatchBytes = DefaultLokiBatchBytes
+	}
+
+	params := fmt.Sprintf("src=%s loki=%s tenant=%q %s", src, opts.Target, opts.Tenant, filterFingerprint(filter))
+	return exportBatches(ctx, src, filter, progress, batchExport{
+		dest:       "loki",
+		params:     params,
+		checkpoint: opts.Checkpoint,
+		resume:     opts.Resume,
+		batchLines: opts.BatchLines,
+		batchBytes: opts.BatchBytes,
+		rate:       opts.Rate,
+		profile:    opts.Profile,
+		group: func(e recv.LogEntry) (string, map[string]string) {
+			labels := lokiLabels(e.Labels)
+			return flattenLabels(labels), labels
+		},
+		push: func(ctx context.Context, b *exportBatch) error {
+			return push(ctx, b.labels, b.entries)
+		},
+	})
+}
+
+// lokiLabels rewrites labels for Loki: invalid name characters become
+// underscores, empty values are dropped and an empty set gets
+// job="logtap". On a name collision the first key in sorted order wins.
+func lokiLabels(labels map[string]string) map[string]string {
+	keys := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	out := make(map[string]string, len(keys))
+	for _, k := range keys {
+		name := lokiLabelName(k)
+		if _, ok := out[name]; !ok {
+			out[name] = labels[k]
+		}
+	}
+	if len(out) == 0 {
+		out["job"] = lokiDefaultJob
+	}
+	return out
+}
+
+func lokiLabelName(k string) string {
+	b := []byte(k)
+	for i, c := range b {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
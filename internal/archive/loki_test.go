@@ -0,0 +1,137 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+type lokiPush struct {
+	labels  string
+	entries []recv.LogEntry
+}
+
+// writeLokiCapture writes two files of five lines each, alternating
+// between two pods.
+func writeLokiCapture(t *testing.T) (dir string, base time.Time) {
+	t.Helper()
+	dir = t.TempDir()
+	base = time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var index []rotate.IndexEntry
+	for f := 0; f < 2; f++ {
+		var entries []recv.LogEntry
+		for i := 0; i < 5; i++ {
+			n := f*5 + i
+			entries = append(entries, recv.LogEntry{
+				Timestamp: base.Add(time.Duration(n)*time.Second + 123456789),
+				Labels:    map[string]string{"app.kubernetes.io/name": "api", "pod": fmt.Sprintf("api-%d", n%2)},
+				Message:   fmt.Sprintf("line %d", n),
+			})
+		}
+		name := fmt.Sprintf("data-%d.jsonl", f)
+		writeDataFile(t, dir, name, entries)
+		index = append(index, rotate.IndexEntry{File: name, From: entries[0].Timestamp, To: entries[4].Timestamp, Lines: 5})
+	}
+	writeMetadata(t, dir, base, base.Add(10*time.Second), 10)
+	writeIndex(t, dir, index)
+	return dir, base
+}
+
+func TestExportLoki(t *testing.T) {
+	dir, base := writeLokiCapture(t)
+	var pushes []lokiPush
+	push := func(_ context.Context, labels map[string]string, entries []recv.LogEntry) error {
+		pushes = append(pushes, lokiPush{flattenLabels(labels), entries})
+		return nil
+	}
+
+	n, err := ExportLoki(context.Background(), dir, nil, push, nil, LokiExportOptions{BatchLines: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Errorf("pushed %d lines, want 10", n)
+	}
+	var lines int
+	for _, p := range pushes {
+		if p.labels != "app_kubernetes_io_name=api;pod=api-0" && p.labels != "app_kubernetes_io_name=api;pod=api-1" {
+			t.Errorf("stream labels = %q, want sanitized names", p.labels)
+		}
+		if len(p.entries) > 2 {
+			t.Errorf("batch of %d lines, want at most 2", len(p.entries))
+		}
+		lines += len(p.entries)
+	}
+	if lines != 10 {
+		t.Errorf("pushed entries = %d, want 10", lines)
+	}
+	if first := pushes[0].entries[0]; !first.Timestamp.Equal(base.Add(123456789)) || first.Message != "line 0" {
+		t.Errorf("first entry = %v %q, want original timestamp and message", first.Timestamp, first.Message)
+	}
+}
+
+func TestExportLokiResume(t *testing.T) {
+	dir, _ := writeLokiCapture(t)
+	cpPath := filepath.Join(t.TempDir(), "loki.checkpoint")
+	opts := LokiExportOptions{Target: "http://loki:3100", Tenant: "team-a", Checkpoint: cpPath, Resume: true}
+
+	var messages []string
+	fail := errors.New("loki down")
+	calls := 0
+	push := func(_ context.Context, _ map[string]string, entries []recv.LogEntry) error {
+		calls++
+		if calls > 2 { // the streams of the first file go through
+			return fail
+		}
+		for _, e := range entries {
+			messages = append(messages, e.Message)
+		}
+		return nil
+	}
+	if _, err := ExportLoki(context.Background(), dir, nil, push, nil, opts); !errors.Is(err, fail) {
+		t.Fatalf("err = %v, want the push error", err)
+	}
+	cp, err := ReadCheckpoint(cpPath)
+	if err != nil || cp == nil {
+		t.Fatalf("checkpoint = %v, %v", cp, err)
+	}
+	if len(cp.Completed) != 1 || cp.Written != 5 {
+		t.Fatalf("checkpoint completed=%v written=%d, want the first file and 5 lines", cp.Completed, cp.Written)
+	}
+
+	calls = -100
+	n, err := ExportLoki(context.Background(), dir, nil, push, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 || len(messages) != 10 {
+		t.Errorf("pushed %d lines (%d messages), want 10 without repeating the first file", n, len(messages))
+	}
+	if cp, _ := ReadCheckpoint(cpPath); cp != nil {
+		t.Error("checkpoint should be removed after a complete export")
+	}
+
+	opts.Tenant = "team-b"
+	if err := WriteCheckpoint(cpPath, &Checkpoint{Op: "export", Params: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExportLoki(context.Background(), dir, nil, push, nil, opts); err == nil {
+		t.Error("resume with a different target should fail")
+	}
+}
+
+func TestLokiLabels(t *testing.T) {
+	got := flattenLabels(lokiLabels(map[string]string{"app.name": "a", "app_name": "b", "1x": "c", "empty": ""}))
+	if want := "_x=c;app_name=a"; got != want {
+		t.Errorf("lokiLabels = %q, want %q", got, want)
+	}
+	if got := flattenLabels(lokiLabels(nil)); got != "job=logtap" {
+		t.Errorf("lokiLabels(nil) = %q, want job=logtap", got)
+	}
+}
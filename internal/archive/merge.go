@@ -225,7 +225,7 @@ func writeIndexFile(dir string, entries []rotate.IndexEntry) error {
 
 func mergeMetadata(metas []*recv.Metadata, index []rotate.IndexEntry) *recv.Metadata {
 	out := &recv.Metadata{
-		Version: 1,
+		Version: recv.MetadataVersion,
 		Format:  "jsonl",
 	}
 
@@ -244,10 +244,18 @@ func mergeMetadata(metas []*recv.Metadata, index []rotate.IndexEntry) *recv.Meta
 		for _, l := range m.LabelsSeen {
 			labelSet[l] = true
 		}
+		out.Precision = recv.FinerPrecision(out.Precision, m.Precision)
 
 		if m.Redaction != nil && m.Redaction.Enabled {
 			out.Redaction = m.Redaction
 		}
+
+		// data files are copied as they are, so features of their content carry over
+		for _, f := range m.Features {
+			if f == recv.FeatureDedup || f == recv.FeatureEncryption || f == recv.FeatureChecksums {
+				out.AddFeature(f)
+			}
+		}
 	}
 
 	// also collect labels from index entries
@@ -0,0 +1,291 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// ConflictStrategy says how a merge resolves a label key that the sources
+// use with different meanings.
+type ConflictStrategy string
+
+const (
+	// ConflictMix leaves entries as they are; conflicts are only reported.
+	ConflictMix ConflictStrategy = ""
+	// ConflictKeepA keeps the key in the first source that has it and drops
+	// it from the others.
+	ConflictKeepA ConflictStrategy = "keep-a"
+	// ConflictKeepB keeps the key in the last source that has it and drops
+	// it from the others.
+	ConflictKeepB ConflictStrategy = "keep-b"
+	// ConflictSuffix keeps the key in the first source that has it and
+	// renames it to <key>_<n> in source n of the others (1-based).
+	ConflictSuffix ConflictStrategy = "suffix"
+)
+
+// ParseConflictStrategy parses an --on-conflict value.
+func ParseConflictStrategy(s string) (ConflictStrategy, error) {
+	switch cs := ConflictStrategy(s); cs {
+	case ConflictMix, ConflictKeepA, ConflictKeepB, ConflictSuffix:
+		return cs, nil
+	}
+	return "", fmt.Errorf("unknown conflict strategy %q (want keep-a, keep-b or suffix)", s)
+}
+
+// mergeStreamLabels identify a stream; captures of different workloads
+// naturally have different values for them, so they never conflict.
+var mergeStreamLabels = map[string]bool{
+	"namespace": true,
+	"pod":       true,
+	"container": true,
+	"app":       true,
+	"node":      true,
+	"session":   true,
+}
+
+// LabelConflict is a label key found in several merge sources with no value
+// in common, which suggests it means different things in each.
+type LabelConflict struct {
+	Key        string   `json:"key"`
+	Sources    []string `json:"sources"`              // sources carrying the key, in merge order
+	Resolution string   `json:"resolution,omitempty"` // what the merge did about it
+}
+
+// DetectLabelConflicts compares the label values recorded in each source's
+// index and returns the conflicting keys, sorted.
+func DetectLabelConflicts(sources []string) ([]LabelConflict, error) {
+	values := make([]map[string]map[string]bool, len(sources)) // source → key → values
+	for i, src := range sources {
+		reader, err := NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", src, err)
+		}
+		values[i] = make(map[string]map[string]bool)
+		for _, f := range reader.Files() {
+			if f.Index == nil {
+				continue
+			}
+			for key, vals := range f.Index.Labels {
+				if mergeStreamLabels[key] {
+					continue
+				}
+				if values[i][key] == nil {
+					values[i][key] = make(map[string]bool)
+				}
+				for v := range vals {
+					values[i][key][v] = true
+				}
+			}
+		}
+	}
+
+	keys := make(map[string]bool)
+	for _, kv := range values {
+		for key := range kv {
+			keys[key] = true
+		}
+	}
+
+	var conflicts []LabelConflict
+	for key := range keys {
+		var carriers []string
+		shared := false
+		seen := make(map[string]bool)
+		for i, kv := range values {
+			vals, ok := kv[key]
+			if !ok {
+				continue
+			}
+			carriers = append(carriers, sources[i])
+			for v := range vals {
+				if seen[v] {
+					shared = true
+				}
+			}
+			for v := range vals {
+				seen[v] = true
+			}
+		}
+		if len(carriers) > 1 && !shared {
+			conflicts = append(conflicts, LabelConflict{Key: key, Sources: carriers})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Key < conflicts[j].Key })
+	return conflicts, nil
+}
+
+// ResolveLabelConflicts applies strategy to conflicts, rewriting the affected
+// sources into temporary captures. It returns the sources to merge in place
+// of the given ones and a cleanup function removing the temporary captures,
+// and sets each conflict's Resolution.
+func ResolveLabelConflicts(sources []string, conflicts []LabelConflict, strategy ConflictStrategy) ([]string, func(), error) {
+	adjusted := make([]string, len(sources))
+	copy(adjusted, sources)
+	var tmpDirs []string
+	cleanup := func() {
+		for _, d := range tmpDirs {
+			_ = os.RemoveAll(d)
+		}
+	}
+
+	// source index → old key → new key ("" drops the key)
+	rewrites := make(map[int]map[string]string)
+	position := make(map[string]int, len(sources))
+	for i, src := range sources {
+		position[src] = i
+	}
+	for ci := range conflicts {
+		c := &conflicts[ci]
+		if strategy == ConflictMix {
+			c.Resolution = "mixed"
+			continue
+		}
+		keep := c.Sources[0]
+		if strategy == ConflictKeepB {
+			keep = c.Sources[len(c.Sources)-1]
+		}
+		var renamed []string
+		for _, src := range c.Sources {
+			if src == keep {
+				continue
+			}
+			i := position[src]
+			if rewrites[i] == nil {
+				rewrites[i] = make(map[string]string)
+			}
+			if strategy == ConflictSuffix {
+				to := fmt.Sprintf("%s_%d", c.Key, i+1)
+				rewrites[i][c.Key] = to
+				renamed = append(renamed, fmt.Sprintf("%s in %s", to, src))
+			} else {
+				rewrites[i][c.Key] = ""
+			}
+		}
+		if strategy == ConflictSuffix {
+			c.Resolution = "renamed to " + strings.Join(renamed, ", ")
+		} else {
+			c.Resolution = "kept from " + keep
+		}
+	}
+
+	for i, src := range sources {
+		if rewrites[i] == nil {
+			continue
+		}
+		tmpDir, err := os.MkdirTemp("", "logtap-relabel-*")
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("create temp dir: %w", err)
+		}
+		tmpDirs = append(tmpDirs, tmpDir)
+		if err := rewriteLabels(src, tmpDir, rewrites[i]); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("rewrite %s: %w", src, err)
+		}
+		adjusted[i] = tmpDir
+	}
+	return adjusted, cleanup, nil
+}
+
+// rewriteLabels copies the capture at src to dst, renaming label keys by
+// rename; keys renamed to "" are dropped.
+func rewriteLabels(src, dst string, rename map[string]string) error {
+	reader, err := NewReader(src)
+	if err != nil {
+		return fmt.Errorf("open source: %w", err)
+	}
+
+	dataName := "relabeled-000.jsonl"
+	dataFile, err := os.Create(filepath.Join(dst, dataName))
+	if err != nil {
+		return fmt.Errorf("create data file: %w", err)
+	}
+	defer func() { _ = dataFile.Close() }()
+	w := bufio.NewWriter(dataFile)
+
+	var totalLines, totalBytes int64
+	var minTS, maxTS time.Time
+	labels := make(map[string]map[string]int64)
+	var writeErr error
+	_, scanErr := reader.Scan(nil, func(e recv.LogEntry) bool {
+		for from, to := range rename {
+			v, ok := e.Labels[from]
+			if !ok {
+				continue
+			}
+			delete(e.Labels, from)
+			if to != "" {
+				e.Labels[to] = v
+			}
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return true // skip bad entries
+		}
+		data = append(data, '\n')
+		if _, writeErr = w.Write(data); writeErr != nil {
+			return false
+		}
+		totalLines++
+		totalBytes += int64(len(data))
+		if minTS.IsZero() || e.Timestamp.Before(minTS) {
+			minTS = e.Timestamp
+		}
+		if maxTS.IsZero() || e.Timestamp.After(maxTS) {
+			maxTS = e.Timestamp
+		}
+		for k, v := range e.Labels {
+			if labels[k] == nil {
+				labels[k] = make(map[string]int64)
+			}
+			labels[k][v]++
+		}
+		return true
+	})
+	if scanErr != nil {
+		return fmt.Errorf("scan source: %w", scanErr)
+	}
+	if writeErr != nil {
+		return fmt.Errorf("write data: %w", writeErr)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("flush data: %w", err)
+	}
+
+	outMeta := &recv.Metadata{
+		Version:    recv.MetadataVersion,
+		Format:     "jsonl",
+		Started:    minTS,
+		Stopped:    maxTS,
+		TotalLines: totalLines,
+		TotalBytes: totalBytes,
+	}
+	if meta := reader.Metadata(); meta != nil {
+		outMeta.Redaction = meta.Redaction
+	}
+	for k := range labels {
+		outMeta.LabelsSeen = append(outMeta.LabelsSeen, k)
+	}
+	sort.Strings(outMeta.LabelsSeen)
+	if err := recv.WriteMetadata(dst, outMeta); err != nil {
+		return fmt.Errorf("write metadata: %w", err)
+	}
+
+	return writeIndexFile(dst, []rotate.IndexEntry{{
+		File:   dataName,
+		From:   minTS,
+		To:     maxTS,
+		Lines:  totalLines,
+		Bytes:  totalBytes,
+		Labels: labels,
+	}})
+}
@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// conflictSource writes a capture whose entries carry app, env and level.
+func conflictSource(t *testing.T, base time.Time, app, env, level string) string {
+	t.Helper()
+	dir := t.TempDir()
+	entries := makeEntries(3, base, app)
+	for i := range entries {
+		entries[i].Labels["env"] = env
+		entries[i].Labels["level"] = level
+	}
+	writeMetadata(t, dir, base, base.Add(2*time.Second), 3)
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", entries)
+	writeIndex(t, dir, []rotate.IndexEntry{{
+		File: "2024-01-15T100000-000.jsonl", From: base, To: base.Add(2 * time.Second), Lines: 3,
+		Labels: map[string]map[string]int64{
+			"app":   {app: 3},
+			"env":   {env: 3},
+			"level": {level: 3},
+		},
+	}})
+	return dir
+}
+
+func TestDetectLabelConflicts(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	a := conflictSource(t, base, "api", "prod", "info")
+	b := conflictSource(t, base.Add(time.Minute), "web", "us-east-1", "info")
+
+	conflicts, err := DetectLabelConflicts([]string{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// app is a stream label and level shares "info"; only env conflicts
+	if len(conflicts) != 1 || conflicts[0].Key != "env" || len(conflicts[0].Sources) != 2 {
+		t.Fatalf("conflicts = %+v, want env in both sources", conflicts)
+	}
+}
+
+func TestResolveLabelConflicts(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	a := conflictSource(t, base, "api", "prod", "info")
+	b := conflictSource(t, base.Add(time.Minute), "web", "us-east-1", "info")
+
+	tests := []struct {
+		strategy ConflictStrategy
+		want     map[string]string // app → labels other than app and level
+	}{
+		{ConflictMix, map[string]string{"api": "env=prod", "web": "env=us-east-1"}},
+		{ConflictKeepA, map[string]string{"api": "env=prod", "web": ""}},
+		{ConflictKeepB, map[string]string{"api": "", "web": "env=us-east-1"}},
+		{ConflictSuffix, map[string]string{"api": "env=prod", "web": "env_2=us-east-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			sources := []string{a, b}
+			conflicts, err := DetectLabelConflicts(sources)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs, cleanup, err := ResolveLabelConflicts(sources, conflicts, tt.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+			if conflicts[0].Resolution == "" {
+				t.Error("resolution not set")
+			}
+
+			dst := t.TempDir()
+			if err := Merge(inputs, dst, nil); err != nil {
+				t.Fatal(err)
+			}
+			reader, err := NewReader(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			_, err = reader.Scan(nil, func(e recv.LogEntry) bool {
+				var rest string
+				for k, v := range e.Labels {
+					if k != "app" && k != "level" {
+						rest = fmt.Sprintf("%s=%s", k, v)
+					}
+				}
+				got[e.Labels["app"]] = rest
+				return true
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("labels = %v, want %v", got, tt.want)
+			}
+			if reader.TotalLines() != 6 {
+				t.Errorf("TotalLines = %d, want 6", reader.TotalLines())
+			}
+		})
+	}
+}
+
+func TestParseConflictStrategy(t *testing.T) {
+	for _, s := range []string{"", "keep-a", "keep-b", "suffix"} {
+		if _, err := ParseConflictStrategy(s); err != nil {
+			t.Errorf("ParseConflictStrategy(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseConflictStrategy("keep-c"); err == nil {
+		t.Error("want error for keep-c")
+	}
+}
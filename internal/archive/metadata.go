@@ -11,6 +11,7 @@ import (
 // Metadata represents the structure of metadata.json
 type Metadata struct {
 	Version    int                    `json:"version"`
+	Features   []string               `json:"features,omitempty"`
 	Format     string                 `json:"format"`
 	Started    time.Time              `json:"started"`
 	Stopped    time.Time              `json:"stopped"`
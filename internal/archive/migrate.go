@@ -0,0 +1,171 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// MigrateOptions configures Migrate.
+type MigrateOptions struct {
+	DryRun bool
+}
+
+// MigratedDir is the migration of one metadata.json: the capture itself or
+// one of its session directories.
+type MigratedDir struct {
+	Dir      string   `json:"dir"`
+	From     int      `json:"from_version"`
+	To       int      `json:"to_version"`
+	Features []string `json:"features,omitempty"`
+	Changed  bool     `json:"changed"`
+}
+
+// MigrateResult summarizes a migration.
+type MigrateResult struct {
+	Dir    string        `json:"dir"`
+	DryRun bool          `json:"dry_run"`
+	Dirs   []MigratedDir `json:"dirs"`
+}
+
+// Migrate upgrades the metadata.json of a capture, and of each of its
+// sessions, to recv.MetadataVersion in place. Features are detected from
+// the metadata fields and the files of the capture: encrypted data files,
+// an offload manifest and index checksums. Data files and indexes are not
+// changed, and captures already at the current version are left alone.
+// Migrate must not run while a receiver writes to dir.
+func Migrate(dir string, opts MigrateOptions) (*MigrateResult, error) {
+	result := &MigrateResult{Dir: dir, DryRun: opts.DryRun}
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+	dirs := []string{dir}
+	for _, s := range meta.Sessions {
+		dirs = append(dirs, filepath.Join(dir, s))
+	}
+	for _, d := range dirs {
+		m, err := migrateDir(d, opts)
+		if err != nil {
+			return result, fmt.Errorf("migrate %s: %w", d, err)
+		}
+		result.Dirs = append(result.Dirs, *m)
+	}
+	return result, nil
+}
+
+func migrateDir(dir string, opts MigrateOptions) (*MigratedDir, error) {
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+	from := meta.Version
+	if from == 0 {
+		from = 1 // written before the field existed
+	}
+	out := &MigratedDir{Dir: dir, From: from, To: from, Features: meta.Features}
+	if from >= recv.MetadataVersion {
+		return out, nil
+	}
+
+	// v1 → v2: record the features in use
+	features, err := detectFeatures(dir, meta)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range features {
+		meta.AddFeature(f)
+	}
+	meta.Version = recv.MetadataVersion
+	out.To = meta.Version
+	out.Features = meta.Features
+	out.Changed = true
+	if opts.DryRun {
+		return out, nil
+	}
+	if err := replaceMetadataFile(dir, meta); err != nil {
+		return nil, fmt.Errorf("write metadata: %w", err)
+	}
+	return out, nil
+}
+
+// detectFeatures returns the features a capture uses, from its metadata
+// fields and its files.
+func detectFeatures(dir string, meta *recv.Metadata) ([]string, error) {
+	features := meta.ImpliedFeatures()
+	for _, d := range captureDirs(dir, meta) {
+		if _, err := os.Stat(filepath.Join(d, rotate.OffloadFile)); err == nil {
+			features = append(features, recv.FeatureOffload)
+		}
+		index, err := readIndex(d)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read index: %w", err)
+		}
+		for _, e := range index {
+			if strings.HasSuffix(e.File, EncryptedSuffix) {
+				features = append(features, recv.FeatureEncryption)
+			}
+			if e.SHA256 != "" {
+				features = append(features, recv.FeatureChecksums)
+			}
+		}
+		orphans, err := discoverOrphans(d, nil)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, o := range orphans {
+			if strings.HasSuffix(o.Name, EncryptedSuffix) {
+				features = append(features, recv.FeatureEncryption)
+			}
+		}
+	}
+	slices.Sort(features)
+	return slices.Compact(features), nil
+}
+
+// replaceMetadataFile writes meta to a temporary file and renames it over
+// metadata.json, so an interrupted migration leaves the old file intact.
+func replaceMetadataFile(dir string, meta *recv.Metadata) error {
+	tmp, err := os.MkdirTemp(dir, ".migrate-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	if err := recv.WriteMetadata(tmp, meta); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(tmp, "metadata.json"), filepath.Join(dir, "metadata.json"))
+}
+
+// WriteJSON writes the result as indented JSON.
+func (r *MigrateResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a human-readable summary.
+func (r *MigrateResult) WriteText(w io.Writer) {
+	tw := &textWriter{w: w}
+	for _, d := range r.Dirs {
+		features := "none"
+		if len(d.Features) > 0 {
+			features = strings.Join(d.Features, ", ")
+		}
+		switch {
+		case !d.Changed:
+			tw.printf("%s: already at version %d\n", d.Dir, d.From)
+		case r.DryRun:
+			tw.printf("%s: would migrate version %d -> %d (features: %s)\n", d.Dir, d.From, d.To, features)
+		default:
+			tw.printf("%s: migrated version %d -> %d (features: %s)\n", d.Dir, d.From, d.To, features)
+		}
+	}
+}
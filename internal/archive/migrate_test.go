@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func TestMigrate(t *testing.T) {
+	dir := writeRotatedCapture(t, 10, rotate.Config{}, "web")
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.Dedup = &recv.DedupInfo{Window: "1s"}
+	if err := recv.WriteMetadata(dir, meta); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(filepath.Join(dir, "metadata.json"))
+
+	// dry run leaves the file alone
+	result, err := Migrate(dir, MigrateOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Dirs) != 1 || !result.Dirs[0].Changed || result.Dirs[0].From != 1 || result.Dirs[0].To != recv.MetadataVersion {
+		t.Fatalf("dry run result = %+v", result.Dirs)
+	}
+	after, _ := os.ReadFile(filepath.Join(dir, "metadata.json"))
+	if string(before) != string(after) {
+		t.Error("dry run changed metadata.json")
+	}
+
+	if _, err := Migrate(dir, MigrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := recv.ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != recv.MetadataVersion {
+		t.Errorf("version = %d, want %d", got.Version, recv.MetadataVersion)
+	}
+	want := []string{recv.FeatureChecksums, recv.FeatureDedup}
+	if !slices.Equal(got.Features, want) {
+		t.Errorf("features = %v, want %v", got.Features, want)
+	}
+	if got.TotalLines != meta.TotalLines || !got.Started.Equal(meta.Started) {
+		t.Errorf("migration changed other fields: %+v", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".migrate-") {
+			t.Errorf("temporary %s left behind", e.Name())
+		}
+	}
+
+	// a second run is a no-op
+	result, err = Migrate(dir, MigrateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Dirs[0].Changed {
+		t.Errorf("second run changed %+v", result.Dirs[0])
+	}
+	var b strings.Builder
+	result.WriteText(&b)
+	if !strings.Contains(b.String(), "already at version 2") {
+		t.Errorf("text = %q", b.String())
+	}
+}
+
+func TestMigrateSessions(t *testing.T) {
+	dir := t.TempDir()
+	for _, s := range []string{"s1", "s2"} {
+		if err := os.Mkdir(filepath.Join(dir, s), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := recv.WriteMetadata(filepath.Join(dir, s), &recv.Metadata{Version: 1, Format: "jsonl"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recv.WriteMetadata(dir, &recv.Metadata{Version: 1, Format: "jsonl", Sessions: []string{"s1", "s2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Migrate(dir, MigrateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Dirs) != 3 {
+		t.Fatalf("dirs = %+v", result.Dirs)
+	}
+	root, _ := recv.ReadMetadata(dir)
+	if !slices.Contains(root.Features, recv.FeatureSessions) {
+		t.Errorf("root features = %v", root.Features)
+	}
+	for _, s := range []string{"s1", "s2"} {
+		m, err := recv.ReadMetadata(filepath.Join(dir, s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Version != recv.MetadataVersion {
+			t.Errorf("%s version = %d", s, m.Version)
+		}
+	}
+}
+
+func TestMigrateMissingMetadata(t *testing.T) {
+	if _, err := Migrate(t.TempDir(), MigrateOptions{}); err == nil {
+		t.Fatal("expected error for missing metadata")
+	}
+}
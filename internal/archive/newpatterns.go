@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// maxNewPatternSignatures bounds the signatures tracked on each side of the
+// cutoff; once reached, further unseen signatures are not tracked.
+const maxNewPatternSignatures = 100000
+
+// NewPattern is a message signature first seen after the cutoff.
+type NewPattern struct {
+	Pattern   string    `json:"pattern"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Sample    string    `json:"sample"` // earliest message with the signature
+}
+
+// NewPatterns finds message signatures (see NormalizeMessage) that appear at
+// or after Since but never before it. Entries may be added in any order.
+type NewPatterns struct {
+	Since time.Time
+
+	before    map[string]bool
+	after     map[string]*NewPattern
+	truncated bool
+}
+
+// NewNewPatterns creates a tracker with the given cutoff.
+func NewNewPatterns(since time.Time) *NewPatterns {
+	return &NewPatterns{
+		Since:  since,
+		before: make(map[string]bool),
+		after:  make(map[string]*NewPattern),
+	}
+}
+
+// Add records one entry.
+func (n *NewPatterns) Add(e recv.LogEntry) {
+	sig := NormalizeMessage(e.Message)
+	if e.Timestamp.Before(n.Since) {
+		if !n.before[sig] && !n.track(len(n.before)) {
+			return
+		}
+		n.before[sig] = true
+		return
+	}
+	p := n.after[sig]
+	if p == nil {
+		if !n.track(len(n.after)) {
+			return
+		}
+		p = &NewPattern{Pattern: sig, FirstSeen: e.Timestamp, LastSeen: e.Timestamp, Sample: e.Message}
+		n.after[sig] = p
+	}
+	p.Count++
+	if e.Timestamp.Before(p.FirstSeen) {
+		p.FirstSeen = e.Timestamp
+		p.Sample = e.Message
+	}
+	if e.Timestamp.After(p.LastSeen) {
+		p.LastSeen = e.Timestamp
+	}
+}
+
+func (n *NewPatterns) track(size int) bool {
+	if size >= maxNewPatternSignatures {
+		n.truncated = true
+		return false
+	}
+	return true
+}
+
+// Truncated reports whether signatures were left untracked because the
+// capture has too many distinct ones; results may then be incomplete.
+func (n *NewPatterns) Truncated() bool { return n.truncated }
+
+// Results returns the new signatures ordered by first appearance.
+func (n *NewPatterns) Results() []NewPattern {
+	var out []NewPattern
+	for sig, p := range n.after {
+		if !n.before[sig] {
+			out = append(out, *p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].FirstSeen.Equal(out[j].FirstSeen) {
+			return out[i].FirstSeen.Before(out[j].FirstSeen)
+		}
+		return out[i].Pattern < out[j].Pattern
+	})
+	return out
+}
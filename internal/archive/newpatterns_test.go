@@ -0,0 +1,71 @@
+package archive
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestNewPatterns(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	since := base.Add(30 * time.Minute)
+	entry := func(min int, msg string) recv.LogEntry {
+		return recv.LogEntry{Timestamp: base.Add(time.Duration(min) * time.Minute), Message: msg}
+	}
+
+	n := NewNewPatterns(since)
+	// added out of order, as parallel scans deliver them
+	for _, e := range []recv.LogEntry{
+		entry(45, "connection refused to 10.0.0.7"),
+		entry(5, "served request 1234"),
+		entry(40, "served request 5678"),
+		entry(31, "connection refused to 10.0.0.9"),
+		entry(50, "pool exhausted"),
+		entry(35, "cache warm"),
+		entry(10, "cache warm"),
+		entry(30, "retry budget exceeded"),
+	} {
+		n.Add(e)
+	}
+
+	got := n.Results()
+	want := []struct {
+		pattern string
+		count   int64
+		first   int
+		last    int
+		sample  string
+	}{
+		{"retry budget exceeded", 1, 30, 30, "retry budget exceeded"},
+		{"connection refused to <IP>", 2, 31, 45, "connection refused to 10.0.0.9"},
+		{"pool exhausted", 1, 50, 50, "pool exhausted"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d patterns, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Pattern != w.pattern || g.Count != w.count || g.Sample != w.sample ||
+			!g.FirstSeen.Equal(base.Add(time.Duration(w.first)*time.Minute)) ||
+			!g.LastSeen.Equal(base.Add(time.Duration(w.last)*time.Minute)) {
+			t.Errorf("pattern %d = %+v, want %+v", i, g, w)
+		}
+	}
+	if n.Truncated() {
+		t.Error("unexpected truncation")
+	}
+}
+
+func TestNewPatterns_Truncated(t *testing.T) {
+	since := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	n := NewNewPatterns(since)
+	for i := 0; i <= maxNewPatternSignatures; i++ {
+		n.after[strconv.Itoa(i)] = &NewPattern{}
+	}
+	n.Add(recv.LogEntry{Timestamp: since, Message: "one more"})
+	if !n.Truncated() {
+		t.Error("expected truncation")
+	}
+}
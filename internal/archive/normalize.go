@@ -5,11 +5,14 @@ import (
 	"strings"
 )
 
-// normalizer replaces variable tokens in log messages to extract stable error signatures.
-var normalizers = []struct {
+// normalizer replaces a variable token in log messages with a placeholder.
+type normalizer struct {
 	re   *regexp.Regexp
 	repl string
-}{
+}
+
+// normalizers replace variable tokens in log messages to extract stable error signatures.
+var normalizers = []normalizer{
 	// UUIDs: 8-4-4-4-12 hex
 	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<UUID>"},
 	// ISO timestamps: 2024-01-15T10:32:01 (with optional fractional seconds and timezone)
@@ -26,13 +29,32 @@ var normalizers = []struct {
 
 // NormalizeMessage replaces variable tokens (UUIDs, IPs, timestamps, etc.) with
 // placeholders to produce a stable signature for grouping similar messages.
+// Selected language packs add their own tokens first.
 func NormalizeMessage(msg string) string {
+	for _, p := range currentPacks() {
+		for _, n := range p.normalizers {
+			msg = n.re.ReplaceAllString(msg, n.repl)
+		}
+	}
 	for _, n := range normalizers {
 		msg = n.re.ReplaceAllString(msg, n.repl)
 	}
 	return msg
 }
 
+// placeholder matches the tokens normalizers put in signatures.
+var placeholder = regexp.MustCompile(`<[A-Z_]+>`)
+
+// SignaturePattern returns a regular expression matching the messages
+// NormalizeMessage turns into sig, with each placeholder matching any text.
+func SignaturePattern(sig string) string {
+	parts := placeholder.Split(sig, -1)
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return strings.Join(parts, ".+?")
+}
+
 // errorKeywords are checked case-insensitively against log messages.
 var errorKeywords = []string{
 	"error",
@@ -49,13 +71,34 @@ var errorKeywords = []string{
 	"deadline exceeded",
 }
 
-// IsError returns true if the message contains common error-indicating keywords.
+// IsError returns true if the message contains common error-indicating keywords,
+// or matches a selected language pack.
 func IsError(msg string) bool {
+	packs := currentPacks()
+	for _, p := range packs {
+		if p.classify != nil {
+			if isErr, ok := p.classify(msg); ok {
+				return isErr
+			}
+		}
+	}
 	lower := strings.ToLower(msg)
 	for _, kw := range errorKeywords {
 		if strings.Contains(lower, kw) {
 			return true
 		}
 	}
+	for _, p := range packs {
+		for _, kw := range p.keywords {
+			if strings.Contains(lower, kw) {
+				return true
+			}
+		}
+		for _, re := range p.patterns {
+			if re.MatchString(msg) {
+				return true
+			}
+		}
+	}
 	return false
 }
@@ -0,0 +1,146 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ppiankov/logtap/internal/cloud"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// OffloadManifestFile lists data files moved off the capture host. Index
+// entries whose file is missing locally are fetched from the recorded URL
+// on first read and cached, so analysis commands work on offloaded
+// captures without a full download. recv --sink writes it as segments are
+// uploaded.
+const OffloadManifestFile = rotate.OffloadFile
+
+// OffloadManifest maps data file names to object storage URLs
+// (s3://bucket/key or gs://bucket/key).
+type OffloadManifest struct {
+	Files map[string]string `json:"files"`
+}
+
+// ReadOffloadManifest reads offload.json from dir. A missing manifest
+// yields an empty one.
+func ReadOffloadManifest(dir string) (*OffloadManifest, error) {
+	m := &OffloadManifest{Files: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(dir, OffloadManifestFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", OffloadManifestFile, err)
+	}
+	for name, url := range m.Files {
+		if !cloud.IsURL(url) {
+			return nil, fmt.Errorf("%s: %s: unsupported URL %q", OffloadManifestFile, name, url)
+		}
+	}
+	return m, nil
+}
+
+// fetchObject downloads a cloud URL to w. Tests replace it.
+var fetchObject = func(ctx context.Context, url string, w io.Writer) error {
+	scheme, bucket, key, err := cloud.ParseURL(url)
+	if err != nil {
+		return err
+	}
+	backend, err := cloud.NewBackend(ctx, scheme, bucket)
+	if err != nil {
+		return err
+	}
+	return backend.Download(ctx, key, w)
+}
+
+// offloadCacheDir is where fetched files are kept between runs.
+var offloadCacheDir = func() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logtap", "offload"), nil
+}
+
+// fetchOffloaded returns a local path holding the file at url, downloading
+// it into the cache unless an earlier run already did.
+func fetchOffloaded(name, url string) (string, error) {
+	dir, err := offloadCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("offload cache: %w", err)
+	}
+	sum := sha256.Sum256([]byte(url))
+	path := filepath.Join(dir, hex.EncodeToString(sum[:8])+"-"+filepath.Base(name))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("offload cache: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".fetch-*")
+	if err != nil {
+		return "", fmt.Errorf("offload cache: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := fetchObject(context.Background(), url, tmp); err != nil {
+		_ = tmp.Close()
+		return "", fmt.Errorf("fetch offloaded %s from %s: %w", name, url, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("offload cache: %w", err)
+	}
+	return path, nil
+}
+
+// openDataFile opens a data file for reading, fetching it if it was
+// offloaded and decrypting it if it is encrypted. The returned stream is
+// still zstd-compressed when plainName ends in ".zst".
+func openDataFile(f FileInfo) (rc io.ReadCloser, plainName string, err error) {
+	path := f.Path
+	if f.Remote != "" {
+		if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+			if path, err = fetchOffloaded(f.Name, f.Remote); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	plainName, encrypted := strings.CutSuffix(f.Name, EncryptedSuffix)
+	if !encrypted {
+		return file, plainName, nil
+	}
+	key, err := decryptionKey()
+	if err != nil {
+		_ = file.Close()
+		return nil, "", err
+	}
+	dec, err := NewDecryptReader(file, key)
+	if err != nil {
+		_ = file.Close()
+		return nil, "", err
+	}
+	return readCloser{dec, file}, plainName, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
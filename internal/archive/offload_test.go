@@ -0,0 +1,115 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// stubOffload serves objects from memory and caches into a temp dir.
+func stubOffload(t *testing.T, objects map[string]string) *int {
+	t.Helper()
+	fetches := 0
+	origFetch, origCache := fetchObject, offloadCacheDir
+	cacheDir := t.TempDir()
+	fetchObject = func(_ context.Context, url string, w io.Writer) error {
+		fetches++
+		data, ok := objects[url]
+		if !ok {
+			return errors.New("NoSuchKey")
+		}
+		_, err := io.WriteString(w, data)
+		return err
+	}
+	offloadCacheDir = func() (string, error) { return cacheDir, nil }
+	t.Cleanup(func() { fetchObject, offloadCacheDir = origFetch, origCache })
+	return &fetches
+}
+
+func TestReaderOffloadedFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	writeMetadata(t, dir, base, base.Add(time.Minute), 2)
+
+	local := "2024-01-15T100000-000.jsonl"
+	remote := "2024-01-15T100030-000.jsonl"
+	writeDataFile(t, dir, local, []recv.LogEntry{{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "local"}})
+	writeIndex(t, dir, []rotate.IndexEntry{
+		{File: local, From: base, To: base, Lines: 1},
+		{File: remote, From: base.Add(30 * time.Second), To: base.Add(30 * time.Second), Lines: 1},
+	})
+	manifest := `{"files":{"` + remote + `":"s3://bucket/caps/` + remote + `"}}`
+	if err := os.WriteFile(filepath.Join(dir, OffloadManifestFile), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fetches := stubOffload(t, map[string]string{
+		"s3://bucket/caps/" + remote: `{"ts":"2024-01-15T10:00:30Z","labels":{"app":"api"},"msg":"remote"}` + "\n",
+	})
+
+	scan := func() []string {
+		reader, err := NewReader(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msgs []string
+		if _, err := reader.Scan(nil, func(e recv.LogEntry) bool {
+			msgs = append(msgs, e.Message)
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return msgs
+	}
+	if got := strings.Join(scan(), ","); got != "local,remote" {
+		t.Errorf("messages = %s", got)
+	}
+	// second run is served from the cache
+	if got := strings.Join(scan(), ","); got != "local,remote" {
+		t.Errorf("messages = %s", got)
+	}
+	if *fetches != 1 {
+		t.Errorf("fetches = %d, want 1", *fetches)
+	}
+}
+
+func TestReaderOffloadFetchError(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	writeMetadata(t, dir, base, base.Add(time.Minute), 1)
+	name := "2024-01-15T100000-000.jsonl"
+	writeIndex(t, dir, []rotate.IndexEntry{{File: name, From: base, To: base, Lines: 1}})
+	if err := os.WriteFile(filepath.Join(dir, OffloadManifestFile), []byte(`{"files":{"`+name+`":"gs://b/missing"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stubOffload(t, nil)
+
+	reader, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reader.Scan(nil, func(recv.LogEntry) bool { return true })
+	if err == nil || !strings.Contains(err.Error(), "gs://b/missing") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestReadOffloadManifestInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if m, err := ReadOffloadManifest(dir); err != nil || len(m.Files) != 0 {
+		t.Fatalf("missing manifest: %v, %v", m, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, OffloadManifestFile), []byte(`{"files":{"a.jsonl":"http://x/a"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadOffloadManifest(dir); err == nil {
+		t.Error("expected error for non-cloud URL")
+	}
+}
@@ -0,0 +1,207 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnownedOwner is the rollup name for errors no ownership rule matched.
+const UnownedOwner = "unowned"
+
+// OwnersFile is the owners.yaml format: rules tried in order, first match
+// wins.
+//
+//	owners:
+//	  - team: payments
+//	    labels:
+//	      app: [payments-api, "ledger-*"]
+//	    signatures:
+//	      - "(?i)stripe"
+type OwnersFile struct {
+	Owners []OwnerRule `yaml:"owners"`
+}
+
+// OwnerRule assigns errors to a team when any label value glob or any
+// signature regex matches.
+type OwnerRule struct {
+	Team       string              `yaml:"team"`
+	Labels     map[string][]string `yaml:"labels,omitempty"`
+	Signatures []string            `yaml:"signatures,omitempty"`
+}
+
+// Owners maps error lines to owning teams.
+type Owners struct {
+	rules  []ownerRule
+	digest string // identifies the rules in triage cache keys
+}
+
+type ownerRule struct {
+	team       string
+	labels     map[string][]string
+	signatures []*regexp.Regexp
+}
+
+// LoadOwners reads and validates an owners.yaml file.
+func LoadOwners(file string) (*Owners, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read owners: %w", err)
+	}
+	var f OwnersFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse owners: %w", err)
+	}
+	return NewOwners(f.Owners)
+}
+
+// NewOwners compiles ownership rules.
+func NewOwners(rules []OwnerRule) (*Owners, error) {
+	sum, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+	o := &Owners{digest: fmt.Sprintf("%x", sha256.Sum256(sum))}
+	for i, r := range rules {
+		if r.Team == "" {
+			return nil, fmt.Errorf("owners rule %d: missing team", i+1)
+		}
+		if len(r.Labels) == 0 && len(r.Signatures) == 0 {
+			return nil, fmt.Errorf("owners rule %d (%s): needs labels or signatures", i+1, r.Team)
+		}
+		rule := ownerRule{team: r.Team, labels: r.Labels}
+		for key, globs := range r.Labels {
+			for _, g := range globs {
+				if _, err := path.Match(g, ""); err != nil {
+					return nil, fmt.Errorf("owners rule %d (%s): label %s: invalid pattern %q", i+1, r.Team, key, g)
+				}
+			}
+		}
+		for _, s := range r.Signatures {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("owners rule %d (%s): invalid signature regex: %w", i+1, r.Team, err)
+			}
+			rule.signatures = append(rule.signatures, re)
+		}
+		o.rules = append(o.rules, rule)
+	}
+	return o, nil
+}
+
+// Match returns the team owning an error line, or "" if no rule matches.
+func (o *Owners) Match(labels map[string]string, signature string) string {
+	return o.match(labels, o.signatureRule(signature))
+}
+
+// signatureRule returns the index of the first rule whose signature regexes
+// match, or len(rules). Callers cache it per signature.
+func (o *Owners) signatureRule(signature string) int {
+	for i, r := range o.rules {
+		for _, re := range r.signatures {
+			if re.MatchString(signature) {
+				return i
+			}
+		}
+	}
+	return len(o.rules)
+}
+
+// match applies rule order: a label rule before sigRule wins, else sigRule.
+func (o *Owners) match(labels map[string]string, sigRule int) string {
+	for i := 0; i < sigRule; i++ {
+		if o.rules[i].matchLabels(labels) {
+			return o.rules[i].team
+		}
+	}
+	if sigRule < len(o.rules) {
+		return o.rules[sigRule].team
+	}
+	return ""
+}
+
+func (r ownerRule) matchLabels(labels map[string]string) bool {
+	for key, globs := range r.labels {
+		v, ok := labels[key]
+		if !ok {
+			continue
+		}
+		for _, g := range globs {
+			if ok, _ := path.Match(g, v); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OwnerRollup sums error lines per owning team.
+type OwnerRollup struct {
+	Owner        string `json:"owner"`
+	ErrorLines   int64  `json:"error_lines"`
+	Signatures   int    `json:"signatures"`
+	TopSignature string `json:"top_signature"`
+}
+
+// buildOwnerRollups sums per-signature owner counts into per-team totals,
+// sorted by error lines.
+func buildOwnerRollups(signatures map[string]*sigAccum) []OwnerRollup {
+	type accum struct {
+		lines  int64
+		sigs   int
+		top    string
+		topCnt int64
+	}
+	byOwner := make(map[string]*accum)
+	for sig, sa := range signatures {
+		for owner, n := range sa.owners {
+			if owner == "" {
+				owner = UnownedOwner
+			}
+			a := byOwner[owner]
+			if a == nil {
+				a = &accum{}
+				byOwner[owner] = a
+			}
+			a.lines += n
+			a.sigs++
+			if n > a.topCnt || (n == a.topCnt && sig < a.top) {
+				a.top, a.topCnt = sig, n
+			}
+		}
+	}
+	if len(byOwner) == 0 {
+		return nil
+	}
+
+	rollups := make([]OwnerRollup, 0, len(byOwner))
+	for owner, a := range byOwner {
+		rollups = append(rollups, OwnerRollup{Owner: owner, ErrorLines: a.lines, Signatures: a.sigs, TopSignature: a.top})
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		if rollups[i].ErrorLines != rollups[j].ErrorLines {
+			return rollups[i].ErrorLines > rollups[j].ErrorLines
+		}
+		return rollups[i].Owner < rollups[j].Owner
+	})
+	return rollups
+}
+
+// dominantOwner returns the team with the most lines of a signature, "" if
+// those are unowned; ties go to the alphabetically first.
+func dominantOwner(owners map[string]int64) string {
+	var best string
+	var bestN int64
+	for owner, n := range owners {
+		if n > bestN || (n == bestN && owner < best) {
+			best, bestN = owner, n
+		}
+	}
+	return best
+}
@@ -0,0 +1,139 @@
+package archive
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testOwners(t *testing.T) *Owners {
+	t.Helper()
+	o, err := NewOwners([]OwnerRule{
+		{Team: "payments", Signatures: []string{"(?i)connection refused"}},
+		{Team: "api", Labels: map[string][]string{"app": {"api"}}},
+		{Team: "workers", Labels: map[string][]string{"app": {"work*"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestOwnersMatch(t *testing.T) {
+	o := testOwners(t)
+	tests := []struct {
+		labels map[string]string
+		sig    string
+		want   string
+	}{
+		{map[string]string{"app": "api"}, "connection refused to payments:<N>", "payments"},
+		{map[string]string{"app": "api"}, "panic: nil pointer", "api"},
+		{map[string]string{"app": "worker-7"}, "timeout", "workers"},
+		{map[string]string{"app": "gateway"}, "timeout", ""},
+		{nil, "Connection refused", "payments"},
+	}
+	for _, tt := range tests {
+		if got := o.Match(tt.labels, tt.sig); got != tt.want {
+			t.Errorf("Match(%v, %q) = %q, want %q", tt.labels, tt.sig, got, tt.want)
+		}
+	}
+}
+
+func TestNewOwnersErrors(t *testing.T) {
+	bad := [][]OwnerRule{
+		{{Labels: map[string][]string{"app": {"x"}}}},
+		{{Team: "a"}},
+		{{Team: "a", Signatures: []string{"("}}},
+		{{Team: "a", Labels: map[string][]string{"app": {"["}}}},
+	}
+	for i, rules := range bad {
+		if _, err := NewOwners(rules); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestLoadOwners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owners.yaml")
+	data := "owners:\n  - team: api\n    labels:\n      app: [api]\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	o, err := LoadOwners(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := o.Match(map[string]string{"app": "api"}, "x"); got != "api" {
+		t.Errorf("Match = %q, want api", got)
+	}
+	if _, err := LoadOwners(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestTriageOwners(t *testing.T) {
+	src, _ := setupTriageSource(t)
+
+	result, err := Triage(src, TriageConfig{Jobs: 1, Owners: testOwners(t)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owners := make(map[string]int64)
+	for _, o := range result.Owners {
+		owners[o.Owner] = o.ErrorLines
+	}
+	if owners["payments"] != 3 || owners["api"] == 0 || owners["workers"] == 0 {
+		t.Errorf("rollups = %+v", result.Owners)
+	}
+	if result.Owners[0].Owner != "payments" || !strings.HasPrefix(result.Owners[0].TopSignature, "connection refused") {
+		t.Errorf("top rollup = %+v, want payments first", result.Owners[0])
+	}
+	for _, e := range result.Errors {
+		if strings.HasPrefix(e.Signature, "connection refused") && e.Owner != "payments" {
+			t.Errorf("owner of %q = %q, want payments", e.Signature, e.Owner)
+		}
+	}
+
+	var summary, html bytes.Buffer
+	result.WriteSummary(&summary)
+	if !strings.Contains(summary.String(), "## Errors by Owner") || !strings.Contains(summary.String(), "[payments]") {
+		t.Errorf("summary missing owners:\n%s", summary.String())
+	}
+	if err := result.WriteHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "Errors by Owner") || !strings.Contains(html.String(), "<th>Owner</th>") {
+		t.Error("HTML missing owner column or rollup table")
+	}
+
+	plain, err := Triage(src, TriageConfig{Jobs: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Owners != nil || plain.Errors[0].Owner != "" {
+		t.Error("owners reported without rules")
+	}
+}
+
+func TestReportOwners(t *testing.T) {
+	src, _ := setupTriageSource(t)
+
+	result, err := Report(src, ReportConfig{Jobs: 1, Top: 10, Owners: testOwners(t)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Triage.Owners) == 0 {
+		t.Fatal("report has no owner rollups")
+	}
+
+	var md bytes.Buffer
+	if err := result.WriteMarkdownSummary(&md, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "**Errors by owner:** payments 3") || !strings.Contains(md.String(), "— payments") {
+		t.Errorf("markdown summary missing owners:\n%s", md.String())
+	}
+}
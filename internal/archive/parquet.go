@@ -11,9 +11,17 @@ import (
 
 const parquetBatchSize = 50000
 
-// parquetEntry is the Parquet schema struct.
+// parquetRowGroupRows caps the rows of a row group, so the writer holds a
+// bounded part of the export in memory and query engines can split the file
+// across workers and skip row groups by their ts statistics.
+const parquetRowGroupRows = 1 << 20
+
+// parquetEntry is the Parquet schema struct. ts is in microseconds, the
+// finest timestamp unit Spark and Athena read; ts_ns keeps the exact time
+// for ordering lines within a microsecond.
 type parquetEntry struct {
-	Ts     int64             `parquet:"ts,timestamp(nanosecond)"`
+	Ts     int64             `parquet:"ts,timestamp(microsecond)"`
+	TsNs   int64             `parquet:"ts_ns"`
 	Labels map[string]string `parquet:"labels"`
 	Msg    string            `parquet:"msg"`
 }
@@ -32,6 +40,7 @@ func newParquetWriter(path string) (*parquetWriter, error) {
 
 	w := parquet.NewGenericWriter[parquetEntry](f,
 		parquet.Compression(&zstd.Codec{}),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupRows),
 	)
 
 	return &parquetWriter{
@@ -43,7 +52,8 @@ func newParquetWriter(path string) (*parquetWriter, error) {
 
 func (w *parquetWriter) Write(e recv.LogEntry) error {
 	w.batch = append(w.batch, parquetEntry{
-		Ts:     e.Timestamp.UnixNano(),
+		Ts:     e.Timestamp.UnixMicro(),
+		TsNs:   e.Timestamp.UnixNano(),
 		Labels: e.Labels,
 		Msg:    e.Message,
 	})
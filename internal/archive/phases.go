@@ -0,0 +1,89 @@
+package archive
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// Phase is a test phase started by an annotation with a phase label. It
+// lasts until the next phase annotation; the last phase is open-ended.
+type Phase struct {
+	Name string
+	From time.Time
+	To   time.Time // zero for the last phase
+}
+
+// FindPhases scans a capture for phase annotations, in timestamp order.
+func FindPhases(r *Reader) ([]Phase, error) {
+	var phases []Phase
+	filter := &Filter{Labels: []LabelMatcher{{Key: recv.AnnotationLabel, Value: "true"}}}
+	_, err := r.Scan(filter, func(e recv.LogEntry) bool {
+		if name := e.Labels[recv.PhaseLabel]; name != "" {
+			phases = append(phases, Phase{Name: name, From: e.Timestamp})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find phases: %w", err)
+	}
+	sort.SliceStable(phases, func(i, j int) bool { return phases[i].From.Before(phases[j].From) })
+	for i := 0; i+1 < len(phases); i++ {
+		phases[i].To = phases[i+1].From
+	}
+	return phases, nil
+}
+
+// PhaseFilter keeps entries within any of Phases, from the phase marker up
+// to but excluding the next one.
+type PhaseFilter struct {
+	Phases []Phase
+}
+
+// NewPhaseFilter returns a filter for the phases of phases named name, or
+// an error if there are none.
+func NewPhaseFilter(phases []Phase, name string) (*PhaseFilter, error) {
+	pf := &PhaseFilter{}
+	for _, p := range phases {
+		if p.Name == name {
+			pf.Phases = append(pf.Phases, p)
+		}
+	}
+	if len(pf.Phases) == 0 {
+		return nil, fmt.Errorf("no phase %q in capture", name)
+	}
+	return pf, nil
+}
+
+// Match reports whether ts falls within a phase.
+func (pf *PhaseFilter) Match(ts time.Time) bool {
+	for _, p := range pf.Phases {
+		if !ts.Before(p.From) && (p.To.IsZero() || ts.Before(p.To)) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps reports whether any phase intersects [from, to].
+func (pf *PhaseFilter) overlaps(from, to time.Time) bool {
+	for _, p := range pf.Phases {
+		if !to.Before(p.From) && (p.To.IsZero() || from.Before(p.To)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pf *PhaseFilter) String() string {
+	s := "phases="
+	for i, p := range pf.Phases {
+		if i > 0 {
+			s += ","
+		}
+		s += fmt.Sprintf("%s@%s", p.Name, p.From.UTC().Format(time.RFC3339Nano))
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package archive
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// writePhaseCapture writes a line a minute over ten minutes, with phase
+// annotations for rampup at minute 2, steady at minute 5 and rampup
+// again at minute 8.
+func writePhaseCapture(t *testing.T) (dir string, base time.Time) {
+	t.Helper()
+	dir = t.TempDir()
+	base = time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var entries []recv.LogEntry
+	for i := 0; i <= 10; i++ {
+		ts := base.Add(time.Duration(i) * time.Minute)
+		entries = append(entries, recv.LogEntry{Timestamp: ts, Labels: map[string]string{"app": "api"}, Message: fmt.Sprintf("line %d", i)})
+		phase := map[int]string{2: "rampup", 5: "steady", 8: "rampup"}[i]
+		if phase != "" {
+			a, err := recv.Annotation{Labels: map[string]string{recv.PhaseLabel: phase}}.Entry(ts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, a)
+		}
+	}
+	writeMetadata(t, dir, base, base.Add(10*time.Minute), int64(len(entries)))
+	writeIndex(t, dir, []rotate.IndexEntry{{
+		File: "data.jsonl", From: base, To: base.Add(10 * time.Minute), Lines: int64(len(entries)),
+	}})
+	writeDataFile(t, dir, "data.jsonl", entries)
+	return dir, base
+}
+
+func TestFindPhases(t *testing.T) {
+	dir, base := writePhaseCapture(t)
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phases, err := FindPhases(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(n int) time.Time { return base.Add(time.Duration(n) * time.Minute) }
+	want := []Phase{
+		{Name: "rampup", From: at(2), To: at(5)},
+		{Name: "steady", From: at(5), To: at(8)},
+		{Name: "rampup", From: at(8)},
+	}
+	if fmt.Sprint(phases) != fmt.Sprint(want) {
+		t.Fatalf("phases = %v, want %v", phases, want)
+	}
+
+	if _, err := NewPhaseFilter(phases, "soak"); err == nil {
+		t.Error("want error for an unknown phase")
+	}
+}
+
+func TestFilterPhases(t *testing.T) {
+	dir, base := writePhaseCapture(t)
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phases, err := FindPhases(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := NewPhaseFilter(phases, "rampup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	if _, err := r.Scan(&Filter{Phases: pf}, func(e recv.LogEntry) bool {
+		if !recv.IsAnnotation(e.Labels) {
+			got = append(got, e.Message)
+		}
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"line 2", "line 3", "line 4", "line 8", "line 9", "line 10"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("rampup lines = %v, want %v", got, want)
+	}
+
+	steady, _ := NewPhaseFilter(phases, "steady")
+	if !(&Filter{Phases: steady}).SkipFile(&rotate.IndexEntry{From: base, To: base.Add(4 * time.Minute)}) {
+		t.Error("want a file before the steady phase skipped")
+	}
+}
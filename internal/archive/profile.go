@@ -0,0 +1,193 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// FileProfile records where time went while reading one data file.
+// Decompress excludes the disk reads it triggers, which are counted in Read.
+type FileProfile struct {
+	File       string        `json:"file"`
+	BytesRead  int64         `json:"bytes_read"` // bytes read from disk (compressed size for .zst)
+	Lines      int64         `json:"lines"`
+	Read       time.Duration `json:"read_ns"`
+	Decompress time.Duration `json:"decompress_ns"`
+	Decode     time.Duration `json:"decode_ns"`
+	Filter     time.Duration `json:"filter_ns"` // filtering or, for triage, per-entry analysis
+	Total      time.Duration `json:"total_ns"`
+}
+
+// Profile collects per-file read profiles for --profile. It is safe for
+// concurrent use. A nil *Profile disables profiling at no cost beyond a nil
+// check per line.
+type Profile struct {
+	mu    sync.Mutex
+	files []FileProfile
+}
+
+// NewProfile creates an empty profile.
+func NewProfile() *Profile {
+	return &Profile{}
+}
+
+// Files returns the recorded file profiles sorted by name.
+func (p *Profile) Files() []FileProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]FileProfile, len(p.files))
+	copy(out, p.files)
+	sort.Slice(out, func(i, j int) bool { return out[i].File < out[j].File })
+	return out
+}
+
+// Totals sums all file profiles. File is set to "total".
+func (p *Profile) Totals() FileProfile {
+	t := FileProfile{File: "total"}
+	for _, f := range p.Files() {
+		t.BytesRead += f.BytesRead
+		t.Lines += f.Lines
+		t.Read += f.Read
+		t.Decompress += f.Decompress
+		t.Decode += f.Decode
+		t.Filter += f.Filter
+		t.Total += f.Total
+	}
+	return t
+}
+
+// WriteText writes a per-file table followed by totals.
+func (p *Profile) WriteText(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(tw, "FILE\tBYTES READ\tLINES\tREAD\tDECOMPRESS\tDECODE\tFILTER\tTOTAL\t")
+	row := func(f FileProfile) {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t\n", f.File, formatBytes(f.BytesRead), f.Lines,
+			roundDuration(f.Read), roundDuration(f.Decompress), roundDuration(f.Decode),
+			roundDuration(f.Filter), roundDuration(f.Total))
+	}
+	for _, f := range p.Files() {
+		row(f)
+	}
+	row(p.Totals())
+	_ = tw.Flush()
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
+
+// fileProfiler measures one file. All methods are no-ops on nil, so scan
+// loops call them unconditionally.
+type fileProfiler struct {
+	profile *Profile
+	fp      FileProfile
+	start   time.Time
+}
+
+// profileFile starts profiling the named file. Returns nil when p is nil.
+func (p *Profile) profileFile(name string) *fileProfiler {
+	if p == nil {
+		return nil
+	}
+	return &fileProfiler{profile: p, fp: FileProfile{File: name}, start: time.Now()}
+}
+
+// wrapFile measures disk reads.
+func (fp *fileProfiler) wrapFile(r io.Reader) io.Reader {
+	if fp == nil {
+		return r
+	}
+	return &timedReader{r: r, d: &fp.fp.Read, n: &fp.fp.BytesRead}
+}
+
+// wrapDecoder measures decompression, which includes the disk reads it
+// triggers; done subtracts those.
+func (fp *fileProfiler) wrapDecoder(r io.Reader) io.Reader {
+	if fp == nil {
+		return r
+	}
+	return &timedReader{r: r, d: &fp.fp.Decompress}
+}
+
+// now returns the current time, or the zero time when not profiling.
+func (fp *fileProfiler) now() time.Time {
+	if fp == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func (fp *fileProfiler) decoded(start time.Time) {
+	if fp == nil {
+		return
+	}
+	fp.fp.Lines++
+	fp.fp.Decode += time.Since(start)
+}
+
+func (fp *fileProfiler) filtered(start time.Time) {
+	if fp == nil {
+		return
+	}
+	fp.fp.Filter += time.Since(start)
+}
+
+// done records the file in its profile.
+func (fp *fileProfiler) done() {
+	if fp == nil {
+		return
+	}
+	fp.fp.Total = time.Since(fp.start)
+	if fp.fp.Decompress > 0 {
+		fp.fp.Decompress -= fp.fp.Read
+		if fp.fp.Decompress < 0 {
+			fp.fp.Decompress = 0
+		}
+	}
+	fp.profile.mu.Lock()
+	fp.profile.files = append(fp.profile.files, fp.fp)
+	fp.profile.mu.Unlock()
+}
+
+type timedReader struct {
+	r io.Reader
+	d *time.Duration
+	n *int64
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.r.Read(p)
+	*t.d += time.Since(start)
+	if t.n != nil {
+		*t.n += int64(n)
+	}
+	return n, err
+}
+
+// openZstd opens a zstd stream. When profiling, decoding runs synchronously
+// so decompression time is attributed to the reading goroutine.
+func (fp *fileProfiler) openZstd(r io.Reader) (io.Reader, func(), error) {
+	var opts []zstd.DOption
+	if fp != nil {
+		opts = append(opts, zstd.WithDecoderConcurrency(1))
+	}
+	dec, err := zstd.NewReader(r, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("zstd open: %w", err)
+	}
+	return fp.wrapDecoder(dec), dec.Close, nil
+}
@@ -0,0 +1,149 @@
+package archive
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func setupProfileSource(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	first := makeEntries(20, base, "api")
+	second := makeEntries(30, base.Add(time.Minute), "worker")
+	writeMetadata(t, dir, base, base.Add(2*time.Minute), 50)
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", first)
+	writeCompressedDataFile(t, dir, "2024-01-15T100100-000.jsonl.zst", second)
+	writeIndex(t, dir, []rotate.IndexEntry{
+		{File: "2024-01-15T100000-000.jsonl", From: base, To: base.Add(19 * time.Second), Lines: 20},
+		{File: "2024-01-15T100100-000.jsonl.zst", From: base.Add(time.Minute), To: base.Add(time.Minute + 29*time.Second), Lines: 30},
+	})
+	return dir
+}
+
+func checkProfile(t *testing.T, p *Profile, wantFiles int, wantLines int64) {
+	t.Helper()
+	files := p.Files()
+	if len(files) != wantFiles {
+		t.Fatalf("profiled files = %d, want %d: %+v", len(files), wantFiles, files)
+	}
+	for _, f := range files {
+		if f.BytesRead == 0 {
+			t.Errorf("%s: bytes read = 0", f.File)
+		}
+		if f.Total <= 0 {
+			t.Errorf("%s: total = %v", f.File, f.Total)
+		}
+		if f.Decompress < 0 {
+			t.Errorf("%s: negative decompress time %v", f.File, f.Decompress)
+		}
+	}
+	if got := p.Totals().Lines; got != wantLines {
+		t.Errorf("total lines = %d, want %d", got, wantLines)
+	}
+}
+
+func TestProfile_NilSafe(t *testing.T) {
+	var p *Profile
+	fp := p.profileFile("x")
+	if fp != nil {
+		t.Fatal("expected nil profiler")
+	}
+	r := strings.NewReader("abc")
+	if fp.wrapFile(r) != r || fp.wrapDecoder(r) != r {
+		t.Error("nil profiler should not wrap readers")
+	}
+	if !fp.now().IsZero() {
+		t.Error("nil profiler now should be zero")
+	}
+	fp.decoded(time.Now())
+	fp.filtered(time.Now())
+	fp.done()
+}
+
+func TestProfile_Grep(t *testing.T) {
+	src := setupProfileSource(t)
+	p := NewProfile()
+	filter := &Filter{Grep: regexp.MustCompile("line 1")}
+
+	if _, err := Grep(src, filter, GrepConfig{Profile: p}, func(GrepMatch) {}, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, p, 2, 50)
+
+	files := p.Files()
+	if files[0].Decompress != 0 {
+		t.Errorf("plain file decompress = %v, want 0", files[0].Decompress)
+	}
+	if !strings.HasSuffix(files[1].File, ".zst") {
+		t.Errorf("second file = %q, want .zst", files[1].File)
+	}
+}
+
+func TestProfile_Slice(t *testing.T) {
+	src := setupProfileSource(t)
+	p := NewProfile()
+
+	err := Slice(SliceOptions{CaptureDir: src, OutputDir: filepath.Join(t.TempDir(), "out"), Profile: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, p, 2, 50)
+}
+
+func TestProfile_Export(t *testing.T) {
+	src := setupProfileSource(t)
+	p := NewProfile()
+	dst := filepath.Join(t.TempDir(), "out.jsonl")
+
+	if err := ExportWithOptions(src, dst, FormatJSONL, nil, nil, ExportOptions{Profile: p}); err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, p, 2, 50)
+}
+
+func TestProfile_Triage(t *testing.T) {
+	src := setupProfileSource(t)
+	p := NewProfile()
+
+	if _, err := Triage(src, TriageConfig{Jobs: 2, Profile: p}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// triage scans each file once for analysis and once for correlation
+	checkProfile(t, p, 4, 100)
+}
+
+func TestProfile_WriteText(t *testing.T) {
+	p := NewProfile()
+	p.files = []FileProfile{
+		{File: "b.jsonl.zst", BytesRead: 2048, Lines: 10, Read: time.Millisecond, Decompress: 2 * time.Millisecond, Total: 5 * time.Millisecond},
+		{File: "a.jsonl", BytesRead: 1024, Lines: 5, Decode: time.Millisecond, Total: 2 * time.Millisecond},
+	}
+
+	var buf bytes.Buffer
+	p.WriteText(&buf)
+	out := buf.String()
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("lines = %d, want 4:\n%s", len(lines), out)
+	}
+	for _, want := range []string{"FILE", "DECOMPRESS", "FILTER"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("header missing %s: %q", want, lines[0])
+		}
+	}
+	if !strings.Contains(lines[1], "a.jsonl") || !strings.Contains(lines[2], "b.jsonl.zst") {
+		t.Errorf("files not sorted:\n%s", out)
+	}
+	if !strings.Contains(lines[3], "total") || !strings.Contains(lines[3], "15") || !strings.Contains(lines[3], "7ms") {
+		t.Errorf("unexpected totals row: %q", lines[3])
+	}
+}
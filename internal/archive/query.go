@@ -0,0 +1,294 @@
+package archive
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// LabelOp is a label matcher operator in a log selector.
+type LabelOp string
+
+// Label matcher operators, as in LogQL.
+const (
+	LabelEqual    LabelOp = "="
+	LabelNotEqual LabelOp = "!="
+	LabelRegex    LabelOp = "=~"
+	LabelNotRegex LabelOp = "!~"
+)
+
+// LineOp is a line filter operator in a log selector.
+type LineOp string
+
+// Line filter operators, as in LogQL.
+const (
+	LineContains    LineOp = "|="
+	LineNotContains LineOp = "!="
+	LineRegex       LineOp = "|~"
+	LineNotRegex    LineOp = "!~"
+)
+
+// SelectorLabel matches one label of an entry. A missing label has the
+// empty value.
+type SelectorLabel struct {
+	Key   string
+	Op    LabelOp
+	Value string
+	re    *regexp.Regexp
+}
+
+// LineFilter matches the message of an entry.
+type LineFilter struct {
+	Op    LineOp
+	Value string
+	re    *regexp.Regexp
+}
+
+// LogSelector is a LogQL-style log stream selector with line filters, e.g.
+// {app="web", pod=~"api-.*"} |= "order completed" != "test". Both parts are
+// optional; an empty selector matches every entry.
+type LogSelector struct {
+	Labels []SelectorLabel
+	Lines  []LineFilter
+}
+
+// ParseLogSelector parses a log selector.
+func ParseLogSelector(s string) (*LogSelector, error) {
+	p := &queryParser{s: s}
+	sel, err := p.selector()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); !p.eof() {
+		return nil, p.errorf("unexpected %q", p.rest())
+	}
+	return sel, nil
+}
+
+// Match reports whether the entry passes every label matcher and line filter.
+func (s *LogSelector) Match(e recv.LogEntry) bool {
+	for _, m := range s.Labels {
+		v := e.Labels[m.Key]
+		switch m.Op {
+		case LabelEqual:
+			if v != m.Value {
+				return false
+			}
+		case LabelNotEqual:
+			if v == m.Value {
+				return false
+			}
+		case LabelRegex:
+			if !m.re.MatchString(v) {
+				return false
+			}
+		case LabelNotRegex:
+			if m.re.MatchString(v) {
+				return false
+			}
+		}
+	}
+	for _, f := range s.Lines {
+		switch f.Op {
+		case LineContains:
+			if !strings.Contains(e.Message, f.Value) {
+				return false
+			}
+		case LineNotContains:
+			if strings.Contains(e.Message, f.Value) {
+				return false
+			}
+		case LineRegex:
+			if !f.re.MatchString(e.Message) {
+				return false
+			}
+		case LineNotRegex:
+			if f.re.MatchString(e.Message) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// String formats the selector in canonical form.
+func (s *LogSelector) String() string {
+	var b strings.Builder
+	if len(s.Labels) > 0 {
+		b.WriteByte('{')
+		for i, m := range s.Labels {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(m.Key + string(m.Op) + strconv.Quote(m.Value))
+		}
+		b.WriteByte('}')
+	}
+	for _, f := range s.Lines {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(string(f.Op) + " " + strconv.Quote(f.Value))
+	}
+	return b.String()
+}
+
+// queryParser is a small recursive-descent parser over a query string.
+type queryParser struct {
+	s   string
+	pos int
+}
+
+func (p *queryParser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *queryParser) rest() string { return p.s[p.pos:] }
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("parse %q at offset %d: %s", p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes tok if the input continues with it.
+func (p *queryParser) accept(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.rest(), tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(tok string) error {
+	if !p.accept(tok) {
+		if p.eof() {
+			return p.errorf("expected %q, got end of input", tok)
+		}
+		return p.errorf("expected %q", tok)
+	}
+	return nil
+}
+
+// ident reads a label name or function name.
+func (p *queryParser) ident() (string, error) {
+	p.skipSpace()
+	start := p.pos
+	for !p.eof() {
+		c := p.s[p.pos]
+		if c == '_' || unicode.IsLetter(rune(c)) || (p.pos > start && unicode.IsDigit(rune(c))) {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if p.pos == start {
+		return "", p.errorf("expected identifier")
+	}
+	return p.s[start:p.pos], nil
+}
+
+// str reads a double-quoted (Go escapes) or backtick-quoted string.
+func (p *queryParser) str() (string, error) {
+	p.skipSpace()
+	if p.eof() || (p.s[p.pos] != '"' && p.s[p.pos] != '`') {
+		return "", p.errorf("expected quoted string")
+	}
+	quote := p.s[p.pos]
+	for i := p.pos + 1; i < len(p.s); i++ {
+		switch {
+		case p.s[i] == '\\' && quote == '"':
+			i++
+		case p.s[i] == quote:
+			v, err := strconv.Unquote(p.s[p.pos : i+1])
+			if err != nil {
+				return "", p.errorf("invalid string: %v", err)
+			}
+			p.pos = i + 1
+			return v, nil
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// number reads a decimal number.
+func (p *queryParser) number() (float64, error) {
+	p.skipSpace()
+	start := p.pos
+	for !p.eof() && strings.IndexByte("+-.0123456789eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("expected number")
+	}
+	return v, nil
+}
+
+// oneOf consumes the first of ops the input continues with.
+func (p *queryParser) oneOf(ops ...string) (string, bool) {
+	for _, op := range ops {
+		if p.accept(op) {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *queryParser) selector() (*LogSelector, error) {
+	sel := &LogSelector{}
+	if p.accept("{") {
+		for !p.accept("}") {
+			if len(sel.Labels) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			key, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			op, ok := p.oneOf("=~", "!~", "!=", "=")
+			if !ok {
+				return nil, p.errorf("expected label operator (=, !=, =~, !~)")
+			}
+			val, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			m := SelectorLabel{Key: key, Op: LabelOp(op), Value: val}
+			if m.Op == LabelRegex || m.Op == LabelNotRegex {
+				// Label regexes are fully anchored, as in LogQL.
+				if m.re, err = regexp.Compile("^(?:" + val + ")$"); err != nil {
+					return nil, p.errorf("invalid regex for %s: %v", key, err)
+				}
+			}
+			sel.Labels = append(sel.Labels, m)
+		}
+	}
+	for {
+		op, ok := p.oneOf("|=", "!=", "|~", "!~")
+		if !ok {
+			return sel, nil
+		}
+		val, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		f := LineFilter{Op: LineOp(op), Value: val}
+		if f.Op == LineRegex || f.Op == LineNotRegex {
+			if f.re, err = regexp.Compile(val); err != nil {
+				return nil, p.errorf("invalid regex: %v", err)
+			}
+		}
+		sel.Lines = append(sel.Lines, f)
+	}
+}
@@ -0,0 +1,90 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestParseLogSelector(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{``, ``},
+		{`{}`, ``},
+		{`{app="web"}`, `{app="web"}`},
+		{` { app = "web" , pod=~"api-.*", level!="debug",env!~"dev|test" } `, `{app="web", pod=~"api-.*", level!="debug", env!~"dev|test"}`},
+		{`|= "deadlock"`, `|= "deadlock"`},
+		{`{app="web"} |= "order completed" != "retry" |~ "id=[0-9]+" !~ "(?i)test"`, `{app="web"} |= "order completed" != "retry" |~ "id=[0-9]+" !~ "(?i)test"`},
+		{"|= `C:\\path` |= \"quote \\\" inside\"", `|= "C:\\path" |= "quote \" inside"`},
+	}
+	for _, tt := range tests {
+		sel, err := ParseLogSelector(tt.in)
+		if err != nil {
+			t.Errorf("ParseLogSelector(%q): %v", tt.in, err)
+			continue
+		}
+		if got := sel.String(); got != tt.want {
+			t.Errorf("ParseLogSelector(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseLogSelector_Errors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{app}`, "expected label operator"},
+		{`{app="web"`, "expected \",\""},
+		{`{app=web}`, "expected quoted string"},
+		{`{="web"}`, "expected identifier"},
+		{`{app=~"[a"}`, "invalid regex for app"},
+		{`|~ "(unclosed"`, "invalid regex"},
+		{`|= "unterminated`, "unterminated string"},
+		{`|= "x" trailing`, "unexpected \"trailing\""},
+	}
+	for _, tt := range tests {
+		_, err := ParseLogSelector(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseLogSelector(%q) error = %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestLogSelectorMatch(t *testing.T) {
+	entry := recv.LogEntry{
+		Labels:  map[string]string{"app": "web", "pod": "api-7f9c"},
+		Message: "order 42 completed in 12ms",
+	}
+	tests := []struct {
+		sel  string
+		want bool
+	}{
+		{``, true},
+		{`{app="web"}`, true},
+		{`{app="worker"}`, false},
+		{`{app!="worker"}`, true},
+		{`{level=""}`, true}, // missing label is empty
+		{`{pod=~"api-.*"}`, true},
+		{`{pod=~"api"}`, false}, // label regex is anchored
+		{`{pod!~"api-.*"}`, false},
+		{`|= "completed"`, true},
+		{`|= "failed"`, false},
+		{`!= "failed"`, true},
+		{`|~ "order \\d+"`, true},
+		{`!~ "\\d+ms"`, false},
+		{`{app="web"} |= "order" != "completed"`, false},
+	}
+	for _, tt := range tests {
+		sel, err := ParseLogSelector(tt.sel)
+		if err != nil {
+			t.Fatalf("ParseLogSelector(%q): %v", tt.sel, err)
+		}
+		if got := sel.Match(entry); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.sel, got, tt.want)
+		}
+	}
+}
@@ -4,14 +4,11 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
-	"github.com/klauspost/compress/zstd"
-
 	"github.com/ppiankov/logtap/internal/recv"
 	"github.com/ppiankov/logtap/internal/rotate"
 )
@@ -22,13 +19,16 @@ type FileInfo struct {
 	Name   string
 	Index  *rotate.IndexEntry // nil for orphan files
 	Orphan bool
+	Remote string // offload URL, fetched on read when Path is missing
 }
 
 // Reader provides streaming access to a capture directory.
 type Reader struct {
-	dir   string
-	meta  *recv.Metadata
-	files []FileInfo
+	dir     string
+	meta    *recv.Metadata
+	files   []FileInfo
+	profile *Profile
+	expand  bool
 }
 
 // NewReader opens a capture directory and resolves its file list.
@@ -38,39 +38,41 @@ func NewReader(dir string) (*Reader, error) {
 		return nil, fmt.Errorf("read metadata: %w", err)
 	}
 
-	index, err := readIndex(dir)
-	if err != nil && !os.IsNotExist(err) {
-		return nil, fmt.Errorf("read index: %w", err)
-	}
-
-	// build file list from index
-	indexedFiles := make(map[string]bool, len(index))
 	var files []FileInfo
-	for i := range index {
-		entry := &index[i]
-		indexedFiles[entry.File] = true
-		files = append(files, FileInfo{
-			Path:  filepath.Join(dir, entry.File),
-			Name:  entry.File,
-			Index: entry,
-		})
-	}
-
-	// discover orphans
-	orphans, err := discoverOrphans(dir, indexedFiles)
-	if err != nil {
-		return nil, fmt.Errorf("discover orphans: %w", err)
+	for i, d := range captureDirs(dir, meta) {
+		dirFiles, err := readDirFiles(d)
+		if err != nil {
+			if i > 0 {
+				return nil, fmt.Errorf("shard %s: %w", d, err)
+			}
+			return nil, err
+		}
+		files = append(files, dirFiles...)
 	}
-	files = append(files, orphans...)
 
-	// sort by filename for chronological order
+	// sort by filename for chronological order; shards may reuse a name
 	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name < files[j].Name
+		if files[i].Name != files[j].Name {
+			return files[i].Name < files[j].Name
+		}
+		return files[i].Path < files[j].Path
 	})
 
 	return &Reader{dir: dir, meta: meta, files: files}, nil
 }
 
+// SetProfile records per-file read profiles of subsequent scans in p.
+func (r *Reader) SetProfile(p *Profile) {
+	r.profile = p
+}
+
+// SetExpandRepeats makes subsequent scans pass an entry collapsed by recv
+// --dedup-window to fn once per line it stands for, with RepeatCount
+// cleared. By default fn sees the entry once, with its RepeatCount.
+func (r *Reader) SetExpandRepeats(expand bool) {
+	r.expand = expand
+}
+
 // Metadata returns the capture session metadata.
 func (r *Reader) Metadata() *recv.Metadata {
 	return r.meta
@@ -150,7 +152,7 @@ func (r *Reader) ServiceSummary() []ServiceEntry {
 func (r *Reader) Scan(filter *Filter, fn func(recv.LogEntry) bool) (int64, error) {
 	var scanned int64
 	for _, f := range r.files {
-		if filter != nil && !f.Orphan && f.Index != nil && filter.SkipFile(f.Index) {
+		if filter.skip(f) {
 			continue
 		}
 
@@ -167,7 +169,7 @@ func (r *Reader) Scan(filter *Filter, fn func(recv.LogEntry) bool) (int64, error
 }
 
 func (r *Reader) scanFile(f FileInfo, filter *Filter, fn func(recv.LogEntry) bool) (int64, bool, error) {
-	file, err := os.Open(f.Path)
+	file, name, err := openDataFile(f)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return 0, false, nil // file rotated away during scan
@@ -176,13 +178,16 @@ func (r *Reader) scanFile(f FileInfo, filter *Filter, fn func(recv.LogEntry) boo
 	}
 	defer func() { _ = file.Close() }()
 
-	var reader io.Reader = file
-	if strings.HasSuffix(f.Name, ".zst") {
-		dec, err := zstd.NewReader(file)
+	prof := r.profile.profileFile(f.Name)
+	defer prof.done()
+
+	reader := prof.wrapFile(file)
+	if strings.HasSuffix(name, ".zst") {
+		dec, closeDec, err := prof.openZstd(reader)
 		if err != nil {
-			return 0, false, fmt.Errorf("zstd open: %w", err)
+			return 0, false, err
 		}
-		defer dec.Close()
+		defer closeDec()
 		reader = dec
 	}
 
@@ -196,14 +201,29 @@ func (r *Reader) scanFile(f FileInfo, filter *Filter, fn func(recv.LogEntry) boo
 		}
 
 		var entry recv.LogEntry
-		if err := json.Unmarshal(line, &entry); err != nil {
+		t := prof.now()
+		err := json.Unmarshal(line, &entry)
+		prof.decoded(t)
+		if err != nil {
 			continue // skip malformed lines
 		}
 		scanned++
 
-		if filter != nil && !filter.MatchEntry(entry) {
+		t = prof.now()
+		match := filter == nil || filter.MatchEntry(entry)
+		prof.filtered(t)
+		if !match {
 			continue
 		}
+		if r.expand && entry.RepeatCount > 1 {
+			n := entry.RepeatCount
+			entry.RepeatCount = 0
+			for ; n > 1; n-- {
+				if !fn(entry) {
+					return scanned, true, nil
+				}
+			}
+		}
 		if !fn(entry) {
 			return scanned, true, nil
 		}
@@ -211,6 +231,69 @@ func (r *Reader) scanFile(f FileInfo, filter *Filter, fn func(recv.LogEntry) boo
 	return scanned, false, scanner.Err()
 }
 
+// captureDirs returns the directories holding a capture's data files: dir
+// followed by the shard directories of a sharded receiver. Relative shard
+// paths are resolved against dir.
+func captureDirs(dir string, meta *recv.Metadata) []string {
+	dirs := []string{dir}
+	for _, shard := range meta.Shards {
+		if !filepath.IsAbs(shard) {
+			shard = filepath.Join(dir, shard)
+		}
+		dirs = append(dirs, shard)
+	}
+	return dirs
+}
+
+// outsideDir reports whether path lies outside dir, so that walking dir
+// does not reach it.
+func outsideDir(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readDirFiles resolves the indexed and orphan data files of one directory.
+func readDirFiles(dir string) ([]FileInfo, error) {
+	index, err := readIndex(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+
+	offload, err := ReadOffloadManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read offload manifest: %w", err)
+	}
+
+	// build file list from index
+	indexedFiles := make(map[string]bool, len(index))
+	var files []FileInfo
+	for i := range index {
+		entry := &index[i]
+		indexedFiles[entry.File] = true
+		files = append(files, FileInfo{
+			Path:   filepath.Join(dir, entry.File),
+			Name:   entry.File,
+			Index:  entry,
+			Remote: offload.Files[entry.File],
+		})
+	}
+
+	// discover orphans
+	orphans, err := discoverOrphans(dir, indexedFiles)
+	if err != nil {
+		return nil, fmt.Errorf("discover orphans: %w", err)
+	}
+	return append(files, orphans...), nil
+}
+
 func readIndex(dir string) ([]rotate.IndexEntry, error) {
 	data, err := os.ReadFile(filepath.Join(dir, "index.jsonl"))
 	if err != nil {
@@ -246,7 +329,7 @@ func discoverOrphans(dir string, indexed map[string]bool) ([]FileInfo, error) {
 		if name == "index.jsonl" || name == "metadata.json" || name == ".gitkeep" {
 			continue
 		}
-		if !strings.HasSuffix(name, ".jsonl") && !strings.HasSuffix(name, ".jsonl.zst") {
+		if !isDataFileName(strings.TrimSuffix(name, EncryptedSuffix)) {
 			continue
 		}
 		if indexed[name] {
@@ -260,3 +343,9 @@ func discoverOrphans(dir string, indexed map[string]bool) ([]FileInfo, error) {
 	}
 	return orphans, nil
 }
+
+// isDataFileName reports whether name is a (possibly compressed) JSONL data
+// file name.
+func isDataFileName(name string) bool {
+	return strings.HasSuffix(name, ".jsonl") || strings.HasSuffix(name, ".jsonl.zst")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -312,6 +313,73 @@ func TestReaderMultipleFiles(t *testing.T) {
 	}
 }
 
+func writeShardedCapture(t *testing.T) (dir string, base time.Time) {
+	t.Helper()
+	dir, shard := t.TempDir(), t.TempDir()
+	base = time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	meta := recv.Metadata{Version: 1, Format: "jsonl", Started: base, Shards: []string{shard}}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "metadata.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// both shards rotated in the same second, so their file names collide
+	name := "2024-01-15T100000-000.jsonl"
+	writeDataFile(t, dir, name, makeEntries(3, base, "api"))
+	writeIndex(t, dir, []rotate.IndexEntry{{File: name, From: base, To: base.Add(2 * time.Second), Lines: 3,
+		Labels: map[string]map[string]int64{"app": {"api": 3}}}})
+	writeDataFile(t, shard, name, makeEntries(4, base, "web"))
+	writeIndex(t, shard, []rotate.IndexEntry{{File: name, From: base, To: base.Add(3 * time.Second), Lines: 4,
+		Labels: map[string]map[string]int64{"app": {"web": 4}}}})
+	// an orphan in the shard is found like one in the primary
+	writeDataFile(t, shard, "2024-01-15T100010-000.jsonl", makeEntries(2, base.Add(10*time.Second), "web"))
+	return dir, base
+}
+
+func TestReaderShards(t *testing.T) {
+	dir, _ := writeShardedCapture(t)
+
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Files()) != 3 {
+		t.Fatalf("files = %d, want 3 across both shards", len(r.Files()))
+	}
+	if r.TotalLines() != 9 {
+		t.Errorf("TotalLines = %d, want 9", r.TotalLines())
+	}
+
+	apps := make(map[string]int)
+	if _, err := r.Scan(nil, func(e recv.LogEntry) bool {
+		apps[e.Labels["app"]]++
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if apps["api"] != 3 || apps["web"] != 6 {
+		t.Errorf("scanned %v, want api=3 web=6", apps)
+	}
+}
+
+func TestReaderMissingShard(t *testing.T) {
+	dir, _ := writeShardedCapture(t)
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(meta.Shards[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReader(dir); err == nil {
+		t.Error("expected error for a missing shard directory")
+	}
+}
+
 func TestReaderMissingMetadata(t *testing.T) {
 	dir := t.TempDir()
 	_, err := NewReader(dir)
@@ -405,3 +473,92 @@ func TestReaderNoIndex(t *testing.T) {
 		t.Errorf("got %d entries, want 3", len(got))
 	}
 }
+
+func TestReaderPartitionedScan(t *testing.T) {
+	dir := t.TempDir()
+	rot, err := rotate.New(rotate.Config{Dir: dir, MaxFile: 200, MaxDisk: 1 << 20, PartitionBy: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := range 30 {
+		app := []string{"web", "api", "worker"}[i%3]
+		e := recv.LogEntry{Timestamp: base.Add(time.Duration(i) * time.Second), Labels: map[string]string{"app": app}, Message: fmt.Sprintf("line %d", i)}
+		data, _ := json.Marshal(e)
+		if _, err := rot.WriteLabeled(append(data, '\n'), e.Timestamp, e.Labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rot.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeMetadata(t, dir, base, base.Add(30*time.Second), 30)
+
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var matched int
+	scanned, err := r.Scan(&Filter{Labels: []LabelMatcher{{Key: "app", Value: "web"}}}, func(e recv.LogEntry) bool {
+		matched++
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the web partition's files are read
+	if matched != 10 || scanned != 10 {
+		t.Errorf("matched %d, scanned %d; want 10 and 10", matched, scanned)
+	}
+}
+
+func TestReaderExpandRepeats(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	writeMetadata(t, dir, base, base, 2)
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "retrying", RepeatCount: 3},
+		{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "done"},
+	})
+
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counts []int64
+	if _, err := r.Scan(nil, func(e recv.LogEntry) bool {
+		counts = append(counts, e.RepeatCount)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(counts) != "[3 0]" {
+		t.Errorf("repeat counts = %v, want [3 0]", counts)
+	}
+
+	r.SetExpandRepeats(true)
+	var msgs []string
+	if _, err := r.Scan(nil, func(e recv.LogEntry) bool {
+		if e.RepeatCount != 0 {
+			t.Errorf("expanded entry has repeat_count %d", e.RepeatCount)
+		}
+		msgs = append(msgs, e.Message)
+		return len(msgs) < 2 // stopping inside a repeat ends the scan
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Errorf("scan after stop saw %v", msgs)
+	}
+
+	msgs = nil
+	if _, err := r.Scan(nil, func(e recv.LogEntry) bool {
+		msgs = append(msgs, e.Message)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(msgs, ",") != "retrying,retrying,retrying,done" {
+		t.Errorf("expanded = %v", msgs)
+	}
+}
@@ -3,7 +3,11 @@ package archive
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ppiankov/logtap/internal/recv"
@@ -11,17 +15,27 @@ import (
 
 // ReportConfig controls report generation behavior.
 type ReportConfig struct {
-	Jobs int // parallel triage workers
-	Top  int // top error signatures
+	Jobs   int     // parallel triage workers
+	Top    int     // top error signatures
+	Owners *Owners // error ownership rules (nil = none)
+
+	Latency *LatencyConfig // request duration extraction for latency percentiles (nil = off)
+
+	// QueryURL is the base URL of a logtap recv serving the capture, e.g.
+	// http://localhost:3100; top errors in report.html link to its live
+	// query API filtered to the signature and time range.
+	QueryURL string
 }
 
 // ReportResult is the single-artifact incident deliverable.
 type ReportResult struct {
-	Capture   ReportCapture  `json:"capture"`
-	Labels    map[string]int `json:"labels"`
-	Triage    ReportTriage   `json:"triage"`
-	Severity  string         `json:"severity"`
-	Suggested []string       `json:"suggested_commands,omitempty"`
+	Capture     ReportCapture  `json:"capture"`
+	Labels      map[string]int `json:"labels"`
+	Triage      ReportTriage   `json:"triage"`
+	Severity    string         `json:"severity"`
+	Suggested   []string       `json:"suggested_commands,omitempty"`
+	Annotations []Annotation   `json:"annotations,omitempty"` // reviewer bookmarks from the open TUI
+	QueryURL    string         `json:"query_url,omitempty"`
 }
 
 // ReportCapture holds capture metadata for the report.
@@ -41,7 +55,9 @@ type ReportTriage struct {
 	ErrorLines   int64            `json:"error_lines"`
 	ErrorRatePct float64          `json:"error_rate_pct"`
 	TopErrors    []ErrorSignature `json:"top_errors,omitempty"`
+	Owners       []OwnerRollup    `json:"owners,omitempty"`
 	Windows      TriageWindows    `json:"windows"`
+	Latency      *LatencyReport   `json:"latency,omitempty"`
 }
 
 // Report generates a combined inspect + triage result for a capture directory.
@@ -54,8 +70,10 @@ func Report(dir string, cfg ReportConfig, progress func(TriageProgress)) (*Repor
 
 	// Triage
 	triageCfg := TriageConfig{
-		Jobs: cfg.Jobs,
-		Top:  cfg.Top,
+		Jobs:    cfg.Jobs,
+		Top:     cfg.Top,
+		Owners:  cfg.Owners,
+		Latency: cfg.Latency,
 	}
 	triage, err := Triage(dir, triageCfg, progress)
 	if err != nil {
@@ -64,13 +82,17 @@ func Report(dir string, cfg ReportConfig, progress func(TriageProgress)) (*Repor
 
 	// Build report
 	result := &ReportResult{
-		Capture: buildReportCapture(dir, summary),
-		Labels:  buildLabelSummary(summary),
-		Triage:  buildReportTriage(triage),
+		Capture:  buildReportCapture(dir, summary),
+		Labels:   buildLabelSummary(summary),
+		Triage:   buildReportTriage(triage),
+		QueryURL: strings.TrimRight(cfg.QueryURL, "/"),
 	}
 
 	result.Severity = classifySeverity(result.Triage.ErrorRatePct, triage.Errors)
 	result.Suggested = buildSuggestions(dir, triage)
+	if result.Annotations, err = ReadAnnotations(dir); err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
@@ -105,7 +127,9 @@ func buildReportTriage(t *TriageResult) ReportTriage {
 		TotalLines: t.TotalLines,
 		ErrorLines: t.ErrorLines,
 		TopErrors:  t.Errors,
+		Owners:     t.Owners,
 		Windows:    t.Windows,
+		Latency:    t.Latency,
 	}
 	if t.TotalLines > 0 {
 		rt.ErrorRatePct = float64(t.ErrorLines) / float64(t.TotalLines) * 100
@@ -172,6 +196,139 @@ func (r *ReportResult) WriteJSON(w io.Writer) error {
 	return enc.Encode(r)
 }
 
+// ReportLink is a labelled URL listed in the Markdown summary.
+type ReportLink struct {
+	Label string
+	URL   string
+}
+
+// summaryTopErrors is the number of signatures in the Markdown summary.
+const summaryTopErrors = 3
+
+// summarySignatureLen caps signature length in the Markdown summary so the
+// block stays readable in chat clients.
+const summarySignatureLen = 100
+
+// WriteMarkdownSummary writes a short Markdown block sized for pasting into
+// Slack or a PR description: volume, error rate, the top three error
+// signatures, and links. It is not a substitute for the HTML report.
+func (r *ReportResult) WriteMarkdownSummary(w io.Writer, links []ReportLink) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**logtap report** — severity **%s** — `%s`\n\n", r.Severity, r.Capture.Dir)
+
+	lines := fmt.Sprintf("- **Lines:** %s", FormatCount(r.Triage.TotalLines))
+	if r.Capture.DurationSeconds > 0 {
+		dur := time.Duration(r.Capture.DurationSeconds * float64(time.Second))
+		lines += fmt.Sprintf(" over %s", dur.Truncate(time.Second))
+	}
+	if !r.Capture.Started.IsZero() {
+		lines += fmt.Sprintf(" (from %s)", r.Capture.Started.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+	b.WriteString(lines + "\n")
+
+	fmt.Fprintf(&b, "- **Errors:** %s (%.1f%%)", FormatCount(r.Triage.ErrorLines), r.Triage.ErrorRatePct)
+	if pw := r.Triage.Windows.PeakError; pw != nil {
+		fmt.Fprintf(&b, ", peak %s from %s", pw.Desc, pw.From)
+	}
+	b.WriteString("\n")
+
+	if len(r.Triage.TopErrors) > 0 {
+		b.WriteString("- **Top errors:**\n")
+		for i, e := range r.Triage.TopErrors {
+			if i == summaryTopErrors {
+				break
+			}
+			fmt.Fprintf(&b, "  %d. `%s` × %s", i+1, summarySignature(e.Signature), FormatCount(e.Count))
+			if r.Triage.Owners != nil {
+				fmt.Fprintf(&b, " — %s", e.ownerName())
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(r.Triage.Owners) > 0 {
+		parts := make([]string, 0, len(r.Triage.Owners))
+		for _, o := range r.Triage.Owners {
+			parts = append(parts, fmt.Sprintf("%s %s", o.Owner, FormatCount(o.ErrorLines)))
+		}
+		fmt.Fprintf(&b, "- **Errors by owner:** %s\n", strings.Join(parts, " · "))
+	}
+
+	if l := r.Triage.Latency; l != nil && len(l.Groups) > 0 {
+		parts := make([]string, 0, summaryTopErrors)
+		for i, g := range l.Groups {
+			if i == summaryTopErrors {
+				break
+			}
+			parts = append(parts, fmt.Sprintf("%s p50 %.0fms / p95 %.0fms / p99 %.0fms", g.groupName(), g.P50, g.P95, g.P99))
+		}
+		fmt.Fprintf(&b, "- **Latency:** %s\n", strings.Join(parts, " · "))
+	}
+
+	if len(r.Annotations) > 0 {
+		b.WriteString("- **Bookmarks:**\n")
+		for _, a := range r.Annotations {
+			fmt.Fprintf(&b, "  - %s `%s`", a.Timestamp.UTC().Format("15:04:05"), summarySignature(a.Message))
+			if a.Note != "" {
+				fmt.Fprintf(&b, " — %s", a.Note)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(links) > 0 {
+		parts := make([]string, 0, len(links))
+		for _, l := range links {
+			parts = append(parts, fmt.Sprintf("[%s](%s)", l.Label, l.URL))
+		}
+		fmt.Fprintf(&b, "- **Links:** %s\n", strings.Join(parts, " · "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// signatureQueryLimit caps the entries a signature link asks for.
+const signatureQueryLimit = 1000
+
+// SignatureLink returns the URL of the live query API of QueryURL for the
+// entries of e, from its first occurrence to the end of the capture, or ""
+// without a QueryURL.
+func (r *ReportResult) SignatureLink(e ErrorSignature) string {
+	if r.QueryURL == "" {
+		return ""
+	}
+	q := url.Values{}
+	q.Set("grep", SignaturePattern(e.Signature))
+	q.Set("from", e.FirstSeen.UTC().Format(time.RFC3339Nano))
+	if !r.Capture.Stopped.IsZero() {
+		q.Set("to", r.Capture.Stopped.UTC().Format(time.RFC3339Nano))
+	}
+	q.Set("files", "true")
+	q.Set("limit", strconv.Itoa(signatureQueryLimit))
+	return r.QueryURL + "/logtap/api/v1/query?" + q.Encode()
+}
+
+// signatureCell renders the signature cell of a top error, linked to the
+// live query API when there is a QueryURL.
+func (r *ReportResult) signatureCell(e ErrorSignature) string {
+	sig := html.EscapeString(e.Signature)
+	if link := r.SignatureLink(e); link != "" {
+		return fmt.Sprintf(`<a href="%s" title="open in the live query API"><code>%s</code></a>`, html.EscapeString(link), sig)
+	}
+	return "<code>" + sig + "</code>"
+}
+
+// summarySignature makes a signature safe for an inline code span and
+// truncates it to summarySignatureLen runes.
+func summarySignature(sig string) string {
+	sig = strings.Join(strings.Fields(strings.ReplaceAll(sig, "`", "'")), " ")
+	if r := []rune(sig); len(r) > summarySignatureLen {
+		sig = string(r[:summarySignatureLen-1]) + "…"
+	}
+	return sig
+}
+
 // WriteHTML writes a self-contained HTML report.
 // It delegates to the triage HTML writer with an extended header.
 func (r *ReportResult) WriteHTML(w io.Writer, triageResult *TriageResult, meta *recv.Metadata) error {
@@ -229,14 +386,47 @@ func (r *ReportResult) WriteHTML(w io.Writer, triageResult *TriageResult, meta *
 	// Top errors table
 	if len(r.Triage.TopErrors) > 0 {
 		p(`<h2>Top Errors</h2>`)
-		p(`<table><thead><tr><th>Signature</th><th>Count</th><th>First Seen</th></tr></thead><tbody>`)
+		showOwners := r.Triage.Owners != nil
+		if showOwners {
+			p(`<table><thead><tr><th>Signature</th><th>Owner</th><th>Count</th><th>First Seen</th></tr></thead><tbody>`)
+		} else {
+			p(`<table><thead><tr><th>Signature</th><th>Count</th><th>First Seen</th></tr></thead><tbody>`)
+		}
 		limit := len(r.Triage.TopErrors)
 		if limit > 20 {
 			limit = 20
 		}
 		for _, e := range r.Triage.TopErrors[:limit] {
-			pf("<tr><td><code>%s</code></td><td>%d</td><td>%s</td></tr>\n",
-				e.Signature, e.Count, e.FirstSeen.Format("15:04:05"))
+			if showOwners {
+				pf("<tr><td>%s</td><td>%s</td><td>%d</td><td>%s</td></tr>\n",
+					r.signatureCell(e), html.EscapeString(e.ownerName()), e.Count, e.FirstSeen.Format("15:04:05"))
+				continue
+			}
+			pf("<tr><td>%s</td><td>%d</td><td>%s</td></tr>\n",
+				r.signatureCell(e), e.Count, e.FirstSeen.Format("15:04:05"))
+		}
+		p(`</tbody></table>`)
+	}
+
+	// Per-owner rollups
+	if len(r.Triage.Owners) > 0 {
+		p(`<h2>Errors by Owner</h2>`)
+		p(`<table><thead><tr><th>Owner</th><th>Errors</th><th>Signatures</th><th>Top Signature</th></tr></thead><tbody>`)
+		for _, o := range r.Triage.Owners {
+			pf("<tr><td>%s</td><td>%d</td><td>%d</td><td><code>%s</code></td></tr>\n",
+				html.EscapeString(o.Owner), o.ErrorLines, o.Signatures, html.EscapeString(o.TopSignature))
+		}
+		p(`</tbody></table>`)
+	}
+
+	// Reviewer bookmarks
+	if len(r.Annotations) > 0 {
+		p(`<h2>Bookmarks</h2>`)
+		p(`<table><thead><tr><th>Time</th><th>Labels</th><th>Note</th><th>Entry</th></tr></thead><tbody>`)
+		for _, a := range r.Annotations {
+			pf("<tr><td>%s</td><td>%s</td><td>%s</td><td><code>%s</code></td></tr>\n",
+				a.Timestamp.UTC().Format(time.RFC3339), html.EscapeString(flattenLabels(a.Labels)),
+				html.EscapeString(a.Note), html.EscapeString(a.Message))
 		}
 		p(`</tbody></table>`)
 	}
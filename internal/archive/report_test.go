@@ -3,8 +3,11 @@ package archive
 import (
 	"bytes"
 	"encoding/json"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -132,3 +135,138 @@ func TestReport_HTML(t *testing.T) {
 		t.Error("expected 'incident report' in HTML")
 	}
 }
+
+func TestReport_SignatureLinks(t *testing.T) {
+	first := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	r := &ReportResult{
+		Capture:  ReportCapture{Stopped: first.Add(time.Hour)},
+		QueryURL: "http://localhost:3100",
+		Triage: ReportTriage{TopErrors: []ErrorSignature{
+			{Signature: "timeout after <DUR> to <IP> (attempt <N>)", Count: 3, FirstSeen: first},
+		}},
+	}
+	link, err := url.Parse(r.SignatureLink(r.Triage.TopErrors[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Path != "/logtap/api/v1/query" {
+		t.Errorf("path = %q", link.Path)
+	}
+	q := link.Query()
+	if q.Get("from") != "2024-01-15T10:00:00Z" || q.Get("to") != "2024-01-15T11:00:00Z" || q.Get("files") != "true" {
+		t.Errorf("query = %v", q)
+	}
+	re := regexp.MustCompile(q.Get("grep"))
+	if msg := "timeout after 230ms to 10.0.0.1 (attempt 12345)"; !re.MatchString(msg) {
+		t.Errorf("grep %q does not match %q", re, msg)
+	}
+	if re.MatchString("timeout after 230ms to 10.0.0.1 attempt") {
+		t.Errorf("grep %q matches a different message", re)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteHTML(&buf, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<a href="http://localhost:3100/logtap/api/v1/query?`) {
+		t.Error("expected the top error linked to the live query API")
+	}
+
+	r.QueryURL = ""
+	if link := r.SignatureLink(r.Triage.TopErrors[0]); link != "" {
+		t.Errorf("link without QueryURL = %q", link)
+	}
+}
+
+func TestReport_MarkdownSummary(t *testing.T) {
+	r := &ReportResult{
+		Capture:  ReportCapture{Dir: "./capture", DurationSeconds: 300},
+		Severity: "high",
+		Triage: ReportTriage{
+			TotalLines:   12345,
+			ErrorLines:   321,
+			ErrorRatePct: 2.6,
+			TopErrors: []ErrorSignature{
+				{Signature: "connection refused to `db`", Count: 200},
+				{Signature: "timeout after <N>ms", Count: 100},
+				{Signature: strings.Repeat("x", 150), Count: 20},
+				{Signature: "fourth", Count: 1},
+			},
+			Windows: TriageWindows{PeakError: &TimeWindow{From: "2025-01-15T10:02:00Z", Desc: "80 errors in 5 minutes"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	links := []ReportLink{{Label: "HTML report", URL: "out/report.html"}, {Label: "Grafana", URL: "https://g/d/1"}}
+	if err := r.WriteMarkdownSummary(&buf, links); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"severity **high** — `./capture`",
+		"**Lines:** 12,345 over 5m0s",
+		"**Errors:** 321 (2.6%), peak 80 errors in 5 minutes from 2025-01-15T10:02:00Z",
+		"1. `connection refused to 'db'` × 200",
+		"2. `timeout after <N>ms` × 100",
+		strings.Repeat("x", 99) + "…` × 20",
+		"[HTML report](out/report.html) · [Grafana](https://g/d/1)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "fourth") {
+		t.Errorf("summary should list only the top %d errors:\n%s", summaryTopErrors, out)
+	}
+	if n := strings.Count(out, "\n"); n > 10 {
+		t.Errorf("summary is %d lines, want a short block", n)
+	}
+}
+
+func TestReport_MarkdownSummaryNoErrors(t *testing.T) {
+	r := &ReportResult{Capture: ReportCapture{Dir: "c"}, Severity: "low", Triage: ReportTriage{TotalLines: 5}}
+	var buf bytes.Buffer
+	if err := r.WriteMarkdownSummary(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "Top errors") || strings.Contains(buf.String(), "Links") {
+		t.Errorf("unexpected sections:\n%s", buf.String())
+	}
+}
+
+func TestReport_Annotations(t *testing.T) {
+	dir := createTestCapture(t)
+	entry := recv.LogEntry{
+		Timestamp: time.Date(2026, 2, 20, 10, 0, 42, 0, time.UTC),
+		Labels:    map[string]string{"app": "test"},
+		Message:   "line <2>",
+	}
+	if err := AddAnnotation(dir, NewAnnotation(entry, "first sign of trouble")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Report(dir, ReportConfig{Jobs: 1, Top: 5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Annotations) != 1 || result.Annotations[0].Note != "first sign of trouble" {
+		t.Fatalf("annotations = %+v", result.Annotations)
+	}
+
+	var md bytes.Buffer
+	if err := result.WriteMarkdownSummary(&md, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "10:00:42 `line <2>` — first sign of trouble") {
+		t.Errorf("markdown summary missing bookmark:\n%s", md.String())
+	}
+
+	var page bytes.Buffer
+	if err := result.WriteHTML(&page, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), "<h2>Bookmarks</h2>") || !strings.Contains(page.String(), "line &lt;2&gt;") {
+		t.Error("HTML report missing escaped bookmark")
+	}
+}
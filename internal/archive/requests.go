@@ -0,0 +1,99 @@
+package archive
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// RequestsMetric is the harness metric holding the requests completed
+// since the previous sample, posted as an annotation with
+// metric=requests value=<n>.
+const RequestsMetric = "requests"
+
+// Request rate sources, in order of precedence.
+const (
+	RequestSourceHarness = "harness" // requests metric annotations
+	RequestSourcePattern = "pattern" // lines matching a request pattern
+)
+
+// RequestRate is a per-minute request count used to normalize error rates,
+// so that more errors under more load do not read as a regression.
+type RequestRate struct {
+	Source    string          // RequestSourceHarness or RequestSourcePattern
+	PerMinute map[int64]int64 // minute (unix seconds) → requests
+	Total     int64
+}
+
+// ErrorsPer1k returns errors per 1000 requests, or 0 without requests.
+func ErrorsPer1k(errors, requests int64) float64 {
+	if requests <= 0 {
+		return 0
+	}
+	return float64(errors) * 1000 / float64(requests)
+}
+
+// requestCounter collects both request sources in one scan; harness
+// metrics win when the capture has any.
+type requestCounter struct {
+	pattern *regexp.Regexp
+	harness map[int64]int64
+	matched map[int64]int64
+}
+
+func newRequestCounter(pattern *regexp.Regexp) *requestCounter {
+	return &requestCounter{pattern: pattern, harness: make(map[int64]int64), matched: make(map[int64]int64)}
+}
+
+func (c *requestCounter) add(e recv.LogEntry) {
+	minute := e.Timestamp.Truncate(time.Minute).Unix()
+	if recv.IsAnnotation(e.Labels) {
+		if e.Labels[recv.MetricLabel] == RequestsMetric {
+			// unparsable or negative samples are ignored
+			if n, err := strconv.ParseInt(e.Labels[recv.MetricValueLabel], 10, 64); err == nil && n >= 0 {
+				c.harness[minute] += n
+			}
+		}
+		return
+	}
+	if c.pattern != nil && c.pattern.MatchString(e.Message) {
+		c.matched[minute] += e.Count()
+	}
+}
+
+// rate returns the counted requests, or nil when neither source had any.
+func (c *requestCounter) rate() *RequestRate {
+	rr := &RequestRate{Source: RequestSourceHarness, PerMinute: c.harness}
+	if len(c.harness) == 0 {
+		rr = &RequestRate{Source: RequestSourcePattern, PerMinute: c.matched}
+	}
+	for _, n := range rr.PerMinute {
+		rr.Total += n
+	}
+	if rr.Total == 0 {
+		return nil
+	}
+	return rr
+}
+
+// CountRequests returns the request rate of the capture read by r: from
+// requests metric annotations posted by a test harness when there are any,
+// else from the lines matching pattern (nil = harness metrics only). Each
+// matching line counts as one request. It returns nil when no requests were
+// found. Without a pattern only files holding annotations are read.
+func CountRequests(r *Reader, pattern *regexp.Regexp) (*RequestRate, error) {
+	var filter *Filter
+	if pattern == nil {
+		filter = &Filter{Labels: []LabelMatcher{{Key: recv.AnnotationLabel, Value: "true"}}}
+	}
+	c := newRequestCounter(pattern)
+	if _, err := r.Scan(filter, func(e recv.LogEntry) bool {
+		c.add(e)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return c.rate(), nil
+}
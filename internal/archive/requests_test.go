@@ -0,0 +1,170 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// writeLoadCapture writes a capture of minutes minutes, each with requests
+// access log lines, 200 background lines and errors error lines. With
+// harness set, each minute also carries a requests metric annotation.
+func writeLoadCapture(t *testing.T, minutes, requests, errors int, harness bool) string {
+	t.Helper()
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	app := map[string]string{"app": "api"}
+	var entries []recv.LogEntry
+	for m := 0; m < minutes; m++ {
+		ts := base.Add(time.Duration(m) * time.Minute)
+		for i := 0; i < requests; i++ {
+			entries = append(entries, recv.LogEntry{Timestamp: ts, Labels: app, Message: fmt.Sprintf(`"GET /items/%d HTTP/1.1" 200`, i)})
+		}
+		entries = append(entries, recv.LogEntry{Timestamp: ts, Labels: app, Message: "cache refreshed", RepeatCount: 199})
+		for i := 0; i < errors; i++ {
+			entries = append(entries, recv.LogEntry{Timestamp: ts, Labels: app, Message: "error: upstream timeout"})
+		}
+		if harness {
+			entries = append(entries, recv.LogEntry{
+				Timestamp: ts.Add(59 * time.Second),
+				Labels:    map[string]string{recv.AnnotationLabel: "true", recv.MetricLabel: RequestsMetric, recv.MetricValueLabel: fmt.Sprint(requests * 10)},
+				Message:   "[logtap] annotation: metric=requests",
+			})
+		}
+	}
+	var lines int64
+	for _, e := range entries {
+		lines += e.Count()
+	}
+	end := base.Add(time.Duration(minutes) * time.Minute)
+	writeMetadata(t, dir, base, end, lines)
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", entries)
+	writeIndex(t, dir, []rotate.IndexEntry{{File: "2024-01-15T100000-000.jsonl", From: base, To: end, Lines: lines}})
+	return dir
+}
+
+func TestCountRequests(t *testing.T) {
+	access := regexp.MustCompile(`"GET `)
+
+	r, err := NewReader(writeLoadCapture(t, 3, 50, 1, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr, err := CountRequests(r, nil); err != nil || rr != nil {
+		t.Errorf("no harness metrics, no pattern: %+v, %v", rr, err)
+	}
+	rr, err := CountRequests(r, access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Source != RequestSourcePattern || rr.Total != 150 || len(rr.PerMinute) != 3 {
+		t.Errorf("pattern rate = %+v", rr)
+	}
+
+	// harness metrics win over the pattern
+	r, err = NewReader(writeLoadCapture(t, 3, 50, 1, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr, err = CountRequests(r, access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Source != RequestSourceHarness || rr.Total != 1500 {
+		t.Errorf("harness rate = %+v", rr)
+	}
+
+	if got := ErrorsPer1k(3, 1500); got != 2 {
+		t.Errorf("ErrorsPer1k = %v, want 2", got)
+	}
+	if got := ErrorsPer1k(3, 0); got != 0 {
+		t.Errorf("ErrorsPer1k without requests = %v, want 0", got)
+	}
+}
+
+func TestTriageRequestRate(t *testing.T) {
+	dir := writeLoadCapture(t, 3, 50, 2, false)
+	result, err := Triage(dir, TriageConfig{Jobs: 1, RequestPattern: regexp.MustCompile(`"GET `)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Requests != 150 || result.ErrorsPer1kRequests != 40 || result.RequestSource != RequestSourcePattern {
+		t.Errorf("result = %d requests, %v per 1k, source %q", result.Requests, result.ErrorsPer1kRequests, result.RequestSource)
+	}
+	if b := result.Timeline[1]; b.Requests != 50 || b.ErrorsPer1k != 40 {
+		t.Errorf("timeline[1] = %+v", b)
+	}
+
+	var buf bytes.Buffer
+	result.WriteTimeline(&buf)
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(records[0], ","); got != "minute,total_lines,error_lines,requests,errors_per_1k_requests" {
+		t.Errorf("header = %s", got)
+	}
+	if records[1][3] != "50" || records[1][4] != "40.00" {
+		t.Errorf("row = %v", records[1])
+	}
+
+	buf.Reset()
+	result.WriteSummary(&buf)
+	if !strings.Contains(buf.String(), "Requests: 150 (40.00 errors per 1k requests, from request pattern)") {
+		t.Errorf("summary missing request rate:\n%s", buf.String())
+	}
+
+	// without a rate the timeline keeps its columns
+	result, err = Triage(dir, TriageConfig{Jobs: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Requests != 0 || result.Timeline[0].Requests != 0 {
+		t.Errorf("requests without a source: %+v", result)
+	}
+}
+
+func TestBaselineDiffRequestRate(t *testing.T) {
+	// three times the load with three times the errors: the share of error
+	// lines rises, errors per request do not
+	baseline := writeLoadCapture(t, 3, 100, 10, false)
+	current := writeLoadCapture(t, 3, 300, 30, false)
+
+	raw, err := BaselineDiff(baseline, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Verdict != "regression" || raw.ErrorsPer1kChange != "" {
+		t.Fatalf("without a request rate: %+v", raw)
+	}
+
+	got, err := BaselineDiffWithConfig(baseline, current, BaselineDiffConfig{RequestPattern: regexp.MustCompile(`"GET `)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Verdict != "stable" || got.ErrorsPer1kChange != "+0%" || got.RequestChange != "+200%" ||
+		got.BaselineErrorsPer1k != 100 || got.CurrentErrorsPer1k != 100 {
+		t.Errorf("with a request pattern: %+v", got)
+	}
+	var buf bytes.Buffer
+	got.WriteText(&buf)
+	if !strings.Contains(buf.String(), "Errors/1k requests: +0% (100.00 -> 100.00)") {
+		t.Errorf("text missing errors per 1k requests:\n%s", buf.String())
+	}
+
+	// harness metrics are used without a pattern
+	got, err = BaselineDiff(writeLoadCapture(t, 3, 100, 10, true), writeLoadCapture(t, 3, 300, 30, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Verdict != "stable" || got.BaselineErrorsPer1k != 10 {
+		t.Errorf("with harness metrics: %+v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package archive
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// DefaultRestartWindow is how far either side of a restart marker
+// --restarts-only reaches.
+const DefaultRestartWindow = time.Minute
+
+// Restart is a container restart recorded by a forwarder restart marker.
+type Restart struct {
+	Time      time.Time
+	Pod       string
+	Container string
+}
+
+// RestartFilter keeps entries within Window of a restart in the same pod.
+// Entries of every container in the pod are kept, so the sidecars' view of
+// the restart comes along.
+type RestartFilter struct {
+	Restarts []Restart
+	Window   time.Duration
+}
+
+// FindRestarts scans a capture for restart markers, in timestamp order.
+func FindRestarts(r *Reader) ([]Restart, error) {
+	var restarts []Restart
+	_, err := r.Scan(nil, func(e recv.LogEntry) bool {
+		if logtypes.IsRestartMarker(e.Message) {
+			restarts = append(restarts, Restart{
+				Time:      e.Timestamp,
+				Pod:       e.Labels["pod"],
+				Container: e.Labels["container"],
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find restarts: %w", err)
+	}
+	return restarts, nil
+}
+
+// Match reports whether an entry at ts with labels is near a restart.
+// Restarts without a pod label match entries of any pod.
+func (rf *RestartFilter) Match(ts time.Time, labels map[string]string) bool {
+	for _, rs := range rf.Restarts {
+		if rs.Pod != "" && labels["pod"] != rs.Pod {
+			continue
+		}
+		if !ts.Before(rs.Time.Add(-rf.Window)) && !ts.After(rs.Time.Add(rf.Window)) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps reports whether any restart window intersects [from, to].
+func (rf *RestartFilter) overlaps(from, to time.Time) bool {
+	for _, rs := range rf.Restarts {
+		if !to.Before(rs.Time.Add(-rf.Window)) && !from.After(rs.Time.Add(rf.Window)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rf *RestartFilter) String() string {
+	return fmt.Sprintf("restarts=%d window=%s", len(rf.Restarts), rf.Window)
+}
@@ -0,0 +1,134 @@
+package archive
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// writeRestartCapture writes a line a minute for pods a and b over ten
+// minutes, with pod a's app container restarting at minute 5.
+func writeRestartCapture(t *testing.T) (dir string, base time.Time) {
+	t.Helper()
+	dir = t.TempDir()
+	base = time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var entries []recv.LogEntry
+	for i := 0; i <= 10; i++ {
+		ts := base.Add(time.Duration(i) * time.Minute)
+		for _, pod := range []string{"a", "b"} {
+			msg := fmt.Sprintf("%s line %d", pod, i)
+			if pod == "a" && i == 5 {
+				msg = logtypes.RestartMarker("app", 1)
+			}
+			entries = append(entries, recv.LogEntry{
+				Timestamp: ts,
+				Labels:    map[string]string{"pod": pod, "container": "app"},
+				Message:   msg,
+			})
+		}
+	}
+	writeMetadata(t, dir, base, base.Add(10*time.Minute), int64(len(entries)))
+	writeIndex(t, dir, []rotate.IndexEntry{{
+		File: "data.jsonl", From: base, To: base.Add(10 * time.Minute), Lines: int64(len(entries)),
+	}})
+	writeDataFile(t, dir, "data.jsonl", entries)
+	return dir, base
+}
+
+func TestFindRestarts(t *testing.T) {
+	dir, base := writeRestartCapture(t)
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarts, err := FindRestarts(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Restart{Time: base.Add(5 * time.Minute), Pod: "a", Container: "app"}
+	if len(restarts) != 1 || restarts[0] != want {
+		t.Fatalf("restarts = %+v, want [%+v]", restarts, want)
+	}
+}
+
+func TestFilterRestarts(t *testing.T) {
+	dir, base := writeRestartCapture(t)
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarts, err := FindRestarts(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Filter{Restarts: &RestartFilter{Restarts: restarts, Window: time.Minute}}
+
+	var got []string
+	if _, err := r.Scan(f, func(e recv.LogEntry) bool {
+		got = append(got, e.Message)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a line 4", logtypes.RestartMarker("app", 1), "a line 6"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	far := &rotate.IndexEntry{From: base.Add(7 * time.Minute), To: base.Add(10 * time.Minute)}
+	if !f.SkipFile(far) {
+		t.Error("file after every restart window not skipped")
+	}
+	near := &rotate.IndexEntry{From: base.Add(6 * time.Minute), To: base.Add(10 * time.Minute)}
+	if f.SkipFile(near) {
+		t.Error("file overlapping a restart window skipped")
+	}
+}
+
+func TestRestartFilter_NoPod(t *testing.T) {
+	at := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	rf := &RestartFilter{Restarts: []Restart{{Time: at}}, Window: time.Minute}
+	if !rf.Match(at.Add(30*time.Second), map[string]string{"pod": "any"}) {
+		t.Error("restart without a pod should match every pod")
+	}
+	if rf.Match(at.Add(2*time.Minute), nil) {
+		t.Error("entry outside the window matched")
+	}
+}
+
+func TestSlice_Restarts(t *testing.T) {
+	src, base := writeRestartCapture(t)
+	out := filepath.Join(t.TempDir(), "out")
+	r, err := NewReader(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarts, err := FindRestarts(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Slice(SliceOptions{
+		CaptureDir: src,
+		OutputDir:  out,
+		Restarts:   &RestartFilter{Restarts: restarts, Window: time.Minute},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := ReadMetadata(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TotalLines != 3 {
+		t.Errorf("TotalLines = %d, want 3", meta.TotalLines)
+	}
+	if !meta.Started.Equal(base.Add(4*time.Minute)) || !meta.Stopped.Equal(base.Add(6*time.Minute)) {
+		t.Errorf("range = %s..%s, want minutes 4..6", meta.Started, meta.Stopped)
+	}
+}
@@ -0,0 +1,136 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// DefaultRunTopErrors is the number of error signatures in a run document.
+const DefaultRunTopErrors = 10
+
+// RunDocument is the summary of one finished capture, indexed by catalog
+// --to-elastic so the history of load test runs can be dashboarded in
+// OpenSearch or Kibana. Its ID is stable, so exporting a capture again
+// updates its document instead of adding one.
+type RunDocument struct {
+	ID              string            `json:"-"`
+	Timestamp       time.Time         `json:"@timestamp"` // capture start
+	Capture         string            `json:"capture"`    // capture directory name
+	Dir             string            `json:"dir"`
+	Stopped         time.Time         `json:"stopped"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Description     string            `json:"description,omitempty"`
+	Owner           string            `json:"owner,omitempty"`
+	Meta            map[string]string `json:"meta,omitempty"` // dots in keys become underscores
+	Labels          []string          `json:"labels,omitempty"`
+	Files           int               `json:"files"`
+	Entries         int64             `json:"entries"`
+	Bytes           int64             `json:"bytes"`
+	ErrorLines      int64             `json:"error_lines"`
+	ErrorRatePct    float64           `json:"error_rate_pct"`
+	Severity        string            `json:"severity"`
+	Baseline        string            `json:"baseline,omitempty"`
+	Verdict         string            `json:"verdict,omitempty"`        // against Baseline: stable, regression, improvement or different
+	CleanShutdown   *bool             `json:"clean_shutdown,omitempty"` // nil when the receiver did not record its shutdown
+	TopErrors       []RunSignature    `json:"top_errors,omitempty"`
+}
+
+// RunSignature is one top error signature of a run.
+type RunSignature struct {
+	Signature string `json:"signature"`
+	Count     int64  `json:"count"`
+	Owner     string `json:"owner,omitempty"`
+}
+
+// RunSummaryConfig controls SummarizeRun.
+type RunSummaryConfig struct {
+	Jobs     int     // parallel triage workers
+	Top      int     // error signatures kept (default DefaultRunTopErrors)
+	Owners   *Owners // error ownership rules (nil = none)
+	Baseline string  // capture each run is diffed against for a verdict ("" = none)
+}
+
+// SummarizeRun triages the capture of entry and returns its run document:
+// metadata, totals, severity, top error signatures and, with a baseline,
+// the baseline diff verdict.
+func SummarizeRun(entry CatalogEntry, cfg RunSummaryConfig) (*RunDocument, error) {
+	if cfg.Top <= 0 {
+		cfg.Top = DefaultRunTopErrors
+	}
+	report, err := Report(entry.Dir, ReportConfig{Jobs: cfg.Jobs, Top: cfg.Top, Owners: cfg.Owners}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", entry.Dir, err)
+	}
+	meta, err := recv.ReadMetadata(entry.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", entry.Dir, err)
+	}
+
+	doc := &RunDocument{
+		ID:              runDocumentID(entry.Dir, entry.Started),
+		Timestamp:       entry.Started.UTC(),
+		Capture:         filepath.Base(filepath.Clean(entry.Dir)),
+		Dir:             entry.Dir,
+		Stopped:         entry.Stopped.UTC(),
+		DurationSeconds: report.Capture.DurationSeconds,
+		Description:     entry.Description,
+		Owner:           entry.Owner,
+		Labels:          entry.Labels,
+		Files:           entry.Files,
+		Entries:         entry.Entries,
+		Bytes:           entry.Bytes,
+		ErrorLines:      report.Triage.ErrorLines,
+		ErrorRatePct:    report.Triage.ErrorRatePct,
+		Severity:        report.Severity,
+	}
+	if len(entry.Meta) > 0 {
+		doc.Meta = make(map[string]string, len(entry.Meta))
+		for k, v := range entry.Meta {
+			doc.Meta[strings.ReplaceAll(k, ".", "_")] = v
+		}
+	}
+	if meta.Shutdown != nil {
+		clean := meta.Shutdown.Clean
+		doc.CleanShutdown = &clean
+	}
+	for _, e := range report.Triage.TopErrors {
+		doc.TopErrors = append(doc.TopErrors, RunSignature{Signature: e.Signature, Count: e.Count, Owner: e.Owner})
+	}
+
+	if cfg.Baseline == "" {
+		return doc, nil
+	}
+	same, err := sameDir(cfg.Baseline, entry.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if !same {
+		diff, err := BaselineDiff(cfg.Baseline, entry.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s: baseline: %w", entry.Dir, err)
+		}
+		doc.Baseline = cfg.Baseline
+		doc.Verdict = diff.Verdict
+	}
+	return doc, nil
+}
+
+// runDocumentID derives a document ID from the capture's absolute path and
+// start time, which together identify a run.
+func runDocumentID(dir string, started time.Time) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	h := sha256.New()
+	h.Write([]byte(dir))
+	h.Write([]byte{0})
+	h.Write(strconv.AppendInt(nil, started.UnixNano(), 10))
+	return hex.EncodeToString(h.Sum(nil)[:20])
+}
@@ -0,0 +1,74 @@
+package archive
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestSummarizeRun(t *testing.T) {
+	baseline := writeLoadCapture(t, 3, 100, 10, false)
+	current := writeLoadCapture(t, 3, 300, 30, false)
+
+	meta, err := recv.ReadMetadata(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.Shutdown = &recv.ShutdownInfo{Timeout: "5s", Clean: true}
+	if err := recv.WriteMetadata(current, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := CatalogEntry{
+		Dir:     current,
+		Started: meta.Started,
+		Stopped: meta.Stopped,
+		Entries: meta.TotalLines,
+		Owner:   "payments",
+		Meta:    map[string]string{"build.sha": "abc123"},
+	}
+	doc, err := SummarizeRun(entry, RunSummaryConfig{Jobs: 1, Baseline: baseline})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ErrorLines != 90 || doc.Verdict != "regression" || doc.Baseline != baseline || doc.DurationSeconds != 180 {
+		t.Errorf("doc = %+v", doc)
+	}
+	if doc.CleanShutdown == nil || !*doc.CleanShutdown {
+		t.Errorf("clean_shutdown = %v", doc.CleanShutdown)
+	}
+	if len(doc.TopErrors) != 1 || doc.TopErrors[0].Count != 90 {
+		t.Errorf("top errors = %+v", doc.TopErrors)
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"@timestamp":"2024-01-15T10:00:00Z"`, `"meta":{"build_sha":"abc123"}`, `"owner":"payments"`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("document missing %s: %s", want, out)
+		}
+	}
+	if strings.Contains(string(out), doc.ID) {
+		t.Error("document ID in the source")
+	}
+
+	// the ID is stable, and a run is not diffed against itself
+	again, err := SummarizeRun(entry, RunSummaryConfig{Jobs: 1, Baseline: current})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != doc.ID || again.Verdict != "" {
+		t.Errorf("rerun: id %s vs %s, verdict %q", again.ID, doc.ID, again.Verdict)
+	}
+	other, err := SummarizeRun(CatalogEntry{Dir: baseline, Started: meta.Started}, RunSummaryConfig{Jobs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == doc.ID || other.CleanShutdown != nil {
+		t.Errorf("other run = %+v", other)
+	}
+}
@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// ShardSources checks that dirs are the complete set of captures written by
+// one group of recv --shard receivers and returns them in shard order. A
+// single directory is taken as the parent of the shard captures.
+func ShardSources(dirs []string) ([]string, error) {
+	if len(dirs) == 1 {
+		entries, err := os.ReadDir(dirs[0])
+		if err != nil {
+			return nil, err
+		}
+		var subdirs []string
+		for _, e := range entries {
+			if e.IsDir() {
+				subdirs = append(subdirs, filepath.Join(dirs[0], e.Name()))
+			}
+		}
+		dirs = subdirs
+	}
+
+	type shardDir struct {
+		dir   string
+		shard recv.Shard
+	}
+	var shards []shardDir
+	count := 0
+	for _, dir := range dirs {
+		meta, err := recv.ReadMetadata(dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s: read metadata: %w", dir, err)
+		}
+		if meta.Shard == "" {
+			return nil, fmt.Errorf("%s: not written by a recv --shard receiver", dir)
+		}
+		shard, err := recv.ParseShard(meta.Shard)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		if count == 0 {
+			count = shard.Count
+		} else if shard.Count != count {
+			return nil, fmt.Errorf("%s: shard %s does not belong to a set of %d", dir, shard, count)
+		}
+		shards = append(shards, shardDir{dir: dir, shard: shard})
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no shard captures found")
+	}
+
+	sort.Slice(shards, func(i, j int) bool { return shards[i].shard.Index < shards[j].shard.Index })
+	seen := make(map[int]string)
+	for _, s := range shards {
+		if prev, ok := seen[s.shard.Index]; ok {
+			return nil, fmt.Errorf("shard %s found twice: %s and %s", s.shard, prev, s.dir)
+		}
+		seen[s.shard.Index] = s.dir
+	}
+	var missing []string
+	for i := 1; i <= count; i++ {
+		if _, ok := seen[i]; !ok {
+			missing = append(missing, fmt.Sprintf("%d/%d", i, count))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("incomplete shard set: missing %s", strings.Join(missing, ", "))
+	}
+
+	out := make([]string, len(shards))
+	for i, s := range shards {
+		out[i] = s.dir
+	}
+	return out, nil
+}
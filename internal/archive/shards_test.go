@@ -0,0 +1,73 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func writeShardCapture(t *testing.T, dir, shard string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := recv.WriteMetadata(dir, &recv.Metadata{Version: 1, Format: "jsonl", Shard: shard}); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestShardSources(t *testing.T) {
+	parent := t.TempDir()
+	c := writeShardCapture(t, filepath.Join(parent, "recv-2"), "3/3")
+	a := writeShardCapture(t, filepath.Join(parent, "recv-0"), "1/3")
+	b := writeShardCapture(t, filepath.Join(parent, "recv-1"), "2/3")
+
+	got, err := ShardSources([]string{c, a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != strings.Join([]string{a, b, c}, ",") {
+		t.Errorf("sources = %v, want shard order", got)
+	}
+
+	// a single parent directory expands to its shard subdirectories
+	got, err = ShardSources([]string{parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != a {
+		t.Errorf("sources from parent = %v", got)
+	}
+}
+
+func TestShardSources_Invalid(t *testing.T) {
+	base := t.TempDir()
+	one := writeShardCapture(t, filepath.Join(base, "a"), "1/3")
+	two := writeShardCapture(t, filepath.Join(base, "b"), "2/3")
+	twoAgain := writeShardCapture(t, filepath.Join(base, "c"), "2/3")
+	other := writeShardCapture(t, filepath.Join(base, "d"), "2/2")
+	plain := writeShardCapture(t, filepath.Join(base, "e"), "")
+
+	tests := []struct {
+		name string
+		dirs []string
+		want string
+	}{
+		{"missing shard", []string{one, two}, "missing 3/3"},
+		{"duplicate shard", []string{one, two, twoAgain}, "found twice"},
+		{"mixed sets", []string{one, other}, "does not belong"},
+		{"not a shard", []string{one, plain}, "not written by"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ShardSources(tt.dirs)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
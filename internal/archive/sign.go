@@ -12,6 +12,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
 )
 
 const manifestFile = "manifest.sha256"
@@ -62,7 +64,7 @@ func Sign(dir string) (*SignResult, error) {
 
 	var digests []FileDigest
 	for _, name := range files {
-		h, size, err := hashFile(filepath.Join(dir, name))
+		h, size, err := hashFile(manifestFilePath(dir, name))
 		if err != nil {
 			return nil, fmt.Errorf("hash %s: %w", name, err)
 		}
@@ -98,7 +100,7 @@ func Verify(dir string) (*VerifyResult, error) {
 
 	// Hash all expected files and check for mismatches/missing.
 	for _, entry := range expected {
-		actual, _, err := hashFile(filepath.Join(dir, entry.File))
+		actual, _, err := hashFile(manifestFilePath(dir, entry.File))
 		if err != nil {
 			if os.IsNotExist(err) {
 				result.Missing = append(result.Missing, entry.File)
@@ -139,8 +141,30 @@ func Verify(dir string) (*VerifyResult, error) {
 }
 
 // captureFiles returns sorted filenames of all regular files in dir,
-// excluding manifest.sha256 itself.
+// excluding manifest.sha256 itself and a running receiver's lock file. The
+// files of a sharded capture's shard directories are included, named by
+// the shard path as recorded in metadata.json.
 func captureFiles(dir string) ([]string, error) {
+	names, err := listCaptureFiles(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	if meta, err := recv.ReadMetadata(dir); err == nil {
+		for i, shardDir := range captureDirs(dir, meta)[1:] {
+			shardNames, err := listCaptureFiles(shardDir, meta.Shards[i])
+			if err != nil {
+				return nil, fmt.Errorf("shard %s: %w", meta.Shards[i], err)
+			}
+			names = append(names, shardNames...)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// listCaptureFiles returns the capture files directly in dir, prefixed
+// with prefix.
+func listCaptureFiles(dir, prefix string) ([]string, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("read directory: %w", err)
@@ -148,15 +172,23 @@ func captureFiles(dir string) ([]string, error) {
 
 	var names []string
 	for _, e := range entries {
-		if e.IsDir() || e.Name() == manifestFile {
+		if e.IsDir() || e.Name() == manifestFile || e.Name() == AnnotationsFile || e.Name() == recv.LockFile {
 			continue
 		}
-		names = append(names, e.Name())
+		names = append(names, filepath.Join(prefix, e.Name()))
 	}
-	sort.Strings(names)
 	return names, nil
 }
 
+// manifestFilePath resolves a manifest file name against dir, unless it
+// lies in a shard recorded by absolute path.
+func manifestFilePath(dir, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
 // hashFile computes the SHA256 hex digest and byte size of a file.
 func hashFile(path string) (string, int64, error) {
 	f, err := os.Open(path)
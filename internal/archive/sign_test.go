@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ppiankov/logtap/internal/recv"
 	"github.com/ppiankov/logtap/internal/rotate"
 )
 
@@ -196,3 +197,39 @@ func TestVerify_NoManifest(t *testing.T) {
 		t.Error("expected error when manifest is missing")
 	}
 }
+
+func TestSign_Shards(t *testing.T) {
+	dir, _ := writeShardedCapture(t)
+	meta, err := recv.ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shardFile := filepath.Join(meta.Shards[0], "2024-01-15T100010-000.jsonl")
+
+	result, err := Sign(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 6 {
+		t.Errorf("signed %d files, want 6 across both shards", len(result.Files))
+	}
+
+	v, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Valid {
+		t.Fatalf("expected valid, got mismatches=%v missing=%v extra=%v", v.Mismatches, v.Missing, v.Extra)
+	}
+
+	if err := os.WriteFile(shardFile, []byte("tampered\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v, err = Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Valid || len(v.Mismatches) != 1 || v.Mismatches[0].File != shardFile {
+		t.Errorf("mismatches = %v, want the tampered shard file", v.Mismatches)
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/klauspost/compress/zstd"
+
+	"github.com/ppiankov/logtap/internal/recv"
 )
 
 // LabelFilter represents a key-value pair for label filtering.
@@ -25,14 +27,43 @@ type SliceOptions struct {
 	From       time.Time
 	To         time.Time
 	Labels     []LabelFilter
+	Fields     []FieldMatcher // JSON message fields, e.g. trace_id=abc
 	Grep       *regexp.Regexp
+	Restarts   *RestartFilter // keep only lines near container restarts
+	Phases     *PhaseFilter   // keep only lines within annotated test phases
 	OutputDir  string
 	CaptureDir string
+	Resume     bool     // continue from the checkpoint in OutputDir, if any
+	Profile    *Profile // per-file read profile (nil = off)
+}
+
+// sliceCheckpointFile is written to the output directory while a slice runs.
+const sliceCheckpointFile = ".slice-checkpoint.json"
+
+func (o SliceOptions) fingerprint() string {
+	grep := ""
+	if o.Grep != nil {
+		grep = o.Grep.String()
+	}
+	fp := fmt.Sprintf("src=%s from=%s to=%s labels=%v grep=%q", o.CaptureDir,
+		o.From.UTC().Format(time.RFC3339Nano), o.To.UTC().Format(time.RFC3339Nano), o.Labels, grep)
+	if len(o.Fields) > 0 {
+		fp += fmt.Sprintf(" fields=%v", o.Fields)
+	}
+	if o.Restarts != nil {
+		fp += " " + o.Restarts.String()
+	}
+	if o.Phases != nil {
+		fp += " " + o.Phases.String()
+	}
+	return fp
 }
 
-// logEntry represents a minimal structure to parse the timestamp from a log line.
+// logEntry represents a minimal structure to parse the timestamp, labels and message from a log line.
 type logEntry struct {
-	Timestamp string `json:"ts"`
+	Timestamp string            `json:"ts"`
+	Labels    map[string]string `json:"labels"`
+	Message   string            `json:"msg"`
 }
 
 // Slice performs the slicing operation.
@@ -63,6 +94,11 @@ func Slice(opts SliceOptions) error {
 
 	newMeta := NewMetadata()
 	newMeta.Version = sourceMeta.Version
+	for _, f := range sourceMeta.Features {
+		if f == recv.FeatureDedup { // lines are copied with their repeat counts
+			newMeta.Features = append(newMeta.Features, f)
+		}
+	}
 	newMeta.Format = sourceMeta.Format
 	newMeta.Redaction = sourceMeta.Redaction
 
@@ -73,9 +109,41 @@ func Slice(opts SliceOptions) error {
 	var minTS, maxTS time.Time
 
 	filtered := filterIndexEntries(sourceIndex.Entries, opts)
-	timeFilterActive := !opts.From.IsZero() || !opts.To.IsZero()
+	timeFilterActive := !opts.From.IsZero() || !opts.To.IsZero() || opts.Restarts != nil || opts.Phases != nil
+
+	cpPath := filepath.Join(opts.OutputDir, sliceCheckpointFile)
+	var cp *Checkpoint
+	if opts.Resume {
+		cp, err = loadResumeCheckpoint(cpPath, "slice", opts.fingerprint())
+		if err != nil {
+			return err
+		}
+	}
+	if cp == nil {
+		cp = &Checkpoint{Op: "slice", Params: opts.fingerprint()}
+	}
+
+	addEntry := func(e IndexEntry) {
+		newIndex.Entries = append(newIndex.Entries, e)
+		totalLines += e.Lines
+		totalBytes += e.Bytes
+		if minTS.IsZero() || e.From.Before(minTS) {
+			minTS = e.From
+		}
+		if maxTS.IsZero() || e.To.After(maxTS) {
+			maxTS = e.To
+		}
+	}
+	for _, e := range cp.Index {
+		addEntry(e)
+	}
 
 	for _, ie := range filtered {
+		if cp.Done(ie.File) {
+			fmt.Printf("Skipping completed file: %s\n", ie.File)
+			continue
+		}
+
 		srcPath := filepath.Join(opts.CaptureDir, ie.File)
 		outPath := filepath.Join(opts.OutputDir, ie.File)
 
@@ -85,26 +153,24 @@ func Slice(opts SliceOptions) error {
 		}
 
 		if lines > 0 {
-			newIndex.Entries = append(newIndex.Entries, IndexEntry{
+			e := IndexEntry{
 				File:  ie.File,
 				From:  fileMinTS,
 				To:    fileMaxTS,
 				Lines: lines,
 				Bytes: bytes,
-			})
-			totalLines += lines
-			totalBytes += bytes
-
-			if minTS.IsZero() || fileMinTS.Before(minTS) {
-				minTS = fileMinTS
-			}
-			if maxTS.IsZero() || fileMaxTS.After(maxTS) {
-				maxTS = fileMaxTS
 			}
+			addEntry(e)
+			cp.Index = append(cp.Index, e)
 		} else {
 			_ = os.Remove(outPath)
 		}
 
+		cp.Completed = append(cp.Completed, ie.File)
+		if err := WriteCheckpoint(cpPath, cp); err != nil {
+			return err
+		}
+
 		fmt.Printf("Processed file: %s (Matched %d lines)\n", ie.File, lines)
 	}
 
@@ -124,6 +190,7 @@ func Slice(opts SliceOptions) error {
 	if err := WriteIndex(opts.OutputDir, newIndex); err != nil {
 		return fmt.Errorf("write output index: %w", err)
 	}
+	_ = os.Remove(cpPath)
 
 	fmt.Printf("Slicing complete. Wrote %d lines across %d files to %s\n", totalLines, len(newIndex.Entries), opts.OutputDir)
 	return nil
@@ -138,13 +205,16 @@ func sliceFile(srcPath, outPath string, opts SliceOptions, timeFilterActive bool
 	}
 	defer func() { _ = inFile.Close() }()
 
-	var reader io.Reader = inFile
+	prof := opts.Profile.profileFile(filepath.Base(srcPath))
+	defer prof.done()
+
+	reader := prof.wrapFile(inFile)
 	if strings.HasSuffix(srcPath, ".zst") {
-		dec, zstdErr := zstd.NewReader(inFile)
+		dec, closeDec, zstdErr := prof.openZstd(reader)
 		if zstdErr != nil {
-			return 0, 0, minTS, maxTS, fmt.Errorf("zstd open: %w", zstdErr)
+			return 0, 0, minTS, maxTS, zstdErr
 		}
-		defer dec.Close()
+		defer closeDec()
 		reader = dec
 	}
 
@@ -174,10 +244,16 @@ func sliceFile(srcPath, outPath string, opts SliceOptions, timeFilterActive bool
 		var ts time.Time
 
 		var entry logEntry
-		if unmarshalErr := json.Unmarshal(lineBytes, &entry); unmarshalErr != nil {
-			if timeFilterActive {
+		t := prof.now()
+		unmarshalErr := json.Unmarshal(lineBytes, &entry)
+		prof.decoded(t)
+		t = prof.now()
+		if unmarshalErr != nil {
+			if timeFilterActive || len(opts.Fields) > 0 {
 				match = false
 			}
+		} else if !matchFields(opts.Fields, entry.Message) {
+			match = false
 		} else {
 			ts, err = time.Parse(time.RFC3339, entry.Timestamp)
 			if err != nil {
@@ -191,6 +267,12 @@ func sliceFile(srcPath, outPath string, opts SliceOptions, timeFilterActive bool
 				if !opts.To.IsZero() && (ts.After(opts.To) || ts.Equal(opts.To)) {
 					match = false
 				}
+				if opts.Restarts != nil && !opts.Restarts.Match(ts, entry.Labels) {
+					match = false
+				}
+				if opts.Phases != nil && !opts.Phases.Match(ts) {
+					match = false
+				}
 				if match {
 					if minTS.IsZero() || ts.Before(minTS) {
 						minTS = ts
@@ -205,6 +287,7 @@ func sliceFile(srcPath, outPath string, opts SliceOptions, timeFilterActive bool
 		if match && opts.Grep != nil && !opts.Grep.Match(lineBytes) {
 			match = false
 		}
+		prof.filtered(t)
 
 		if match {
 			if _, writeErr := writer.Write(append(lineBytes, '\n')); writeErr != nil {
@@ -222,7 +305,8 @@ func sliceFile(srcPath, outPath string, opts SliceOptions, timeFilterActive bool
 	return lines, bytes, minTS, maxTS, nil
 }
 
-// filterIndexEntries filters index entries based on time and label criteria.
+// filterIndexEntries filters index entries based on time, label and field
+// criteria, the latter through the field index of each file.
 func filterIndexEntries(entries []IndexEntry, opts SliceOptions) []IndexEntry {
 	var filtered []IndexEntry
 	for _, entry := range entries {
@@ -232,6 +316,12 @@ func filterIndexEntries(entries []IndexEntry, opts SliceOptions) []IndexEntry {
 		if !opts.To.IsZero() && entry.From.After(opts.To) {
 			continue
 		}
+		if opts.Restarts != nil && !opts.Restarts.overlaps(entry.From, entry.To) {
+			continue
+		}
+		if opts.Phases != nil && !opts.Phases.overlaps(entry.From, entry.To) {
+			continue
+		}
 
 		if len(opts.Labels) > 0 {
 			labelMatch := false
@@ -249,6 +339,9 @@ func filterIndexEntries(entries []IndexEntry, opts SliceOptions) []IndexEntry {
 				continue
 			}
 		}
+		if (&Filter{Fields: opts.Fields}).skipFields(opts.CaptureDir, entry.File) {
+			continue
+		}
 		filtered = append(filtered, entry)
 	}
 	return filtered
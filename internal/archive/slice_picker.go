@@ -0,0 +1,377 @@
+package archive
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// pickerMaxBuckets bounds the histogram columns; wider captures get wider
+// buckets rather than a histogram that needs scrolling to see a spike.
+const pickerMaxBuckets = 120
+
+// pickerBucketWidths are the bucket widths the picker chooses from, so
+// window edges land on round times.
+var pickerBucketWidths = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 4 * time.Hour, 24 * time.Hour,
+}
+
+// SlicePick is the time window and labels chosen in the slice picker.
+// Zero From and To mean the whole capture; no Labels means every entry.
+type SlicePick struct {
+	From   time.Time
+	To     time.Time // exclusive, the end of the last selected bucket
+	Labels []LabelFilter
+}
+
+// SlicePickerModel is a bubbletea model that shows a histogram of a
+// capture's lines from its index and lets the user mark a time window and
+// pick label values before slicing.
+type SlicePickerModel struct {
+	buckets []Bucket
+	width   time.Duration
+
+	cursor int
+	anchor int // bucket where the window being marked starts, -1 if none
+	from   int // selected window, bucket indexes; -1 for the whole capture
+	to     int
+
+	services    []ServiceEntry
+	selected    []bool
+	labelFocus  bool
+	labelCursor int
+
+	done      bool
+	cancelled bool
+
+	termWidth  int
+	termHeight int
+}
+
+// NewSlicePickerModel builds the picker from the reader's index. It fails
+// when no file is indexed, since there is nothing to draw.
+func NewSlicePickerModel(r *Reader) (SlicePickerModel, error) {
+	lipgloss.SetColorProfile(termenv.ANSI256)
+
+	var index []rotate.IndexEntry
+	var minTime, maxTime time.Time
+	for _, f := range r.files {
+		if f.Index == nil || f.Index.Lines == 0 {
+			continue
+		}
+		index = append(index, *f.Index)
+		if minTime.IsZero() || f.Index.From.Before(minTime) {
+			minTime = f.Index.From
+		}
+		if f.Index.To.After(maxTime) {
+			maxTime = f.Index.To
+		}
+	}
+	if len(index) == 0 {
+		return SlicePickerModel{}, fmt.Errorf("capture has no indexed files to draw a histogram from")
+	}
+
+	width := pickerBucketWidths[len(pickerBucketWidths)-1]
+	for _, w := range pickerBucketWidths {
+		if int(maxTime.Truncate(w).Sub(minTime.Truncate(w))/w)+1 <= pickerMaxBuckets {
+			width = w
+			break
+		}
+	}
+
+	services := r.ServiceSummary()
+	selected := make([]bool, len(services))
+	for i := range selected {
+		selected[i] = true
+	}
+
+	return SlicePickerModel{
+		buckets:    buildTimeline(index, minTime, maxTime, width),
+		width:      width,
+		anchor:     -1,
+		from:       -1,
+		to:         -1,
+		services:   services,
+		selected:   selected,
+		termWidth:  80,
+		termHeight: 24,
+	}, nil
+}
+
+// Init implements tea.Model.
+func (m SlicePickerModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles messages.
+func (m SlicePickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.termWidth = msg.Width
+		m.termHeight = msg.Height
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			m.cancelled = true
+			return m, tea.Quit
+		case "enter":
+			if m.anchor >= 0 {
+				m.from, m.to = ordered(m.anchor, m.cursor)
+				m.anchor = -1
+			}
+			m.done = true
+			return m, tea.Quit
+		case "tab":
+			if len(m.services) > 1 {
+				m.labelFocus = !m.labelFocus
+			}
+			return m, nil
+		}
+		if m.labelFocus {
+			return m.updateLabels(msg), nil
+		}
+		return m.updateHistogram(msg), nil
+	}
+	return m, nil
+}
+
+func (m SlicePickerModel) updateHistogram(msg tea.KeyMsg) SlicePickerModel {
+	last := len(m.buckets) - 1
+	switch msg.String() {
+	case "h", "left":
+		m.cursor = clamp(m.cursor-1, 0, last)
+	case "l", "right":
+		m.cursor = clamp(m.cursor+1, 0, last)
+	case "H", "shift+left":
+		m.cursor = clamp(m.cursor-10, 0, last)
+	case "L", "shift+right":
+		m.cursor = clamp(m.cursor+10, 0, last)
+	case "g", "home":
+		m.cursor = 0
+	case "G", "end":
+		m.cursor = last
+	case " ":
+		// first press marks where the window starts, second where it ends
+		if m.anchor < 0 {
+			m.anchor = m.cursor
+		} else {
+			m.from, m.to = ordered(m.anchor, m.cursor)
+			m.anchor = -1
+		}
+	case "a":
+		m.anchor, m.from, m.to = -1, -1, -1
+	}
+	return m
+}
+
+func (m SlicePickerModel) updateLabels(msg tea.KeyMsg) SlicePickerModel {
+	switch msg.String() {
+	case "j", "down":
+		m.labelCursor = clamp(m.labelCursor+1, 0, len(m.services)-1)
+	case "k", "up":
+		m.labelCursor = clamp(m.labelCursor-1, 0, len(m.services)-1)
+	case " ":
+		m.selected[m.labelCursor] = !m.selected[m.labelCursor]
+	case "a":
+		all := true
+		for _, s := range m.selected {
+			all = all && s
+		}
+		for i := range m.selected {
+			m.selected[i] = !all
+		}
+	}
+	return m
+}
+
+// Result returns the pick once the user confirmed with enter. ok is false
+// if the picker was cancelled.
+func (m SlicePickerModel) Result() (pick SlicePick, ok bool) {
+	if !m.done || m.cancelled {
+		return SlicePick{}, false
+	}
+	if m.from >= 0 {
+		pick.From = m.buckets[m.from].Time
+		pick.To = m.buckets[m.to].Time.Add(m.width)
+	}
+	n := 0
+	for _, s := range m.selected {
+		if s {
+			n++
+		}
+	}
+	// all or nothing selected means no label filter, as in the replay picker
+	if n > 0 && n < len(m.services) {
+		for i, svc := range m.services {
+			if m.selected[i] {
+				pick.Labels = append(pick.Labels, LabelFilter{Key: svc.Label, Value: svc.Value})
+			}
+		}
+	}
+	return pick, true
+}
+
+// window returns the buckets currently shown as selected, following the
+// cursor while a window is being marked.
+func (m SlicePickerModel) window() (int, int) {
+	if m.anchor >= 0 {
+		return ordered(m.anchor, m.cursor)
+	}
+	return m.from, m.to
+}
+
+func (m SlicePickerModel) windowLines(from, to int) int64 {
+	var n int64
+	for i := from; i <= to && i >= 0; i++ {
+		n += m.buckets[i].Lines
+	}
+	return n
+}
+
+func (m SlicePickerModel) timeLayout() string {
+	first, last := m.buckets[0].Time, m.buckets[len(m.buckets)-1].Time
+	switch {
+	case first.YearDay() != last.YearDay() || first.Year() != last.Year():
+		return "01-02 15:04"
+	case m.width < time.Minute:
+		return "15:04:05"
+	}
+	return "15:04"
+}
+
+// View renders the histogram, the window summary and the label list.
+func (m SlicePickerModel) View() string {
+	var b strings.Builder
+	layout := m.timeLayout()
+
+	b.WriteString(rBoldStyle.Render("  Select a time window and labels to slice"))
+	b.WriteString("\n\n")
+
+	// columns that fit, scrolled to keep the cursor visible
+	cols := max(m.termWidth-4, 10)
+	start := clamp(m.cursor-cols/2, 0, max(len(m.buckets)-cols, 0))
+	end := min(start+cols, len(m.buckets))
+
+	var maxLines int64
+	for _, bk := range m.buckets {
+		maxLines = max(maxLines, bk.Lines)
+	}
+	height := clamp(m.termHeight-16, 4, 12)
+	from, to := m.window()
+	for row := height - 1; row >= 0; row-- {
+		b.WriteString("  ")
+		for i := start; i < end; i++ {
+			// eighths of a row filled by this bucket's bar at this row
+			var fill int
+			if maxLines > 0 {
+				fill = int(math.Round(float64(m.buckets[i].Lines)/float64(maxLines)*float64(height*8))) - row*8
+			}
+			ch := " "
+			switch {
+			case fill >= 8:
+				ch = "█"
+			case fill > 0:
+				ch = string(sparkBlocks[fill-1])
+			}
+			switch {
+			case i == m.cursor:
+				b.WriteString(rPickerCursorStyle.Render(ch))
+			case from >= 0 && i >= from && i <= to:
+				b.WriteString(rPickerSelStyle.Render(ch))
+			default:
+				b.WriteString(ch)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	// axis: cursor marker under the histogram, edge times on the next line
+	b.WriteString("  " + strings.Repeat(" ", m.cursor-start) + rPickerCursorStyle.Render("^") + "\n")
+	left := m.buckets[start].Time.Format(layout)
+	right := m.buckets[end-1].Time.Format(layout)
+	gap := max(end-start-len(left)-len(right), 1)
+	b.WriteString(rLabelStyle.Render("  "+left+strings.Repeat(" ", gap)+right) + "\n\n")
+
+	cur := m.buckets[m.cursor]
+	b.WriteString(fmt.Sprintf("  Cursor:  %s  %s lines  (%s buckets)\n", cur.Time.Format(layout), formatRate(float64(cur.Lines)), bucketWidthLabel(m.width)))
+	switch {
+	case m.anchor >= 0:
+		b.WriteString(fmt.Sprintf("  Window:  %s → %s  %s lines  (space to set the end)\n",
+			m.buckets[from].Time.Format(layout), m.buckets[to].Time.Add(m.width).Format(layout), formatRate(float64(m.windowLines(from, to)))))
+	case from >= 0:
+		b.WriteString(fmt.Sprintf("  Window:  %s → %s  %s lines\n",
+			m.buckets[from].Time.Format(layout), m.buckets[to].Time.Add(m.width).Format(layout), formatRate(float64(m.windowLines(from, to)))))
+	default:
+		b.WriteString(fmt.Sprintf("  Window:  whole capture  %s lines\n", formatRate(float64(m.windowLines(0, len(m.buckets)-1)))))
+	}
+
+	if len(m.services) > 1 {
+		b.WriteString("\n")
+		title := fmt.Sprintf("  Labels (%s)", m.services[0].Label)
+		if m.labelFocus {
+			b.WriteString(rBoldStyle.Render(title))
+		} else {
+			b.WriteString(rLabelStyle.Render(title + " — tab to edit"))
+		}
+		b.WriteString("\n")
+		for i, svc := range m.services {
+			cursor := "  "
+			if m.labelFocus && i == m.labelCursor {
+				cursor = "> "
+			}
+			check := "[ ]"
+			if m.selected[i] {
+				check = "[x]"
+			}
+			line := fmt.Sprintf("  %s%s %s (%s lines)", cursor, check, svc.Value, formatRate(float64(svc.Lines)))
+			switch {
+			case m.labelFocus && i == m.labelCursor:
+				b.WriteString(rPickerCursorStyle.Render(line))
+			case m.selected[i]:
+				b.WriteString(rPickerSelStyle.Render(line))
+			default:
+				b.WriteString(line)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n")
+	if m.labelFocus {
+		b.WriteString(rLabelStyle.Render("  j/k: move  |  Space: toggle  |  a: toggle all  |  Tab: histogram  |  Enter: slice  |  q: quit"))
+	} else {
+		b.WriteString(rLabelStyle.Render("  h/l: move  |  H/L: move 10  |  Space: mark start/end  |  a: whole capture  |  Tab: labels  |  Enter: slice  |  q: quit"))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func ordered(a, b int) (int, int) {
+	if a > b {
+		return b, a
+	}
+	return a, b
+}
+
+// bucketWidthLabel drops the zero units time.Duration prints ("1m0s").
+func bucketWidthLabel(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
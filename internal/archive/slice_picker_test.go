@@ -0,0 +1,119 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func newTestSlicePicker(t *testing.T) SlicePickerModel {
+	t.Helper()
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var index []rotate.IndexEntry
+	for i, app := range []string{"api", "worker"} {
+		name := []string{"2024-01-15T100000-000.jsonl", "2024-01-15T101000-000.jsonl"}[i]
+		from := base.Add(time.Duration(i) * 10 * time.Minute)
+		entries := []recv.LogEntry{
+			{Timestamp: from, Labels: map[string]string{"app": app}, Message: "line"},
+			{Timestamp: from.Add(9 * time.Minute), Labels: map[string]string{"app": app}, Message: "line"},
+		}
+		writeDataFile(t, dir, name, entries)
+		lines := int64(100 * (i*9 + 1)) // the worker file is the spike
+		index = append(index, rotate.IndexEntry{File: name, From: from, To: from.Add(9 * time.Minute), Lines: lines,
+			Labels: map[string]map[string]int64{"app": {app: lines}}})
+	}
+	writeMetadata(t, dir, base, base.Add(19*time.Minute), 1100)
+	writeIndex(t, dir, index)
+
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewSlicePickerModel(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func sendPickerKey(m SlicePickerModel, key string) SlicePickerModel {
+	msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	switch key {
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "tab":
+		msg = tea.KeyMsg{Type: tea.KeyTab}
+	}
+	updated, _ := m.Update(msg)
+	return updated.(SlicePickerModel)
+}
+
+func TestSlicePicker(t *testing.T) {
+	m := newTestSlicePicker(t)
+	if m.width != 10*time.Second || len(m.buckets) != 115 {
+		t.Fatalf("width = %s, buckets = %d; want 10s buckets for a 19m capture", m.width, len(m.buckets))
+	}
+	if !strings.Contains(m.View(), "whole capture  1.1K lines") {
+		t.Errorf("view does not summarize the whole capture:\n%s", m.View())
+	}
+
+	// mark from the right edge back to bucket 60: the window is ordered
+	for _, key := range []string{"G", " ", "H", "H", "H", "H", "H"} {
+		m = sendPickerKey(m, key)
+	}
+	m = sendPickerKey(m, "h")
+	if !strings.Contains(m.View(), "space to set the end") {
+		t.Error("view should show the window being marked")
+	}
+	m = sendPickerKey(m, " ")
+
+	// deselect the busiest label value, which is listed first
+	m = sendPickerKey(m, "tab")
+	m = sendPickerKey(m, " ")
+	m = sendPickerKey(m, "enter")
+
+	pick, ok := m.Result()
+	if !ok {
+		t.Fatal("expected a pick after enter")
+	}
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	if !pick.From.Equal(base.Add(63*10*time.Second)) || !pick.To.Equal(base.Add(115*10*time.Second)) {
+		t.Errorf("window = %s to %s", pick.From, pick.To)
+	}
+	if len(pick.Labels) != 1 || pick.Labels[0] != (LabelFilter{Key: "app", Value: "api"}) {
+		t.Errorf("labels = %v, want [app=api]", pick.Labels)
+	}
+}
+
+func TestSlicePicker_WholeCaptureAndCancel(t *testing.T) {
+	m := newTestSlicePicker(t)
+	m = sendPickerKey(m, " ")
+	m = sendPickerKey(m, "a") // reset to the whole capture
+	pick, ok := sendPickerKey(m, "enter").Result()
+	if !ok || !pick.From.IsZero() || !pick.To.IsZero() || pick.Labels != nil {
+		t.Errorf("pick = %+v, ok = %v; want the whole capture", pick, ok)
+	}
+
+	if _, ok := sendPickerKey(m, "q").Result(); ok {
+		t.Error("expected no pick after q")
+	}
+}
+
+func TestSlicePicker_NoIndex(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	writeMetadata(t, dir, base, base, 0)
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSlicePickerModel(r); err == nil {
+		t.Error("expected error for a capture without an index")
+	}
+}
@@ -426,3 +426,29 @@ func TestSlice_OutputDirIsInput(t *testing.T) {
 }
 
 // TODO: Add more tests for combined filters, empty capture, metadata/index recalculation, etc.
+
+func TestSlice_FieldFilter(t *testing.T) {
+	captureDir := writeFieldCapture(t, 30, true)
+	outputDir := filepath.Join(t.TempDir(), "output")
+
+	err := Slice(SliceOptions{
+		CaptureDir: captureDir,
+		OutputDir:  outputDir,
+		Fields:     []FieldMatcher{{Key: "trace_id", Value: "t05"}},
+	})
+	if err != nil {
+		t.Fatalf("Slice failed: %v", err)
+	}
+
+	outIndex, err := ReadIndex(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outIndex.Entries) != 1 {
+		t.Fatalf("Expected the one file holding t05, got %+v", outIndex.Entries)
+	}
+	lines := readZstFile(t, filepath.Join(outputDir, outIndex.Entries[0].File))
+	if len(lines) != 1 || !strings.Contains(lines[0], `\"trace_id\":\"t05\"`) {
+		t.Errorf("sliced lines = %v", lines)
+	}
+}
@@ -16,46 +16,114 @@ import (
 
 // Pack creates a tar.zst archive from a capture directory.
 func Pack(src, dst string) error {
-	// Validate source is a capture directory
-	metaPath := filepath.Join(src, "metadata.json")
-	if _, err := os.Stat(metaPath); err != nil {
-		return fmt.Errorf("not a capture directory (missing metadata.json): %w", err)
+	// Validate before creating the output file
+	if err := checkCaptureDir(src); err != nil {
+		return err
 	}
 
 	out, err := os.Create(dst)
 	if err != nil {
 		return fmt.Errorf("create output: %w", err)
 	}
+	err = PackTo(src, out)
+	if outErr := out.Close(); outErr != nil && err == nil {
+		err = outErr
+	}
+	return err
+}
+
+// PackTo streams a tar.zst archive of a capture directory to w, so it can
+// be written to a pipe (e.g. an object storage upload) without a local copy.
+// Shards of a sharded capture outside src are packed as shard-N
+// directories, and the packed metadata.json points at them, so the
+// unpacked capture is self-contained.
+func PackTo(src string, w io.Writer) error {
+	if err := checkCaptureDir(src); err != nil {
+		return err
+	}
 
-	zw, err := zstd.NewWriter(out)
+	// metadata that cannot be read is packed as is and rejected by Unpack
+	type packedShard struct{ dir, name string }
+	var shards []packedShard
+	var metaData []byte
+	if meta, err := recv.ReadMetadata(src); err == nil && len(meta.Shards) > 0 {
+		packed := *meta
+		packed.Shards = append([]string(nil), meta.Shards...)
+		for i, dir := range captureDirs(src, meta)[1:] {
+			if !outsideDir(src, dir) {
+				continue
+			}
+			name := fmt.Sprintf("shard-%d", i+1)
+			shards = append(shards, packedShard{dir: dir, name: name})
+			packed.Shards[i] = name
+		}
+		if len(shards) > 0 {
+			if metaData, err = json.MarshalIndent(&packed, "", "  "); err != nil {
+				return fmt.Errorf("marshal metadata: %w", err)
+			}
+		}
+	}
+
+	zw, err := zstd.NewWriter(w)
 	if err != nil {
-		_ = out.Close()
 		return fmt.Errorf("create zstd writer: %w", err)
 	}
 
 	tw := tar.NewWriter(zw)
 
-	walkErr := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+	walkErr := packTree(tw, src, "", metaData)
+	for _, s := range shards {
+		if walkErr != nil {
+			break
+		}
+		walkErr = packTree(tw, s.dir, s.name, nil)
+	}
+
+	// Close in reverse order: tar → zstd
+	if twErr := tw.Close(); twErr != nil && walkErr == nil {
+		walkErr = twErr
+	}
+	if zwErr := zw.Close(); zwErr != nil && walkErr == nil {
+		walkErr = zwErr
+	}
+
+	return walkErr
+}
+
+// packTree adds the files under root to tw, named by their path relative to
+// root below prefix. A non-nil metaData replaces root's metadata.json.
+func packTree(tw *tar.Writer, root, prefix string, metaData []byte) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		rel, err := filepath.Rel(src, path)
+		rel, err := filepath.Rel(root, path)
 		if err != nil {
 			return err
 		}
-		if rel == "." {
+		if rel == "." && prefix == "" {
 			return nil
 		}
+		name := filepath.Join(prefix, rel)
 
 		header, err := tar.FileInfoHeader(info, "")
 		if err != nil {
-			return fmt.Errorf("file info header %s: %w", rel, err)
+			return fmt.Errorf("file info header %s: %w", name, err)
+		}
+		header.Name = name
+
+		if metaData != nil && rel == "metadata.json" {
+			header.Size = int64(len(metaData))
+			if err := tw.WriteHeader(header); err != nil {
+				return fmt.Errorf("write header %s: %w", name, err)
+			}
+			_, err := tw.Write(metaData)
+			return err
 		}
-		header.Name = rel
 
 		if err := tw.WriteHeader(header); err != nil {
-			return fmt.Errorf("write header %s: %w", rel, err)
+			return fmt.Errorf("write header %s: %w", name, err)
 		}
 
 		if info.IsDir() {
@@ -64,25 +132,20 @@ func Pack(src, dst string) error {
 
 		f, err := os.Open(path)
 		if err != nil {
-			return fmt.Errorf("open %s: %w", rel, err)
+			return fmt.Errorf("open %s: %w", name, err)
 		}
 		_, copyErr := io.Copy(tw, f)
 		_ = f.Close()
 		return copyErr
 	})
+}
 
-	// Close in reverse order: tar → zstd → file
-	if twErr := tw.Close(); twErr != nil && walkErr == nil {
-		walkErr = twErr
-	}
-	if zwErr := zw.Close(); zwErr != nil && walkErr == nil {
-		walkErr = zwErr
-	}
-	if outErr := out.Close(); outErr != nil && walkErr == nil {
-		walkErr = outErr
+func checkCaptureDir(src string) error {
+	metaPath := filepath.Join(src, "metadata.json")
+	if _, err := os.Stat(metaPath); err != nil {
+		return fmt.Errorf("not a capture directory (missing metadata.json): %w", err)
 	}
-
-	return walkErr
+	return nil
 }
 
 // Unpack extracts a tar.zst archive to a directory and validates the capture.
@@ -220,3 +220,31 @@ func TestPackInvalidOutput(t *testing.T) {
 		t.Fatal("expected error for invalid output path")
 	}
 }
+
+func TestPackUnpackShards(t *testing.T) {
+	src, _ := writeShardedCapture(t)
+
+	archivePath := filepath.Join(t.TempDir(), "capture.tar.zst")
+	if err := Pack(src, archivePath); err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	dst := filepath.Join(t.TempDir(), "extracted")
+	if err := Unpack(archivePath, dst); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+
+	meta, err := recv.ReadMetadata(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Shards) != 1 || meta.Shards[0] != "shard-1" {
+		t.Errorf("shards = %v, want the shard packed as shard-1", meta.Shards)
+	}
+	r, err := NewReader(dst)
+	if err != nil {
+		t.Fatalf("NewReader on extracted: %v", err)
+	}
+	if len(r.Files()) != 3 || r.TotalLines() != 9 {
+		t.Errorf("files = %d, lines = %d; want 3 and 9 across both shards", len(r.Files()), r.TotalLines())
+	}
+}
@@ -0,0 +1,57 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// Splunk export defaults.
+const (
+	DefaultSplunkBatchLines = 1000
+	DefaultSplunkBatchBytes = 512 << 10 // well under HEC's default 1 MB max_content_length
+)
+
+// SplunkSendFunc sends entries to a Splunk HTTP Event Collector in one
+// request.
+type SplunkSendFunc func(ctx context.Context, entries []recv.LogEntry) error
+
+// SplunkExportOptions holds settings for ExportSplunk.
+type SplunkExportOptions struct {
+	Target     string   // collector address, part of the checkpoint fingerprint
+	Index      string   // Splunk index, part of the checkpoint fingerprint
+	Checkpoint string   // checkpoint path; empty disables checkpointing
+	Resume     bool     // skip files completed by an earlier run
+	BatchLines int      // max events per request (0 = DefaultSplunkBatchLines)
+	BatchBytes int      // max message bytes per request (0 = DefaultSplunkBatchBytes)
+	Profile    *Profile // per-file read profile (nil = off)
+}
+
+// ExportSplunk sends the filtered entries of src to a Splunk HTTP Event
+// Collector. A checkpoint records each completed file, so a failed export
+// can be resumed; HEC does not deduplicate, so events of the file in
+// flight may be indexed twice. Returns the number of entries sent.
+func ExportSplunk(ctx context.Context, src string, filter *Filter, send SplunkSendFunc, progress func(ExportProgress), opts SplunkExportOptions) (int64, error) {
+	if opts.BatchLines <= 0 {
+		opts.BatchLines = DefaultSplunkBatchLines
+	}
+	if opts.BatchBytes <= 0 {
+		opts.BatchBytes = DefaultSplunkBatchBytes
+	}
+
+	params := fmt.Sprintf("src=%s splunk=%s index=%q %s", src, opts.Target, opts.Index, filterFingerprint(filter))
+	return exportBatches(ctx, src, filter, progress, batchExport{
+		dest:       "splunk",
+		params:     params,
+		checkpoint: opts.Checkpoint,
+		resume:     opts.Resume,
+		batchLines: opts.BatchLines,
+		batchBytes: opts.BatchBytes,
+		profile:    opts.Profile,
+		group:      func(recv.LogEntry) (string, map[string]string) { return "", nil },
+		push: func(ctx context.Context, b *exportBatch) error {
+			return send(ctx, b.entries)
+		},
+	})
+}
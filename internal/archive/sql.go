@@ -0,0 +1,860 @@
+package archive
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+// SQLQuery is a parsed SQL statement over the entries of a capture, the one
+// table logs with the columns ts, msg and labels:
+//
+//	SELECT label('app'), count(*) FROM logs
+//	WHERE msg LIKE '%timeout%' AND ts >= '2024-01-15T10:00:00Z'
+//	GROUP BY 1 ORDER BY 2 DESC LIMIT 10
+//
+// Supported are WHERE, GROUP BY, HAVING, ORDER BY and LIMIT; the aggregates
+// count, count(DISTINCT x), sum, avg, min and max; the operators = != <> <
+// <= > >= + - * / % || AND OR NOT, LIKE, ILIKE, ~ and !~ (regex), IN and
+// IS NULL; and the functions in sqlFuncs. A missing label is NULL.
+type SQLQuery struct {
+	items   []sqlItem
+	where   sqlExpr
+	groupBy []sqlExpr
+	having  sqlExpr
+	orderBy []sqlOrder
+	limit   int // -1 = no limit
+	aggs    []*sqlAgg
+}
+
+type sqlItem struct {
+	expr  sqlExpr
+	name  string
+	alias bool // name was given with AS
+}
+
+type sqlOrder struct {
+	expr sqlExpr
+	desc bool
+}
+
+// ParseSQL parses a statement.
+func ParseSQL(s string) (*SQLQuery, error) {
+	toks, err := lexSQL(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &sqlParser{s: s, toks: toks}
+	q, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	if err := q.checkColumns(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Columns returns the names of the result columns.
+func (q *SQLQuery) Columns() []string {
+	names := make([]string, len(q.items))
+	for i, it := range q.items {
+		names[i] = it.name
+	}
+	return names
+}
+
+// grouped reports whether rows are aggregated into groups.
+func (q *SQLQuery) grouped() bool { return len(q.groupBy) > 0 || len(q.aggs) > 0 }
+
+// Run scans the capture at src and calls emit with each result row, in
+// order, until it returns false. Queries without grouping or ORDER BY
+// stream rows as they are read and stop at LIMIT. Time ranges and label
+// equalities ANDed into WHERE skip files through the index, and LIKE or ~
+// on msg through the bloom filters. Lines collapsed by recv --dedup-window
+// count in full. Run returns the number of lines scanned.
+func (q *SQLQuery) Run(src string, emit func(row []any) bool) (int64, error) {
+	reader, err := NewReader(src)
+	if err != nil {
+		return 0, fmt.Errorf("open source: %w", err)
+	}
+	reader.SetExpandRepeats(true)
+	filter := q.pushdown()
+
+	if !q.grouped() && len(q.orderBy) == 0 {
+		n := 0
+		scanned, err := reader.Scan(filter, func(e recv.LogEntry) bool {
+			r := &sqlRow{e: &e}
+			if q.where != nil && !truthy(q.where.eval(r)) {
+				return true
+			}
+			if q.limit >= 0 && n >= q.limit {
+				return false
+			}
+			n++
+			return emit(q.output(r)) && (q.limit < 0 || n < q.limit)
+		})
+		return scanned, err
+	}
+
+	var rows []*sqlRow
+	var scanned int64
+	if q.grouped() {
+		groups := make(map[string]*sqlGroup)
+		var order []*sqlGroup
+		scanned, err = reader.Scan(filter, func(e recv.LogEntry) bool {
+			r := &sqlRow{e: &e}
+			if q.where != nil && !truthy(q.where.eval(r)) {
+				return true
+			}
+			key := groupKey(q.groupBy, r)
+			g := groups[key]
+			if g == nil {
+				g = &sqlGroup{first: e, accs: make([]sqlAcc, len(q.aggs))}
+				groups[key] = g
+				order = append(order, g)
+			}
+			for _, a := range q.aggs {
+				a.update(&g.accs[a.idx], &sqlRow{e: &e})
+			}
+			return true
+		})
+		if len(order) == 0 && len(q.groupBy) == 0 {
+			order = append(order, &sqlGroup{accs: make([]sqlAcc, len(q.aggs))}) // aggregates over no rows
+		}
+		for _, g := range order {
+			r := &sqlRow{e: &g.first, g: g}
+			r.out = q.output(r)
+			if q.having != nil && !truthy(q.having.eval(r)) {
+				continue
+			}
+			rows = append(rows, r)
+		}
+	} else {
+		scanned, err = reader.Scan(filter, func(e recv.LogEntry) bool {
+			r := &sqlRow{e: &e}
+			if q.where != nil && !truthy(q.where.eval(r)) {
+				return true
+			}
+			r.out = q.output(r)
+			rows = append(rows, r)
+			return true
+		})
+	}
+	if err != nil {
+		return scanned, err
+	}
+
+	if len(q.orderBy) > 0 {
+		keys := make(map[*sqlRow][]any, len(rows))
+		for _, r := range rows {
+			k := make([]any, len(q.orderBy))
+			for i, o := range q.orderBy {
+				k[i] = o.expr.eval(r)
+			}
+			keys[r] = k
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			ki, kj := keys[rows[i]], keys[rows[j]]
+			for n, o := range q.orderBy {
+				c := compareOrder(ki[n], kj[n])
+				if c == 0 {
+					continue
+				}
+				if o.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+	for i, r := range rows {
+		if q.limit >= 0 && i >= q.limit {
+			break
+		}
+		if !emit(r.out) {
+			break
+		}
+	}
+	return scanned, nil
+}
+
+func (q *SQLQuery) output(r *sqlRow) []any {
+	out := make([]any, len(q.items))
+	for i, it := range q.items {
+		out[i] = it.expr.eval(r)
+	}
+	r.out = out
+	return out
+}
+
+// pushdown derives a Filter from the conjuncts of WHERE the index can
+// answer. It only narrows what is read; WHERE is still applied to every
+// entry.
+func (q *SQLQuery) pushdown() *Filter {
+	f := &Filter{}
+	var walk func(e sqlExpr)
+	walk = func(e sqlExpr) {
+		switch x := e.(type) {
+		case *sqlBinary:
+			if x.op == "AND" {
+				walk(x.l)
+				walk(x.r)
+				return
+			}
+			f.pushComparison(x)
+		case *sqlMatch:
+			if col, ok := x.x.(*sqlCol); ok && col.name == "msg" && !x.not && f.Grep == nil {
+				f.Grep = x.re
+			}
+		}
+	}
+	if q.where != nil {
+		walk(q.where)
+	}
+	return f
+}
+
+// pushComparison narrows f by ts <op> 'time' or label('k') = 'v'.
+func (f *Filter) pushComparison(b *sqlBinary) {
+	op, l, r := b.op, b.l, b.r
+	if _, ok := r.(*sqlCol); ok {
+		l, r = r, l
+		op = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<=", "=": "=", "!=": "!="}[op]
+	}
+	lit, ok := r.(*sqlLit)
+	if !ok {
+		return
+	}
+	s, ok := lit.v.(string)
+	if !ok {
+		return
+	}
+	if col, ok := l.(*sqlCol); ok && col.name == "ts" {
+		t, ok := parseSQLTime(s)
+		if !ok {
+			return
+		}
+		if (op == ">" || op == ">=" || op == "=") && t.After(f.From) {
+			f.From = t
+		}
+		if (op == "<" || op == "<=" || op == "=") && (f.To.IsZero() || t.Before(f.To)) {
+			f.To = t
+		}
+		return
+	}
+	if c, ok := l.(*sqlCall); ok && c.name == "label" && op == "=" {
+		if key, ok := c.args[0].(*sqlLit); ok {
+			if k, ok := key.v.(string); ok {
+				f.Labels = append(f.Labels, LabelMatcher{Key: k, Value: s})
+			}
+		}
+	}
+}
+
+// checkColumns reports references to columns that do not exist.
+func (q *SQLQuery) checkColumns() error {
+	var err error
+	check := func(e sqlExpr) {
+		walkSQL(e, func(e sqlExpr) {
+			if c, ok := e.(*sqlCol); ok && err == nil && !sqlColumns[c.name] {
+				err = fmt.Errorf("unknown column %q: columns are ts, msg and labels; read a label with label('%s')", c.name, c.name)
+			}
+		})
+	}
+	for _, it := range q.items {
+		check(it.expr)
+	}
+	check(q.where)
+	for _, e := range q.groupBy {
+		check(e)
+	}
+	check(q.having)
+	for _, o := range q.orderBy {
+		check(o.expr)
+	}
+	return err
+}
+
+var sqlColumns = map[string]bool{"ts": true, "msg": true, "labels": true}
+
+// resolveAliases replaces bare identifiers naming a select alias with a
+// reference to that column.
+func resolveAliases(e sqlExpr, q *SQLQuery) sqlExpr {
+	if c, ok := e.(*sqlCol); ok && !sqlColumns[c.name] {
+		for i, it := range q.items {
+			if it.alias && strings.EqualFold(it.name, c.name) {
+				return &sqlOutRef{i: i}
+			}
+		}
+		return e
+	}
+	switch x := e.(type) {
+	case *sqlBinary:
+		x.l, x.r = resolveAliases(x.l, q), resolveAliases(x.r, q)
+	case *sqlNot:
+		x.x = resolveAliases(x.x, q)
+	case *sqlIsNull:
+		x.x = resolveAliases(x.x, q)
+	case *sqlMatch:
+		x.x = resolveAliases(x.x, q)
+	case *sqlIn:
+		x.x = resolveAliases(x.x, q)
+		for i := range x.list {
+			x.list[i] = resolveAliases(x.list[i], q)
+		}
+	case *sqlCall:
+		for i := range x.args {
+			x.args[i] = resolveAliases(x.args[i], q)
+		}
+	}
+	return e
+}
+
+// walkSQL calls fn for e and every expression below it.
+func walkSQL(e sqlExpr, fn func(sqlExpr)) {
+	if e == nil {
+		return
+	}
+	fn(e)
+	switch x := e.(type) {
+	case *sqlBinary:
+		walkSQL(x.l, fn)
+		walkSQL(x.r, fn)
+	case *sqlNot:
+		walkSQL(x.x, fn)
+	case *sqlIsNull:
+		walkSQL(x.x, fn)
+	case *sqlMatch:
+		walkSQL(x.x, fn)
+	case *sqlIn:
+		walkSQL(x.x, fn)
+		for _, l := range x.list {
+			walkSQL(l, fn)
+		}
+	case *sqlCall:
+		for _, a := range x.args {
+			walkSQL(a, fn)
+		}
+	case *sqlAgg:
+		walkSQL(x.arg, fn)
+	}
+}
+
+func hasAgg(e sqlExpr) bool {
+	found := false
+	walkSQL(e, func(e sqlExpr) {
+		if _, ok := e.(*sqlAgg); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+// sqlRow is what expressions are evaluated against: an entry, and for
+// grouped queries the group's aggregates and output columns.
+type sqlRow struct {
+	e   *recv.LogEntry
+	g   *sqlGroup
+	out []any
+}
+
+type sqlGroup struct {
+	first recv.LogEntry // non-aggregate columns are taken from the group's first entry
+	accs  []sqlAcc
+}
+
+// groupKey encodes the GROUP BY values of r.
+func groupKey(exprs []sqlExpr, r *sqlRow) string {
+	var b strings.Builder
+	for _, e := range exprs {
+		v := e.eval(r)
+		fmt.Fprintf(&b, "%T:%v\x00", v, v)
+	}
+	return b.String()
+}
+
+// sqlExpr is a node of a parsed expression. Values are nil (NULL), string,
+// float64, bool, time.Time or map[string]string.
+type sqlExpr interface {
+	eval(r *sqlRow) any
+}
+
+type sqlLit struct{ v any }
+
+func (l *sqlLit) eval(*sqlRow) any { return l.v }
+
+type sqlCol struct{ name string }
+
+func (c *sqlCol) eval(r *sqlRow) any {
+	switch c.name {
+	case "ts":
+		return r.e.Timestamp
+	case "msg":
+		return r.e.Message
+	default:
+		if r.e.Labels == nil {
+			return map[string]string{}
+		}
+		return r.e.Labels
+	}
+}
+
+// sqlOutRef is a result column referenced by number or alias.
+type sqlOutRef struct{ i int }
+
+func (o *sqlOutRef) eval(r *sqlRow) any { return r.out[o.i] }
+
+type sqlNot struct{ x sqlExpr }
+
+func (n *sqlNot) eval(r *sqlRow) any {
+	v := n.x.eval(r)
+	if v == nil {
+		return nil
+	}
+	return !truthy(v)
+}
+
+type sqlIsNull struct {
+	x   sqlExpr
+	not bool
+}
+
+func (n *sqlIsNull) eval(r *sqlRow) any { return (n.x.eval(r) == nil) != n.not }
+
+// sqlMatch is LIKE, ILIKE, ~ or !~.
+type sqlMatch struct {
+	x   sqlExpr
+	re  *regexp.Regexp
+	not bool
+}
+
+func (m *sqlMatch) eval(r *sqlRow) any {
+	v := m.x.eval(r)
+	if v == nil {
+		return nil
+	}
+	return m.re.MatchString(toString(v)) != m.not
+}
+
+type sqlIn struct {
+	x    sqlExpr
+	list []sqlExpr
+	not  bool
+}
+
+func (in *sqlIn) eval(r *sqlRow) any {
+	v := in.x.eval(r)
+	if v == nil {
+		return nil
+	}
+	for _, e := range in.list {
+		if c, ok := compareValues(v, e.eval(r)); ok && c == 0 {
+			return !in.not
+		}
+	}
+	return in.not
+}
+
+type sqlBinary struct {
+	op   string
+	l, r sqlExpr
+}
+
+func (b *sqlBinary) eval(r *sqlRow) any {
+	l := b.l.eval(r)
+	switch b.op {
+	case "AND":
+		if l != nil && !truthy(l) {
+			return false
+		}
+		rv := b.r.eval(r)
+		if rv != nil && !truthy(rv) {
+			return false
+		}
+		if l == nil || rv == nil {
+			return nil
+		}
+		return true
+	case "OR":
+		if l != nil && truthy(l) {
+			return true
+		}
+		rv := b.r.eval(r)
+		if rv != nil && truthy(rv) {
+			return true
+		}
+		if l == nil || rv == nil {
+			return nil
+		}
+		return false
+	}
+
+	rv := b.r.eval(r)
+	if l == nil || rv == nil {
+		return nil
+	}
+	switch b.op {
+	case "||":
+		return toString(l) + toString(rv)
+	case "+", "-", "*", "/", "%":
+		x, ok1 := toNumber(l)
+		y, ok2 := toNumber(rv)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		switch b.op {
+		case "+":
+			return x + y
+		case "-":
+			return x - y
+		case "*":
+			return x * y
+		case "/":
+			if y == 0 {
+				return nil
+			}
+			return x / y
+		default:
+			if y == 0 {
+				return nil
+			}
+			return math.Mod(x, y)
+		}
+	}
+	c, ok := compareValues(l, rv)
+	if !ok {
+		return nil
+	}
+	switch b.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// sqlCall is a scalar function call.
+type sqlCall struct {
+	name string
+	args []sqlExpr
+	fn   func(r *sqlRow, args []any) any
+}
+
+func (c *sqlCall) eval(r *sqlRow) any {
+	args := make([]any, len(c.args))
+	for i, a := range c.args {
+		args[i] = a.eval(r)
+	}
+	return c.fn(r, args)
+}
+
+type sqlFunc struct {
+	min, max int // argument count, max -1 = any
+	fn       func(r *sqlRow, args []any) any
+	check    func(args []sqlExpr) error
+}
+
+// sqlFuncs are the scalar functions. All but coalesce return NULL for a
+// NULL argument.
+var sqlFuncs = map[string]sqlFunc{
+	// label('key') is the value of a label, NULL if the entry lacks it.
+	"label": {min: 1, max: 1, fn: func(r *sqlRow, a []any) any {
+		if a[0] == nil {
+			return nil
+		}
+		if v, ok := r.e.Labels[toString(a[0])]; ok {
+			return v
+		}
+		return nil
+	}},
+	// field('key') is a field of a JSON message (dotted path), NULL if absent.
+	"field": {min: 1, max: 1, fn: func(r *sqlRow, a []any) any {
+		if a[0] == nil {
+			return nil
+		}
+		key := toString(a[0])
+		if v, ok := rotate.ExtractFields(r.e.Message, []string{key})[key]; ok {
+			return v
+		}
+		return nil
+	}},
+	"lower": {min: 1, max: 1, fn: strFunc(strings.ToLower)},
+	"upper": {min: 1, max: 1, fn: strFunc(strings.ToUpper)},
+	"length": {min: 1, max: 1, fn: func(_ *sqlRow, a []any) any {
+		if a[0] == nil {
+			return nil
+		}
+		return float64(utf8.RuneCountInString(toString(a[0])))
+	}},
+	// signature(msg) is the normalized error pattern triage groups by.
+	"signature": {min: 1, max: 1, fn: strFunc(NormalizeMessage)},
+	"is_error": {min: 1, max: 1, fn: func(_ *sqlRow, a []any) any {
+		if a[0] == nil {
+			return nil
+		}
+		return IsError(toString(a[0]))
+	}},
+	// bucket(ts, '5m') truncates a time to a multiple of the duration.
+	"bucket": {min: 2, max: 2, fn: func(_ *sqlRow, a []any) any {
+		t, ok := toTime(a[0])
+		if !ok || a[1] == nil {
+			return nil
+		}
+		d, err := time.ParseDuration(toString(a[1]))
+		if err != nil || d <= 0 {
+			return nil
+		}
+		return t.Truncate(d)
+	}, check: func(args []sqlExpr) error {
+		lit, ok := args[1].(*sqlLit)
+		if !ok {
+			return nil
+		}
+		if d, err := time.ParseDuration(toString(lit.v)); err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %q", toString(lit.v))
+		}
+		return nil
+	}},
+	"coalesce": {min: 1, max: -1, fn: func(_ *sqlRow, a []any) any {
+		for _, v := range a {
+			if v != nil {
+				return v
+			}
+		}
+		return nil
+	}},
+}
+
+func strFunc(f func(string) string) func(*sqlRow, []any) any {
+	return func(_ *sqlRow, a []any) any {
+		if a[0] == nil {
+			return nil
+		}
+		return f(toString(a[0]))
+	}
+}
+
+var sqlAggregates = map[string]bool{"count": true, "sum": true, "avg": true, "min": true, "max": true}
+
+// sqlAgg is an aggregate call; its value is read from the row's group.
+type sqlAgg struct {
+	fn       string
+	arg      sqlExpr // nil for count(*)
+	distinct bool
+	idx      int // position in SQLQuery.aggs and sqlGroup.accs
+}
+
+type sqlAcc struct {
+	count int64
+	sum   float64
+	best  any // min or max
+	seen  map[string]bool
+}
+
+func (a *sqlAgg) update(acc *sqlAcc, r *sqlRow) {
+	if a.arg == nil {
+		acc.count++
+		return
+	}
+	v := a.arg.eval(r)
+	if v == nil {
+		return
+	}
+	if a.distinct {
+		key := fmt.Sprintf("%T:%v", v, v)
+		if acc.seen[key] {
+			return
+		}
+		if acc.seen == nil {
+			acc.seen = make(map[string]bool)
+		}
+		acc.seen[key] = true
+	}
+	switch a.fn {
+	case "count":
+		acc.count++
+	case "sum", "avg":
+		if n, ok := toNumber(v); ok {
+			acc.sum += n
+			acc.count++
+		}
+	case "min", "max":
+		if acc.best == nil {
+			acc.best = v
+			return
+		}
+		if c, ok := compareValues(v, acc.best); ok && (c < 0) == (a.fn == "min") && c != 0 {
+			acc.best = v
+		}
+	}
+}
+
+func (a *sqlAgg) eval(r *sqlRow) any {
+	if r.g == nil {
+		return nil
+	}
+	acc := r.g.accs[a.idx]
+	switch a.fn {
+	case "count":
+		return float64(acc.count)
+	case "sum":
+		if acc.count == 0 {
+			return nil
+		}
+		return acc.sum
+	case "avg":
+		if acc.count == 0 {
+			return nil
+		}
+		return acc.sum / float64(acc.count)
+	default:
+		return acc.best
+	}
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	case nil:
+		return false
+	}
+	return true
+}
+
+func toNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		return parseSQLTime(x)
+	}
+	return time.Time{}, false
+}
+
+// toString formats a value as it is printed.
+func toString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case time.Time:
+		return x.UTC().Format(time.RFC3339Nano)
+	case map[string]string:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + "=" + x[k]
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// compareValues orders two non-NULL values. Times compare with strings
+// holding a time, and numbers with numeric strings; anything else
+// compares as text.
+func compareValues(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := toTime(b); ok {
+			return ta.Compare(tb), true
+		}
+	}
+	if tb, ok := b.(time.Time); ok {
+		if ta, ok := toTime(a); ok {
+			return ta.Compare(tb), true
+		}
+	}
+	_, aNum := a.(float64)
+	_, bNum := b.(float64)
+	if aNum || bNum {
+		x, ok1 := toNumber(a)
+		y, ok2 := toNumber(b)
+		if ok1 && ok2 {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if ba, ok := a.(bool); ok {
+		if bb, ok := b.(bool); ok {
+			switch {
+			case ba == bb:
+				return 0, true
+			case !ba:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return strings.Compare(toString(a), toString(b)), true
+}
+
+// compareOrder is compareValues with NULL sorting first.
+func compareOrder(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	c, _ := compareValues(a, b)
+	return c
+}
+
+// FormatSQLValue formats a result value for text output; NULL is empty.
+func FormatSQLValue(v any) string { return toString(v) }
@@ -0,0 +1,606 @@
+package archive
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// sqlToken is a lexed token of a SQL statement.
+type sqlToken struct {
+	kind byte   // 'i' identifier or keyword, 's' string, 'n' number, 'p' punctuation, 0 end
+	text string // identifier, unquoted string, number or operator
+	pos  int
+}
+
+// sqlKeywords cannot be used as bare identifiers.
+var sqlKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "BY": true,
+	"HAVING": true, "ORDER": true, "ASC": true, "DESC": true, "LIMIT": true,
+	"AS": true, "AND": true, "OR": true, "NOT": true, "LIKE": true, "ILIKE": true,
+	"IN": true, "IS": true, "NULL": true, "TRUE": true, "FALSE": true, "DISTINCT": true,
+}
+
+func lexSQL(s string) ([]sqlToken, error) {
+	var toks []sqlToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(s) && (s[i] == '_' || unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i]))) {
+				i++
+			}
+			toks = append(toks, sqlToken{kind: 'i', text: s[start:i], pos: start})
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			start := i
+			for i < len(s) && strings.IndexByte("0123456789.eE", s[i]) >= 0 {
+				i++
+			}
+			if _, err := strconv.ParseFloat(s[start:i], 64); err != nil {
+				return nil, sqlErrorf(s, start, "invalid number %q", s[start:i])
+			}
+			toks = append(toks, sqlToken{kind: 'n', text: s[start:i], pos: start})
+		case c == '\'':
+			start := i
+			var b strings.Builder
+			for i++; ; i++ {
+				if i >= len(s) {
+					return nil, sqlErrorf(s, start, "unterminated string")
+				}
+				if s[i] == '\'' {
+					if i+1 < len(s) && s[i+1] == '\'' { // '' is a quote
+						b.WriteByte('\'')
+						i++
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(s[i])
+			}
+			toks = append(toks, sqlToken{kind: 's', text: b.String(), pos: start})
+		default:
+			op := ""
+			for _, o := range []string{"<=", ">=", "<>", "!=", "!~", "||", "(", ")", ",", "*", "+", "-", "/", "%", "=", "<", ">", "~", ";"} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, sqlErrorf(s, i, "unexpected %q", string(c))
+			}
+			toks = append(toks, sqlToken{kind: 'p', text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, sqlToken{pos: len(s)}), nil
+}
+
+func sqlErrorf(s string, pos int, format string, args ...any) error {
+	return fmt.Errorf("parse %q at offset %d: %s", s, pos, fmt.Sprintf(format, args...))
+}
+
+// sqlParser is a recursive-descent parser over the tokens of a statement.
+type sqlParser struct {
+	s    string
+	toks []sqlToken
+	i    int
+	aggs []*sqlAgg // aggregate calls, in order of appearance
+}
+
+func (p *sqlParser) peek() sqlToken { return p.toks[p.i] }
+
+func (p *sqlParser) next() sqlToken {
+	t := p.toks[p.i]
+	if t.kind != 0 {
+		p.i++
+	}
+	return t
+}
+
+func (p *sqlParser) errorf(format string, args ...any) error {
+	return sqlErrorf(p.s, p.peek().pos, format, args...)
+}
+
+// keyword consumes the keyword kw, case-insensitively.
+func (p *sqlParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == 'i' && strings.EqualFold(t.text, kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		return p.errorf("expected %s", kw)
+	}
+	return nil
+}
+
+// punct consumes the operator or punctuation op.
+func (p *sqlParser) punct(op string) bool {
+	if t := p.peek(); t.kind == 'p' && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) expectPunct(op string) error {
+	if !p.punct(op) {
+		if p.peek().kind == 0 {
+			return p.errorf("expected %q, got end of input", op)
+		}
+		return p.errorf("expected %q", op)
+	}
+	return nil
+}
+
+// text returns the source of the tokens from start up to the current one.
+func (p *sqlParser) text(start int) string {
+	return strings.TrimSpace(p.s[p.toks[start].pos:p.peek().pos])
+}
+
+func (p *sqlParser) statement() (*SQLQuery, error) {
+	q := &SQLQuery{limit: -1}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	if p.punct("*") {
+		for _, name := range []string{"ts", "labels", "msg"} {
+			q.items = append(q.items, sqlItem{expr: &sqlCol{name: name}, name: name})
+		}
+	} else {
+		for {
+			start := p.i
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			item := sqlItem{expr: e, name: p.text(start)}
+			if p.keyword("AS") {
+				t := p.next()
+				if t.kind != 'i' && t.kind != 's' {
+					return nil, sqlErrorf(p.s, t.pos, "expected column alias")
+				}
+				item.name, item.alias = t.text, true
+			} else if t := p.peek(); t.kind == 'i' && !sqlKeywords[strings.ToUpper(t.text)] {
+				item.name, item.alias = p.next().text, true
+			}
+			q.items = append(q.items, item)
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != 'i' || !strings.EqualFold(t.text, "logs") {
+		return nil, sqlErrorf(p.s, t.pos, "unknown table %q: the only table is logs", t.text)
+	}
+
+	if p.keyword("WHERE") {
+		n := len(p.aggs)
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if len(p.aggs) > n {
+			return nil, fmt.Errorf("aggregate functions are not allowed in WHERE; use HAVING")
+		}
+		q.where = e
+	}
+	if p.keyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			n := len(p.aggs)
+			e, err := p.orderTerm(q)
+			if err != nil {
+				return nil, err
+			}
+			if ref, ok := e.(*sqlOutRef); ok {
+				e = q.items[ref.i].expr
+				if hasAgg(e) {
+					return nil, fmt.Errorf("cannot GROUP BY %s: it is an aggregate", q.items[ref.i].name)
+				}
+			}
+			if len(p.aggs) > n {
+				return nil, fmt.Errorf("aggregate functions are not allowed in GROUP BY")
+			}
+			q.groupBy = append(q.groupBy, e)
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("HAVING") {
+		e, err := p.exprWithAliases(q)
+		if err != nil {
+			return nil, err
+		}
+		q.having = e
+	}
+	if p.keyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.orderTerm(q)
+			if err != nil {
+				return nil, err
+			}
+			key := sqlOrder{expr: e}
+			if p.keyword("DESC") {
+				key.desc = true
+			} else {
+				p.keyword("ASC")
+			}
+			q.orderBy = append(q.orderBy, key)
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("LIMIT") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != 'n' || err != nil || n < 0 {
+			return nil, sqlErrorf(p.s, t.pos, "LIMIT needs a non-negative integer")
+		}
+		q.limit = n
+	}
+	p.punct(";")
+	if t := p.peek(); t.kind != 0 {
+		return nil, p.errorf("unexpected %q", p.s[t.pos:])
+	}
+
+	q.aggs = p.aggs
+	if q.having != nil && len(q.aggs) == 0 && len(q.groupBy) == 0 {
+		return nil, fmt.Errorf("HAVING needs GROUP BY or an aggregate; use WHERE")
+	}
+	for _, a := range q.aggs {
+		if a.arg != nil && hasAgg(a.arg) {
+			return nil, fmt.Errorf("aggregate functions cannot be nested")
+		}
+	}
+	return q, nil
+}
+
+// orderTerm parses a GROUP BY or ORDER BY term: a 1-based column number,
+// a column alias, or an expression.
+func (p *sqlParser) orderTerm(q *SQLQuery) (sqlExpr, error) {
+	if t := p.peek(); t.kind == 'n' {
+		if next := p.toks[p.i+1]; next.kind == 0 || next.kind == 'p' && (next.text == "," || next.text == ";") || next.kind == 'i' {
+			n, err := strconv.Atoi(t.text)
+			if err != nil || n < 1 || n > len(q.items) {
+				return nil, p.errorf("column number %s out of range", t.text)
+			}
+			p.i++
+			return &sqlOutRef{i: n - 1}, nil
+		}
+	}
+	return p.exprWithAliases(q)
+}
+
+// exprWithAliases parses an expression in which bare identifiers may name
+// select columns by their alias.
+func (p *sqlParser) exprWithAliases(q *SQLQuery) (sqlExpr, error) {
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return resolveAliases(e, q), nil
+}
+
+func (p *sqlParser) expr() (sqlExpr, error) { return p.or() }
+
+func (p *sqlParser) or() (sqlExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = &sqlBinary{op: "OR", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *sqlParser) and() (sqlExpr, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = &sqlBinary{op: "AND", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *sqlParser) not() (sqlExpr, error) {
+	if p.keyword("NOT") {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &sqlNot{x: x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *sqlParser) comparison() (sqlExpr, error) {
+	l, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"=", "!=", "<>", "<=", ">=", "<", ">"} {
+		if p.punct(op) {
+			r, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			if op == "<>" {
+				op = "!="
+			}
+			return &sqlBinary{op: op, l: l, r: r}, nil
+		}
+	}
+
+	if p.keyword("IS") {
+		not := p.keyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &sqlIsNull{x: l, not: not}, nil
+	}
+
+	start := p.i
+	not := p.keyword("NOT")
+	switch {
+	case p.keyword("LIKE"), p.keyword("ILIKE"):
+		insensitive := strings.EqualFold(p.toks[p.i-1].text, "ILIKE")
+		t := p.next()
+		if t.kind != 's' {
+			return nil, sqlErrorf(p.s, t.pos, "LIKE needs a string pattern")
+		}
+		return &sqlMatch{x: l, re: likeRegexp(t.text, insensitive), not: not}, nil
+	case p.keyword("IN"):
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		in := &sqlIn{x: l, not: not}
+		for {
+			e, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, e)
+			if !p.punct(",") {
+				break
+			}
+		}
+		return in, p.expectPunct(")")
+	case !not && (p.punct("~") || p.punct("!~")):
+		neg := p.toks[p.i-1].text == "!~"
+		t := p.next()
+		if t.kind != 's' {
+			return nil, sqlErrorf(p.s, t.pos, "~ needs a string regex")
+		}
+		re, err := regexp.Compile(t.text)
+		if err != nil {
+			return nil, sqlErrorf(p.s, t.pos, "invalid regex: %v", err)
+		}
+		return &sqlMatch{x: l, re: re, not: neg}, nil
+	}
+	if not {
+		p.i = start
+		return nil, p.errorf("expected LIKE, ILIKE or IN after NOT")
+	}
+	return l, nil
+}
+
+func (p *sqlParser) additive() (sqlExpr, error) {
+	l, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range []string{"+", "-", "||"} {
+			if p.punct(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		r, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &sqlBinary{op: op, l: l, r: r}
+	}
+}
+
+func (p *sqlParser) multiplicative() (sqlExpr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range []string{"*", "/", "%"} {
+			if p.punct(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = &sqlBinary{op: op, l: l, r: r}
+	}
+}
+
+func (p *sqlParser) unary() (sqlExpr, error) {
+	if p.punct("-") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &sqlBinary{op: "-", l: &sqlLit{v: 0.0}, r: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *sqlParser) primary() (sqlExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case 'n':
+		v, _ := strconv.ParseFloat(t.text, 64)
+		return &sqlLit{v: v}, nil
+	case 's':
+		return &sqlLit{v: t.text}, nil
+	case 'p':
+		if t.text == "(" {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expectPunct(")")
+		}
+	case 'i':
+		upper := strings.ToUpper(t.text)
+		switch upper {
+		case "NULL":
+			return &sqlLit{}, nil
+		case "TRUE", "FALSE":
+			return &sqlLit{v: upper == "TRUE"}, nil
+		}
+		if p.punct("(") {
+			return p.call(t)
+		}
+		if sqlKeywords[upper] {
+			return nil, sqlErrorf(p.s, t.pos, "unexpected %s", upper)
+		}
+		return &sqlCol{name: strings.ToLower(t.text)}, nil
+	case 0:
+		return nil, sqlErrorf(p.s, t.pos, "unexpected end of input")
+	}
+	return nil, sqlErrorf(p.s, t.pos, "unexpected %q", t.text)
+}
+
+// call parses the arguments of function name, whose "(" is consumed.
+func (p *sqlParser) call(name sqlToken) (sqlExpr, error) {
+	fn := strings.ToLower(name.text)
+	if sqlAggregates[fn] {
+		a := &sqlAgg{fn: fn}
+		switch {
+		case p.punct("*"):
+			if fn != "count" {
+				return nil, sqlErrorf(p.s, name.pos, "%s(*) is not supported; only count(*)", fn)
+			}
+		default:
+			a.distinct = p.keyword("DISTINCT")
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			a.arg = arg
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		a.idx = len(p.aggs)
+		p.aggs = append(p.aggs, a)
+		return a, nil
+	}
+
+	f, ok := sqlFuncs[fn]
+	if !ok {
+		return nil, sqlErrorf(p.s, name.pos, "unknown function %s", name.text)
+	}
+	c := &sqlCall{name: fn, fn: f.fn}
+	if !p.punct(")") {
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, e)
+			if !p.punct(",") {
+				break
+			}
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.args) < f.min || f.max >= 0 && len(c.args) > f.max {
+		return nil, sqlErrorf(p.s, name.pos, "%s: wrong number of arguments", fn)
+	}
+	if f.check != nil {
+		if err := f.check(c.args); err != nil {
+			return nil, sqlErrorf(p.s, name.pos, "%s: %v", fn, err)
+		}
+	}
+	return c, nil
+}
+
+// likeRegexp converts a LIKE pattern (% any run, _ any character) to an
+// anchored regex.
+func likeRegexp(pattern string, insensitive bool) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?s)")
+	if insensitive {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// parseSQLTime reads a time literal: RFC3339, or a date with an optional
+// time of day, in UTC.
+func parseSQLTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
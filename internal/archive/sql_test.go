@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func runSQL(t *testing.T, src, query string) ([][]string, int64) {
+	t.Helper()
+	q, err := ParseSQL(query)
+	if err != nil {
+		t.Fatalf("ParseSQL(%q): %v", query, err)
+	}
+	var rows [][]string
+	scanned, err := q.Run(src, func(row []any) bool {
+		out := make([]string, len(row))
+		for i, v := range row {
+			out[i] = FormatSQLValue(v)
+			if v == nil {
+				out[i] = "NULL"
+			}
+		}
+		rows = append(rows, out)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Run(%q): %v", query, err)
+	}
+	return rows, scanned
+}
+
+func TestSQL(t *testing.T) {
+	src, _ := setupTriageSource(t)
+
+	tests := []struct {
+		query string
+		want  string // rows joined by ";", columns by "|"
+	}{
+		{`SELECT label('app'), count(*) FROM logs GROUP BY 1 ORDER BY 2 DESC, 1`, "api|6;gateway|2;worker|2"},
+		{`select count(*) from logs where msg like '%refused%'`, "3"},
+		{`SELECT count(*) FROM logs WHERE msg ILIKE '%CONNECTION%' AND label('app') = 'api'`, "3"},
+		{`SELECT label('app') AS app, count(*) n FROM logs WHERE is_error(msg) GROUP BY app HAVING n > 1 ORDER BY n DESC`, "api|5"},
+		{`SELECT msg FROM logs WHERE ts >= '2024-01-15T10:07:00Z' ORDER BY ts DESC`, "connection refused to payments:8080;health check ok"},
+		{`SELECT msg FROM logs LIMIT 2`, "request started;connection refused to payments:8080"},
+		{`SELECT count(DISTINCT label('app')), min(ts), max(length(msg)) FROM logs`, "3|2024-01-15T10:00:00Z|35"},
+		{`SELECT signature(msg), count(*) FROM logs WHERE msg ~ 'refused|timeout' GROUP BY 1 ORDER BY 2 DESC`, "connection refused to payments:<N>|3;timeout processing job=<N>|1"},
+		{`SELECT bucket(ts, '5m'), count(*) FROM logs GROUP BY 1 ORDER BY 1`, "2024-01-15T10:00:00Z|6;2024-01-15T10:05:00Z|4"},
+		{`SELECT label('pod'), count(*) FROM logs WHERE label('app') IN ('worker', 'gateway') GROUP BY 1`, "NULL|4"},
+		{`SELECT count(*), sum(length(msg)) FROM logs WHERE label('app') = 'nope'`, "0|NULL"},
+		{`SELECT upper(label('app')) || '-' || (1 + 2) FROM logs WHERE label('app') NOT IN ('api') AND msg NOT LIKE 'job%' LIMIT 1`, "GATEWAY-3"},
+	}
+	for _, tt := range tests {
+		rows, _ := runSQL(t, src, tt.query)
+		var got []string
+		for _, r := range rows {
+			got = append(got, strings.Join(r, "|"))
+		}
+		if g := strings.Join(got, ";"); g != tt.want {
+			t.Errorf("%s\n got %s\nwant %s", tt.query, g, tt.want)
+		}
+	}
+}
+
+func TestSQLPushdown(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var index []rotate.IndexEntry
+	for i, app := range []string{"api", "worker"} {
+		name := []string{"2024-01-15T100000-000.jsonl", "2024-01-15T110000-000.jsonl"}[i]
+		from := base.Add(time.Duration(i) * time.Hour)
+		var entries []recv.LogEntry
+		for j := range 5 {
+			entries = append(entries, recv.LogEntry{Timestamp: from.Add(time.Duration(j) * time.Minute), Labels: map[string]string{"app": app}, Message: "line"})
+		}
+		writeDataFile(t, dir, name, entries)
+		index = append(index, rotate.IndexEntry{File: name, From: from, To: from.Add(4 * time.Minute), Lines: 5,
+			Labels: map[string]map[string]int64{"app": {app: 5}}})
+	}
+	writeMetadata(t, dir, base, base.Add(time.Hour+4*time.Minute), 10)
+	writeIndex(t, dir, index)
+
+	for _, query := range []string{
+		`SELECT count(*) FROM logs WHERE ts > '2024-01-15 10:30'`,
+		`SELECT count(*) FROM logs WHERE label('app') = 'worker'`,
+		`SELECT count(*) FROM logs WHERE '2024-01-15T10:30:00Z' < ts AND label('app') = 'worker'`,
+	} {
+		rows, scanned := runSQL(t, dir, query)
+		if rows[0][0] != "5" || scanned != 5 {
+			t.Errorf("%s: count = %s, scanned = %d; want 5 from one file", query, rows[0][0], scanned)
+		}
+	}
+	if _, scanned := runSQL(t, dir, `SELECT count(*) FROM logs WHERE label('app') = 'worker' OR ts < '2024-01-15 10:30'`); scanned != 10 {
+		t.Errorf("OR: scanned = %d, want every file", scanned)
+	}
+}
+
+func TestParseSQLErrors(t *testing.T) {
+	for _, query := range []string{
+		``,
+		`SELECT`,
+		`SELECT * FROM events`,
+		`SELECT app FROM logs`,
+		`SELECT count(*) FROM logs WHERE count(*) > 1`,
+		`SELECT count(count(*)) FROM logs`,
+		`SELECT msg FROM logs HAVING msg = 'x'`,
+		`SELECT msg FROM logs ORDER BY 3`,
+		`SELECT nope(msg) FROM logs`,
+		`SELECT label() FROM logs`,
+		`SELECT bucket(ts, 'soon') FROM logs`,
+		`SELECT msg FROM logs WHERE msg LIKE label('app')`,
+		`SELECT msg FROM logs WHERE msg ~ '('`,
+		`SELECT 'open FROM logs`,
+		`SELECT msg FROM logs LIMIT -1`,
+		`SELECT msg FROM logs extra`,
+		`SELECT count(*) FROM logs GROUP BY 1`,
+	} {
+		if _, err := ParseSQL(query); err == nil {
+			t.Errorf("ParseSQL(%q): expected error", query)
+		}
+	}
+}
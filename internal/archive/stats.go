@@ -0,0 +1,409 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+const (
+	// statsMaxLabelValues caps the distinct values tracked per label key;
+	// past it the key's cardinality is reported as a lower bound.
+	statsMaxLabelValues = 100_000
+
+	// statsMaxMessages caps the distinct messages counted for the top-N
+	// list. When full, the rarest are pruned (lossy counting), so the top
+	// entries survive with counts that may be low by at most the prune floor.
+	statsMaxMessages = 200_000
+
+	// statsMessageKeyLen truncates messages before counting so huge
+	// payloads don't dominate memory; longer messages are grouped by prefix.
+	statsMessageKeyLen = 512
+)
+
+// Stats holds distribution statistics for a capture, computed in a single
+// pass over its entries.
+type Stats struct {
+	Dir              string             `json:"dir"`
+	Lines            int64              `json:"lines"`
+	MessageBytes     int64              `json:"message_bytes"`
+	RawBytes         int64              `json:"raw_bytes"`
+	DiskSize         int64              `json:"disk_size"`
+	CompressionRatio float64            `json:"compression_ratio,omitempty"`
+	From             time.Time          `json:"from,omitempty"`
+	To               time.Time          `json:"to,omitempty"`
+	Labels           []LabelCardinality `json:"labels"`
+	MessageLength    LengthStats        `json:"message_length"`
+	LinesPerMinute   Percentiles        `json:"lines_per_minute"`
+	BytesPerMinute   Percentiles        `json:"bytes_per_minute"`
+	TopMessages      []RepeatedMessage  `json:"top_messages"`
+	// TopApproximate is set when distinct messages exceeded what stats
+	// tracks and rare ones were pruned; top counts may then be slightly low.
+	TopApproximate bool `json:"top_approximate,omitempty"`
+}
+
+// LabelCardinality is the number of distinct values seen for a label key.
+type LabelCardinality struct {
+	Key      string `json:"key"`
+	Values   int    `json:"values"`
+	Capped   bool   `json:"capped,omitempty"` // Values is a lower bound
+	Lines    int64  `json:"lines"`            // lines carrying the key
+	TopValue string `json:"top_value"`
+	TopLines int64  `json:"top_lines"`
+}
+
+// LengthStats describes the distribution of message lengths in bytes.
+type LengthStats struct {
+	Min       int64          `json:"min"`
+	Mean      float64        `json:"mean"`
+	P50       int64          `json:"p50"`
+	P90       int64          `json:"p90"`
+	P99       int64          `json:"p99"`
+	Max       int64          `json:"max"`
+	Histogram []LengthBucket `json:"histogram"`
+}
+
+// LengthBucket counts messages whose length falls in [Min, Max].
+type LengthBucket struct {
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+	Lines int64 `json:"lines"`
+}
+
+// Percentiles summarizes a per-minute series. Minutes with no lines between
+// the first and last entry count as zero.
+type Percentiles struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P99 int64 `json:"p99"`
+	Max int64 `json:"max"`
+}
+
+// RepeatedMessage is a message and how many lines repeated it verbatim.
+type RepeatedMessage struct {
+	Message string `json:"message"`
+	Lines   int64  `json:"lines"`
+}
+
+type labelCounter struct {
+	values map[string]int64
+	lines  int64
+	capped bool
+}
+
+type minuteCount struct {
+	lines, bytes int64
+}
+
+// ComputeStats scans the capture in dir once and returns per-label
+// cardinality, the message length distribution, per-minute line and byte
+// percentiles and the top repeated messages. top bounds the repeated
+// messages reported; 0 or less means 10.
+func ComputeStats(dir string, top int) (*Stats, error) {
+	if top <= 0 {
+		top = 10
+	}
+	summary, err := Inspect(dir)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewReader(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stats{
+		Dir:      dir,
+		RawBytes: summary.TotalBytes,
+		DiskSize: summary.DiskSize,
+	}
+	if s.RawBytes > 0 && s.DiskSize > 0 {
+		s.CompressionRatio = float64(s.RawBytes) / float64(s.DiskSize)
+	}
+
+	labels := make(map[string]*labelCounter)
+	lengths := make(map[int64]int64)
+	minutes := make(map[int64]*minuteCount)
+	messages := make(map[string]int64)
+	var pruneFloor int64
+
+	_, err = reader.Scan(nil, func(e recv.LogEntry) bool {
+		n := e.Count()
+		size := int64(len(e.Message))
+		s.Lines += n
+		s.MessageBytes += size * n
+		if !e.Timestamp.IsZero() {
+			if s.From.IsZero() || e.Timestamp.Before(s.From) {
+				s.From = e.Timestamp
+			}
+			if e.Timestamp.After(s.To) {
+				s.To = e.Timestamp
+			}
+			m := e.Timestamp.Unix() / 60
+			mc := minutes[m]
+			if mc == nil {
+				mc = &minuteCount{}
+				minutes[m] = mc
+			}
+			mc.lines += n
+			mc.bytes += size * n
+		}
+
+		for k, v := range e.Labels {
+			lc := labels[k]
+			if lc == nil {
+				lc = &labelCounter{values: make(map[string]int64)}
+				labels[k] = lc
+			}
+			lc.lines += n
+			if _, ok := lc.values[v]; ok || len(lc.values) < statsMaxLabelValues {
+				lc.values[v] += n
+			} else {
+				lc.capped = true
+			}
+		}
+
+		lengths[size] += n
+
+		key := e.Message
+		if len(key) > statsMessageKeyLen {
+			key = key[:statsMessageKeyLen]
+		}
+		if _, ok := messages[key]; !ok && len(messages) >= statsMaxMessages {
+			pruneFloor++
+			for m, c := range messages {
+				if c <= pruneFloor {
+					delete(messages, m)
+				}
+			}
+			s.TopApproximate = true
+		}
+		messages[key] += n
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Labels = labelCardinalities(labels)
+	s.MessageLength = lengthStats(lengths, s.Lines, s.MessageBytes)
+	s.LinesPerMinute, s.BytesPerMinute = minutePercentiles(minutes)
+	s.TopMessages = topMessages(messages, top)
+	return s, nil
+}
+
+func labelCardinalities(labels map[string]*labelCounter) []LabelCardinality {
+	out := make([]LabelCardinality, 0, len(labels))
+	for k, lc := range labels {
+		c := LabelCardinality{Key: k, Values: len(lc.values), Capped: lc.capped, Lines: lc.lines}
+		for v, n := range lc.values {
+			if n > c.TopLines || (n == c.TopLines && v < c.TopValue) {
+				c.TopValue, c.TopLines = v, n
+			}
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Values != out[j].Values {
+			return out[i].Values > out[j].Values
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+// lengthStats derives percentiles from the exact length counts and buckets
+// them by powers of two for the histogram.
+func lengthStats(lengths map[int64]int64, lines, bytes int64) LengthStats {
+	var ls LengthStats
+	if lines == 0 {
+		return ls
+	}
+	sizes := make([]int64, 0, len(lengths))
+	for size := range lengths {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+
+	ls.Min, ls.Max = sizes[0], sizes[len(sizes)-1]
+	ls.Mean = float64(bytes) / float64(lines)
+	ls.P50 = weightedPercentile(sizes, lengths, lines, 0.50)
+	ls.P90 = weightedPercentile(sizes, lengths, lines, 0.90)
+	ls.P99 = weightedPercentile(sizes, lengths, lines, 0.99)
+
+	// bucket i holds lengths in [2^(i-1), 2^i), bucket 0 empty messages;
+	// every bucket between the shortest and longest is kept so gaps show
+	var counts [65]int64
+	lo, hi := 64, 0
+	for size, n := range lengths {
+		b := bits.Len64(uint64(size))
+		counts[b] += n
+		lo, hi = min(lo, b), max(hi, b)
+	}
+	for b := lo; b <= hi; b++ {
+		bk := LengthBucket{Lines: counts[b]}
+		if b > 0 {
+			bk.Min = 1 << (b - 1)
+			bk.Max = 1<<b - 1
+		}
+		ls.Histogram = append(ls.Histogram, bk)
+	}
+	return ls
+}
+
+func weightedPercentile(sorted []int64, counts map[int64]int64, total int64, p float64) int64 {
+	rank := int64(float64(total)*p + 0.5)
+	rank = max(rank, 1)
+	var seen int64
+	for _, v := range sorted {
+		seen += counts[v]
+		if seen >= rank {
+			return v
+		}
+	}
+	return sorted[len(sorted)-1]
+}
+
+func minutePercentiles(minutes map[int64]*minuteCount) (lines, bytes Percentiles) {
+	if len(minutes) == 0 {
+		return lines, bytes
+	}
+	first, last := int64(-1), int64(0)
+	ls := make([]int64, 0, len(minutes))
+	bs := make([]int64, 0, len(minutes))
+	for m, c := range minutes {
+		if first < 0 || m < first {
+			first = m
+		}
+		last = max(last, m)
+		ls = append(ls, c.lines)
+		bs = append(bs, c.bytes)
+	}
+	// quiet minutes are counted, not stored, as a capture may span months
+	quiet := last - first + 1 - int64(len(minutes))
+	return percentiles(ls, quiet), percentiles(bs, quiet)
+}
+
+// percentiles returns the percentiles of vals plus zeros more zero values.
+func percentiles(vals []int64, zeros int64) Percentiles {
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	n := int64(len(vals)) + zeros
+	at := func(p float64) int64 {
+		i := min(max(int64(float64(n)*p+0.5)-1, 0), n-1)
+		if i < zeros {
+			return 0
+		}
+		return vals[i-zeros]
+	}
+	return Percentiles{P50: at(0.50), P90: at(0.90), P99: at(0.99), Max: vals[len(vals)-1]}
+}
+
+func topMessages(messages map[string]int64, top int) []RepeatedMessage {
+	out := make([]RepeatedMessage, 0, len(messages))
+	for m, n := range messages {
+		// a message seen once isn't repeated
+		if n > 1 {
+			out = append(out, RepeatedMessage{Message: m, Lines: n})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Lines != out[j].Lines {
+			return out[i].Lines > out[j].Lines
+		}
+		return out[i].Message < out[j].Message
+	})
+	if len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
+
+// WriteJSON writes the stats as indented JSON.
+func (s *Stats) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// WriteText renders the stats as human-readable text.
+func (s *Stats) WriteText(w io.Writer) {
+	tw := &textWriter{w: w}
+
+	tw.printf("Capture:  %s\n", s.Dir)
+	if s.Lines == 0 {
+		tw.println("Lines:    0")
+		return
+	}
+	if !s.From.IsZero() {
+		tw.printf("Lines:    %s over %s (%s – %s)\n", FormatCount(s.Lines), formatHumanDuration(s.To.Sub(s.From)),
+			s.From.UTC().Format("2006-01-02 15:04:05"), s.To.UTC().Format("2006-01-02 15:04:05"))
+	} else {
+		tw.printf("Lines:    %s\n", FormatCount(s.Lines))
+	}
+	switch {
+	case s.CompressionRatio > 0:
+		tw.printf("Size:     %s raw, %s on disk (%.1fx compression)\n", FormatBytes(s.RawBytes), FormatBytes(s.DiskSize), s.CompressionRatio)
+	default:
+		tw.printf("Size:     %s on disk\n", FormatBytes(s.DiskSize))
+	}
+	tw.println()
+
+	tw.printf("Lines per minute:  p50 %s  p90 %s  p99 %s  max %s\n",
+		FormatCount(s.LinesPerMinute.P50), FormatCount(s.LinesPerMinute.P90), FormatCount(s.LinesPerMinute.P99), FormatCount(s.LinesPerMinute.Max))
+	tw.printf("Bytes per minute:  p50 %s  p90 %s  p99 %s  max %s\n",
+		FormatBytes(s.BytesPerMinute.P50), FormatBytes(s.BytesPerMinute.P90), FormatBytes(s.BytesPerMinute.P99), FormatBytes(s.BytesPerMinute.Max))
+	tw.println()
+
+	ml := s.MessageLength
+	tw.printf("Message length (bytes):  min %d  p50 %d  p90 %d  p99 %d  max %d  mean %.0f\n", ml.Min, ml.P50, ml.P90, ml.P99, ml.Max, ml.Mean)
+	var peak int64
+	for _, b := range ml.Histogram {
+		peak = max(peak, b.Lines)
+	}
+	for _, b := range ml.Histogram {
+		bar := 0
+		if peak > 0 {
+			bar = int(b.Lines * 30 / peak)
+		}
+		tw.printf("  %-13s %-30s %5.1f%%\n", fmt.Sprintf("%d-%d", b.Min, b.Max), strings.Repeat("█", bar), float64(b.Lines)*100/float64(s.Lines))
+	}
+
+	if len(s.Labels) > 0 {
+		tw.println()
+		tw.println("Label cardinality:")
+		tw.printf("  %-20s %8s  %s\n", "KEY", "VALUES", "TOP VALUE")
+		for _, l := range s.Labels {
+			values := FormatCount(int64(l.Values))
+			if l.Capped {
+				values = ">" + values
+			}
+			tw.printf("  %-20s %8s  %s (%.0f%%)\n", l.Key, values, l.TopValue, float64(l.TopLines)*100/float64(s.Lines))
+		}
+	}
+
+	if len(s.TopMessages) > 0 {
+		tw.println()
+		title := "Top repeated messages:"
+		if s.TopApproximate {
+			title = "Top repeated messages (approximate counts):"
+		}
+		tw.println(title)
+		tw.printf("  %10s  %6s  %s\n", "LINES", "SHARE", "MESSAGE")
+		for _, m := range s.TopMessages {
+			msg := m.Message
+			if len(msg) > 100 {
+				msg = msg[:97] + "..."
+			}
+			tw.printf("  %10s  %5.1f%%  %s\n", FormatCount(m.Lines), float64(m.Lines)*100/float64(s.Lines), msg)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+	"github.com/ppiankov/logtap/internal/rotate"
+)
+
+func writeStatsCapture(t *testing.T) (dir string, base time.Time) {
+	t.Helper()
+	dir = t.TempDir()
+	base = time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var entries []recv.LogEntry
+	// minute 0: 4 lines, minute 1: empty, minute 2: 2 lines
+	for i := 0; i < 4; i++ {
+		entries = append(entries, recv.LogEntry{
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Labels:    map[string]string{"app": "api", "pod": "api-" + string(rune('a'+i))},
+			Message:   "health ok",
+		})
+	}
+	entries = append(entries,
+		recv.LogEntry{Timestamp: base.Add(2 * time.Minute), Labels: map[string]string{"app": "web"}, Message: "GET /index.html 200", RepeatCount: 3},
+		recv.LogEntry{Timestamp: base.Add(2*time.Minute + time.Second), Labels: map[string]string{"app": "web"}, Message: ""},
+	)
+	writeMetadata(t, dir, base, base.Add(3*time.Minute), 8)
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", entries)
+	writeIndex(t, dir, []rotate.IndexEntry{{
+		File:  "2024-01-15T100000-000.jsonl",
+		From:  base,
+		To:    base.Add(2*time.Minute + time.Second),
+		Lines: 8,
+		Bytes: 800,
+	}})
+	return dir, base
+}
+
+func TestComputeStats(t *testing.T) {
+	dir, base := writeStatsCapture(t)
+
+	s, err := ComputeStats(dir, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Lines != 8 {
+		t.Errorf("Lines = %d, want 8", s.Lines)
+	}
+	if !s.From.Equal(base) || !s.To.Equal(base.Add(2*time.Minute+time.Second)) {
+		t.Errorf("range = %v – %v", s.From, s.To)
+	}
+	if s.RawBytes != 800 || s.DiskSize == 0 || s.CompressionRatio <= 0 {
+		t.Errorf("sizes = raw %d disk %d ratio %f", s.RawBytes, s.DiskSize, s.CompressionRatio)
+	}
+
+	// pod has 4 values, app 2, sorted by cardinality
+	if len(s.Labels) != 2 || s.Labels[0].Key != "pod" || s.Labels[0].Values != 4 {
+		t.Fatalf("Labels = %+v", s.Labels)
+	}
+	if app := s.Labels[1]; app.Values != 2 || app.TopValue != "api" || app.TopLines != 4 || app.Lines != 8 {
+		t.Errorf("app = %+v", app)
+	}
+
+	ml := s.MessageLength
+	if ml.Min != 0 || ml.Max != 19 || ml.P50 != 9 || ml.P99 != 19 {
+		t.Errorf("MessageLength = %+v", ml)
+	}
+	var histLines int64
+	for _, b := range ml.Histogram {
+		histLines += b.Lines
+	}
+	if histLines != 8 || ml.Histogram[0].Max != 0 {
+		t.Errorf("Histogram = %+v", ml.Histogram)
+	}
+
+	// minutes: 4, 0, 4 lines
+	if s.LinesPerMinute.P50 != 4 || s.LinesPerMinute.Max != 4 {
+		t.Errorf("LinesPerMinute = %+v", s.LinesPerMinute)
+	}
+	if s.BytesPerMinute.Max != 57 {
+		t.Errorf("BytesPerMinute = %+v", s.BytesPerMinute)
+	}
+
+	// the empty message appears once and is not a repeat
+	if len(s.TopMessages) != 2 || s.TopMessages[0].Message != "health ok" || s.TopMessages[0].Lines != 4 ||
+		s.TopMessages[1].Lines != 3 {
+		t.Errorf("TopMessages = %+v", s.TopMessages)
+	}
+}
+
+func TestComputeStats_TopLimit(t *testing.T) {
+	dir, _ := writeStatsCapture(t)
+	s, err := ComputeStats(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.TopMessages) != 1 {
+		t.Errorf("TopMessages = %+v, want 1", s.TopMessages)
+	}
+}
+
+func TestStats_Write(t *testing.T) {
+	dir, _ := writeStatsCapture(t)
+	s, err := ComputeStats(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text bytes.Buffer
+	s.WriteText(&text)
+	for _, want := range []string{"Lines:    8", "compression", "Lines per minute:", "Label cardinality:", "pod", "Top repeated messages:", "health ok"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var js bytes.Buffer
+	if err := s.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var got Stats
+	if err := json.Unmarshal(js.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Lines != 8 || len(got.TopMessages) != 2 {
+		t.Errorf("round-trip = %+v", got)
+	}
+}
+
+func TestMinutePercentiles_LongSpan(t *testing.T) {
+	// two busy minutes ten years apart: the quiet minutes between them
+	// count as zeros without being allocated
+	minutes := map[int64]*minuteCount{
+		0:             {lines: 10, bytes: 100},
+		10 * 525600:   {lines: 20, bytes: 200},
+		10*525600 - 1: {lines: 5, bytes: 50},
+	}
+	lines, bytes := minutePercentiles(minutes)
+	if lines.P50 != 0 || lines.P99 != 0 || lines.Max != 20 {
+		t.Errorf("lines = %+v, want quiet percentiles and max 20", lines)
+	}
+	if bytes.Max != 200 {
+		t.Errorf("bytes max = %d, want 200", bytes.Max)
+	}
+
+	dense := map[int64]*minuteCount{0: {lines: 4}, 1: {lines: 0}, 2: {lines: 2}, 5: {lines: 8}} // 3 and 4 quiet
+	got, _ := minutePercentiles(dense)
+	want := Percentiles{P50: 0, P90: 4, P99: 8, Max: 8} // 0 0 0 2 4 8
+	if got != want {
+		t.Errorf("percentiles = %+v, want %+v", got, want)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"runtime"
 	"sort"
 	"strings"
@@ -14,8 +15,7 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/klauspost/compress/zstd"
-
+	"github.com/ppiankov/logtap/internal/logtypes"
 	"github.com/ppiankov/logtap/internal/recv"
 )
 
@@ -25,6 +25,25 @@ type TriageConfig struct {
 	Window        time.Duration // histogram bucket width (default 1m)
 	Top           int           // top error signatures (default 50)
 	MaxSignatures int           // cap on unique signatures kept in memory (default 10000)
+
+	CorrelationWindow        time.Duration // error bucket width for correlation (default 10s)
+	CorrelationMaxLag        time.Duration // longest cascade lag considered (default 5 windows)
+	CorrelationMinConfidence *float64      // discard correlations at or below this (nil = 0.5)
+	CorrelationLabel         string        // label key naming the service (default "app")
+
+	Owners *Owners // error ownership rules (nil = no owner column or rollups)
+
+	RestartWindow time.Duration // errors counted either side of a restart (default 1m)
+
+	Profile *Profile // per-file read profile (nil = off)
+
+	CacheDir string // per-file scan results kept between runs ("" = off)
+
+	Anomalies *AnomalyConfig // seasonal EWMA anomaly detection over the timeline (nil = off)
+
+	RequestPattern *regexp.Regexp // lines counted as requests when no harness metrics exist (nil = harness only)
+
+	Latency *LatencyConfig // request duration extraction for latency percentiles (nil = off)
 }
 
 // TriageProgress reports progress during triage scanning.
@@ -42,8 +61,18 @@ type TriageResult struct {
 	Talkers      map[string][]TalkerEntry `json:"talkers,omitempty"`
 	Windows      TriageWindows            `json:"windows"`
 	Correlations []Correlation            `json:"correlations,omitempty"`
+	Owners       []OwnerRollup            `json:"owners,omitempty"`
+	Restarts     []TriageRestart          `json:"restarts,omitempty"`
+	Anomalies    []Anomaly                `json:"anomalies,omitempty"`
+	Latency      *LatencyReport           `json:"latency,omitempty"`
 	TotalLines   int64                    `json:"total_lines"`
 	ErrorLines   int64                    `json:"error_lines"`
+	CachedFiles  int                      `json:"-"` // files whose scan was reused from TriageConfig.CacheDir
+
+	// set with a request rate, from harness metrics or RequestPattern
+	Requests            int64   `json:"requests,omitempty"`
+	ErrorsPer1kRequests float64 `json:"errors_per_1k_requests,omitempty"`
+	RequestSource       string  `json:"request_source,omitempty"`
 }
 
 // TriageBucket represents one time window in the histogram.
@@ -51,6 +80,21 @@ type TriageBucket struct {
 	Time       time.Time `json:"time"`
 	TotalLines int64     `json:"total_lines"`
 	ErrorLines int64     `json:"error_lines"`
+	Restarts   int64     `json:"restarts,omitempty"`
+
+	Requests    int64   `json:"requests,omitempty"`
+	ErrorsPer1k float64 `json:"errors_per_1k_requests,omitempty"`
+}
+
+// TriageRestart is a container restart with the errors logged in the pod
+// just before and just after it, so an error spike that caused the restart
+// can be told from one that followed it.
+type TriageRestart struct {
+	Time         time.Time `json:"time"`
+	Pod          string    `json:"pod,omitempty"`
+	Container    string    `json:"container,omitempty"`
+	ErrorsBefore int64     `json:"errors_before"`
+	ErrorsAfter  int64     `json:"errors_after"`
 }
 
 // ErrorSignature represents a normalized error pattern.
@@ -59,6 +103,7 @@ type ErrorSignature struct {
 	Count     int64     `json:"count"`
 	FirstSeen time.Time `json:"first_seen"`
 	Example   string    `json:"example"`
+	Owner     string    `json:"owner,omitempty"`
 }
 
 // TalkerEntry represents volume per label value.
@@ -89,17 +134,22 @@ type fileResult struct {
 	buckets    map[int64]*bucketCount             // minute unix → counts
 	signatures map[string]*sigAccum               // normalized → accumulator
 	talkers    map[string]map[string]*talkerAccum // label key → value → accumulator
+	restarts   []Restart
+	cached     bool // loaded from the triage cache
+	skipped    bool // rotated away before it could be read
 }
 
 type bucketCount struct {
-	total int64
-	errs  int64
+	total    int64
+	errs     int64
+	restarts int64
 }
 
 type sigAccum struct {
 	count     int64
 	firstSeen time.Time
 	example   string
+	owners    map[string]int64 // team → lines, "" = unowned (only with TriageConfig.Owners)
 }
 
 type talkerAccum struct {
@@ -129,6 +179,9 @@ func Triage(src string, cfg TriageConfig, progress func(TriageProgress)) (*Triag
 	if cfg.MaxSignatures <= 0 {
 		cfg.MaxSignatures = 10000
 	}
+	if cfg.RestartWindow <= 0 {
+		cfg.RestartWindow = DefaultRestartWindow
+	}
 
 	reader, err := NewReader(src)
 	if err != nil {
@@ -139,7 +192,8 @@ func Triage(src string, cfg TriageConfig, progress func(TriageProgress)) (*Triag
 	totalLines := reader.TotalLines()
 
 	// pass 1: parallel scan (skips rotated files gracefully)
-	results, err := parallelScan(files, cfg.Jobs, totalLines, progress)
+	cache := newTriageCache(cfg.CacheDir, cfg.Owners)
+	results, err := parallelScan(files, cfg.Jobs, totalLines, cfg.Owners, cfg.Profile, cache, progress)
 	if err != nil {
 		return nil, fmt.Errorf("scan: %w", err)
 	}
@@ -158,7 +212,7 @@ func Triage(src string, cfg TriageConfig, progress func(TriageProgress)) (*Triag
 		}
 		if len(newFiles) > 0 {
 			_, _ = fmt.Fprintf(os.Stderr, "\nCatch-up: scanning %d new files added during triage\n", len(newFiles))
-			catchupResults, err := parallelScan(newFiles, cfg.Jobs, 0, nil)
+			catchupResults, err := parallelScan(newFiles, cfg.Jobs, 0, cfg.Owners, cfg.Profile, cache, nil)
 			if err == nil {
 				results = append(results, catchupResults...)
 			}
@@ -167,6 +221,12 @@ func Triage(src string, cfg TriageConfig, progress func(TriageProgress)) (*Triag
 
 	// merge file results
 	merged := mergeResults(results)
+	cached := 0
+	for _, fr := range results {
+		if fr.cached {
+			cached++
+		}
+	}
 
 	// cap signatures to bound memory on large captures
 	if len(merged.signatures) > cfg.MaxSignatures {
@@ -187,7 +247,50 @@ func Triage(src string, cfg TriageConfig, progress func(TriageProgress)) (*Triag
 	windows := deriveWindows(timeline, merged.signatures)
 
 	// pass 3: cross-service error correlation
-	correlations, _ := Correlate(src, 10*time.Second)
+	correlations, _ := CorrelateWithConfig(src, CorrelateConfig{
+		Window:        cfg.CorrelationWindow,
+		MaxLag:        cfg.CorrelationMaxLag,
+		MinConfidence: cfg.CorrelationMinConfidence,
+		ServiceLabel:  cfg.CorrelationLabel,
+		Profile:       cfg.Profile,
+	})
+
+	var owners []OwnerRollup
+	if cfg.Owners != nil {
+		owners = buildOwnerRollups(merged.signatures)
+	}
+
+	// pass 4: errors around container restarts
+	restarts, err := countRestartErrors(reader, merged.restarts, cfg.RestartWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	// pass 5: request rate, to normalize error counts by load
+	requests, err := CountRequests(reader, cfg.RequestPattern)
+	if err != nil {
+		return nil, fmt.Errorf("count requests: %w", err)
+	}
+	if requests != nil {
+		for i := range timeline {
+			b := &timeline[i]
+			b.Requests = requests.PerMinute[b.Time.Unix()]
+			b.ErrorsPer1k = ErrorsPer1k(b.ErrorLines, b.Requests)
+		}
+	}
+
+	// pass 6: latency percentiles
+	var latency *LatencyReport
+	if cfg.Latency != nil {
+		if latency, err = ExtractLatency(reader, *cfg.Latency); err != nil {
+			return nil, fmt.Errorf("latency: %w", err)
+		}
+	}
+
+	var anomalies []Anomaly
+	if cfg.Anomalies != nil {
+		anomalies = DetectAnomalies(timeline, *cfg.Anomalies)
+	}
 
 	result := &TriageResult{
 		Dir:          src,
@@ -197,14 +300,24 @@ func Triage(src string, cfg TriageConfig, progress func(TriageProgress)) (*Triag
 		Talkers:      talkers,
 		Windows:      windows,
 		Correlations: correlations,
+		Owners:       owners,
+		Restarts:     restarts,
+		Anomalies:    anomalies,
+		Latency:      latency,
 		TotalLines:   merged.totalLines,
 		ErrorLines:   merged.errorLines,
+		CachedFiles:  cached,
+	}
+	if requests != nil {
+		result.Requests = requests.Total
+		result.ErrorsPer1kRequests = ErrorsPer1k(merged.errorLines, requests.Total)
+		result.RequestSource = requests.Source
 	}
 
 	return result, nil
 }
 
-func parallelScan(files []FileInfo, jobs int, totalLines int64, progress func(TriageProgress)) ([]*fileResult, error) {
+func parallelScan(files []FileInfo, jobs int, totalLines int64, owners *Owners, profile *Profile, cache *triageCache, progress func(TriageProgress)) ([]*fileResult, error) {
 	if len(files) == 0 {
 		return nil, nil
 	}
@@ -230,7 +343,7 @@ func parallelScan(files []FileInfo, jobs int, totalLines int64, progress func(Tr
 		go func() {
 			defer wg.Done()
 			for f := range fileCh {
-				fr, err := scanFileForTriage(f)
+				fr, err := cache.scan(f, owners, profile)
 				if err != nil {
 					scanErr.Store(err)
 					return
@@ -265,29 +378,35 @@ func parallelScan(files []FileInfo, jobs int, totalLines int64, progress func(Tr
 	return results, nil
 }
 
-func scanFileForTriage(f FileInfo) (*fileResult, error) {
-	file, err := os.Open(f.Path)
+func scanFileForTriage(f FileInfo, owners *Owners, profile *Profile) (*fileResult, error) {
+	file, name, err := openDataFile(f)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// File was rotated away during scan — skip gracefully.
 			_, _ = fmt.Fprintf(os.Stderr, "\nSkipping rotated file: %s\n", f.Name)
-			return newFileResult(), nil
+			fr := newFileResult()
+			fr.skipped = true
+			return fr, nil
 		}
 		return nil, err
 	}
 	defer func() { _ = file.Close() }()
 
-	var r io.Reader = file
-	if strings.HasSuffix(f.Name, ".zst") {
-		dec, err := zstd.NewReader(file)
+	prof := profile.profileFile(f.Name)
+	defer prof.done()
+
+	r := prof.wrapFile(file)
+	if strings.HasSuffix(name, ".zst") {
+		dec, closeDec, err := prof.openZstd(r)
 		if err != nil {
-			return nil, fmt.Errorf("zstd open: %w", err)
+			return nil, err
 		}
-		defer dec.Close()
+		defer closeDec()
 		r = dec
 	}
 
 	fr := newFileResult()
+	sigRules := make(map[string]int) // signature → first owner rule matching it
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 256*1024), 1024*1024)
 
@@ -298,14 +417,19 @@ func scanFileForTriage(f FileInfo) (*fileResult, error) {
 		}
 
 		var entry recv.LogEntry
-		if err := json.Unmarshal(line, &entry); err != nil {
+		t := prof.now()
+		err := json.Unmarshal(line, &entry)
+		prof.decoded(t)
+		if err != nil {
 			continue
 		}
+		t = prof.now()
 
-		fr.totalLines++
+		n := entry.Count() // lines collapsed by recv --dedup-window count in full
+		fr.totalLines += n
 		isErr := IsError(entry.Message)
 		if isErr {
-			fr.errorLines++
+			fr.errorLines += n
 		}
 
 		// timeline bucket
@@ -315,9 +439,17 @@ func scanFileForTriage(f FileInfo) (*fileResult, error) {
 			bc = &bucketCount{}
 			fr.buckets[bucketKey] = bc
 		}
-		bc.total++
+		bc.total += n
 		if isErr {
-			bc.errs++
+			bc.errs += n
+		}
+		if logtypes.IsRestartMarker(entry.Message) {
+			bc.restarts++
+			fr.restarts = append(fr.restarts, Restart{
+				Time:      entry.Timestamp,
+				Pod:       entry.Labels["pod"],
+				Container: entry.Labels["container"],
+			})
 		}
 
 		// error signature
@@ -328,10 +460,21 @@ func scanFileForTriage(f FileInfo) (*fileResult, error) {
 				sa = &sigAccum{firstSeen: entry.Timestamp, example: entry.Message}
 				fr.signatures[sig] = sa
 			}
-			sa.count++
+			sa.count += n
 			if entry.Timestamp.Before(sa.firstSeen) {
 				sa.firstSeen = entry.Timestamp
 			}
+			if owners != nil {
+				rule, ok := sigRules[sig]
+				if !ok {
+					rule = owners.signatureRule(sig)
+					sigRules[sig] = rule
+				}
+				if sa.owners == nil {
+					sa.owners = make(map[string]int64)
+				}
+				sa.owners[owners.match(entry.Labels, rule)] += n
+			}
 		}
 
 		// talkers
@@ -346,11 +489,12 @@ func scanFileForTriage(f FileInfo) (*fileResult, error) {
 				ta = &talkerAccum{}
 				vals[v] = ta
 			}
-			ta.total++
+			ta.total += n
 			if isErr {
-				ta.errs++
+				ta.errs += n
 			}
 		}
+		prof.filtered(t)
 	}
 
 	return fr, scanner.Err()
@@ -370,7 +514,9 @@ func mergeResults(results []*fileResult) *fileResult {
 			}
 			mbc.total += bc.total
 			mbc.errs += bc.errs
+			mbc.restarts += bc.restarts
 		}
+		merged.restarts = append(merged.restarts, fr.restarts...)
 
 		for sig, sa := range fr.signatures {
 			msa := merged.signatures[sig]
@@ -383,6 +529,12 @@ func mergeResults(results []*fileResult) *fileResult {
 				msa.firstSeen = sa.firstSeen
 				msa.example = sa.example
 			}
+			for owner, n := range sa.owners {
+				if msa.owners == nil {
+					msa.owners = make(map[string]int64)
+				}
+				msa.owners[owner] += n
+			}
 		}
 
 		for key, vals := range fr.talkers {
@@ -454,11 +606,49 @@ func buildTriageTimeline(buckets map[int64]*bucketCount, _ time.Duration) []Tria
 		if bc := buckets[key]; bc != nil {
 			timeline[i].TotalLines = bc.total
 			timeline[i].ErrorLines = bc.errs
+			timeline[i].Restarts = bc.restarts
 		}
 	}
 	return timeline
 }
 
+// countRestartErrors counts the errors logged in each restarted pod within
+// window before and after the restart. Only files near a restart are read.
+func countRestartErrors(reader *Reader, restarts []Restart, window time.Duration) ([]TriageRestart, error) {
+	if len(restarts) == 0 {
+		return nil, nil
+	}
+	sort.Slice(restarts, func(i, j int) bool { return restarts[i].Time.Before(restarts[j].Time) })
+	out := make([]TriageRestart, len(restarts))
+	for i, rs := range restarts {
+		out[i] = TriageRestart{Time: rs.Time, Pod: rs.Pod, Container: rs.Container}
+	}
+
+	filter := &Filter{Restarts: &RestartFilter{Restarts: restarts, Window: window}}
+	_, err := reader.Scan(filter, func(e recv.LogEntry) bool {
+		if !IsError(e.Message) || logtypes.IsRestartMarker(e.Message) {
+			return true
+		}
+		for i, rs := range restarts {
+			if rs.Pod != "" && e.Labels["pod"] != rs.Pod {
+				continue
+			}
+			switch {
+			case e.Timestamp.Before(rs.Time.Add(-window)), e.Timestamp.After(rs.Time.Add(window)):
+			case e.Timestamp.Before(rs.Time):
+				out[i].ErrorsBefore++
+			default:
+				out[i].ErrorsAfter++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("count restart errors: %w", err)
+	}
+	return out, nil
+}
+
 func buildTopErrors(signatures map[string]*sigAccum, top int) []ErrorSignature {
 	if len(signatures) == 0 {
 		return nil
@@ -471,6 +661,7 @@ func buildTopErrors(signatures map[string]*sigAccum, top int) []ErrorSignature {
 			Count:     sa.count,
 			FirstSeen: sa.firstSeen,
 			Example:   sa.example,
+			Owner:     dominantOwner(sa.owners),
 		})
 	}
 
@@ -627,6 +818,10 @@ func (r *TriageResult) WriteSummary(w io.Writer) {
 	}
 
 	tw.printf("Lines:   %s (%s errors)\n", FormatCount(r.TotalLines), FormatCount(r.ErrorLines))
+	if r.Requests > 0 {
+		tw.printf("Requests: %s (%.2f errors per 1k requests, from %s)\n",
+			FormatCount(r.Requests), r.ErrorsPer1kRequests, r.requestSourceDesc())
+	}
 	tw.println()
 
 	// incident signal
@@ -643,6 +838,16 @@ func (r *TriageResult) WriteSummary(w io.Writer) {
 		tw.println()
 	}
 
+	// ranked anomaly windows
+	if len(r.Anomalies) > 0 {
+		tw.println("## Anomalies")
+		for i, a := range r.Anomalies {
+			tw.printf("  %d. %-7s %-6s %s — %s  score=%.1f  (%s)\n", i+1, a.Kind, a.Metric,
+				a.From.UTC().Format(time.RFC3339), a.To.UTC().Format(time.RFC3339), a.Score, a.Desc)
+		}
+		tw.println()
+	}
+
 	// top errors
 	if len(r.Errors) > 0 {
 		tw.printf("## Top Errors (of %s total)\n", FormatCount(r.ErrorLines))
@@ -651,7 +856,28 @@ func (r *TriageResult) WriteSummary(w io.Writer) {
 			if r.ErrorLines > 0 {
 				pct = float64(e.Count) / float64(r.ErrorLines) * 100
 			}
-			tw.printf("  %d. %-60s %s  (%.1f%%)\n", i+1, e.Signature, FormatCount(e.Count), pct)
+			tw.printf("  %d. %-60s %s  (%.1f%%)%s\n", i+1, e.Signature, FormatCount(e.Count), pct, r.ownerSuffix(e))
+		}
+		tw.println()
+	}
+
+	// latency percentiles
+	if r.Latency != nil {
+		tw.printf("## Latency (%s, by %s)\n", r.Latency.Source, r.Latency.GroupBy)
+		r.Latency.WriteText(w)
+		tw.println()
+	}
+
+	// per-owner rollups
+	if len(r.Owners) > 0 {
+		tw.println("## Errors by Owner")
+		for _, o := range r.Owners {
+			pct := float64(0)
+			if r.ErrorLines > 0 {
+				pct = float64(o.ErrorLines) / float64(r.ErrorLines) * 100
+			}
+			tw.printf("  %-20s %s errors  (%.1f%%)  %d signatures  top: %s\n",
+				o.Owner, FormatCount(o.ErrorLines), pct, o.Signatures, o.TopSignature)
 		}
 		tw.println()
 	}
@@ -684,6 +910,16 @@ func (r *TriageResult) WriteSummary(w io.Writer) {
 		tw.println()
 	}
 
+	// container restarts
+	if len(r.Restarts) > 0 {
+		tw.println("## Restarts")
+		for _, rs := range r.Restarts {
+			tw.printf("  %s  %-40s %s errors before, %s after\n",
+				rs.Time.UTC().Format("15:04:05"), rs.name(), FormatCount(rs.ErrorsBefore), FormatCount(rs.ErrorsAfter))
+		}
+		tw.println()
+	}
+
 	// cross-service correlations
 	if len(r.Correlations) > 0 {
 		tw.println("## Cross-Service Correlations")
@@ -695,8 +931,13 @@ func (r *TriageResult) WriteSummary(w io.Writer) {
 	}
 
 	// recommended slices
-	if r.Windows.PeakError != nil {
+	if r.Windows.PeakError != nil || len(r.Anomalies) > 0 {
 		tw.println("## Recommended Slices")
+	}
+	if len(r.Anomalies) > 0 {
+		tw.printf("  %s\n", r.anomalySliceCommand())
+	}
+	if r.Windows.PeakError != nil {
 		tw.printf("  logtap slice %s --from %s --to %s --out ./incident\n",
 			r.Dir, r.Windows.PeakError.From, r.Windows.PeakError.To)
 		if len(r.Errors) > 0 {
@@ -711,20 +952,73 @@ func (r *TriageResult) WriteSummary(w io.Writer) {
 	}
 }
 
-// WriteTimeline writes a CSV histogram: minute,total_lines,error_lines.
+// anomalySliceCommand suggests slicing the top-ranked anomaly window.
+func (r *TriageResult) anomalySliceCommand() string {
+	a := r.Anomalies[0]
+	return fmt.Sprintf("logtap slice %s --from %s --to %s --out ./anomaly",
+		r.Dir, a.From.UTC().Format(time.RFC3339), a.To.UTC().Format(time.RFC3339))
+}
+
+// WriteAnomalies writes the ranked anomaly windows as JSON.
+func (r *TriageResult) WriteAnomalies(w io.Writer) error {
+	anomalies := r.Anomalies
+	if anomalies == nil {
+		anomalies = []Anomaly{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(anomalies)
+}
+
+// requestSourceDesc says where the request counts came from.
+func (r *TriageResult) requestSourceDesc() string {
+	if r.RequestSource == RequestSourceHarness {
+		return "harness metrics"
+	}
+	return "request pattern"
+}
+
+// WriteTimeline writes a CSV histogram: minute,total_lines,error_lines, plus
+// a restarts column when the capture recorded container restarts and
+// requests,errors_per_1k_requests columns when it has a request rate.
 func (r *TriageResult) WriteTimeline(w io.Writer) {
 	cw := csv.NewWriter(w)
-	_ = cw.Write([]string{"minute", "total_lines", "error_lines"})
+	header := []string{"minute", "total_lines", "error_lines"}
+	if len(r.Restarts) > 0 {
+		header = append(header, "restarts")
+	}
+	if r.Requests > 0 {
+		header = append(header, "requests", "errors_per_1k_requests")
+	}
+	_ = cw.Write(header)
 	for _, b := range r.Timeline {
-		_ = cw.Write([]string{
+		row := []string{
 			b.Time.Format(time.RFC3339),
 			fmt.Sprintf("%d", b.TotalLines),
 			fmt.Sprintf("%d", b.ErrorLines),
-		})
+		}
+		if len(r.Restarts) > 0 {
+			row = append(row, fmt.Sprintf("%d", b.Restarts))
+		}
+		if r.Requests > 0 {
+			row = append(row, fmt.Sprintf("%d", b.Requests), fmt.Sprintf("%.2f", b.ErrorsPer1k))
+		}
+		_ = cw.Write(row)
 	}
 	cw.Flush()
 }
 
+// name returns pod/container, or whichever of the two is known.
+func (rs TriageRestart) name() string {
+	switch {
+	case rs.Pod == "":
+		return rs.Container
+	case rs.Container == "":
+		return rs.Pod
+	}
+	return rs.Pod + "/" + rs.Container
+}
+
 // WriteTopErrors writes ranked error signatures.
 func (r *TriageResult) WriteTopErrors(w io.Writer) {
 	tw := &textWriter{w: w}
@@ -733,9 +1027,29 @@ func (r *TriageResult) WriteTopErrors(w io.Writer) {
 		if r.ErrorLines > 0 {
 			pct = float64(e.Count) / float64(r.ErrorLines) * 100
 		}
-		tw.printf("%d. %s\t%d\t(%.1f%%)\tfirst: %s\n",
+		tw.printf("%d. %s\t%d\t(%.1f%%)\tfirst: %s",
 			i+1, e.Signature, e.Count, pct, e.FirstSeen.Format(time.RFC3339))
+		if r.Owners != nil {
+			tw.printf("\towner: %s", e.ownerName())
+		}
+		tw.println()
+	}
+}
+
+// ownerSuffix returns " [team]" for summary lines when ownership is mapped.
+func (r *TriageResult) ownerSuffix(e ErrorSignature) string {
+	if r.Owners == nil {
+		return ""
+	}
+	return "  [" + e.ownerName() + "]"
+}
+
+// ownerName returns the signature's owner, or UnownedOwner.
+func (e ErrorSignature) ownerName() string {
+	if e.Owner == "" {
+		return UnownedOwner
 	}
+	return e.Owner
 }
 
 // WriteTopTalkers writes volume per label value, sorted by line count.
@@ -0,0 +1,221 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// triageCacheVersion changes whenever the scan or the cached format does,
+// so results of an older logtap are not reused.
+const triageCacheVersion = 2
+
+// Bounds of the triage cache, applied when a triage run opens it: entries
+// unused for triageCacheMaxAge are removed, then the least recently used
+// until the rest fit in triageCacheMaxBytes.
+const (
+	triageCacheMaxAge   = 30 * 24 * time.Hour
+	triageCacheMaxBytes = 256 << 20
+)
+
+// DefaultTriageCacheDir returns where triage keeps per-file results
+// between runs.
+func DefaultTriageCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logtap", "triage"), nil
+}
+
+// triageCache stores the scan result of each data file keyed by the
+// file's digest in the index, so a re-run only scans files it has not seen.
+// Files without a digest (the active file, older captures) are always
+// scanned, and encrypted files are never cached, so their decrypted
+// examples are not left on disk in plain text. The cache is best effort:
+// unreadable entries are rescanned and failed writes are ignored.
+type triageCache struct {
+	dir    string
+	owners string // Owners.digest, "" without ownership rules
+}
+
+// newTriageCache returns nil, a disabled cache, when dir is empty. It
+// prunes dir to the cache bounds first.
+func newTriageCache(dir string, owners *Owners) *triageCache {
+	if dir == "" {
+		return nil
+	}
+	pruneTriageCache(dir, time.Now(), triageCacheMaxAge, triageCacheMaxBytes)
+	c := &triageCache{dir: dir}
+	if owners != nil {
+		c.owners = owners.digest
+	}
+	return c
+}
+
+// cachedFileResult is the on-disk form of a fileResult.
+type cachedFileResult struct {
+	TotalLines int64                          `json:"total_lines"`
+	ErrorLines int64                          `json:"error_lines"`
+	Buckets    map[int64][3]int64             `json:"buckets,omitempty"` // total, errors, restarts
+	Signatures map[string]cachedSignature     `json:"signatures,omitempty"`
+	Talkers    map[string]map[string][2]int64 `json:"talkers,omitempty"` // total, errors
+	Restarts   []Restart                      `json:"restarts,omitempty"`
+}
+
+type cachedSignature struct {
+	Count     int64            `json:"count"`
+	FirstSeen time.Time        `json:"first_seen"`
+	Example   string           `json:"example"`
+	Owners    map[string]int64 `json:"owners,omitempty"`
+}
+
+// scan returns the cached result of f or scans it and stores the result.
+func (c *triageCache) scan(f FileInfo, owners *Owners, profile *Profile) (*fileResult, error) {
+	path := c.path(f)
+	if path == "" {
+		return scanFileForTriage(f, owners, profile)
+	}
+	if fr := c.load(path); fr != nil {
+		return fr, nil
+	}
+	fr, err := scanFileForTriage(f, owners, profile)
+	if err == nil && !fr.skipped {
+		c.store(path, fr)
+	}
+	return fr, err
+}
+
+// path returns the cache file for f, or "" when f cannot be cached. The
+// key covers the language packs, which change what counts as an error and
+// how messages are normalized.
+func (c *triageCache) path(f FileInfo) string {
+	if c == nil || f.Index == nil || f.Index.SHA256 == "" || strings.HasSuffix(f.Name, EncryptedSuffix) {
+		return ""
+	}
+	key, _ := json.Marshal([]any{triageCacheVersion, f.Index.SHA256, c.owners, ActiveLanguagePacks()})
+	sum := sha256.Sum256(key)
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *triageCache) load(path string) *fileResult {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cr cachedFileResult
+	if err := json.Unmarshal(data, &cr); err != nil {
+		return nil
+	}
+	now := time.Now() // mark as used for pruning
+	_ = os.Chtimes(path, now, now)
+	fr := newFileResult()
+	fr.cached = true
+	fr.totalLines, fr.errorLines = cr.TotalLines, cr.ErrorLines
+	for k, b := range cr.Buckets {
+		fr.buckets[k] = &bucketCount{total: b[0], errs: b[1], restarts: b[2]}
+	}
+	for sig, s := range cr.Signatures {
+		fr.signatures[sig] = &sigAccum{count: s.Count, firstSeen: s.FirstSeen, example: s.Example, owners: s.Owners}
+	}
+	for key, vals := range cr.Talkers {
+		m := make(map[string]*talkerAccum, len(vals))
+		for v, t := range vals {
+			m[v] = &talkerAccum{total: t[0], errs: t[1]}
+		}
+		fr.talkers[key] = m
+	}
+	fr.restarts = cr.Restarts
+	return fr
+}
+
+func (c *triageCache) store(path string, fr *fileResult) {
+	cr := cachedFileResult{
+		TotalLines: fr.totalLines,
+		ErrorLines: fr.errorLines,
+		Buckets:    make(map[int64][3]int64, len(fr.buckets)),
+		Signatures: make(map[string]cachedSignature, len(fr.signatures)),
+		Talkers:    make(map[string]map[string][2]int64, len(fr.talkers)),
+		Restarts:   fr.restarts,
+	}
+	for k, b := range fr.buckets {
+		cr.Buckets[k] = [3]int64{b.total, b.errs, b.restarts}
+	}
+	for sig, s := range fr.signatures {
+		cr.Signatures[sig] = cachedSignature{Count: s.count, FirstSeen: s.firstSeen, Example: s.example, Owners: s.owners}
+	}
+	for key, vals := range fr.talkers {
+		m := make(map[string][2]int64, len(vals))
+		for v, t := range vals {
+			m[v] = [2]int64{t.total, t.errs}
+		}
+		cr.Talkers[key] = m
+	}
+	data, err := json.Marshal(cr)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, ".triage-*")
+	if err != nil {
+		return
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	_ = os.Rename(tmp.Name(), path)
+}
+
+// pruneTriageCache removes cache entries last used before now-maxAge, then
+// the least recently used until the rest total at most maxBytes.
+func pruneTriageCache(dir string, now time.Time, maxAge time.Duration, maxBytes int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		path string
+		used time.Time
+		size int64
+	}
+	var kept []cached
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if now.Sub(info.ModTime()) > maxAge {
+			_ = os.Remove(path)
+			continue
+		}
+		kept = append(kept, cached{path: path, used: info.ModTime(), size: info.Size()})
+		total += info.Size()
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].used.Before(kept[j].used) })
+	for _, c := range kept {
+		if total <= maxBytes {
+			break
+		}
+		if os.Remove(c.path) == nil {
+			total -= c.size
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 )
 
 // htmlError holds pre-formatted error data for the HTML template.
@@ -16,6 +17,16 @@ type htmlError struct {
 	Pct       string
 	FirstSeen string
 	Example   string
+	Owner     string
+}
+
+// htmlOwner holds one per-owner rollup row for the HTML template.
+type htmlOwner struct {
+	Owner        string
+	ErrorLines   string
+	Pct          string
+	Signatures   int
+	TopSignature string
 }
 
 // htmlTalkerEntry holds a single talker bar for the HTML template.
@@ -34,6 +45,35 @@ type htmlTalkerGroup struct {
 	Entries []htmlTalkerEntry
 }
 
+// htmlRestart holds one container restart row for the HTML template.
+type htmlRestart struct {
+	Time         string
+	Pod          string
+	Container    string
+	ErrorsBefore string
+	ErrorsAfter  string
+}
+
+// htmlAnomaly holds one anomaly window row for the HTML template.
+type htmlAnomaly struct {
+	Kind   string
+	Metric string
+	From   string
+	To     string
+	Score  string
+	Desc   string
+}
+
+// htmlLatency holds one latency group row for the HTML template.
+type htmlLatency struct {
+	Value   string
+	Samples string
+	P50     string
+	P95     string
+	P99     string
+	Max     string
+}
+
 // htmlSlice holds a recommended slice command for the HTML template.
 type htmlSlice struct {
 	Desc    string
@@ -47,11 +87,19 @@ type htmlData struct {
 	TotalLines string
 	ErrorLines string
 	ErrorPct   string
+	Requests   string // "" without a request rate
+	Per1k      string
 	HasSignal  bool
 	Signal     *TriageWindows
 	HasChart   bool
 	Timeline   template.HTML
+	Restarts   []htmlRestart
+	Anomalies  []htmlAnomaly
+	Latency    *LatencyReport
+	Latencies  []htmlLatency
 	Errors     []htmlError
+	ShowOwners bool
+	Owners     []htmlOwner
 	Talkers    []htmlTalkerGroup
 	Slices     []htmlSlice
 }
@@ -75,6 +123,11 @@ func (r *TriageResult) buildHTMLData() htmlData {
 		d.ErrorPct = "0.0%"
 	}
 
+	if r.Requests > 0 {
+		d.Requests = FormatCount(r.Requests)
+		d.Per1k = fmt.Sprintf("%.2f", r.ErrorsPer1kRequests)
+	}
+
 	// period
 	if r.Meta != nil && !r.Meta.Started.IsZero() {
 		start := r.Meta.Started.Format("2006-01-02 15:04")
@@ -106,6 +159,17 @@ func (r *TriageResult) buildHTMLData() htmlData {
 		d.Timeline = template.HTML(r.buildTimelineSVG())
 	}
 
+	// restarts
+	for _, rs := range r.Restarts {
+		d.Restarts = append(d.Restarts, htmlRestart{
+			Time:         rs.Time.UTC().Format("15:04:05"),
+			Pod:          rs.Pod,
+			Container:    rs.Container,
+			ErrorsBefore: FormatCount(rs.ErrorsBefore),
+			ErrorsAfter:  FormatCount(rs.ErrorsAfter),
+		})
+	}
+
 	// errors
 	for i, e := range r.Errors {
 		pct := float64(0)
@@ -119,6 +183,23 @@ func (r *TriageResult) buildHTMLData() htmlData {
 			Pct:       fmt.Sprintf("%.1f%%", pct),
 			FirstSeen: e.FirstSeen.Format("15:04:05"),
 			Example:   e.Example,
+			Owner:     e.ownerName(),
+		})
+	}
+
+	// owners
+	d.ShowOwners = r.Owners != nil
+	for _, o := range r.Owners {
+		pct := float64(0)
+		if r.ErrorLines > 0 {
+			pct = float64(o.ErrorLines) / float64(r.ErrorLines) * 100
+		}
+		d.Owners = append(d.Owners, htmlOwner{
+			Owner:        o.Owner,
+			ErrorLines:   FormatCount(o.ErrorLines),
+			Pct:          fmt.Sprintf("%.1f%%", pct),
+			Signatures:   o.Signatures,
+			TopSignature: o.TopSignature,
 		})
 	}
 
@@ -164,7 +245,31 @@ func (r *TriageResult) buildHTMLData() htmlData {
 		d.Talkers = append(d.Talkers, group)
 	}
 
+	// anomaly windows
+	for _, a := range r.Anomalies {
+		d.Anomalies = append(d.Anomalies, htmlAnomaly{
+			Kind:   a.Kind,
+			Metric: a.Metric,
+			From:   a.From.UTC().Format(time.RFC3339),
+			To:     a.To.UTC().Format(time.RFC3339),
+			Score:  fmt.Sprintf("%.1f", a.Score),
+			Desc:   a.Desc,
+		})
+	}
+
+	// latency percentiles
+	if r.Latency != nil {
+		d.Latency = r.Latency
+		d.Latencies = buildHTMLLatency(r.Latency)
+	}
+
 	// recommended slices
+	if len(r.Anomalies) > 0 {
+		d.Slices = append(d.Slices, htmlSlice{
+			Desc:    "Top anomaly: " + r.Anomalies[0].Kind + " in " + r.Anomalies[0].Metric + ", " + r.Anomalies[0].Desc,
+			Command: r.anomalySliceCommand(),
+		})
+	}
 	if r.Windows.PeakError != nil {
 		d.Slices = append(d.Slices, htmlSlice{
 			Desc:    "Peak error window: " + r.Windows.PeakError.Desc,
@@ -266,11 +371,25 @@ func (r *TriageResult) buildTimelineSVG() string {
 	// error lines polyline (red)
 	sb.WriteString(fmt.Sprintf(`<polyline points="%s" fill="none" stroke="#ef4444" stroke-width="2"/>`, strings.Join(errorPoints, " ")))
 
+	// container restarts (dashed purple verticals)
+	start := r.Timeline[0].Time
+	for _, rs := range r.Restarts {
+		x := float64(padL) + rs.Time.Sub(start).Minutes()*xStep
+		if x < padL || x > width-padR {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#8b5cf6" stroke-width="1.5" stroke-dasharray="4,3"/>`, x, padT, x, padT+chartH))
+	}
+
 	// legend
 	sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="12" height="3" fill="#3b82f6"/>`, width-padR-120, padT))
 	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="11" fill="#666">Total lines</text>`, width-padR-104, padT+4))
 	sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="12" height="3" fill="#ef4444"/>`, width-padR-120, padT+14))
 	sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="11" fill="#666">Errors</text>`, width-padR-104, padT+18))
+	if len(r.Restarts) > 0 {
+		sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="12" height="3" fill="#8b5cf6"/>`, width-padR-120, padT+28))
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="11" fill="#666">Restarts</text>`, width-padR-104, padT+32))
+	}
 
 	sb.WriteString(`</svg>`)
 	return sb.String()
@@ -323,7 +442,8 @@ var htmlTmpl = template.Must(template.New("triage").Parse(`<!DOCTYPE html>
 <div>
   <span class="stat">{{.TotalLines}} lines</span>
   <span class="stat stat-error">{{.ErrorLines}} errors ({{.ErrorPct}})</span>
-</div>
+{{if .Requests}}  <span class="stat">{{.Requests}} requests ({{.Per1k}} errors per 1k)</span>
+{{end}}</div>
 
 {{if .HasSignal}}
 <h2>Incident Signal</h2>
@@ -341,18 +461,58 @@ var htmlTmpl = template.Must(template.New("triage").Parse(`<!DOCTYPE html>
 <div class="empty">Not enough data for timeline chart.</div>
 {{end}}
 
+{{if .Anomalies}}
+<h2>Anomalies</h2>
+<table>
+<thead><tr><th>Kind</th><th>Metric</th><th>From</th><th>To</th><th class="num">Score</th><th>Detail</th></tr></thead>
+<tbody>
+{{range .Anomalies}}<tr><td>{{.Kind}}</td><td>{{.Metric}}</td><td>{{.From}}</td><td>{{.To}}</td><td class="num">{{.Score}}</td><td>{{.Desc}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
+{{if .Latency}}
+<h2>Latency ({{.Latency.Source}}, by {{.Latency.GroupBy}})</h2>
+<table>
+<thead><tr><th>{{.Latency.GroupBy}}</th><th class="num">Samples</th><th class="num">p50 ms</th><th class="num">p95 ms</th><th class="num">p99 ms</th><th class="num">max ms</th></tr></thead>
+<tbody>
+{{range .Latencies}}<tr><td>{{.Value}}</td><td class="num">{{.Samples}}</td><td class="num">{{.P50}}</td><td class="num">{{.P95}}</td><td class="num">{{.P99}}</td><td class="num">{{.Max}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
+{{if .Restarts}}
+<h2>Restarts</h2>
+<table>
+<thead><tr><th>Time</th><th>Pod</th><th>Container</th><th class="num">Errors before</th><th class="num">Errors after</th></tr></thead>
+<tbody>
+{{range .Restarts}}<tr><td>{{.Time}}</td><td>{{.Pod}}</td><td>{{.Container}}</td><td class="num">{{.ErrorsBefore}}</td><td class="num">{{.ErrorsAfter}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
 <h2>Top Errors</h2>
 {{if .Errors}}
 <table>
-<thead><tr><th>#</th><th>Signature</th><th class="num">Count</th><th class="num">%</th><th class="num">First Seen</th></tr></thead>
+<thead><tr><th>#</th><th>Signature</th>{{if .ShowOwners}}<th>Owner</th>{{end}}<th class="num">Count</th><th class="num">%</th><th class="num">First Seen</th></tr></thead>
 <tbody>
-{{range .Errors}}<tr><td>{{.Rank}}</td><td class="sig" title="{{.Example}}">{{.Signature}}</td><td class="num">{{.Count}}</td><td class="num">{{.Pct}}</td><td class="num">{{.FirstSeen}}</td></tr>
+{{range .Errors}}<tr><td>{{.Rank}}</td><td class="sig" title="{{.Example}}">{{.Signature}}</td>{{if $.ShowOwners}}<td>{{.Owner}}</td>{{end}}<td class="num">{{.Count}}</td><td class="num">{{.Pct}}</td><td class="num">{{.FirstSeen}}</td></tr>
 {{end}}</tbody>
 </table>
 {{else}}
 <div class="empty">No errors found.</div>
 {{end}}
 
+{{if .Owners}}
+<h2>Errors by Owner</h2>
+<table>
+<thead><tr><th>Owner</th><th class="num">Errors</th><th class="num">%</th><th class="num">Signatures</th><th>Top Signature</th></tr></thead>
+<tbody>
+{{range .Owners}}<tr><td>{{.Owner}}</td><td class="num">{{.ErrorLines}}</td><td class="num">{{.Pct}}</td><td class="num">{{.Signatures}}</td><td class="sig">{{.TopSignature}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
 {{if .Talkers}}
 <h2>Top Talkers</h2>
 {{range .Talkers}}
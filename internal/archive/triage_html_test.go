@@ -164,3 +164,31 @@ func TestWriteHTML_LargeTimeline(t *testing.T) {
 		t.Error("should contain polyline elements")
 	}
 }
+
+func TestWriteHTML_Restarts(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	result := &TriageResult{
+		Dir: "/tmp/capture-001",
+		Timeline: []TriageBucket{
+			{Time: base, TotalLines: 100, ErrorLines: 5},
+			{Time: base.Add(time.Minute), TotalLines: 100, ErrorLines: 40, Restarts: 1},
+			{Time: base.Add(2 * time.Minute), TotalLines: 100, ErrorLines: 2},
+		},
+		Restarts: []TriageRestart{
+			{Time: base.Add(90 * time.Second), Pod: "<script>api-1", Container: "api", ErrorsBefore: 38, ErrorsAfter: 3},
+		},
+	}
+	var buf bytes.Buffer
+	if err := result.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{"<h2>Restarts</h2>", "stroke-dasharray", ">Restarts</text>", "&lt;script&gt;api-1", ">38<"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML missing %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>api-1") {
+		t.Error("pod label not escaped")
+	}
+}
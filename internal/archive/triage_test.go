@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ppiankov/logtap/internal/logtypes"
 	"github.com/ppiankov/logtap/internal/recv"
 	"github.com/ppiankov/logtap/internal/rotate"
 )
@@ -88,6 +89,29 @@ func TestTriageErrorDetection(t *testing.T) {
 	}
 }
 
+func TestTriageRepeatCount(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	entries := []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "connection refused", RepeatCount: 40},
+		{Timestamp: base, Labels: map[string]string{"app": "web"}, Message: "request ok"},
+	}
+	writeMetadata(t, dir, base, base, 2)
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", entries)
+
+	result, err := Triage(dir, TriageConfig{Jobs: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a collapsed entry counts as the lines it stands for
+	if result.TotalLines != 41 || result.ErrorLines != 40 {
+		t.Errorf("TotalLines = %d, ErrorLines = %d; want 41 and 40", result.TotalLines, result.ErrorLines)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Count != 40 {
+		t.Errorf("errors = %+v, want one signature seen 40 times", result.Errors)
+	}
+}
+
 func TestTriageSignatureNormalization(t *testing.T) {
 	src, _ := setupTriageSource(t)
 
@@ -398,3 +422,179 @@ func TestTriageSummaryContainsKey(t *testing.T) {
 		}
 	}
 }
+
+func TestTriageRestarts(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	api := map[string]string{"app": "api", "pod": "api-1", "container": "api"}
+	other := map[string]string{"app": "worker", "pod": "worker-1"}
+	entries := []recv.LogEntry{
+		{Timestamp: base, Labels: api, Message: "request started"},
+		{Timestamp: base.Add(90 * time.Second), Labels: api, Message: "panic: nil pointer dereference"},
+		{Timestamp: base.Add(100 * time.Second), Labels: api, Message: "connection refused to db:5432"},
+		{Timestamp: base.Add(100 * time.Second), Labels: other, Message: "connection refused to db:5432"},
+		{Timestamp: base.Add(2 * time.Minute), Labels: api, Message: logtypes.RestartMarker("api", 1)},
+		{Timestamp: base.Add(150 * time.Second), Labels: api, Message: "error: cache cold"},
+		{Timestamp: base.Add(5 * time.Minute), Labels: api, Message: "error: late failure"},
+	}
+	writeMetadata(t, dir, base, base.Add(5*time.Minute), int64(len(entries)))
+	writeDataFile(t, dir, "2024-01-15T100000-000.jsonl", entries)
+	writeIndex(t, dir, []rotate.IndexEntry{{
+		File:  "2024-01-15T100000-000.jsonl",
+		From:  base,
+		To:    base.Add(5 * time.Minute),
+		Lines: int64(len(entries)),
+	}})
+
+	result, err := Triage(dir, TriageConfig{Jobs: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Restarts) != 1 {
+		t.Fatalf("restarts = %+v, want 1", result.Restarts)
+	}
+	rs := result.Restarts[0]
+	if rs.Pod != "api-1" || rs.Container != "api" || !rs.Time.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("restart = %+v", rs)
+	}
+	if rs.ErrorsBefore != 2 || rs.ErrorsAfter != 1 {
+		t.Errorf("errors before/after = %d/%d, want 2/1", rs.ErrorsBefore, rs.ErrorsAfter)
+	}
+	if result.Timeline[2].Restarts != 1 {
+		t.Errorf("timeline[2].Restarts = %d, want 1", result.Timeline[2].Restarts)
+	}
+
+	var buf bytes.Buffer
+	result.WriteTimeline(&buf)
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(records[0], ","); got != "minute,total_lines,error_lines,restarts" {
+		t.Errorf("header = %s", got)
+	}
+	if records[3][3] != "1" || records[1][3] != "0" {
+		t.Errorf("restart column = %v", records)
+	}
+
+	buf.Reset()
+	result.WriteSummary(&buf)
+	if !strings.Contains(buf.String(), "## Restarts") || !strings.Contains(buf.String(), "api-1/api") {
+		t.Errorf("summary missing restarts:\n%s", buf.String())
+	}
+}
+
+func TestTriageTimelineNoRestartColumn(t *testing.T) {
+	src, _ := setupTriageSource(t)
+	result, err := Triage(src, TriageConfig{Jobs: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	result.WriteTimeline(&buf)
+	if header, _, _ := strings.Cut(buf.String(), "\n"); header != "minute,total_lines,error_lines" {
+		t.Errorf("header = %q", header)
+	}
+}
+
+func TestTriageCache(t *testing.T) {
+	src, base := setupTriageSource(t)
+	idx, err := readIndex(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx[0].SHA256 = "abc123"
+	writeIndex(t, src, idx)
+	cacheDir := t.TempDir()
+
+	first, err := Triage(src, TriageConfig{Jobs: 1, CacheDir: cacheDir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.CachedFiles != 0 {
+		t.Errorf("first run CachedFiles = %d, want 0", first.CachedFiles)
+	}
+
+	// the digest is unchanged, so the second run must not read the file
+	writeDataFile(t, src, "2024-01-15T100000-000.jsonl", []recv.LogEntry{
+		{Timestamp: base, Labels: map[string]string{"app": "api"}, Message: "rewritten"},
+	})
+	second, err := Triage(src, TriageConfig{Jobs: 1, CacheDir: cacheDir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.CachedFiles != 1 {
+		t.Errorf("second run CachedFiles = %d, want 1", second.CachedFiles)
+	}
+	if second.TotalLines != first.TotalLines || second.ErrorLines != first.ErrorLines ||
+		len(second.Errors) != len(first.Errors) || len(second.Timeline) != len(first.Timeline) ||
+		len(second.Talkers["app"]) != len(first.Talkers["app"]) {
+		t.Errorf("cached result differs: %+v vs %+v", second, first)
+	}
+
+	// ownership rules are part of the key
+	owners, err := NewOwners([]OwnerRule{{Team: "payments", Labels: map[string][]string{"app": {"api"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := Triage(src, TriageConfig{Jobs: 1, CacheDir: cacheDir, Owners: owners}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.CachedFiles != 0 || third.TotalLines != 1 {
+		t.Errorf("with owners: CachedFiles = %d, TotalLines = %d; want a fresh scan", third.CachedFiles, third.TotalLines)
+	}
+
+	// so are the language packs
+	withLanguagePacks(t, "go")
+	fourth, err := Triage(src, TriageConfig{Jobs: 1, CacheDir: cacheDir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fourth.CachedFiles != 0 || fourth.TotalLines != 1 {
+		t.Errorf("with language packs: CachedFiles = %d, TotalLines = %d; want a fresh scan", fourth.CachedFiles, fourth.TotalLines)
+	}
+}
+
+func TestTriageCacheSkipsEncrypted(t *testing.T) {
+	c := newTriageCache(t.TempDir(), nil)
+	idx := &rotate.IndexEntry{SHA256: "abc123"}
+	if c.path(FileInfo{Name: "2024-01-15T100000-000.jsonl", Index: idx}) == "" {
+		t.Error("plain file with a digest is not cached")
+	}
+	if p := c.path(FileInfo{Name: "2024-01-15T100000-000.jsonl" + EncryptedSuffix, Index: idx}); p != "" {
+		t.Errorf("encrypted file cached at %s", p)
+	}
+}
+
+func TestPruneTriageCache(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	write := func(name string, size int, used time.Time) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("stale.json", 10, now.Add(-48*time.Hour))
+	write("oldest.json", 100, now.Add(-3*time.Hour))
+	write("older.json", 100, now.Add(-2*time.Hour))
+	write("newest.json", 100, now.Add(-time.Hour))
+
+	pruneTriageCache(dir, now, 24*time.Hour, 250)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if strings.Join(left, ",") != "newest.json,older.json" {
+		t.Errorf("left = %v, want newest.json and older.json", left)
+	}
+}
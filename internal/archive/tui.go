@@ -64,6 +64,13 @@ type ReplayModel struct {
 	markJumping bool
 	markMsg     string
 
+	// annotations: bookmarks with notes, saved to the capture
+	annotations   []Annotation
+	annotating    bool
+	annotateInput string
+	annotateEntry recv.LogEntry
+	annotateMsg   string
+
 	// export
 	exporting   bool
 	exportInput string
@@ -97,19 +104,21 @@ func NewReplayModel(feeder *Feeder, ring *recv.LogRing, meta *recv.Metadata, dir
 	for i := range sel {
 		sel[i] = true // default: all selected
 	}
+	annotations, _ := ReadAnnotations(dir) // unreadable annotations are not worth refusing to open
 
 	return ReplayModel{
-		feeder:     feeder,
-		ring:       ring,
-		meta:       meta,
-		dir:        dir,
-		totalLines: totalLines,
-		picker:     showPicker,
-		services:   services,
-		pickerSel:  sel,
-		follow:     true,
-		width:      80,
-		height:     24,
+		feeder:      feeder,
+		ring:        ring,
+		meta:        meta,
+		dir:         dir,
+		totalLines:  totalLines,
+		picker:      showPicker,
+		services:    services,
+		pickerSel:   sel,
+		annotations: annotations,
+		follow:      true,
+		width:       80,
+		height:      24,
 	}
 }
 
@@ -161,6 +170,9 @@ func (m ReplayModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.markJumping {
 			return m.updateMarkJump(msg)
 		}
+		if m.annotating {
+			return m.updateAnnotate(msg)
+		}
 		if m.exporting {
 			return m.updateExport(msg)
 		}
@@ -268,6 +280,17 @@ func (m ReplayModel) updateNormal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.markJumping = true
 		m.markMsg = ""
 
+	case "b":
+		if idx, ok := m.currentIndex(); ok {
+			m.annotating = true
+			m.annotateInput = ""
+			m.annotateEntry = m.lines[idx]
+			m.annotateMsg = ""
+		}
+
+	case "B":
+		m.nextAnnotation()
+
 	case "w":
 		m.exporting = true
 		m.exportInput = fmt.Sprintf("./filtered-%s", time.Now().Format("20060102-150405"))
@@ -403,6 +426,73 @@ func (m ReplayModel) updateMarkJump(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// currentIndex returns the entry bookmarks apply to: the current search
+// match while one is highlighted, else the top line on screen.
+func (m ReplayModel) currentIndex() (int, bool) {
+	if len(m.lines) == 0 {
+		return 0, false
+	}
+	if m.highlight != nil && len(m.matches) > 0 {
+		return m.matches[m.searchIdx], true
+	}
+	return clamp(m.scrollOff, 0, len(m.lines)-1), true
+}
+
+func (m ReplayModel) updateAnnotate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.annotating = false
+		a := NewAnnotation(m.annotateEntry, strings.TrimSpace(m.annotateInput))
+		if err := AddAnnotation(m.dir, a); err != nil {
+			m.annotateMsg = fmt.Sprintf("Bookmark error: %s", err)
+		} else {
+			m.annotations = append(m.annotations, a)
+			m.annotateMsg = fmt.Sprintf("Bookmarked %s", a.Timestamp.Format("15:04:05"))
+		}
+
+	case "esc":
+		m.annotating = false
+		m.annotateInput = ""
+
+	case "backspace":
+		if len(m.annotateInput) > 0 {
+			m.annotateInput = m.annotateInput[:len(m.annotateInput)-1]
+		}
+
+	default:
+		if len(msg.String()) == 1 {
+			m.annotateInput += msg.String()
+		}
+	}
+
+	return m, nil
+}
+
+// annotated reports whether e is bookmarked.
+func (m ReplayModel) annotated(e recv.LogEntry) bool {
+	for _, a := range m.annotations {
+		if a.Matches(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextAnnotation scrolls to the next bookmarked line below the top line,
+// wrapping around.
+func (m *ReplayModel) nextAnnotation() {
+	n := len(m.lines)
+	for i := 1; i <= n; i++ {
+		idx := (m.scrollOff + i) % n
+		if m.annotated(m.lines[idx]) {
+			m.follow = false
+			m.scrollOff = clamp(idx, 0, m.maxScroll())
+			return
+		}
+	}
+	m.annotateMsg = "no bookmarks in view"
+}
+
 func (m ReplayModel) updateExport(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
@@ -623,6 +713,8 @@ func (m ReplayModel) renderHelp() []string {
 		h.Render("  Bookmarks"),
 		d.Render("    m + a-z    ") + "set bookmark at current position",
 		d.Render("    ' + a-z    ") + "jump to bookmark",
+		d.Render("    b          ") + "bookmark entry with a note (annotations.json)",
+		d.Render("    B          ") + "jump to next annotated entry",
 		"",
 		h.Render("  Export"),
 		d.Render("    w          ") + "export filtered view to capture dir",
@@ -856,6 +948,8 @@ func (m ReplayModel) View() string {
 
 			if matchSet[i] {
 				b.WriteString(rMatchStyle.Render(line))
+			} else if m.annotated(entry) {
+				b.WriteString(rAnnotatedStyle.Render(line))
 			} else {
 				b.WriteString(rLogLineStyle.Render(line))
 			}
@@ -877,6 +971,11 @@ func (m ReplayModel) View() string {
 	} else if m.markMsg != "" {
 		status.WriteString(rFilterBadge.Render(m.markMsg))
 	}
+	if m.annotating {
+		status.WriteString(rSearchBadge.Render(fmt.Sprintf("note:%s", m.annotateInput)))
+	} else if m.annotateMsg != "" {
+		status.WriteString(rExportBadge.Render(m.annotateMsg))
+	}
 	if m.exporting {
 		status.WriteString(rSearchBadge.Render(fmt.Sprintf("w:%s", m.exportInput)))
 	} else if m.exportMsg != "" {
@@ -962,6 +1061,7 @@ var (
 	rSepStyle          = lipgloss.NewStyle().Faint(true)
 	rLogLineStyle      = lipgloss.NewStyle()
 	rMatchStyle        = lipgloss.NewStyle().Background(lipgloss.Color("226")).Foreground(lipgloss.Color("0"))
+	rAnnotatedStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("75"))
 	rSearchBadge       = lipgloss.NewStyle().Background(lipgloss.Color("226")).Foreground(lipgloss.Color("0")).Padding(0, 1)
 	rFollowBadge       = lipgloss.NewStyle().Background(lipgloss.Color("34")).Foreground(lipgloss.Color("15")).Padding(0, 1)
 	rFilterBadge       = lipgloss.NewStyle().Background(lipgloss.Color("63")).Foreground(lipgloss.Color("15")).Padding(0, 1)
@@ -631,3 +631,56 @@ func TestReplayExportWrite(t *testing.T) {
 		t.Errorf("exported lines: got %d, want 3", meta.TotalLines)
 	}
 }
+
+func TestReplayAnnotate(t *testing.T) {
+	m := newTestReplayModel()
+	m.dir = t.TempDir()
+	feedReplayLines(&m, 50)
+	m.follow = false
+	m.scrollOff = 7
+
+	m = sendReplayKey(m, "b")
+	if !m.annotating {
+		t.Fatal("expected note input after 'b'")
+	}
+	for _, r := range "slow start" {
+		m = sendReplayKey(m, string(r))
+	}
+	m = sendReplaySpecialKey(m, tea.KeyEnter)
+	if m.annotating {
+		t.Error("note input still open after enter")
+	}
+
+	anns, err := ReadAnnotations(m.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anns) != 1 || anns[0].Note != "slow start" || !anns[0].Matches(m.lines[7]) {
+		t.Fatalf("annotations = %+v", anns)
+	}
+	if !strings.Contains(m.View(), "Bookmarked") {
+		t.Error("status should confirm the bookmark")
+	}
+
+	m.scrollOff = 0
+	m = sendReplayKey(m, "B")
+	if m.scrollOff != 7 {
+		t.Errorf("scrollOff = %d after B, want 7", m.scrollOff)
+	}
+}
+
+func TestReplayAnnotateCancel(t *testing.T) {
+	m := newTestReplayModel()
+	m.dir = t.TempDir()
+	feedReplayLines(&m, 5)
+
+	m = sendReplayKey(m, "b")
+	m = sendReplayKey(m, "x")
+	m = sendReplaySpecialKey(m, tea.KeyEsc)
+	if m.annotating {
+		t.Error("esc should cancel the note")
+	}
+	if anns, _ := ReadAnnotations(m.dir); len(anns) != 0 {
+		t.Errorf("cancelled note saved: %+v", anns)
+	}
+}
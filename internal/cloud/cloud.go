@@ -10,7 +10,8 @@ import (
 
 // Backend abstracts cloud object storage operations.
 type Backend interface {
-	// Upload writes the content from r to the given key.
+	// Upload writes the content from r to the given key. size is the content
+	// length, or -1 when unknown, in which case r is streamed.
 	Upload(ctx context.Context, key string, r io.Reader, size int64) error
 
 	// Download reads the object at key and writes it to w.
@@ -68,6 +69,11 @@ func ParseURL(raw string) (scheme, bucket, prefix string, err error) {
 	return scheme, bucket, prefix, nil
 }
 
+// IsURL reports whether s looks like a cloud URL accepted by ParseURL.
+func IsURL(s string) bool {
+	return strings.HasPrefix(s, "s3://") || strings.HasPrefix(s, "gs://")
+}
+
 // NewBackend creates a Backend for the given scheme and bucket.
 func NewBackend(ctx context.Context, scheme, bucket string) (Backend, error) {
 	switch scheme {
@@ -1,6 +1,7 @@
 package cloud
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -9,14 +10,24 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // s3API abstracts the S3 client methods used by s3Backend.
 type s3API interface {
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
 }
 
+// s3PartSize is the multipart chunk size for uploads of unknown length. With
+// S3's 10,000 part limit it caps streamed objects at ~156 GiB; only one part
+// is held in memory at a time.
+const s3PartSize = 16 << 20
+
 // s3Paginator abstracts the S3 list paginator.
 type s3Paginator interface {
 	HasMorePages() bool
@@ -35,7 +46,11 @@ func newS3Backend(ctx context.Context, bucket string) (*s3Backend, error) {
 	if err != nil {
 		return nil, fmt.Errorf("load AWS config: %w", err)
 	}
-	client := s3.NewFromConfig(cfg)
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// S3-compatible stores behind AWS_ENDPOINT_URL, e.g. MinIO, are
+		// usually addressed by path rather than bucket subdomain
+		o.UsePathStyle = cfg.BaseEndpoint != nil
+	})
 	presigner := s3.NewPresignClient(client)
 	return &s3Backend{
 		client: client,
@@ -60,6 +75,9 @@ func newS3Backend(ctx context.Context, bucket string) (*s3Backend, error) {
 }
 
 func (b *s3Backend) Upload(ctx context.Context, key string, r io.Reader, size int64) error {
+	if size < 0 {
+		return b.uploadStream(ctx, key, r)
+	}
 	_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:        &b.bucket,
 		Key:           &key,
@@ -72,6 +90,68 @@ func (b *s3Backend) Upload(ctx context.Context, key string, r io.Reader, size in
 	return nil
 }
 
+// uploadStream uploads r in s3PartSize parts. The multipart upload is aborted
+// on error so no orphaned parts are billed.
+func (b *s3Backend) uploadStream(ctx context.Context, key string, r io.Reader) error {
+	created, err := b.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: &b.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return fmt.Errorf("s3 upload %s: %w", key, err)
+	}
+	uploadID := created.UploadId
+
+	abort := func(err error) error {
+		_, _ = b.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   &b.bucket,
+			Key:      &key,
+			UploadId: uploadID,
+		})
+		return fmt.Errorf("s3 upload %s: %w", key, err)
+	}
+
+	var parts []s3types.CompletedPart
+	buf := make([]byte, s3PartSize)
+	for partNum := int32(1); ; partNum++ {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return abort(readErr)
+		}
+		// S3 needs at least one part, even if empty
+		if n > 0 || len(parts) == 0 {
+			size := int64(n)
+			num := partNum
+			out, err := b.client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:        &b.bucket,
+				Key:           &key,
+				UploadId:      uploadID,
+				PartNumber:    &num,
+				Body:          bytes.NewReader(buf[:n]),
+				ContentLength: &size,
+			})
+			if err != nil {
+				return abort(err)
+			}
+			parts = append(parts, s3types.CompletedPart{ETag: out.ETag, PartNumber: &num})
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	_, err = b.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          &b.bucket,
+		Key:             &key,
+		UploadId:        uploadID,
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return abort(err)
+	}
+	return nil
+}
+
 func (b *s3Backend) Download(ctx context.Context, key string, w io.Writer) error {
 	resp, err := b.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: &b.bucket,
@@ -18,6 +18,42 @@ type mockS3Client struct {
 	putErr  error
 	getBody string
 	getErr  error
+
+	partErr   error
+	parts     [][]byte
+	completed []s3types.CompletedPart
+	aborted   bool
+}
+
+func (m *mockS3Client) CreateMultipartUpload(_ context.Context, _ *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	id := "upload-1"
+	return &s3.CreateMultipartUploadOutput{UploadId: &id}, nil
+}
+
+func (m *mockS3Client) UploadPart(_ context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if m.partErr != nil {
+		return nil, m.partErr
+	}
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != *in.ContentLength {
+		return nil, errors.New("content length mismatch")
+	}
+	m.parts = append(m.parts, data)
+	etag := "etag"
+	return &s3.UploadPartOutput{ETag: &etag}, nil
+}
+
+func (m *mockS3Client) CompleteMultipartUpload(_ context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	m.completed = in.MultipartUpload.Parts
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (m *mockS3Client) AbortMultipartUpload(_ context.Context, _ *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	m.aborted = true
+	return &s3.AbortMultipartUploadOutput{}, nil
 }
 
 func (m *mockS3Client) PutObject(_ context.Context, _ *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
@@ -85,6 +121,60 @@ func TestS3Upload_Error(t *testing.T) {
 	}
 }
 
+func TestS3Upload_Stream(t *testing.T) {
+	client := &mockS3Client{}
+	b := newTestS3Backend(client, nil)
+	data := bytes.Repeat([]byte("x"), s3PartSize+100)
+
+	if err := b.Upload(context.Background(), "run1.tar.zst", bytes.NewReader(data), -1); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if len(client.parts) != 2 || len(client.parts[0]) != s3PartSize || len(client.parts[1]) != 100 {
+		t.Fatalf("parts = %d, want 2 (full + 100 bytes)", len(client.parts))
+	}
+	if len(client.completed) != 2 || *client.completed[1].PartNumber != 2 {
+		t.Errorf("completed parts = %+v", client.completed)
+	}
+	if client.aborted {
+		t.Error("upload should not be aborted")
+	}
+}
+
+func TestS3Upload_StreamEmpty(t *testing.T) {
+	client := &mockS3Client{}
+	b := newTestS3Backend(client, nil)
+	if err := b.Upload(context.Background(), "empty", strings.NewReader(""), -1); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if len(client.parts) != 1 || len(client.parts[0]) != 0 {
+		t.Errorf("parts = %d, want one empty part", len(client.parts))
+	}
+}
+
+func TestS3Upload_StreamAbort(t *testing.T) {
+	t.Run("read error", func(t *testing.T) {
+		client := &mockS3Client{}
+		b := newTestS3Backend(client, nil)
+		err := b.Upload(context.Background(), "k", &failReader{}, -1)
+		if err == nil || !strings.Contains(err.Error(), "read failure") {
+			t.Fatalf("err = %v, want read error", err)
+		}
+		if !client.aborted {
+			t.Error("expected multipart upload to be aborted")
+		}
+	})
+	t.Run("part error", func(t *testing.T) {
+		client := &mockS3Client{partErr: errors.New("denied")}
+		b := newTestS3Backend(client, nil)
+		if err := b.Upload(context.Background(), "k", strings.NewReader("data"), -1); err == nil {
+			t.Fatal("expected error")
+		}
+		if !client.aborted {
+			t.Error("expected multipart upload to be aborted")
+		}
+	})
+}
+
 func TestS3Download_Success(t *testing.T) {
 	b := newTestS3Backend(&mockS3Client{getBody: "file contents"}, nil)
 	var buf bytes.Buffer
@@ -128,7 +218,7 @@ func TestS3Download_CopyError(t *testing.T) {
 }
 
 // failingReadS3Client returns a reader that fails after a few bytes.
-type failingReadS3Client struct{}
+type failingReadS3Client struct{ mockS3Client }
 
 func (f *failingReadS3Client) PutObject(_ context.Context, _ *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
 	return &s3.PutObjectOutput{}, nil
@@ -13,17 +13,38 @@ type Config struct {
 	Recv     RecvConfig     `yaml:"recv"`
 	Tap      TapConfig      `yaml:"tap"`
 	Defaults DefaultsConfig `yaml:"defaults"`
+
+	// Presets are named filters that grep, slice, export and open apply
+	// with --preset. A preset in .logtap.yaml replaces one of the same
+	// name in the home config.
+	Presets map[string]Preset `yaml:"presets"`
+
+	// Issues lists problems found in the loaded files. Loading is lenient:
+	// unknown keys and invalid values are reported here, not rejected.
+	Issues []Issue `yaml:"-"`
 }
 
 // RecvConfig holds receiver defaults.
 type RecvConfig struct {
 	Addr           string   `yaml:"addr"`
+	OTLPGRPCAddr   string   `yaml:"otlp_grpc_addr"`
+	SyslogAddr     string   `yaml:"syslog_addr"`
+	ForwardAddr    string   `yaml:"forward_addr"`
 	Dir            string   `yaml:"dir"`
 	DiskCap        string   `yaml:"disk_cap"`
 	Redact         string   `yaml:"redact"`
 	RedactPatterns string   `yaml:"redact_patterns"`
 	Webhooks       []string `yaml:"webhooks"`
 	WebhookEvents  string   `yaml:"webhook_events"`
+	AuditSinks     []string `yaml:"audit_sinks"`
+
+	TimestampFallback bool     `yaml:"timestamp_fallback"`
+	TimestampLayouts  []string `yaml:"timestamp_layouts"`
+
+	KafkaBrokers []string `yaml:"kafka_brokers"`
+	KafkaTopics  []string `yaml:"kafka_topics"`
+	KafkaGroup   string   `yaml:"kafka_group"`
+	KafkaStart   string   `yaml:"kafka_start"`
 }
 
 // TapConfig holds tap defaults.
@@ -31,12 +52,28 @@ type TapConfig struct {
 	Namespace string `yaml:"namespace"`
 	CPU       string `yaml:"cpu"`
 	Memory    string `yaml:"memory"`
+	Sanitize  string `yaml:"sanitize"`
 }
 
 // DefaultsConfig holds global defaults.
 type DefaultsConfig struct {
 	Timeout string `yaml:"timeout"`
 	Verbose bool   `yaml:"verbose"`
+
+	// LanguagePacks selects the error classification packs (java, go,
+	// python, nginx) used by triage, diff and the other analysis commands.
+	LanguagePacks []string `yaml:"language_packs"`
+}
+
+// Preset is a saved filter. From and To take the same forms as the --from
+// and --to flags, so relative times like -30m are templates resolved
+// against each capture.
+type Preset struct {
+	Description string   `yaml:"description" json:"description,omitempty"`
+	Labels      []string `yaml:"labels" json:"labels,omitempty"` // key=value
+	Grep        string   `yaml:"grep" json:"grep,omitempty"`
+	From        string   `yaml:"from" json:"from,omitempty"`
+	To          string   `yaml:"to" json:"to,omitempty"`
 }
 
 // Load reads config from ~/.logtap/config.yaml then CWD .logtap.yaml.
@@ -74,6 +111,7 @@ func loadFile(path string, cfg *Config) error {
 	if err != nil {
 		return err
 	}
+	cfg.Issues = append(cfg.Issues, Lint(path, data)...)
 	return yaml.Unmarshal(data, cfg)
 }
 
@@ -81,6 +119,15 @@ func applyEnv(cfg *Config) {
 	if v := os.Getenv("LOGTAP_RECV_ADDR"); v != "" {
 		cfg.Recv.Addr = v
 	}
+	if v := os.Getenv("LOGTAP_RECV_OTLP_GRPC_ADDR"); v != "" {
+		cfg.Recv.OTLPGRPCAddr = v
+	}
+	if v := os.Getenv("LOGTAP_RECV_SYSLOG_ADDR"); v != "" {
+		cfg.Recv.SyslogAddr = v
+	}
+	if v := os.Getenv("LOGTAP_RECV_FORWARD_ADDR"); v != "" {
+		cfg.Recv.ForwardAddr = v
+	}
 	if v := os.Getenv("LOGTAP_RECV_DIR"); v != "" {
 		cfg.Recv.Dir = v
 	}
@@ -96,6 +143,18 @@ func applyEnv(cfg *Config) {
 	if v := os.Getenv("LOGTAP_RECV_WEBHOOK_EVENTS"); v != "" {
 		cfg.Recv.WebhookEvents = v
 	}
+	if v := os.Getenv("LOGTAP_RECV_AUDIT_SINKS"); v != "" {
+		cfg.Recv.AuditSinks = strings.Split(v, ",")
+	}
+	if v := os.Getenv("LOGTAP_RECV_KAFKA_BROKERS"); v != "" {
+		cfg.Recv.KafkaBrokers = strings.Split(v, ",")
+	}
+	if v := os.Getenv("LOGTAP_RECV_KAFKA_TOPICS"); v != "" {
+		cfg.Recv.KafkaTopics = strings.Split(v, ",")
+	}
+	if v := os.Getenv("LOGTAP_RECV_KAFKA_GROUP"); v != "" {
+		cfg.Recv.KafkaGroup = v
+	}
 	if v := os.Getenv("LOGTAP_TAP_NAMESPACE"); v != "" {
 		cfg.Tap.Namespace = v
 	}
@@ -105,10 +164,16 @@ func applyEnv(cfg *Config) {
 	if v := os.Getenv("LOGTAP_TAP_MEMORY"); v != "" {
 		cfg.Tap.Memory = v
 	}
+	if v := os.Getenv("LOGTAP_TAP_SANITIZE"); v != "" {
+		cfg.Tap.Sanitize = v
+	}
 	if v := os.Getenv("LOGTAP_TIMEOUT"); v != "" {
 		cfg.Defaults.Timeout = v
 	}
 	if v := os.Getenv("LOGTAP_VERBOSE"); v != "" {
 		cfg.Defaults.Verbose = strings.EqualFold(v, "true") || v == "1"
 	}
+	if v := os.Getenv("LOGTAP_LANGUAGE_PACKS"); v != "" {
+		cfg.Defaults.LanguagePacks = strings.Split(v, ",")
+	}
 }
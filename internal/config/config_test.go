@@ -220,6 +220,91 @@ func TestWebhookEnvOverride(t *testing.T) {
 	}
 }
 
+func TestAuditSinksEnvOverride(t *testing.T) {
+	t.Setenv("LOGTAP_RECV_AUDIT_SINKS", "syslog+tcp://siem:601,https://audit.example.com")
+
+	cfg := &Config{}
+	applyEnv(cfg)
+
+	if len(cfg.Recv.AuditSinks) != 2 || cfg.Recv.AuditSinks[0] != "syslog+tcp://siem:601" {
+		t.Errorf("audit_sinks = %v", cfg.Recv.AuditSinks)
+	}
+}
+
+func TestTapSanitizeEnvOverride(t *testing.T) {
+	t.Setenv("LOGTAP_TAP_SANITIZE", "all")
+
+	cfg := &Config{}
+	applyEnv(cfg)
+
+	if cfg.Tap.Sanitize != "all" {
+		t.Errorf("Tap.Sanitize = %q, want all", cfg.Tap.Sanitize)
+	}
+}
+
+func TestLanguagePacksEnvOverride(t *testing.T) {
+	t.Setenv("LOGTAP_LANGUAGE_PACKS", "java,nginx")
+
+	cfg := &Config{}
+	applyEnv(cfg)
+
+	if len(cfg.Defaults.LanguagePacks) != 2 || cfg.Defaults.LanguagePacks[1] != "nginx" {
+		t.Errorf("language_packs = %v", cfg.Defaults.LanguagePacks)
+	}
+}
+
+func TestOTLPGRPCAddrEnvOverride(t *testing.T) {
+	t.Setenv("LOGTAP_RECV_OTLP_GRPC_ADDR", "0.0.0.0:4317")
+
+	cfg := &Config{}
+	applyEnv(cfg)
+
+	if cfg.Recv.OTLPGRPCAddr != "0.0.0.0:4317" {
+		t.Errorf("Recv.OTLPGRPCAddr = %q, want 0.0.0.0:4317", cfg.Recv.OTLPGRPCAddr)
+	}
+}
+
+func TestSyslogAddrEnvOverride(t *testing.T) {
+	t.Setenv("LOGTAP_RECV_SYSLOG_ADDR", ":5514")
+
+	cfg := &Config{}
+	applyEnv(cfg)
+
+	if cfg.Recv.SyslogAddr != ":5514" {
+		t.Errorf("Recv.SyslogAddr = %q, want :5514", cfg.Recv.SyslogAddr)
+	}
+}
+
+func TestForwardAddrEnvOverride(t *testing.T) {
+	t.Setenv("LOGTAP_RECV_FORWARD_ADDR", ":24224")
+
+	cfg := &Config{}
+	applyEnv(cfg)
+
+	if cfg.Recv.ForwardAddr != ":24224" {
+		t.Errorf("Recv.ForwardAddr = %q, want :24224", cfg.Recv.ForwardAddr)
+	}
+}
+
+func TestKafkaEnvOverride(t *testing.T) {
+	t.Setenv("LOGTAP_RECV_KAFKA_BROKERS", "kafka-0:9092,kafka-1:9092")
+	t.Setenv("LOGTAP_RECV_KAFKA_TOPICS", "app-logs")
+	t.Setenv("LOGTAP_RECV_KAFKA_GROUP", "capture")
+
+	cfg := &Config{}
+	applyEnv(cfg)
+
+	if len(cfg.Recv.KafkaBrokers) != 2 || cfg.Recv.KafkaBrokers[1] != "kafka-1:9092" {
+		t.Errorf("Recv.KafkaBrokers = %v", cfg.Recv.KafkaBrokers)
+	}
+	if len(cfg.Recv.KafkaTopics) != 1 || cfg.Recv.KafkaTopics[0] != "app-logs" {
+		t.Errorf("Recv.KafkaTopics = %v", cfg.Recv.KafkaTopics)
+	}
+	if cfg.Recv.KafkaGroup != "capture" {
+		t.Errorf("Recv.KafkaGroup = %q, want capture", cfg.Recv.KafkaGroup)
+	}
+}
+
 func TestPartialConfig(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "config.yaml")
@@ -246,3 +331,43 @@ func TestPartialConfig(t *testing.T) {
 		t.Errorf("Tap.Namespace = %q, want empty", cfg.Tap.Namespace)
 	}
 }
+
+func TestPresets(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home.yaml")
+	local := filepath.Join(dir, "local.yaml")
+	if err := os.WriteFile(home, []byte(`presets:
+  payment-errors:
+    labels: [app=payments]
+    grep: "declined"
+  slow:
+    grep: "took [0-9]+s"
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(local, []byte(`presets:
+  payment-errors:
+    description: Payment failures near the end
+    labels: [app=payments, env=staging]
+    from: -30m
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{}
+	if err := loadFile(home, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadFile(local, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.Presets) != 2 || cfg.Presets["slow"].Grep != "took [0-9]+s" {
+		t.Errorf("Presets = %+v, want both files' presets", cfg.Presets)
+	}
+	// the later file replaces a preset as a whole
+	p := cfg.Presets["payment-errors"]
+	if p.Grep != "" || p.From != "-30m" || len(p.Labels) != 2 || p.Description == "" {
+		t.Errorf("payment-errors = %+v", p)
+	}
+}
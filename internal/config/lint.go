@@ -0,0 +1,588 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+// Issue severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is a problem found in a config file, with its location.
+type Issue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Key      string `json:"key,omitempty"` // dotted path, e.g. recv.disk_cap
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	loc := i.File
+	if i.Line > 0 {
+		loc += ":" + strconv.Itoa(i.Line)
+		if i.Column > 0 {
+			loc += ":" + strconv.Itoa(i.Column)
+		}
+	}
+	if i.Key != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", loc, i.Severity, i.Key, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, i.Severity, i.Message)
+}
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindBool
+	kindStringList
+)
+
+type field struct {
+	kind  fieldKind
+	check func(v string) error // optional, per scalar value
+}
+
+// schema mirrors the yaml tags of Config. Keep in sync when adding fields.
+var schema = map[string]map[string]field{
+	"recv": {
+		"addr":               {kind: kindString, check: checkAddr},
+		"otlp_grpc_addr":     {kind: kindString, check: checkAddr},
+		"syslog_addr":        {kind: kindString, check: checkAddr},
+		"forward_addr":       {kind: kindString, check: checkAddr},
+		"dir":                {kind: kindString},
+		"disk_cap":           {kind: kindString, check: checkByteSize},
+		"redact":             {kind: kindString},
+		"redact_patterns":    {kind: kindString},
+		"webhooks":           {kind: kindStringList, check: checkWebhookURL},
+		"webhook_events":     {kind: kindString, check: checkWebhookEvents},
+		"audit_sinks":        {kind: kindStringList, check: checkAuditSink},
+		"timestamp_fallback": {kind: kindBool},
+		"timestamp_layouts":  {kind: kindStringList, check: recv.CheckTimestampLayout},
+		"kafka_brokers":      {kind: kindStringList, check: checkBrokerAddr},
+		"kafka_topics":       {kind: kindStringList},
+		"kafka_group":        {kind: kindString},
+		"kafka_start":        {kind: kindString, check: checkKafkaStart},
+	},
+	"tap": {
+		"namespace": {kind: kindString},
+		"cpu":       {kind: kindString, check: checkQuantity},
+		"memory":    {kind: kindString, check: checkQuantity},
+		"sanitize":  {kind: kindString, check: checkSanitize},
+	},
+	"defaults": {
+		"timeout":        {kind: kindString, check: checkDuration},
+		"verbose":        {kind: kindBool},
+		"language_packs": {kind: kindStringList, check: checkLanguagePack},
+	},
+}
+
+// presetSchema mirrors the yaml tags of Preset, for every entry of the
+// presets section.
+var presetSchema = map[string]field{
+	"description": {kind: kindString},
+	"labels":      {kind: kindStringList, check: checkLabelFilter},
+	"grep":        {kind: kindString, check: checkRegex},
+	"from":        {kind: kindString, check: checkTimeTemplate},
+	"to":          {kind: kindString, check: checkTimeTemplate},
+}
+
+var presetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// webhookEvents are the event names accepted by recv --webhook-events.
+var webhookEvents = recv.WebhookEvents
+
+// languagePacks are the values accepted by --language-pack. Keep in sync
+// with the packs built into the archive package.
+var languagePacks = []string{"go", "java", "nginx", "python"}
+
+// sanitizeModes are the values accepted by tap --sanitize.
+var sanitizeModes = []string{"ansi", "control", "all", "off"}
+
+var byteSizePattern = regexp.MustCompile(`(?i)^\d+(?:\.\d+)?\s*(KB|MB|GB|TB|B)?$`)
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// LintFile reads and checks a config file. The error is only set when the
+// file cannot be read; problems with its content are returned as issues.
+func LintFile(path string) ([]Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Lint(path, data), nil
+}
+
+// Lint checks config data against the schema: syntax, unknown keys, value
+// types and formats, deprecated usage, and settings that contradict each
+// other. name is used as the file in reported issues.
+func Lint(name string, data []byte) []Issue {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []Issue{syntaxIssue(name, err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil // empty file
+	}
+
+	l := &linter{file: name}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		if isNull(root) {
+			return nil
+		}
+		l.errorf(root, "", "expected a mapping of sections (recv, tap, defaults, presets)")
+		return l.issues
+	}
+
+	values := make(map[string]*yaml.Node)
+	l.eachPair(root, "", func(key, val *yaml.Node) {
+		if key.Value == "presets" {
+			l.lintPresets(val)
+			return
+		}
+		section, ok := schema[key.Value]
+		if !ok {
+			l.unknown(key, "", key.Value, append(keysOf(schema), "presets"))
+			return
+		}
+		if isNull(val) {
+			return
+		}
+		if val.Kind != yaml.MappingNode {
+			l.errorf(val, key.Value, "expected a mapping")
+			return
+		}
+		l.eachPair(val, key.Value, func(k, v *yaml.Node) {
+			path := key.Value + "." + k.Value
+			f, ok := section[k.Value]
+			if !ok {
+				l.unknown(k, key.Value, k.Value, keysOf(section))
+				return
+			}
+			if l.checkField(path, f, v) {
+				values[path] = v
+			}
+		})
+	})
+
+	l.checkDeprecated(values)
+	l.checkConflicts(values)
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].Line != l.issues[j].Line {
+			return l.issues[i].Line < l.issues[j].Line
+		}
+		return l.issues[i].Column < l.issues[j].Column
+	})
+	return l.issues
+}
+
+// HasErrors reports whether any issue has error severity.
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+type linter struct {
+	file   string
+	issues []Issue
+}
+
+func (l *linter) add(n *yaml.Node, severity, key, format string, args ...any) {
+	l.issues = append(l.issues, Issue{
+		File:     l.file,
+		Line:     n.Line,
+		Column:   n.Column,
+		Severity: severity,
+		Key:      key,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) errorf(n *yaml.Node, key, format string, args ...any) {
+	l.add(n, SeverityError, key, format, args...)
+}
+
+func (l *linter) warnf(n *yaml.Node, key, format string, args ...any) {
+	l.add(n, SeverityWarning, key, format, args...)
+}
+
+// eachPair calls fn for every key of a mapping node and reports duplicates,
+// which yaml would otherwise reject only when decoding.
+func (l *linter) eachPair(m *yaml.Node, parent string, fn func(key, val *yaml.Node)) {
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, val := m.Content[i], m.Content[i+1]
+		if seen[key.Value] {
+			l.errorf(key, joinKey(parent, key.Value), "duplicate key")
+			continue
+		}
+		seen[key.Value] = true
+		fn(key, val)
+	}
+}
+
+func (l *linter) unknown(key *yaml.Node, parent, name string, known []string) {
+	msg := "unknown key, ignored"
+	if s := suggest(name, known); s != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", s)
+	}
+	l.errorf(key, joinKey(parent, name), "%s", msg)
+}
+
+// checkField validates a value's type and format. Returns false when the
+// value is unusable.
+func (l *linter) checkField(path string, f field, v *yaml.Node) bool {
+	if isNull(v) {
+		return false
+	}
+	switch f.kind {
+	case kindBool:
+		if v.Kind != yaml.ScalarNode || v.Tag != "!!bool" {
+			l.errorf(v, path, "expected true or false")
+			return false
+		}
+	case kindString:
+		if v.Kind != yaml.ScalarNode {
+			l.errorf(v, path, "expected a string")
+			return false
+		}
+		return l.checkValue(path, f, v)
+	case kindStringList:
+		if v.Kind != yaml.SequenceNode {
+			l.errorf(v, path, "expected a list of strings")
+			return false
+		}
+		ok := true
+		for _, item := range v.Content {
+			if item.Kind != yaml.ScalarNode {
+				l.errorf(item, path, "expected a string")
+				ok = false
+				continue
+			}
+			ok = l.checkValue(path, f, item) && ok
+		}
+		return ok
+	}
+	return true
+}
+
+func (l *linter) checkValue(path string, f field, v *yaml.Node) bool {
+	if f.check == nil || v.Value == "" {
+		return true
+	}
+	if err := f.check(v.Value); err != nil {
+		l.errorf(v, path, "%v", err)
+		return false
+	}
+	return true
+}
+
+// lintPresets checks the presets section: a mapping of names to presets.
+func (l *linter) lintPresets(val *yaml.Node) {
+	if isNull(val) {
+		return
+	}
+	if val.Kind != yaml.MappingNode {
+		l.errorf(val, "presets", "expected a mapping of preset names")
+		return
+	}
+	l.eachPair(val, "presets", func(name, preset *yaml.Node) {
+		parent := "presets." + name.Value
+		if !presetNamePattern.MatchString(name.Value) {
+			l.errorf(name, parent, "invalid preset name (use letters, digits, '-', '_' and '.')")
+		}
+		if isNull(preset) {
+			return
+		}
+		if preset.Kind != yaml.MappingNode {
+			l.errorf(preset, parent, "expected a mapping")
+			return
+		}
+		l.eachPair(preset, parent, func(k, v *yaml.Node) {
+			f, ok := presetSchema[k.Value]
+			if !ok {
+				l.unknown(k, parent, k.Value, keysOf(presetSchema))
+				return
+			}
+			l.checkField(parent+"."+k.Value, f, v)
+		})
+	})
+}
+
+// checkDeprecated flags settings that still work but have a replacement.
+func (l *linter) checkDeprecated(values map[string]*yaml.Node) {
+	if v := values["recv.redact"]; v != nil {
+		lower := strings.ToLower(v.Value)
+		if strings.HasSuffix(lower, ".yaml") || strings.HasSuffix(lower, ".yml") {
+			l.warnf(v, "recv.redact", "a patterns file path here is deprecated; set redact: \"true\" and redact_patterns: %q", v.Value)
+		}
+	}
+}
+
+// checkConflicts flags settings that have no effect given other settings.
+func (l *linter) checkConflicts(values map[string]*yaml.Node) {
+	if v := values["recv.redact_patterns"]; v != nil && v.Value != "" {
+		redact := values["recv.redact"]
+		if redact == nil || redact.Value == "" || strings.EqualFold(redact.Value, "false") {
+			l.warnf(v, "recv.redact_patterns", "has no effect while redact is disabled")
+		}
+	}
+	if v := values["recv.webhook_events"]; v != nil && v.Value != "" {
+		hooks := values["recv.webhooks"]
+		if hooks == nil || len(hooks.Content) == 0 {
+			l.warnf(v, "recv.webhook_events", "has no effect without webhooks")
+		}
+	}
+	brokers, topics := values["recv.kafka_brokers"], values["recv.kafka_topics"]
+	hasBrokers := brokers != nil && len(brokers.Content) > 0
+	hasTopics := topics != nil && len(topics.Content) > 0
+	if hasBrokers && !hasTopics {
+		l.warnf(brokers, "recv.kafka_brokers", "has no effect without kafka_topics")
+	}
+	if hasTopics && !hasBrokers {
+		l.warnf(topics, "recv.kafka_topics", "has no effect without kafka_brokers")
+	}
+}
+
+func syntaxIssue(name string, err error) Issue {
+	is := Issue{File: name, Severity: SeverityError, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+	if m := yamlLinePattern.FindStringSubmatch(err.Error()); m != nil {
+		is.Line, _ = strconv.Atoi(m[1])
+	}
+	return is
+}
+
+func isNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null"
+}
+
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func keysOf[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// suggest returns the known key closest to name, if it is a plausible typo.
+func suggest(name string, known []string) string {
+	best, bestDist := "", 0
+	for _, k := range known {
+		d := editDistance(strings.ToLower(name), k)
+		if best == "" || d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	if best == "" || bestDist > 2 || bestDist > len(best)/2 {
+		return ""
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func checkAddr(v string) error {
+	if _, _, err := net.SplitHostPort(v); err != nil {
+		return fmt.Errorf("invalid listen address %q (expected host:port or :port)", v)
+	}
+	return nil
+}
+
+func checkBrokerAddr(v string) error {
+	if host, port, err := net.SplitHostPort(v); err != nil || host == "" || port == "" {
+		return fmt.Errorf("invalid broker address %q (expected host:port)", v)
+	}
+	return nil
+}
+
+func checkKafkaStart(v string) error {
+	if v != "latest" && v != "earliest" {
+		return fmt.Errorf("invalid kafka start %q (expected latest or earliest)", v)
+	}
+	return nil
+}
+
+func checkByteSize(v string) error {
+	if !byteSizePattern.MatchString(strings.TrimSpace(v)) {
+		return fmt.Errorf("invalid size %q (expected e.g. 500MB, 50GB)", v)
+	}
+	return nil
+}
+
+func checkDuration(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q (expected e.g. 30s, 2m)", v)
+	}
+	if d <= 0 {
+		return errors.New("duration must be positive")
+	}
+	return nil
+}
+
+func checkLabelFilter(v string) error {
+	if key, _, ok := strings.Cut(v, "="); !ok || key == "" {
+		return fmt.Errorf("invalid label filter %q (expected key=value)", v)
+	}
+	return nil
+}
+
+func checkRegex(v string) error {
+	if _, err := regexp.Compile(v); err != nil {
+		return fmt.Errorf("invalid regex %q: %v", v, err)
+	}
+	return nil
+}
+
+// checkTimeTemplate accepts the forms of the --from and --to flags:
+// RFC3339, HH:MM, or a duration before the end of the capture (-30m).
+func checkTimeTemplate(v string) error {
+	if _, err := time.Parse(time.RFC3339, v); err == nil {
+		return nil
+	}
+	if _, err := time.Parse("15:04", v); err == nil && len(v) == 5 {
+		return nil
+	}
+	if rest, ok := strings.CutPrefix(v, "-"); ok {
+		if _, err := time.ParseDuration(rest); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time %q (expected RFC3339, HH:MM, or -30m)", v)
+}
+
+func checkQuantity(v string) error {
+	if _, err := resource.ParseQuantity(v); err != nil {
+		return fmt.Errorf("invalid resource quantity %q (expected e.g. 25m, 16Mi)", v)
+	}
+	return nil
+}
+
+func checkWebhookURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q (expected http:// or https://)", v)
+	}
+	return nil
+}
+
+func checkAuditSink(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid audit sink %q (expected https://, syslog://, or syslog+tcp:// URL)", v)
+	}
+	switch u.Scheme {
+	case "http", "https", "syslog", "syslog+udp", "syslog+tcp":
+		return nil
+	}
+	return fmt.Errorf("unsupported audit sink scheme %q (use https, syslog, or syslog+tcp)", u.Scheme)
+}
+
+func checkSanitize(v string) error {
+	for _, mode := range strings.Split(v, ",") {
+		mode = strings.ToLower(strings.TrimSpace(mode))
+		if mode == "" || mode == "none" {
+			continue
+		}
+		known := false
+		for _, k := range sanitizeModes {
+			if mode == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			if s := suggest(mode, sanitizeModes); s != "" {
+				return fmt.Errorf("unknown sanitize mode %q (did you mean %q?)", mode, s)
+			}
+			return fmt.Errorf("unknown sanitize mode %q (valid: %s)", mode, strings.Join(sanitizeModes, ", "))
+		}
+	}
+	return nil
+}
+
+func checkLanguagePack(v string) error {
+	name := strings.ToLower(strings.TrimSpace(v))
+	for _, k := range languagePacks {
+		if name == k {
+			return nil
+		}
+	}
+	if s := suggest(name, languagePacks); s != "" {
+		return fmt.Errorf("unknown language pack %q (did you mean %q?)", name, s)
+	}
+	return fmt.Errorf("unknown language pack %q (valid: %s)", name, strings.Join(languagePacks, ", "))
+}
+
+func checkWebhookEvents(v string) error {
+	for _, ev := range strings.Split(v, ",") {
+		ev = strings.TrimSpace(ev)
+		if ev == "" {
+			continue
+		}
+		known := false
+		for _, k := range webhookEvents {
+			if ev == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			if s := suggest(ev, webhookEvents); s != "" {
+				return fmt.Errorf("unknown webhook event %q (did you mean %q?)", ev, s)
+			}
+			return fmt.Errorf("unknown webhook event %q (valid: %s)", ev, strings.Join(webhookEvents, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintValid(t *testing.T) {
+	data := `recv:
+  addr: ":9000"
+  disk_cap: "50GB"
+  redact: "true"
+  redact_patterns: "/etc/patterns.yaml"
+  webhooks:
+    - "https://example.com/hook"
+  webhook_events: "start,stop,client-version"
+  audit_sinks:
+    - "syslog+tcp://siem.internal:601"
+    - "https://audit.example.com/ingest"
+  timestamp_fallback: true
+  timestamp_layouts:
+    - "02.01.2006 15:04:05"
+  kafka_brokers:
+    - "kafka-0.kafka:9092"
+  kafka_topics:
+    - "app-logs"
+  kafka_group: "logtap-load-test"
+  kafka_start: "earliest"
+tap:
+  cpu: "25m"
+  memory: "16Mi"
+  sanitize: "ansi,control"
+defaults:
+  timeout: "30s"
+  verbose: false
+`
+	if issues := Lint("config.yaml", []byte(data)); len(issues) != 0 {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+}
+
+func TestLintEmpty(t *testing.T) {
+	for _, data := range []string{"", "# only comments\n", "~\n"} {
+		if issues := Lint("config.yaml", []byte(data)); len(issues) != 0 {
+			t.Errorf("Lint(%q) = %v, want none", data, issues)
+		}
+	}
+}
+
+func TestLintUnknownKeys(t *testing.T) {
+	data := `recv:
+  dsk_cap: "10GB"
+defualts:
+  timeout: "30s"
+`
+	issues := Lint("config.yaml", []byte(data))
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 issues, got %v", issues)
+	}
+	first := issues[0]
+	if first.Line != 2 || first.Column != 3 || first.Key != "recv.dsk_cap" || first.Severity != SeverityError {
+		t.Errorf("first issue = %+v", first)
+	}
+	if !strings.Contains(first.Message, `did you mean "disk_cap"`) {
+		t.Errorf("message = %q, want suggestion", first.Message)
+	}
+	if issues[1].Line != 3 || !strings.Contains(issues[1].Message, `did you mean "defaults"`) {
+		t.Errorf("second issue = %+v", issues[1])
+	}
+}
+
+func TestLintInvalidValues(t *testing.T) {
+	data := `recv:
+  addr: "9000"
+  disk_cap: "lots"
+  webhooks: "https://example.com"
+  webhook_events: "start,rotaton"
+  audit_sinks:
+    - "ftp://siem.internal"
+  timestamp_layouts: ["2006-01-02"]
+tap:
+  cpu: "a bit"
+  sanitize: "ansii"
+defaults:
+  timeout: "30"
+  verbose: "yes please"
+  language_packs: [java, pyhton]
+`
+	issues := Lint("config.yaml", []byte(data))
+	want := map[string]int{
+		"recv.addr":               2,
+		"recv.disk_cap":           3,
+		"recv.webhooks":           4,
+		"recv.webhook_events":     5,
+		"recv.audit_sinks":        7,
+		"recv.timestamp_layouts":  8,
+		"tap.cpu":                 10,
+		"tap.sanitize":            11,
+		"defaults.timeout":        13,
+		"defaults.verbose":        14,
+		"defaults.language_packs": 15,
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %v", len(want), issues)
+	}
+	for _, is := range issues {
+		line, ok := want[is.Key]
+		if !ok || is.Line != line || is.Severity != SeverityError {
+			t.Errorf("unexpected issue %+v", is)
+		}
+	}
+	for _, is := range issues {
+		if is.Key == "recv.webhook_events" && !strings.Contains(is.Message, `did you mean "rotation"`) {
+			t.Errorf("webhook_events message = %q", is.Message)
+		}
+		if is.Key == "tap.sanitize" && !strings.Contains(is.Message, `did you mean "ansi"`) {
+			t.Errorf("sanitize message = %q", is.Message)
+		}
+		if is.Key == "defaults.language_packs" && !strings.Contains(is.Message, `did you mean "python"`) {
+			t.Errorf("language_packs message = %q", is.Message)
+		}
+	}
+}
+
+func TestLintDeprecatedAndConflicts(t *testing.T) {
+	data := `recv:
+  redact: "patterns.yaml"
+  webhook_events: "start"
+`
+	issues := Lint("config.yaml", []byte(data))
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 issues, got %v", issues)
+	}
+	for _, is := range issues {
+		if is.Severity != SeverityWarning {
+			t.Errorf("expected warning, got %+v", is)
+		}
+	}
+	if HasErrors(issues) {
+		t.Error("HasErrors should be false for warnings only")
+	}
+
+	issues = Lint("config.yaml", []byte("recv:\n  redact: \"false\"\n  redact_patterns: \"p.yaml\"\n"))
+	if len(issues) != 1 || issues[0].Key != "recv.redact_patterns" || issues[0].Line != 3 {
+		t.Errorf("expected redact_patterns conflict, got %v", issues)
+	}
+
+	issues = Lint("config.yaml", []byte("recv:\n  kafka_brokers: [\"kafka:9092\"]\n  kafka_start: \"oldest\"\n"))
+	if len(issues) != 2 || issues[0].Key != "recv.kafka_brokers" || issues[1].Key != "recv.kafka_start" {
+		t.Errorf("expected kafka_start error and kafka_brokers conflict, got %v", issues)
+	}
+}
+
+func TestLintDuplicateKey(t *testing.T) {
+	issues := Lint("config.yaml", []byte("tap:\n  cpu: \"25m\"\n  cpu: \"50m\"\n"))
+	if len(issues) != 1 || issues[0].Line != 3 || !strings.Contains(issues[0].Message, "duplicate") {
+		t.Errorf("expected duplicate key issue, got %v", issues)
+	}
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	issues := Lint("config.yaml", []byte("recv:\n  addr: \":9000\"\n bad: [\n"))
+	if len(issues) != 1 || issues[0].Severity != SeverityError || issues[0].Line == 0 {
+		t.Fatalf("expected syntax error with line, got %v", issues)
+	}
+	if !HasErrors(issues) {
+		t.Error("HasErrors should be true")
+	}
+}
+
+func TestLintWrongShape(t *testing.T) {
+	issues := Lint("config.yaml", []byte("- recv\n"))
+	if len(issues) != 1 || issues[0].Severity != SeverityError {
+		t.Errorf("expected top-level shape error, got %v", issues)
+	}
+	issues = Lint("config.yaml", []byte("tap: \"default\"\n"))
+	if len(issues) != 1 || issues[0].Key != "tap" {
+		t.Errorf("expected section shape error, got %v", issues)
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	is := Issue{File: "c.yaml", Line: 3, Column: 5, Severity: SeverityError, Key: "tap.cpu", Message: "bad"}
+	if got := is.String(); got != "c.yaml:3:5: error: tap.cpu: bad" {
+		t.Errorf("String() = %q", got)
+	}
+	is = Issue{File: "c.yaml", Severity: SeverityWarning, Message: "x"}
+	if got := is.String(); got != "c.yaml: warning: x" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestLoadCollectsIssues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("tap:\n  namespce: loadtest\n  cpu: \"50m\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tap.CPU != "50m" {
+		t.Errorf("valid keys should still load, CPU = %q", cfg.Tap.CPU)
+	}
+	if len(cfg.Issues) != 1 || cfg.Issues[0].File != path || cfg.Issues[0].Key != "tap.namespce" {
+		t.Errorf("Issues = %v", cfg.Issues)
+	}
+}
+
+func TestLintFileMissing(t *testing.T) {
+	if _, err := LintFile(filepath.Join(t.TempDir(), "nope.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLintPresets(t *testing.T) {
+	data := `presets:
+  payment-errors:
+    description: Payment failures
+    labels: [app=payments]
+    grep: "declined|timeout"
+    from: -30m
+    to: "2024-01-15T11:00:00Z"
+  bad preset:
+    grep: "(unclosed"
+    labels: [payments]
+    from: yesterday
+    lables: [app=api]
+  empty:
+`
+	issues := Lint("config.yaml", []byte(data))
+	want := map[string]int{
+		"presets.bad preset":        8,
+		"presets.bad preset.grep":   9,
+		"presets.bad preset.labels": 10,
+		"presets.bad preset.from":   11,
+		"presets.bad preset.lables": 12,
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %v", len(want), issues)
+	}
+	for _, is := range issues {
+		if line, ok := want[is.Key]; !ok || is.Line != line {
+			t.Errorf("unexpected issue %+v", is)
+		}
+		if is.Key == "presets.bad preset.lables" && !strings.Contains(is.Message, `did you mean "labels"`) {
+			t.Errorf("lables message = %q", is.Message)
+		}
+	}
+
+	if issues := Lint("config.yaml", []byte("presets: [a, b]\n")); len(issues) != 1 || issues[0].Key != "presets" {
+		t.Errorf("presets list: %v", issues)
+	}
+	if issues := Lint("config.yaml", []byte("preset:\n  x:\n    grep: a\n")); len(issues) != 1 || !strings.Contains(issues[0].Message, `did you mean "presets"`) {
+		t.Errorf("misspelled section: %v", issues)
+	}
+}
@@ -0,0 +1,125 @@
+package forward
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Push while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open: receiver failing, push paused")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+// Breaker states; the values are exported as the circuit state metric.
+const (
+	BreakerClosed   BreakerState = iota // pushes flow
+	BreakerHalfOpen                     // one trial push after the cooldown
+	BreakerOpen                         // pushes fail fast
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// Breaker pauses pushes after a run of consecutive failures. Once open it
+// rejects pushes for the cooldown, then lets a single trial through: success
+// closes it, failure opens it for another cooldown.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	onChange  func(BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trialAt  time.Time // start of the half-open trial; zero when none is in flight
+}
+
+// NewBreaker creates a Breaker opening after threshold consecutive failures.
+func NewBreaker(threshold int, cooldown time.Duration) (*Breaker, error) {
+	if threshold <= 0 {
+		return nil, fmt.Errorf("breaker threshold must be positive, got %d", threshold)
+	}
+	if cooldown <= 0 {
+		return nil, fmt.Errorf("breaker cooldown must be positive, got %s", cooldown)
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}, nil
+}
+
+// SetOnChange sets a callback invoked with the new state on each transition.
+func (b *Breaker) SetOnChange(fn func(BreakerState)) { b.onChange = fn }
+
+// State returns the current state.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow returns ErrCircuitOpen if a push may not be attempted now.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		b.trialAt = now
+	case BreakerHalfOpen:
+		// a trial that never reported back (cancelled) expires after a cooldown
+		if !b.trialAt.IsZero() && now.Sub(b.trialAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.trialAt = now
+	}
+	return nil
+}
+
+// Success records a delivered push and closes the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trialAt = time.Time{}
+	b.setState(BreakerClosed)
+}
+
+// Failure records a failed push, opening the breaker at the threshold or
+// when the half-open trial fails.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trialAt = time.Time{}
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setState(BreakerOpen)
+	}
+}
+
+// setState transitions and notifies; b.mu must be held.
+func (b *Breaker) setState(s BreakerState) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	if b.onChange != nil {
+		b.onChange(s)
+	}
+}
@@ -0,0 +1,94 @@
+package forward
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker_Transitions(t *testing.T) {
+	b, err := NewBreaker(3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	b.now = func() time.Time { return now }
+	var states []BreakerState
+	b.SetOnChange(func(s BreakerState) { states = append(states, s) })
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.Failure()
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("state after 2 failures = %s, want closed", b.State())
+	}
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("state after 3 failures = %s, want open", b.State())
+	}
+	if err := b.Allow(); err != ErrCircuitOpen {
+		t.Errorf("Allow while open = %v, want ErrCircuitOpen", err)
+	}
+
+	// after the cooldown one trial goes through
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow after cooldown: %v", err)
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state = %s, want half-open", b.State())
+	}
+	if err := b.Allow(); err != ErrCircuitOpen {
+		t.Errorf("second trial = %v, want ErrCircuitOpen", err)
+	}
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("failed trial: state = %s, want open", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	_ = b.Allow()
+	b.Success()
+	if b.State() != BreakerClosed {
+		t.Errorf("successful trial: state = %s, want closed", b.State())
+	}
+
+	want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(states) != len(want) {
+		t.Fatalf("transitions = %v, want %v", states, want)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Errorf("transition %d = %s, want %s", i, states[i], want[i])
+		}
+	}
+}
+
+func TestBreaker_StuckTrialExpires(t *testing.T) {
+	b, _ := NewBreaker(1, time.Minute)
+	now := time.Unix(1700000000, 0)
+	b.now = func() time.Time { return now }
+	b.Failure()
+	now = now.Add(time.Minute)
+	_ = b.Allow() // trial starts and never reports back
+
+	now = now.Add(30 * time.Second)
+	if err := b.Allow(); err != ErrCircuitOpen {
+		t.Errorf("Allow during trial = %v, want ErrCircuitOpen", err)
+	}
+	now = now.Add(30 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Errorf("Allow after abandoned trial: %v", err)
+	}
+}
+
+func TestNewBreaker_Invalid(t *testing.T) {
+	if _, err := NewBreaker(0, time.Second); err == nil {
+		t.Error("expected error for zero threshold")
+	}
+	if _, err := NewBreaker(1, 0); err == nil {
+		t.Error("expected error for zero cooldown")
+	}
+}
@@ -9,13 +9,15 @@ type Batch struct {
 	Size   int // estimated byte size
 }
 
-// Buffer is a bounded FIFO queue that drops oldest entries when full.
+// Buffer is a bounded FIFO queue that drops oldest entries when full, or
+// moves them to a disk spill when one is attached.
 type Buffer struct {
 	mu      sync.Mutex
 	batches []Batch
 	size    int
 	cap     int
 	drops   int64
+	spill   *Spill
 }
 
 // NewBuffer creates a buffer with the given byte capacity.
@@ -23,6 +25,14 @@ func NewBuffer(maxBytes int) *Buffer {
 	return &Buffer{cap: maxBytes}
 }
 
+// SetSpill moves batches evicted from memory to s instead of dropping them.
+// Spilled batches are older than those in memory and drain first.
+func (b *Buffer) SetSpill(s *Spill) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spill = s
+}
+
 // Add appends a batch, evicting oldest entries if over capacity.
 func (b *Buffer) Add(batch Batch) {
 	b.mu.Lock()
@@ -30,20 +40,33 @@ func (b *Buffer) Add(batch Batch) {
 
 	// evict oldest until there is room
 	for b.size+batch.Size > b.cap && len(b.batches) > 0 {
+		if b.spill == nil || b.spill.Write(b.batches[0]) != nil {
+			b.drops++
+		}
 		b.size -= b.batches[0].Size
 		b.batches = b.batches[1:]
-		b.drops++
 	}
 
 	b.batches = append(b.batches, batch)
 	b.size += batch.Size
 }
 
-// Drain returns all buffered batches and clears the buffer.
+// Drain returns all buffered batches and clears the buffer. With a spill
+// attached, each call returns one spilled segment while any remain, then
+// the in-memory batches.
 func (b *Buffer) Drain() []Batch {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	for b.spill != nil && b.spill.Len() > 0 {
+		batches, err := b.spill.ReadOldest()
+		if len(batches) > 0 {
+			return batches
+		}
+		if err != nil {
+			break
+		}
+	}
 	if len(b.batches) == 0 {
 		return nil
 	}
@@ -54,25 +77,50 @@ func (b *Buffer) Drain() []Batch {
 	return out
 }
 
-// Size returns the current total byte usage.
+// Persist moves the in-memory batches to the spill so they survive a
+// restart. Without a spill it does nothing.
+func (b *Buffer) Persist() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spill == nil || len(b.batches) == 0 {
+		return nil
+	}
+	if err := b.spill.Write(b.batches...); err != nil {
+		return err
+	}
+	b.batches = nil
+	b.size = 0
+	return nil
+}
+
+// Size returns the current in-memory byte usage.
 func (b *Buffer) Size() int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	return b.size
 }
 
-// Len returns the number of buffered batches.
+// Len returns the number of buffered batches, spilled ones included.
 func (b *Buffer) Len() int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return len(b.batches)
+	n := len(b.batches)
+	if b.spill != nil {
+		n += b.spill.Len()
+	}
+	return n
 }
 
-// Drops returns the total number of batches dropped due to overflow.
+// Drops returns the total number of batches dropped due to overflow,
+// including those dropped from the spill.
 func (b *Buffer) Drops() int64 {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return b.drops
+	n := b.drops
+	if b.spill != nil {
+		n += b.spill.Drops()
+	}
+	return n
 }
 
 // EstimateBatchSize returns a rough byte estimate for a batch.
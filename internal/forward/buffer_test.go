@@ -131,3 +131,63 @@ func TestEstimateBatchSize(t *testing.T) {
 		t.Errorf("EstimateBatchSize = %d, want 84", size)
 	}
 }
+
+func TestBuffer_SpillOverflow(t *testing.T) {
+	spill, err := OpenSpill(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b0, b1, b2 := spillTestBatch(0), spillTestBatch(1), spillTestBatch(2)
+	buf := NewBuffer(b0.Size * 2)
+	buf.SetSpill(spill)
+
+	buf.Add(b0)
+	buf.Add(b1)
+	buf.Add(b2) // evicts b0 to disk
+	if buf.Drops() != 0 {
+		t.Fatalf("drops = %d, want 0 with a spill", buf.Drops())
+	}
+	if buf.Len() != 3 || spill.Len() != 1 {
+		t.Fatalf("Len = %d (spill %d), want 3 (1)", buf.Len(), spill.Len())
+	}
+
+	// spilled batches are older and drain first
+	if got := buf.Drain(); len(got) != 1 || got[0].Lines[0].Line != "line 0" {
+		t.Fatalf("first drain = %+v, want spilled line 0", got)
+	}
+	if got := buf.Drain(); len(got) != 2 || got[0].Lines[0].Line != "line 1" {
+		t.Fatalf("second drain = %+v, want memory lines 1-2", got)
+	}
+}
+
+func TestBuffer_Persist(t *testing.T) {
+	dir := t.TempDir()
+	spill, err := OpenSpill(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := NewBuffer(1 << 20)
+	buf.SetSpill(spill)
+	buf.Add(spillTestBatch(0))
+	if err := buf.Persist(); err != nil {
+		t.Fatal(err)
+	}
+	_ = spill.Close()
+	if buf.Size() != 0 {
+		t.Errorf("Size after persist = %d, want 0", buf.Size())
+	}
+
+	reopened, err := OpenSpill(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf = NewBuffer(1 << 20)
+	buf.SetSpill(reopened)
+	if got := buf.Drain(); len(got) != 1 || got[0].Lines[0].Line != "line 0" {
+		t.Errorf("drain after restart = %+v, want persisted batch", got)
+	}
+
+	if err := NewBuffer(10).Persist(); err != nil {
+		t.Errorf("Persist without spill: %v", err)
+	}
+}
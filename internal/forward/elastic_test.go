@@ -0,0 +1,160 @@
+package forward
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestElasticClient_Bulk(t *testing.T) {
+	var (
+		template map[string]any
+		attempts int
+		indexed  []string
+		auth     string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/_index_template/logtap":
+			_ = json.NewDecoder(r.Body).Decode(&template)
+			_, _ = w.Write([]byte(`{"acknowledged":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+			attempts++
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			var items []string
+			sc := bufio.NewScanner(r.Body)
+			for sc.Scan() {
+				var action struct {
+					Index struct {
+						Index string `json:"_index"`
+						ID    string `json:"_id"`
+					} `json:"index"`
+				}
+				if err := json.Unmarshal(sc.Bytes(), &action); err != nil {
+					t.Errorf("action line: %v", err)
+				}
+				sc.Scan()
+				status := 201
+				switch {
+				case strings.Contains(sc.Text(), "bad"):
+					status = 400
+				case strings.Contains(sc.Text(), "busy") && attempts == 2:
+					status = 429
+				default:
+					indexed = append(indexed, action.Index.Index+"/"+action.Index.ID)
+				}
+				items = append(items, fmt.Sprintf(`{"index":{"status":%d,"error":{"type":"x"}}}`, status))
+			}
+			_, _ = fmt.Fprintf(w, `{"errors":true,"items":[%s]}`, strings.Join(items, ","))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewElasticClient(srv.URL)
+	c.SetBackoff(Backoff{Base: time.Millisecond, Max: time.Millisecond})
+	c.SetAPIKey("a2V5")
+	pattern, err := ParseIndexPattern("logtap-%{+yyyy.MM.dd}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutIndexTemplate(context.Background(), "logtap", pattern); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(template["index_patterns"]); got != "[logtap-*]" {
+		t.Errorf("index_patterns = %s, want [logtap-*]", got)
+	}
+
+	ts := time.Date(2024, 1, 15, 23, 30, 0, 0, time.FixedZone("X", -3600))
+	var docs []ElasticDoc
+	for i, msg := range []string{"ok", "bad", "busy"} {
+		src, err := ElasticSource(ts, nil, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, ElasticDoc{Index: pattern.Name(ts), ID: fmt.Sprint(i), Source: src})
+	}
+	res, err := c.Bulk(context.Background(), docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Indexed != 2 || res.Rejected != 1 || !strings.Contains(res.FirstError, "HTTP 400") {
+		t.Errorf("result = %+v, want 2 indexed and the 400 rejected", res)
+	}
+	if want := "[logtap-2024.01.16/0 logtap-2024.01.16/2]"; fmt.Sprint(indexed) != want {
+		t.Errorf("indexed = %v, want %s (UTC dates, 429 retried)", indexed, want)
+	}
+	if attempts != 3 {
+		t.Errorf("bulk attempts = %d, want 3 (503 retried, then the 429 document)", attempts)
+	}
+	if auth != "ApiKey a2V5" {
+		t.Errorf("Authorization = %q", auth)
+	}
+}
+
+func TestElasticClient_ClientError(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, `{"error":"security_exception"}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	c := NewElasticClient(srv.URL)
+	_, err := c.Bulk(context.Background(), []ElasticDoc{{Index: "logtap", Source: []byte(`{}`)}})
+	if err == nil || !strings.Contains(err.Error(), "security_exception") {
+		t.Fatalf("err = %v, want the 403 body", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (no retry on 403)", calls)
+	}
+}
+
+func TestParseIndexPattern(t *testing.T) {
+	ts := time.Date(2024, 3, 5, 7, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		in, name, wildcard string
+	}{
+		{"logtap", "logtap", "logtap"},
+		{"logtap-%{+yyyy.MM.dd}", "logtap-2024.03.05", "logtap-*"},
+		{"logs-%{+yy-MM-dd_HH}-app", "logs-24-03-05_07-app", "logs-*-app"},
+	} {
+		p, err := ParseIndexPattern(tc.in)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.in, err)
+		}
+		if got := p.Name(ts); got != tc.name {
+			t.Errorf("%s: Name = %q, want %q", tc.in, got, tc.name)
+		}
+		if got := p.Wildcard(); got != tc.wildcard {
+			t.Errorf("%s: Wildcard = %q, want %q", tc.in, got, tc.wildcard)
+		}
+	}
+	for _, bad := range []string{"", "Logtap", "logtap-%{+yyyy.MMM}", "logtap-%{+yyyy", "logtap-%{host}"} {
+		if _, err := ParseIndexPattern(bad); err == nil {
+			t.Errorf("ParseIndexPattern(%q) should fail", bad)
+		}
+	}
+}
+
+func TestElasticSource(t *testing.T) {
+	src, err := ElasticSource(time.Unix(0, 1500).In(time.FixedZone("X", 3600)), map[string]string{"app.kubernetes.io/name": "api", "app": "web"}, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"@timestamp":"1970-01-01T00:00:00.0000015Z","message":"hello","labels":{"app":"web","app_kubernetes_io/name":"api"}}`
+	if string(src) != want {
+		t.Errorf("source = %s, want %s", src, want)
+	}
+}
@@ -0,0 +1,46 @@
+package forward
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DropFiltered is the drop reason for lines rejected by a LineFilter.
+const DropFiltered = "filtered"
+
+// LineFilter keeps only the lines worth shipping. A line must match Keep
+// (when set) and must not match Drop (when set). Multiline records are
+// matched as a whole, so a stack trace follows its first line.
+type LineFilter struct {
+	keep *regexp.Regexp
+	drop *regexp.Regexp
+}
+
+// NewLineFilter compiles the keep and drop patterns; an empty pattern is
+// not applied. It returns nil when both are empty.
+func NewLineFilter(keep, drop string) (*LineFilter, error) {
+	if keep == "" && drop == "" {
+		return nil, nil
+	}
+	f := &LineFilter{}
+	var err error
+	if keep != "" {
+		if f.keep, err = regexp.Compile(keep); err != nil {
+			return nil, fmt.Errorf("invalid keep pattern: %w", err)
+		}
+	}
+	if drop != "" {
+		if f.drop, err = regexp.Compile(drop); err != nil {
+			return nil, fmt.Errorf("invalid drop pattern: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether line is kept.
+func (f *LineFilter) Match(line string) bool {
+	if f.keep != nil && !f.keep.MatchString(line) {
+		return false
+	}
+	return f.drop == nil || !f.drop.MatchString(line)
+}
@@ -0,0 +1,50 @@
+package forward
+
+import "testing"
+
+func TestLineFilter(t *testing.T) {
+	f, err := NewLineFilter(`(?i)error|panic`, `healthz`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"GET /api 200", false},
+		{"ERROR db timeout", true},
+		{"panic: nil map\n\tgoroutine 1", true},
+		{"error on /healthz probe", false},
+	}
+	for _, tt := range tests {
+		if got := f.Match(tt.line); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestLineFilter_DropOnly(t *testing.T) {
+	f, err := NewLineFilter("", `^DEBUG`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Match("DEBUG cache hit") {
+		t.Error("DEBUG line kept")
+	}
+	if !f.Match("INFO started") {
+		t.Error("INFO line dropped")
+	}
+}
+
+func TestNewLineFilter(t *testing.T) {
+	f, err := NewLineFilter("", "")
+	if err != nil || f != nil {
+		t.Errorf("empty patterns = %v, %v; want nil, nil", f, err)
+	}
+	if _, err := NewLineFilter("(", ""); err == nil {
+		t.Error("expected error for invalid keep pattern")
+	}
+	if _, err := NewLineFilter("", "["); err == nil {
+		t.Error("expected error for invalid drop pattern")
+	}
+}
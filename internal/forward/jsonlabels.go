@@ -0,0 +1,135 @@
+package forward
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxJSONLabelValue caps a promoted value; longer values are not promoted.
+const maxJSONLabelValue = 256
+
+// reservedLabels are set by the forwarder itself and cannot be promoted.
+var reservedLabels = map[string]bool{"namespace": true, "pod": true, "session": true, "container": true}
+
+// JSONLabels promotes fields of JSON log lines to push labels, so a
+// capture can be sliced by level or tenant without grepping messages.
+type JSONLabels struct {
+	fields []jsonLabelField
+}
+
+type jsonLabelField struct {
+	label string
+	path  string // dotted path into the object
+}
+
+// ParseJSONLabels parses a comma list of fields to promote. Each item is a
+// field path, promoted under the path with dots replaced by underscores,
+// or label=path to choose the label name: "level,trace_id,tenant" or
+// "level,http_status=http.status". It returns nil for an empty spec.
+func ParseJSONLabels(spec string) (*JSONLabels, error) {
+	j := &JSONLabels{}
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		label, path, ok := strings.Cut(item, "=")
+		if !ok {
+			path, label = item, strings.ReplaceAll(item, ".", "_")
+		}
+		label, path = strings.TrimSpace(label), strings.TrimSpace(path)
+		if path == "" || !validLabelName(label) {
+			return nil, fmt.Errorf("invalid JSON label %q", item)
+		}
+		if reservedLabels[label] {
+			return nil, fmt.Errorf("JSON label %q is set by the forwarder", label)
+		}
+		if seen[label] {
+			return nil, fmt.Errorf("duplicate JSON label %q", label)
+		}
+		seen[label] = true
+		j.fields = append(j.fields, jsonLabelField{label: label, path: path})
+	}
+	if len(j.fields) == 0 {
+		return nil, nil
+	}
+	return j, nil
+}
+
+// Extract returns the promoted labels of line, or nil when line is not a
+// JSON object or has none of the fields. Only string, number and bool
+// values are promoted. A stitched multiline record is matched by its
+// leading object.
+func (j *JSONLabels) Extract(line string) map[string]string {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil
+	}
+	var doc map[string]any
+	dec := json.NewDecoder(strings.NewReader(trimmed))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil
+	}
+	var labels map[string]string
+	for _, f := range j.fields {
+		v, ok := jsonScalar(jsonField(doc, f.path))
+		if !ok || v == "" || len(v) > maxJSONLabelValue {
+			continue
+		}
+		if labels == nil {
+			labels = make(map[string]string, len(j.fields))
+		}
+		labels[f.label] = v
+	}
+	return labels
+}
+
+// jsonField looks up a dotted path in doc, accepting both nested objects
+// and literal dotted keys.
+func jsonField(doc map[string]any, path string) any {
+	if v, ok := doc[path]; ok {
+		return v
+	}
+	head, rest, ok := strings.Cut(path, ".")
+	for ok {
+		if sub, isMap := doc[head].(map[string]any); isMap {
+			if v := jsonField(sub, rest); v != nil {
+				return v
+			}
+		}
+		var next string
+		next, rest, ok = strings.Cut(rest, ".")
+		head += "." + next
+	}
+	return nil
+}
+
+func jsonScalar(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case json.Number:
+		return x.String(), true
+	case bool:
+		return strconv.FormatBool(x), true
+	}
+	return "", false
+}
+
+// validLabelName reports whether name is letters, digits and underscores,
+// not starting with a digit.
+func validLabelName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
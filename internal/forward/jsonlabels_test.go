@@ -0,0 +1,73 @@
+package forward
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONLabels_Extract(t *testing.T) {
+	j, err := ParseJSONLabels("level, trace_id,tenant,status=http.status,ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		line string
+		want map[string]string
+	}{
+		{"plain text", "level=error something", nil},
+		{"invalid json", `{"level": "error"`, nil},
+		{"no fields", `{"msg": "hi"}`, nil},
+		{
+			"scalars",
+			`{"level":"error","trace_id":"abc123","tenant":42,"ok":true,"msg":"x"}`,
+			map[string]string{"level": "error", "trace_id": "abc123", "tenant": "42", "ok": "true"},
+		},
+		{
+			"nested path",
+			`{"level":"info","http":{"status":503}}`,
+			map[string]string{"level": "info", "status": "503"},
+		},
+		{
+			"dotted key",
+			`{"http.status":200}`,
+			map[string]string{"status": "200"},
+		},
+		{
+			"objects and empty values skipped",
+			`{"level":"","tenant":{"id":1},"trace_id":null}`,
+			nil,
+		},
+		{
+			"stitched record",
+			"{\"level\":\"error\",\"msg\":\"boom\"}\n\tat Foo.bar(Foo.java:1)",
+			map[string]string{"level": "error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := j.Extract(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONLabels(t *testing.T) {
+	j, err := ParseJSONLabels(" , ")
+	if err != nil || j != nil {
+		t.Errorf("empty spec = %v, %v; want nil, nil", j, err)
+	}
+	j, err = ParseJSONLabels("log.level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := j.Extract(`{"log":{"level":"warn"}}`); got["log_level"] != "warn" {
+		t.Errorf("log.level promoted as %v, want log_level", got)
+	}
+	for _, spec := range []string{"pod", "level,level", "9lives", "bad-name=x", "lvl="} {
+		if _, err := ParseJSONLabels(spec); err == nil {
+			t.Errorf("ParseJSONLabels(%q): expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package forward
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Reasons a LineLimiter drops a line, used as the drop metric label.
+const (
+	DropSampled     = "sampled"
+	DropRateLimited = "rate_limited"
+)
+
+// LineLimiter thins the line stream of a chatty pod before it is batched.
+// Sampling keeps each line with a fixed probability; the rate cap then
+// drops lines beyond a per-second budget, allowing bursts of up to one
+// second's worth. A budget under one line a second keeps a line each time a
+// whole one has built up, e.g. every other second at 0.5. It is not safe for
+// concurrent use.
+type LineLimiter struct {
+	sampleRate float64 // fraction of lines kept; 1 keeps all
+	maxPerSec  float64 // 0 disables the cap
+	burst      float64 // tokens held at most: a second's worth, at least 1
+	rand       func() float64
+	now        func() time.Time
+
+	tokens float64
+	last   time.Time
+}
+
+// NewLineLimiter creates a LineLimiter keeping sampleRate (0 < rate <= 1)
+// of the lines and at most maxPerSec lines per second (0 for no cap).
+func NewLineLimiter(sampleRate, maxPerSec float64) (*LineLimiter, error) {
+	if sampleRate <= 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("sample rate must be in (0, 1], got %v", sampleRate)
+	}
+	if maxPerSec < 0 {
+		return nil, fmt.Errorf("max lines per second must not be negative, got %v", maxPerSec)
+	}
+	return &LineLimiter{
+		sampleRate: sampleRate,
+		maxPerSec:  maxPerSec,
+		rand:       rand.Float64,
+		now:        time.Now,
+		burst:      max(maxPerSec, 1),
+		tokens:     max(maxPerSec, 1),
+	}, nil
+}
+
+// Allow reports whether the next line is kept. A dropped line comes with
+// the reason, DropSampled or DropRateLimited.
+func (l *LineLimiter) Allow() (bool, string) {
+	if l.sampleRate < 1 && l.rand() >= l.sampleRate {
+		return false, DropSampled
+	}
+	if l.maxPerSec == 0 {
+		return true, ""
+	}
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.maxPerSec
+		l.tokens = min(l.tokens, l.burst)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false, DropRateLimited
+	}
+	l.tokens--
+	return true, ""
+}
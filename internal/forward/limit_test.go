@@ -0,0 +1,107 @@
+package forward
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineLimiter_Sample(t *testing.T) {
+	l, err := NewLineLimiter(0.25, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	draws := []float64{0.1, 0.3, 0.24, 0.9}
+	l.rand = func() float64 {
+		d := draws[0]
+		draws = draws[1:]
+		return d
+	}
+	var kept []bool
+	for range 4 {
+		ok, reason := l.Allow()
+		if !ok && reason != DropSampled {
+			t.Errorf("reason = %q, want %q", reason, DropSampled)
+		}
+		kept = append(kept, ok)
+	}
+	want := []bool{true, false, true, false}
+	for i := range want {
+		if kept[i] != want[i] {
+			t.Errorf("line %d kept = %v, want %v", i, kept[i], want[i])
+		}
+	}
+}
+
+func TestLineLimiter_Rate(t *testing.T) {
+	l, err := NewLineLimiter(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+
+	kept := 0
+	for range 25 {
+		if ok, reason := l.Allow(); ok {
+			kept++
+		} else if reason != DropRateLimited {
+			t.Errorf("reason = %q, want %q", reason, DropRateLimited)
+		}
+	}
+	if kept != 10 {
+		t.Errorf("kept %d lines of a burst, want the 10/s budget", kept)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	kept = 0
+	for range 25 {
+		if ok, _ := l.Allow(); ok {
+			kept++
+		}
+	}
+	if kept != 5 {
+		t.Errorf("kept %d lines after 500ms, want 5", kept)
+	}
+
+	now = now.Add(time.Hour)
+	kept = 0
+	for range 25 {
+		if ok, _ := l.Allow(); ok {
+			kept++
+		}
+	}
+	if kept != 10 {
+		t.Errorf("kept %d lines after an idle hour, want the burst capped at 10", kept)
+	}
+}
+
+func TestLineLimiter_FractionalRate(t *testing.T) {
+	l, err := NewLineLimiter(1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+
+	var kept []bool
+	for range 5 {
+		ok, _ := l.Allow()
+		kept = append(kept, ok)
+		now = now.Add(time.Second)
+	}
+	// one line every other second
+	want := []bool{true, false, true, false, true}
+	for i := range want {
+		if kept[i] != want[i] {
+			t.Errorf("second %d kept = %v, want %v", i, kept[i], want[i])
+		}
+	}
+}
+
+func TestNewLineLimiter_Invalid(t *testing.T) {
+	for _, tc := range []struct{ rate, max float64 }{{0, 0}, {1.5, 0}, {-0.1, 0}, {1, -1}} {
+		if _, err := NewLineLimiter(tc.rate, tc.max); err == nil {
+			t.Errorf("NewLineLimiter(%v, %v): expected error", tc.rate, tc.max)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package forward
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+)
+
+const (
+	// DefaultMultilineTimeout is how long a record waits for more lines
+	// before it is sent.
+	DefaultMultilineTimeout = time.Second
+	maxMultilineLines       = 1000
+	maxMultilineBytes       = 256 * 1024
+)
+
+// Multiline stitches continuation lines onto the record they belong to, so
+// a Java stack trace or Python traceback becomes one entry. A line matching
+// the start pattern begins a new record; any other line is appended to the
+// container's current record with a newline. Records are emitted when the
+// next one starts, when they grow past 1000 lines or 256KB, or once no line
+// has arrived for the timeout.
+type Multiline struct {
+	start   *regexp.Regexp
+	timeout time.Duration
+	now     func() time.Time
+
+	pending map[string]*multilineRecord // by container
+}
+
+type multilineRecord struct {
+	line  LogLine
+	b     strings.Builder
+	lines int
+	last  time.Time
+}
+
+// NewMultiline creates a Multiline whose records start at lines matching
+// pattern.
+func NewMultiline(pattern string, timeout time.Duration) (*Multiline, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multiline pattern: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = DefaultMultilineTimeout
+	}
+	return &Multiline{
+		start:   re,
+		timeout: timeout,
+		now:     time.Now,
+		pending: make(map[string]*multilineRecord),
+	}, nil
+}
+
+// Add feeds one line and returns the records it completes, in order.
+func (m *Multiline) Add(l LogLine) []LogLine {
+	var done []LogLine
+	if logtypes.IsRestartMarker(l.Line) {
+		// a restart ends the record in progress; the marker stands alone
+		if m.pending[l.Container] != nil {
+			done = append(done, m.take(l.Container))
+		}
+		return append(done, l)
+	}
+	rec := m.pending[l.Container]
+	if rec != nil && (m.start.MatchString(l.Line) ||
+		rec.lines >= maxMultilineLines || rec.b.Len()+1+len(l.Line) > maxMultilineBytes) {
+		done = append(done, rec.finish())
+		rec = nil
+	}
+	if rec == nil {
+		// a continuation without a record in progress starts its own
+		rec = &multilineRecord{line: l}
+		m.pending[l.Container] = rec
+	} else {
+		rec.b.WriteByte('\n')
+	}
+	rec.b.WriteString(l.Line)
+	rec.lines++
+	rec.last = m.now()
+	if rec.b.Len() >= maxMultilineBytes {
+		done = append(done, m.take(l.Container))
+	}
+	return done
+}
+
+// Expire returns the records that received no line for the timeout.
+func (m *Multiline) Expire() []LogLine {
+	now := m.now()
+	var done []LogLine
+	for _, c := range m.containers() {
+		if now.Sub(m.pending[c].last) >= m.timeout {
+			done = append(done, m.take(c))
+		}
+	}
+	return done
+}
+
+// Drain returns every record in progress.
+func (m *Multiline) Drain() []LogLine {
+	var done []LogLine
+	for _, c := range m.containers() {
+		done = append(done, m.take(c))
+	}
+	return done
+}
+
+// containers returns the containers with a record in progress, sorted.
+func (m *Multiline) containers() []string {
+	names := make([]string, 0, len(m.pending))
+	for c := range m.pending {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *Multiline) take(container string) LogLine {
+	rec := m.pending[container]
+	delete(m.pending, container)
+	return rec.finish()
+}
+
+func (r *multilineRecord) finish() LogLine {
+	l := r.line
+	l.Line = r.b.String()
+	return l
+}
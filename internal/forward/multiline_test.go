@@ -0,0 +1,116 @@
+package forward
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+)
+
+func newTestMultiline(t *testing.T, pattern string) (*Multiline, *time.Time) {
+	t.Helper()
+	m, err := NewMultiline(pattern, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func TestMultiline_PythonTraceback(t *testing.T) {
+	m, _ := newTestMultiline(t, `^\S`)
+	// continuation lines are indented
+	lines := []string{
+		"Traceback (most recent call last):",
+		`  File "app.py", line 3, in <module>`,
+		"    main()",
+		"next record",
+	}
+	var got []LogLine
+	for _, l := range lines {
+		got = append(got, m.Add(LogLine{Container: "app", Line: l})...)
+	}
+	if len(got) != 1 || strings.Count(got[0].Line, "\n") != 2 {
+		t.Fatalf("got %q, want the three-line traceback", got)
+	}
+	if rest := m.Drain(); len(rest) != 1 || rest[0].Line != "next record" {
+		t.Errorf("Drain = %q, want the record in progress", rest)
+	}
+}
+
+func TestMultiline_PerContainer(t *testing.T) {
+	m, _ := newTestMultiline(t, `^\d{4}-`)
+	ts := time.Unix(1700000000, 0)
+	m.Add(LogLine{Timestamp: ts, Container: "a", Line: "2024-01-15 a start"})
+	m.Add(LogLine{Container: "b", Line: "2024-01-15 b start"})
+	m.Add(LogLine{Container: "a", Line: "  a continued"})
+	m.Add(LogLine{Container: "b", Line: "  b continued"})
+
+	got := m.Drain()
+	if len(got) != 2 {
+		t.Fatalf("got %d records, want one per container", len(got))
+	}
+	if got[0].Container != "a" || got[0].Line != "2024-01-15 a start\n  a continued" || !got[0].Timestamp.Equal(ts) {
+		t.Errorf("record a = %+v", got[0])
+	}
+	if got[1].Line != "2024-01-15 b start\n  b continued" {
+		t.Errorf("record b = %q", got[1].Line)
+	}
+}
+
+func TestMultiline_Expire(t *testing.T) {
+	m, now := newTestMultiline(t, `^\S`)
+	m.Add(LogLine{Container: "app", Line: "panic: boom"})
+	*now = now.Add(500 * time.Millisecond)
+	m.Add(LogLine{Container: "app", Line: "\tgoroutine 1"})
+	if got := m.Expire(); len(got) != 0 {
+		t.Fatalf("expired %q before the timeout", got)
+	}
+	*now = now.Add(time.Second)
+	if got := m.Expire(); len(got) != 1 || got[0].Line != "panic: boom\n\tgoroutine 1" {
+		t.Errorf("Expire = %q", got)
+	}
+	if got := m.Drain(); len(got) != 0 {
+		t.Errorf("Drain after expiry = %q", got)
+	}
+}
+
+func TestMultiline_Caps(t *testing.T) {
+	m, _ := newTestMultiline(t, `^START`)
+	m.Add(LogLine{Container: "app", Line: "START"})
+	var done []LogLine
+	for i := 0; i < maxMultilineLines+5; i++ {
+		done = append(done, m.Add(LogLine{Container: "app", Line: "  more"})...)
+	}
+	if len(done) != 1 || strings.Count(done[0].Line, "\n")+1 != maxMultilineLines {
+		t.Fatalf("got %d records, want one capped at %d lines", len(done), maxMultilineLines)
+	}
+
+	big := strings.Repeat("x", maxMultilineBytes)
+	if got := m.Add(LogLine{Container: "other", Line: big}); len(got) != 1 || got[0].Line != big {
+		t.Error("oversized line should be emitted on its own")
+	}
+}
+
+func TestMultiline_RestartMarker(t *testing.T) {
+	// with a pattern that never matches, every line would be a continuation
+	m, _ := newTestMultiline(t, `^START`)
+	marker := logtypes.RestartMarker("app", 1)
+	got := m.Add(LogLine{Container: "app", Line: "START panic"})
+	got = append(got, m.Add(LogLine{Container: "app", Line: "  goroutine 1"})...)
+	got = append(got, m.Add(LogLine{Container: "app", Line: marker})...)
+	if len(got) != 2 || got[0].Line != "START panic\n  goroutine 1" || got[1].Line != marker {
+		t.Fatalf("got %q, want the record then the marker on its own", got)
+	}
+	if rest := m.Drain(); len(rest) != 0 {
+		t.Errorf("Drain = %q, want nothing in progress", rest)
+	}
+}
+
+func TestNewMultiline_InvalidPattern(t *testing.T) {
+	if _, err := NewMultiline("(", 0); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,70 @@
+package forward
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// DefaultPacingJitter is the jitter used when only a rate is configured.
+const DefaultPacingJitter = 0.2
+
+// Pacer spaces pushes to at most a rate per second. Each gap is randomized
+// by ±jitter of the interval and the first push waits a random fraction of
+// the jittered interval, so sidecars started by the same rollout drift apart
+// instead of flushing in lockstep.
+type Pacer struct {
+	interval time.Duration
+	jitter   float64
+	rand     func() float64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewPacer creates a Pacer allowing rate pushes per second with the given
+// jitter fraction (0 to 1).
+func NewPacer(rate, jitter float64) (*Pacer, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("push rate must be positive, got %v", rate)
+	}
+	if jitter < 0 || jitter > 1 {
+		return nil, fmt.Errorf("push jitter must be between 0 and 1, got %v", jitter)
+	}
+	return &Pacer{
+		interval: time.Duration(float64(time.Second) / rate),
+		jitter:   jitter,
+		rand:     rand.Float64,
+	}, nil
+}
+
+// Wait blocks until the next push is allowed and returns how long it waited.
+func (p *Pacer) Wait(ctx context.Context) (time.Duration, error) {
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.IsZero() {
+		p.next = now.Add(time.Duration(p.rand() * p.jitter * float64(p.interval)))
+	}
+	start := p.next
+	if start.Before(now) {
+		start = now
+	}
+	gap := time.Duration(float64(p.interval) * (1 + p.jitter*(2*p.rand()-1)))
+	p.next = start.Add(gap)
+	p.mu.Unlock()
+
+	wait := start.Sub(now)
+	if wait <= 0 {
+		return 0, nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return wait, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
@@ -0,0 +1,72 @@
+package forward
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewPacer_Invalid(t *testing.T) {
+	if _, err := NewPacer(0, 0.2); err == nil {
+		t.Error("expected error for zero rate")
+	}
+	if _, err := NewPacer(10, 1.5); err == nil {
+		t.Error("expected error for jitter above 1")
+	}
+}
+
+func TestPacer_Spacing(t *testing.T) {
+	p, err := NewPacer(50, 0) // 20ms apart
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := p.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Errorf("4 pushes at 50/s took %v, want >= 60ms", elapsed)
+	}
+}
+
+func TestPacer_Jitter(t *testing.T) {
+	p, err := NewPacer(10, 0.5) // 100ms ±50ms
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.rand = func() float64 { return 0 }
+
+	// first wait: 0 * jitter; next gap: interval * (1 - jitter)
+	now := time.Now()
+	if wait, _ := p.Wait(context.Background()); wait != 0 {
+		t.Errorf("first wait = %v, want 0", wait)
+	}
+	if gap := p.next.Sub(now); gap < 45*time.Millisecond || gap > 55*time.Millisecond+time.Since(now) {
+		t.Errorf("gap = %v, want ~50ms", gap)
+	}
+
+	p2, _ := NewPacer(10, 0.5)
+	p2.rand = func() float64 { return 1 }
+	wait, _ := p2.Wait(context.Background())
+	if wait < 45*time.Millisecond || wait > 55*time.Millisecond {
+		t.Errorf("first wait = %v, want ~50ms (full jitter)", wait)
+	}
+}
+
+func TestPacer_ContextCancel(t *testing.T) {
+	p, err := NewPacer(0.1, 0) // 10s apart
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := p.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := p.Wait(ctx); err == nil {
+		t.Error("expected context error")
+	}
+}
@@ -0,0 +1,68 @@
+package forward
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encodeLokiProtobuf builds a snappy-compressed logproto.PushRequest with a
+// single stream, the native Loki push wire format.
+func encodeLokiProtobuf(labels map[string]string, lines []TimestampedLine) []byte {
+	var stream []byte
+	stream = protowire.AppendTag(stream, 1, protowire.BytesType) // StreamAdapter.labels
+	stream = protowire.AppendString(stream, formatLabels(labels))
+
+	var entry, ts []byte
+	for _, l := range lines {
+		ts = ts[:0]
+		if sec := l.Timestamp.Unix(); sec != 0 {
+			ts = protowire.AppendTag(ts, 1, protowire.VarintType) // Timestamp.seconds
+			ts = protowire.AppendVarint(ts, uint64(sec))
+		}
+		if nsec := l.Timestamp.Nanosecond(); nsec != 0 {
+			ts = protowire.AppendTag(ts, 2, protowire.VarintType) // Timestamp.nanos
+			ts = protowire.AppendVarint(ts, uint64(nsec))
+		}
+
+		entry = entry[:0]
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType) // EntryAdapter.timestamp
+		entry = protowire.AppendBytes(entry, ts)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType) // EntryAdapter.line
+		entry = protowire.AppendString(entry, l.Line)
+
+		stream = protowire.AppendTag(stream, 2, protowire.BytesType) // StreamAdapter.entries
+		stream = protowire.AppendBytes(stream, entry)
+	}
+
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType) // PushRequest.streams
+	req = protowire.AppendBytes(req, stream)
+	return snappy.Encode(nil, req)
+}
+
+// formatLabels renders labels in Prometheus form, e.g. {app="api", ns="prod"},
+// with names sorted so identical label sets encode identically.
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func TestFormatLabels(t *testing.T) {
+	got := formatLabels(map[string]string{"pod": "api-0", "app": `say "hi"`})
+	want := `{app="say \"hi\"", pod="api-0"}`
+	if got != want {
+		t.Errorf("formatLabels = %s, want %s", got, want)
+	}
+	if got := formatLabels(nil); got != "{}" {
+		t.Errorf("formatLabels(nil) = %s, want {}", got)
+	}
+}
+
+func TestPush_Protobuf(t *testing.T) {
+	var gotType string
+	var body []byte
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			gotType = r.Header.Get("Content-Type")
+			body, _ = io.ReadAll(r.Body)
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+
+	p := NewPusherWithClient("receiver:3100", client)
+	if err := p.SetEncoding(EncodingProtobuf); err != nil {
+		t.Fatal(err)
+	}
+
+	labels := map[string]string{"namespace": "default", "pod": "api-gw-abc"}
+	lines := []TimestampedLine{
+		{Timestamp: time.Unix(1700000000, 123), Line: "hello world"},
+		{Timestamp: time.Unix(0, 0), Line: "epoch"},
+	}
+	if err := p.Push(context.Background(), labels, lines); err != nil {
+		t.Fatal(err)
+	}
+	if gotType != "application/x-protobuf" {
+		t.Errorf("content-type = %q, want application/x-protobuf", gotType)
+	}
+
+	req, err := recv.ParseLokiProtobuf(body)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(req.Streams) != 1 || len(req.Streams[0].Values) != 2 {
+		t.Fatalf("streams = %+v", req.Streams)
+	}
+	s := req.Streams[0]
+	if s.Stream["pod"] != "api-gw-abc" || s.Stream["namespace"] != "default" {
+		t.Errorf("labels = %v", s.Stream)
+	}
+	if s.Values[0][0] != "1700000000000000123" || s.Values[0][1] != "hello world" {
+		t.Errorf("values[0] = %v", s.Values[0])
+	}
+	if s.Values[1][0] != "0" || s.Values[1][1] != "epoch" {
+		t.Errorf("values[1] = %v", s.Values[1])
+	}
+}
+
+func TestPush_ProtobufFallsBackToJSON(t *testing.T) {
+	var types []string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			ct := r.Header.Get("Content-Type")
+			types = append(types, ct)
+			status := http.StatusNoContent
+			if ct != "application/json" {
+				status = http.StatusBadRequest
+			}
+			return &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+
+	p := NewPusherWithClient("receiver:3100", client)
+	p.SetMaxRetries(1)
+	_ = p.SetEncoding(EncodingProtobuf)
+
+	lines := []TimestampedLine{{Timestamp: time.Unix(1, 0), Line: "x"}}
+	if err := p.Push(context.Background(), map[string]string{"a": "b"}, lines); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if len(types) != 2 || types[0] != "application/x-protobuf" || types[1] != "application/json" {
+		t.Errorf("content types = %v, want protobuf then json", types)
+	}
+	if p.Encoding() != EncodingJSON {
+		t.Errorf("encoding = %s, want json after fallback", p.Encoding())
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for _, s := range []string{EncodingJSON, EncodingProtobuf} {
+		if got, err := ParseEncoding(s); err != nil || got != s {
+			t.Errorf("ParseEncoding(%q) = %q, %v", s, got, err)
+		}
+	}
+	if _, err := ParseEncoding("avro"); err == nil {
+		t.Error("expected error for unknown encoding")
+	}
+}
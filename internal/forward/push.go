@@ -5,11 +5,15 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
 )
 
 const (
@@ -19,6 +23,18 @@ const (
 	pushPath          = "/loki/api/v1/push"
 )
 
+// TenantHeader carries the tenant ID to a multi-tenant Loki.
+const TenantHeader = "X-Scope-OrgID"
+
+// Push body encodings.
+const (
+	// EncodingJSON is the Loki push API JSON format, accepted by every receiver.
+	EncodingJSON = "json"
+	// EncodingProtobuf is snappy-compressed logproto protobuf, the native
+	// Loki wire format, several times smaller than JSON.
+	EncodingProtobuf = "protobuf"
+)
+
 // TimestampedLine is a single log line with its timestamp.
 type TimestampedLine struct {
 	Timestamp time.Time
@@ -40,8 +56,16 @@ type Pusher struct {
 	target     string
 	client     *http.Client
 	maxRetries int
-	maxBackoff time.Duration
+	retry      Backoff
+	breaker    *Breaker
 	onRetry    func()
+	encoding   string
+	authToken  string
+	tenant     string
+
+	clientHeaders  []string // logtypes.ClientInfo pairs sent with every push
+	onIncompatible func(receiverProtocol int)
+	warned         bool
 }
 
 // NewPusher creates a Pusher targeting the given receiver address.
@@ -53,13 +77,17 @@ func NewPusher(target string) *Pusher {
 // NewTLSPusher creates a Pusher with TLS support.
 // Set skipVerify to true for self-signed certificates.
 func NewTLSPusher(target string, skipVerify bool) *Pusher {
+	return NewTLSConfigPusher(target, &tls.Config{
+		InsecureSkipVerify: skipVerify, //nolint:gosec // user-controlled flag for self-signed certs
+	})
+}
+
+// NewTLSConfigPusher creates a Pusher that dials https:// targets with cfg,
+// such as a pinned config from ClientTLSConfig.
+func NewTLSConfigPusher(target string, cfg *tls.Config) *Pusher {
 	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: skipVerify, //nolint:gosec // user-controlled flag for self-signed certs
-			},
-		},
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: cfg},
 	}
 	return NewPusherWithClient(target, client)
 }
@@ -73,7 +101,8 @@ func NewPusherWithClient(target string, client *http.Client) *Pusher {
 		target:     target,
 		client:     client,
 		maxRetries: defaultMaxRetries,
-		maxBackoff: defaultMaxBackoff,
+		retry:      DefaultBackoff,
+		encoding:   EncodingJSON,
 	}
 }
 
@@ -81,17 +110,63 @@ func NewPusherWithClient(target string, client *http.Client) *Pusher {
 func (p *Pusher) SetMaxRetries(n int) { p.maxRetries = n }
 
 // SetMaxBackoff sets the maximum backoff duration between retries.
-func (p *Pusher) SetMaxBackoff(d time.Duration) { p.maxBackoff = d }
+func (p *Pusher) SetMaxBackoff(d time.Duration) { p.retry.Max = d }
+
+// SetBackoff sets the retry delay schedule.
+func (p *Pusher) SetBackoff(b Backoff) { p.retry = b }
+
+// SetBreaker makes pushes fail fast with ErrCircuitOpen while b is open.
+// Pushes that exhaust their retries count as failures; any response from
+// the receiver, even a client error, counts as success.
+func (p *Pusher) SetBreaker(b *Breaker) { p.breaker = b }
 
 // SetOnRetry sets a callback invoked on each retry attempt.
 func (p *Pusher) SetOnRetry(fn func()) { p.onRetry = fn }
 
-// Push sends a batch of log lines with the given labels to the receiver.
-// Returns ErrBufferExceeded if the serialized payload exceeds 1MB.
-// Retries transient errors up to 3 times with exponential backoff.
-func (p *Pusher) Push(ctx context.Context, labels map[string]string, lines []TimestampedLine) error {
-	if len(lines) == 0 {
-		return nil
+// SetAuthToken sends token as a bearer token with every push, for
+// receivers started with --auth-token.
+func (p *Pusher) SetAuthToken(token string) { p.authToken = token }
+
+// SetTenant sends id as the X-Scope-OrgID header with every push, for
+// multi-tenant Loki.
+func (p *Pusher) SetTenant(id string) { p.tenant = id }
+
+// SetClientInfo identifies the client build to the receiver with every push.
+func (p *Pusher) SetClientInfo(info logtypes.ClientInfo) { p.clientHeaders = info.Pairs() }
+
+// SetOnIncompatible sets a callback invoked once when the receiver answers
+// with a push protocol this build cannot speak.
+func (p *Pusher) SetOnIncompatible(fn func(receiverProtocol int)) { p.onIncompatible = fn }
+
+// SetEncoding selects the push body encoding (EncodingJSON or
+// EncodingProtobuf). A receiver that rejects protobuf with HTTP 400 or 415
+// switches the pusher back to JSON for the rest of its life.
+func (p *Pusher) SetEncoding(enc string) error {
+	enc, err := ParseEncoding(enc)
+	if err != nil {
+		return err
+	}
+	p.encoding = enc
+	return nil
+}
+
+// ParseEncoding validates a push encoding name.
+func ParseEncoding(s string) (string, error) {
+	switch s {
+	case EncodingJSON, EncodingProtobuf:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown push encoding %q (want %s or %s)", s, EncodingJSON, EncodingProtobuf)
+}
+
+// Encoding returns the push body encoding currently in use.
+func (p *Pusher) Encoding() string { return p.encoding }
+
+// encode serializes a batch in the pusher's encoding and returns the body
+// and its content type.
+func (p *Pusher) encode(labels map[string]string, lines []TimestampedLine) ([]byte, string, error) {
+	if p.encoding == EncodingProtobuf {
+		return encodeLokiProtobuf(labels, lines), "application/x-protobuf", nil
 	}
 
 	values := make([][]string, len(lines))
@@ -107,7 +182,40 @@ func (p *Pusher) Push(ctx context.Context, labels map[string]string, lines []Tim
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("marshal push request: %w", err)
+		return nil, "", fmt.Errorf("marshal push request: %w", err)
+	}
+	return body, "application/json", nil
+}
+
+// Push sends a batch of log lines with the given labels to the receiver.
+// Returns ErrBufferExceeded if the serialized payload exceeds 1MB, and
+// ErrCircuitOpen without trying while the circuit breaker is open.
+// Retries transient errors and HTTP 429 up to 3 times with exponential
+// backoff.
+func (p *Pusher) Push(ctx context.Context, labels map[string]string, lines []TimestampedLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	if p.breaker == nil {
+		return p.push(ctx, labels, lines)
+	}
+	if err := p.breaker.Allow(); err != nil {
+		return err
+	}
+	err := p.push(ctx, labels, lines)
+	switch {
+	case err == nil, errors.Is(err, errClientStatus):
+		p.breaker.Success()
+	case err != ErrBufferExceeded && ctx.Err() == nil:
+		p.breaker.Failure()
+	}
+	return err
+}
+
+func (p *Pusher) push(ctx context.Context, labels map[string]string, lines []TimestampedLine) error {
+	body, contentType, err := p.encode(labels, lines)
+	if err != nil {
+		return err
 	}
 
 	if len(body) > maxBufferBytes {
@@ -117,7 +225,7 @@ func (p *Pusher) Push(ctx context.Context, labels map[string]string, lines []Tim
 	url := buildPushURL(p.target)
 
 	var lastErr error
-	for attempt := range p.maxRetries {
+	for attempt := 0; attempt < p.maxRetries; attempt++ {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
@@ -126,7 +234,16 @@ func (p *Pusher) Push(ctx context.Context, labels map[string]string, lines []Tim
 		if err != nil {
 			return fmt.Errorf("create request: %w", err)
 		}
-		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Content-Type", contentType)
+		if p.authToken != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+p.authToken)
+		}
+		if p.tenant != "" {
+			httpReq.Header.Set(TenantHeader, p.tenant)
+		}
+		for i := 0; i+1 < len(p.clientHeaders); i += 2 {
+			httpReq.Header.Set(p.clientHeaders[i], p.clientHeaders[i+1])
+		}
 
 		resp, err := p.client.Do(httpReq)
 		if err != nil {
@@ -135,36 +252,65 @@ func (p *Pusher) Push(ctx context.Context, labels map[string]string, lines []Tim
 				if p.onRetry != nil {
 					p.onRetry()
 				}
-				backoff(ctx, attempt, p.maxBackoff)
+				p.retry.Wait(ctx, attempt)
 			}
 			continue
 		}
 		_ = resp.Body.Close()
+		p.checkProtocol(resp.Header.Get(logtypes.ProtocolHeader))
 
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 			return nil
 		}
 
-		lastErr = fmt.Errorf("push failed: HTTP %d", resp.StatusCode)
+		if p.encoding == EncodingProtobuf &&
+			(resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnsupportedMediaType) {
+			// older receivers only speak JSON; re-encode and resend
+			p.encoding = EncodingJSON
+			if body, contentType, err = p.encode(labels, lines); err != nil {
+				return err
+			}
+			if len(body) > maxBufferBytes {
+				return ErrBufferExceeded
+			}
+			attempt--
+			continue
+		}
 
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			return lastErr // client error, no retry
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("push failed: HTTP %d: %w", resp.StatusCode, errClientStatus) // no retry
 		}
+		lastErr = fmt.Errorf("push failed: HTTP %d", resp.StatusCode)
 
 		if attempt < p.maxRetries-1 {
 			if p.onRetry != nil {
 				p.onRetry()
 			}
-			backoff(ctx, attempt, p.maxBackoff)
+			p.retry.Wait(ctx, attempt)
 		}
 	}
 
 	return lastErr
 }
 
+// checkProtocol reports an incompatible receiver protocol once.
+func (p *Pusher) checkProtocol(header string) {
+	if p.warned || p.onIncompatible == nil || header == "" {
+		return
+	}
+	if v, err := strconv.Atoi(header); err == nil && !logtypes.ReceiverProtocolSupported(v) {
+		p.warned = true
+		p.onIncompatible(v)
+	}
+}
+
 // ErrBufferExceeded is returned when the serialized payload exceeds the buffer limit.
 var ErrBufferExceeded = fmt.Errorf("payload exceeds %d byte buffer limit", maxBufferBytes)
 
+// errClientStatus marks a 4xx response: the receiver is up but refused the
+// batch, so it is neither retried nor counted against the breaker.
+var errClientStatus = errors.New("rejected by receiver")
+
 // buildPushURL constructs the push endpoint URL from a target address.
 // Targets with an explicit scheme (http:// or https://) are used as-is.
 // Plain host:port targets default to http://.
@@ -183,15 +329,43 @@ func TargetURL(target, path string) string {
 	return "http://" + target + path
 }
 
-func backoff(ctx context.Context, attempt int, maxBackoff time.Duration) {
-	d := time.Duration(1<<uint(attempt)) * time.Second
-	if d > maxBackoff {
-		d = maxBackoff
+// Backoff is an exponential retry schedule: Base doubled per attempt,
+// capped at Max, each delay randomized by ±Jitter (0 to 1) of itself so
+// forwarders failing together do not retry together.
+type Backoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+// DefaultBackoff is 1s, 2s, 4s, ... up to 30s without jitter.
+var DefaultBackoff = Backoff{Base: time.Second, Max: defaultMaxBackoff}
+
+// Delay returns the wait before retry attempt+1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Base
+	for i := 0; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
 	}
-	t := time.NewTimer(d)
+	if b.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + b.Jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+// Wait sleeps for Delay(attempt) or until ctx is done.
+func (b Backoff) Wait(ctx context.Context, attempt int) {
+	t := time.NewTimer(b.Delay(attempt))
 	defer t.Stop()
 	select {
 	case <-t.C:
 	case <-ctx.Done():
 	}
 }
+
+func backoff(ctx context.Context, attempt int, maxBackoff time.Duration) {
+	Backoff{Base: time.Second, Max: maxBackoff}.Wait(ctx, attempt)
+}
@@ -7,9 +7,12 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
 )
 
 type roundTripperFunc func(*http.Request) (*http.Response, error)
@@ -72,6 +75,88 @@ func TestPush_Success(t *testing.T) {
 	}
 }
 
+func TestPush_NanosecondTimestamps(t *testing.T) {
+	// CRI-O and containerd stamp lines with nanoseconds; several lines of a
+	// fast request often share the millisecond
+	ts, _ := ParseLogLine("2024-01-15T10:30:00.123456789Z request done")
+	next, _ := ParseLogLine("2024-01-15T10:30:00.123456790Z response sent")
+
+	body, _, err := NewPusher("receiver:3100").encode(map[string]string{"app": "api"},
+		[]TimestampedLine{{Timestamp: ts, Line: "request done"}, {Timestamp: next, Line: "response sent"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req lokiPushRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatal(err)
+	}
+	values := req.Streams[0].Values
+	if values[0][0] != "1705314600123456789" || values[1][0] != "1705314600123456790" {
+		t.Errorf("timestamps = %s, %s, want nanoseconds kept", values[0][0], values[1][0])
+	}
+}
+
+func TestPush_AuthToken(t *testing.T) {
+	var got []string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			got = append(got, r.Header.Get("Authorization"))
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+
+	p := NewPusherWithClient("receiver:3100", client)
+	lines := []TimestampedLine{{Timestamp: time.Unix(1, 0), Line: "x"}}
+	if err := p.Push(context.Background(), nil, lines); err != nil {
+		t.Fatal(err)
+	}
+	p.SetAuthToken("lt-a3f9.abc")
+	if err := p.Push(context.Background(), nil, lines); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "" || got[1] != "Bearer lt-a3f9.abc" {
+		t.Errorf("Authorization headers = %q, want none then the bearer token", got)
+	}
+}
+
+func TestPush_ClientInfo(t *testing.T) {
+	var got http.Header
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			got = r.Header.Clone()
+			h := make(http.Header)
+			h.Set(logtypes.ProtocolHeader, "-1") // a receiver older than the minimum protocol
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Header:     h,
+			}, nil
+		}),
+	}
+
+	p := NewPusherWithClient("receiver:3100", client)
+	p.SetClientInfo(logtypes.ClientInfo{Name: "logtap-forwarder", Version: "1.4.0", Commit: "abc1234", Protocol: logtypes.ProtocolVersion})
+	var warned []int
+	p.SetOnIncompatible(func(protocol int) { warned = append(warned, protocol) })
+	lines := []TimestampedLine{{Timestamp: time.Unix(1, 0), Line: "x"}}
+	for range 2 {
+		if err := p.Push(context.Background(), nil, lines); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got.Get(logtypes.ClientHeader) != "logtap-forwarder/1.4.0" || got.Get(logtypes.CommitHeader) != "abc1234" ||
+		got.Get(logtypes.ProtocolHeader) != strconv.Itoa(logtypes.ProtocolVersion) {
+		t.Errorf("client headers = %v", got)
+	}
+	if len(warned) != 1 || warned[0] != -1 {
+		t.Errorf("incompatible callbacks = %v, want one for protocol -1", warned)
+	}
+}
+
 func TestPush_ServerError(t *testing.T) {
 	calls := 0
 	client := &http.Client{
@@ -168,6 +253,37 @@ func TestPush_ClientError(t *testing.T) {
 	}
 }
 
+func TestPush_TenantAndRateLimit(t *testing.T) {
+	var tenants []string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			tenants = append(tenants, r.Header.Get(TenantHeader))
+			status := http.StatusNoContent
+			if len(tenants) == 1 {
+				status = http.StatusTooManyRequests
+			}
+			return &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+	p := NewPusherWithClient("loki:3100", client)
+	p.SetBackoff(Backoff{Base: time.Millisecond, Max: time.Millisecond})
+	p.SetTenant("team-a")
+
+	err := p.Push(context.Background(), map[string]string{"app": "web"}, []TimestampedLine{
+		{Timestamp: time.Now(), Line: "test"},
+	})
+	if err != nil {
+		t.Fatalf("Push: %v (429 should be retried)", err)
+	}
+	if len(tenants) != 2 || tenants[0] != "team-a" || tenants[1] != "team-a" {
+		t.Errorf("%s headers = %q, want team-a on both attempts", TenantHeader, tenants)
+	}
+}
+
 func TestPush_ContextCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // cancel immediately
@@ -322,3 +438,63 @@ func TestNewTLSPusher(t *testing.T) {
 		t.Errorf("target = %q, want %q", p.target, "https://receiver:3100")
 	}
 }
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got := b.Delay(attempt); got != want*time.Millisecond {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, want*time.Millisecond)
+		}
+	}
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := b.Delay(1); d < 100*time.Millisecond || d > 300*time.Millisecond {
+			t.Fatalf("jittered Delay(1) = %v, want 100ms-300ms", d)
+		}
+	}
+}
+
+func TestPush_CircuitBreaker(t *testing.T) {
+	calls := 0
+	status := http.StatusInternalServerError
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+	p := NewPusherWithClient("receiver:3100", client)
+	p.SetMaxRetries(1)
+	breaker, err := NewBreaker(2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetBreaker(breaker)
+
+	ctx := context.Background()
+	lines := []TimestampedLine{{Timestamp: time.Now(), Line: "test"}}
+	_ = p.Push(ctx, nil, lines)
+	_ = p.Push(ctx, nil, lines)
+	if err := p.Push(ctx, nil, lines); err != ErrCircuitOpen {
+		t.Fatalf("third push = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 (no request while open)", calls)
+	}
+
+	// a client error means the receiver is up: it does not trip the breaker
+	b2, _ := NewBreaker(1, time.Hour)
+	p.SetBreaker(b2)
+	status = http.StatusBadRequest
+	if err := p.Push(ctx, nil, lines); err == nil {
+		t.Fatal("expected 400 error")
+	}
+	if b2.State() != BreakerClosed {
+		t.Errorf("breaker after 400 = %s, want closed", b2.State())
+	}
+}
@@ -12,6 +12,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
 )
 
 const containerPrefix = "logtap-forwarder-"
@@ -28,6 +30,43 @@ type Reader struct {
 	podName   string
 	namespace string
 	cs        kubernetes.Interface
+	filter    ContainerFilter
+}
+
+// ContainerFilter selects the sibling containers to follow. An empty
+// Include follows every container; Exclude wins over Include.
+type ContainerFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// Allow reports whether the named container passes the filter.
+func (f ContainerFilter) Allow(name string) bool {
+	for _, c := range f.Exclude {
+		if c == name {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, c := range f.Include {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the names passing the filter, in order.
+func (f ContainerFilter) Apply(names []string) []string {
+	var kept []string
+	for _, n := range names {
+		if f.Allow(n) {
+			kept = append(kept, n)
+		}
+	}
+	return kept
 }
 
 // NewReader creates a Reader using in-cluster config.
@@ -48,6 +87,9 @@ func NewReaderFromClient(cs kubernetes.Interface, podName, namespace string) *Re
 	return &Reader{podName: podName, namespace: namespace, cs: cs}
 }
 
+// SetContainerFilter limits FollowAll to the containers passing f.
+func (r *Reader) SetContainerFilter(f ContainerFilter) { r.filter = f }
+
 // DiscoverContainers returns the names of sibling containers (excluding logtap-forwarder ones).
 func (r *Reader) DiscoverContainers(ctx context.Context) ([]string, error) {
 	pod, err := r.cs.CoreV1().Pods(r.namespace).Get(ctx, r.podName, metav1.GetOptions{})
@@ -111,16 +153,21 @@ func ParseLogLine(line string) (time.Time, string) {
 	return ts, line[idx+1:]
 }
 
-// FollowAll discovers containers and follows each in a goroutine.
-// Sends all log lines to out. Returns when context is cancelled.
+// FollowAll discovers containers and follows each passing the container
+// filter in a goroutine. Sends all log lines to out. Returns when context is
+// cancelled.
 func (r *Reader) FollowAll(ctx context.Context, out chan<- LogLine) error {
-	containers, err := r.DiscoverContainers(ctx)
+	discovered, err := r.DiscoverContainers(ctx)
 	if err != nil {
 		return err
 	}
-	if len(containers) == 0 {
+	if len(discovered) == 0 {
 		return fmt.Errorf("no sibling containers found")
 	}
+	containers := r.filter.Apply(discovered)
+	if len(containers) == 0 {
+		return fmt.Errorf("container filter excludes every container (%s)", strings.Join(discovered, ", "))
+	}
 
 	errCh := make(chan error, len(containers))
 	for _, name := range containers {
@@ -135,6 +182,7 @@ func (r *Reader) FollowAll(ctx context.Context, out chan<- LogLine) error {
 }
 
 func (r *Reader) followWithRetry(ctx context.Context, container string, out chan<- LogLine) error {
+	restarts, _ := r.restartCount(ctx, container)
 	for {
 		err := r.Follow(ctx, container, out)
 		if ctx.Err() != nil {
@@ -143,6 +191,13 @@ func (r *Reader) followWithRetry(ctx context.Context, container string, out chan
 		if err != nil && err != io.EOF {
 			fmt.Printf("follow %s: %v, retrying in 2s\n", container, err)
 		}
+		if marker, ok := r.checkRestart(ctx, container, &restarts); ok {
+			select {
+			case out <- marker:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
 		select {
 		case <-time.After(2 * time.Second):
 		case <-ctx.Done():
@@ -150,3 +205,32 @@ func (r *Reader) followWithRetry(ctx context.Context, container string, out chan
 		}
 	}
 }
+
+// checkRestart returns a restart marker line if the container's restart
+// count rose above *last, and records the new count.
+func (r *Reader) checkRestart(ctx context.Context, container string, last *int32) (LogLine, bool) {
+	n, ok := r.restartCount(ctx, container)
+	if !ok || n <= *last {
+		return LogLine{}, false
+	}
+	*last = n
+	return LogLine{
+		Timestamp: time.Now(),
+		Container: container,
+		Line:      logtypes.RestartMarker(container, n),
+	}, true
+}
+
+// restartCount returns the container's restart count from the pod status.
+func (r *Reader) restartCount(ctx context.Context, container string) (int32, bool) {
+	pod, err := r.cs.CoreV1().Pods(r.namespace).Get(ctx, r.podName, metav1.GetOptions{})
+	if err != nil {
+		return 0, false
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == container {
+			return cs.RestartCount, true
+		}
+	}
+	return 0, false
+}
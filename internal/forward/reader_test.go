@@ -13,6 +13,8 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
 )
 
 func TestParseLogLine(t *testing.T) {
@@ -243,6 +245,41 @@ func TestFollowWithRetry_ErrorRetry(t *testing.T) {
 	}
 }
 
+func TestCheckRestart(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app", RestartCount: 2}},
+		},
+	}
+	cs := fake.NewSimpleClientset(pod) //nolint:staticcheck
+	r := NewReaderFromClient(cs, "test-pod", "default")
+	ctx := context.Background()
+
+	last := int32(2)
+	if _, ok := r.checkRestart(ctx, "app", &last); ok {
+		t.Fatal("marker without a restart")
+	}
+
+	pod.Status.ContainerStatuses[0].RestartCount = 3
+	if _, err := cs.CoreV1().Pods("default").UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	marker, ok := r.checkRestart(ctx, "app", &last)
+	if !ok {
+		t.Fatal("no marker after the restart count rose")
+	}
+	if marker.Container != "app" || !logtypes.IsRestartMarker(marker.Line) || last != 3 {
+		t.Errorf("marker = %+v, last = %d", marker, last)
+	}
+	if _, ok := r.checkRestart(ctx, "app", &last); ok {
+		t.Error("second marker for the same restart")
+	}
+	if _, ok := r.checkRestart(ctx, "sidecar", &last); ok {
+		t.Error("marker for a container without status")
+	}
+}
+
 func TestFollowAll_NoContainers(t *testing.T) {
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
@@ -288,3 +325,60 @@ func TestFollowAll(t *testing.T) {
 		t.Errorf("FollowAll returned error: %v", err)
 	}
 }
+
+func TestContainerFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ContainerFilter
+		want   []string
+	}{
+		{"none", ContainerFilter{}, []string{"app", "worker", "istio-proxy"}},
+		{"include", ContainerFilter{Include: []string{"app", "worker"}}, []string{"app", "worker"}},
+		{"exclude", ContainerFilter{Exclude: []string{"istio-proxy"}}, []string{"app", "worker"}},
+		{"exclude wins", ContainerFilter{Include: []string{"app", "istio-proxy"}, Exclude: []string{"istio-proxy"}}, []string{"app"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.Apply([]string{"app", "worker", "istio-proxy"})
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Apply = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowAll_ContainerFilter(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}, {Name: "istio-proxy"}},
+		},
+	}
+	cs := fake.NewSimpleClientset(pod) //nolint:staticcheck
+	r := NewReaderFromClient(cs, "test-pod", "default")
+	r.SetContainerFilter(ContainerFilter{Exclude: []string{"istio-proxy"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	out := make(chan LogLine, 10)
+	if err := r.FollowAll(ctx, out); err != nil {
+		t.Fatalf("FollowAll returned error: %v", err)
+	}
+	close(out)
+	seen := 0
+	for l := range out {
+		seen++
+		if l.Container != "app" {
+			t.Errorf("line from excluded container %q", l.Container)
+		}
+	}
+	if seen == 0 {
+		t.Error("no lines from the included container")
+	}
+
+	r.SetContainerFilter(ContainerFilter{Include: []string{"worker"}})
+	err := r.FollowAll(context.Background(), make(chan LogLine, 1))
+	if err == nil || !strings.Contains(err.Error(), "excludes every container") {
+		t.Errorf("err = %v, want an excludes every container error", err)
+	}
+}
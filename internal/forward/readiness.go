@@ -0,0 +1,89 @@
+package forward
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultReadyWindow is how long a forwarder with a non-empty retry buffer
+// stays ready after its last successful push.
+const DefaultReadyWindow = time.Minute
+
+// Readiness tracks whether the push pipeline is delivering. A forwarder is
+// ready when nothing is waiting for retry, or when a push succeeded within
+// the window — a process can be up while every push fails.
+type Readiness struct {
+	mu          sync.Mutex
+	window      time.Duration
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastErr     string
+	pending     int
+	now         func() time.Time
+}
+
+// ReadinessStatus is a point-in-time view of Readiness.
+type ReadinessStatus struct {
+	Ready       bool      `json:"ready"`
+	Reason      string    `json:"reason,omitempty"`
+	Pending     int       `json:"pending_batches"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastFailure time.Time `json:"last_failure,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// NewReadiness creates a tracker. A zero window uses DefaultReadyWindow.
+func NewReadiness(window time.Duration) *Readiness {
+	if window <= 0 {
+		window = DefaultReadyWindow
+	}
+	return &Readiness{window: window, now: time.Now}
+}
+
+// RecordSuccess notes a push the receiver accepted.
+func (r *Readiness) RecordSuccess() {
+	r.mu.Lock()
+	r.lastSuccess = r.now()
+	r.mu.Unlock()
+}
+
+// RecordFailure notes a push that failed and was buffered or dropped.
+func (r *Readiness) RecordFailure(err error) {
+	r.mu.Lock()
+	r.lastFailure = r.now()
+	if err != nil {
+		r.lastErr = err.Error()
+	}
+	r.mu.Unlock()
+}
+
+// SetPending sets the number of batches waiting in the retry buffer.
+func (r *Readiness) SetPending(n int) {
+	r.mu.Lock()
+	r.pending = n
+	r.mu.Unlock()
+}
+
+// Status reports whether the forwarder is currently delivering.
+func (r *Readiness) Status() ReadinessStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st := ReadinessStatus{
+		Pending:     r.pending,
+		LastSuccess: r.lastSuccess,
+		LastFailure: r.lastFailure,
+		LastError:   r.lastErr,
+	}
+	switch {
+	case r.pending == 0:
+		st.Ready = true
+	case !r.lastSuccess.IsZero() && r.now().Sub(r.lastSuccess) <= r.window:
+		st.Ready = true
+	case r.lastSuccess.IsZero():
+		st.Reason = "batches buffered and no successful push yet"
+	default:
+		st.Reason = "batches buffered and no successful push in " + r.window.String()
+	}
+	return st
+}
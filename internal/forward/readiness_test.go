@@ -0,0 +1,51 @@
+package forward
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	r := NewReadiness(time.Minute)
+	r.now = func() time.Time { return now }
+
+	// fresh forwarder with nothing buffered is ready
+	if st := r.Status(); !st.Ready {
+		t.Fatalf("fresh status = %+v, want ready", st)
+	}
+
+	// failures with a pending buffer and no success yet
+	r.RecordFailure(errors.New("connection refused"))
+	r.SetPending(2)
+	st := r.Status()
+	if st.Ready || st.Reason == "" || st.LastError != "connection refused" || st.Pending != 2 {
+		t.Fatalf("status = %+v, want not ready with reason", st)
+	}
+
+	// a recent success keeps it ready while the buffer drains
+	r.RecordSuccess()
+	now = now.Add(30 * time.Second)
+	if st := r.Status(); !st.Ready {
+		t.Fatalf("status after recent success = %+v, want ready", st)
+	}
+
+	// success too long ago
+	now = now.Add(time.Minute)
+	if st := r.Status(); st.Ready {
+		t.Fatalf("status after stale success = %+v, want not ready", st)
+	}
+
+	// buffer drained
+	r.SetPending(0)
+	if st := r.Status(); !st.Ready {
+		t.Fatalf("status with empty buffer = %+v, want ready", st)
+	}
+}
+
+func TestReadinessDefaultWindow(t *testing.T) {
+	if r := NewReadiness(0); r.window != DefaultReadyWindow {
+		t.Errorf("window = %v, want %v", r.window, DefaultReadyWindow)
+	}
+}
@@ -0,0 +1,159 @@
+package forward
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Sanitizer strips terminal escape sequences and control characters from
+// log lines before they are pushed. Colored application output otherwise
+// ends up in captures as raw escape codes, which break rendering in open and
+// grep and inflate storage. The zero value leaves lines unchanged.
+type Sanitizer struct {
+	ANSI    bool // remove ANSI escape sequences (colors, cursor movement, OSC titles)
+	Control bool // remove C0/C1 control characters other than tab
+}
+
+// ParseSanitize parses a comma-separated list of "ansi", "control", "all",
+// or "off". An empty spec disables sanitization.
+func ParseSanitize(spec string) (Sanitizer, error) {
+	var s Sanitizer
+	for _, part := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(strings.ToLower(part)) {
+		case "", "off", "none":
+		case "ansi":
+			s.ANSI = true
+		case "control":
+			s.Control = true
+		case "all":
+			s.ANSI, s.Control = true, true
+		default:
+			return Sanitizer{}, fmt.Errorf("invalid sanitize mode %q (expected ansi, control, all, or off)", part)
+		}
+	}
+	return s, nil
+}
+
+// Enabled reports whether any sanitization is configured.
+func (s Sanitizer) Enabled() bool {
+	return s.ANSI || s.Control
+}
+
+// String returns the spec that ParseSanitize accepts for s.
+func (s Sanitizer) String() string {
+	switch {
+	case s.ANSI && s.Control:
+		return "all"
+	case s.ANSI:
+		return "ansi"
+	case s.Control:
+		return "control"
+	default:
+		return "off"
+	}
+}
+
+// Clean returns line with the configured characters removed. Lines without
+// any control bytes are returned as is without allocating.
+func (s Sanitizer) Clean(line string) string {
+	if !s.Enabled() || !hasControl(line) {
+		return line
+	}
+
+	var b strings.Builder
+	b.Grow(len(line))
+	for i := 0; i < len(line); {
+		c := line[i]
+		if c == 0x1b && s.ANSI {
+			i += escapeLen(line[i:])
+			continue
+		}
+		if c < utf8.RuneSelf {
+			if s.Control && isControl(rune(c)) {
+				i++
+				continue
+			}
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if r == 0x9b && s.ANSI { // C1 CSI
+			i += size + csiLen(line[i+size:])
+			continue
+		}
+		if s.Control && isControl(r) {
+			i += size
+			continue
+		}
+		b.WriteString(line[i : i+size])
+		i += size
+	}
+	return b.String()
+}
+
+// hasControl reports whether line contains any byte that Clean may remove.
+// C1 controls are encoded in UTF-8 with a 0xC2 lead byte.
+func hasControl(line string) bool {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if (c < 0x20 && c != '\t') || c == 0x7f || c == 0xc2 {
+			return true
+		}
+	}
+	return false
+}
+
+func isControl(r rune) bool {
+	return (r < 0x20 && r != '\t') || (r >= 0x7f && r <= 0x9f)
+}
+
+// escapeLen returns the length of the escape sequence starting with ESC at
+// s[0]. Unterminated sequences run to the end of the line.
+func escapeLen(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch c := s[1]; {
+	case c == '[': // CSI
+		return 2 + csiLen(s[2:])
+	case c == ']' || c == 'P' || c == '_' || c == '^' || c == 'X': // OSC, DCS, APC, PM, SOS: until BEL or ST
+		for i := 2; i < len(s); i++ {
+			if s[i] == 0x07 {
+				return i + 1
+			}
+			if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	case c >= 0x20 && c <= 0x2f: // nF: intermediates then a final byte
+		i := 1
+		for i < len(s) && s[i] >= 0x20 && s[i] <= 0x2f {
+			i++
+		}
+		if i < len(s) {
+			i++
+		}
+		return i
+	case c >= 0x30 && c <= 0x7e: // two-byte Fp/Fe/Fs sequence
+		return 2
+	default:
+		return 1
+	}
+}
+
+// csiLen returns the length of CSI parameters, intermediates, and the final
+// byte in s.
+func csiLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+		if s[i] < 0x20 || s[i] > 0x3f {
+			return i // malformed: stop before the unexpected byte
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,78 @@
+package forward
+
+import "testing"
+
+func TestParseSanitize(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Sanitizer
+	}{
+		{"", Sanitizer{}},
+		{"off", Sanitizer{}},
+		{"ansi", Sanitizer{ANSI: true}},
+		{"control", Sanitizer{Control: true}},
+		{"ansi, control", Sanitizer{ANSI: true, Control: true}},
+		{"ALL", Sanitizer{ANSI: true, Control: true}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSanitize(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSanitize(%q): %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSanitize(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+		if again, _ := ParseSanitize(got.String()); again != got {
+			t.Errorf("String() %q does not round-trip", got.String())
+		}
+	}
+
+	if _, err := ParseSanitize("ansi,colors"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestSanitizerClean(t *testing.T) {
+	ansi := Sanitizer{ANSI: true}
+	control := Sanitizer{Control: true}
+	all := Sanitizer{ANSI: true, Control: true}
+
+	tests := []struct {
+		name string
+		s    Sanitizer
+		in   string
+		want string
+	}{
+		{"disabled", Sanitizer{}, "\x1b[31mred\x1b[0m", "\x1b[31mred\x1b[0m"},
+		{"plain", all, "GET /api 200 ümlaut\ttab", "GET /api 200 ümlaut\ttab"},
+		{"sgr colors", ansi, "\x1b[1;31mERROR\x1b[0m failed", "ERROR failed"},
+		{"cursor and erase", ansi, "\x1b[2K\x1b[1Gprogress 50%", "progress 50%"},
+		{"private mode", ansi, "\x1b[?25lhidden cursor\x1b[?25h", "hidden cursor"},
+		{"osc title bel", ansi, "\x1b]0;my title\x07text", "text"},
+		{"osc hyperlink st", ansi, "\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"charset nF", ansi, "\x1b(Bplain", "plain"},
+		{"two byte", ansi, "\x1b=keypad\x1b>", "keypad"},
+		{"c1 csi", ansi, "\u009b31mred", "red"},
+		{"unterminated csi", ansi, "text\x1b[31", "text"},
+		{"trailing esc", ansi, "text\x1b", "text"},
+		{"ansi keeps control", ansi, "a\rb\x00", "a\rb\x00"},
+		{"control only", control, "\x1b[31mred\r\x00\x7f\u0085", "[31mred"},
+		{"all", all, "\x1b[32mok\x1b[0m\r\n", "ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.Clean(tt.in); got != tt.want {
+				t.Errorf("Clean(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizerCleanNoAlloc(t *testing.T) {
+	s := Sanitizer{ANSI: true, Control: true}
+	line := "2024-01-01T00:00:00Z INFO request served in 12ms"
+	if n := testing.AllocsPerRun(100, func() { _ = s.Clean(line) }); n != 0 {
+		t.Errorf("allocs = %v, want 0 for clean lines", n)
+	}
+}
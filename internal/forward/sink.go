@@ -0,0 +1,89 @@
+package forward
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink is where the forwarder delivers batches. Pusher and StreamPusher are
+// the sinks for a logtap receiver; alternatives are registered with
+// RegisterSink and chosen by URL scheme. A failed Push is buffered and
+// retried by the forwarder, so sinks need not retry themselves.
+type Sink interface {
+	Push(ctx context.Context, labels map[string]string, lines []TimestampedLine) error
+}
+
+// SinkFactory builds a sink from its URL, e.g. kafka://broker:9092/logs.
+type SinkFactory func(u *url.URL) (Sink, error)
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = make(map[string]SinkFactory)
+)
+
+// RegisterSink makes a sink available under a URL scheme. Call it from an
+// init function to compile a sink into the forwarder. Registering the same
+// scheme twice panics.
+func RegisterSink(scheme string, factory SinkFactory) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	if _, dup := sinks[scheme]; dup {
+		panic("forward: sink " + scheme + " registered twice")
+	}
+	sinks[scheme] = factory
+}
+
+// SinkSchemes returns the registered sink schemes, sorted.
+func SinkSchemes() []string {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	schemes := make([]string, 0, len(sinks))
+	for scheme := range sinks {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// OpenSink builds the registered sink for rawURL's scheme.
+func OpenSink(rawURL string) (Sink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sink URL %q: %w", rawURL, err)
+	}
+	sinksMu.RLock()
+	factory, ok := sinks[u.Scheme]
+	sinksMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q (available: %s)", u.Scheme, strings.Join(SinkSchemes(), ", "))
+	}
+	s, err := factory(u)
+	if err != nil {
+		return nil, fmt.Errorf("sink %s: %w", u.Scheme, err)
+	}
+	return s, nil
+}
+
+func init() {
+	RegisterSink("file", newFileSink)
+	RegisterSink("kafka", newKafkaSink)
+	RegisterSink("nats", newNATSSink)
+}
+
+// sinkRecord is one line as alternate sinks write it: the JSONL entry
+// format of a logtap capture, so the output can be read back like one.
+type sinkRecord struct {
+	Timestamp time.Time         `json:"ts"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Message   string            `json:"msg"`
+}
+
+func encodeRecord(labels map[string]string, l TimestampedLine) ([]byte, error) {
+	return json.Marshal(sinkRecord{Timestamp: l.Timestamp, Labels: labels, Message: l.Line})
+}
@@ -0,0 +1,64 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileSink appends lines as JSONL to a local file, e.g. a shared volume a
+// log agent already collects. file:///var/log/app/out.jsonl.
+type fileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func newFileSink(u *url.URL) (Sink, error) {
+	path := u.Path
+	if u.Host != "" && u.Host != "localhost" {
+		// file://out.jsonl parses the name as a host
+		path = u.Host + u.Path
+	}
+	if path == "" {
+		return nil, errors.New("missing path, e.g. file:///var/log/logtap.jsonl")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{f: f}, nil
+}
+
+// Push appends the batch in one write, so a batch is never interleaved
+// with another writer's lines on an O_APPEND file.
+func (s *fileSink) Push(_ context.Context, labels map[string]string, lines []TimestampedLine) error {
+	var buf []byte
+	for _, l := range lines {
+		data, err := encodeRecord(labels, l)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(buf); err != nil {
+		return fmt.Errorf("write %s: %w", s.f.Name(), err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *fileSink) Close(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,326 @@
+package forward
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/kafkawire"
+)
+
+// A minimal Kafka producer for the kafka sink, the write-side counterpart
+// of the receiver's Kafka source. It speaks Metadata v1 and Produce v3,
+// which every broker from 0.11 on accepts, over plaintext TCP and writes
+// uncompressed message format v2 record batches. SASL and TLS are not
+// supported.
+
+const (
+	kafkaAPIProduce  int16 = 0
+	kafkaAPIMetadata int16 = 3
+
+	kafkaTimeout     = 10 * time.Second
+	kafkaMaxResponse = 16 << 20
+)
+
+// kafkaRetriable are the error codes after which the partition leaders are
+// looked up again before the next push.
+var kafkaRetriable = map[int16]string{
+	3: "UNKNOWN_TOPIC_OR_PARTITION",
+	5: "LEADER_NOT_AVAILABLE",
+	6: "NOT_LEADER_OR_FOLLOWER",
+	7: "REQUEST_TIMED_OUT",
+}
+
+func kafkaError(code int16) error {
+	if code == 0 {
+		return nil
+	}
+	if name, ok := kafkaRetriable[code]; ok {
+		return fmt.Errorf("kafka error %d (%s)", code, name)
+	}
+	return fmt.Errorf("kafka error %d", code)
+}
+
+// kafkaSink produces each line as a record to one topic:
+// kafka://broker1:9092,broker2:9092/topic?acks=all&key=pod. Records of a
+// batch go to the partition its key label hashes to, so a pod's lines stay
+// in order.
+type kafkaSink struct {
+	brokers  []string
+	topic    string
+	acks     int16
+	keyLabel string
+
+	mu      sync.Mutex
+	conns   map[string]*kafkawire.Conn
+	leaders []string // broker address by partition; nil until looked up
+}
+
+func newKafkaSink(u *url.URL) (Sink, error) {
+	topic := strings.Trim(u.Path, "/")
+	if topic == "" || strings.Contains(topic, "/") {
+		return nil, fmt.Errorf("invalid topic %q, e.g. kafka://broker:9092/logs", topic)
+	}
+	var brokers []string
+	for _, b := range strings.Split(u.Host, ",") {
+		if b == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			b = net.JoinHostPort(b, "9092")
+		}
+		brokers = append(brokers, b)
+	}
+	if len(brokers) == 0 {
+		return nil, errors.New("missing broker address")
+	}
+	s := &kafkaSink{brokers: brokers, topic: topic, acks: 1, keyLabel: "pod", conns: make(map[string]*kafkawire.Conn)}
+	q := u.Query()
+	switch q.Get("acks") {
+	case "", "1", "leader":
+	case "all", "-1":
+		s.acks = -1
+	default:
+		return nil, fmt.Errorf("invalid acks %q: expected 1 or all", q.Get("acks"))
+	}
+	if k := q.Get("key"); k != "" {
+		s.keyLabel = k
+	}
+	return s, nil
+}
+
+// Push produces the batch as one record batch and waits for the acks.
+func (s *kafkaSink) Push(ctx context.Context, labels map[string]string, lines []TimestampedLine) error {
+	values := make([][]byte, len(lines))
+	for i, l := range lines {
+		data, err := encodeRecord(labels, l)
+		if err != nil {
+			return err
+		}
+		values[i] = data
+	}
+	key := labels[s.keyLabel]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leaders == nil {
+		if err := s.lookupLeaders(ctx); err != nil {
+			return err
+		}
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	partition := int32(h.Sum32() % uint32(len(s.leaders)))
+	leader := s.leaders[partition]
+
+	conn, err := s.conn(ctx, leader)
+	if err != nil {
+		s.leaders = nil
+		return err
+	}
+	var e kafkawire.Encoder
+	e.Int16(-1) // transactional id
+	e.Int16(s.acks)
+	e.Int32(int32(kafkaTimeout / time.Millisecond))
+	e.Int32(1)
+	e.Str(s.topic)
+	e.Int32(1)
+	e.Int32(partition)
+	batch := kafkaRecordBatch([]byte(key), lines, values)
+	e.Int32(int32(len(batch)))
+	e.B = append(e.B, batch...)
+
+	d, err := conn.RoundTrip(kafkaAPIProduce, 3, e.B, kafkaDeadline(ctx))
+	if err != nil {
+		s.drop(leader)
+		return fmt.Errorf("kafka produce to %s: %w", leader, err)
+	}
+	var code int16
+	for t := d.ArrayLen(); t > 0; t-- {
+		d.Str()
+		for p := d.ArrayLen(); p > 0; p-- {
+			d.Int32()
+			if c := d.Int16(); c != 0 {
+				code = c
+			}
+			d.Int64() // base offset
+			d.Int64() // log append time
+		}
+	}
+	if d.Err != nil {
+		s.drop(leader)
+		return fmt.Errorf("kafka produce response: %w", d.Err)
+	}
+	if code != 0 {
+		if _, ok := kafkaRetriable[code]; ok {
+			s.leaders = nil
+		}
+		return fmt.Errorf("kafka produce to %s/%d: %w", s.topic, partition, kafkaError(code))
+	}
+	return nil
+}
+
+// lookupLeaders asks the bootstrap brokers in turn for the topic's
+// partition leaders.
+func (s *kafkaSink) lookupLeaders(ctx context.Context) error {
+	var lastErr error
+	for _, addr := range s.brokers {
+		conn, err := s.conn(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var e kafkawire.Encoder
+		e.Int32(1)
+		e.Str(s.topic)
+		d, err := conn.RoundTrip(kafkaAPIMetadata, 1, e.B, kafkaDeadline(ctx))
+		if err != nil {
+			s.drop(addr)
+			lastErr = err
+			continue
+		}
+		brokers := make(map[int32]string)
+		for n := d.ArrayLen(); n > 0; n-- {
+			id := d.Int32()
+			host := d.Str()
+			port := d.Int32()
+			d.Str() // rack
+			brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+		}
+		d.Int32() // controller
+		var leaders []string
+		var code int16
+		for n := d.ArrayLen(); n > 0; n-- {
+			code = d.Int16()
+			d.Str()
+			d.Bool()
+			for p := d.ArrayLen(); p > 0; p-- {
+				d.Int16()
+				idx := d.Int32()
+				leader := d.Int32()
+				for r := d.ArrayLen(); r > 0; r-- {
+					d.Int32()
+				}
+				for r := d.ArrayLen(); r > 0; r-- {
+					d.Int32()
+				}
+				for int(idx) >= len(leaders) {
+					leaders = append(leaders, "")
+				}
+				leaders[idx] = brokers[leader]
+			}
+		}
+		switch {
+		case d.Err != nil:
+			lastErr = fmt.Errorf("metadata response: %w", d.Err)
+			continue
+		case code != 0:
+			return fmt.Errorf("kafka topic %s: %w", s.topic, kafkaError(code))
+		case len(leaders) == 0 || slices.Contains(leaders, ""):
+			return fmt.Errorf("kafka topic %s: partition leaders not available", s.topic)
+		}
+		s.leaders = leaders
+		return nil
+	}
+	return fmt.Errorf("kafka metadata from %s: %w", strings.Join(s.brokers, ","), lastErr)
+}
+
+func (s *kafkaSink) conn(ctx context.Context, addr string) (*kafkawire.Conn, error) {
+	if c, ok := s.conns[addr]; ok {
+		return c, nil
+	}
+	d := net.Dialer{Timeout: kafkaTimeout}
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("kafka dial %s: %w", addr, err)
+	}
+	c := kafkawire.NewConn(nc, "logtap-forwarder", kafkaMaxResponse)
+	s.conns[addr] = c
+	return c, nil
+}
+
+func (s *kafkaSink) drop(addr string) {
+	if c, ok := s.conns[addr]; ok {
+		_ = c.Close()
+		delete(s.conns, addr)
+	}
+	s.leaders = nil
+}
+
+// Close closes the broker connections.
+func (s *kafkaSink) Close(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for addr := range s.conns {
+		s.drop(addr)
+	}
+	return nil
+}
+
+// kafkaRecordBatch encodes values as an uncompressed v2 record batch with
+// the line timestamps.
+func kafkaRecordBatch(key []byte, lines []TimestampedLine, values [][]byte) []byte {
+	first := lines[0].Timestamp.UnixMilli()
+	maxTS := first
+	var records []byte
+	for i, l := range lines {
+		ts := l.Timestamp.UnixMilli()
+		maxTS = max(maxTS, ts)
+		var r []byte
+		r = append(r, 0) // attributes
+		r = binary.AppendVarint(r, ts-first)
+		r = binary.AppendVarint(r, int64(i))
+		if key == nil {
+			r = binary.AppendVarint(r, -1)
+		} else {
+			r = binary.AppendVarint(r, int64(len(key)))
+			r = append(r, key...)
+		}
+		r = binary.AppendVarint(r, int64(len(values[i])))
+		r = append(r, values[i]...)
+		r = binary.AppendVarint(r, 0) // headers
+		records = binary.AppendVarint(records, int64(len(r)))
+		records = append(records, r...)
+	}
+
+	// the CRC covers attributes through the records
+	var body kafkawire.Encoder
+	body.Int16(0) // attributes: no compression
+	body.Int32(int32(len(lines) - 1))
+	body.Int64(first)
+	body.Int64(maxTS)
+	body.Int64(-1) // producer id
+	body.Int16(-1) // producer epoch
+	body.Int32(-1) // base sequence
+	body.Int32(int32(len(lines)))
+	body.B = append(body.B, records...)
+
+	var e kafkawire.Encoder
+	e.Int64(0) // base offset
+	e.Int32(int32(4 + 1 + 4 + len(body.B)))
+	e.Int32(-1)          // partition leader epoch
+	e.B = append(e.B, 2) // magic
+	e.B = binary.BigEndian.AppendUint32(e.B, crc32.Checksum(body.B, kafkawire.CRC))
+	e.B = append(e.B, body.B...)
+	return e.B
+}
+
+// kafkaDeadline is when a request must finish: kafkaTimeout from now, or
+// the deadline of ctx when that is sooner.
+func kafkaDeadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(kafkaTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return deadline
+}
@@ -0,0 +1,200 @@
+package forward
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const natsTimeout = 10 * time.Second
+
+// natsSubjectLabel matches {label} placeholders in a NATS subject.
+var natsSubjectLabel = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// natsSink publishes each line to a NATS subject over the core text
+// protocol: nats://[user:pass@|token@]host:4222/logs.{namespace}.{pod}.
+// Placeholders in the subject are filled from the batch labels. A PING
+// after each batch confirms the server took it.
+type natsSink struct {
+	addr    string
+	subject string
+	connect []byte
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	max  int // server max_payload
+}
+
+func newNATSSink(u *url.URL) (Sink, error) {
+	subject := strings.Trim(u.Path, "/")
+	if subject == "" {
+		return nil, errors.New("missing subject, e.g. nats://nats:4222/logs.{namespace}")
+	}
+	if strings.ContainsAny(subject, " \t\r\n/") {
+		return nil, fmt.Errorf("invalid subject %q", subject)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	opts := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "logtap-forwarder",
+		"lang":     "go",
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			opts["user"], opts["pass"] = u.User.Username(), pass
+		} else {
+			opts["auth_token"] = u.User.Username()
+		}
+	}
+	connect, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &natsSink{addr: addr, subject: subject, connect: connect}, nil
+}
+
+// Push publishes the batch, one message per line.
+func (s *natsSink) Push(ctx context.Context, labels map[string]string, lines []TimestampedLine) error {
+	subject := natsSubjectLabel.ReplaceAllStringFunc(s.subject, func(m string) string {
+		v := labels[m[1:len(m)-1]]
+		if v == "" {
+			return "_"
+		}
+		// subject tokens are dot separated and may not contain spaces
+		return strings.NewReplacer(".", "_", " ", "_").Replace(v)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.dial(ctx); err != nil {
+			return fmt.Errorf("nats %s: %w", s.addr, err)
+		}
+	}
+	var buf []byte
+	for _, l := range lines {
+		data, err := encodeRecord(labels, l)
+		if err != nil {
+			return err
+		}
+		if s.max > 0 && len(data) > s.max {
+			return fmt.Errorf("nats: line of %d bytes exceeds the server's max_payload %d", len(data), s.max)
+		}
+		buf = fmt.Appendf(buf, "PUB %s %d\r\n", subject, len(data))
+		buf = append(buf, data...)
+		buf = append(buf, "\r\n"...)
+	}
+	buf = append(buf, "PING\r\n"...)
+	s.setDeadline(ctx)
+	if _, err := s.conn.Write(buf); err != nil {
+		s.reset()
+		return fmt.Errorf("nats publish: %w", err)
+	}
+	if err := s.awaitPong(); err != nil {
+		s.reset()
+		return fmt.Errorf("nats publish: %w", err)
+	}
+	return nil
+}
+
+func (s *natsSink) dial(ctx context.Context) error {
+	d := net.Dialer{Timeout: natsTimeout}
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.conn, s.r = conn, bufio.NewReader(conn)
+	s.setDeadline(ctx)
+
+	line, err := s.readLine()
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		s.reset()
+		return fmt.Errorf("no INFO from server: %q %v", line, err)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+		MaxPayload  int  `json:"max_payload"`
+	}
+	if err := json.Unmarshal([]byte(line[len("INFO "):]), &info); err != nil {
+		s.reset()
+		return fmt.Errorf("parse INFO: %w", err)
+	}
+	if info.TLSRequired {
+		s.reset()
+		return errors.New("server requires TLS, which the nats sink does not support")
+	}
+	s.max = info.MaxPayload
+
+	if _, err := fmt.Fprintf(s.conn, "CONNECT %s\r\nPING\r\n", s.connect); err != nil {
+		s.reset()
+		return err
+	}
+	if err := s.awaitPong(); err != nil {
+		s.reset()
+		return err
+	}
+	return nil
+}
+
+// awaitPong reads until the server answers the last PING, replying to its
+// own PINGs and failing on -ERR (bad auth, bad subject).
+func (s *natsSink) awaitPong() error {
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := s.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK and INFO updates need no answer
+	}
+}
+
+func (s *natsSink) readLine() (string, error) {
+	line, err := s.r.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (s *natsSink) setDeadline(ctx context.Context) {
+	deadline := time.Now().Add(natsTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = s.conn.SetDeadline(deadline)
+}
+
+func (s *natsSink) reset() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+	s.conn, s.r = nil, nil
+}
+
+// Close closes the connection.
+func (s *natsSink) Close(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+	return nil
+}
@@ -0,0 +1,435 @@
+package forward
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/kafkawire"
+)
+
+var sinkLabels = map[string]string{"namespace": "payments", "pod": "api-7d9f", "container": "api"}
+
+func sinkLines(msgs ...string) []TimestampedLine {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	lines := make([]TimestampedLine, len(msgs))
+	for i, m := range msgs {
+		lines[i] = TimestampedLine{Timestamp: base.Add(time.Duration(i) * time.Second), Line: m}
+	}
+	return lines
+}
+
+func TestOpenSink(t *testing.T) {
+	if got := SinkSchemes(); !slices.Equal(got, []string{"file", "kafka", "nats"}) {
+		t.Errorf("SinkSchemes = %v", got)
+	}
+	for _, tt := range []struct{ url, want string }{
+		{"redis://cache:6379/logs", "unknown sink"},
+		{"kafka://broker:9092/", "invalid topic"},
+		{"kafka://broker:9092/logs?acks=2", "invalid acks"},
+		{"nats://nats:4222", "missing subject"},
+		{"file://", "missing path"},
+	} {
+		if _, err := OpenSink(tt.url); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("OpenSink(%q) err = %v, want %q", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "logs.jsonl")
+	s, err := OpenSink("file://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := s.Push(ctx, sinkLabels, sinkLines("first", "second")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Push(ctx, sinkLabels, sinkLines("third")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.(interface{ Close(context.Context) error }).Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var r sinkRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if r.Labels["pod"] != "api-7d9f" || r.Timestamp.IsZero() {
+			t.Errorf("record = %+v", r)
+		}
+		msgs = append(msgs, r.Message)
+	}
+	if !slices.Equal(msgs, []string{"first", "second", "third"}) {
+		t.Errorf("messages = %v", msgs)
+	}
+}
+
+// fakeNATS accepts one client and records the subjects and payloads it
+// publishes. Publishing to a subject starting with "deny." gets -ERR.
+type fakeNATS struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	connect string
+	msgs    map[string][]string
+}
+
+func startFakeNATS(t *testing.T) *fakeNATS {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeNATS{ln: ln, msgs: make(map[string][]string)}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeNATS) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_, _ = io.WriteString(conn, `INFO {"server_id":"fake","max_payload":1048576}`+"\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			f.mu.Lock()
+			f.connect = line[len("CONNECT "):]
+			f.mu.Unlock()
+		case line == "PING":
+			_, _ = io.WriteString(conn, "PONG\r\n")
+		case strings.HasPrefix(line, "PUB "):
+			fields := strings.Fields(line)
+			n, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, n+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			if strings.HasPrefix(fields[1], "deny.") {
+				_, _ = io.WriteString(conn, "-ERR 'Permissions Violation for Publish'\r\n")
+				return
+			}
+			f.mu.Lock()
+			f.msgs[fields[1]] = append(f.msgs[fields[1]], string(payload[:n]))
+			f.mu.Unlock()
+		}
+	}
+}
+
+func TestNATSSink(t *testing.T) {
+	f := startFakeNATS(t)
+	s, err := OpenSink("nats://secret@" + f.ln.Addr().String() + "/logs.{namespace}.{pod}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := s.Push(ctx, sinkLabels, sinkLines("a", "b")); err != nil {
+		t.Fatal(err)
+	}
+	// the connection is reused
+	if err := s.Push(ctx, map[string]string{"namespace": "web"}, sinkLines("c")); err != nil {
+		t.Fatal(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.Contains(f.connect, `"auth_token":"secret"`) {
+		t.Errorf("CONNECT = %s, want the token", f.connect)
+	}
+	if got := f.msgs["logs.payments.api-7d9f"]; len(got) != 2 || !strings.Contains(got[0], `"msg":"a"`) {
+		t.Errorf("messages = %v", f.msgs)
+	}
+	if got := f.msgs["logs.web._"]; len(got) != 1 {
+		t.Errorf("missing label: messages = %v", f.msgs)
+	}
+}
+
+func TestNATSSink_Error(t *testing.T) {
+	f := startFakeNATS(t)
+	s, err := OpenSink("nats://" + f.ln.Addr().String() + "/deny.logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Push(context.Background(), sinkLabels, sinkLines("a"))
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("err = %v, want the server's -ERR", err)
+	}
+
+	closed, err := OpenSink("nats://127.0.0.1:1/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := closed.Push(context.Background(), sinkLabels, sinkLines("a")); err == nil {
+		t.Error("expected dial error")
+	}
+}
+
+// fakeKafkaBroker is a single broker leading every partition of one topic.
+// It answers Metadata v1 and Produce v3 and keeps the produced values.
+type fakeKafkaBroker struct {
+	t          *testing.T
+	ln         net.Listener
+	topic      string
+	partitions int
+	errCode    int16
+
+	mu       sync.Mutex
+	produced map[int32][]string
+	keys     map[int32][]string
+}
+
+func startFakeKafkaBroker(t *testing.T, topic string, partitions int) *fakeKafkaBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeKafkaBroker{t: t, ln: ln, topic: topic, partitions: partitions,
+		produced: make(map[int32][]string), keys: make(map[int32][]string)}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeKafkaBroker) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+		d := &kafkawire.Decoder{B: req}
+		apiKey, _ := d.Int16(), d.Int16()
+		corr := d.Int32()
+		d.Str() // client id
+
+		var e kafkawire.Encoder
+		e.Int32(0)
+		e.Int32(corr)
+		switch apiKey {
+		case kafkaAPIMetadata:
+			f.metadata(d, &e)
+		case kafkaAPIProduce:
+			f.produce(d, &e)
+		default:
+			return
+		}
+		binary.BigEndian.PutUint32(e.B, uint32(len(e.B)-4))
+		if _, err := conn.Write(e.B); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeKafkaBroker) metadata(d *kafkawire.Decoder, e *kafkawire.Encoder) {
+	host, portStr, _ := net.SplitHostPort(f.ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	e.Int32(1)
+	e.Int32(7)
+	e.Str(host)
+	e.Int32(int32(port))
+	e.Int16(-1) // rack
+	e.Int32(7)  // controller
+	n := d.ArrayLen()
+	e.Int32(int32(n))
+	for ; n > 0; n-- {
+		topic := d.Str()
+		if topic != f.topic {
+			e.Int16(3)
+			e.Str(topic)
+			e.B = append(e.B, 0)
+			e.Int32(0)
+			continue
+		}
+		e.Int16(0)
+		e.Str(topic)
+		e.B = append(e.B, 0)
+		e.Int32(int32(f.partitions))
+		for p := 0; p < f.partitions; p++ {
+			e.Int16(0)
+			e.Int32(int32(p))
+			e.Int32(7) // leader
+			e.Int32(1)
+			e.Int32(7)
+			e.Int32(1)
+			e.Int32(7)
+		}
+	}
+}
+
+func (f *fakeKafkaBroker) produce(d *kafkawire.Decoder, e *kafkawire.Encoder) {
+	d.Str() // transactional id
+	if acks := d.Int16(); acks != 1 && acks != -1 {
+		f.t.Errorf("acks = %d", acks)
+	}
+	d.Int32()
+	d.ArrayLen()
+	topic := d.Str()
+	d.ArrayLen()
+	partition := d.Int32()
+	batch := d.Take(int(d.Int32()))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.decodeBatch(partition, batch); err != nil {
+		f.t.Errorf("record batch: %v", err)
+	}
+	e.Int32(1)
+	e.Str(topic)
+	e.Int32(1)
+	e.Int32(partition)
+	e.Int16(f.errCode)
+	e.Int64(0)
+	e.Int64(-1)
+	e.Int32(0) // throttle
+}
+
+func (f *fakeKafkaBroker) decodeBatch(partition int32, b []byte) error {
+	d := &kafkawire.Decoder{B: b}
+	d.Int64()
+	if n := int(d.Int32()); n != len(d.B) {
+		return io.ErrUnexpectedEOF
+	}
+	d.Int32()
+	if magic := d.Take(1); magic == nil || magic[0] != 2 {
+		return io.ErrUnexpectedEOF
+	}
+	crc := uint32(d.Int32())
+	if crc32.Checksum(d.B, crc32.MakeTable(crc32.Castagnoli)) != crc {
+		return io.ErrShortBuffer
+	}
+	d.Int16()
+	d.Int32()
+	d.Int64()
+	d.Int64()
+	d.Int64()
+	d.Int16()
+	d.Int32()
+	count := d.Int32()
+	for i := int32(0); i < count; i++ {
+		length, n := binary.Varint(d.B)
+		rec := &kafkawire.Decoder{B: d.Take(n + int(length))[n:]}
+		rec.Take(1)
+		for range 2 { // timestamp and offset deltas
+			_, n := binary.Varint(rec.B)
+			rec.Take(n)
+		}
+		var fields [2]string
+		for j := range fields {
+			l, n := binary.Varint(rec.B)
+			rec.Take(n)
+			fields[j] = string(rec.Take(int(l)))
+		}
+		f.keys[partition] = append(f.keys[partition], fields[0])
+		f.produced[partition] = append(f.produced[partition], fields[1])
+	}
+	return d.Err
+}
+
+func TestKafkaSink(t *testing.T) {
+	f := startFakeKafkaBroker(t, "logs", 3)
+	s, err := OpenSink("kafka://" + f.ln.Addr().String() + "/logs?acks=all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := s.Push(ctx, sinkLabels, sinkLines("one", "two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Push(ctx, sinkLabels, sinkLines("three")); err != nil {
+		t.Fatal(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.produced) != 1 {
+		t.Fatalf("one pod's lines spread over partitions: %v", f.produced)
+	}
+	for p, values := range f.produced {
+		var msgs []string
+		for _, v := range values {
+			var r sinkRecord
+			if err := json.Unmarshal([]byte(v), &r); err != nil {
+				t.Fatalf("value %q: %v", v, err)
+			}
+			msgs = append(msgs, r.Message)
+		}
+		if !slices.Equal(msgs, []string{"one", "two", "three"}) {
+			t.Errorf("messages = %v", msgs)
+		}
+		if f.keys[p][0] != "api-7d9f" {
+			t.Errorf("key = %q, want the pod", f.keys[p][0])
+		}
+	}
+}
+
+func TestKafkaSink_Errors(t *testing.T) {
+	f := startFakeKafkaBroker(t, "logs", 1)
+	missing, err := OpenSink("kafka://" + f.ln.Addr().String() + "/other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := missing.Push(context.Background(), sinkLabels, sinkLines("a")); err == nil || !strings.Contains(err.Error(), "UNKNOWN_TOPIC_OR_PARTITION") {
+		t.Errorf("unknown topic: err = %v", err)
+	}
+
+	f.mu.Lock()
+	f.errCode = 6
+	f.mu.Unlock()
+	s, err := OpenSink("kafka://" + f.ln.Addr().String() + "/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Push(context.Background(), sinkLabels, sinkLines("a")); err == nil || !strings.Contains(err.Error(), "NOT_LEADER") {
+		t.Errorf("produce error: err = %v", err)
+	}
+	if ks := s.(*kafkaSink); ks.leaders != nil {
+		t.Error("leaders kept after NOT_LEADER_OR_FOLLOWER")
+	}
+}
@@ -0,0 +1,274 @@
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	spillPrefix       = "spill-"
+	spillSuffix       = ".jsonl"
+	maxSpillSegment   = 4 << 20 // 4MB
+	spillSegmentRatio = 8       // at least this many segments fit in the cap
+)
+
+// Spill is a size-capped on-disk FIFO of batches, stored as JSONL segment
+// files in a directory. Segments survive forwarder restarts; when the cap
+// is exceeded the oldest segment is dropped.
+type Spill struct {
+	dir      string
+	maxBytes int64
+	segBytes int64
+
+	mu       sync.Mutex
+	segments []spillSegment // oldest first; the last may be active
+	active   *os.File
+	nextSeq  uint64
+	size     int64
+	count    int
+	drops    int64
+}
+
+type spillSegment struct {
+	name  string
+	size  int64
+	count int
+}
+
+type spillBatch struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Lines  []spillLine       `json:"lines"`
+}
+
+type spillLine struct {
+	Timestamp int64  `json:"ts"`
+	Line      string `json:"line"`
+}
+
+// OpenSpill opens (creating if needed) a spill directory holding at most
+// maxBytes of batches. Segments left by a previous run are kept for replay.
+func OpenSpill(dir string, maxBytes int64) (*Spill, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("spill size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create spill dir: %w", err)
+	}
+	s := &Spill{dir: dir, maxBytes: maxBytes, segBytes: min(maxSpillSegment, maxBytes/spillSegmentRatio+1)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read spill dir: %w", err)
+	}
+	for _, e := range entries {
+		seq, ok := spillSeq(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read spill segment: %w", err)
+		}
+		seg := spillSegment{name: e.Name(), size: int64(len(data)), count: bytes.Count(data, []byte{'\n'})}
+		s.segments = append(s.segments, seg)
+		s.size += seg.size
+		s.count += seg.count
+		s.nextSeq = max(s.nextSeq, seq+1)
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].name < s.segments[j].name })
+	return s, nil
+}
+
+// spillSeq parses the sequence number of a segment file name.
+func spillSeq(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, spillPrefix) || !strings.HasSuffix(name, spillSuffix) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, spillPrefix), spillSuffix), 10, 64)
+	return n, err == nil
+}
+
+// Write appends batches to the newest segment, starting a new one when it
+// is full and dropping the oldest segments while over the size cap.
+func (s *Spill) Write(batches ...Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range batches {
+		rec := spillBatch{Labels: b.Labels, Lines: make([]spillLine, len(b.Lines))}
+		for i, l := range b.Lines {
+			rec.Lines[i] = spillLine{Timestamp: l.Timestamp.UnixNano(), Line: l.Line}
+		}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("encode spill batch: %w", err)
+		}
+		data = append(data, '\n')
+
+		if s.active == nil || s.activeFull(len(data)) {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		}
+		if _, err := s.active.Write(data); err != nil {
+			return fmt.Errorf("write spill segment: %w", err)
+		}
+		seg := &s.segments[len(s.segments)-1]
+		seg.size += int64(len(data))
+		seg.count++
+		s.size += int64(len(data))
+		s.count++
+	}
+
+	// drop oldest closed segments while over the cap
+	for s.size > s.maxBytes && len(s.segments) > 1 {
+		n, err := s.removeOldest()
+		if err != nil {
+			return err
+		}
+		s.drops += int64(n)
+	}
+	return nil
+}
+
+// activeFull reports whether a record of n bytes would push a non-empty
+// active segment past the segment size; s.mu must be held.
+func (s *Spill) activeFull(n int) bool {
+	seg := s.segments[len(s.segments)-1]
+	return seg.size > 0 && seg.size+int64(n) > s.segBytes
+}
+
+// rotate closes the active segment and opens a new one; s.mu must be held.
+func (s *Spill) rotate() error {
+	if err := s.closeActive(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s%020d%s", spillPrefix, s.nextSeq, spillSuffix)
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("create spill segment: %w", err)
+	}
+	s.nextSeq++
+	s.active = f
+	s.segments = append(s.segments, spillSegment{name: name})
+	return nil
+}
+
+func (s *Spill) closeActive() error {
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Close()
+	s.active = nil
+	if err != nil {
+		return fmt.Errorf("close spill segment: %w", err)
+	}
+	return nil
+}
+
+// removeOldest deletes the oldest segment and returns its batch count;
+// s.mu must be held.
+func (s *Spill) removeOldest() (int, error) {
+	if len(s.segments) == 1 {
+		if err := s.closeActive(); err != nil {
+			return 0, err
+		}
+	}
+	seg := s.segments[0]
+	if err := os.Remove(filepath.Join(s.dir, seg.name)); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("remove spill segment: %w", err)
+	}
+	s.segments = s.segments[1:]
+	s.size -= seg.size
+	s.count -= seg.count
+	return seg.count, nil
+}
+
+// ReadOldest removes the oldest segment and returns its batches. A torn
+// final record (a crash mid-write) is skipped; batches after a read error
+// are counted as dropped.
+func (s *Spill) ReadOldest() ([]Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.segments) == 0 {
+		return nil, nil
+	}
+	if len(s.segments) == 1 {
+		if err := s.closeActive(); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, s.segments[0].name))
+	if err != nil {
+		return nil, fmt.Errorf("open spill segment: %w", err)
+	}
+	var batches []Batch
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 2*maxBufferBytes)
+	for sc.Scan() {
+		var rec spillBatch
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			s.drops++
+			continue
+		}
+		b := Batch{Labels: rec.Labels, Lines: make([]TimestampedLine, len(rec.Lines))}
+		for i, l := range rec.Lines {
+			b.Lines[i] = TimestampedLine{Timestamp: time.Unix(0, l.Timestamp), Line: l.Line}
+		}
+		b.Size = EstimateBatchSize(b.Labels, b.Lines)
+		batches = append(batches, b)
+	}
+	scanErr := sc.Err()
+	_ = f.Close()
+
+	// the segment is removed even when unreadable, so one bad file cannot
+	// stall the replay
+	n, err := s.removeOldest()
+	if err != nil {
+		return nil, err
+	}
+	if scanErr != nil {
+		s.drops += int64(max(n-len(batches), 0))
+		return batches, fmt.Errorf("read spill segment: %w", scanErr)
+	}
+	return batches, nil
+}
+
+// Len returns the number of spilled batches.
+func (s *Spill) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Size returns the bytes on disk.
+func (s *Spill) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Drops returns the number of batches dropped because the cap was
+// exceeded or a record was unreadable.
+func (s *Spill) Drops() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.drops
+}
+
+// Close closes the active segment; spilled batches stay on disk.
+func (s *Spill) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeActive()
+}
@@ -0,0 +1,135 @@
+package forward
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func spillTestBatch(i int) Batch {
+	lines := []TimestampedLine{{Timestamp: time.Unix(1700000000, int64(i)), Line: fmt.Sprintf("line %d", i)}}
+	labels := map[string]string{"pod": "api-0"}
+	return Batch{Labels: labels, Lines: lines, Size: EstimateBatchSize(labels, lines)}
+}
+
+func TestSpill_ReopenAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpill(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := s.Write(spillTestBatch(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted forwarder finds the batches and appends after them
+	s, err = OpenSpill(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 3 {
+		t.Fatalf("Len after reopen = %d, want 3", s.Len())
+	}
+	if err := s.Write(spillTestBatch(3)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for s.Len() > 0 {
+		batches, err := s.ReadOldest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range batches {
+			got = append(got, b.Lines[0].Line)
+		}
+	}
+	if strings.Join(got, ",") != "line 0,line 1,line 2,line 3" {
+		t.Errorf("replayed %v, want lines 0-3 in order", got)
+	}
+	if s.Size() != 0 {
+		t.Errorf("Size after replay = %d, want 0", s.Size())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("%d segment files left after replay", len(entries))
+	}
+}
+
+func TestSpill_CapDropsOldestSegment(t *testing.T) {
+	s, err := OpenSpill(t.TempDir(), 800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		if err := s.Write(spillTestBatch(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Size() > 800 {
+		t.Errorf("Size = %d, want <= 800", s.Size())
+	}
+	if s.Drops() == 0 {
+		t.Error("expected drops once over the cap")
+	}
+	if int64(s.Len())+s.Drops() != 30 {
+		t.Errorf("Len %d + Drops %d, want 30", s.Len(), s.Drops())
+	}
+
+	batches, err := s.ReadOldest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batches[0].Lines[0].Line == "line 0" {
+		t.Error("oldest batch survived the cap")
+	}
+}
+
+func TestSpill_TornRecord(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpill(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(spillTestBatch(0)); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+
+	entries, _ := os.ReadDir(dir)
+	f, err := os.OpenFile(filepath.Join(dir, entries[0].Name()), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"labels":{"pod":"ap`)
+	_ = f.Close()
+
+	s, err = OpenSpill(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches, err := s.ReadOldest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || batches[0].Lines[0].Line != "line 0" {
+		t.Errorf("batches = %+v, want the intact record", batches)
+	}
+	if s.Drops() != 1 {
+		t.Errorf("Drops = %d, want 1 for the torn record", s.Drops())
+	}
+}
+
+func TestOpenSpill_InvalidSize(t *testing.T) {
+	if _, err := OpenSpill(t.TempDir(), 0); err == nil {
+		t.Error("expected error for zero size")
+	}
+}
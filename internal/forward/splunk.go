@@ -0,0 +1,282 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Splunk HTTP Event Collector endpoints.
+const (
+	splunkEventPath = "/services/collector/event"
+	splunkAckPath   = "/services/collector/ack"
+)
+
+// DefaultSplunkAckTimeout is how long Send waits for Splunk to confirm
+// that a batch was indexed.
+const DefaultSplunkAckTimeout = 2 * time.Minute
+
+// splunkAckPoll is the first interval between acknowledgement polls.
+var splunkAckPoll = time.Second
+
+// SplunkEvent is one event of a HEC request. Empty metadata fields are
+// left to the token's defaults.
+type SplunkEvent struct {
+	Time       time.Time
+	Host       string
+	Source     string
+	Sourcetype string
+	Index      string
+	Event      string
+	Fields     map[string]string // indexed fields
+}
+
+// MarshalJSON encodes the event in the HEC format, with time as epoch
+// seconds to microseconds, the finest precision Splunk stores.
+func (e SplunkEvent) MarshalJSON() ([]byte, error) {
+	us := e.Time.UnixMicro()
+	return json.Marshal(struct {
+		Time       json.Number       `json:"time"`
+		Host       string            `json:"host,omitempty"`
+		Source     string            `json:"source,omitempty"`
+		Sourcetype string            `json:"sourcetype,omitempty"`
+		Index      string            `json:"index,omitempty"`
+		Event      string            `json:"event"`
+		Fields     map[string]string `json:"fields,omitempty"`
+	}{
+		Time:       json.Number(fmt.Sprintf("%d.%06d", us/1e6, us%1e6)),
+		Host:       e.Host,
+		Source:     e.Source,
+		Sourcetype: e.Sourcetype,
+		Index:      e.Index,
+		Event:      e.Event,
+		Fields:     e.Fields,
+	})
+}
+
+// SplunkClient sends events to a Splunk HTTP Event Collector. With
+// acknowledgement enabled, requests carry a channel ID and a batch counts
+// as sent only once Splunk confirms it was indexed.
+type SplunkClient struct {
+	target     string
+	token      string
+	client     *http.Client
+	maxRetries int
+	retry      Backoff
+	channel    string // set when acknowledgement is enabled
+	ackTimeout time.Duration
+}
+
+// NewSplunkClient creates a client for the collector at target,
+// authenticating with a HEC token.
+func NewSplunkClient(target, token string) *SplunkClient {
+	return NewSplunkClientWithClient(target, token, &http.Client{Timeout: 30 * time.Second})
+}
+
+// NewSplunkClientWithClient creates a client with a custom HTTP client.
+func NewSplunkClientWithClient(target, token string, client *http.Client) *SplunkClient {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &SplunkClient{
+		target:     target,
+		token:      token,
+		client:     client,
+		maxRetries: defaultMaxRetries,
+		retry:      DefaultBackoff,
+		ackTimeout: DefaultSplunkAckTimeout,
+	}
+}
+
+// SetMaxRetries sets the maximum number of attempts per request.
+func (c *SplunkClient) SetMaxRetries(n int) { c.maxRetries = n }
+
+// SetBackoff sets the retry delay schedule.
+func (c *SplunkClient) SetBackoff(b Backoff) { c.retry = b }
+
+// EnableAck turns on indexer acknowledgement, which must also be enabled
+// for the token. Send then waits up to timeout (0 = DefaultSplunkAckTimeout)
+// for each batch to be indexed.
+func (c *SplunkClient) EnableAck(timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultSplunkAckTimeout
+	}
+	channel, err := newChannelID()
+	if err != nil {
+		return fmt.Errorf("create HEC channel: %w", err)
+	}
+	c.channel, c.ackTimeout = channel, timeout
+	return nil
+}
+
+// Send posts events in one request. Requests failing with a network error,
+// HTTP 429 or 5xx are retried with backoff.
+func (c *SplunkClient) Send(ctx context.Context, events []SplunkEvent) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	resp, err := c.do(ctx, splunkEventPath, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("send events: %w", err)
+	}
+	if c.channel == "" {
+		return nil
+	}
+
+	var parsed struct {
+		AckID *int64 `json:"ackId"`
+	}
+	if err := json.Unmarshal(resp, &parsed); err != nil {
+		return fmt.Errorf("send events: decode response: %w", err)
+	}
+	if parsed.AckID == nil {
+		return fmt.Errorf("send events: no ackId in response; indexer acknowledgement is not enabled for the token")
+	}
+	return c.waitAck(ctx, *parsed.AckID)
+}
+
+// waitAck polls until Splunk reports the request id as indexed.
+func (c *SplunkClient) waitAck(ctx context.Context, id int64) error {
+	body, err := json.Marshal(map[string][]int64{"acks": {id}})
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(c.ackTimeout)
+	poll := Backoff{Base: splunkAckPoll, Max: 10 * splunkAckPoll}
+	for attempt := 0; ; attempt++ {
+		poll.Wait(ctx, attempt)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		resp, err := c.do(ctx, splunkAckPath, body)
+		if err != nil {
+			return fmt.Errorf("query ack %d: %w", id, err)
+		}
+		var parsed struct {
+			Acks map[string]bool `json:"acks"`
+		}
+		if err := json.Unmarshal(resp, &parsed); err != nil {
+			return fmt.Errorf("query ack %d: decode response: %w", id, err)
+		}
+		if parsed.Acks[strconv.FormatInt(id, 10)] {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("events not acknowledged as indexed within %s (ack %d)", c.ackTimeout, id)
+		}
+	}
+}
+
+// do posts body to path, retrying network errors, HTTP 429 and 5xx, and
+// returns the response body of a 2xx answer.
+func (c *SplunkClient) do(ctx context.Context, path string, body []byte) ([]byte, error) {
+	url := TargetURL(c.target, path)
+	var lastErr error
+	for attempt := 0; attempt < c.maxRetries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Splunk "+c.token)
+		if c.channel != "" {
+			req.Header.Set("X-Splunk-Request-Channel", c.channel)
+		}
+
+		resp, err := c.client.Do(req)
+		if err == nil {
+			data, rerr := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			switch {
+			case rerr != nil:
+				err = rerr
+			case resp.StatusCode >= 200 && resp.StatusCode < 300:
+				return data, nil
+			case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+				return nil, fmt.Errorf("HTTP %d: %s: %w", resp.StatusCode, errorText(data), errClientStatus) // no retry
+			default:
+				err = fmt.Errorf("HTTP %d: %s", resp.StatusCode, errorText(data))
+			}
+		}
+		lastErr = err
+		if attempt < c.maxRetries-1 {
+			c.retry.Wait(ctx, attempt)
+		}
+	}
+	return nil, lastErr
+}
+
+// newChannelID returns a random UUID, the form HEC requires for channels.
+func newChannelID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// SplunkMapping maps labels onto the metadata of HEC events.
+type SplunkMapping struct {
+	Sourcetype string            // fixed sourcetype, used when no label maps to it
+	Index      string            // fixed index, used when no label maps to it
+	Labels     map[string]string // HEC field (host, source, sourcetype, index) -> label key
+}
+
+// SplunkMetaFields are the event metadata fields labels can be mapped to.
+var SplunkMetaFields = []string{"host", "source", "sourcetype", "index"}
+
+// ParseSplunkMapping parses field=label pairs such as host=pod.
+func ParseSplunkMapping(pairs []string) (map[string]string, error) {
+	out := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		field, label, ok := strings.Cut(p, "=")
+		if !ok || field == "" || label == "" {
+			return nil, fmt.Errorf("invalid mapping %q: expected field=label", p)
+		}
+		if !slices.Contains(SplunkMetaFields, field) {
+			return nil, fmt.Errorf("invalid mapping %q: field must be one of host, source, sourcetype, index", p)
+		}
+		out[field] = label
+	}
+	return out, nil
+}
+
+// Event returns the HEC event of a log line. Labels mapped to metadata
+// fill host, source, sourcetype and index; every label is also sent as an
+// indexed field.
+func (m SplunkMapping) Event(ts time.Time, labels map[string]string, message string) SplunkEvent {
+	meta := func(field, fallback string) string {
+		if key, ok := m.Labels[field]; ok {
+			if v := labels[key]; v != "" {
+				return v
+			}
+		}
+		return fallback
+	}
+	return SplunkEvent{
+		Time:       ts,
+		Host:       meta("host", ""),
+		Source:     meta("source", ""),
+		Sourcetype: meta("sourcetype", m.Sourcetype),
+		Index:      meta("index", m.Index),
+		Event:      message,
+		Fields:     labels,
+	}
+}
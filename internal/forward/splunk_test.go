@@ -0,0 +1,147 @@
+package forward
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplunkClient_Send(t *testing.T) {
+	var (
+		attempts int
+		auth     string
+		events   []map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != splunkEventPath {
+			http.NotFound(w, r)
+			return
+		}
+		attempts++
+		auth = r.Header.Get("Authorization")
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"text":"Server is busy","code":9}`))
+			return
+		}
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			var e map[string]any
+			dec := json.NewDecoder(strings.NewReader(sc.Text()))
+			dec.UseNumber()
+			if err := dec.Decode(&e); err != nil {
+				t.Errorf("event line: %v", err)
+			}
+			events = append(events, e)
+		}
+		_, _ = w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer srv.Close()
+
+	c := NewSplunkClient(srv.URL, "tok")
+	c.SetBackoff(Backoff{Base: time.Millisecond, Max: time.Millisecond})
+	m := SplunkMapping{Sourcetype: "logtap", Labels: map[string]string{"host": "pod", "sourcetype": "app"}}
+	ts := time.Date(2024, 1, 15, 10, 0, 0, 123456789, time.UTC)
+	err := c.Send(context.Background(), []SplunkEvent{
+		m.Event(ts, map[string]string{"pod": "api-0", "app": "api"}, "one"),
+		m.Event(ts, map[string]string{"pod": "api-1"}, "two"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || auth != "Splunk tok" {
+		t.Errorf("attempts = %d, auth = %q; want a retry and the HEC token", attempts, auth)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	first, second := events[0], events[1]
+	if fmt.Sprint(first["time"]) != "1705312800.123456" || first["event"] != "one" || first["host"] != "api-0" || first["sourcetype"] != "api" {
+		t.Errorf("first event = %v", first)
+	}
+	if fields, _ := first["fields"].(map[string]any); fields["pod"] != "api-0" || fields["app"] != "api" {
+		t.Errorf("fields = %v, want every label", first["fields"])
+	}
+	if second["sourcetype"] != "logtap" {
+		t.Errorf("sourcetype without the mapped label = %v, want the fixed one", second["sourcetype"])
+	}
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"text":"Invalid token","code":4}`))
+	}))
+	defer bad.Close()
+	c = NewSplunkClient(bad.URL, "wrong")
+	if err := c.Send(context.Background(), []SplunkEvent{{Time: ts, Event: "x"}}); err == nil || !strings.Contains(err.Error(), "Invalid token") {
+		t.Errorf("err = %v, want the HEC error without retries", err)
+	}
+}
+
+func TestSplunkClient_Ack(t *testing.T) {
+	old := splunkAckPoll
+	splunkAckPoll = time.Millisecond
+	defer func() { splunkAckPoll = old }()
+
+	var (
+		channels = make(map[string]bool)
+		polls    int
+		ackOn    = true
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		channels[r.Header.Get("X-Splunk-Request-Channel")] = true
+		switch r.URL.Path {
+		case splunkEventPath:
+			if !ackOn {
+				_, _ = w.Write([]byte(`{"text":"Success","code":0}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"text":"Success","code":0,"ackId":7}`))
+		case splunkAckPath:
+			var req struct {
+				Acks []int64 `json:"acks"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			polls++
+			_, _ = fmt.Fprintf(w, `{"acks":{"%d":%t}}`, req.Acks[0], polls >= 3)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewSplunkClient(srv.URL, "tok")
+	if err := c.EnableAck(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(context.Background(), []SplunkEvent{{Time: time.Now(), Event: "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if polls != 3 {
+		t.Errorf("polls = %d, want until indexed", polls)
+	}
+	if len(channels) != 1 || channels[""] {
+		t.Errorf("channels = %v, want one channel on every request", channels)
+	}
+
+	ackOn = false
+	err := c.Send(context.Background(), []SplunkEvent{{Time: time.Now(), Event: "x"}})
+	if err == nil || !strings.Contains(err.Error(), "acknowledgement is not enabled") {
+		t.Errorf("err = %v, want acknowledgement not enabled", err)
+	}
+}
+
+func TestParseSplunkMapping(t *testing.T) {
+	m, err := ParseSplunkMapping([]string{"host=pod", "sourcetype=app"})
+	if err != nil || m["host"] != "pod" || m["sourcetype"] != "app" {
+		t.Errorf("ParseSplunkMapping = %v, %v", m, err)
+	}
+	for _, bad := range []string{"host", "host=", "=pod", "message=msg"} {
+		if _, err := ParseSplunkMapping([]string{bad}); err == nil {
+			t.Errorf("ParseSplunkMapping(%q): expected error", bad)
+		}
+	}
+}
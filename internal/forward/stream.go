@@ -0,0 +1,458 @@
+package forward
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+	"github.com/ppiankov/logtap/internal/pushproto"
+)
+
+const (
+	defaultStreamWindow = 64
+	defaultAckTimeout   = 30 * time.Second
+	helloTimeout        = 10 * time.Second
+)
+
+// StreamPusher sends batches over the logtap push stream, a bidirectional
+// gRPC stream to the receiver's --otlp-grpc-listen address. Unlike Pusher
+// it does not wait for a response per batch: up to a window of batches are
+// in flight, each acknowledged by sequence number. Unacknowledged batches
+// are resent after a reconnect and the receiver drops the ones it already
+// wrote, so a broken connection neither loses nor duplicates lines.
+type StreamPusher struct {
+	conn    *grpc.ClientConn
+	session string
+
+	window         int
+	ackTimeout     time.Duration
+	maxRetries     int
+	retry          Backoff
+	breaker        *Breaker
+	onRetry        func()
+	onBackpressure func()
+	authToken      string
+	clientMD       []string // logtypes.ClientInfo pairs as gRPC metadata
+	onIncompatible func(receiverProtocol int)
+	warned         bool
+
+	pushMu sync.Mutex // serializes Push and Close; held while sending
+
+	mu      sync.Mutex
+	seq     uint64
+	pending []streamBatch
+	stream  grpc.ClientStream
+	cancel  context.CancelFunc
+	ackCh   chan struct{}
+}
+
+type streamBatch struct {
+	seq   uint64
+	frame []byte
+}
+
+// NewStreamPusher creates a StreamPusher for target. Targets prefixed with
+// https:// use TLS (skipVerify accepts self-signed certificates); plain
+// host:port and http:// targets are plaintext. No connection is made until
+// the first Push.
+func NewStreamPusher(target string, skipVerify bool) (*StreamPusher, error) {
+	return NewTLSConfigStreamPusher(target, &tls.Config{
+		InsecureSkipVerify: skipVerify, //nolint:gosec // user-controlled flag for self-signed certs
+	})
+}
+
+// NewTLSConfigStreamPusher creates a StreamPusher that dials https://
+// targets with cfg, such as a pinned config from ClientTLSConfig.
+func NewTLSConfigStreamPusher(target string, cfg *tls.Config) (*StreamPusher, error) {
+	creds := insecure.NewCredentials()
+	if rest, ok := strings.CutPrefix(target, "https://"); ok {
+		target = rest
+		creds = credentials.NewTLS(cfg)
+	} else {
+		target = strings.TrimPrefix(target, "http://")
+	}
+	target = strings.TrimRight(target, "/")
+
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(pushproto.Codec{})))
+	if err != nil {
+		return nil, fmt.Errorf("create push stream client: %w", err)
+	}
+	return &StreamPusher{
+		conn:       conn,
+		session:    newStreamSession(),
+		window:     defaultStreamWindow,
+		ackTimeout: defaultAckTimeout,
+		maxRetries: defaultMaxRetries,
+		retry:      DefaultBackoff,
+		ackCh:      make(chan struct{}, 1),
+	}, nil
+}
+
+func newStreamSession() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SetWindow sets the number of unacknowledged batches allowed in flight.
+func (p *StreamPusher) SetWindow(n int) {
+	if n > 0 {
+		p.window = n
+	}
+}
+
+// SetMaxRetries sets the maximum number of reconnect attempts per push.
+func (p *StreamPusher) SetMaxRetries(n int) { p.maxRetries = n }
+
+// SetMaxBackoff sets the maximum backoff duration between reconnects.
+func (p *StreamPusher) SetMaxBackoff(d time.Duration) { p.retry.Max = d }
+
+// SetBackoff sets the reconnect delay schedule.
+func (p *StreamPusher) SetBackoff(b Backoff) { p.retry = b }
+
+// SetBreaker makes pushes fail fast with ErrCircuitOpen while b is open.
+// Pushes whose reconnect attempts are exhausted count as failures.
+func (p *StreamPusher) SetBreaker(b *Breaker) { p.breaker = b }
+
+// SetOnRetry sets a callback invoked on each reconnect attempt.
+func (p *StreamPusher) SetOnRetry(fn func()) { p.onRetry = fn }
+
+// SetOnBackpressure sets a callback invoked each time the receiver reports
+// its write queue full.
+func (p *StreamPusher) SetOnBackpressure(fn func()) { p.onBackpressure = fn }
+
+// SetAuthToken sends token as a bearer token when opening the stream, for
+// receivers started with --auth-token.
+func (p *StreamPusher) SetAuthToken(token string) { p.authToken = token }
+
+// SetClientInfo identifies the client build to the receiver when opening
+// the stream.
+func (p *StreamPusher) SetClientInfo(info logtypes.ClientInfo) {
+	pairs := info.Pairs()
+	for i := 0; i < len(pairs); i += 2 {
+		pairs[i] = strings.ToLower(pairs[i])
+	}
+	p.clientMD = pairs
+}
+
+// SetOnIncompatible sets a callback invoked once when the receiver answers
+// with a push protocol this build cannot speak.
+func (p *StreamPusher) SetOnIncompatible(fn func(receiverProtocol int)) { p.onIncompatible = fn }
+
+// Session returns the stream session ID.
+func (p *StreamPusher) Session() string { return p.session }
+
+// Pending returns the number of batches sent but not yet acknowledged.
+func (p *StreamPusher) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// Push sends a batch of log lines with the given labels. It returns once the
+// batch is on the stream, blocking while the in-flight window is full.
+// Returns ErrBufferExceeded if the encoded batch exceeds 1MB. On error the
+// batch was not accepted and the caller keeps it.
+func (p *StreamPusher) Push(ctx context.Context, labels map[string]string, lines []TimestampedLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	f := pushproto.Frame{Labels: labels, Entries: make([]pushproto.Entry, len(lines))}
+	for i, l := range lines {
+		f.Entries[i] = pushproto.Entry{Line: l.Line}
+		if !l.Timestamp.IsZero() {
+			f.Entries[i].TimeUnixNano = l.Timestamp.UnixNano()
+		}
+	}
+	if len(pushproto.MarshalFrame(f)) > maxBufferBytes {
+		return ErrBufferExceeded
+	}
+
+	p.pushMu.Lock()
+	defer p.pushMu.Unlock()
+
+	if err := p.waitWindow(ctx); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.seq++
+	f.Seq = p.seq
+	batch := streamBatch{seq: f.Seq, frame: pushproto.MarshalFrame(f)}
+	p.pending = append(p.pending, batch)
+	stream := p.stream
+	p.mu.Unlock()
+
+	if stream != nil {
+		if err := stream.SendMsg(&batch.frame); err == nil {
+			return nil
+		}
+		p.dropStream(stream)
+	}
+	// reconnecting resends everything pending, this batch included
+	if err := p.reconnect(ctx); err != nil {
+		p.mu.Lock()
+		p.removePending(batch.seq)
+		p.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// waitWindow blocks until fewer than window batches are unacknowledged.
+// If no ack arrives for the ack timeout the stream is considered stalled
+// and is reconnected.
+func (p *StreamPusher) waitWindow(ctx context.Context) error {
+	timer := time.NewTimer(p.ackTimeout)
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		n := len(p.pending)
+		stream := p.stream
+		p.mu.Unlock()
+		if n < p.window {
+			return nil
+		}
+		if stream == nil {
+			if err := p.reconnect(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		select {
+		case <-p.ackCh:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(p.ackTimeout)
+		case <-timer.C:
+			p.dropStream(stream)
+			timer.Reset(p.ackTimeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// reconnect opens a new stream, learns the receiver's last acked sequence
+// for this session and resends the batches after it, retrying with
+// exponential backoff.
+func (p *StreamPusher) reconnect(ctx context.Context) error {
+	if p.breaker != nil {
+		if err := p.breaker.Allow(); err != nil {
+			return err
+		}
+	}
+	var lastErr error
+	for attempt := 0; attempt < p.maxRetries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if attempt > 0 {
+			if p.onRetry != nil {
+				p.onRetry()
+			}
+			p.retry.Wait(ctx, attempt-1)
+		}
+		if lastErr = p.connect(); lastErr == nil {
+			if p.breaker != nil {
+				p.breaker.Success()
+			}
+			return nil
+		}
+	}
+	if p.breaker != nil && ctx.Err() == nil {
+		p.breaker.Failure()
+	}
+	return fmt.Errorf("push stream: %w", lastErr)
+}
+
+func (p *StreamPusher) connect() error {
+	sctx, cancel := context.WithCancel(context.Background())
+	if p.authToken != "" {
+		sctx = metadata.AppendToOutgoingContext(sctx, "authorization", "Bearer "+p.authToken)
+	}
+	if len(p.clientMD) > 0 {
+		sctx = metadata.AppendToOutgoingContext(sctx, p.clientMD...)
+	}
+	stream, err := p.conn.NewStream(sctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, pushproto.FullMethod)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	hello := pushproto.MarshalFrame(pushproto.Frame{Session: p.session})
+	if err := stream.SendMsg(&hello); err != nil {
+		cancel()
+		return err
+	}
+	stalled := time.AfterFunc(helloTimeout, cancel)
+	var msg []byte
+	err = stream.RecvMsg(&msg)
+	if !stalled.Stop() || err != nil {
+		cancel()
+		if err == nil {
+			err = errors.New("hello ack timed out")
+		}
+		return err
+	}
+	ack, err := pushproto.UnmarshalAck(msg)
+	if err != nil {
+		cancel()
+		return err
+	}
+	if md, err := stream.Header(); err == nil {
+		p.checkProtocol(md.Get(strings.ToLower(logtypes.ProtocolHeader)))
+	}
+
+	p.mu.Lock()
+	p.release(ack.Seq)
+	resend := make([]streamBatch, len(p.pending))
+	copy(resend, p.pending)
+	p.mu.Unlock()
+
+	for i := range resend {
+		if err := stream.SendMsg(&resend[i].frame); err != nil {
+			cancel()
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.stream, p.cancel = stream, cancel
+	p.mu.Unlock()
+	go p.readAcks(stream)
+	return nil
+}
+
+// checkProtocol reports an incompatible receiver protocol once.
+func (p *StreamPusher) checkProtocol(values []string) {
+	if p.warned || p.onIncompatible == nil || len(values) == 0 {
+		return
+	}
+	if v, err := strconv.Atoi(values[0]); err == nil && !logtypes.ReceiverProtocolSupported(v) {
+		p.warned = true
+		p.onIncompatible(v)
+	}
+}
+
+// readAcks releases acknowledged batches until the stream ends.
+func (p *StreamPusher) readAcks(stream grpc.ClientStream) {
+	var msg []byte
+	for {
+		if err := stream.RecvMsg(&msg); err != nil {
+			p.dropStream(stream)
+			return
+		}
+		ack, err := pushproto.UnmarshalAck(msg)
+		if err != nil {
+			p.dropStream(stream)
+			return
+		}
+		if ack.Backpressure && p.onBackpressure != nil {
+			p.onBackpressure()
+		}
+		p.mu.Lock()
+		p.release(ack.Seq)
+		p.mu.Unlock()
+		select {
+		case p.ackCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// dropStream forgets stream if it is still the current one, so the next
+// Push reconnects.
+func (p *StreamPusher) dropStream(stream grpc.ClientStream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream != stream {
+		return
+	}
+	p.cancel()
+	p.stream, p.cancel = nil, nil
+}
+
+// release removes batches up to and including seq; p.mu must be held.
+func (p *StreamPusher) release(seq uint64) {
+	i := 0
+	for i < len(p.pending) && p.pending[i].seq <= seq {
+		i++
+	}
+	p.pending = p.pending[i:]
+}
+
+// removePending removes one batch; p.mu must be held.
+func (p *StreamPusher) removePending(seq uint64) {
+	for i, b := range p.pending {
+		if b.seq == seq {
+			p.pending = append(p.pending[:i], p.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// Close waits until every batch is acknowledged or ctx is done, then closes
+// the connection. It returns an error if batches remain unacknowledged.
+func (p *StreamPusher) Close(ctx context.Context) error {
+	p.pushMu.Lock()
+	defer p.pushMu.Unlock()
+
+	var err error
+	for {
+		p.mu.Lock()
+		n, stream := len(p.pending), p.stream
+		p.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if stream == nil {
+			if err = p.reconnect(ctx); err != nil {
+				break
+			}
+			continue
+		}
+		select {
+		case <-p.ackCh:
+			continue
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		break
+	}
+	if n := p.Pending(); n > 0 {
+		err = fmt.Errorf("push stream closed with %d unacknowledged batches: %w", n, err)
+	}
+
+	p.mu.Lock()
+	if p.stream != nil {
+		_ = p.stream.CloseSend()
+		p.cancel()
+		p.stream, p.cancel = nil, nil
+	}
+	p.mu.Unlock()
+	if cerr := p.conn.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	return err
+}
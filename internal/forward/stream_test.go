@@ -0,0 +1,140 @@
+package forward
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/recv"
+)
+
+func startStreamReceiver(t *testing.T) (string, *recv.LogRing) {
+	t.Helper()
+	w := recv.NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	ring := recv.NewLogRing(0)
+	srv := recv.NewServer(":0", w, nil, nil, nil, ring)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.ServeOTLPGRPC(ln) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+	return ln.Addr().String(), ring
+}
+
+func TestStreamPusher_RoundTrip(t *testing.T) {
+	addr, ring := startStreamReceiver(t)
+	p, err := NewStreamPusher("http://"+addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ts := time.Unix(1700000000, 123)
+	labels := map[string]string{"pod": "api-0"}
+	for i := 0; i < 10; i++ {
+		if err := p.Push(ctx, labels, []TimestampedLine{{Timestamp: ts, Line: "hello"}, {Timestamp: ts, Line: "world"}}); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	entries := ring.Snapshot()
+	if len(entries) != 20 {
+		t.Fatalf("received %d lines, want 20", len(entries))
+	}
+	if !entries[0].Timestamp.Equal(ts) || entries[0].Labels["pod"] != "api-0" || entries[1].Message != "world" {
+		t.Errorf("entry = %+v", entries[0])
+	}
+}
+
+func TestStreamPusher_Resume(t *testing.T) {
+	addr, ring := startStreamReceiver(t)
+	p, err := NewStreamPusher(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	line := []TimestampedLine{{Timestamp: time.Now(), Line: "one"}}
+	if err := p.Push(ctx, nil, line); err != nil {
+		t.Fatal(err)
+	}
+	for p.Pending() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// a batch sent but never acked is resent on the next stream; one already
+	// acked by the receiver must not be written twice
+	p.mu.Lock()
+	p.pending = append(p.pending, streamBatch{seq: 1, frame: nil})
+	stream := p.stream
+	p.mu.Unlock()
+	p.dropStream(stream)
+
+	line[0].Line = "two"
+	if err := p.Push(ctx, nil, line); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	entries := ring.Snapshot()
+	if len(entries) != 2 || entries[1].Message != "two" {
+		t.Errorf("entries = %+v, want one and two", entries)
+	}
+}
+
+func TestStreamPusher_BufferExceeded(t *testing.T) {
+	p, err := NewStreamPusher("127.0.0.1:1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := make([]byte, maxBufferBytes+1)
+	err = p.Push(context.Background(), nil, []TimestampedLine{{Line: string(big)}})
+	if err != ErrBufferExceeded {
+		t.Errorf("err = %v, want ErrBufferExceeded", err)
+	}
+}
+
+func TestStreamPusher_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	p, err := NewStreamPusher(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetMaxRetries(2)
+	p.SetMaxBackoff(10 * time.Millisecond)
+	var retries int
+	p.SetOnRetry(func() { retries++ })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Push(ctx, nil, []TimestampedLine{{Line: "x"}}); err == nil {
+		t.Fatal("expected error for unreachable receiver")
+	}
+	if p.Pending() != 0 {
+		t.Errorf("pending = %d, want failed batch returned to caller", p.Pending())
+	}
+	if retries != 1 {
+		t.Errorf("retries = %d, want 1", retries)
+	}
+	_ = p.Close(ctx)
+}
@@ -0,0 +1,55 @@
+package forward
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParsePin normalizes a SHA-256 certificate fingerprint as printed by
+// logtap recv --tls-self-signed or openssl (colons and case are ignored).
+func ParsePin(pin string) ([]byte, error) {
+	s := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(pin), ":", ""))
+	s = strings.TrimPrefix(s, "sha256")
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != sha256.Size {
+		return nil, fmt.Errorf("expected a SHA-256 fingerprint (64 hex digits), got %q", pin)
+	}
+	return b, nil
+}
+
+// ClientTLSConfig returns the TLS config pushers dial the receiver with.
+// With a pin the receiver's certificate is accepted only when its SHA-256
+// fingerprint matches, whoever signed it, which is how forwarders trust a
+// self-signed receiver without skipping verification. Otherwise skipVerify
+// accepts any certificate.
+func ClientTLSConfig(skipVerify bool, pin string) (*tls.Config, error) {
+	if pin == "" {
+		return &tls.Config{
+			InsecureSkipVerify: skipVerify, //nolint:gosec // user-controlled flag for self-signed certs
+		}, nil
+	}
+	want, err := ParsePin(pin)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		// chain verification is replaced by the fingerprint check below
+		InsecureSkipVerify: true, //nolint:gosec // the peer certificate is pinned
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("receiver sent no certificate")
+			}
+			got := sha256.Sum256(rawCerts[0])
+			if subtle.ConstantTimeCompare(got[:], want) != 1 {
+				return fmt.Errorf("receiver certificate fingerprint %s does not match the pinned one", hex.EncodeToString(got[:]))
+			}
+			return nil
+		},
+	}, nil
+}
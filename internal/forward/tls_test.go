@@ -0,0 +1,63 @@
+package forward
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePin(t *testing.T) {
+	raw := strings.Repeat("ab", 32)
+	colons := strings.ToUpper(strings.TrimSuffix(strings.Repeat("AB:", 32), ":"))
+	for _, pin := range []string{raw, colons, "sha256:" + raw, " " + raw + "\n"} {
+		b, err := ParsePin(pin)
+		if err != nil || hex.EncodeToString(b) != raw {
+			t.Errorf("ParsePin(%q) = %x, %v", pin, b, err)
+		}
+	}
+	for _, pin := range []string{"", "abc", strings.Repeat("zz", 32), strings.Repeat("ab", 20)} {
+		if _, err := ParsePin(pin); err == nil {
+			t.Errorf("ParsePin(%q): expected error", pin)
+		}
+	}
+}
+
+func TestClientTLSConfig_Pin(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	sum := sha256.Sum256(srv.Certificate().Raw)
+	pin := hex.EncodeToString(sum[:])
+
+	get := func(pin string) error {
+		cfg, err := ClientTLSConfig(false, pin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(pin); err != nil {
+		t.Errorf("pinned certificate rejected: %v", err)
+	}
+	if err := get(strings.Repeat("00", 32)); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("mismatched pin: err = %v, want fingerprint mismatch", err)
+	}
+	// without a pin or skip-verify the self-signed test certificate fails
+	if err := get(""); err == nil {
+		t.Error("expected verification error without a pin")
+	}
+
+	if _, err := ClientTLSConfig(false, "nope"); err == nil {
+		t.Error("expected error for an invalid pin")
+	}
+}
@@ -3,8 +3,12 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -16,23 +20,31 @@ type ResourceWarning struct {
 	Message string `json:"message"`
 }
 
-// CheckResources inspects namespace quotas, limit ranges, and node capacity
-// for potential issues when adding a sidecar.
-func CheckResources(ctx context.Context, c *Client, replicas int32, memReq, cpuReq string) ([]ResourceWarning, error) {
+// CheckResources inspects namespace quotas, limit ranges, priority-based
+// preemption, and node capacity for potential issues when adding a sidecar
+// with the given requests and limits to every replica of w. Limit range and
+// priority checks that need the pod template are skipped when w carries none.
+func CheckResources(ctx context.Context, c *Client, w *Workload, memReq, cpuReq, memLimit, cpuLimit string) ([]ResourceWarning, error) {
 	var warnings []ResourceWarning
 
-	qw, err := checkQuotas(ctx, c, replicas, memReq, cpuReq)
+	qw, err := checkQuotas(ctx, c, w.Replicas, memReq, cpuReq)
 	if err != nil {
 		return nil, err
 	}
 	warnings = append(warnings, qw...)
 
-	lw, err := checkLimitRanges(ctx, c, memReq, cpuReq)
+	lw, err := checkLimitRanges(ctx, c, podSpec(w), memReq, cpuReq, memLimit, cpuLimit)
 	if err != nil {
 		return nil, err
 	}
 	warnings = append(warnings, lw...)
 
+	pw, err := checkPreemption(ctx, c, w, memReq, cpuReq)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, pw...)
+
 	nw, err := checkNodeCapacity(ctx, c)
 	if err != nil {
 		return nil, err
@@ -151,37 +163,69 @@ func checkQuotas(ctx context.Context, c *Client, replicas int32, memReq, cpuReq
 	return warnings, nil
 }
 
-func checkLimitRanges(ctx context.Context, c *Client, memReq, cpuReq string) ([]ResourceWarning, error) {
+// checkLimitRanges reports LimitRange constraints the sidecar would violate.
+// Any violation makes the API server reject the new pods, so the rollout
+// stalls with no pods created. spec is the workload's pod template, used for
+// Pod-type limits; it may be nil.
+func checkLimitRanges(ctx context.Context, c *Client, spec *corev1.PodSpec, memReq, cpuReq, memLimit, cpuLimit string) ([]ResourceWarning, error) {
 	ranges, err := c.CS.CoreV1().LimitRanges(c.NS).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("list limitranges: %w", err)
 	}
 
 	var warnings []ResourceWarning
-	sidecarMem := resource.MustParse(memReq)
-	sidecarCPU := resource.MustParse(cpuReq)
+	requests := corev1.ResourceList{
+		corev1.ResourceMemory: resource.MustParse(memReq),
+		corev1.ResourceCPU:    resource.MustParse(cpuReq),
+	}
+	limits := corev1.ResourceList{
+		corev1.ResourceMemory: resource.MustParse(memLimit),
+		corev1.ResourceCPU:    resource.MustParse(cpuLimit),
+	}
+	warn := func(lr, format string, args ...any) {
+		warnings = append(warnings, ResourceWarning{
+			Level:   "warn",
+			Check:   "limitrange",
+			Message: fmt.Sprintf("limitrange %q: ", lr) + fmt.Sprintf(format, args...) + " — pods would be rejected",
+		})
+	}
 
 	for _, lr := range ranges.Items {
 		for _, item := range lr.Spec.Limits {
-			if item.Type != corev1.LimitTypeContainer {
-				continue
-			}
-			if maxMem, ok := item.Max[corev1.ResourceMemory]; ok {
-				if sidecarMem.Cmp(maxMem) > 0 {
-					warnings = append(warnings, ResourceWarning{
-						Level:   "warn",
-						Check:   "limitrange",
-						Message: fmt.Sprintf("limitrange %q: sidecar memory %s exceeds container max %s", lr.Name, sidecarMem.String(), maxMem.String()),
-					})
+			switch item.Type {
+			case corev1.LimitTypeContainer:
+				for _, name := range []corev1.ResourceName{corev1.ResourceMemory, corev1.ResourceCPU} {
+					req, lim := requests[name], limits[name]
+					if maxQ, ok := item.Max[name]; ok && lim.Cmp(maxQ) > 0 {
+						warn(lr.Name, "sidecar %s limit %s exceeds container max %s", name, lim.String(), maxQ.String())
+					}
+					if minQ, ok := item.Min[name]; ok && req.Cmp(minQ) < 0 {
+						warn(lr.Name, "sidecar %s request %s is below container min %s", name, req.String(), minQ.String())
+					}
+					if ratio, ok := item.MaxLimitRequestRatio[name]; ok && req.MilliValue() > 0 {
+						// The limit may be at most ratio × request.
+						allowed := float64(ratio.MilliValue()) / 1000 * float64(req.MilliValue())
+						if float64(lim.MilliValue()) > allowed {
+							warn(lr.Name, "sidecar %s limit/request ratio %s/%s exceeds max ratio %s", name, lim.String(), req.String(), ratio.String())
+						}
+					}
 				}
-			}
-			if maxCPU, ok := item.Max[corev1.ResourceCPU]; ok {
-				if sidecarCPU.Cmp(maxCPU) > 0 {
-					warnings = append(warnings, ResourceWarning{
-						Level:   "warn",
-						Check:   "limitrange",
-						Message: fmt.Sprintf("limitrange %q: sidecar cpu %s exceeds container max %s", lr.Name, sidecarCPU.String(), maxCPU.String()),
-					})
+			case corev1.LimitTypePod:
+				if spec == nil {
+					continue
+				}
+				_, podLim := podTotals(spec)
+				for _, name := range []corev1.ResourceName{corev1.ResourceMemory, corev1.ResourceCPU} {
+					maxQ, ok := item.Max[name]
+					if !ok {
+						continue
+					}
+					current, sidecar := podLim[name], limits[name]
+					total := current.DeepCopy()
+					total.Add(sidecar)
+					if total.Cmp(maxQ) > 0 {
+						warn(lr.Name, "pod %s limits %s + sidecar %s exceed pod max %s", name, current.String(), sidecar.String(), maxQ.String())
+					}
 				}
 			}
 		}
@@ -190,6 +234,168 @@ func checkLimitRanges(ctx context.Context, c *Client, memReq, cpuReq string) ([]
 	return warnings, nil
 }
 
+// podTotals sums the container requests and limits of a pod template.
+// Containers without an explicit value contribute nothing.
+func podTotals(spec *corev1.PodSpec) (requests, limits corev1.ResourceList) {
+	requests, limits = corev1.ResourceList{}, corev1.ResourceList{}
+	for _, ctr := range spec.Containers {
+		for name, q := range ctr.Resources.Requests {
+			total := requests[name]
+			total.Add(q)
+			requests[name] = total
+		}
+		for name, q := range ctr.Resources.Limits {
+			total := limits[name]
+			total.Add(q)
+			limits[name] = total
+		}
+	}
+	return requests, limits
+}
+
+// podSpec returns the pod template of w, or nil when w carries no object.
+func podSpec(w *Workload) *corev1.PodSpec {
+	switch obj := w.Raw.(type) {
+	case *appsv1.Deployment:
+		return &obj.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		return &obj.Spec.Template.Spec
+	case *appsv1.DaemonSet:
+		return &obj.Spec.Template.Spec
+	}
+	return nil
+}
+
+// checkPreemption warns when the extra sidecar requests do not fit in the
+// cluster's free allocatable capacity. Pods whose priority class may preempt
+// will evict lower-priority pods to make room; others stay Pending. Without
+// permission to list pods cluster-wide the check is skipped.
+func checkPreemption(ctx context.Context, c *Client, w *Workload, memReq, cpuReq string) ([]ResourceWarning, error) {
+	spec := podSpec(w)
+	if spec == nil || w.Replicas == 0 {
+		return nil, nil
+	}
+
+	class, err := resolvePriorityClass(ctx, c, spec.PriorityClassName)
+	if err != nil {
+		return nil, err
+	}
+
+	free, ok, err := freeAllocatable(ctx, c)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	needed := corev1.ResourceList{
+		corev1.ResourceMemory: resource.MustParse(memReq),
+		corev1.ResourceCPU:    resource.MustParse(cpuReq),
+	}
+	var short []string
+	for _, name := range []corev1.ResourceName{corev1.ResourceMemory, corev1.ResourceCPU} {
+		need := needed[name]
+		need.SetMilli(need.MilliValue() * int64(w.Replicas))
+		avail := free[name]
+		if need.Cmp(avail) > 0 {
+			short = append(short, fmt.Sprintf("%s needs %s, %s free", name, need.String(), avail.String()))
+		}
+	}
+	if len(short) == 0 {
+		return nil, nil
+	}
+
+	detail := strings.Join(short, "; ")
+	if class != nil && (class.PreemptionPolicy == nil || *class.PreemptionPolicy != corev1.PreemptNever) && class.Value > 0 {
+		return []ResourceWarning{{
+			Level:   "warn",
+			Check:   "priority",
+			Message: fmt.Sprintf("%s/%s: priority class %q (value %d) can preempt and sidecars exceed free capacity (%s) — rollout may evict lower-priority pods", w.Kind, w.Name, class.Name, class.Value, detail),
+		}}, nil
+	}
+	return []ResourceWarning{{
+		Level:   "warn",
+		Check:   "capacity",
+		Message: fmt.Sprintf("%s/%s: sidecars exceed free cluster capacity (%s) — new pods may stay Pending", w.Kind, w.Name, detail),
+	}}, nil
+}
+
+// resolvePriorityClass returns the named priority class, or the global
+// default when name is empty. Returns nil when neither exists.
+func resolvePriorityClass(ctx context.Context, c *Client, name string) (*schedulingv1.PriorityClass, error) {
+	if name != "" {
+		pc, err := c.CS.SchedulingV1().PriorityClasses().Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get priorityclass %s: %w", name, err)
+		}
+		return pc, nil
+	}
+	classes, err := c.CS.SchedulingV1().PriorityClasses().List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list priorityclasses: %w", err)
+	}
+	for i := range classes.Items {
+		if classes.Items[i].GlobalDefault {
+			return &classes.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// freeAllocatable returns schedulable node allocatable minus the requests of
+// pods bound to those nodes. ok is false when nodes or pods cannot be listed.
+func freeAllocatable(ctx context.Context, c *Client) (corev1.ResourceList, bool, error) {
+	nodes, err := c.CS.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("list nodes: %w", err)
+	}
+	if len(nodes.Items) == 0 {
+		return nil, false, nil
+	}
+	pods, err := c.CS.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("list pods: %w", err)
+	}
+
+	free := corev1.ResourceList{}
+	schedulable := make(map[string]bool)
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		schedulable[node.Name] = true
+		for _, name := range []corev1.ResourceName{corev1.ResourceMemory, corev1.ResourceCPU} {
+			total := free[name]
+			total.Add(node.Status.Allocatable[name])
+			free[name] = total
+		}
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !schedulable[pod.Spec.NodeName] || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		requests, _ := podTotals(&pod.Spec)
+		for name, q := range requests {
+			if total, ok := free[name]; ok {
+				total.Sub(q)
+				free[name] = total
+			}
+		}
+	}
+	return free, true, nil
+}
+
 func checkNodeCapacity(ctx context.Context, c *Client) ([]ResourceWarning, error) {
 	nodes, err := c.CS.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
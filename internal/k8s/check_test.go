@@ -6,10 +6,14 @@ import (
 	"strings"
 	"testing"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
 )
@@ -21,7 +25,7 @@ func TestCheckResources_LimitRangeListError(t *testing.T) {
 	})
 	c := NewClientFromInterface(cs, "default")
 
-	_, err := CheckResources(context.Background(), c, 1, "16Mi", "25m")
+	_, err := CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
 	if err == nil {
 		t.Fatal("expected error for limitrange list failure")
 	}
@@ -34,7 +38,7 @@ func TestCheckResources_NodeListError(t *testing.T) {
 	})
 	c := NewClientFromInterface(cs, "default")
 
-	_, err := CheckResources(context.Background(), c, 1, "16Mi", "25m")
+	_, err := CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
 	if err == nil {
 		t.Fatal("expected error for node list failure")
 	}
@@ -47,7 +51,7 @@ func TestCheckResources_QuotaListError(t *testing.T) {
 	})
 	c := NewClientFromInterface(cs, "default")
 
-	_, err := CheckResources(context.Background(), c, 1, "16Mi", "25m")
+	_, err := CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
 	if err == nil {
 		t.Fatal("expected error for quota list failure")
 	}
@@ -69,7 +73,7 @@ func TestCheckResources_TightQuota(t *testing.T) {
 	cs := fake.NewSimpleClientset(quota) //nolint:staticcheck // NewClientset requires generated apply configs
 	c := NewClientFromInterface(cs, "default")
 
-	warnings, err := CheckResources(context.Background(), c, 3, "16Mi", "25m")
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 3}, "16Mi", "25m", "32Mi", "50m")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -104,7 +108,7 @@ func TestCheckResources_Headroom(t *testing.T) {
 	cs := fake.NewSimpleClientset(quota) //nolint:staticcheck // NewClientset requires generated apply configs
 	c := NewClientFromInterface(cs, "default")
 
-	warnings, err := CheckResources(context.Background(), c, 2, "16Mi", "25m")
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 2}, "16Mi", "25m", "32Mi", "50m")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -134,7 +138,7 @@ func TestCheckResources_LimitRangeConflict(t *testing.T) {
 	cs := fake.NewSimpleClientset(lr) //nolint:staticcheck // NewClientset requires generated apply configs
 	c := NewClientFromInterface(cs, "default")
 
-	warnings, err := CheckResources(context.Background(), c, 1, "16Mi", "25m")
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -154,7 +158,7 @@ func TestCheckResources_NoQuotas(t *testing.T) {
 	cs := fake.NewSimpleClientset() //nolint:staticcheck // NewClientset requires generated apply configs
 	c := NewClientFromInterface(cs, "default")
 
-	warnings, err := CheckResources(context.Background(), c, 2, "16Mi", "25m")
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 2}, "16Mi", "25m", "32Mi", "50m")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -265,7 +269,7 @@ func TestCheckResources_CPULimitRange(t *testing.T) {
 	cs := fake.NewSimpleClientset(lr) //nolint:staticcheck // NewClientset requires generated apply configs
 	c := NewClientFromInterface(cs, "default")
 
-	warnings, err := CheckResources(context.Background(), c, 1, "16Mi", "25m")
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -297,7 +301,7 @@ func TestCheckResources_CPUQuota(t *testing.T) {
 	cs := fake.NewSimpleClientset(quota) //nolint:staticcheck // NewClientset requires generated apply configs
 	c := NewClientFromInterface(cs, "default")
 
-	warnings, err := CheckResources(context.Background(), c, 2, "16Mi", "25m")
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 2}, "16Mi", "25m", "32Mi", "50m")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -329,7 +333,7 @@ func TestCheckResources_DiskPressure(t *testing.T) {
 	cs := fake.NewSimpleClientset(node) //nolint:staticcheck // NewClientset requires generated apply configs
 	c := NewClientFromInterface(cs, "default")
 
-	warnings, err := CheckResources(context.Background(), c, 1, "16Mi", "25m")
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -361,7 +365,7 @@ func TestCheckResources_MemoryPressure(t *testing.T) {
 	cs := fake.NewSimpleClientset(node) //nolint:staticcheck // NewClientset requires generated apply configs
 	c := NewClientFromInterface(cs, "default")
 
-	warnings, err := CheckResources(context.Background(), c, 1, "16Mi", "25m")
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -376,3 +380,240 @@ func TestCheckResources_MemoryPressure(t *testing.T) {
 		t.Error("expected capacity warning for memory pressure")
 	}
 }
+
+func warningsFor(warnings []ResourceWarning, check string) []string {
+	var msgs []string
+	for _, w := range warnings {
+		if w.Check == check {
+			msgs = append(msgs, w.Message)
+		}
+	}
+	return msgs
+}
+
+func TestCheckResources_LimitRangeMinAndRatio(t *testing.T) {
+	lr := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "floor", Namespace: "default"},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type: corev1.LimitTypeContainer,
+				Min: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("64Mi"),
+				},
+				MaxLimitRequestRatio: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("1500m"),
+				},
+			}},
+		},
+	}
+	cs := fake.NewSimpleClientset(lr) //nolint:staticcheck // NewClientset requires generated apply configs
+	c := NewClientFromInterface(cs, "default")
+
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := warningsFor(warnings, "limitrange")
+	if len(msgs) != 2 {
+		t.Fatalf("limitrange warnings = %v, want 2", msgs)
+	}
+	if !strings.Contains(msgs[0], "below container min 64Mi") {
+		t.Errorf("min warning = %q", msgs[0])
+	}
+	if !strings.Contains(msgs[1], "ratio 50m/25m exceeds max ratio 1500m") || !strings.Contains(msgs[1], "rejected") {
+		t.Errorf("ratio warning = %q", msgs[1])
+	}
+}
+
+func TestCheckResources_LimitRangeMaxChecksLimit(t *testing.T) {
+	lr := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "cap", Namespace: "default"},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type: corev1.LimitTypeContainer,
+				Max: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("24Mi"),
+				},
+			}},
+		},
+	}
+	cs := fake.NewSimpleClientset(lr) //nolint:staticcheck // NewClientset requires generated apply configs
+	c := NewClientFromInterface(cs, "default")
+
+	// Request fits under the max, but the limit does not.
+	warnings, err := CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := warningsFor(warnings, "limitrange")
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "memory limit 32Mi exceeds container max 24Mi") {
+		t.Errorf("limitrange warnings = %v", msgs)
+	}
+}
+
+func tappableDeployment(priorityClass string, limits corev1.ResourceList) *Workload {
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					PriorityClassName: priorityClass,
+					Containers: []corev1.Container{{
+						Name:      "app",
+						Resources: corev1.ResourceRequirements{Limits: limits},
+					}},
+				},
+			},
+		},
+	}
+	w := workloadFromDeployment(d)
+	w.Replicas = 4
+	return w
+}
+
+func TestCheckResources_LimitRangePodMax(t *testing.T) {
+	lr := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "pods", Namespace: "default"},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type: corev1.LimitTypePod,
+				Max: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("512Mi"),
+					corev1.ResourceCPU:    resource.MustParse("2"),
+				},
+			}},
+		},
+	}
+	cs := fake.NewSimpleClientset(lr) //nolint:staticcheck // NewClientset requires generated apply configs
+	c := NewClientFromInterface(cs, "default")
+
+	w := tappableDeployment("", corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("500Mi")})
+	warnings, err := CheckResources(context.Background(), c, w, "16Mi", "25m", "32Mi", "50m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := warningsFor(warnings, "limitrange")
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "pod memory limits 500Mi + sidecar 32Mi exceed pod max 512Mi") {
+		t.Errorf("limitrange warnings = %v", msgs)
+	}
+
+	// Without a pod template the Pod-type limit cannot be evaluated.
+	warnings, err = CheckResources(context.Background(), c, &Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgs := warningsFor(warnings, "limitrange"); len(msgs) != 0 {
+		t.Errorf("unexpected warnings without template: %v", msgs)
+	}
+}
+
+func fullNode(name string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+				corev1.ResourceCPU:    resource.MustParse("1"),
+			},
+		},
+	}
+}
+
+func busyPod(node string, mem string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "busy-" + node, Namespace: "other"},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{{
+				Name: "busy",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse(mem),
+				}},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestCheckResources_Preemption(t *testing.T) {
+	never := corev1.PreemptNever
+	tests := []struct {
+		name      string
+		class     *schedulingv1.PriorityClass
+		className string
+		used      string
+		check     string
+		want      string
+	}{
+		{
+			name:      "preempting class",
+			class:     &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "critical"}, Value: 1000},
+			className: "critical",
+			used:      "1000Mi",
+			check:     "priority",
+			want:      `priority class "critical" (value 1000) can preempt`,
+		},
+		{
+			name:  "global default",
+			class: &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "high"}, Value: 500, GlobalDefault: true},
+			used:  "1000Mi",
+			check: "priority",
+			want:  "may evict lower-priority pods",
+		},
+		{
+			name:      "never preempts",
+			class:     &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "batch"}, Value: 1000, PreemptionPolicy: &never},
+			className: "batch",
+			used:      "1000Mi",
+			check:     "capacity",
+			want:      "(memory needs 64Mi, 24Mi free) — new pods may stay Pending",
+		},
+		{
+			name:  "room to spare",
+			used:  "100Mi",
+			check: "priority",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{fullNode("n1"), busyPod("n1", tt.used)}
+			if tt.class != nil {
+				objs = append(objs, tt.class)
+			}
+			cs := fake.NewSimpleClientset(objs...) //nolint:staticcheck // NewClientset requires generated apply configs
+			c := NewClientFromInterface(cs, "default")
+
+			w := tappableDeployment(tt.className, nil)
+			warnings, err := CheckResources(context.Background(), c, w, "16Mi", "25m", "32Mi", "50m")
+			if err != nil {
+				t.Fatal(err)
+			}
+			msgs := warningsFor(warnings, tt.check)
+			if tt.want == "" {
+				if len(msgs) != 0 {
+					t.Errorf("unexpected warnings: %v", msgs)
+				}
+				return
+			}
+			if len(msgs) != 1 || !strings.Contains(msgs[0], tt.want) {
+				t.Errorf("%s warnings = %v, want %q", tt.check, msgs, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckResources_PreemptionForbidden(t *testing.T) {
+	cs := fake.NewSimpleClientset(fullNode("n1"), busyPod("n1", "1000Mi")) //nolint:staticcheck // NewClientset requires generated apply configs
+	cs.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", fmt.Errorf("no"))
+	})
+	c := NewClientFromInterface(cs, "default")
+
+	warnings, err := CheckResources(context.Background(), c, tappableDeployment("", nil), "16Mi", "25m", "32Mi", "50m")
+	if err != nil {
+		t.Fatalf("forbidden pod list should skip the check: %v", err)
+	}
+	if msgs := warningsFor(warnings, "capacity"); len(msgs) != 0 {
+		t.Errorf("unexpected capacity warnings: %v", msgs)
+	}
+}
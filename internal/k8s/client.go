@@ -4,8 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -19,10 +24,31 @@ type Client struct {
 	CS         kubernetes.Interface
 	NS         string
 	RestConfig *rest.Config // nil for test clients
+
+	workloads workloadCache
+}
+
+// AuthOptions overrides the identity used for cluster requests.
+// The zero value keeps the credentials from the kubeconfig.
+type AuthOptions struct {
+	As        string   // impersonate this user
+	AsGroups  []string // impersonate these groups (requires As)
+	Token     string   // bearer token, replaces kubeconfig credentials
+	TokenPath string   // file holding a bearer token, re-read on rotation
 }
 
 // NewClient creates a Client from the default kubeconfig.
 func NewClient(namespace string) (*Client, error) {
+	return NewClientWithAuth(namespace, AuthOptions{})
+}
+
+// NewClientWithAuth creates a Client from the default kubeconfig with the
+// given identity overrides applied.
+func NewClientWithAuth(namespace string, auth AuthOptions) (*Client, error) {
+	if err := auth.validate(); err != nil {
+		return nil, err
+	}
+
 	rules := clientcmd.NewDefaultClientConfigLoadingRules()
 	overrides := &clientcmd.ConfigOverrides{}
 	if namespace != "" {
@@ -50,6 +76,7 @@ func NewClient(namespace string) (*Client, error) {
 		}
 		return nil, fmt.Errorf("build kubeconfig: %w", err)
 	}
+	applyAuth(restConfig, auth)
 
 	cs, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
@@ -59,30 +86,203 @@ func NewClient(namespace string) (*Client, error) {
 	return &Client{CS: cs, NS: ns, RestConfig: restConfig}, nil
 }
 
+func (a AuthOptions) validate() error {
+	if len(a.AsGroups) > 0 && a.As == "" {
+		return errors.New("impersonation groups require a user to impersonate")
+	}
+	if a.Token != "" && a.TokenPath != "" {
+		return errors.New("token and token path are mutually exclusive")
+	}
+	if a.TokenPath != "" {
+		if _, err := os.Stat(a.TokenPath); err != nil {
+			return fmt.Errorf("read service-account token: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyAuth replaces the kubeconfig credentials with an explicit token, if
+// given, and sets impersonation. Other credential sources are cleared so a
+// token always wins over client certs or exec plugins.
+func applyAuth(cfg *rest.Config, auth AuthOptions) {
+	if auth.Token != "" || auth.TokenPath != "" {
+		cfg.BearerToken = auth.Token
+		cfg.BearerTokenFile = auth.TokenPath
+		cfg.Username = ""
+		cfg.Password = ""
+		cfg.CertFile = ""
+		cfg.CertData = nil
+		cfg.KeyFile = ""
+		cfg.KeyData = nil
+		cfg.ExecProvider = nil
+		cfg.AuthProvider = nil
+	}
+	if auth.As != "" {
+		cfg.Impersonate = rest.ImpersonationConfig{
+			UserName: auth.As,
+			Groups:   auth.AsGroups,
+		}
+	}
+}
+
 // NewClientFromInterface creates a Client from an existing clientset (for testing).
 func NewClientFromInterface(cs kubernetes.Interface, ns string) *Client {
 	return &Client{CS: cs, NS: ns}
 }
 
-// ClusterInfo holds basic cluster metadata for display.
+// ClusterInfo holds cluster metadata for display and for recording the
+// environment a capture was taken in. Sections the caller may not read
+// (RBAC forbidden) are left empty.
 type ClusterInfo struct {
 	Version   string `json:"version"`
 	Namespace string `json:"namespace"`
+	// APILatencyMs is the round trip of the server version request.
+	APILatencyMs        float64      `json:"api_latency_ms"`
+	Nodes               *NodeSummary `json:"nodes,omitempty"`
+	DefaultStorageClass string       `json:"default_storage_class,omitempty"`
+	// PodSecurity maps namespaces to their Pod Security Admission enforce
+	// level. Namespaces without one are omitted.
+	PodSecurity map[string]string `json:"pod_security,omitempty"`
+	// Errors maps the sections that could not be read (nodes,
+	// storage_class, pod_security) to why; they are left empty.
+	Errors map[string]string `json:"errors,omitempty"`
 }
 
+// NodeSummary counts nodes by readiness and pressure condition.
+type NodeSummary struct {
+	Total          int `json:"total"`
+	Ready          int `json:"ready"`
+	Unschedulable  int `json:"unschedulable,omitempty"`
+	MemoryPressure int `json:"memory_pressure,omitempty"`
+	DiskPressure   int `json:"disk_pressure,omitempty"`
+	PIDPressure    int `json:"pid_pressure,omitempty"`
+}
+
+// Pod Security Admission and default storage class markers.
+const (
+	podSecurityEnforceLabel  = "pod-security.kubernetes.io/enforce"
+	defaultClassAnnotation   = "storageclass.kubernetes.io/is-default-class"
+	defaultClassAnnotationV1 = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
 // isNoConfig returns true when the error indicates no kubeconfig was found.
 func isNoConfig(err error) bool {
 	return err != nil && strings.Contains(err.Error(), "no configuration has been provided")
 }
 
-// GetClusterInfo retrieves the cluster version and current namespace.
+// GetClusterInfo retrieves the cluster version, current namespace, API
+// latency, node health, default storage class and Pod Security levels. Only
+// a failed version request is an error: a section that is forbidden is left
+// empty, and one that fails otherwise is also recorded in Errors.
 func GetClusterInfo(ctx context.Context, c *Client) (*ClusterInfo, error) {
+	start := time.Now()
 	sv, err := c.CS.Discovery().ServerVersion()
 	if err != nil {
 		return nil, fmt.Errorf("get server version: %w", err)
 	}
-	return &ClusterInfo{
-		Version:   sv.GitVersion,
-		Namespace: c.NS,
-	}, nil
+	info := &ClusterInfo{
+		Version:      sv.GitVersion,
+		Namespace:    c.NS,
+		APILatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if info.Nodes, err = nodeSummary(ctx, c); err != nil {
+		info.sectionError("nodes", err)
+	}
+	if info.DefaultStorageClass, err = defaultStorageClass(ctx, c); err != nil {
+		info.sectionError("storage_class", err)
+	}
+	if info.PodSecurity, err = podSecurityLevels(ctx, c); err != nil {
+		info.sectionError("pod_security", err)
+	}
+	return info, nil
+}
+
+func (info *ClusterInfo) sectionError(section string, err error) {
+	if info.Errors == nil {
+		info.Errors = make(map[string]string)
+	}
+	info.Errors[section] = err.Error()
+}
+
+// nodeSummary returns nil when nodes cannot be listed.
+func nodeSummary(ctx context.Context, c *Client) (*NodeSummary, error) {
+	nodes, err := c.CS.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list nodes: %w", err)
+	}
+	sum := &NodeSummary{Total: len(nodes.Items)}
+	for _, n := range nodes.Items {
+		if n.Spec.Unschedulable {
+			sum.Unschedulable++
+		}
+		for _, cond := range n.Status.Conditions {
+			if cond.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch cond.Type {
+			case corev1.NodeReady:
+				sum.Ready++
+			case corev1.NodeMemoryPressure:
+				sum.MemoryPressure++
+			case corev1.NodeDiskPressure:
+				sum.DiskPressure++
+			case corev1.NodePIDPressure:
+				sum.PIDPressure++
+			}
+		}
+	}
+	return sum, nil
+}
+
+// defaultStorageClass returns "" when there is none or classes cannot be
+// listed.
+func defaultStorageClass(ctx context.Context, c *Client) (string, error) {
+	classes, err := c.CS.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("list storageclasses: %w", err)
+	}
+	for _, sc := range classes.Items {
+		if sc.Annotations[defaultClassAnnotation] == "true" || sc.Annotations[defaultClassAnnotationV1] == "true" {
+			return sc.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// podSecurityLevels reads the enforce level of every namespace, falling
+// back to the current namespace alone when namespaces cannot be listed.
+func podSecurityLevels(ctx context.Context, c *Client) (map[string]string, error) {
+	levels := make(map[string]string)
+	list, err := c.CS.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	switch {
+	case err == nil:
+		for _, ns := range list.Items {
+			if level := ns.Labels[podSecurityEnforceLabel]; level != "" {
+				levels[ns.Name] = level
+			}
+		}
+	case apierrors.IsForbidden(err):
+		ns, err := c.CS.CoreV1().Namespaces().Get(ctx, c.NS, metav1.GetOptions{})
+		if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get namespace %s: %w", c.NS, err)
+		}
+		if level := ns.Labels[podSecurityEnforceLabel]; level != "" {
+			levels[ns.Name] = level
+		}
+	default:
+		return nil, fmt.Errorf("list namespaces: %w", err)
+	}
+	if len(levels) == 0 {
+		return nil, nil
+	}
+	return levels, nil
 }
@@ -2,9 +2,22 @@ package k8s
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestGetClusterInfo(t *testing.T) {
@@ -23,3 +36,166 @@ func TestGetClusterInfo(t *testing.T) {
 		t.Error("Version is empty")
 	}
 }
+
+func TestGetClusterInfo_Environment(t *testing.T) {
+	node := func(name string, unschedulable bool, conds ...corev1.NodeConditionType) *corev1.Node {
+		n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		n.Spec.Unschedulable = unschedulable
+		for _, c := range conds {
+			n.Status.Conditions = append(n.Status.Conditions, corev1.NodeCondition{Type: c, Status: corev1.ConditionTrue})
+		}
+		return n
+	}
+	ns := func(name, level string) *corev1.Namespace {
+		n := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if level != "" {
+			n.Labels = map[string]string{podSecurityEnforceLabel: level}
+		}
+		return n
+	}
+	cs := fake.NewSimpleClientset( //nolint:staticcheck // NewClientset requires generated apply configs
+		node("n1", false, corev1.NodeReady),
+		node("n2", false, corev1.NodeReady, corev1.NodeMemoryPressure),
+		node("n3", true, corev1.NodeDiskPressure, corev1.NodePIDPressure),
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "slow"}},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{
+			Name:        "gp3",
+			Annotations: map[string]string{defaultClassAnnotation: "true"},
+		}},
+		ns("test-ns", "restricted"),
+		ns("kube-system", "privileged"),
+		ns("other", ""),
+	)
+	c := NewClientFromInterface(cs, "test-ns")
+
+	info, err := GetClusterInfo(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NodeSummary{Total: 3, Ready: 2, Unschedulable: 1, MemoryPressure: 1, DiskPressure: 1, PIDPressure: 1}
+	if info.Nodes == nil || *info.Nodes != want {
+		t.Errorf("Nodes = %+v, want %+v", info.Nodes, want)
+	}
+	if info.DefaultStorageClass != "gp3" {
+		t.Errorf("DefaultStorageClass = %q, want gp3", info.DefaultStorageClass)
+	}
+	wantPSA := map[string]string{"test-ns": "restricted", "kube-system": "privileged"}
+	if !reflect.DeepEqual(info.PodSecurity, wantPSA) {
+		t.Errorf("PodSecurity = %v, want %v", info.PodSecurity, wantPSA)
+	}
+	if info.APILatencyMs < 0 {
+		t.Errorf("APILatencyMs = %v", info.APILatencyMs)
+	}
+}
+
+func TestGetClusterInfo_Forbidden(t *testing.T) {
+	cs := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{ //nolint:staticcheck // NewClientset requires generated apply configs
+		Name:   "team",
+		Labels: map[string]string{podSecurityEnforceLabel: "baseline"},
+	}})
+	forbid := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: action.GetResource().Resource}, "", fmt.Errorf("no"))
+	}
+	cs.PrependReactor("list", "nodes", forbid)
+	cs.PrependReactor("list", "storageclasses", forbid)
+	cs.PrependReactor("list", "namespaces", forbid)
+	c := NewClientFromInterface(cs, "team")
+
+	info, err := GetClusterInfo(context.Background(), c)
+	if err != nil {
+		t.Fatalf("forbidden lists should be skipped: %v", err)
+	}
+	if info.Nodes != nil || info.DefaultStorageClass != "" {
+		t.Errorf("expected empty node and storage sections, got %+v %q", info.Nodes, info.DefaultStorageClass)
+	}
+	if info.PodSecurity["team"] != "baseline" {
+		t.Errorf("PodSecurity = %v, want the current namespace's level", info.PodSecurity)
+	}
+}
+
+func TestGetClusterInfo_ListError(t *testing.T) {
+	cs := fake.NewSimpleClientset(&storagev1.StorageClass{ //nolint:staticcheck // NewClientset requires generated apply configs
+		ObjectMeta: metav1.ObjectMeta{Name: "fast", Annotations: map[string]string{defaultClassAnnotation: "true"}},
+	})
+	cs.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("injected node list error")
+	})
+	c := NewClientFromInterface(cs, "default")
+	info, err := GetClusterInfo(context.Background(), c)
+	if err != nil {
+		t.Fatalf("a failed section should not fail the rest: %v", err)
+	}
+	if info.Nodes != nil || !strings.Contains(info.Errors["nodes"], "list nodes") {
+		t.Errorf("nodes = %+v, errors = %v; want the node list error recorded", info.Nodes, info.Errors)
+	}
+	if info.DefaultStorageClass != "fast" || len(info.Errors) != 1 {
+		t.Errorf("storage class = %q, errors = %v; want the other sections read", info.DefaultStorageClass, info.Errors)
+	}
+}
+
+func TestAuthOptionsValidate(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("abc"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		auth    AuthOptions
+		wantErr bool
+	}{
+		{"zero", AuthOptions{}, false},
+		{"impersonate user", AuthOptions{As: "ci-bot"}, false},
+		{"impersonate groups", AuthOptions{As: "ci-bot", AsGroups: []string{"ci"}}, false},
+		{"groups without user", AuthOptions{AsGroups: []string{"ci"}}, true},
+		{"token", AuthOptions{Token: "abc"}, false},
+		{"token path", AuthOptions{TokenPath: tokenFile}, false},
+		{"token and path", AuthOptions{Token: "abc", TokenPath: tokenFile}, true},
+		{"missing token path", AuthOptions{TokenPath: "/nonexistent/token"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyAuth(t *testing.T) {
+	cfg := &rest.Config{
+		Username: "admin",
+		Password: "secret",
+		TLSClientConfig: rest.TLSClientConfig{
+			CertData: []byte("cert"),
+			KeyData:  []byte("key"),
+			CAData:   []byte("ca"),
+		},
+	}
+	applyAuth(cfg, AuthOptions{Token: "abc", As: "ci-bot", AsGroups: []string{"ci"}})
+
+	if cfg.BearerToken != "abc" {
+		t.Errorf("BearerToken = %q, want %q", cfg.BearerToken, "abc")
+	}
+	if cfg.Username != "" || cfg.Password != "" || cfg.CertData != nil || cfg.KeyData != nil {
+		t.Error("expected kubeconfig credentials to be cleared")
+	}
+	if string(cfg.CAData) != "ca" {
+		t.Error("expected CA data to be kept")
+	}
+	if cfg.Impersonate.UserName != "ci-bot" || len(cfg.Impersonate.Groups) != 1 {
+		t.Errorf("Impersonate = %+v", cfg.Impersonate)
+	}
+}
+
+func TestApplyAuth_ZeroKeepsCredentials(t *testing.T) {
+	cfg := &rest.Config{BearerToken: "from-kubeconfig"}
+	applyAuth(cfg, AuthOptions{})
+	if cfg.BearerToken != "from-kubeconfig" {
+		t.Errorf("BearerToken = %q, want kubeconfig token kept", cfg.BearerToken)
+	}
+	if cfg.Impersonate.UserName != "" {
+		t.Error("expected no impersonation")
+	}
+}
@@ -3,9 +3,13 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // WorkloadKind identifies the type of Kubernetes workload.
@@ -124,38 +128,146 @@ func DiscoverByName(ctx context.Context, c *Client, kind WorkloadKind, name stri
 	}
 }
 
-// DiscoverBySelector finds all workloads matching a label selector.
+// discoverPageSize caps the objects returned by one list call, so namespaces
+// with thousands of workloads are read in pages rather than one response
+// large enough to time out.
+const discoverPageSize = 500
+
+// workloadCacheTTL is how long a full namespace listing answers later
+// discovery calls before the API is listed again.
+const workloadCacheTTL = 30 * time.Second
+
+// workloadCache holds the last full listing of a client's namespace, so a
+// command that discovers several times (check, untap --all) lists the API
+// once and filters locally, like an informer's store.
+type workloadCache struct {
+	mu        sync.Mutex
+	workloads []*Workload
+	listed    time.Time
+}
+
+func (wc *workloadCache) get() ([]*Workload, bool) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if wc.listed.IsZero() || time.Since(wc.listed) > workloadCacheTTL {
+		return nil, false
+	}
+	return wc.workloads, true
+}
+
+func (wc *workloadCache) set(workloads []*Workload) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wc.workloads, wc.listed = workloads, time.Now()
+}
+
+func (wc *workloadCache) invalidate() {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wc.workloads, wc.listed = nil, time.Time{}
+}
+
+// DiscoverBySelector finds all workloads matching a label selector. Lists
+// are paginated; a full listing of the namespace is cached on the client
+// and later selectors are matched against it locally.
 func DiscoverBySelector(ctx context.Context, c *Client, selector string) ([]*Workload, error) {
-	opts := metav1.ListOptions{LabelSelector: selector}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("parse selector %q: %w", selector, err)
+	}
+	if all, ok := c.workloads.get(); ok {
+		var workloads []*Workload
+		for _, w := range all {
+			if sel.Matches(labels.Set(workloadLabels(w))) {
+				workloads = append(workloads, w)
+			}
+		}
+		return workloads, nil
+	}
+
 	var workloads []*Workload
 
-	deps, err := c.CS.AppsV1().Deployments(c.NS).List(ctx, opts)
+	deps, err := listPages(ctx, selector, func(ctx context.Context, opts metav1.ListOptions) ([]appsv1.Deployment, string, error) {
+		l, err := c.CS.AppsV1().Deployments(c.NS).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return l.Items, l.Continue, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("list deployments: %w", err)
 	}
-	for i := range deps.Items {
-		workloads = append(workloads, workloadFromDeployment(&deps.Items[i]))
+	for i := range deps {
+		workloads = append(workloads, workloadFromDeployment(&deps[i]))
 	}
 
-	sts, err := c.CS.AppsV1().StatefulSets(c.NS).List(ctx, opts)
+	sts, err := listPages(ctx, selector, func(ctx context.Context, opts metav1.ListOptions) ([]appsv1.StatefulSet, string, error) {
+		l, err := c.CS.AppsV1().StatefulSets(c.NS).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return l.Items, l.Continue, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("list statefulsets: %w", err)
 	}
-	for i := range sts.Items {
-		workloads = append(workloads, workloadFromStatefulSet(&sts.Items[i]))
+	for i := range sts {
+		workloads = append(workloads, workloadFromStatefulSet(&sts[i]))
 	}
 
-	dss, err := c.CS.AppsV1().DaemonSets(c.NS).List(ctx, opts)
+	dss, err := listPages(ctx, selector, func(ctx context.Context, opts metav1.ListOptions) ([]appsv1.DaemonSet, string, error) {
+		l, err := c.CS.AppsV1().DaemonSets(c.NS).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return l.Items, l.Continue, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("list daemonsets: %w", err)
 	}
-	for i := range dss.Items {
-		workloads = append(workloads, workloadFromDaemonSet(&dss.Items[i]))
+	for i := range dss {
+		workloads = append(workloads, workloadFromDaemonSet(&dss[i]))
 	}
 
+	if selector == "" {
+		c.workloads.set(workloads)
+	}
 	return workloads, nil
 }
 
+// listPages calls list with discoverPageSize until the continue token runs
+// out. If the token expires mid-way (410 Gone, the server compacted past the
+// snapshot) the listing restarts as a single unpaginated call, as client-go's
+// pager does.
+func listPages[T any](ctx context.Context, selector string, list func(context.Context, metav1.ListOptions) ([]T, string, error)) ([]T, error) {
+	var items []T
+	opts := metav1.ListOptions{LabelSelector: selector, Limit: discoverPageSize}
+	for {
+		page, next, err := list(ctx, opts)
+		if err != nil {
+			if opts.Continue == "" || !apierrors.IsResourceExpired(err) {
+				return nil, err
+			}
+			items, _, err = list(ctx, metav1.ListOptions{LabelSelector: selector})
+			return items, err
+		}
+		items = append(items, page...)
+		if next == "" {
+			return items, nil
+		}
+		opts.Continue = next
+	}
+}
+
+// workloadLabels returns the labels on the workload object itself, which
+// are what a discovery selector matches.
+func workloadLabels(w *Workload) map[string]string {
+	if obj, ok := w.Raw.(metav1.Object); ok {
+		return obj.GetLabels()
+	}
+	return nil
+}
+
 // DiscoverTapped finds all workloads with a non-empty template annotation for the given key.
 func DiscoverTapped(ctx context.Context, c *Client, annotationKey string) ([]*Workload, error) {
 	all, err := DiscoverBySelector(ctx, c, "")
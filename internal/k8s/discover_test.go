@@ -8,6 +8,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
@@ -163,6 +164,109 @@ func TestDiscoverBySelector_Empty(t *testing.T) {
 	}
 }
 
+func TestDiscoverBySelector_Paginates(t *testing.T) {
+	cs := fake.NewSimpleClientset() //nolint:staticcheck // NewClientset requires generated apply configs
+	var calls []metav1.ListOptions
+	cs.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		opts := action.(k8stesting.ListActionImpl).ListOptions
+		calls = append(calls, opts)
+		if opts.Limit != discoverPageSize {
+			return true, nil, fmt.Errorf("limit = %d, want %d", opts.Limit, discoverPageSize)
+		}
+		page := &appsv1.DeploymentList{}
+		page.Items = []appsv1.Deployment{{ObjectMeta: metav1.ObjectMeta{Name: "d" + opts.Continue, Labels: map[string]string{"team": "platform"}}}}
+		if opts.Continue == "" {
+			page.Continue = "2"
+		}
+		return true, page, nil
+	})
+	c := NewClientFromInterface(cs, "default")
+
+	workloads, err := DiscoverBySelector(context.Background(), c, "team=platform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workloads) != 2 || workloads[1].Name != "d2" {
+		t.Errorf("workloads = %v, want both pages", workloads)
+	}
+	if len(calls) != 2 || calls[1].Continue != "2" || calls[1].LabelSelector != "team=platform" {
+		t.Errorf("list calls = %+v, want a second page with the continue token", calls)
+	}
+}
+
+func TestDiscoverBySelector_ExpiredContinue(t *testing.T) {
+	cs := fake.NewSimpleClientset() //nolint:staticcheck // NewClientset requires generated apply configs
+	cs.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		opts := action.(k8stesting.ListActionImpl).ListOptions
+		switch {
+		case opts.Continue != "":
+			return true, nil, apierrors.NewResourceExpired("continue token expired")
+		case opts.Limit == 0:
+			return true, &appsv1.DeploymentList{Items: make([]appsv1.Deployment, 3)}, nil
+		}
+		return true, &appsv1.DeploymentList{ListMeta: metav1.ListMeta{Continue: "x"}, Items: make([]appsv1.Deployment, 1)}, nil
+	})
+	c := NewClientFromInterface(cs, "default")
+
+	workloads, err := DiscoverBySelector(context.Background(), c, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workloads) != 3 {
+		t.Errorf("found %d workloads, want 3 from the unpaginated relist", len(workloads))
+	}
+}
+
+func TestDiscoverBySelector_Cache(t *testing.T) {
+	cs := fake.NewSimpleClientset( //nolint:staticcheck // NewClientset requires generated apply configs
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default", Labels: map[string]string{"team": "platform"}},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"logtap.dev/tapped": "lt-a3f9"}},
+			}},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default", Labels: map[string]string{"team": "other"}},
+		},
+	)
+	lists := 0
+	cs.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		lists++
+		return false, nil, nil
+	})
+	c := NewClientFromInterface(cs, "default")
+	ctx := context.Background()
+
+	if _, err := DiscoverBySelector(ctx, c, ""); err != nil {
+		t.Fatal(err)
+	}
+	tapped, err := DiscoverTapped(ctx, c, "logtap.dev/tapped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	platform, err := DiscoverBySelector(ctx, c, "team in (platform)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lists != 3 {
+		t.Errorf("list calls = %d, want 3 (one per kind, then served from cache)", lists)
+	}
+	if len(tapped) != 1 || len(platform) != 1 || platform[0].Name != "api" {
+		t.Errorf("tapped = %d, platform = %v; want 1 and [api]", len(tapped), platform)
+	}
+
+	if _, err := RemovePatch(ctx, c, tapped[0], RemovePatchSpec{DeleteAnnotations: []string{"logtap.dev/tapped"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if tapped, _ = DiscoverTapped(ctx, c, "logtap.dev/tapped"); len(tapped) != 0 || lists != 6 {
+		t.Errorf("after untap: tapped = %d, list calls = %d; want 0 and a fresh listing", len(tapped), lists)
+	}
+
+	if _, err := DiscoverBySelector(ctx, c, "team in (platform"); err == nil {
+		t.Error("expected error for an invalid selector")
+	}
+}
+
 func TestDiscoverTapped(t *testing.T) {
 	tapped := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{Name: "api-gw", Namespace: "default"},
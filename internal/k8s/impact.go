@@ -0,0 +1,151 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultImpactSampleWindow is how much recent log output EstimateImpact
+// reads from each sampled pod to project receiver bandwidth.
+const DefaultImpactSampleWindow = 5 * time.Minute
+
+// maxImpactSampleBytes caps the log bytes read per sampled container.
+const maxImpactSampleBytes = 16 << 20
+
+// WorkloadImpact is the projected cost of tapping one workload.
+type WorkloadImpact struct {
+	Kind        WorkloadKind `json:"kind"`
+	Name        string       `json:"name"`
+	Replicas    int32        `json:"replicas"`
+	Restarts    int32        `json:"restarts"`
+	MemRequest  string       `json:"mem_request"`
+	CPURequest  string       `json:"cpu_request"`
+	BytesPerSec float64      `json:"bytes_per_sec"`
+	Sampled     bool         `json:"sampled"` // false when no running pod could be sampled
+}
+
+// Impact is the aggregate projected cost of a tap session.
+type Impact struct {
+	Workloads   []WorkloadImpact `json:"workloads"`
+	Replicas    int32            `json:"replicas"`
+	Restarts    int32            `json:"restarts"`
+	MemRequest  string           `json:"mem_request"`
+	CPURequest  string           `json:"cpu_request"`
+	MemLimit    string           `json:"mem_limit"`
+	CPULimit    string           `json:"cpu_limit"`
+	BytesPerSec float64          `json:"bytes_per_sec"`
+	Unsampled   int              `json:"unsampled"` // workloads without a bandwidth sample
+}
+
+// EstimateImpact projects the extra sidecar requests and limits across all
+// replicas, the pod restarts the rollout causes, and the receiver bandwidth.
+// Bandwidth is sampled from the last window of container logs of one running
+// pod per workload and scaled by replica count; it counts message bytes only,
+// so the actual push volume is somewhat higher.
+func EstimateImpact(ctx context.Context, c *Client, workloads []*Workload, memReq, cpuReq, memLimit, cpuLimit string, window time.Duration) (*Impact, error) {
+	if window <= 0 {
+		window = DefaultImpactSampleWindow
+	}
+	perPod := make(map[string]resource.Quantity, 4)
+	for name, v := range map[string]string{"memReq": memReq, "cpuReq": cpuReq, "memLimit": memLimit, "cpuLimit": cpuLimit} {
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse sidecar resource %q: %w", v, err)
+		}
+		perPod[name] = q
+	}
+
+	impact := &Impact{Workloads: make([]WorkloadImpact, 0, len(workloads))}
+	for _, w := range workloads {
+		wi := WorkloadImpact{
+			Kind:       w.Kind,
+			Name:       w.Name,
+			Replicas:   w.Replicas,
+			Restarts:   w.Replicas, // adding a container rolls every pod
+			MemRequest: scaleQuantity(perPod["memReq"], w.Replicas),
+			CPURequest: scaleQuantity(perPod["cpuReq"], w.Replicas),
+		}
+		if rate, ok := samplePodLogRate(ctx, c, w, window); ok {
+			wi.BytesPerSec = rate * float64(w.Replicas)
+			wi.Sampled = true
+		} else {
+			impact.Unsampled++
+		}
+		impact.Workloads = append(impact.Workloads, wi)
+		impact.Replicas += wi.Replicas
+		impact.Restarts += wi.Restarts
+		impact.BytesPerSec += wi.BytesPerSec
+	}
+
+	impact.MemRequest = scaleQuantity(perPod["memReq"], impact.Replicas)
+	impact.CPURequest = scaleQuantity(perPod["cpuReq"], impact.Replicas)
+	impact.MemLimit = scaleQuantity(perPod["memLimit"], impact.Replicas)
+	impact.CPULimit = scaleQuantity(perPod["cpuLimit"], impact.Replicas)
+	return impact, nil
+}
+
+func scaleQuantity(q resource.Quantity, n int32) string {
+	total := q.DeepCopy()
+	total.SetMilli(q.MilliValue() * int64(n))
+	return total.String()
+}
+
+// samplePodLogRate returns the log bytes per second of one running pod of w,
+// summed over its containers.
+func samplePodLogRate(ctx context.Context, c *Client, w *Workload, window time.Duration) (float64, bool) {
+	selector := getWorkloadSelector(w)
+	if selector == "" {
+		return 0, false
+	}
+	pods, err := c.CS.CoreV1().Pods(c.NS).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return 0, false
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		span := window
+		if pod.Status.StartTime != nil {
+			if age := time.Since(pod.Status.StartTime.Time); age > 0 && age < span {
+				span = age
+			}
+		}
+		var total int64
+		for _, ctr := range pod.Spec.Containers {
+			n, err := podLogBytes(ctx, c, pod.Name, ctr.Name, span)
+			if err != nil {
+				return 0, false
+			}
+			total += n
+		}
+		return float64(total) / span.Seconds(), true
+	}
+	return 0, false
+}
+
+func podLogBytes(ctx context.Context, c *Client, pod, container string, span time.Duration) (int64, error) {
+	since := int64(span.Seconds())
+	if since < 1 {
+		since = 1
+	}
+	limit := int64(maxImpactSampleBytes)
+	req := c.CS.CoreV1().Pods(c.NS).GetLogs(pod, &corev1.PodLogOptions{
+		Container:    container,
+		SinceSeconds: &since,
+		LimitBytes:   &limit,
+	})
+	rc, err := req.Stream(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rc.Close() }()
+	return io.Copy(io.Discard, rc)
+}
@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEstimateImpact(t *testing.T) {
+	replicas := int32(3)
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "default", Labels: map[string]string{"app": "api"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status: corev1.PodStatus{
+			Phase:     corev1.PodRunning,
+			StartTime: &metav1.Time{Time: time.Now().Add(-time.Hour)},
+		},
+	}
+	worker := &Workload{Kind: KindStatefulSet, Name: "worker", Replicas: 2, Raw: &appsv1.StatefulSet{}}
+
+	cs := fake.NewSimpleClientset(pod) //nolint:staticcheck // NewClientset requires generated apply configs
+	c := NewClientFromInterface(cs, "default")
+
+	impact, err := EstimateImpact(context.Background(), c, []*Workload{workloadFromDeployment(dep), worker},
+		"16Mi", "25m", "32Mi", "50m", time.Minute)
+	if err != nil {
+		t.Fatalf("EstimateImpact: %v", err)
+	}
+
+	if impact.Replicas != 5 || impact.Restarts != 5 {
+		t.Errorf("replicas/restarts = %d/%d, want 5/5", impact.Replicas, impact.Restarts)
+	}
+	if impact.MemRequest != "80Mi" || impact.CPURequest != "125m" {
+		t.Errorf("requests = %s/%s, want 80Mi/125m", impact.MemRequest, impact.CPURequest)
+	}
+	if impact.MemLimit != "160Mi" || impact.CPULimit != "250m" {
+		t.Errorf("limits = %s/%s, want 160Mi/250m", impact.MemLimit, impact.CPULimit)
+	}
+	if impact.Unsampled != 1 {
+		t.Errorf("unsampled = %d, want 1 (worker has no selector)", impact.Unsampled)
+	}
+
+	api := impact.Workloads[0]
+	if !api.Sampled || api.BytesPerSec <= 0 {
+		t.Fatalf("api not sampled: %+v", api)
+	}
+	// the fake clientset returns "fake logs" for every log request
+	if want := float64(len("fake logs")) / 60 * 3; api.BytesPerSec != want {
+		t.Errorf("api bytes/sec = %v, want %v", api.BytesPerSec, want)
+	}
+	if impact.BytesPerSec != api.BytesPerSec {
+		t.Errorf("total bytes/sec = %v, want %v", impact.BytesPerSec, api.BytesPerSec)
+	}
+}
+
+func TestEstimateImpact_NoRunningPods(t *testing.T) {
+	replicas := int32(2)
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+		},
+	}
+	pending := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "default", Labels: map[string]string{"app": "api"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	cs := fake.NewSimpleClientset(pending) //nolint:staticcheck // NewClientset requires generated apply configs
+	c := NewClientFromInterface(cs, "default")
+
+	impact, err := EstimateImpact(context.Background(), c, []*Workload{workloadFromDeployment(dep)}, "16Mi", "25m", "32Mi", "50m", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if impact.Unsampled != 1 || impact.BytesPerSec != 0 {
+		t.Errorf("expected unsampled workload, got %+v", impact)
+	}
+}
+
+func TestEstimateImpact_InvalidResource(t *testing.T) {
+	c := NewClientFromInterface(fake.NewSimpleClientset(), "default") //nolint:staticcheck // NewClientset requires generated apply configs
+	if _, err := EstimateImpact(context.Background(), c, nil, "lots", "25m", "32Mi", "50m", 0); err == nil {
+		t.Error("expected error for invalid memory request")
+	}
+}
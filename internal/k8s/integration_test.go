@@ -388,7 +388,7 @@ func TestIntegration(t *testing.T) {
 		waitForQuotaSync(t, ctx, client, quotaNS, "tight-quota", 30*time.Second)
 
 		// CheckResources with 3 replicas × 16Mi should exceed 32Mi quota.
-		warnings, err := k8s.CheckResources(ctx, quotaClient, 3, "16Mi", "25m")
+		warnings, err := k8s.CheckResources(ctx, quotaClient, &k8s.Workload{Replicas: 3}, "16Mi", "25m", "32Mi", "50m")
 		if err != nil {
 			t.Fatalf("CheckResources: %v", err)
 		}
@@ -408,7 +408,7 @@ func TestIntegration(t *testing.T) {
 
 	t.Run("NodeCapacity", func(t *testing.T) {
 		// Just verify it doesn't error. Kind node should have no pressure.
-		warnings, err := k8s.CheckResources(ctx, nsClient, 1, "16Mi", "25m")
+		warnings, err := k8s.CheckResources(ctx, nsClient, &k8s.Workload{Replicas: 1}, "16Mi", "25m", "32Mi", "50m")
 		if err != nil {
 			t.Fatalf("CheckResources: %v", err)
 		}
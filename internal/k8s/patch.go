@@ -23,6 +23,9 @@ type PatchSpec struct {
 // ApplyPatch adds a sidecar container and annotations to a workload.
 // If dryRun is true, the diff is computed but the workload is not modified.
 func ApplyPatch(ctx context.Context, c *Client, w *Workload, ps PatchSpec, dryRun bool) (string, error) {
+	if !dryRun {
+		c.workloads.invalidate()
+	}
 	switch w.Kind {
 	case KindDeployment:
 		return applyDeploymentPatch(ctx, c, w.Raw.(*appsv1.Deployment), ps, dryRun)
@@ -136,6 +139,9 @@ type RemovePatchSpec struct {
 // RemovePatch removes containers and updates annotations on a workload.
 // If dryRun is true, the diff is computed but the workload is not modified.
 func RemovePatch(ctx context.Context, c *Client, w *Workload, rs RemovePatchSpec, dryRun bool) (string, error) {
+	if !dryRun {
+		c.workloads.invalidate()
+	}
 	switch w.Kind {
 	case KindDeployment:
 		return removeDeploymentPatch(ctx, c, w.Raw.(*appsv1.Deployment), rs, dryRun)
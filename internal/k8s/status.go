@@ -2,19 +2,33 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// Forwarder delivery states reported by ProbeForwarders.
+const (
+	ForwarderReady    = "ready"
+	ForwarderNotReady = "not_ready"
+	ForwarderUnknown  = "unknown"
+)
+
+const forwarderProbeTimeout = 3 * time.Second
+
 // PodStatus describes sidecar health in a single pod.
 type PodStatus struct {
 	Name           string `json:"name"`
 	SidecarRunning bool   `json:"sidecar_running"`
+	Forwarder      string `json:"forwarder,omitempty"` // delivery state from /readyz, set by ProbeForwarders
+	Reason         string `json:"reason,omitempty"`
 }
 
 // TappedStatus describes a tapped workload with pod-level health.
@@ -25,6 +39,8 @@ type TappedStatus struct {
 	Pods     []PodStatus `json:"pods,omitempty"`
 	Ready    int         `json:"ready"`
 	Total    int         `json:"total"`
+	// NotDelivering counts running sidecars whose /readyz reports not ready.
+	NotDelivering int `json:"not_delivering,omitempty"`
 }
 
 // GetTappedStatus returns the status of all tapped workloads including pod health.
@@ -71,6 +87,56 @@ func GetTappedStatus(ctx context.Context, c *Client, annotationTapped, annotatio
 	return statuses, nil
 }
 
+// ProbeForwarders queries /readyz of every running sidecar through the API
+// server pod proxy, so a forwarder that is up but cannot deliver is told
+// apart from a healthy one. Sidecars that do not answer with a readiness
+// status (e.g. Fluent Bit) are reported as unknown.
+func ProbeForwarders(ctx context.Context, c *Client, statuses []TappedStatus, port int) {
+	for i := range statuses {
+		ts := &statuses[i]
+		ns := c.NS
+		if ts.Workload != nil && ts.Workload.Namespace != "" {
+			ns = ts.Workload.Namespace
+		}
+		for j := range ts.Pods {
+			ps := &ts.Pods[j]
+			if !ps.SidecarRunning {
+				continue
+			}
+			ps.Forwarder, ps.Reason = probeForwarder(ctx, c, ns, ps.Name, port)
+			if ps.Forwarder == ForwarderNotReady {
+				ts.NotDelivering++
+			}
+		}
+	}
+}
+
+func probeForwarder(ctx context.Context, c *Client, ns, pod string, port int) (string, string) {
+	ctx, cancel := context.WithTimeout(ctx, forwarderProbeTimeout)
+	defer cancel()
+
+	rw := c.CS.CoreV1().Pods(ns).ProxyGet("http", pod, strconv.Itoa(port), "/readyz", nil)
+	if rw == nil {
+		return ForwarderUnknown, ""
+	}
+	// a 503 returns the body along with an error
+	body, err := rw.DoRaw(ctx)
+	var resp struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if jerr := json.Unmarshal(body, &resp); jerr != nil || resp.Status == "" {
+		if err != nil {
+			return ForwarderUnknown, err.Error()
+		}
+		return ForwarderUnknown, ""
+	}
+	if resp.Status == "not_ready" {
+		return ForwarderNotReady, resp.Reason
+	}
+	return ForwarderReady, ""
+}
+
 func getWorkloadSelector(w *Workload) string {
 	var labels map[string]string
 	switch raw := w.Raw.(type) {
@@ -1,7 +1,10 @@
 package k8s
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"testing"
 	"time"
 
@@ -9,6 +12,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func tappedDeploymentWithSelector(name string, labels map[string]string, annotations map[string]string, containers []corev1.Container) *appsv1.Deployment {
@@ -228,3 +233,74 @@ func TestGetTappedStatus_NoTapped(t *testing.T) {
 		t.Errorf("statuses = %d, want 0", len(statuses))
 	}
 }
+
+type fakeProxyResponse struct {
+	body []byte
+	err  error
+}
+
+func (r fakeProxyResponse) DoRaw(context.Context) ([]byte, error) { return r.body, r.err }
+
+func (r fakeProxyResponse) Stream(context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(r.body)), r.err
+}
+
+func TestProbeForwarders(t *testing.T) {
+	cs := fake.NewSimpleClientset() //nolint:staticcheck // NewClientset requires generated apply configs
+	cs.PrependProxyReactor("pods", func(action k8stesting.Action) (bool, restclient.ResponseWrapper, error) {
+		pa := action.(k8stesting.ProxyGetAction)
+		if pa.GetPort() != "9091" || pa.GetPath() != "/readyz" {
+			t.Errorf("unexpected proxy request %s:%s", pa.GetPort(), pa.GetPath())
+		}
+		switch pa.GetName() {
+		case "ok":
+			return true, fakeProxyResponse{body: []byte(`{"status":"ok","ready":true}`)}, nil
+		case "stuck":
+			return true, fakeProxyResponse{
+				body: []byte(`{"status":"not_ready","reason":"batches buffered"}`),
+				err:  errors.New("the server is currently unable to handle the request"),
+			}, nil
+		default:
+			return true, fakeProxyResponse{err: errors.New("connection refused")}, nil
+		}
+	})
+	c := NewClientFromInterface(cs, "default")
+
+	statuses := []TappedStatus{{
+		Workload: &Workload{Kind: "Deployment", Name: "api", Namespace: "default"},
+		Pods: []PodStatus{
+			{Name: "ok", SidecarRunning: true},
+			{Name: "stuck", SidecarRunning: true},
+			{Name: "fluent", SidecarRunning: true},
+			{Name: "pending", SidecarRunning: false},
+		},
+	}}
+	ProbeForwarders(context.Background(), c, statuses, 9091)
+
+	pods := statuses[0].Pods
+	if pods[0].Forwarder != ForwarderReady {
+		t.Errorf("ok = %q, want ready", pods[0].Forwarder)
+	}
+	if pods[1].Forwarder != ForwarderNotReady || pods[1].Reason != "batches buffered" {
+		t.Errorf("stuck = %+v, want not_ready with reason", pods[1])
+	}
+	if pods[2].Forwarder != ForwarderUnknown {
+		t.Errorf("fluent = %q, want unknown", pods[2].Forwarder)
+	}
+	if pods[3].Forwarder != "" {
+		t.Errorf("pending sidecar should not be probed, got %q", pods[3].Forwarder)
+	}
+	if statuses[0].NotDelivering != 1 {
+		t.Errorf("NotDelivering = %d, want 1", statuses[0].NotDelivering)
+	}
+}
+
+func TestProbeForwarders_NoProxy(t *testing.T) {
+	cs := fake.NewSimpleClientset() //nolint:staticcheck // NewClientset requires generated apply configs
+	c := NewClientFromInterface(cs, "default")
+	statuses := []TappedStatus{{Pods: []PodStatus{{Name: "p", SidecarRunning: true}}}}
+	ProbeForwarders(context.Background(), c, statuses, 9091)
+	if statuses[0].Pods[0].Forwarder != ForwarderUnknown {
+		t.Errorf("Forwarder = %q, want unknown", statuses[0].Pods[0].Forwarder)
+	}
+}
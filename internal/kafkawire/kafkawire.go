@@ -0,0 +1,211 @@
+// Package kafkawire is the part of the Kafka wire protocol shared by the
+// receiver's Kafka source and the forwarder's Kafka sink: big-endian field
+// encoding and decoding, the request/response framing over one broker
+// connection, and the CRC-32C table record batches are checked with. The
+// requests themselves are built by the callers, which each speak only the
+// API versions they need.
+package kafkawire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"time"
+)
+
+// CRC is the CRC-32C table of message format v2 record batches.
+var CRC = crc32.MakeTable(crc32.Castagnoli)
+
+// Encoder appends big-endian protocol fields to B.
+type Encoder struct {
+	B []byte
+}
+
+func (e *Encoder) Int8(v int8)   { e.B = append(e.B, byte(v)) }
+func (e *Encoder) Int16(v int16) { e.B = binary.BigEndian.AppendUint16(e.B, uint16(v)) }
+func (e *Encoder) Int32(v int32) { e.B = binary.BigEndian.AppendUint32(e.B, uint32(v)) }
+func (e *Encoder) Int64(v int64) { e.B = binary.BigEndian.AppendUint64(e.B, uint64(v)) }
+
+func (e *Encoder) Bool(v bool) {
+	if v {
+		e.Int8(1)
+	} else {
+		e.Int8(0)
+	}
+}
+
+// Str appends an int16-length string.
+func (e *Encoder) Str(s string) {
+	e.Int16(int16(len(s)))
+	e.B = append(e.B, s...)
+}
+
+// Decoder reads big-endian protocol fields from B; the first error sticks
+// in Err and later reads return zero values.
+type Decoder struct {
+	B   []byte
+	Err error
+}
+
+// Take returns the next n bytes.
+func (d *Decoder) Take(n int) []byte {
+	if d.Err != nil {
+		return nil
+	}
+	if n < 0 || len(d.B) < n {
+		d.Err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := d.B[:n]
+	d.B = d.B[n:]
+	return v
+}
+
+func (d *Decoder) Int8() int8 {
+	if b := d.Take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *Decoder) Int16() int16 {
+	if b := d.Take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *Decoder) Int32() int32 {
+	if b := d.Take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *Decoder) Int64() int64 {
+	if b := d.Take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *Decoder) Bool() bool { return d.Int8() != 0 }
+
+// Str reads a (nullable) int16-length string; null reads as "".
+func (d *Decoder) Str() string {
+	n := d.Int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.Take(int(n)))
+}
+
+// Bytes reads a nullable int32-length byte array.
+func (d *Decoder) Bytes() []byte {
+	n := d.Int32()
+	if n < 0 {
+		return nil
+	}
+	return d.Take(int(n))
+}
+
+// ArrayLen reads an array length; null arrays are empty.
+func (d *Decoder) ArrayLen() int {
+	n := int(d.Int32())
+	if d.Err == nil && n > len(d.B) {
+		// every element takes at least one byte
+		d.Err = fmt.Errorf("kafka: array length %d exceeds response", n)
+	}
+	if d.Err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Varint reads a zigzag-encoded variable-length integer.
+func (d *Decoder) Varint() int64 {
+	if d.Err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.B)
+	if n <= 0 {
+		d.Err = errors.New("kafka: malformed varint")
+		return 0
+	}
+	d.B = d.B[n:]
+	return v
+}
+
+// VarBytes reads a varint-length byte array; -1 is null.
+func (d *Decoder) VarBytes() []byte {
+	n := d.Varint()
+	if n < 0 {
+		return nil
+	}
+	return d.Take(int(n))
+}
+
+// Conn is a connection to one broker. It is not safe for concurrent use.
+type Conn struct {
+	conn        net.Conn
+	r           *bufio.Reader
+	clientID    string
+	maxResponse uint32
+	corr        int32
+}
+
+// NewConn frames requests over conn with clientID, refusing responses over
+// maxResponse bytes so a corrupt size prefix cannot make the caller
+// allocate unbounded memory.
+func NewConn(conn net.Conn, clientID string, maxResponse uint32) *Conn {
+	return &Conn{conn: conn, r: bufio.NewReaderSize(conn, 64<<10), clientID: clientID, maxResponse: maxResponse}
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// RoundTrip sends a request with a v1 header and returns a decoder over the
+// response body, after its correlation ID. The exchange must finish by
+// deadline.
+func (c *Conn) RoundTrip(apiKey, version int16, body []byte, deadline time.Time) (*Decoder, error) {
+	c.corr++
+	var e Encoder
+	e.Int32(0) // size, filled in below
+	e.Int16(apiKey)
+	e.Int16(version)
+	e.Int32(c.corr)
+	e.Str(c.clientID)
+	e.B = append(e.B, body...)
+	binary.BigEndian.PutUint32(e.B, uint32(len(e.B)-4))
+
+	_ = c.conn.SetDeadline(deadline)
+	if _, err := c.conn.Write(e.B); err != nil {
+		return nil, err
+	}
+	var sizeBuf [4]byte
+	if _, err := io.ReadFull(c.r, sizeBuf[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(sizeBuf[:])
+	if size < 4 || size > c.maxResponse {
+		return nil, fmt.Errorf("kafka: invalid response size %d", size)
+	}
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	d := &Decoder{B: resp}
+	if corr := d.Int32(); corr != c.corr {
+		return nil, fmt.Errorf("kafka: response correlation id %d, want %d", corr, c.corr)
+	}
+	return d, nil
+}
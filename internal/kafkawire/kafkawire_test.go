@@ -0,0 +1,140 @@
+package kafkawire
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoderDecoder(t *testing.T) {
+	var e Encoder
+	e.Int8(-3)
+	e.Int16(-2)
+	e.Int32(70000)
+	e.Int64(-1 << 40)
+	e.Bool(true)
+	e.Str("logs")
+	e.Int16(-1) // null string
+	e.Int32(2)
+	e.B = append(e.B, "hi"...)
+	e.Int32(-1) // null bytes
+	e.B = binary.AppendVarint(e.B, -5)
+	e.B = binary.AppendVarint(e.B, 3)
+	e.B = append(e.B, "abc"...)
+
+	d := &Decoder{B: e.B}
+	if v := d.Int8(); v != -3 {
+		t.Errorf("Int8 = %d", v)
+	}
+	if v := d.Int16(); v != -2 {
+		t.Errorf("Int16 = %d", v)
+	}
+	if v := d.Int32(); v != 70000 {
+		t.Errorf("Int32 = %d", v)
+	}
+	if v := d.Int64(); v != -1<<40 {
+		t.Errorf("Int64 = %d", v)
+	}
+	if !d.Bool() {
+		t.Error("Bool = false")
+	}
+	if v := d.Str(); v != "logs" {
+		t.Errorf("Str = %q", v)
+	}
+	if v := d.Str(); v != "" {
+		t.Errorf("null Str = %q", v)
+	}
+	if v := d.Bytes(); string(v) != "hi" {
+		t.Errorf("Bytes = %q", v)
+	}
+	if v := d.Bytes(); v != nil {
+		t.Errorf("null Bytes = %q", v)
+	}
+	if v := d.Varint(); v != -5 {
+		t.Errorf("Varint = %d", v)
+	}
+	if v := d.VarBytes(); string(v) != "abc" {
+		t.Errorf("VarBytes = %q", v)
+	}
+	if d.Err != nil || len(d.B) != 0 {
+		t.Errorf("err = %v, %d bytes left", d.Err, len(d.B))
+	}
+}
+
+func TestDecoderErrorSticks(t *testing.T) {
+	d := &Decoder{B: []byte{0, 1}}
+	if v := d.Int32(); v != 0 || !errors.Is(d.Err, io.ErrUnexpectedEOF) {
+		t.Fatalf("short Int32 = %d, err %v", v, d.Err)
+	}
+	if v := d.Int8(); v != 0 {
+		t.Errorf("read after error = %d, want 0", v)
+	}
+
+	d = &Decoder{B: binary.BigEndian.AppendUint32(nil, 1000)}
+	if n := d.ArrayLen(); n != 0 || d.Err == nil {
+		t.Errorf("ArrayLen past the response = %d, err %v", n, d.Err)
+	}
+}
+
+// serve answers one request on conn with resp, framed with a size prefix.
+func serve(t *testing.T, conn net.Conn, resp func(corr int32) []byte) {
+	t.Helper()
+	go func() {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		d := &Decoder{B: req}
+		d.Int16() // api key
+		d.Int16() // version
+		_, _ = conn.Write(resp(d.Int32()))
+	}()
+}
+
+func TestConnRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = server.Close() }()
+	c := NewConn(client, "logtap", 1<<20)
+	defer func() { _ = c.Close() }()
+	deadline := time.Now().Add(5 * time.Second)
+
+	serve(t, server, func(corr int32) []byte {
+		var e Encoder
+		e.Int32(8)
+		e.Int32(corr)
+		e.Int32(42)
+		return e.B
+	})
+	d, err := c.RoundTrip(3, 1, nil, deadline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := d.Int32(); v != 42 || d.Err != nil {
+		t.Errorf("body = %d, err %v", v, d.Err)
+	}
+
+	serve(t, server, func(corr int32) []byte {
+		var e Encoder
+		e.Int32(4)
+		e.Int32(corr + 1)
+		return e.B
+	})
+	if _, err := c.RoundTrip(3, 1, nil, deadline); err == nil || !strings.Contains(err.Error(), "correlation id") {
+		t.Errorf("mismatched correlation id: err = %v", err)
+	}
+
+	serve(t, server, func(int32) []byte {
+		return binary.BigEndian.AppendUint32(nil, 2<<20)
+	})
+	if _, err := c.RoundTrip(3, 1, nil, deadline); err == nil || !strings.Contains(err.Error(), "invalid response size") {
+		t.Errorf("oversized response: err = %v", err)
+	}
+}
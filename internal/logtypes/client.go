@@ -0,0 +1,80 @@
+package logtypes
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ProtocolVersion is the push protocol this build speaks. It is incremented
+// on breaking changes to the push API; receivers accept clients speaking
+// MinProtocolVersion up to their own version.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// Push clients identify their build with these HTTP headers, sent as
+// lowercase gRPC metadata on the push stream. Receivers answer with
+// ProtocolHeader so clients can detect a mismatch from their side too.
+const (
+	ClientHeader   = "X-Logtap-Client"   // client name and version, e.g. "logtap-forwarder/1.4.0"
+	CommitHeader   = "X-Logtap-Commit"   // client build commit
+	ProtocolHeader = "X-Logtap-Protocol" // push protocol version
+)
+
+// ClientInfo identifies the build of a push client.
+type ClientInfo struct {
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	Protocol int    `json:"protocol,omitempty"` // 0 = not sent
+}
+
+// Header returns the ClientHeader value, name/version.
+func (c ClientInfo) Header() string {
+	if c.Version == "" {
+		return c.Name
+	}
+	return c.Name + "/" + c.Version
+}
+
+// Pairs returns the identifying headers and their values, in order, for
+// setting on an HTTP request or gRPC metadata.
+func (c ClientInfo) Pairs() []string {
+	pairs := []string{ClientHeader, c.Header()}
+	if c.Commit != "" {
+		pairs = append(pairs, CommitHeader, c.Commit)
+	}
+	if c.Protocol > 0 {
+		pairs = append(pairs, ProtocolHeader, strconv.Itoa(c.Protocol))
+	}
+	return pairs
+}
+
+// ParseClientInfo reads a client's identifying headers through get, which
+// returns the value of a header or "". It reports false when the client
+// sent no ClientHeader.
+func ParseClientInfo(get func(key string) string) (ClientInfo, bool) {
+	client := get(ClientHeader)
+	if client == "" {
+		return ClientInfo{}, false
+	}
+	name, version, _ := strings.Cut(client, "/")
+	info := ClientInfo{Name: name, Version: version, Commit: get(CommitHeader)}
+	info.Protocol, _ = strconv.Atoi(get(ProtocolHeader))
+	return info, true
+}
+
+// ClientProtocolSupported reports whether a receiver of this build accepts
+// a client speaking protocol. Clients that do not announce one (0) predate
+// negotiation and speak version 1.
+func ClientProtocolSupported(protocol int) bool {
+	return protocol == 0 || (protocol >= MinProtocolVersion && protocol <= ProtocolVersion)
+}
+
+// ReceiverProtocolSupported reports whether a client of this build can push
+// to a receiver speaking protocol. Newer receivers judge compatibility
+// themselves; 0 means the receiver did not answer with ProtocolHeader.
+func ReceiverProtocolSupported(protocol int) bool {
+	return protocol == 0 || protocol >= MinProtocolVersion
+}
@@ -0,0 +1,38 @@
+package logtypes
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientInfoRoundTrip(t *testing.T) {
+	in := ClientInfo{Name: "logtap-forwarder", Version: "1.4.0", Commit: "abc1234", Protocol: ProtocolVersion}
+	h := http.Header{}
+	pairs := in.Pairs()
+	for i := 0; i < len(pairs); i += 2 {
+		h.Set(pairs[i], pairs[i+1])
+	}
+	out, ok := ParseClientInfo(h.Get)
+	if !ok || out != in {
+		t.Errorf("ParseClientInfo = %+v, %v; want %+v", out, ok, in)
+	}
+
+	if _, ok := ParseClientInfo(http.Header{}.Get); ok {
+		t.Error("no client header should not parse")
+	}
+}
+
+func TestProtocolSupported(t *testing.T) {
+	if !ClientProtocolSupported(0) || !ClientProtocolSupported(ProtocolVersion) {
+		t.Error("current and unannounced client protocols must be supported")
+	}
+	if ClientProtocolSupported(ProtocolVersion+1) || ClientProtocolSupported(-1) {
+		t.Error("client protocols outside the supported range accepted")
+	}
+	if !ReceiverProtocolSupported(0) || !ReceiverProtocolSupported(ProtocolVersion+1) {
+		t.Error("unannounced and newer receivers must be supported")
+	}
+	if ReceiverProtocolSupported(-1) {
+		t.Error("receiver older than the minimum protocol accepted")
+	}
+}
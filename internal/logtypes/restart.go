@@ -0,0 +1,20 @@
+package logtypes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RestartMarkerPrefix starts the line the forwarder writes into a
+// container's stream when it sees the container restart.
+const RestartMarkerPrefix = "[logtap] container restarted"
+
+// RestartMarker returns the marker line for a restart of container.
+func RestartMarker(container string, restarts int32) string {
+	return fmt.Sprintf("%s: %s (restart count %d)", RestartMarkerPrefix, container, restarts)
+}
+
+// IsRestartMarker reports whether msg is a restart marker line.
+func IsRestartMarker(msg string) bool {
+	return strings.HasPrefix(msg, RestartMarkerPrefix)
+}
@@ -0,0 +1,24 @@
+package logtypes
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// StreamHash hashes a label set independent of map order, so one stream
+// always maps to the same shard.
+func StreamHash(labels map[string]string) uint32 {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := fnv.New32a()
+	for _, k := range keys {
+		_, _ = h.Write([]byte(k))
+		_, _ = h.Write([]byte{'='})
+		_, _ = h.Write([]byte(labels[k]))
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum32()
+}
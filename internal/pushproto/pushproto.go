@@ -0,0 +1,222 @@
+// Package pushproto is the wire format of the logtap push stream, a
+// bidirectional gRPC stream between the forwarder and the receiver.
+//
+// The client opens the stream with a hello frame carrying only its session
+// ID; the server answers with an ack holding the last sequence number it
+// ingested for that session (0 for a new one), so a reconnecting client
+// resends only what was not acknowledged. Every later frame is one batch
+// with the next sequence number. The server acks each batch after ingest
+// and sets Backpressure while its writer queue is full, delaying the ack
+// until there is room again.
+//
+// Messages are hand-encoded protobuf, equivalent to:
+//
+//	message Frame { string session = 1; uint64 seq = 2; repeated Label labels = 3; repeated Entry entries = 4; }
+//	message Label { string name = 1; string value = 2; }
+//	message Entry { sfixed64 ts_unix_nano = 1; string line = 2; }
+//	message Ack   { uint64 seq = 1; bool backpressure = 2; }
+package pushproto
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// ServiceName is the gRPC service of the push stream.
+	ServiceName = "logtap.push.v1.PushService"
+	// StreamName is its bidirectional streaming method.
+	StreamName = "Stream"
+	// FullMethod is the method path clients open.
+	FullMethod = "/" + ServiceName + "/" + StreamName
+)
+
+// Frame is one client message: a hello (Session set, no entries) or a batch.
+type Frame struct {
+	Session string
+	Seq     uint64
+	Labels  map[string]string
+	Entries []Entry
+}
+
+// Entry is one log line.
+type Entry struct {
+	TimeUnixNano int64
+	Line         string
+}
+
+// Ack is one server message.
+type Ack struct {
+	Seq          uint64 // highest batch ingested for the session
+	Backpressure bool   // writer queue full; the next ack is delayed
+}
+
+// MarshalFrame encodes f.
+func MarshalFrame(f Frame) []byte {
+	var b []byte
+	if f.Session != "" {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, f.Session)
+	}
+	if f.Seq != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, f.Seq)
+	}
+	var sub []byte
+	for k, v := range f.Labels {
+		sub = sub[:0]
+		sub = protowire.AppendTag(sub, 1, protowire.BytesType)
+		sub = protowire.AppendString(sub, k)
+		sub = protowire.AppendTag(sub, 2, protowire.BytesType)
+		sub = protowire.AppendString(sub, v)
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, sub)
+	}
+	for _, e := range f.Entries {
+		sub = sub[:0]
+		sub = protowire.AppendTag(sub, 1, protowire.Fixed64Type)
+		sub = protowire.AppendFixed64(sub, uint64(e.TimeUnixNano))
+		sub = protowire.AppendTag(sub, 2, protowire.BytesType)
+		sub = protowire.AppendString(sub, e.Line)
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, sub)
+	}
+	return b
+}
+
+// UnmarshalFrame decodes a frame.
+func UnmarshalFrame(data []byte) (Frame, error) {
+	var f Frame
+	err := fields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			f.Session = string(v)
+		case num == 2 && typ == protowire.VarintType:
+			f.Seq, _ = protowire.ConsumeVarint(v)
+		case num == 3 && typ == protowire.BytesType:
+			var name, value string
+			if err := fields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if typ == protowire.BytesType && num == 1 {
+					name = string(v)
+				} else if typ == protowire.BytesType && num == 2 {
+					value = string(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if f.Labels == nil {
+				f.Labels = make(map[string]string)
+			}
+			f.Labels[name] = value
+		case num == 4 && typ == protowire.BytesType:
+			var e Entry
+			if err := fields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num == 1 && typ == protowire.Fixed64Type {
+					ts, _ := protowire.ConsumeFixed64(v)
+					e.TimeUnixNano = int64(ts)
+				} else if num == 2 && typ == protowire.BytesType {
+					e.Line = string(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			f.Entries = append(f.Entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return Frame{}, fmt.Errorf("invalid push frame: %w", err)
+	}
+	return f, nil
+}
+
+// MarshalAck encodes a.
+func MarshalAck(a Ack) []byte {
+	var b []byte
+	if a.Seq != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, a.Seq)
+	}
+	if a.Backpressure {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b
+}
+
+// UnmarshalAck decodes an ack.
+func UnmarshalAck(data []byte) (Ack, error) {
+	var a Ack
+	err := fields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.VarintType {
+			return nil
+		}
+		x, _ := protowire.ConsumeVarint(v)
+		switch num {
+		case 1:
+			a.Seq = x
+		case 2:
+			a.Backpressure = x != 0
+		}
+		return nil
+	})
+	if err != nil {
+		return Ack{}, fmt.Errorf("invalid push ack: %w", err)
+	}
+	return a, nil
+}
+
+// fields calls fn for each field in data; v is the raw value for scalar
+// types and the payload for length-delimited ones.
+func fields(data []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		m := protowire.ConsumeFieldValue(num, typ, data)
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		v := data[:m]
+		if typ == protowire.BytesType {
+			v, _ = protowire.ConsumeBytes(v)
+		}
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+		data = data[m:]
+	}
+	return nil
+}
+
+// Codec passes messages through as *[]byte, so the stream needs no
+// generated types.
+type Codec struct{}
+
+// Marshal returns the bytes v points to.
+func (Codec) Marshal(v any) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("pushproto: unexpected type %T", v)
+	}
+	return *b, nil
+}
+
+// Unmarshal copies data into the slice v points to.
+func (Codec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("pushproto: unexpected type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// Name reports the proto content subtype, so servers forcing their own
+// codec interoperate.
+func (Codec) Name() string { return "proto" }
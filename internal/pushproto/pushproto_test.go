@@ -0,0 +1,38 @@
+package pushproto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	in := Frame{
+		Session: "abc",
+		Seq:     42,
+		Labels:  map[string]string{"pod": "api-0", "container": ""},
+		Entries: []Entry{{TimeUnixNano: 1700000000123456789, Line: "hello"}, {Line: ""}},
+	}
+	out, err := UnmarshalFrame(MarshalFrame(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+
+	if _, err := UnmarshalFrame([]byte{0x0a, 0xff}); err == nil {
+		t.Error("expected error for truncated frame")
+	}
+}
+
+func TestAckRoundTrip(t *testing.T) {
+	for _, in := range []Ack{{}, {Seq: 7}, {Seq: 9, Backpressure: true}} {
+		out, err := UnmarshalAck(MarshalAck(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != in {
+			t.Errorf("round trip = %+v, want %+v", out, in)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package recv
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Activity kinds shown in the TUI activity pane.
+const (
+	ActivityAlert   = "alert"
+	ActivityWebhook = "webhook"
+	ActivityAudit   = "audit"
+)
+
+// maxActivity is how many recent activity records Stats keeps.
+const maxActivity = 200
+
+// Activity is one notification-related event: an alert rule firing or
+// resolving, a webhook delivery attempt, or an audit record.
+type Activity struct {
+	Time    time.Time
+	Kind    string
+	Summary string
+	Failed  bool
+	Latency time.Duration // webhook deliveries only
+}
+
+// RecordActivity appends an activity record, discarding the oldest beyond
+// maxActivity.
+func (s *Stats) RecordActivity(a Activity) {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	s.mu.Lock()
+	s.activity = append(s.activity, a)
+	if over := len(s.activity) - maxActivity; over > 0 {
+		s.activity = append(s.activity[:0], s.activity[over:]...)
+	}
+	s.mu.Unlock()
+}
+
+// WebhookDelivery describes one webhook POST attempt.
+type WebhookDelivery struct {
+	URL     string
+	Event   string
+	Status  int // HTTP status, 0 when the request failed
+	Err     error
+	Latency time.Duration
+}
+
+// OK reports whether the receiver accepted the delivery.
+func (d WebhookDelivery) OK() bool {
+	return d.Err == nil && d.Status >= 200 && d.Status < 300
+}
+
+// Activity converts the delivery into an activity record.
+func (d WebhookDelivery) Activity() Activity {
+	target := d.URL
+	if u, err := url.Parse(d.URL); err == nil {
+		target = u.Redacted()
+	}
+	result := fmt.Sprintf("%d", d.Status)
+	if d.Err != nil {
+		result = d.Err.Error()
+	}
+	return Activity{
+		Kind:    ActivityWebhook,
+		Summary: fmt.Sprintf("%s → %s: %s", d.Event, target, result),
+		Failed:  !d.OK(),
+		Latency: d.Latency,
+	}
+}
+
+// AuditActivity converts an audit entry into an activity record. Per-push
+// records return ok=false: ingest volume is already on the stats pane.
+func AuditActivity(e AuditEntry) (a Activity, ok bool) {
+	if strings.HasSuffix(e.Event, "push_received") {
+		return Activity{}, false
+	}
+	summary := e.Event
+	if e.Lines > 0 {
+		summary += fmt.Sprintf(" (%d lines)", e.Lines)
+	}
+	if e.RemoteIP != "" {
+		summary += " from " + e.RemoteIP
+	}
+	return Activity{Time: e.Timestamp, Kind: ActivityAudit, Summary: summary}, true
+}
+
+// AlertActivity converts an alert rule state change into an activity record.
+func AlertActivity(rule AlertRule, value float64, firing bool) Activity {
+	state := "resolved"
+	if firing {
+		state = "FIRING"
+	}
+	summary := fmt.Sprintf("%s %s: %s %s %g (now %g)", rule.Name, state, rule.Metric, rule.Op, rule.Threshold, value)
+	if firing && rule.Detail != "" {
+		summary += " — " + rule.Detail
+	}
+	return Activity{Kind: ActivityAlert, Summary: summary, Failed: firing}
+}
@@ -1,8 +1,14 @@
 package recv
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path"
+	"sort"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -22,13 +28,44 @@ type AlertRulesFile struct {
 	Rules []AlertRule `yaml:"rules"`
 }
 
+// Alert silencing errors.
+var (
+	ErrUnknownAlert   = errors.New("unknown alert rule")
+	ErrAlertNotFiring = errors.New("alert rule is not firing")
+)
+
+// AlertSilence suppresses a rule's webhook events until it expires. An
+// acknowledgement is a silence taken while the rule was firing.
+type AlertSilence struct {
+	Rule    string    `json:"rule"`
+	Until   time.Time `json:"until"`
+	Ack     bool      `json:"ack,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// AlertStatus is the current state of one rule.
+type AlertStatus struct {
+	Name      string        `json:"name"`
+	Metric    string        `json:"metric"`
+	Op        string        `json:"op"`
+	Threshold float64       `json:"threshold"`
+	Firing    bool          `json:"firing"`
+	Silence   *AlertSilence `json:"silence,omitempty"`
+}
+
 // AlertEngine evaluates alert rules against pipeline snapshots and fires
 // webhook events when thresholds are crossed.
 type AlertEngine struct {
 	rules      []AlertRule
 	dispatcher *WebhookDispatcher
-	lastSnap   *Snapshot
-	fired      map[string]bool // per-rule dedup (hysteresis)
+	onChange   func(rule AlertRule, value float64, firing bool)
+	now        func() time.Time
+
+	mu          sync.Mutex
+	lastSnap    *Snapshot
+	fired       map[string]bool // per-rule dedup (hysteresis)
+	silences    map[string]AlertSilence
+	silenceFile string // silences persist here when set
 }
 
 // NewAlertEngine creates an engine with the given rules and webhook dispatcher.
@@ -36,10 +73,17 @@ func NewAlertEngine(rules []AlertRule, dispatcher *WebhookDispatcher) *AlertEngi
 	return &AlertEngine{
 		rules:      rules,
 		dispatcher: dispatcher,
+		now:        time.Now,
 		fired:      make(map[string]bool),
+		silences:   make(map[string]AlertSilence),
 	}
 }
 
+// SetOnChange sets a callback invoked when a rule fires or resolves.
+func (e *AlertEngine) SetOnChange(fn func(rule AlertRule, value float64, firing bool)) {
+	e.onChange = fn
+}
+
 // LoadAlertRules loads alert rules from a YAML file.
 func LoadAlertRules(path string) ([]AlertRule, error) {
 	data, err := os.ReadFile(path)
@@ -81,6 +125,9 @@ func validateRule(r AlertRule) error {
 // thresholds (with hysteresis — once fired, a rule won't re-fire until the
 // condition resolves).
 func (e *AlertEngine) Evaluate(snap Snapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	var dropRate float64
 	if e.lastSnap != nil {
 		dropRate = float64(snap.LogsDropped - e.lastSnap.LogsDropped)
@@ -110,13 +157,21 @@ func (e *AlertEngine) Evaluate(snap Snapshot) {
 		triggered := compare(val, rule.Op, rule.Threshold)
 		if triggered && !e.fired[rule.Name] {
 			e.fired[rule.Name] = true
-			e.dispatcher.Fire(WebhookEvent{
-				Event:     "alert",
-				Timestamp: time.Now(),
-				Detail:    rule.Detail,
-			})
+			if !e.silenced(rule.Name) {
+				e.dispatcher.Fire(WebhookEvent{
+					Event:     "alert",
+					Timestamp: time.Now(),
+					Detail:    rule.Detail,
+				})
+			}
+			if e.onChange != nil {
+				e.onChange(rule, val, true)
+			}
 		} else if !triggered && e.fired[rule.Name] {
 			e.fired[rule.Name] = false
+			if e.onChange != nil {
+				e.onChange(rule, val, false)
+			}
 		}
 	}
 
@@ -126,6 +181,8 @@ func (e *AlertEngine) Evaluate(snap Snapshot) {
 
 // Fired returns the names of rules currently in the fired state.
 func (e *AlertEngine) Fired() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	var names []string
 	for name, f := range e.fired {
 		if f {
@@ -135,6 +192,126 @@ func (e *AlertEngine) Fired() []string {
 	return names
 }
 
+// Silence suppresses webhook events of the named rule for d.
+func (e *AlertEngine) Silence(name string, d time.Duration) (AlertSilence, error) {
+	return e.addSilence(name, d, false)
+}
+
+// Ack acknowledges a firing rule, suppressing its webhook events for d so
+// it does not page again if it resolves and fires repeatedly.
+func (e *AlertEngine) Ack(name string, d time.Duration) (AlertSilence, error) {
+	return e.addSilence(name, d, true)
+}
+
+func (e *AlertEngine) addSilence(name string, d time.Duration, ack bool) (AlertSilence, error) {
+	if d <= 0 {
+		return AlertSilence{}, fmt.Errorf("silence duration must be positive, got %s", d)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.hasRule(name) {
+		return AlertSilence{}, fmt.Errorf("%w: %s", ErrUnknownAlert, name)
+	}
+	if ack && !e.fired[name] {
+		return AlertSilence{}, fmt.Errorf("%w: %s", ErrAlertNotFiring, name)
+	}
+	now := e.now()
+	sil := AlertSilence{Rule: name, Until: now.Add(d), Ack: ack, Created: now}
+	e.silences[name] = sil
+	return sil, e.saveSilences()
+}
+
+// Unsilence removes the silence or acknowledgement of the named rule.
+func (e *AlertEngine) Unsilence(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.hasRule(name) {
+		return fmt.Errorf("%w: %s", ErrUnknownAlert, name)
+	}
+	delete(e.silences, name)
+	return e.saveSilences()
+}
+
+// Status returns the state of every rule, in rule order.
+func (e *AlertEngine) Status() []AlertStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := make([]AlertStatus, len(e.rules))
+	for i, r := range e.rules {
+		status[i] = AlertStatus{Name: r.Name, Metric: r.Metric, Op: r.Op, Threshold: r.Threshold, Firing: e.fired[r.Name]}
+		if e.silenced(r.Name) {
+			sil := e.silences[r.Name]
+			status[i].Silence = &sil
+		}
+	}
+	return status
+}
+
+// SetSilenceFile loads silences saved at path by a previous run and saves
+// every change there, so silences survive a receiver restart.
+func (e *AlertEngine) SetSilenceFile(path string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.silenceFile = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read alert silences: %w", err)
+	}
+	var saved []AlertSilence
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("parse alert silences: %w", err)
+	}
+	for _, sil := range saved {
+		if e.hasRule(sil.Rule) {
+			e.silences[sil.Rule] = sil
+		}
+	}
+	return nil
+}
+
+// silenced reports whether the named rule has an unexpired silence;
+// e.mu must be held.
+func (e *AlertEngine) silenced(name string) bool {
+	sil, ok := e.silences[name]
+	return ok && e.now().Before(sil.Until)
+}
+
+// hasRule reports whether a rule is named name.
+func (e *AlertEngine) hasRule(name string) bool {
+	for _, r := range e.rules {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// saveSilences writes the unexpired silences to the silence file, if any;
+// e.mu must be held.
+func (e *AlertEngine) saveSilences() error {
+	if e.silenceFile == "" {
+		return nil
+	}
+	saved := make([]AlertSilence, 0, len(e.silences))
+	for name, sil := range e.silences {
+		if e.silenced(name) {
+			saved = append(saved, sil)
+		}
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Rule < saved[j].Rule })
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal alert silences: %w", err)
+	}
+	if err := os.WriteFile(e.silenceFile, data, 0o644); err != nil {
+		return fmt.Errorf("write alert silences: %w", err)
+	}
+	return nil
+}
+
 func compare(val float64, op string, threshold float64) bool {
 	switch op {
 	case "gt":
@@ -148,3 +325,85 @@ func compare(val float64, op string, threshold float64) bool {
 	}
 	return false
 }
+
+const (
+	// DefaultSilence is the silence duration when an admin request names none.
+	DefaultSilence = time.Hour
+	// AlertSilencesFile holds a receiver's alert silences in the capture dir.
+	AlertSilencesFile = "alert-silences.json"
+)
+
+// SetAlertEngine enables the /admin/alerts endpoints, which list rules and
+// acknowledge or silence them.
+func (s *Server) SetAlertEngine(e *AlertEngine) {
+	s.alerts = e
+}
+
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if s.alerts == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.alerts.Status())
+}
+
+// handleAlertSilence serves POST /admin/alerts/{rule}/ack and .../silence;
+// the optional for query parameter is a Go duration.
+func (s *Server) handleAlertSilence(w http.ResponseWriter, r *http.Request) {
+	if s.alerts == nil {
+		http.NotFound(w, r)
+		return
+	}
+	d := DefaultSilence
+	if v := r.URL.Query().Get("for"); v != "" {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("invalid duration %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	rule := r.PathValue("rule")
+	silence, event := s.alerts.Silence, "alert_silence"
+	if path.Base(r.URL.Path) == "ack" {
+		silence, event = s.alerts.Ack, "alert_ack"
+	}
+	sil, err := silence(rule, d)
+	if !writeAlertError(w, err) {
+		return
+	}
+	s.auditRequest(r, AuditEntry{Event: event, Detail: fmt.Sprintf("%s for %s", rule, d)})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sil)
+}
+
+func (s *Server) handleAlertUnsilence(w http.ResponseWriter, r *http.Request) {
+	if s.alerts == nil {
+		http.NotFound(w, r)
+		return
+	}
+	rule := r.PathValue("rule")
+	if !writeAlertError(w, s.alerts.Unsilence(rule)) {
+		return
+	}
+	s.auditRequest(r, AuditEntry{Event: "alert_unsilence", Detail: rule})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeAlertError writes the response for a failed silence change and
+// reports whether err was nil.
+func writeAlertError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrUnknownAlert):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrAlertNotFiring):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
@@ -2,12 +2,15 @@ package recv
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 )
 
 type alertCapture struct {
@@ -290,3 +293,186 @@ func waitForAlerts(t *testing.T, c *alertCapture, want int) {
 	}
 	t.Fatalf("timed out waiting for %d alerts, got %d", want, c.count())
 }
+
+// awaitAlerts polls for webhook deliveries for up to two seconds.
+func awaitAlerts(t *testing.T, c *alertCapture, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.count() < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d alerts, got %d", want, c.count())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAlertEngine_SilenceSuppressesWebhook(t *testing.T) {
+	rules := []AlertRule{
+		{Name: "high_drops", Metric: "logs_dropped", Op: "gt", Threshold: 100, Detail: "drops"},
+		{Name: "disk_full", Metric: "disk_pct", Op: "gt", Threshold: 90, Detail: "disk"},
+	}
+	engine, capture, cleanup := newAlertTestSetup(t, rules)
+	defer cleanup()
+
+	if _, err := engine.Silence("high_drops", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	engine.Evaluate(Snapshot{LogsDropped: 200, DiskUsage: 95, DiskCap: 100})
+	awaitAlerts(t, capture, 1)
+	time.Sleep(50 * time.Millisecond)
+	if capture.count() != 1 || capture.lastDetail() != "disk" {
+		t.Errorf("events = %d (last %q), want only the unsilenced rule", capture.count(), capture.lastDetail())
+	}
+
+	// silenced rules still track state
+	if len(engine.Fired()) != 2 {
+		t.Errorf("fired = %v, want both rules", engine.Fired())
+	}
+}
+
+func TestAlertEngine_AckStopsRepeats(t *testing.T) {
+	rules := []AlertRule{{Name: "high_drops", Metric: "logs_dropped", Op: "gt", Threshold: 100}}
+	engine, capture, cleanup := newAlertTestSetup(t, rules)
+	defer cleanup()
+
+	if _, err := engine.Ack("high_drops", time.Hour); !errors.Is(err, ErrAlertNotFiring) {
+		t.Errorf("ack before firing: err = %v, want ErrAlertNotFiring", err)
+	}
+	engine.Evaluate(Snapshot{LogsDropped: 200})
+	awaitAlerts(t, capture, 1)
+
+	sil, err := engine.Ack("high_drops", time.Hour)
+	if err != nil || !sil.Ack {
+		t.Fatalf("Ack = %+v, %v", sil, err)
+	}
+	// flapping no longer pages
+	engine.Evaluate(Snapshot{LogsDropped: 50})
+	engine.Evaluate(Snapshot{LogsDropped: 200})
+	time.Sleep(50 * time.Millisecond)
+	if capture.count() != 1 {
+		t.Errorf("fired %d times after ack, want 1", capture.count())
+	}
+
+	if err := engine.Unsilence("high_drops"); err != nil {
+		t.Fatal(err)
+	}
+	engine.Evaluate(Snapshot{LogsDropped: 50})
+	engine.Evaluate(Snapshot{LogsDropped: 200})
+	awaitAlerts(t, capture, 2)
+}
+
+func TestAlertEngine_SilenceExpires(t *testing.T) {
+	engine := NewAlertEngine([]AlertRule{{Name: "r", Metric: "disk_pct", Op: "gt", Threshold: 90}}, nil)
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+	if _, err := engine.Silence("r", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if st := engine.Status(); st[0].Silence == nil {
+		t.Fatal("expected an active silence")
+	}
+	now = now.Add(2 * time.Minute)
+	if st := engine.Status(); st[0].Silence != nil {
+		t.Errorf("silence still active after expiry: %+v", st[0].Silence)
+	}
+
+	if _, err := engine.Silence("nope", time.Minute); !errors.Is(err, ErrUnknownAlert) {
+		t.Errorf("unknown rule: err = %v", err)
+	}
+	if _, err := engine.Silence("r", 0); err == nil {
+		t.Error("expected error for zero duration")
+	}
+}
+
+func TestAlertEngine_SilenceFile(t *testing.T) {
+	rules := []AlertRule{{Name: "r", Metric: "disk_pct", Op: "gt", Threshold: 90}}
+	path := filepath.Join(t.TempDir(), AlertSilencesFile)
+
+	engine := NewAlertEngine(rules, nil)
+	if err := engine.SetSilenceFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Silence("r", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted receiver keeps the silence
+	restarted := NewAlertEngine(rules, nil)
+	if err := restarted.SetSilenceFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if st := restarted.Status(); st[0].Silence == nil {
+		t.Error("silence lost across restart")
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewAlertEngine(rules, nil).SetSilenceFile(path); err == nil {
+		t.Error("expected error for corrupt silence file")
+	}
+}
+
+func TestServer_AlertAdmin(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	ts := httptest.NewServer(srv.httpSrv.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/admin/alerts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("without alert engine status = %d, want 404", resp.StatusCode)
+	}
+
+	engine := NewAlertEngine([]AlertRule{{Name: "disk_full", Metric: "disk_pct", Op: "gt", Threshold: 90}}, nil)
+	srv.SetAlertEngine(engine)
+
+	do := func(method, path string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := do(http.MethodPost, "/admin/alerts/disk_full/ack"); code != http.StatusConflict {
+		t.Errorf("ack of a quiet rule = %d, want 409", code)
+	}
+	if code := do(http.MethodPost, "/admin/alerts/nope/silence"); code != http.StatusNotFound {
+		t.Errorf("silence of an unknown rule = %d, want 404", code)
+	}
+	if code := do(http.MethodPost, "/admin/alerts/disk_full/silence?for=soon"); code != http.StatusBadRequest {
+		t.Errorf("bad duration = %d, want 400", code)
+	}
+	if code := do(http.MethodPost, "/admin/alerts/disk_full/silence?for=30m"); code != http.StatusOK {
+		t.Errorf("silence = %d, want 200", code)
+	}
+
+	resp, err = http.Get(ts.URL + "/admin/alerts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status []AlertStatus
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 1 || status[0].Silence == nil || time.Until(status[0].Silence.Until) > 31*time.Minute {
+		t.Errorf("status = %+v, want disk_full silenced for 30m", status)
+	}
+
+	if code := do(http.MethodDelete, "/admin/alerts/disk_full/silence"); code != http.StatusNoContent {
+		t.Errorf("unsilence = %d, want 204", code)
+	}
+	if st := engine.Status(); st[0].Silence != nil {
+		t.Error("silence not removed")
+	}
+}
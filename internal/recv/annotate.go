@@ -0,0 +1,135 @@
+package recv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AnnotationLabel marks the entries inserted through the annotate API, so
+// readers can tell them from log lines.
+const AnnotationLabel = "logtap_annotation"
+
+// PhaseLabel is the annotation label that starts a named test phase; the
+// phase lasts until the next annotation carrying it.
+const PhaseLabel = "phase"
+
+// MetricLabel names the harness metric an annotation carries a sample of,
+// with the sample in MetricValueLabel, e.g. metric=requests value=1250.
+const (
+	MetricLabel      = "metric"
+	MetricValueLabel = "value"
+)
+
+// AnnotationPrefix starts the message of an annotation posted without one.
+const AnnotationPrefix = "[logtap] annotation"
+
+// maxAnnotationLabels caps the labels of one annotation.
+const maxAnnotationLabels = 32
+
+// Annotation is the body of POST /api/v1/annotate: a marker an external
+// system such as a load generator or CI job inserts into the capture.
+type Annotation struct {
+	Labels    map[string]string `json:"labels"`
+	Message   string            `json:"msg,omitempty"` // defaults to AnnotationPrefix and the labels
+	Timestamp time.Time         `json:"ts,omitzero"`   // defaults to the time received
+}
+
+// Entry returns the capture entry of the annotation, received at now.
+func (a Annotation) Entry(now time.Time) (LogEntry, error) {
+	if len(a.Labels) == 0 {
+		return LogEntry{}, fmt.Errorf("annotation needs at least one label")
+	}
+	if len(a.Labels) > maxAnnotationLabels {
+		return LogEntry{}, fmt.Errorf("too many labels (max %d)", maxAnnotationLabels)
+	}
+	keys := make([]string, 0, len(a.Labels))
+	for k, v := range a.Labels {
+		if !validLabelName(k) {
+			return LogEntry{}, fmt.Errorf("invalid label name %q", k)
+		}
+		if k == AnnotationLabel {
+			return LogEntry{}, fmt.Errorf("label %s is set by the receiver", AnnotationLabel)
+		}
+		if err := checkCaptureText("label "+k, v); err != nil {
+			return LogEntry{}, err
+		}
+		keys = append(keys, k)
+	}
+	if err := checkCaptureText("msg", a.Message); err != nil {
+		return LogEntry{}, err
+	}
+	slices.Sort(keys)
+
+	e := LogEntry{Timestamp: a.Timestamp, Message: a.Message, Labels: make(map[string]string, len(a.Labels)+1)}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = now
+	}
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		e.Labels[k] = a.Labels[k]
+		pairs[i] = k + "=" + a.Labels[k]
+	}
+	e.Labels[AnnotationLabel] = "true"
+	if e.Message == "" {
+		e.Message = AnnotationPrefix + ": " + strings.Join(pairs, " ")
+	}
+	return e, nil
+}
+
+// IsAnnotation reports whether labels are those of an annotation entry.
+func IsAnnotation(labels map[string]string) bool {
+	return labels[AnnotationLabel] == "true"
+}
+
+// validLabelName reports whether name is a Prometheus/Loki label name.
+func validLabelName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// handleAnnotate stores a marker entry posted by an external system. Its
+// message is redacted like a pushed line's, but it skips processors and
+// sampling, so markers always reach the capture.
+func (s *Server) handleAnnotate(w http.ResponseWriter, r *http.Request) {
+	var a Annotation
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&a); err != nil {
+		http.Error(w, fmt.Sprintf("invalid annotation: %v", err), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	entry, err := a.Entry(now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry.Labels = withSession(entry.Labels, r.Header.Get(SessionHeader))
+	if err := checkSession(r.Context(), func(int) map[string]string { return entry.Labels }, 1); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if s.redactor != nil {
+		entry.Message = s.redactor.Redact(entry.Message)
+	}
+
+	if !s.enqueue(&entry, now, nil) {
+		http.Error(w, "write queue full", http.StatusServiceUnavailable)
+		return
+	}
+	s.auditRequest(r, AuditEntry{Event: "annotation", Lines: 1, Detail: entry.Message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(entry)
+}
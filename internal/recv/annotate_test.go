@@ -0,0 +1,98 @@
+package recv
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnnotationEntry(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	e, err := Annotation{Labels: map[string]string{"phase": "rampup", "run": "42"}}.Entry(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Timestamp.Equal(now) || !IsAnnotation(e.Labels) || e.Labels[PhaseLabel] != "rampup" {
+		t.Errorf("entry = %+v", e)
+	}
+	if want := AnnotationPrefix + ": phase=rampup run=42"; e.Message != want {
+		t.Errorf("msg = %q, want %q", e.Message, want)
+	}
+
+	for _, bad := range []Annotation{
+		{},
+		{Labels: map[string]string{"1phase": "x"}},
+		{Labels: map[string]string{"a-b": "x"}},
+		{Labels: map[string]string{AnnotationLabel: "false"}},
+		{Labels: map[string]string{"phase": "x"}, Message: strings.Repeat("x", maxCaptureInfoBytes+1)},
+	} {
+		if _, err := bad.Entry(now); err == nil {
+			t.Errorf("Entry(%.40v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestServer_AnnotateAPI(t *testing.T) {
+	ring := NewLogRing(0)
+	w := NewWriter(1024, &bytes.Buffer{}, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/annotate", strings.NewReader(body))
+		r.Header.Set(SessionHeader, "load-a")
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := post(`{"labels":{"phase":"steady"},"ts":"2024-01-15T10:05:00Z","msg":"steady state"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got LogEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Message != "steady state" || got.Labels["session"] != "load-a" || !IsAnnotation(got.Labels) {
+		t.Errorf("response = %+v", got)
+	}
+
+	snap := ring.Snapshot()
+	if len(snap) != 1 || snap[0].Labels[PhaseLabel] != "steady" ||
+		!snap[0].Timestamp.Equal(time.Date(2024, 1, 15, 10, 5, 0, 0, time.UTC)) {
+		t.Errorf("ring = %+v", snap)
+	}
+
+	for _, body := range []string{`{"labels":{}}`, `{"labels":{"phase":"x"},"extra":1}`, `not json`} {
+		if rec := post(body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestServer_AnnotateRedacted(t *testing.T) {
+	redactor, err := NewRedactor([]string{"email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := NewLogRing(0)
+	w := NewWriter(1024, &bytes.Buffer{}, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, redactor, nil, nil, ring)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/annotate",
+		strings.NewReader(`{"labels":{"phase":"steady"},"msg":"started by alice@example.com"}`))
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if snap := ring.Snapshot(); len(snap) != 1 || snap[0].Message != "started by [REDACTED:email]" {
+		t.Errorf("ring = %+v, want the message redacted", snap)
+	}
+}
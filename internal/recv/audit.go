@@ -11,18 +11,27 @@ import (
 // AuditEntry records a single auditable event.
 type AuditEntry struct {
 	Timestamp time.Time     `json:"timestamp"`
+	Seq       uint64        `json:"seq"`               // 1-based; gaps in a remote copy reveal lost records
+	Capture   string        `json:"capture,omitempty"` // capture directory, set when shipping to sinks
 	Event     string        `json:"event"`
 	RemoteIP  string        `json:"remote_ip,omitempty"`
+	Proto     string        `json:"proto,omitempty"` // http or https, for HTTP requests
 	Lines     int           `json:"lines,omitempty"`
 	Bytes     int           `json:"bytes,omitempty"`
 	Duration  time.Duration `json:"duration_ms,omitempty"`
+	Detail    string        `json:"detail,omitempty"`
 }
 
-// AuditLogger writes append-only JSONL audit records.
+// AuditLogger writes append-only JSONL audit records and, when sinks are
+// attached, ships a copy of each record off the capture host.
 type AuditLogger struct {
-	mu   sync.Mutex
-	file *os.File
-	enc  *json.Encoder
+	mu       sync.Mutex
+	file     *os.File
+	dir      string
+	seq      uint64
+	shippers []*auditShipper
+	closed   bool
+	onLog    func(AuditEntry)
 }
 
 // NewAuditLogger creates an audit logger writing to <dir>/audit.jsonl.
@@ -32,7 +41,27 @@ func NewAuditLogger(dir string) (*AuditLogger, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &AuditLogger{file: f, enc: json.NewEncoder(f)}, nil
+	return &AuditLogger{file: f, dir: dir}, nil
+}
+
+// AddSink ships every record logged from now on to s. Delivery is
+// asynchronous and never blocks Log; onError (may be nil) is called when the
+// sink starts failing, not for every failed record.
+func (a *AuditLogger) AddSink(s AuditSink, onError func(sink string, err error)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.shippers = append(a.shippers, newAuditShipper(s, onError))
+}
+
+// SetOnLog sets a callback invoked with each entry after it is written. It
+// runs under the logger's lock and must not call Log.
+func (a *AuditLogger) SetOnLog(fn func(AuditEntry)) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.onLog = fn
+	a.mu.Unlock()
 }
 
 // Log writes an audit entry. Safe to call from multiple goroutines.
@@ -44,13 +73,58 @@ func (a *AuditLogger) Log(entry AuditEntry) {
 	entry.Timestamp = time.Now()
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	_ = a.enc.Encode(entry)
+	if a.closed {
+		return
+	}
+	a.seq++
+	entry.Seq = a.seq
+	if len(a.shippers) > 0 {
+		entry.Capture = a.dir
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+	_, _ = a.file.Write(data)
+	for _, s := range a.shippers {
+		s.enqueue(data)
+	}
+	if a.onLog != nil {
+		a.onLog(entry)
+	}
+}
+
+// SinkDropped returns the number of records that sinks failed to deliver or
+// had no queue space for. It is final once Close returns.
+func (a *AuditLogger) SinkDropped() int64 {
+	if a == nil {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var n int64
+	for _, s := range a.shippers {
+		n += s.dropped.Load()
+	}
+	return n
 }
 
-// Close flushes and closes the audit log file.
+// Close flushes pending sink deliveries and closes the audit log file.
 func (a *AuditLogger) Close() error {
 	if a == nil {
 		return nil
 	}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	shippers := a.shippers
+	a.closed = true
+	a.mu.Unlock()
+	for _, s := range shippers {
+		s.close()
+	}
 	return a.file.Close()
 }
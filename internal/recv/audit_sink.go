@@ -0,0 +1,260 @@
+package recv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	auditQueueSize    = 4096
+	auditBatchSize    = 256
+	auditSendTimeout  = 5 * time.Second
+	auditDrainTimeout = 5 * time.Second
+	auditRetries      = 3
+)
+
+// AuditSink receives audit records as they are written. Each record is one
+// JSON line including its trailing newline, byte-identical to the line in
+// audit.jsonl.
+type AuditSink interface {
+	Send(ctx context.Context, records [][]byte) error
+	Close() error
+	String() string
+}
+
+// NewAuditSink creates a sink from a URL:
+//
+//	https://host/path        POST records as NDJSON (also http://)
+//	syslog://host[:port]     RFC 5424 over UDP (port 514)
+//	syslog+udp://host[:port] same as syslog://
+//	syslog+tcp://host[:port] RFC 5424 over TCP, octet-counted (RFC 6587)
+//
+// authSpec authenticates HTTP sinks, in --webhook-auth format.
+func NewAuditSink(spec, authSpec string) (AuditSink, error) {
+	u, err := url.Parse(spec)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid audit sink %q (expected https://, syslog://, or syslog+tcp:// URL)", spec)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		mode, val, err := ParseWebhookAuth(authSpec)
+		if err != nil {
+			return nil, err
+		}
+		return &httpAuditSink{url: spec, client: &http.Client{Timeout: auditSendTimeout}, authMode: mode, authValue: val}, nil
+	case "syslog", "syslog+udp", "syslog+tcp":
+		network := "udp"
+		if u.Scheme == "syslog+tcp" {
+			network = "tcp"
+		}
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "514")
+		}
+		host, _ := os.Hostname()
+		if host == "" {
+			host = "-"
+		}
+		return &syslogAuditSink{network: network, addr: addr, hostname: host}, nil
+	default:
+		return nil, fmt.Errorf("unsupported audit sink scheme %q (use https, syslog, or syslog+tcp)", u.Scheme)
+	}
+}
+
+// httpAuditSink posts batches of records as NDJSON.
+type httpAuditSink struct {
+	url       string
+	client    *http.Client
+	authMode  string
+	authValue string
+}
+
+func (s *httpAuditSink) Send(ctx context.Context, records [][]byte) error {
+	body := bytes.Join(records, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	setWebhookAuth(req, s.authMode, s.authValue, body)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *httpAuditSink) Close() error { return nil }
+
+func (s *httpAuditSink) String() string {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return s.url
+	}
+	return u.Redacted()
+}
+
+// syslogAuditSink sends one RFC 5424 message per record.
+type syslogAuditSink struct {
+	network  string
+	addr     string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// syslogPriority is facility 13 (log audit), severity 6 (informational).
+const syslogPriority = 13*8 + 6
+
+func (s *syslogAuditSink) Send(ctx context.Context, records [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = s.conn.SetWriteDeadline(dl)
+	}
+	for _, rec := range records {
+		msg := s.format(bytes.TrimRight(rec, "\n"))
+		if s.network == "tcp" {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			_ = s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogAuditSink) format(rec []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s logtap %d audit - ", syslogPriority,
+		time.Now().UTC().Format(time.RFC3339Nano), s.hostname, os.Getpid())
+	b.Write(rec)
+	return b.Bytes()
+}
+
+func (s *syslogAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *syslogAuditSink) String() string {
+	return "syslog+" + s.network + "://" + s.addr
+}
+
+// auditShipper delivers records to one sink in the background, in batches.
+// When the queue is full, records are dropped rather than blocking Log.
+type auditShipper struct {
+	sink    AuditSink
+	onError func(sink string, err error)
+	queue   chan []byte
+	done    chan struct{}
+	stop    chan struct{}
+	dropped atomic.Int64
+	failing bool
+}
+
+func newAuditShipper(sink AuditSink, onError func(string, error)) *auditShipper {
+	s := &auditShipper{
+		sink:    sink,
+		onError: onError,
+		queue:   make(chan []byte, auditQueueSize),
+		done:    make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *auditShipper) enqueue(rec []byte) {
+	select {
+	case s.queue <- rec:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *auditShipper) run() {
+	defer close(s.done)
+	for rec := range s.queue {
+		batch := [][]byte{rec}
+	fill:
+		for len(batch) < auditBatchSize {
+			select {
+			case r, ok := <-s.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, r)
+			default:
+				break fill
+			}
+		}
+		s.deliver(batch)
+	}
+}
+
+func (s *auditShipper) deliver(batch [][]byte) {
+	var err error
+	for attempt := 0; attempt < auditRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-s.stop:
+				attempt = auditRetries // last try, then give up
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), auditSendTimeout)
+		err = s.sink.Send(ctx, batch)
+		cancel()
+		if err == nil {
+			s.failing = false
+			return
+		}
+	}
+	s.dropped.Add(int64(len(batch)))
+	if !s.failing && s.onError != nil {
+		s.onError(s.sink.String(), err)
+	}
+	s.failing = true
+}
+
+// close flushes queued records, waiting at most auditDrainTimeout.
+func (s *auditShipper) close() {
+	close(s.queue)
+	select {
+	case <-s.done:
+	case <-time.After(auditDrainTimeout):
+		close(s.stop)
+	}
+	_ = s.sink.Close()
+}
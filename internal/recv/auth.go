@@ -0,0 +1,221 @@
+package recv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ppiankov/logtap/internal/pushproto"
+)
+
+// PushAuth checks bearer tokens on the push endpoints. A push is
+// authorized by the receiver token itself or by a session token derived
+// from it with SessionToken, which `logtap tap` hands to forwarders so the
+// receiver token never lands in pod specs.
+type PushAuth struct {
+	token []byte
+}
+
+// NewPushAuth returns nil for an empty token, leaving pushes open.
+func NewPushAuth(token string) *PushAuth {
+	if token == "" {
+		return nil
+	}
+	return &PushAuth{token: []byte(token)}
+}
+
+// SessionToken derives the token for one tap session: the session ID and
+// an HMAC-SHA256 of it keyed by the receiver token, joined by a dot.
+func SessionToken(token, session string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(session))
+	return session + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Check reports whether an Authorization header value carries a valid
+// token: as a bearer token, or as the basic auth password for clients that
+// only support basic auth (the user name is ignored). session is the
+// session a session token is bound to, or empty for the receiver token.
+func (a *PushAuth) Check(authorization string) (session string, ok bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !ok {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	switch {
+	case strings.EqualFold(scheme, "Bearer"):
+	case strings.EqualFold(scheme, "Basic"):
+		creds, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return "", false
+		}
+		_, token, _ = strings.Cut(string(creds), ":")
+	default:
+		return "", false
+	}
+	if subtle.ConstantTimeCompare([]byte(token), a.token) == 1 {
+		return "", true
+	}
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 {
+		return "", false
+	}
+	want := SessionToken(string(a.token), token[:i])
+	if !hmac.Equal([]byte(token), []byte(want)) {
+		return "", false
+	}
+	return token[:i], true
+}
+
+// authSessionKey is the context key for the session a push's session token
+// is bound to.
+type authSessionKey struct{}
+
+// boundSession returns the session the token of a push is bound to, or
+// empty when it was authorized by the receiver token or auth is off.
+func boundSession(ctx context.Context) string {
+	session, _ := ctx.Value(authSessionKey{}).(string)
+	return session
+}
+
+// checkSession refuses a push authorized by a session token when any of
+// its n streams or entries is labelled with another session. Run it after
+// the session label has been filled in from SessionHeader.
+func checkSession(ctx context.Context, labels func(i int) map[string]string, n int) error {
+	bound := boundSession(ctx)
+	if bound == "" {
+		return nil
+	}
+	for i := range n {
+		if got := labels(i)["session"]; got != bound {
+			return fmt.Errorf("session token for %q cannot push to session %q", bound, truncateLabel(got))
+		}
+	}
+	return nil
+}
+
+// requireAuth wraps a push handler, rejecting requests without a valid
+// token with 401. endpoint names it in metrics and the audit log. A session
+// token binds the push to its session: SessionHeader is set to it, and a
+// different SessionHeader is refused with 403.
+func (s *Server) requireAuth(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.auth == nil {
+			h(w, r)
+			return
+		}
+		session, ok := s.auth.Check(r.Header.Get("Authorization"))
+		if !ok {
+			s.rejectPush(endpoint, s.trusted.ClientIP(r), s.trusted.Proto(r))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="logtap"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if session != "" {
+			if hs := r.Header.Get(SessionHeader); hs != "" && hs != session {
+				s.rejectPush(endpoint, s.trusted.ClientIP(r), s.trusted.Proto(r))
+				http.Error(w, fmt.Sprintf("session token for %q cannot push to session %q", session, truncateLabel(hs)), http.StatusForbidden)
+				return
+			}
+			r.Header.Set(SessionHeader, session)
+			r = r.WithContext(context.WithValue(r.Context(), authSessionKey{}, session))
+		}
+		h(w, r)
+	}
+}
+
+// requireReceiverToken wraps a read or admin handler, accepting only the
+// receiver token: session tokens sit in pod specs and may only push.
+func (s *Server) requireReceiverToken(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.auth == nil {
+			h(w, r)
+			return
+		}
+		session, ok := s.auth.Check(r.Header.Get("Authorization"))
+		if !ok || session != "" {
+			s.rejectPush(endpoint, s.trusted.ClientIP(r), s.trusted.Proto(r))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="logtap"`)
+			if !ok {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "session tokens may only push", http.StatusForbidden)
+			}
+			return
+		}
+		h(w, r)
+	}
+}
+
+// grpcAuth rejects gRPC calls whose "authorization" metadata does not carry
+// a valid token. The returned context carries the session a session token
+// is bound to.
+func (s *Server) grpcAuth(ctx context.Context, method string) (context.Context, error) {
+	if s.auth == nil {
+		return ctx, nil
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, v := range md.Get("authorization") {
+			if session, ok := s.auth.Check(v); ok {
+				if session != "" {
+					ctx = context.WithValue(ctx, authSessionKey{}, session)
+				}
+				return ctx, nil
+			}
+		}
+	}
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = stripPort(p.Addr.String())
+	}
+	endpoint := "otlp_grpc"
+	if method == pushproto.FullMethod {
+		endpoint = "push_stream"
+	}
+	s.rejectPush(endpoint, remote, "")
+	return nil, status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+func (s *Server) grpcUnaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.grpcAuth(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) grpcStreamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.grpcAuth(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, authedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authedStream overrides a stream's context with the one from grpcAuth.
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a authedStream) Context() context.Context { return a.ctx }
+
+// rejectPush counts and audits a push refused for a missing or bad token.
+func (s *Server) rejectPush(endpoint, clientIP, proto string) {
+	if s.metrics != nil {
+		s.metrics.PushUnauthorized.WithLabelValues(endpoint).Inc()
+	}
+	s.audit.Log(AuditEntry{Event: "push_unauthorized", RemoteIP: clientIP, Proto: proto, Detail: endpoint})
+}
@@ -0,0 +1,323 @@
+package recv
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ppiankov/logtap/internal/pushproto"
+)
+
+func TestPushAuth_Check(t *testing.T) {
+	a := NewPushAuth("s3cret")
+	session := SessionToken("s3cret", "lt-a3f9")
+	basic := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+
+	tests := []struct {
+		name        string
+		auth        string
+		want        bool
+		wantSession string
+	}{
+		{"receiver token", "Bearer s3cret", true, ""},
+		{"scheme is case-insensitive", "bearer s3cret", true, ""},
+		{"session token", "Bearer " + session, true, "lt-a3f9"},
+		{"basic password", basic("logtap", "s3cret"), true, ""},
+		{"basic session token", basic("", session), true, "lt-a3f9"},
+		{"wrong token", "Bearer nope", false, ""},
+		{"session signed by another token", "Bearer " + SessionToken("other", "lt-a3f9"), false, ""},
+		{"session ID swapped", "Bearer lt-b001" + session[strings.IndexByte(session, '.'):], false, ""},
+		{"missing", "", false, ""},
+		{"no scheme", "s3cret", false, ""},
+		{"unknown scheme", "Token s3cret", false, ""},
+		{"bad basic encoding", "Basic !!!", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSession, got := a.Check(tt.auth)
+			if got != tt.want || gotSession != tt.wantSession {
+				t.Errorf("Check(%q) = %q, %v, want %q, %v", tt.auth, gotSession, got, tt.wantSession, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPushAuth_Empty(t *testing.T) {
+	if NewPushAuth("") != nil {
+		t.Error("empty token should disable auth")
+	}
+}
+
+func TestServer_PushAuth(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+
+	audit, err := NewAuditLogger(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := prometheus.NewRegistry()
+	srv := NewServer(":0", w, nil, NewMetrics(reg), nil, nil)
+	srv.SetAuditLogger(audit)
+	srv.SetPushAuth(NewPushAuth("s3cret"))
+
+	push := func(path, auth string) int {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"msg":"hi"}`))
+		r.RemoteAddr = "10.1.2.3:4000"
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	if code := push("/logtap/raw", ""); code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want 401", code)
+	}
+	if code := push("/loki/api/v1/push", "Bearer nope"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", code)
+	}
+	if code := push("/logtap/raw", "Bearer "+SessionToken("s3cret", "lt-a3f9")); code != http.StatusNoContent {
+		t.Errorf("session token: status = %d, want 204", code)
+	}
+	if err := audit.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f := gatherMetric(t, reg, "logtap_push_unauthorized_total")
+	if f == nil {
+		t.Fatal("logtap_push_unauthorized_total not found")
+	}
+	byEndpoint := make(map[string]float64)
+	for _, m := range f.GetMetric() {
+		byEndpoint[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+	}
+	if byEndpoint["raw"] != 1 || byEndpoint["loki"] != 1 {
+		t.Errorf("unauthorized = %v, want raw=1 loki=1", byEndpoint)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rejected []AuditEntry
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var e AuditEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Event == "push_unauthorized" {
+			rejected = append(rejected, e)
+		}
+	}
+	if len(rejected) != 2 {
+		t.Fatalf("audited %d rejections, want 2", len(rejected))
+	}
+	if rejected[0].RemoteIP != "10.1.2.3" || rejected[0].Detail != "raw" {
+		t.Errorf("audit entry = %+v", rejected[0])
+	}
+}
+
+func TestServer_AdminAuth(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	srv.SetPushAuth(NewPushAuth("s3cret"))
+
+	want := map[string]int{
+		"": http.StatusUnauthorized,
+		"Bearer " + SessionToken("s3cret", "lt-a3f9"): http.StatusForbidden,
+	}
+	for _, route := range []string{
+		"GET /api/v1/watermark",
+		"GET /logtap/api/v1/query",
+		"GET /logtap/api/v1/tail",
+		"GET /logtap/api/v1/capture",
+		"PUT /logtap/api/v1/capture",
+		"POST /admin/debug",
+		"GET /admin/alerts",
+		"POST /admin/alerts/high_drops/ack",
+		"POST /admin/alerts/high_drops/silence",
+		"DELETE /admin/alerts/high_drops/silence",
+	} {
+		method, path, _ := strings.Cut(route, " ")
+		for _, auth := range []string{"", "Bearer " + SessionToken("s3cret", "lt-a3f9"), "Bearer s3cret"} {
+			r := httptest.NewRequest(method, path, nil)
+			if auth != "" {
+				r.Header.Set("Authorization", auth)
+			}
+			rec := httptest.NewRecorder()
+			srv.httpSrv.Handler.ServeHTTP(rec, r)
+			if code, denied := want[auth]; denied && rec.Code != code {
+				t.Errorf("%s with auth %q: status = %d, want %d", route, auth, rec.Code, code)
+			} else if !denied && (rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden) {
+				t.Errorf("%s with receiver token: status = %d", route, rec.Code)
+			}
+		}
+	}
+}
+
+func TestServer_SessionTokenBinding(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	srv.SetPushAuth(NewPushAuth("s3cret"))
+	token := "Bearer " + SessionToken("s3cret", "lt-a3f9")
+
+	push := func(path, header, body string) int {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Authorization", token)
+		if header != "" {
+			r.Header.Set(SessionHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	if code := push("/logtap/raw", "lt-b001", `{"msg":"hi"}`); code != http.StatusForbidden {
+		t.Errorf("other session header: status = %d, want 403", code)
+	}
+	if code := push("/logtap/raw", "", `{"msg":"hi","labels":{"session":"lt-b001"}}`); code != http.StatusForbidden {
+		t.Errorf("other session label: status = %d, want 403", code)
+	}
+	lokiBody := `{"streams":[{"stream":{"session":"lt-b001"},"values":[["1700000000000000000","hi"]]}]}`
+	if code := push("/loki/api/v1/push", "", lokiBody); code != http.StatusForbidden {
+		t.Errorf("loki other session label: status = %d, want 403", code)
+	}
+	if got := len(ring.Snapshot()); got != 0 {
+		t.Fatalf("refused pushes ingested %d entries", got)
+	}
+
+	if code := push("/logtap/raw", "", `{"msg":"hi"}`); code != http.StatusNoContent {
+		t.Errorf("own session: status = %d, want 204", code)
+	}
+	if code := push("/logtap/raw", "lt-a3f9", `{"msg":"hi","labels":{"session":"lt-a3f9"}}`); code != http.StatusNoContent {
+		t.Errorf("own session label: status = %d, want 204", code)
+	}
+	entries := ring.Snapshot()
+	if len(entries) != 2 {
+		t.Fatalf("ingested %d entries, want 2", len(entries))
+	}
+	for _, e := range entries {
+		if e.Labels["session"] != "lt-a3f9" {
+			t.Errorf("session label = %q, want lt-a3f9", e.Labels["session"])
+		}
+	}
+}
+
+func TestServer_OTLPGRPCAuth(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	srv.SetPushAuth(NewPushAuth("s3cret"))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.ServeOTLPGRPC(ln) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+	conn, err := grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, resp := sampleOTLPRequest(), []byte{}
+	if err := conn.Invoke(ctx, otlpLogsGRPCMethod, &req, &resp); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Export without token = %v, want Unauthenticated", err)
+	}
+	if got := len(ring.Snapshot()); got != 0 {
+		t.Errorf("unauthenticated Export ingested %d entries", got)
+	}
+
+	actx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer s3cret")
+	if err := conn.Invoke(actx, otlpLogsGRPCMethod, &req, &resp); err != nil {
+		t.Fatalf("Export with token: %v", err)
+	}
+	if got := len(ring.Snapshot()); got != 2 {
+		t.Errorf("ingested %d entries, want 2", got)
+	}
+}
+
+func TestServer_PushStreamAuth(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	srv := NewServer(":0", w, nil, nil, nil, NewLogRing(0))
+	srv.SetPushAuth(NewPushAuth("s3cret"))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.ServeOTLPGRPC(ln) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+	conn, err := grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(pushproto.Codec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, pushproto.FullMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendPushFrame(t, stream, pushproto.Frame{Session: "s1"})
+	var b []byte
+	if err := stream.RecvMsg(&b); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("recv without token = %v, want Unauthenticated", err)
+	}
+
+	actx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer s3cret")
+	if _, ack := openPushStream(t, actx, conn, "s1"); ack.Seq != 0 {
+		t.Errorf("hello ack = %d, want 0", ack.Seq)
+	}
+
+	sctx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+SessionToken("s3cret", "lt-a3f9"))
+	stream, _ = openPushStream(t, sctx, conn, "s2")
+	sendPushFrame(t, stream, pushproto.Frame{Seq: 1, Labels: map[string]string{"session": "lt-b001"},
+		Entries: []pushproto.Entry{{Line: "hi"}}})
+	if err := stream.RecvMsg(&b); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("recv for another session = %v, want PermissionDenied", err)
+	}
+}
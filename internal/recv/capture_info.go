@@ -0,0 +1,149 @@
+package recv
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Limits on capture info, so a client cannot grow metadata.json without
+// bound through the capture API.
+const (
+	maxCaptureMeta      = 64
+	maxCaptureInfoBytes = 4096 // per description, owner, meta key or value
+)
+
+// CaptureInfo describes what a capture was recorded for: the test or
+// incident that produced it and who to ask about it. It is set with recv
+// --description, --owner and --meta, or by clients through the capture API,
+// and stored in metadata.json.
+type CaptureInfo struct {
+	Description string            `json:"description,omitempty"`
+	Owner       string            `json:"owner,omitempty"`
+	Meta        map[string]string `json:"meta,omitempty"`
+}
+
+// Merge applies update to c: a non-empty description or owner replaces the
+// current one, and meta keys are set, or removed when their value is "".
+func (c *CaptureInfo) Merge(update CaptureInfo) error {
+	if err := checkCaptureText("description", update.Description); err != nil {
+		return err
+	}
+	if err := checkCaptureText("owner", update.Owner); err != nil {
+		return err
+	}
+	if update.Description != "" {
+		c.Description = update.Description
+	}
+	if update.Owner != "" {
+		c.Owner = update.Owner
+	}
+	for k, v := range update.Meta {
+		if k == "" || strings.ContainsAny(k, "=\n") {
+			return fmt.Errorf("invalid meta key %q", k)
+		}
+		if err := checkCaptureText("meta "+k, k+v); err != nil {
+			return err
+		}
+		if v == "" {
+			delete(c.Meta, k)
+			continue
+		}
+		if _, ok := c.Meta[k]; !ok && len(c.Meta) >= maxCaptureMeta {
+			return fmt.Errorf("too many meta keys (max %d)", maxCaptureMeta)
+		}
+		if c.Meta == nil {
+			c.Meta = make(map[string]string)
+		}
+		c.Meta[k] = v
+	}
+	return nil
+}
+
+func checkCaptureText(field, s string) error {
+	if len(s) > maxCaptureInfoBytes {
+		return fmt.Errorf("%s exceeds %d bytes", field, maxCaptureInfoBytes)
+	}
+	return nil
+}
+
+// ParseCaptureMeta parses key=value pairs as given to recv --meta.
+func ParseCaptureMeta(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	meta := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid meta %q: expected key=value", p)
+		}
+		meta[k] = v
+	}
+	return meta, nil
+}
+
+// captureInfo holds the capture info of a running receiver.
+type captureInfo struct {
+	mu       sync.Mutex
+	info     CaptureInfo
+	onChange func(CaptureInfo)
+}
+
+func (c *captureInfo) get() CaptureInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info := c.info
+	info.Meta = maps.Clone(c.info.Meta)
+	return info
+}
+
+// SetCaptureInfo sets the initial capture info. onChange, if set, is called
+// with the result of each update through the capture API.
+func (s *Server) SetCaptureInfo(info CaptureInfo, onChange func(CaptureInfo)) {
+	s.capture.mu.Lock()
+	defer s.capture.mu.Unlock()
+	s.capture.info = info
+	s.capture.onChange = onChange
+}
+
+// CaptureInfo returns the current capture info.
+func (s *Server) CaptureInfo() CaptureInfo { return s.capture.get() }
+
+func (s *Server) handleCaptureInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.capture.get())
+}
+
+// handleCaptureUpdate merges a JSON CaptureInfo into the capture info, for
+// test harnesses that describe the run they are about to push.
+func (s *Server) handleCaptureUpdate(w http.ResponseWriter, r *http.Request) {
+	var update CaptureInfo
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&update); err != nil {
+		http.Error(w, fmt.Sprintf("invalid capture info: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	s.capture.mu.Lock()
+	current := s.capture.info
+	current.Meta = maps.Clone(s.capture.info.Meta)
+	if err := current.Merge(update); err != nil {
+		s.capture.mu.Unlock()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.capture.info = current // Merge works on a copy, so current.Meta is not changed later
+	if s.capture.onChange != nil {
+		s.capture.onChange(current) // under the lock, so updates are stored in order
+	}
+	s.capture.mu.Unlock()
+
+	s.auditRequest(r, AuditEntry{Event: "capture_info", Detail: fmt.Sprintf("owner=%q meta=%d", current.Owner, len(current.Meta))})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(current)
+}
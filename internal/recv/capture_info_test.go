@@ -0,0 +1,86 @@
+package recv
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCaptureInfoMerge(t *testing.T) {
+	info := CaptureInfo{Description: "soak", Owner: "sre", Meta: map[string]string{"run": "1", "env": "staging"}}
+	err := info.Merge(CaptureInfo{Owner: "payments", Meta: map[string]string{"run": "2", "env": ""}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Description != "soak" || info.Owner != "payments" {
+		t.Errorf("info = %+v, want description kept and owner replaced", info)
+	}
+	if len(info.Meta) != 1 || info.Meta["run"] != "2" {
+		t.Errorf("meta = %v, want run=2 only", info.Meta)
+	}
+
+	for _, bad := range []CaptureInfo{
+		{Meta: map[string]string{"a=b": "c"}},
+		{Description: strings.Repeat("x", maxCaptureInfoBytes+1)},
+	} {
+		if err := info.Merge(bad); err == nil {
+			t.Errorf("Merge(%.40v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestParseCaptureMeta(t *testing.T) {
+	meta, err := ParseCaptureMeta([]string{"test-run=1234", "url=http://ci/x?a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta["test-run"] != "1234" || meta["url"] != "http://ci/x?a=b" {
+		t.Errorf("meta = %v", meta)
+	}
+	if _, err := ParseCaptureMeta([]string{"novalue"}); err == nil {
+		t.Error("want error for a pair without =")
+	}
+}
+
+func TestServer_CaptureInfoAPI(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	var stored []CaptureInfo
+	srv.SetCaptureInfo(CaptureInfo{Owner: "sre"}, func(info CaptureInfo) { stored = append(stored, info) })
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/logtap/api/v1/capture", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := do(http.MethodPut, `{"description":"checkout load test","meta":{"test-run":"1234"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+	var got CaptureInfo
+	if err := json.Unmarshal(do(http.MethodGet, "").Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Description != "checkout load test" || got.Owner != "sre" || got.Meta["test-run"] != "1234" {
+		t.Errorf("GET = %+v", got)
+	}
+	if len(stored) != 1 || stored[0].Meta["test-run"] != "1234" {
+		t.Errorf("onChange calls = %+v, want one with the update", stored)
+	}
+	if srv.CaptureInfo().Description != "checkout load test" {
+		t.Errorf("CaptureInfo = %+v", srv.CaptureInfo())
+	}
+
+	if rec := do(http.MethodPut, `{"owners":"typo"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown field: status %d, want 400", rec.Code)
+	}
+	if len(stored) != 1 {
+		t.Errorf("rejected update stored")
+	}
+}
@@ -0,0 +1,90 @@
+package recv
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+)
+
+// maxClientBuilds caps the client builds tracked; further builds are not
+// recorded.
+const maxClientBuilds = 100
+
+// ClientBuild is one push client build seen by the receiver, recorded in
+// metadata.json so mixed-version sessions show up after the fact.
+type ClientBuild struct {
+	logtypes.ClientInfo
+	Pushes       int64     `json:"pushes"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	Incompatible bool      `json:"incompatible,omitempty"` // speaks a push protocol this receiver does not support
+}
+
+// clientBuilds counts pushes per client build.
+type clientBuilds struct {
+	mu             sync.Mutex
+	seen           map[logtypes.ClientInfo]*ClientBuild
+	onIncompatible func(ClientBuild)
+}
+
+// note records a push from the client whose headers get returns. The
+// incompatible callback runs once per build, on its first push.
+func (c *clientBuilds) note(get func(key string) string, now time.Time) {
+	info, ok := logtypes.ParseClientInfo(get)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	b := c.seen[info]
+	if b == nil {
+		if len(c.seen) >= maxClientBuilds {
+			c.mu.Unlock()
+			return
+		}
+		if c.seen == nil {
+			c.seen = make(map[logtypes.ClientInfo]*ClientBuild)
+		}
+		b = &ClientBuild{ClientInfo: info, FirstSeen: now, Incompatible: !logtypes.ClientProtocolSupported(info.Protocol)}
+		c.seen[info] = b
+		if b.Incompatible && c.onIncompatible != nil {
+			defer c.onIncompatible(*b)
+		}
+	}
+	b.Pushes++
+	b.LastSeen = now
+	c.mu.Unlock()
+}
+
+// list returns the builds seen, sorted by name and version.
+func (c *clientBuilds) list() []ClientBuild {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]ClientBuild, 0, len(c.seen))
+	for _, b := range c.seen {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		if out[i].Version != out[j].Version {
+			return out[i].Version < out[j].Version
+		}
+		return out[i].Commit < out[j].Commit
+	})
+	return out
+}
+
+// SetOnIncompatibleClient sets a callback invoked the first time a client
+// build speaking an unsupported push protocol pushes. Its pushes are still
+// accepted.
+func (s *Server) SetOnIncompatibleClient(fn func(ClientBuild)) {
+	s.clients.mu.Lock()
+	s.clients.onIncompatible = fn
+	s.clients.mu.Unlock()
+}
+
+// Clients returns the push client builds seen so far.
+func (s *Server) Clients() []ClientBuild { return s.clients.list() }
@@ -0,0 +1,50 @@
+package recv
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+)
+
+func TestServer_ClientBuilds(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	var incompatible []ClientBuild
+	srv.SetOnIncompatibleClient(func(b ClientBuild) { incompatible = append(incompatible, b) })
+
+	push := func(client, protocol string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/logtap/raw", strings.NewReader(`{"msg":"hi"}`))
+		r.Header.Set(logtypes.ClientHeader, client)
+		r.Header.Set(logtypes.ProtocolHeader, protocol)
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := push("logtap-forwarder/1.4.0", "1")
+	if got := rec.Header().Get(logtypes.ProtocolHeader); got != "1" {
+		t.Errorf("response %s = %q, want 1", logtypes.ProtocolHeader, got)
+	}
+	push("logtap-forwarder/1.4.0", "1")
+	push("logtap-forwarder/9.0.0", "99")
+	push("logtap-forwarder/9.0.0", "99")
+
+	clients := srv.Clients()
+	if len(clients) != 2 {
+		t.Fatalf("clients = %+v, want 2 builds", clients)
+	}
+	if c := clients[0]; c.Version != "1.4.0" || c.Pushes != 2 || c.Incompatible {
+		t.Errorf("clients[0] = %+v", c)
+	}
+	if c := clients[1]; c.Version != "9.0.0" || c.Protocol != 99 || !c.Incompatible {
+		t.Errorf("clients[1] = %+v", c)
+	}
+	if len(incompatible) != 1 || incompatible[0].Version != "9.0.0" {
+		t.Errorf("incompatible callbacks = %+v, want one for 9.0.0", incompatible)
+	}
+}
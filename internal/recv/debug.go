@@ -0,0 +1,184 @@
+package recv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// DebugState is the runtime state recorded at the top of a diagnostics dump.
+type DebugState struct {
+	Time       time.Time      `json:"time"`
+	Version    string         `json:"version,omitempty"`
+	GoVersion  string         `json:"go_version"`
+	Uptime     string         `json:"uptime"`
+	Goroutines int            `json:"goroutines"`
+	HeapAlloc  uint64         `json:"heap_alloc_bytes"`
+	HeapSys    uint64         `json:"heap_sys_bytes"`
+	NumGC      uint32         `json:"num_gc"`
+	Writer     DebugWriter    `json:"writer"`
+	Ring       DebugRing      `json:"ring"`
+	Stats      *DebugStats    `json:"stats,omitempty"`
+	Sections   map[string]any `json:"sections,omitempty"`
+}
+
+// DebugWriter holds the writer's internal counters.
+type DebugWriter struct {
+	LinesWritten int64 `json:"lines_written"`
+	BytesWritten int64 `json:"bytes_written"`
+	Queued       int   `json:"queued"`
+	QueueCap     int   `json:"queue_cap"`
+}
+
+// DebugRing holds the TUI ring buffer's fill level.
+type DebugRing struct {
+	Len     int `json:"len"`
+	Cap     int `json:"cap"`
+	Version int `json:"version"`
+}
+
+// DebugStats holds the ingest counters.
+type DebugStats struct {
+	LogsReceived int64 `json:"logs_received"`
+	LogsDropped  int64 `json:"logs_dropped"`
+	ActiveConns  int64 `json:"active_conns"`
+}
+
+// Debugger writes runtime diagnostics dumps (state plus goroutine stacks)
+// into the capture directory, so a hung receiver can be diagnosed without
+// attaching a debugger.
+type Debugger struct {
+	dir     string
+	version string
+	started time.Time
+	writer  *Writer
+	ring    *LogRing
+	stats   *Stats
+
+	mu       sync.Mutex
+	sections map[string]func() any
+}
+
+// NewDebugger creates a Debugger writing dumps to dir. ring and stats may be nil.
+func NewDebugger(dir, version string, writer *Writer, ring *LogRing, stats *Stats) *Debugger {
+	return &Debugger{
+		dir:      dir,
+		version:  version,
+		started:  time.Now(),
+		writer:   writer,
+		ring:     ring,
+		stats:    stats,
+		sections: make(map[string]func() any),
+	}
+}
+
+// AddSection includes fn's JSON-encodable result under name in every dump,
+// for state owned outside this package (rotator counters, effective config).
+func (d *Debugger) AddSection(name string, fn func() any) {
+	d.mu.Lock()
+	d.sections[name] = fn
+	d.mu.Unlock()
+}
+
+// State collects the current runtime state.
+func (d *Debugger) State() DebugState {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	st := DebugState{
+		Time:       time.Now().UTC(),
+		Version:    d.version,
+		GoVersion:  runtime.Version(),
+		Uptime:     time.Since(d.started).Round(time.Second).String(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  mem.HeapAlloc,
+		HeapSys:    mem.HeapSys,
+		NumGC:      mem.NumGC,
+	}
+	if d.writer != nil {
+		st.Writer = DebugWriter{
+			LinesWritten: d.writer.LinesWritten(),
+			BytesWritten: d.writer.BytesWritten(),
+			Queued:       d.writer.Queued(),
+			QueueCap:     d.writer.QueueCap(),
+		}
+	}
+	if d.ring != nil {
+		st.Ring = DebugRing{Len: d.ring.Len(), Cap: d.ring.Cap(), Version: d.ring.Version()}
+	}
+	if d.stats != nil {
+		st.Stats = &DebugStats{
+			LogsReceived: d.stats.LogsReceived.Load(),
+			LogsDropped:  d.stats.LogsDropped.Load(),
+			ActiveConns:  d.stats.ActiveConns.Load(),
+		}
+	}
+
+	d.mu.Lock()
+	if len(d.sections) > 0 {
+		st.Sections = make(map[string]any, len(d.sections))
+		for name, fn := range d.sections {
+			st.Sections[name] = fn()
+		}
+	}
+	d.mu.Unlock()
+	return st
+}
+
+// Write writes a dump: the state as indented JSON, then all goroutine stacks.
+func (d *Debugger) Write(w io.Writer) error {
+	state, err := json.MarshalIndent(d.State(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode debug state: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "%s\n\n", state); err != nil {
+		return err
+	}
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// Dump writes a dump to debug-<timestamp>.txt in the capture directory and
+// returns its path and contents.
+func (d *Debugger) Dump() (string, []byte, error) {
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		return "", nil, err
+	}
+	name := "debug-" + time.Now().UTC().Format("20060102T150405.000Z") + ".txt"
+	path := filepath.Join(d.dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return "", nil, fmt.Errorf("write debug dump: %w", err)
+	}
+	return path, buf.Bytes(), nil
+}
+
+// SetDebugger enables POST /admin/debug, which writes a dump to the capture
+// directory and returns it.
+func (s *Server) SetDebugger(d *Debugger) {
+	s.debugger = d
+}
+
+func (s *Server) handleDebug(w http.ResponseWriter, r *http.Request) {
+	if s.debugger == nil {
+		http.NotFound(w, r)
+		return
+	}
+	path, dump, err := s.debugger.Dump()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.auditRequest(r, AuditEntry{Event: "debug_dump"})
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Logtap-Debug-File", filepath.Base(path))
+	_, _ = w.Write(dump)
+}
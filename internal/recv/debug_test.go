@@ -0,0 +1,78 @@
+package recv
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebuggerDump(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	ring := NewLogRing(16)
+	ring.Push(LogEntry{Message: "x"})
+
+	d := NewDebugger(dir, "v1.2.3", w, ring, NewStats())
+	d.AddSection("rotator", func() any { return map[string]int{"active_size": 42} })
+
+	path, dump, err := d.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != dir || !strings.HasPrefix(filepath.Base(path), "debug-") {
+		t.Errorf("path = %s", path)
+	}
+	onDisk, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(onDisk) != string(dump) {
+		t.Error("returned dump differs from file")
+	}
+	for _, want := range []string{`"version": "v1.2.3"`, `"queue_cap": 1024`, `"len": 1`, `"active_size": 42`, "goroutine ", "TestDebuggerDump"} {
+		if !strings.Contains(string(dump), want) {
+			t.Errorf("dump missing %q", want)
+		}
+	}
+}
+
+func TestHandleDebug(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	ts := httptest.NewServer(srv.httpSrv.Handler)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/admin/debug", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("without debugger status = %d, want 404", resp.StatusCode)
+	}
+
+	srv.SetDebugger(NewDebugger(dir, "", w, nil, nil))
+	resp, err = http.Post(ts.URL+"/admin/debug", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	name := resp.Header.Get("X-Logtap-Debug-File")
+	if _, err := os.Stat(filepath.Join(dir, name)); err != nil || name == "" {
+		t.Errorf("dump file %q: %v", name, err)
+	}
+	if !strings.Contains(string(body), "goroutine ") {
+		t.Error("response does not contain goroutine stacks")
+	}
+}
@@ -0,0 +1,111 @@
+package recv
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDedupPending caps the distinct lines held for deduplication; further
+// lines are written as they are until held ones are flushed.
+const maxDedupPending = 10000
+
+// DedupInfo records write path deduplication in metadata.
+type DedupInfo struct {
+	Window    string `json:"window"`
+	Collapsed int64  `json:"collapsed"` // lines folded into an earlier identical entry
+}
+
+// deduper collapses identical entries (same labels and message) arriving
+// within window of the first into that entry, counting them in its
+// RepeatCount. It is only used from the writer's drain goroutine.
+type deduper struct {
+	window time.Duration
+	now    func() time.Time
+	tick   *time.Ticker
+
+	pending map[string]*dedupEntry
+	order   []string // pending keys by arrival, so by deadline
+}
+
+type dedupEntry struct {
+	entry    LogEntry
+	deadline time.Time
+}
+
+func newDeduper(window time.Duration) *deduper {
+	tick := window / 4
+	if tick < 10*time.Millisecond {
+		tick = 10 * time.Millisecond
+	}
+	return &deduper{
+		window:  window,
+		now:     time.Now,
+		tick:    time.NewTicker(tick),
+		pending: make(map[string]*dedupEntry),
+	}
+}
+
+// dedupKey identifies an entry by its labels and message.
+func dedupKey(e LogEntry) string {
+	keys := make([]string, 0, len(e.Labels))
+	for k := range e.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(e.Labels[k])
+		b.WriteByte(0)
+	}
+	b.WriteByte(0)
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// add takes in entry. It returns the lines folded into a held entry, or
+// false when entry cannot be held and should be written as it is.
+func (d *deduper) add(entry LogEntry) (folded int64, held bool) {
+	key := dedupKey(entry)
+	if p := d.pending[key]; p != nil {
+		p.entry.RepeatCount = p.entry.Count() + entry.Count()
+		return entry.Count(), true
+	}
+	if len(d.pending) >= maxDedupPending {
+		return 0, false
+	}
+	d.pending[key] = &dedupEntry{entry: entry, deadline: d.now().Add(d.window)}
+	d.order = append(d.order, key)
+	return 0, true
+}
+
+// expire returns the held entries whose window has passed, oldest first.
+func (d *deduper) expire() []LogEntry {
+	now := d.now()
+	var out []LogEntry
+	n := 0
+	for _, key := range d.order {
+		p := d.pending[key]
+		if p.deadline.After(now) {
+			break
+		}
+		out = append(out, p.entry)
+		delete(d.pending, key)
+		n++
+	}
+	d.order = d.order[n:]
+	return out
+}
+
+// flush returns every held entry, oldest first.
+func (d *deduper) flush() []LogEntry {
+	out := make([]LogEntry, 0, len(d.order))
+	for _, key := range d.order {
+		out = append(out, d.pending[key].entry)
+	}
+	d.pending = make(map[string]*dedupEntry)
+	d.order = nil
+	return out
+}
@@ -0,0 +1,97 @@
+package recv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func decodeEntries(t *testing.T, buf *bytes.Buffer) []LogEntry {
+	t.Helper()
+	var out []LogEntry
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var e LogEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("decode %q: %v", sc.Text(), err)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestWriterDedup(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(64, &buf, nil)
+	var folded int64
+	w.SetDedup(time.Hour, func(n int64) { folded += n })
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	api := map[string]string{"app": "api"}
+	for i := 0; i < 5; i++ {
+		w.Send(LogEntry{Timestamp: ts.Add(time.Duration(i) * time.Second), Labels: api, Message: "conn reset"})
+	}
+	w.Send(LogEntry{Timestamp: ts, Labels: map[string]string{"app": "web"}, Message: "conn reset"})
+	w.Send(LogEntry{Timestamp: ts, Labels: api, Message: "other"})
+	w.Close() // flushes held entries
+
+	entries := decodeEntries(t, &buf)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(entries), entries)
+	}
+	if entries[0].Message != "conn reset" || entries[0].RepeatCount != 5 || !entries[0].Timestamp.Equal(ts) {
+		t.Errorf("first entry = %+v, want conn reset at %v with repeat_count 5", entries[0], ts)
+	}
+	if entries[1].Labels["app"] != "web" || entries[1].Count() != 1 {
+		t.Errorf("second entry = %+v, want web once", entries[1])
+	}
+	if w.Deduplicated() != 4 || folded != 4 {
+		t.Errorf("Deduplicated = %d, callback %d, want 4", w.Deduplicated(), folded)
+	}
+	if w.LinesWritten() != 3 {
+		t.Errorf("LinesWritten = %d, want 3", w.LinesWritten())
+	}
+}
+
+func TestWriterDedupWindowExpires(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(64, &buf, nil)
+	w.SetDedup(40*time.Millisecond, nil)
+
+	entry := LogEntry{Timestamp: time.Now(), Message: "tick"}
+	w.Send(entry)
+	w.Send(entry)
+	time.Sleep(150 * time.Millisecond) // window passes, held entry is written
+	if w.LinesWritten() != 1 {
+		t.Fatalf("LinesWritten after window = %d, want 1", w.LinesWritten())
+	}
+	w.Send(entry)
+	w.Close()
+
+	entries := decodeEntries(t, &buf)
+	if len(entries) != 2 || entries[0].RepeatCount != 2 || entries[1].Count() != 1 {
+		t.Errorf("entries = %+v, want one of 2 then one single", entries)
+	}
+}
+
+func TestDeduperPendingCap(t *testing.T) {
+	d := newDeduper(time.Hour)
+	defer d.tick.Stop()
+	for i := 0; i < maxDedupPending; i++ {
+		if _, held := d.add(LogEntry{Message: strconv.Itoa(i)}); !held {
+			t.Fatalf("entry %d not held", i)
+		}
+	}
+	if _, held := d.add(LogEntry{Message: "one more"}); held {
+		t.Error("entry past maxDedupPending held")
+	}
+	if folded, held := d.add(LogEntry{Message: "0"}); !held || folded != 1 {
+		t.Errorf("repeat of a held entry: folded %d held %v, want 1 true", folded, held)
+	}
+	if got := len(d.flush()); got != maxDedupPending {
+		t.Errorf("flush returned %d entries, want %d", got, maxDedupPending)
+	}
+}
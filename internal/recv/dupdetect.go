@@ -0,0 +1,197 @@
+package recv
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dupBloomBits      = 1 << 23 // 1 MiB per filter, ~5e-6 false positives at 100k entries
+	dupBloomHashes    = 4
+	defaultDupWindow  = time.Minute
+	defaultDupMinHits = 100
+)
+
+// DuplicateStream describes two tap sessions delivering identical entries,
+// which usually means the same pod was tapped twice.
+type DuplicateStream struct {
+	Sessions [2]string `json:"sessions"` // sorted
+	Stream   string    `json:"stream"`   // labels of the first duplicate, without session
+	Hits     int64     `json:"hits"`     // duplicate entries seen when reported
+}
+
+// DupDetector flags entries that another session already delivered recently.
+// Each session keeps two Bloom filters that rotate every window, so memory is
+// bounded and only recent entries are compared. Entries without a "session"
+// label are ignored. All methods are safe for concurrent use.
+type DupDetector struct {
+	mu       sync.Mutex
+	window   time.Duration
+	minHits  int64
+	sessions map[string]*sessionBloom
+	hits     map[[2]string]int64
+	reported map[[2]string]bool
+	onDup    func(DuplicateStream)
+	now      func() time.Time
+}
+
+type sessionBloom struct {
+	cur, prev []uint64
+	rotated   time.Time
+	lastSeen  time.Time
+}
+
+// NewDupDetector creates a detector that compares entries within window and
+// reports a session pair once minHits duplicates were seen. Zero values use
+// a one minute window and 100 hits.
+func NewDupDetector(window time.Duration, minHits int64, onDup func(DuplicateStream)) *DupDetector {
+	if window <= 0 {
+		window = defaultDupWindow
+	}
+	if minHits <= 0 {
+		minHits = defaultDupMinHits
+	}
+	return &DupDetector{
+		window:   window,
+		minHits:  minHits,
+		sessions: make(map[string]*sessionBloom),
+		hits:     make(map[[2]string]int64),
+		reported: make(map[[2]string]bool),
+		onDup:    onDup,
+		now:      time.Now,
+	}
+}
+
+// Check records the entry for its session and reports whether another
+// session already delivered an identical entry within the window.
+func (d *DupDetector) Check(e LogEntry) bool {
+	session := e.Labels["session"]
+	if session == "" {
+		return false
+	}
+	h1, h2 := dupHash(e)
+	now := d.now()
+
+	d.mu.Lock()
+	own := d.sessions[session]
+	if own == nil {
+		own = &sessionBloom{cur: make([]uint64, dupBloomBits/64), rotated: now}
+		d.sessions[session] = own
+	}
+
+	var report *DuplicateStream
+	dup := false
+	for name, other := range d.sessions {
+		if name == session {
+			continue
+		}
+		if now.Sub(other.lastSeen) > 2*d.window {
+			delete(d.sessions, name) // session ended; nothing recent left to compare
+			continue
+		}
+		other.rotate(now, d.window)
+		if !other.mayContain(h1, h2) {
+			continue
+		}
+		dup = true
+		pair := [2]string{session, name}
+		if pair[0] > pair[1] {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		d.hits[pair]++
+		if d.hits[pair] >= d.minHits && !d.reported[pair] {
+			d.reported[pair] = true
+			report = &DuplicateStream{Sessions: pair, Stream: streamKey(e.Labels), Hits: d.hits[pair]}
+		}
+	}
+
+	own.rotate(now, d.window)
+	own.add(h1, h2)
+	own.lastSeen = now
+	d.mu.Unlock()
+
+	if report != nil && d.onDup != nil {
+		d.onDup(*report)
+	}
+	return dup
+}
+
+func (b *sessionBloom) rotate(now time.Time, window time.Duration) {
+	if now.Sub(b.rotated) < window {
+		return
+	}
+	// reuse the oldest filter's memory for the new current one
+	next := b.prev
+	if next == nil {
+		next = make([]uint64, dupBloomBits/64)
+	} else {
+		clear(next)
+	}
+	b.prev, b.cur = b.cur, next
+	b.rotated = now
+}
+
+func (b *sessionBloom) add(h1, h2 uint64) {
+	for i := uint64(0); i < dupBloomHashes; i++ {
+		bit := (h1 + i*h2) % dupBloomBits
+		b.cur[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *sessionBloom) mayContain(h1, h2 uint64) bool {
+	return bloomHas(b.cur, h1, h2) || bloomHas(b.prev, h1, h2)
+}
+
+func bloomHas(bits []uint64, h1, h2 uint64) bool {
+	if bits == nil {
+		return false
+	}
+	for i := uint64(0); i < dupBloomHashes; i++ {
+		bit := (h1 + i*h2) % dupBloomBits
+		if bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// dupHash returns two independent hashes of the entry's stream (labels
+// without session), timestamp, and message for double hashing.
+func dupHash(e LogEntry) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(streamKey(e.Labels)))
+	var ts [8]byte
+	binary.LittleEndian.PutUint64(ts[:], uint64(e.Timestamp.UnixNano()))
+	_, _ = h.Write(ts[:])
+	_, _ = h.Write([]byte(e.Message))
+	h1 := h.Sum64()
+
+	// second hash: mix h1 so double hashing probes differ; force odd
+	h2 := (h1 ^ (h1 >> 33)) * 0xff51afd7ed558ccd
+	return h1, h2 | 1
+}
+
+// streamKey renders labels other than session as sorted key=value pairs.
+func streamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "session" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package recv
+
+import (
+	"testing"
+	"time"
+)
+
+func dupEntry(session, msg string, ts time.Time) LogEntry {
+	return LogEntry{
+		Timestamp: ts,
+		Labels:    map[string]string{"namespace": "shop", "pod": "api-0", "session": session},
+		Message:   msg,
+	}
+}
+
+func TestDupDetector_ReportsDoubleTap(t *testing.T) {
+	var reports []DuplicateStream
+	d := NewDupDetector(time.Minute, 3, func(ds DuplicateStream) { reports = append(reports, ds) })
+
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		ts := base.Add(time.Duration(i) * time.Second)
+		if d.Check(dupEntry("lt-b", "hello", ts)) {
+			t.Fatalf("entry %d from first session flagged as duplicate", i)
+		}
+		if !d.Check(dupEntry("lt-a", "hello", ts)) {
+			t.Fatalf("entry %d from second session not flagged", i)
+		}
+	}
+
+	if len(reports) != 1 {
+		t.Fatalf("reports = %d, want 1 (once per pair)", len(reports))
+	}
+	r := reports[0]
+	if r.Sessions != [2]string{"lt-a", "lt-b"} {
+		t.Errorf("Sessions = %v, want sorted [lt-a lt-b]", r.Sessions)
+	}
+	if r.Stream != "namespace=shop,pod=api-0" {
+		t.Errorf("Stream = %q", r.Stream)
+	}
+	if r.Hits != 3 {
+		t.Errorf("Hits = %d, want 3", r.Hits)
+	}
+}
+
+func TestDupDetector_DistinctStreams(t *testing.T) {
+	reported := false
+	d := NewDupDetector(time.Minute, 1, func(DuplicateStream) { reported = true })
+
+	ts := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	d.Check(dupEntry("lt-a", "hello", ts))
+	other := dupEntry("lt-b", "hello", ts)
+	other.Labels["pod"] = "api-1"
+	if d.Check(other) {
+		t.Error("different pod flagged as duplicate")
+	}
+	// same session repeating itself is not a double tap
+	if d.Check(dupEntry("lt-a", "hello", ts)) {
+		t.Error("same session flagged as duplicate")
+	}
+	// entries without a session label are ignored
+	if d.Check(LogEntry{Timestamp: ts, Labels: map[string]string{"app": "x"}, Message: "hello"}) {
+		t.Error("session-less entry flagged")
+	}
+	if reported {
+		t.Error("unexpected report")
+	}
+}
+
+func TestDupDetector_WindowExpiry(t *testing.T) {
+	d := NewDupDetector(time.Minute, 1, nil)
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	ts := now
+	d.Check(dupEntry("lt-a", "hello", ts))
+
+	// still within the previous filter after one rotation
+	now = now.Add(90 * time.Second)
+	d.Check(dupEntry("lt-a", "keepalive", now))
+	if !d.Check(dupEntry("lt-b", "hello", ts)) {
+		t.Error("expected duplicate within two windows")
+	}
+
+	// after two rotations lt-a has forgotten the original entry
+	now = now.Add(90 * time.Second)
+	d.Check(dupEntry("lt-a", "keepalive2", now))
+	if d.Check(dupEntry("lt-b", "hello", ts)) {
+		t.Error("expected entry to expire after two windows")
+	}
+}
+
+func TestStats_RecordDuplicate(t *testing.T) {
+	s := NewStats()
+	s.RecordDuplicate(DuplicateStream{Sessions: [2]string{"a", "b"}, Stream: "pod=x"})
+	snap := s.Snapshot(0, 0, 0)
+	if len(snap.Duplicates) != 1 || snap.Duplicates[0].Stream != "pod=x" {
+		t.Errorf("Duplicates = %+v", snap.Duplicates)
+	}
+}
@@ -0,0 +1,303 @@
+package recv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Elasticsearch _bulk ingest. Filebeat, Logstash, and other Beats-style
+// shippers send NDJSON action/source line pairs; index and create actions
+// become entries, update and delete are acknowledged without effect.
+//
+// Mapping onto capture entries:
+//   - the message, log, or msg field is the message; documents without one
+//     are stored as JSON
+//   - @timestamp (RFC 3339 or epoch milliseconds) is the timestamp, falling
+//     back to arrival time
+//   - the target index becomes the index label; Kubernetes metadata added by
+//     add_kubernetes_metadata, service.name, host.name, and log.level map
+//     onto namespace, pod, container, node, app, host, and level; scalar
+//     custom "fields" keep their names
+
+// esVersion is the Elasticsearch version reported to clients that check it
+// before shipping (Beats refuse unknown products and very old versions).
+const esVersion = "8.11.0"
+
+// esLabelFields maps document fields onto capture label names, in order of
+// preference where several map to the same label.
+var esLabelFields = []struct{ field, label string }{
+	{"kubernetes.namespace", "namespace"},
+	{"kubernetes.pod.name", "pod"},
+	{"kubernetes.container.name", "container"},
+	{"kubernetes.node.name", "node"},
+	{"kubernetes.labels.app", "app"},
+	{"kubernetes.labels.app_kubernetes_io/name", "app"},
+	{"service.name", "app"},
+	{"host.name", "host"},
+	{"host", "host"},
+	{"log.level", "level"},
+	{"level", "level"},
+}
+
+var esMessageFields = []string{"message", "log", "msg"}
+
+// bulkItem is one entry of the _bulk response items array.
+type bulkItem struct {
+	action string
+	Index  string   `json:"_index"`
+	ID     string   `json:"_id,omitempty"`
+	Status int      `json:"status"`
+	Result string   `json:"result,omitempty"`
+	Error  *bulkErr `json:"error,omitempty"`
+}
+
+type bulkErr struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// parseBulk parses an Elasticsearch _bulk body. defaultIndex applies to
+// actions without _index (the index in the request path). It returns the
+// entries to store and per-action results for the response; a malformed
+// action line fails the whole request, a malformed document only its item.
+func parseBulk(body []byte, defaultIndex string, now time.Time) ([]LogEntry, []bulkItem, error) {
+	var entries []LogEntry
+	var items []bulkItem
+	lines := bytes.Split(body, []byte("\n"))
+	for i := 0; i < len(lines); i++ {
+		line := bytes.TrimSpace(lines[i])
+		if len(line) == 0 {
+			continue
+		}
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+			return nil, nil, fmt.Errorf("malformed action/metadata line %d: expected {\"index\": {...}}", i+1)
+		}
+		for name, meta := range action {
+			item := bulkItem{action: name, Index: meta.Index, ID: meta.ID}
+			if item.Index == "" {
+				item.Index = defaultIndex
+			}
+
+			switch name {
+			case "index", "create", "update":
+				if i+1 >= len(lines) {
+					return nil, nil, fmt.Errorf("action on line %d has no source line", i+1)
+				}
+				i++
+				if name == "update" {
+					item.Status, item.Result = http.StatusOK, "noop"
+					break
+				}
+				var doc map[string]any
+				if err := json.Unmarshal(lines[i], &doc); err != nil {
+					item.Status = http.StatusBadRequest
+					item.Error = &bulkErr{Type: "document_parsing_exception", Reason: err.Error()}
+					break
+				}
+				entries = append(entries, esEntry(doc, item.Index, now))
+				item.Status, item.Result = http.StatusCreated, "created"
+			case "delete":
+				item.Status, item.Result = http.StatusOK, "not_found"
+			default:
+				return nil, nil, fmt.Errorf("unknown bulk action %q on line %d", name, i+1)
+			}
+			items = append(items, item)
+		}
+	}
+	return entries, items, nil
+}
+
+// esEntry builds an entry from a bulk document.
+func esEntry(doc map[string]any, index string, now time.Time) LogEntry {
+	e := LogEntry{Timestamp: now, Labels: map[string]string{}}
+	if index != "" {
+		e.Labels["index"] = index
+	}
+
+	switch ts := esField(doc, "@timestamp").(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			e.Timestamp = t
+		}
+	case float64:
+		e.Timestamp = time.UnixMilli(int64(ts)).UTC()
+	}
+
+	found := false
+	for _, f := range esMessageFields {
+		if s, ok := esField(doc, f).(string); ok {
+			e.Message = strings.TrimRight(s, "\n")
+			found = true
+			break
+		}
+	}
+	if !found {
+		if b, err := json.Marshal(doc); err == nil {
+			e.Message = string(b)
+		}
+	}
+
+	for _, m := range esLabelFields {
+		if _, set := e.Labels[m.label]; set {
+			continue
+		}
+		if s, ok := esField(doc, m.field).(string); ok && s != "" {
+			e.Labels[m.label] = s
+		}
+	}
+	if fields, ok := doc["fields"].(map[string]any); ok {
+		for k, v := range fields {
+			name := labelName(k)
+			if _, set := e.Labels[name]; set {
+				continue
+			}
+			if s, ok := scalarString(v); ok && s != "" {
+				e.Labels[name] = s
+			}
+		}
+	}
+	return e
+}
+
+// esField looks up a dotted path in doc, accepting both nested objects and
+// literal dotted keys ({"log": {"level": ...}} or {"log.level": ...}).
+func esField(doc map[string]any, path string) any {
+	if v, ok := doc[path]; ok {
+		return v
+	}
+	head, rest, ok := strings.Cut(path, ".")
+	for ok {
+		if sub, isMap := doc[head].(map[string]any); isMap {
+			if v := esField(sub, rest); v != nil {
+				return v
+			}
+		}
+		var next string
+		next, rest, ok = strings.Cut(rest, ".")
+		head += "." + next
+	}
+	return nil
+}
+
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	s.trackConnOpen()
+	defer s.trackConnClose()
+	defer func() {
+		if s.metrics != nil {
+			s.metrics.PushDuration.Observe(time.Since(start).Seconds())
+		}
+	}()
+
+	ctx, span := s.startPush(r.Context(), r.Header, "bulk push")
+	defer span.End()
+
+	_, dspan := s.tracer.Start(ctx, "decode")
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			endSpan(dspan, err)
+			writeESError(w, http.StatusBadRequest, "parse_exception", fmt.Sprintf("invalid gzip body: %v", err))
+			return
+		}
+		defer func() { _ = gz.Close() }()
+		body = io.LimitReader(gz, maxRequestBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		endSpan(dspan, err)
+		writeESError(w, http.StatusBadRequest, "parse_exception", fmt.Sprintf("read body: %v", err))
+		return
+	}
+	if len(data) > maxRequestBytes {
+		endSpan(dspan, errors.New("request body too large"))
+		writeESError(w, http.StatusRequestEntityTooLarge, "content_too_long_exception", "request body too large")
+		return
+	}
+
+	entries, items, err := parseBulk(data, r.PathValue("index"), start)
+	endSpan(dspan, err)
+	if err != nil {
+		writeESError(w, http.StatusBadRequest, "illegal_argument_exception", err.Error())
+		return
+	}
+	if err := s.checkLabelLimits("bulk", func(i int) map[string]string { return entries[i].Labels }, len(entries)); err != nil {
+		writeESError(w, http.StatusBadRequest, "illegal_argument_exception", err.Error())
+		return
+	}
+
+	session := r.Header.Get(SessionHeader)
+	for i := range entries {
+		entries[i].Labels = withSession(entries[i].Labels, session)
+	}
+	if err := checkSession(ctx, func(i int) map[string]string { return entries[i].Labels }, len(entries)); err != nil {
+		writeESError(w, http.StatusForbidden, "security_exception", err.Error())
+		return
+	}
+	s.ingestBatch(ctx, entries, start)
+	var byteCount int
+	for i := range entries {
+		byteCount += len(entries[i].Message)
+	}
+	s.auditRequest(r, AuditEntry{
+		Event:    "bulk_push_received",
+		Lines:    len(entries),
+		Bytes:    byteCount,
+		Duration: time.Since(start),
+	})
+
+	resp := struct {
+		Took   int64            `json:"took"`
+		Errors bool             `json:"errors"`
+		Items  []map[string]any `json:"items"`
+	}{Took: time.Since(start).Milliseconds(), Items: make([]map[string]any, 0, len(items))}
+	for _, item := range items {
+		if item.Error != nil {
+			resp.Errors = true
+		}
+		resp.Items = append(resp.Items, map[string]any{item.action: item})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// handleESInfo answers the cluster info request Beats and Logstash send
+// before shipping.
+func (s *Server) handleESInfo(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"name":         "logtap",
+		"cluster_name": "logtap",
+		"version": map[string]any{
+			"number":                              esVersion,
+			"build_flavor":                        "default",
+			"minimum_wire_compatibility_version":  "7.17.0",
+			"minimum_index_compatibility_version": "7.0.0",
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+func writeESError(w http.ResponseWriter, status int, typ, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error":  map[string]any{"type": typ, "reason": reason},
+		"status": status,
+	})
+}
@@ -0,0 +1,144 @@
+package recv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleBulk = `{"index":{"_index":"filebeat-8.11.0","_id":"a1"}}
+{"@timestamp":"2025-01-15T10:00:00.250Z","message":"GET /health 200\n","kubernetes":{"namespace":"shop","pod":{"name":"api-0"},"container":{"name":"api"},"labels":{"app":"api"}},"log.level":"info","host":{"name":"node-1"},"fields":{"env":"load","team":"payments"}}
+{"create":{}}
+{"@timestamp":1736935201000,"log":"plain log field","service":{"name":"worker"}}
+{"delete":{"_index":"old","_id":"x"}}
+{"update":{"_id":"u1"}}
+{"doc":{"message":"ignored"}}
+{"index":{}}
+{"level":"error","code":42}
+`
+
+func TestParseBulk(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	entries, items, err := parseBulk([]byte(sampleBulk), "logs", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || len(items) != 5 {
+		t.Fatalf("entries = %d, items = %d", len(entries), len(items))
+	}
+
+	e := entries[0]
+	if e.Message != "GET /health 200" || !e.Timestamp.Equal(time.Date(2025, 1, 15, 10, 0, 0, 25e7, time.UTC)) {
+		t.Errorf("entry 0 = %+v", e)
+	}
+	want := map[string]string{"index": "filebeat-8.11.0", "namespace": "shop", "pod": "api-0", "container": "api", "app": "api", "host": "node-1", "level": "info", "env": "load", "team": "payments"}
+	for k, v := range want {
+		if e.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, e.Labels[k], v)
+		}
+	}
+
+	e = entries[1]
+	if e.Message != "plain log field" || e.Labels["app"] != "worker" || e.Labels["index"] != "logs" {
+		t.Errorf("entry 1 = %+v", e)
+	}
+	if !e.Timestamp.Equal(time.UnixMilli(1736935201000)) {
+		t.Errorf("epoch ts = %v", e.Timestamp)
+	}
+
+	e = entries[2]
+	if e.Message != `{"code":42,"level":"error"}` || e.Labels["level"] != "error" || !e.Timestamp.Equal(now) {
+		t.Errorf("entry 2 = %+v", e)
+	}
+
+	if items[2].action != "delete" || items[2].Status != http.StatusOK || items[3].Result != "noop" {
+		t.Errorf("items = %+v", items)
+	}
+}
+
+func TestParseBulkErrors(t *testing.T) {
+	now := time.Now()
+	if _, _, err := parseBulk([]byte("not json\n"), "", now); err == nil {
+		t.Error("expected error for malformed action")
+	}
+	if _, _, err := parseBulk([]byte(`{"upsert":{}}`+"\n{}\n"), "", now); err == nil {
+		t.Error("expected error for unknown action")
+	}
+
+	// a bad document fails only its item
+	entries, items, err := parseBulk([]byte(`{"index":{}}`+"\n{broken\n"+`{"index":{}}`+"\n"+`{"message":"ok"}`+"\n"), "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || items[0].Status != http.StatusBadRequest || items[0].Error == nil || items[1].Status != http.StatusCreated {
+		t.Errorf("entries = %v items = %+v", entries, items)
+	}
+}
+
+func TestBulkHTTP(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	ts := httptest.NewServer(srv.httpSrv.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&info)
+	_ = resp.Body.Close()
+	if info.Version.Number == "" || resp.Header.Get("X-Elastic-Product") != "Elasticsearch" {
+		t.Errorf("info = %+v, headers = %v", info, resp.Header)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(`{"index":{}}` + "\n" + `{"message":"one"}` + "\n" + `{"index":{}}` + "\n{bad\n"))
+	_ = zw.Close()
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/logs-app/_bulk", &gz)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Errors bool                         `json:"errors"`
+		Items  []map[string]json.RawMessage `json:"items"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !out.Errors || len(out.Items) != 2 {
+		t.Fatalf("status = %d, resp = %+v", resp.StatusCode, out)
+	}
+	if !strings.Contains(string(out.Items[0]["index"]), `"status":201`) {
+		t.Errorf("item 0 = %s", out.Items[0]["index"])
+	}
+
+	snap := ring.Snapshot()
+	if len(snap) != 1 || snap[0].Message != "one" || snap[0].Labels["index"] != "logs-app" {
+		t.Errorf("entries = %+v", snap)
+	}
+
+	resp, err = http.Post(ts.URL+"/_bulk", "application/x-ndjson", strings.NewReader("nonsense\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("malformed status = %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,93 @@
+package recv
+
+import (
+	"sync"
+	"time"
+)
+
+// maxExpiredStreams caps the expired streams kept for metadata; older ones
+// are only counted.
+const maxExpiredStreams = 1000
+
+// StreamExpiryInfo records streams forgotten for being idle, for metadata.
+type StreamExpiryInfo struct {
+	IdleTTL string            `json:"idle_ttl"`
+	Expired int64             `json:"expired"`           // streams expired in total
+	Streams []StreamWatermark `json:"streams,omitempty"` // most recent, with last-seen in updated
+}
+
+// StreamExpiry forgets streams that stopped pushing, so a long-running
+// receiver's per-stream state stays proportional to the live streams. Each
+// expired stream's final watermark is kept for metadata. All methods are
+// safe for concurrent use.
+type StreamExpiry struct {
+	idle       time.Duration
+	watermarks *Watermarks
+	stats      *Stats
+	onExpire   func(expired []StreamWatermark, active int)
+
+	mu      sync.Mutex
+	expired int64
+	recent  []StreamWatermark
+}
+
+// NewStreamExpiry creates an expiry for the streams of watermarks and the
+// talkers of stats (nil to skip) idle for longer than idle.
+func NewStreamExpiry(idle time.Duration, watermarks *Watermarks, stats *Stats) *StreamExpiry {
+	return &StreamExpiry{idle: idle, watermarks: watermarks, stats: stats}
+}
+
+// SetOnExpire sets a callback invoked after each sweep with the streams
+// expired and the number still active.
+func (e *StreamExpiry) SetOnExpire(fn func(expired []StreamWatermark, active int)) {
+	e.onExpire = fn
+}
+
+// Sweep expires idle streams and returns how many were removed.
+func (e *StreamExpiry) Sweep() int {
+	expired := e.watermarks.Expire(e.idle)
+	if e.stats != nil {
+		e.stats.ExpireTalkers(e.idle)
+	}
+	if len(expired) > 0 {
+		e.mu.Lock()
+		e.expired += int64(len(expired))
+		e.recent = append(e.recent, expired...)
+		if over := len(e.recent) - maxExpiredStreams; over > 0 {
+			e.recent = append([]StreamWatermark(nil), e.recent[over:]...)
+		}
+		e.mu.Unlock()
+	}
+	if e.onExpire != nil {
+		e.onExpire(expired, e.watermarks.Len())
+	}
+	return len(expired)
+}
+
+// Run sweeps every interval until stop is closed.
+func (e *StreamExpiry) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.Sweep()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Info returns the expired streams for metadata, nil when none expired.
+func (e *StreamExpiry) Info() *StreamExpiryInfo {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.expired == 0 {
+		return nil
+	}
+	return &StreamExpiryInfo{
+		IdleTTL: e.idle.String(),
+		Expired: e.expired,
+		Streams: append([]StreamWatermark(nil), e.recent...),
+	}
+}
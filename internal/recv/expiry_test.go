@@ -0,0 +1,92 @@
+package recv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatermarks_Expire(t *testing.T) {
+	wm := NewWatermarks()
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	wm.now = func() time.Time { return now }
+
+	wm.Record(now, map[string]string{"session": "lt-1", "pod": "api-0"})
+	wm.Record(now, map[string]string{"session": "lt-2", "pod": "api-0"})
+	now = now.Add(30 * time.Minute)
+	wm.Record(now, map[string]string{"session": "lt-1", "pod": "worker-0"})
+	now = now.Add(45 * time.Minute)
+
+	expired := wm.Expire(time.Hour)
+	if len(expired) != 2 {
+		t.Fatalf("expired = %+v, want the two api-0 streams", expired)
+	}
+	for _, e := range expired {
+		if e.Stream != "pod=api-0" || e.Lines != 1 {
+			t.Errorf("expired stream = %+v", e)
+		}
+	}
+	if wm.Len() != 1 {
+		t.Errorf("Len = %d, want 1", wm.Len())
+	}
+	if streams := wm.Streams("lt-2"); len(streams) != 0 {
+		t.Errorf("lt-2 still has streams: %+v", streams)
+	}
+	if _, ok := wm.streams["lt-2"]; ok {
+		t.Error("empty session not dropped")
+	}
+}
+
+func TestStreamExpiry_Sweep(t *testing.T) {
+	wm := NewWatermarks()
+	now := time.Now()
+	wm.now = func() time.Time { return now }
+	stats := NewStats()
+
+	labels := map[string]string{"app": "api"}
+	wm.Record(now, labels)
+	stats.RecordEntry(labels)
+	stats.talkerSeen["api"] = now.Add(-2 * time.Hour)
+
+	e := NewStreamExpiry(time.Hour, wm, stats)
+	active := -1
+	e.SetOnExpire(func(expired []StreamWatermark, n int) { active = n })
+
+	if n := e.Sweep(); n != 0 || e.Info() != nil {
+		t.Fatalf("fresh stream expired: %d, %+v", n, e.Info())
+	}
+	if active != 1 {
+		t.Errorf("active = %d, want 1", active)
+	}
+	if len(stats.Snapshot(0, 0, 0).Talkers) != 0 {
+		t.Error("idle talker not expired")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if n := e.Sweep(); n != 1 {
+		t.Fatalf("Sweep = %d, want 1", n)
+	}
+	info := e.Info()
+	if info == nil || info.Expired != 1 || info.IdleTTL != "1h0m0s" || len(info.Streams) != 1 {
+		t.Fatalf("info = %+v", info)
+	}
+	if info.Streams[0].Stream != "app=api" || active != 0 {
+		t.Errorf("expired %+v, active %d", info.Streams[0], active)
+	}
+}
+
+func TestStreamExpiry_CapsRecent(t *testing.T) {
+	wm := NewWatermarks()
+	now := time.Now()
+	wm.now = func() time.Time { return now }
+	for i := 0; i < maxExpiredStreams+5; i++ {
+		wm.Record(now, map[string]string{"pod": time.Duration(i).String()})
+	}
+	now = now.Add(time.Hour)
+
+	e := NewStreamExpiry(time.Minute, wm, nil)
+	e.Sweep()
+	info := e.Info()
+	if info.Expired != maxExpiredStreams+5 || len(info.Streams) != maxExpiredStreams {
+		t.Errorf("expired %d, kept %d", info.Expired, len(info.Streams))
+	}
+}
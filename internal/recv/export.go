@@ -59,7 +59,7 @@ func ExportFilteredLines(entries []LogEntry, dir string) (int64, error) {
 	totalLines := int64(len(entries))
 
 	meta := &Metadata{
-		Version:    1,
+		Version:    MetadataVersion,
 		Format:     "jsonl",
 		Started:    minTS,
 		Stopped:    maxTS,
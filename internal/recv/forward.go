@@ -0,0 +1,374 @@
+package recv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fluentd forward protocol (v1) receiver. Fluent Bit and Fluentd ship
+// msgpack events over TCP in Message, Forward, PackedForward, and
+// CompressedPackedForward modes; chunk options are acknowledged, and an
+// optional shared key enables the HELO/PING/PONG handshake. User
+// authentication and UDP heartbeats are not supported.
+//
+// Mapping onto capture entries:
+//   - the tag becomes the tag label
+//   - the log, message, or msg field is the message; records without one
+//     are stored as JSON
+//   - Kubernetes metadata added by the kubernetes filter becomes the
+//     namespace, pod, container, and app labels
+
+// forwardKeepalive is how long an idle forward connection is kept open.
+const forwardKeepalive = 5 * time.Minute
+
+// recordMessageFields are the record fields tried, in order, for the message.
+var recordMessageFields = []string{"log", "message", "msg"}
+
+// ForwardListener receives the Fluentd forward protocol over TCP and feeds
+// events through a Server's ingest pipeline.
+type ForwardListener struct {
+	srv       *Server
+	ln        net.Listener
+	sharedKey string
+	hostname  string
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// ListenForward binds addr and starts serving. A non-empty sharedKey
+// requires clients to complete the forward protocol handshake.
+func ListenForward(addr, sharedKey string, srv *Server) (*ForwardListener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("forward: %w", err)
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "logtap"
+	}
+	l := &ForwardListener{srv: srv, ln: ln, sharedKey: sharedKey, hostname: host, conns: make(map[net.Conn]struct{})}
+	l.wg.Add(1)
+	go l.serve()
+	return l, nil
+}
+
+// Addr returns the bound address.
+func (l *ForwardListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// Close stops the listener and open connections and waits for in-flight
+// events to be ingested.
+func (l *ForwardListener) Close() error {
+	err := l.ln.Close()
+	l.mu.Lock()
+	l.closed = true
+	for c := range l.conns {
+		_ = c.Close()
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+	return err
+}
+
+func (l *ForwardListener) serve() {
+	defer l.wg.Done()
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		l.conns[conn] = struct{}{}
+		l.wg.Add(1)
+		l.mu.Unlock()
+		go l.handleConn(conn)
+	}
+}
+
+func (l *ForwardListener) handleConn(conn net.Conn) {
+	defer l.wg.Done()
+	start := time.Now()
+	l.srv.trackConnOpen()
+	defer l.srv.trackConnClose()
+
+	var lines, byteCount int
+	var err error
+	dec := newMsgpackDecoder(bufio.NewReaderSize(conn, 64<<10))
+	if l.sharedKey != "" {
+		err = l.handshake(conn, dec)
+	}
+	for err == nil {
+		_ = conn.SetReadDeadline(time.Now().Add(forwardKeepalive))
+		var msg any
+		if msg, err = dec.Decode(); err != nil {
+			break
+		}
+		var entries []LogEntry
+		var chunk string
+		if entries, chunk, err = parseForwardMessage(msg); err != nil {
+			break
+		}
+		for i := range entries {
+			l.srv.Ingest(&entries[i])
+			byteCount += len(entries[i].Message)
+		}
+		lines += len(entries)
+		if chunk != "" {
+			_, err = conn.Write(appendMsgpack(nil, map[string]any{"ack": chunk}))
+		}
+	}
+
+	l.mu.Lock()
+	delete(l.conns, conn)
+	l.mu.Unlock()
+	_ = conn.Close()
+
+	l.srv.audit.Log(AuditEntry{
+		Event:    "forward_push_received",
+		RemoteIP: stripPort(conn.RemoteAddr().String()),
+		Lines:    lines,
+		Bytes:    byteCount,
+		Duration: time.Since(start),
+	})
+}
+
+// handshake runs HELO/PING/PONG with shared key authentication.
+func (l *ForwardListener) handshake(conn net.Conn, dec *msgpackDecoder) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	helo := []any{"HELO", map[string]any{"nonce": nonce, "auth": []byte{}, "keepalive": true}}
+	if _, err := conn.Write(appendMsgpack(nil, helo)); err != nil {
+		return err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	msg, err := dec.Decode()
+	if err != nil {
+		return err
+	}
+	ping, _ := msg.([]any)
+	if len(ping) < 4 {
+		return errors.New("forward handshake: expected PING")
+	}
+	kind, _ := msgpackString(ping[0])
+	clientHost, _ := msgpackString(ping[1])
+	salt, _ := msgpackString(ping[2])
+	digest, _ := msgpackString(ping[3])
+	if kind != "PING" {
+		return errors.New("forward handshake: expected PING")
+	}
+
+	want := forwardDigest(salt, clientHost, string(nonce), l.sharedKey)
+	ok := subtle.ConstantTimeCompare([]byte(digest), []byte(want)) == 1
+	reason := ""
+	if !ok {
+		reason = "shared_key mismatch"
+	}
+	pong := []any{"PONG", ok, reason, l.hostname, forwardDigest(salt, l.hostname, string(nonce), l.sharedKey)}
+	if _, err := conn.Write(appendMsgpack(nil, pong)); err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("forward handshake: %s from %s", reason, clientHost)
+	}
+	return nil
+}
+
+// forwardDigest is the shared key digest of the forward protocol handshake.
+func forwardDigest(salt, hostname, nonce, sharedKey string) string {
+	sum := sha512.Sum512([]byte(salt + hostname + nonce + sharedKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseForwardMessage decodes one forward protocol message into entries and
+// returns the chunk ID to acknowledge, if requested.
+func parseForwardMessage(msg any) ([]LogEntry, string, error) {
+	arr, ok := msg.([]any)
+	if !ok || len(arr) < 2 {
+		return nil, "", fmt.Errorf("forward: expected [tag, ...] array, got %T", msg)
+	}
+	tag, ok := msgpackString(arr[0])
+	if !ok {
+		return nil, "", errors.New("forward: tag is not a string")
+	}
+
+	var option map[string]any
+	var entries []LogEntry
+	switch second := arr[1].(type) {
+	case []any: // Forward mode: [tag, [[time, record], ...], option]
+		for _, e := range second {
+			pair, ok := e.([]any)
+			if !ok || len(pair) < 2 {
+				return nil, "", errors.New("forward: entry is not [time, record]")
+			}
+			entries = append(entries, forwardEntry(tag, pair[0], pair[1]))
+		}
+		option = forwardOption(arr, 2)
+	case string, []byte: // PackedForward mode: [tag, packed entries, option]
+		option = forwardOption(arr, 2)
+		packed, _ := msgpackString(second)
+		var err error
+		if entries, err = parsePackedForward(tag, []byte(packed), option["compressed"] == "gzip"); err != nil {
+			return nil, "", err
+		}
+	default: // Message mode: [tag, time, record, option]
+		if len(arr) < 3 {
+			return nil, "", errors.New("forward: message mode needs [tag, time, record]")
+		}
+		entries = []LogEntry{forwardEntry(tag, arr[1], arr[2])}
+		option = forwardOption(arr, 3)
+	}
+
+	chunk, _ := msgpackString(option["chunk"])
+	return entries, chunk, nil
+}
+
+func forwardOption(arr []any, i int) map[string]any {
+	if len(arr) > i {
+		if m, ok := arr[i].(map[string]any); ok {
+			return m
+		}
+	}
+	return nil
+}
+
+func parsePackedForward(tag string, packed []byte, compressed bool) ([]LogEntry, error) {
+	var r io.Reader = bytes.NewReader(packed)
+	if compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("forward: compressed entries: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		r = io.LimitReader(gz, maxRequestBytes)
+	}
+	dec := newMsgpackDecoder(r)
+	var entries []LogEntry
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("forward: packed entries: %w", err)
+		}
+		pair, ok := v.([]any)
+		if !ok || len(pair) < 2 {
+			return nil, errors.New("forward: packed entry is not [time, record]")
+		}
+		entries = append(entries, forwardEntry(tag, pair[0], pair[1]))
+	}
+}
+
+// forwardEntry builds an entry from a tag, an event time, and a record.
+func forwardEntry(tag string, ts, record any) LogEntry {
+	e := LogEntry{Timestamp: forwardTime(ts), Labels: map[string]string{"tag": tag}}
+	applyRecord(&e, record)
+	return e
+}
+
+// applyRecord sets the message and Kubernetes labels of e from a structured
+// log record (a forward protocol record or a decoded Kafka value).
+func applyRecord(e *LogEntry, record any) {
+	rec, _ := record.(map[string]any)
+
+	found := false
+	for _, f := range recordMessageFields {
+		if s, ok := msgpackString(rec[f]); ok {
+			e.Message = strings.TrimRight(s, "\n")
+			found = true
+			break
+		}
+	}
+	if !found {
+		if b, err := json.Marshal(jsonSafe(record)); err == nil {
+			e.Message = string(b)
+		}
+	}
+
+	if k8s, ok := rec["kubernetes"].(map[string]any); ok {
+		for field, label := range map[string]string{"namespace_name": "namespace", "pod_name": "pod", "container_name": "container"} {
+			if s, ok := msgpackString(k8s[field]); ok && s != "" {
+				e.Labels[label] = s
+			}
+		}
+		if podLabels, ok := k8s["labels"].(map[string]any); ok {
+			for _, key := range []string{"app", "app.kubernetes.io/name"} {
+				if s, ok := msgpackString(podLabels[key]); ok && s != "" {
+					e.Labels["app"] = s
+					break
+				}
+			}
+		}
+	}
+}
+
+// forwardTime converts an integer, float, or EventTime (ext type 0) time.
+func forwardTime(v any) time.Time {
+	switch t := v.(type) {
+	case int64:
+		return time.Unix(t, 0).UTC()
+	case float64:
+		sec := int64(t)
+		return time.Unix(sec, int64((t-float64(sec))*1e9)).UTC()
+	case msgpackExt:
+		if t.Type == 0 && len(t.Data) == 8 {
+			sec := binary.BigEndian.Uint32(t.Data[:4])
+			nsec := binary.BigEndian.Uint32(t.Data[4:])
+			return time.Unix(int64(sec), int64(nsec)).UTC()
+		}
+	}
+	return time.Now()
+}
+
+// jsonSafe converts decoded msgpack values into values encoding/json can
+// marshal: binaries become strings, extensions their raw bytes.
+func jsonSafe(v any) any {
+	switch x := v.(type) {
+	case []byte:
+		return string(x)
+	case msgpackExt:
+		return x.Data
+	case []any:
+		out := make([]any, len(x))
+		for i, item := range x {
+			out[i] = jsonSafe(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, item := range x {
+			out[k] = jsonSafe(item)
+		}
+		return out
+	}
+	return v
+}
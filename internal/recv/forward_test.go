@@ -0,0 +1,232 @@
+package recv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func eventTime(sec, nsec uint32) msgpackExt {
+	b := binary.BigEndian.AppendUint32(nil, sec)
+	return msgpackExt{Type: 0, Data: binary.BigEndian.AppendUint32(b, nsec)}
+}
+
+func TestForwardEntry(t *testing.T) {
+	rec := map[string]any{
+		"log": "GET /health 200\n",
+		"kubernetes": map[string]any{
+			"namespace_name": "shop",
+			"pod_name":       "api-7d9f-x2",
+			"container_name": "api",
+			"labels":         map[string]any{"app.kubernetes.io/name": "api"},
+		},
+	}
+	e := forwardEntry("kube.var.log", eventTime(1736935200, 500), rec)
+	if e.Message != "GET /health 200" {
+		t.Errorf("msg = %q", e.Message)
+	}
+	if !e.Timestamp.Equal(time.Unix(1736935200, 500)) {
+		t.Errorf("ts = %v", e.Timestamp)
+	}
+	want := map[string]string{"tag": "kube.var.log", "namespace": "shop", "pod": "api-7d9f-x2", "container": "api", "app": "api"}
+	for k, v := range want {
+		if e.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, e.Labels[k], v)
+		}
+	}
+
+	// records without a message field are kept as JSON
+	e = forwardEntry("app", int64(1736935200), map[string]any{"level": "info", "bin": []byte("b")})
+	if e.Message != `{"bin":"b","level":"info"}` {
+		t.Errorf("msg = %q", e.Message)
+	}
+	if !e.Timestamp.Equal(time.Unix(1736935200, 0)) {
+		t.Errorf("ts = %v", e.Timestamp)
+	}
+}
+
+func TestParseForwardMessageModes(t *testing.T) {
+	rec := func(msg string) map[string]any { return map[string]any{"message": msg} }
+	var packed []byte
+	packed = appendMsgpack(packed, []any{int64(1), rec("p1")})
+	packed = appendMsgpack(packed, []any{int64(2), rec("p2")})
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(packed)
+	_ = zw.Close()
+
+	cases := []struct {
+		name  string
+		msg   []any
+		want  []string
+		chunk string
+	}{
+		{"message", []any{"t", int64(1), rec("m1")}, []string{"m1"}, ""},
+		{"message with ack", []any{"t", int64(1), rec("m1"), map[string]any{"chunk": "c1"}}, []string{"m1"}, "c1"},
+		{"forward", []any{"t", []any{[]any{int64(1), rec("f1")}, []any{int64(2), rec("f2")}}, map[string]any{"chunk": "c2"}}, []string{"f1", "f2"}, "c2"},
+		{"packed", []any{"t", packed}, []string{"p1", "p2"}, ""},
+		{"compressed", []any{"t", gz.Bytes(), map[string]any{"compressed": "gzip", "chunk": "c3"}}, []string{"p1", "p2"}, "c3"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entries, chunk, err := parseForwardMessage(c.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Message)
+				if e.Labels["tag"] != "t" {
+					t.Errorf("tag = %q", e.Labels["tag"])
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(c.want, ",") {
+				t.Errorf("messages = %v, want %v", got, c.want)
+			}
+			if chunk != c.chunk {
+				t.Errorf("chunk = %q, want %q", chunk, c.chunk)
+			}
+		})
+	}
+}
+
+func TestParseForwardMessageInvalid(t *testing.T) {
+	for _, msg := range []any{
+		"not an array",
+		[]any{"only-tag"},
+		[]any{int64(1), int64(2), map[string]any{}},
+		[]any{"t", []any{"not a pair"}},
+		[]any{"t", int64(1)},
+		[]any{"t", []byte{0x92, 0x01}},
+	} {
+		if _, _, err := parseForwardMessage(msg); err == nil {
+			t.Errorf("expected error for %#v", msg)
+		}
+	}
+}
+
+func startForward(t *testing.T, sharedKey string) (*ForwardListener, *LogRing) {
+	t.Helper()
+	w := NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	l, err := ListenForward("127.0.0.1:0", sharedKey, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, ring
+}
+
+func TestForwardListenerAck(t *testing.T) {
+	l, ring := startForward(t, "")
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write(appendMsgpack(nil, []any{"app", int64(1), map[string]any{"log": "no ack"}}))
+	_, _ = conn.Write(appendMsgpack(nil, []any{"app", []any{[]any{int64(2), map[string]any{"log": "acked"}}}, map[string]any{"chunk": "abc"}}))
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	resp, err := newMsgpackDecoder(conn).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := resp.(map[string]any); m["ack"] != "abc" {
+		t.Errorf("ack = %#v", resp)
+	}
+	_ = conn.Close()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := ring.Snapshot()
+	if len(snap) != 2 || snap[0].Message != "no ack" || snap[1].Message != "acked" {
+		t.Errorf("entries = %+v", snap)
+	}
+}
+
+// forwardClientHandshake performs the client side of HELO/PING/PONG.
+func forwardClientHandshake(t *testing.T, conn net.Conn, sharedKey string) map[string]any {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	dec := newMsgpackDecoder(bufio.NewReader(conn))
+	helo, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, _ := helo.([]any)
+	if len(arr) != 2 || arr[0] != "HELO" {
+		t.Fatalf("helo = %#v", helo)
+	}
+	opts, _ := arr[1].(map[string]any)
+	nonce, _ := msgpackString(opts["nonce"])
+	salt := "s4lt"
+	ping := []any{"PING", "client-1", salt, forwardDigest(salt, "client-1", nonce, sharedKey), "", ""}
+	if _, err := conn.Write(appendMsgpack(nil, ping)); err != nil {
+		t.Fatal(err)
+	}
+	pong, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parr, _ := pong.([]any)
+	if len(parr) != 5 || parr[0] != "PONG" {
+		t.Fatalf("pong = %#v", pong)
+	}
+	host, _ := msgpackString(parr[3])
+	return map[string]any{"ok": parr[1], "reason": parr[2], "digest": parr[4], "want": forwardDigest(salt, host, nonce, sharedKey)}
+}
+
+func TestForwardListenerHandshake(t *testing.T) {
+	l, ring := startForward(t, "secret")
+	defer func() { _ = l.Close() }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := forwardClientHandshake(t, conn, "secret")
+	if res["ok"] != true {
+		t.Fatalf("handshake rejected: %v", res["reason"])
+	}
+	if res["digest"] != res["want"] {
+		t.Error("server digest mismatch")
+	}
+	_, _ = conn.Write(appendMsgpack(nil, []any{"app", int64(1), map[string]any{"log": "authed"}}))
+	_ = conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(ring.Snapshot()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if snap := ring.Snapshot(); len(snap) != 1 || snap[0].Message != "authed" {
+		t.Errorf("entries = %+v", snap)
+	}
+}
+
+func TestForwardListenerHandshakeRejected(t *testing.T) {
+	l, ring := startForward(t, "secret")
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := forwardClientHandshake(t, conn, "wrong")
+	if res["ok"] != false || res["reason"] == "" {
+		t.Errorf("pong = %v", res)
+	}
+	_, _ = conn.Write(appendMsgpack(nil, []any{"app", int64(1), map[string]any{"log": "sneaky"}}))
+	_ = conn.Close()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ring.Snapshot()); n != 0 {
+		t.Errorf("ingested %d entries after failed handshake", n)
+	}
+}
@@ -0,0 +1,498 @@
+package recv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kafka source. The consumer reads every partition of the configured
+// topics itself and, when a group is set, commits its progress to that
+// consumer group as a standalone consumer (it does not join the group or
+// share partitions with other members), so a restarted receiver resumes
+// where it stopped. Partitions without a committed offset start at the
+// latest or earliest offset.
+//
+// Mapping onto capture entries:
+//   - the topic and partition become the topic and partition labels
+//   - JSON and msgpack map values are records: the log, message, or msg
+//     field is the message (others are stored as JSON) and Kubernetes
+//     metadata becomes labels, as for the forward protocol
+//   - any other value is the message as is
+//   - the record timestamp is the entry timestamp
+
+// Kafka start positions for partitions without a committed offset.
+const (
+	KafkaStartLatest   = "latest"
+	KafkaStartEarliest = "earliest"
+)
+
+const (
+	kafkaRequestTimeout = 10 * time.Second
+	kafkaMaxWait        = 500 * time.Millisecond
+	kafkaFetchBytes     = 8 << 20
+	kafkaCommitInterval = 5 * time.Second
+	kafkaMaxBackoff     = 30 * time.Second
+)
+
+// KafkaConfig configures a Kafka source.
+type KafkaConfig struct {
+	Brokers []string // bootstrap brokers, host:port
+	Topics  []string
+	Group   string // consumer group offsets are committed to; empty disables commits
+	Start   string // KafkaStartLatest (default) or KafkaStartEarliest
+	// OnError, if set, is called with errors that interrupt consumption;
+	// the consumer reconnects with backoff.
+	OnError func(error)
+}
+
+// KafkaConsumer consumes Kafka topics into a Server's ingest pipeline.
+type KafkaConsumer struct {
+	cfg    KafkaConfig
+	srv    *Server
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	offsets   map[kafkaTP]int64 // next offset to consume
+	committed map[kafkaTP]int64
+}
+
+// StartKafka validates cfg, checks that a broker is reachable and the
+// topics exist, and starts consuming in the background.
+func StartKafka(cfg KafkaConfig, srv *Server) (*KafkaConsumer, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("kafka: no brokers")
+	}
+	if len(cfg.Topics) == 0 {
+		return nil, errors.New("kafka: no topics")
+	}
+	switch cfg.Start {
+	case "":
+		cfg.Start = KafkaStartLatest
+	case KafkaStartLatest, KafkaStartEarliest:
+	default:
+		return nil, fmt.Errorf("kafka: invalid start %q (expected %s or %s)", cfg.Start, KafkaStartLatest, KafkaStartEarliest)
+	}
+
+	k := &KafkaConsumer{
+		cfg:       cfg,
+		srv:       srv,
+		done:      make(chan struct{}),
+		offsets:   map[kafkaTP]int64{},
+		committed: map[kafkaTP]int64{},
+	}
+	if _, err := k.metadata(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+	go k.run(ctx)
+	return k, nil
+}
+
+// Close stops consuming and commits the final offsets.
+func (k *KafkaConsumer) Close() error {
+	k.cancel()
+	<-k.done
+	return nil
+}
+
+func (k *KafkaConsumer) run(ctx context.Context) {
+	defer close(k.done)
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := k.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && k.cfg.OnError != nil {
+			k.cfg.OnError(err)
+		}
+		if time.Since(start) > kafkaMaxBackoff {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, kafkaMaxBackoff)
+	}
+}
+
+// metadata queries the bootstrap brokers in turn.
+func (k *KafkaConsumer) metadata() (*kafkaMetadata, error) {
+	var lastErr error
+	for _, addr := range k.cfg.Brokers {
+		conn, err := dialKafka(addr, "logtap", kafkaRequestTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		md, err := conn.metadata(k.cfg.Topics, kafkaRequestTimeout)
+		_ = conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, t := range k.cfg.Topics {
+			if len(md.partitions[t]) == 0 {
+				return nil, fmt.Errorf("kafka: topic %q not found", t)
+			}
+		}
+		return md, nil
+	}
+	return nil, fmt.Errorf("kafka: no broker reachable: %w", lastErr)
+}
+
+// session consumes until an error or cancellation: it resolves partition
+// leaders and start offsets, then runs one fetcher per leader.
+func (k *KafkaConsumer) session(ctx context.Context) error {
+	md, err := k.metadata()
+	if err != nil {
+		return err
+	}
+	byLeader := map[string][]kafkaTP{}
+	for _, topic := range k.cfg.Topics {
+		for _, p := range md.partitions[topic] {
+			tp := kafkaTP{topic: topic, partition: p.partition}
+			addr, ok := md.brokers[p.leader]
+			if p.err != 0 || !ok {
+				return fmt.Errorf("kafka: partition %s has no leader", tp)
+			}
+			byLeader[addr] = append(byLeader[addr], tp)
+		}
+	}
+	if err := k.initOffsets(byLeader); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errCh := make(chan error, len(byLeader)+1)
+	var wg sync.WaitGroup
+	for addr, tps := range byLeader {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errCh <- k.fetchLoop(ctx, addr, tps)
+		}()
+	}
+	if k.cfg.Group != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errCh <- k.commitLoop(ctx)
+		}()
+	}
+
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+	}
+	cancel()
+	wg.Wait()
+	if k.cfg.Group != "" {
+		if cerr := k.commit(); cerr != nil && err == nil && ctx.Err() == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// initOffsets sets the start offset of partitions not consumed yet: the
+// group's committed offset, else the configured start position.
+func (k *KafkaConsumer) initOffsets(byLeader map[string][]kafkaTP) error {
+	var missing []kafkaTP
+	k.mu.Lock()
+	for _, tps := range byLeader {
+		for _, tp := range tps {
+			if _, ok := k.offsets[tp]; !ok {
+				missing = append(missing, tp)
+			}
+		}
+	}
+	k.mu.Unlock()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	committed := map[kafkaTP]int64{}
+	if k.cfg.Group != "" {
+		err := k.withCoordinator(func(conn *kafkaConn) error {
+			var err error
+			committed, err = conn.offsetFetch(k.cfg.Group, missing, kafkaRequestTimeout)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	resolved := map[kafkaTP]int64{}
+	missingSet := map[kafkaTP]bool{}
+	for _, tp := range missing {
+		if off, ok := committed[tp]; ok && off >= 0 {
+			resolved[tp] = off
+		} else {
+			missingSet[tp] = true
+		}
+	}
+	for addr, tps := range byLeader {
+		var need []kafkaTP
+		for _, tp := range tps {
+			if missingSet[tp] {
+				need = append(need, tp)
+			}
+		}
+		if len(need) == 0 {
+			continue
+		}
+		offs, err := k.listOffsets(addr, need, k.startTimestamp())
+		if err != nil {
+			return err
+		}
+		for tp, off := range offs {
+			resolved[tp] = off
+		}
+	}
+
+	k.mu.Lock()
+	for tp, off := range resolved {
+		k.offsets[tp] = off
+		k.committed[tp] = committed[tp]
+	}
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *KafkaConsumer) startTimestamp() int64 {
+	if k.cfg.Start == KafkaStartEarliest {
+		return kafkaOffsetEarliest
+	}
+	return kafkaOffsetLatest
+}
+
+func (k *KafkaConsumer) listOffsets(addr string, tps []kafkaTP, ts int64) (map[kafkaTP]int64, error) {
+	conn, err := dialKafka(addr, "logtap", kafkaRequestTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	return conn.listOffsets(tps, ts, kafkaRequestTimeout)
+}
+
+// withCoordinator runs fn on a connection to the group coordinator.
+func (k *KafkaConsumer) withCoordinator(fn func(*kafkaConn) error) error {
+	var addr string
+	var lastErr error
+	for _, b := range k.cfg.Brokers {
+		conn, err := dialKafka(b, "logtap", kafkaRequestTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		addr, err = conn.findCoordinator(k.cfg.Group, kafkaRequestTimeout)
+		_ = conn.Close()
+		if err == nil {
+			break
+		}
+		lastErr = err
+	}
+	if addr == "" {
+		return fmt.Errorf("kafka: %w", lastErr)
+	}
+	conn, err := dialKafka(addr, "logtap", kafkaRequestTimeout)
+	if err != nil {
+		return fmt.Errorf("kafka: coordinator: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	return fn(conn)
+}
+
+// fetchLoop consumes the partitions led by one broker.
+func (k *KafkaConsumer) fetchLoop(ctx context.Context, addr string, tps []kafkaTP) error {
+	conn, err := dialKafka(addr, "logtap", kafkaRequestTimeout)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close() // unblock a pending fetch
+	}()
+
+	for ctx.Err() == nil {
+		offsets := make(map[kafkaTP]int64, len(tps))
+		k.mu.Lock()
+		for _, tp := range tps {
+			offsets[tp] = k.offsets[tp]
+		}
+		k.mu.Unlock()
+
+		resp, err := conn.fetch(offsets, kafkaMaxWait, kafkaFetchBytes)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("kafka: fetch from %s: %w", addr, err)
+		}
+		for tp, fp := range resp {
+			if fp.err == kafkaErrOffsetOutOfRange {
+				if err := k.resetOffset(addr, tp); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := kafkaError(fp.err); err != nil {
+				return fmt.Errorf("kafka: fetch %s: %w", tp, err)
+			}
+			if err := k.ingest(tp, offsets[tp], fp.records); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resetOffset moves a partition whose offset fell out of the retained range
+// (deleted by retention) to the configured start position.
+func (k *KafkaConsumer) resetOffset(addr string, tp kafkaTP) error {
+	offs, err := k.listOffsets(addr, []kafkaTP{tp}, k.startTimestamp())
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.offsets[tp] = offs[tp]
+	k.mu.Unlock()
+	return nil
+}
+
+// ingest decodes a partition's record set and feeds it to the server.
+func (k *KafkaConsumer) ingest(tp kafkaTP, from int64, data []byte) error {
+	start := time.Now()
+	records, next, err := decodeRecordBatches(data, from)
+	if err != nil {
+		return fmt.Errorf("kafka: %s: %w", tp, err)
+	}
+	var byteCount int
+	for _, rec := range records {
+		e := kafkaEntry(tp, rec)
+		k.srv.Ingest(&e)
+		byteCount += len(e.Message)
+	}
+	k.mu.Lock()
+	k.offsets[tp] = next
+	k.mu.Unlock()
+
+	if len(records) > 0 {
+		k.srv.audit.Log(AuditEntry{
+			Event:    "kafka_records_received",
+			Lines:    len(records),
+			Bytes:    byteCount,
+			Duration: time.Since(start),
+		})
+	}
+	return nil
+}
+
+func (k *KafkaConsumer) commitLoop(ctx context.Context) error {
+	ticker := time.NewTicker(kafkaCommitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := k.commit(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// commit commits the offsets that advanced since the last commit.
+func (k *KafkaConsumer) commit() error {
+	pending := map[kafkaTP]int64{}
+	k.mu.Lock()
+	for tp, off := range k.offsets {
+		if off != k.committed[tp] {
+			pending[tp] = off
+		}
+	}
+	k.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	err := k.withCoordinator(func(conn *kafkaConn) error {
+		return conn.offsetCommit(k.cfg.Group, pending, kafkaRequestTimeout)
+	})
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	for tp, off := range pending {
+		k.committed[tp] = off
+	}
+	k.mu.Unlock()
+	return nil
+}
+
+// Offsets returns the next offset to consume per "topic/partition".
+func (k *KafkaConsumer) Offsets() map[string]int64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	out := make(map[string]int64, len(k.offsets))
+	for tp, off := range k.offsets {
+		out[tp.String()] = off
+	}
+	return out
+}
+
+// kafkaEntry builds an entry from a consumed record.
+func kafkaEntry(tp kafkaTP, rec kafkaRecord) LogEntry {
+	e := LogEntry{
+		Timestamp: rec.Timestamp,
+		Labels:    map[string]string{"topic": tp.topic, "partition": strconv.Itoa(int(tp.partition))},
+	}
+	if doc, ok := kafkaValueRecord(rec.Value); ok {
+		applyRecord(&e, doc)
+	} else {
+		e.Message = strings.TrimRight(string(rec.Value), "\n")
+	}
+	return e
+}
+
+// kafkaValueRecord decodes a JSON object or msgpack map value.
+func kafkaValueRecord(v []byte) (map[string]any, bool) {
+	trimmed := bytes.TrimSpace(v)
+	if len(trimmed) == 0 {
+		return nil, false
+	}
+	if trimmed[0] == '{' {
+		var doc map[string]any
+		if err := json.Unmarshal(trimmed, &doc); err == nil {
+			return doc, true
+		}
+		return nil, false
+	}
+	if c := v[0]; c&0xf0 == 0x80 || c == 0xde || c == 0xdf {
+		dec := newMsgpackDecoder(bytes.NewReader(v))
+		doc, err := dec.Decode()
+		if m, ok := doc.(map[string]any); ok && err == nil {
+			if _, err := dec.r.ReadByte(); err == io.EOF {
+				return jsonSafe(m).(map[string]any), true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,535 @@
+package recv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy/xerial"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+
+	"github.com/ppiankov/logtap/internal/kafkawire"
+)
+
+// testBatch encodes a message format v2 record batch.
+func testBatch(t *testing.T, base, tsMillis int64, codec int, attrs int16, values ...string) []byte {
+	t.Helper()
+	var body []byte
+	for i, v := range values {
+		var rec []byte
+		rec = append(rec, 0)                          // attributes
+		rec = binary.AppendVarint(rec, int64(i))      // timestamp delta
+		rec = binary.AppendVarint(rec, int64(i))      // offset delta
+		rec = binary.AppendVarint(rec, -1)            // null key
+		rec = binary.AppendVarint(rec, int64(len(v))) // value
+		rec = append(rec, v...)
+		rec = binary.AppendVarint(rec, 1) // one header
+		rec = binary.AppendVarint(rec, 2)
+		rec = append(rec, "h1"...)
+		rec = binary.AppendVarint(rec, 1)
+		rec = append(rec, 'x')
+		body = binary.AppendVarint(body, int64(len(rec)))
+		body = append(body, rec...)
+	}
+
+	switch codec {
+	case 1:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		body = buf.Bytes()
+	case 2:
+		body = xerial.Encode(nil, body)
+	case 3:
+		var buf bytes.Buffer
+		zw := lz4.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		body = buf.Bytes()
+	case 4:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = enc.EncodeAll(body, nil)
+		_ = enc.Close()
+	}
+
+	var e kafkawire.Encoder
+	e.Int64(base)
+	e.Int32(0) // batch length, filled in below
+	e.Int32(0) // partition leader epoch
+	e.Int8(2)  // magic
+	e.Int32(0) // crc, filled in below
+	e.Int16(attrs | int16(codec))
+	e.Int32(int32(len(values) - 1))
+	e.Int64(tsMillis)
+	e.Int64(tsMillis + int64(len(values)) - 1)
+	e.Int64(-1) // producer id
+	e.Int16(-1) // producer epoch
+	e.Int32(-1) // base sequence
+	e.Int32(int32(len(values)))
+	e.B = append(e.B, body...)
+	binary.BigEndian.PutUint32(e.B[8:], uint32(len(e.B)-12))
+	binary.BigEndian.PutUint32(e.B[17:], crc32.Checksum(e.B[21:], kafkawire.CRC))
+	return e.B
+}
+
+func TestDecodeRecordBatches(t *testing.T) {
+	const ts = 1736935200000
+	for codec, name := range []string{"none", "gzip", "snappy", "lz4", "zstd"} {
+		t.Run(name, func(t *testing.T) {
+			data := testBatch(t, 10, ts, codec, 0, "a", "b", "c")
+			recs, next, err := decodeRecordBatches(data, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next != 13 || len(recs) != 3 {
+				t.Fatalf("next = %d, records = %d", next, len(recs))
+			}
+			r := recs[2]
+			if string(r.Value) != "c" || r.Offset != 12 || r.Key != nil || r.Headers["h1"] != "x" {
+				t.Errorf("record = %+v", r)
+			}
+			if !r.Timestamp.Equal(time.UnixMilli(ts + 2)) {
+				t.Errorf("ts = %v", r.Timestamp)
+			}
+		})
+	}
+}
+
+func TestDecodeRecordBatchesEdges(t *testing.T) {
+	first := testBatch(t, 0, 1000, 0, 0, "a", "b", "c")
+	control := testBatch(t, 3, 1000, 0, kafkaAttrControlBatch, "commit")
+	last := testBatch(t, 4, 1000, 0, kafkaAttrLogAppendTS, "d", "e")
+
+	// records before the fetch offset inside a batch are dropped
+	data := append(append(append([]byte{}, first...), control...), last...)
+	recs, next, err := decodeRecordBatches(data, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range recs {
+		got = append(got, string(r.Value))
+	}
+	if strings.Join(got, ",") != "c,d,e" || next != 6 {
+		t.Errorf("records = %v, next = %d", got, next)
+	}
+	// log append time applies the batch max timestamp
+	if !recs[1].Timestamp.Equal(time.UnixMilli(1001)) {
+		t.Errorf("log append ts = %v", recs[1].Timestamp)
+	}
+
+	// a batch cut off by the fetch size limit is left for the next fetch
+	recs, next, err = decodeRecordBatches(append(append([]byte{}, first...), last[:20]...), 0)
+	if err != nil || len(recs) != 3 || next != 3 {
+		t.Errorf("partial: records = %d, next = %d, err = %v", len(recs), next, err)
+	}
+
+	corrupt := append([]byte{}, first...)
+	corrupt[len(corrupt)-3] ^= 0xff
+	if _, _, err := decodeRecordBatches(corrupt, 0); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("corrupt batch: err = %v", err)
+	}
+	// a length field too short for the batch header is malformed, not a
+	// panic reading the magic byte
+	if _, _, err := decodeRecordBatches(make([]byte, 70), 0); err == nil || !strings.Contains(err.Error(), "shorter than its header") {
+		t.Errorf("zero length batch: err = %v", err)
+	}
+}
+
+func TestKafkaEntry(t *testing.T) {
+	tp := kafkaTP{topic: "logs", partition: 3}
+	ts := time.UnixMilli(1736935200000).UTC()
+
+	jsonVal := `{"log":"GET /health 200\n","kubernetes":{"namespace_name":"shop","pod_name":"api-1","container_name":"api"}}`
+	e := kafkaEntry(tp, kafkaRecord{Timestamp: ts, Value: []byte(jsonVal)})
+	want := map[string]string{"topic": "logs", "partition": "3", "namespace": "shop", "pod": "api-1", "container": "api"}
+	for k, v := range want {
+		if e.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, e.Labels[k], v)
+		}
+	}
+	if e.Message != "GET /health 200" || !e.Timestamp.Equal(ts) {
+		t.Errorf("entry = %+v", e)
+	}
+
+	mp := appendMsgpack(nil, map[string]any{"message": "from msgpack", "level": "warn"})
+	if e := kafkaEntry(tp, kafkaRecord{Value: mp}); e.Message != "from msgpack" {
+		t.Errorf("msgpack message = %q", e.Message)
+	}
+	if e := kafkaEntry(tp, kafkaRecord{Value: appendMsgpack(nil, map[string]any{"level": "warn"})}); e.Message != `{"level":"warn"}` {
+		t.Errorf("msgpack without message = %q", e.Message)
+	}
+	for _, plain := range []string{"plain text line\n", "{not json", "\xdeplain"} {
+		if e := kafkaEntry(tp, kafkaRecord{Value: []byte(plain)}); e.Message != strings.TrimRight(plain, "\n") {
+			t.Errorf("plain %q = %q", plain, e.Message)
+		}
+	}
+}
+
+// fakeKafka is a single-node broker serving one topic from memory.
+type fakeKafka struct {
+	t     *testing.T
+	ln    net.Listener
+	topic string
+
+	mu         sync.Mutex
+	partitions [][]string // values by partition, offset = index
+	committed  map[int32]int64
+	commits    int
+}
+
+func startFakeKafka(t *testing.T, topic string, partitions ...[]string) *fakeKafka {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeKafka{t: t, ln: ln, topic: topic, partitions: partitions, committed: map[int32]int64{}}
+	go f.serve()
+	t.Cleanup(func() { _ = ln.Close() })
+	return f
+}
+
+func (f *fakeKafka) addr() string { return f.ln.Addr().String() }
+
+func (f *fakeKafka) append(partition int, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.partitions[partition] = append(f.partitions[partition], values...)
+}
+
+func (f *fakeKafka) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeKafka) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+		d := &kafkawire.Decoder{B: req}
+		apiKey, _ := d.Int16(), d.Int16()
+		corr := d.Int32()
+		d.Str() // client id
+
+		var e kafkawire.Encoder
+		e.Int32(0)
+		e.Int32(corr)
+		if !f.respond(apiKey, d, &e) {
+			return
+		}
+		binary.BigEndian.PutUint32(e.B, uint32(len(e.B)-4))
+		if _, err := conn.Write(e.B); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeKafka) respond(apiKey int16, d *kafkawire.Decoder, e *kafkawire.Encoder) bool {
+	host, portStr, _ := net.SplitHostPort(f.addr())
+	port, _ := strconv.Atoi(portStr)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch apiKey {
+	case kafkaAPIMetadata:
+		var topics []string
+		for n := d.ArrayLen(); n > 0; n-- {
+			topics = append(topics, d.Str())
+		}
+		e.Int32(0) // throttle
+		e.Int32(1)
+		e.Int32(1)
+		e.Str(host)
+		e.Int32(int32(port))
+		e.Int16(-1) // rack
+		e.Int16(-1) // cluster id
+		e.Int32(1)  // controller
+		e.Int32(int32(len(topics)))
+		for _, topic := range topics {
+			if topic != f.topic {
+				e.Int16(3)
+				e.Str(topic)
+				e.Bool(false)
+				e.Int32(0)
+				continue
+			}
+			e.Int16(0)
+			e.Str(topic)
+			e.Bool(false)
+			e.Int32(int32(len(f.partitions)))
+			for p := range f.partitions {
+				e.Int16(0)
+				e.Int32(int32(p))
+				e.Int32(1) // leader
+				e.Int32(1)
+				e.Int32(1) // replicas
+				e.Int32(1)
+				e.Int32(1) // isr
+			}
+		}
+	case kafkaAPIFindCoordinator:
+		e.Int32(0)
+		e.Int16(0)
+		e.Int16(-1)
+		e.Int32(1)
+		e.Str(host)
+		e.Int32(int32(port))
+	case kafkaAPIOffsetFetch:
+		d.Str() // group
+		e.Int32(int32(d.ArrayLen()))
+		e.Str(d.Str())
+		n := d.ArrayLen()
+		e.Int32(int32(n))
+		for ; n > 0; n-- {
+			p := d.Int32()
+			off, ok := f.committed[p]
+			if !ok {
+				off = -1
+			}
+			e.Int32(p)
+			e.Int64(off)
+			e.Int16(-1)
+			e.Int16(0)
+		}
+	case kafkaAPIListOffsets:
+		d.Int32() // replica
+		e.Int32(int32(d.ArrayLen()))
+		e.Str(d.Str())
+		n := d.ArrayLen()
+		e.Int32(int32(n))
+		for ; n > 0; n-- {
+			p := d.Int32()
+			off := int64(0)
+			if d.Int64() == kafkaOffsetLatest {
+				off = int64(len(f.partitions[p]))
+			}
+			e.Int32(p)
+			e.Int16(0)
+			e.Int64(-1)
+			e.Int64(off)
+		}
+	case kafkaAPIFetch:
+		d.Int32() // replica
+		maxWait := time.Duration(d.Int32()) * time.Millisecond
+		d.Int32()
+		d.Int32()
+		d.Int8()
+		type want struct {
+			p   int32
+			off int64
+		}
+		var wants []want
+		topics := d.ArrayLen()
+		topic := d.Str()
+		for n := d.ArrayLen(); n > 0; n-- {
+			w := want{p: d.Int32(), off: d.Int64()}
+			d.Int32()
+			wants = append(wants, w)
+		}
+		empty := true
+		for _, w := range wants {
+			if w.off < int64(len(f.partitions[w.p])) {
+				empty = false
+			}
+		}
+		if empty {
+			f.mu.Unlock()
+			time.Sleep(min(maxWait, 50*time.Millisecond))
+			f.mu.Lock()
+		}
+		e.Int32(0)
+		e.Int32(int32(topics))
+		e.Str(topic)
+		e.Int32(int32(len(wants)))
+		for _, w := range wants {
+			vals := f.partitions[w.p]
+			e.Int32(w.p)
+			if w.off > int64(len(vals)) {
+				e.Int16(kafkaErrOffsetOutOfRange)
+			} else {
+				e.Int16(0)
+			}
+			e.Int64(int64(len(vals)))
+			e.Int64(int64(len(vals)))
+			e.Int32(-1) // aborted transactions
+			if w.off < int64(len(vals)) {
+				// whole-batch reads like a real broker: the batch starts at 0
+				batch := testBatch(f.t, 0, 1736935200000, 0, 0, vals...)
+				e.Int32(int32(len(batch)))
+				e.B = append(e.B, batch...)
+			} else {
+				e.Int32(0)
+			}
+		}
+	case kafkaAPIOffsetCommit:
+		d.Str()   // group
+		d.Int32() // generation
+		d.Str()   // member
+		d.Int64() // retention
+		e.Int32(int32(d.ArrayLen()))
+		e.Str(d.Str())
+		n := d.ArrayLen()
+		e.Int32(int32(n))
+		for ; n > 0; n-- {
+			p := d.Int32()
+			f.committed[p] = d.Int64()
+			d.Str()
+			e.Int32(p)
+			e.Int16(0)
+		}
+		f.commits++
+	default:
+		return false
+	}
+	return d.Err == nil
+}
+
+func startKafkaTest(t *testing.T, cfg KafkaConfig) (*KafkaConsumer, *LogRing) {
+	t.Helper()
+	w := NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	k, err := StartKafka(cfg, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, ring
+}
+
+func waitRing(t *testing.T, ring *LogRing, n int) []LogEntry {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ring.Snapshot()) < n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	snap := ring.Snapshot()
+	if len(snap) != n {
+		t.Fatalf("got %d entries, want %d", len(snap), n)
+	}
+	return snap
+}
+
+func TestKafkaConsumerGroupOffsets(t *testing.T) {
+	f := startFakeKafka(t, "logs", []string{"a0", "a1", "a2"}, []string{`{"msg":"b0"}`})
+	cfg := KafkaConfig{Brokers: []string{f.addr()}, Topics: []string{"logs"}, Group: "capture", Start: KafkaStartEarliest}
+
+	k, ring := startKafkaTest(t, cfg)
+	snap := waitRing(t, ring, 4)
+	byPartition := map[string][]string{}
+	for _, e := range snap {
+		if e.Labels["topic"] != "logs" {
+			t.Errorf("topic label = %q", e.Labels["topic"])
+		}
+		byPartition[e.Labels["partition"]] = append(byPartition[e.Labels["partition"]], e.Message)
+	}
+	if strings.Join(byPartition["0"], ",") != "a0,a1,a2" || strings.Join(byPartition["1"], ",") != "b0" {
+		t.Errorf("entries by partition = %v", byPartition)
+	}
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	if f.committed[0] != 3 || f.committed[1] != 1 {
+		t.Errorf("committed = %v", f.committed)
+	}
+	f.mu.Unlock()
+
+	// a restarted consumer resumes from the committed offsets
+	f.append(0, "a3")
+	k, ring = startKafkaTest(t, cfg)
+	snap = waitRing(t, ring, 1)
+	if snap[0].Message != "a3" {
+		t.Errorf("resumed entry = %q", snap[0].Message)
+	}
+	_ = k.Close()
+	if got := k.Offsets(); got["logs/0"] != 4 || got["logs/1"] != 1 {
+		t.Errorf("offsets = %v", got)
+	}
+}
+
+func TestKafkaConsumerStartLatest(t *testing.T) {
+	f := startFakeKafka(t, "logs", []string{"old"})
+	k, ring := startKafkaTest(t, KafkaConfig{Brokers: []string{f.addr()}, Topics: []string{"logs"}})
+	defer func() { _ = k.Close() }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for k.Offsets()["logs/0"] != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	f.append(0, "new")
+	if snap := waitRing(t, ring, 1); snap[0].Message != "new" {
+		t.Errorf("entry = %q", snap[0].Message)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.commits != 0 {
+		t.Errorf("commits without a group = %d", f.commits)
+	}
+}
+
+func TestStartKafkaErrors(t *testing.T) {
+	f := startFakeKafka(t, "logs", []string{})
+	srv := NewServer(":0", NewWriter(1024, io.Discard, nil), nil, nil, nil, NewLogRing(0))
+
+	cases := []struct {
+		name string
+		cfg  KafkaConfig
+		want string
+	}{
+		{"no brokers", KafkaConfig{Topics: []string{"logs"}}, "no brokers"},
+		{"no topics", KafkaConfig{Brokers: []string{f.addr()}}, "no topics"},
+		{"bad start", KafkaConfig{Brokers: []string{f.addr()}, Topics: []string{"logs"}, Start: "middle"}, "invalid start"},
+		{"unknown topic", KafkaConfig{Brokers: []string{f.addr()}, Topics: []string{"nope"}}, "UNKNOWN_TOPIC_OR_PARTITION"},
+		{"unreachable", KafkaConfig{Brokers: []string{"127.0.0.1:1"}, Topics: []string{"logs"}}, "no broker reachable"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := StartKafka(c.cfg, srv)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("err = %v, want %q", err, c.want)
+			}
+		})
+	}
+}
+
+func TestKafkaError(t *testing.T) {
+	if kafkaError(0) != nil {
+		t.Error("code 0 is not an error")
+	}
+	if err := kafkaError(6); err == nil || !strings.Contains(err.Error(), "NOT_LEADER_OR_FOLLOWER") {
+		t.Errorf("err = %v", err)
+	}
+	if err := kafkaError(999); err == nil || errors.Unwrap(err) != nil {
+		t.Errorf("unknown code err = %v", err)
+	}
+}
@@ -0,0 +1,526 @@
+package recv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy/xerial"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+
+	"github.com/ppiankov/logtap/internal/kafkawire"
+)
+
+// Minimal Kafka wire protocol client for the Kafka source. It speaks the
+// non-flexible request versions every broker from 0.11 through 4.x accepts
+// (Metadata v4, FindCoordinator v1, ListOffsets v1, Fetch v4, OffsetFetch
+// v1, OffsetCommit v2) over plaintext TCP and decodes message format v2
+// record batches. SASL and TLS are not supported.
+
+const (
+	kafkaAPIFetch           int16 = 1
+	kafkaAPIListOffsets     int16 = 2
+	kafkaAPIMetadata        int16 = 3
+	kafkaAPIOffsetCommit    int16 = 8
+	kafkaAPIOffsetFetch     int16 = 9
+	kafkaAPIFindCoordinator int16 = 10
+)
+
+// ListOffsets timestamps selecting the first and next offset of a partition.
+const (
+	kafkaOffsetEarliest int64 = -2
+	kafkaOffsetLatest   int64 = -1
+)
+
+const (
+	// kafkaMaxResponse caps a response so a corrupt size prefix cannot make
+	// the receiver allocate unbounded memory.
+	kafkaMaxResponse = 64 << 20
+	// kafkaMaxBatch caps a decompressed record batch.
+	kafkaMaxBatch = 64 << 20
+)
+
+// kafkaErrorNames names the error codes the consumer may see.
+var kafkaErrorNames = map[int16]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	14: "COORDINATOR_LOAD_IN_PROGRESS",
+	15: "COORDINATOR_NOT_AVAILABLE",
+	16: "NOT_COORDINATOR",
+	22: "ILLEGAL_GENERATION",
+	25: "UNKNOWN_MEMBER_ID",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	30: "GROUP_AUTHORIZATION_FAILED",
+	35: "UNSUPPORTED_VERSION",
+}
+
+const kafkaErrOffsetOutOfRange int16 = 1
+
+// kafkaError converts a non-zero error code.
+func kafkaError(code int16) error {
+	if code == 0 {
+		return nil
+	}
+	if name, ok := kafkaErrorNames[code]; ok {
+		return fmt.Errorf("kafka error %d (%s)", code, name)
+	}
+	return fmt.Errorf("kafka error %d", code)
+}
+
+// kafkaTP identifies a topic partition.
+type kafkaTP struct {
+	topic     string
+	partition int32
+}
+
+func (tp kafkaTP) String() string {
+	return tp.topic + "/" + strconv.Itoa(int(tp.partition))
+}
+
+// kafkaConn is a connection to one broker. It is not safe for concurrent use.
+type kafkaConn struct {
+	wire *kafkawire.Conn
+}
+
+func dialKafka(addr, clientID string, timeout time.Duration) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConn{wire: kafkawire.NewConn(conn, clientID, kafkaMaxResponse)}, nil
+}
+
+func (c *kafkaConn) Close() error {
+	return c.wire.Close()
+}
+
+// roundTrip sends a request and returns a decoder over the response body.
+func (c *kafkaConn) roundTrip(apiKey, version int16, body []byte, timeout time.Duration) (*kafkawire.Decoder, error) {
+	return c.wire.RoundTrip(apiKey, version, body, time.Now().Add(timeout))
+}
+
+// kafkaMetadata is the part of a Metadata response the consumer uses.
+type kafkaMetadata struct {
+	brokers    map[int32]string // node ID -> host:port
+	partitions map[string][]kafkaPartitionMeta
+}
+
+type kafkaPartitionMeta struct {
+	partition int32
+	leader    int32
+	err       int16
+}
+
+// metadata fetches brokers and partition leaders for topics.
+func (c *kafkaConn) metadata(topics []string, timeout time.Duration) (*kafkaMetadata, error) {
+	var e kafkawire.Encoder
+	e.Int32(int32(len(topics)))
+	for _, t := range topics {
+		e.Str(t)
+	}
+	e.Bool(false) // allow_auto_topic_creation
+	d, err := c.roundTrip(kafkaAPIMetadata, 4, e.B, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &kafkaMetadata{brokers: map[int32]string{}, partitions: map[string][]kafkaPartitionMeta{}}
+	d.Int32() // throttle_time_ms
+	for n := d.ArrayLen(); n > 0; n-- {
+		id := d.Int32()
+		host := d.Str()
+		port := d.Int32()
+		d.Str() // rack
+		md.brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.Str()   // cluster_id
+	d.Int32() // controller_id
+	for n := d.ArrayLen(); n > 0; n-- {
+		topicErr := d.Int16()
+		name := d.Str()
+		d.Bool() // is_internal
+		if topicErr != 0 {
+			if d.Err == nil {
+				return nil, fmt.Errorf("topic %q: %w", name, kafkaError(topicErr))
+			}
+			break
+		}
+		var parts []kafkaPartitionMeta
+		for p := d.ArrayLen(); p > 0; p-- {
+			pm := kafkaPartitionMeta{err: d.Int16(), partition: d.Int32(), leader: d.Int32()}
+			for r := d.ArrayLen(); r > 0; r-- { // replica_nodes
+				d.Int32()
+			}
+			for r := d.ArrayLen(); r > 0; r-- { // isr_nodes
+				d.Int32()
+			}
+			parts = append(parts, pm)
+		}
+		md.partitions[name] = parts
+	}
+	if d.Err != nil {
+		return nil, fmt.Errorf("kafka: metadata response: %w", d.Err)
+	}
+	return md, nil
+}
+
+// findCoordinator returns the address of the group coordinator.
+func (c *kafkaConn) findCoordinator(group string, timeout time.Duration) (string, error) {
+	var e kafkawire.Encoder
+	e.Str(group)
+	e.Int8(0) // key_type: group
+	d, err := c.roundTrip(kafkaAPIFindCoordinator, 1, e.B, timeout)
+	if err != nil {
+		return "", err
+	}
+	d.Int32() // throttle_time_ms
+	code := d.Int16()
+	d.Str()   // error_message
+	d.Int32() // node_id
+	host := d.Str()
+	port := d.Int32()
+	if d.Err != nil {
+		return "", fmt.Errorf("kafka: find coordinator response: %w", d.Err)
+	}
+	if err := kafkaError(code); err != nil {
+		return "", fmt.Errorf("find coordinator for group %q: %w", group, err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+// groupByTopic orders partitions by topic for request encoding.
+func groupByTopic(tps []kafkaTP) (topics []string, parts map[string][]int32) {
+	parts = map[string][]int32{}
+	for _, tp := range tps {
+		if _, ok := parts[tp.topic]; !ok {
+			topics = append(topics, tp.topic)
+		}
+		parts[tp.topic] = append(parts[tp.topic], tp.partition)
+	}
+	return topics, parts
+}
+
+// listOffsets resolves a timestamp (kafkaOffsetEarliest or
+// kafkaOffsetLatest) to an offset for each partition led by this broker.
+func (c *kafkaConn) listOffsets(tps []kafkaTP, ts int64, timeout time.Duration) (map[kafkaTP]int64, error) {
+	topics, parts := groupByTopic(tps)
+	var e kafkawire.Encoder
+	e.Int32(-1) // replica_id
+	e.Int32(int32(len(topics)))
+	for _, t := range topics {
+		e.Str(t)
+		e.Int32(int32(len(parts[t])))
+		for _, p := range parts[t] {
+			e.Int32(p)
+			e.Int64(ts)
+		}
+	}
+	d, err := c.roundTrip(kafkaAPIListOffsets, 1, e.B, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[kafkaTP]int64{}
+	for n := d.ArrayLen(); n > 0; n-- {
+		topic := d.Str()
+		for p := d.ArrayLen(); p > 0; p-- {
+			tp := kafkaTP{topic: topic, partition: d.Int32()}
+			code := d.Int16()
+			d.Int64() // timestamp
+			offset := d.Int64()
+			if err := kafkaError(code); err != nil && d.Err == nil {
+				return nil, fmt.Errorf("list offsets %s: %w", tp, err)
+			}
+			out[tp] = offset
+		}
+	}
+	if d.Err != nil {
+		return nil, fmt.Errorf("kafka: list offsets response: %w", d.Err)
+	}
+	return out, nil
+}
+
+// offsetFetch returns the group's committed offsets; partitions without a
+// commit map to -1.
+func (c *kafkaConn) offsetFetch(group string, tps []kafkaTP, timeout time.Duration) (map[kafkaTP]int64, error) {
+	topics, parts := groupByTopic(tps)
+	var e kafkawire.Encoder
+	e.Str(group)
+	e.Int32(int32(len(topics)))
+	for _, t := range topics {
+		e.Str(t)
+		e.Int32(int32(len(parts[t])))
+		for _, p := range parts[t] {
+			e.Int32(p)
+		}
+	}
+	d, err := c.roundTrip(kafkaAPIOffsetFetch, 1, e.B, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[kafkaTP]int64{}
+	for n := d.ArrayLen(); n > 0; n-- {
+		topic := d.Str()
+		for p := d.ArrayLen(); p > 0; p-- {
+			tp := kafkaTP{topic: topic, partition: d.Int32()}
+			offset := d.Int64()
+			d.Str() // metadata
+			if err := kafkaError(d.Int16()); err != nil && d.Err == nil {
+				return nil, fmt.Errorf("fetch committed offset %s: %w", tp, err)
+			}
+			out[tp] = offset
+		}
+	}
+	if d.Err != nil {
+		return nil, fmt.Errorf("kafka: offset fetch response: %w", d.Err)
+	}
+	return out, nil
+}
+
+// offsetCommit commits offsets for the group as a standalone consumer
+// (generation -1, no member ID).
+func (c *kafkaConn) offsetCommit(group string, offsets map[kafkaTP]int64, timeout time.Duration) error {
+	tps := make([]kafkaTP, 0, len(offsets))
+	for tp := range offsets {
+		tps = append(tps, tp)
+	}
+	topics, parts := groupByTopic(tps)
+	var e kafkawire.Encoder
+	e.Str(group)
+	e.Int32(-1) // generation_id
+	e.Str("")   // member_id
+	e.Int64(-1) // retention_time_ms: broker default
+	e.Int32(int32(len(topics)))
+	for _, t := range topics {
+		e.Str(t)
+		e.Int32(int32(len(parts[t])))
+		for _, p := range parts[t] {
+			e.Int32(p)
+			e.Int64(offsets[kafkaTP{topic: t, partition: p}])
+			e.Str("") // committed_metadata
+		}
+	}
+	d, err := c.roundTrip(kafkaAPIOffsetCommit, 2, e.B, timeout)
+	if err != nil {
+		return err
+	}
+	for n := d.ArrayLen(); n > 0; n-- {
+		topic := d.Str()
+		for p := d.ArrayLen(); p > 0; p-- {
+			tp := kafkaTP{topic: topic, partition: d.Int32()}
+			if err := kafkaError(d.Int16()); err != nil && d.Err == nil {
+				return fmt.Errorf("commit offset %s: %w", tp, err)
+			}
+		}
+	}
+	if d.Err != nil {
+		return fmt.Errorf("kafka: offset commit response: %w", d.Err)
+	}
+	return nil
+}
+
+// kafkaFetchPartition is one partition's result in a Fetch response.
+type kafkaFetchPartition struct {
+	err     int16
+	records []byte
+}
+
+// fetch reads from the given offsets, waiting up to maxWait for data.
+func (c *kafkaConn) fetch(offsets map[kafkaTP]int64, maxWait time.Duration, maxBytes int32) (map[kafkaTP]kafkaFetchPartition, error) {
+	tps := make([]kafkaTP, 0, len(offsets))
+	for tp := range offsets {
+		tps = append(tps, tp)
+	}
+	topics, parts := groupByTopic(tps)
+	var e kafkawire.Encoder
+	e.Int32(-1) // replica_id
+	e.Int32(int32(maxWait / time.Millisecond))
+	e.Int32(1) // min_bytes
+	e.Int32(maxBytes)
+	e.Int8(0) // isolation_level: read uncommitted
+	e.Int32(int32(len(topics)))
+	for _, t := range topics {
+		e.Str(t)
+		e.Int32(int32(len(parts[t])))
+		for _, p := range parts[t] {
+			e.Int32(p)
+			e.Int64(offsets[kafkaTP{topic: t, partition: p}])
+			e.Int32(maxBytes) // partition_max_bytes
+		}
+	}
+	d, err := c.roundTrip(kafkaAPIFetch, 4, e.B, maxWait+30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[kafkaTP]kafkaFetchPartition{}
+	d.Int32() // throttle_time_ms
+	for n := d.ArrayLen(); n > 0; n-- {
+		topic := d.Str()
+		for p := d.ArrayLen(); p > 0; p-- {
+			tp := kafkaTP{topic: topic, partition: d.Int32()}
+			fp := kafkaFetchPartition{err: d.Int16()}
+			d.Int64()                           // high_watermark
+			d.Int64()                           // last_stable_offset
+			for a := d.ArrayLen(); a > 0; a-- { // aborted_transactions
+				d.Int64()
+				d.Int64()
+			}
+			fp.records = d.Bytes()
+			out[tp] = fp
+		}
+	}
+	if d.Err != nil {
+		return nil, fmt.Errorf("kafka: fetch response: %w", d.Err)
+	}
+	return out, nil
+}
+
+// kafkaRecord is one decoded record.
+type kafkaRecord struct {
+	Offset    int64
+	Timestamp time.Time
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+}
+
+const (
+	kafkaBatchHeaderLen   = 61
+	kafkaAttrCompression  = 0x07
+	kafkaAttrLogAppendTS  = 0x08
+	kafkaAttrControlBatch = 0x20
+)
+
+// decodeRecordBatches decodes a fetched record set. It returns the records
+// at or after minOffset and the offset to fetch next; a partial batch at
+// the end of the set (cut off by the size limit) is left for the next
+// fetch. Legacy (pre-0.11) message sets are skipped.
+func decodeRecordBatches(data []byte, minOffset int64) ([]kafkaRecord, int64, error) {
+	var records []kafkaRecord
+	next := minOffset
+	for len(data) >= 17 {
+		baseOffset := int64(binary.BigEndian.Uint64(data))
+		batchLen := int(int32(binary.BigEndian.Uint32(data[8:])))
+		if batchLen < 0 || 12+batchLen > len(data) {
+			break // partial batch
+		}
+		if 12+batchLen < kafkaBatchHeaderLen {
+			return nil, next, fmt.Errorf("kafka: record batch at offset %d: length %d is shorter than its header", baseOffset, batchLen)
+		}
+		batch := data[:12+batchLen]
+		data = data[12+batchLen:]
+
+		if magic := batch[16]; magic != 2 {
+			next = max(next, baseOffset+1)
+			continue
+		}
+		if crc32.Checksum(batch[21:], kafkawire.CRC) != binary.BigEndian.Uint32(batch[17:]) {
+			return nil, next, fmt.Errorf("kafka: record batch at offset %d: checksum mismatch", baseOffset)
+		}
+		attrs := int16(binary.BigEndian.Uint16(batch[21:]))
+		lastOffsetDelta := int32(binary.BigEndian.Uint32(batch[23:]))
+		firstTS := int64(binary.BigEndian.Uint64(batch[27:]))
+		maxTS := int64(binary.BigEndian.Uint64(batch[35:]))
+		count := int(int32(binary.BigEndian.Uint32(batch[57:])))
+		batchNext := baseOffset + int64(lastOffsetDelta) + 1
+
+		if attrs&kafkaAttrControlBatch != 0 || batchNext <= minOffset {
+			next = max(next, batchNext)
+			continue
+		}
+		body, err := kafkaDecompress(int(attrs&kafkaAttrCompression), batch[kafkaBatchHeaderLen:])
+		if err != nil {
+			return nil, next, fmt.Errorf("kafka: record batch at offset %d: %w", baseOffset, err)
+		}
+
+		d := &kafkawire.Decoder{B: body}
+		for i := 0; i < count && d.Err == nil; i++ {
+			rec := &kafkawire.Decoder{B: d.Take(int(d.Varint()))}
+			rec.Int8() // attributes
+			tsDelta := rec.Varint()
+			offsetDelta := rec.Varint()
+			r := kafkaRecord{Offset: baseOffset + offsetDelta, Key: rec.VarBytes(), Value: rec.VarBytes()}
+			for h := rec.Varint(); h > 0 && rec.Err == nil; h-- {
+				k := string(rec.VarBytes())
+				v := rec.VarBytes()
+				if r.Headers == nil {
+					r.Headers = map[string]string{}
+				}
+				r.Headers[k] = string(v)
+			}
+			if rec.Err != nil {
+				d.Err = rec.Err
+				break
+			}
+			ms := firstTS + tsDelta
+			if attrs&kafkaAttrLogAppendTS != 0 {
+				ms = maxTS
+			}
+			if ms >= 0 {
+				r.Timestamp = time.UnixMilli(ms).UTC()
+			}
+			if r.Offset >= minOffset {
+				records = append(records, r)
+			}
+		}
+		if d.Err != nil {
+			return nil, next, fmt.Errorf("kafka: record batch at offset %d: %w", baseOffset, d.Err)
+		}
+		next = max(next, batchNext)
+	}
+	return records, next, nil
+}
+
+// kafkaDecompress decodes a record batch body with the batch's codec.
+func kafkaDecompress(codec int, data []byte) ([]byte, error) {
+	var r io.Reader
+	switch codec {
+	case 0:
+		return data, nil
+	case 1:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	case 2:
+		out, err := xerial.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("snappy: %w", err)
+		}
+		return out, nil
+	case 3:
+		r = lz4.NewReader(bytes.NewReader(data))
+	case 4:
+		zr, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %d", codec)
+	}
+	out, err := io.ReadAll(io.LimitReader(r, kafkaMaxBatch+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > kafkaMaxBatch {
+		return nil, errors.New("decompressed batch too large")
+	}
+	return out, nil
+}
@@ -0,0 +1,87 @@
+package recv
+
+import "fmt"
+
+// Reasons a push is refused by LabelLimits, as counted in
+// logtap_push_label_limited_total.
+const (
+	LimitReasonLabels     = "labels"
+	LimitReasonValueBytes = "value_bytes"
+)
+
+// LabelLimits caps the labels of each stream or entry of a push, so
+// producers stuffing whole payloads into labels cannot bloat the index and
+// the per-stream state held in memory. Zero fields are unlimited; limits are
+// opt-in because OTLP resource attributes become labels and SDKs send many.
+type LabelLimits struct {
+	MaxLabels     int // labels per stream
+	MaxValueBytes int // bytes per label value
+}
+
+// LabelLimitError reports labels over a LabelLimits cap.
+type LabelLimitError struct {
+	Reason string // LimitReasonLabels or LimitReasonValueBytes
+	msg    string
+}
+
+func (e *LabelLimitError) Error() string { return e.msg }
+
+// Check returns a *LabelLimitError if labels exceed the limits.
+func (l LabelLimits) Check(labels map[string]string) error {
+	if err := l.check(labels); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (l LabelLimits) check(labels map[string]string) *LabelLimitError {
+	if l.MaxLabels > 0 && len(labels) > l.MaxLabels {
+		return &LabelLimitError{
+			Reason: LimitReasonLabels,
+			msg:    fmt.Sprintf("stream has %d labels, more than the limit of %d", len(labels), l.MaxLabels),
+		}
+	}
+	if l.MaxValueBytes > 0 {
+		for k, v := range labels {
+			if len(v) > l.MaxValueBytes {
+				return &LabelLimitError{
+					Reason: LimitReasonValueBytes,
+					msg:    fmt.Sprintf("label %q value is %d bytes, more than the limit of %d", truncateLabel(k), len(v), l.MaxValueBytes),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// truncateLabel shortens a label name quoted in an error.
+func truncateLabel(k string) string {
+	const maxLen = 64
+	if len(k) <= maxLen {
+		return k
+	}
+	return k[:maxLen] + "..."
+}
+
+// SetLabelLimits refuses pushes (Loki, raw, OTLP/HTTP and gRPC, and _bulk)
+// with a stream or entry over the limits. The zero value accepts any labels.
+func (s *Server) SetLabelLimits(l LabelLimits) {
+	s.limits = l
+}
+
+// checkLabelLimits checks the labels of every stream or entry of a push
+// before anything of it is stored, and counts a refused push.
+func (s *Server) checkLabelLimits(endpoint string, labels func(i int) map[string]string, n int) *LabelLimitError {
+	if s.limits == (LabelLimits{}) {
+		return nil
+	}
+	for i := range n {
+		if err := s.limits.check(labels(i)); err != nil {
+			if s.metrics != nil {
+				s.metrics.PushLabelLimited.WithLabelValues(endpoint, err.Reason).Inc()
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package recv
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLabelLimitsCheck(t *testing.T) {
+	l := LabelLimits{MaxLabels: 2, MaxValueBytes: 4}
+
+	if err := l.Check(map[string]string{"app": "api", "env": "prod"}); err != nil {
+		t.Errorf("within limits: %v", err)
+	}
+	var lerr *LabelLimitError
+	err := l.Check(map[string]string{"a": "1", "b": "2", "c": "3"})
+	if !errors.As(err, &lerr) || lerr.Reason != LimitReasonLabels {
+		t.Errorf("3 labels: %v, want %s", err, LimitReasonLabels)
+	}
+	err = l.Check(map[string]string{"payload": `{"a":1}`})
+	if !errors.As(err, &lerr) || lerr.Reason != LimitReasonValueBytes || !strings.Contains(err.Error(), `"payload"`) {
+		t.Errorf("long value: %v, want %s", err, LimitReasonValueBytes)
+	}
+	if err := (LabelLimits{}).Check(map[string]string{"a": strings.Repeat("x", 1<<16)}); err != nil {
+		t.Errorf("zero limits should accept anything: %v", err)
+	}
+}
+
+func TestPush_LabelLimits(t *testing.T) {
+	ring := NewLogRing(10)
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	reg := prometheus.NewRegistry()
+	srv := NewServer(":0", w, nil, NewMetrics(reg), nil, ring)
+	srv.SetLabelLimits(LabelLimits{MaxLabels: 2, MaxValueBytes: 16})
+
+	push := func(path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// one stream over the limit refuses the whole push
+	rec := push("/loki/api/v1/push", `{"streams":[{"stream":{"app":"api"},"values":[["1234567890000000000","ok"]]},`+
+		`{"stream":{"app":"api","body":"{\"user\":\"x\",\"items\":[1,2,3]}"},"values":[["1234567890000000000","stuffed"]]}]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "limit of 16") {
+		t.Errorf("loki: status = %d, body = %q; want 400", rec.Code, rec.Body.String())
+	}
+	rec = push("/logtap/raw", `{"msg":"a","labels":{"a":"1","b":"2","c":"3"}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("raw: status = %d, want 400", rec.Code)
+	}
+	rec = push("/logtap/raw", `{"msg":"a","labels":{"a":"1","session":"s"}}`)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("raw within limits: status = %d, want 204", rec.Code)
+	}
+	if n := len(ring.Snapshot()); n != 1 {
+		t.Errorf("ring has %d entries, want only the accepted one", n)
+	}
+
+	f := gatherMetric(t, reg, "logtap_push_label_limited_total")
+	if f == nil {
+		t.Fatal("logtap_push_label_limited_total not found")
+	}
+	got := make(map[string]float64)
+	for _, m := range f.GetMetric() {
+		got[m.GetLabel()[0].GetValue()+"/"+m.GetLabel()[1].GetValue()] = m.GetCounter().GetValue()
+	}
+	if got["loki/value_bytes"] != 1 || got["raw/labels"] != 1 {
+		t.Errorf("label limited = %v, want loki/value_bytes=1 raw/labels=1", got)
+	}
+}
+
+func TestOTLPGRPC_LabelLimits(t *testing.T) {
+	ring := NewLogRing(10)
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+
+	// off by default: every resource attribute is kept
+	if _, err := srv.exportOTLP(context.Background(), sampleOTLPRequest()); err != nil {
+		t.Fatalf("Export without limits: %v", err)
+	}
+
+	srv.SetLabelLimits(LabelLimits{MaxLabels: 2})
+	_, err := srv.exportOTLP(context.Background(), sampleOTLPRequest())
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "limit of 2") {
+		t.Errorf("Export over the limit = %v, want InvalidArgument", err)
+	}
+	if n := len(ring.Snapshot()); n != 2 {
+		t.Errorf("ring has %d entries, want only the first push", n)
+	}
+}
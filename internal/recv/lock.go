@@ -0,0 +1,118 @@
+package recv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LockFile marks a capture directory as being written by a running
+// receiver. It is removed when the receiver stops; one left behind by a
+// crash goes stale once its heartbeat stops.
+const LockFile = ".logtap.lock"
+
+// LockHeartbeat is how often a receiver refreshes the modification time of
+// its lock file.
+const LockHeartbeat = 30 * time.Second
+
+// lockStaleAfter is how long a lock file may go without a heartbeat before
+// its writer is presumed dead.
+const lockStaleAfter = 3 * LockHeartbeat
+
+// heartbeatInterval is LockHeartbeat, shortened in tests.
+var heartbeatInterval = LockHeartbeat
+
+// LockInfo describes the receiver holding a capture lock.
+type LockInfo struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Started   time.Time `json:"started"`
+	Heartbeat time.Time `json:"-"` // modification time of the lock file
+}
+
+// Live reports whether the lock's writer refreshed it recently enough to
+// still be running at now.
+func (li *LockInfo) Live(now time.Time) bool {
+	return now.Sub(li.Heartbeat) < lockStaleAfter
+}
+
+// CaptureLock is a lock file held by a running receiver.
+type CaptureLock struct {
+	path string
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// AcquireLock writes the lock file of dir and refreshes it every
+// LockHeartbeat until Release.
+func AcquireLock(dir string) (*CaptureLock, error) {
+	host, _ := os.Hostname()
+	data, err := json.Marshal(LockInfo{PID: os.Getpid(), Host: host, Started: time.Now().UTC()})
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, LockFile)
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("write lock file: %w", err)
+	}
+
+	l := &CaptureLock{path: path, stop: make(chan struct{})}
+	l.wg.Add(1)
+	go l.heartbeat()
+	return l, nil
+}
+
+func (l *CaptureLock) heartbeat() {
+	defer l.wg.Done()
+	t := time.NewTicker(heartbeatInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+			now := time.Now()
+			_ = os.Chtimes(l.path, now, now)
+		}
+	}
+}
+
+// Release stops the heartbeat and removes the lock file.
+func (l *CaptureLock) Release() error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		l.wg.Wait()
+		if rerr := os.Remove(l.path); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			err = rerr
+		}
+	})
+	return err
+}
+
+// ReadLock returns the lock of dir, or nil without error if there is none.
+func ReadLock(dir string) (*LockInfo, error) {
+	path := filepath.Join(dir, LockFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var li LockInfo
+	if err := json.Unmarshal(data, &li); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	li.Heartbeat = st.ModTime()
+	return &li, nil
+}
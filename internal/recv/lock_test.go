@@ -0,0 +1,53 @@
+package recv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCaptureLock(t *testing.T) {
+	old := heartbeatInterval
+	heartbeatInterval = 10 * time.Millisecond
+	defer func() { heartbeatInterval = old }()
+
+	dir := t.TempDir()
+	if li, err := ReadLock(dir); err != nil || li != nil {
+		t.Fatalf("ReadLock without lock = %v, %v", li, err)
+	}
+
+	l, err := AcquireLock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, LockFile), past, past); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	li, err := ReadLock(dir)
+	if err != nil || li == nil {
+		t.Fatalf("ReadLock = %v, %v", li, err)
+	}
+	if li.PID != os.Getpid() || li.Started.IsZero() {
+		t.Errorf("lock = %+v, want this process", li)
+	}
+	if !li.Live(time.Now()) {
+		t.Errorf("heartbeat %v not refreshed", li.Heartbeat)
+	}
+	if li.Live(time.Now().Add(lockStaleAfter)) {
+		t.Error("lock should go stale without heartbeats")
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("second Release: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFile)); !os.IsNotExist(err) {
+		t.Errorf("lock file left after Release: %v", err)
+	}
+}
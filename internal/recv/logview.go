@@ -86,3 +86,23 @@ func (r *LogRing) Version() int {
 	r.mu.Unlock()
 	return v
 }
+
+// Len returns the number of entries in the ring.
+func (r *LogRing) Len() int {
+	r.mu.Lock()
+	n := r.count
+	r.mu.Unlock()
+	return n
+}
+
+// Cap returns the ring capacity.
+func (r *LogRing) Cap() int { return r.cap }
+
+// Reset empties the ring.
+func (r *LogRing) Reset() {
+	r.mu.Lock()
+	clear(r.buf)
+	r.head, r.count = 0, 0
+	r.version++
+	r.mu.Unlock()
+}
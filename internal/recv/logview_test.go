@@ -65,6 +65,25 @@ func TestLogRingVersion(t *testing.T) {
 	}
 }
 
+func TestLogRingReset(t *testing.T) {
+	r := NewLogRing(2)
+	r.Push(LogEntry{Message: "a"})
+	r.Push(LogEntry{Message: "b"})
+	r.Push(LogEntry{Message: "c"})
+	v := r.Version()
+	r.Reset()
+	if snap := r.Snapshot(); snap != nil {
+		t.Errorf("snapshot after reset = %v", snap)
+	}
+	if r.Version() <= v {
+		t.Errorf("version = %d, want > %d after reset", r.Version(), v)
+	}
+	r.Push(LogEntry{Message: "d"})
+	if snap := r.Snapshot(); len(snap) != 1 || snap[0].Message != "d" {
+		t.Errorf("snapshot = %v, want [d]", snap)
+	}
+}
+
 func TestLogRingDefaultCapacity(t *testing.T) {
 	r := NewLogRing(0)
 	// should not panic on push
@@ -0,0 +1,165 @@
+package recv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of logproto.PushRequest and its nested messages, the native
+// Loki push wire format (snappy-compressed protobuf).
+const (
+	lokiPushStreams = 1 // PushRequest.streams
+
+	lokiStreamLabels  = 1 // StreamAdapter.labels
+	lokiStreamEntries = 2 // StreamAdapter.entries
+
+	lokiEntryTimestamp = 1 // EntryAdapter.timestamp
+	lokiEntryLine      = 2 // EntryAdapter.line
+
+	protoTimestampSeconds = 1 // google.protobuf.Timestamp.seconds
+	protoTimestampNanos   = 2 // google.protobuf.Timestamp.nanos
+)
+
+// ParseLokiProtobuf decodes a snappy-compressed logproto.PushRequest into the
+// same shape as the JSON push payload. Structured metadata is ignored.
+func ParseLokiProtobuf(body []byte) (*LokiPushRequest, error) {
+	n, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy body: %w", err)
+	}
+	if n > maxRequestBytes {
+		return nil, fmt.Errorf("decompressed body exceeds %d bytes", maxRequestBytes)
+	}
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy body: %w", err)
+	}
+
+	req := &LokiPushRequest{}
+	err = protoFields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != lokiPushStreams || typ != protowire.BytesType {
+			return nil
+		}
+		stream, err := parseLokiProtoStream(v)
+		if err != nil {
+			return err
+		}
+		req.Streams = append(req.Streams, stream)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid push request: %w", err)
+	}
+	return req, nil
+}
+
+func parseLokiProtoStream(data []byte) (LokiStream, error) {
+	var stream LokiStream
+	err := protoFields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case lokiStreamLabels:
+			labels, err := parseLokiLabels(string(v))
+			if err != nil {
+				return err
+			}
+			stream.Stream = labels
+		case lokiStreamEntries:
+			ts, line, err := parseLokiProtoEntry(v)
+			if err != nil {
+				return err
+			}
+			stream.Values = append(stream.Values, []string{ts, line})
+		}
+		return nil
+	})
+	return stream, err
+}
+
+// parseLokiProtoEntry returns the entry's timestamp as a nanosecond string,
+// matching the JSON values array, and its line.
+func parseLokiProtoEntry(data []byte) (string, string, error) {
+	var sec, nsec int64
+	var line string
+	err := protoFields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == lokiEntryTimestamp && typ == protowire.BytesType:
+			return protoFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if typ != protowire.VarintType {
+					return nil
+				}
+				switch num {
+				case protoTimestampSeconds:
+					sec = int64(protoVarint(v))
+				case protoTimestampNanos:
+					nsec = int64(int32(protoVarint(v)))
+				}
+				return nil
+			})
+		case num == lokiEntryLine && typ == protowire.BytesType:
+			line = string(v)
+		}
+		return nil
+	})
+	return strconv.FormatInt(sec*1e9+nsec, 10), line, err
+}
+
+// parseLokiLabels parses a Prometheus-style label set such as
+// {app="api", namespace="prod"}.
+func parseLokiLabels(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("invalid labels %q", s)
+	}
+	rest := strings.TrimSpace(s[1 : len(s)-1])
+	labels := make(map[string]string)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid labels %q", s)
+		}
+		name := strings.TrimSpace(rest[:eq])
+		rest = strings.TrimLeft(rest[eq+1:], " ")
+		end := quotedEnd(rest)
+		if end < 0 {
+			return nil, fmt.Errorf("invalid labels %q", s)
+		}
+		value, err := strconv.Unquote(rest[:end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid label value in %q: %w", s, err)
+		}
+		labels[name] = value
+		rest = strings.TrimLeft(rest[end:], " ")
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return nil, fmt.Errorf("invalid labels %q", s)
+		}
+		rest = strings.TrimLeft(rest[1:], " ")
+	}
+	return labels, nil
+}
+
+// quotedEnd returns the index just past the double-quoted string at the
+// start of s, or -1 if s does not start with a terminated one.
+func quotedEnd(s string) int {
+	if s == "" || s[0] != '"' {
+		return -1
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
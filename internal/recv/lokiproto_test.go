@@ -0,0 +1,117 @@
+package recv
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// lokiProtoBody builds a snappy-compressed PushRequest with one stream.
+func lokiProtoBody(labels string, sec, nsec int64, line string) []byte {
+	var ts []byte
+	ts = protowire.AppendTag(ts, protoTimestampSeconds, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(sec))
+	ts = protowire.AppendTag(ts, protoTimestampNanos, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(nsec))
+
+	var entry []byte
+	entry = protowire.AppendTag(entry, lokiEntryTimestamp, protowire.BytesType)
+	entry = protowire.AppendBytes(entry, ts)
+	entry = protowire.AppendTag(entry, lokiEntryLine, protowire.BytesType)
+	entry = protowire.AppendString(entry, line)
+
+	var stream []byte
+	stream = protowire.AppendTag(stream, lokiStreamLabels, protowire.BytesType)
+	stream = protowire.AppendString(stream, labels)
+	stream = protowire.AppendTag(stream, lokiStreamEntries, protowire.BytesType)
+	stream = protowire.AppendBytes(stream, entry)
+
+	var req []byte
+	req = protowire.AppendTag(req, lokiPushStreams, protowire.BytesType)
+	req = protowire.AppendBytes(req, stream)
+	return snappy.Encode(nil, req)
+}
+
+func TestLokiPushProtobuf(t *testing.T) {
+	w := NewWriter(1024, &bytes.Buffer{}, nil)
+	t.Cleanup(w.Close)
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	ts := httptest.NewServer(srv.httpSrv.Handler)
+	defer ts.Close()
+
+	body := lokiProtoBody(`{app="api", msg="a \"quoted\", value"}`, 1700000000, 5, "hello proto")
+	resp, err := http.Post(ts.URL+"/loki/api/v1/push", "application/x-protobuf", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", resp.StatusCode)
+	}
+
+	entries := ring.Snapshot()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Message != "hello proto" {
+		t.Errorf("message = %q", e.Message)
+	}
+	if !e.Timestamp.Equal(time.Unix(1700000000, 5)) {
+		t.Errorf("timestamp = %v", e.Timestamp)
+	}
+	if e.Labels["app"] != "api" || e.Labels["msg"] != `a "quoted", value` {
+		t.Errorf("labels = %v", e.Labels)
+	}
+
+	resp, err = http.Post(ts.URL+"/loki/api/v1/push", "application/x-protobuf", bytes.NewReader([]byte("not snappy")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid body status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestParseLokiLabels(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+		ok   bool
+	}{
+		{`{}`, map[string]string{}, true},
+		{`{a="1"}`, map[string]string{"a": "1"}, true},
+		{` { a = "1" ,b="x\ny" } `, map[string]string{"a": "1", "b": "x\ny"}, true},
+		{`{a="}"}`, map[string]string{"a": "}"}, true},
+		{`a="1"`, nil, false},
+		{`{a=1}`, nil, false},
+		{`{a="1" b="2"}`, nil, false},
+		{`{a="1}`, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parseLokiLabels(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseLokiLabels(%q) err = %v, want ok=%v", tt.in, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseLokiLabels(%q) = %v, want %v", tt.in, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("parseLokiLabels(%q)[%s] = %q, want %q", tt.in, k, got[k], v)
+			}
+		}
+	}
+}
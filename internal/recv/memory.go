@@ -0,0 +1,88 @@
+package recv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultMemoryEntries is the ring size of an in-memory receiver.
+const DefaultMemoryEntries = 100_000
+
+// InMemoryServer is a receiver that keeps the newest entries in a bounded
+// ring instead of writing a capture, for integration tests of forwarders
+// and producer apps. Entries are read back with Entries or over the live
+// query API; nothing touches the disk.
+type InMemoryServer struct {
+	*Server
+	ring   *LogRing
+	writer *Writer
+	ln     net.Listener
+	errCh  chan error
+}
+
+// NewInMemoryServer creates an in-memory receiver for addr (e.g.
+// "127.0.0.1:0") holding up to entries entries; entries ≤ 0 uses
+// DefaultMemoryEntries. It serves nothing until Start.
+func NewInMemoryServer(addr string, entries int) *InMemoryServer {
+	if entries <= 0 {
+		entries = DefaultMemoryEntries
+	}
+	ring := NewLogRing(entries)
+	writer := NewWriter(65536, io.Discard, nil)
+	return &InMemoryServer{
+		Server: NewServer(addr, writer, nil, nil, nil, ring),
+		ring:   ring,
+		writer: writer,
+		errCh:  make(chan error, 1),
+	}
+}
+
+// Start listens on the server's address and serves in the background.
+func (m *InMemoryServer) Start() error {
+	ln, err := net.Listen("tcp", m.httpSrv.Addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	m.ln = ln
+	go func() {
+		if err := m.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.errCh <- err
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on, with the port chosen
+// when started on port 0.
+func (m *InMemoryServer) Addr() string {
+	if m.ln == nil {
+		return m.httpSrv.Addr
+	}
+	return m.ln.Addr().String()
+}
+
+// URL returns the server's base URL, e.g. for a forwarder's push target.
+func (m *InMemoryServer) URL() string { return "http://" + m.Addr() }
+
+// Err returns a channel receiving the error that stopped serving, if any.
+func (m *InMemoryServer) Err() <-chan error { return m.errCh }
+
+// Entries returns the entries received, oldest first.
+func (m *InMemoryServer) Entries() []LogEntry { return m.ring.Snapshot() }
+
+// Reset forgets the entries received so far.
+func (m *InMemoryServer) Reset() { m.ring.Reset() }
+
+// Close stops serving and the writer.
+func (m *InMemoryServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := m.Shutdown(ctx)
+	m.writer.Close()
+	return err
+}
@@ -0,0 +1,61 @@
+package recv
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInMemoryServer(t *testing.T) {
+	srv := NewInMemoryServer("127.0.0.1:0", 2)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = srv.Close() }()
+
+	push := func(path, body string) {
+		t.Helper()
+		resp, err := http.Post(srv.URL()+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			t.Fatalf("push %s: status %d", path, resp.StatusCode)
+		}
+	}
+	push("/logtap/raw", `{"ts":"2024-01-15T10:00:00Z","labels":{"app":"api"},"msg":"first"}`)
+	push("/loki/api/v1/push", `{"streams":[{"stream":{"app":"web"},"values":[["1705312801000000000","second"],["1705312802000000000","third"]]}]}`)
+
+	var got []string
+	for _, e := range srv.Entries() {
+		got = append(got, e.Message)
+	}
+	if strings.Join(got, ",") != "second,third" {
+		t.Errorf("entries = %v, want the newest two", got)
+	}
+
+	resp, err := http.Get(srv.URL() + "/logtap/api/v1/query?label=app%3Dweb&grep=third")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var res QueryResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Entries) != 1 || res.Entries[0].Message != "third" {
+		t.Errorf("query = %+v", res)
+	}
+
+	srv.Reset()
+	if n := len(srv.Entries()); n != 0 {
+		t.Errorf("after Reset: %d entries", n)
+	}
+	push("/logtap/raw", `{"ts":"2024-01-15T10:00:03Z","msg":"fourth"}`)
+	if e := srv.Entries(); len(e) != 1 || !e[0].Timestamp.Equal(time.Date(2024, 1, 15, 10, 0, 3, 0, time.UTC)) {
+		t.Errorf("after Reset and push: %+v", e)
+	}
+}
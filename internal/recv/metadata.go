@@ -5,19 +5,144 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 )
 
+// MetadataVersion is the metadata.json schema version written by this
+// release. Version 2 adds Features; metadata without a version is version 1.
+// Captures of older versions are read as they are and upgraded in place by
+// logtap migrate.
+const MetadataVersion = 2
+
+// Capture features listed in Metadata.Features, so tools can tell what a
+// capture needs them to understand before reading it.
+const (
+	FeatureShards     = "shards"      // data files spread over the directories in Shards
+	FeatureSessions   = "sessions"    // one capture per session subdirectory
+	FeaturePartitions = "partitions"  // a file series per value of PartitionBy
+	FeatureDedup      = "dedup"       // entries with repeat_count stand for several lines
+	FeatureOffload    = "offload"     // data files copied to object storage, listed in offload.json
+	FeatureEncryption = "encryption"  // data files encrypted at rest (.enc)
+	FeatureChecksums  = "checksums"   // index entries record the sha256 of their file
+	FeatureFieldIndex = "field_index" // data files have a .fidx index of the JSON fields in IndexFields
+	FeatureGrepBloom  = "grep_bloom"  // data files have a .bloom filter of their message tokens
+)
+
+// KnownFeatures lists the features this release understands.
+var KnownFeatures = []string{
+	FeatureShards, FeatureSessions, FeaturePartitions, FeatureDedup,
+	FeatureOffload, FeatureEncryption, FeatureChecksums, FeatureFieldIndex,
+	FeatureGrepBloom,
+}
+
 // Metadata records session-level information for a capture directory.
 type Metadata struct {
-	Version    int            `json:"version"`
-	Format     string         `json:"format"`
-	Started    time.Time      `json:"started"`
-	Stopped    time.Time      `json:"stopped,omitempty"`
-	TotalLines int64          `json:"total_lines"`
-	TotalBytes int64          `json:"total_bytes"`
-	LabelsSeen []string       `json:"labels_seen"`
-	Redaction  *RedactionInfo `json:"redaction,omitempty"`
+	Version     int               `json:"version"`
+	Features    []string          `json:"features,omitempty"` // capture features in use, since version 2
+	Format      string            `json:"format"`
+	Started     time.Time         `json:"started"`
+	Stopped     time.Time         `json:"stopped,omitempty"`
+	TotalLines  int64             `json:"total_lines"`
+	TotalBytes  int64             `json:"total_bytes"`
+	LabelsSeen  []string          `json:"labels_seen"`
+	Precision   string            `json:"timestamp_precision,omitempty"` // finest timestamp unit stored: s, ms, us or ns
+	Redaction   *RedactionInfo    `json:"redaction,omitempty"`
+	ReplayOf    string            `json:"replay_of,omitempty"`       // source capture when written by recv --replay
+	Derived     *DerivedInfo      `json:"derived,omitempty"`         // source capture and pattern when written by grep --out-capture
+	Processors  []string          `json:"processors,omitempty"`      // write path processors, in order
+	Shards      []string          `json:"shards,omitempty"`          // further data directories of a sharded capture
+	Sampling    *SamplingInfo     `json:"sampling,omitempty"`        // ingest sampling rules and counts
+	Session     string            `json:"session,omitempty"`         // session hosted by a multi-session receiver
+	Sessions    []string          `json:"sessions,omitempty"`        // session subdirectories of a multi-session receiver
+	Shard       string            `json:"shard,omitempty"`           // N/M when written by one receiver of recv --shard
+	Expired     *StreamExpiryInfo `json:"expired_streams,omitempty"` // streams forgotten after --stream-idle-ttl
+	Sink        string            `json:"sink,omitempty"`            // object storage URL rotated segments are copied to
+	Clients     []ClientBuild     `json:"clients,omitempty"`         // push client builds seen, by their X-Logtap-Client headers
+	PartitionBy string            `json:"partition_by,omitempty"`    // label whose values have their own file series
+	IndexFields []string          `json:"index_fields,omitempty"`    // JSON message fields indexed per data file
+	GrepBloom   bool              `json:"grep_bloom,omitempty"`      // data files have a bloom filter of message tokens
+	Dedup       *DedupInfo        `json:"dedup,omitempty"`           // repeated lines collapsed by --dedup-window
+	Description string            `json:"description,omitempty"`     // what the capture was recorded for
+	Owner       string            `json:"owner,omitempty"`           // who to ask about the capture
+	Meta        map[string]string `json:"meta,omitempty"`            // free-form key=value context, e.g. test run IDs
+	Recovered   []string          `json:"recovered,omitempty"`       // data files indexed at startup after an unclean shutdown
+	Shutdown    *ShutdownInfo     `json:"shutdown,omitempty"`        // how the write queue was drained when the receiver stopped
+}
+
+// ShutdownInfo records how the lines queued when the receiver stopped were
+// drained within its shutdown deadline.
+type ShutdownInfo struct {
+	Timeout string `json:"timeout,omitempty"` // "" without a drain deadline
+	Flushed int64  `json:"flushed"`           // queued lines written before the deadline
+	Dropped int64  `json:"dropped"`           // queued lines lost at the deadline
+	Clean   bool   `json:"clean"`             // no line was lost
+}
+
+// DerivedInfo records what a capture written from another capture holds.
+type DerivedInfo struct {
+	Source  string `json:"source"`            // capture the lines were read from
+	Pattern string `json:"pattern,omitempty"` // grep pattern the lines matched
+	Context int    `json:"context,omitempty"` // lines kept around each match
+}
+
+// SetCaptureInfo stores info in the metadata.
+func (m *Metadata) SetCaptureInfo(info CaptureInfo) {
+	m.Description = info.Description
+	m.Owner = info.Owner
+	m.Meta = info.Meta
+}
+
+// CaptureInfo returns the capture info stored in the metadata.
+func (m *Metadata) CaptureInfo() CaptureInfo {
+	return CaptureInfo{Description: m.Description, Owner: m.Owner, Meta: m.Meta}
+}
+
+// AddFeature records that the capture uses feature.
+func (m *Metadata) AddFeature(feature string) {
+	if !slices.Contains(m.Features, feature) {
+		m.Features = append(m.Features, feature)
+		slices.Sort(m.Features)
+	}
+}
+
+// UnknownFeatures returns the features of the capture this release does not
+// understand; reading such a capture may miss or misread data.
+func (m *Metadata) UnknownFeatures() []string {
+	var unknown []string
+	for _, f := range m.Features {
+		if !slices.Contains(KnownFeatures, f) {
+			unknown = append(unknown, f)
+		}
+	}
+	return unknown
+}
+
+// ImpliedFeatures returns the features implied by the metadata fields.
+func (m *Metadata) ImpliedFeatures() []string {
+	var features []string
+	if len(m.Shards) > 0 {
+		features = append(features, FeatureShards)
+	}
+	if len(m.Sessions) > 0 {
+		features = append(features, FeatureSessions)
+	}
+	if m.PartitionBy != "" {
+		features = append(features, FeaturePartitions)
+	}
+	if m.Dedup != nil {
+		features = append(features, FeatureDedup)
+	}
+	if m.Sink != "" {
+		features = append(features, FeatureOffload)
+	}
+	if len(m.IndexFields) > 0 {
+		features = append(features, FeatureFieldIndex)
+	}
+	if m.GrepBloom {
+		features = append(features, FeatureGrepBloom)
+	}
+	return features
 }
 
 // RedactionInfo records which redaction patterns were active.
@@ -26,16 +151,25 @@ type RedactionInfo struct {
 	Patterns []string `json:"patterns"`
 }
 
-// WriteMetadata writes metadata.json to the given directory.
+// WriteMetadata writes metadata.json to the given directory. From version 2
+// on, the features implied by the metadata fields are added to Features.
 func WriteMetadata(dir string, meta *Metadata) error {
-	data, err := json.MarshalIndent(meta, "", "  ")
+	out := *meta
+	if out.Version >= 2 {
+		out.Features = slices.Clone(meta.Features)
+		for _, f := range meta.ImpliedFeatures() {
+			out.AddFeature(f)
+		}
+	}
+	data, err := json.MarshalIndent(&out, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshal metadata: %w", err)
 	}
 	return os.WriteFile(filepath.Join(dir, "metadata.json"), data, 0o600)
 }
 
-// ReadMetadata reads metadata.json from the given directory.
+// ReadMetadata reads metadata.json from the given directory. It fails for
+// captures written with a newer schema version than MetadataVersion.
 func ReadMetadata(dir string) (*Metadata, error) {
 	data, err := os.ReadFile(filepath.Join(dir, "metadata.json"))
 	if err != nil {
@@ -45,5 +179,8 @@ func ReadMetadata(dir string) (*Metadata, error) {
 	if err := json.Unmarshal(data, &meta); err != nil {
 		return nil, fmt.Errorf("unmarshal metadata: %w", err)
 	}
+	if meta.Version > MetadataVersion {
+		return nil, fmt.Errorf("metadata version %d is newer than this release supports (%d); upgrade logtap", meta.Version, MetadataVersion)
+	}
 	return &meta, nil
 }
@@ -129,3 +129,67 @@ func TestMetadataStoppedField(t *testing.T) {
 		t.Errorf("stopped: got %v, want %v", got.Stopped, stopped)
 	}
 }
+
+func TestMetadataFeatures(t *testing.T) {
+	dir := t.TempDir()
+	meta := &Metadata{
+		Version:     MetadataVersion,
+		Format:      "jsonl",
+		PartitionBy: "app",
+		Dedup:       &DedupInfo{Window: "1s"},
+	}
+	meta.AddFeature(FeatureChecksums)
+	meta.AddFeature(FeatureChecksums)
+	if err := WriteMetadata(dir, meta); err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Features) != 1 {
+		t.Errorf("WriteMetadata changed its argument: %v", meta.Features)
+	}
+
+	got, err := ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{FeatureChecksums, FeatureDedup, FeaturePartitions}
+	if len(got.Features) != len(want) {
+		t.Fatalf("features = %v, want %v", got.Features, want)
+	}
+	for i := range want {
+		if got.Features[i] != want[i] {
+			t.Errorf("features = %v, want %v", got.Features, want)
+		}
+	}
+	if unknown := got.UnknownFeatures(); len(unknown) != 0 {
+		t.Errorf("unknown = %v", unknown)
+	}
+	got.AddFeature("columnar")
+	if unknown := got.UnknownFeatures(); len(unknown) != 1 || unknown[0] != "columnar" {
+		t.Errorf("unknown = %v", unknown)
+	}
+}
+
+func TestMetadataV1WithoutFeatures(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteMetadata(dir, &Metadata{Version: 1, Format: "jsonl", Dedup: &DedupInfo{}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Features) != 0 {
+		t.Errorf("version 1 metadata got features %v", got.Features)
+	}
+}
+
+func TestReadMetadataNewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte(`{"version": 99, "format": "jsonl"}`)
+	if err := os.WriteFile(filepath.Join(dir, "metadata.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadMetadata(dir); err == nil {
+		t.Fatal("expected error for newer metadata version")
+	}
+}
@@ -1,6 +1,13 @@
 package recv
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+)
 
 // Metrics holds all Prometheus metrics for the receiver pipeline.
 type Metrics struct {
@@ -15,6 +22,24 @@ type Metrics struct {
 	WriterQueueLength  prometheus.Gauge
 	RotationTotal      *prometheus.CounterVec
 	RotationErrors     prometheus.Counter
+	ProcessorDropped   *prometheus.CounterVec
+	ProcessorErrors    *prometheus.CounterVec
+	TimestampFallback  *prometheus.CounterVec
+	LogsSampled        *prometheus.CounterVec
+	PushUnauthorized   *prometheus.CounterVec
+	PushLabelLimited   *prometheus.CounterVec
+	ShardForwarded     *prometheus.CounterVec
+	ShardForwardErrors *prometheus.CounterVec
+	StreamsActive      prometheus.Gauge
+	StreamsExpired     prometheus.Counter
+	TailClients        prometheus.Gauge
+	TailDropped        prometheus.Counter
+	SinkUploads        *prometheus.CounterVec
+	SinkUploadBytes    prometheus.Counter
+	LogsDeduplicated   prometheus.Counter
+	FsyncDuration      prometheus.Histogram
+	FsyncErrors        prometheus.Counter
+	IngestLatency      prometheus.Histogram
 }
 
 // NewMetrics creates and registers all receiver metrics.
@@ -65,6 +90,80 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			Name: "logtap_rotation_errors_total",
 			Help: "Total failed file rotations",
 		}),
+		ProcessorDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logtap_processor_dropped_total",
+			Help: "Total log entries dropped by write path processors",
+		}, []string{"processor"}),
+		ProcessorErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logtap_processor_errors_total",
+			Help: "Total write path processor failures",
+		}, []string{"processor"}),
+		TimestampFallback: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logtap_timestamp_fallback_total",
+			Help: "Total entries whose missing or implausible timestamp was replaced, by source (message, arrival)",
+		}, []string{"source"}),
+		LogsSampled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logtap_logs_sampled_total",
+			Help: "Total log entries not stored by --sample, by rule",
+		}, []string{"rule"}),
+		PushUnauthorized: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logtap_push_unauthorized_total",
+			Help: "Total pushes rejected for a missing or invalid --auth-token, by endpoint",
+		}, []string{"endpoint"}),
+		PushLabelLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logtap_push_label_limited_total",
+			Help: "Total pushes rejected for a stream over --max-labels or --max-label-value-bytes, by endpoint and reason",
+		}, []string{"endpoint", "reason"}),
+		ShardForwarded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logtap_shard_forwarded_total",
+			Help: "Total log entries passed on to the shard owning their stream, by shard",
+		}, []string{"shard"}),
+		ShardForwardErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logtap_shard_forward_errors_total",
+			Help: "Total failed forwards to the shard owning a stream, by shard",
+		}, []string{"shard"}),
+		StreamsActive: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "logtap_streams_active",
+			Help: "Current streams tracked by the receiver",
+		}),
+		StreamsExpired: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logtap_streams_expired_total",
+			Help: "Total streams forgotten after --stream-idle-ttl without entries",
+		}),
+		TailClients: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "logtap_tail_clients",
+			Help: "Current live tail WebSocket clients",
+		}),
+		TailDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logtap_tail_dropped_total",
+			Help: "Total entries not sent to live tail clients that fell behind",
+		}),
+		SinkUploads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logtap_sink_uploads_total",
+			Help: "Total rotated segments copied to the --sink, by result",
+		}, []string{"result"}),
+		SinkUploadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logtap_sink_upload_bytes_total",
+			Help: "Total bytes of rotated segments copied to the --sink",
+		}),
+		LogsDeduplicated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logtap_logs_deduplicated_total",
+			Help: "Total log entries folded into an identical earlier entry by --dedup-window",
+		}),
+		FsyncDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "logtap_fsync_duration_seconds",
+			Help:    "Duration of syncs of written lines to disk with --fsync-interval or --durable",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 4, 8),
+		}),
+		FsyncErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logtap_fsync_errors_total",
+			Help: "Total failed syncs of written lines to disk",
+		}),
+		IngestLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "logtap_ingest_latency_seconds",
+			Help:    "Time from receiving a log entry to writing it, with the trace ID of traced pushes as exemplar",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16),
+		}),
 	}
 	reg.MustRegister(
 		m.LogsReceived,
@@ -78,6 +177,43 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		m.WriterQueueLength,
 		m.RotationTotal,
 		m.RotationErrors,
+		m.ProcessorDropped,
+		m.ProcessorErrors,
+		m.TimestampFallback,
+		m.LogsSampled,
+		m.PushUnauthorized,
+		m.PushLabelLimited,
+		m.ShardForwarded,
+		m.ShardForwardErrors,
+		m.StreamsActive,
+		m.StreamsExpired,
+		m.TailClients,
+		m.TailDropped,
+		m.SinkUploads,
+		m.SinkUploadBytes,
+		m.LogsDeduplicated,
+		m.FsyncDuration,
+		m.FsyncErrors,
+		m.IngestLatency,
 	)
 	return m
 }
+
+// ObserveIngestLatency records the time an entry took from being received
+// to being written. The trace of a sampled push becomes the exemplar.
+func (m *Metrics) ObserveIngestLatency(d time.Duration, sc trace.SpanContext) {
+	if sc.IsSampled() {
+		if eo, ok := m.IngestLatency.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(d.Seconds(), prometheus.Labels{"trace_id": sc.TraceID().String()})
+			return
+		}
+	}
+	m.IngestLatency.Observe(d.Seconds())
+}
+
+// metricsHandler serves the default registry, in the OpenMetrics format
+// when the scraper accepts it so histogram exemplars are exposed.
+func metricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+}
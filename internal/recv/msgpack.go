@@ -0,0 +1,320 @@
+package recv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Minimal MessagePack codec for the Fluentd forward protocol. Decoding
+// yields nil, bool, int64, uint64, float64, string, []byte, []any,
+// map[string]any (non-string keys are formatted), and msgpackExt.
+
+// msgpackMaxLen caps strings, binaries, and container lengths so a corrupt
+// or hostile stream cannot make the receiver allocate unbounded memory.
+const msgpackMaxLen = maxRequestBytes
+
+// msgpackMaxDepth caps container nesting.
+const msgpackMaxDepth = 64
+
+// msgpackExt is an extension value, e.g. a Fluentd EventTime (type 0).
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+type msgpackDecoder struct {
+	r *bufio.Reader
+}
+
+func newMsgpackDecoder(r io.Reader) *msgpackDecoder {
+	if br, ok := r.(*bufio.Reader); ok {
+		return &msgpackDecoder{r: br}
+	}
+	return &msgpackDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads one value. It returns io.EOF only at a value boundary.
+func (d *msgpackDecoder) Decode() (any, error) {
+	return d.decode(0)
+}
+
+func (d *msgpackDecoder) decode(depth int) (any, error) {
+	if depth > msgpackMaxDepth {
+		return nil, fmt.Errorf("msgpack: nesting deeper than %d", msgpackMaxDepth)
+	}
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.readMap(int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.readArray(int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.readString(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLen(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLen(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(n)
+	case 0xca:
+		b, err := d.readBytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := d.readBytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := d.readUint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if v <= math.MaxInt64 {
+			return int64(v), nil
+		}
+		return v, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLen(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(n)
+	case 0xdc, 0xdd:
+		n, err := d.readLen(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.readArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.readLen(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.readMap(n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type byte 0x%02x", c)
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	b, err := d.readBytes(size)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, x := range b {
+		v = v<<8 | uint64(x)
+	}
+	return v, nil
+}
+
+func (d *msgpackDecoder) readLen(size int) (int, error) {
+	v, err := d.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+	if v > msgpackMaxLen {
+		return 0, fmt.Errorf("msgpack: length %d exceeds limit", v)
+	}
+	return int(v), nil
+}
+
+func (d *msgpackDecoder) readBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b, nil
+}
+
+func (d *msgpackDecoder) readString(n int) (string, error) {
+	b, err := d.readBytes(n)
+	return string(b), err
+}
+
+func (d *msgpackDecoder) readExt(n int) (any, error) {
+	t, err := d.r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	b, err := d.readBytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return msgpackExt{Type: int8(t), Data: b}, nil
+}
+
+func (d *msgpackDecoder) readArray(n, depth int) ([]any, error) {
+	out := make([]any, 0, min(n, 1024))
+	for range n {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (d *msgpackDecoder) readMap(n, depth int) (map[string]any, error) {
+	out := make(map[string]any, min(n, 1024))
+	for range n {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		key, ok := msgpackString(k)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// unexpectedEOF turns io.EOF inside a value into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// msgpackString returns v as a string if it is a str or bin value.
+func msgpackString(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case []byte:
+		return string(x), true
+	}
+	return "", false
+}
+
+// appendMsgpack encodes v, which may be nil, bool, int, int64, string,
+// []byte, []any, or map[string]any (keys are written sorted).
+func appendMsgpack(b []byte, v any) []byte {
+	switch x := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if x {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case int:
+		return appendMsgpackInt(b, int64(x))
+	case int64:
+		return appendMsgpackInt(b, x)
+	case string:
+		n := len(x)
+		switch {
+		case n < 32:
+			b = append(b, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			b = append(b, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+		}
+		return append(b, x...)
+	case []byte:
+		n := len(x)
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc4, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+		}
+		return append(b, x...)
+	case []any:
+		b = appendMsgpackHeader(b, len(x), 0x90, 0xdc)
+		for _, item := range x {
+			b = appendMsgpack(b, item)
+		}
+		return b
+	case map[string]any:
+		b = appendMsgpackHeader(b, len(x), 0x80, 0xde)
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b = appendMsgpack(b, k)
+			b = appendMsgpack(b, x[k])
+		}
+		return b
+	}
+	panic(fmt.Sprintf("msgpack: cannot encode %T", v))
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v <= 0x7f:
+		return append(b, byte(v))
+	case v < 0 && v >= -32:
+		return append(b, byte(int8(v)))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+// appendMsgpackHeader writes an array or map header: fix when n < 16,
+// otherwise the 16- or 32-bit form (long16, long16+1).
+func appendMsgpackHeader(b []byte, n int, fix, long16 byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, long16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, long16+1), uint32(n))
+	}
+}
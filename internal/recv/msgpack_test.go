@@ -0,0 +1,80 @@
+package recv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	in := []any{
+		nil, true, false,
+		int64(0), int64(127), int64(-1), int64(-32), int64(-33), int64(1 << 40),
+		"", "short", long,
+		[]byte("bin"),
+		[]any{int64(1), "two"},
+		map[string]any{"a": int64(1), "b": []any{}},
+	}
+	for _, v := range in {
+		got, err := newMsgpackDecoder(bytes.NewReader(appendMsgpack(nil, v))).Decode()
+		if err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("round trip %#v = %#v", v, got)
+		}
+	}
+}
+
+func TestMsgpackDecodeWideTypes(t *testing.T) {
+	var b []byte
+	b = append(b, 0xcc, 0xff)                                              // uint8
+	b = append(b, 0xd1, 0xff, 0x00)                                        // int16 -256
+	b = binary.BigEndian.AppendUint64(append(b, 0xcb), 0x3ff8000000000000) // float64 1.5
+	b = append(b, 0xd7, 0x00, 0, 0, 0, 1, 0, 0, 0, 2)                      // fixext8 type 0
+	b = append(b, 0x81, 0x01, 0xa1, 'v')                                   // {1: "v"}
+
+	d := newMsgpackDecoder(bytes.NewReader(b))
+	want := []any{
+		int64(255),
+		int64(-256),
+		1.5,
+		msgpackExt{Type: 0, Data: []byte{0, 0, 0, 1, 0, 0, 0, 2}},
+		map[string]any{"1": "v"},
+	}
+	for _, w := range want {
+		got, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("got %#v, want %#v", got, w)
+		}
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("err = %v, want EOF", err)
+	}
+}
+
+func TestMsgpackDecodeErrors(t *testing.T) {
+	cases := map[string][]byte{
+		"truncated array": {0x92, 0x01},
+		"truncated str":   {0xa5, 'a'},
+		"huge length":     {0xdb, 0xff, 0xff, 0xff, 0xff},
+		"reserved byte":   {0xc1},
+	}
+	for name, b := range cases {
+		if _, err := newMsgpackDecoder(bytes.NewReader(b)).Decode(); err == nil || err == io.EOF {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+
+	deep := bytes.Repeat([]byte{0x91}, msgpackMaxDepth+2)
+	if _, err := newMsgpackDecoder(bytes.NewReader(deep)).Decode(); err == nil {
+		t.Error("expected nesting error")
+	}
+}
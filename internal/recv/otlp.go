@@ -0,0 +1,619 @@
+package recv
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// OTLP logs ingest. Requests are ExportLogsServiceRequest messages from
+// opentelemetry-proto (collector/logs/v1), accepted over OTLP/HTTP with
+// protobuf encoding on POST /v1/logs and over OTLP/gRPC. Messages are decoded
+// with protowire against the stable field numbers, so the generated OTLP
+// packages are not needed.
+//
+// Mapping onto capture entries:
+//   - resource attributes become labels; well-known Kubernetes and service
+//     attributes map onto the labels taps use (app, namespace, pod, ...),
+//     other dotted keys have non-alphanumerics replaced with "_"
+//   - severity becomes the level label; ERROR and FATAL records whose body
+//     does not already read as an error get the severity text prefixed, so
+//     keyword-based classification (triage, stats, alerts) counts them
+//   - the body becomes the message; structured bodies are encoded as JSON
+//   - time_unix_nano is the timestamp, falling back to the observed time
+
+const (
+	otlpLogsPath        = "/v1/logs"
+	otlpLogsGRPCService = "opentelemetry.proto.collector.logs.v1.LogsService"
+	otlpLogsGRPCMethod  = "/" + otlpLogsGRPCService + "/Export"
+)
+
+// otlpLabelNames maps resource attribute keys onto capture label names.
+var otlpLabelNames = map[string]string{
+	"service.name":       "app",
+	"k8s.namespace.name": "namespace",
+	"k8s.pod.name":       "pod",
+	"k8s.container.name": "container",
+	"k8s.node.name":      "node",
+}
+
+// OTLP severity number ranges (logs/v1 SeverityNumber).
+const (
+	otlpSeverityDebug = 5
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+	otlpSeverityFatal = 21
+)
+
+// ParseOTLPLogs decodes a protobuf ExportLogsServiceRequest into entries.
+func ParseOTLPLogs(data []byte) ([]LogEntry, error) {
+	var entries []LogEntry
+	err := protoFields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != 1 || typ != protowire.BytesType { // resource_logs
+			return nil
+		}
+		return parseResourceLogs(v, &entries)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decode OTLP logs: %w", err)
+	}
+	return entries, nil
+}
+
+func parseResourceLogs(data []byte, entries *[]LogEntry) error {
+	labels := map[string]string{}
+	var scopes [][]byte
+	err := protoFields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1: // resource
+			return protoFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num != 1 || typ != protowire.BytesType { // attributes
+					return nil
+				}
+				key, val, err := parseKeyValue(v, 0)
+				if err != nil {
+					return err
+				}
+				if s, ok := scalarString(val); ok && s != "" {
+					labels[otlpLabelName(key)] = s
+				}
+				return nil
+			})
+		case 2: // scope_logs
+			scopes = append(scopes, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range scopes {
+		err := protoFields(scope, func(num protowire.Number, typ protowire.Type, v []byte) error {
+			if num != 2 || typ != protowire.BytesType { // log_records
+				return nil
+			}
+			entry, err := parseLogRecord(v)
+			if err != nil {
+				return err
+			}
+			entry.Labels = otlpEntryLabels(labels, entry.Labels["level"])
+			*entries = append(*entries, entry)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseLogRecord(data []byte) (LogEntry, error) {
+	var (
+		ts, observed uint64
+		sevNum       int
+		sevText      string
+		body         any
+		hasBody      bool
+	)
+	err := protoFields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			ts = protoFixed64(v)
+		case num == 11 && typ == protowire.Fixed64Type:
+			observed = protoFixed64(v)
+		case num == 2 && typ == protowire.VarintType:
+			sevNum = int(protoVarint(v))
+		case num == 3 && typ == protowire.BytesType:
+			sevText = string(v)
+		case num == 5 && typ == protowire.BytesType:
+			val, err := parseAnyValue(v, 0)
+			if err != nil {
+				return err
+			}
+			body, hasBody = val, true
+		}
+		return nil
+	})
+	if err != nil {
+		return LogEntry{}, err
+	}
+
+	entry := LogEntry{Timestamp: otlpTime(ts, observed)}
+	if hasBody {
+		if s, ok := body.(string); ok {
+			entry.Message = s
+		} else if b, err := json.Marshal(body); err == nil {
+			entry.Message = string(b)
+		}
+	}
+
+	level := otlpLevel(sevNum, sevText)
+	if (level == "error" || level == "fatal") && !otlpLooksLikeError(entry.Message) {
+		prefix := strings.ToUpper(level)
+		if sevText != "" {
+			prefix = sevText
+		}
+		entry.Message = prefix + " " + entry.Message
+	}
+	if level != "" {
+		entry.Labels = map[string]string{"level": level}
+	}
+	return entry, nil
+}
+
+// otlpEntryLabels returns a copy of the resource labels with level added.
+func otlpEntryLabels(resource map[string]string, level string) map[string]string {
+	out := make(map[string]string, len(resource)+1)
+	for k, v := range resource {
+		out[k] = v
+	}
+	if level != "" {
+		out["level"] = level
+	}
+	return out
+}
+
+func otlpTime(ts, observed uint64) time.Time {
+	switch {
+	case ts > 0 && ts <= math.MaxInt64:
+		return time.Unix(0, int64(ts)).UTC()
+	case observed > 0 && observed <= math.MaxInt64:
+		return time.Unix(0, int64(observed)).UTC()
+	}
+	return time.Now()
+}
+
+// otlpLevel derives a lowercase level from the severity number, or from the
+// severity text when the number is unset.
+func otlpLevel(num int, text string) string {
+	switch {
+	case num >= otlpSeverityFatal:
+		return "fatal"
+	case num >= otlpSeverityError:
+		return "error"
+	case num >= otlpSeverityWarn:
+		return "warn"
+	case num >= otlpSeverityInfo:
+		return "info"
+	case num >= otlpSeverityDebug:
+		return "debug"
+	case num > 0:
+		return "trace"
+	}
+	t := strings.ToLower(strings.TrimSpace(text))
+	switch {
+	case t == "":
+		return ""
+	case strings.HasPrefix(t, "fatal"), strings.HasPrefix(t, "crit"), strings.HasPrefix(t, "emerg"), strings.HasPrefix(t, "alert"):
+		return "fatal"
+	case strings.HasPrefix(t, "err"):
+		return "error"
+	case strings.HasPrefix(t, "warn"):
+		return "warn"
+	case strings.HasPrefix(t, "info"), t == "notice":
+		return "info"
+	case strings.HasPrefix(t, "debug"):
+		return "debug"
+	case strings.HasPrefix(t, "trace"):
+		return "trace"
+	}
+	return t
+}
+
+// otlpLooksLikeError reports whether msg already starts with an error or
+// fatal marker, so the severity is not prefixed twice.
+func otlpLooksLikeError(msg string) bool {
+	lower := strings.ToLower(strings.TrimSpace(msg))
+	return strings.HasPrefix(lower, "error") || strings.HasPrefix(lower, "fatal") ||
+		strings.HasPrefix(lower, "panic")
+}
+
+// otlpLabelName maps a resource attribute key onto a label name.
+func otlpLabelName(key string) string {
+	if name, ok := otlpLabelNames[key]; ok {
+		return name
+	}
+	return labelName(key)
+}
+
+// labelName replaces characters that are not valid in a label name
+// (letters, digits, underscore) with "_".
+func labelName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// parseKeyValue decodes a KeyValue whose value sits depth levels deep.
+func parseKeyValue(data []byte, depth int) (string, any, error) {
+	var key string
+	var val any
+	err := protoFields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			key = string(v)
+		case 2:
+			var err error
+			val, err = parseAnyValue(v, depth)
+			return err
+		}
+		return nil
+	})
+	return key, val, err
+}
+
+// maxAnyValueDepth caps the nesting of OTLP array and kvlist values, so a
+// crafted payload cannot exhaust the stack.
+const maxAnyValueDepth = 32
+
+// parseAnyValue decodes an AnyValue, nested depth levels inside arrays and
+// kvlists, into string, bool, int64, float64, []any, map[string]any, or a
+// base64 string for bytes.
+func parseAnyValue(data []byte, depth int) (any, error) {
+	if depth > maxAnyValueDepth {
+		return nil, fmt.Errorf("value nested deeper than %d levels", maxAnyValueDepth)
+	}
+	var val any
+	err := protoFields(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			val = string(v)
+		case num == 2 && typ == protowire.VarintType:
+			val = protoVarint(v) != 0
+		case num == 3 && typ == protowire.VarintType:
+			val = int64(protoVarint(v))
+		case num == 4 && typ == protowire.Fixed64Type:
+			val = math.Float64frombits(protoFixed64(v))
+		case num == 5 && typ == protowire.BytesType: // array_value
+			arr := []any{}
+			err := protoFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num != 1 || typ != protowire.BytesType {
+					return nil
+				}
+				item, err := parseAnyValue(v, depth+1)
+				arr = append(arr, item)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			val = arr
+		case num == 6 && typ == protowire.BytesType: // kvlist_value
+			obj := map[string]any{}
+			err := protoFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num != 1 || typ != protowire.BytesType {
+					return nil
+				}
+				k, item, err := parseKeyValue(v, depth+1)
+				obj[k] = item
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			val = obj
+		case num == 7 && typ == protowire.BytesType:
+			val = base64.StdEncoding.EncodeToString(v)
+		}
+		return nil
+	})
+	return val, err
+}
+
+// scalarString formats scalar attribute values; structured values are
+// not usable as labels.
+func scalarString(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case bool:
+		return strconv.FormatBool(x), true
+	case int64:
+		return strconv.FormatInt(x, 10), true
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+// protoFields calls fn for each field of a protobuf message. For varint and
+// fixed fields v holds the raw encoded value; for bytes fields, the payload.
+func protoFields(data []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		m := protowire.ConsumeFieldValue(num, typ, data)
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		v := data[:m]
+		if typ == protowire.BytesType {
+			b, _ := protowire.ConsumeBytes(v)
+			v = b
+		}
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+		data = data[m:]
+	}
+	return nil
+}
+
+func protoFixed64(v []byte) uint64 {
+	x, _ := protowire.ConsumeFixed64(v)
+	return x
+}
+
+func protoVarint(v []byte) uint64 {
+	x, _ := protowire.ConsumeVarint(v)
+	return x
+}
+
+// ingestOTLP runs decoded entries through the pipeline and audits the push
+// as coming from clientIP over proto (empty for gRPC).
+func (s *Server) ingestOTLP(ctx context.Context, entries []LogEntry, clientIP, proto string, start time.Time) {
+	s.ingestBatch(ctx, entries, start)
+	var byteCount int
+	for i := range entries {
+		byteCount += len(entries[i].Message)
+	}
+	s.audit.Log(AuditEntry{
+		Event:    "otlp_push_received",
+		RemoteIP: clientIP,
+		Proto:    proto,
+		Lines:    len(entries),
+		Bytes:    byteCount,
+		Duration: time.Since(start),
+	})
+}
+
+func (s *Server) handleOTLPLogs(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	s.trackConnOpen()
+	defer s.trackConnClose()
+	defer func() {
+		if s.metrics != nil {
+			s.metrics.PushDuration.Observe(time.Since(start).Seconds())
+		}
+	}()
+
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/x-protobuf") {
+		http.Error(w, fmt.Sprintf("unsupported content type %q (only application/x-protobuf)", ct), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ctx, span := s.startPush(r.Context(), r.Header, "otlp push")
+	defer span.End()
+
+	entries, code, err := s.decodeOTLPLogs(ctx, w, r)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if err := s.checkLabelLimits("otlp", func(i int) map[string]string { return entries[i].Labels }, len(entries)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if session := r.Header.Get(SessionHeader); session != "" {
+		for i := range entries {
+			entries[i].Labels = withSession(entries[i].Labels, session)
+		}
+	}
+	if err := checkSession(ctx, func(i int) map[string]string { return entries[i].Labels }, len(entries)); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	s.ingestOTLP(ctx, entries, s.trusted.ClientIP(r), s.trusted.Proto(r), start)
+
+	// empty ExportLogsServiceResponse: full success
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+// decodeOTLPLogs reads and parses an OTLP/HTTP logs request, returning the
+// status code to answer with on error.
+func (s *Server) decodeOTLPLogs(ctx context.Context, w http.ResponseWriter, r *http.Request) (entries []LogEntry, code int, err error) {
+	_, span := s.tracer.Start(ctx, "decode")
+	defer func() { endSpan(span, err) }()
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		body = io.LimitReader(gz, maxRequestBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("read body: %w", err)
+	}
+	if len(data) > maxRequestBytes {
+		return nil, http.StatusRequestEntityTooLarge, errors.New("request body too large")
+	}
+	entries, err = ParseOTLPLogs(data)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return entries, http.StatusOK, nil
+}
+
+// rawCodec passes gRPC messages through as bytes so the OTLP service needs
+// no generated message types.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("rawCodec: unexpected type %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("rawCodec: unexpected type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// otlpServiceDesc describes the OTLP LogsService with its single Export
+// method.
+var otlpServiceDesc = grpc.ServiceDesc{
+	ServiceName: otlpLogsGRPCService,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Export",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			var req []byte
+			if err := dec(&req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return srv.(*Server).exportOTLP(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: otlpLogsGRPCMethod}
+			return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				return srv.(*Server).exportOTLP(ctx, req.([]byte))
+			})
+		},
+	}},
+	Streams: []grpc.StreamDesc{},
+}
+
+func (s *Server) exportOTLP(ctx context.Context, req []byte) (*[]byte, error) {
+	start := time.Now()
+	s.trackConnOpen()
+	defer s.trackConnClose()
+	defer func() {
+		if s.metrics != nil {
+			s.metrics.PushDuration.Observe(time.Since(start).Seconds())
+		}
+	}()
+
+	ctx, span := s.startPush(ctx, nil, "otlp push")
+	defer span.End()
+
+	_, dspan := s.tracer.Start(ctx, "decode")
+	entries, err := ParseOTLPLogs(req)
+	endSpan(dspan, err)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.checkLabelLimits("otlp_grpc", func(i int) map[string]string { return entries[i].Labels }, len(entries)); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if session := boundSession(ctx); session != "" {
+		for i := range entries {
+			entries[i].Labels = withSession(entries[i].Labels, session)
+		}
+	}
+	if err := checkSession(ctx, func(i int) map[string]string { return entries[i].Labels }, len(entries)); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = p.Addr.String()
+	}
+	s.ingestOTLP(ctx, entries, stripPort(remote), "", start)
+
+	resp := []byte{} // empty ExportLogsServiceResponse
+	return &resp, nil
+}
+
+// ServeOTLPGRPC serves the OTLP/gRPC logs service and the logtap push
+// stream on ln until Shutdown. opts are passed to grpc.NewServer, e.g. TLS
+// credentials.
+func (s *Server) ServeOTLPGRPC(ln net.Listener, opts ...grpc.ServerOption) error {
+	opts = append(opts, grpc.ForceServerCodec(rawCodec{}), grpc.MaxRecvMsgSize(maxRequestBytes),
+		grpc.ChainUnaryInterceptor(s.grpcUnaryAuth), grpc.ChainStreamInterceptor(s.grpcStreamAuth))
+	gs := grpc.NewServer(opts...)
+	gs.RegisterService(&otlpServiceDesc, s)
+	gs.RegisterService(&pushStreamDesc, s)
+
+	s.grpcMu.Lock()
+	s.grpcSrv = gs
+	s.grpcMu.Unlock()
+
+	err := gs.Serve(ln)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// stopGRPC stops the OTLP/gRPC server, waiting for in-flight exports until
+// ctx is done.
+func (s *Server) stopGRPC(ctx context.Context) {
+	s.grpcMu.Lock()
+	gs := s.grpcSrv
+	s.grpcMu.Unlock()
+	if gs == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		gs.Stop()
+	}
+}
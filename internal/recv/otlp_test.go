@@ -0,0 +1,273 @@
+package recv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// OTLP message builders, by field number.
+
+func pbBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func pbString(s string) []byte { return pbBytes(nil, 1, []byte(s)) } // AnyValue.string_value
+
+func pbKV(key string, value []byte) []byte {
+	b := pbBytes(nil, 1, []byte(key))
+	return pbBytes(b, 2, value)
+}
+
+func pbLogRecord(ts uint64, sevNum int, sevText string, body []byte) []byte {
+	var b []byte
+	if ts > 0 {
+		b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, ts)
+	}
+	if sevNum > 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(sevNum))
+	}
+	if sevText != "" {
+		b = pbBytes(b, 3, []byte(sevText))
+	}
+	if body != nil {
+		b = pbBytes(b, 5, body)
+	}
+	return b
+}
+
+func pbRequest(attrs [][]byte, records ...[]byte) []byte {
+	var resource []byte
+	for _, a := range attrs {
+		resource = pbBytes(resource, 1, a)
+	}
+	var scope []byte
+	for _, r := range records {
+		scope = pbBytes(scope, 2, r)
+	}
+	rl := pbBytes(nil, 1, resource)
+	rl = pbBytes(rl, 2, scope)
+	return pbBytes(nil, 1, rl)
+}
+
+func sampleOTLPRequest() []byte {
+	ts := uint64(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC).UnixNano())
+	attrs := [][]byte{
+		pbKV("service.name", pbString("checkout")),
+		pbKV("k8s.namespace.name", pbString("shop")),
+		pbKV("deployment.environment", pbString("load")),
+	}
+	return pbRequest(attrs,
+		pbLogRecord(ts, 9, "INFO", pbString("order completed")),
+		pbLogRecord(ts+1, 17, "ERROR", pbString("payment declined")),
+	)
+}
+
+func TestParseOTLPLogs(t *testing.T) {
+	entries, err := ParseOTLPLogs(sampleOTLPRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	info := entries[0]
+	if info.Message != "order completed" {
+		t.Errorf("msg = %q", info.Message)
+	}
+	want := map[string]string{"app": "checkout", "namespace": "shop", "deployment_environment": "load", "level": "info"}
+	for k, v := range want {
+		if info.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q (labels %v)", k, info.Labels[k], v, info.Labels)
+		}
+	}
+	if !info.Timestamp.Equal(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("ts = %v", info.Timestamp)
+	}
+
+	errEntry := entries[1]
+	if errEntry.Message != "ERROR payment declined" {
+		t.Errorf("error msg = %q, want severity prefixed", errEntry.Message)
+	}
+	if errEntry.Labels["level"] != "error" {
+		t.Errorf("level = %q", errEntry.Labels["level"])
+	}
+}
+
+func TestParseOTLPLogsBodies(t *testing.T) {
+	var kvlist []byte
+	kvlist = pbBytes(kvlist, 1, pbKV("user", pbString("alice")))
+	intVal := protowire.AppendVarint(protowire.AppendTag(nil, 3, protowire.VarintType), 42)
+	kvlist = pbBytes(kvlist, 1, pbKV("attempt", intVal))
+	structured := pbBytes(nil, 6, kvlist)
+
+	dbl := protowire.AppendTag(nil, 4, protowire.Fixed64Type)
+	dbl = protowire.AppendFixed64(dbl, math.Float64bits(1.5))
+
+	observed := pbLogRecord(0, 0, "", pbString("no ts"))
+	observed = protowire.AppendTag(observed, 11, protowire.Fixed64Type)
+	observed = protowire.AppendFixed64(observed, uint64(time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC).UnixNano()))
+
+	data := pbRequest(nil,
+		pbLogRecord(1, 0, "", structured),
+		pbLogRecord(1, 0, "", dbl),
+		pbLogRecord(1, 21, "", pbString("error: disk full")),
+		pbLogRecord(1, 0, "warning", pbString("slow")),
+		observed,
+	)
+	entries, err := ParseOTLPLogs(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+	if entries[0].Message != `{"attempt":42,"user":"alice"}` {
+		t.Errorf("kvlist body = %q", entries[0].Message)
+	}
+	if entries[1].Message != "1.5" {
+		t.Errorf("double body = %q", entries[1].Message)
+	}
+	if entries[2].Message != "error: disk full" || entries[2].Labels["level"] != "fatal" {
+		t.Errorf("fatal entry = %q %v, want no prefix", entries[2].Message, entries[2].Labels)
+	}
+	if entries[3].Labels["level"] != "warn" {
+		t.Errorf("severity text level = %q", entries[3].Labels["level"])
+	}
+	if !entries[4].Timestamp.Equal(time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("observed ts = %v", entries[4].Timestamp)
+	}
+}
+
+func TestParseOTLPLogsInvalid(t *testing.T) {
+	if _, err := ParseOTLPLogs([]byte{0x0a, 0xff}); err == nil {
+		t.Error("expected error for truncated message")
+	}
+}
+
+func TestParseOTLPLogsNestingLimit(t *testing.T) {
+	// nested wraps a string body in depth single-item arrays
+	nested := func(depth int) []byte {
+		v := pbString("deep")
+		for range depth {
+			v = pbBytes(nil, 5, pbBytes(nil, 1, v))
+		}
+		return v
+	}
+	if _, err := ParseOTLPLogs(pbRequest(nil, pbLogRecord(1, 0, "", nested(maxAnyValueDepth)))); err != nil {
+		t.Errorf("body nested %d levels: %v", maxAnyValueDepth, err)
+	}
+	if _, err := ParseOTLPLogs(pbRequest(nil, pbLogRecord(1, 0, "", nested(maxAnyValueDepth+1)))); err == nil {
+		t.Error("expected error for a body nested past the limit")
+	}
+}
+
+func TestOTLPHTTP(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	ts := httptest.NewServer(srv.httpSrv.Handler)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/v1/logs", "application/x-protobuf", bytes.NewReader(sampleOTLPRequest()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(sampleOTLPRequest())
+	_ = zw.Close()
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/logs", &gz)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("gzip status = %d, want 200", resp.StatusCode)
+	}
+
+	if got := len(ring.Snapshot()); got != 4 {
+		t.Errorf("ingested %d entries, want 4", got)
+	}
+
+	resp, err = http.Post(ts.URL+"/v1/logs", "application/json", bytes.NewReader([]byte("{}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("json status = %d, want 415", resp.StatusCode)
+	}
+}
+
+func TestOTLPGRPC(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ServeOTLPGRPC(ln) }()
+
+	conn, err := grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	method := otlpLogsGRPCMethod
+	req, resp := sampleOTLPRequest(), []byte{}
+	if err := conn.Invoke(ctx, method, &req, &resp); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if got := len(ring.Snapshot()); got != 2 {
+		t.Errorf("ingested %d entries, want 2", got)
+	}
+
+	bad := []byte{0x0a, 0xff}
+	err = conn.Invoke(ctx, method, &bad, &resp)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("invalid request err = %v, want InvalidArgument", err)
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("ServeOTLPGRPC after shutdown: %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package recv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// OTLP trace export. Spans are sent to POST /v1/traces as protobuf
+// ExportTraceServiceRequest messages from opentelemetry-proto
+// (collector/trace/v1), encoded with protowire against the stable field
+// numbers like the logs ingest decodes them, so the OTLP exporter modules
+// are not needed.
+
+const otlpTracesPath = "/v1/traces"
+
+// OTLPTraceExporter sends spans to an OTLP/HTTP endpoint.
+type OTLPTraceExporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewOTLPTraceExporter returns an exporter for endpoint, an http or https
+// URL. /v1/traces is appended when the URL has no path.
+func NewOTLPTraceExporter(endpoint string) (*OTLPTraceExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid trace endpoint %q: want http(s)://host[:port][/path]", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpTracesPath
+	}
+	return &OTLPTraceExporter{url: u.String(), client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// ExportSpans implements sdktrace.SpanExporter.
+func (e *OTLPTraceExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(encodeOTLPTraces(spans)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export spans: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("export spans: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter.
+func (e *OTLPTraceExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// encodeOTLPTraces encodes spans as an ExportTraceServiceRequest, one
+// ResourceSpans per resource and one ScopeSpans per instrumentation scope.
+func encodeOTLPTraces(spans []sdktrace.ReadOnlySpan) []byte {
+	type scopeKey struct{ name, version string }
+	type resourceGroup struct {
+		attrs  []attribute.KeyValue
+		order  []scopeKey
+		scopes map[scopeKey][][]byte
+	}
+	var order []attribute.Distinct
+	groups := make(map[attribute.Distinct]*resourceGroup)
+	for _, s := range spans {
+		var attrs []attribute.KeyValue
+		var key attribute.Distinct
+		if res := s.Resource(); res != nil {
+			attrs = res.Attributes()
+			key = res.Equivalent()
+		}
+		g := groups[key]
+		if g == nil {
+			g = &resourceGroup{attrs: attrs, scopes: make(map[scopeKey][][]byte)}
+			groups[key] = g
+			order = append(order, key)
+		}
+		scope := s.InstrumentationScope()
+		sk := scopeKey{scope.Name, scope.Version}
+		if _, ok := g.scopes[sk]; !ok {
+			g.order = append(g.order, sk)
+		}
+		g.scopes[sk] = append(g.scopes[sk], encodeSpan(s))
+	}
+
+	var req []byte
+	for _, key := range order {
+		g := groups[key]
+		var rs, res []byte
+		for _, kv := range g.attrs {
+			res = appendMessage(res, 1, encodeKeyValue(kv)) // attributes
+		}
+		rs = appendMessage(rs, 1, res) // resource
+		for _, sk := range g.order {
+			var ss, scope []byte
+			scope = appendString(scope, 1, sk.name)
+			scope = appendString(scope, 2, sk.version)
+			ss = appendMessage(ss, 1, scope)
+			for _, span := range g.scopes[sk] {
+				ss = appendMessage(ss, 2, span)
+			}
+			rs = appendMessage(rs, 2, ss) // scope_spans
+		}
+		req = appendMessage(req, 1, rs) // resource_spans
+	}
+	return req
+}
+
+// OTLP span flags: the parent is known to be local or remote.
+const (
+	otlpSpanFlagsHasIsRemote = 0x100
+	otlpSpanFlagsIsRemote    = 0x200
+)
+
+func encodeSpan(s sdktrace.ReadOnlySpan) []byte {
+	sc := s.SpanContext()
+	tid, sid := sc.TraceID(), sc.SpanID()
+	var b []byte
+	b = appendBytes(b, 1, tid[:])
+	b = appendBytes(b, 2, sid[:])
+	b = appendString(b, 3, sc.TraceState().String())
+	if p := s.Parent(); p.IsValid() {
+		psid := p.SpanID()
+		b = appendBytes(b, 4, psid[:])
+	}
+	b = appendString(b, 5, s.Name())
+	b = appendVarint(b, 6, uint64(s.SpanKind()))
+	b = appendFixed64(b, 7, uint64(s.StartTime().UnixNano()))
+	b = appendFixed64(b, 8, uint64(s.EndTime().UnixNano()))
+	for _, kv := range s.Attributes() {
+		b = appendMessage(b, 9, encodeKeyValue(kv))
+	}
+	b = appendVarint(b, 10, uint64(s.DroppedAttributes()))
+	for _, ev := range s.Events() {
+		var e []byte
+		e = appendFixed64(e, 1, uint64(ev.Time.UnixNano()))
+		e = appendString(e, 2, ev.Name)
+		for _, kv := range ev.Attributes {
+			e = appendMessage(e, 3, encodeKeyValue(kv))
+		}
+		e = appendVarint(e, 4, uint64(ev.DroppedAttributeCount))
+		b = appendMessage(b, 11, e)
+	}
+	b = appendVarint(b, 12, uint64(s.DroppedEvents()))
+	for _, l := range s.Links() {
+		ltid, lsid := l.SpanContext.TraceID(), l.SpanContext.SpanID()
+		var lb []byte
+		lb = appendBytes(lb, 1, ltid[:])
+		lb = appendBytes(lb, 2, lsid[:])
+		lb = appendString(lb, 3, l.SpanContext.TraceState().String())
+		for _, kv := range l.Attributes {
+			lb = appendMessage(lb, 4, encodeKeyValue(kv))
+		}
+		lb = appendVarint(lb, 5, uint64(l.DroppedAttributeCount))
+		b = appendMessage(b, 13, lb)
+	}
+	b = appendVarint(b, 14, uint64(s.DroppedLinks()))
+
+	var st []byte
+	status := s.Status()
+	st = appendString(st, 2, status.Description)
+	switch status.Code {
+	case codes.Ok:
+		st = appendVarint(st, 3, 1)
+	case codes.Error:
+		st = appendVarint(st, 3, 2)
+	}
+	b = appendMessage(b, 15, st)
+
+	flags := uint32(sc.TraceFlags()) | otlpSpanFlagsHasIsRemote
+	if s.Parent().IsRemote() {
+		flags |= otlpSpanFlagsIsRemote
+	}
+	b = protowire.AppendTag(b, 16, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, flags)
+	return b
+}
+
+// encodeKeyValue encodes an attribute as an OTLP KeyValue.
+func encodeKeyValue(kv attribute.KeyValue) []byte {
+	var b []byte
+	b = appendString(b, 1, string(kv.Key))
+	return appendMessage(b, 2, encodeAnyValue(kv.Value))
+}
+
+func encodeAnyValue(v attribute.Value) []byte {
+	var b []byte
+	switch v.Type() {
+	case attribute.BOOL:
+		var x uint64
+		if v.AsBool() {
+			x = 1
+		}
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, x)
+	case attribute.INT64:
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(v.AsInt64()))
+	case attribute.FLOAT64:
+		b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(v.AsFloat64()))
+	case attribute.BOOLSLICE:
+		b = encodeArray(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		b = encodeArray(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		b = encodeArray(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		b = encodeArray(v.AsStringSlice(), attribute.StringValue)
+	default:
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, v.Emit())
+	}
+	return b
+}
+
+// encodeArray encodes values as the array_value of an AnyValue.
+func encodeArray[T any](values []T, value func(T) attribute.Value) []byte {
+	var arr []byte
+	for _, x := range values {
+		arr = appendMessage(arr, 1, encodeAnyValue(value(x)))
+	}
+	return appendMessage(nil, 5, arr)
+}
+
+// appendMessage appends an embedded message field, even when empty.
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// appendBytes, appendString, appendVarint and appendFixed64 append a field
+// unless it holds the default value, as proto3 encoders do.
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendMessage(b, num, v)
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
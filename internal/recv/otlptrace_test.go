@@ -0,0 +1,136 @@
+package recv
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestNewOTLPTraceExporter(t *testing.T) {
+	tests := []struct {
+		endpoint, want string
+	}{
+		{"http://collector:4318", "http://collector:4318/v1/traces"},
+		{"https://collector:4318/", "https://collector:4318/v1/traces"},
+		{"http://gateway/otlp/v1/traces", "http://gateway/otlp/v1/traces"},
+	}
+	for _, tt := range tests {
+		e, err := NewOTLPTraceExporter(tt.endpoint)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.endpoint, err)
+		}
+		if e.url != tt.want {
+			t.Errorf("%s: url = %s, want %s", tt.endpoint, e.url, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "collector:4318", "grpc://collector:4317", "http://"} {
+		if _, err := NewOTLPTraceExporter(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestOTLPTraceExport(t *testing.T) {
+	var body []byte
+	var contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != otlpTracesPath {
+			http.NotFound(w, r)
+			return
+		}
+		contentType = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	ctx, parent := tp.Tracer(tracerName).Start(context.Background(), "raw push")
+	_, child := tp.Tracer(tracerName).Start(ctx, "decode")
+	child.SetAttributes(attribute.Int("logtap.entries", 3), attribute.StringSlice("tags", []string{"a", "b"}))
+	child.End()
+	parent.End()
+
+	exp, err := NewOTLPTraceExporter(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportSpans(context.Background(), rec.Ended()); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/x-protobuf" {
+		t.Errorf("content type = %q", contentType)
+	}
+
+	// resource_spans → scope_spans → spans
+	type span struct{ name, traceID, parentID string }
+	var spans []span
+	var scopes int
+	err = protoFields(body, func(num protowire.Number, _ protowire.Type, rs []byte) error {
+		return protoFields(rs, func(num protowire.Number, _ protowire.Type, ss []byte) error {
+			if num != 2 {
+				return nil
+			}
+			scopes++
+			return protoFields(ss, func(num protowire.Number, _ protowire.Type, v []byte) error {
+				if num != 2 {
+					return nil
+				}
+				var s span
+				err := protoFields(v, func(num protowire.Number, _ protowire.Type, f []byte) error {
+					switch num {
+					case 1:
+						s.traceID = hex.EncodeToString(f)
+					case 4:
+						s.parentID = hex.EncodeToString(f)
+					case 5:
+						s.name = string(f)
+					}
+					return nil
+				})
+				spans = append(spans, s)
+				return err
+			})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scopes != 1 || len(spans) != 2 {
+		t.Fatalf("scopes = %d, spans = %+v", scopes, spans)
+	}
+	if spans[0].name != "decode" || spans[1].name != "raw push" {
+		t.Errorf("span names = %s, %s", spans[0].name, spans[1].name)
+	}
+	if spans[0].traceID != parent.SpanContext().TraceID().String() || spans[0].parentID != parent.SpanContext().SpanID().String() {
+		t.Errorf("decode span = %+v", spans[0])
+	}
+	if spans[1].parentID != "" {
+		t.Errorf("root span has parent %s", spans[1].parentID)
+	}
+}
+
+func TestOTLPTraceExportError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	_, span := tp.Tracer(tracerName).Start(context.Background(), "raw push")
+	span.End()
+
+	exp, _ := NewOTLPTraceExporter(ts.URL)
+	if err := exp.ExportSpans(context.Background(), rec.Ended()); err == nil {
+		t.Fatal("expected error for 503")
+	}
+}
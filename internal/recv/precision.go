@@ -0,0 +1,81 @@
+package recv
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Timestamp precisions recorded in metadata.json: the finest unit any
+// stored timestamp needed.
+const (
+	PrecisionSecond = "s"
+	PrecisionMilli  = "ms"
+	PrecisionMicro  = "us"
+	PrecisionNano   = "ns"
+)
+
+var precisionNames = map[int32]string{
+	1:  PrecisionSecond,
+	4:  PrecisionMilli,
+	7:  PrecisionMicro,
+	10: PrecisionNano,
+}
+
+// PrecisionDigits returns the fractional second digits of a precision, or
+// 3 for an unknown one (captures written before it was recorded).
+func PrecisionDigits(precision string) int {
+	switch precision {
+	case PrecisionSecond:
+		return 0
+	case PrecisionMicro:
+		return 6
+	case PrecisionNano:
+		return 9
+	default:
+		return 3
+	}
+}
+
+// FinerPrecision returns the finer of two precisions; "" is unknown and
+// loses to any other.
+func FinerPrecision(a, b string) string {
+	if a == "" || (b != "" && PrecisionDigits(b) > PrecisionDigits(a)) {
+		return b
+	}
+	return a
+}
+
+// timestampDigits returns one more than the fractional second digits t
+// needs, so that 0 means nothing recorded.
+func timestampDigits(t time.Time) int32 {
+	switch ns := t.Nanosecond(); {
+	case ns == 0:
+		return 1
+	case ns%int(time.Millisecond) == 0:
+		return 4
+	case ns%int(time.Microsecond) == 0:
+		return 7
+	default:
+		return 10
+	}
+}
+
+// precisionTracker records the finest timestamp precision written.
+type precisionTracker struct {
+	digits atomic.Int32
+}
+
+func (p *precisionTracker) record(t time.Time) {
+	d := timestampDigits(t)
+	for {
+		cur := p.digits.Load()
+		if d <= cur || p.digits.CompareAndSwap(cur, d) {
+			return
+		}
+	}
+}
+
+// String returns the precision recorded, or "" before the first entry.
+func (p *precisionTracker) String() string {
+	return precisionNames[p.digits.Load()]
+}
@@ -0,0 +1,65 @@
+package recv
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrecisionTracker(t *testing.T) {
+	var p precisionTracker
+	if got := p.String(); got != "" {
+		t.Errorf("empty tracker = %q, want \"\"", got)
+	}
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		ts   time.Time
+		want string
+	}{
+		{base, PrecisionSecond},
+		{base.Add(123 * time.Millisecond), PrecisionMilli},
+		{base.Add(5 * time.Second), PrecisionMilli}, // coarser lines keep the finest seen
+		{base.Add(123456 * time.Microsecond), PrecisionMicro},
+		{base.Add(123456789), PrecisionNano},
+	} {
+		p.record(tc.ts)
+		if got := p.String(); got != tc.want {
+			t.Errorf("after %s: precision = %q, want %q", tc.ts.Format(time.RFC3339Nano), got, tc.want)
+		}
+	}
+
+	if got := FinerPrecision(PrecisionMilli, PrecisionNano); got != PrecisionNano {
+		t.Errorf("FinerPrecision(ms, ns) = %q", got)
+	}
+	if got := FinerPrecision("", PrecisionSecond); got != PrecisionSecond {
+		t.Errorf("FinerPrecision(\"\", s) = %q", got)
+	}
+}
+
+func TestLokiPush_NanosecondTimestamps(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(1024, &buf, nil)
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+
+	// two lines 1ns apart in the same millisecond
+	payload := `{"streams":[{"stream":{"app":"api"},"values":[["1705314600123456789","first"],["1705314600123456790","second"]]}]}`
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", strings.NewReader(payload)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	w.Close()
+
+	out := buf.String()
+	for _, want := range []string{":00.123456789", ":00.12345679"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %s:\n%s", want, out)
+		}
+	}
+	if got := w.TimestampPrecision(); got != PrecisionNano {
+		t.Errorf("precision = %q, want ns", got)
+	}
+}
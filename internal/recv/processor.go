@@ -0,0 +1,182 @@
+package recv
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Processor transforms entries on the write path, after redaction and before
+// the entry reaches the ring and the writer. OnEntry returns the entry to
+// store, or drop=true to discard it. Implementations must be safe for
+// concurrent use and must not modify entry.Labels in place: the map can be
+// shared with other entries of the same push stream.
+type Processor interface {
+	OnEntry(entry LogEntry) (out LogEntry, drop bool)
+}
+
+// ErrorReporter is implemented by processors that can fail at runtime
+// without failing the entry (e.g. an external command that exited).
+type ErrorReporter interface {
+	SetOnError(fn func(error))
+}
+
+// ProcessorFactory builds a processor from the argument that follows the
+// name in a "name:arg" spec. arg is empty when the spec has no colon.
+type ProcessorFactory func(arg string) (Processor, error)
+
+var (
+	processorsMu sync.RWMutex
+	processors   = make(map[string]ProcessorFactory)
+)
+
+// RegisterProcessor makes a processor available under name. Call it from an
+// init function to compile a processor into the receiver. Registering the
+// same name twice panics.
+func RegisterProcessor(name string, factory ProcessorFactory) {
+	processorsMu.Lock()
+	defer processorsMu.Unlock()
+	if _, dup := processors[name]; dup {
+		panic("recv: processor " + name + " registered twice")
+	}
+	processors[name] = factory
+}
+
+// ProcessorNames returns the registered processor names, sorted.
+func ProcessorNames() []string {
+	processorsMu.RLock()
+	defer processorsMu.RUnlock()
+	names := make([]string, 0, len(processors))
+	for name := range processors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProcessor builds a registered processor from a "name[:arg]" spec.
+func NewProcessor(spec string) (Processor, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	processorsMu.RLock()
+	factory, ok := processors[name]
+	processorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown processor %q (available: %s)", name, strings.Join(ProcessorNames(), ", "))
+	}
+	p, err := factory(arg)
+	if err != nil {
+		return nil, fmt.Errorf("processor %s: %w", name, err)
+	}
+	return p, nil
+}
+
+// ProcessorChain runs processors in order and stops at the first drop.
+type ProcessorChain struct {
+	names  []string
+	procs  []Processor
+	onDrop func(name string)
+}
+
+// NewProcessorChain builds a chain from "name[:arg]" specs. Processors
+// already built are closed if a later spec fails.
+func NewProcessorChain(specs []string) (*ProcessorChain, error) {
+	c := &ProcessorChain{}
+	for _, spec := range specs {
+		p, err := NewProcessor(spec)
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+		name, _, _ := strings.Cut(spec, ":")
+		c.names = append(c.names, name)
+		c.procs = append(c.procs, p)
+	}
+	return c, nil
+}
+
+// SetOnDrop sets a callback invoked with the processor name for each
+// dropped entry.
+func (c *ProcessorChain) SetOnDrop(fn func(name string)) {
+	c.onDrop = fn
+}
+
+// SetOnError forwards runtime errors of processors that report them.
+func (c *ProcessorChain) SetOnError(fn func(name string, err error)) {
+	for i, p := range c.procs {
+		if r, ok := p.(ErrorReporter); ok {
+			name := c.names[i]
+			r.SetOnError(func(err error) { fn(name, err) })
+		}
+	}
+}
+
+// Names returns the processor names in chain order.
+func (c *ProcessorChain) Names() []string {
+	return c.names
+}
+
+// Process runs the entry through every processor.
+func (c *ProcessorChain) Process(entry LogEntry) (LogEntry, bool) {
+	for i, p := range c.procs {
+		var drop bool
+		entry, drop = p.OnEntry(entry)
+		if drop {
+			if c.onDrop != nil {
+				c.onDrop(c.names[i])
+			}
+			return entry, true
+		}
+	}
+	return entry, false
+}
+
+// Close closes every processor that implements io.Closer.
+func (c *ProcessorChain) Close() error {
+	var first error
+	for _, p := range c.procs {
+		if cl, ok := p.(io.Closer); ok {
+			if err := cl.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func init() {
+	RegisterProcessor("exec", func(arg string) (Processor, error) {
+		return NewExecProcessor(arg, defaultExecTimeout)
+	})
+	RegisterProcessor("label", newLabelProcessor)
+}
+
+// labelProcessor adds static labels, e.g. "label:env=load,team=payments".
+type labelProcessor struct {
+	labels map[string]string
+}
+
+func newLabelProcessor(arg string) (Processor, error) {
+	labels := make(map[string]string)
+	for _, kv := range strings.Split(arg, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label %q: expected key=value", kv)
+		}
+		labels[k] = v
+	}
+	return &labelProcessor{labels: labels}, nil
+}
+
+func (p *labelProcessor) OnEntry(entry LogEntry) (LogEntry, bool) {
+	labels := make(map[string]string, len(entry.Labels)+len(p.labels))
+	for k, v := range entry.Labels {
+		labels[k] = v
+	}
+	for k, v := range p.labels {
+		labels[k] = v
+	}
+	entry.Labels = labels
+	return entry, false
+}
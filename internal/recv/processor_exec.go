@@ -0,0 +1,190 @@
+package recv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExecTimeout = 5 * time.Second
+	maxExecLineBytes   = maxRequestBytes
+)
+
+// ExecProcessor pipes entries through a long-running external command.
+// Each entry is written to the command's stdin as one JSON line
+// ({"ts":...,"labels":{...},"msg":"..."}) and the command answers each with
+// one line on stdout: a JSON entry to store, or an empty line or null to drop
+// it. Fields missing from the answer keep their original values.
+//
+// If the command exits, writes garbage, or does not take and answer an
+// entry within the timeout, it is stopped and all further entries pass through unchanged, so
+// a broken processor never loses logs.
+type ExecProcessor struct {
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan []byte
+	done    chan struct{}
+	timeout time.Duration
+	failed  bool
+	onError func(error)
+}
+
+// NewExecProcessor starts command (split on whitespace, no shell) and
+// returns a processor that waits up to timeout for each answer.
+func NewExecProcessor(command string, timeout time.Duration) (*ExecProcessor, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("command required (exec:/path/to/program [args])")
+	}
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", args[0], err)
+	}
+
+	p := &ExecProcessor{
+		cmd:     cmd,
+		stdin:   stdin,
+		lines:   make(chan []byte),
+		done:    make(chan struct{}),
+		timeout: timeout,
+	}
+	go p.readLoop(stdout)
+	return p, nil
+}
+
+func (p *ExecProcessor) readLoop(stdout io.Reader) {
+	defer close(p.lines)
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64*1024), maxExecLineBytes)
+	for sc.Scan() {
+		line := bytes.Clone(sc.Bytes())
+		select {
+		case p.lines <- line:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// SetOnError sets a callback invoked once when the command fails.
+func (p *ExecProcessor) SetOnError(fn func(error)) {
+	p.mu.Lock()
+	p.onError = fn
+	p.mu.Unlock()
+}
+
+// OnEntry sends the entry to the command and applies its answer.
+func (p *ExecProcessor) OnEntry(entry LogEntry) (LogEntry, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failed {
+		return entry, false
+	}
+
+	req, err := json.Marshal(entry)
+	if err != nil {
+		return entry, false
+	}
+	// the timeout covers the write too: a command that stops reading fills
+	// the pipe and would otherwise block ingest. stop unblocks the write by
+	// closing stdin and killing the command.
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	written := make(chan error, 1)
+	go func() {
+		_, err := p.stdin.Write(append(req, '\n'))
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			p.fail(fmt.Errorf("write: %w", err))
+			return entry, false
+		}
+	case <-timer.C:
+		p.fail(fmt.Errorf("stdin not read within %s", p.timeout))
+		return entry, false
+	}
+
+	var line []byte
+	select {
+	case l, ok := <-p.lines:
+		if !ok {
+			p.fail(errors.New("command exited"))
+			return entry, false
+		}
+		line = bytes.TrimSpace(l)
+	case <-timer.C:
+		p.fail(fmt.Errorf("no answer within %s", p.timeout))
+		return entry, false
+	}
+
+	if len(line) == 0 || string(line) == "null" {
+		return entry, true
+	}
+	out := entry
+	out.Labels = nil // decode into a fresh map; the original may be shared
+	if err := json.Unmarshal(line, &out); err != nil {
+		p.fail(fmt.Errorf("invalid answer: %w", err))
+		return entry, false
+	}
+	if out.Labels == nil {
+		out.Labels = entry.Labels
+	}
+	return out, false
+}
+
+// fail stops the command and switches to pass-through. Caller holds mu.
+func (p *ExecProcessor) fail(err error) {
+	p.failed = true
+	p.stop()
+	if p.onError != nil {
+		p.onError(err)
+	}
+}
+
+func (p *ExecProcessor) stop() {
+	select {
+	case <-p.done:
+		return
+	default:
+		close(p.done)
+	}
+	_ = p.stdin.Close()
+	if p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	_ = p.cmd.Wait()
+}
+
+// Close stops the command.
+func (p *ExecProcessor) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failed = true
+	p.stop()
+	return nil
+}
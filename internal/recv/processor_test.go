@@ -0,0 +1,238 @@
+package recv
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type dropProcessor struct{ msg string }
+
+func (p dropProcessor) OnEntry(e LogEntry) (LogEntry, bool) {
+	return e, e.Message == p.msg
+}
+
+func TestRegisterProcessor(t *testing.T) {
+	RegisterProcessor("test-drop", func(arg string) (Processor, error) {
+		if arg == "" {
+			return nil, errors.New("message required")
+		}
+		return dropProcessor{msg: arg}, nil
+	})
+
+	names := ProcessorNames()
+	for _, want := range []string{"exec", "label", "test-drop"} {
+		found := false
+		for _, n := range names {
+			found = found || n == want
+		}
+		if !found {
+			t.Errorf("ProcessorNames() = %v, missing %q", names, want)
+		}
+	}
+
+	if _, err := NewProcessor("test-drop"); err == nil || !strings.Contains(err.Error(), "message required") {
+		t.Errorf("expected factory error, got %v", err)
+	}
+	if _, err := NewProcessor("nope"); err == nil || !strings.Contains(err.Error(), "available:") {
+		t.Errorf("expected unknown processor error, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	RegisterProcessor("test-drop", nil)
+}
+
+func TestProcessorChain(t *testing.T) {
+	c, err := NewProcessorChain([]string{"label:env=load,team=pay", "label:env=prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := map[string]string{"app": "api"}
+	out, drop := c.Process(LogEntry{Labels: shared, Message: "hi"})
+	if drop {
+		t.Fatal("unexpected drop")
+	}
+	if out.Labels["env"] != "prod" || out.Labels["team"] != "pay" || out.Labels["app"] != "api" {
+		t.Errorf("labels = %v", out.Labels)
+	}
+	if len(shared) != 1 {
+		t.Errorf("shared labels modified: %v", shared)
+	}
+	if got := c.Names(); len(got) != 2 || got[0] != "label" {
+		t.Errorf("Names() = %v", got)
+	}
+
+	if _, err := NewProcessorChain([]string{"label:novalue"}); err == nil {
+		t.Error("expected error for invalid label spec")
+	}
+}
+
+func TestProcessorChainDrop(t *testing.T) {
+	c := &ProcessorChain{
+		names: []string{"first", "second"},
+		procs: []Processor{dropProcessor{msg: "noise"}, dropProcessor{msg: "other"}},
+	}
+	var dropped []string
+	c.SetOnDrop(func(name string) { dropped = append(dropped, name) })
+
+	if _, drop := c.Process(LogEntry{Message: "noise"}); !drop {
+		t.Error("expected drop by first")
+	}
+	if _, drop := c.Process(LogEntry{Message: "other"}); !drop {
+		t.Error("expected drop by second")
+	}
+	if _, drop := c.Process(LogEntry{Message: "keep"}); drop {
+		t.Error("unexpected drop")
+	}
+	if strings.Join(dropped, ",") != "first,second" {
+		t.Errorf("dropped = %v", dropped)
+	}
+}
+
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "proc.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExecProcessor(t *testing.T) {
+	script := writeScript(t, `while read -r line; do
+  case "$line" in
+    *'"msg":"drop me"'*) echo ;;
+    *'"msg":"rewrite"'*) echo '{"msg":"rewritten"}' ;;
+    *) echo "$line" ;;
+  esac
+done`)
+	p, err := NewExecProcessor(script, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = p.Close() }()
+
+	ts := time.Unix(1700000000, 0).UTC()
+	labels := map[string]string{"app": "api"}
+
+	out, drop := p.OnEntry(LogEntry{Timestamp: ts, Labels: labels, Message: "keep"})
+	if drop || out.Message != "keep" || out.Labels["app"] != "api" || !out.Timestamp.Equal(ts) {
+		t.Errorf("pass-through = %+v drop=%v", out, drop)
+	}
+
+	if _, drop := p.OnEntry(LogEntry{Timestamp: ts, Labels: labels, Message: "drop me"}); !drop {
+		t.Error("expected drop")
+	}
+
+	out, drop = p.OnEntry(LogEntry{Timestamp: ts, Labels: labels, Message: "rewrite"})
+	if drop || out.Message != "rewritten" || out.Labels["app"] != "api" || !out.Timestamp.Equal(ts) {
+		t.Errorf("rewrite = %+v drop=%v, want missing fields kept", out, drop)
+	}
+}
+
+func TestExecProcessorFailurePassesThrough(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"exits", "exit 0", "exited|write"},
+		{"timeout", "sleep 5", "no answer"},
+		{"stdin full", "sleep 5", "stdin not read"},
+		{"garbage", `read -r line; echo "not json"; cat`, "invalid answer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewExecProcessor(writeScript(t, tt.script), 200*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = p.Close() }()
+
+			var mu sync.Mutex
+			var errs []error
+			p.SetOnError(func(err error) {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			})
+
+			msg := "hello"
+			if tt.name == "stdin full" {
+				msg = strings.Repeat("x", 1<<20) // more than a pipe buffer
+			}
+			for i := 0; i < 2; i++ {
+				start := time.Now()
+				out, drop := p.OnEntry(LogEntry{Message: msg})
+				if took := time.Since(start); took > 2*time.Second {
+					t.Errorf("entry %d took %s", i, took)
+				}
+				if drop || out.Message != msg {
+					t.Errorf("entry %d = %+v drop=%v, want pass-through", i, out, drop)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(errs) != 1 || !containsAny(errs[0].Error(), strings.Split(tt.want, "|")) {
+				t.Errorf("errors = %v, want one containing %q", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecProcessorInvalidCommand(t *testing.T) {
+	if _, err := NewExecProcessor("", 0); err == nil {
+		t.Error("expected error for empty command")
+	}
+	if _, err := NewExecProcessor(filepath.Join(t.TempDir(), "missing"), 0); err == nil {
+		t.Error("expected error for missing command")
+	}
+}
+
+func TestIngestProcessors(t *testing.T) {
+	c, err := NewProcessorChain([]string{"label:env=load"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.procs = append(c.procs, dropProcessor{msg: "noise"})
+	c.names = append(c.names, "drop")
+
+	var buf bytes.Buffer
+	w := NewWriter(10, &buf, nil)
+	ring := NewLogRing(10)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	srv.SetProcessors(c)
+
+	entry := LogEntry{Timestamp: time.Now(), Labels: map[string]string{"app": "api"}, Message: "ok"}
+	if !srv.Ingest(&entry) {
+		t.Fatal("entry not accepted")
+	}
+	if entry.Labels["env"] != "load" {
+		t.Errorf("labels = %v, want processor label", entry.Labels)
+	}
+	noise := LogEntry{Timestamp: time.Now(), Message: "noise"}
+	if !srv.Ingest(&noise) {
+		t.Error("processor drop should count as accepted")
+	}
+	if n := len(ring.Snapshot()); n != 1 {
+		t.Errorf("ring len = %d, want 1 (dropped entry must not be stored)", n)
+	}
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
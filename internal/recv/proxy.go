@@ -0,0 +1,117 @@
+package recv
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies identifies the real client of requests arriving through
+// an Ingress or load balancer. X-Forwarded-For and X-Forwarded-Proto are
+// honored only when the direct peer is in one of the trusted prefixes, so
+// other clients cannot spoof their address.
+type TrustedProxies struct {
+	prefixes []netip.Prefix
+}
+
+// ParseTrustedProxies parses CIDRs or bare IP addresses. It returns nil for
+// an empty list.
+func ParseTrustedProxies(specs []string) (*TrustedProxies, error) {
+	t := &TrustedProxies{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		if p, err := netip.ParsePrefix(spec); err == nil {
+			t.prefixes = append(t.prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: want a CIDR or IP address", spec)
+		}
+		t.prefixes = append(t.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	if len(t.prefixes) == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// Trusted reports whether addr is a trusted proxy.
+func (t *TrustedProxies) Trusted(addr string) bool {
+	if t == nil {
+		return false
+	}
+	a, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	a = a.Unmap()
+	for _, p := range t.prefixes {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client behind r. Through trusted
+// proxies it is the rightmost X-Forwarded-For address that is not itself a
+// trusted proxy; otherwise it is the direct peer.
+func (t *TrustedProxies) ClientIP(r *http.Request) string {
+	peer := hostOnly(r.RemoteAddr)
+	if !t.Trusted(peer) {
+		return peer
+	}
+	hops := forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !t.Trusted(hops[i]) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return peer
+}
+
+// Proto returns the scheme the client used: X-Forwarded-Proto from a
+// trusted proxy, else that of the direct connection.
+func (t *TrustedProxies) Proto(r *http.Request) string {
+	if t.Trusted(hostOnly(r.RemoteAddr)) {
+		if v, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ","); strings.TrimSpace(v) != "" {
+			return strings.ToLower(strings.TrimSpace(v))
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// forwardedFor returns the X-Forwarded-For hops, client first, across all
+// header lines. Entries that are not IP addresses are dropped.
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	for _, line := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(line, ",") {
+			hop = hostOnly(strings.TrimSpace(hop))
+			if _, err := netip.ParseAddr(hop); err == nil {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// hostOnly strips the port from host:port, including bracketed IPv6.
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
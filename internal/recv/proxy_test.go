@@ -0,0 +1,139 @@
+package recv
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	tp, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 192.168.1.5 ", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer spoofing", "203.0.113.7:5000", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"ingress", "10.1.2.3:4000", []string{"198.51.100.9"}, "198.51.100.9"},
+		{"proxy chain", "10.1.2.3:4000", []string{"6.6.6.6, 198.51.100.9, 192.168.1.5"}, "198.51.100.9"},
+		{"multiple header lines", "10.1.2.3:4000", []string{"6.6.6.6", "198.51.100.9"}, "198.51.100.9"},
+		{"only proxies", "10.1.2.3:4000", []string{"10.9.9.9, 192.168.1.5"}, "10.9.9.9"},
+		{"no header", "10.1.2.3:4000", nil, "10.1.2.3"},
+		{"garbage hop skipped", "10.1.2.3:4000", []string{"198.51.100.9, unknown"}, "198.51.100.9"},
+		{"ipv6 peer", "[fd00::1]:4000", []string{"2001:db8::7"}, "2001:db8::7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := tp.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxies_Proto(t *testing.T) {
+	tp, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.RemoteAddr = "10.1.2.3:4000"
+	r.Header.Set("X-Forwarded-Proto", "HTTPS")
+	if got := tp.Proto(r); got != "https" {
+		t.Errorf("trusted Proto = %q, want https", got)
+	}
+	r.RemoteAddr = "203.0.113.7:5000"
+	if got := tp.Proto(r); got != "http" {
+		t.Errorf("untrusted Proto = %q, want http", got)
+	}
+	r.TLS = &tls.ConnectionState{}
+	if got := tp.Proto(r); got != "https" {
+		t.Errorf("TLS Proto = %q, want https", got)
+	}
+}
+
+func TestTrustedProxies_Nil(t *testing.T) {
+	var tp *TrustedProxies
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.RemoteAddr = "10.1.2.3:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.9")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	if got := tp.ClientIP(r); got != "10.1.2.3" {
+		t.Errorf("ClientIP = %q, want the peer", got)
+	}
+	if got := tp.Proto(r); got != "http" {
+		t.Errorf("Proto = %q, want http", got)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tp, err := ParseTrustedProxies(nil)
+	if err != nil || tp != nil {
+		t.Errorf("empty = %v, %v; want nil, nil", tp, err)
+	}
+	for _, spec := range []string{"10.0.0.0/33", "ingress", "10.0.0"} {
+		if _, err := ParseTrustedProxies([]string{spec}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q): expected error", spec)
+		}
+	}
+}
+
+func TestServer_AuditTrustedProxy(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	w := NewWriter(1024, &buf, nil)
+	defer w.Close()
+
+	audit, err := NewAuditLogger(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	srv.SetAuditLogger(audit)
+	srv.SetTrustedProxies(tp)
+
+	r := httptest.NewRequest(http.MethodPost, "/logtap/raw", strings.NewReader(`{"msg":"hi"}`))
+	r.RemoteAddr = "10.1.2.3:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.9")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if err := audit.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e AuditEntry
+	if err := json.Unmarshal(bytes.TrimSpace(data), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.RemoteIP != "198.51.100.9" || e.Proto != "https" {
+		t.Errorf("audit client = %q over %q, want 198.51.100.9 over https", e.RemoteIP, e.Proto)
+	}
+}
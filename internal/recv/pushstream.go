@@ -0,0 +1,211 @@
+package recv
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+	"github.com/ppiankov/logtap/internal/pushproto"
+)
+
+const (
+	maxPushSessions       = 10000
+	pushSessionTTL        = time.Hour
+	pushBackpressurePoll  = 50 * time.Millisecond
+	pushBackpressureNudge = 5 * time.Second
+)
+
+// pushStreamDesc describes the logtap push stream service (see pushproto).
+var pushStreamDesc = grpc.ServiceDesc{
+	ServiceName: pushproto.ServiceName,
+	HandlerType: (*any)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    pushproto.StreamName,
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			return srv.(*Server).servePushStream(stream)
+		},
+	}},
+}
+
+// pushSession remembers the last batch ingested for a forwarder session, so
+// batches resent after a reconnect are acked without being written twice.
+type pushSession struct {
+	mu       sync.Mutex
+	lastSeq  uint64
+	lastSeen time.Time
+}
+
+// pushSessions is the bounded session table; idle sessions expire after
+// pushSessionTTL and the least recently seen is evicted when full.
+type pushSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*pushSession
+}
+
+func (t *pushSessions) get(id string, now time.Time) *pushSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessions == nil {
+		t.sessions = make(map[string]*pushSession)
+	}
+	if sess, ok := t.sessions[id]; ok {
+		sess.lastSeen = now
+		return sess
+	}
+
+	if len(t.sessions) >= maxPushSessions {
+		var oldest string
+		var oldestSeen time.Time
+		for k, sess := range t.sessions {
+			if now.Sub(sess.lastSeen) > pushSessionTTL {
+				delete(t.sessions, k)
+				continue
+			}
+			if oldest == "" || sess.lastSeen.Before(oldestSeen) {
+				oldest, oldestSeen = k, sess.lastSeen
+			}
+		}
+		if len(t.sessions) >= maxPushSessions {
+			delete(t.sessions, oldest)
+		}
+	}
+	sess := &pushSession{lastSeen: now}
+	t.sessions[id] = sess
+	return sess
+}
+
+// servePushStream handles one forwarder stream: a hello answered with the
+// session's last acked sequence, then one ack per batch.
+func (s *Server) servePushStream(stream grpc.ServerStream) error {
+	s.trackConnOpen()
+	defer s.trackConnClose()
+
+	var remote string
+	if p, ok := peer.FromContext(stream.Context()); ok && p.Addr != nil {
+		remote = stripPort(p.Addr.String())
+	}
+
+	var msg []byte
+	if err := stream.RecvMsg(&msg); err != nil {
+		return err
+	}
+	hello, err := pushproto.UnmarshalFrame(msg)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if hello.Session == "" {
+		return status.Error(codes.InvalidArgument, "push stream: hello without session")
+	}
+	sess := s.pushSessions.get(hello.Session, time.Now())
+	sess.mu.Lock()
+	acked := sess.lastSeq
+	sess.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	clientHeader := func(key string) string {
+		if v := md.Get(strings.ToLower(key)); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	_ = stream.SetHeader(metadata.Pairs(strings.ToLower(logtypes.ProtocolHeader), strconv.Itoa(logtypes.ProtocolVersion)))
+	if err := sendPushAck(stream, pushproto.Ack{Seq: acked}); err != nil {
+		return err
+	}
+
+	for {
+		if err := stream.RecvMsg(&msg); err != nil {
+			return nil // client closed or went away; resumes on a new stream
+		}
+		start := time.Now()
+		f, err := pushproto.UnmarshalFrame(msg)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		f.Labels = withSession(f.Labels, boundSession(stream.Context()))
+		if err := checkSession(stream.Context(), func(int) map[string]string { return f.Labels }, 1); err != nil {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+		if err := s.waitPushCapacity(stream, sess); err != nil {
+			return err
+		}
+
+		sess.mu.Lock()
+		var lines, bytes int
+		if f.Seq > sess.lastSeq {
+			for _, e := range f.Entries {
+				entry := LogEntry{Labels: f.Labels, Message: e.Line}
+				if e.TimeUnixNano != 0 {
+					entry.Timestamp = time.Unix(0, e.TimeUnixNano)
+				}
+				s.Ingest(&entry)
+				lines++
+				bytes += len(entry.Message)
+			}
+			sess.lastSeq = f.Seq
+		}
+		acked := sess.lastSeq
+		sess.lastSeen = time.Now()
+		sess.mu.Unlock()
+
+		if lines > 0 {
+			s.clients.note(clientHeader, time.Now())
+			if s.metrics != nil {
+				s.metrics.PushDuration.Observe(time.Since(start).Seconds())
+			}
+			s.audit.Log(AuditEntry{
+				Event:    "stream_push_received",
+				RemoteIP: remote,
+				Lines:    lines,
+				Bytes:    bytes,
+				Duration: time.Since(start),
+			})
+		}
+		if err := sendPushAck(stream, pushproto.Ack{Seq: acked}); err != nil {
+			return err
+		}
+	}
+}
+
+// waitPushCapacity holds a batch while the writer queue is full, telling the
+// client so (and repeating it periodically) instead of dropping lines.
+func (s *Server) waitPushCapacity(stream grpc.ServerStream, sess *pushSession) error {
+	if s.writer == nil || s.writer.Healthy() {
+		return nil
+	}
+	poll := time.NewTicker(pushBackpressurePoll)
+	defer poll.Stop()
+	var nudged time.Time
+	for !s.writer.Healthy() {
+		if time.Since(nudged) >= pushBackpressureNudge {
+			sess.mu.Lock()
+			acked := sess.lastSeq
+			sess.mu.Unlock()
+			if err := sendPushAck(stream, pushproto.Ack{Seq: acked, Backpressure: true}); err != nil {
+				return err
+			}
+			nudged = time.Now()
+		}
+		select {
+		case <-poll.C:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+	return nil
+}
+
+func sendPushAck(stream grpc.ServerStream, a pushproto.Ack) error {
+	b := pushproto.MarshalAck(a)
+	return stream.SendMsg(&b)
+}
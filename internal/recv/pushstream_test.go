@@ -0,0 +1,182 @@
+package recv
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/ppiankov/logtap/internal/pushproto"
+)
+
+func startPushStreamServer(t *testing.T, w *Writer, ring *LogRing) *grpc.ClientConn {
+	t.Helper()
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.ServeOTLPGRPC(ln) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+
+	conn, err := grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(pushproto.Codec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func openPushStream(t *testing.T, ctx context.Context, conn *grpc.ClientConn, session string) (grpc.ClientStream, pushproto.Ack) {
+	t.Helper()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, pushproto.FullMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendPushFrame(t, stream, pushproto.Frame{Session: session})
+	return stream, recvPushAck(t, stream)
+}
+
+func sendPushFrame(t *testing.T, stream grpc.ClientStream, f pushproto.Frame) {
+	t.Helper()
+	b := pushproto.MarshalFrame(f)
+	if err := stream.SendMsg(&b); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+func recvPushAck(t *testing.T, stream grpc.ClientStream) pushproto.Ack {
+	t.Helper()
+	var b []byte
+	if err := stream.RecvMsg(&b); err != nil {
+		t.Fatalf("recv ack: %v", err)
+	}
+	ack, err := pushproto.UnmarshalAck(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ack
+}
+
+func pushBatch(seq uint64, lines ...string) pushproto.Frame {
+	f := pushproto.Frame{Seq: seq, Labels: map[string]string{"app": "api"}}
+	for _, l := range lines {
+		f.Entries = append(f.Entries, pushproto.Entry{TimeUnixNano: time.Now().UnixNano(), Line: l})
+	}
+	return f
+}
+
+func TestPushStream_AckAndResume(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	t.Cleanup(w.Close)
+	ring := NewLogRing(0)
+	conn := startPushStreamServer(t, w, ring)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, ack := openPushStream(t, ctx, conn, "s1")
+	if ack.Seq != 0 {
+		t.Fatalf("hello ack = %d, want 0 for a new session", ack.Seq)
+	}
+	sendPushFrame(t, stream, pushBatch(1, "a", "b"))
+	if ack := recvPushAck(t, stream); ack.Seq != 1 {
+		t.Fatalf("ack = %d, want 1", ack.Seq)
+	}
+	_ = stream.CloseSend()
+
+	// reconnect: the receiver reports seq 1 and ignores its resend
+	stream, ack = openPushStream(t, ctx, conn, "s1")
+	if ack.Seq != 1 {
+		t.Fatalf("resume ack = %d, want 1", ack.Seq)
+	}
+	sendPushFrame(t, stream, pushBatch(1, "a", "b"))
+	sendPushFrame(t, stream, pushBatch(2, "c"))
+	if ack := recvPushAck(t, stream); ack.Seq != 1 {
+		t.Errorf("duplicate ack = %d, want 1", ack.Seq)
+	}
+	if ack := recvPushAck(t, stream); ack.Seq != 2 {
+		t.Errorf("ack = %d, want 2", ack.Seq)
+	}
+
+	entries := ring.Snapshot()
+	if len(entries) != 3 {
+		t.Fatalf("ingested %d entries, want 3", len(entries))
+	}
+	if entries[0].Labels["app"] != "api" || entries[2].Message != "c" {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	// other sessions are tracked separately
+	_, ack = openPushStream(t, ctx, conn, "s2")
+	if ack.Seq != 0 {
+		t.Errorf("new session ack = %d, want 0", ack.Seq)
+	}
+}
+
+type blockingWriter struct{ release chan struct{} }
+
+func (b blockingWriter) Write(p []byte) (int, error) {
+	<-b.release
+	return len(p), nil
+}
+
+func TestPushStream_Backpressure(t *testing.T) {
+	dst := blockingWriter{release: make(chan struct{})}
+	w := NewWriter(1, dst, nil)
+	t.Cleanup(w.Close)
+	conn := startPushStreamServer(t, w, nil)
+
+	// one entry stuck in the writer, one filling the queue
+	w.Send(LogEntry{Message: "x"})
+	for w.Queued() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	w.Send(LogEntry{Message: "y"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, _ := openPushStream(t, ctx, conn, "bp")
+	sendPushFrame(t, stream, pushBatch(1, "z"))
+
+	if ack := recvPushAck(t, stream); !ack.Backpressure || ack.Seq != 0 {
+		t.Fatalf("ack = %+v, want backpressure before ingest", ack)
+	}
+	close(dst.release)
+	if ack := recvPushAck(t, stream); ack.Backpressure || ack.Seq != 1 {
+		t.Errorf("ack = %+v, want seq 1 once the queue drains", ack)
+	}
+}
+
+func TestPushSessions_Evict(t *testing.T) {
+	var table pushSessions
+	now := time.Now()
+	first := table.get("first", now.Add(-time.Minute))
+	first.lastSeq = 7
+	for i := 1; i < maxPushSessions; i++ {
+		table.get(strconv.Itoa(i), now)
+	}
+	if got := table.get("first", now); got.lastSeq != 7 {
+		t.Fatalf("existing session lost: lastSeq = %d", got.lastSeq)
+	}
+
+	table.sessions["first"].lastSeen = now.Add(-2 * pushSessionTTL)
+	table.get("new", now)
+	if len(table.sessions) != maxPushSessions {
+		t.Errorf("sessions = %d, want %d", len(table.sessions), maxPushSessions)
+	}
+	if _, ok := table.sessions["first"]; ok {
+		t.Error("expired session not evicted")
+	}
+}
@@ -0,0 +1,195 @@
+package recv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultQueryLimit  = 100
+	maxQueryLimit      = 10_000
+	defaultQueryWindow = 15 * time.Minute // how far back files are searched without from
+)
+
+// LiveQuery filters the entries of a running receiver: those held in the
+// LogRing and, with Files, those already written to the capture.
+type LiveQuery struct {
+	Labels map[string]string // all must match
+	Grep   *regexp.Regexp    // matches the message or any label value
+	From   time.Time
+	To     time.Time
+	Limit  int  // newest matches returned
+	Files  bool // also search the capture files
+}
+
+// ParseLiveQuery reads a query from URL parameters: label (key=value,
+// repeatable), grep, from and to (RFC3339 or relative like -30m, against
+// now), limit and files.
+func ParseLiveQuery(v url.Values, now time.Time) (*LiveQuery, error) {
+	q := &LiveQuery{Limit: defaultQueryLimit}
+	for _, l := range v["label"] {
+		key, val, ok := strings.Cut(l, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("label %q: expected key=value", l)
+		}
+		if q.Labels == nil {
+			q.Labels = make(map[string]string)
+		}
+		q.Labels[key] = val
+	}
+	if g := v.Get("grep"); g != "" {
+		re, err := regexp.Compile(g)
+		if err != nil {
+			return nil, fmt.Errorf("grep: %w", err)
+		}
+		q.Grep = re
+	}
+	var err error
+	if q.From, err = parseQueryTime(v.Get("from"), now); err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	if q.To, err = parseQueryTime(v.Get("to"), now); err != nil {
+		return nil, fmt.Errorf("to: %w", err)
+	}
+	if l := v.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxQueryLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+		}
+		q.Limit = n
+	}
+	if f := v.Get("files"); f != "" {
+		if q.Files, err = strconv.ParseBool(f); err != nil {
+			return nil, fmt.Errorf("files: %w", err)
+		}
+	}
+	return q, nil
+}
+
+func parseQueryTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// Match reports whether e passes the query's filters.
+func (q *LiveQuery) Match(e LogEntry) bool {
+	if !q.From.IsZero() && e.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && e.Timestamp.After(q.To) {
+		return false
+	}
+	for k, v := range q.Labels {
+		if e.Labels[k] != v {
+			return false
+		}
+	}
+	if q.Grep == nil || q.Grep.MatchString(e.Message) {
+		return true
+	}
+	for _, v := range e.Labels {
+		if q.Grep.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// FileSearch calls fn for each entry in the capture files matching q.
+type FileSearch func(q *LiveQuery, fn func(LogEntry)) error
+
+// QueryResult is the response of the live query endpoint.
+type QueryResult struct {
+	Entries   []LogEntry `json:"entries"`             // chronological
+	Matched   int        `json:"matched"`             // matches before the limit
+	Truncated bool       `json:"truncated,omitempty"` // older matches left out
+	RingFrom  time.Time  `json:"ring_from,omitempty"` // oldest entry held in memory
+	Files     bool       `json:"files"`               // capture files were searched
+}
+
+// SetFileSearch lets live queries with files=true search the capture.
+func (s *Server) SetFileSearch(fn FileSearch) {
+	s.files = fn
+}
+
+// Query runs q against the ring and, when asked for, the capture files.
+// Files only fill in entries older than the oldest one in the ring, so
+// nothing is returned twice.
+func (s *Server) Query(q *LiveQuery) (*QueryResult, error) {
+	res := &QueryResult{Entries: []LogEntry{}}
+	var ring []LogEntry
+	if s.ring != nil {
+		all := s.ring.Snapshot()
+		for _, e := range all {
+			if res.RingFrom.IsZero() || e.Timestamp.Before(res.RingFrom) {
+				res.RingFrom = e.Timestamp
+			}
+			if q.Match(e) {
+				ring = append(ring, e)
+			}
+		}
+	}
+
+	var matches []LogEntry
+	if q.Files && s.files != nil {
+		fq := *q
+		if fq.From.IsZero() {
+			fq.From = time.Now().Add(-defaultQueryWindow)
+		}
+		if !res.RingFrom.IsZero() && (fq.To.IsZero() || !fq.To.Before(res.RingFrom)) {
+			fq.To = res.RingFrom.Add(-time.Nanosecond)
+		}
+		err := s.files(&fq, func(e LogEntry) {
+			res.Matched++
+			matches = append(matches, e)
+			if len(matches) > 2*q.Limit {
+				matches = append(matches[:0], matches[len(matches)-q.Limit:]...)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		res.Files = true
+	}
+	res.Matched += len(ring)
+	matches = append(matches, ring...)
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Timestamp.Before(matches[j].Timestamp) })
+	if len(matches) > q.Limit {
+		matches = matches[len(matches)-q.Limit:]
+	}
+	res.Truncated = res.Matched > len(matches)
+	res.Entries = append(res.Entries, matches...)
+	return res, nil
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	q, err := ParseLiveQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := s.Query(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
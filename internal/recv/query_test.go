@@ -0,0 +1,140 @@
+package recv
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseLiveQuery(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	v := url.Values{
+		"label": {"app=api", "pod=api-0"},
+		"grep":  {"timeout|refused"},
+		"from":  {"-30m"},
+		"to":    {"2024-01-15T09:55:00Z"},
+		"limit": {"20"},
+		"files": {"true"},
+	}
+	q, err := ParseLiveQuery(v, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Labels["app"] != "api" || q.Labels["pod"] != "api-0" || q.Grep.String() != "timeout|refused" {
+		t.Errorf("filters = %+v", q)
+	}
+	if !q.From.Equal(now.Add(-30*time.Minute)) || !q.To.Equal(now.Add(-5*time.Minute)) {
+		t.Errorf("from/to = %v/%v", q.From, q.To)
+	}
+	if q.Limit != 20 || !q.Files {
+		t.Errorf("limit/files = %d/%v", q.Limit, q.Files)
+	}
+
+	for name, bad := range map[string]url.Values{
+		"label":  {"label": {"app"}},
+		"grep":   {"grep": {"("}},
+		"from":   {"from": {"yesterday"}},
+		"limit":  {"limit": {"0"}},
+		"toobig": {"limit": {"100000"}},
+		"files":  {"files": {"maybe"}},
+	} {
+		if _, err := ParseLiveQuery(bad, now); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestServer_Query(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	ring := NewLogRing(0)
+	ring.Push(LogEntry{Timestamp: base.Add(2 * time.Second), Labels: map[string]string{"app": "api"}, Message: "request timeout"})
+	ring.Push(LogEntry{Timestamp: base.Add(3 * time.Second), Labels: map[string]string{"app": "web"}, Message: "request timeout"})
+	ring.Push(LogEntry{Timestamp: base.Add(4 * time.Second), Labels: map[string]string{"app": "api"}, Message: "ok"})
+
+	w := NewWriter(16, io.Discard, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+
+	var searched *LiveQuery
+	srv.SetFileSearch(func(q *LiveQuery, fn func(LogEntry)) error {
+		searched = q
+		for i := 0; i < 3; i++ {
+			e := LogEntry{Timestamp: base.Add(time.Duration(i-3) * time.Second), Labels: map[string]string{"app": "api"}, Message: "upstream timeout"}
+			if q.Match(e) {
+				fn(e)
+			}
+		}
+		return nil
+	})
+
+	q, err := ParseLiveQuery(url.Values{"label": {"app=api"}, "grep": {"timeout"}}, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := srv.Query(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Matched != 1 || len(res.Entries) != 1 || res.Files || searched != nil {
+		t.Fatalf("ring only: %+v", res)
+	}
+	if !res.RingFrom.Equal(base.Add(2 * time.Second)) {
+		t.Errorf("ring_from = %v", res.RingFrom)
+	}
+
+	q.Files, q.Limit = true, 3
+	q.From = base.Add(-time.Hour)
+	if res, err = srv.Query(q); err != nil {
+		t.Fatal(err)
+	}
+	if !searched.To.Before(base.Add(2 * time.Second)) {
+		t.Errorf("files searched up to %v, want before the ring", searched.To)
+	}
+	if res.Matched != 4 || len(res.Entries) != 3 || !res.Truncated {
+		t.Fatalf("with files: matched %d, entries %d, truncated %v", res.Matched, len(res.Entries), res.Truncated)
+	}
+	if res.Entries[0].Message != "upstream timeout" || res.Entries[2].Message != "request timeout" {
+		t.Errorf("entries not the newest in order: %+v", res.Entries)
+	}
+}
+
+func TestServer_QueryHandler(t *testing.T) {
+	ring := NewLogRing(0)
+	ring.Push(LogEntry{Timestamp: time.Now(), Labels: map[string]string{"app": "api"}, Message: "boom"})
+	w := NewWriter(16, io.Discard, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	srv.SetPushAuth(NewPushAuth("s3cret"))
+
+	get := func(target, auth string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := get("/logtap/api/v1/query?grep=boom", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want 401", rec.Code)
+	}
+	if rec := get("/logtap/api/v1/query?limit=x", "Bearer s3cret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: status = %d, want 400", rec.Code)
+	}
+	rec := get("/logtap/api/v1/query?grep=boom", "Bearer s3cret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var res QueryResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Matched != 1 || res.Entries[0].Message != "boom" {
+		t.Errorf("result = %+v", res)
+	}
+}
@@ -0,0 +1,142 @@
+package recv
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SampleDefault names the rule applying to entries no override matches.
+const SampleDefault = "default"
+
+// SampleRule keeps Percent of the entries whose label Key equals Value.
+type SampleRule struct {
+	Key     string
+	Value   string
+	Percent float64
+}
+
+func (r SampleRule) String() string {
+	return fmt.Sprintf("%s=%s=%s%%", r.Key, r.Value, strconv.FormatFloat(r.Percent, 'f', -1, 64))
+}
+
+// Sampler thins entries at ingest. Each entry is kept with the percentage of
+// the first override whose label matches, else the default. Sampled-out
+// entries are counted per rule, apart from backpressure drops. Safe for
+// concurrent use.
+type Sampler struct {
+	def      float64 // percent kept when no override matches
+	rules    []SampleRule
+	rand     func() float64
+	onSample func(rule string)
+
+	mu      sync.Mutex
+	sampled map[string]int64 // by rule
+}
+
+// ParseSampler parses a comma-separated rule list such as
+// "default=100%,app=ingress-nginx=10%". The default rule is optional and
+// keeps everything when omitted; the percent sign is optional.
+func ParseSampler(spec string) (*Sampler, error) {
+	s := &Sampler{def: 100, rand: rand.Float64, sampled: make(map[string]int64)}
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid sample rule %q: expected default=<pct> or key=value=<pct>", item)
+		}
+		pct, err := parsePercent(item[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample rule %q: %w", item, err)
+		}
+		sel := item[:i]
+		if seen[sel] {
+			return nil, fmt.Errorf("duplicate sample rule for %q", sel)
+		}
+		seen[sel] = true
+		if sel == SampleDefault {
+			s.def = pct
+			continue
+		}
+		key, value, ok := strings.Cut(sel, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid sample rule %q: expected default=<pct> or key=value=<pct>", item)
+		}
+		s.rules = append(s.rules, SampleRule{Key: key, Value: value, Percent: pct})
+	}
+	return s, nil
+}
+
+func parsePercent(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || v < 0 || v > 100 {
+		return 0, fmt.Errorf("percent must be 0-100, got %q", s)
+	}
+	return v, nil
+}
+
+// SetOnSample sets a callback invoked with the rule name each time an entry
+// is sampled out.
+func (s *Sampler) SetOnSample(fn func(rule string)) { s.onSample = fn }
+
+// Keep reports whether an entry with labels is stored.
+func (s *Sampler) Keep(labels map[string]string) bool {
+	pct, rule := s.def, SampleDefault
+	for _, r := range s.rules {
+		if v, ok := labels[r.Key]; ok && v == r.Value {
+			pct, rule = r.Percent, r.Key+"="+r.Value
+			break
+		}
+	}
+	if pct >= 100 || s.rand()*100 < pct {
+		return true
+	}
+	s.mu.Lock()
+	s.sampled[rule]++
+	s.mu.Unlock()
+	if s.onSample != nil {
+		s.onSample(rule)
+	}
+	return false
+}
+
+// Sampled returns the number of entries sampled out, by rule.
+func (s *Sampler) Sampled() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int64, len(s.sampled))
+	for k, v := range s.sampled {
+		out[k] = v
+	}
+	return out
+}
+
+// Rules returns the rules in their normalized form, default first.
+func (s *Sampler) Rules() []string {
+	out := []string{fmt.Sprintf("%s=%s%%", SampleDefault, strconv.FormatFloat(s.def, 'f', -1, 64))}
+	for _, r := range s.rules {
+		out = append(out, r.String())
+	}
+	return out
+}
+
+// SamplingInfo records ingest sampling in capture metadata.
+type SamplingInfo struct {
+	Rules   []string         `json:"rules"`
+	Sampled map[string]int64 `json:"sampled,omitempty"` // entries not stored, by rule
+}
+
+// Info returns the sampler's rules and counts for metadata.
+func (s *Sampler) Info() *SamplingInfo {
+	info := &SamplingInfo{Rules: s.Rules(), Sampled: s.Sampled()}
+	if len(info.Sampled) == 0 {
+		info.Sampled = nil
+	}
+	return info
+}
@@ -0,0 +1,99 @@
+package recv
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSampler(t *testing.T) {
+	s, err := ParseSampler("default=50%, app=ingress-nginx=10%,ns=kube-system=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"default=50%", "app=ingress-nginx=10%", "ns=kube-system=0%"}
+	if got := s.Rules(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Rules = %v, want %v", got, want)
+	}
+
+	s, err = ParseSampler("app=web=25%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.def != 100 {
+		t.Errorf("default = %v, want 100 when omitted", s.def)
+	}
+
+	for _, bad := range []string{"default", "=10%", "app=web=abc", "app=web=150%", "default=-1", "web=10%", "app=web=10%,app=web=20%"} {
+		if _, err := ParseSampler(bad); err == nil {
+			t.Errorf("ParseSampler(%q): expected error", bad)
+		}
+	}
+}
+
+func TestSamplerKeep(t *testing.T) {
+	s, err := ParseSampler("default=100%,app=ingress-nginx=10%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	draw := 0.05
+	s.rand = func() float64 { return draw }
+	var rules []string
+	s.SetOnSample(func(rule string) { rules = append(rules, rule) })
+
+	nginx := map[string]string{"app": "ingress-nginx"}
+	if !s.Keep(nginx) {
+		t.Error("draw below 10% should be kept")
+	}
+	draw = 0.5
+	if s.Keep(nginx) || s.Keep(nginx) {
+		t.Error("draw above 10% should be sampled out")
+	}
+	if !s.Keep(map[string]string{"app": "api"}) {
+		t.Error("default 100% should keep everything")
+	}
+
+	want := map[string]int64{"app=ingress-nginx": 2}
+	if got := s.Sampled(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Sampled = %v, want %v", got, want)
+	}
+	if len(rules) != 2 || rules[0] != "app=ingress-nginx" {
+		t.Errorf("callback rules = %v", rules)
+	}
+	info := s.Info()
+	if !reflect.DeepEqual(info.Sampled, want) || len(info.Rules) != 2 {
+		t.Errorf("Info = %+v", info)
+	}
+}
+
+func TestIngestSampler(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(10, &buf, nil)
+	ring := NewLogRing(10)
+	stats := NewStats()
+	srv := NewServer(":0", w, nil, nil, stats, ring)
+
+	s, err := ParseSampler("app=noisy=0%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetOnSample(func(string) { stats.RecordSampled() })
+	srv.SetSampler(s)
+
+	noisy := LogEntry{Timestamp: time.Now(), Labels: map[string]string{"app": "noisy"}, Message: "spam"}
+	if !srv.Ingest(&noisy) {
+		t.Error("sampled entry should count as accepted")
+	}
+	quiet := LogEntry{Timestamp: time.Now(), Labels: map[string]string{"app": "api"}, Message: "ok"}
+	if !srv.Ingest(&quiet) {
+		t.Fatal("entry not accepted")
+	}
+	if n := len(ring.Snapshot()); n != 1 {
+		t.Errorf("ring has %d entries, want 1", n)
+	}
+	snap := stats.Snapshot(0, 0, 0)
+	if snap.LogsSampled != 1 || snap.LogsReceived != 1 || snap.LogsDropped != 0 {
+		t.Errorf("received=%d sampled=%d dropped=%d, want 1/1/0", snap.LogsReceived, snap.LogsSampled, snap.LogsDropped)
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
 )
 
 // LokiPushRequest is the Loki push API JSON payload.
@@ -27,8 +32,12 @@ type LokiStream struct {
 
 const maxRequestBytes = 10 << 20 // 10MB
 
+// SessionHeader names the capture session of a push whose entries carry no
+// session label, for clients that cannot set labels per line.
+const SessionHeader = "X-Logtap-Session"
+
 // APIVersion is incremented on breaking changes to the push API.
-const APIVersion = 1
+const APIVersion = logtypes.ProtocolVersion
 
 // Server is the HTTP receiver server.
 type Server struct {
@@ -39,8 +48,28 @@ type Server struct {
 	stats      *Stats
 	ring       *LogRing
 	audit      *AuditLogger
+	dups       *DupDetector
+	processors *ProcessorChain
+	sampler    *Sampler
+	timestamps *TimestampResolver
+	trusted    *TrustedProxies
+	limits     LabelLimits
+	auth       *PushAuth
+	shards     *ShardRouter
+	files      FileSearch
+	debugger   *Debugger
+	alerts     *AlertEngine
 	activeConn atomic.Int64
 	version    string
+	tracer     trace.Tracer
+
+	grpcMu  sync.Mutex
+	grpcSrv *grpc.Server // OTLP/gRPC, when ServeOTLPGRPC is running
+
+	pushSessions pushSessions
+	tails        tailHub
+	clients      clientBuilds
+	capture      captureInfo
 }
 
 // NewServer creates an HTTP server bound to addr.
@@ -51,15 +80,33 @@ func NewServer(addr string, writer *Writer, redactor *Redactor, metrics *Metrics
 		metrics:  metrics,
 		stats:    stats,
 		ring:     ring,
+		tracer:   noopTracer,
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /loki/api/v1/push", s.handleLokiPush)
-	mux.HandleFunc("POST /logtap/raw", s.handleRawPush)
+	mux.HandleFunc("POST /loki/api/v1/push", s.requireAuth("loki", s.handleLokiPush))
+	mux.HandleFunc("POST /logtap/raw", s.requireAuth("raw", s.handleRawPush))
+	mux.HandleFunc("POST "+otlpLogsPath, s.requireAuth("otlp", s.handleOTLPLogs))
+	mux.HandleFunc("POST /_bulk", s.requireAuth("bulk", s.handleBulk))
+	mux.HandleFunc("PUT /_bulk", s.requireAuth("bulk", s.handleBulk))
+	mux.HandleFunc("POST /{index}/_bulk", s.requireAuth("bulk", s.handleBulk))
+	mux.HandleFunc("PUT /{index}/_bulk", s.requireAuth("bulk", s.handleBulk))
+	mux.HandleFunc("GET /{$}", s.handleESInfo)
 	mux.HandleFunc("GET /healthz", s.handleHealthz)
 	mux.HandleFunc("GET /readyz", s.handleReadyz)
 	mux.HandleFunc("GET /api/version", s.handleVersion)
-	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /api/v1/watermark", s.requireReceiverToken("watermark", s.handleWatermark))
+	mux.HandleFunc("POST /api/v1/annotate", s.requireAuth("annotate", s.handleAnnotate))
+	mux.HandleFunc("GET /logtap/api/v1/query", s.requireReceiverToken("query", s.handleQuery))
+	mux.HandleFunc("GET /logtap/api/v1/tail", s.requireReceiverToken("tail", s.handleTail))
+	mux.HandleFunc("GET /logtap/api/v1/capture", s.requireReceiverToken("capture", s.handleCaptureInfo))
+	mux.HandleFunc("PUT /logtap/api/v1/capture", s.requireReceiverToken("capture", s.handleCaptureUpdate))
+	mux.HandleFunc("POST /admin/debug", s.requireReceiverToken("debug", s.handleDebug))
+	mux.HandleFunc("GET /admin/alerts", s.requireReceiverToken("alerts", s.handleAlerts))
+	mux.HandleFunc("POST /admin/alerts/{rule}/ack", s.requireReceiverToken("alerts", s.handleAlertSilence))
+	mux.HandleFunc("POST /admin/alerts/{rule}/silence", s.requireReceiverToken("alerts", s.handleAlertSilence))
+	mux.HandleFunc("DELETE /admin/alerts/{rule}/silence", s.requireReceiverToken("alerts", s.handleAlertUnsilence))
+	mux.Handle("GET /metrics", metricsHandler())
 
 	s.httpSrv = &http.Server{
 		Addr:         addr,
@@ -76,6 +123,55 @@ func (s *Server) SetAuditLogger(a *AuditLogger) {
 	s.audit = a
 }
 
+// SetDupDetector enables cross-session duplicate detection on ingest.
+func (s *Server) SetDupDetector(d *DupDetector) {
+	s.dups = d
+}
+
+// SetProcessors runs entries through the chain after redaction.
+func (s *Server) SetProcessors(c *ProcessorChain) {
+	s.processors = c
+}
+
+// SetSampler thins entries at ingest, after processors so rules can match
+// labels they add.
+func (s *Server) SetSampler(sm *Sampler) {
+	s.sampler = sm
+}
+
+// SetTimestampResolver fixes zero or implausible entry timestamps on
+// ingest, before redaction so timestamps in the message are still intact.
+func (s *Server) SetTimestampResolver(r *TimestampResolver) {
+	s.timestamps = r
+}
+
+// SetTrustedProxies honors X-Forwarded-For and X-Forwarded-Proto from
+// these proxies when identifying clients in audit records.
+func (s *Server) SetTrustedProxies(t *TrustedProxies) {
+	s.trusted = t
+}
+
+// SetPushAuth requires a bearer token on the push endpoints (Loki, raw,
+// OTLP, _bulk, and the gRPC services), where session tokens may push to
+// their own session, and the receiver token on the query, tail, watermark,
+// capture and admin APIs. nil leaves them open.
+func (s *Server) SetPushAuth(a *PushAuth) {
+	s.auth = a
+}
+
+// SetShardRouter passes Loki and raw pushes for streams owned by other
+// shards on to their receivers.
+func (s *Server) SetShardRouter(r *ShardRouter) {
+	s.shards = r
+}
+
+// auditRequest logs e with the client identified from r.
+func (s *Server) auditRequest(r *http.Request, e AuditEntry) {
+	e.RemoteIP = s.trusted.ClientIP(r)
+	e.Proto = s.trusted.Proto(r)
+	s.audit.Log(e)
+}
+
 // SetVersion sets the application version reported by /api/version.
 func (s *Server) SetVersion(v string) {
 	s.version = v
@@ -96,8 +192,11 @@ func (s *Server) Serve(ln net.Listener) error {
 	return s.httpSrv.Serve(ln)
 }
 
-// Shutdown gracefully shuts down the server.
+// Shutdown gracefully shuts down the server, including the OTLP/gRPC
+// listener if one is serving.
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopGRPC(ctx)
+	s.tails.close()
 	return s.httpSrv.Shutdown(ctx)
 }
 
@@ -111,64 +210,58 @@ func (s *Server) handleLokiPush(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	ctx, span := s.startPush(r.Context(), r.Header, "loki push")
+	defer span.End()
+
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	s.noteClient(w, r)
 
-	var req LokiPushRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+	req, err := s.decodeLokiPush(ctx, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkLabelLimits("loki", func(i int) map[string]string { return req.Streams[i].Stream }, len(req.Streams)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var lineCount int
-	var byteCount int
-	for _, stream := range req.Streams {
+	session := r.Header.Get(SessionHeader)
+	for i := range req.Streams {
+		req.Streams[i].Stream = withSession(req.Streams[i].Stream, session)
+	}
+	if err := checkSession(ctx, func(i int) map[string]string { return req.Streams[i].Stream }, len(req.Streams)); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	streams, err := s.routeLoki(r, req.Streams)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("forward to shard: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	var entries []LogEntry
+	for _, stream := range streams {
 		for _, val := range stream.Values {
 			if len(val) < 2 {
 				continue
 			}
-			ts := parseNanoTimestamp(val[0])
-			msg := val[1]
-
-			if s.redactor != nil {
-				msg = s.redactor.Redact(msg)
-			}
-
-			lineCount++
-			byteCount += len(msg)
-
-			entry := LogEntry{
-				Timestamp: ts,
+			entries = append(entries, LogEntry{
+				Timestamp: parseNanoTimestamp(val[0]),
 				Labels:    stream.Stream,
-				Message:   msg,
-			}
-
-			if s.ring != nil {
-				s.ring.Push(entry)
-			}
-
-			if s.writer.Send(entry) {
-				if s.metrics != nil {
-					s.metrics.LogsReceived.Inc()
-				}
-				if s.stats != nil {
-					s.stats.RecordEntry(stream.Stream)
-				}
-			} else {
-				if s.metrics != nil {
-					s.metrics.LogsDropped.Inc()
-					s.metrics.BackpressureEvents.Inc()
-				}
-				if s.stats != nil {
-					s.stats.RecordDrop()
-				}
-			}
+				Message:   val[1],
+			})
 		}
 	}
+	s.ingestBatch(ctx, entries, start)
+	var byteCount int
+	for _, entry := range entries {
+		byteCount += len(entry.Message)
+	}
 
-	s.audit.Log(AuditEntry{
+	s.auditRequest(r, AuditEntry{
 		Event:    "loki_push_received",
-		RemoteIP: stripPort(r.RemoteAddr),
-		Lines:    lineCount,
+		Lines:    len(entries),
 		Bytes:    byteCount,
 		Duration: time.Since(start),
 	})
@@ -176,6 +269,35 @@ func (s *Server) handleLokiPush(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// decodeLokiPush reads a Loki push request in JSON or protobuf encoding.
+func (s *Server) decodeLokiPush(ctx context.Context, r *http.Request) (req LokiPushRequest, err error) {
+	_, span := s.tracer.Start(ctx, "decode")
+	defer func() { endSpan(span, err) }()
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-protobuf") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return req, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return req, fmt.Errorf("read body: %w", err)
+	}
+	pb, err := ParseLokiProtobuf(body)
+	if err != nil {
+		return req, err
+	}
+	return *pb, nil
+}
+
+// noteClient records the build of the pushing client and answers with the
+// receiver's push protocol version.
+func (s *Server) noteClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(logtypes.ProtocolHeader, strconv.Itoa(logtypes.ProtocolVersion))
+	s.clients.note(r.Header.Get, time.Now())
+}
+
 func (s *Server) handleRawPush(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	s.trackConnOpen()
@@ -186,58 +308,53 @@ func (s *Server) handleRawPush(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	ctx, span := s.startPush(r.Context(), r.Header, "raw push")
+	defer span.End()
+
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	s.noteClient(w, r)
 
+	_, dspan := s.tracer.Start(ctx, "decode")
 	var lines []LogEntry
 	dec := json.NewDecoder(r.Body)
 	for dec.More() {
 		var entry LogEntry
 		if err := dec.Decode(&entry); err != nil {
+			endSpan(dspan, err)
 			http.Error(w, fmt.Sprintf("invalid JSON line: %v", err), http.StatusBadRequest)
 			return
 		}
-		if s.redactor != nil {
-			entry.Message = s.redactor.Redact(entry.Message)
-		}
 		lines = append(lines, entry)
 	}
+	dspan.End()
+	if err := s.checkLabelLimits("raw", func(i int) map[string]string { return lines[i].Labels }, len(lines)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	session := r.Header.Get(SessionHeader)
+	for i := range lines {
+		lines[i].Labels = withSession(lines[i].Labels, session)
+	}
+	if err := checkSession(ctx, func(i int) map[string]string { return lines[i].Labels }, len(lines)); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	lines, err := s.routeRaw(r, lines)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("forward to shard: %v", err), http.StatusServiceUnavailable)
+		return
+	}
 
-	var lineCount int
+	s.ingestBatch(ctx, lines, start)
 	var byteCount int
 	for _, entry := range lines {
-		if entry.Timestamp.IsZero() {
-			entry.Timestamp = time.Now()
-		}
-
-		lineCount++
 		byteCount += len(entry.Message)
-
-		if s.ring != nil {
-			s.ring.Push(entry)
-		}
-
-		if s.writer.Send(entry) {
-			if s.metrics != nil {
-				s.metrics.LogsReceived.Inc()
-			}
-			if s.stats != nil {
-				s.stats.RecordEntry(entry.Labels)
-			}
-		} else {
-			if s.metrics != nil {
-				s.metrics.LogsDropped.Inc()
-				s.metrics.BackpressureEvents.Inc()
-			}
-			if s.stats != nil {
-				s.stats.RecordDrop()
-			}
-		}
 	}
 
-	s.audit.Log(AuditEntry{
+	s.auditRequest(r, AuditEntry{
 		Event:    "raw_push_received",
-		RemoteIP: stripPort(r.RemoteAddr),
-		Lines:    lineCount,
+		Lines:    len(lines),
 		Bytes:    byteCount,
 		Duration: time.Since(start),
 	})
@@ -245,6 +362,97 @@ func (s *Server) handleRawPush(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Ingest runs one entry through the receive pipeline: timestamp repair,
+// redaction, processors, sampling, duplicate detection, the live ring, the
+// writer queue, and metrics/stats accounting. The entry is updated in place.
+// Returns false if the writer dropped the entry; entries a processor drops or
+// the sampler skips count as accepted.
+func (s *Server) Ingest(entry *LogEntry) bool {
+	if !s.prepare(entry) {
+		return true
+	}
+	return s.enqueue(entry, time.Now(), nil)
+}
+
+// prepare repairs the timestamp of entry, redacts it and runs processors
+// and the sampler. Returns false if the entry is not to be stored.
+func (s *Server) prepare(entry *LogEntry) bool {
+	if s.timestamps != nil {
+		s.timestamps.Resolve(entry, time.Now())
+	} else if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	if s.redactor != nil {
+		entry.Message = s.redactor.Redact(entry.Message)
+	}
+
+	if s.processors != nil {
+		var drop bool
+		if *entry, drop = s.processors.Process(*entry); drop {
+			return false
+		}
+	}
+
+	return s.sampler == nil || s.sampler.Keep(entry.Labels)
+}
+
+// enqueue checks entry for duplicates, publishes it to the live ring and
+// tails, and queues it for the writer, with the push trace tr if any.
+// Returns false if the writer dropped it.
+func (s *Server) enqueue(entry *LogEntry, received time.Time, tr *ingestTrace) bool {
+	if s.dups != nil {
+		s.dups.Check(*entry)
+	}
+
+	if s.ring != nil {
+		s.ring.Push(*entry)
+	}
+	if n := s.tails.publish(*entry); n > 0 && s.metrics != nil {
+		s.metrics.TailDropped.Add(float64(n))
+	}
+
+	if tr != nil {
+		tr.queued()
+	}
+	if s.writer.send(queuedEntry{entry: *entry, received: received, trace: tr}) {
+		if s.metrics != nil {
+			s.metrics.LogsReceived.Inc()
+		}
+		if s.stats != nil {
+			s.stats.RecordEntry(entry.Labels)
+		}
+		return true
+	}
+
+	if tr != nil {
+		tr.unqueued()
+	}
+	if s.metrics != nil {
+		s.metrics.LogsDropped.Inc()
+		s.metrics.BackpressureEvents.Inc()
+	}
+	if s.stats != nil {
+		s.stats.RecordDrop()
+	}
+	return false
+}
+
+// withSession returns labels with the session label set from a
+// SessionHeader value, unless it is empty or labels already carry one.
+// labels is copied, not modified.
+func withSession(labels map[string]string, session string) map[string]string {
+	if session == "" || labels["session"] != "" {
+		return labels
+	}
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out["session"] = session
+	return out
+}
+
 func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -278,6 +486,28 @@ func (s *Server) handleVersion(w http.ResponseWriter, _ *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// watermarkResponse is the /api/v1/watermark payload. Low is the oldest
+// stream watermark: all streams are captured at least up to it. Queued
+// entries are accepted but not yet written.
+type watermarkResponse struct {
+	Session string            `json:"session,omitempty"`
+	Low     time.Time         `json:"low,omitzero"`
+	Queued  int               `json:"queued"`
+	Streams []StreamWatermark `json:"streams"`
+}
+
+func (s *Server) handleWatermark(w http.ResponseWriter, r *http.Request) {
+	session := r.URL.Query().Get("session")
+	streams := s.writer.Watermarks().Streams(session)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(watermarkResponse{
+		Session: session,
+		Low:     LowWatermark(streams),
+		Queued:  s.writer.Queued(),
+		Streams: streams,
+	})
+}
+
 func (s *Server) trackConnOpen() {
 	n := s.activeConn.Add(1)
 	if s.metrics != nil {
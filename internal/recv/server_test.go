@@ -102,6 +102,37 @@ func TestRawPush(t *testing.T) {
 	}
 }
 
+func TestPush_SessionHeader(t *testing.T) {
+	ring := NewLogRing(0)
+	w := NewWriter(1024, &bytes.Buffer{}, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+
+	push := func(path, body string) {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set(SessionHeader, "load-a")
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, r)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: status = %d", path, rec.Code)
+		}
+	}
+	push("/loki/api/v1/push", `{"streams":[{"stream":{"app":"api"},"values":[["1234567890000000000","one"]]},`+
+		`{"stream":{"app":"api","session":"lt-a3f9"},"values":[["1234567890000000000","two"]]}]}`)
+	push("/logtap/raw", `{"msg":"three"}`)
+
+	got := make(map[string]string)
+	for _, e := range ring.Snapshot() {
+		got[e.Message] = e.Labels["session"]
+	}
+	want := map[string]string{"one": "load-a", "two": "lt-a3f9", "three": "load-a"}
+	for msg, session := range want {
+		if got[msg] != session {
+			t.Errorf("%s: session = %q, want %q", msg, got[msg], session)
+		}
+	}
+}
+
 func TestBackpressure(t *testing.T) {
 	var buf bytes.Buffer
 	// buffer size 1 to force drops
@@ -484,3 +515,39 @@ func TestRawPush_AuditEntry(t *testing.T) {
 		t.Error("remote_ip is empty")
 	}
 }
+
+func TestIngest(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(1, &buf, nil)
+
+	redactor, err := NewRedactor([]string{"email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := NewStats()
+	ring := NewLogRing(10)
+	srv := NewServer(":0", w, redactor, nil, stats, ring)
+
+	entry := LogEntry{
+		Timestamp: time.Now(),
+		Labels:    map[string]string{"app": "web"},
+		Message:   "login from test@example.com",
+	}
+	if !srv.Ingest(&entry) {
+		t.Fatal("expected first entry to be accepted")
+	}
+	if strings.Contains(entry.Message, "test@example.com") {
+		t.Error("expected entry to be redacted in place")
+	}
+	if n := len(ring.Snapshot()); n != 1 {
+		t.Errorf("ring len = %d, want 1", n)
+	}
+
+	w.Close()
+	if snap := stats.Snapshot(0, 0, 0); snap.LogsReceived != 1 {
+		t.Errorf("received = %d, want 1", snap.LogsReceived)
+	}
+	if !strings.Contains(buf.String(), "[REDACTED:email]") {
+		t.Errorf("expected redacted output, got %q", buf.String())
+	}
+}
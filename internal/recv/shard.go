@@ -0,0 +1,198 @@
+package recv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+)
+
+// ShardForwardedHeader marks a push a shard passed on to the stream's owner,
+// which stores it without routing it again.
+const ShardForwardedHeader = "X-Logtap-Shard-Forwarded"
+
+const shardForwardTimeout = 10 * time.Second
+
+// Shard identifies one receiver of a horizontally scaled set: receiver
+// Index (1-based) of Count stores the streams whose label hash maps to it.
+type Shard struct {
+	Index int
+	Count int
+}
+
+// ParseShard parses "N/M", e.g. "2/3" for the second of three receivers.
+func ParseShard(s string) (Shard, error) {
+	n, m, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Shard{}, fmt.Errorf("shard %q: expected N/M", s)
+	}
+	index, err := strconv.Atoi(n)
+	if err != nil {
+		return Shard{}, fmt.Errorf("shard %q: invalid index", s)
+	}
+	count, err := strconv.Atoi(m)
+	if err != nil || count < 1 {
+		return Shard{}, fmt.Errorf("shard %q: invalid count", s)
+	}
+	if index < 1 || index > count {
+		return Shard{}, fmt.Errorf("shard %q: index must be between 1 and %d", s, count)
+	}
+	return Shard{Index: index, Count: count}, nil
+}
+
+func (s Shard) String() string { return fmt.Sprintf("%d/%d", s.Index, s.Count) }
+
+// Owner returns the index of the shard that stores the stream with labels.
+func (s Shard) Owner(labels map[string]string) int {
+	return int(logtypes.StreamHash(labels)%uint32(s.Count)) + 1
+}
+
+// ShardRouter passes streams this receiver does not own to their owners,
+// so forwarders can push to any receiver of the set, e.g. through one
+// Service. Peers are the base URLs of all receivers in shard order; the
+// entry for this receiver is not used.
+type ShardRouter struct {
+	shard  Shard
+	peers  []string
+	client *http.Client
+}
+
+// NewShardRouter creates a router for shard with one peer URL per shard.
+func NewShardRouter(shard Shard, peers []string) (*ShardRouter, error) {
+	if len(peers) != shard.Count {
+		return nil, fmt.Errorf("%d shard peers given for %d shards", len(peers), shard.Count)
+	}
+	urls := make([]string, len(peers))
+	for i, p := range peers {
+		p = strings.TrimRight(strings.TrimSpace(p), "/")
+		if !strings.HasPrefix(p, "http://") && !strings.HasPrefix(p, "https://") {
+			p = "http://" + p
+		}
+		urls[i] = p
+	}
+	return &ShardRouter{
+		shard:  shard,
+		peers:  urls,
+		client: &http.Client{Timeout: shardForwardTimeout},
+	}, nil
+}
+
+// Shard returns the shard this router belongs to.
+func (r *ShardRouter) Shard() Shard { return r.shard }
+
+// forwardLoki sends streams to the owning peer as a Loki push, passing on
+// the client's authorization.
+func (r *ShardRouter) forwardLoki(ctx context.Context, owner int, streams []LokiStream, auth string) error {
+	body, err := json.Marshal(LokiPushRequest{Streams: streams})
+	if err != nil {
+		return err
+	}
+	return r.post(ctx, owner, "/loki/api/v1/push", "application/json", body, auth)
+}
+
+// forwardRaw sends entries to the owning peer as a raw JSONL push.
+func (r *ShardRouter) forwardRaw(ctx context.Context, owner int, entries []LogEntry, auth string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return r.post(ctx, owner, "/logtap/raw", "application/x-ndjson", buf.Bytes(), auth)
+}
+
+func (r *ShardRouter) post(ctx context.Context, owner int, path, contentType string, body []byte, auth string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.peers[owner-1]+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(ShardForwardedHeader, r.shard.String())
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("shard %d: %w", owner, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("shard %d: status %d", owner, resp.StatusCode)
+	}
+	return nil
+}
+
+// routeLoki forwards the streams of a push owned by other shards and
+// returns the ones to store here. Pushes already forwarded by a peer are
+// stored as they are.
+func (s *Server) routeLoki(r *http.Request, streams []LokiStream) ([]LokiStream, error) {
+	if s.shards == nil || r.Header.Get(ShardForwardedHeader) != "" {
+		return streams, nil
+	}
+	var local []LokiStream
+	foreign := make(map[int][]LokiStream)
+	for _, st := range streams {
+		if owner := s.shards.shard.Owner(st.Stream); owner != s.shards.shard.Index {
+			foreign[owner] = append(foreign[owner], st)
+			continue
+		}
+		local = append(local, st)
+	}
+	for owner, group := range foreign {
+		var lines int
+		for _, st := range group {
+			lines += len(st.Values)
+		}
+		if err := s.shards.forwardLoki(r.Context(), owner, group, r.Header.Get("Authorization")); err != nil {
+			s.countShardForward(owner, 0, err)
+			return nil, err
+		}
+		s.countShardForward(owner, lines, nil)
+	}
+	return local, nil
+}
+
+// routeRaw is routeLoki for raw pushes.
+func (s *Server) routeRaw(r *http.Request, entries []LogEntry) ([]LogEntry, error) {
+	if s.shards == nil || r.Header.Get(ShardForwardedHeader) != "" {
+		return entries, nil
+	}
+	var local []LogEntry
+	foreign := make(map[int][]LogEntry)
+	for _, e := range entries {
+		if owner := s.shards.shard.Owner(e.Labels); owner != s.shards.shard.Index {
+			foreign[owner] = append(foreign[owner], e)
+			continue
+		}
+		local = append(local, e)
+	}
+	for owner, group := range foreign {
+		if err := s.shards.forwardRaw(r.Context(), owner, group, r.Header.Get("Authorization")); err != nil {
+			s.countShardForward(owner, 0, err)
+			return nil, err
+		}
+		s.countShardForward(owner, len(group), nil)
+	}
+	return local, nil
+}
+
+func (s *Server) countShardForward(owner, lines int, err error) {
+	if s.metrics == nil {
+		return
+	}
+	peer := strconv.Itoa(owner)
+	if err != nil {
+		s.metrics.ShardForwardErrors.WithLabelValues(peer).Inc()
+		return
+	}
+	s.metrics.ShardForwarded.WithLabelValues(peer).Add(float64(lines))
+}
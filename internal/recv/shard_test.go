@@ -0,0 +1,186 @@
+package recv
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseShard(t *testing.T) {
+	s, err := ParseShard("2/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Index != 2 || s.Count != 3 || s.String() != "2/3" {
+		t.Errorf("shard = %+v", s)
+	}
+	for _, bad := range []string{"", "2", "0/3", "4/3", "a/3", "1/0", "1/x"} {
+		if _, err := ParseShard(bad); err == nil {
+			t.Errorf("ParseShard(%q) should fail", bad)
+		}
+	}
+}
+
+func TestShard_Owner(t *testing.T) {
+	s := Shard{Index: 1, Count: 4}
+	owners := make(map[int]bool)
+	for i := 0; i < 32; i++ {
+		labels := map[string]string{"app": fmt.Sprintf("svc-%d", i), "namespace": "load"}
+		owner := s.Owner(labels)
+		if owner < 1 || owner > 4 {
+			t.Fatalf("owner = %d, want 1..4", owner)
+		}
+		if again := s.Owner(map[string]string{"namespace": "load", "app": labels["app"]}); again != owner {
+			t.Fatalf("owner of %v changed with map order", labels)
+		}
+		owners[owner] = true
+	}
+	if len(owners) < 3 {
+		t.Errorf("32 streams landed on only %d of 4 shards", len(owners))
+	}
+}
+
+// startShardSet runs count receivers that route to each other and returns
+// their servers, rings and URLs.
+func startShardSet(t *testing.T, count int, reg *prometheus.Registry) ([]*Server, []*LogRing, []string) {
+	t.Helper()
+	servers := make([]*Server, count)
+	rings := make([]*LogRing, count)
+	urls := make([]string, count)
+	handlers := make([]http.Handler, count)
+	for i := range servers {
+		i := i
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		t.Cleanup(ts.Close)
+		urls[i] = ts.URL
+	}
+	for i := range servers {
+		w := NewWriter(1024, io.Discard, nil)
+		t.Cleanup(w.Close)
+		rings[i] = NewLogRing(0)
+		var m *Metrics
+		if i == 0 && reg != nil {
+			m = NewMetrics(reg)
+		}
+		servers[i] = NewServer(":0", w, nil, m, nil, rings[i])
+		router, err := NewShardRouter(Shard{Index: i + 1, Count: count}, urls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers[i].SetShardRouter(router)
+		handlers[i] = servers[i].httpSrv.Handler
+	}
+	return servers, rings, urls
+}
+
+func TestShardRouter_LokiPush(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	_, rings, urls := startShardSet(t, 3, reg)
+
+	var streams []string
+	for i := 0; i < 12; i++ {
+		streams = append(streams, fmt.Sprintf(`{"stream":{"app":"svc-%d"},"values":[["1700000000000000000","line %d"]]}`, i, i))
+	}
+	body := `{"streams":[` + strings.Join(streams, ",") + `]}`
+	resp, err := http.Post(urls[0]+"/loki/api/v1/push", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	shard := Shard{Count: 3}
+	total := 0
+	for i, ring := range rings {
+		for _, e := range ring.Snapshot() {
+			total++
+			if owner := shard.Owner(e.Labels); owner != i+1 {
+				t.Errorf("%v stored on shard %d, owner is %d", e.Labels, i+1, owner)
+			}
+		}
+	}
+	if total != 12 {
+		t.Errorf("stored %d entries across shards, want 12", total)
+	}
+
+	var forwarded float64
+	if f := gatherMetric(t, reg, "logtap_shard_forwarded_total"); f != nil {
+		for _, m := range f.GetMetric() {
+			forwarded += m.GetCounter().GetValue()
+		}
+	}
+	if want := float64(12 - len(rings[0].Snapshot())); forwarded != want {
+		t.Errorf("forwarded = %v, want %v", forwarded, want)
+	}
+}
+
+func TestShardRouter_RawPush(t *testing.T) {
+	_, rings, urls := startShardSet(t, 2, nil)
+
+	var lines []string
+	for i := 0; i < 8; i++ {
+		lines = append(lines, fmt.Sprintf(`{"ts":"2024-01-01T00:00:00Z","labels":{"app":"svc-%d"},"msg":"m"}`, i))
+	}
+	resp, err := http.Post(urls[1]+"/logtap/raw", "application/x-ndjson", strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if got := len(rings[0].Snapshot()) + len(rings[1].Snapshot()); got != 8 {
+		t.Errorf("stored %d entries, want 8", got)
+	}
+}
+
+func TestShardRouter_PeerDown(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+	router, err := NewShardRouter(Shard{Index: 1, Count: 2}, []string{"127.0.0.1:1", "127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.client.Timeout = time.Second
+	srv.SetShardRouter(router)
+
+	// find a stream owned by shard 2
+	app := "svc-0"
+	for i := 1; router.shard.Owner(map[string]string{"app": app}) != 2; i++ {
+		app = fmt.Sprintf("svc-%d", i)
+	}
+	body := `{"streams":[{"stream":{"app":"` + app + `"},"values":[["1700000000000000000","x"]]}]}`
+	r := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503 so the forwarder retries", rec.Code)
+	}
+
+	// a push a peer already forwarded is stored without routing
+	r = httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", strings.NewReader(body))
+	r.Header.Set(ShardForwardedHeader, "2/2")
+	rec = httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusNoContent || len(ring.Snapshot()) != 1 {
+		t.Errorf("forwarded push: status %d, %d stored", rec.Code, len(ring.Snapshot()))
+	}
+}
+
+func TestNewShardRouter_PeerCount(t *testing.T) {
+	if _, err := NewShardRouter(Shard{Index: 1, Count: 3}, []string{"a:3100", "b:3100"}); err == nil {
+		t.Error("expected error for 2 peers in a set of 3")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // Stats collects pipeline counters for TUI display.
@@ -11,16 +12,21 @@ import (
 type Stats struct {
 	LogsReceived atomic.Int64
 	LogsDropped  atomic.Int64
+	LogsSampled  atomic.Int64
 	ActiveConns  atomic.Int64
 
-	mu      sync.Mutex
-	talkers map[string]int64
+	mu         sync.Mutex
+	talkers    map[string]int64
+	talkerSeen map[string]time.Time
+	duplicates []DuplicateStream
+	activity   []Activity
 }
 
 // NewStats creates a Stats collector.
 func NewStats() *Stats {
 	return &Stats{
-		talkers: make(map[string]int64),
+		talkers:    make(map[string]int64),
+		talkerSeen: make(map[string]time.Time),
 	}
 }
 
@@ -42,14 +48,44 @@ func (s *Stats) RecordEntry(labels map[string]string) {
 
 	s.mu.Lock()
 	s.talkers[name]++
+	s.talkerSeen[name] = time.Now()
 	s.mu.Unlock()
 }
 
+// ExpireTalkers forgets talkers without entries for idle and returns how
+// many were removed.
+func (s *Stats) ExpireTalkers(idle time.Duration) int {
+	cutoff := time.Now().Add(-idle)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for name, seen := range s.talkerSeen {
+		if seen.Before(cutoff) {
+			delete(s.talkers, name)
+			delete(s.talkerSeen, name)
+			n++
+		}
+	}
+	return n
+}
+
 // RecordDrop increments the dropped counter.
 func (s *Stats) RecordDrop() {
 	s.LogsDropped.Add(1)
 }
 
+// RecordSampled increments the sampled-out counter.
+func (s *Stats) RecordSampled() {
+	s.LogsSampled.Add(1)
+}
+
+// RecordDuplicate records a pair of sessions delivering the same stream.
+func (s *Stats) RecordDuplicate(d DuplicateStream) {
+	s.mu.Lock()
+	s.duplicates = append(s.duplicates, d)
+	s.mu.Unlock()
+}
+
 // Talker is a name and its cumulative entry count.
 type Talker struct {
 	Name  string
@@ -60,11 +96,14 @@ type Talker struct {
 type Snapshot struct {
 	LogsReceived int64
 	LogsDropped  int64
+	LogsSampled  int64
 	ActiveConns  int64
 	DiskUsage    int64
 	DiskCap      int64
 	BytesWritten int64
 	Talkers      []Talker
+	Duplicates   []DuplicateStream
+	Activity     []Activity // oldest first
 }
 
 // Snapshot returns a point-in-time copy of all stats.
@@ -72,6 +111,7 @@ func (s *Stats) Snapshot(diskUsage, diskCap, bytesWritten int64) Snapshot {
 	snap := Snapshot{
 		LogsReceived: s.LogsReceived.Load(),
 		LogsDropped:  s.LogsDropped.Load(),
+		LogsSampled:  s.LogsSampled.Load(),
 		ActiveConns:  s.ActiveConns.Load(),
 		DiskUsage:    diskUsage,
 		DiskCap:      diskCap,
@@ -83,6 +123,8 @@ func (s *Stats) Snapshot(diskUsage, diskCap, bytesWritten int64) Snapshot {
 	for name, count := range s.talkers {
 		snap.Talkers = append(snap.Talkers, Talker{Name: name, Count: count})
 	}
+	snap.Duplicates = append([]DuplicateStream(nil), s.duplicates...)
+	snap.Activity = append([]Activity(nil), s.activity...)
 	s.mu.Unlock()
 
 	sort.Slice(snap.Talkers, func(i, j int) bool {
@@ -0,0 +1,372 @@
+package recv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslogMaxMessage caps one syslog message (UDP datagram or TCP frame).
+const syslogMaxMessage = 256 << 10
+
+// syslogMaxLengthDigits is the longest octet count prefix accepted.
+var syslogMaxLengthDigits = len(strconv.Itoa(syslogMaxMessage))
+
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// ParseSyslog parses one RFC 5424 or RFC 3164 message into an entry. The
+// facility, severity, hostname, app name, and MSGID become labels, as do
+// RFC 5424 structured-data parameters (as <sd-id>_<param>, with any
+// @enterprise suffix dropped). Messages without a valid priority are kept
+// whole with the RFC 3164 defaults (user.notice). now is used when the
+// message has no usable timestamp and to pick the year of RFC 3164 ones.
+func ParseSyslog(msg string, now time.Time) LogEntry {
+	msg = strings.TrimRight(msg, "\r\n\x00")
+	pri, rest, ok := parseSyslogPRI(msg)
+	if !ok {
+		pri, rest = 1<<3|5, msg
+	}
+	labels := map[string]string{
+		"facility": syslogFacility(pri >> 3),
+		"severity": syslogSeverities[pri&7],
+	}
+	entry := LogEntry{Timestamp: now, Labels: labels}
+
+	if ok && strings.HasPrefix(rest, "1 ") {
+		parseSyslog5424(rest[2:], &entry)
+	} else if ok {
+		parseSyslog3164(rest, now, &entry)
+	} else {
+		entry.Message = rest
+	}
+	return entry
+}
+
+// parseSyslogPRI parses a leading <PRI>.
+func parseSyslogPRI(msg string) (int, string, bool) {
+	if len(msg) < 3 || msg[0] != '<' {
+		return 0, msg, false
+	}
+	end := strings.IndexByte(msg, '>')
+	if end < 2 || end > 4 {
+		return 0, msg, false
+	}
+	pri, err := strconv.Atoi(msg[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, msg, false
+	}
+	return pri, msg[end+1:], true
+}
+
+func syslogFacility(code int) string {
+	if code < len(syslogFacilities) {
+		return syslogFacilities[code]
+	}
+	return strconv.Itoa(code)
+}
+
+// parseSyslog5424 parses the part after "<PRI>1 ".
+func parseSyslog5424(s string, e *LogEntry) {
+	fields := make([]string, 0, 5)
+	for range 5 { // TIMESTAMP HOSTNAME APP-NAME PROCID MSGID
+		f, rest, _ := strings.Cut(s, " ")
+		fields = append(fields, f)
+		s = rest
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		e.Timestamp = ts
+	}
+	setSyslogLabel(e.Labels, "host", fields[1])
+	setSyslogLabel(e.Labels, "app", fields[2])
+	setSyslogLabel(e.Labels, "msgid", fields[4])
+
+	if strings.HasPrefix(s, "-") {
+		s = strings.TrimPrefix(s[1:], " ")
+	} else {
+		s = parseStructuredData(s, e.Labels)
+	}
+	e.Message = strings.TrimPrefix(s, "\ufeff") // BOM
+}
+
+// parseStructuredData adds SD parameters to labels and returns the rest of
+// the message. Malformed structured data is left in the message.
+func parseStructuredData(s string, labels map[string]string) string {
+	orig := s
+	params := map[string]string{}
+	for strings.HasPrefix(s, "[") {
+		end := -1
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if s[i] == ']' {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return orig
+		}
+		elem := s[1:end]
+		s = s[end+1:]
+
+		id, body, _ := strings.Cut(elem, " ")
+		id, _, _ = strings.Cut(id, "@")
+		for body != "" {
+			name, rest, ok := strings.Cut(body, `="`)
+			if !ok {
+				return orig
+			}
+			var val strings.Builder
+			i := 0
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				val.WriteByte(rest[i])
+			}
+			if i == len(rest) {
+				return orig
+			}
+			params[labelName(id+"_"+strings.TrimSpace(name))] = val.String()
+			body = strings.TrimLeft(rest[i+1:], " ")
+		}
+	}
+	for k, v := range params {
+		labels[k] = v
+	}
+	return strings.TrimPrefix(s, " ")
+}
+
+// parseSyslog3164 parses "Mmm dd hh:mm:ss HOST TAG[PID]: MSG". The year is
+// taken from now, or the year before when that would put the message more
+// than a day in the future.
+func parseSyslog3164(s string, now time.Time, e *LogEntry) {
+	if len(s) >= 16 && s[15] == ' ' {
+		if ts, err := time.ParseInLocation(time.Stamp, s[:15], now.Location()); err == nil {
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.Sub(now) > 24*time.Hour {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			e.Timestamp = ts
+			s = s[16:]
+			if host, rest, ok := strings.Cut(s, " "); ok && !strings.ContainsAny(host, ":[") {
+				setSyslogLabel(e.Labels, "host", host)
+				s = rest
+			}
+		}
+	}
+
+	// TAG is alphanumeric, up to 32 characters, ending at '[' or ':'
+	if i := strings.IndexAny(s, "[: "); i > 0 && i <= 32 && s[i] != ' ' {
+		tag, rest := s[:i], s[i:]
+		if rest[0] == '[' {
+			if end := strings.Index(rest, "]"); end > 0 {
+				rest = rest[end+1:]
+			}
+		}
+		if strings.HasPrefix(rest, ":") {
+			setSyslogLabel(e.Labels, "app", tag)
+			s = strings.TrimPrefix(rest[1:], " ")
+		}
+	}
+	e.Message = s
+}
+
+func setSyslogLabel(labels map[string]string, key, value string) {
+	if value != "" && value != "-" {
+		labels[key] = value
+	}
+}
+
+// SyslogListener receives syslog over UDP and TCP on the same address and
+// feeds parsed entries through a Server's ingest pipeline. TCP accepts both
+// octet-counted (RFC 6587) and newline-delimited framing.
+type SyslogListener struct {
+	srv    *Server
+	udp    net.PacketConn
+	tcp    net.Listener
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	now    func() time.Time
+}
+
+// ListenSyslog binds addr for UDP and TCP and starts serving.
+func ListenSyslog(addr string, srv *Server) (*SyslogListener, error) {
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("syslog tcp: %w", err)
+	}
+	// bind UDP on the port TCP got, so ":0" yields one port for both
+	udp, err := net.ListenPacket("udp", tcp.Addr().String())
+	if err != nil {
+		_ = tcp.Close()
+		return nil, fmt.Errorf("syslog udp: %w", err)
+	}
+	l := &SyslogListener{srv: srv, udp: udp, tcp: tcp, conns: make(map[net.Conn]struct{}), now: time.Now}
+	l.wg.Add(2)
+	go l.serveUDP()
+	go l.serveTCP()
+	return l, nil
+}
+
+// Addr returns the bound address (same port for UDP and TCP).
+func (l *SyslogListener) Addr() net.Addr {
+	return l.tcp.Addr()
+}
+
+// Close stops both listeners and open TCP connections and waits for
+// in-flight messages to be ingested.
+func (l *SyslogListener) Close() error {
+	err := errors.Join(l.udp.Close(), l.tcp.Close())
+	l.mu.Lock()
+	l.closed = true
+	for c := range l.conns {
+		_ = c.Close()
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+	return err
+}
+
+func (l *SyslogListener) ingest(msg string) {
+	if strings.TrimSpace(msg) == "" {
+		return
+	}
+	entry := ParseSyslog(msg, l.now())
+	l.srv.Ingest(&entry)
+}
+
+func (l *SyslogListener) serveUDP() {
+	defer l.wg.Done()
+	buf := make([]byte, syslogMaxMessage)
+	for {
+		n, _, err := l.udp.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		l.ingest(string(buf[:n]))
+	}
+}
+
+func (l *SyslogListener) serveTCP() {
+	defer l.wg.Done()
+	for {
+		conn, err := l.tcp.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		l.conns[conn] = struct{}{}
+		l.wg.Add(1)
+		l.mu.Unlock()
+		go l.handleConn(conn)
+	}
+}
+
+func (l *SyslogListener) handleConn(conn net.Conn) {
+	defer l.wg.Done()
+	start := time.Now()
+	l.srv.trackConnOpen()
+	defer l.srv.trackConnClose()
+
+	var lines, byteCount int
+	r := bufio.NewReaderSize(conn, 64<<10)
+	for {
+		msg, err := readSyslogFrame(r)
+		if msg != "" {
+			l.ingest(msg)
+			lines++
+			byteCount += len(msg)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	l.mu.Lock()
+	delete(l.conns, conn)
+	l.mu.Unlock()
+	_ = conn.Close()
+
+	l.srv.audit.Log(AuditEntry{
+		Event:    "syslog_push_received",
+		RemoteIP: stripPort(conn.RemoteAddr().String()),
+		Lines:    lines,
+		Bytes:    byteCount,
+		Duration: time.Since(start),
+	})
+}
+
+// readSyslogFrame reads one message from a TCP stream: "LEN SP MSG" when the
+// frame starts with a digit, otherwise up to the next newline.
+func readSyslogFrame(r *bufio.Reader) (string, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if first[0] >= '1' && first[0] <= '9' {
+		// the length is read a byte at a time, so a frame that never sends
+		// its space cannot grow a buffer
+		var lenStr []byte
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			if c == ' ' {
+				break
+			}
+			if c < '0' || c > '9' || len(lenStr) >= syslogMaxLengthDigits {
+				return "", fmt.Errorf("invalid syslog frame length %q", append(lenStr, c))
+			}
+			lenStr = append(lenStr, c)
+		}
+		n, err := strconv.Atoi(string(lenStr))
+		if err != nil || n > syslogMaxMessage {
+			return "", fmt.Errorf("invalid syslog frame length %q", lenStr)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+
+	var b strings.Builder
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if b.Len()+len(chunk) <= syslogMaxMessage {
+			b.Write(chunk)
+		}
+		if err != nil || !isPrefix {
+			return b.String(), err
+		}
+	}
+}
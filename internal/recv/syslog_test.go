@@ -0,0 +1,188 @@
+package recv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog5424(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	msg := `<165>1 2025-01-15T10:00:00.5Z fw01 sshd 4242 ID47 [origin@32473 ip="10.0.0.1" note="a \"quoted\" \]"][meta x="1"] ` + "\ufeff" + `accepted key`
+	e := ParseSyslog(msg, now)
+
+	if e.Message != "accepted key" {
+		t.Errorf("msg = %q", e.Message)
+	}
+	if !e.Timestamp.Equal(time.Date(2025, 1, 15, 10, 0, 0, 5e8, time.UTC)) {
+		t.Errorf("ts = %v", e.Timestamp)
+	}
+	want := map[string]string{
+		"facility":    "local4",
+		"severity":    "notice",
+		"host":        "fw01",
+		"app":         "sshd",
+		"msgid":       "ID47",
+		"origin_ip":   "10.0.0.1",
+		"origin_note": `a "quoted" ]`,
+		"meta_x":      "1",
+	}
+	for k, v := range want {
+		if e.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, e.Labels[k], v)
+		}
+	}
+	if _, ok := e.Labels["procid"]; ok {
+		t.Error("procid should not be a label")
+	}
+}
+
+func TestParseSyslog5424NilValues(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	e := ParseSyslog("<11>1 - - - - - - disk failure\n", now)
+	if e.Message != "disk failure" {
+		t.Errorf("msg = %q", e.Message)
+	}
+	if !e.Timestamp.Equal(now) {
+		t.Errorf("ts = %v, want now", e.Timestamp)
+	}
+	if e.Labels["facility"] != "user" || e.Labels["severity"] != "err" {
+		t.Errorf("labels = %v", e.Labels)
+	}
+	if _, ok := e.Labels["host"]; ok {
+		t.Errorf("nil host should not be a label: %v", e.Labels)
+	}
+}
+
+func TestParseSyslog3164(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	e := ParseSyslog("<34>Jan 15 10:22:01 router1 su[123]: 'su root' failed on /dev/pts/8", now)
+	if e.Message != "'su root' failed on /dev/pts/8" {
+		t.Errorf("msg = %q", e.Message)
+	}
+	if !e.Timestamp.Equal(time.Date(2025, 1, 15, 10, 22, 1, 0, time.UTC)) {
+		t.Errorf("ts = %v", e.Timestamp)
+	}
+	if e.Labels["facility"] != "auth" || e.Labels["severity"] != "crit" || e.Labels["host"] != "router1" || e.Labels["app"] != "su" {
+		t.Errorf("labels = %v", e.Labels)
+	}
+
+	// December message received in January belongs to the previous year
+	e = ParseSyslog("<13>Dec 31 23:59:59 host app: late", now)
+	if e.Timestamp.Year() != 2024 {
+		t.Errorf("year = %d, want 2024", e.Timestamp.Year())
+	}
+}
+
+func TestParseSyslogNoPriority(t *testing.T) {
+	now := time.Now()
+	e := ParseSyslog("plain line without header", now)
+	if e.Message != "plain line without header" {
+		t.Errorf("msg = %q", e.Message)
+	}
+	if e.Labels["facility"] != "user" || e.Labels["severity"] != "notice" {
+		t.Errorf("labels = %v", e.Labels)
+	}
+}
+
+func TestParseStructuredDataMalformed(t *testing.T) {
+	labels := map[string]string{}
+	rest := parseStructuredData(`[broken x="1" msg`, labels)
+	if rest != `[broken x="1" msg` || len(labels) != 0 {
+		t.Errorf("rest = %q labels = %v", rest, labels)
+	}
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	in := "11 <13>1 - - -12 <13>1 x y z\n<13>plain line\nlast"
+	r := bufio.NewReader(strings.NewReader(in))
+	var got []string
+	for {
+		msg, err := readSyslogFrame(r)
+		if msg != "" {
+			got = append(got, msg)
+		}
+		if err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	want := []string{"<13>1 - - -", "<13>1 x y z\n", "<13>plain line", "last"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("frames = %q, want %q", got, want)
+	}
+}
+
+func TestReadSyslogFrameBounded(t *testing.T) {
+	for _, in := range []string{
+		strings.Repeat("9", 1<<20),              // a length that never ends
+		"12x <13>1 x",                           // a length that is not a number
+		strconv.Itoa(syslogMaxMessage+1) + " x", // a length over the cap
+	} {
+		r := bufio.NewReader(strings.NewReader(in))
+		if _, err := readSyslogFrame(r); err == nil || err == io.EOF {
+			t.Errorf("readSyslogFrame(%.20q...) err = %v, want an invalid length", in, err)
+		}
+	}
+
+	// an endless non-octet-counted line is capped, not buffered whole
+	r := bufio.NewReader(strings.NewReader("<13>" + strings.Repeat("x", 2*syslogMaxMessage) + "\n"))
+	msg, err := readSyslogFrame(r)
+	if err != nil || len(msg) > syslogMaxMessage {
+		t.Errorf("long line = %d bytes, %v; want at most %d", len(msg), err, syslogMaxMessage)
+	}
+}
+
+func TestSyslogListener(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+
+	l, err := ListenSyslog("127.0.0.1:0", srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+
+	udp, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = udp.Write([]byte("<14>1 2025-01-15T10:00:00Z h app - - - via udp"))
+	_ = udp.Close()
+
+	tcp, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "<14>1 2025-01-15T10:00:01Z h app - - - via tcp"
+	_, _ = fmt.Fprintf(tcp, "%d %s<14>Jan 15 10:00:02 h app: newline framed\n", len(msg), msg)
+	_ = tcp.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(ring.Snapshot()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []string
+	for _, e := range ring.Snapshot() {
+		msgs = append(msgs, e.Message)
+	}
+	joined := strings.Join(msgs, "|")
+	for _, want := range []string{"via udp", "via tcp", "newline framed"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("missing %q in %q", want, joined)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package recv
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	tailBuffer     = 1024 // entries queued per client before dropping
+	tailMaxBacklog = 10_000
+	tailPing       = 30 * time.Second
+	tailWriteWait  = 10 * time.Second
+)
+
+// tailHub fans ingested entries out to live tail clients. A client that
+// falls behind loses entries rather than slowing ingest down.
+type tailHub struct {
+	n      atomic.Int32 // subscribers, checked before taking mu
+	mu     sync.Mutex
+	subs   map[*tailSub]struct{}
+	closed bool
+}
+
+type tailSub struct {
+	q    *LiveQuery
+	ch   chan LogEntry
+	done chan struct{} // closed when the hub shuts down
+}
+
+func (h *tailHub) subscribe(q *LiveQuery) *tailSub {
+	sub := &tailSub{q: q, ch: make(chan LogEntry, tailBuffer), done: make(chan struct{})}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(sub.done)
+		return sub
+	}
+	if h.subs == nil {
+		h.subs = make(map[*tailSub]struct{})
+	}
+	h.subs[sub] = struct{}{}
+	h.n.Add(1)
+	return sub
+}
+
+func (h *tailHub) unsubscribe(sub *tailSub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		h.n.Add(-1)
+	}
+}
+
+// publish offers e to every client whose filters match, returning the
+// number of clients it was dropped for.
+func (h *tailHub) publish(e LogEntry) int {
+	if h.n.Load() == 0 {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	dropped := 0
+	for sub := range h.subs {
+		if !sub.q.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// close ends every tail, which http.Server.Shutdown does not do for
+// hijacked connections.
+func (h *tailHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		close(sub.done)
+		delete(h.subs, sub)
+	}
+	h.n.Store(0)
+}
+
+var tailUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 16 << 10}
+
+// handleTail streams entries matching label and grep filters over a
+// WebSocket as they arrive, one JSON entry per text message. backlog=N
+// first sends the newest N matching entries held in memory.
+func (s *Server) handleTail(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	q, err := ParseLiveQuery(v, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.From, q.To = time.Time{}, time.Time{}
+	backlog := 0
+	if b := v.Get("backlog"); b != "" {
+		if backlog, err = strconv.Atoi(b); err != nil || backlog < 0 || backlog > tailMaxBacklog {
+			http.Error(w, "backlog must be between 0 and 10000", http.StatusBadRequest)
+			return
+		}
+	}
+
+	conn, err := tailUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader has replied
+	}
+	defer func() { _ = conn.Close() }()
+
+	sub := s.tails.subscribe(q)
+	defer s.tails.unsubscribe(sub)
+	if s.metrics != nil {
+		s.metrics.TailClients.Inc()
+		defer s.metrics.TailClients.Dec()
+	}
+
+	if backlog > 0 && s.ring != nil {
+		recent := s.ring.SnapshotFiltered(q.Match)
+		if len(recent) > backlog {
+			recent = recent[len(recent)-backlog:]
+		}
+		for _, e := range recent {
+			if err := writeTailEntry(conn, e); err != nil {
+				return
+			}
+		}
+	}
+
+	// the client sends nothing; reading notices it going away
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(tailPing)
+	defer ping.Stop()
+	for {
+		select {
+		case e := <-sub.ch:
+			if err := writeTailEntry(conn, e); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(tailWriteWait)); err != nil {
+				return
+			}
+		case <-sub.done:
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "receiver shutting down"),
+				time.Now().Add(time.Second))
+			return
+		case <-gone:
+			return
+		}
+	}
+}
+
+func writeTailEntry(conn *websocket.Conn, e LogEntry) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(tailWriteWait))
+	return conn.WriteJSON(e)
+}
@@ -0,0 +1,110 @@
+package recv
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestServer_Tail(t *testing.T) {
+	ring := NewLogRing(0)
+	w := NewWriter(64, io.Discard, nil)
+	defer w.Close()
+	reg := prometheus.NewRegistry()
+	srv := NewServer(":0", w, nil, NewMetrics(reg), nil, ring)
+	hs := httptest.NewServer(srv.httpSrv.Handler)
+	defer hs.Close()
+
+	api := map[string]string{"app": "api"}
+	web := map[string]string{"app": "web"}
+	srv.Ingest(&LogEntry{Labels: api, Message: "old timeout"})
+	srv.Ingest(&LogEntry{Labels: api, Message: "older ok"})
+	srv.Ingest(&LogEntry{Labels: api, Message: "recent timeout"})
+
+	u := "ws" + strings.TrimPrefix(hs.URL, "http") + "/logtap/api/v1/tail?label=app%3Dapi&grep=timeout&backlog=1"
+	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	read := func() string {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var e LogEntry
+		if err := conn.ReadJSON(&e); err != nil {
+			t.Fatal(err)
+		}
+		return e.Message
+	}
+	// subscribed before the backlog is sent
+	if got := read(); got != "recent timeout" {
+		t.Errorf("backlog = %q, want the newest match", got)
+	}
+
+	srv.Ingest(&LogEntry{Labels: web, Message: "web timeout"})
+	srv.Ingest(&LogEntry{Labels: api, Message: "api ok"})
+	srv.Ingest(&LogEntry{Labels: api, Message: "live timeout"})
+	if got := read(); got != "live timeout" {
+		t.Errorf("live = %q", got)
+	}
+	if v := gatherMetric(t, reg, "logtap_tail_clients").GetMetric()[0].GetGauge().GetValue(); v != 1 {
+		t.Errorf("logtap_tail_clients = %v, want 1", v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("after shutdown: %v, want going away", err)
+	}
+}
+
+func TestServer_TailBadRequest(t *testing.T) {
+	w := NewWriter(16, io.Discard, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	srv.SetPushAuth(NewPushAuth("s3cret"))
+
+	for target, want := range map[string]int{
+		"/logtap/api/v1/tail":                 http.StatusUnauthorized,
+		"/logtap/api/v1/tail?grep=(":          http.StatusBadRequest,
+		"/logtap/api/v1/tail?backlog=-1":      http.StatusBadRequest,
+		"/logtap/api/v1/tail?backlog=1000000": http.StatusBadRequest,
+	} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if want != http.StatusUnauthorized {
+			r.Header.Set("Authorization", "Bearer s3cret")
+		}
+		rec := httptest.NewRecorder()
+		srv.httpSrv.Handler.ServeHTTP(rec, r)
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestTailHub_DropsForSlowClients(t *testing.T) {
+	var h tailHub
+	sub := h.subscribe(&LiveQuery{})
+	for i := 0; i < tailBuffer; i++ {
+		if n := h.publish(LogEntry{Message: "x"}); n != 0 {
+			t.Fatalf("dropped %d at %d", n, i)
+		}
+	}
+	if n := h.publish(LogEntry{Message: "x"}); n != 1 {
+		t.Errorf("dropped %d past the buffer, want 1", n)
+	}
+	h.unsubscribe(sub)
+	if n := h.publish(LogEntry{Message: "x"}); n != 0 {
+		t.Errorf("dropped %d after unsubscribe", n)
+	}
+}
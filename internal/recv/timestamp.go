@@ -0,0 +1,313 @@
+package recv
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Timestamp sources recorded in the ts_source label when the resolver
+// replaces an entry's timestamp.
+const (
+	TimestampSourceMessage = "message" // parsed from the message body
+	TimestampSourceArrival = "arrival" // receive time
+)
+
+// TimestampSourceLabel names the label that records where a replaced
+// timestamp came from.
+const TimestampSourceLabel = "ts_source"
+
+const (
+	// tsScanBytes bounds how far into a message a timestamp is looked for.
+	tsScanBytes = 256
+	// tsMaxFuture is how far ahead of arrival a timestamp may be before it
+	// is considered wrong (clock skew between nodes is tolerated).
+	tsMaxFuture = 24 * time.Hour
+)
+
+// tsMinValid is the earliest plausible timestamp; anything before it is a
+// zero value or an epoch/unit mistake.
+var tsMinValid = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DefaultTimestampLayouts are the message timestamp formats of common
+// languages and frameworks, tried in order after any custom layouts.
+var DefaultTimestampLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",  // RFC 3339: Go, Node toISOString, logrus, zap, .NET "o"
+	"2006-01-02 15:04:05 -0700",  // Rails
+	"2006-01-02 15:04:05Z07:00",  // RFC 3339 with a space
+	"2006-01-02T15:04:05",        // Ruby Logger, Java LocalDateTime (UTC assumed)
+	"2006-01-02 15:04:05",        // Python logging, log4j, logback, Spring Boot (UTC assumed)
+	"02/Jan/2006:15:04:05 -0700", // nginx and Apache access logs
+	"2006/01/02 15:04:05",        // Go log package
+	"Mon Jan _2 15:04:05 2006",   // C ctime, Rust/PHP defaults
+	"Jan _2 15:04:05",            // BSD syslog, no year
+	"0102 15:04:05",              // klog/glog ("I0102 15:04:05.000000"), no year
+}
+
+// tsJSONFields are the fields of JSON log lines that usually hold the event
+// time (zap "ts", pino "time", logstash "@timestamp").
+var tsJSONFields = []string{"time", "ts", "timestamp", "@timestamp", "t", "date"}
+
+type tsLayout struct {
+	layout string
+	re     *regexp.Regexp
+	noYear bool
+}
+
+// TimestampResolver replaces zero or implausible entry timestamps (before
+// 2000 or more than a day after arrival) with a timestamp parsed from the
+// message body, or with the arrival time when none is found, and records
+// the source in the ts_source label. Layouts without a zone are read as UTC.
+type TimestampResolver struct {
+	layouts   []tsLayout
+	onResolve func(source string)
+}
+
+// NewTimestampResolver builds a resolver that tries the custom Go time
+// layouts first, then DefaultTimestampLayouts. A layout that cannot be
+// matched in messages, such as one without a time of day, is an error.
+func NewTimestampResolver(custom []string) (*TimestampResolver, error) {
+	r := &TimestampResolver{}
+	for _, layout := range custom {
+		l, err := compileTimestampLayout(layout)
+		if err != nil {
+			return nil, err
+		}
+		r.layouts = append(r.layouts, l)
+	}
+	for _, layout := range DefaultTimestampLayouts {
+		l, err := compileTimestampLayout(layout)
+		if err != nil {
+			return nil, fmt.Errorf("built-in layout: %w", err)
+		}
+		r.layouts = append(r.layouts, l)
+	}
+	return r, nil
+}
+
+// CheckTimestampLayout reports whether layout can be used as a custom
+// message timestamp layout.
+func CheckTimestampLayout(layout string) error {
+	_, err := compileTimestampLayout(layout)
+	return err
+}
+
+// SetOnResolve sets a callback invoked with the source for each replaced
+// timestamp.
+func (r *TimestampResolver) SetOnResolve(fn func(source string)) {
+	r.onResolve = fn
+}
+
+// Resolve fixes entry.Timestamp in place if it is zero or implausible
+// relative to now, the arrival time. Entries with a plausible timestamp are
+// left untouched.
+func (r *TimestampResolver) Resolve(entry *LogEntry, now time.Time) {
+	ts := entry.Timestamp
+	if !ts.IsZero() && !ts.Before(tsMinValid) && !ts.After(now.Add(tsMaxFuture)) {
+		return
+	}
+
+	source := TimestampSourceArrival
+	entry.Timestamp = now
+	if parsed, ok := r.parse(entry.Message, now); ok {
+		source = TimestampSourceMessage
+		entry.Timestamp = parsed
+	}
+
+	// labels may be shared with other entries of the same push stream
+	labels := make(map[string]string, len(entry.Labels)+1)
+	for k, v := range entry.Labels {
+		labels[k] = v
+	}
+	labels[TimestampSourceLabel] = source
+	entry.Labels = labels
+
+	if r.onResolve != nil {
+		r.onResolve(source)
+	}
+}
+
+// parse finds a plausible timestamp in msg.
+func (r *TimestampResolver) parse(msg string, now time.Time) (time.Time, bool) {
+	if strings.HasPrefix(strings.TrimSpace(msg), "{") {
+		if ts, ok := r.parseJSON(msg, now); ok {
+			return ts, true
+		}
+	}
+	head := msg
+	if len(head) > tsScanBytes {
+		head = head[:tsScanBytes]
+	}
+	for _, l := range r.layouts {
+		if ts, ok := l.find(head, now); ok {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseJSON reads the first well-known time field of a JSON log line:
+// strings go through the layouts, numbers are Unix time in seconds,
+// milliseconds, microseconds, or nanoseconds, by magnitude.
+func (r *TimestampResolver) parseJSON(msg string, now time.Time) (time.Time, bool) {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(msg), &obj); err != nil {
+		return time.Time{}, false
+	}
+	for _, field := range tsJSONFields {
+		switch v := obj[field].(type) {
+		case string:
+			for _, l := range r.layouts {
+				if ts, ok := l.find(v, now); ok {
+					return ts, true
+				}
+			}
+		case float64:
+			if ts := unixAnyUnit(v); plausibleTimestamp(ts, now) {
+				return ts, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// unixAnyUnit converts a Unix time whose unit is inferred from magnitude.
+func unixAnyUnit(v float64) time.Time {
+	abs := math.Abs(v)
+	switch {
+	case abs < 1e11:
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	case abs < 1e14:
+		return time.UnixMicro(int64(v * 1e3)).UTC()
+	case abs < 1e17:
+		return time.UnixMicro(int64(v)).UTC()
+	default:
+		return time.Unix(0, int64(v)).UTC()
+	}
+}
+
+func plausibleTimestamp(ts, now time.Time) bool {
+	return !ts.Before(tsMinValid) && !ts.After(now.Add(tsMaxFuture))
+}
+
+// find returns the first plausible timestamp in s matching the layout.
+func (l tsLayout) find(s string, now time.Time) (time.Time, bool) {
+	for _, loc := range l.re.FindAllStringIndex(s, 4) {
+		ts, err := time.ParseInLocation(l.layout, s[loc[0]:loc[1]], time.UTC)
+		if err != nil {
+			continue
+		}
+		if l.noYear {
+			// same rule as RFC 3164 syslog: current year unless that puts
+			// the timestamp more than a day in the future
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.Sub(now) > tsMaxFuture {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+		}
+		if plausibleTimestamp(ts, now) {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// tsLayoutTokens maps Go layout elements to patterns. Longer elements come
+// first so e.g. "2006" wins over "2" and "Jan" over "1".
+var tsLayoutTokens = []struct{ elem, pattern string }{
+	{"January", `[A-Z][a-z]{2,8}`},
+	{"Monday", `[A-Z][a-z]{5,8}`},
+	{"2006", `\d{4}`},
+	{"Z07:00", `(?:Z|[+-]\d{2}:\d{2})`},
+	{"-07:00", `[+-]\d{2}:\d{2}`},
+	{"Z0700", `(?:Z|[+-]\d{4})`},
+	{"-0700", `[+-]\d{4}`},
+	{"Z07", `(?:Z|[+-]\d{2})`},
+	{"-07", `[+-]\d{2}`},
+	{"Jan", `[A-Z][a-z]{2}`},
+	{"Mon", `[A-Z][a-z]{2}`},
+	{"MST", `[A-Z]{3,5}`},
+	{"__2", `[ \d]{2}\d`},
+	{"002", `\d{3}`},
+	{"_2", `[ \d]\d`},
+	{"01", `\d{2}`},
+	{"02", `\d{2}`},
+	{"03", `\d{2}`},
+	{"04", `\d{2}`},
+	{"05", `\d{2}`},
+	{"06", `\d{2}`},
+	{"15", `\d{2}`},
+	{"PM", `[AP]M`},
+	{"pm", `[ap]m`},
+	{"1", `\d{1,2}`},
+	{"2", `\d{1,2}`},
+	{"3", `\d{1,2}`},
+	{"4", `\d{1,2}`},
+	{"5", `\d{1,2}`},
+}
+
+// tsFraction matches an optional fractional second; Go accepts one after
+// the seconds field even when the layout has none.
+const tsFraction = `(?:[.,]\d{1,9})?`
+
+// compileTimestampLayout turns a Go time layout into a pattern that finds
+// candidate timestamps inside a message.
+func compileTimestampLayout(layout string) (tsLayout, error) {
+	var b strings.Builder
+	hasYear, hasTime := false, false
+	for i := 0; i < len(layout); {
+		rest := layout[i:]
+
+		// explicit fractional seconds: ".000"/",000" fixed, ".999" optional
+		if (rest[0] == '.' || rest[0] == ',') && len(rest) > 1 && (rest[1] == '0' || rest[1] == '9') {
+			n := 1
+			for n < len(rest) && rest[n] == rest[1] {
+				n++
+			}
+			if rest[1] == '0' {
+				fmt.Fprintf(&b, `[.,]\d{%d}`, n-1)
+			} else {
+				b.WriteString(tsFraction)
+			}
+			i += n
+			continue
+		}
+
+		matched := false
+		for _, tok := range tsLayoutTokens {
+			if strings.HasPrefix(rest, tok.elem) {
+				b.WriteString(tok.pattern)
+				switch tok.elem {
+				case "2006", "06":
+					hasYear = true
+				case "15", "03", "3":
+					hasTime = true
+				case "05":
+					next := rest[len(tok.elem):]
+					if !strings.HasPrefix(next, ".") && !strings.HasPrefix(next, ",") {
+						b.WriteString(tsFraction)
+					}
+				}
+				i += len(tok.elem)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteString(regexp.QuoteMeta(rest[:1]))
+			i++
+		}
+	}
+	if !hasTime {
+		return tsLayout{}, fmt.Errorf("timestamp layout %q has no hour (use Go reference time, e.g. 2006-01-02 15:04:05)", layout)
+	}
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return tsLayout{}, fmt.Errorf("timestamp layout %q: %w", layout, err)
+	}
+	return tsLayout{layout: layout, re: re, noYear: !hasYear}, nil
+}
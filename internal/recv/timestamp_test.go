@@ -0,0 +1,141 @@
+package recv
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTimestampResolverLayouts(t *testing.T) {
+	r, err := NewTimestampResolver(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name, msg string
+		want      time.Time
+	}{
+		{"rfc3339", "level=info ts=2025-01-15T10:00:00.25+01:00 msg=ok", time.Date(2025, 1, 15, 9, 0, 0, 25e7, time.UTC)},
+		{"python", "2025-01-15 10:00:00,123 ERROR app: boom", time.Date(2025, 1, 15, 10, 0, 0, 123e6, time.UTC)},
+		{"log4j", "[main] 2025-01-15 10:00:00.500 WARN x", time.Date(2025, 1, 15, 10, 0, 0, 5e8, time.UTC)},
+		{"rails", "I, [2025-01-15 10:00:00 +0200] started", time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{"nginx", `10.0.0.1 - - [15/Jan/2025:10:00:00 +0000] "GET / HTTP/1.1" 200`, time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{"go log", "2025/01/15 10:00:00 listening", time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{"klog", "I0115 10:00:00.000123    1 main.go:42] ready", time.Date(2025, 1, 15, 10, 0, 0, 123e3, time.UTC)},
+		{"syslog no year", "Jan 15 10:00:00 host app: x", time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{"json string", `{"time":"2025-01-15T10:00:00Z","msg":"x"}`, time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{"json seconds", `{"ts":1736935200.5,"msg":"x"}`, time.Date(2025, 1, 15, 10, 0, 0, 5e8, time.UTC)},
+		{"json millis", `{"time":1736935200123}`, time.Date(2025, 1, 15, 10, 0, 0, 123e6, time.UTC)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := LogEntry{Message: c.msg, Labels: map[string]string{"app": "a"}}
+			r.Resolve(&e, now)
+			if !e.Timestamp.Equal(c.want) {
+				t.Errorf("ts = %v, want %v", e.Timestamp, c.want)
+			}
+			if e.Labels[TimestampSourceLabel] != TimestampSourceMessage {
+				t.Errorf("source = %q", e.Labels[TimestampSourceLabel])
+			}
+		})
+	}
+}
+
+func TestTimestampResolverKeepsPlausible(t *testing.T) {
+	r, _ := NewTimestampResolver(nil)
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	ts := now.Add(-time.Hour)
+	labels := map[string]string{"app": "a"}
+	e := LogEntry{Timestamp: ts, Message: "2020-01-01 00:00:00 old text", Labels: labels}
+	r.Resolve(&e, now)
+	if !e.Timestamp.Equal(ts) {
+		t.Errorf("ts = %v, want unchanged", e.Timestamp)
+	}
+	if _, ok := e.Labels[TimestampSourceLabel]; ok {
+		t.Error("plausible timestamp should not be labeled")
+	}
+}
+
+func TestTimestampResolverArrivalFallback(t *testing.T) {
+	var sources []string
+	r, _ := NewTimestampResolver(nil)
+	r.SetOnResolve(func(s string) { sources = append(sources, s) })
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	shared := map[string]string{"app": "a"}
+	for _, ts := range []time.Time{{}, time.Unix(0, 0), now.Add(48 * time.Hour)} {
+		e := LogEntry{Timestamp: ts, Message: "no time here", Labels: shared}
+		r.Resolve(&e, now)
+		if !e.Timestamp.Equal(now) {
+			t.Errorf("ts %v -> %v, want arrival", ts, e.Timestamp)
+		}
+		if e.Labels[TimestampSourceLabel] != TimestampSourceArrival {
+			t.Errorf("source = %q", e.Labels[TimestampSourceLabel])
+		}
+	}
+	if _, ok := shared[TimestampSourceLabel]; ok {
+		t.Error("shared label map was modified")
+	}
+	if len(sources) != 3 || sources[0] != TimestampSourceArrival {
+		t.Errorf("callbacks = %v", sources)
+	}
+
+	// a timestamp in the message that is itself implausible is ignored
+	e := LogEntry{Message: "1999-12-31 23:59:59 party"}
+	r.Resolve(&e, now)
+	if !e.Timestamp.Equal(now) {
+		t.Errorf("ts = %v, want arrival", e.Timestamp)
+	}
+}
+
+func TestTimestampResolverCustomLayout(t *testing.T) {
+	r, err := NewTimestampResolver([]string{"02.01.2006 15:04:05.000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	e := LogEntry{Message: "[15.01.2025 10:00:00.042] job done"}
+	r.Resolve(&e, now)
+	if want := time.Date(2025, 1, 15, 10, 0, 0, 42e6, time.UTC); !e.Timestamp.Equal(want) {
+		t.Errorf("ts = %v, want %v", e.Timestamp, want)
+	}
+
+	if _, err := NewTimestampResolver([]string{"2006-01-02"}); err == nil {
+		t.Error("expected error for layout without time of day")
+	}
+	if err := CheckTimestampLayout("2006-01-02"); err == nil {
+		t.Error("CheckTimestampLayout: expected error for layout without time of day")
+	}
+
+	saved := DefaultTimestampLayouts
+	defer func() { DefaultTimestampLayouts = saved }()
+	DefaultTimestampLayouts = append([]string{"01/02"}, saved...)
+	if _, err := NewTimestampResolver(nil); err == nil {
+		t.Error("expected error, not a panic, for a bad built-in layout")
+	}
+}
+
+func TestServerIngestTimestampResolver(t *testing.T) {
+	w := NewWriter(16, io.Discard, nil)
+	defer w.Close()
+	ring := NewLogRing(0)
+	srv := NewServer(":0", w, nil, nil, nil, ring)
+
+	// without a resolver zero timestamps still become arrival time
+	srv.Ingest(&LogEntry{Message: "2025-01-15 10:00:00 x"})
+	r, _ := NewTimestampResolver(nil)
+	srv.SetTimestampResolver(r)
+	srv.Ingest(&LogEntry{Message: "2025-01-15 10:00:00 x"})
+
+	snap := ring.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("entries = %d", len(snap))
+	}
+	if snap[0].Timestamp.IsZero() || snap[0].Labels[TimestampSourceLabel] != "" {
+		t.Errorf("without resolver: %+v", snap[0])
+	}
+	if !snap[1].Timestamp.Equal(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)) || snap[1].Labels[TimestampSourceLabel] != TimestampSourceMessage {
+		t.Errorf("with resolver: %+v", snap[1])
+	}
+}
@@ -0,0 +1,154 @@
+package recv
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	selfSignedCertFile = "recv-self-signed.crt"
+	selfSignedKeyFile  = "recv-self-signed.key"
+
+	// selfSignedValidity is how long a generated certificate is valid;
+	// it is replaced once less than selfSignedRenewBefore remains.
+	selfSignedValidity    = 365 * 24 * time.Hour
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// SelfSignedCert returns the paths of a self-signed certificate and key in
+// dir valid for hosts (DNS names or IPs). An existing pair is reused while
+// it covers every host and is not close to expiry, so its fingerprint stays
+// the same across restarts and pinned forwarders keep working.
+func SelfSignedCert(dir string, hosts []string) (certFile, keyFile string, err error) {
+	certFile = filepath.Join(dir, selfSignedCertFile)
+	keyFile = filepath.Join(dir, selfSignedKeyFile)
+	if cert, err := loadCert(certFile); err == nil && certCovers(cert, hosts) &&
+		time.Until(cert.NotAfter) > selfSignedRenewBefore {
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+			return certFile, keyFile, nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", "", fmt.Errorf("create tls dir: %w", err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", fmt.Errorf("generate serial: %w", err)
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "logtap recv", Organization: []string{"logtap"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return "", "", fmt.Errorf("create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("marshal key: %w", err)
+	}
+
+	// key first: a certificate without its key would be reused next time
+	if err := writePEM(keyFile, "EC PRIVATE KEY", keyDER, 0o600); err != nil {
+		return "", "", err
+	}
+	if err := writePEM(certFile, "CERTIFICATE", der, 0o644); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+// CertFingerprint returns the SHA-256 fingerprint of the first certificate
+// in certFile as lowercase hex, the form forwarders pin with LOGTAP_TLS_PIN.
+func CertFingerprint(certFile string) (string, error) {
+	cert, err := loadCert(certFile)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ACMETLSConfig returns a TLS config that obtains and renews certificates
+// for domains from Let's Encrypt, answering TLS-ALPN-01 challenges on the
+// listener it serves. Certificates and the account key are cached in
+// cacheDir. The CA connects on port 443, so the receiver must be reachable
+// there under each domain.
+func ACMETLSConfig(domains []string, email, cacheDir string) *tls.Config {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      email,
+	}
+	return m.TLSConfig()
+}
+
+// SetTLSConfig sets the TLS config ListenAndServeTLS uses; with one that
+// provides certificates, such as ACMETLSConfig, the file arguments are
+// left empty.
+func (s *Server) SetTLSConfig(cfg *tls.Config) {
+	s.httpSrv.TLSConfig = cfg
+}
+
+func loadCert(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s: no PEM certificate", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func certCovers(cert *x509.Certificate, hosts []string) bool {
+	for _, h := range hosts {
+		if h != "" && cert.VerifyHostname(h) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func writePEM(path, typ string, der []byte, perm os.FileMode) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package recv
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfSignedCert(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+	hosts := []string{"localhost", "127.0.0.1", "recv.example"}
+
+	certFile, keyFile, err := SelfSignedCert(dir, hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("load generated pair: %v", err)
+	}
+	if pair.Leaf == nil || pair.Leaf.VerifyHostname("recv.example") != nil || pair.Leaf.VerifyHostname("127.0.0.1") != nil {
+		t.Errorf("certificate does not cover %v", hosts)
+	}
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("key mode = %v, want 0600", perm)
+	}
+
+	fp, err := CertFingerprint(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fp) != 64 {
+		t.Errorf("fingerprint = %q, want 64 hex digits", fp)
+	}
+
+	// a restart reuses the pair, so pinned forwarders keep working
+	if _, _, err := SelfSignedCert(dir, hosts[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := CertFingerprint(certFile); again != fp {
+		t.Errorf("fingerprint changed on reuse: %s → %s", fp, again)
+	}
+
+	// a host the certificate does not cover replaces it
+	if _, _, err := SelfSignedCert(dir, []string{"other.example"}); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := CertFingerprint(certFile); again == fp {
+		t.Error("certificate not regenerated for a new host")
+	}
+}
+
+func TestCertFingerprint_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.crt")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CertFingerprint(path); err == nil {
+		t.Error("expected error for a file without a PEM certificate")
+	}
+}
@@ -0,0 +1,152 @@
+package recv
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Push tracing. With a tracer provider set (recv --trace-endpoint), each
+// push request gets a server span, parented by the client's W3C
+// traceparent header when it sends one, with a child span per stage:
+//   - decode: reading and parsing the request body
+//   - redact: timestamp repair, redaction, processors and sampling
+//   - enqueue: handing the entries to the writer queue
+//   - write: from the first to the last entry of the push leaving the
+//     queue, written or folded by dedup; it ends after the push was
+//     answered, so it shows how far the writer lags behind
+//
+// The time from receiving an entry to writing it is recorded in the
+// logtap_ingest_latency_seconds histogram with the trace ID of sampled
+// pushes as exemplar, so a slow bucket leads to a trace.
+
+// tracerName is the instrumentation scope of receiver spans.
+const tracerName = "github.com/ppiankov/logtap/internal/recv"
+
+// DefaultTraceSampleRatio is the share of pushes traced when their client
+// made no sampling decision.
+const DefaultTraceSampleRatio = 0.1
+
+// NewTracerProvider returns a tracer provider exporting spans in batches
+// to an OTLP/HTTP endpoint (e.g. http://otel-collector:4318). Pushes whose
+// client sent a sampled traceparent are always traced; of the others,
+// ratio are. Shut it down to flush the spans still buffered.
+func NewTracerProvider(endpoint string, ratio float64, version string) (*sdktrace.TracerProvider, error) {
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("trace sample ratio %v out of range [0, 1]", ratio)
+	}
+	exp, err := NewOTLPTraceExporter(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "logtap-recv"),
+		attribute.String("service.version", version),
+	)
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	), nil
+}
+
+// SetTracerProvider makes the server trace push requests with tp.
+func (s *Server) SetTracerProvider(tp trace.TracerProvider) {
+	s.tracer = tp.Tracer(tracerName)
+}
+
+// noopTracer is used until SetTracerProvider is called.
+var noopTracer = noop.NewTracerProvider().Tracer(tracerName)
+
+// startPush starts the server span of a push, continuing the trace of the
+// client when header carries a traceparent.
+func (s *Server) startPush(ctx context.Context, header http.Header, name string) (context.Context, trace.Span) {
+	if header != nil {
+		ctx = propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(header))
+	}
+	return s.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// endSpan ends a stage span, marking it failed when err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ingestBatch runs the entries of a push through the receive pipeline
+// like Ingest, tracing the redact and enqueue stages as children of the
+// push span in ctx. Entries are updated in place; received is when the
+// push arrived.
+func (s *Server) ingestBatch(ctx context.Context, entries []LogEntry, received time.Time) {
+	tr := &ingestTrace{tracer: s.tracer, ctx: ctx}
+	tr.pending.Store(1) // held until every entry is queued
+
+	_, span := s.tracer.Start(ctx, "redact")
+	keep := make([]bool, len(entries))
+	var kept int
+	for i := range entries {
+		if keep[i] = s.prepare(&entries[i]); keep[i] {
+			kept++
+		}
+	}
+	span.SetAttributes(attribute.Int("logtap.entries", len(entries)), attribute.Int("logtap.kept", kept))
+	span.End()
+
+	_, span = s.tracer.Start(ctx, "enqueue")
+	var dropped int
+	for i := range entries {
+		if keep[i] && !s.enqueue(&entries[i], received, tr) {
+			dropped++
+		}
+	}
+	span.SetAttributes(attribute.Int("logtap.queued", kept-dropped), attribute.Int("logtap.dropped", dropped))
+	span.End()
+
+	tr.release()
+}
+
+// ingestTrace follows the entries of one push through the writer queue to
+// record its write span.
+type ingestTrace struct {
+	tracer     trace.Tracer
+	ctx        context.Context
+	pending    atomic.Int64 // queued entries not yet written, plus one while queueing
+	firstWrite atomic.Int64 // unix nanoseconds
+}
+
+// queued notes an entry about to be sent to the writer; unqueued takes it
+// back when the queue was full.
+func (t *ingestTrace) queued()   { t.pending.Add(1) }
+func (t *ingestTrace) unqueued() { t.release() }
+
+// written is called by the writer for each entry of the push.
+func (t *ingestTrace) written() {
+	t.firstWrite.CompareAndSwap(0, time.Now().UnixNano())
+	t.release()
+}
+
+// release drops one pending entry and ends the write span after the last.
+func (t *ingestTrace) release() {
+	if t.pending.Add(-1) != 0 {
+		return
+	}
+	first := t.firstWrite.Load()
+	if first == 0 {
+		return // nothing was queued
+	}
+	_, span := t.tracer.Start(t.ctx, "write", trace.WithTimestamp(time.Unix(0, first)))
+	span.End()
+}
@@ -0,0 +1,129 @@
+package recv
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPushTracing(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(1024, &buf, nil)
+	var latencies []time.Duration
+	var latencyTrace trace.SpanContext
+	w.SetLatencyObserver(func(d time.Duration, sc trace.SpanContext) {
+		latencies = append(latencies, d)
+		latencyTrace = sc
+	})
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	srv.SetTracerProvider(tp)
+
+	body := `{"ts":"2024-01-15T10:00:00Z","labels":{"app":"api"},"msg":"one"}
+{"ts":"2024-01-15T10:00:01Z","labels":{"app":"api"},"msg":"two"}`
+	r := httptest.NewRequest(http.MethodPost, "/logtap/raw", strings.NewReader(body))
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp := httptest.NewRecorder()
+	srv.httpSrv.Handler.ServeHTTP(resp, r)
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+	}
+	w.Close()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range rec.Ended() {
+		spans[s.Name()] = s
+	}
+	push := spans["raw push"]
+	if push == nil {
+		t.Fatalf("no push span, got %d spans", len(rec.Ended()))
+	}
+	if got := push.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, want the client's", got)
+	}
+	if !push.Parent().IsRemote() || push.SpanKind() != trace.SpanKindServer {
+		t.Errorf("push span parent = %+v, kind = %v", push.Parent(), push.SpanKind())
+	}
+	for _, name := range []string{"decode", "redact", "enqueue", "write"} {
+		s := spans[name]
+		if s == nil {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if s.Parent().SpanID() != push.SpanContext().SpanID() {
+			t.Errorf("%s span is not a child of the push span", name)
+		}
+	}
+
+	if len(latencies) != 2 {
+		t.Fatalf("latencies = %v, want 2", latencies)
+	}
+	if latencyTrace.TraceID() != push.SpanContext().TraceID() {
+		t.Errorf("latency trace = %s", latencyTrace.TraceID())
+	}
+}
+
+func TestPushTracingQueueFull(t *testing.T) {
+	w := &Writer{ch: make(chan queuedEntry)} // no drain: every send fails
+	rec := tracetest.NewSpanRecorder()
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	srv.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	r := httptest.NewRequest(http.MethodPost, "/logtap/raw", strings.NewReader(`{"msg":"one"}`))
+	srv.httpSrv.Handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	for _, s := range rec.Ended() {
+		if s.Name() == "write" {
+			t.Error("write span recorded though nothing was queued")
+		}
+	}
+}
+
+func TestObserveIngestLatency(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg)
+
+	tid, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	sid, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sampled := trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid, TraceFlags: trace.FlagsSampled})
+	m.ObserveIngestLatency(3*time.Millisecond, sampled)
+	m.ObserveIngestLatency(time.Millisecond, trace.SpanContext{})
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "logtap_ingest_latency_seconds" {
+			continue
+		}
+		h := f.GetMetric()[0].GetHistogram()
+		if h.GetSampleCount() != 2 {
+			t.Errorf("count = %d, want 2", h.GetSampleCount())
+		}
+		var exemplars int
+		for _, b := range h.GetBucket() {
+			if e := b.GetExemplar(); e != nil {
+				exemplars++
+				if got := e.GetLabel()[0].GetValue(); got != tid.String() {
+					t.Errorf("exemplar trace_id = %s", got)
+				}
+			}
+		}
+		if exemplars != 1 {
+			t.Errorf("exemplars = %d, want 1", exemplars)
+		}
+		return
+	}
+	t.Fatal("logtap_ingest_latency_seconds not registered")
+}
@@ -96,6 +96,13 @@ type TUIModel struct {
 	// help overlay
 	showHelp bool
 
+	// activity pane (alerts, webhook deliveries, audit events) in place of logs
+	showActivity bool
+
+	// alert acknowledgement
+	alerts   *AlertEngine
+	alertMsg string // brief confirmation shown in status bar
+
 	// quit signal
 	quitting bool
 }
@@ -129,6 +136,11 @@ func NewTUIModel(stats *Stats, ring *LogRing, disk DiskReporter, diskCap int64,
 	}
 }
 
+// SetAlertEngine lets the dashboard acknowledge and unsilence alert rules.
+func (m *TUIModel) SetAlertEngine(e *AlertEngine) {
+	m.alerts = e
+}
+
 // Init starts the tick timer.
 func (m TUIModel) Init() tea.Cmd {
 	return tickCmd()
@@ -298,6 +310,15 @@ func (m TUIModel) updateNormal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.exportInput = fmt.Sprintf("./filtered-%s", time.Now().Format("20060102-150405"))
 		m.exportMsg = ""
 
+	case "a":
+		m.showActivity = !m.showActivity
+
+	case "A":
+		m.alertMsg = m.ackFiring()
+
+	case "U":
+		m.alertMsg = m.unsilenceAll()
+
 	case "?":
 		m.showHelp = !m.showHelp
 	}
@@ -580,6 +601,9 @@ func (m *TUIModel) scrollToBottom() {
 func (m TUIModel) logPaneHeight() int {
 	// header(1) + blank(1) + stats(6) + separator(1) = 9 lines overhead
 	h := m.height - 9
+	if len(m.curr.Duplicates) > 0 {
+		h-- // duplicate-stream warning row
+	}
 	if h < 1 {
 		h = 1
 	}
@@ -662,6 +686,14 @@ func (m TUIModel) View() string {
 			}
 			b.WriteString("\n")
 		}
+	} else if m.showActivity {
+		actLines := m.renderActivity(paneH)
+		for i := 0; i < paneH; i++ {
+			if i < len(actLines) {
+				b.WriteString(actLines[i])
+			}
+			b.WriteString("\n")
+		}
 	} else {
 		end := m.scrollOff + paneH
 		if end > len(m.lines) {
@@ -721,6 +753,9 @@ func (m TUIModel) View() string {
 	} else if m.exportMsg != "" {
 		status.WriteString(exportBadge.Render(m.exportMsg))
 	}
+	if m.alertMsg != "" {
+		status.WriteString(filterBadge.Render(m.alertMsg))
+	}
 	if m.timeJumping {
 		status.WriteString(searchBadge.Render(fmt.Sprintf("t:%s", m.timeJumpInput)))
 	}
@@ -762,7 +797,12 @@ func (m TUIModel) View() string {
 		}
 		status.WriteString(searchBadge.Render(fmt.Sprintf("[%d/%d] /%s", m.searchIdx+1, len(m.matches), m.highlight.String())))
 	}
-	if m.follow {
+	if m.showActivity {
+		if status.Len() > 0 {
+			status.WriteString(" ")
+		}
+		status.WriteString(filterBadge.Render("ACTIVITY"))
+	} else if m.follow {
 		if status.Len() > 0 {
 			status.WriteString(" ")
 		}
@@ -792,12 +832,26 @@ func (m TUIModel) renderStats() string {
 		b.WriteString("0")
 	}
 	b.WriteString("\n")
+	if m.curr.LogsSampled > 0 {
+		b.WriteString(labelStyle.Render(" Sampled out:  "))
+		b.WriteString(fmt.Sprintf("%d\n", m.curr.LogsSampled))
+	}
 	b.WriteString(labelStyle.Render(" Redact:        "))
 	if m.redactInfo != "" {
 		b.WriteString(m.redactInfo)
 	} else {
 		b.WriteString(warnStyle.Render("OFF — captured logs may contain sensitive data (use --redact)"))
 	}
+	if n := len(m.curr.Duplicates); n > 0 {
+		d := m.curr.Duplicates[n-1]
+		b.WriteString("\n")
+		b.WriteString(labelStyle.Render(" Duplicates:   "))
+		msg := fmt.Sprintf("sessions %s and %s push the same stream (%s)", d.Sessions[0], d.Sessions[1], d.Stream)
+		if n > 1 {
+			msg += fmt.Sprintf(" +%d more", n-1)
+		}
+		b.WriteString(warnStyle.Render(msg))
+	}
 	return b.String()
 }
 
@@ -835,6 +889,44 @@ func (m TUIModel) renderTalkers() string {
 	return b.String()
 }
 
+// renderActivity returns the most recent activity records that fit in h
+// lines, oldest first, after a header line.
+func (m TUIModel) renderActivity(h int) []string {
+	acts := m.curr.Activity
+	var failed int
+	for _, a := range acts {
+		if a.Failed && a.Kind == ActivityWebhook {
+			failed++
+		}
+	}
+	header := boldStyle.Render("Activity") + labelStyle.Render(fmt.Sprintf("  alerts, webhook deliveries, audit events (%d recent", len(acts)))
+	if failed > 0 {
+		header += droppedStyle.Render(fmt.Sprintf(", %d webhook failures", failed))
+	}
+	header += labelStyle.Render(") — press a to return to logs")
+	out := []string{header}
+	if len(acts) == 0 {
+		return append(out, labelStyle.Render(" no activity yet"))
+	}
+
+	if n := h - 1; n > 0 && len(acts) > n {
+		acts = acts[len(acts)-n:]
+	}
+	for _, a := range acts {
+		latency := ""
+		if a.Kind == ActivityWebhook {
+			latency = a.Latency.Round(time.Millisecond).String()
+		}
+		line := fmt.Sprintf(" %s %-8s %7s %s", a.Time.Format("15:04:05"), strings.ToUpper(a.Kind), latency, a.Summary)
+		line = truncateWidth(line, m.width)
+		if a.Failed {
+			line = droppedStyle.Render(line)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
 func (m TUIModel) renderHelp() []string {
 	h := helpKeyStyle
 	d := helpDescStyle
@@ -866,6 +958,9 @@ func (m TUIModel) renderHelp() []string {
 		d.Render("    w          ") + "export filtered view to capture dir",
 		"",
 		h.Render("  General"),
+		d.Render("    a          ") + "toggle activity pane (alerts, webhooks, audit)",
+		d.Render("    A          ") + "acknowledge firing alerts for 1h (no repeat webhooks)",
+		d.Render("    U          ") + "clear alert acknowledgements and silences",
 		d.Render("    ?          ") + "toggle this help",
 		d.Render("    q          ") + "quit",
 	}
@@ -890,6 +985,45 @@ var (
 	helpDescStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("250"))
 )
 
+// ackFiring acknowledges every firing alert rule and describes the result.
+func (m TUIModel) ackFiring() string {
+	if m.alerts == nil {
+		return "no alert rules"
+	}
+	var acked []string
+	for _, st := range m.alerts.Status() {
+		if !st.Firing || (st.Silence != nil && st.Silence.Ack) {
+			continue
+		}
+		if _, err := m.alerts.Ack(st.Name, DefaultSilence); err != nil {
+			return fmt.Sprintf("ack %s: %s", st.Name, err)
+		}
+		acked = append(acked, st.Name)
+	}
+	if len(acked) == 0 {
+		return "no unacknowledged alerts firing"
+	}
+	return "acked " + strings.Join(acked, ", ")
+}
+
+// unsilenceAll removes every alert silence and describes the result.
+func (m TUIModel) unsilenceAll() string {
+	if m.alerts == nil {
+		return "no alert rules"
+	}
+	n := 0
+	for _, st := range m.alerts.Status() {
+		if st.Silence == nil {
+			continue
+		}
+		if err := m.alerts.Unsilence(st.Name); err != nil {
+			return fmt.Sprintf("unsilence %s: %s", st.Name, err)
+		}
+		n++
+	}
+	return fmt.Sprintf("cleared %d alert silences", n)
+}
+
 // helpers
 
 func clamp(v, lo, hi int) int {
@@ -910,6 +1044,22 @@ func padRight(s string, w int) string {
 	return s + strings.Repeat(" ", w-n)
 }
 
+// truncateWidth cuts s to at most w terminal cells without splitting a
+// rune.
+func truncateWidth(s string, w int) string {
+	if lipgloss.Width(s) <= w {
+		return s
+	}
+	width := 0
+	for i, r := range s {
+		width += lipgloss.Width(string(r))
+		if width > w {
+			return s[:i]
+		}
+	}
+	return s
+}
+
 func padLeft(s string, w int) string {
 	n := lipgloss.Width(s)
 	if n >= w {
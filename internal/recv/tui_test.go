@@ -840,6 +840,38 @@ func TestTUIBookmarkNotSet(t *testing.T) {
 	}
 }
 
+func TestTUIAckAlerts(t *testing.T) {
+	m := newTestModel()
+	m = sendKey(m, "A")
+	if m.alertMsg != "no alert rules" {
+		t.Errorf("alertMsg without engine: got %q", m.alertMsg)
+	}
+
+	engine := NewAlertEngine([]AlertRule{
+		{Name: "disk_full", Metric: "disk_pct", Op: "gt", Threshold: 90},
+		{Name: "drops", Metric: "logs_dropped", Op: "gt", Threshold: 100},
+	}, nil)
+	engine.Evaluate(Snapshot{DiskUsage: 95, DiskCap: 100})
+	m.SetAlertEngine(engine)
+
+	m = sendKey(m, "A")
+	if m.alertMsg != "acked disk_full" {
+		t.Errorf("alertMsg: got %q", m.alertMsg)
+	}
+	if st := engine.Status(); st[0].Silence == nil || !st[0].Silence.Ack || st[1].Silence != nil {
+		t.Errorf("status after ack: %+v", st)
+	}
+	m = sendKey(m, "A")
+	if m.alertMsg != "no unacknowledged alerts firing" {
+		t.Errorf("alertMsg on repeat: got %q", m.alertMsg)
+	}
+
+	m = sendKey(m, "U")
+	if m.alertMsg != "cleared 1 alert silences" || engine.Status()[0].Silence != nil {
+		t.Errorf("after U: alertMsg %q, status %+v", m.alertMsg, engine.Status())
+	}
+}
+
 func containsStr(s, sub string) bool {
 	return len(s) >= len(sub) && searchStr(s, sub)
 }
@@ -0,0 +1,176 @@
+package recv
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// StreamWatermark is the latest persisted entry of one stream.
+type StreamWatermark struct {
+	Session   string            `json:"session,omitempty"`
+	Stream    string            `json:"stream"` // labels other than session, sorted
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp time.Time         `json:"ts"`      // newest entry timestamp written
+	Lines     int64             `json:"lines"`   // entries written for the stream
+	Updated   time.Time         `json:"updated"` // wall clock of the last write
+}
+
+// Watermarks tracks, per session and stream, the newest entry timestamp the
+// writer has handed to the capture file, or with a sync policy (see
+// Writer.SetSync) synced to it. Producers poll it to wait until everything
+// up to a point in time is captured before tearing down. All methods are
+// safe for concurrent use.
+type Watermarks struct {
+	mu      sync.Mutex
+	streams map[string]map[string]*StreamWatermark // session → stream key → watermark
+	now     func() time.Time
+}
+
+// NewWatermarks creates an empty tracker.
+func NewWatermarks() *Watermarks {
+	return &Watermarks{
+		streams: make(map[string]map[string]*StreamWatermark),
+		now:     time.Now,
+	}
+}
+
+// Record notes that an entry with ts and labels was written.
+func (w *Watermarks) Record(ts time.Time, labels map[string]string) {
+	session := labels["session"]
+	key := streamKey(labels)
+	now := w.now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	bySession := w.streams[session]
+	if bySession == nil {
+		bySession = make(map[string]*StreamWatermark)
+		w.streams[session] = bySession
+	}
+	wm := bySession[key]
+	if wm == nil {
+		wm = &StreamWatermark{Session: session, Stream: key, Labels: streamLabels(labels)}
+		bySession[key] = wm
+	}
+	if ts.After(wm.Timestamp) {
+		wm.Timestamp = ts
+	}
+	wm.Lines++
+	wm.Updated = now
+}
+
+// absorb moves the streams of from into w, keeping the newer timestamp and
+// update and adding up lines, and leaves from empty.
+func (w *Watermarks) absorb(from *Watermarks) {
+	from.mu.Lock()
+	streams := from.streams
+	from.streams = make(map[string]map[string]*StreamWatermark)
+	from.mu.Unlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for session, pending := range streams {
+		bySession := w.streams[session]
+		if bySession == nil {
+			w.streams[session] = pending
+			continue
+		}
+		for key, p := range pending {
+			wm := bySession[key]
+			if wm == nil {
+				bySession[key] = p
+				continue
+			}
+			if p.Timestamp.After(wm.Timestamp) {
+				wm.Timestamp = p.Timestamp
+			}
+			if p.Updated.After(wm.Updated) {
+				wm.Updated = p.Updated
+			}
+			wm.Lines += p.Lines
+		}
+	}
+}
+
+// Streams returns the watermarks of session sorted by stream, or of all
+// sessions when session is empty.
+func (w *Watermarks) Streams(session string) []StreamWatermark {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	out := []StreamWatermark{}
+	for s, bySession := range w.streams {
+		if session != "" && s != session {
+			continue
+		}
+		for _, wm := range bySession {
+			out = append(out, *wm)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Session != out[j].Session {
+			return out[i].Session < out[j].Session
+		}
+		return out[i].Stream < out[j].Stream
+	})
+	return out
+}
+
+// Expire removes the streams not written to for idle and returns their
+// final watermarks, oldest update first. Sessions left without streams are
+// dropped too.
+func (w *Watermarks) Expire(idle time.Duration) []StreamWatermark {
+	cutoff := w.now().Add(-idle)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var expired []StreamWatermark
+	for session, bySession := range w.streams {
+		for key, wm := range bySession {
+			if wm.Updated.Before(cutoff) {
+				expired = append(expired, *wm)
+				delete(bySession, key)
+			}
+		}
+		if len(bySession) == 0 {
+			delete(w.streams, session)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Updated.Before(expired[j].Updated) })
+	return expired
+}
+
+// Len returns the number of streams tracked.
+func (w *Watermarks) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, bySession := range w.streams {
+		n += len(bySession)
+	}
+	return n
+}
+
+// LowWatermark returns the oldest of the stream watermarks: every stream has
+// been captured at least up to this time. Zero when there are no streams.
+func LowWatermark(streams []StreamWatermark) time.Time {
+	var low time.Time
+	for i, s := range streams {
+		if i == 0 || s.Timestamp.Before(low) {
+			low = s.Timestamp
+		}
+	}
+	return low
+}
+
+// streamLabels copies labels without the session label.
+func streamLabels(labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k != "session" {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package recv
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatermarks_Record(t *testing.T) {
+	wm := NewWatermarks()
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	api := map[string]string{"session": "lt-1", "pod": "api-0", "container": "app"}
+	worker := map[string]string{"session": "lt-1", "pod": "worker-0", "container": "app"}
+	other := map[string]string{"session": "lt-2", "pod": "api-0", "container": "app"}
+
+	wm.Record(base.Add(2*time.Second), api)
+	wm.Record(base.Add(1*time.Second), api) // out of order: watermark stays
+	wm.Record(base, worker)
+	wm.Record(base.Add(time.Minute), other)
+
+	streams := wm.Streams("lt-1")
+	if len(streams) != 2 {
+		t.Fatalf("streams = %+v, want 2", streams)
+	}
+	if streams[0].Stream != "container=app,pod=api-0" || !streams[0].Timestamp.Equal(base.Add(2*time.Second)) || streams[0].Lines != 2 {
+		t.Errorf("api stream = %+v", streams[0])
+	}
+	if _, ok := streams[0].Labels["session"]; ok {
+		t.Error("stream labels should not repeat the session")
+	}
+	if low := LowWatermark(streams); !low.Equal(base) {
+		t.Errorf("low = %v, want %v", low, base)
+	}
+
+	if all := wm.Streams(""); len(all) != 3 || all[2].Session != "lt-2" {
+		t.Errorf("all streams = %+v", all)
+	}
+	if none := wm.Streams("lt-9"); len(none) != 0 || !LowWatermark(none).IsZero() {
+		t.Errorf("unknown session = %+v", none)
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriter_WatermarkSkipsFailedWrites(t *testing.T) {
+	w := NewWriter(16, failWriter{}, nil)
+	w.Send(LogEntry{Timestamp: time.Now(), Labels: map[string]string{"session": "s"}, Message: "x"})
+	w.Close()
+	if streams := w.Watermarks().Streams(""); len(streams) != 0 {
+		t.Errorf("failed write recorded a watermark: %+v", streams)
+	}
+}
+
+func TestWriter_WatermarkWaitsForSync(t *testing.T) {
+	var buf syncBuffer
+	w := NewWriter(16, &buf, nil)
+	if err := w.SetSync(time.Hour, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Send(LogEntry{Timestamp: time.Now(), Labels: map[string]string{"session": "s"}, Message: "x"})
+	for w.LinesWritten() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if streams := w.Watermarks().Streams(""); len(streams) != 0 {
+		t.Errorf("unsynced write recorded a watermark: %+v", streams)
+	}
+	w.Close() // syncs a last time
+	if streams := w.Watermarks().Streams("s"); len(streams) != 1 || streams[0].Lines != 1 {
+		t.Errorf("synced streams = %+v, want one with 1 line", streams)
+	}
+}
+
+func TestWatermarkEndpoint(t *testing.T) {
+	w := NewWriter(1024, io.Discard, nil)
+	defer w.Close()
+	srv := NewServer(":0", w, nil, nil, nil, nil)
+	ts := httptest.NewServer(srv.httpSrv.Handler)
+	defer ts.Close()
+
+	payload := `{"streams":[
+		{"stream":{"session":"lt-a","pod":"api-0"},"values":[["1700000000000000000","a"],["1700000005000000000","b"]]},
+		{"stream":{"session":"lt-a","pod":"api-1"},"values":[["1700000003000000000","c"]]},
+		{"stream":{"session":"lt-b","pod":"api-0"},"values":[["1700000009000000000","d"]]}
+	]}`
+	resp, err := http.Post(ts.URL+"/loki/api/v1/push", "application/json", strings.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	var got watermarkResponse
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := http.Get(ts.URL + "/api/v1/watermark?session=lt-a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type = %q", resp.Header.Get("Content-Type"))
+		}
+		got = watermarkResponse{}
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Streams) == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got.Session != "lt-a" || len(got.Streams) != 2 {
+		t.Fatalf("response = %+v", got)
+	}
+	if !got.Low.Equal(time.Unix(1700000003, 0)) {
+		t.Errorf("low = %v, want %v", got.Low, time.Unix(1700000003, 0))
+	}
+	if !got.Streams[0].Timestamp.Equal(time.Unix(1700000005, 0)) || got.Streams[0].Lines != 2 {
+		t.Errorf("api-0 = %+v", got.Streams[0])
+	}
+}
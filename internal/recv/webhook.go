@@ -15,6 +15,10 @@ import (
 
 const webhookTimeout = 5 * time.Second
 
+// WebhookEvents are the events a receiver fires, the values accepted by
+// recv --webhook-events.
+var WebhookEvents = []string{"start", "stop", "rotation", "error", "disk-warning", "duplicate-stream", "client-version"}
+
 // WebhookEvent is the JSON payload sent to webhook URLs.
 type WebhookEvent struct {
 	Event     string        `json:"event"`
@@ -30,6 +34,8 @@ type WebhookStats struct {
 	BytesWritten int64 `json:"bytes_written"`
 	DiskUsage    int64 `json:"disk_usage"`
 	DiskCap      int64 `json:"disk_cap"`
+
+	Shutdown *ShutdownInfo `json:"shutdown,omitempty"` // stop event only
 }
 
 // WebhookDispatcher sends fire-and-forget HTTP POST notifications.
@@ -39,6 +45,7 @@ type WebhookDispatcher struct {
 	client    *http.Client
 	authMode  string
 	authValue string
+	onDeliver func(WebhookDelivery)
 }
 
 // ParseWebhookAuth validates and splits an auth spec into mode and value.
@@ -109,33 +116,56 @@ func (d *WebhookDispatcher) Fire(evt WebhookEvent) {
 	}
 
 	for _, url := range d.urls {
-		go d.post(url, data)
+		go d.post(url, evt.Event, data)
+	}
+}
+
+// SetOnDeliver sets a callback invoked after each delivery attempt, from
+// the delivering goroutine.
+func (d *WebhookDispatcher) SetOnDeliver(fn func(WebhookDelivery)) {
+	if d == nil {
+		return
 	}
+	d.onDeliver = fn
 }
 
-func (d *WebhookDispatcher) post(url string, data []byte) {
+func (d *WebhookDispatcher) post(url, event string, data []byte) {
+	start := time.Now()
+	status, err := d.send(url, data)
+	if d.onDeliver != nil {
+		d.onDeliver(WebhookDelivery{URL: url, Event: event, Status: status, Err: err, Latency: time.Since(start)})
+	}
+}
+
+func (d *WebhookDispatcher) send(url string, data []byte) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
 	if err != nil {
-		return
+		return 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	setWebhookAuth(req, d.authMode, d.authValue, data)
 
-	switch d.authMode {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// setWebhookAuth authenticates a request whose body is data, per a mode and
+// value from ParseWebhookAuth.
+func setWebhookAuth(req *http.Request, mode, value string, data []byte) {
+	switch mode {
 	case "bearer":
-		req.Header.Set("Authorization", "Bearer "+d.authValue)
+		req.Header.Set("Authorization", "Bearer "+value)
 	case "hmac-sha256":
-		mac := hmac.New(sha256.New, []byte(d.authValue))
+		mac := hmac.New(sha256.New, []byte(value))
 		_, _ = mac.Write(data)
 		sig := hex.EncodeToString(mac.Sum(nil))
 		req.Header.Set("X-Logtap-Signature", "sha256="+sig)
 	}
-
-	resp, err := d.client.Do(req)
-	if err != nil {
-		return
-	}
-	_ = resp.Body.Close()
 }
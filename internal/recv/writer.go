@@ -7,6 +7,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // LogEntry represents a single parsed log line.
@@ -14,12 +16,33 @@ type LogEntry struct {
 	Timestamp time.Time         `json:"ts"`
 	Labels    map[string]string `json:"labels,omitempty"`
 	Message   string            `json:"msg"`
+
+	// RepeatCount is the number of identical lines this entry stands for
+	// when recv --dedup-window collapsed repeats; 0 means one.
+	RepeatCount int64 `json:"repeat_count,omitempty"`
+}
+
+// Count returns the number of lines the entry stands for.
+func (e LogEntry) Count() int64 {
+	if e.RepeatCount > 1 {
+		return e.RepeatCount
+	}
+	return 1
+}
+
+// queuedEntry is an entry waiting in the writer queue, with the time it
+// was received and the trace of its push, if any.
+type queuedEntry struct {
+	entry    LogEntry
+	received time.Time
+	trace    *ingestTrace
 }
 
 // Writer drains LogEntry from a bounded channel and writes JSONL to a destination.
 type Writer struct {
-	ch     chan LogEntry
+	ch     chan queuedEntry
 	dst    io.Writer
+	ldst   LabeledWriter                      // set instead of dst by NewLabeledWriter
 	track  func(time.Time, map[string]string) // called per line for index tracking
 	done   chan struct{}
 	wg     sync.WaitGroup
@@ -27,18 +50,76 @@ type Writer struct {
 
 	bytesWritten atomic.Int64
 	linesWritten atomic.Int64
+	precision    precisionTracker
 
 	queueGauge func(float64) // optional callback to report queue length
+	latency    func(time.Duration, trace.SpanContext)
+	watermarks *Watermarks
+
+	dedup        atomic.Pointer[deduper]
+	deduplicated atomic.Int64
+	onDedup      func(n int64)
+
+	sync atomic.Pointer[syncPolicy]
+
+	deadline atomic.Int64 // unix nanoseconds past which Close drops queued entries (0 = none)
+	flushed  atomic.Int64
+	dropped  atomic.Int64
+}
+
+// DrainResult counts what became of the entries still queued when a writer
+// was closed; like LinesWritten, an entry counts once whatever its
+// RepeatCount.
+type DrainResult struct {
+	Flushed int64 // written before the deadline
+	Dropped int64 // discarded at the deadline
+}
+
+// Syncer is implemented by destinations that can commit written lines to
+// stable storage, such as rotators.
+type Syncer interface {
+	Sync() error
+}
+
+// syncPolicy is when the writer commits written lines to stable storage.
+type syncPolicy struct {
+	dst      Syncer
+	tick     *time.Ticker // nil without an interval
+	perBatch bool
+	onSync   func(took time.Duration, err error)
+	synced   *Watermarks // the writer's watermarks
+	pending  *Watermarks // lines written since the last successful sync
+}
+
+// LabeledWriter receives each JSONL line together with its timestamp and
+// labels, so it can route and index lines itself (e.g. a sharded rotator).
+type LabeledWriter interface {
+	WriteLabeled(p []byte, ts time.Time, labels map[string]string) (int, error)
 }
 
 // NewWriter creates a Writer with the given buffer size.
 // dst receives JSONL output; track is called per line for metadata tracking (may be nil).
 func NewWriter(bufSize int, dst io.Writer, track func(time.Time, map[string]string)) *Writer {
 	w := &Writer{
-		ch:    make(chan LogEntry, bufSize),
-		dst:   dst,
-		track: track,
-		done:  make(chan struct{}),
+		ch:         make(chan queuedEntry, bufSize),
+		dst:        dst,
+		track:      track,
+		done:       make(chan struct{}),
+		watermarks: NewWatermarks(),
+	}
+	w.wg.Add(1)
+	go w.drain()
+	return w
+}
+
+// NewLabeledWriter creates a Writer whose lines go to dst along with their
+// timestamp and labels.
+func NewLabeledWriter(bufSize int, dst LabeledWriter) *Writer {
+	w := &Writer{
+		ch:         make(chan queuedEntry, bufSize),
+		ldst:       dst,
+		done:       make(chan struct{}),
+		watermarks: NewWatermarks(),
 	}
 	w.wg.Add(1)
 	go w.drain()
@@ -50,11 +131,65 @@ func (w *Writer) SetQueueGauge(fn func(float64)) {
 	w.queueGauge = fn
 }
 
+// SetLatencyObserver sets a callback called with the time each entry took
+// from being received to being written (or folded by dedup), and the span
+// of its push when it was traced. Call it before the first Send.
+func (w *Writer) SetLatencyObserver(fn func(time.Duration, trace.SpanContext)) {
+	w.latency = fn
+}
+
+// SetDedup collapses identical lines (same labels and message) arriving
+// within window of the first into that entry, with the number of lines in
+// its RepeatCount. Held entries are written when their window ends, so
+// they can land after later lines. onDedup, if set, is called with the
+// lines folded away. Call it before the first Send.
+func (w *Writer) SetDedup(window time.Duration, onDedup func(n int64)) {
+	if window <= 0 {
+		return
+	}
+	w.onDedup = onDedup
+	w.dedup.Store(newDeduper(window))
+}
+
+// SetSync makes the writer sync its destination every interval (0 = no
+// interval), and with perBatch also whenever it has written every queued
+// entry, so lines survive a crash of the host once synced. Watermarks then
+// advance only when a sync succeeds, and a last sync runs on close. onSync,
+// if set, is called after each sync. The destination must implement
+// Syncer. Call it before the first Send.
+func (w *Writer) SetSync(interval time.Duration, perBatch bool, onSync func(took time.Duration, err error)) error {
+	if interval <= 0 && !perBatch {
+		return nil
+	}
+	var dst any = w.dst
+	if w.ldst != nil {
+		dst = w.ldst
+	}
+	syncer, ok := dst.(Syncer)
+	if !ok {
+		return fmt.Errorf("destination %T cannot sync", dst)
+	}
+	p := &syncPolicy{dst: syncer, perBatch: perBatch, onSync: onSync, synced: w.watermarks, pending: NewWatermarks()}
+	if interval > 0 {
+		p.tick = time.NewTicker(interval)
+	}
+	w.sync.Store(p)
+	return nil
+}
+
+// Deduplicated returns the number of lines folded into earlier identical
+// entries.
+func (w *Writer) Deduplicated() int64 { return w.deduplicated.Load() }
+
 // Send attempts a non-blocking send of entry to the writer channel.
 // Returns false if the channel is full (caller should count as dropped).
 func (w *Writer) Send(entry LogEntry) bool {
+	return w.send(queuedEntry{entry: entry, received: time.Now()})
+}
+
+func (w *Writer) send(q queuedEntry) bool {
 	select {
-	case w.ch <- entry:
+	case w.ch <- q:
 		w.reportQueue()
 		return true
 	default:
@@ -76,35 +211,155 @@ func (w *Writer) Close() {
 	}
 }
 
+// CloseBy is Close with a deadline: entries still queued at deadline are
+// discarded instead of written. The entry being written when the deadline
+// passes is finished. A zero deadline writes every queued entry, as Close
+// does. It reports the queued lines flushed and dropped.
+func (w *Writer) CloseBy(deadline time.Time) DrainResult {
+	if !deadline.IsZero() {
+		w.deadline.Store(deadline.UnixNano())
+	}
+	w.Close()
+	return DrainResult{Flushed: w.flushed.Load(), Dropped: w.dropped.Load()}
+}
+
 // BytesWritten returns total bytes written.
 func (w *Writer) BytesWritten() int64 { return w.bytesWritten.Load() }
 
 // LinesWritten returns total lines written.
 func (w *Writer) LinesWritten() int64 { return w.linesWritten.Load() }
 
+// TimestampPrecision returns the finest precision of the timestamps
+// written (PrecisionNano etc.), or "" before the first line.
+func (w *Writer) TimestampPrecision() string { return w.precision.String() }
+
+// Queued returns the number of entries waiting to be written.
+func (w *Writer) Queued() int { return len(w.ch) }
+
+// QueueCap returns the capacity of the writer channel.
+func (w *Writer) QueueCap() int { return cap(w.ch) }
+
+// Watermarks returns the per-stream watermarks of written entries, or of
+// synced entries under SetSync.
+func (w *Writer) Watermarks() *Watermarks { return w.watermarks }
+
 // Healthy returns true if the writer channel has capacity (not in backpressure).
 func (w *Writer) Healthy() bool { return len(w.ch) < cap(w.ch) }
 
 func (w *Writer) drain() {
 	defer w.wg.Done()
 	for {
+		var tick, syncTick <-chan time.Time
+		d := w.dedup.Load()
+		if d != nil {
+			tick = d.tick.C
+		}
+		sp := w.sync.Load()
+		if sp != nil && sp.tick != nil {
+			syncTick = sp.tick.C
+		}
+		// a close wins over queued entries, so its deadline holds
+		select {
+		case <-w.done:
+			w.finish(d, sp)
+			return
+		default:
+		}
 		select {
-		case entry := <-w.ch:
-			w.writeLine(entry)
+		case q := <-w.ch:
+			w.writeQueued(q)
 			w.reportQueue()
+			if sp != nil && sp.perBatch && len(w.ch) == 0 {
+				sp.run()
+			}
+		case <-tick:
+			for _, e := range d.expire() {
+				w.writeLine(e)
+			}
+		case <-syncTick:
+			sp.run()
 		case <-w.done:
-			// drain remaining
-			for {
-				select {
-				case entry := <-w.ch:
-					w.writeLine(entry)
-					w.reportQueue()
-				default:
-					return
+			w.finish(d, sp)
+			return
+		}
+	}
+}
+
+// finish writes the entries still queued on close, dropping those left at
+// the deadline, then flushes the deduper and runs a last sync.
+func (w *Writer) finish(d *deduper, sp *syncPolicy) {
+	deadline := w.deadline.Load()
+	for {
+		select {
+		case q := <-w.ch:
+			if deadline != 0 && time.Now().UnixNano() >= deadline {
+				w.dropped.Add(1)
+				if q.trace != nil {
+					q.trace.unqueued()
 				}
+				continue
 			}
+			w.writeQueued(q)
+			w.flushed.Add(1)
+			w.reportQueue()
+		default:
+			if d != nil {
+				d.tick.Stop()
+				for _, e := range d.flush() {
+					w.writeLine(e)
+				}
+			}
+			if sp != nil {
+				if sp.tick != nil {
+					sp.tick.Stop()
+				}
+				sp.run()
+			}
+			return
+		}
+	}
+}
+
+func (p *syncPolicy) run() {
+	start := time.Now()
+	err := p.dst.Sync()
+	if err == nil {
+		p.synced.absorb(p.pending)
+	}
+	if p.onSync != nil {
+		p.onSync(time.Since(start), err)
+	}
+}
+
+// writeQueued writes a queued entry and reports how long it took to get
+// there.
+func (w *Writer) writeQueued(q queuedEntry) {
+	w.write(q.entry)
+	var sc trace.SpanContext
+	if q.trace != nil {
+		sc = trace.SpanContextFromContext(q.trace.ctx)
+		q.trace.written()
+	}
+	if w.latency != nil {
+		w.latency(time.Since(q.received), sc)
+	}
+}
+
+// write passes entry through the deduper, if any, and writes what is due.
+func (w *Writer) write(entry LogEntry) {
+	if d := w.dedup.Load(); d != nil {
+		folded, held := d.add(entry)
+		if folded > 0 {
+			w.deduplicated.Add(folded)
+			if w.onDedup != nil {
+				w.onDedup(folded)
+			}
+		}
+		if held {
+			return
 		}
 	}
+	w.writeLine(entry)
 }
 
 func (w *Writer) writeLine(entry LogEntry) {
@@ -113,10 +368,23 @@ func (w *Writer) writeLine(entry LogEntry) {
 		return
 	}
 	line := fmt.Sprintf("%s\n", data)
-	n, _ := io.WriteString(w.dst, line)
+	var n int
+	if w.ldst != nil {
+		n, err = w.ldst.WriteLabeled([]byte(line), entry.Timestamp, entry.Labels)
+	} else {
+		n, err = io.WriteString(w.dst, line)
+	}
 	w.bytesWritten.Add(int64(n))
 	w.linesWritten.Add(1)
+	w.precision.record(entry.Timestamp)
 	if w.track != nil {
 		w.track(entry.Timestamp, entry.Labels)
 	}
+	if err == nil {
+		if sp := w.sync.Load(); sp != nil {
+			sp.pending.Record(entry.Timestamp, entry.Labels)
+		} else {
+			w.watermarks.Record(entry.Timestamp, entry.Labels)
+		}
+	}
 }
@@ -2,6 +2,8 @@ package recv
 
 import (
 	"bytes"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -80,3 +82,134 @@ func TestWriterDoubleClose(t *testing.T) {
 	w.Close()
 	w.Close() // should not panic
 }
+
+type labeledBuffer struct {
+	bytes.Buffer
+	labels []map[string]string
+}
+
+func (b *labeledBuffer) WriteLabeled(p []byte, _ time.Time, labels map[string]string) (int, error) {
+	b.labels = append(b.labels, labels)
+	return b.Write(p)
+}
+
+func TestLabeledWriter(t *testing.T) {
+	var dst labeledBuffer
+	w := NewLabeledWriter(64, &dst)
+	w.Send(LogEntry{Timestamp: time.Now(), Labels: map[string]string{"app": "api"}, Message: "routed"})
+	w.Close()
+
+	if len(dst.labels) != 1 || dst.labels[0]["app"] != "api" {
+		t.Errorf("labels = %v, want one line labelled app=api", dst.labels)
+	}
+	if !bytes.Contains(dst.Bytes(), []byte(`"msg":"routed"`)) {
+		t.Errorf("output = %q", dst.String())
+	}
+	if w.LinesWritten() != 1 || w.BytesWritten() != int64(dst.Len()) {
+		t.Errorf("counters = %d lines %d bytes, want 1 and %d", w.LinesWritten(), w.BytesWritten(), dst.Len())
+	}
+}
+
+// syncBuffer counts syncs of a buffer.
+type syncBuffer struct {
+	bytes.Buffer
+	mu    sync.Mutex
+	syncs int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.syncs++
+	return nil
+}
+
+func (b *syncBuffer) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.syncs
+}
+
+func TestWriterSync(t *testing.T) {
+	var buf syncBuffer
+	w := NewWriter(64, &buf, nil)
+	var synced atomic.Int64
+	if err := w.SetSync(0, true, func(time.Duration, error) { synced.Add(1) }); err != nil {
+		t.Fatal(err)
+	}
+	w.Send(LogEntry{Timestamp: time.Now(), Message: "one"})
+	time.Sleep(50 * time.Millisecond)
+	if buf.count() == 0 || synced.Load() == 0 {
+		t.Errorf("no sync after a batch: syncs %d, callbacks %d", buf.count(), synced.Load())
+	}
+	w.Close()
+
+	var ticked syncBuffer
+	w = NewWriter(64, &ticked, nil)
+	if err := w.SetSync(10*time.Millisecond, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Send(LogEntry{Timestamp: time.Now(), Message: "wake"}) // the drain loop picks up the ticker
+	time.Sleep(80 * time.Millisecond)
+	w.Close()
+	if ticked.count() < 2 {
+		t.Errorf("interval syncs = %d, want several", ticked.count())
+	}
+
+	var plain bytes.Buffer
+	w = NewWriter(64, &plain, nil)
+	defer w.Close()
+	if err := w.SetSync(time.Second, false, nil); err == nil {
+		t.Error("want error for a destination without Sync")
+	}
+}
+
+// gatedWriter blocks every write until its gate is closed.
+type gatedWriter struct {
+	gate chan struct{}
+	buf  bytes.Buffer
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	<-g.gate
+	return g.buf.Write(p)
+}
+
+func TestWriterCloseBy(t *testing.T) {
+	fill := func(dst *gatedWriter) *Writer {
+		w := NewWriter(16, dst, nil)
+		for i := 0; i < 5; i++ {
+			w.Send(LogEntry{Timestamp: time.Now(), Message: "queued"})
+		}
+		// the first entry is in flight, the rest wait in the queue
+		for w.Queued() != 4 {
+			time.Sleep(time.Millisecond)
+		}
+		return w
+	}
+
+	slow := &gatedWriter{gate: make(chan struct{})}
+	w := fill(slow)
+	time.AfterFunc(20*time.Millisecond, func() { close(slow.gate) })
+	got := w.CloseBy(time.Now())
+	if got.Flushed != 0 || got.Dropped != 4 {
+		t.Errorf("past deadline: %+v, want 0 flushed, 4 dropped", got)
+	}
+	if w.LinesWritten() != 1 {
+		t.Errorf("lines written = %d, want the in-flight one", w.LinesWritten())
+	}
+
+	open := &gatedWriter{gate: make(chan struct{})}
+	w = fill(open)
+	close(open.gate)
+	if got := w.CloseBy(time.Now().Add(time.Minute)); got.Dropped != 0 || w.LinesWritten() != 5 {
+		t.Errorf("within deadline: %+v, %d written", got, w.LinesWritten())
+	}
+
+	unbounded := &gatedWriter{gate: make(chan struct{})}
+	w = fill(unbounded)
+	time.AfterFunc(20*time.Millisecond, func() { close(unbounded.gate) })
+	if got := w.CloseBy(time.Time{}); got.Flushed != 4 || got.Dropped != 0 || w.LinesWritten() != 5 {
+		t.Errorf("without deadline: %+v, %d written", got, w.LinesWritten())
+	}
+}
@@ -0,0 +1,208 @@
+package rotate
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// BloomSuffix is appended to the name of a data file for its token bloom
+// filter sidecar, e.g. 2024-01-15T100000-000.jsonl.zst.bloom.
+const BloomSuffix = ".bloom"
+
+// BloomTokenLen is the length of the tokens in a bloom filter: every
+// overlapping run of that many bytes of a message, ASCII lowercased. Any
+// substring at least this long is only in a message if all its tokens are.
+const BloomTokenLen = 3
+
+// maxBloomTokens caps the distinct tokens tracked per file; a file past it
+// gets no bloom filter, since it holds most tokens anyway.
+const maxBloomTokens = 1 << 20
+
+const (
+	bloomMagic      = "LTB1"
+	bloomBitsPerKey = 10 // about 1% false positives
+	bloomHashes     = 7
+)
+
+// Bloom is the bloom filter of the message tokens of a data file. It can
+// tell that a file holds no message with a given substring.
+type Bloom struct {
+	k    uint8
+	bits []uint64
+}
+
+// MayContain reports whether the data file may hold a message containing
+// s. It is false only when s is at least BloomTokenLen bytes long and one
+// of its tokens is in no message. Matching is ASCII case-insensitive.
+func (b *Bloom) MayContain(s string) bool {
+	if b == nil || len(s) < BloomTokenLen {
+		return true
+	}
+	s = foldToken(s)
+	for i := 0; i+BloomTokenLen <= len(s); i++ {
+		if !b.has(token(s[i:])) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Bloom) has(t uint32) bool {
+	m := uint64(len(b.bits)) * 64
+	h1, h2 := bloomHash(t)
+	for i := range uint64(b.k) {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Bloom) set(t uint32) {
+	m := uint64(len(b.bits)) * 64
+	h1, h2 := bloomHash(t)
+	for i := range uint64(b.k) {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// bloomHash derives the two hashes of double hashing from a token
+// (splitmix64 finalizer).
+func bloomHash(t uint32) (uint64, uint64) {
+	h := uint64(t) + 0x9e3779b97f4a7c15
+	h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+	h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+	h ^= h >> 31
+	return h, h>>32 | 1
+}
+
+// token packs the first BloomTokenLen bytes of s.
+func token(s string) uint32 {
+	return uint32(s[0])<<16 | uint32(s[1])<<8 | uint32(s[2])
+}
+
+// foldRunes maps the two non-ASCII runes that case-fold to ASCII letters.
+var foldRunes = strings.NewReplacer("\u212a", "k", "\u017f", "s")
+
+// foldToken lowercases ASCII letters of s after foldRunes, so that a
+// case-insensitive match implies a match of the folded tokens.
+func foldToken(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			s = foldRunes.Replace(s)
+			break
+		}
+	}
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; 'A' <= c && c <= 'Z' {
+			if buf == nil {
+				buf = []byte(s)
+			}
+			buf[i] = c + 'a' - 'A'
+		}
+	}
+	if buf == nil {
+		return s
+	}
+	return string(buf)
+}
+
+// BloomBuilder collects the message tokens of a data file for its Bloom.
+type BloomBuilder struct {
+	tokens   map[uint32]struct{}
+	overflow bool
+}
+
+// NewBloomBuilder returns an empty BloomBuilder.
+func NewBloomBuilder() *BloomBuilder {
+	return &BloomBuilder{tokens: make(map[uint32]struct{})}
+}
+
+// AddLine adds the message of a JSONL line of the data file.
+func (bb *BloomBuilder) AddLine(line []byte) {
+	if msg, ok := lineMessage(line); ok {
+		bb.addMessage(msg)
+	}
+}
+
+func (bb *BloomBuilder) addMessage(msg string) {
+	if bb.overflow || len(msg) < BloomTokenLen {
+		return
+	}
+	msg = foldToken(msg)
+	for i := 0; i+BloomTokenLen <= len(msg); i++ {
+		bb.tokens[token(msg[i:])] = struct{}{}
+	}
+	if len(bb.tokens) > maxBloomTokens {
+		bb.overflow = true
+		bb.tokens = nil
+	}
+}
+
+// Bloom returns the bloom filter of the tokens added, or nil when there
+// were too many to be worth one.
+func (bb *BloomBuilder) Bloom() *Bloom {
+	if bb.overflow {
+		return nil
+	}
+	words := (len(bb.tokens)*bloomBitsPerKey + 63) / 64
+	b := &Bloom{k: bloomHashes, bits: make([]uint64, max(words, 1))}
+	for t := range bb.tokens {
+		b.set(t)
+	}
+	return b
+}
+
+// lineMessage returns the message of a JSONL line.
+func lineMessage(line []byte) (string, bool) {
+	var e struct {
+		Message string `json:"msg"`
+	}
+	if json.Unmarshal(line, &e) != nil {
+		return "", false
+	}
+	return e.Message, true
+}
+
+// ReadBloom reads the bloom filter of data file name in dir. It returns
+// nil without error when the file has none.
+func ReadBloom(dir, name string) (*Bloom, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name+BloomSuffix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	n := len(bloomMagic) + 1
+	if len(data) < n+8 || string(data[:len(bloomMagic)]) != bloomMagic || (len(data)-n)%8 != 0 {
+		return nil, errors.New("invalid bloom filter")
+	}
+	b := &Bloom{k: data[len(bloomMagic)], bits: make([]uint64, (len(data)-n)/8)}
+	if b.k == 0 {
+		return nil, errors.New("invalid bloom filter")
+	}
+	for i := range b.bits {
+		b.bits[i] = binary.LittleEndian.Uint64(data[n+i*8:])
+	}
+	return b, nil
+}
+
+// WriteBloom writes b as the bloom filter of data file name in dir.
+func WriteBloom(dir, name string, b *Bloom, durable bool) error {
+	data := make([]byte, 0, len(bloomMagic)+1+len(b.bits)*8)
+	data = append(data, bloomMagic...)
+	data = append(data, b.k)
+	for _, w := range b.bits {
+		data = binary.LittleEndian.AppendUint64(data, w)
+	}
+	return writeFile(filepath.Join(dir, name+BloomSuffix), data, durable)
+}
@@ -0,0 +1,119 @@
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBloomMayContain(t *testing.T) {
+	bb := NewBloomBuilder()
+	bb.addMessage("Connection refused by upstream")
+	bb.addMessage("temperature 300\u212a")
+	b := bb.Bloom()
+
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"refused", true},
+		{"CONNECTION REFUSED", true}, // ASCII case folded
+		{"by up", true},
+		{"300k", true}, // KELVIN SIGN folds to k
+		{"ab", true},   // shorter than a token
+		{"", true},
+		{"timeout", false},
+		{"refusedx", false},
+	}
+	for _, tt := range tests {
+		if got := b.MayContain(tt.s); got != tt.want {
+			t.Errorf("MayContain(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+	if !(*Bloom)(nil).MayContain("timeout") {
+		t.Error("nil bloom filter rules out")
+	}
+}
+
+func TestBloomFalsePositives(t *testing.T) {
+	bb := NewBloomBuilder()
+	for i := range 2000 {
+		bb.addMessage(fmt.Sprintf("request %d served", i*7919))
+	}
+	b := bb.Bloom()
+	var fp int
+	for i := range 1000 {
+		if b.MayContain(fmt.Sprintf("zq%cx", 'a'+i%26)) {
+			fp++
+		}
+	}
+	if fp > 50 {
+		t.Errorf("%d/1000 false positives", fp)
+	}
+}
+
+func TestBloomSidecar(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 30, Compress: true, GrepBloom: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFieldLines(t, r, "upstream timed out", "cache miss")
+	if _, err := r.Write([]byte(`{"ts":"2024-01-15T10:00:00Z","msg":"unlabeled line"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	index := readIndex(t, dir)
+	if len(index) != 1 {
+		t.Fatalf("index = %+v", index)
+	}
+	b, err := ReadBloom(dir, index[0].File)
+	if err != nil || b == nil {
+		t.Fatalf("ReadBloom = %v, %v", b, err)
+	}
+	for _, s := range []string{"timed out", "cache", "unlabeled"} {
+		if !b.MayContain(s) {
+			t.Errorf("MayContain(%q) = false", s)
+		}
+	}
+	if b.MayContain("deadlock") {
+		t.Error("MayContain(deadlock) = true")
+	}
+
+	// files without a bloom filter may contain anything
+	if b, err := ReadBloom(dir, "2024-01-15T100000-999.jsonl"); err != nil || b != nil {
+		t.Errorf("missing sidecar = %v, %v", b, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.jsonl"+BloomSuffix), []byte("junk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBloom(dir, "bad.jsonl"); err == nil {
+		t.Error("ReadBloom accepted a corrupt filter")
+	}
+}
+
+func TestBloomRecovered(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 30, GrepBloom: true}
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFieldLines(t, r, "panic: nil map")
+	name := r.active.name
+	_ = r.active.file.Close() // crash
+
+	r, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	b, err := ReadBloom(dir, name)
+	if err != nil || b == nil || !b.MayContain("nil map") {
+		t.Fatalf("recovered bloom filter = %v, %v", b, err)
+	}
+}
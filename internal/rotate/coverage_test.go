@@ -213,6 +213,13 @@ func TestPruneIndexWithEntries(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// New indexes the last, untracked file, so read the index after it
+	r2, err := New(Config{Dir: dir, MaxFile: 4096, MaxDisk: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r2.Close() }()
+
 	entries := readIndex(t, dir)
 	if len(entries) < 2 {
 		t.Fatalf("need at least 2 entries, got %d", len(entries))
@@ -220,11 +227,6 @@ func TestPruneIndexWithEntries(t *testing.T) {
 
 	// prune first entry
 	deleted := map[string]bool{entries[0].File: true}
-	r2, err := New(Config{Dir: dir, MaxFile: 4096, MaxDisk: 1 << 20})
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer func() { _ = r2.Close() }()
 
 	if err := r2.pruneIndex(deleted); err != nil {
 		t.Fatal(err)
@@ -0,0 +1,240 @@
+package rotate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// FieldIndexSuffix is appended to the name of an indexed data file for its
+// field index sidecar, e.g. 2024-01-15T100000-000.jsonl.zst.fidx.
+const FieldIndexSuffix = ".fidx"
+
+// MaxFieldValue is the longest field value indexed. Longer values are left
+// out, so lookups of them must scan.
+const MaxFieldValue = 256
+
+// maxFieldValues caps the distinct values indexed per field and file; a
+// field past it is given up on for that file.
+const maxFieldValues = 1 << 20
+
+// FieldIndex is the inverted index of a data file over fields of its JSON
+// messages: for each field configured with Config.IndexFields, the number
+// of lines per value. Fields without any value in the file are listed with
+// no values, so readers can tell them from fields not indexed.
+type FieldIndex struct {
+	Fields   map[string]map[string]int64 `json:"fields"`
+	Overflow []string                    `json:"overflow,omitempty"` // fields with too many values to index
+}
+
+func newFieldIndex(fields []string) *FieldIndex {
+	fi := &FieldIndex{Fields: make(map[string]map[string]int64, len(fields))}
+	for _, f := range fields {
+		fi.Fields[f] = make(map[string]int64)
+	}
+	return fi
+}
+
+// MayContain reports whether the data file may hold a line whose field has
+// value. It is false only when the field was indexed and the value is not
+// in the file.
+func (fi *FieldIndex) MayContain(field, value string) bool {
+	if fi == nil || len(value) > MaxFieldValue || slices.Contains(fi.Overflow, field) {
+		return true
+	}
+	values, ok := fi.Fields[field]
+	if !ok {
+		return true
+	}
+	return values[value] > 0
+}
+
+func (fi *FieldIndex) add(field, value string) {
+	if len(value) > MaxFieldValue || slices.Contains(fi.Overflow, field) {
+		return
+	}
+	values := fi.Fields[field]
+	if values[value] == 0 && len(values) >= maxFieldValues {
+		fi.Overflow = append(fi.Overflow, field)
+		fi.Fields[field] = map[string]int64{}
+		return
+	}
+	values[value]++
+}
+
+// Merge adds the counts of other, the field index of another file, for a
+// file holding the lines of both. Fields indexed in only one of them are
+// dropped, since the other file may hold any value.
+func (fi *FieldIndex) Merge(other *FieldIndex) {
+	for field, values := range fi.Fields {
+		more, ok := other.Fields[field]
+		if !ok {
+			delete(fi.Fields, field)
+			fi.Overflow = slices.DeleteFunc(fi.Overflow, func(f string) bool { return f == field })
+			continue
+		}
+		if slices.Contains(other.Overflow, field) && !slices.Contains(fi.Overflow, field) {
+			fi.Overflow = append(fi.Overflow, field)
+			fi.Fields[field] = map[string]int64{}
+			continue
+		}
+		for v, n := range more {
+			if values[v] == 0 && len(values) >= maxFieldValues {
+				fi.Overflow = append(fi.Overflow, field)
+				fi.Fields[field] = map[string]int64{}
+				break
+			}
+			values[v] += n
+		}
+	}
+}
+
+// trackMessage adds the fields of one message to the index.
+func (fi *FieldIndex) trackMessage(msg string, fields []string) {
+	for field, value := range ExtractFields(msg, fields) {
+		fi.add(field, value)
+	}
+}
+
+// ExtractFields returns the values of fields in msg when it is a JSON
+// object. A field is a top-level key or a dotted path into nested objects;
+// only string, number and bool values are returned.
+func ExtractFields(msg string, fields []string) map[string]string {
+	trimmed := strings.TrimSpace(msg)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil
+	}
+	var doc map[string]any
+	dec := json.NewDecoder(strings.NewReader(trimmed))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil
+	}
+	var values map[string]string
+	for _, f := range fields {
+		v, ok := fieldScalar(fieldValue(doc, f))
+		if !ok {
+			continue
+		}
+		if values == nil {
+			values = make(map[string]string, len(fields))
+		}
+		values[f] = v
+	}
+	return values
+}
+
+// fieldValue looks up a dotted path in doc, accepting both nested objects
+// and literal dotted keys.
+func fieldValue(doc map[string]any, path string) any {
+	if v, ok := doc[path]; ok {
+		return v
+	}
+	head, rest, ok := strings.Cut(path, ".")
+	for ok {
+		if sub, isMap := doc[head].(map[string]any); isMap {
+			if v := fieldValue(sub, rest); v != nil {
+				return v
+			}
+		}
+		var next string
+		next, rest, ok = strings.Cut(rest, ".")
+		head += "." + next
+	}
+	return nil
+}
+
+func fieldScalar(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case json.Number:
+		return x.String(), true
+	case bool:
+		return strconv.FormatBool(x), true
+	}
+	return "", false
+}
+
+// ReadFieldIndex reads the field index of data file name in dir. It
+// returns nil without error when the file has none.
+func ReadFieldIndex(dir, name string) (*FieldIndex, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name+FieldIndexSuffix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	if data, err = dec.DecodeAll(data, nil); err != nil {
+		return nil, err
+	}
+	var fi FieldIndex
+	if err := json.Unmarshal(data, &fi); err != nil {
+		return nil, err
+	}
+	return &fi, nil
+}
+
+// WriteFieldIndex writes fi as the field index of data file name in dir,
+// zstd compressed.
+func WriteFieldIndex(dir, name string, fi *FieldIndex, durable bool) error {
+	data, err := json.Marshal(fi)
+	if err != nil {
+		return err
+	}
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return err
+	}
+	compressed := enc.EncodeAll(data, nil)
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, name+FieldIndexSuffix), compressed, durable)
+}
+
+// SidecarSuffixes lists the suffixes of the index files kept next to a
+// data file, which are removed and rewritten along with it.
+var SidecarSuffixes = []string{FieldIndexSuffix, BloomSuffix}
+
+// indexSegment sets up the field index and bloom filter of a new segment
+// as configured.
+func (r *Rotator) indexSegment(seg *segment) {
+	if len(r.cfg.IndexFields) > 0 {
+		seg.fields = newFieldIndex(r.cfg.IndexFields)
+	}
+	if r.cfg.GrepBloom {
+		seg.tokens = NewBloomBuilder()
+	}
+}
+
+// writeSidecars writes the field index and bloom filter of seg, now stored
+// as name. Both are written before the index entry, so a file listed in the
+// index has them.
+func (r *Rotator) writeSidecars(seg *segment, name string) error {
+	if seg.fields != nil {
+		if err := WriteFieldIndex(r.cfg.Dir, name, seg.fields, r.cfg.Durable); err != nil {
+			return fmt.Errorf("field index: %w", err)
+		}
+	}
+	if seg.tokens != nil {
+		if b := seg.tokens.Bloom(); b != nil {
+			if err := WriteBloom(r.cfg.Dir, name, b, r.cfg.Durable); err != nil {
+				return fmt.Errorf("bloom filter: %w", err)
+			}
+		}
+	}
+	return nil
+}
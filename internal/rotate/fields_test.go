@@ -0,0 +1,151 @@
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExtractFields(t *testing.T) {
+	msg := `{"trace_id":"abc","status":503,"ok":false,"http":{"method":"GET"},"req.id":"r1","tags":["x"]}`
+	got := ExtractFields(msg, []string{"trace_id", "status", "ok", "http.method", "req.id", "tags", "missing"})
+	want := map[string]string{"trace_id": "abc", "status": "503", "ok": "false", "http.method": "GET", "req.id": "r1"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	for _, msg := range []string{"plain text", `{"trace_id":`, `["trace_id"]`} {
+		if got := ExtractFields(msg, []string{"trace_id"}); got != nil {
+			t.Errorf("%q: got %v, want nil", msg, got)
+		}
+	}
+}
+
+func writeFieldLines(t *testing.T, r *Rotator, msgs ...string) {
+	t.Helper()
+	ts := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for _, msg := range msgs {
+		line := fmt.Sprintf(`{"ts":%q,"labels":{"app":"api"},"msg":%q}`+"\n", ts.Format(time.RFC3339), msg)
+		if _, err := r.WriteLabeled([]byte(line), ts, map[string]string{"app": "api"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFieldIndexSidecar(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 30, Compress: true, IndexFields: []string{"trace_id", "status", "user"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFieldLines(t, r,
+		`{"trace_id":"t1","status":200}`,
+		`{"trace_id":"t1","status":500}`,
+		`{"trace_id":"t2","status":200}`,
+		"not json",
+	)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	index := readIndex(t, dir)
+	if len(index) != 1 {
+		t.Fatalf("index = %+v", index)
+	}
+	fi, err := ReadFieldIndex(dir, index[0].File)
+	if err != nil || fi == nil {
+		t.Fatalf("ReadFieldIndex = %v, %v", fi, err)
+	}
+	if fi.Fields["trace_id"]["t1"] != 2 || fi.Fields["status"]["200"] != 2 {
+		t.Errorf("fields = %v", fi.Fields)
+	}
+	if values, ok := fi.Fields["user"]; !ok || len(values) != 0 {
+		t.Errorf("user = %v, %v, want indexed without values", values, ok)
+	}
+	tests := []struct {
+		field, value string
+		want         bool
+	}{
+		{"trace_id", "t2", true},
+		{"trace_id", "t3", false},
+		{"user", "alice", false},
+		{"request_id", "r1", true}, // not indexed
+	}
+	for _, tt := range tests {
+		if got := fi.MayContain(tt.field, tt.value); got != tt.want {
+			t.Errorf("MayContain(%s, %s) = %v, want %v", tt.field, tt.value, got, tt.want)
+		}
+	}
+
+	// files without a field index may contain anything
+	fi, err = ReadFieldIndex(dir, "2024-01-15T100000-999.jsonl")
+	if err != nil || fi != nil || !fi.MayContain("trace_id", "t3") {
+		t.Errorf("missing sidecar = %v, %v", fi, err)
+	}
+}
+
+func TestFieldIndexOverflow(t *testing.T) {
+	fi := newFieldIndex([]string{"id"})
+	for i := range maxFieldValues + 1 {
+		fi.add("id", fmt.Sprint(i))
+	}
+	if !fi.MayContain("id", "unseen") || len(fi.Fields["id"]) != 0 {
+		t.Errorf("overflowed field still answers lookups")
+	}
+}
+
+func TestFieldIndexRecovered(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 30, IndexFields: []string{"trace_id"}}
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFieldLines(t, r, `{"trace_id":"t1"}`)
+	name := r.active.name
+	_ = r.active.file.Close() // crash
+
+	r, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	fi, err := ReadFieldIndex(dir, name)
+	if err != nil || fi == nil || fi.Fields["trace_id"]["t1"] != 1 {
+		t.Fatalf("recovered field index = %+v, %v", fi, err)
+	}
+}
+
+func TestFieldIndexDiskCap(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Dir: dir, MaxFile: 200, MaxDisk: 1200, IndexFields: []string{"trace_id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 30 {
+		writeFieldLines(t, r, fmt.Sprintf(`{"trace_id":"t%d"}`, i))
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if ext := filepath.Ext(name); ext != FieldIndexSuffix {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, name[:len(name)-len(FieldIndexSuffix)])); err != nil {
+			t.Errorf("field index %s outlived its data file", name)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package rotate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// dataFileRe matches the names of data files written by a Rotator.
+var dataFileRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{6}-\d{3}(\.[^/]+)?\.jsonl(\.zst)?$`)
+
+// recoverFiles indexes the data files a receiver that did not shut down
+// cleanly left behind: the files it was writing, and files rotated but not
+// yet indexed. A partial last line is cut off, an interrupted compression
+// is discarded in favor of its source, and files without a complete line
+// are left alone. It returns the names of the files indexed.
+func (r *Rotator) recoverFiles() ([]string, error) {
+	indexPath := filepath.Join(r.cfg.Dir, "index.jsonl")
+	indexed, err := repairIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dirEntries, err := os.ReadDir(r.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool)
+	var orphans []string
+	for _, e := range dirEntries {
+		name := e.Name()
+		if e.IsDir() || !dataFileRe.MatchString(name) {
+			continue
+		}
+		present[name] = true
+		if !indexed[name] && !indexed[name+".zst"] && !indexed[strings.TrimSuffix(name, ".zst")] {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+
+	var recovered []string
+	for _, name := range orphans {
+		if strings.HasSuffix(name, ".zst") && present[strings.TrimSuffix(name, ".zst")] {
+			continue // compression was interrupted; its source is recovered instead
+		}
+		seg, err := r.scanOrphan(name)
+		if err != nil {
+			return recovered, err
+		}
+		entry := seg.indexEntry()
+		if entry.Lines == 0 {
+			continue
+		}
+		if present[name+".zst"] {
+			if err := os.Remove(filepath.Join(r.cfg.Dir, name+".zst")); err != nil {
+				return recovered, err
+			}
+		}
+		if r.cfg.Compress && !strings.HasSuffix(name, ".zst") {
+			compressed, sum, err := r.compressFile(name)
+			if err != nil {
+				return recovered, err
+			}
+			entry.File = filepath.Base(compressed)
+			entry.SHA256 = sum
+		}
+		if err := r.writeSidecars(seg, entry.File); err != nil {
+			return recovered, err
+		}
+		if err := r.appendIndex(entry); err != nil {
+			return recovered, err
+		}
+		r.queueUpload(entry.File)
+		recovered = append(recovered, entry.File)
+	}
+	return recovered, nil
+}
+
+// repairIndex cuts a partial last line off the index at path and returns
+// the files it lists.
+func repairIndex(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n := bytes.LastIndexByte(data, '\n') + 1; n < len(data) {
+		if err := os.Truncate(path, int64(n)); err != nil {
+			return nil, err
+		}
+		data = data[:n]
+	}
+	indexed := make(map[string]bool)
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		var e IndexEntry
+		if json.Unmarshal(line, &e) == nil && e.File != "" {
+			indexed[e.File] = true
+		}
+	}
+	return indexed, nil
+}
+
+// scanOrphan rebuilds the segment of a data file from its lines,
+// truncating a plain file after its last complete line.
+func (r *Rotator) scanOrphan(name string) (*segment, error) {
+	path := filepath.Join(r.cfg.Dir, name)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var src io.Reader = f
+	compressed := strings.HasSuffix(name, ".zst")
+	if compressed {
+		dec, err := zstd.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		src = dec
+	}
+
+	// the digest covers the file as stored: the complete lines of a plain
+	// file, or every byte of a compressed one
+	sum := sha256.New()
+	if compressed {
+		f2, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(sum, f2)
+		_ = f2.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seg := &segment{name: name, labels: make(map[string]map[string]int64)}
+	r.indexSegment(seg)
+	br := bufio.NewReaderSize(src, 256*1024)
+	var complete int64 // bytes up to the last newline
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			complete += int64(len(line))
+			if !compressed {
+				sum.Write(line)
+			}
+			var e struct {
+				Timestamp time.Time         `json:"ts"`
+				Labels    map[string]string `json:"labels"`
+			}
+			if json.Unmarshal(line, &e) == nil {
+				seg.track(e.Timestamp, e.Labels)
+			}
+			seg.trackMessage(line, r.cfg.IndexFields)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if compressed {
+				break // keep the lines of a damaged tail
+			}
+			return nil, err
+		}
+	}
+	seg.size = complete
+	seg.hash = sum
+
+	if !compressed && seg.lines > 0 {
+		if info, err := f.Stat(); err == nil && info.Size() > complete {
+			if err := os.Truncate(path, complete); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return seg, nil
+}
@@ -0,0 +1,136 @@
+package rotate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// crashedCapture writes lines through a rotator that is never closed, as
+// after a crash, and appends a partial line to its active file.
+func crashedCapture(t *testing.T, dir string, lines int) string {
+	t.Helper()
+	r, err := New(Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := range lines {
+		ts := base.Add(time.Duration(i) * time.Second)
+		labels := map[string]string{"app": "api"}
+		line := fmt.Sprintf(`{"ts":%q,"labels":{"app":"api"},"msg":"line %d"}`+"\n", ts.Format(time.RFC3339), i)
+		if _, err := r.WriteLabeled([]byte(line), ts, labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+	name := r.active.name
+	if _, err := r.active.file.WriteString(`{"ts":"2024-01-15T10:05:00Z","msg":"cut of`); err != nil {
+		t.Fatal(err)
+	}
+	_ = r.active.file.Close()
+	return name
+}
+
+func TestRecoverActiveFile(t *testing.T) {
+	dir := t.TempDir()
+	name := crashedCapture(t, dir, 5)
+
+	r, err := New(Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	if got := r.Recovered(); len(got) != 1 || got[0] != name {
+		t.Fatalf("Recovered = %v, want [%s]", got, name)
+	}
+	index := readIndex(t, dir)
+	if len(index) != 1 {
+		t.Fatalf("index = %+v, want one entry", index)
+	}
+	e := index[0]
+	if e.File != name || e.Lines != 5 || e.Labels["app"]["api"] != 5 {
+		t.Errorf("entry = %+v, want 5 api lines in %s", e, name)
+	}
+	if want := time.Date(2024, 1, 15, 10, 0, 4, 0, time.UTC); !e.To.Equal(want) {
+		t.Errorf("To = %v, want %v", e.To, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "cut of") || int64(len(data)) != e.Bytes {
+		t.Errorf("partial line not cut: %d bytes, entry says %d", len(data), e.Bytes)
+	}
+	if sum := sha256.Sum256(data); e.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("SHA256 = %q, does not match the recovered file", e.SHA256)
+	}
+}
+
+func TestRecoverCompressesAndRepairsIndex(t *testing.T) {
+	dir := t.TempDir()
+	name := crashedCapture(t, dir, 3)
+	// an index line cut off mid-append, and a compression that never finished
+	if err := os.WriteFile(filepath.Join(dir, "index.jsonl"), []byte(`{"file":"2024-01-15T09`), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".zst"), []byte("partial"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 30, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	index := readIndex(t, dir)
+	if len(index) != 1 || index[0].File != name+".zst" || index[0].Lines != 3 {
+		t.Fatalf("index = %+v, want %s.zst with 3 lines", index, name)
+	}
+	if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+		t.Errorf("uncompressed file left behind: %v", err)
+	}
+
+	// recovery is done once: a second start finds nothing to recover
+	r2, err := New(Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 30, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r2.Close() }()
+	if got := r2.Recovered(); len(got) != 0 {
+		t.Errorf("second start recovered %v", got)
+	}
+}
+
+func TestDurableSync(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Dir: dir, MaxFile: 100, MaxDisk: 1 << 20, Durable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(`{"ts":"2024-01-01T00:00:00Z","msg":"aaaaaaaaaa"}` + "\n")
+	for range 5 {
+		if _, err := r.WriteLabeled(line, time.Now(), nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var lines int64
+	for _, e := range readIndex(t, dir) {
+		lines += e.Lines
+	}
+	if lines != 5 {
+		t.Errorf("indexed %d lines, want 5", lines)
+	}
+}
@@ -1,10 +1,16 @@
 package rotate
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
@@ -15,12 +21,22 @@ import (
 
 // Config controls rotation behavior.
 type Config struct {
-	Dir      string // output directory
-	MaxFile  int64  // max bytes per file before rotation
-	MaxDisk  int64  // max total bytes on disk
-	Compress bool   // zstd compress rotated files
+	Dir         string        // output directory
+	MaxFile     int64         // max bytes per file before rotation
+	MaxDisk     int64         // max total bytes on disk
+	Compress    bool          // zstd compress rotated files
+	Sink        Sink          // optional: copy each finished segment off the host
+	RotateEvery time.Duration // optional: also rotate at multiples of this wall-clock interval
+	PartitionBy string        // optional: label key whose values get their own file series
+	Durable     bool          // optional: fsync finished files and the index before moving on
+	IndexFields []string      // optional: JSON message fields to build a FieldIndex of per file
+	GrepBloom   bool          // optional: write a Bloom of the message tokens of each file
 }
 
+// MaxPartitions caps the partitions written at once with PartitionBy;
+// lines with further values go to the unpartitioned file series.
+const MaxPartitions = 256
+
 // IndexEntry records metadata for one rotated file.
 type IndexEntry struct {
 	File   string                      `json:"file"`
@@ -29,6 +45,7 @@ type IndexEntry struct {
 	Lines  int64                       `json:"lines"`
 	Bytes  int64                       `json:"bytes"`
 	Labels map[string]map[string]int64 `json:"labels,omitempty"`
+	SHA256 string                      `json:"sha256,omitempty"` // hex digest of the file as stored
 }
 
 // Rotator manages the active log file, rotation, compression, and disk cap.
@@ -36,45 +53,120 @@ type Rotator struct {
 	cfg Config
 
 	mu         sync.Mutex
-	active     *os.File
-	activeSize int64
-	activeName string
+	active     *segment            // file for lines without a partition
+	parts      map[string]*segment // PartitionBy value → its file, opened on first line
 	diskUsage  int64
 	seq        int // sequence within same second
 	lastSecond string
-
-	// tracking for current file's index entry
-	from   time.Time
-	to     time.Time
-	lines  int64
-	labels map[string]map[string]int64
+	stopTimer  chan struct{}
 
 	// optional callbacks for metrics
 	onRotate      func(reason string)    // called on successful rotation
 	onError       func()                 // called on rotation error
 	onDiskWarning func(usage, cap int64) // called when disk usage exceeds 80%
+	onUpload      func(name string, size int64, err error)
 
 	diskWarningFired bool // avoid repeat-firing
+
+	// with a sink: segments waiting for upload, and those already stored
+	uploads     chan string
+	uploadsDone chan struct{}
+	offloaded   map[string]string
+
+	recovered []string // files left by an unclean shutdown, indexed by New
+}
+
+// segment is a data file being written and the tracking for its index entry.
+type segment struct {
+	partition string // PartitionBy value, "" for the unpartitioned series
+	file      *os.File
+	name      string
+	size      int64
+	hash      hash.Hash // SHA-256 of the bytes written
+	rotateAt  time.Time // next RotateEvery boundary
+
+	from   time.Time
+	to     time.Time
+	lines  int64
+	labels map[string]map[string]int64
+	fields *FieldIndex   // nil without Config.IndexFields
+	tokens *BloomBuilder // nil without Config.GrepBloom
 }
 
-// New creates a Rotator, scanning any existing files for disk usage.
+// New creates a Rotator, indexing files left unindexed by a receiver that
+// did not shut down cleanly and scanning existing files for disk usage.
 func New(cfg Config) (*Rotator, error) {
 	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
 		return nil, fmt.Errorf("create dir: %w", err)
 	}
 	r := &Rotator{
-		cfg:    cfg,
-		labels: make(map[string]map[string]int64),
+		cfg:   cfg,
+		parts: make(map[string]*segment),
 	}
-	if err := r.bootstrap(); err != nil {
+	if cfg.Sink != nil {
+		if err := r.readOffload(); err != nil {
+			return nil, err
+		}
+		r.uploads = make(chan string, uploadQueue)
+		r.uploadsDone = make(chan struct{})
+		go r.runUploads(r.uploads)
+	}
+	var err error
+	if r.recovered, err = r.recoverFiles(); err != nil {
+		r.stopUploads()
+		return nil, fmt.Errorf("recover: %w", err)
+	}
+	if err = r.bootstrap(); err != nil {
+		r.stopUploads()
 		return nil, fmt.Errorf("bootstrap: %w", err)
 	}
-	if err := r.openNew(); err != nil {
+	if r.active, err = r.openNew(""); err != nil {
+		r.stopUploads()
 		return nil, fmt.Errorf("open initial file: %w", err)
 	}
+	if cfg.RotateEvery > 0 {
+		r.stopTimer = make(chan struct{})
+		go r.runRotateTimer(r.stopTimer)
+	}
 	return r, nil
 }
 
+// stopUploads ends the upload goroutine of a Rotator New gives up on.
+func (r *Rotator) stopUploads() {
+	if r.uploads != nil {
+		close(r.uploads)
+		<-r.uploadsDone
+		r.uploads = nil
+	}
+}
+
+// Recovered returns the files New indexed after an unclean shutdown.
+func (r *Rotator) Recovered() []string {
+	return r.recovered
+}
+
+// Sync commits the data written to the open files to stable storage, so
+// it survives a crash of the host.
+func (r *Rotator) Sync() error {
+	r.mu.Lock()
+	segs := r.segments()
+	files := make([]*os.File, len(segs))
+	for i, seg := range segs {
+		files[i] = seg.file
+	}
+	r.mu.Unlock()
+
+	// sync outside the lock so writes go on meanwhile; a file rotated away
+	// in between was synced by the rotation if Durable
+	var errs []error
+	for _, f := range files {
+		if err := f.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // SetOnRotate sets a callback invoked on each successful rotation with the reason.
 func (r *Rotator) SetOnRotate(fn func(reason string)) {
 	r.onRotate = fn
@@ -91,45 +183,215 @@ func (r *Rotator) SetOnDiskWarning(fn func(usage, cap int64)) {
 	r.onDiskWarning = fn
 }
 
-// Write appends data to the active file, rotating if over MaxFile.
+// SetOnUpload sets a callback invoked after each attempt to copy a segment
+// to the sink, with the segment's size and the error if it failed.
+func (r *Rotator) SetOnUpload(fn func(name string, size int64, err error)) {
+	r.onUpload = fn
+}
+
+// Write appends data to the unpartitioned file, rotating if over MaxFile.
 func (r *Rotator) Write(p []byte) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	n, err := r.write(p, nil)
+	if seg, segErr := r.segmentFor(nil); segErr == nil && (seg.fields != nil || seg.tokens != nil) {
+		for line := range bytes.Lines(p) { // sidecars must cover every line
+			seg.trackMessage(line, r.cfg.IndexFields)
+		}
+	}
+	return n, err
+}
 
-	if r.activeSize+int64(len(p)) > r.cfg.MaxFile && r.activeSize > 0 {
-		if err := r.rotate(); err != nil {
-			if r.onError != nil {
-				r.onError()
-			}
-			return 0, fmt.Errorf("rotate: %w", err)
+// WriteLabeled appends data to the file of the partition in labels and
+// tracks the line in its index entry.
+func (r *Rotator) WriteLabeled(p []byte, ts time.Time, labels map[string]string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, err := r.write(p, labels)
+	if seg, segErr := r.segmentFor(labels); segErr == nil {
+		seg.track(ts, labels)
+		seg.trackMessage(p, r.cfg.IndexFields)
+	}
+	return n, err
+}
+
+// write appends p to the segment for labels; r.mu must be held.
+func (r *Rotator) write(p []byte, labels map[string]string) (int, error) {
+	seg, err := r.segmentFor(labels)
+	if err != nil {
+		return 0, err
+	}
+	if seg.size+int64(len(p)) > r.cfg.MaxFile && seg.size > 0 {
+		if err := r.rotateFor(seg, "size"); err != nil {
+			return 0, err
 		}
-		if r.onRotate != nil {
-			r.onRotate("size")
+	} else if r.dueForTime(seg, time.Now()) {
+		if err := r.rotateFor(seg, "time"); err != nil {
+			return 0, err
 		}
 	}
-	n, err := r.active.Write(p)
-	r.activeSize += int64(n)
+	if seg, err = r.segmentFor(labels); err != nil {
+		return 0, err
+	}
+	n, err := seg.file.Write(p)
+	seg.hash.Write(p[:n])
+	seg.size += int64(n)
 	r.diskUsage += int64(n)
 	return n, err
 }
 
-// TrackLine accumulates metadata for the current file's index entry.
+// segmentFor returns the segment lines with labels go to, opening the
+// file of a new partition; r.mu must be held. Lines without the PartitionBy
+// label, and new values past MaxPartitions, go to the unpartitioned file.
+func (r *Rotator) segmentFor(labels map[string]string) (*segment, error) {
+	if r.active == nil {
+		return nil, os.ErrClosed
+	}
+	value := ""
+	if r.cfg.PartitionBy != "" {
+		value = labels[r.cfg.PartitionBy]
+	}
+	if value == "" {
+		return r.active, nil
+	}
+	if seg := r.parts[value]; seg != nil {
+		return seg, nil
+	}
+	if len(r.parts) >= MaxPartitions {
+		return r.active, nil
+	}
+	seg, err := r.openNew(value)
+	if err != nil {
+		return nil, fmt.Errorf("open partition %s: %w", value, err)
+	}
+	r.parts[value] = seg
+	return seg, nil
+}
+
+// rotateFor rotates seg and reports the outcome to the callbacks; r.mu must
+// be held.
+func (r *Rotator) rotateFor(seg *segment, reason string) error {
+	if err := r.rotate(seg); err != nil {
+		if r.onError != nil {
+			r.onError()
+		}
+		return fmt.Errorf("rotate: %w", err)
+	}
+	if r.onRotate != nil {
+		r.onRotate(reason)
+	}
+	return nil
+}
+
+// dueForTime reports whether seg has data and has passed its RotateEvery
+// boundary. An empty file just moves on to the next boundary; r.mu must be
+// held.
+func (r *Rotator) dueForTime(seg *segment, now time.Time) bool {
+	if r.cfg.RotateEvery <= 0 || now.Before(seg.rotateAt) {
+		return false
+	}
+	if seg.size == 0 {
+		seg.rotateAt = nextBoundary(now, r.cfg.RotateEvery)
+		return false
+	}
+	return true
+}
+
+// runRotateTimer rotates at each RotateEvery boundary, so files of quiet
+// streams are closed and indexed on time too.
+func (r *Rotator) runRotateTimer(stop <-chan struct{}) {
+	for {
+		r.mu.Lock()
+		var wait time.Duration
+		if r.active != nil {
+			wait = time.Until(r.active.rotateAt)
+		}
+		for _, seg := range r.parts {
+			wait = min(wait, time.Until(seg.rotateAt))
+		}
+		r.mu.Unlock()
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+		r.mu.Lock()
+		now := time.Now()
+		for _, seg := range r.segments() {
+			if r.dueForTime(seg, now) {
+				if err := r.rotateFor(seg, "time"); err != nil {
+					seg.rotateAt = nextBoundary(now, r.cfg.RotateEvery) // retry at the next boundary
+				}
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// segments returns the open segments, the unpartitioned one first; r.mu
+// must be held.
+func (r *Rotator) segments() []*segment {
+	segs := make([]*segment, 0, len(r.parts)+1)
+	if r.active != nil {
+		segs = append(segs, r.active)
+	}
+	values := make([]string, 0, len(r.parts))
+	for v := range r.parts {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		segs = append(segs, r.parts[v])
+	}
+	return segs
+}
+
+// nextBoundary returns the first multiple of every after t.
+func nextBoundary(t time.Time, every time.Duration) time.Time {
+	return t.Truncate(every).Add(every)
+}
+
+// TrackLine accumulates metadata for the index entry of the file lines
+// with labels are written to.
 func (r *Rotator) TrackLine(ts time.Time, labels map[string]string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if seg, err := r.segmentFor(labels); err == nil {
+		seg.track(ts, labels)
+	}
+}
+
+// trackMessage adds the message of a JSONL line to the field index and
+// bloom filter of the segment, if it keeps them.
+func (s *segment) trackMessage(line []byte, fields []string) {
+	if s.fields == nil && s.tokens == nil {
+		return
+	}
+	msg, ok := lineMessage(line)
+	if !ok {
+		return
+	}
+	if s.fields != nil {
+		s.fields.trackMessage(msg, fields)
+	}
+	if s.tokens != nil {
+		s.tokens.addMessage(msg)
+	}
+}
 
-	r.lines++
-	if r.from.IsZero() || ts.Before(r.from) {
-		r.from = ts
+func (s *segment) track(ts time.Time, labels map[string]string) {
+	s.lines++
+	if s.from.IsZero() || ts.Before(s.from) {
+		s.from = ts
 	}
-	if ts.After(r.to) {
-		r.to = ts
+	if ts.After(s.to) {
+		s.to = ts
 	}
 	for k, v := range labels {
-		if r.labels[k] == nil {
-			r.labels[k] = make(map[string]int64)
+		if s.labels[k] == nil {
+			s.labels[k] = make(map[string]int64)
 		}
-		r.labels[k][v]++
+		s.labels[k][v]++
 	}
 }
 
@@ -140,33 +402,99 @@ func (r *Rotator) DiskUsage() int64 {
 	return r.diskUsage
 }
 
-// Close flushes the active file and writes a final index entry.
-func (r *Rotator) Close() error {
+// Stats is a point-in-time view of the rotator's internal counters.
+type Stats struct {
+	ActiveFile  string    `json:"active_file"`
+	ActiveSize  int64     `json:"active_size"`
+	ActiveLines int64     `json:"active_lines"`
+	From        time.Time `json:"from,omitempty"`
+	To          time.Time `json:"to,omitempty"`
+	DiskUsage   int64     `json:"disk_usage"`
+	MaxFile     int64     `json:"max_file"`
+	MaxDisk     int64     `json:"max_disk"`
+	DiskWarning bool      `json:"disk_warning"`
+	Partitions  int       `json:"partitions,omitempty"` // partition files open; active counters cover all of them
+}
+
+// Stats returns the rotator's current counters.
+func (r *Rotator) Stats() Stats {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	st := Stats{
+		DiskUsage:   r.diskUsage,
+		MaxFile:     r.cfg.MaxFile,
+		MaxDisk:     r.cfg.MaxDisk,
+		DiskWarning: r.diskWarningFired,
+		Partitions:  len(r.parts),
+	}
+	if r.active != nil {
+		st.ActiveFile = r.active.name
+	}
+	for _, seg := range r.segments() {
+		st.ActiveSize += seg.size
+		st.ActiveLines += seg.lines
+		if !seg.from.IsZero() && (st.From.IsZero() || seg.from.Before(st.From)) {
+			st.From = seg.from
+		}
+		if seg.to.After(st.To) {
+			st.To = seg.to
+		}
+	}
+	return st
+}
 
-	if r.active == nil {
-		return nil
+// Close flushes the active file and writes a final index entry. With a
+// sink it waits until every finished segment is uploaded.
+func (r *Rotator) Close() error {
+	r.mu.Lock()
+	var errs []error
+	for _, seg := range r.segments() {
+		if err := r.closeSegment(seg); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	if err := r.active.Close(); err != nil {
+	r.active = nil
+	clear(r.parts)
+	err := errors.Join(errs...)
+	uploads := r.uploads
+	r.uploads = nil
+	if r.stopTimer != nil {
+		close(r.stopTimer)
+		r.stopTimer = nil
+	}
+	r.mu.Unlock()
+
+	if uploads != nil {
+		close(uploads)
+		<-r.uploadsDone
+	}
+	return err
+}
+
+func (r *Rotator) closeSegment(seg *segment) error {
+	if err := r.finishFile(seg.file); err != nil {
 		return err
 	}
 
 	// write index entry for final file if it has data
-	if r.lines > 0 {
-		entry := r.buildIndexEntry()
+	if seg.lines > 0 {
+		entry := seg.indexEntry()
 		if r.cfg.Compress {
-			compressed, err := r.compressFile(r.activeName)
+			compressed, sum, err := r.compressFile(seg.name)
 			if err != nil {
 				return fmt.Errorf("compress final: %w", err)
 			}
 			entry.File = filepath.Base(compressed)
+			entry.SHA256 = sum
+		}
+		if err := r.writeSidecars(seg, entry.File); err != nil {
+			return fmt.Errorf("write final %w", err)
 		}
 		if err := r.appendIndex(entry); err != nil {
 			return fmt.Errorf("write final index: %w", err)
 		}
+		r.queueUpload(entry.File)
 	}
-	r.active = nil
 	return nil
 }
 
@@ -190,23 +518,45 @@ func (r *Rotator) bootstrap() error {
 	return nil
 }
 
-func (r *Rotator) openNew() error {
-	name := r.nextFilename()
-	f, err := os.Create(filepath.Join(r.cfg.Dir, name))
-	if err != nil {
-		return err
+// openNew creates the next file of partition ("" for the unpartitioned
+// series).
+func (r *Rotator) openNew(partition string) (*segment, error) {
+	var name string
+	var f *os.File
+	for {
+		// names already taken by files of an earlier run in the same second
+		// are skipped, so they are never truncated
+		name = r.nextFilename(partition)
+		if _, err := os.Stat(filepath.Join(r.cfg.Dir, name+".zst")); err == nil {
+			continue
+		}
+		var err error
+		f, err = os.OpenFile(filepath.Join(r.cfg.Dir, name), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
 	}
-	r.active = f
-	r.activeName = name
-	r.activeSize = 0
-	r.from = time.Time{}
-	r.to = time.Time{}
-	r.lines = 0
-	r.labels = make(map[string]map[string]int64)
-	return nil
+	seg := &segment{
+		partition: partition,
+		file:      f,
+		name:      name,
+		hash:      sha256.New(),
+		labels:    make(map[string]map[string]int64),
+	}
+	r.indexSegment(seg)
+	if r.cfg.RotateEvery > 0 {
+		seg.rotateAt = nextBoundary(time.Now(), r.cfg.RotateEvery)
+	}
+	return seg, nil
 }
 
-func (r *Rotator) nextFilename() string {
+// nextFilename names files by creation time, so they sort oldest first.
+// Partition files carry the partition value, made safe for file names.
+func (r *Rotator) nextFilename(partition string) string {
 	now := time.Now().UTC()
 	sec := now.Format("2006-01-02T150405")
 	if sec == r.lastSecond {
@@ -215,18 +565,23 @@ func (r *Rotator) nextFilename() string {
 		r.lastSecond = sec
 		r.seq = 0
 	}
+	if partition != "" {
+		return fmt.Sprintf("%s-%03d.%s.jsonl", sec, r.seq, SessionName(partition))
+	}
 	return fmt.Sprintf("%s-%03d.jsonl", sec, r.seq)
 }
 
-func (r *Rotator) rotate() error {
-	if err := r.active.Close(); err != nil {
+// rotate finishes seg and replaces it: the unpartitioned file is reopened
+// at once, a partition's file when its next line arrives.
+func (r *Rotator) rotate(seg *segment) error {
+	if err := r.finishFile(seg.file); err != nil {
 		return err
 	}
 
-	entry := r.buildIndexEntry()
+	entry := seg.indexEntry()
 
 	if r.cfg.Compress {
-		compressed, err := r.compressFile(r.activeName)
+		compressed, sum, err := r.compressFile(seg.name)
 		if err != nil {
 			return fmt.Errorf("compress: %w", err)
 		}
@@ -235,60 +590,91 @@ func (r *Rotator) rotate() error {
 		if err != nil {
 			return err
 		}
-		r.diskUsage = r.diskUsage - r.activeSize + info.Size()
+		r.diskUsage = r.diskUsage - seg.size + info.Size()
 		entry.File = filepath.Base(compressed)
+		entry.SHA256 = sum
 	}
 
+	if err := r.writeSidecars(seg, entry.File); err != nil {
+		return fmt.Errorf("write %w", err)
+	}
 	if err := r.appendIndex(entry); err != nil {
 		return err
 	}
+	r.queueUpload(entry.File)
+
+	if seg.partition != "" {
+		delete(r.parts, seg.partition)
+	} else {
+		next, err := r.openNew("")
+		if err != nil {
+			return err
+		}
+		r.active = next
+	}
 
 	if err := r.enforceDiskCap(); err != nil {
 		return fmt.Errorf("enforce disk cap: %w", err)
 	}
+	return nil
+}
 
-	return r.openNew()
+// finishFile closes a segment's file, syncing it first when Durable.
+func (r *Rotator) finishFile(f *os.File) error {
+	if r.cfg.Durable {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	return f.Close()
 }
 
-func (r *Rotator) buildIndexEntry() IndexEntry {
+func (s *segment) indexEntry() IndexEntry {
 	entry := IndexEntry{
-		File:  r.activeName,
-		From:  r.from,
-		To:    r.to,
-		Lines: r.lines,
-		Bytes: r.activeSize,
+		File:  s.name,
+		From:  s.from,
+		To:    s.to,
+		Lines: s.lines,
+		Bytes: s.size,
 	}
-	if len(r.labels) > 0 {
-		entry.Labels = r.labels
+	if s.hash != nil {
+		entry.SHA256 = hex.EncodeToString(s.hash.Sum(nil))
+	}
+	if len(s.labels) > 0 {
+		entry.Labels = s.labels
 	}
 	return entry
 }
 
-func (r *Rotator) compressFile(name string) (string, error) {
+// compressFile replaces a data file with its zstd compressed copy. It
+// returns the new path and the SHA-256 of the compressed file.
+func (r *Rotator) compressFile(name string) (string, string, error) {
 	srcPath := filepath.Join(r.cfg.Dir, name)
 	dstPath := srcPath + ".zst"
 
 	src, err := os.ReadFile(srcPath)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	enc, err := zstd.NewWriter(nil)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	compressed := enc.EncodeAll(src, nil)
 	if err := enc.Close(); err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	if err := os.WriteFile(dstPath, compressed, 0o640); err != nil {
-		return "", err
+	if err := writeFile(dstPath, compressed, r.cfg.Durable); err != nil {
+		return "", "", err
 	}
 	if err := os.Remove(srcPath); err != nil {
-		return "", err
+		return "", "", err
 	}
-	return dstPath, nil
+	sum := sha256.Sum256(compressed)
+	return dstPath, hex.EncodeToString(sum[:]), nil
 }
 
 func (r *Rotator) appendIndex(entry IndexEntry) error {
@@ -303,8 +689,46 @@ func (r *Rotator) appendIndex(entry IndexEntry) error {
 	if err != nil {
 		return err
 	}
-	_, err = fmt.Fprintf(f, "%s\n", data)
-	return err
+	if _, err = fmt.Fprintf(f, "%s\n", data); err != nil || !r.cfg.Durable {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return syncDir(r.cfg.Dir) // file creations and removals of the rotation
+}
+
+// writeFile writes data to a new file at path, syncing it when durable.
+func writeFile(path string, data []byte, durable bool) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if durable {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// syncDir commits the entries of dir, so new and renamed files survive a
+// crash of the host.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil // directories cannot be synced there
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
 }
 
 func (r *Rotator) enforceDiskCap() error {
@@ -356,6 +780,16 @@ func (r *Rotator) enforceDiskCap() error {
 		}
 	}
 	sort.Strings(dataFiles)
+	// never remove a file still being written, e.g. a quiet partition's
+	open := make(map[string]bool)
+	for _, seg := range r.segments() {
+		open[seg.name] = true
+	}
+	// files already in the sink go first; their index entries stay, so
+	// readers fetch them from there
+	sort.SliceStable(dataFiles, func(i, j int) bool {
+		return r.offloaded[dataFiles[i]] != "" && r.offloaded[dataFiles[j]] == ""
+	})
 
 	// track which files we delete so we can prune index
 	deleted := make(map[string]bool)
@@ -363,6 +797,9 @@ func (r *Rotator) enforceDiskCap() error {
 		if r.diskUsage <= r.cfg.MaxDisk {
 			break
 		}
+		if open[name] {
+			continue
+		}
 		path := filepath.Join(r.cfg.Dir, name)
 		info, err := os.Stat(path)
 		if err != nil {
@@ -373,7 +810,15 @@ func (r *Rotator) enforceDiskCap() error {
 			continue
 		}
 		r.diskUsage -= size
-		deleted[name] = true
+		if r.offloaded[name] == "" {
+			deleted[name] = true
+			// the sidecars go with their file; an offloaded file keeps them
+			for _, suffix := range SidecarSuffixes {
+				if info, err := os.Stat(path + suffix); err == nil && os.Remove(path+suffix) == nil {
+					r.diskUsage -= info.Size()
+				}
+			}
+		}
 	}
 
 	if len(deleted) > 0 {
@@ -1,6 +1,8 @@
 package rotate
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -77,6 +79,139 @@ func TestRotationTriggered(t *testing.T) {
 	}
 }
 
+func TestRotateEvery(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 20, RotateEvery: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := make(chan string, 10)
+	r.mu.Lock()
+	r.onRotate = func(reason string) { reasons <- reason }
+	r.mu.Unlock()
+
+	line := []byte(`{"ts":"2024-01-01T00:00:00Z","msg":"hello"}` + "\n")
+	if _, err := r.Write(line); err != nil {
+		t.Fatal(err)
+	}
+	r.TrackLine(time.Now(), nil)
+
+	// no further writes: the timer rotates the idle file at the boundary
+	select {
+	case reason := <-reasons:
+		if reason != "time" {
+			t.Errorf("reason = %q, want time", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("file not rotated by time")
+	}
+	if n := len(readIndex(t, dir)); n != 1 {
+		t.Errorf("index entries = %d, want 1", n)
+	}
+
+	// an empty file is not rotated
+	time.Sleep(120 * time.Millisecond)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reason := <-reasons:
+		t.Errorf("empty file rotated (%s)", reason)
+	default:
+	}
+	if n := len(readIndex(t, dir)); n != 1 {
+		t.Errorf("index entries after close = %d, want 1", n)
+	}
+}
+
+func TestPartitionBy(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Dir: dir, MaxFile: 100, MaxDisk: 1 << 20, PartitionBy: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	line := []byte(`{"msg":"a line of about forty bytes...."}` + "\n")
+	for i := range 6 {
+		for _, app := range []string{"web", "api", ""} {
+			labels := map[string]string{"app": app}
+			if app == "" {
+				labels = map[string]string{"job": "cron"}
+			}
+			if _, err := r.WriteLabeled(line, ts.Add(time.Duration(i)*time.Second), labels); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if st := r.Stats(); st.Partitions != 2 || st.ActiveLines == 0 {
+		t.Errorf("stats = %+v, want 2 partitions", st)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readIndex(t, dir)
+	lines := make(map[string]int64)
+	for _, e := range entries {
+		if len(e.Labels["app"]) > 1 {
+			t.Errorf("%s mixes apps: %v", e.File, e.Labels["app"])
+		}
+		app := ""
+		for v := range e.Labels["app"] {
+			app = v
+			if !strings.HasSuffix(e.File, "."+v+".jsonl") {
+				t.Errorf("partition file %s not named after %s", e.File, v)
+			}
+		}
+		lines[app] += e.Lines
+	}
+	if lines["web"] != 6 || lines["api"] != 6 || lines[""] != 6 {
+		t.Errorf("lines per partition = %v, want 6 each", lines)
+	}
+	if len(entries) < 6 {
+		t.Errorf("got %d index entries, want each partition rotated", len(entries))
+	}
+}
+
+func TestPartitionByMaxPartitions(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Dir: dir, MaxFile: 1 << 20, MaxDisk: 1 << 30, PartitionBy: "pod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range MaxPartitions + 10 {
+		if _, err := r.WriteLabeled([]byte("{}\n"), time.Now(), map[string]string{"pod": fmt.Sprint("p", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := r.Stats(); st.Partitions != MaxPartitions {
+		t.Errorf("partitions = %d, want %d", st.Partitions, MaxPartitions)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var overflow int64
+	for _, e := range readIndex(t, dir) {
+		if len(e.Labels["pod"]) > 1 {
+			overflow = e.Lines
+		}
+	}
+	if overflow != 10 {
+		t.Errorf("unpartitioned file holds %d lines, want 10", overflow)
+	}
+}
+
+func TestNextBoundary(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC)
+	if got := nextBoundary(ts, 5*time.Minute); !got.Equal(time.Date(2024, 1, 1, 10, 10, 0, 0, time.UTC)) {
+		t.Errorf("nextBoundary = %s, want 10:10", got)
+	}
+	on := time.Date(2024, 1, 1, 10, 10, 0, 0, time.UTC)
+	if got := nextBoundary(on, 5*time.Minute); !got.Equal(on.Add(5 * time.Minute)) {
+		t.Errorf("nextBoundary on a boundary = %s, want 10:15", got)
+	}
+}
+
 func TestCompression(t *testing.T) {
 	dir := t.TempDir()
 	r, err := New(Config{Dir: dir, MaxFile: 50, MaxDisk: 1 << 20, Compress: true})
@@ -178,11 +313,44 @@ func TestIndexEntryMetadata(t *testing.T) {
 	}
 }
 
+func TestIndexChecksums(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		r, err := New(Config{Dir: dir, MaxFile: 200, MaxDisk: 1 << 20, Compress: compress})
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := []byte(`{"ts":"2024-01-01T00:00:00Z","msg":"checksummed line"}` + "\n")
+		for i := 0; i < 12; i++ {
+			if _, err := r.WriteLabeled(line, time.Now(), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		entries := readIndex(t, dir)
+		if len(entries) < 2 {
+			t.Fatalf("compress=%v: expected rotations, got %d entries", compress, len(entries))
+		}
+		for _, e := range entries {
+			data, err := os.ReadFile(filepath.Join(dir, e.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sum := sha256.Sum256(data); e.SHA256 != hex.EncodeToString(sum[:]) {
+				t.Errorf("compress=%v: %s SHA256 = %q, does not match the file", compress, e.File, e.SHA256)
+			}
+		}
+	}
+}
+
 func TestDiskCap(t *testing.T) {
 	dir := t.TempDir()
 	maxFile := int64(200)
-	// allow roughly 3 files worth of data
-	maxDisk := 3 * maxFile
+	// allow roughly 3 files worth of data, plus their index entries
+	maxDisk := 4 * maxFile
 
 	r, err := New(Config{Dir: dir, MaxFile: maxFile, MaxDisk: maxDisk})
 	if err != nil {
@@ -372,3 +540,27 @@ func totalDiskUsage(t *testing.T, dir string) int64 {
 	}
 	return total
 }
+
+func TestStats(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Dir: dir, MaxFile: 4096, MaxDisk: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	data := []byte(`{"ts":"2024-01-01T00:00:00Z","msg":"hello"}` + "\n")
+	if _, err := r.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.TrackLine(ts, nil)
+
+	st := r.Stats()
+	if st.ActiveFile == "" || st.ActiveSize != int64(len(data)) || st.ActiveLines != 1 {
+		t.Errorf("Stats = %+v", st)
+	}
+	if !st.From.Equal(ts) || st.MaxFile != 4096 || st.MaxDisk != 1<<20 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
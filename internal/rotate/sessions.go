@@ -0,0 +1,243 @@
+package rotate
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSession receives lines that carry no session label.
+	DefaultSession = "default"
+	// DefaultMaxSessions caps the sessions one receiver hosts; lines for
+	// further sessions go to DefaultSession.
+	DefaultMaxSessions = 256
+	maxSessionName     = 64
+)
+
+// Sessions hosts one capture per session in subdirectories of a base
+// directory, so several tap sessions can share one receiver. Lines are
+// routed by their session label; each session gets its own Rotator, disk
+// cap and index, created when its first line arrives.
+type Sessions struct {
+	base        string
+	cfg         Config
+	maxSessions int
+
+	onOpen        func(name, dir string)
+	onRotate      func(reason string)
+	onError       func()
+	onDiskWarning func(usage, cap int64)
+	onUpload      func(name string, size int64, err error)
+
+	mu       sync.Mutex
+	sessions map[string]*sessionCapture
+}
+
+type sessionCapture struct {
+	rot     *Rotator
+	dir     string
+	started time.Time
+	lines   int64
+	bytes   int64
+}
+
+// SessionStats summarizes one hosted session.
+type SessionStats struct {
+	Name      string    `json:"name"`
+	Dir       string    `json:"dir"`
+	Started   time.Time `json:"started"`
+	Lines     int64     `json:"lines"`
+	Bytes     int64     `json:"bytes"`
+	DiskUsage int64     `json:"disk_usage"`
+}
+
+// NewSessions creates a Sessions writing below base. cfg applies to every
+// session's rotator, MaxDisk included; cfg.Dir is ignored.
+func NewSessions(cfg Config, base string) *Sessions {
+	return &Sessions{
+		base:        base,
+		cfg:         cfg,
+		maxSessions: DefaultMaxSessions,
+		sessions:    make(map[string]*sessionCapture),
+	}
+}
+
+// SetMaxSessions caps the number of sessions hosted at once.
+func (s *Sessions) SetMaxSessions(n int) {
+	if n > 0 {
+		s.maxSessions = n
+	}
+}
+
+// SetOnOpen sets a callback invoked when a session's capture is created.
+func (s *Sessions) SetOnOpen(fn func(name, dir string)) { s.onOpen = fn }
+
+// SetOnRotate sets the rotation callback on every session.
+func (s *Sessions) SetOnRotate(fn func(reason string)) { s.onRotate = fn }
+
+// SetOnError sets the rotation error callback on every session.
+func (s *Sessions) SetOnError(fn func()) { s.onError = fn }
+
+// SetOnDiskWarning sets the disk warning callback on every session. Usage
+// and cap are reported per session.
+func (s *Sessions) SetOnDiskWarning(fn func(usage, cap int64)) { s.onDiskWarning = fn }
+
+// SetOnUpload sets the sink upload callback on every session.
+func (s *Sessions) SetOnUpload(fn func(name string, size int64, err error)) { s.onUpload = fn }
+
+// SessionName maps a session label to the directory name it is stored
+// under: characters other than letters, digits, '.', '_' and '-' become
+// '_', and the name is cut to 64 bytes. Empty names map to DefaultSession.
+func SessionName(label string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, label)
+	if len(name) > maxSessionName {
+		name = name[:maxSessionName]
+	}
+	if strings.Trim(name, ".") == "" {
+		return DefaultSession
+	}
+	return name
+}
+
+// WriteLabeled writes p to the capture of the session in labels, creating
+// it on first use.
+func (s *Sessions) WriteLabeled(p []byte, ts time.Time, labels map[string]string) (int, error) {
+	s.mu.Lock()
+	c, err := s.capture(SessionName(labels["session"]))
+	if err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	c.lines++
+	c.bytes += int64(len(p))
+	s.mu.Unlock()
+
+	return c.rot.WriteLabeled(p, ts, labels)
+}
+
+// capture returns the capture for name, opening it if needed; s.mu must be
+// held. Past the session cap lines fall back to DefaultSession.
+func (s *Sessions) capture(name string) (*sessionCapture, error) {
+	if c := s.sessions[name]; c != nil {
+		return c, nil
+	}
+	if len(s.sessions) >= s.maxSessions && name != DefaultSession {
+		return s.capture(DefaultSession)
+	}
+	cfg := s.cfg
+	cfg.Dir = filepath.Join(s.base, name)
+	r, err := New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", name, err)
+	}
+	if s.onRotate != nil {
+		r.SetOnRotate(s.onRotate)
+	}
+	if s.onError != nil {
+		r.SetOnError(s.onError)
+	}
+	if s.onDiskWarning != nil {
+		r.SetOnDiskWarning(s.onDiskWarning)
+	}
+	if s.onUpload != nil {
+		r.SetOnUpload(s.onUpload)
+	}
+	c := &sessionCapture{rot: r, dir: cfg.Dir, started: time.Now()}
+	s.sessions[name] = c
+	if s.onOpen != nil {
+		s.onOpen(name, cfg.Dir)
+	}
+	return c, nil
+}
+
+// names returns the hosted session names, sorted; s.mu must be held.
+func (s *Sessions) names() []string {
+	names := make([]string, 0, len(s.sessions))
+	for name := range s.sessions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sessions returns a summary of each hosted session, sorted by name.
+func (s *Sessions) Sessions() []SessionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SessionStats, 0, len(s.sessions))
+	for _, name := range s.names() {
+		c := s.sessions[name]
+		out = append(out, SessionStats{
+			Name:      name,
+			Dir:       c.dir,
+			Started:   c.started,
+			Lines:     c.lines,
+			Bytes:     c.bytes,
+			DiskUsage: c.rot.DiskUsage(),
+		})
+	}
+	return out
+}
+
+// DiskUsage returns the total bytes on disk across all sessions.
+func (s *Sessions) DiskUsage() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total int64
+	for _, c := range s.sessions {
+		total += c.rot.DiskUsage()
+	}
+	return total
+}
+
+// Stats returns the counters of each session's rotator, sorted by name.
+func (s *Sessions) Stats() []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]Stats, 0, len(s.sessions))
+	for _, name := range s.names() {
+		stats = append(stats, s.sessions[name].rot.Stats())
+	}
+	return stats
+}
+
+// Sync commits the open files of every session to stable storage.
+func (s *Sessions) Sync() error {
+	s.mu.Lock()
+	rots := make([]*Rotator, 0, len(s.sessions))
+	for _, c := range s.sessions {
+		rots = append(rots, c.rot)
+	}
+	s.mu.Unlock()
+	var errs []error
+	for _, r := range rots {
+		if err := r.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every session, writing their final index entries.
+func (s *Sessions) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for _, c := range s.sessions {
+		if err := c.rot.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
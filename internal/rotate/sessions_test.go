@@ -0,0 +1,89 @@
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSessions_RoutesBySessionLabel(t *testing.T) {
+	base := t.TempDir()
+	s := NewSessions(Config{MaxFile: 1 << 20, MaxDisk: 1 << 20}, base)
+	var opened []string
+	s.SetOnOpen(func(name, dir string) {
+		opened = append(opened, name)
+		if dir != filepath.Join(base, name) {
+			t.Errorf("session %s opened in %s", name, dir)
+		}
+	})
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, session := range []string{"load-a", "load-b", "load-a", ""} {
+		line := []byte(fmt.Sprintf(`{"ts":"2024-01-01T00:00:00Z","msg":"%d"}`+"\n", i))
+		labels := map[string]string{"app": "api"}
+		if session != "" {
+			labels["session"] = session
+		}
+		if _, err := s.WriteLabeled(line, ts, labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(opened) != 3 {
+		t.Errorf("opened %v, want load-a, load-b and default", opened)
+	}
+
+	got := make(map[string]int64)
+	for _, st := range s.Sessions() {
+		got[st.Name] = st.Lines
+	}
+	if got["load-a"] != 2 || got["load-b"] != 1 || got[DefaultSession] != 1 {
+		t.Errorf("lines per session = %v", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"load-a", "load-b", DefaultSession} {
+		if _, err := os.Stat(filepath.Join(base, name, "index.jsonl")); err != nil {
+			t.Errorf("session %s has no index: %v", name, err)
+		}
+	}
+}
+
+func TestSessions_MaxSessions(t *testing.T) {
+	s := NewSessions(Config{MaxFile: 1 << 20, MaxDisk: 1 << 20}, t.TempDir())
+	s.SetMaxSessions(2)
+	ts := time.Now()
+	for _, session := range []string{"a", "b", "c"} {
+		if _, err := s.WriteLabeled([]byte("{}\n"), ts, map[string]string{"session": session}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var names []string
+	for _, st := range s.Sessions() {
+		names = append(names, st.Name)
+	}
+	if fmt.Sprint(names) != "[a b default]" {
+		t.Errorf("sessions = %v, want the overflow in default", names)
+	}
+	_ = s.Close()
+}
+
+func TestSessionName(t *testing.T) {
+	tests := map[string]string{
+		"lt-a3f9":      "lt-a3f9",
+		"":             DefaultSession,
+		"..":           DefaultSession,
+		"../etc":       ".._etc",
+		"team a/run 1": "team_a_run_1",
+	}
+	for in, want := range tests {
+		if got := SessionName(in); got != want {
+			t.Errorf("SessionName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := SessionName(string(make([]byte, 100))); len(got) != maxSessionName {
+		t.Errorf("long name cut to %d bytes, want %d", len(got), maxSessionName)
+	}
+}
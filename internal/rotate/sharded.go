@@ -0,0 +1,138 @@
+package rotate
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/logtypes"
+)
+
+// Sharded spreads lines across rotators in several directories, choosing
+// the directory by a hash of the line's label set so each stream always
+// lands in the same shard. MaxDisk is split evenly between the shards.
+type Sharded struct {
+	shards []*Rotator
+	dirs   []string
+}
+
+// NewSharded creates one Rotator per directory using cfg for everything
+// but Dir. With a single directory it behaves like New.
+func NewSharded(cfg Config, dirs []string) (*Sharded, error) {
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no shard directories")
+	}
+	s := &Sharded{dirs: dirs}
+	shardCfg := cfg
+	shardCfg.MaxDisk = cfg.MaxDisk / int64(len(dirs))
+	for _, dir := range dirs {
+		shardCfg.Dir = dir
+		r, err := New(shardCfg)
+		if err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("shard %s: %w", dir, err)
+		}
+		s.shards = append(s.shards, r)
+	}
+	return s, nil
+}
+
+// Dirs returns the shard directories; the first is the primary.
+func (s *Sharded) Dirs() []string { return s.dirs }
+
+// ShardFor returns the index of the shard receiving lines with labels.
+func (s *Sharded) ShardFor(labels map[string]string) int {
+	if len(s.shards) == 1 {
+		return 0
+	}
+	return int(logtypes.StreamHash(labels) % uint32(len(s.shards)))
+}
+
+// WriteLabeled writes p to the shard owning labels and tracks the line in
+// that shard's index.
+func (s *Sharded) WriteLabeled(p []byte, ts time.Time, labels map[string]string) (int, error) {
+	r := s.shards[s.ShardFor(labels)]
+	return r.WriteLabeled(p, ts, labels)
+}
+
+// SetOnRotate sets the rotation callback on every shard.
+func (s *Sharded) SetOnRotate(fn func(reason string)) {
+	for _, r := range s.shards {
+		r.SetOnRotate(fn)
+	}
+}
+
+// SetOnError sets the rotation error callback on every shard.
+func (s *Sharded) SetOnError(fn func()) {
+	for _, r := range s.shards {
+		r.SetOnError(fn)
+	}
+}
+
+// SetOnDiskWarning sets the disk warning callback on every shard. Usage and
+// cap are reported per shard.
+func (s *Sharded) SetOnDiskWarning(fn func(usage, cap int64)) {
+	for _, r := range s.shards {
+		r.SetOnDiskWarning(fn)
+	}
+}
+
+// SetOnUpload sets the sink upload callback on every shard.
+func (s *Sharded) SetOnUpload(fn func(name string, size int64, err error)) {
+	for _, r := range s.shards {
+		r.SetOnUpload(fn)
+	}
+}
+
+// DiskUsage returns the total bytes on disk across all shards.
+func (s *Sharded) DiskUsage() int64 {
+	var total int64
+	for _, r := range s.shards {
+		total += r.DiskUsage()
+	}
+	return total
+}
+
+// Stats returns the counters of each shard, in directory order.
+func (s *Sharded) Stats() []Stats {
+	stats := make([]Stats, len(s.shards))
+	for i, r := range s.shards {
+		stats[i] = r.Stats()
+	}
+	return stats
+}
+
+// Sync commits the open files of every shard to stable storage.
+func (s *Sharded) Sync() error {
+	var errs []error
+	for _, r := range s.shards {
+		if err := r.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Recovered returns the paths of the files indexed after an unclean
+// shutdown, across all shards.
+func (s *Sharded) Recovered() []string {
+	var out []string
+	for i, r := range s.shards {
+		for _, name := range r.Recovered() {
+			out = append(out, filepath.Join(s.dirs[i], name))
+		}
+	}
+	return out
+}
+
+// Close closes every shard, writing their final index entries.
+func (s *Sharded) Close() error {
+	var errs []error
+	for _, r := range s.shards {
+		if err := r.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,75 @@
+package rotate
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSharded_StreamsStayOnOneShard(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir(), t.TempDir()}
+	s, err := NewSharded(Config{MaxFile: 1 << 20, MaxDisk: 3 << 20}, dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	used := make(map[int]bool)
+	for i := 0; i < 40; i++ {
+		labels := map[string]string{"app": fmt.Sprintf("svc-%d", i%8), "ns": "prod"}
+		shard := s.ShardFor(labels)
+		if again := s.ShardFor(map[string]string{"ns": "prod", "app": labels["app"]}); again != shard {
+			t.Fatalf("shard for %v changed with map order: %d then %d", labels, shard, again)
+		}
+		used[shard] = true
+		line := []byte(fmt.Sprintf(`{"ts":"2024-01-01T00:00:00Z","msg":"%d"}`+"\n", i))
+		if _, err := s.WriteLabeled(line, ts, labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(used) < 2 {
+		t.Errorf("8 streams all hashed to shard %v", used)
+	}
+
+	var lines int64
+	for i, st := range s.Stats() {
+		lines += st.ActiveLines
+		if st.MaxDisk != 1<<20 {
+			t.Errorf("shard %d MaxDisk = %d, want an even third", i, st.MaxDisk)
+		}
+		if !strings.HasPrefix(filepath.Join(dirs[i], st.ActiveFile), dirs[i]) {
+			t.Errorf("shard %d writes outside its dir: %s", i, st.ActiveFile)
+		}
+	}
+	if lines != 40 {
+		t.Errorf("tracked %d lines across shards, want 40", lines)
+	}
+	if s.DiskUsage() == 0 {
+		t.Error("DiskUsage = 0 after writes")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharded_SingleDir(t *testing.T) {
+	s, err := NewSharded(Config{MaxFile: 1 << 20, MaxDisk: 1 << 20}, []string{t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	if got := s.ShardFor(map[string]string{"app": "x"}); got != 0 {
+		t.Errorf("ShardFor = %d, want 0", got)
+	}
+	if st := s.Stats(); len(st) != 1 || st[0].MaxDisk != 1<<20 {
+		t.Errorf("Stats = %+v, want one shard with the full cap", st)
+	}
+}
+
+func TestNewSharded_NoDirs(t *testing.T) {
+	if _, err := NewSharded(Config{MaxFile: 1, MaxDisk: 1}, nil); err == nil {
+		t.Error("expected error without directories")
+	}
+}
@@ -0,0 +1,186 @@
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/cloud"
+)
+
+// OffloadFile maps data files copied to object storage to their URLs. It
+// is the manifest archive.Reader reads to fetch files no longer on disk.
+const OffloadFile = "offload.json"
+
+const (
+	uploadQueue    = 1024
+	uploadAttempts = 3
+	uploadTimeout  = 5 * time.Minute
+)
+
+// Sink copies finished segments off the capture host.
+type Sink interface {
+	// Store copies the content of r, the file name in capture directory
+	// dir, to remote storage and returns the URL it is stored under.
+	Store(ctx context.Context, dir, name string, r io.Reader, size int64) (string, error)
+}
+
+// CloudSink stores segments in S3 or GCS below a URL prefix, keeping the
+// layout of the capture directory: files of a session directory go below
+// the session's name.
+type CloudSink struct {
+	backend cloud.Backend
+	scheme  string
+	bucket  string
+	prefix  string
+	base    string
+}
+
+// NewCloudSink creates a sink storing files of the capture in base below
+// url (s3://bucket/prefix or gs://bucket/prefix) through backend.
+func NewCloudSink(backend cloud.Backend, url, base string) (*CloudSink, error) {
+	scheme, bucket, prefix, err := cloud.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudSink{backend: backend, scheme: scheme, bucket: bucket, prefix: prefix, base: base}, nil
+}
+
+// Store uploads r to the key of name in dir.
+func (s *CloudSink) Store(ctx context.Context, dir, name string, r io.Reader, size int64) (string, error) {
+	rel, err := filepath.Rel(s.base, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is outside the capture directory %s", dir, s.base)
+	}
+	key := path.Join(s.prefix, filepath.ToSlash(rel), name)
+	if err := s.backend.Upload(ctx, key, r, size); err != nil {
+		return "", err
+	}
+	return s.scheme + "://" + s.bucket + "/" + key, nil
+}
+
+// StoreFile copies the file name in dir to sink.
+func StoreFile(ctx context.Context, sink Sink, dir, name string) (string, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return sink.Store(ctx, dir, name, f, info.Size())
+}
+
+// readOffload loads the offload manifest of the rotator's directory.
+func (r *Rotator) readOffload() error {
+	r.offloaded = make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(r.cfg.Dir, OffloadFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var m struct {
+		Files map[string]string `json:"files"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("parse %s: %w", OffloadFile, err)
+	}
+	for name, url := range m.Files {
+		r.offloaded[name] = url
+	}
+	return nil
+}
+
+// writeOffload rewrites the offload manifest; r.mu must be held.
+func (r *Rotator) writeOffload() error {
+	data, err := json.MarshalIndent(struct {
+		Files map[string]string `json:"files"`
+	}{r.offloaded}, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(r.cfg.Dir, OffloadFile)
+	if err := os.WriteFile(p+".tmp", append(data, '\n'), 0o640); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// queueUpload hands a finished segment to the upload worker; r.mu must be
+// held. A full queue fails the upload rather than blocking writes.
+func (r *Rotator) queueUpload(name string) {
+	if r.uploads == nil {
+		return
+	}
+	select {
+	case r.uploads <- name:
+	default:
+		if r.onUpload != nil {
+			r.onUpload(name, 0, fmt.Errorf("upload queue full"))
+		}
+	}
+}
+
+// runUploads copies queued segments to the sink until the queue is closed.
+func (r *Rotator) runUploads(uploads <-chan string) {
+	defer close(r.uploadsDone)
+	for name := range uploads {
+		size, err := r.upload(name)
+		if r.onUpload != nil {
+			r.onUpload(name, size, err)
+		}
+	}
+}
+
+// upload stores one segment, records it in the offload manifest and
+// refreshes the remote copy of the index.
+func (r *Rotator) upload(name string) (int64, error) {
+	info, err := os.Stat(filepath.Join(r.cfg.Dir, name))
+	if err != nil {
+		return 0, err
+	}
+	var url string
+	for attempt := 0; attempt < uploadAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+		url, err = StoreFile(ctx, r.cfg.Sink, r.cfg.Dir, name)
+		cancel()
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("upload %s: %w", name, err)
+	}
+
+	r.mu.Lock()
+	r.offloaded[name] = url
+	err = r.writeOffload()
+	index, rerr := os.ReadFile(filepath.Join(r.cfg.Dir, "index.jsonl"))
+	r.mu.Unlock()
+	if err != nil {
+		return info.Size(), fmt.Errorf("write %s: %w", OffloadFile, err)
+	}
+	if rerr != nil {
+		return info.Size(), rerr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+	defer cancel()
+	if _, err := r.cfg.Sink.Store(ctx, r.cfg.Dir, "index.jsonl", bytes.NewReader(index), int64(len(index))); err != nil {
+		return info.Size(), fmt.Errorf("upload index: %w", err)
+	}
+	return info.Size(), nil
+}
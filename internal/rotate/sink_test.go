@@ -0,0 +1,190 @@
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ppiankov/logtap/internal/cloud"
+)
+
+// memSink keeps stored files by "dir/name".
+type memSink struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *memSink) Store(_ context.Context, dir, name string, r io.Reader, _ int64) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = make(map[string][]byte)
+	}
+	s.files[filepath.Join(dir, name)] = data
+	return "s3://bucket/" + name, nil
+}
+
+func (s *memSink) has(dir, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[filepath.Join(dir, name)]
+	return ok
+}
+
+func writeLines(t *testing.T, r *Rotator, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		line := []byte(`{"ts":"2024-01-01T00:00:00Z","msg":"0123456789012345678901234567890123456789"}` + "\n")
+		if _, err := r.Write(line); err != nil {
+			t.Fatal(err)
+		}
+		r.TrackLine(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), map[string]string{"app": "api"})
+	}
+}
+
+func readOffloadFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, OffloadFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Files map[string]string `json:"files"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m.Files
+}
+
+func TestRotator_SinkUploadsSegments(t *testing.T) {
+	dir := t.TempDir()
+	sink := &memSink{}
+	r, err := New(Config{Dir: dir, MaxFile: 100, MaxDisk: 1 << 20, Compress: true, Sink: sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uploaded []string
+	var mu sync.Mutex
+	r.SetOnUpload(func(name string, size int64, err error) {
+		if err != nil || size == 0 {
+			t.Errorf("upload %s: size %d, err %v", name, size, err)
+		}
+		mu.Lock()
+		uploaded = append(uploaded, name)
+		mu.Unlock()
+	})
+	writeLines(t, r, 3)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexed []string
+	for _, line := range strings.Split(strings.TrimSpace(string(index)), "\n") {
+		var e IndexEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		indexed = append(indexed, e.File)
+	}
+	sort.Strings(uploaded)
+	if strings.Join(uploaded, ",") != strings.Join(indexed, ",") || len(indexed) != 3 {
+		t.Errorf("uploaded %v, indexed %v", uploaded, indexed)
+	}
+	offloaded := readOffloadFiles(t, dir)
+	for _, name := range indexed {
+		if !strings.HasSuffix(name, ".jsonl.zst") || !sink.has(dir, name) || offloaded[name] != "s3://bucket/"+name {
+			t.Errorf("%s: stored %v, offload URL %q", name, sink.has(dir, name), offloaded[name])
+		}
+	}
+	sink.mu.Lock()
+	remoteIndex := sink.files[filepath.Join(dir, "index.jsonl")]
+	sink.mu.Unlock()
+	if !bytes.Equal(remoteIndex, index) {
+		t.Errorf("remote index = %q, want the final index", remoteIndex)
+	}
+}
+
+func TestRotator_SinkDiskCapKeepsIndex(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Dir: dir, MaxFile: 100, MaxDisk: 350, Sink: &memSink{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan string, 10)
+	// later segments may be removed by the cap before they are uploaded
+	r.SetOnUpload(func(name string, _ int64, err error) {
+		if err == nil {
+			done <- name
+		}
+	})
+
+	writeLines(t, r, 2) // rotates once
+	first := <-done
+	writeLines(t, r, 3) // over the cap: the uploaded segment goes first
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, first)); !os.IsNotExist(err) {
+		t.Errorf("%s still on disk: %v", first, err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "index.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), first) {
+		t.Errorf("index lost the uploaded segment %s:\n%s", first, index)
+	}
+}
+
+// fakeBackend records uploaded keys.
+type fakeBackend struct {
+	cloud.Backend
+	keys []string
+}
+
+func (b *fakeBackend) Upload(_ context.Context, key string, r io.Reader, _ int64) error {
+	_, _ = io.Copy(io.Discard, r)
+	b.keys = append(b.keys, key)
+	return nil
+}
+
+func TestCloudSink(t *testing.T) {
+	base := t.TempDir()
+	b := &fakeBackend{}
+	sink, err := NewCloudSink(b, "s3://bucket/soak/run1/", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := sink.Store(context.Background(), base, "a.jsonl.zst", strings.NewReader("x"), 1)
+	if err != nil || url != "s3://bucket/soak/run1/a.jsonl.zst" {
+		t.Errorf("Store = %q, %v", url, err)
+	}
+	url, err = sink.Store(context.Background(), filepath.Join(base, "lt-a3f9"), "b.jsonl", strings.NewReader("x"), 1)
+	if err != nil || url != "s3://bucket/soak/run1/lt-a3f9/b.jsonl" {
+		t.Errorf("session Store = %q, %v", url, err)
+	}
+	if _, err := sink.Store(context.Background(), t.TempDir(), "c.jsonl", strings.NewReader("x"), 1); err == nil {
+		t.Error("expected error for a directory outside the capture")
+	}
+	if _, err := NewCloudSink(b, "ftp://bucket", base); err == nil {
+		t.Error("expected error for an unsupported URL")
+	}
+}
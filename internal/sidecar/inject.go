@@ -68,6 +68,9 @@ func Inject(ctx context.Context, c *k8s.Client, w *k8s.Workload, cfg SidecarConf
 		}
 	} else {
 		container = BuildContainer(cfg)
+		if cfg.SpoolSize != "" {
+			volumes = append(volumes, SpoolVolume(cfg))
+		}
 	}
 
 	ps := k8s.PatchSpec{
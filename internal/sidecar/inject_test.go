@@ -288,3 +288,68 @@ func TestInject_DryRun(t *testing.T) {
 		t.Errorf("containers = %d, want 1 (should not be modified)", len(original.Spec.Template.Spec.Containers))
 	}
 }
+
+func TestInject_Spool(t *testing.T) {
+	deploy := makeDeployment("api-gw")
+	cs := fake.NewSimpleClientset(deploy) //nolint:staticcheck // NewClientset requires generated apply configs
+	c := k8s.NewClientFromInterface(cs, "default")
+	ctx := context.Background()
+
+	for _, session := range []string{"lt-a3f9", "lt-b001"} {
+		w, err := k8s.DiscoverByName(ctx, c, k8s.KindDeployment, "api-gw")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := SidecarConfig{SessionID: session, Target: "logtap:9000", SpoolSize: "256Mi"}
+		if _, err := Inject(ctx, c, w, cfg, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	updated, err := cs.AppsV1().Deployments("default").Get(ctx, "api-gw", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vols := updated.Spec.Template.Spec.Volumes
+	if len(vols) != 2 || vols[0].Name != SpoolVolumeName("lt-a3f9") || vols[0].EmptyDir == nil {
+		t.Fatalf("volumes = %+v, want one spool emptyDir per session", vols)
+	}
+	if got := vols[0].EmptyDir.SizeLimit.String(); got != "256Mi" {
+		t.Errorf("sizeLimit = %s, want 256Mi", got)
+	}
+	mounts := updated.Spec.Template.Spec.Containers[1].VolumeMounts
+	if len(mounts) != 1 || mounts[0].Name != vols[0].Name || mounts[0].MountPath != SpoolMountPath {
+		t.Errorf("mounts = %+v", mounts)
+	}
+
+	w, err := k8s.DiscoverByName(ctx, c, k8s.KindDeployment, "api-gw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Remove(ctx, c, w, "lt-a3f9", false); err != nil {
+		t.Fatal(err)
+	}
+	updated, err = cs.AppsV1().Deployments("default").Get(ctx, "api-gw", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vols = updated.Spec.Template.Spec.Volumes
+	if len(vols) != 1 || vols[0].Name != SpoolVolumeName("lt-b001") {
+		t.Errorf("volumes after untap = %+v, want only lt-b001's spool", vols)
+	}
+
+	w, err = k8s.DiscoverByName(ctx, c, k8s.KindDeployment, "api-gw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RemoveAll(ctx, c, w, false); err != nil {
+		t.Fatal(err)
+	}
+	updated, err = cs.AppsV1().Deployments("default").Get(ctx, "api-gw", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(updated.Spec.Template.Spec.Volumes); n != 0 {
+		t.Errorf("volumes after untap --all = %d, want 0", n)
+	}
+}
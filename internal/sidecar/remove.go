@@ -41,10 +41,11 @@ func Remove(ctx context.Context, c *k8s.Client, w *k8s.Workload, sessionID strin
 
 	rs := k8s.RemovePatchSpec{
 		ContainerNames: []string{containerName},
+		VolumeNames:    []string{SpoolVolumeName(sessionID)},
 	}
 
 	if isFluentBit {
-		rs.VolumeNames = FluentBitVolumeNames()
+		rs.VolumeNames = append(rs.VolumeNames, FluentBitVolumeNames()...)
 		// Clean up ConfigMap
 		_ = DeleteFluentBitConfigMap(ctx, c, sessionID, dryRun)
 	}
@@ -84,12 +85,15 @@ func RemoveAll(ctx context.Context, c *k8s.Client, w *k8s.Workload, dryRun bool)
 	isFluentBit := w.Annotations[AnnotationForwarder] == ForwarderFluentBit
 
 	containerNames := make([]string, len(sessions))
+	volumeNames := make([]string, len(sessions))
 	for i, s := range sessions {
 		containerNames[i] = ContainerPrefix + s
+		volumeNames[i] = SpoolVolumeName(s)
 	}
 
 	rs := k8s.RemovePatchSpec{
 		ContainerNames: containerNames,
+		VolumeNames:    volumeNames,
 		DeleteAnnotations: append(
 			[]string{AnnotationTapped, AnnotationTarget, AnnotationForwarder},
 			MeshBypassAnnotationKeys()...,
@@ -97,7 +101,7 @@ func RemoveAll(ctx context.Context, c *k8s.Client, w *k8s.Workload, dryRun bool)
 	}
 
 	if isFluentBit {
-		rs.VolumeNames = FluentBitVolumeNames()
+		rs.VolumeNames = append(rs.VolumeNames, FluentBitVolumeNames()...)
 		for _, s := range sessions {
 			_ = DeleteFluentBitConfigMap(ctx, c, s, dryRun)
 		}
@@ -0,0 +1,56 @@
+package sidecar
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// TargetRoute sends workloads whose name matches Pattern (a path.Match glob,
+// e.g. "payments-*") to the receiver at Target.
+type TargetRoute struct {
+	Pattern string
+	Target  string
+}
+
+// ParseTargets parses --target values. A plain "host:port" is the default
+// receiver; "pattern=host:port" routes matching workloads to their own
+// receiver, so one large tap can spread load over several receivers. At most
+// one default is allowed; routes are tried in order.
+func ParseTargets(specs []string) (def string, routes []TargetRoute, err error) {
+	for _, spec := range specs {
+		pattern, target, routed := strings.Cut(spec, "=")
+		if !routed {
+			target = spec
+		}
+		if err := ValidateTarget(target); err != nil {
+			return "", nil, err
+		}
+		if !routed {
+			if def != "" {
+				return "", nil, fmt.Errorf("invalid --target %q: default receiver already set to %q (route groups with pattern=host:port)", spec, def)
+			}
+			def = target
+			continue
+		}
+		if pattern == "" {
+			return "", nil, fmt.Errorf("invalid --target %q: empty workload pattern", spec)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return "", nil, fmt.Errorf("invalid --target %q: bad workload pattern: %w", spec, err)
+		}
+		routes = append(routes, TargetRoute{Pattern: pattern, Target: target})
+	}
+	return def, routes, nil
+}
+
+// RouteTarget returns the receiver for a workload: the target of the first
+// route whose pattern matches name, else def (which may be empty).
+func RouteTarget(name, def string, routes []TargetRoute) string {
+	for _, r := range routes {
+		if ok, _ := path.Match(r.Pattern, name); ok {
+			return r.Target
+		}
+	}
+	return def
+}
@@ -0,0 +1,54 @@
+package sidecar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	def, routes, err := ParseTargets([]string{"payments-*=receiver-a:3100", "receiver-b:3100", "checkout=receiver-c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def != "receiver-b:3100" {
+		t.Errorf("default = %q", def)
+	}
+	if len(routes) != 2 || routes[0] != (TargetRoute{Pattern: "payments-*", Target: "receiver-a:3100"}) {
+		t.Errorf("routes = %+v", routes)
+	}
+
+	tests := map[string]struct {
+		name, want string
+	}{
+		"route":    {"payments-api", "receiver-a:3100"},
+		"exact":    {"checkout", "receiver-c"},
+		"fallback": {"frontend", "receiver-b:3100"},
+	}
+	for label, tt := range tests {
+		if got := RouteTarget(tt.name, def, routes); got != tt.want {
+			t.Errorf("%s: RouteTarget(%q) = %q, want %q", label, tt.name, got, tt.want)
+		}
+	}
+	if got := RouteTarget("frontend", "", routes); got != "" {
+		t.Errorf("no default: got %q, want empty", got)
+	}
+}
+
+func TestParseTargetsInvalid(t *testing.T) {
+	tests := []struct {
+		specs   []string
+		wantErr string
+	}{
+		{[]string{"a:3100", "b:3100"}, "default receiver already set"},
+		{[]string{"=a:3100"}, "empty workload pattern"},
+		{[]string{"pay[=a:3100"}, "bad workload pattern"},
+		{[]string{"pay=host;rm"}, "invalid --target"},
+		{[]string{""}, "invalid --target"},
+	}
+	for _, tt := range tests {
+		_, _, err := ParseTargets(tt.specs)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ParseTargets(%q) = %v, want %q", tt.specs, err, tt.wantErr)
+		}
+	}
+}
@@ -1,8 +1,20 @@
 package sidecar
 
 import (
+	"context"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/ppiankov/logtap/internal/k8s"
 )
 
 // GenerateSessionID returns a unique session identifier in the format
@@ -15,3 +27,148 @@ func GenerateSessionID() (string, error) {
 	}
 	return fmt.Sprintf("lt-%016x", b), nil
 }
+
+// LabelSessionRecord marks the ConfigMaps holding session records.
+const LabelSessionRecord = "logtap.dev/session-record"
+
+// sessionDataKey is the ConfigMap key holding the session as JSON.
+const sessionDataKey = "session.json"
+
+// Session records the workloads tapped together by one tap, so untap and
+// status can treat them as a unit. It is kept in a ConfigMap in the
+// workloads' namespace, named by SessionConfigMapName.
+type Session struct {
+	ID        string            `json:"id"`
+	Created   time.Time         `json:"created"`
+	Forwarder string            `json:"forwarder"`
+	Workloads []SessionWorkload `json:"workloads"`
+}
+
+// SessionWorkload is a workload of a session and the receiver it sends to.
+type SessionWorkload struct {
+	Kind   k8s.WorkloadKind `json:"kind"`
+	Name   string           `json:"name"`
+	Target string           `json:"target"`
+}
+
+// Has reports whether the session covers workload w.
+func (s *Session) Has(w *k8s.Workload) bool {
+	return slices.ContainsFunc(s.Workloads, func(sw SessionWorkload) bool {
+		return sw.Kind == w.Kind && sw.Name == w.Name
+	})
+}
+
+// Without returns the session with workload w removed.
+func (s *Session) Without(w *k8s.Workload) *Session {
+	out := *s
+	out.Workloads = slices.DeleteFunc(slices.Clone(s.Workloads), func(sw SessionWorkload) bool {
+		return sw.Kind == w.Kind && sw.Name == w.Name
+	})
+	return &out
+}
+
+// Targets lists the receivers of the session, in workload order.
+func (s *Session) Targets() []string {
+	var targets []string
+	for _, w := range s.Workloads {
+		if !slices.Contains(targets, w.Target) {
+			targets = append(targets, w.Target)
+		}
+	}
+	return targets
+}
+
+// SessionConfigMapName returns the name of the ConfigMap recording a session.
+func SessionConfigMapName(sessionID string) string {
+	return "logtap-session-" + sessionID
+}
+
+// SaveSession creates or replaces the record of s.
+func SaveSession(ctx context.Context, c *k8s.Client, s *Session, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SessionConfigMapName(s.ID),
+			Namespace: c.NS,
+			Labels: map[string]string{
+				k8s.LabelManagedBy:   k8s.ManagedByValue,
+				"logtap.dev/session": s.ID,
+				LabelSessionRecord:   "true",
+			},
+		},
+		Data: map[string]string{sessionDataKey: string(data)},
+	}
+	cms := c.CS.CoreV1().ConfigMaps(c.NS)
+	if _, err = cms.Create(ctx, cm, metav1.CreateOptions{}); apierrors.IsAlreadyExists(err) {
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("save session %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// LoadSession returns the record of a session, or nil when it has none,
+// as for sessions tapped by earlier releases.
+func LoadSession(ctx context.Context, c *k8s.Client, sessionID string) (*Session, error) {
+	cm, err := c.CS.CoreV1().ConfigMaps(c.NS).Get(ctx, SessionConfigMapName(sessionID), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load session %s: %w", sessionID, err)
+	}
+	return parseSession(cm)
+}
+
+// ListSessions returns the session records of the namespace, oldest first.
+func ListSessions(ctx context.Context, c *k8s.Client) ([]*Session, error) {
+	list, err := c.CS.CoreV1().ConfigMaps(c.NS).List(ctx, metav1.ListOptions{LabelSelector: LabelSessionRecord + "=true"})
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	var sessions []*Session
+	for i := range list.Items {
+		s, err := parseSession(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].Created.Equal(sessions[j].Created) {
+			return sessions[i].Created.Before(sessions[j].Created)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+// DeleteSession removes the record of a session, if it has one.
+func DeleteSession(ctx context.Context, c *k8s.Client, sessionID string, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+	err := c.CS.CoreV1().ConfigMaps(c.NS).Delete(ctx, SessionConfigMapName(sessionID), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete session %s: %w", sessionID, err)
+	}
+	return nil
+}
+
+func parseSession(cm *corev1.ConfigMap) (*Session, error) {
+	var s Session
+	if err := json.Unmarshal([]byte(cm.Data[sessionDataKey]), &s); err != nil {
+		return nil, fmt.Errorf("parse session record %s: %w", cm.Name, err)
+	}
+	if s.ID == "" {
+		s.ID = strings.TrimPrefix(cm.Name, SessionConfigMapName(""))
+	}
+	return &s, nil
+}
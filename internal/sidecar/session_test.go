@@ -1,8 +1,15 @@
 package sidecar
 
 import (
+	"context"
 	"regexp"
+	"slices"
 	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/ppiankov/logtap/internal/k8s"
 )
 
 func TestGenerateSessionID_Format(t *testing.T) {
@@ -33,3 +40,75 @@ func TestGenerateSessionID_Unique(t *testing.T) {
 		seen[id] = true
 	}
 }
+
+func TestSessionWorkloads(t *testing.T) {
+	s := &Session{ID: "lt-abc", Workloads: []SessionWorkload{
+		{Kind: k8s.KindDeployment, Name: "api", Target: "recv:3100"},
+		{Kind: k8s.KindStatefulSet, Name: "db", Target: "recv:3100"},
+		{Kind: k8s.KindDaemonSet, Name: "agent", Target: "recv2:3100"},
+	}}
+	api := &k8s.Workload{Kind: k8s.KindDeployment, Name: "api"}
+	if !s.Has(api) || s.Has(&k8s.Workload{Kind: k8s.KindStatefulSet, Name: "api"}) {
+		t.Error("Has matched by name only")
+	}
+	if got := s.Targets(); !slices.Equal(got, []string{"recv:3100", "recv2:3100"}) {
+		t.Errorf("Targets = %v", got)
+	}
+	rest := s.Without(api)
+	if rest.Has(api) || len(rest.Workloads) != 2 || len(s.Workloads) != 3 {
+		t.Errorf("Without = %+v, original %+v", rest.Workloads, s.Workloads)
+	}
+}
+
+func TestSessionRecord(t *testing.T) {
+	cs := fake.NewSimpleClientset() //nolint:staticcheck // NewClientset requires generated apply configs
+	c := k8s.NewClientFromInterface(cs, "default")
+	ctx := context.Background()
+	created := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	s := &Session{ID: "lt-abc", Created: created, Workloads: []SessionWorkload{
+		{Kind: k8s.KindDeployment, Name: "api", Target: "recv:3100"},
+	}}
+	if err := SaveSession(ctx, c, s, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := LoadSession(ctx, c, "lt-abc"); err != nil || got != nil {
+		t.Fatalf("dry-run saved session: %+v, %v", got, err)
+	}
+	if err := SaveSession(ctx, c, s, false); err != nil {
+		t.Fatal(err)
+	}
+	s.Workloads = append(s.Workloads, SessionWorkload{Kind: k8s.KindDaemonSet, Name: "agent", Target: "recv:3100"})
+	if err := SaveSession(ctx, c, s, false); err != nil { // update
+		t.Fatal(err)
+	}
+	older := &Session{ID: "lt-old", Created: created.Add(-time.Hour)}
+	if err := SaveSession(ctx, c, older, false); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadSession(ctx, c, "lt-abc")
+	if err != nil || got == nil {
+		t.Fatalf("LoadSession = %+v, %v", got, err)
+	}
+	if !got.Created.Equal(created) || len(got.Workloads) != 2 {
+		t.Errorf("loaded %+v", got)
+	}
+	list, err := ListSessions(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "lt-old" || list[1].ID != "lt-abc" {
+		t.Errorf("ListSessions = %+v", list)
+	}
+
+	if err := DeleteSession(ctx, c, "lt-abc", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteSession(ctx, c, "lt-abc", false); err != nil {
+		t.Errorf("deleting a missing session: %v", err)
+	}
+	if got, _ := LoadSession(ctx, c, "lt-abc"); got != nil {
+		t.Errorf("session still recorded: %+v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package sidecar
 
 import (
+	"strconv"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -18,6 +19,12 @@ const (
 	DefaultCPUReq    = "25m"
 	DefaultCPULimit  = "50m"
 	HealthPort       = 9091
+
+	// SpoolVolumePrefix prefixes the per-session emptyDir volume the
+	// forwarder spools undelivered batches to.
+	SpoolVolumePrefix = "logtap-spool-"
+	// SpoolMountPath is where the spool volume is mounted in the sidecar.
+	SpoolMountPath = "/var/spool/logtap"
 )
 
 // SidecarConfig holds parameters for building the forwarder sidecar container.
@@ -30,7 +37,11 @@ type SidecarConfig struct {
 	MemLimit   string
 	CPURequest string
 	CPULimit   string
-	PinImages  bool // change imagePullPolicy Always → IfNotPresent on existing containers
+	PinImages  bool   // change imagePullPolicy Always → IfNotPresent on existing containers
+	Sanitize   string // forwarder line sanitization (ansi, control, all); empty disables
+	AuthToken  string // bearer token the forwarder pushes with; empty sends none
+	SpoolSize  string // size limit of the emptyDir spool volume (e.g. 256Mi); empty disables spooling
+	TLSPin     string // SHA-256 fingerprint the receiver's certificate must match; empty verifies normally
 }
 
 // ContainerName returns the sidecar container name for this session.
@@ -61,19 +72,42 @@ func BuildContainer(cfg SidecarConfig) corev1.Container {
 		cpuLimit = DefaultCPULimit
 	}
 
+	env := []corev1.EnvVar{
+		{Name: "LOGTAP_TARGET", Value: cfg.Target},
+		{Name: "LOGTAP_SESSION", Value: cfg.SessionID},
+		{Name: "LOGTAP_POD_NAME", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+		}},
+		{Name: "LOGTAP_NAMESPACE", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+		}},
+	}
+	if cfg.Sanitize != "" {
+		env = append(env, corev1.EnvVar{Name: "LOGTAP_SANITIZE", Value: cfg.Sanitize})
+	}
+	if cfg.AuthToken != "" {
+		env = append(env, corev1.EnvVar{Name: "LOGTAP_AUTH_TOKEN", Value: cfg.AuthToken})
+	}
+	if cfg.TLSPin != "" {
+		env = append(env, corev1.EnvVar{Name: "LOGTAP_TLS_PIN", Value: cfg.TLSPin})
+	}
+	var mounts []corev1.VolumeMount
+	if cfg.SpoolSize != "" {
+		// leave headroom below the volume limit: the kubelet evicts the pod
+		// when an emptyDir outgrows its sizeLimit
+		limit := resource.MustParse(cfg.SpoolSize)
+		env = append(env,
+			corev1.EnvVar{Name: "LOGTAP_SPILL_DIR", Value: SpoolMountPath},
+			corev1.EnvVar{Name: "LOGTAP_SPILL_SIZE", Value: strconv.FormatInt(limit.Value()*9/10, 10)},
+		)
+		mounts = append(mounts, corev1.VolumeMount{Name: SpoolVolumeName(cfg.SessionID), MountPath: SpoolMountPath})
+	}
+
 	return corev1.Container{
-		Name:  cfg.ContainerName(),
-		Image: image,
-		Env: []corev1.EnvVar{
-			{Name: "LOGTAP_TARGET", Value: cfg.Target},
-			{Name: "LOGTAP_SESSION", Value: cfg.SessionID},
-			{Name: "LOGTAP_POD_NAME", ValueFrom: &corev1.EnvVarSource{
-				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
-			}},
-			{Name: "LOGTAP_NAMESPACE", ValueFrom: &corev1.EnvVarSource{
-				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
-			}},
-		},
+		Name:         cfg.ContainerName(),
+		Image:        image,
+		Env:          env,
+		VolumeMounts: mounts,
 		LivenessProbe: &corev1.Probe{
 			ProbeHandler: corev1.ProbeHandler{
 				HTTPGet: &corev1.HTTPGetAction{
@@ -104,6 +138,23 @@ func BuildContainer(cfg SidecarConfig) corev1.Container {
 	}
 }
 
+// SpoolVolumeName returns the spool volume name for a session.
+func SpoolVolumeName(sessionID string) string {
+	return SpoolVolumePrefix + sessionID
+}
+
+// SpoolVolume returns the size-limited emptyDir volume backing the
+// forwarder spool of cfg's session.
+func SpoolVolume(cfg SidecarConfig) corev1.Volume {
+	limit := resource.MustParse(cfg.SpoolSize)
+	return corev1.Volume{
+		Name: SpoolVolumeName(cfg.SessionID),
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &limit},
+		},
+	}
+}
+
 // Annotations returns the annotation key-value pairs for a tapped workload.
 func Annotations(cfg SidecarConfig) map[string]string {
 	return map[string]string{
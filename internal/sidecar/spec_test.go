@@ -76,6 +76,83 @@ func TestBuildContainer_EnvVars(t *testing.T) {
 	}
 }
 
+func TestBuildContainer_Sanitize(t *testing.T) {
+	hasEnv := func(c SidecarConfig) (string, bool) {
+		for _, e := range BuildContainer(c).Env {
+			if e.Name == "LOGTAP_SANITIZE" {
+				return e.Value, true
+			}
+		}
+		return "", false
+	}
+
+	if _, ok := hasEnv(SidecarConfig{SessionID: "lt-a3f9", Target: "logtap:9000"}); ok {
+		t.Error("LOGTAP_SANITIZE should be unset by default")
+	}
+	if v, ok := hasEnv(SidecarConfig{SessionID: "lt-a3f9", Target: "logtap:9000", Sanitize: "ansi"}); !ok || v != "ansi" {
+		t.Errorf("LOGTAP_SANITIZE = %q, %v; want ansi", v, ok)
+	}
+}
+
+func TestBuildContainer_AuthToken(t *testing.T) {
+	token := func(c SidecarConfig) (string, bool) {
+		for _, e := range BuildContainer(c).Env {
+			if e.Name == "LOGTAP_AUTH_TOKEN" {
+				return e.Value, true
+			}
+		}
+		return "", false
+	}
+
+	if _, ok := token(SidecarConfig{SessionID: "lt-a3f9", Target: "logtap:9000"}); ok {
+		t.Error("LOGTAP_AUTH_TOKEN should be unset by default")
+	}
+	if v, ok := token(SidecarConfig{SessionID: "lt-a3f9", Target: "logtap:9000", AuthToken: "lt-a3f9.abc"}); !ok || v != "lt-a3f9.abc" {
+		t.Errorf("LOGTAP_AUTH_TOKEN = %q, %v; want lt-a3f9.abc", v, ok)
+	}
+}
+
+func TestBuildContainer_TLSPin(t *testing.T) {
+	pin := func(c SidecarConfig) (string, bool) {
+		for _, e := range BuildContainer(c).Env {
+			if e.Name == "LOGTAP_TLS_PIN" {
+				return e.Value, true
+			}
+		}
+		return "", false
+	}
+
+	if _, ok := pin(SidecarConfig{SessionID: "lt-a3f9", Target: "https://logtap:9000"}); ok {
+		t.Error("LOGTAP_TLS_PIN should be unset by default")
+	}
+	if v, ok := pin(SidecarConfig{SessionID: "lt-a3f9", Target: "https://logtap:9000", TLSPin: "ab12"}); !ok || v != "ab12" {
+		t.Errorf("LOGTAP_TLS_PIN = %q, %v; want ab12", v, ok)
+	}
+}
+
+func TestBuildContainer_Spool(t *testing.T) {
+	c := BuildContainer(SidecarConfig{SessionID: "lt-a3f9", Target: "logtap:9000", SpoolSize: "100Mi"})
+	env := make(map[string]string)
+	for _, e := range c.Env {
+		env[e.Name] = e.Value
+	}
+	if env["LOGTAP_SPILL_DIR"] != SpoolMountPath {
+		t.Errorf("LOGTAP_SPILL_DIR = %q, want %q", env["LOGTAP_SPILL_DIR"], SpoolMountPath)
+	}
+	// 90% of 100Mi leaves headroom below the emptyDir limit
+	if env["LOGTAP_SPILL_SIZE"] != "94371840" {
+		t.Errorf("LOGTAP_SPILL_SIZE = %q, want 94371840", env["LOGTAP_SPILL_SIZE"])
+	}
+	if len(c.VolumeMounts) != 1 || c.VolumeMounts[0].Name != "logtap-spool-lt-a3f9" {
+		t.Errorf("mounts = %+v", c.VolumeMounts)
+	}
+
+	c = BuildContainer(SidecarConfig{SessionID: "lt-a3f9", Target: "logtap:9000"})
+	if len(c.VolumeMounts) != 0 {
+		t.Errorf("mounts without spool = %+v", c.VolumeMounts)
+	}
+}
+
 func TestAnnotations(t *testing.T) {
 	cfg := SidecarConfig{
 		SessionID: "lt-a3f9",